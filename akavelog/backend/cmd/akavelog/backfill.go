@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/akave-ai/akavelog/internal/batchindex"
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/database"
+	"github.com/akave-ai/akavelog/internal/logger"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// backfillIndex indexes the batch objects already in O3 (akavelog backfill-index). Objects
+// that are indexed are skipped unless -force is given.
+func backfillIndex(args []string) error {
+	fs := flag.NewFlagSet("backfill-index", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "only index objects under this key prefix (default: logs and every stream's o3_prefix)")
+	force := fs.Bool("force", false, "re-index objects that are already indexed")
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfigFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	if store == nil {
+		return fmt.Errorf("storage is not configured")
+	}
+
+	loggerService := logger.NewLoggerService(cfg.Observability)
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+	defer loggerService.Shutdown()
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx, &log, cfg); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	defer db.Pool.Close()
+
+	prefixes := []string{*prefix}
+	if *prefix == "" {
+		streams, err := repository.NewStreamRepository(db.Pool).List(ctx)
+		if err != nil {
+			return fmt.Errorf("list streams: %w", err)
+		}
+		prefixes = storage.BatchPrefixes(streams)
+	}
+	st, err := batchindex.Backfill(ctx, store, repository.NewBatchRepository(db.Pool), prefixes, *force)
+	fmt.Fprintf(os.Stdout, "scanned %d objects: indexed %d, skipped %d, %d errors\n", st.Scanned, st.Indexed, st.Skipped, st.Errors)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/bench"
+)
+
+// benchCmd sends synthetic entries to an input and reports the throughput, latencies and
+// errors it achieved (akavelog bench). It needs no configuration: only the target.
+func benchCmd(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	var cfg bench.Config
+	fs.StringVar(&cfg.Target, "target", "", "http(s):// URL of an HTTP input, or tcp://host:port or udp://host:port of a socket input (required)")
+	fs.StringVar(&cfg.Token, "token", "", "ingest key or token of the input (HTTP targets)")
+	fs.IntVar(&cfg.EntrySize, "size", 256, "approximate bytes of one entry")
+	fs.IntVar(&cfg.Batch, "batch", 1, "entries per request")
+	fs.Float64Var(&cfg.Rate, "rate", 0, "entries per second over all workers (0: as fast as possible)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 4, "workers sending at once")
+	fs.DurationVar(&cfg.Duration, "duration", 10*time.Second, "how long to send")
+	fs.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "a request fails after this long")
+	fs.StringVar(&cfg.Service, "service", "bench", "service of the generated entries")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Parse(args)
+	if cfg.Target == "" {
+		return fmt.Errorf("-target is required")
+	}
+
+	// SIGINT stops the run early; what was sent so far is reported.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "sending to %s for %s with %d workers...\n", cfg.Target, cfg.Duration, cfg.Concurrency)
+	res, err := bench.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{
+			"result":             res,
+			"entries_per_second": res.EntriesPerSecond(),
+			"bytes_per_second":   res.BytesPerSecond(),
+			"error_rate":         res.ErrorRate(),
+		})
+	}
+	l := res.Latency
+	fmt.Fprintf(os.Stdout, "requests:   %d in %s, %d failed (%.2f%%)\n", res.Requests, res.Elapsed.Round(time.Millisecond), res.Errors, 100*res.ErrorRate())
+	fmt.Fprintf(os.Stdout, "throughput: %.1f entries/s, %.2f MiB/s (%d entries, %d bytes)\n",
+		res.EntriesPerSecond(), res.BytesPerSecond()/(1<<20), res.Entries, res.Bytes)
+	fmt.Fprintf(os.Stdout, "latency:    min %s  mean %s  p50 %s  p90 %s  p95 %s  p99 %s  max %s\n",
+		l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	kinds := make([]string, 0, len(res.ByError))
+	for kind := range res.ByError {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(os.Stdout, "  %-20s %d\n", kind, res.ByError[kind])
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/database"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/server"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// Outcomes of a doctor check. Only failed checks make doctor exit with an error.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+// reportFunc prints the outcome of a check.
+type reportFunc func(outcome, name, format string, args ...any)
+
+// doctor checks that the server can run with the configuration: that it is valid, the
+// database is reachable and migrated, the O3 bucket exists and may be written to, and the
+// ports of the server and the declared inputs are free (akavelog doctor). Each check prints
+// one line; doctor fails when any check does.
+func doctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	timeout := fs.Duration("timeout", 10*time.Second, "time each network check may take")
+	fs.Parse(args)
+
+	failed := 0
+	report := func(outcome, name, format string, args ...any) {
+		fmt.Fprintf(os.Stdout, "%-4s  %-8s  %s\n", outcome, name, fmt.Sprintf(format, args...))
+		if outcome == checkFail {
+			failed++
+		}
+	}
+
+	cfg, err := config.LoadConfigFile(*configPath)
+	if err != nil {
+		report(checkFail, "config", "%v", err)
+		return fmt.Errorf("configuration does not load")
+	}
+	if errs := server.ValidateConfig(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			report(checkFail, "config", "%v", err)
+		}
+	} else {
+		report(checkOK, "config", "loaded from %s", configSource(cfg))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	checkDatabase(ctx, cfg, report)
+	cancel()
+	ctx, cancel = context.WithTimeout(context.Background(), *timeout)
+	checkStorage(ctx, cfg, report)
+	cancel()
+	checkPorts(cfg, report)
+
+	if failed > 0 {
+		return fmt.Errorf("checks failed: %d", failed)
+	}
+	return nil
+}
+
+// checkDatabase connects to the database and compares its schema with the migrations.
+func checkDatabase(ctx context.Context, cfg *config.Config, report reportFunc) {
+	db := fmt.Sprintf("%s@%s/%s", cfg.Database.User, net.JoinHostPort(cfg.Database.Host, strconv.Itoa(cfg.Database.Port)), cfg.Database.Name)
+	current, list, err := database.Status(ctx, cfg)
+	if err != nil {
+		report(checkFail, "database", "%s: %v", db, err)
+		return
+	}
+	pending := 0
+	for _, m := range list {
+		switch m.State {
+		case database.MigrationModified:
+			report(checkFail, "database", "%s: migration %d (%s) was changed after it was applied", db, m.Version, m.Name)
+			return
+		case database.MigrationUnknown:
+			report(checkFail, "database", "%s: schema version %d is newer than this build's", db, current)
+			return
+		case database.MigrationPending:
+			pending++
+		}
+	}
+	if pending > 0 {
+		report(checkWarn, "database", "%s: schema version %d, %d migrations pending; serve or migrate up applies them", db, current, pending)
+		return
+	}
+	report(checkOK, "database", "%s: schema version %d", db, current)
+}
+
+// checkStorage checks that the bucket of the storage backend exists and that objects can be
+// written, read, listed and deleted in it, with a probe object under .akavelog-doctor/.
+func checkStorage(ctx context.Context, cfg *config.Config, report reportFunc) {
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		report(checkFail, "storage", "client: %v", err)
+		return
+	}
+	if store == nil {
+		report(checkWarn, "storage", "no storage backend is configured: logs are not stored")
+		return
+	}
+	name := store.Backend()
+	if err := store.HeadBucket(ctx); err != nil {
+		report(checkFail, name, "%s: %v", storageLocation(name, cfg.Storage), err)
+		return
+	}
+	report(checkOK, name, "%s exists", storageLocation(name, cfg.Storage))
+
+	host, _ := os.Hostname()
+	key := fmt.Sprintf(".akavelog-doctor/%s-%d", host, time.Now().UnixNano())
+	probe := []byte("akavelog doctor probe\n")
+	if err := store.PutObject(ctx, key, probe, "text/plain"); err != nil {
+		report(checkFail, name, "put %s: %v", key, err)
+		return
+	}
+	allowed := true
+	defer func() {
+		if err := store.DeleteObject(ctx, key); err != nil {
+			report(checkFail, name, "delete %s: %v", key, err)
+		} else if allowed {
+			report(checkOK, name, "put, get, list and delete allowed")
+		}
+	}()
+	if data, err := store.GetObject(ctx, key); err != nil {
+		report(checkFail, name, "get %s: %v", key, err)
+		allowed = false
+	} else if !bytes.Equal(data, probe) {
+		report(checkFail, name, "get %s: read back %d bytes that differ from the %d written", key, len(data), len(probe))
+		allowed = false
+	}
+	if _, err := store.ListObjects(ctx, ".akavelog-doctor/"); err != nil {
+		report(checkFail, name, "list: %v", err)
+		allowed = false
+	}
+}
+
+// storageLocation describes the bucket of backend in cfg.
+func storageLocation(backend string, cfg *config.StorageConfig) string {
+	switch backend {
+	case storage.BackendFS:
+		return "directory " + cfg.FS.Dir
+	case storage.BackendGCS:
+		return "bucket gs://" + cfg.GCS.Bucket
+	case storage.BackendAzure:
+		return "container " + cfg.Azure.Account + "/" + cfg.Azure.Container
+	case storage.BackendAkave:
+		return cfg.Akave.Endpoint + " bucket " + cfg.Akave.Bucket
+	}
+	return cfg.O3.Endpoint + " bucket " + cfg.O3.Bucket
+}
+
+// checkPorts checks that the server's port and the listen addresses of the running inputs
+// declared in the config file are free. Inputs created through the API are not checked.
+func checkPorts(cfg *config.Config, report reportFunc) {
+	checkListen(report, "tcp", ":"+cfg.Server.Port, "server")
+	for _, in := range cfg.Inputs {
+		addr, _ := in.Config["listen"].(string)
+		if addr == "" || (in.State != "" && model.InputState(in.State) != model.InputStateRunning) {
+			continue
+		}
+		network := "tcp"
+		if in.Type == "udp" || in.Type == "statsd" {
+			network = "udp"
+		}
+		checkListen(report, network, addr, "input "+in.Title)
+	}
+}
+
+func checkListen(report reportFunc, network, addr, owner string) {
+	var err error
+	if network == "udp" {
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket(network, addr); err == nil {
+			pc.Close()
+		}
+	} else {
+		var l net.Listener
+		if l, err = net.Listen(network, addr); err == nil {
+			l.Close()
+		}
+	}
+	if err != nil {
+		report(checkFail, "port", "%s %s (%s): %v", network, addr, owner, err)
+		return
+	}
+	report(checkOK, "port", "%s %s (%s) is free", network, addr, owner)
+}
@@ -2,39 +2,108 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/akave-ai/akavelog/internal/config"
 	"github.com/akave-ai/akavelog/internal/database"
 	"github.com/akave-ai/akavelog/internal/logger"
+	"github.com/akave-ai/akavelog/internal/selflog"
 	"github.com/akave-ai/akavelog/internal/server"
 )
 
+// command is a subcommand of akavelog.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// commands are the subcommands of akavelog; serve runs when none is given.
+var commands = []command{
+	{"serve", "run the server (default)", serve},
+	{"migrate", "migrate up|down|status: apply, roll back or list database migrations", migrate},
+	{"validate-config", "load and validate the configuration without starting anything", validateConfig},
+	{"doctor", "check the database, O3 and the ports the server listens on", doctor},
+	{"backfill-index", "index the batch objects already in O3", backfillIndex},
+	{"replay", "run archived entries of a time range through the pipelines to the outputs again", replayCmd},
+	{"bench", "send synthetic log traffic to an input and report throughput and latency", benchCmd},
+}
+
 func main() {
-	cfg, err := config.LoadConfig()
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				fmt.Fprintf(os.Stderr, "akavelog %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "akavelog: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: akavelog [command] [-config file] [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun akavelog <command> -h for the flags of a command.\n")
+}
+
+// serve migrates the database and runs the server until SIGINT or SIGTERM (akavelog serve,
+// or akavelog without a command).
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	fs.Parse(args)
+	cfg, err := config.LoadConfigFile(*configPath)
 	if err != nil {
-		log.Fatalf("load config: %v", err)
+		return fmt.Errorf("load config: %w", err)
 	}
 
 	loggerService := logger.NewLoggerService(cfg.Observability)
 	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
 	defer loggerService.Shutdown()
+	if cfg.Observability.SelfLogs.Enabled {
+		log = log.Hook(selflog.Hook())
+	}
 
-	ctx := context.Background()
+	// SIGINT and SIGTERM shut the server down gracefully; a second signal exits at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 	if err := database.Migrate(ctx, &log, cfg); err != nil {
-		log.Fatal().Err(err).Msg("migrate")
+		return fmt.Errorf("migrate: %w", err)
 	}
 
 	db, err := database.New(cfg, &log, loggerService)
 	if err != nil {
-		log.Fatal().Err(err).Msg("database")
+		return fmt.Errorf("database: %w", err)
 	}
 	defer db.Pool.Close()
 
 	srv := server.New(cfg, db.Pool)
 	if err := srv.Start(ctx); err != nil {
-		log.Printf("server exited: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("server exited: %w", err)
 	}
+	return nil
 }
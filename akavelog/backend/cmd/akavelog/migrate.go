@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/database"
+	"github.com/akave-ai/akavelog/internal/logger"
+)
+
+// migrate applies, rolls back or lists the database migrations (akavelog migrate up|down|status).
+// up migrates to the latest version, or to -to; down rolls back the last migration, or down
+// to -to.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("want up, down or status")
+	}
+	sub, args := args[0], args[1:]
+	fs := flag.NewFlagSet("migrate "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	to := fs.Int("to", -1, "schema version to migrate to (up: default latest; down: default the one before the current)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfigFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	loggerService := logger.NewLoggerService(cfg.Observability)
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+	defer loggerService.Shutdown()
+
+	ctx := context.Background()
+	switch sub {
+	case "up":
+		return database.MigrateTo(ctx, &log, cfg, int32(*to))
+	case "down":
+		version := int32(*to)
+		if version < 0 {
+			current, _, err := database.Status(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				fmt.Fprintln(os.Stdout, "no migration applied")
+				return nil
+			}
+			version = current - 1
+		}
+		return database.MigrateTo(ctx, &log, cfg, version)
+	case "status":
+		current, list, err := database.Status(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "schema version %d\n", current)
+		problems := 0
+		for _, m := range list {
+			applied := ""
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if m.State == database.MigrationModified || m.State == database.MigrationUnknown {
+				problems++
+			}
+			fmt.Fprintf(os.Stdout, "  %3d  %-8s %-22s %-20s %s\n", m.Version, m.State, m.Name, applied, m.Checksum[:12])
+		}
+		if problems > 0 {
+			return fmt.Errorf("%d migrations are modified or unknown to this build", problems)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown subcommand %q (want up, down or status)", sub)
+}
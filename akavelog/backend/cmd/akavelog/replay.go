@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/database"
+	"github.com/akave-ai/akavelog/internal/logger"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/replay"
+	"github.com/akave-ai/akavelog/internal/server"
+	"github.com/google/uuid"
+)
+
+// replayCmd runs the archived entries of a time range through the pipelines again and hands
+// them to the outputs, and with -archive to the streams they are now routed to (akavelog
+// replay). It runs in this process; POST /replay runs one in the server.
+func replayCmd(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	from := fs.String("from", "", "start of the range, RFC 3339 (required)")
+	to := fs.String("to", "", "end of the range, RFC 3339 (default: now)")
+	project := fs.String("project", "", "only entries of this project")
+	q := fs.String("query", "", "only entries matching this query")
+	input := fs.String("input", "", "run the pipelines of this input ID (default: the global ones)")
+	only := fs.String("outputs", "", "comma-separated outputs to send to (default: every output an entry is routed to)")
+	archive := fs.Bool("archive", false, "store entries now routed to another stream under its o3_prefix")
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	fs.Parse(args)
+
+	req := replay.Request{ProjectID: strings.TrimSpace(*project), End: time.Now().UTC(), Archive: *archive}
+	var err error
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	if req.Start, err = time.Parse(time.RFC3339Nano, *from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if *to != "" {
+		if req.End, err = time.Parse(time.RFC3339Nano, *to); err != nil {
+			return fmt.Errorf("-to: %w", err)
+		}
+	}
+	if !req.Start.Before(req.End) {
+		return fmt.Errorf("-from must be before -to")
+	}
+	if req.Query, err = query.Parse(*q); err != nil {
+		return fmt.Errorf("-query: %w", err)
+	}
+	if *input != "" {
+		if req.InputID, err = uuid.Parse(*input); err != nil {
+			return fmt.Errorf("-input: %w", err)
+		}
+	}
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Outputs = append(req.Outputs, name)
+		}
+	}
+
+	cfg, err := config.LoadConfigFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	loggerService := logger.NewLoggerService(cfg.Observability)
+	log := logger.NewLoggerWithService(cfg.Observability, loggerService)
+	defer loggerService.Shutdown()
+
+	// SIGINT and SIGTERM stop the replay; what was handed on stays delivered.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := database.Migrate(ctx, &log, cfg); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	db, err := database.New(cfg, &log, loggerService)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	defer db.Pool.Close()
+
+	m, closeTargets, err := server.NewReplayer(ctx, cfg, db.Pool, req.Archive)
+	if err != nil {
+		return err
+	}
+	c, err := m.Run(ctx, req, func(c replay.Counts) {
+		fmt.Fprintf(os.Stderr, "\r%d objects read, %d entries replayed", c.Objects, c.Replayed)
+	})
+	// Outputs and the batcher deliver what they hold before we report.
+	closeTargets()
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stdout, "read %d objects: %d entries matched, %d replayed, %d dropped by processors, %d archived\n",
+		c.Objects, c.Matched, c.Replayed, c.Dropped, c.Archived)
+	return err
+}
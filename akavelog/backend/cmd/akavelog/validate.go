@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/server"
+)
+
+// validateConfig loads the configuration as serve would and validates it, without connecting
+// to anything but the secret providers it references (akavelog validate-config). Problems
+// are printed one per line.
+func validateConfig(args []string) error {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	configPath := fs.String("config", "", "YAML or TOML config file (default: $AKAVELOG_CONFIG)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfigFile(*configPath)
+	if err != nil {
+		return err
+	}
+	if errs := server.ValidateConfig(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  %v\n", err)
+		}
+		return fmt.Errorf("invalid configuration")
+	}
+	fmt.Fprintf(os.Stdout, "configuration ok (%s): %d inputs and %d pipelines declared\n", configSource(cfg), len(cfg.Inputs), len(cfg.Pipelines))
+	return nil
+}
+
+// configSource describes where cfg was loaded from.
+func configSource(cfg *config.Config) string {
+	if cfg.File == "" {
+		return "environment"
+	}
+	return cfg.File + " and environment"
+}
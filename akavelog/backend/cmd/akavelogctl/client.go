@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+// defaultServer is the server of a profile that names none.
+const defaultServer = "http://localhost:8080"
+
+// requestTimeout bounds every request but tails.
+const requestTimeout = 5 * time.Minute
+
+// Profile is a server and the credentials akavelogctl sends it.
+type Profile struct {
+	Server string `json:"server"`
+	APIKey string `json:"api_key,omitempty"` // sent as X-API-Key; may be an env://, file:// or vault:// reference
+	Token  string `json:"token,omitempty"`   // session token of POST /auth/login, sent as a bearer token
+}
+
+// profileFile holds the named profiles and the one used by default.
+type profileFile struct {
+	Current  string             `json:"current,omitempty"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// profilePath is the file AKAVELOGCTL_CONFIG names, or akavelog/ctl.json in the user's
+// config directory.
+func profilePath() (string, error) {
+	if p := os.Getenv("AKAVELOGCTL_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "akavelog", "ctl.json"), nil
+}
+
+// readProfiles reads the profile file; a missing one has no profiles.
+func readProfiles() (*profileFile, string, error) {
+	path, err := profilePath()
+	if err != nil {
+		return nil, "", err
+	}
+	f := &profileFile{Profiles: map[string]Profile{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]Profile{}
+	}
+	return f, path, nil
+}
+
+// write saves f to path, readable by the user only since it holds credentials.
+func (f *profileFile) write(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// loadProfile returns the profile name, AKAVELOGCTL_PROFILE or the current one. Without
+// profiles, the zero Profile is returned unless a name was asked for.
+func loadProfile(name string) (Profile, error) {
+	f, path, err := readProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+	if name == "" {
+		name = os.Getenv("AKAVELOGCTL_PROFILE")
+	}
+	if name == "" {
+		name = f.Current
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("no profile %q in %s", name, path)
+	}
+	return p, nil
+}
+
+// client calls the management API of one server.
+type client struct {
+	base   string
+	apiKey string
+	token  string
+	http   *http.Client
+}
+
+func newClient(ctx context.Context, p Profile) (*client, error) {
+	base := strings.TrimRight(p.Server, "/")
+	if base == "" {
+		base = defaultServer
+	}
+	if _, err := url.Parse(base); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	key, err := secrets.Resolve(ctx, p.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("api_key: %w", err)
+	}
+	return &client{base: base, apiKey: key, token: p.Token, http: &http.Client{}}, nil
+}
+
+// apiError is the error body of the API, response.APIError.
+type apiError struct {
+	Message   string `json:"message"`
+	Error     string `json:"error"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id"`
+}
+
+// request sends method path?query with body as JSON, if not nil, and returns the response
+// when its status is a success. Errors carry the API's message and request ID.
+func (c *client) request(ctx context.Context, method, path string, query url.Values, body any, accept string) (*http.Response, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var e apiError
+	if json.Unmarshal(data, &e) != nil || e.Message == "" {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	msg := e.Message
+	if e.Error != "" && e.Error != e.Message {
+		msg += ": " + e.Error
+	}
+	if e.RequestID != "" {
+		return nil, fmt.Errorf("%s (HTTP %d, request %s)", msg, resp.StatusCode, e.RequestID)
+	}
+	return nil, fmt.Errorf("%s (HTTP %d)", msg, resp.StatusCode)
+}
+
+// do sends a request and decodes the data of the response envelope into out, if not nil.
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := c.request(ctx, method, path, query, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("%s %s: decode data: %w", method, path, err)
+	}
+	return nil
+}
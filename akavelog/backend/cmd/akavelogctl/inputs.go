@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// input is an input as the API returns it.
+type input struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Title         string          `json:"title"`
+	ProjectID     string          `json:"project_id,omitempty"`
+	Configuration json.RawMessage `json:"configuration"`
+	CreatedAt     string          `json:"created_at"`
+	State         string          `json:"state"`
+	Health        string          `json:"health,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	Restarts      int             `json:"restarts,omitempty"`
+	IngestKey     string          `json:"ingest_key,omitempty"`
+}
+
+// inputsCmd lists, creates and deletes inputs (akavelogctl inputs list|create|delete).
+func inputsCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("want list, create or delete")
+	}
+	sub, args := args[0], args[1:]
+	var o options
+	fs := o.flagSet("inputs " + sub)
+	switch sub {
+	case "list":
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		c, err := o.client(ctx)
+		if err != nil {
+			return err
+		}
+		list, err := c.listInputs(ctx)
+		if err != nil {
+			return err
+		}
+		if o.json() {
+			return printJSON(list)
+		}
+		return printInputs(list)
+
+	case "create":
+		typ := fs.String("type", "", "input type, e.g. http (required)")
+		title := fs.String("title", "", "title (required)")
+		description := fs.String("description", "", "description")
+		project := fs.String("project", "", "project ID or name")
+		state := fs.String("state", "", "RUNNING (default), STOPPED or PAUSED")
+		cfg := fs.String("config", "", "type-specific config as a JSON object, or @file to read it from a file")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		if *typ == "" || *title == "" {
+			return fmt.Errorf("-type and -title are required")
+		}
+		c, err := o.client(ctx)
+		if err != nil {
+			return err
+		}
+		body := map[string]any{"type": *typ, "title": *title, "description": *description, "state": *state}
+		if *project != "" {
+			body["project_id"] = *project
+		}
+		if *cfg != "" {
+			raw := []byte(*cfg)
+			if path, ok := strings.CutPrefix(*cfg, "@"); ok {
+				if raw, err = os.ReadFile(path); err != nil {
+					return err
+				}
+			}
+			if !json.Valid(raw) {
+				return fmt.Errorf("-config is not valid JSON")
+			}
+			body["config"] = json.RawMessage(raw)
+		}
+		var in input
+		if err := c.do(ctx, http.MethodPost, "/inputs", nil, body, &in); err != nil {
+			return err
+		}
+		if o.json() {
+			return printJSON(in)
+		}
+		if err := printInputs([]input{in}); err != nil {
+			return err
+		}
+		if in.IngestKey != "" {
+			fmt.Fprintf(os.Stdout, "\ningest key (shown once): %s\n", in.IngestKey)
+		}
+		return nil
+
+	case "delete":
+		args = parseArgs(fs, args)
+		if len(args) == 0 {
+			return fmt.Errorf("want the IDs or titles of the inputs to delete")
+		}
+		c, err := o.client(ctx)
+		if err != nil {
+			return err
+		}
+		var list []input
+		for _, ref := range args {
+			id := ref
+			if _, err := uuid.Parse(ref); err != nil {
+				if list == nil {
+					if list, err = c.listInputs(ctx); err != nil {
+						return err
+					}
+				}
+				if id = inputByTitle(list, ref); id == "" {
+					return fmt.Errorf("no input titled %q", ref)
+				}
+			}
+			if err := c.do(ctx, http.MethodDelete, "/inputs/"+id, nil, nil, nil); err != nil {
+				return fmt.Errorf("%s: %w", ref, err)
+			}
+			fmt.Fprintf(os.Stdout, "deleted input %s\n", ref)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown subcommand %q (want list, create or delete)", sub)
+}
+
+// listInputs returns every input, a page of GET /inputs at a time.
+func (c *client) listInputs(ctx context.Context) ([]input, error) {
+	var list []input
+	for {
+		var out struct {
+			Inputs []input `json:"inputs"`
+			Total  int     `json:"total"`
+		}
+		query := url.Values{"limit": {"1000"}, "offset": {strconv.Itoa(len(list))}}
+		if err := c.do(ctx, http.MethodGet, "/inputs", query, nil, &out); err != nil {
+			return nil, err
+		}
+		list = append(list, out.Inputs...)
+		if len(out.Inputs) == 0 || len(list) >= out.Total {
+			return list, nil
+		}
+	}
+}
+
+// inputByTitle returns the ID of the input of list titled title, or "".
+func inputByTitle(list []input, title string) string {
+	for _, in := range list {
+		if in.Title == title {
+			return in.ID
+		}
+	}
+	return ""
+}
+
+func printInputs(list []input) error {
+	rows := make([][]string, 0, len(list))
+	for _, in := range list {
+		state := in.State
+		if in.Health != "" {
+			state += " (" + in.Health + ")"
+		}
+		var listen string
+		var cfg map[string]any
+		if json.Unmarshal(in.Configuration, &cfg) == nil {
+			listen, _ = cfg["listen"].(string)
+		}
+		rows = append(rows, []string{in.ID, in.Type, in.Title, state, orDash(listen), orDash(in.ProjectID), strconv.Itoa(in.Restarts), orDash(in.LastError)})
+	}
+	return printTable([]string{"ID", "TYPE", "TITLE", "STATE", "LISTEN", "PROJECT", "RESTARTS", "LAST ERROR"}, rows)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// searchCmd prints the newest entries matching a query (akavelogctl search), newest first.
+func searchCmd(ctx context.Context, args []string) error {
+	var o options
+	fs := o.flagSet("search")
+	q := fs.String("query", "", "query, e.g. 'service:api AND level:error' (default: every entry)")
+	project := fs.String("project", "", "project ID")
+	since := fs.Duration("since", 0, "search the last duration, e.g. 1h (default: the server's 24h)")
+	start := fs.String("start", "", "start of the range, RFC 3339")
+	end := fs.String("end", "", "end of the range, RFC 3339 (default now)")
+	limit := fs.Int("limit", 0, "entries at most (default 100, at most 1000)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *since > 0 {
+		*start = time.Now().Add(-*since).UTC().Format(time.RFC3339)
+	}
+	c, err := o.client(ctx)
+	if err != nil {
+		return err
+	}
+	body := map[string]any{"query": *q, "project_id": *project, "start": *start, "end": *end, "limit": *limit}
+	var out struct {
+		Query   string           `json:"query"`
+		Start   time.Time        `json:"start"`
+		End     time.Time        `json:"end"`
+		Entries []model.LogEntry `json:"entries"`
+		Count   int              `json:"count"`
+		Stats   struct {
+			Objects   int   `json:"scanned_objects"`
+			Bytes     int64 `json:"scanned_bytes"`
+			Truncated bool  `json:"truncated"`
+		} `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/query", nil, body, &out); err != nil {
+		return err
+	}
+	if o.json() {
+		return printJSON(out)
+	}
+	rows := make([][]string, 0, len(out.Entries))
+	for _, e := range out.Entries {
+		rows = append(rows, []string{e.Timestamp, orDash(e.Level), e.Service, e.Message})
+	}
+	if err := printTable([]string{"TIMESTAMP", "LEVEL", "SERVICE", "MESSAGE"}, rows); err != nil {
+		return err
+	}
+	note := ""
+	if out.Stats.Truncated {
+		note = "; truncated, narrow the range to read every object"
+	}
+	fmt.Fprintf(os.Stderr, "%d entries from %d objects (%d bytes)%s\n", out.Count, out.Stats.Objects, out.Stats.Bytes, note)
+	return nil
+}
+
+// tailEvent is a "log" event of GET /logs/tail.
+type tailEvent struct {
+	Entry    model.LogEntry `json:"entry"`
+	Received time.Time      `json:"received_at"`
+}
+
+// tailCmd streams the entries matching a query as they are ingested (akavelogctl tail) until
+// interrupted. Entries the server dropped because the terminal kept up too slowly are
+// reported on stderr.
+func tailCmd(ctx context.Context, args []string) error {
+	var o options
+	fs := o.flagSet("tail")
+	q := fs.String("query", "", "query, e.g. 'level:error' (default: every entry)")
+	project := fs.String("project", "", "project ID")
+	backlog := fs.Int("backlog", 0, "first print the last N matching entries")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client(ctx)
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *q != "" {
+		query.Set("query", *q)
+	}
+	if *project != "" {
+		query.Set("project_id", *project)
+	}
+	if *backlog > 0 {
+		query.Set("backlog", strconv.Itoa(*backlog))
+	}
+	resp, err := c.request(ctx, http.MethodGet, "/logs/tail", query, nil, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	var event, data string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "":
+			if err := printTailEvent(event, data, o.json()); err != nil {
+				return err
+			}
+			event, data = "", ""
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("the server closed the stream")
+}
+
+func printTailEvent(event, data string, asJSON bool) error {
+	switch event {
+	case "log":
+		if asJSON {
+			_, err := fmt.Fprintln(os.Stdout, data)
+			return err
+		}
+		var ev tailEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		e := ev.Entry
+		_, err := fmt.Fprintf(os.Stdout, "%s %-5s %s: %s\n", e.Timestamp, orDash(e.Level), e.Service, e.Message)
+		return err
+	case "dropped":
+		var d struct {
+			Dropped int64 `json:"dropped"`
+		}
+		if json.Unmarshal([]byte(data), &d) == nil {
+			fmt.Fprintf(os.Stderr, "(%d entries dropped so far)\n", d.Dropped)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Command akavelogctl manages an akavelog server through its management API: inputs,
+// searches, live tails, batch objects and flushes. Servers and their credentials are kept as
+// named profiles.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command is a subcommand of akavelogctl.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"inputs", "inputs list|create|delete: manage inputs", inputsCmd},
+	{"search", "search -query q: the newest entries matching a query", searchCmd},
+	{"tail", "tail [-query q]: stream entries as they are ingested", tailCmd},
+	{"objects", "objects list|get: list and download batch objects", objectsCmd},
+	{"flush", "upload the open batches to O3 now", flushCmd},
+	{"profile", "profile list|set|use|delete: manage the servers akavelogctl talks to", profileCmd},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage()
+		return
+	}
+	name, args := os.Args[1], os.Args[2:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := cmd.run(ctx, args)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "akavelogctl %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "akavelogctl: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: akavelogctl <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nEvery command but profile takes -profile, -server, -api-key and -o table|json.\n")
+	fmt.Fprintf(os.Stderr, "Run akavelogctl <command> -h for the flags of a command.\n")
+}
+
+// options are the flags every command talking to a server takes.
+type options struct {
+	profile string
+	server  string
+	apiKey  string
+	output  string
+}
+
+// flagSet returns the flag set of command name with the options registered in it.
+func (o *options) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&o.profile, "profile", "", "profile to use (default: $AKAVELOGCTL_PROFILE or the current profile)")
+	fs.StringVar(&o.server, "server", "", "server URL, overriding the profile's (default: $AKAVELOGCTL_SERVER)")
+	fs.StringVar(&o.apiKey, "api-key", "", "API key, overriding the profile's (default: $AKAVELOGCTL_API_KEY)")
+	fs.StringVar(&o.output, "o", "table", "output format: table or json")
+	return fs
+}
+
+// parseArgs parses the flags of fs wherever they are among args and returns the other
+// arguments, so that "inputs delete edge -o json" works like "inputs delete -o json edge".
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return rest
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseFlags is parseArgs for commands that take no arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	return nil
+}
+
+// client returns a client of the server the options, environment and profile name, in
+// that order.
+func (o *options) client(ctx context.Context) (*client, error) {
+	if o.output != "table" && o.output != "json" {
+		return nil, fmt.Errorf("-o must be table or json")
+	}
+	p, err := loadProfile(o.profile)
+	if err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("AKAVELOGCTL_SERVER"); v != "" {
+		p.Server = v
+	}
+	if v := os.Getenv("AKAVELOGCTL_API_KEY"); v != "" {
+		p.APIKey, p.Token = v, ""
+	}
+	if o.server != "" {
+		p.Server = o.server
+	}
+	if o.apiKey != "" {
+		p.APIKey, p.Token = o.apiKey, ""
+	}
+	return newClient(ctx, p)
+}
+
+// json reports whether results are printed as JSON.
+func (o *options) json() bool {
+	return o.output == "json"
+}
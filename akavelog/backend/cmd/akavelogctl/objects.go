@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// object is a batch object as GET /uploads lists it.
+type object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// objectsCmd lists and downloads batch objects (akavelogctl objects list|get).
+func objectsCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("want list or get")
+	}
+	sub, args := args[0], args[1:]
+	var o options
+	fs := o.flagSet("objects " + sub)
+	switch sub {
+	case "list":
+		prefix := fs.String("prefix", "", "key prefix, e.g. logs/")
+		project := fs.String("project", "", "project ID")
+		start := fs.String("start", "", "only objects of days from this RFC 3339 time")
+		end := fs.String("end", "", "only objects of days until this RFC 3339 time")
+		limit := fs.Int("limit", 0, "objects at most (default 100, at most 1000)")
+		cursor := fs.String("cursor", "", "next_cursor of the previous page")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		c, err := o.client(ctx)
+		if err != nil {
+			return err
+		}
+		query := url.Values{}
+		for k, v := range map[string]string{"prefix": *prefix, "project_id": *project, "start": *start, "end": *end, "cursor": *cursor} {
+			if v != "" {
+				query.Set(k, v)
+			}
+		}
+		if *limit > 0 {
+			query.Set("limit", strconv.Itoa(*limit))
+		}
+		var out struct {
+			Objects    []object `json:"objects"`
+			Count      int      `json:"count"`
+			NextCursor string   `json:"next_cursor"`
+			Truncated  bool     `json:"truncated"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/uploads", query, nil, &out); err != nil {
+			return err
+		}
+		if o.json() {
+			return printJSON(out)
+		}
+		rows := make([][]string, 0, len(out.Objects))
+		for _, obj := range out.Objects {
+			rows = append(rows, []string{obj.Key, strconv.FormatInt(obj.Size, 10), obj.LastModified.Format(time.RFC3339)})
+		}
+		if err := printTable([]string{"KEY", "SIZE", "LAST MODIFIED"}, rows); err != nil {
+			return err
+		}
+		if out.NextCursor != "" {
+			fmt.Fprintf(os.Stderr, "more objects: -cursor %s\n", out.NextCursor)
+		}
+		return nil
+
+	case "get":
+		dest := fs.String("out", "", "file or directory to write to, - for stdout (default: the key's base name)")
+		args = parseArgs(fs, args)
+		if len(args) == 0 {
+			return fmt.Errorf("want the keys of the objects to download")
+		}
+		if *dest == "-" && len(args) > 1 {
+			return fmt.Errorf("-out - takes one key")
+		}
+		c, err := o.client(ctx)
+		if err != nil {
+			return err
+		}
+		for _, key := range args {
+			n, file, err := c.download(ctx, key, *dest)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if file != "" {
+				fmt.Fprintf(os.Stderr, "%s: %d bytes to %s\n", key, n, file)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown subcommand %q (want list or get)", sub)
+}
+
+// download fetches the object at key through a presigned URL into dest, as objectsCmd
+// describes it, and returns its size and the file written, "" for stdout. Objects are
+// written as stored, compressed by their codec.
+func (c *client) download(ctx context.Context, key, dest string) (int64, string, error) {
+	var presigned struct {
+		URL string `json:"url"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/uploads/presign", nil, map[string]string{"key": key}, &presigned); err != nil {
+		return 0, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presigned.URL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("download: %s", resp.Status)
+	}
+	if dest == "-" {
+		n, err := io.Copy(os.Stdout, resp.Body)
+		return n, "", err
+	}
+	file := path.Base(key)
+	if dest != "" {
+		file = dest
+		if st, err := os.Stat(dest); err == nil && st.IsDir() {
+			file = path.Join(dest, path.Base(key))
+		}
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, "", err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, file, err
+}
+
+// flushCmd uploads the batches the server has open to O3 now (akavelogctl flush), e.g.
+// before searching entries just sent.
+func flushCmd(ctx context.Context, args []string) error {
+	var o options
+	fs := o.flagSet("flush")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client(ctx)
+	if err != nil {
+		return err
+	}
+	var out struct {
+		Batches int `json:"batches"`
+		Entries int `json:"entries"`
+		Retry   struct {
+			Depth     int    `json:"depth"`
+			Entries   int    `json:"entries"`
+			LastError string `json:"last_error"`
+		} `json:"retry"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/flush", nil, nil, &out); err != nil {
+		return err
+	}
+	if o.json() {
+		return printJSON(out)
+	}
+	fmt.Fprintf(os.Stdout, "flushed %d batches (%d entries)\n", out.Batches, out.Entries)
+	if out.Retry.Depth > 0 {
+		fmt.Fprintf(os.Stdout, "%d objects (%d entries) wait for a retry; last error: %s\n", out.Retry.Depth, out.Retry.Entries, out.Retry.LastError)
+	}
+	return nil
+}
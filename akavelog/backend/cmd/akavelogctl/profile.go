@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+// profileCmd lists, saves, selects and deletes profiles (akavelogctl profile
+// list|set|use|delete). The first profile saved becomes the current one.
+func profileCmd(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("want list, set, use or delete")
+	}
+	sub, args := args[0], args[1:]
+	fs := flag.NewFlagSet("profile "+sub, flag.ExitOnError)
+	f, path, err := readProfiles()
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "list":
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(f.Profiles))
+		for name := range f.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rows := make([][]string, 0, len(names))
+		for _, name := range names {
+			p := f.Profiles[name]
+			current := ""
+			if name == f.Current {
+				current = "*"
+			}
+			auth := "-"
+			switch {
+			case p.APIKey != "" && secrets.IsRef(p.APIKey):
+				auth = "api key " + p.APIKey
+			case p.APIKey != "":
+				auth = "api key " + secrets.Mask
+			case p.Token != "":
+				auth = "token " + secrets.Mask
+			}
+			rows = append(rows, []string{current, name, p.Server, auth})
+		}
+		return printTable([]string{"CURRENT", "NAME", "SERVER", "AUTH"}, rows)
+
+	case "set":
+		server := fs.String("server", "", "server URL, e.g. https://akavelog.example.com")
+		apiKey := fs.String("api-key", "", "API key, or an env://, file:// or vault:// reference to one")
+		token := fs.String("token", "", "session token of POST /auth/login, used without an API key")
+		args = parseArgs(fs, args)
+		if len(args) != 1 {
+			return fmt.Errorf("want the name of the profile")
+		}
+		name := args[0]
+		p := f.Profiles[name]
+		fs.Visit(func(fl *flag.Flag) {
+			switch fl.Name {
+			case "server":
+				p.Server = *server
+			case "api-key":
+				p.APIKey = *apiKey
+			case "token":
+				p.Token = *token
+			}
+		})
+		f.Profiles[name] = p
+		if f.Current == "" {
+			f.Current = name
+		}
+		if err := f.write(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "saved profile %s in %s\n", name, path)
+		return nil
+
+	case "use":
+		args = parseArgs(fs, args)
+		if len(args) != 1 {
+			return fmt.Errorf("want the name of the profile")
+		}
+		name := args[0]
+		if _, ok := f.Profiles[name]; !ok {
+			return fmt.Errorf("no profile %q", name)
+		}
+		f.Current = name
+		if err := f.write(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "using profile %s\n", name)
+		return nil
+
+	case "delete":
+		args = parseArgs(fs, args)
+		if len(args) != 1 {
+			return fmt.Errorf("want the name of the profile")
+		}
+		name := args[0]
+		if _, ok := f.Profiles[name]; !ok {
+			return fmt.Errorf("no profile %q", name)
+		}
+		delete(f.Profiles, name)
+		if f.Current == name {
+			f.Current = ""
+		}
+		if err := f.write(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "deleted profile %s\n", name)
+		return nil
+	}
+	return fmt.Errorf("unknown subcommand %q (want list, set, use or delete)", sub)
+}
@@ -0,0 +1,124 @@
+package alerting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window limits.
+const (
+	MinWindow = 10 * time.Second
+	MaxWindow = 24 * time.Hour
+)
+
+// Anomaly kinds: which baselines of the anomaly analyzer an anomaly condition watches.
+const (
+	AnomalyAny       = "any"
+	AnomalyVolume    = "volume"
+	AnomalyErrorRate = "error_rate"
+)
+
+// Condition is when an alert fires: the number of matching entries ingested in the last
+// Window compared with Threshold, or, for an absence, no matching entry in Window. An
+// anomaly condition instead fires while the anomaly analyzer finds services matching the
+// alert that deviate from their baselines; it has no Window.
+type Condition struct {
+	Absence   bool
+	Anomaly   string // "", AnomalyAny, AnomalyVolume or AnomalyErrorRate
+	Op        string // >, >=, <, <= or ==; "==" with Threshold 0 for an absence
+	Threshold int
+	Window    time.Duration
+}
+
+// ParseCondition parses "count <op> <n> in <window>", e.g. "count > 100 in 5m",
+// "no logs in <window>", or "anomaly", "volume anomaly" or "error_rate anomaly". The window
+// is a Go duration or whole days ("1d"), between 10s and 24h.
+func ParseCondition(s string) (Condition, error) {
+	f := strings.Fields(strings.ToLower(s))
+	var c Condition
+	var window string
+	switch {
+	case len(f) == 1 && f[0] == "anomaly":
+		return Condition{Anomaly: AnomalyAny, Op: ">"}, nil
+	case len(f) == 2 && f[1] == "anomaly" && (f[0] == AnomalyVolume || f[0] == AnomalyErrorRate):
+		return Condition{Anomaly: f[0], Op: ">"}, nil
+	case len(f) == 4 && f[0] == "no" && f[1] == "logs" && f[2] == "in":
+		c = Condition{Absence: true, Op: "=="}
+		window = f[3]
+	case len(f) == 5 && f[0] == "count" && f[3] == "in":
+		switch f[1] {
+		case ">", ">=", "<", "<=", "==":
+		default:
+			return c, fmt.Errorf("unknown operator %q; use >, >=, <, <= or ==", f[1])
+		}
+		n, err := strconv.Atoi(f[2])
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("threshold %q is not a count", f[2])
+		}
+		c = Condition{Op: f[1], Threshold: n}
+		window = f[4]
+	default:
+		return c, fmt.Errorf(`condition must be "count <op> <n> in <window>", "no logs in <window>" or "[volume|error_rate] anomaly"`)
+	}
+	d, err := parseWindow(window)
+	if err != nil || d < MinWindow || d > MaxWindow {
+		return c, fmt.Errorf("window %q must be a duration between %s and %s", window, MinWindow, MaxWindow)
+	}
+	c.Window = d
+	return c, nil
+}
+
+func parseWindow(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// String returns c in the syntax of ParseCondition.
+func (c Condition) String() string {
+	switch c.Anomaly {
+	case "":
+	case AnomalyAny:
+		return "anomaly"
+	default:
+		return c.Anomaly + " anomaly"
+	}
+	if c.Absence {
+		return "no logs in " + c.Window.String()
+	}
+	return fmt.Sprintf("count %s %d in %s", c.Op, c.Threshold, c.Window)
+}
+
+// Holds reports whether the condition is met by count entries in the window, or for an
+// anomaly condition, count deviating services.
+func (c Condition) Holds(count int) bool {
+	switch c.Op {
+	case ">":
+		return count > c.Threshold
+	case ">=":
+		return count >= c.Threshold
+	case "<":
+		return count < c.Threshold
+	case "<=":
+		return count <= c.Threshold
+	case "==":
+		return count == c.Threshold
+	}
+	return false
+}
+
+// needsFullWindow reports whether the condition can hold merely because entries have not
+// been counted for a whole window yet, as for absences and upper bounds.
+func (c Condition) needsFullWindow() bool {
+	if c.Anomaly != "" {
+		return false
+	}
+	return c.Absence || c.Op == "<" || c.Op == "<=" || c.Op == "=="
+}
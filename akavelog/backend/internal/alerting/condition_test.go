@@ -0,0 +1,44 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Condition
+	}{
+		{"count > 100 in 5m", Condition{Op: ">", Threshold: 100, Window: 5 * time.Minute}},
+		{"COUNT <= 0 in 1h30m", Condition{Op: "<=", Threshold: 0, Window: 90 * time.Minute}},
+		{"count == 3 in 1d", Condition{Op: "==", Threshold: 3, Window: 24 * time.Hour}},
+		{"no logs in 10m", Condition{Absence: true, Op: "==", Window: 10 * time.Minute}},
+		{"anomaly", Condition{Anomaly: AnomalyAny, Op: ">"}},
+		{"Error_Rate  anomaly", Condition{Anomaly: AnomalyErrorRate, Op: ">"}},
+	} {
+		got, err := ParseCondition(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseCondition(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+		if again, err := ParseCondition(got.String()); err != nil || again != got {
+			t.Errorf("%q does not round-trip: %+v, %v", got.String(), again, err)
+		}
+	}
+	for _, in := range []string{"", "count > 100", "count ~ 1 in 5m", "count > -1 in 5m", "count > 1 in 5s", "count > 1 in 2d", "no logs for 5m", "latency anomaly"} {
+		if _, err := ParseCondition(in); err == nil {
+			t.Errorf("ParseCondition(%q) succeeded", in)
+		}
+	}
+}
+
+func TestHolds(t *testing.T) {
+	c := Condition{Op: ">=", Threshold: 2}
+	if c.Holds(1) || !c.Holds(2) {
+		t.Error(">= 2")
+	}
+	absent := Condition{Absence: true, Op: "=="}
+	if !absent.Holds(0) || absent.Holds(1) {
+		t.Error("absence")
+	}
+}
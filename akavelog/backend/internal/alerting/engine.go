@@ -0,0 +1,361 @@
+// Package alerting evaluates alert conditions over the live ingest pipeline. Every entry
+// leaving the ingest queue is counted by the enabled alerts whose query and project it
+// matches; every Interval each alert's count over its window is checked against its condition
+// and its state moves between ok, firing and resolved. Anomaly alerts are not counted here;
+// the anomaly analyzer judges them through EvaluateAnomalies.
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/google/uuid"
+)
+
+// DefaultInterval is how often alerts are evaluated.
+const DefaultInterval = 15 * time.Second
+
+// slots is how many parts a window is counted in; counts are exact to 1/slots of a window.
+const slots = 60
+
+const recordTimeout = 10 * time.Second
+
+// Store persists state changes (repository.AlertRepository).
+type Store interface {
+	RecordState(ctx context.Context, ev *model.AlertEvent) error
+}
+
+// Config configures an Engine.
+type Config struct {
+	Interval time.Duration // between evaluations (default DefaultInterval)
+	// OnChange, when set, is called with every state change after it is recorded.
+	OnChange func(a model.Alert, ev model.AlertEvent)
+}
+
+// Status is the live state of an alert.
+type Status struct {
+	State          model.AlertState `json:"state"`
+	Value          int              `json:"value"` // entries counted in the window (deviating services for anomaly alerts) at the last evaluation
+	EvaluatedAt    *time.Time       `json:"evaluated_at"`
+	StateChangedAt *time.Time       `json:"state_changed_at"`
+	WarmingUp      bool             `json:"warming_up"` // the window has not been counted in full yet
+}
+
+// counter counts events in a sliding window of slots parts.
+type counter struct {
+	width  time.Duration // of one slot
+	slot   [slots]int64  // slot number each count belongs to
+	counts [slots]int
+}
+
+func (c *counter) add(now time.Time) {
+	n := now.UnixNano() / int64(c.width)
+	i := n % slots
+	if c.slot[i] != n {
+		c.slot[i], c.counts[i] = n, 0
+	}
+	c.counts[i]++
+}
+
+// sum returns the count of the slots overlapping the window ending at now.
+func (c *counter) sum(now time.Time) int {
+	cur := now.UnixNano() / int64(c.width)
+	total := 0
+	for i, n := range c.slot {
+		if n > cur-slots && n <= cur {
+			total += c.counts[i]
+		}
+	}
+	return total
+}
+
+// rule is a loaded alert.
+type rule struct {
+	def   model.Alert
+	query *query.Query
+	cond  Condition
+	since time.Time // counting started
+
+	mu          sync.Mutex
+	counter     counter
+	state       model.AlertState
+	changedAt   *time.Time
+	value       int
+	evaluatedAt *time.Time
+}
+
+type ruleSet struct {
+	list     []*rule
+	byID     map[uuid.UUID]*rule
+	counting int // rules that count entries, i.e. not anomaly rules
+}
+
+// Engine counts entries for the loaded alerts and evaluates them.
+type Engine struct {
+	cfg   Config
+	store Store
+	now   func() time.Time
+
+	mu    sync.Mutex // serializes Load and evaluate
+	rules atomic.Pointer[ruleSet]
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewEngine starts an engine that evaluates the alerts passed to Load every Interval and
+// records their state changes in store.
+func NewEngine(cfg Config, store Store) *Engine {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	e := &Engine{cfg: cfg, store: store, now: time.Now, stop: make(chan struct{}), done: make(chan struct{})}
+	e.rules.Store(&ruleSet{byID: map[uuid.UUID]*rule{}})
+	go e.loop()
+	return e
+}
+
+func (e *Engine) loop() {
+	defer close(e.done)
+	t := time.NewTicker(e.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-t.C:
+			e.Evaluate(context.Background())
+		}
+	}
+}
+
+// Stop stops evaluating.
+func (e *Engine) Stop() {
+	close(e.stop)
+	<-e.done
+}
+
+// Load replaces the evaluated alerts with the enabled ones in list. Alerts whose definition
+// is unchanged (same UpdatedAt) keep their counts; others start counting anew, in the state
+// stored in their definition. An alert that does not parse is skipped and its error returned.
+func (e *Engine) Load(list []model.Alert) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prev := e.rules.Load()
+	next := &ruleSet{byID: make(map[uuid.UUID]*rule, len(list))}
+	var errs []error
+	for _, a := range list {
+		if !a.Enabled {
+			continue
+		}
+		r, ok := prev.byID[a.ID]
+		if !ok || !r.def.UpdatedAt.Equal(a.UpdatedAt) {
+			var err error
+			if r, err = newRule(a, e.now()); err != nil {
+				errs = append(errs, fmt.Errorf("alert %q: %w", a.Name, err))
+				continue
+			}
+		}
+		next.list = append(next.list, r)
+		next.byID[a.ID] = r
+		if r.cond.Anomaly == "" {
+			next.counting++
+		}
+	}
+	e.rules.Store(next)
+	return errors.Join(errs...)
+}
+
+func newRule(a model.Alert, now time.Time) (*rule, error) {
+	q, err := query.Parse(a.Query)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := ParseCondition(a.Condition)
+	if err != nil {
+		return nil, err
+	}
+	r := &rule{def: a, query: q, cond: cond, since: now, state: a.State, changedAt: a.StateChangedAt}
+	if r.state == "" {
+		r.state = model.AlertOK
+	}
+	r.counter.width = max(cond.Window/slots, time.Millisecond)
+	return r, nil
+}
+
+// Active reports whether any alert that counts entries is loaded, so callers can skip
+// decoding entries.
+func (e *Engine) Active() bool {
+	return e.rules.Load().counting > 0
+}
+
+// Observe counts entry for every loaded alert it matches.
+func (e *Engine) Observe(entry *model.LogEntry) {
+	set := e.rules.Load()
+	if set.counting == 0 {
+		return
+	}
+	now := e.now()
+	for _, r := range set.list {
+		if r.cond.Anomaly != "" || !r.matches(entry) {
+			continue
+		}
+		r.mu.Lock()
+		r.counter.add(now)
+		r.mu.Unlock()
+	}
+}
+
+// Status returns the live state of a loaded alert. ok is false when it is not loaded
+// (unknown, disabled or invalid).
+func (e *Engine) Status(id uuid.UUID) (Status, bool) {
+	r, ok := e.rules.Load().byID[id]
+	if !ok {
+		return Status{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Status{
+		State:          r.state,
+		Value:          r.value,
+		EvaluatedAt:    r.evaluatedAt,
+		StateChangedAt: r.changedAt,
+		WarmingUp:      r.cond.needsFullWindow() && e.now().Sub(r.since) < r.cond.Window,
+	}, true
+}
+
+// matches reports whether entry is in r's project and matches its query.
+func (r *rule) matches(entry *model.LogEntry) bool {
+	if r.def.ProjectID != "" && entry.ProjectID != r.def.ProjectID {
+		return false
+	}
+	return r.query.MatchEntry(entry)
+}
+
+// Evaluate checks every loaded alert that counts entries now. An alert whose condition holds
+// fires; a firing one whose condition no longer holds is resolved. Conditions that could hold
+// only because a whole window has not been counted yet, such as absences, are not checked
+// until it has. State changes are recorded in the store and passed to OnChange.
+func (e *Engine) Evaluate(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now().UTC()
+	for _, r := range e.rules.Load().list {
+		if r.cond.Anomaly != "" {
+			continue
+		}
+		e.record(ctx, r, r.evaluate(now))
+	}
+}
+
+// Judge returns how many services deviate from their baselines in the way kind (AnomalyAny,
+// AnomalyVolume or AnomalyErrorRate) names among those an alert selects, and a message
+// describing them. match reports whether an entry of a service is selected; it looks at
+// ProjectID, Service and the fields derived from them only.
+type Judge func(kind string, match func(*model.LogEntry) bool) (int, string)
+
+// EvaluateAnomalies checks every loaded anomaly alert with judge, moving its state like
+// Evaluate: an alert fires while judge reports deviating services and resolves when it
+// reports none.
+func (e *Engine) EvaluateAnomalies(ctx context.Context, judge Judge) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now().UTC()
+	for _, r := range e.rules.Load().list {
+		if r.cond.Anomaly == "" {
+			continue
+		}
+		n, msg := judge(r.cond.Anomaly, r.matches)
+		e.record(ctx, r, r.transition(now, n, msg))
+	}
+}
+
+// record stores a state change of r, if any, and passes it to OnChange.
+func (e *Engine) record(ctx context.Context, r *rule, ev *model.AlertEvent) {
+	if ev == nil {
+		return
+	}
+	rctx, cancel := context.WithTimeout(ctx, recordTimeout)
+	if err := e.store.RecordState(rctx, ev); err != nil {
+		log.Printf("[alerts] %s: record state %s: %v", r.def.Name, ev.State, err)
+	}
+	cancel()
+	log.Printf("[alerts] %s: %s (%s)", r.def.Name, ev.State, ev.Message)
+	if e.cfg.OnChange != nil {
+		e.cfg.OnChange(r.def, *ev)
+	}
+}
+
+// evaluate updates r's value and state at now and returns the event of a state change, or nil.
+func (r *rule) evaluate(now time.Time) *model.AlertEvent {
+	r.mu.Lock()
+	value := r.counter.sum(now)
+	if r.cond.needsFullWindow() && now.Sub(r.since) < r.cond.Window {
+		r.value, r.evaluatedAt = value, &now
+		r.mu.Unlock()
+		return nil
+	}
+	r.mu.Unlock()
+	return r.transition(now, value, fmt.Sprintf("%d entries in %s", value, r.cond.Window))
+}
+
+// transition sets r's value at now and moves its state by whether the condition holds for
+// it. It returns the event of a state change, with msg and the condition as its message, or
+// nil.
+func (r *rule) transition(now time.Time, value int, msg string) *model.AlertEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.value = value
+	r.evaluatedAt = &now
+	holds := r.cond.Holds(value)
+	var next model.AlertState
+	switch {
+	case holds && r.state != model.AlertFiring:
+		next = model.AlertFiring
+	case !holds && r.state == model.AlertFiring:
+		next = model.AlertResolved
+	default:
+		return nil
+	}
+	r.state, r.changedAt = next, &now
+	msg = fmt.Sprintf("%s, condition %s", msg, r.cond)
+	if next == model.AlertResolved {
+		msg += " no longer holds"
+	}
+	return &model.AlertEvent{AlertID: r.def.ID, State: next, Value: value, Message: msg, CreatedAt: now}
+}
+
+// Buffer implements inputs.InputBuffer: it inserts every payload into Next and counts the
+// valid ones Next took for the Engine's alerts.
+type Buffer struct {
+	Engine *Engine
+	Next   inputs.InputBuffer
+}
+
+func (b *Buffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+// InsertContext is Insert for a payload whose trace ctx carries.
+func (b *Buffer) InsertContext(ctx context.Context, p []byte) error {
+	if err := inputs.InsertContext(ctx, b.Next, p); err != nil {
+		return err
+	}
+	if !b.Engine.Active() {
+		return nil
+	}
+	entry, err := batcher.ValidateLog(p)
+	if err != nil {
+		return nil
+	}
+	b.Engine.Observe(entry)
+	return nil
+}
@@ -0,0 +1,176 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+type memStore struct {
+	mu     sync.Mutex
+	events []model.AlertEvent
+}
+
+func (s *memStore) RecordState(_ context.Context, ev *model.AlertEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, *ev)
+	return nil
+}
+
+func (s *memStore) states() []model.AlertState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var states []model.AlertState
+	for _, ev := range s.events {
+		states = append(states, ev.State)
+	}
+	return states
+}
+
+// newTestEngine returns an engine that does not evaluate on its own, with a clock at *now.
+func newTestEngine(t *testing.T, now *time.Time) (*Engine, *memStore) {
+	store := &memStore{}
+	e := NewEngine(Config{Interval: time.Hour}, store)
+	e.now = func() time.Time { return *now }
+	t.Cleanup(e.Stop)
+	return e, store
+}
+
+func equal(a, b []model.AlertState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestThreshold(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	e, store := newTestEngine(t, &now)
+	a := model.Alert{ID: uuid.New(), Name: "errors", Query: "level:error", Condition: "count > 2 in 1m", Enabled: true}
+	if err := e.Load([]model.Alert{a}); err != nil {
+		t.Fatal(err)
+	}
+	errEntry := &model.LogEntry{Service: "api", Level: "error", Message: "timeout"}
+	for range 3 {
+		e.Observe(errEntry)
+		e.Observe(&model.LogEntry{Service: "api", Level: "info", Message: "ok"})
+	}
+	e.Evaluate(context.Background())
+	if st, _ := e.Status(a.ID); st.State != model.AlertFiring || st.Value != 3 || st.WarmingUp {
+		t.Errorf("status %+v", st)
+	}
+
+	// Still firing: nothing recorded.
+	now = now.Add(30 * time.Second)
+	e.Evaluate(context.Background())
+	// The errors leave the window.
+	now = now.Add(time.Minute)
+	e.Evaluate(context.Background())
+	if got := store.states(); !equal(got, []model.AlertState{model.AlertFiring, model.AlertResolved}) {
+		t.Errorf("recorded %v", got)
+	}
+
+	// Reloading an unchanged alert keeps its counts and state.
+	for range 3 {
+		e.Observe(errEntry)
+	}
+	if err := e.Load([]model.Alert{a}); err != nil {
+		t.Fatal(err)
+	}
+	e.Evaluate(context.Background())
+	if st, _ := e.Status(a.ID); st.State != model.AlertFiring {
+		t.Errorf("after reload: %+v", st)
+	}
+
+	// Disabled alerts are not loaded.
+	a.Enabled = false
+	if err := e.Load([]model.Alert{a}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Status(a.ID); ok || e.Active() {
+		t.Error("disabled alert loaded")
+	}
+}
+
+func TestAbsence(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	e, store := newTestEngine(t, &now)
+	a := model.Alert{ID: uuid.New(), Name: "api silent", Query: "service:api", ProjectID: "p1", Condition: "no logs in 10m", Enabled: true}
+	if err := e.Load([]model.Alert{a}); err != nil {
+		t.Fatal(err)
+	}
+	// Until a whole window has been counted, an absence is not known.
+	now = now.Add(5 * time.Minute)
+	e.Evaluate(context.Background())
+	if st, _ := e.Status(a.ID); st.State != model.AlertOK || !st.WarmingUp {
+		t.Errorf("warming up: %+v", st)
+	}
+	// Entries of other projects do not count.
+	e.Observe(&model.LogEntry{ProjectID: "p2", Service: "api", Level: "info", Message: "ok"})
+	now = now.Add(5 * time.Minute)
+	e.Evaluate(context.Background())
+	if st, _ := e.Status(a.ID); st.State != model.AlertFiring || st.WarmingUp {
+		t.Errorf("silent: %+v", st)
+	}
+	e.Observe(&model.LogEntry{ProjectID: "p1", Service: "api", Level: "info", Message: "ok"})
+	e.Evaluate(context.Background())
+	if got := store.states(); !equal(got, []model.AlertState{model.AlertFiring, model.AlertResolved}) {
+		t.Errorf("recorded %v", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	now := time.Now()
+	e, _ := newTestEngine(t, &now)
+	good := model.Alert{ID: uuid.New(), Name: "good", Condition: "count > 1 in 1m", Enabled: true}
+	bad := model.Alert{ID: uuid.New(), Name: "bad", Condition: "sometimes", Enabled: true}
+	if err := e.Load([]model.Alert{good, bad}); err == nil {
+		t.Error("invalid condition loaded")
+	}
+	if _, ok := e.Status(good.ID); !ok {
+		t.Error("valid alert not loaded next to an invalid one")
+	}
+}
+
+func TestEvaluateAnomalies(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	e, store := newTestEngine(t, &now)
+	a := model.Alert{ID: uuid.New(), Name: "api volume", Query: "service:api", Condition: "volume anomaly", Enabled: true}
+	if err := e.Load([]model.Alert{a}); err != nil {
+		t.Fatal(err)
+	}
+	if e.Active() {
+		t.Error("anomaly alerts need no decoded entries")
+	}
+	deviating := map[string]string{"api": AnomalyVolume, "db": AnomalyVolume}
+	judge := func(kind string, match func(*model.LogEntry) bool) (int, string) {
+		n := 0
+		for service, k := range deviating {
+			if (kind == AnomalyAny || kind == k) && match(&model.LogEntry{Service: service}) {
+				n++
+			}
+		}
+		return n, "deviating services"
+	}
+	e.EvaluateAnomalies(context.Background(), judge)
+	if st, _ := e.Status(a.ID); st.State != model.AlertFiring || st.Value != 1 {
+		t.Errorf("status %+v", st)
+	}
+	// Counting evaluations leave anomaly alerts alone.
+	e.Evaluate(context.Background())
+	deviating = map[string]string{"db": AnomalyVolume}
+	e.EvaluateAnomalies(context.Background(), judge)
+	if got := store.states(); !equal(got, []model.AlertState{model.AlertFiring, model.AlertResolved}) {
+		t.Errorf("recorded %v", got)
+	}
+}
@@ -0,0 +1,302 @@
+// Package anomaly learns per-service baselines of log volume and error rate from the entries
+// in O3 and flags the services that deviate from them. Time is cut into buckets of Interval;
+// each bucket is read once through the batches index, shortly after it ends, and its entries
+// per project and service are compared with exponentially weighted baselines, overall and
+// per hour of day, before they are learned. Deviations are judged by anomaly alerts.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/alerting"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/search"
+)
+
+// Metrics, as named by anomaly alert conditions.
+const (
+	MetricVolume    = alerting.AnomalyVolume
+	MetricErrorRate = alerting.AnomalyErrorRate
+)
+
+// forgetAfter is how long a service may have no entries before its baselines are deleted.
+const forgetAfter = 7 * 24 * time.Hour
+
+// maxListed bounds the deviations an alert message names.
+const maxListed = 5
+
+// Repo stores baselines and the last bucket analyzed (repository.BaselineRepository).
+type Repo interface {
+	List(ctx context.Context, projectID, service string) ([]model.Baseline, error)
+	Save(ctx context.Context, list []model.Baseline, forgetBefore time.Time) error
+	Cursor(ctx context.Context) (time.Time, error)
+	Claim(ctx context.Context, prev, next time.Time) (bool, error)
+}
+
+// Alerts judges anomaly alerts (alerting.Engine).
+type Alerts interface {
+	EvaluateAnomalies(ctx context.Context, judge alerting.Judge)
+}
+
+// Config configures an Analyzer. Zero fields take their defaults.
+type Config struct {
+	Interval   time.Duration // bucket length (default 5m); volumes are entries per bucket
+	Delay      time.Duration // a bucket is analyzed this long after it ends, once its batches are uploaded (default 2m)
+	Alpha      float64       // weight of a new bucket in a baseline (default 0.1)
+	Threshold  float64       // deviation in standard deviations that is an anomaly (default 3)
+	MinCount   int           // entries a volume spike or error rate spike needs, and a volume drop expects (default 20)
+	MinSamples int           // buckets a baseline learns from before it is compared with (default 12)
+	MaxCatchUp int           // buckets analyzed at most after downtime (default 12)
+	MaxObjects int           // batch objects one bucket reads at most (default 10000)
+	Timeout    time.Duration // analyzing a bucket is canceled after this long (default 5m)
+}
+
+func (c *Config) setDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Minute
+	}
+	if c.Delay <= 0 {
+		c.Delay = 2 * time.Minute
+	}
+	if c.Alpha <= 0 || c.Alpha > 1 {
+		c.Alpha = 0.1
+	}
+	if c.Threshold <= 0 {
+		c.Threshold = 3
+	}
+	if c.MinCount <= 0 {
+		c.MinCount = 20
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = 12
+	}
+	if c.MaxCatchUp <= 0 {
+		c.MaxCatchUp = 12
+	}
+	if c.MaxObjects <= 0 {
+		c.MaxObjects = 10000
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Minute
+	}
+}
+
+// Deviation is a metric of a service that deviated from its baseline in a bucket.
+type Deviation struct {
+	ProjectID string
+	Service   string
+	Metric    string // MetricVolume or MetricErrorRate
+	model.Observation
+}
+
+func (d Deviation) String() string {
+	name := d.Service
+	if name == "" {
+		name = "(no service)"
+	}
+	if d.ProjectID != "" {
+		name = d.ProjectID + "/" + name
+	}
+	if d.Metric == MetricErrorRate {
+		return fmt.Sprintf("%s error rate %s (%.1f%%, expected %.1f%%)", name, d.Anomaly, d.Value*100, d.Expected*100)
+	}
+	return fmt.Sprintf("%s volume %s (%.0f entries, expected %.0f)", name, d.Anomaly, d.Value, d.Expected)
+}
+
+// Analyzer analyzes each bucket once it is due and has the alerts judge its deviations.
+type Analyzer struct {
+	cfg    Config
+	repo   Repo
+	index  search.Index
+	logs   search.Store
+	alerts Alerts // may be nil
+
+	ctx    context.Context // canceled by Stop
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewAnalyzer starts an analyzer that looks for due buckets every Interval, the first time
+// right away. Entries are read through index and logs.
+func NewAnalyzer(cfg Config, repo Repo, index search.Index, logs search.Store, alerts Alerts) *Analyzer {
+	cfg.setDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Analyzer{cfg: cfg, repo: repo, index: index, logs: logs, alerts: alerts, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go a.loop()
+	return a
+}
+
+func (a *Analyzer) loop() {
+	defer close(a.done)
+	t := time.NewTicker(a.cfg.Interval)
+	defer t.Stop()
+	for {
+		if err := a.RunDue(a.ctx, time.Now()); err != nil && a.ctx.Err() == nil {
+			log.Printf("[anomaly] %v", err)
+		}
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stop stops the analyzer, canceling an analysis in progress, and waits for it to end.
+func (a *Analyzer) Stop() {
+	a.cancel()
+	<-a.done
+}
+
+// Config returns the configuration in use, defaults filled in.
+func (a *Analyzer) Config() Config {
+	return a.cfg
+}
+
+// RunDue analyzes the buckets that ended at least Delay before now and were not analyzed
+// yet, at most MaxCatchUp of them. Each bucket is first claimed by moving the stored cursor
+// past it, so analyzers of several servers analyze it once.
+func (a *Analyzer) RunDue(ctx context.Context, now time.Time) error {
+	latest := now.Add(-a.cfg.Delay).Truncate(a.cfg.Interval)
+	cursor, err := a.repo.Cursor(ctx)
+	if err != nil {
+		return fmt.Errorf("load cursor: %w", err)
+	}
+	start := cursor.Truncate(a.cfg.Interval)
+	if earliest := latest.Add(-time.Duration(a.cfg.MaxCatchUp) * a.cfg.Interval); start.Before(earliest) {
+		start = earliest
+	}
+	for end := start.Add(a.cfg.Interval); !end.After(latest); end = end.Add(a.cfg.Interval) {
+		claimed, err := a.repo.Claim(ctx, cursor, end)
+		if err != nil {
+			return fmt.Errorf("claim bucket ending %s: %w", end.UTC().Format(time.RFC3339), err)
+		}
+		if !claimed {
+			return nil // another server is analyzing
+		}
+		cursor = end
+		if err := a.Analyze(ctx, end.Add(-a.cfg.Interval), end); err != nil {
+			log.Printf("[anomaly] bucket %s: %v", end.Add(-a.cfg.Interval).UTC().Format(time.RFC3339), err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+type seriesKey struct{ project, service string }
+
+type counts struct{ total, errors int }
+
+// Analyze counts the entries of every project and service in [start, end), compares them
+// with and adds them to the stored baselines, and has the alerts judge the deviations. A
+// bucket with more than MaxObjects objects is skipped, as its counts would be too low.
+func (a *Analyzer) Analyze(ctx context.Context, start, end time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+	seen := make(map[seriesKey]*counts)
+	st, err := search.Scan(ctx, a.index, a.logs, search.ScanOptions{Start: start, End: end, MaxObjects: a.cfg.MaxObjects},
+		func(e *model.LogEntry, _ time.Time) bool {
+			k := seriesKey{e.ProjectID, e.Service}
+			c := seen[k]
+			if c == nil {
+				c = &counts{}
+				seen[k] = c
+			}
+			c.total++
+			if e.Level == "error" || e.Level == "fatal" {
+				c.errors++
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	if st.Truncated {
+		return fmt.Errorf("over %d batch objects, skipped", a.cfg.MaxObjects)
+	}
+	list, err := a.repo.List(ctx, "", "")
+	if err != nil {
+		return fmt.Errorf("load baselines: %w", err)
+	}
+	known := make(map[seriesKey]bool, len(list))
+	for _, b := range list {
+		known[seriesKey{b.ProjectID, b.Service}] = true
+	}
+	for k := range seen {
+		if !known[k] {
+			list = append(list, model.Baseline{ProjectID: k.project, Service: k.service})
+		}
+	}
+	var devs []Deviation
+	for i := range list {
+		b := &list[i]
+		c := seen[seriesKey{b.ProjectID, b.Service}]
+		if c == nil {
+			c = &counts{}
+		} else {
+			b.LastSeenAt = end
+		}
+		b.UpdatedAt = end
+		if obs := a.observeVolume(&b.Volume, c.total, start, end); obs.Anomaly != "" {
+			devs = append(devs, Deviation{ProjectID: b.ProjectID, Service: b.Service, Metric: MetricVolume, Observation: obs})
+		}
+		if c.total > 0 {
+			if obs := a.observeErrorRate(&b.ErrorRate, c.errors, c.total, start, end); obs.Anomaly != "" {
+				devs = append(devs, Deviation{ProjectID: b.ProjectID, Service: b.Service, Metric: MetricErrorRate, Observation: obs})
+			}
+		}
+	}
+	if err := a.repo.Save(ctx, list, end.Add(-forgetAfter)); err != nil {
+		return fmt.Errorf("save baselines: %w", err)
+	}
+	for _, d := range devs {
+		log.Printf("[anomaly] %s, %.1f standard deviations", d, d.Score)
+	}
+	if a.alerts != nil {
+		a.alerts.EvaluateAnomalies(ctx, judge(devs))
+	}
+	return nil
+}
+
+// judge returns an alerting.Judge over devs: it counts the services with deviations of the
+// alert's kind that the alert selects and names the largest of them.
+func judge(devs []Deviation) alerting.Judge {
+	return func(kind string, match func(*model.LogEntry) bool) (int, string) {
+		var picked []Deviation
+		services := make(map[seriesKey]bool)
+		for _, d := range devs {
+			if kind != alerting.AnomalyAny && kind != d.Metric {
+				continue
+			}
+			if !match(&model.LogEntry{ProjectID: d.ProjectID, Service: d.Service}) {
+				continue
+			}
+			picked = append(picked, d)
+			services[seriesKey{d.ProjectID, d.Service}] = true
+		}
+		if len(picked) == 0 {
+			return 0, "no service deviates from its baseline"
+		}
+		sort.SliceStable(picked, func(i, j int) bool { return math.Abs(picked[i].Score) > math.Abs(picked[j].Score) })
+		names := make([]string, 0, maxListed)
+		for _, d := range picked[:min(len(picked), maxListed)] {
+			names = append(names, d.String())
+		}
+		if len(picked) > maxListed {
+			names = append(names, fmt.Sprintf("%d more", len(picked)-maxListed))
+		}
+		noun := "services deviate from their baselines"
+		if len(services) == 1 {
+			noun = "service deviates from its baselines"
+		}
+		return len(services), fmt.Sprintf("%d %s: %s", len(services), noun, strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,196 @@
+package anomaly
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/alerting"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+)
+
+type memRepo struct {
+	mu        sync.Mutex
+	baselines map[seriesKey]model.Baseline
+	cursor    time.Time
+}
+
+func (r *memRepo) List(_ context.Context, projectID, service string) ([]model.Baseline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []model.Baseline
+	for _, b := range r.baselines {
+		if (projectID == "" || b.ProjectID == projectID) && (service == "" || b.Service == service) {
+			list = append(list, b)
+		}
+	}
+	return list, nil
+}
+
+func (r *memRepo) Save(_ context.Context, list []model.Baseline, forgetBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.baselines == nil {
+		r.baselines = make(map[seriesKey]model.Baseline)
+	}
+	for _, b := range list {
+		r.baselines[seriesKey{b.ProjectID, b.Service}] = b
+	}
+	for k, b := range r.baselines {
+		if b.LastSeenAt.Before(forgetBefore) {
+			delete(r.baselines, k)
+		}
+	}
+	return nil
+}
+
+func (r *memRepo) Cursor(context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cursor, nil
+}
+
+func (r *memRepo) Claim(_ context.Context, prev, next time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.cursor.Equal(prev) {
+		return false, nil
+	}
+	r.cursor = next
+	return true, nil
+}
+
+// bucketLogs serves one object per bucket, keyed by its start, with the entries gen returns
+// for that start.
+type bucketLogs struct {
+	gen func(start time.Time) []model.LogEntry
+}
+
+func (b bucketLogs) Find(_ context.Context, f repository.BatchFilter) ([]model.Batch, error) {
+	return []model.Batch{{Key: f.Start.UTC().Format(time.RFC3339)}}, nil
+}
+
+func (b bucketLogs) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	start, err := time.Parse(time.RFC3339, key)
+	if err != nil {
+		return nil, err
+	}
+	return b.gen(start), nil
+}
+
+func entries(start time.Time, service string, total, errors int) []model.LogEntry {
+	ts := start.Add(time.Second).Format(time.RFC3339)
+	list := make([]model.LogEntry, total)
+	for i := range list {
+		list[i] = model.LogEntry{Timestamp: ts, Service: service, Level: "info", ProjectID: "p1"}
+		if i < errors {
+			list[i].Level = "error"
+		}
+	}
+	return list
+}
+
+// lastJudge keeps the judge of the last analyzed bucket.
+type lastJudge struct{ judge alerting.Judge }
+
+func (l *lastJudge) EvaluateAnomalies(_ context.Context, judge alerting.Judge) { l.judge = judge }
+
+func (l *lastJudge) count(kind string) (int, string) {
+	return l.judge(kind, func(*model.LogEntry) bool { return true })
+}
+
+func TestAnalyze(t *testing.T) {
+	total, errs := 0, 0
+	logs := bucketLogs{gen: func(start time.Time) []model.LogEntry {
+		return entries(start, "api", total, errs)
+	}}
+	repo := &memRepo{}
+	alerts := &lastJudge{}
+	a := &Analyzer{cfg: Config{Interval: 5 * time.Minute}, repo: repo, index: logs, logs: logs, alerts: alerts}
+	a.cfg.setDefaults()
+
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	bucket := func(n, e int) {
+		t.Helper()
+		total, errs = n, e
+		if err := a.Analyze(context.Background(), start, start.Add(a.cfg.Interval)); err != nil {
+			t.Fatal(err)
+		}
+		start = start.Add(a.cfg.Interval)
+	}
+	for i := range a.cfg.MinSamples {
+		bucket(95+10*(i%2), 2)
+		if n, _ := alerts.count(alerting.AnomalyAny); n != 0 {
+			t.Fatalf("bucket %d: deviation while learning", i)
+		}
+	}
+	b := repo.baselines[seriesKey{"p1", "api"}]
+	if b.Volume.Overall.Samples != 12 || b.Volume.Overall.Mean < 99 || b.Volume.Overall.Mean > 101 || b.Volume.Last == nil || !b.Volume.Last.Learning {
+		t.Fatalf("volume baseline %+v", b.Volume)
+	}
+
+	bucket(400, 2)
+	if n, msg := alerts.count(alerting.AnomalyVolume); n != 1 || !strings.Contains(msg, "p1/api volume spike (400 entries, expected 100)") {
+		t.Errorf("volume spike: %d, %q", n, msg)
+	}
+	if n, _ := alerts.count(alerting.AnomalyErrorRate); n != 0 {
+		t.Error("error rate deviates with a volume spike")
+	}
+
+	bucket(100, 40)
+	if n, msg := alerts.count(alerting.AnomalyErrorRate); n != 1 || !strings.Contains(msg, "error rate spike (40.0%") {
+		t.Errorf("error rate spike: %d, %q", n, msg)
+	}
+
+	// A known service without entries is a drop.
+	bucket(0, 0)
+	if n, msg := alerts.count(alerting.AnomalyAny); n != 1 || !strings.Contains(msg, "volume drop (0 entries") {
+		t.Errorf("drop: %d, %q", n, msg)
+	}
+	if n, _ := alerts.judge(alerting.AnomalyAny, func(e *model.LogEntry) bool { return e.Service == "web" }); n != 0 {
+		t.Error("deviation of an unselected service")
+	}
+
+	bucket(100, 2)
+	if n, _ := alerts.count(alerting.AnomalyAny); n != 0 {
+		t.Error("deviation back at the baseline")
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	var mu sync.Mutex
+	analyzed := 0
+	logs := bucketLogs{gen: func(start time.Time) []model.LogEntry {
+		mu.Lock()
+		analyzed++
+		mu.Unlock()
+		return entries(start, "api", 10, 0)
+	}}
+	repo := &memRepo{}
+	a := &Analyzer{cfg: Config{Interval: 5 * time.Minute, Delay: 2 * time.Minute, MaxCatchUp: 3}, repo: repo, index: logs, logs: logs}
+	a.cfg.setDefaults()
+
+	now := time.Date(2026, 3, 1, 10, 6, 0, 0, time.UTC)
+	if err := a.RunDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	// Up to MaxCatchUp buckets ending by 10:04, i.e. 9:45 to 10:00.
+	if want := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC); analyzed != 3 || !repo.cursor.Equal(want) {
+		t.Errorf("analyzed %d, cursor %s", analyzed, repo.cursor)
+	}
+	if err := a.RunDue(context.Background(), now); err != nil || analyzed != 3 {
+		t.Errorf("second run analyzed %d: %v", analyzed, err)
+	}
+
+	// Another server analyzed the next bucket; this one carries on after it.
+	repo.cursor = time.Date(2026, 3, 1, 10, 5, 0, 0, time.UTC)
+	if err := a.RunDue(context.Background(), now.Add(5*time.Minute)); err != nil || analyzed != 3 {
+		t.Errorf("claimed bucket analyzed again: %d, %v", analyzed, err)
+	}
+	if err := a.RunDue(context.Background(), now.Add(10*time.Minute)); err != nil || analyzed != 4 {
+		t.Errorf("next bucket: %d, %v", analyzed, err)
+	}
+}
@@ -0,0 +1,93 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Kinds of deviation.
+const (
+	Spike = "spike"
+	Drop  = "drop"
+)
+
+// minRateStdDev is the smallest standard deviation an error rate is compared with, so a
+// service that never logged errors does not fire on its first one.
+const minRateStdDev = 0.01
+
+// learn adds x to w. Until 1/samples drops below alpha, the mean and variance are those of
+// all samples, so early baselines are not dominated by the first bucket.
+func learn(w *model.EWMA, x, alpha float64) {
+	w.Samples++
+	alpha = max(alpha, 1/float64(w.Samples))
+	d := x - w.Mean
+	w.Mean += alpha * d
+	w.Variance = (1 - alpha) * (w.Variance + alpha*d*d)
+}
+
+// observation compares x, the metric's value in [start, end), with the seasonal baseline of
+// the bucket's hour when it has minSamples, else the overall one. floor is the smallest
+// standard deviation to compare with given the expected value.
+func observation(m *model.MetricBaseline, x float64, start, end time.Time, minSamples int, floor func(mean float64) float64) model.Observation {
+	obs := model.Observation{Start: start, End: end, Value: x}
+	base := m.Hourly[start.UTC().Hour()]
+	obs.Seasonal = base.Samples >= minSamples
+	if !obs.Seasonal {
+		base = m.Overall
+	}
+	if base.Samples < minSamples {
+		obs.Learning = true
+		return obs
+	}
+	obs.Expected = base.Mean
+	obs.StdDev = max(math.Sqrt(base.Variance), floor(base.Mean))
+	obs.Score = (x - base.Mean) / obs.StdDev
+	return obs
+}
+
+// observeVolume judges and learns count, the entries of a service in [start, end). A spike
+// needs minCount entries, a drop an expected minCount.
+func (a *Analyzer) observeVolume(m *model.MetricBaseline, count int, start, end time.Time) model.Observation {
+	x := float64(count)
+	// Counts vary at least like a Poisson process.
+	obs := observation(m, x, start, end, a.cfg.MinSamples, func(mean float64) float64 { return math.Sqrt(max(mean, 1)) })
+	switch {
+	case obs.Learning:
+	case obs.Score >= a.cfg.Threshold && count >= a.cfg.MinCount:
+		obs.Anomaly = Spike
+	case obs.Score <= -a.cfg.Threshold && obs.Expected >= float64(a.cfg.MinCount):
+		obs.Anomaly = Drop
+	}
+	a.learn(m, x, start, obs)
+	return obs
+}
+
+// observeErrorRate judges and learns the share of errors among total entries in [start,
+// end); total must be positive. A spike needs total to be at least minCount.
+func (a *Analyzer) observeErrorRate(m *model.MetricBaseline, errs, total int, start, end time.Time) model.Observation {
+	x := float64(errs) / float64(total)
+	// A rate measured over total entries varies at least like a binomial proportion.
+	obs := observation(m, x, start, end, a.cfg.MinSamples, func(mean float64) float64 {
+		return max(math.Sqrt(mean*(1-mean)/float64(total)), minRateStdDev)
+	})
+	if !obs.Learning && obs.Score >= a.cfg.Threshold && total >= a.cfg.MinCount {
+		obs.Anomaly = Spike
+	}
+	a.learn(m, x, start, obs)
+	return obs
+}
+
+// learn adds x to the baselines of m and records obs as its last observation. A value
+// outside the normal band is learned as the band's edge, so one spike does not inflate the
+// baseline while a lasting change is still adopted bucket by bucket.
+func (a *Analyzer) learn(m *model.MetricBaseline, x float64, start time.Time, obs model.Observation) {
+	if !obs.Learning {
+		band := a.cfg.Threshold * obs.StdDev
+		x = min(max(x, obs.Expected-band), obs.Expected+band)
+	}
+	learn(&m.Overall, x, a.cfg.Alpha)
+	learn(&m.Hourly[start.UTC().Hour()], x, a.cfg.Alpha)
+	m.Last = &obs
+}
@@ -5,90 +5,244 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/metrics"
 	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pkg"
 	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"github.com/akave-ai/akavelog/internal/wal"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // BatcherConfig configures batch size and flush interval.
 type BatcherConfig struct {
 	MaxBatchSize  int           // flush when batch has this many entries
+	MaxBatchBytes int           // flush when the batch's uncompressed JSON reaches this many bytes
 	FlushInterval time.Duration // flush at least this often
+	// MaxObjectBytes caps each uploaded object; larger batches are split into several objects.
+	// It counts uncompressed JSON, or encoded bytes when ObjectSizeCompressed is set. An entry
+	// larger than the cap is uploaded on its own.
+	MaxObjectBytes       int
+	ObjectSizeCompressed bool
+	// Codec encodes each object: gzipped JSON arrays (the default), zstd NDJSON, plain NDJSON
+	// or Parquet. It is recorded in the object's metadata and key extension.
+	Codec storage.Codec
+	// SpillDir holds objects whose upload failed until a retry succeeds, so they survive a
+	// restart. When empty they wait in memory.
+	SpillDir string
+	// MaxRetryBytes caps the objects waiting for a retry; beyond it the oldest are dropped.
+	MaxRetryBytes int64
+	// Workers is how many batches are encoded and uploaded at once.
+	Workers int
 }
 
-// DefaultBatcherConfig returns defaults: 1000 entries or 30s.
+// DefaultBatcherConfig returns defaults: 1000 entries, 32 MiB or 30s, gzip-JSON objects of at
+// most 32 MiB uncompressed, up to 1 GiB waiting for a retry, and one upload worker per CPU.
 func DefaultBatcherConfig() BatcherConfig {
 	return BatcherConfig{
-		MaxBatchSize:  1000,
-		FlushInterval: 30 * time.Second,
+		MaxBatchSize:   1000,
+		MaxBatchBytes:  32 << 20,
+		FlushInterval:  30 * time.Second,
+		MaxObjectBytes: 32 << 20,
+		Codec:          storage.CodecGzipJSON,
+		MaxRetryBytes:  1 << 30,
+		Workers:        runtime.GOMAXPROCS(0),
 	}
 }
 
+// withDefaults returns cfg with the defaults in place of unset settings.
+func (cfg BatcherConfig) withDefaults() BatcherConfig {
+	def := DefaultBatcherConfig()
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = def.MaxBatchSize
+	}
+	if cfg.MaxBatchBytes <= 0 {
+		cfg.MaxBatchBytes = def.MaxBatchBytes
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = def.FlushInterval
+	}
+	if cfg.MaxObjectBytes <= 0 {
+		cfg.MaxObjectBytes = def.MaxObjectBytes
+	}
+	if cfg.Codec == "" {
+		cfg.Codec = def.Codec
+	}
+	if cfg.MaxRetryBytes <= 0 {
+		cfg.MaxRetryBytes = def.MaxRetryBytes
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	return cfg
+}
+
 // Batcher implements inputs.InputBuffer. It validates log payloads, batches them,
 // and on flush compresses and uploads to Akave O3 (if configured).
+//
+// Entries are sharded into partitions by project, key prefix and storage target (see
+// partitionKey). Each
+// partition is flushed on its own, when it is full or its oldest entry is FlushInterval old,
+// and sealed batches are uploaded by a pool of Workers, so a slow or busy project does not
+// hold up the others.
 type Batcher struct {
-	mu      sync.Mutex
-	logs    []model.LogEntry
-	config  BatcherConfig
-	o3      *storage.O3Client
-	stop    chan struct{}
-	done    chan struct{}
-	project string
-	opts    *BatcherOpts
+	mu         sync.Mutex
+	partitions map[partitionKey]*partition
+	config     BatcherConfig
+	targets    map[string]*storeTarget // "" is the default store; nil without O3
+	warned     sync.Map                // storage targets unknownTarget logged
+	jobs       chan job
+	sendMu     sync.RWMutex // Insert holds it shared while sending a job; Stop exclusively
+	stopped    bool
+	workers    sync.WaitGroup
+	stop       chan struct{}
+	done       chan struct{}
+	project    string
+	opts       *BatcherOpts
+	wal        *walRefs // nil unless opts.WAL is set
 }
 
 // BatcherOpts optional callbacks for demo UI (recent logs, upload status).
 type BatcherOpts struct {
 	OnLog   func(entry *model.LogEntry)     // called for each validated log
-	OnFlush func(count int, key string)     // called after successful upload
+	OnFlush func(b model.Batch)             // called after each object is uploaded
+	// OnUploadError, when set, is called when an object fails to upload, with its key and
+	// entries, before it is queued for a retry; or when a batch cannot be encoded, with no key.
+	OnUploadError func(key string, entries int, err error)
+	// KeyPrefix returns the top-level O3 prefix for an entry ("" for logs/), e.g. its
+	// stream's o3_prefix. Entries with different prefixes are batched in separate partitions.
+	KeyPrefix func(entry *model.LogEntry) string
+	// Target returns the storage target an entry is uploaded to ("" for the default store),
+	// e.g. the o3_target of its stream or project. Entries naming a target the store does not
+	// have are uploaded to the default store.
+	Target func(entry *model.LogEntry) string
+	// WAL, when set, records every accepted entry before Insert returns; segments are removed
+	// once their entries are uploaded, and entries left from a previous run are replayed by
+	// NewBatcher. The batcher closes it on Stop.
+	WAL *wal.Log
 }
 
-// NewBatcher creates a batcher that flushes to O3 when configured. opts may be nil.
+// NewBatcher creates a batcher that flushes to O3 when configured, and to each of its storage
+// targets (see storage.O3Client.SetTargets). projectID is used for entries without a valid
+// project_id. opts may be nil.
 func NewBatcher(cfg BatcherConfig, o3 *storage.O3Client, projectID string, opts *BatcherOpts) *Batcher {
-	if cfg.MaxBatchSize <= 0 {
-		cfg.MaxBatchSize = DefaultBatcherConfig().MaxBatchSize
+	cfg = cfg.withDefaults()
+	b := &Batcher{
+		partitions: make(map[partitionKey]*partition),
+		config:     cfg,
+		jobs:       make(chan job, cfg.Workers),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		project:    projectID,
+		opts:       opts,
 	}
-	if cfg.FlushInterval <= 0 {
-		cfg.FlushInterval = DefaultBatcherConfig().FlushInterval
+	if o3 != nil {
+		b.setStore("", o3)
+		for _, name := range o3.TargetNames() {
+			b.setStore(name, o3.Target(name))
+		}
 	}
-	b := &Batcher{
-		logs:    make([]model.LogEntry, 0, cfg.MaxBatchSize),
-		config:  cfg,
-		o3:      o3,
-		stop:    make(chan struct{}),
-		done:    make(chan struct{}),
-		project: projectID,
-		opts:    opts,
+	if opts != nil && opts.WAL != nil {
+		b.wal = newWALRefs(opts.WAL)
+		b.replayWAL()
+	}
+	for range cfg.Workers {
+		b.workers.Add(1)
+		go b.worker()
 	}
 	go b.flushLoop()
 	return b
 }
 
-// Insert implements inputs.InputBuffer. Parses and validates JSON; on success appends to batch and may flush.
-func (b *Batcher) Insert(raw []byte) {
+// Insert implements inputs.InputBuffer. Parses and validates JSON; on success appends to its
+// partition's batch and may flush it. Invalid payloads are logged and dropped; it returns nil
+// unless a durable insert (see inputs.WithDurable) could not be written to the WAL.
+func (b *Batcher) Insert(raw []byte) error {
+	return b.InsertContext(context.Background(), raw)
+}
+
+// InsertContext is Insert for a payload whose trace ctx carries; the span of the flush that
+// uploads the entry links to it.
+func (b *Batcher) InsertContext(ctx context.Context, raw []byte) error {
 	entry, err := ValidateLog(raw)
 	if err != nil {
 		log.Printf("[batcher] invalid log: %v", err)
-		return
+		return nil
 	}
+	if entry.ID == "" {
+		// Entries that did not come through an input's pipeline.
+		entry.ID = pkg.NewULID()
+	}
+	normalized, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[batcher] marshal log: %v", err)
+		return nil
+	}
+	key := b.partitionOf(entry)
 	b.mu.Lock()
-	b.logs = append(b.logs, *entry)
-	shouldFlush := len(b.logs) >= b.config.MaxBatchSize
+	var seg uint64
+	logged := false
+	if b.wal != nil {
+		if seg, logged = b.wal.append(normalized); !logged && inputs.Durable(ctx) {
+			b.mu.Unlock()
+			return inputs.ErrNotDurable
+		}
+	}
+	p := b.partition(key)
+	if logged {
+		p.segs[seg]++
+	}
+	p.add(*entry, len(normalized))
+	p.link(trace.SpanContextFromContext(ctx))
+	var j *job
+	if len(p.logs) >= b.config.MaxBatchSize || p.bytes >= b.config.MaxBatchBytes {
+		j = b.seal(key, flushSize)
+	}
 	b.mu.Unlock()
 	if b.opts != nil && b.opts.OnLog != nil {
 		b.opts.OnLog(entry)
 	}
-	if shouldFlush {
-		b.flush(context.Background())
+	if j != nil {
+		b.submit(*j)
+	}
+	return nil
+}
+
+// submit hands j to the workers, or stores it in the calling goroutine once Stop has closed
+// the queue.
+func (b *Batcher) submit(j job) {
+	b.sendMu.RLock()
+	if !b.stopped {
+		b.jobs <- j
+		b.sendMu.RUnlock()
+		return
 	}
+	b.sendMu.RUnlock()
+	b.storeBatch(context.Background(), j)
 }
 
+func (b *Batcher) worker() {
+	defer b.workers.Done()
+	for j := range b.jobs {
+		b.storeBatch(context.Background(), j)
+	}
+}
+
+// flushLoop seals every partition whose oldest entry has waited FlushInterval.
 func (b *Batcher) flushLoop() {
-	ticker := time.NewTicker(b.config.FlushInterval)
+	ticker := time.NewTicker(min(b.settings().FlushInterval, time.Second))
 	defer ticker.Stop()
 	for {
 		select {
@@ -96,56 +250,382 @@ func (b *Batcher) flushLoop() {
 			close(b.done)
 			return
 		case <-ticker.C:
-			b.flush(context.Background())
+			for _, j := range b.sealDue(time.Now()) {
+				b.submit(j)
+			}
 		}
 	}
 }
 
-// flush serializes the current batch, gzips it, uploads to O3, and clears the batch.
-func (b *Batcher) flush(ctx context.Context) {
+// Flush seals every partition now, rather than when it is full or due, and waits until
+// their batches are stored or queued for a retry. It returns the batches and entries flushed.
+func (b *Batcher) Flush(ctx context.Context) (batches, entries int) {
+	return b.flush(ctx, flushManual)
+}
+
+// flush seals every partition for reason and waits until their batches are stored or queued
+// for a retry.
+func (b *Batcher) flush(ctx context.Context, reason string) (batches, entries int) {
 	b.mu.Lock()
-	if len(b.logs) == 0 {
-		b.mu.Unlock()
-		return
+	var jobs []job
+	for key := range b.partitions {
+		j := b.seal(key, reason)
+		jobs = append(jobs, *j)
+		entries += len(j.logs)
 	}
-	snapshot := make([]model.LogEntry, len(b.logs))
-	copy(snapshot, b.logs)
-	b.logs = b.logs[:0]
 	b.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.storeBatch(ctx, j)
+		}()
+	}
+	wg.Wait()
+	return len(jobs), entries
+}
 
-	payload, err := json.Marshal(snapshot)
-	if err != nil {
-		log.Printf("[batcher] marshal batch: %v", err)
-		return
+// sealDue seals the partitions whose oldest entry is at least FlushInterval old.
+func (b *Batcher) sealDue(now time.Time) []job {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var jobs []job
+	for key, p := range b.partitions {
+		if now.Sub(p.since) >= b.config.FlushInterval {
+			jobs = append(jobs, *b.seal(key, flushInterval))
+		}
 	}
-	var buf bytes.Buffer
-	w := gzip.NewWriter(&buf)
-	if _, err := w.Write(payload); err != nil {
-		log.Printf("[batcher] gzip: %v", err)
-		return
+	return jobs
+}
+
+// storeBatch uploads one sealed batch. Objects that fail go to the retry queue; the batch's WAL
+// entries are released once every object is stored, or spilled to disk.
+func (b *Batcher) storeBatch(ctx context.Context, j job) {
+	ctx, span := tracing.Start(ctx, "batcher.flush",
+		attribute.String("project", j.key.project),
+		attribute.String("prefix", j.key.prefix),
+		attribute.String("target", j.key.target),
+		attribute.String("reason", j.reason),
+		attribute.Int("entries", len(j.logs)),
+	)
+	for _, l := range j.links {
+		span.AddLink(l)
 	}
-	if err := w.Close(); err != nil {
-		log.Printf("[batcher] gzip close: %v", err)
-		return
+	defer span.End()
+	start := time.Now()
+	failed, ok := b.upload(ctx, j.key, j.logs)
+	metrics.BatcherFlushDuration.Observe(time.Since(start).Seconds())
+	if !ok {
+		span.SetStatus(codes.Error, "encode failed")
+	} else if len(failed) > 0 {
+		span.SetStatus(codes.Error, strconv.Itoa(len(failed))+" objects queued for retry")
+	}
+	var release func()
+	if ok && b.wal != nil {
+		release = func() { b.wal.release(j.segs) }
 	}
-	compressed := buf.Bytes()
+	// When !ok the entries stay counted, so their segments are kept and replayed on the
+	// next start.
+	if len(failed) > 0 {
+		b.targets[j.key.target].retry.add(failed, release)
+	} else if release != nil {
+		release()
+	}
+}
 
-	if b.o3 != nil {
-		key := storage.KeyForBatch(b.project, uuid.New().String(), ".json.gz")
-		if err := b.o3.PutObject(ctx, key, compressed, "application/gzip"); err != nil {
-			log.Printf("[batcher] upload to O3: %v", err)
-			return
+// storeTarget is where the batches of one storage target are uploaded, with the retry queue
+// of its failed uploads.
+type storeTarget struct {
+	store putter
+	retry *retryQueue
+}
+
+// setStore sets where the batches of storage target name ("" for the default) are uploaded
+// and starts its retry queue. A named target spills under targets/<name> of SpillDir.
+func (b *Batcher) setStore(name string, store putter) {
+	store = meteredPutter{store}
+	dir := b.config.SpillDir
+	if dir != "" && name != "" {
+		dir = filepath.Join(dir, "targets", name)
+	}
+	var onUpload func(model.Batch)
+	if b.opts != nil && b.opts.OnFlush != nil {
+		onFlush := b.opts.OnFlush
+		onUpload = func(batch model.Batch) {
+			batch.Target = name
+			onFlush(batch)
+		}
+	}
+	if b.targets == nil {
+		b.targets = make(map[string]*storeTarget)
+	}
+	b.targets[name] = &storeTarget{store: store, retry: newRetryQueue(store, dir, b.config.MaxRetryBytes, onUpload)}
+}
+
+// Codec returns the codec batch objects are encoded with.
+func (b *Batcher) Codec() storage.Codec {
+	return b.settings().Codec
+}
+
+// settings returns the current configuration; Reconfigure may change it at any time.
+func (b *Batcher) settings() BatcherConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.config
+}
+
+// Reconfigure applies cfg, with defaults for unset settings, and returns the names of the
+// settings that changed. Batch limits and the flush interval apply to the partitions as they
+// are; object size and codec to the batches uploaded from now on. Workers and SpillDir only
+// take effect on a restart: when they differ they are returned in restart and left as they are.
+func (b *Batcher) Reconfigure(cfg BatcherConfig) (changed, restart []string) {
+	cfg = cfg.withDefaults()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old := b.config
+	for _, c := range []struct {
+		name string
+		diff bool
+	}{
+		{"max_batch_size", cfg.MaxBatchSize != old.MaxBatchSize},
+		{"max_batch_bytes", cfg.MaxBatchBytes != old.MaxBatchBytes},
+		{"flush_interval", cfg.FlushInterval != old.FlushInterval},
+		{"max_object_bytes", cfg.MaxObjectBytes != old.MaxObjectBytes},
+		{"object_size_compressed", cfg.ObjectSizeCompressed != old.ObjectSizeCompressed},
+		{"codec", cfg.Codec != old.Codec},
+		{"max_retry_bytes", cfg.MaxRetryBytes != old.MaxRetryBytes},
+	} {
+		if c.diff {
+			changed = append(changed, c.name)
+		}
+	}
+	if cfg.Workers != old.Workers {
+		restart = append(restart, "workers")
+	}
+	if cfg.SpillDir != old.SpillDir {
+		restart = append(restart, "spill_dir")
+	}
+	cfg.Workers, cfg.SpillDir = old.Workers, old.SpillDir
+	b.config = cfg
+	if cfg.MaxRetryBytes != old.MaxRetryBytes {
+		for _, t := range b.targets {
+			t.retry.setMaxBytes(cfg.MaxRetryBytes)
+		}
+	}
+	return changed, restart
+}
+
+// RetryStats reports the upload retry queues of every storage target together, and those of
+// the named targets in Targets; it is empty without O3.
+func (b *Batcher) RetryStats() RetryStats {
+	var st RetryStats
+	for name, t := range b.targets {
+		ts := t.retry.Stats()
+		st.merge(ts)
+		if name != "" {
+			if st.Targets == nil {
+				st.Targets = make(map[string]RetryStats)
+			}
+			st.Targets[name] = ts
+		}
+	}
+	return st
+}
+
+// upload encodes entries with the configured codec and puts them under the partition's prefix (logs/ when empty) and
+// project, in its storage target, split into objects of at most MaxObjectBytes. It returns the
+// objects that failed to upload, and false when the entries could not be encoded. While older
+// objects of the target wait for a retry, new ones queue behind them without an attempt.
+func (b *Batcher) upload(ctx context.Context, key partitionKey, entries []model.LogEntry) ([]object, bool) {
+	cfg := b.settings()
+	objects, err := splitObjects(entries, cfg.MaxObjectBytes, cfg.ObjectSizeCompressed, cfg.Codec)
+	if err != nil {
+		log.Printf("[batcher] %v", err)
+		b.uploadFailed("", len(entries), err)
+		return nil, false
+	}
+	if len(objects) > 1 {
+		log.Printf("[batcher] split %d logs into %d objects", len(entries), len(objects))
+	}
+
+	t := b.targets[key.target]
+	if t == nil {
+		return nil, true
+	}
+	prefix := key.prefix
+	if prefix == "" {
+		prefix = "logs"
+	}
+	for i := range objects {
+		objects[i].key = storage.KeyForBatchUnder(prefix, key.project, uuid.New().String(), cfg.Codec.Ext())
+	}
+	if t.retry.pending() {
+		return objects, true
+	}
+	var failed []object
+	for _, obj := range objects {
+		contentType, meta := objectMeta(obj.key, obj.count)
+		cid, err := t.store.PutObjectCID(ctx, obj.key, obj.data, contentType, meta)
+		if err != nil {
+			log.Printf("[batcher] upload to O3: %v (queued for retry)", err)
+			t.retry.failed(err)
+			b.uploadFailed(obj.key, obj.count, err)
+			failed = append(failed, obj)
+			continue
 		}
-		log.Printf("[batcher] uploaded %d logs to %s", len(snapshot), key)
+		log.Printf("[batcher] uploaded %d logs to %s", obj.count, obj.key)
 		if b.opts != nil && b.opts.OnFlush != nil {
-			b.opts.OnFlush(len(snapshot), key)
+			batch := storage.DescribeBatch(obj.key, obj.data, obj.entries)
+			batch.Target, batch.CID = key.target, cid
+			b.opts.OnFlush(batch)
 		}
 	}
+	return failed, true
+}
+
+// uploadFailed reports a failed upload or encoding to OnUploadError, if set.
+func (b *Batcher) uploadFailed(key string, entries int, err error) {
+	if b.opts != nil && b.opts.OnUploadError != nil {
+		b.opts.OnUploadError(key, entries, err)
+	}
+}
+
+// object is an encoded batch object holding count entries.
+type object struct {
+	key     string
+	data    []byte
+	count   int
+	entries []model.LogEntry // encoded in data; nil once queued for a retry
 }
 
-// Stop stops the flush loop and flushes any remaining logs.
+// splitObjects encodes entries into objects of codec c of at most limit bytes each, counted
+// before compression unless compressed is set. Uncompressed sizes are known per entry, so
+// entries are packed greedily; compressed objects are halved until they fit.
+func splitObjects(entries []model.LogEntry, limit int, compressed bool, c storage.Codec) ([]object, error) {
+	rows := make([][]byte, len(entries))
+	for i := range entries {
+		raw, err := json.Marshal(&entries[i])
+		if err != nil {
+			return nil, fmt.Errorf("marshal batch: %w", err)
+		}
+		rows[i] = raw
+	}
+	// encode builds the object of entries[i:j]; Parquet is built from the entries, the other
+	// codecs from their marshaled rows.
+	encode := func(i, j int) (object, error) {
+		var data []byte
+		var err error
+		if c == storage.CodecParquet {
+			data, err = storage.EncodeParquet(entries[i:j])
+		} else {
+			data, err = storage.EncodeRows(c, rows[i:j])
+		}
+		return object{data: data, count: j - i, entries: entries[i:j]}, err
+	}
+	var out []object
+	if compressed {
+		var split func(i, j int) error
+		split = func(i, j int) error {
+			obj, err := encode(i, j)
+			if err != nil {
+				return err
+			}
+			if len(obj.data) <= limit || j-i == 1 {
+				out = append(out, obj)
+				return nil
+			}
+			half := i + (j-i)/2
+			if err := split(i, half); err != nil {
+				return err
+			}
+			return split(half, j)
+		}
+		return out, split(0, len(rows))
+	}
+	start, size := 0, 2 // the brackets
+	for i, row := range rows {
+		if i > start && size+len(row)+1 > limit {
+			obj, err := encode(start, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, obj)
+			start, size = i, 2
+		}
+		size += len(row) + 1
+	}
+	obj, err := encode(start, len(rows))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, obj), nil
+}
+
+// objectMeta returns the content type and metadata of the object at key holding count entries.
+// The codec is taken from the key's extension, so spilled objects need nothing else.
+func objectMeta(key string, count int) (string, map[string]string) {
+	c := storage.CodecForKey(key)
+	return c.ContentType(), map[string]string{
+		storage.MetaCodec: string(c),
+		storage.MetaCount: strconv.Itoa(count),
+	}
+}
+
+// EncodeBatch returns entries as the gzipped JSON array stored in each batch object.
+func EncodeBatch(entries []model.LogEntry) ([]byte, error) {
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch: %w", err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(payload); err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("gzip close: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Stop stops the flush loop, flushes every partition, waits for the workers and gives queued
+// retries a last attempt.
 func (b *Batcher) Stop() {
 	close(b.stop)
 	<-b.done
-	b.flush(context.Background())
+	b.sendMu.Lock()
+	b.stopped = true
+	close(b.jobs)
+	b.sendMu.Unlock()
+	b.workers.Wait()
+	b.flush(context.Background(), flushStop)
+	for _, t := range b.targets {
+		t.retry.Stop()
+	}
+	if b.wal != nil {
+		b.wal.Close()
+	}
+}
+
+// replayWAL adds the entries a previous run left in the WAL to their partitions. They are
+// released together under replaySeg.
+func (b *Batcher) replayWAL() {
+	recs := b.wal.log.Replay()
+	n := 0
+	for _, raw := range recs {
+		var e model.LogEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			log.Printf("[batcher] wal replay: %v", err)
+			continue
+		}
+		p := b.partition(b.partitionOf(&e))
+		p.add(e, len(raw))
+		p.segs[replaySeg]++
+		n++
+	}
+	b.wal.hold(replaySeg, n)
+	if n > 0 {
+		log.Printf("[batcher] replayed %d logs from the wal", n)
+	}
 }
@@ -0,0 +1,353 @@
+package batcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/wal"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBatcherReplaysWAL(t *testing.T) {
+	dir := t.TempDir()
+	l, err := wal.Open(wal.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBatcher(BatcherConfig{}, nil, "default", &BatcherOpts{WAL: l})
+	b.Insert([]byte(`{"service":"api","message":"accepted before the crash"}`))
+	b.Insert([]byte(`not json`))
+	l.Close() // crash: the batch is never flushed
+
+	l, err = wal.Open(wal.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = NewBatcher(BatcherConfig{}, nil, "default", &BatcherOpts{WAL: l})
+	logs := pendingLogs(b)
+	n := len(logs)
+	msg := ""
+	if n > 0 {
+		msg = logs[0].Message
+	}
+	if n != 1 || msg != "accepted before the crash" {
+		t.Fatalf("replayed %d logs (%q), want the accepted one", n, msg)
+	}
+
+	b.Stop()
+	l, _ = wal.Open(wal.Options{Dir: dir})
+	defer l.Close()
+	if recs := l.Replay(); len(recs) != 0 {
+		t.Errorf("%d records left in the wal after a flush", len(recs))
+	}
+}
+
+func TestBatcherDurableInsertNeedsWAL(t *testing.T) {
+	l, err := wal.Open(wal.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBatcher(BatcherConfig{}, nil, "default", &BatcherOpts{WAL: l})
+	durable := inputs.WithDurable(context.Background())
+	if err := b.InsertContext(durable, []byte(`{"service":"api","message":"logged"}`)); err != nil {
+		t.Fatalf("durable insert: %v", err)
+	}
+	l.Close() // every later append fails
+	if err := b.InsertContext(durable, []byte(`{"service":"api","message":"lost"}`)); !errors.Is(err, inputs.ErrNotDurable) {
+		t.Fatalf("durable insert without a WAL: err = %v, want ErrNotDurable", err)
+	}
+	if err := b.Insert([]byte(`{"service":"api","message":"in memory"}`)); err != nil {
+		t.Fatalf("accepted insert: %v", err)
+	}
+	logs := pendingLogs(b)
+	if len(logs) != 2 || logs[0].Message != "logged" || logs[1].Message != "in memory" {
+		t.Fatalf("pending %+v, want the logged and the in-memory entry", logs)
+	}
+}
+
+func TestBatcherFlushesOnBytes(t *testing.T) {
+	b := NewBatcher(BatcherConfig{MaxBatchBytes: 500}, nil, "default", nil)
+	defer b.Stop()
+	msg := strings.Repeat("x", 300)
+	b.Insert([]byte(`{"service":"api","message":"` + msg + `"}`))
+	if n := len(pendingLogs(b)); n != 1 {
+		t.Fatalf("batch has %d logs after the first insert, want 1", n)
+	}
+	b.Insert([]byte(`{"service":"api","message":"` + msg + `"}`))
+	if n := len(pendingLogs(b)); n != 0 {
+		t.Fatalf("batch has %d logs past the byte limit, want a flush", n)
+	}
+}
+
+func TestBatcherReconfigure(t *testing.T) {
+	b := NewBatcher(BatcherConfig{MaxBatchSize: 10, Workers: 1}, nil, "default", nil)
+	defer b.Stop()
+	for range 3 {
+		b.Insert([]byte(`{"service":"api","message":"m"}`))
+	}
+	changed, restart := b.Reconfigure(BatcherConfig{MaxBatchSize: 4, Workers: 3, Codec: storage.CodecNDJSON})
+	if strings.Join(changed, ",") != "max_batch_size,codec" || strings.Join(restart, ",") != "workers" {
+		t.Fatalf("Reconfigure changed %v, restart %v", changed, restart)
+	}
+	if b.Codec() != storage.CodecNDJSON || b.settings().Workers != 1 {
+		t.Errorf("settings = %+v, want the new codec and the old workers", b.settings())
+	}
+	b.Insert([]byte(`{"service":"api","message":"m"}`))
+	if n := len(pendingLogs(b)); n != 0 {
+		t.Fatalf("batch has %d logs at the new size limit, want a flush", n)
+	}
+	if changed, restart := b.Reconfigure(BatcherConfig{MaxBatchSize: 4, Workers: 1, Codec: storage.CodecNDJSON}); changed != nil || restart != nil {
+		t.Errorf("Reconfigure with the same settings changed %v, restart %v", changed, restart)
+	}
+}
+
+// pendingLogs returns the entries in b's open partitions.
+func pendingLogs(b *Batcher) []model.LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []model.LogEntry
+	for _, p := range b.partitions {
+		out = append(out, p.logs...)
+	}
+	return out
+}
+
+func TestSplitObjects(t *testing.T) {
+	entries := make([]model.LogEntry, 10)
+	for i := range entries {
+		entries[i] = model.LogEntry{Service: "api", Message: strings.Repeat("m", 100) + strconv.Itoa(i)}
+	}
+	whole, err := EncodeBatch(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, compressed := range []bool{false, true} {
+		limit := 400
+		if compressed {
+			limit = 100
+		}
+		objects, err := splitObjects(entries, limit, compressed, storage.CodecGzipJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) < 2 {
+			t.Fatalf("compressed=%v: %d objects, want a split", compressed, len(objects))
+		}
+		var got []model.LogEntry
+		for _, obj := range objects {
+			raw := gunzip(t, obj.data)
+			if !compressed && len(raw) > limit {
+				t.Errorf("object of %d bytes over the %d limit", len(raw), limit)
+			}
+			if compressed && len(obj.data) > limit && obj.count > 1 {
+				t.Errorf("object of %d gzipped bytes over the %d limit", len(obj.data), limit)
+			}
+			var part []model.LogEntry
+			if err := json.Unmarshal(raw, &part); err != nil {
+				t.Fatal(err)
+			}
+			if len(part) != obj.count {
+				t.Errorf("object holds %d entries, count says %d", len(part), obj.count)
+			}
+			got = append(got, part...)
+		}
+		if len(got) != len(entries) || got[9].Message != entries[9].Message {
+			t.Fatalf("compressed=%v: split lost or reordered entries", compressed)
+		}
+	}
+	objects, _ := splitObjects(entries, 1<<20, false, storage.CodecGzipJSON)
+	if len(objects) != 1 || !bytes.Equal(gunzip(t, objects[0].data), gunzip(t, whole)) {
+		t.Fatal("unsplit object differs from EncodeBatch")
+	}
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestBatcherPartitionsByProject(t *testing.T) {
+	sizeFlushes := testutil.ToFloat64(metrics.BatcherFlushes.WithLabelValues(flushSize))
+	stopFlushes := testutil.ToFloat64(metrics.BatcherFlushes.WithLabelValues(flushStop))
+	uploads := testutil.ToFloat64(metrics.O3Uploads)
+	store := &flakyStore{}
+	b := NewBatcher(BatcherConfig{MaxBatchSize: 2, Workers: 2}, nil, "default", nil)
+	b.setStore("", store)
+	b.Insert([]byte(`{"service":"api","message":"a1","project_id":"alpha"}`))
+	b.Insert([]byte(`{"service":"api","message":"b1","project_id":"beta"}`))
+	b.Insert([]byte(`{"service":"api","message":"a2","project_id":"alpha"}`))
+	b.Insert([]byte(`{"service":"api","message":"x","project_id":"../escape"}`))
+
+	parts := b.Partitions()
+	if len(parts) != 2 || parts[0].Project != "beta" || parts[1].Project != "default" {
+		t.Fatalf("open partitions = %+v, want beta and default", parts)
+	}
+	b.Stop()
+	prefixes := make(map[string]int)
+	for _, key := range store.keys {
+		prefixes[strings.Join(strings.SplitN(key, "/", 3)[:2], "/")]++
+	}
+	if len(store.keys) != 3 || prefixes["logs/alpha"] != 1 || prefixes["logs/beta"] != 1 || prefixes["logs/default"] != 1 {
+		t.Fatalf("uploaded %v, want one object per project", store.keys)
+	}
+	if n := testutil.ToFloat64(metrics.BatcherFlushes.WithLabelValues(flushSize)) - sizeFlushes; n != 1 {
+		t.Errorf("size flushes = %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(metrics.BatcherFlushes.WithLabelValues(flushStop)) - stopFlushes; n != 2 {
+		t.Errorf("stop flushes = %v, want 2", n)
+	}
+	if n := testutil.ToFloat64(metrics.O3Uploads) - uploads; n != 3 {
+		t.Errorf("uploads = %v, want 3", n)
+	}
+}
+
+func TestBatcherRoutesToStorageTargets(t *testing.T) {
+	def, regulated := &flakyStore{}, &flakyStore{fail: true}
+	var recorded []model.Batch
+	var mu sync.Mutex
+	opts := &BatcherOpts{
+		Target: func(e *model.LogEntry) string { return e.Tags["target"] },
+		OnFlush: func(b model.Batch) {
+			mu.Lock()
+			recorded = append(recorded, b)
+			mu.Unlock()
+		},
+	}
+	b := NewBatcher(BatcherConfig{Workers: 1}, nil, "default", opts)
+	b.setStore("", def)
+	b.setStore("regulated", regulated)
+	b.Insert([]byte(`{"service":"api","message":"plain"}`))
+	b.Insert([]byte(`{"service":"api","message":"kept apart","tags":{"target":"regulated"}}`))
+	b.Insert([]byte(`{"service":"api","message":"unknown target","tags":{"target":"gone"}}`))
+
+	if parts := b.Partitions(); len(parts) != 2 || parts[1].Target != "regulated" {
+		t.Fatalf("partitions = %+v, want the default and regulated targets", parts)
+	}
+	b.Flush(context.Background())
+	if len(def.keys) != 1 {
+		t.Fatalf("default store got %v, want one object with both untargeted entries", def.keys)
+	}
+	st := b.RetryStats()
+	if st.Depth != 1 || st.Targets["regulated"].Depth != 1 {
+		t.Fatalf("retry stats = %+v, want the regulated object queued under its target", st)
+	}
+	regulated.setFail(false)
+	b.Stop()
+	if len(regulated.keys) != 1 {
+		t.Fatalf("regulated store got %v, want its object after the retry", regulated.keys)
+	}
+	targets := map[string]int{}
+	for _, r := range recorded {
+		targets[r.Target]++
+		if r.CID != "cid:"+r.Key {
+			t.Errorf("batch %s recorded with cid %q", r.Key, r.CID)
+		}
+	}
+	if targets[""] != 1 || targets["regulated"] != 1 {
+		t.Errorf("recorded batches by target = %v", targets)
+	}
+}
+
+func TestBatcherFlush(t *testing.T) {
+	store := &flakyStore{}
+	b := NewBatcher(BatcherConfig{Workers: 1}, nil, "default", nil)
+	b.setStore("", store)
+	defer b.Stop()
+	b.Insert([]byte(`{"service":"api","message":"a1","project_id":"alpha"}`))
+	b.Insert([]byte(`{"service":"api","message":"a2","project_id":"alpha"}`))
+	b.Insert([]byte(`{"service":"api","message":"b1","project_id":"beta"}`))
+
+	if batches, entries := b.Flush(context.Background()); batches != 2 || entries != 3 {
+		t.Errorf("Flush = %d batches, %d entries, want 2 and 3", batches, entries)
+	}
+	if len(store.keys) != 2 || len(b.Partitions()) != 0 {
+		t.Errorf("uploaded %v with partitions %+v left, want 2 objects and none", store.keys, b.Partitions())
+	}
+	if batches, entries := b.Flush(context.Background()); batches != 0 || entries != 0 {
+		t.Errorf("second Flush = %d batches, %d entries, want none", batches, entries)
+	}
+}
+
+func TestWALRefsKeepsSharedSegments(t *testing.T) {
+	dir := t.TempDir()
+	l, err := wal.Open(wal.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newWALRefs(l)
+	seqA, _ := w.append([]byte(`{"message":"alpha"}`))
+	seqB, _ := w.append([]byte(`{"message":"beta"}`))
+	if seqA != seqB {
+		t.Fatalf("records in segments %d and %d, want one", seqA, seqB)
+	}
+	segment := filepath.Join(dir, fmt.Sprintf("%020d.wal", seqA))
+
+	w.release(map[uint64]int{seqA: 1})
+	if _, err := os.Stat(segment); err != nil {
+		t.Fatalf("segment removed while it still holds an unstored entry: %v", err)
+	}
+	w.release(map[uint64]int{seqB: 1})
+	if _, err := os.Stat(segment); !os.IsNotExist(err) {
+		t.Fatalf("segment kept after all its entries were stored (err=%v)", err)
+	}
+	w.Close()
+}
+
+func TestBatcherTracesFlushes(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	store := &flakyStore{}
+	b := NewBatcher(BatcherConfig{}, nil, "default", nil)
+	b.setStore("", store)
+	ctx, ingest := tp.Tracer("test").Start(context.Background(), "ingest")
+	b.InsertContext(ctx, []byte(`{"service":"api","message":"traced"}`))
+	ingest.End()
+	b.Stop()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	flush, put := spans["batcher.flush"], spans["o3.PutObject"]
+	if flush == nil || put == nil {
+		t.Fatalf("spans = %v, want batcher.flush and o3.PutObject", slices.Collect(maps.Keys(spans)))
+	}
+	if put.Parent().SpanID() != flush.SpanContext().SpanID() {
+		t.Error("o3.PutObject is not a child of batcher.flush")
+	}
+	if links := flush.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != ingest.SpanContext().SpanID() {
+		t.Errorf("flush links = %+v, want the ingest span", links)
+	}
+}
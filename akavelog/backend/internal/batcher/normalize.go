@@ -0,0 +1,212 @@
+package batcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Levels is the canonical level set every stored entry uses.
+var Levels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// levelAliases maps common spellings (syslog, log4j, zap, ECS, ...) onto Levels.
+var levelAliases = map[string]string{
+	"trace":         "trace",
+	"finest":        "trace",
+	"verbose":       "trace",
+	"debug":         "debug",
+	"dbg":           "debug",
+	"fine":          "debug",
+	"info":          "info",
+	"information":   "info",
+	"informational": "info",
+	"notice":        "info",
+	"warn":          "warn",
+	"warning":       "warn",
+	"wrn":           "warn",
+	"error":         "error",
+	"err":           "error",
+	"severe":        "error",
+	"fatal":         "fatal",
+	"crit":          "fatal",
+	"critical":      "fatal",
+	"alert":         "fatal",
+	"emerg":         "fatal",
+	"emergency":     "fatal",
+	"panic":         "fatal",
+	"dpanic":        "fatal",
+}
+
+// syslogSeverities maps numeric syslog severities (0 emerg … 7 debug) onto Levels.
+var syslogSeverities = []string{"fatal", "fatal", "fatal", "error", "warn", "info", "info", "debug"}
+
+// MaxIDLen is the longest _id an entry may carry.
+const MaxIDLen = 128
+
+// Tags added when a field could not be normalized; they hold the original value.
+const (
+	TagInvalidTimestamp = "invalid_timestamp"
+	TagInvalidLevel     = "invalid_level"
+)
+
+// timestampLayouts are tried in order for string timestamps that are not epoch numbers.
+// Layouts without a zone are read as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999-0700",
+	"02/Jan/2006:15:04:05 -0700", // common/combined log format
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+	"2006-01-02",
+}
+
+// rawEntry mirrors model.LogEntry but keeps timestamp and level undecoded, so numeric
+// epochs and syslog severities are accepted alongside strings.
+type rawEntry struct {
+	ID         string                `json:"_id"`
+	Timestamp  json.RawMessage       `json:"timestamp"`
+	Service    string                `json:"service"`
+	Level      json.RawMessage       `json:"level"`
+	Message    string                `json:"message"`
+	Tags       map[string]string     `json:"tags"`
+	ProjectID  string                `json:"project_id"`
+	RawRequest *model.RawRequestData `json:"raw_request"`
+}
+
+// Normalize validates required fields and the length of _id, and rewrites timestamp and level into canonical form:
+// timestamps become RFC3339 UTC (now when missing), levels one of Levels ("info" when missing).
+// Values that cannot be normalized are replaced by those defaults and kept in the
+// invalid_timestamp / invalid_level tags, so the entry is stored rather than dropped.
+func Normalize(e *model.LogEntry, now time.Time) error {
+	e.ID = strings.TrimSpace(e.ID)
+	if len(e.ID) > MaxIDLen {
+		return fmt.Errorf("invalid _id: longer than %d bytes", MaxIDLen)
+	}
+	e.Service = strings.TrimSpace(e.Service)
+	if e.Service == "" {
+		return fmt.Errorf("missing required field: service")
+	}
+	if strings.TrimSpace(e.Message) == "" {
+		return fmt.Errorf("missing required field: message")
+	}
+	if e.Tags == nil {
+		e.Tags = make(map[string]string)
+	}
+	if e.Timestamp == "" {
+		e.Timestamp = now.UTC().Format(time.RFC3339Nano)
+	} else if t, ok := ParseTimestamp(e.Timestamp); ok {
+		e.Timestamp = t.Format(time.RFC3339Nano)
+	} else {
+		e.Tags[TagInvalidTimestamp] = e.Timestamp
+		e.Timestamp = now.UTC().Format(time.RFC3339Nano)
+	}
+	if e.Level == "" {
+		e.Level = "info"
+	} else if l, ok := NormalizeLevel(e.Level); ok {
+		e.Level = l
+	} else {
+		e.Tags[TagInvalidLevel] = e.Level
+		e.Level = "info"
+	}
+	return nil
+}
+
+// NormalizeLevel maps a level name or numeric syslog severity onto Levels.
+func NormalizeLevel(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if l, ok := levelAliases[s]; ok {
+		return l, true
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(syslogSeverities) {
+		return syslogSeverities[n], true
+	}
+	return "", false
+}
+
+// ParseTimestamp parses ISO8601/RFC3339 and the other timestampLayouts, or a Unix epoch in
+// seconds, milliseconds, microseconds or nanoseconds (picked by magnitude, fractions allowed).
+// The result is in UTC.
+func ParseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, ok := parseEpoch(s); ok {
+		return t, true
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseEpoch(s string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch abs := absInt(n); {
+		case abs < 1e11:
+			return time.Unix(n, 0).UTC(), true
+		case abs < 1e14:
+			return time.UnixMilli(n).UTC(), true
+		case abs < 1e17:
+			return time.UnixMicro(n).UTC(), true
+		default:
+			return time.Unix(0, n).UTC(), true
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	// Fractional epochs are seconds (e.g. 1700000000.123) unless clearly in milliseconds.
+	if math.Abs(f) >= 1e11 {
+		f /= 1e3
+	}
+	if math.Abs(f) > math.MaxInt64/1e9 {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+}
+
+func absInt(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// rawString returns a JSON string's value or a JSON number's literal text.
+func rawString(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", fmt.Errorf("want string or number, got %s", raw)
+	}
+	return n.String(), nil
+}
@@ -0,0 +1,145 @@
+package batcher
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/akave-ai/akavelog/internal/model"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// validProjectID matches project IDs that are safe as an O3 key segment.
+var validProjectID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// partitionKey identifies a partition. Entries of the same project, key prefix and storage
+// target are batched together and end up in the same objects.
+type partitionKey struct {
+	project string
+	prefix  string // "" for logs/
+	target  string // "" for the store the batcher was created with
+}
+
+// partition is the open batch of one partitionKey.
+type partition struct {
+	logs  []model.LogEntry
+	bytes int            // uncompressed JSON size of logs
+	since time.Time      // when the first of logs was added
+	segs  map[uint64]int // WAL segment → entries of logs in it
+	links []trace.Link   // spans logs were inserted under, at most maxFlushLinks
+}
+
+// maxFlushLinks caps the spans of inserted entries a flush span links to.
+const maxFlushLinks = 128
+
+func (p *partition) add(e model.LogEntry, size int) {
+	if len(p.logs) == 0 {
+		p.since = time.Now()
+	}
+	p.logs = append(p.logs, e)
+	p.bytes += size + 1 // and the separating comma
+}
+
+// link records that an entry of p was inserted under sc, for the flush span.
+func (p *partition) link(sc trace.SpanContext) {
+	if sc.IsValid() && len(p.links) < maxFlushLinks {
+		p.links = append(p.links, trace.Link{SpanContext: sc})
+	}
+}
+
+// Reasons a partition is sealed, as counted by metrics.BatcherFlushes.
+const (
+	flushSize     = "size"     // it reached MaxBatchSize or MaxBatchBytes
+	flushInterval = "interval" // its oldest entry waited FlushInterval
+	flushStop     = "stop"     // the batcher stopped
+	flushManual   = "manual"   // Flush was called, e.g. by POST /admin/flush
+)
+
+// job is a sealed batch waiting for a worker.
+type job struct {
+	key    partitionKey
+	logs   []model.LogEntry
+	segs   map[uint64]int
+	links  []trace.Link
+	reason string // one of the flush* reasons
+}
+
+// PartitionStats describes one open partition for /logs/status.
+type PartitionStats struct {
+	Project  string    `json:"project"`
+	Prefix   string    `json:"prefix"`
+	Target   string    `json:"target,omitempty"`
+	Pending  int       `json:"pending_count"`
+	Bytes    int       `json:"pending_bytes"`
+	OldestAt time.Time `json:"oldest_at"`
+}
+
+// partitionOf returns the partition of e: its project_id (the batcher's project when empty
+// or not usable in a key), its key prefix and its storage target.
+func (b *Batcher) partitionOf(e *model.LogEntry) partitionKey {
+	key := partitionKey{project: b.project}
+	if validProjectID.MatchString(e.ProjectID) {
+		key.project = e.ProjectID
+	}
+	if b.opts != nil && b.opts.KeyPrefix != nil {
+		key.prefix = b.opts.KeyPrefix(e)
+	}
+	if b.opts != nil && b.opts.Target != nil {
+		key.target = b.opts.Target(e)
+		if _, ok := b.targets[key.target]; !ok && key.target != "" && b.targets != nil {
+			b.unknownTarget(key.target)
+			key.target = ""
+		}
+	}
+	return key
+}
+
+// unknownTarget logs, once per name, that entries name a storage target that is not
+// configured and are stored with the default store instead.
+func (b *Batcher) unknownTarget(name string) {
+	if _, warned := b.warned.LoadOrStore(name, true); !warned {
+		log.Printf("[batcher] storage target %q is not configured: storing its entries in the default bucket", name)
+	}
+}
+
+// partition returns the open partition for key, creating it. b.mu must be held.
+func (b *Batcher) partition(key partitionKey) *partition {
+	p, ok := b.partitions[key]
+	if !ok {
+		p = &partition{segs: make(map[uint64]int)}
+		b.partitions[key] = p
+	}
+	return p
+}
+
+// seal removes the partition for key and returns its batch as a job, counting the flush for
+// reason. b.mu must be held.
+func (b *Batcher) seal(key partitionKey, reason string) *job {
+	p := b.partitions[key]
+	delete(b.partitions, key)
+	metrics.BatcherFlushes.WithLabelValues(reason).Inc()
+	metrics.BatcherFlushEntries.Observe(float64(len(p.logs)))
+	return &job{key: key, logs: p.logs, segs: p.segs, links: p.links, reason: reason}
+}
+
+// Partitions reports the open partitions, by project, prefix and target.
+func (b *Batcher) Partitions() []PartitionStats {
+	b.mu.Lock()
+	out := make([]PartitionStats, 0, len(b.partitions))
+	for key, p := range b.partitions {
+		out = append(out, PartitionStats{Project: key.project, Prefix: key.prefix, Target: key.target, Pending: len(p.logs), Bytes: p.bytes, OldestAt: p.since})
+	}
+	b.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Project != out[j].Project {
+			return out[i].Project < out[j].Project
+		}
+		if out[i].Prefix != out[j].Prefix {
+			return out[i].Prefix < out[j].Prefix
+		}
+		return out[i].Target < out[j].Target
+	})
+	return out
+}
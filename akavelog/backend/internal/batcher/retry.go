@@ -0,0 +1,466 @@
+package batcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	retryMinBackoff = time.Second
+	retryMaxBackoff = 5 * time.Minute
+
+	retryPutTimeout = time.Minute
+	spillExt        = ".spill"
+)
+
+// putter is the part of storage.O3Client the batcher uploads with.
+type putter interface {
+	// PutObjectCID uploads an object and returns its root CID on a content-addressed backend.
+	PutObjectCID(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) (string, error)
+}
+
+// meteredPutter counts uploads, their bytes, errors and durations into the O3 metrics, and
+// traces each upload.
+type meteredPutter struct {
+	putter
+}
+
+func (p meteredPutter) PutObjectCID(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) (string, error) {
+	ctx, span := tracing.Start(ctx, "o3.PutObject", attribute.String("key", key), attribute.Int("bytes", len(data)))
+	defer span.End()
+	start := time.Now()
+	cid, err := p.putter.PutObjectCID(ctx, key, data, contentType, metadata)
+	metrics.O3UploadDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.O3UploadErrors.Inc()
+		tracing.Fail(span, err)
+		return "", err
+	}
+	metrics.O3Uploads.Inc()
+	metrics.O3UploadBytes.Add(float64(len(data)))
+	return cid, nil
+}
+
+// RetryStats is the state of the upload retry queue, shown by /logs/status.
+type RetryStats struct {
+	Depth       int       `json:"depth"`   // objects waiting
+	Entries     int       `json:"entries"` // log entries in those objects
+	Bytes       int64     `json:"bytes"`
+	Spilled     int       `json:"spilled"`         // objects of Depth kept in the spill directory
+	Dropped     int64     `json:"dropped_objects"` // dropped because the queue was over MaxRetryBytes
+	Attempts    int       `json:"attempts"`        // consecutive failed attempts
+	LastError   string    `json:"last_error"`
+	LastErrorAt time.Time `json:"last_error_at"`
+	NextRetryAt time.Time `json:"next_retry_at"`
+	// Targets holds the queues of the named storage targets, which the totals include.
+	Targets map[string]RetryStats `json:"targets,omitempty"`
+}
+
+// merge adds the queue o to the totals of st: the most attempts, the latest error and the
+// earliest retry.
+func (st *RetryStats) merge(o RetryStats) {
+	st.Depth += o.Depth
+	st.Entries += o.Entries
+	st.Bytes += o.Bytes
+	st.Spilled += o.Spilled
+	st.Dropped += o.Dropped
+	st.Attempts = max(st.Attempts, o.Attempts)
+	if o.LastErrorAt.After(st.LastErrorAt) {
+		st.LastError, st.LastErrorAt = o.LastError, o.LastErrorAt
+	}
+	if !o.NextRetryAt.IsZero() && (st.NextRetryAt.IsZero() || o.NextRetryAt.Before(st.NextRetryAt)) {
+		st.NextRetryAt = o.NextRetryAt
+	}
+}
+
+// retryGroup is the objects of one flush; done runs once all of them are stored.
+type retryGroup struct {
+	remaining int
+	done      func()
+}
+
+// retryItem is an object that failed to upload.
+type retryItem struct {
+	key   string
+	count int
+	size  int64
+	data  []byte // nil when spilled
+	path  string // spill file; "" when kept in memory
+	group *retryGroup
+}
+
+// retryQueue holds objects whose upload failed and puts them again, in order, with
+// exponential backoff and jitter. With a spill directory, objects are written to disk, so
+// they survive a restart and the WAL no longer has to keep their entries.
+type retryQueue struct {
+	store    putter
+	dir      string // "" keeps objects in memory
+	maxBytes int64
+	onUpload func(model.Batch)
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu          sync.Mutex
+	items       []*retryItem
+	bytes       int64
+	entries     int
+	dropped     int64
+	attempts    int
+	retryAt     time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// newRetryQueue creates the queue, loads the objects spilled by a previous run and starts
+// the retry loop. If dir cannot be created, objects are kept in memory.
+func newRetryQueue(store putter, dir string, maxBytes int64, onUpload func(model.Batch)) *retryQueue {
+	q := &retryQueue{store: store, dir: dir, maxBytes: maxBytes, onUpload: onUpload,
+		stop: make(chan struct{}), done: make(chan struct{})}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("[batcher] spill dir: %v (retrying from memory)", err)
+			q.dir = ""
+		} else {
+			q.load()
+		}
+	}
+	go q.loop()
+	return q
+}
+
+// pending reports whether objects are waiting, in which case new ones queue behind them.
+func (q *retryQueue) pending() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items) > 0
+}
+
+// add queues the objects of one flush and returns once they are spilled. done is called when
+// they no longer need the WAL: right away if all of them were written to the spill directory,
+// otherwise once the last one is uploaded. An object dropped for space never calls done.
+func (q *retryQueue) add(objects []object, done func()) {
+	g := &retryGroup{done: done}
+	items := make([]*retryItem, 0, len(objects))
+	for _, obj := range objects {
+		it := &retryItem{key: obj.key, count: obj.count, size: int64(len(obj.data)), data: obj.data}
+		if q.dir != "" {
+			if path, err := q.spill(obj); err != nil {
+				log.Printf("[batcher] spill %s: %v (keeping it in memory)", obj.key, err)
+			} else {
+				it.path, it.data = path, nil
+			}
+		}
+		if it.path == "" {
+			it.group = g
+			g.remaining++
+		}
+		items = append(items, it)
+	}
+	q.mu.Lock()
+	for _, it := range items {
+		q.items = append(q.items, it)
+		q.bytes += it.size
+		q.entries += it.count
+	}
+	for q.bytes > q.maxBytes && len(q.items) > 1 {
+		q.drop()
+	}
+	q.mu.Unlock()
+	if g.remaining == 0 && done != nil {
+		done()
+	}
+}
+
+// setMaxBytes changes the bytes the queue holds at most, dropping the oldest objects beyond it.
+func (q *retryQueue) setMaxBytes(n int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxBytes = n
+	for q.bytes > q.maxBytes && len(q.items) > 1 {
+		q.drop()
+	}
+}
+
+// drop discards the oldest object. q.mu must be held.
+func (q *retryQueue) drop() {
+	it := q.items[0]
+	q.items = q.items[1:]
+	q.bytes -= it.size
+	q.entries -= it.count
+	q.dropped++
+	if it.path != "" {
+		_ = os.Remove(it.path)
+	}
+	log.Printf("[batcher] retry queue over %d bytes: dropped %s (%d logs)", q.maxBytes, it.key, it.count)
+}
+
+func (q *retryQueue) loop() {
+	defer close(q.done)
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-t.C:
+		}
+		q.mu.Lock()
+		wait := time.Now().Before(q.retryAt)
+		q.mu.Unlock()
+		if !wait {
+			q.retry(context.Background())
+		}
+	}
+}
+
+// retry puts the queued objects in order and stops at the first failure, which schedules the
+// next attempt.
+func (q *retryQueue) retry(ctx context.Context) {
+	for {
+		q.mu.Lock()
+		if len(q.items) == 0 {
+			q.attempts, q.retryAt = 0, time.Time{}
+			q.mu.Unlock()
+			return
+		}
+		it := q.items[0]
+		q.mu.Unlock()
+
+		data := it.data
+		var (
+			cid string
+			err error
+		)
+		if data == nil {
+			data, err = os.ReadFile(it.path)
+			if errors.Is(err, os.ErrNotExist) {
+				log.Printf("[batcher] spilled %s is gone: dropping %s", it.path, it.key)
+				q.mu.Lock()
+				q.remove(it)
+				q.dropped++
+				q.mu.Unlock()
+				continue
+			}
+		}
+		if err == nil {
+			putCtx, cancel := context.WithTimeout(ctx, retryPutTimeout)
+			contentType, meta := objectMeta(it.key, it.count)
+			cid, err = q.store.PutObjectCID(putCtx, it.key, data, contentType, meta)
+			cancel()
+		}
+
+		q.mu.Lock()
+		if err != nil {
+			q.failedLocked(err)
+			attempts := q.attempts
+			q.mu.Unlock()
+			log.Printf("[batcher] retry %s (attempt %d): %v", it.key, attempts, err)
+			return
+		}
+		q.remove(it)
+		q.attempts = 0
+		q.mu.Unlock()
+
+		if it.path != "" {
+			if err := os.Remove(it.path); err != nil {
+				log.Printf("[batcher] remove spilled %s: %v", it.path, err)
+			}
+		}
+		log.Printf("[batcher] uploaded %d logs to %s after retrying", it.count, it.key)
+		if q.onUpload != nil {
+			// Entries are not kept while an object waits; read them back for its record.
+			entries, err := storage.DecodeLogs(data)
+			if err != nil {
+				log.Printf("[batcher] decode %s: %v", it.key, err)
+			}
+			batch := storage.DescribeBatch(it.key, data, entries)
+			batch.Count, batch.CID = it.count, cid
+			q.onUpload(batch)
+		}
+		if g := it.group; g != nil {
+			g.remaining--
+			if g.remaining == 0 && g.done != nil {
+				g.done()
+			}
+		}
+	}
+}
+
+// remove takes it off the head of the queue unless it was dropped for space meanwhile.
+// q.mu must be held.
+func (q *retryQueue) remove(it *retryItem) {
+	if len(q.items) > 0 && q.items[0] == it {
+		q.items = q.items[1:]
+		q.bytes -= it.size
+		q.entries -= it.count
+	}
+}
+
+// failed records a failed upload and schedules the next attempt.
+func (q *retryQueue) failed(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failedLocked(err)
+}
+
+// failedLocked is failed with q.mu held.
+func (q *retryQueue) failedLocked(err error) {
+	q.attempts++
+	q.lastError, q.lastErrorAt = err.Error(), time.Now()
+	q.retryAt = time.Now().Add(backoff(q.attempts))
+}
+
+// backoff doubles from retryMinBackoff up to retryMaxBackoff and waits a random 50-100% of it,
+// so many instances recovering from the same outage do not retry in step.
+func backoff(attempts int) time.Duration {
+	d := retryMaxBackoff
+	if attempts < 20 {
+		d = min(retryMinBackoff<<(attempts-1), retryMaxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// Stats reports the queue.
+func (q *retryQueue) Stats() RetryStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := RetryStats{
+		Depth:       len(q.items),
+		Entries:     q.entries,
+		Bytes:       q.bytes,
+		Dropped:     q.dropped,
+		Attempts:    q.attempts,
+		LastError:   q.lastError,
+		LastErrorAt: q.lastErrorAt,
+		NextRetryAt: q.retryAt,
+	}
+	for _, it := range q.items {
+		if it.path != "" {
+			st.Spilled++
+		}
+	}
+	return st
+}
+
+// Stop ends the retry loop after one last attempt. Spilled objects are retried on the next
+// start; objects kept in memory are lost unless the WAL still holds their entries.
+func (q *retryQueue) Stop() {
+	close(q.stop)
+	<-q.done
+	q.retry(context.Background())
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := len(q.items); n > 0 {
+		log.Printf("[batcher] %d objects left in the retry queue (%d spilled)", n, n-q.memoryItems())
+	}
+}
+
+// memoryItems counts the queued objects that are not spilled. q.mu must be held.
+func (q *retryQueue) memoryItems() int {
+	n := 0
+	for _, it := range q.items {
+		if it.path == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// spill writes obj to the spill directory as <unix nanos>-<count>-<escaped key>.spill. The file
+// is renamed into place after an fsync, so a crash never leaves a partial object behind.
+func (q *retryQueue) spill(obj object) (string, error) {
+	name := fmt.Sprintf("%019d-%d-%s%s", time.Now().UnixNano(), obj.count, url.PathEscape(obj.key), spillExt)
+	path := filepath.Join(q.dir, name)
+	f, err := os.CreateTemp(q.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	_, err = f.Write(obj.data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// load queues the objects a previous run left in the spill directory, oldest first, and
+// removes leftover temporary files.
+func (q *retryQueue) load() {
+	dirents, err := os.ReadDir(q.dir)
+	if err != nil {
+		log.Printf("[batcher] spill dir: %v", err)
+		return
+	}
+	var names []string
+	for _, d := range dirents {
+		switch name := d.Name(); {
+		case strings.HasPrefix(name, ".tmp-"):
+			_ = os.Remove(filepath.Join(q.dir, name))
+		case strings.HasSuffix(name, spillExt):
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		it, err := parseSpillName(name)
+		if err != nil {
+			log.Printf("[batcher] spill dir: skipping %s: %v", name, err)
+			continue
+		}
+		it.path = filepath.Join(q.dir, name)
+		fi, err := os.Stat(it.path)
+		if err != nil {
+			continue
+		}
+		it.size = fi.Size()
+		q.items = append(q.items, it)
+		q.bytes += it.size
+		q.entries += it.count
+	}
+	if len(q.items) > 0 {
+		log.Printf("[batcher] %d spilled objects (%d logs) to retry", len(q.items), q.entries)
+	}
+}
+
+func parseSpillName(name string) (*retryItem, error) {
+	parts := strings.SplitN(strings.TrimSuffix(name, spillExt), "-", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("unexpected name")
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("count: %w", err)
+	}
+	key, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	return &retryItem{key: key, count: count}, nil
+}
@@ -0,0 +1,98 @@
+package batcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+)
+
+// flakyStore fails every put while fail is set, and returns "cid:" and the key as the CID of
+// the others.
+type flakyStore struct {
+	mu   sync.Mutex
+	fail bool
+	keys []string
+}
+
+func (s *flakyStore) PutObjectCID(_ context.Context, key string, _ []byte, _ string, _ map[string]string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return "", errors.New("o3 unavailable")
+	}
+	s.keys = append(s.keys, key)
+	return "cid:" + key, nil
+}
+
+func (s *flakyStore) setFail(fail bool) {
+	s.mu.Lock()
+	s.fail = fail
+	s.mu.Unlock()
+}
+
+func TestBatcherSpillsFailedUploads(t *testing.T) {
+	dir := t.TempDir()
+	store := &flakyStore{fail: true}
+	b := NewBatcher(BatcherConfig{SpillDir: dir}, nil, "default", nil)
+	b.setStore("", store)
+	b.Insert([]byte(`{"service":"api","message":"during the outage"}`))
+	b.Flush(context.Background())
+
+	st := b.RetryStats()
+	if st.Depth != 1 || st.Spilled != 1 || st.Entries != 1 || st.LastError == "" {
+		t.Fatalf("retry stats = %+v, want one spilled object and the error", st)
+	}
+	b.Stop() // the last attempt fails too; the object stays on disk
+
+	// The next run picks the object up and uploads it once O3 is back.
+	store.setFail(false)
+	q := newRetryQueue(store, dir, 1<<30, nil)
+	if st := q.Stats(); st.Depth != 1 || st.Entries != 1 {
+		t.Fatalf("reloaded stats = %+v, want the spilled object", st)
+	}
+	q.Stop()
+	if len(store.keys) != 1 {
+		t.Fatalf("uploaded %v, want the spilled object", store.keys)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d files left in the spill dir", len(files))
+	}
+}
+
+func TestRetryQueueCallsDoneAfterUpload(t *testing.T) {
+	store := &flakyStore{fail: true}
+	q := newRetryQueue(store, "", 1<<30, nil)
+	done := false
+	q.add([]object{{key: "logs/a.json.gz", data: []byte("a"), count: 1}, {key: "logs/b.json.gz", data: []byte("b"), count: 2}},
+		func() { done = true })
+	if done {
+		t.Fatal("done called before the objects were uploaded")
+	}
+	q.retry(context.Background())
+	if done || q.Stats().Attempts != 1 {
+		t.Fatalf("after a failed retry: done=%v stats=%+v", done, q.Stats())
+	}
+	store.setFail(false)
+	q.Stop()
+	if !done || len(store.keys) != 2 || store.keys[0] != "logs/a.json.gz" {
+		t.Fatalf("done=%v uploaded=%v, want both objects in order", done, store.keys)
+	}
+}
+
+func TestRetryQueueDropsOldestOverLimit(t *testing.T) {
+	store := &flakyStore{fail: true}
+	q := newRetryQueue(store, "", 10, nil)
+	q.add([]object{{key: "old", data: make([]byte, 8), count: 1}}, nil)
+	q.add([]object{{key: "new", data: make([]byte, 8), count: 1}}, nil)
+	st := q.Stats()
+	if st.Depth != 1 || st.Dropped != 1 {
+		t.Fatalf("stats = %+v, want the oldest object dropped", st)
+	}
+	store.setFail(false)
+	q.Stop()
+	if len(store.keys) != 1 || store.keys[0] != "new" {
+		t.Fatalf("uploaded %v, want only the new object", store.keys)
+	}
+}
@@ -2,32 +2,44 @@ package batcher
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/akave-ai/akavelog/internal/model"
 )
 
+// ErrInvalidJSON is returned (wrapped) by ValidateLog when a payload is not a JSON object.
+var ErrInvalidJSON = errors.New("invalid json")
+
 // ValidateLog parses raw JSON and validates it as a log entry.
-// Required: service, message. Level and timestamp default if missing.
+// Required: service, message. Timestamp and level are normalized (see Normalize);
+// timestamp may be a string or a numeric Unix epoch, level a name or syslog severity.
 func ValidateLog(raw []byte) (*model.LogEntry, error) {
-	var e model.LogEntry
-	if err := json.Unmarshal(raw, &e); err != nil {
-		return nil, fmt.Errorf("invalid json: %w", err)
-	}
-	if e.Service == "" {
-		return nil, fmt.Errorf("missing required field: service")
+	var r rawEntry
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
 	}
-	if e.Message == "" {
-		return nil, fmt.Errorf("missing required field: message")
+	ts, err := rawString(r.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
 	}
-	if e.Level == "" {
-		e.Level = "info"
+	level, err := rawString(r.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid level: %w", err)
 	}
-	if e.Timestamp == "" {
-		e.Timestamp = "0" // or set to now in caller if needed
+	e := model.LogEntry{
+		ID:         r.ID,
+		Timestamp:  ts,
+		Service:    r.Service,
+		Level:      level,
+		Message:    r.Message,
+		Tags:       r.Tags,
+		ProjectID:  r.ProjectID,
+		RawRequest: r.RawRequest,
 	}
-	if e.Tags == nil {
-		e.Tags = make(map[string]string)
+	if err := Normalize(&e, time.Now()); err != nil {
+		return nil, err
 	}
 	return &e, nil
 }
@@ -0,0 +1,87 @@
+package batcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	cases := []string{
+		"2024-03-01T12:30:45Z",
+		"2024-03-01T14:30:45+02:00",
+		"2024-03-01T12:30:45",
+		"2024-03-01 12:30:45",
+		"01/Mar/2024:12:30:45 +0000",
+		"Fri, 01 Mar 2024 12:30:45 GMT",
+		"1709296245",
+		"1709296245000",
+		"1709296245000000",
+		"1709296245000000000",
+		"1709296245.0",
+	}
+	for _, in := range cases {
+		got, ok := ParseTimestamp(in)
+		if !ok || !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	if got, ok := ParseTimestamp("1709296245.5"); !ok || got.Sub(want) != 500*time.Millisecond {
+		t.Errorf("fractional epoch = %v, %v", got, ok)
+	}
+	for _, in := range []string{"", "yesterday", "2024-13-45T00:00:00Z"} {
+		if _, ok := ParseTimestamp(in); ok {
+			t.Errorf("ParseTimestamp(%q) accepted", in)
+		}
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	cases := map[string]string{
+		"INFO": "info", "Warning": "warn", "err": "error", "CRITICAL": "fatal",
+		"notice": "info", "trace": "trace", "3": "error", "7": "debug",
+	}
+	for in, want := range cases {
+		if got, ok := NormalizeLevel(in); !ok || got != want {
+			t.Errorf("NormalizeLevel(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := NormalizeLevel("loud"); ok {
+		t.Error("unknown level accepted")
+	}
+}
+
+func TestValidateLog(t *testing.T) {
+	e, err := ValidateLog([]byte(`{"service":" api ","message":"hi","level":"WARNING","timestamp":1709296245123}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Service != "api" || e.Level != "warn" || e.Timestamp != "2024-03-01T12:30:45.123Z" {
+		t.Errorf("entry = %+v", e)
+	}
+	if len(e.Tags) != 0 {
+		t.Errorf("unexpected tags %v", e.Tags)
+	}
+
+	e, err = ValidateLog([]byte(`{"service":"api","message":"hi","level":"loud","timestamp":"soon"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Level != "info" || e.Tags[TagInvalidLevel] != "loud" || e.Tags[TagInvalidTimestamp] != "soon" {
+		t.Errorf("malformed fields not tagged: %+v", e)
+	}
+	if _, ok := ParseTimestamp(e.Timestamp); !ok {
+		t.Errorf("timestamp %q not replaced", e.Timestamp)
+	}
+
+	for _, raw := range []string{
+		`not json`,
+		`{"message":"hi"}`,
+		`{"service":"api","message":"   "}`,
+		`{"service":"api","message":"hi","timestamp":{"s":1}}`,
+	} {
+		if _, err := ValidateLog([]byte(raw)); err == nil {
+			t.Errorf("ValidateLog(%s) accepted", raw)
+		}
+	}
+}
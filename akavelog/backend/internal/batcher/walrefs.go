@@ -0,0 +1,97 @@
+package batcher
+
+import (
+	"log"
+	"sync"
+
+	"github.com/akave-ai/akavelog/internal/wal"
+)
+
+// replaySeg counts the entries replayed on start. They come from the segments found on Open,
+// which are removed together once all of them are stored.
+const replaySeg = 0
+
+// walRefs removes WAL segments once no batch holds entries from them. Partitions flush
+// independently, so a segment written by several partitions can only go when each of them
+// has stored its entries.
+type walRefs struct {
+	log *wal.Log
+
+	mu     sync.Mutex
+	refs   map[uint64]int // segment → entries not yet stored
+	closed []uint64       // closed segments not yet removed
+	replay []uint64       // segments found on Open
+}
+
+// newWALRefs takes over l. Its segments from a previous run are kept until the replayed
+// entries are released under replaySeg.
+func newWALRefs(l *wal.Log) *walRefs {
+	w := &walRefs{log: l, refs: make(map[uint64]int)}
+	segs, err := l.Checkpoint()
+	if err != nil {
+		log.Printf("[batcher] %v", err)
+	}
+	w.replay = segs
+	return w
+}
+
+// append records raw and counts it against its segment. It reports false if the write
+// failed, in which case the entry is kept in memory only.
+func (w *walRefs) append(raw []byte) (uint64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seq, err := w.log.AppendSegment(raw)
+	if err != nil {
+		log.Printf("[batcher] %v", err)
+		return 0, false
+	}
+	w.refs[seq]++
+	return seq, true
+}
+
+// hold counts n entries against seg without writing them, for replayed entries.
+func (w *walRefs) hold(seg uint64, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.refs[seg] += n
+}
+
+// release marks entries as stored (segs maps segment → entries) and removes every closed
+// segment left without entries. The current segment is closed first, so it can go too.
+func (w *walRefs) release(segs map[uint64]int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for seq, n := range segs {
+		if w.refs[seq] -= n; w.refs[seq] <= 0 {
+			delete(w.refs, seq)
+		}
+	}
+	closed, err := w.log.Checkpoint()
+	if err != nil {
+		log.Printf("[batcher] %v", err)
+	}
+	w.closed = append(w.closed, closed...)
+
+	var remove, keep []uint64
+	for _, seq := range w.closed {
+		if w.refs[seq] == 0 {
+			remove = append(remove, seq)
+		} else {
+			keep = append(keep, seq)
+		}
+	}
+	w.closed = keep
+	if len(w.replay) > 0 && w.refs[replaySeg] == 0 {
+		remove = append(remove, w.replay...)
+		w.replay = nil
+	}
+	w.log.Remove(remove)
+}
+
+// Close removes the segments whose entries are all stored and closes the log.
+func (w *walRefs) Close() {
+	w.release(nil)
+	if err := w.log.Close(); err != nil {
+		log.Printf("[batcher] close wal: %v", err)
+	}
+}
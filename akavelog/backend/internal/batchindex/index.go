@@ -0,0 +1,128 @@
+// Package batchindex keeps the batches table in step with the objects in O3: the batcher
+// records every upload, compaction and retention remove what they delete, and Backfill
+// indexes objects uploaded before the index existed.
+package batchindex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/compaction"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// writeTimeout bounds each index write, so a slow database never holds up an upload worker.
+const writeTimeout = 10 * time.Second
+
+// Repo is the part of repository.BatchRepository the index uses.
+type Repo interface {
+	Upsert(ctx context.Context, b *model.Batch) error
+	Keys(ctx context.Context, prefix string) (map[string]bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	Replace(ctx context.Context, objects []model.Batch, sources []string) error
+}
+
+// Index writes to the batches table. Failed writes are logged; Backfill repairs them.
+type Index struct {
+	Repo Repo
+}
+
+// Record indexes an uploaded object. It is the batcher's OnFlush.
+func (x *Index) Record(b model.Batch) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := x.Repo.Upsert(ctx, &b); err != nil {
+		log.Printf("[batchindex] record %s: %v", b.Key, err)
+	}
+}
+
+// Remove drops deleted objects from the index.
+func (x *Index) Remove(keys ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := x.Repo.Delete(ctx, keys...); err != nil {
+		log.Printf("[batchindex] remove %d keys: %v", len(keys), err)
+	}
+}
+
+// Compacted indexes the objects of a compaction and drops its sources, in one transaction.
+func (x *Index) Compacted(c compaction.Compaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := x.Repo.Replace(ctx, c.Objects, c.Sources); err != nil {
+		log.Printf("[batchindex] compacted %d objects into %d: %v", len(c.Sources), len(c.Objects), err)
+	}
+}
+
+// Store is the part of storage.O3Client Backfill uses.
+type Store interface {
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+	GetObject(ctx context.Context, key string) ([]byte, error)
+}
+
+// BackfillStats describes one backfill.
+type BackfillStats struct {
+	Scanned int `json:"scanned"`
+	Indexed int `json:"indexed"`
+	Skipped int `json:"skipped"` // already indexed, or not a batch key
+	Errors  int `json:"errors"`
+}
+
+// Backfill indexes the batch objects under prefixes that are not indexed yet, or all of them
+// with force. Each object is downloaded to read its entries' time range; one that cannot be
+// read is counted in Errors and the backfill goes on.
+func Backfill(ctx context.Context, store Store, repo Repo, prefixes []string, force bool) (BackfillStats, error) {
+	var st BackfillStats
+	seen := make(map[string]bool) // a nested prefix lists objects again
+	for _, prefix := range prefixes {
+		objects, err := store.ListObjects(ctx, prefix+"/")
+		if err != nil {
+			return st, fmt.Errorf("list %s/: %w", prefix, err)
+		}
+		indexed := map[string]bool{}
+		if !force {
+			if indexed, err = repo.Keys(ctx, prefix+"/"); err != nil {
+				return st, fmt.Errorf("indexed keys: %w", err)
+			}
+		}
+		for _, obj := range objects {
+			if ctx.Err() != nil {
+				return st, ctx.Err()
+			}
+			if seen[obj.Key] {
+				continue
+			}
+			seen[obj.Key] = true
+			st.Scanned++
+			if _, _, ok := storage.KeyParts(obj.Key); !ok || indexed[obj.Key] {
+				st.Skipped++
+				continue
+			}
+			data, err := store.GetObject(ctx, obj.Key)
+			if err != nil {
+				st.Errors++
+				log.Printf("[batchindex] backfill %s: %v", obj.Key, err)
+				continue
+			}
+			entries, err := storage.DecodeLogs(data)
+			if err != nil {
+				st.Errors++
+				log.Printf("[batchindex] backfill %s: %v", obj.Key, err)
+				continue
+			}
+			b := storage.DescribeBatch(obj.Key, data, entries)
+			b.CID = obj.CID
+			if !obj.LastModified.IsZero() {
+				b.UploadedAt = obj.LastModified
+			}
+			if err := repo.Upsert(ctx, &b); err != nil {
+				return st, fmt.Errorf("index %s: %w", obj.Key, err)
+			}
+			st.Indexed++
+		}
+	}
+	return st, nil
+}
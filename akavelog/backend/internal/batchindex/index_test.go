@@ -0,0 +1,126 @@
+package batchindex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/compaction"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// memRepo is an in-memory batches table.
+type memRepo map[string]model.Batch
+
+func (r memRepo) Upsert(_ context.Context, b *model.Batch) error {
+	r[b.Key] = *b
+	return nil
+}
+
+func (r memRepo) Keys(_ context.Context, prefix string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for k := range r {
+		if strings.HasPrefix(k, prefix) {
+			keys[k] = true
+		}
+	}
+	return keys, nil
+}
+
+func (r memRepo) Delete(_ context.Context, keys ...string) error {
+	for _, k := range keys {
+		delete(r, k)
+	}
+	return nil
+}
+
+func (r memRepo) Replace(ctx context.Context, objects []model.Batch, sources []string) error {
+	for i := range objects {
+		r.Upsert(ctx, &objects[i])
+	}
+	return r.Delete(ctx, sources...)
+}
+
+// memStore is an in-memory bucket.
+type memStore map[string][]byte
+
+func (s memStore) ListObjects(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	var out []storage.ObjectInfo
+	for k, data := range s {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, storage.ObjectInfo{Key: k, Size: int64(len(data))})
+		}
+	}
+	return out, nil
+}
+
+func (s memStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, fmt.Errorf("no object %s", key)
+	}
+	return data, nil
+}
+
+func encode(t *testing.T, timestamps ...string) []byte {
+	t.Helper()
+	var rows [][]byte
+	for _, ts := range timestamps {
+		rows = append(rows, []byte(`{"timestamp":"`+ts+`","service":"api","level":"info","message":"m"}`))
+	}
+	data, err := storage.EncodeRows(storage.CodecGzipJSON, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestBackfill(t *testing.T) {
+	store := memStore{
+		"logs/shop/2024/02/17/a.json.gz":      encode(t, "2024-02-17T10:00:00Z", "2024-02-17T08:30:00Z"),
+		"logs/shop/2024/02/17/b.json.gz":      encode(t, "2024-02-17T11:00:00Z"),
+		"logs/shop/2024/02/17/broken.json.gz": []byte("not a batch"),
+		"logs/stray.txt":                      []byte("x"),
+		"audit/eu/shop/2024/02/18/c.json.gz":  encode(t, "2024-02-18T00:00:00Z"),
+	}
+	repo := memRepo{"logs/shop/2024/02/17/b.json.gz": {Key: "logs/shop/2024/02/17/b.json.gz"}}
+
+	st, err := Backfill(context.Background(), store, repo, []string{"logs", "audit/eu", "audit"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Scanned != 5 || st.Indexed != 2 || st.Skipped != 2 || st.Errors != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	a := repo["logs/shop/2024/02/17/a.json.gz"]
+	want := time.Date(2024, 2, 17, 8, 30, 0, 0, time.UTC)
+	if a.ProjectID != "shop" || a.Prefix != "logs" || a.Count != 2 || a.MinTimestamp == nil || !a.MinTimestamp.Equal(want) || a.SHA256 == "" {
+		t.Fatalf("indexed a = %+v", a)
+	}
+	if c := repo["audit/eu/shop/2024/02/18/c.json.gz"]; c.Prefix != "audit/eu" || c.ProjectID != "shop" {
+		t.Fatalf("indexed c = %+v", c)
+	}
+
+	st, err = Backfill(context.Background(), store, repo, []string{"logs"}, true)
+	if err != nil || st.Indexed != 2 {
+		t.Fatalf("forced backfill = %+v, %v", st, err)
+	}
+	if repo["logs/shop/2024/02/17/b.json.gz"].Count != 1 {
+		t.Fatal("forced backfill kept the stale row")
+	}
+}
+
+func TestCompacted(t *testing.T) {
+	repo := memRepo{"logs/p/2024/01/01/a.json.gz": {}, "logs/p/2024/01/01/b.json.gz": {}}
+	x := &Index{Repo: repo}
+	x.Compacted(compaction.Compaction{
+		Sources: []string{"logs/p/2024/01/01/a.json.gz", "logs/p/2024/01/01/b.json.gz"},
+		Objects: []model.Batch{{Key: "logs/p/2024/01/01/compacted-1.json.gz", Count: 2}},
+	})
+	if len(repo) != 1 || repo["logs/p/2024/01/01/compacted-1.json.gz"].Count != 2 {
+		t.Fatalf("index = %+v", repo)
+	}
+}
@@ -0,0 +1,366 @@
+// Package bench generates synthetic log traffic against an input and measures what it
+// achieves: throughput, latency percentiles and errors. It backs akavelog bench.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Config describes a benchmark. Zero fields take their defaults.
+type Config struct {
+	// Target is where entries are sent: an http(s):// URL of an HTTP input (one POST per
+	// batch, as a JSON array), or tcp://host:port or udp://host:port of a socket input (one
+	// line per entry).
+	Target      string
+	Token       string        // sent as X-Akavelog-Token to HTTP targets
+	EntrySize   int           // approximate bytes of one JSON entry (default 256)
+	Batch       int           // entries per request (default 1; always 1 for udp)
+	Rate        float64       // entries per second over all workers; 0 sends as fast as possible
+	Concurrency int           // workers sending at once (default 4)
+	Duration    time.Duration // how long to send (default 10s)
+	Timeout     time.Duration // a request fails after this long (default 10s)
+	Service     string        // service of the generated entries (default "bench")
+}
+
+func (c *Config) defaults() {
+	if c.EntrySize <= 0 {
+		c.EntrySize = 256
+	}
+	if c.Batch <= 0 {
+		c.Batch = 1
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.Duration <= 0 {
+		c.Duration = 10 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Service == "" {
+		c.Service = "bench"
+	}
+}
+
+// Result is what a benchmark achieved.
+type Result struct {
+	Elapsed  time.Duration  `json:"elapsed"`
+	Requests int            `json:"requests"`
+	Entries  int            `json:"entries"` // entries of the requests that succeeded
+	Bytes    int64          `json:"bytes"`   // payload bytes of the requests that succeeded
+	Errors   int            `json:"errors"`  // requests that failed
+	ByError  map[string]int `json:"by_error,omitempty"`
+	Latency  Latency        `json:"latency"` // of the requests that succeeded
+}
+
+// Latency holds percentiles of request latencies.
+type Latency struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// EntriesPerSecond is the achieved throughput in entries.
+func (r Result) EntriesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Entries) / r.Elapsed.Seconds()
+}
+
+// BytesPerSecond is the achieved throughput in payload bytes.
+func (r Result) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// ErrorRate is the share of requests that failed, from 0 to 1.
+func (r Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// sender delivers one request's payload.
+type sender interface {
+	send(ctx context.Context, entries [][]byte) (int64, error)
+	close()
+}
+
+// statusError is an HTTP answer other than 2xx.
+type statusError int
+
+func (e statusError) Error() string { return "HTTP " + strconv.Itoa(int(e)) }
+
+// Run sends entries to cfg.Target until cfg.Duration has passed or ctx is done, and reports
+// what it achieved. It fails only when cfg is invalid; failed requests are counted.
+func Run(ctx context.Context, cfg Config) (Result, error) {
+	cfg.defaults()
+	newSender, err := senderFor(&cfg)
+	if err != nil {
+		return Result{}, err
+	}
+	var limiter *rate.Limiter
+	if cfg.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.Rate), max(cfg.Batch, int(cfg.Rate/10)))
+	}
+	began := time.Now()
+	end := began.Add(cfg.Duration)
+	ctx, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+
+	type tally struct {
+		Result
+		latencies []time.Duration
+	}
+	tallies := make([]tally, cfg.Concurrency)
+	var wg sync.WaitGroup
+	for w := range tallies {
+		wg.Add(1)
+		go func(t *tally) {
+			defer wg.Done()
+			s := newSender()
+			defer s.close()
+			gen := newGenerator(cfg, w)
+			for ctx.Err() == nil {
+				if limiter != nil && limiter.WaitN(ctx, cfg.Batch) != nil {
+					return
+				}
+				batch := gen.next(cfg.Batch)
+				rctx, rcancel := context.WithTimeout(ctx, cfg.Timeout)
+				start := time.Now()
+				n, err := s.send(rctx, batch)
+				took := time.Since(start)
+				rcancel()
+				// A request cut short by the end of the run is not an error of the target.
+				if err != nil && (ctx.Err() != nil || !time.Now().Before(end)) {
+					return
+				}
+				t.Requests++
+				if err != nil {
+					t.Errors++
+					if t.ByError == nil {
+						t.ByError = make(map[string]int)
+					}
+					t.ByError[errorKind(err)]++
+					continue
+				}
+				t.Entries += len(batch)
+				t.Bytes += n
+				t.latencies = append(t.latencies, took)
+			}
+		}(&tallies[w])
+	}
+	wg.Wait()
+
+	res := Result{Elapsed: time.Since(began)}
+	var latencies []time.Duration
+	for _, t := range tallies {
+		res.Requests += t.Requests
+		res.Entries += t.Entries
+		res.Bytes += t.Bytes
+		res.Errors += t.Errors
+		for kind, n := range t.ByError {
+			if res.ByError == nil {
+				res.ByError = make(map[string]int)
+			}
+			res.ByError[kind] += n
+		}
+		latencies = append(latencies, t.latencies...)
+	}
+	res.Latency = percentiles(latencies)
+	return res, nil
+}
+
+// percentiles summarizes latencies, sorting them.
+func percentiles(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+	var sum time.Duration
+	for _, d := range latencies {
+		sum += d
+	}
+	at := func(p float64) time.Duration {
+		i := int(p*float64(len(latencies))+0.5) - 1
+		return latencies[min(max(i, 0), len(latencies)-1)]
+	}
+	return Latency{
+		Min:  latencies[0],
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  at(0.50),
+		P90:  at(0.90),
+		P95:  at(0.95),
+		P99:  at(0.99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// errorKind groups err for the report: the HTTP status, a timeout or the error text.
+func errorKind(err error) string {
+	var status statusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		return status.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+		return "connection closed"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op + " error"
+	}
+	return err.Error()
+}
+
+// senderFor checks cfg.Target and returns a constructor of senders for it, one per worker.
+func senderFor(cfg *Config) (func() sender, error) {
+	u, err := url.Parse(cfg.Target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid target %q: want http(s)://, tcp:// or udp:// with a host", cfg.Target)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client := &http.Client{Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: cfg.Concurrency,
+		}}
+		return func() sender { return &httpSender{client: client, url: cfg.Target, token: cfg.Token} }, nil
+	case "tcp":
+		return func() sender { return &connSender{network: "tcp", addr: u.Host} }, nil
+	case "udp":
+		cfg.Batch = 1
+		return func() sender { return &connSender{network: "udp", addr: u.Host} }, nil
+	}
+	return nil, fmt.Errorf("invalid target %q: scheme must be http, https, tcp or udp", cfg.Target)
+}
+
+type httpSender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *httpSender) send(ctx context.Context, entries [][]byte) (int64, error) {
+	body := append([]byte{'['}, bytes.Join(entries, []byte{','})...)
+	body = append(body, ']')
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("X-Akavelog-Token", s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, statusError(resp.StatusCode)
+	}
+	return int64(len(body)), nil
+}
+
+func (s *httpSender) close() {}
+
+// connSender writes newline-terminated entries on a connection it keeps open, dialing again
+// after a failed write.
+type connSender struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func (s *connSender) send(ctx context.Context, entries [][]byte) (int64, error) {
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return 0, err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.Write(e)
+		buf.WriteByte('\n')
+	}
+	n, err := s.conn.Write(buf.Bytes())
+	if err != nil {
+		s.close()
+		return 0, err
+	}
+	return int64(n), nil
+}
+
+func (s *connSender) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// generator builds synthetic entries of about cfg.EntrySize bytes.
+type generator struct {
+	service string
+	worker  int
+	size    int
+	seq     int
+}
+
+var levels = []string{"info", "info", "info", "debug", "warn", "error"}
+
+func newGenerator(cfg Config, worker int) *generator {
+	return &generator{service: cfg.Service, worker: worker, size: cfg.EntrySize}
+}
+
+func (g *generator) next(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		g.seq++
+		e := map[string]any{
+			"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+			"service":   g.service,
+			"level":     levels[g.seq%len(levels)],
+			"tags":      map[string]string{"worker": strconv.Itoa(g.worker), "seq": strconv.Itoa(g.seq)},
+		}
+		raw, _ := json.Marshal(e)
+		// Pad the message so the whole entry is about the requested size.
+		pad := g.size - len(raw) - len(`,"message":""`)
+		e["message"] = "synthetic log entry " + strings.Repeat("x", max(pad-len("synthetic log entry "), 0))
+		out[i], _ = json.Marshal(e)
+	}
+	return out
+}
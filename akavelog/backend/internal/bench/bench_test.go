@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunHTTP(t *testing.T) {
+	var requests, entries atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Akavelog-Token") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var batch []map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Every fifth request is refused as if the queue were full.
+		if requests.Add(1)%5 == 0 {
+			http.Error(w, "queue full", http.StatusServiceUnavailable)
+			return
+		}
+		entries.Add(int64(len(batch)))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	res, err := Run(context.Background(), Config{
+		Target:      srv.URL + "/ingest/bench",
+		Token:       "secret",
+		EntrySize:   200,
+		Batch:       3,
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests == 0 || int64(res.Requests) > requests.Load() {
+		t.Fatalf("requests %d, server saw %d", res.Requests, requests.Load())
+	}
+	// Requests cut short by the end of the run are not counted, though the server may take them.
+	if int64(res.Entries) > entries.Load() || res.Entries != 3*(res.Requests-res.Errors) {
+		t.Errorf("entries %d, server took %d", res.Entries, entries.Load())
+	}
+	if res.Errors == 0 || res.ByError["HTTP 503"] != res.Errors {
+		t.Errorf("errors %d by %v", res.Errors, res.ByError)
+	}
+	if l := res.Latency; l.Min <= 0 || l.P50 < l.Min || l.P99 < l.P50 || l.Max < l.P99 {
+		t.Errorf("latency %+v", l)
+	}
+	if per := res.Bytes / int64(res.Entries); per < 180 || per > 230 {
+		t.Errorf("%d bytes per entry, want about 200", per)
+	}
+}
+
+func TestRunRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	res, err := Run(context.Background(), Config{Target: srv.URL, Rate: 100, Duration: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 500ms at 100/s with a burst of 10.
+	if res.Entries < 30 || res.Entries > 70 {
+		t.Errorf("sent %d entries at 100/s for 500ms", res.Entries)
+	}
+}
+
+func TestRunTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var lines atomic.Int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines.Add(1)
+				}
+			}()
+		}
+	}()
+	res, err := Run(context.Background(), Config{Target: "tcp://" + ln.Addr().String(), Batch: 5, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Entries == 0 || res.Errors != 0 {
+		t.Errorf("result %+v", res)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if lines.Load() < int64(res.Entries) {
+		t.Errorf("sent %d entries, listener read %d lines", res.Entries, lines.Load())
+	}
+}
+
+func TestRunInvalidTarget(t *testing.T) {
+	for _, target := range []string{"", "localhost:8080", "ftp://host/x"} {
+		if _, err := Run(context.Background(), Config{Target: target}); err == nil {
+			t.Errorf("target %q accepted", target)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	l := percentiles(ds)
+	if l.Min != time.Millisecond || l.P50 != 50*time.Millisecond || l.P99 != 99*time.Millisecond || l.Max != 100*time.Millisecond {
+		t.Errorf("percentiles %+v", l)
+	}
+	if l.Mean != 50500*time.Microsecond {
+		t.Errorf("mean %v", l.Mean)
+	}
+	if (percentiles(nil) != Latency{}) {
+		t.Error("percentiles of nothing")
+	}
+}
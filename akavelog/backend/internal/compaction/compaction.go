@@ -0,0 +1,389 @@
+// Package compaction merges the many small batch objects of a project and day into a few large
+// ones. Queries over old data then download fewer objects, and the bucket holds fewer keys.
+// Objects are re-encoded with the configured codec, so older days can be turned into Parquet.
+package compaction
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+// KeyPrefix starts the file name of compacted objects, e.g.
+// logs/default/2024/02/17/compacted-<uuid>.json.gz.
+const KeyPrefix = "compacted-"
+
+// Store is the part of storage.O3Client the compactor uses.
+type Store interface {
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	PutObjectCID(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) (string, error)
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// Config configures a Manager.
+type Config struct {
+	Interval    time.Duration // between runs (default 6h)
+	MinAge      time.Duration // a day is compacted once it has been over this long (default 1h)
+	SmallBytes  int64         // objects smaller than this are merged (default 8 MiB)
+	TargetBytes int           // merged objects hold at most this many uncompressed bytes (default 128 MiB)
+	MinObjects  int           // a day needs this many small objects to be compacted (default 4)
+	Codec       storage.Codec // of merged objects (default gzip-json)
+
+	// OnCompact, when set, is called after each day is compacted, before its sources are
+	// deleted, so the batches index can follow.
+	OnCompact func(Compaction)
+}
+
+// Compaction describes one day of one project merged into new objects.
+type Compaction struct {
+	Dir     string        `json:"dir"` // <prefix>/<project>/YYYY/MM/DD
+	Sources []string      `json:"sources"`
+	Objects []model.Batch `json:"objects"`
+	Entries int           `json:"entries"`
+	Before  int64         `json:"bytes_before"`
+	After   int64         `json:"bytes_after"`
+}
+
+// RunStats describes one compaction run.
+type RunStats struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DryRun     bool      `json:"dry_run"`
+	Scanned    int       `json:"scanned"`
+	Groups     int       `json:"groups"`  // days compacted, or that would be in a dry run
+	Sources    int       `json:"sources"` // objects merged
+	Written    int       `json:"written"` // objects written
+	Entries    int       `json:"entries"`
+	Before     int64     `json:"bytes_before"`
+	After      int64     `json:"bytes_after"`
+	Errors     int       `json:"errors"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Manager compacts the objects under a set of prefixes every Interval.
+type Manager struct {
+	store    Store
+	prefixes func(ctx context.Context) ([]string, error)
+	config   Config
+	stop     chan struct{}
+	done     chan struct{}
+
+	runMu sync.Mutex // one run at a time
+
+	mu   sync.Mutex
+	last *RunStats
+}
+
+// NewManager starts a manager that compacts the objects of store under the key prefixes
+// returned by prefixes (such as logs and every stream's o3_prefix), loaded anew for each run.
+// The first run starts after one Interval.
+func NewManager(cfg Config, store Store, prefixes func(ctx context.Context) ([]string, error)) *Manager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 6 * time.Hour
+	}
+	if cfg.MinAge <= 0 {
+		cfg.MinAge = time.Hour
+	}
+	if cfg.SmallBytes <= 0 {
+		cfg.SmallBytes = 8 << 20
+	}
+	if cfg.TargetBytes <= 0 {
+		cfg.TargetBytes = 128 << 20
+	}
+	if cfg.MinObjects < 2 {
+		cfg.MinObjects = 4
+	}
+	if cfg.Codec == "" {
+		cfg.Codec = storage.CodecGzipJSON
+	}
+	m := &Manager{store: store, prefixes: prefixes, config: cfg, stop: make(chan struct{}), done: make(chan struct{})}
+	go m.loop()
+	return m
+}
+
+func (m *Manager) loop() {
+	defer close(m.done)
+	t := time.NewTicker(m.config.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-m.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if _, err := m.Run(ctx, false); err != nil {
+			log.Printf("[compaction] %v", err)
+		}
+		cancel()
+	}
+}
+
+// Stop stops the manager, interrupting a run in progress. A day being merged keeps its
+// sources until its merged objects are all written.
+func (m *Manager) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// Config returns the manager's configuration, defaults applied.
+func (m *Manager) Config() Config { return m.config }
+
+// LastRun returns the stats of the last run, or nil before the first one.
+func (m *Manager) LastRun() *RunStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return nil
+	}
+	st := *m.last
+	return &st
+}
+
+// group is the small objects of one project and day.
+type group struct {
+	dir     string
+	objects []storage.ObjectInfo
+}
+
+// plan lists the objects under every prefix and returns the days due for compaction, oldest
+// first, and how many objects it looked at.
+func (m *Manager) plan(ctx context.Context, now time.Time) ([]group, int, error) {
+	prefixes, err := m.prefixes(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load prefixes: %w", err)
+	}
+	byDir := make(map[string]*group)
+	listed := make(map[string]bool)
+	seen := make(map[string]bool) // a nested prefix lists objects again
+	for _, prefix := range prefixes {
+		if listed[prefix] {
+			continue
+		}
+		listed[prefix] = true
+		objects, err := m.store.ListObjects(ctx, prefix+"/")
+		if err != nil {
+			return nil, len(seen), fmt.Errorf("list %s/: %w", prefix, err)
+		}
+		for _, obj := range objects {
+			if seen[obj.Key] {
+				continue
+			}
+			seen[obj.Key] = true
+			day, ok := storage.KeyDate(obj.Key)
+			if !ok || obj.Size >= m.config.SmallBytes || now.Sub(day.Add(24*time.Hour)) < m.config.MinAge {
+				continue
+			}
+			dir := path.Dir(obj.Key)
+			g := byDir[dir]
+			if g == nil {
+				g = &group{dir: dir}
+				byDir[dir] = g
+			}
+			g.objects = append(g.objects, obj)
+		}
+	}
+	var groups []group
+	for _, g := range byDir {
+		if len(g.objects) >= m.config.MinObjects {
+			sort.Slice(g.objects, func(i, j int) bool { return g.objects[i].Key < g.objects[j].Key })
+			groups = append(groups, *g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].dir < groups[j].dir })
+	return groups, len(seen), nil
+}
+
+// Run compacts every day that is due, or with dryRun only counts them.
+func (m *Manager) Run(ctx context.Context, dryRun bool) (RunStats, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	st := RunStats{StartedAt: time.Now().UTC(), DryRun: dryRun}
+	groups, scanned, err := m.plan(ctx, st.StartedAt)
+	st.Scanned = scanned
+	if err != nil {
+		st.Errors++
+		st.LastError = err.Error()
+	}
+	for _, g := range groups {
+		if ctx.Err() != nil {
+			break
+		}
+		if dryRun {
+			st.Groups++
+			st.Sources += len(g.objects)
+			for _, obj := range g.objects {
+				st.Before += obj.Size
+			}
+			continue
+		}
+		c, err := m.compact(ctx, g)
+		if err != nil {
+			st.Errors++
+			st.LastError = err.Error()
+			log.Printf("[compaction] %s: %v", g.dir, err)
+			continue
+		}
+		if len(c.Objects) == 0 {
+			continue
+		}
+		st.Groups++
+		st.Sources += len(c.Sources)
+		st.Written += len(c.Objects)
+		st.Entries += c.Entries
+		st.Before += c.Before
+		st.After += c.After
+	}
+	st.FinishedAt = time.Now().UTC()
+	if st.Groups > 0 || st.Errors > 0 {
+		verb := ""
+		if dryRun {
+			verb = " (dry run)"
+		}
+		log.Printf("[compaction] scanned %d objects: merged %d objects of %d days into %d, %d → %d bytes, %d errors%s",
+			st.Scanned, st.Sources, st.Groups, st.Written, st.Before, st.After, st.Errors, verb)
+	}
+	m.mu.Lock()
+	m.last = &st
+	m.mu.Unlock()
+	return st, err
+}
+
+// compact merges the objects of g. Objects that cannot be read are left in place; the others
+// are deleted once every merged object is written.
+func (m *Manager) compact(ctx context.Context, g group) (Compaction, error) {
+	c := Compaction{Dir: g.dir}
+	var entries []model.LogEntry
+	for _, obj := range g.objects {
+		data, err := m.store.GetObject(ctx, obj.Key)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				continue // deleted meanwhile, e.g. by retention
+			}
+			return c, fmt.Errorf("get %s: %w", obj.Key, err)
+		}
+		decoded, err := storage.DecodeLogs(data)
+		if err != nil {
+			log.Printf("[compaction] skip %s: %v", obj.Key, err)
+			continue
+		}
+		entries = append(entries, decoded...)
+		c.Sources = append(c.Sources, obj.Key)
+		c.Before += int64(len(data))
+	}
+	if len(c.Sources) < 2 {
+		return c, nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp < entries[j].Timestamp })
+	c.Entries = len(entries)
+
+	objects, err := m.encode(entries)
+	if err != nil {
+		return c, err
+	}
+	contentType := m.config.Codec.ContentType()
+	for _, obj := range objects {
+		key := path.Join(g.dir, KeyPrefix+uuid.New().String()+m.config.Codec.Ext())
+		meta := map[string]string{
+			storage.MetaCodec: string(m.config.Codec),
+			storage.MetaCount: strconv.Itoa(obj.count),
+		}
+		cid, err := m.store.PutObjectCID(ctx, key, obj.data, contentType, meta)
+		if err != nil {
+			m.discard(c.Objects)
+			return c, fmt.Errorf("put %s: %w", key, err)
+		}
+		batch := storage.DescribeBatch(key, obj.data, obj.entries)
+		batch.CID = cid
+		c.Objects = append(c.Objects, batch)
+		c.After += int64(len(obj.data))
+	}
+	if m.config.OnCompact != nil {
+		m.config.OnCompact(c)
+	}
+	for _, key := range c.Sources {
+		// A source left behind only duplicates entries; the next run merges it again.
+		if err := m.store.DeleteObject(context.WithoutCancel(ctx), key); err != nil {
+			log.Printf("[compaction] delete %s: %v", key, err)
+		}
+	}
+	log.Printf("[compaction] %s: merged %d objects (%d logs) into %d", g.dir, len(c.Sources), c.Entries, len(c.Objects))
+	return c, nil
+}
+
+// discard deletes the objects written for a day whose compaction failed, so its entries are
+// not stored twice.
+func (m *Manager) discard(objects []model.Batch) {
+	for _, obj := range objects {
+		if err := m.store.DeleteObject(context.Background(), obj.Key); err != nil {
+			log.Printf("[compaction] delete %s: %v", obj.Key, err)
+		}
+	}
+}
+
+// object is an encoded merged object holding count entries.
+type object struct {
+	data    []byte
+	count   int
+	entries []model.LogEntry
+}
+
+// encode encodes entries with the configured codec into objects of at most TargetBytes
+// uncompressed bytes each.
+func (m *Manager) encode(entries []model.LogEntry) ([]object, error) {
+	rows := make([][]byte, len(entries))
+	for i := range entries {
+		raw, err := json.Marshal(&entries[i])
+		if err != nil {
+			return nil, fmt.Errorf("marshal: %w", err)
+		}
+		rows[i] = raw
+	}
+	var out []object
+	flush := func(i, j int) error {
+		var data []byte
+		var err error
+		if m.config.Codec == storage.CodecParquet {
+			data, err = storage.EncodeParquet(entries[i:j])
+		} else {
+			data, err = storage.EncodeRows(m.config.Codec, rows[i:j])
+		}
+		if err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		out = append(out, object{data: data, count: j - i, entries: entries[i:j]})
+		return nil
+	}
+	start, size := 0, 0
+	for i, row := range rows {
+		if i > start && size+len(row)+1 > m.config.TargetBytes {
+			if err := flush(start, i); err != nil {
+				return nil, err
+			}
+			start, size = i, 0
+		}
+		size += len(row) + 1
+	}
+	if err := flush(start, len(rows)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
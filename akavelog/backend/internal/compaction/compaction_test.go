@@ -0,0 +1,177 @@
+package compaction
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// memStore is an in-memory bucket.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: make(map[string][]byte), meta: make(map[string]map[string]string)}
+}
+
+func (s *memStore) ListObjects(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []storage.ObjectInfo
+	for k, data := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, storage.ObjectInfo{Key: k, Size: int64(len(data))})
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("no object %s", key)
+	}
+	return data, nil
+}
+
+func (s *memStore) PutObjectCID(_ context.Context, key string, data []byte, _ string, meta map[string]string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key], s.meta[key] = data, meta
+	return "", nil
+}
+
+func (s *memStore) DeleteObject(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStore) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for k := range s.objects {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *memStore) put(t *testing.T, key string, c storage.Codec, entries ...model.LogEntry) {
+	t.Helper()
+	var data []byte
+	var err error
+	if c == storage.CodecParquet {
+		data, err = storage.EncodeParquet(entries)
+	} else {
+		rows := make([][]byte, len(entries))
+		for i, e := range entries {
+			rows[i] = []byte(fmt.Sprintf(`{"timestamp":%q,"service":%q,"level":"info","message":%q}`, e.Timestamp, e.Service, e.Message))
+		}
+		data, err = storage.EncodeRows(c, rows)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.objects[key] = data
+}
+
+func entry(ts string) model.LogEntry {
+	return model.LogEntry{Timestamp: ts, Service: "api", Level: "info", Message: "at " + ts}
+}
+
+func prefixes(context.Context) ([]string, error) { return []string{"logs", "audit"}, nil }
+
+func TestCompact(t *testing.T) {
+	store := newMemStore()
+	day := "logs/default/2024/02/17/"
+	store.put(t, day+"a.json.gz", storage.CodecGzipJSON, entry("2024-02-17T10:00:00Z"), entry("2024-02-17T08:00:00Z"))
+	store.put(t, day+"b.ndjson.zst", storage.CodecZstdNDJSON, entry("2024-02-17T09:00:00Z"))
+	store.put(t, day+"c.parquet", storage.CodecParquet, entry("2024-02-17T07:00:00Z"))
+	store.put(t, "logs/default/2024/02/18/d.json.gz", storage.CodecGzipJSON, entry("2024-02-18T07:00:00Z")) // alone
+	today := time.Now().UTC().Format("2006/01/02")
+	store.put(t, "audit/default/"+today+"/e.json.gz", storage.CodecGzipJSON, entry("x"))
+	store.put(t, "audit/default/"+today+"/f.json.gz", storage.CodecGzipJSON, entry("y"))
+
+	var compactions []Compaction
+	m := NewManager(Config{Interval: time.Hour, MinObjects: 2, Codec: storage.CodecNDJSON, OnCompact: func(c Compaction) {
+		compactions = append(compactions, c)
+	}}, store, prefixes)
+	defer m.Stop()
+
+	st, err := m.Run(context.Background(), true)
+	if err != nil || st.Scanned != 6 || st.Groups != 1 || st.Sources != 3 {
+		t.Fatalf("dry run = %+v, %v", st, err)
+	}
+	if len(store.keys()) != 6 {
+		t.Fatalf("dry run changed the bucket: %v", store.keys())
+	}
+
+	st, err = m.Run(context.Background(), false)
+	if err != nil || st.Groups != 1 || st.Sources != 3 || st.Written != 1 || st.Entries != 4 {
+		t.Fatalf("run = %+v, %v", st, err)
+	}
+	if len(compactions) != 1 || len(compactions[0].Sources) != 3 || compactions[0].Dir != strings.TrimSuffix(day, "/") {
+		t.Fatalf("compactions = %+v", compactions)
+	}
+	key := compactions[0].Objects[0].Key
+	if !strings.HasPrefix(key, day+KeyPrefix) || !strings.HasSuffix(key, ".ndjson") {
+		t.Fatalf("compacted key %s", key)
+	}
+	if obj := compactions[0].Objects[0]; obj.Count != 4 || obj.MinTimestamp == nil || obj.MinTimestamp.Hour() != 7 {
+		t.Errorf("compacted batch = %+v", obj)
+	}
+	if got := store.meta[key][storage.MetaCount]; got != "4" {
+		t.Errorf("count metadata = %q, want 4", got)
+	}
+	entries, err := storage.DecodeLogs(store.objects[key])
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("decode = %d entries, %v", len(entries), err)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i-1].Timestamp > entries[i].Timestamp {
+			t.Fatalf("entries not sorted: %v before %v", entries[i-1].Timestamp, entries[i].Timestamp)
+		}
+	}
+	if n := len(store.keys()); n != 4 {
+		t.Fatalf("bucket = %v, want the sources replaced", store.keys())
+	}
+}
+
+func TestCompactSplitsLargeDays(t *testing.T) {
+	store := newMemStore()
+	for i := range 6 {
+		store.put(t, fmt.Sprintf("logs/p/2024/01/01/%d.json.gz", i), storage.CodecGzipJSON, entry(fmt.Sprintf("2024-01-01T00:00:0%dZ", i)))
+	}
+	m := NewManager(Config{Interval: time.Hour, MinObjects: 2, TargetBytes: 200, Codec: storage.CodecParquet}, store, prefixes)
+	defer m.Stop()
+	st, err := m.Run(context.Background(), false)
+	if err != nil || st.Sources != 6 || st.Written < 2 || st.Entries != 6 {
+		t.Fatalf("run = %+v, %v", st, err)
+	}
+	total := 0
+	for _, k := range store.keys() {
+		entries, err := storage.DecodeLogs(store.objects[k])
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		total += len(entries)
+	}
+	if total != 6 {
+		t.Fatalf("%d entries after compaction, want 6", total)
+	}
+}
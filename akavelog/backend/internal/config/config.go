@@ -1,14 +1,20 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/akave-ai/akavelog/internal/secrets"
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
-	"github.com/rs/zerolog"
 )
 
 type Config struct {
@@ -18,17 +24,245 @@ type Config struct {
 	Observability *ObservabilityConfig `koanf:"observability" validate:"required"`
 	Storage       *StorageConfig       `koanf:"storage"`       // optional; Akave O3 when set
 	Batcher       *BatcherConfig       `koanf:"batcher"`       // optional; batch size and flush interval
+	Buffer        *BufferConfig        `koanf:"buffer"`        // optional; bounded ingest queue
+	Retention     *RetentionConfig     `koanf:"retention"`     // optional; retention job for O3 objects
+	Compaction    *CompactionConfig    `koanf:"compaction"`    // optional; merges small O3 objects
+	SQL           *SQLConfig           `koanf:"sql"`           // optional; limits of POST /logs/sql
+	Tail          *TailConfig          `koanf:"tail"`          // optional; limits of GET /logs/tail
+	RecentLogs    *RecentLogsConfig    `koanf:"recent_logs"`   // optional; entries kept for GET /logs/recent
+	Export        *ExportConfig        `koanf:"export"`        // optional; limits of POST /exports
+	Replay        *ReplayConfig        `koanf:"replay"`        // optional; limits of POST /replay
+	Idempotency   *IdempotencyConfig   `koanf:"idempotency"`   // optional; Idempotency-Key of POST /inputs and /ingest
+	EntryIDs      *EntryIDsConfig      `koanf:"entry_ids"`     // optional; duplicate _id detection on ingest
+	Reports       *ReportsConfig       `koanf:"reports"`       // optional; scheduled reports
+	Alerts        *AlertsConfig        `koanf:"alerts"`        // optional; evaluation of /alerts
+	Anomaly       *AnomalyConfig       `koanf:"anomaly"`       // optional; baselines of anomaly alerts
+	Auth          *AuthConfig          `koanf:"auth"`          // optional; API keys and users of the management API
+	Events        *EventsConfig        `koanf:"events"`        // optional; GET /events and webhooks of system events
+	Inputs        []InputSpec          `koanf:"inputs"`        // optional; config file only: inputs reconciled at startup and on reload
+	PruneInputs   bool                 `koanf:"prune_inputs"`  // delete inputs not in Inputs when reconciling
+	Pipelines     []PipelineSpec       `koanf:"pipelines"`     // optional; config file only: pipelines created at startup
+
+	File string `koanf:"-"` // the config file loaded, if any; reloads read it again
+}
+
+// InputSpec declares an input in the config file, with the fields of POST /inputs. At startup
+// and on reload the input is created unless one with its title exists, and updated when its
+// config, state or project drifted from the spec.
+type InputSpec struct {
+	Type        string         `koanf:"type"`
+	Title       string         `koanf:"title"` // identifies the input; required
+	Description string         `koanf:"description"`
+	Project     string         `koanf:"project"` // project ID or name
+	State       string         `koanf:"state"`   // RUNNING (default), STOPPED or PAUSED
+	Config      map[string]any `koanf:"config"`  // type-specific, e.g. listen
+}
+
+// PipelineSpec declares a pipeline in the config file, with the fields of POST /pipelines.
+// At startup the pipeline is created unless one with its name exists.
+type PipelineSpec struct {
+	Name        string          `koanf:"name"` // identifies the pipeline; required
+	Description string          `koanf:"description"`
+	Input       string          `koanf:"input"`   // title of the input it applies to; empty for every input
+	Project     string          `koanf:"project"` // project ID or name
+	Enabled     *bool           `koanf:"enabled"` // default true
+	Processors  []ProcessorSpec `koanf:"processors"`
+}
+
+// ProcessorSpec is one processor of a PipelineSpec.
+type ProcessorSpec struct {
+	Type   string         `koanf:"type"`
+	Stage  string         `koanf:"stage"` // default: the type's stage
+	Config map[string]any `koanf:"config"`
+}
+
+// AuthConfig configures the API keys and user sessions every management route requires.
+type AuthConfig struct {
+	Disabled  bool        `koanf:"disabled"`   // serve the API without keys (development only)
+	AdminKey  string      `koanf:"admin_key"`  // a key with the admin scope that is not stored, to create the first keys and users
+	CacheTTL  string      `koanf:"cache_ttl"`  // keys and users are looked up again after this long (default 30s)
+	JWTSecret string      `koanf:"jwt_secret"` // signs session tokens; random per process when empty
+	TokenTTL  string      `koanf:"token_ttl"`  // session tokens expire after this long (default 12h)
+	OIDC      *OIDCConfig `koanf:"oidc"`       // optional; single sign-on with an OpenID Connect provider
+}
+
+// OIDCConfig configures login with an OpenID Connect identity provider such as Okta or
+// Azure AD, and the API accepting the tokens it issues. Lists are comma-separated.
+type OIDCConfig struct {
+	Issuer         string   `koanf:"issuer"` // e.g. https://example.okta.com/oauth2/default
+	ClientID       string   `koanf:"client_id"`
+	ClientSecret   string   `koanf:"client_secret"`
+	RedirectURL    string   `koanf:"redirect_url"`    // this server's /auth/oidc/callback
+	PostLoginURL   string   `koanf:"post_login_url"`  // where to send the browser with the token; default answer JSON
+	Scopes         []string `koanf:"scopes"`          // besides openid (default email,profile)
+	Audiences      []string `koanf:"audiences"`       // accepted aud of tokens sent to the API (default client_id)
+	GroupsClaim    string   `koanf:"groups_claim"`    // claim listing the user's groups (default groups)
+	AdminGroups    []string `koanf:"admin_groups"`    // groups granting the admin role
+	OperatorGroups []string `koanf:"operator_groups"` // groups granting the operator role
+	ViewerGroups   []string `koanf:"viewer_groups"`   // groups granting the viewer role
+	DefaultRole    string   `koanf:"default_role"`    // role of users in none of them; default refuse them
+}
+
+// CompactionConfig enables the job merging the small batch objects of a project and day.
+type CompactionConfig struct {
+	Enabled     bool   `koanf:"enabled"`      // off by default
+	Interval    string `koanf:"interval"`     // between runs (default 6h)
+	MinAge      string `koanf:"min_age"`      // compact a day once it has been over this long (default 1h)
+	SmallBytes  int64  `koanf:"small_bytes"`  // merge objects smaller than this (default 8 MiB)
+	TargetBytes int    `koanf:"target_bytes"` // uncompressed size of merged objects (default 128 MiB)
+	MinObjects  int    `koanf:"min_objects"`  // small objects a day needs to be compacted (default 4)
+	Codec       string `koanf:"codec"`        // of merged objects (default: the batcher's codec)
+}
+
+// SQLConfig bounds the statements run by POST /logs/sql.
+type SQLConfig struct {
+	MaxScanBytes int64  `koanf:"max_scan_bytes"` // stored size of the objects one statement may read (default 1 GiB)
+	MaxRows      int    `koanf:"max_rows"`       // result rows (default 10000)
+	Timeout      string `koanf:"timeout"`        // a statement is canceled after this long (default 5m)
+	SyncWait     string `koanf:"sync_wait"`      // answer with a job ID when a statement runs longer (default 10s)
+	MaxJobs      int    `koanf:"max_jobs"`       // statements running at once (default 4)
+	ResultTTL    string `koanf:"result_ttl"`     // results of background statements are kept this long (default 1h)
+}
+
+// ExportConfig bounds the exports run by POST /exports.
+type ExportConfig struct {
+	MaxJobs      int    `koanf:"max_jobs"`       // exports running at once (default 2)
+	MaxRows      int    `koanf:"max_rows"`       // rows one export writes at most (default 1000000)
+	MaxScanBytes int64  `koanf:"max_scan_bytes"` // stored size of the objects one export reads (default 10 GiB)
+	Timeout      string `koanf:"timeout"`        // an export is canceled after this long (default 30m)
+	URLExpiry    string `koanf:"url_expiry"`     // download URLs are valid this long (default 1h)
+	TTL          string `koanf:"ttl"`            // exports are deleted this long after they finish (default 24h)
+}
+
+// ReplayConfig bounds the replays run by POST /replay and akavelog replay.
+type ReplayConfig struct {
+	MaxJobs      int    `koanf:"max_jobs"`       // replays running at once (default 1)
+	MaxObjects   int    `koanf:"max_objects"`    // batch objects one replay reads at most (default 10000)
+	MaxScanBytes int64  `koanf:"max_scan_bytes"` // stored size of the objects one replay reads (default 10 GiB)
+	Timeout      string `koanf:"timeout"`        // a replay is canceled after this long (default 1h)
+	TTL          string `koanf:"ttl"`            // finished replays are listed this long (default 24h)
+}
+
+// IdempotencyConfig bounds the responses kept for requests with an Idempotency-Key.
+type IdempotencyConfig struct {
+	TTL     string `koanf:"ttl"`      // a response answers retries this long (default 24h)
+	MaxKeys int    `koanf:"max_keys"` // keys kept at most (default 10000)
+}
+
+// EntryIDsConfig bounds the client-provided entry _id values remembered to drop resubmitted
+// entries.
+type EntryIDsConfig struct {
+	Window string `koanf:"window"`  // an _id seen this long ago is a duplicate (default 10m)
+	MaxIDs int    `koanf:"max_ids"` // IDs remembered at most (default 1000000)
+}
+
+// EventsConfig configures the system events of GET /events (an input crashed, a flush failed,
+// retention deleted objects, an alert fired) and the webhooks they are POSTed to.
+type EventsConfig struct {
+	Recent   int                  `koanf:"recent"`   // events kept for GET /events (default 1000)
+	Webhooks []EventWebhookConfig `koanf:"webhooks"` // config file only
+}
+
+// EventWebhookConfig subscribes a URL to system events.
+type EventWebhookConfig struct {
+	Name       string            `koanf:"name"`        // shown in GET /events/webhooks (default: the URL's host)
+	URL        string            `koanf:"url"`         // http or https; required
+	Types      []string          `koanf:"types"`       // event types or families such as input.*; empty for every type
+	Secret     string            `koanf:"secret"`      // signs each body in X-Akavelog-Signature (HMAC-SHA256)
+	Headers    map[string]string `koanf:"headers"`     // extra request headers
+	Timeout    string            `koanf:"timeout"`     // of one attempt (default 10s)
+	MaxRetries int               `koanf:"max_retries"` // of a failed delivery (default 3; -1 for none)
+}
+
+// ReportsConfig configures the scheduler of /reports.
+type ReportsConfig struct {
+	Interval   string `koanf:"interval"`    // how often due reports are looked for (default 1m)
+	Timeout    string `koanf:"timeout"`     // a run is canceled after this long (default 10m)
+	MaxObjects int    `koanf:"max_objects"` // batch objects one run reads at most (default 10000)
+	URLExpiry  string `koanf:"url_expiry"`  // download URLs sent to outputs are valid this long (default 168h)
+}
+
+// AlertsConfig configures the evaluation of /alerts.
+type AlertsConfig struct {
+	Interval string `koanf:"interval"` // how often alert conditions are checked (default 15s)
 }
 
-// BatcherConfig is optional; used when Storage.O3 is set.
+// AnomalyConfig configures the analyzer learning the baselines of anomaly alerts and
+// GET /analytics/baselines. It runs whenever O3 is set.
+type AnomalyConfig struct {
+	Disabled   bool    `koanf:"disabled"`    // turn the analyzer off
+	Interval   string  `koanf:"interval"`    // bucket length; volumes are entries per bucket (default 5m)
+	Delay      string  `koanf:"delay"`       // a bucket is analyzed this long after it ends (default 2m)
+	Alpha      float64 `koanf:"alpha"`       // weight of a new bucket in a baseline (default 0.1)
+	Threshold  float64 `koanf:"threshold"`   // standard deviations that are an anomaly (default 3)
+	MinCount   int     `koanf:"min_count"`   // entries a spike needs and a drop expects (default 20)
+	MinSamples int     `koanf:"min_samples"` // buckets learned before a baseline is compared with (default 12)
+	MaxObjects int     `koanf:"max_objects"` // batch objects one bucket reads at most (default 10000)
+}
+
+// TailConfig bounds the live streams of GET /logs/tail.
+type TailConfig struct {
+	MaxSubscribers int    `koanf:"max_subscribers"` // streams open at once (default 100)
+	QueueSize      int    `koanf:"queue_size"`      // entries queued per stream before they are dropped (default 256)
+	Heartbeat      string `koanf:"heartbeat"`       // keep-alive period of idle streams (default 15s)
+}
+
+// RecentLogsConfig sizes the latest entries kept in memory for GET /logs/recent, GET
+// /inputs/:id/recent and the backlog of GET /logs/tail.
+type RecentLogsConfig struct {
+	Capacity int `koanf:"capacity"`  // entries kept of all inputs (default 1000)
+	PerInput int `koanf:"per_input"` // entries kept of each input on its own (default 100)
+}
+
+// RetentionConfig tunes the retention job. Policies themselves are managed with /retention.
+type RetentionConfig struct {
+	Interval    string `koanf:"interval"`     // between runs (default 1h)
+	DryRun      bool   `koanf:"dry_run"`      // log and report what would be deleted, delete nothing
+	DefaultDays int    `koanf:"default_days"` // keep logs/ objects this long where no policy applies (default 0: forever)
+}
+
+// BufferConfig sizes the bounded queue between inputs (after their pipelines) and the batcher.
+type BufferConfig struct {
+	Capacity     int    `koanf:"capacity"`      // payloads queued at most (default 10000)
+	Overflow     string `koanf:"overflow"`      // block (default), drop_oldest, drop_newest or reject
+	BlockTimeout string `koanf:"block_timeout"` // block: longest wait for space before 429 (default 5s)
+}
+
+// BatcherConfig is optional; used when a storage backend is configured.
 type BatcherConfig struct {
-	MaxBatchSize  int    `koanf:"max_batch_size"`  // flush when batch has this many entries (default 1000)
-	FlushInterval string `koanf:"flush_interval"`  // e.g. "5s", "30s" (default 30s)
+	MaxBatchSize         int    `koanf:"max_batch_size"`         // flush when batch has this many entries (default 1000)
+	MaxBatchBytes        int    `koanf:"max_batch_bytes"`        // flush when batch has this many uncompressed bytes (default 32 MiB)
+	FlushInterval        string `koanf:"flush_interval"`         // e.g. "5s", "30s" (default 30s)
+	MaxObjectBytes       int    `koanf:"max_object_bytes"`       // split batches into objects of at most this size (default 32 MiB)
+	ObjectSizeCompressed bool   `koanf:"object_size_compressed"` // max_object_bytes counts encoded bytes
+	Codec                string `koanf:"codec"`                  // gzip-json (default), zstd-ndjson, ndjson or parquet
+	SpillDir             string `koanf:"spill_dir"`              // failed uploads wait here for a retry (default: in memory)
+	MaxRetryBytes        int64  `koanf:"max_retry_bytes"`        // oldest failed uploads are dropped beyond this (default 1 GiB)
+	Workers              int    `koanf:"workers"`                // concurrent batch uploads (default: number of CPUs)
 }
 
 // StorageConfig holds storage backends (e.g. Akave O3).
 type StorageConfig struct {
-	O3 *O3Config `koanf:"o3"`
+	// Backend is where batches are stored: o3 (default), fs, gcs, azure or akave, each
+	// configured by the field of the same name.
+	Backend string       `koanf:"backend"`
+	O3      *O3Config    `koanf:"o3"`
+	FS      *FSConfig    `koanf:"fs"`
+	GCS     *GCSConfig   `koanf:"gcs"`
+	Azure   *AzureConfig `koanf:"azure"`
+	Akave   *AkaveConfig `koanf:"akave"`
+	WAL     *WALConfig   `koanf:"wal"` // optional; durable on-disk buffer in front of the batcher
+	// Targets are named O3 stores a stream or project may keep its batches in, apart from
+	// the others. Fields a target leaves unset are taken from O3.
+	Targets map[string]*O3Config `koanf:"targets"`
+}
+
+// WALConfig enables the batcher's write-ahead log. Used only when a storage backend is configured.
+type WALConfig struct {
+	Dir           string `koanf:"dir"`            // segment directory; the WAL is off when empty
+	SegmentSize   int64  `koanf:"segment_size"`   // bytes per segment file (default 64 MiB)
+	Fsync         string `koanf:"fsync"`          // always, interval (default) or never
+	FsyncInterval string `koanf:"fsync_interval"` // for fsync=interval (default 1s)
 }
 
 // O3Config is S3-compatible config for Akave O3 (https://o3-rc2.akave.xyz or similar).
@@ -38,6 +272,49 @@ type O3Config struct {
 	Region    string `koanf:"region"`     // e.g. us-east-1
 	AccessKey string `koanf:"access_key"`
 	SecretKey string `koanf:"secret_key"`
+	Manifest  string `koanf:"manifest"` // optional; local JSON-lines record of uploaded objects and their checksums
+
+	// HTTP client tuning. Unset values take the defaults in parentheses.
+	MaxIdleConns    int    `koanf:"max_idle_conns"`   // idle connections kept to the endpoint (100)
+	DialTimeout     string `koanf:"dial_timeout"`     // to connect (5s)
+	RequestTimeout  string `koanf:"request_timeout"`  // per attempt, the body included (60s)
+	MaxRetries      int    `koanf:"max_retries"`      // attempts after the first (2)
+	RetryMode       string `koanf:"retry_mode"`       // adaptive (default) or standard
+	MaxBackoff      string `koanf:"max_backoff"`      // longest wait between attempts (5s)
+	CABundle        string `koanf:"ca_bundle"`        // PEM file of CAs trusted besides the system's
+	BreakerFailures int    `koanf:"breaker_failures"` // consecutive failed requests that open the circuit (5)
+	BreakerCooldown string `koanf:"breaker_cooldown"` // how long it stays open before a probe (30s)
+}
+
+// FSConfig stores objects as files under a local directory (storage.backend: fs), for
+// development and tests without a bucket.
+type FSConfig struct {
+	Dir string `koanf:"dir"` // created if missing
+}
+
+// GCSConfig stores objects in a Google Cloud Storage bucket (storage.backend: gcs).
+type GCSConfig struct {
+	Bucket          string `koanf:"bucket"`
+	CredentialsFile string `koanf:"credentials_file"` // service account JSON key; requests are anonymous without one (emulators)
+	Project         string `koanf:"project"`          // project the bucket is created in (default: the key's project_id)
+	Endpoint        string `koanf:"endpoint"`         // default https://storage.googleapis.com
+}
+
+// AzureConfig stores objects in an Azure Blob Storage container (storage.backend: azure).
+type AzureConfig struct {
+	Account   string `koanf:"account"`   // storage account name
+	Key       string `koanf:"key"`       // base64 account key (Shared Key)
+	Container string `koanf:"container"` // container name
+	Endpoint  string `koanf:"endpoint"`  // default https://<account>.blob.core.windows.net
+}
+
+// AkaveConfig stores objects on the Akave network through the native API of an Akave Link
+// node (storage.backend: akave) rather than the O3 gateway, which records each object's root
+// CID.
+type AkaveConfig struct {
+	Endpoint string `koanf:"endpoint"` // Akave Link API, e.g. http://localhost:8000
+	Bucket   string `koanf:"bucket"`   // bucket name
+	Token    string `koanf:"token"`    // optional bearer token, for a node behind an authenticating proxy
 }
 
 type Primary struct {
@@ -50,6 +327,8 @@ type ServerConfig struct {
 	WriteTimeout       int      `koanf:"write_timeout" validate:"required"`
 	IdleTimeout        int      `koanf:"idle_timeout" validate:"required"`
 	CORSAllowedOrigins []string `koanf:"cors_allowed_origins" validate:"required"`
+	DrainTimeout       string   `koanf:"drain_timeout"`  // bounds draining inputs and requests on shutdown; default 30s
+	MaxBodyBytes       int64    `koanf:"max_body_bytes"` // larger API request bodies get 413; default 10 MiB
 }
 
 type DatabaseConfig struct {
@@ -65,31 +344,58 @@ type DatabaseConfig struct {
 	ConnMaxIdleTime int    `koanf:"conn_max_idle_time" validate:"required"`
 }
 
-// LoadConfig loads the configuration from environment variables using koanf.
-// If a .env file exists in the current directory, it is loaded first.
-func LoadConfig() (mainConfig *Config, err error) {
-	_ = godotenv.Load(".env") // optional; ignore if missing
+// LoadConfig loads the configuration from the file AKAVELOG_CONFIG names, if any, and from
+// environment variables using koanf. If a .env file exists in the current directory, it is
+// loaded first.
+func LoadConfig() (*Config, error) {
+	return LoadConfigFile("")
+}
 
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+// LoadConfigFile is LoadConfig with the config file at path; "" means the one AKAVELOG_CONFIG
+// names. The file is YAML (.yaml, .yml) or TOML (.toml) and uses the keys of the environment
+// variables without their prefix, nested: storage.o3.bucket is AKAVELOG_STORAGE.O3.BUCKET.
+// Environment variables override the file's values. Settings may be env://, file:// or
+// vault:// references, resolved here; those of declared inputs and pipelines are kept for
+// when they run. Invalid settings are returned as errors.
+func LoadConfigFile(path string) (mainConfig *Config, err error) {
+	_ = godotenv.Load(".env") // optional; ignore if missing
 
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
 	k := koanf.New(".")
+	if path != "" {
+		parser, err := fileParser(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.Load(file.Provider(path), parser); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
 	err = k.Load(env.Provider("AKAVELOG_", ".", func(s string) string {
+		if s == configFileEnv {
+			return ""
+		}
 		return strings.ToLower(strings.TrimPrefix(s, "AKAVELOG_"))
 	}), nil)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("could not load initial env variables")
+		return nil, fmt.Errorf("could not load env variables: %w", err)
 	}
 
-	mainConfig = &Config{}
+	mainConfig = &Config{File: path}
 	err = k.Unmarshal("", mainConfig)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("could not unmarshal mainconfig")
+		return nil, fmt.Errorf("could not unmarshal config: %w", err)
+	}
+	if err = secrets.ResolveStruct(context.Background(), mainConfig); err != nil {
+		return nil, fmt.Errorf("could not resolve secrets: %w", err)
 	}
 
 	validate := validator.New()
 	err = validate.Struct(mainConfig)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("could not validate the struct")
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
 	// set default observability config if not provided
@@ -105,8 +411,22 @@ func LoadConfig() (mainConfig *Config, err error) {
 	// automatic pointer dereferencing for method calls
 	err = mainConfig.Observability.Validate()
 	if err != nil {
-		logger.Fatal().Err(err).Msg("invalid observability config")
+		return nil, fmt.Errorf("invalid observability config: %w", err)
 	}
 
 	return
 }
+
+// configFileEnv names the config file when no path is given.
+const configFileEnv = "AKAVELOG_CONFIG"
+
+// fileParser returns the parser of the config file at path, by its extension.
+func fileParser(path string) (koanf.Parser, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Parser(), nil
+	case ".toml":
+		return toml.Parser(), nil
+	}
+	return nil, fmt.Errorf("config file %s: unknown format (want .yaml, .yml or .toml)", path)
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testYAML = `
+primary:
+  env: development
+server:
+  port: "8080"
+  read_timeout: 30
+  write_timeout: 30
+  idle_timeout: 60
+  cors_allowed_origins: ["http://localhost:3000"]
+database:
+  host: localhost
+  port: 5432
+  user: akavelog
+  name: akavelog
+  ssl_mode: disable
+  max_open_conns: 10
+  max_idle_conns: 5
+  conn_max_lifetime: 300
+  conn_max_idle_time: 60
+observability:
+  service_name: akavelog
+  environment: development
+  logging:
+    level: info
+    format: console
+  health_checks:
+    interval: 10s
+    timeout: 5s
+storage:
+  o3:
+    endpoint: https://o3.example
+    bucket: from-file
+batcher:
+  max_batch_size: 500
+  flush_interval: 10s
+inputs:
+  - type: http
+    title: edge
+    config:
+      listen: ":9001"
+pipelines:
+  - name: drop-debug
+    input: edge
+    processors:
+      - type: drop
+        config:
+          levels: debug,trace
+`
+
+func writeConfig(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFileWithEnvOverrides(t *testing.T) {
+	t.Setenv("AKAVELOG_STORAGE.O3.BUCKET", "from-env")
+	t.Setenv(configFileEnv, writeConfig(t, "akavelog.yaml", testYAML))
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage == nil || cfg.Storage.O3 == nil {
+		t.Fatal("storage.o3 not loaded")
+	}
+	if cfg.Storage.O3.Endpoint != "https://o3.example" || cfg.Storage.O3.Bucket != "from-env" {
+		t.Errorf("o3 = %+v, want the file's endpoint and the env's bucket", *cfg.Storage.O3)
+	}
+	if cfg.Batcher == nil || cfg.Batcher.MaxBatchSize != 500 || cfg.Batcher.FlushInterval != "10s" {
+		t.Errorf("batcher = %+v", cfg.Batcher)
+	}
+	if len(cfg.Server.CORSAllowedOrigins) != 1 || cfg.Observability.HealthChecks.Interval.String() != "10s" {
+		t.Errorf("server = %+v, health checks = %+v", cfg.Server, cfg.Observability.HealthChecks)
+	}
+	if len(cfg.Inputs) != 1 || cfg.Inputs[0].Title != "edge" || cfg.Inputs[0].Config["listen"] != ":9001" {
+		t.Errorf("inputs = %+v", cfg.Inputs)
+	}
+	if len(cfg.Pipelines) != 1 || cfg.Pipelines[0].Input != "edge" || len(cfg.Pipelines[0].Processors) != 1 ||
+		cfg.Pipelines[0].Processors[0].Config["levels"] != "debug,trace" {
+		t.Errorf("pipelines = %+v", cfg.Pipelines)
+	}
+}
+
+func TestLoadConfigFileTOML(t *testing.T) {
+	path := writeConfig(t, "akavelog.toml", `
+[primary]
+env = "development"
+
+[server]
+port = "8080"
+read_timeout = 30
+write_timeout = 30
+idle_timeout = 60
+cors_allowed_origins = ["*"]
+
+[database]
+host = "localhost"
+port = 5432
+user = "akavelog"
+name = "akavelog"
+ssl_mode = "disable"
+max_open_conns = 10
+max_idle_conns = 5
+conn_max_lifetime = 300
+conn_max_idle_time = 60
+
+[observability]
+service_name = "akavelog"
+environment = "development"
+
+[observability.logging]
+level = "info"
+format = "console"
+
+[observability.health_checks]
+interval = "10s"
+timeout = "5s"
+
+[buffer]
+capacity = 2000
+`)
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Buffer == nil || cfg.Buffer.Capacity != 2000 || cfg.Database.Port != 5432 {
+		t.Errorf("buffer = %+v, database = %+v", cfg.Buffer, cfg.Database)
+	}
+
+	if _, err := LoadConfigFile(writeConfig(t, "akavelog.ini", "")); err == nil {
+		t.Error("loaded a config file of unknown format")
+	}
+}
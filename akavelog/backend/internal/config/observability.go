@@ -14,6 +14,8 @@ type ObservabilityConfig struct {
 	Logging      LoggingConfig      `koanf:"logging" validate:"required"`
 	NewRelic     NewRelicConfig     `koanf:"new_relic" validate:"required"`
 	HealthChecks HealthChecksConfig `koanf:"health_checks" validate:"required"`
+	Tracing      TracingConfig      `koanf:"tracing"`
+	SelfLogs     SelfLogsConfig     `koanf:"self_logs"`
 }
 
 type LoggingConfig struct {
@@ -29,6 +31,21 @@ type NewRelicConfig struct {
 	DebugLogging              bool   `koanf:"debug_logging"`
 }
 
+// TracingConfig configures OpenTelemetry tracing of the ingest path, exported over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled     bool    `koanf:"enabled"`
+	Endpoint    string  `koanf:"endpoint"`     // OTLP/HTTP traces URL; empty = OTEL_EXPORTER_OTLP_* or localhost:4318
+	SampleRatio float64 `koanf:"sample_ratio"` // fraction of new traces recorded, 0 < r <= 1 (default 1)
+}
+
+// SelfLogsConfig configures feeding akavelog's own logs into a stream of their own, through
+// the pipelines and O3 archival like any ingested entry.
+type SelfLogsConfig struct {
+	Enabled bool   `koanf:"enabled"`
+	Level   string `koanf:"level"`  // lowest level forwarded: debug, info (default), warn or error
+	Stream  string `koanf:"stream"` // stream name, created when missing (default akavelog-internal)
+}
+
 type HealthChecksConfig struct {
 	Enabled  bool          `koanf:"enabled"`
 	Interval time.Duration `koanf:"interval" validate:"min=1s"`
@@ -69,6 +86,10 @@ func (c *ObservabilityConfig) Validate() error {
 		return fmt.Errorf("SlowQueryThreshold should non-negative")
 	}
 
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample_ratio must be between 0 and 1")
+	}
+
 	return nil
 }
 
@@ -0,0 +1,192 @@
+// Package cron parses five-field cron expressions (minute, hour, day of month, month, day of
+// week) and computes when they next fire.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// macros are the @-shorthands and the expressions they stand for.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// field describes the values one position of an expression takes.
+type field struct {
+	name     string
+	min, max int
+	names    []string // names of min, min+1, ...; nil for numbers only
+}
+
+var fields = [5]field{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, monthNames},
+	{"day of week", 0, 7, dayNames}, // 7 is Sunday too
+}
+
+// maxYears bounds the search of Next, for expressions such as "0 0 30 2 *" that never fire.
+const maxYears = 5
+
+// Schedule is a parsed expression. Times are matched in its location.
+type Schedule struct {
+	src                          string
+	loc                          *time.Location
+	minute, hour, dom, month     uint64 // bit n set when value n matches
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+// Parse parses a cron expression: five space-separated fields, each "*", a value, a range
+// "a-b" or a list of them, optionally with a step ("*/15", "1-5/2"). Months and days of week
+// may be given by their first three letters (jan, mon); both 0 and 7 are Sunday. The
+// shorthands @hourly, @daily, @weekly, @monthly and @yearly are accepted as well. As in
+// classic cron, when both the day of month and the day of week are restricted, a day
+// matching either fires. loc is the time zone matched in; nil means UTC.
+func Parse(expr string, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	src := strings.TrimSpace(expr)
+	spec := src
+	if strings.HasPrefix(spec, "@") {
+		m, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown shorthand %s", spec)
+		}
+		spec = m
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(parts))
+	}
+	s := &Schedule{src: src, loc: loc}
+	bits := [5]*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, p := range parts {
+		b, err := parseField(p, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		*bits[i] = b
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = parts[2] != "*" && !strings.HasPrefix(parts[2], "*/")
+	s.dowRestricted = parts[4] != "*" && !strings.HasPrefix(parts[4], "*/")
+	return s, nil
+}
+
+// parseField returns the values of one comma-separated field as a bit set.
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepStr)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(a, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(b, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max // "5/15" is 5, 20, 35, 50
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: range %s is backwards", f.name, rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses one number or name of f.
+func value(s string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: %q is not between %d and %d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// String returns the expression as given to Parse.
+func (s *Schedule) String() string { return s.src }
+
+// Location returns the time zone the schedule is matched in.
+func (s *Schedule) Location() *time.Location { return s.loc }
+
+// Next returns the first time after t the schedule fires, or the zero time when it does not
+// fire within five years (e.g. on February 30).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxYears
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = s.advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc))
+		case !s.dayMatches(t):
+			t = s.advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = s.advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance returns next, or t plus a minute when next is not after t: time.Date may move a
+// time skipped by a daylight saving change back before it.
+func (s *Schedule) advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
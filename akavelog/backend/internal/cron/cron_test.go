@@ -0,0 +1,75 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2026-03-04T10:18:00Z"},
+		{"*/15 * * * *", "2026-03-04T10:30:00Z"},
+		{"5/20 * * * *", "2026-03-04T10:25:00Z"},
+		{"0 9 * * mon", "2026-03-09T09:00:00Z"},
+		{"0 9 * * 1-5", "2026-03-05T09:00:00Z"},
+		{"0 0 * * 7", "2026-03-08T00:00:00Z"},
+		{"30 8 1 * *", "2026-04-01T08:30:00Z"},
+		{"0 0 1,15 * 1", "2026-03-09T00:00:00Z"}, // either day matches
+		{"0 12 * feb-apr *", "2026-03-04T12:00:00Z"},
+		{"@weekly", "2026-03-08T00:00:00Z"},
+		{"@yearly", "2027-01-01T00:00:00Z"},
+		{"0 0 29 2 *", "2028-02-29T00:00:00Z"},
+		{"0 0 30 2 *", "0001-01-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr, nil)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%q: Next = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	s, err := Parse("0 9 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Across the switch to daylight saving time on 2026-03-08.
+	got := s.Next(time.Date(2026, 3, 7, 15, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got.UTC(), want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@fortnightly",
+	} {
+		if _, err := Parse(expr, nil); err == nil {
+			t.Errorf("Parse(%q): no error", expr)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS trigger_set_updated_at();
+DROP FUNCTION IF EXISTS camel(anyelement);
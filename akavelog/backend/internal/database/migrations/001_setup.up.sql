@@ -26,8 +26,3 @@ BEGIN
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
-
----- create above / drop below ----
-
-DROP FUNCTION IF EXISTS trigger_set_updated_at();
-DROP FUNCTION IF EXISTS camel(anyelement);
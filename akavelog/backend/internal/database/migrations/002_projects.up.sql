@@ -6,7 +6,3 @@ CREATE TABLE IF NOT EXISTS projects (
     owner_email TEXT,
     created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
-
----- create above / drop below ----
-
-DROP TABLE IF EXISTS projects;
@@ -21,14 +21,3 @@ CREATE TABLE IF NOT EXISTS inputs (
 
 CREATE INDEX IF NOT EXISTS idx_inputs_type ON inputs(type);
 CREATE INDEX IF NOT EXISTS idx_inputs_node_id ON inputs(node_id);
-
----- create above / drop below ----
-
-DROP TABLE IF EXISTS inputs;
-
-DO $$
-BEGIN
-    IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'input_state') THEN
-        DROP TYPE input_state;
-    END IF;
-END$$;
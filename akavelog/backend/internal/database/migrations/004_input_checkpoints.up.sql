@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS input_checkpoints (
+    key        TEXT PRIMARY KEY,
+    value      TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE inputs DROP COLUMN IF EXISTS last_error_at;
+ALTER TABLE inputs DROP COLUMN IF EXISTS last_error;
@@ -0,0 +1,2 @@
+ALTER TABLE inputs ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE inputs ADD COLUMN IF NOT EXISTS last_error_at TIMESTAMPTZ;
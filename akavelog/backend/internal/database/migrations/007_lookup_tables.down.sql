@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS lookup_tables;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'lookup_table_kind') THEN
+        DROP TYPE lookup_table_kind;
+    END IF;
+END$$;
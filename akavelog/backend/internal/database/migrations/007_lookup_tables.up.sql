@@ -0,0 +1,19 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'lookup_table_kind') THEN
+        CREATE TYPE lookup_table_kind AS ENUM ('csv', 'postgres');
+    END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS lookup_tables (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    kind lookup_table_kind NOT NULL,
+    key_column TEXT NOT NULL,
+    csv_data TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL DEFAULT '',
+    ttl_seconds INTEGER NOT NULL DEFAULT 300,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
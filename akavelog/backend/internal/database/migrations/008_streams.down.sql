@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS streams;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'stream_match_type') THEN
+        DROP TYPE stream_match_type;
+    END IF;
+END$$;
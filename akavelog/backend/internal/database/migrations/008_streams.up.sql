@@ -0,0 +1,20 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'stream_match_type') THEN
+        CREATE TYPE stream_match_type AS ENUM ('all', 'any');
+    END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS streams (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    match_type stream_match_type NOT NULL DEFAULT 'all',
+    rules JSONB NOT NULL DEFAULT '[]',
+    retention_days INTEGER NOT NULL DEFAULT 0,
+    o3_prefix TEXT NOT NULL DEFAULT '',
+    outputs JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS retention_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id TEXT NOT NULL DEFAULT '',
+    stream_id UUID REFERENCES streams(id) ON DELETE CASCADE,
+    days INTEGER NOT NULL,
+    action TEXT NOT NULL DEFAULT 'delete',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One policy per project (or '' for every project) and stream (or NULL for logs/).
+CREATE UNIQUE INDEX IF NOT EXISTS retention_policies_scope
+    ON retention_policies (project_id, COALESCE(stream_id, '00000000-0000-0000-0000-000000000000'));
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS batches (
+    key TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    min_timestamp TIMESTAMPTZ,
+    max_timestamp TIMESTAMPTZ,
+    entry_count INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL DEFAULT '',
+    codec TEXT NOT NULL,
+    uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Time-range lookups are per project; objects overlap [start, end) when
+-- min_timestamp < end AND max_timestamp >= start.
+CREATE INDEX IF NOT EXISTS batches_project_time ON batches (project_id, min_timestamp, max_timestamp);
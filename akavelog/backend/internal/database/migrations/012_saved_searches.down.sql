@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS query_history;
+DROP TABLE IF EXISTS saved_searches;
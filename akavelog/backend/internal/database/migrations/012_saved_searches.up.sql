@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- '' until requests are authenticated; then the user who saved the search.
+    owner TEXT NOT NULL DEFAULT '',
+    shared BOOLEAN NOT NULL DEFAULT TRUE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    project_id TEXT NOT NULL DEFAULT '',
+    time_range TEXT NOT NULL DEFAULT '24h',
+    params JSONB NOT NULL DEFAULT '{}',
+    run_count BIGINT NOT NULL DEFAULT 0,
+    last_run_at TIMESTAMPTZ,
+    last_run_ms BIGINT NOT NULL DEFAULT 0,
+    last_results INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (owner, name)
+);
+
+-- Every run of POST /query, /logs/aggregate and /logs/sql, saved or not.
+CREATE TABLE IF NOT EXISTS query_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    saved_search_id UUID REFERENCES saved_searches(id) ON DELETE SET NULL,
+    owner TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    query TEXT NOT NULL,
+    project_id TEXT NOT NULL DEFAULT '',
+    range_start TIMESTAMPTZ,
+    range_end TIMESTAMPTZ,
+    duration_ms BIGINT NOT NULL,
+    results INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS query_history_created ON query_history (created_at DESC);
+CREATE INDEX IF NOT EXISTS query_history_saved_search ON query_history (saved_search_id, created_at DESC);
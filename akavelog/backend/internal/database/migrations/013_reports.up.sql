@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    -- The aggregation the report runs; deleting it deletes the report.
+    saved_search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    schedule TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    formats JSONB NOT NULL DEFAULT '["json"]',
+    -- Names of outputs told about each run.
+    outputs JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- NULL while disabled.
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_status TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS reports_next_run ON reports (next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS report_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id UUID NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    -- FALSE for runs started with POST /reports/:id/run.
+    scheduled BOOLEAN NOT NULL,
+    status TEXT NOT NULL,
+    range_start TIMESTAMPTZ NOT NULL,
+    range_end TIMESTAMPTZ NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    -- Format to O3 key of each rendering stored.
+    objects JSONB NOT NULL DEFAULT '{}',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS report_runs_report ON report_runs (report_id, created_at DESC);
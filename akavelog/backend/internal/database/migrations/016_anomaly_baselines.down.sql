@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS anomaly_cursor;
+DROP TABLE IF EXISTS anomaly_baselines;
@@ -0,0 +1,16 @@
+-- Baselines the anomaly analyzer learned, one row per service and project ('' for none).
+CREATE TABLE IF NOT EXISTS anomaly_baselines (
+    project_id TEXT NOT NULL DEFAULT '',
+    service TEXT NOT NULL,
+    volume JSONB NOT NULL DEFAULT '{}',
+    error_rate JSONB NOT NULL DEFAULT '{}',
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (project_id, service)
+);
+
+-- End of the last bucket analyzed; servers claim the next bucket by moving it.
+CREATE TABLE IF NOT EXISTS anomaly_cursor (
+    id INT PRIMARY KEY CHECK (id = 1),
+    bucket_end TIMESTAMPTZ NOT NULL
+);
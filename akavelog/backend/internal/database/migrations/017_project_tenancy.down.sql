@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_pipelines_project_id;
+DROP INDEX IF EXISTS idx_inputs_project_id;
+ALTER TABLE pipelines DROP COLUMN IF EXISTS project_id;
+ALTER TABLE inputs DROP COLUMN IF EXISTS project_id;
+ALTER TABLE projects DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE projects DROP COLUMN IF EXISTS description;
@@ -0,0 +1,10 @@
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- An input's project is stamped on every entry it receives; a pipeline's project limits it
+-- to that project's entries. Projects in use cannot be deleted.
+ALTER TABLE inputs ADD COLUMN IF NOT EXISTS project_id UUID REFERENCES projects(id);
+ALTER TABLE pipelines ADD COLUMN IF NOT EXISTS project_id UUID REFERENCES projects(id);
+
+CREATE INDEX IF NOT EXISTS idx_inputs_project_id ON inputs(project_id);
+CREATE INDEX IF NOT EXISTS idx_pipelines_project_id ON pipelines(project_id);
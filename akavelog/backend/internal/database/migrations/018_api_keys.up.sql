@@ -0,0 +1,16 @@
+-- Keys of the management API. Only the SHA-256 of a key is stored; prefix identifies it in
+-- listings. A key bound to a project may only name that project.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    project_id UUID REFERENCES projects(id),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_project_id ON api_keys(project_id);
@@ -0,0 +1,12 @@
+-- Ingest keys of inputs, required by their listeners. Only the SHA-256 of a key is stored.
+-- A rotated-out key keeps working until expires_at; the current key has none.
+CREATE TABLE IF NOT EXISTS input_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    input_id UUID NOT NULL REFERENCES inputs(id) ON DELETE CASCADE,
+    prefix TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_input_keys_input_id ON input_keys(input_id);
@@ -0,0 +1,2 @@
+ALTER TABLE inputs DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE inputs DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- version counts the changes to an input's definition: PUT and PATCH /inputs/:id must name
+-- the version they were based on, so two edits of the same input cannot clobber each other.
+ALTER TABLE inputs ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE inputs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
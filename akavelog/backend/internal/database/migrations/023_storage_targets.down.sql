@@ -0,0 +1,3 @@
+ALTER TABLE batches DROP COLUMN IF EXISTS target;
+ALTER TABLE projects DROP COLUMN IF EXISTS o3_target;
+ALTER TABLE streams DROP COLUMN IF EXISTS o3_target;
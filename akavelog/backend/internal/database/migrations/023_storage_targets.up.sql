@@ -0,0 +1,6 @@
+-- o3_target names the storage target (storage.targets in the config) a stream's or project's
+-- batches are uploaded to, apart from the others; '' is storage.o3. batches.target records
+-- where each object is, so it is read back from there.
+ALTER TABLE streams ADD COLUMN IF NOT EXISTS o3_target TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS o3_target TEXT NOT NULL DEFAULT '';
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS target TEXT NOT NULL DEFAULT '';
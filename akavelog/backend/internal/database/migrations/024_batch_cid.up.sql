@@ -0,0 +1,3 @@
+-- cid is the root CID a content-addressed backend (storage.backend: akave) returned for the
+-- object, so GET /uploads/verify can check it against the network; '' on the others.
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS cid TEXT NOT NULL DEFAULT '';
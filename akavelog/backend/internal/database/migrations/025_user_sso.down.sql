@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_sso_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS sso_subject;
+ALTER TABLE users DROP COLUMN IF EXISTS sso_issuer;
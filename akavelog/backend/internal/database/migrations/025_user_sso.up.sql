@@ -0,0 +1,6 @@
+-- sso_issuer and sso_subject identify the identity provider account a user was created for
+-- by single sign-on; '' for users created with a password. SSO logins find their user by
+-- them, and only such users get their role from the provider's groups.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sso_issuer TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sso_subject TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS users_sso_idx ON users (sso_issuer, sso_subject) WHERE sso_subject <> '';
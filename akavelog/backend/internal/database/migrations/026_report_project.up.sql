@@ -0,0 +1,4 @@
+-- project_id is the project of the report's saved search, '' for none, so that keys bound to
+-- a project only see that project's reports.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS project_id TEXT NOT NULL DEFAULT '';
+UPDATE reports r SET project_id = s.project_id FROM saved_searches s WHERE s.id = r.saved_search_id;
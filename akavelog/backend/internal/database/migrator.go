@@ -2,64 +2,363 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/akave-ai/akavelog/internal/config"
 	"github.com/jackc/pgx/v5"
-	tern "github.com/jackc/tern/v2/migrate"
 	"github.com/rs/zerolog"
 )
 
+// Migrations are versioned pairs of files under migrations/: NNN_name.up.sql applies version
+// NNN and NNN_name.down.sql rolls it back. Versions run from 1 without gaps. Applied versions
+// are recorded in schema_migrations with the SHA-256 of their up file, so a migration edited
+// after it was applied is refused instead of silently diverging.
+//
 //go:embed migrations/*.sql
-var migrations embed.FS
+var migrationFiles embed.FS
 
-func Migrate(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) error {
+// migrationLock is the key of the advisory lock held while migrating, so that two processes
+// starting at once do not apply the same migration twice.
+const migrationLock int64 = 0x616b6176656c6f67 // "akavelog"
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migration is one version of the schema.
+type migration struct {
+	version  int32
+	name     string
+	up       string
+	down     string
+	checksum string // hex SHA-256 of up
+}
+
+// loadMigrations reads the migration pairs of fsys, ordered by version.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := make(map[int32]*migration)
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNN_name.up.sql or NNN_name.down.sql", e.Name())
+		}
+		v, err := strconv.ParseInt(m[1], 10, 32)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", e.Name())
+		}
+		sql, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+		mig := byVersion[int32(v)]
+		if mig == nil {
+			mig = &migration{version: int32(v), name: m[2]}
+			byVersion[int32(v)] = mig
+		}
+		if mig.name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", v, mig.name, m[2])
+		}
+		if m[3] == "up" {
+			sum := sha256.Sum256(sql)
+			mig.up, mig.checksum = string(sql), hex.EncodeToString(sum[:])
+		} else {
+			mig.down = string(sql)
+		}
+	}
+	list := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].version < list[b].version })
+	for i, mig := range list {
+		switch {
+		case mig.version != int32(i+1):
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		case mig.checksum == "":
+			return nil, fmt.Errorf("migration %d (%s) has no up file", mig.version, mig.name)
+		case mig.down == "":
+			return nil, fmt.Errorf("migration %d (%s) has no down file", mig.version, mig.name)
+		}
+	}
+	return list, nil
+}
+
+// embeddedMigrations returns the migrations built into the binary.
+func embeddedMigrations() ([]migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving database migrations subtree: %w", err)
+	}
+	return loadMigrations(sub)
+}
+
+// DSN returns the connection string of cfg.Database.
+func DSN(cfg *config.Config) string {
 	hostPort := net.JoinHostPort(cfg.Database.Host, strconv.Itoa(cfg.Database.Port))
 
 	// URL-encode the password
 	encodedPassword := url.QueryEscape(cfg.Database.Password)
-	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
 		cfg.Database.User,
 		encodedPassword,
 		hostPort,
 		cfg.Database.Name,
 		cfg.Database.SSLMode,
 	)
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	version   int32
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// migrator applies the embedded migrations on one connection.
+type migrator struct {
+	conn       *pgx.Conn
+	migrations []migration
+}
+
+// newMigrator creates schema_migrations when it does not exist and loads the embedded
+// migrations. A database migrated by tern, which recorded only a version in schema_version,
+// has its versions recorded in schema_migrations with the checksums of this build.
+func newMigrator(ctx context.Context, conn *pgx.Conn) (*migrator, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	m := &migrator{conn: conn, migrations: migrations}
+	if err := m.adoptTern(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// adoptTern records the versions of a schema_version table left by tern in an empty
+// schema_migrations.
+func (m *migrator) adoptTern(ctx context.Context) error {
+	var empty, hasTern bool
+	err := m.conn.QueryRow(ctx, `SELECT NOT EXISTS (SELECT 1 FROM schema_migrations), to_regclass('schema_version') IS NOT NULL`).Scan(&empty, &hasTern)
+	if err != nil {
+		return fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !empty || !hasTern {
+		return nil
+	}
+	var version int32
+	if err := m.conn.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("read schema_version: %w", err)
+	}
+	if int(version) > len(m.migrations) {
+		return fmt.Errorf("schema version %d is newer than this build's %d", version, len(m.migrations))
+	}
+	return pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		for _, mig := range m.migrations[:version] {
+			if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				mig.version, mig.name, mig.checksum); err != nil {
+				return fmt.Errorf("record migration %d: %w", mig.version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// applied returns the rows of schema_migrations, ordered by version.
+func (m *migrator) applied(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	var out []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Migration is one of the embedded migrations, or a version recorded in the database that
+// this build does not know, as reported by Status.
+type Migration struct {
+	Version   int32      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	State     string     `json:"state"`    // MigrationApplied, MigrationPending, MigrationModified or MigrationUnknown
+	Checksum  string     `json:"checksum"` // of the embedded up file; the recorded one for unknown versions
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// States of a Migration.
+const (
+	MigrationApplied  = "applied"
+	MigrationPending  = "pending"
+	MigrationModified = "modified" // applied, but its up file has changed since
+	MigrationUnknown  = "unknown"  // applied by a newer build
+)
+
+// migrationStatus merges the embedded migrations with the applied ones. current is the
+// highest applied version.
+func migrationStatus(migrations []migration, applied []appliedMigration) (current int32, list []Migration) {
+	byVersion := make(map[int32]appliedMigration, len(applied))
+	for _, a := range applied {
+		byVersion[a.version] = a
+		current = max(current, a.version)
+	}
+	list = make([]Migration, 0, max(len(migrations), len(applied)))
+	for _, mig := range migrations {
+		st := Migration{Version: mig.version, Name: mig.name, State: MigrationPending, Checksum: mig.checksum}
+		if a, ok := byVersion[mig.version]; ok {
+			st.Applied, st.State, st.AppliedAt = true, MigrationApplied, &a.appliedAt
+			if a.checksum != mig.checksum {
+				st.State = MigrationModified
+			}
+			delete(byVersion, mig.version)
+		}
+		list = append(list, st)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.version]; ok {
+			list = append(list, Migration{Version: a.version, Name: a.name, Applied: true, State: MigrationUnknown, Checksum: a.checksum, AppliedAt: &a.appliedAt})
+		}
+	}
+	return current, list
+}
+
+// verify returns an error when an applied migration was modified or is unknown to this build.
+func verify(list []Migration) error {
+	for _, st := range list {
+		switch st.State {
+		case MigrationModified:
+			return fmt.Errorf("migration %d (%s) was changed after it was applied: checksum mismatch", st.Version, st.Name)
+		case MigrationUnknown:
+			return fmt.Errorf("migration %d (%s) was applied by a newer build", st.Version, st.Name)
+		}
+	}
+	return nil
+}
+
+// Migrate applies the migrations the database is missing.
+func Migrate(ctx context.Context, logger *zerolog.Logger, cfg *config.Config) error {
+	return MigrateTo(ctx, logger, cfg, -1)
+}
 
-	conn, err := pgx.Connect(ctx, dsn)
+// MigrateTo migrates the schema up or down to version; 0 rolls back every migration and -1
+// means the latest. Each migration runs in its own transaction, so a failed one leaves the
+// schema at the version before it. Applied migrations are verified against their checksums
+// first.
+func MigrateTo(ctx context.Context, logger *zerolog.Logger, cfg *config.Config, version int32) error {
+	conn, err := pgx.Connect(ctx, DSN(cfg))
 	if err != nil {
 		return err
 	}
 	defer conn.Close(ctx)
 
-	m, err := tern.NewMigrator(ctx, conn, "schema_version")
-	if err != nil {
-		return fmt.Errorf("constructing database migrator: %w", err)
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
 	}
-	subtree, err := fs.Sub(migrations, "migrations")
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	m, err := newMigrator(ctx, conn)
 	if err != nil {
-		return fmt.Errorf("retrieving database migrations subtree: %w", err)
+		return err
+	}
+	latest := int32(len(m.migrations))
+	if version < 0 {
+		version = latest
 	}
-	if err := m.LoadMigrations(subtree); err != nil {
-		return fmt.Errorf("loading database migrations: %w", err)
+	if version > latest {
+		return fmt.Errorf("no migration %d (latest is %d)", version, latest)
 	}
-	from, err := m.GetCurrentVersion(ctx)
+	applied, err := m.applied(ctx)
 	if err != nil {
-		return fmt.Errorf("retreiving current database migration version")
+		return err
 	}
-	if err := m.Migrate(ctx); err != nil {
+	from, list := migrationStatus(m.migrations, applied)
+	if err := verify(list); err != nil {
 		return err
 	}
-	if from == int32(len(m.Migrations)) {
-		logger.Info().Msgf("database schema up to date, version %d", len(m.Migrations))
+	for v := from + 1; v <= version; v++ {
+		mig := m.migrations[v-1]
+		if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, mig.version, mig.name, mig.checksum)
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %d (%s) up: %w", mig.version, mig.name, err)
+		}
+		logger.Info().Msgf("applied migration %d (%s)", mig.version, mig.name)
+	}
+	for v := from; v > version; v-- {
+		mig := m.migrations[v-1]
+		if err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.version)
+			return err
+		}); err != nil {
+			return fmt.Errorf("migration %d (%s) down: %w", mig.version, mig.name, err)
+		}
+		logger.Info().Msgf("rolled back migration %d (%s)", mig.version, mig.name)
+	}
+	if from == version {
+		logger.Info().Msgf("database schema up to date, version %d", version)
 	} else {
-		logger.Info().Msgf("migrated database schema, from %d to %d", from, len(m.Migrations))
+		logger.Info().Msgf("migrated database schema, from %d to %d", from, version)
 	}
 
 	return nil
 }
+
+// Status returns the version of the database schema and the state of each migration: the
+// embedded ones, then any applied version this build does not know.
+func Status(ctx context.Context, cfg *config.Config) (int32, []Migration, error) {
+	conn, err := pgx.Connect(ctx, DSN(cfg))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close(ctx)
+
+	m, err := newMigrator(ctx, conn)
+	if err != nil {
+		return 0, nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	current, list := migrationStatus(m.migrations, applied)
+	return current, list, nil
+}
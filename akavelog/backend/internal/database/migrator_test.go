@@ -0,0 +1,98 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := embeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) < 21 {
+		t.Fatalf("%d migrations", len(list))
+	}
+	tables := map[string]bool{}
+	for _, mig := range list {
+		if strings.TrimSpace(mig.up) == "" || strings.TrimSpace(mig.down) == "" || len(mig.checksum) != 64 {
+			t.Errorf("migration %d (%s) is incomplete", mig.version, mig.name)
+		}
+		if strings.Contains(mig.up, "drop below") {
+			t.Errorf("migration %d (%s) still has a tern separator", mig.version, mig.name)
+		}
+		for _, table := range []string{"batches", "streams", "pipelines", "alerts", "api_keys", "users", "audit_log"} {
+			if strings.Contains(mig.up, "CREATE TABLE IF NOT EXISTS "+table+" ") {
+				tables[table] = true
+			}
+		}
+	}
+	if len(tables) != 7 {
+		t.Errorf("tables created: %v", tables)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	list, err := loadMigrations(fstest.MapFS{
+		"002_b.up.sql":   file("CREATE TABLE b ();"),
+		"002_b.down.sql": file("DROP TABLE b;"),
+		"001_a.up.sql":   file("CREATE TABLE a ();"),
+		"001_a.down.sql": file("DROP TABLE a;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].name != "a" || list[1].version != 2 || list[1].down != "DROP TABLE b;" {
+		t.Errorf("loaded %+v", list)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"gap":       {"001_a.up.sql": file("x"), "001_a.down.sql": file("x"), "003_c.up.sql": file("x"), "003_c.down.sql": file("x")},
+		"no down":   {"001_a.up.sql": file("x")},
+		"no up":     {"001_a.down.sql": file("x")},
+		"bad name":  {"001_a.sql": file("x")},
+		"two names": {"001_a.up.sql": file("x"), "001_b.down.sql": file("x")},
+		"version 0": {"000_a.up.sql": file("x"), "000_a.down.sql": file("x")},
+	} {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	migrations := []migration{
+		{version: 1, name: "a", checksum: "sum-a"},
+		{version: 2, name: "b", checksum: "sum-b"},
+		{version: 3, name: "c", checksum: "sum-c"},
+	}
+	now := time.Now()
+	current, list := migrationStatus(migrations, []appliedMigration{
+		{version: 1, name: "a", checksum: "sum-a", appliedAt: now},
+		{version: 2, name: "b", checksum: "sum-b", appliedAt: now},
+	})
+	if current != 2 || len(list) != 3 || list[1].State != MigrationApplied || list[2].State != MigrationPending || list[2].Applied {
+		t.Errorf("status %d %+v", current, list)
+	}
+	if err := verify(list); err != nil {
+		t.Error(err)
+	}
+
+	current, list = migrationStatus(migrations, []appliedMigration{
+		{version: 1, name: "a", checksum: "edited", appliedAt: now},
+	})
+	if current != 1 || list[0].State != MigrationModified || verify(list) == nil {
+		t.Errorf("modified: %d %+v", current, list)
+	}
+
+	current, list = migrationStatus(migrations[:1], []appliedMigration{
+		{version: 1, name: "a", checksum: "sum-a", appliedAt: now},
+		{version: 2, name: "b", checksum: "sum-b", appliedAt: now},
+	})
+	if current != 2 || len(list) != 2 || list[1].State != MigrationUnknown || verify(list) == nil {
+		t.Errorf("unknown: %d %+v", current, list)
+	}
+}
@@ -0,0 +1,241 @@
+// Package deadletter keeps payloads that could not be stored — undecodable JSON, entries that
+// fail normalization and entries a pipeline processor failed on — in O3 under deadletter/,
+// with the reason, so they can be inspected and replayed once the cause is fixed.
+package deadletter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Prefix is the O3 prefix dead-letter objects are written under.
+const Prefix = "deadletter"
+
+// Stages a payload can fail at.
+const (
+	StageDecode    = "decode"    // not a JSON object
+	StageNormalize = "normalize" // missing service or message, bad field types
+	StagePipeline  = "pipeline"  // a pipeline processor returned an error
+)
+
+const (
+	// QueueSize is how many records wait for upload; further records are dropped and counted.
+	QueueSize = 10000
+	// BatchSize is the most records written to one object.
+	BatchSize = 500
+	// FlushInterval is how long records wait for a batch to fill.
+	FlushInterval = 10 * time.Second
+
+	putTimeout = 30 * time.Second
+)
+
+// ErrNotFound is returned for keys that do not name a dead-letter object.
+var ErrNotFound = errors.New("dead-letter object not found")
+
+// keyPattern matches the keys the API accepts: the object name without prefix and extension.
+var keyPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z-[0-9a-f-]{36}$`)
+
+// Record is one dead-lettered payload.
+type Record struct {
+	ReceivedAt time.Time `json:"received_at"`
+	InputID    string    `json:"input_id,omitempty"` // empty for entries not read by an input
+	Stage      string    `json:"stage"`
+	Reason     string    `json:"reason"`
+	Payload    string    `json:"payload"` // the payload as the input delivered it
+}
+
+// Object describes one stored batch of records.
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Store is the part of storage.O3Client the queue uses.
+type Store interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// Queue batches records and writes each batch as one gzipped JSON array. Add never blocks.
+type Queue struct {
+	store   Store
+	records chan Record
+	stop    chan struct{}
+	done    chan struct{}
+
+	written atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewQueue starts a queue writing to store.
+func NewQueue(store Store) *Queue {
+	q := &Queue{store: store, records: make(chan Record, QueueSize), stop: make(chan struct{}), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+// Add queues payload with the stage and reason it failed at. inputID may be uuid.Nil.
+func (q *Queue) Add(payload []byte, inputID uuid.UUID, stage, reason string) {
+	r := Record{ReceivedAt: time.Now().UTC(), Stage: stage, Reason: reason, Payload: string(payload)}
+	if inputID != uuid.Nil {
+		r.InputID = inputID.String()
+	}
+	select {
+	case q.records <- r:
+	default:
+		q.dropped.Add(1)
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	t := time.NewTicker(FlushInterval)
+	defer t.Stop()
+	batch := make([]Record, 0, BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			q.write(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case r := <-q.records:
+			batch = append(batch, r)
+			if len(batch) >= BatchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-q.stop:
+			for {
+				select {
+				case r := <-q.records:
+					batch = append(batch, r)
+					if len(batch) >= BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *Queue) write(batch []Record) {
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		log.Printf("[deadletter] marshal: %v", err)
+		return
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(payload)
+	if err := w.Close(); err != nil {
+		log.Printf("[deadletter] gzip: %v", err)
+		return
+	}
+	key := time.Now().UTC().Format("20060102T150405Z") + "-" + uuid.New().String()
+	ctx, cancel := context.WithTimeout(context.Background(), putTimeout)
+	defer cancel()
+	if err := q.store.PutObject(ctx, objectKey(key), buf.Bytes(), "application/gzip"); err != nil {
+		q.dropped.Add(int64(len(batch)))
+		log.Printf("[deadletter] write %d records: %v", len(batch), err)
+		return
+	}
+	q.written.Add(int64(len(batch)))
+	log.Printf("[deadletter] wrote %d records to %s", len(batch), objectKey(key))
+}
+
+// Counts returns how many records were written and dropped (queue full or write failed).
+func (q *Queue) Counts() (written, dropped int64) {
+	return q.written.Load(), q.dropped.Load()
+}
+
+// List returns the stored objects, newest first.
+func (q *Queue) List(ctx context.Context) ([]Object, error) {
+	infos, err := q.store.ListObjects(ctx, Prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Object, 0, len(infos))
+	for _, info := range infos {
+		key := strings.TrimSuffix(strings.TrimPrefix(info.Key, Prefix+"/"), ".json.gz")
+		if !keyPattern.MatchString(key) {
+			continue
+		}
+		out = append(out, Object{Key: key, Size: info.Size, LastModified: info.LastModified})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key > out[j].Key })
+	return out, nil
+}
+
+// Get returns the records of the object key. It returns ErrNotFound when there is no such object.
+func (q *Queue) Get(ctx context.Context, key string) ([]Record, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+	data, err := q.store.GetObject(ctx, objectKey(key))
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", key, err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", key, err)
+	}
+	var records []Record
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, fmt.Errorf("object %s: %w", key, err)
+	}
+	return records, nil
+}
+
+// Delete removes the object key.
+func (q *Queue) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+	return q.store.DeleteObject(ctx, objectKey(key))
+}
+
+// Stop writes the queued records and stops the queue.
+func (q *Queue) Stop() {
+	close(q.stop)
+	<-q.done
+}
+
+// ValidKey reports whether key has the form of a dead-letter object key.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+func objectKey(key string) string {
+	return path.Join(Prefix, key+".json.gz")
+}
@@ -0,0 +1,91 @@
+package deadletter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memStore) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (s *memStore) ListObjects(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []storage.ObjectInfo
+	for key, data := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, storage.ObjectInfo{Key: key, Size: int64(len(data)), LastModified: time.Now()})
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) DeleteObject(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func TestQueueWritesAndReadsRecords(t *testing.T) {
+	store := &memStore{objects: map[string][]byte{"deadletter/README": nil, "logs/x.json.gz": nil}}
+	q := NewQueue(store)
+	input := uuid.New()
+	q.Add([]byte(`not json`), input, StageDecode, "invalid json")
+	q.Add([]byte(`{"service":"api"}`), uuid.Nil, StageNormalize, "missing required field: message")
+	q.Stop()
+
+	ctx := context.Background()
+	objects, err := q.List(ctx)
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("List = %v, %v", objects, err)
+	}
+	records, err := q.Get(ctx, objects[0].Key)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Get = %v, %v", records, err)
+	}
+	if r := records[0]; r.InputID != input.String() || r.Stage != StageDecode || r.Payload != "not json" || r.Reason != "invalid json" {
+		t.Errorf("record = %+v", r)
+	}
+	if records[1].InputID != "" {
+		t.Errorf("input id = %q, want empty", records[1].InputID)
+	}
+	if written, dropped := q.Counts(); written != 2 || dropped != 0 {
+		t.Errorf("counts = %d, %d", written, dropped)
+	}
+
+	if err := q.Delete(ctx, objects[0].Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Get(ctx, objects[0].Key); err != ErrNotFound {
+		t.Errorf("Get after delete: %v", err)
+	}
+	if _, err := q.Get(ctx, "../logs/x"); err != ErrNotFound {
+		t.Errorf("Get with bad key: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Package events records what happens to akavelog itself – an input crashed, a flush failed,
+// retention deleted objects, an alert fired – for GET /events, and POSTs each event to the
+// webhooks subscribed to its type, so external automation can react.
+package events
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of the events akavelog publishes.
+const (
+	InputCrashed     = "input.crashed"     // a running input turned unhealthy
+	InputRecovered   = "input.recovered"   // an unhealthy input is healthy again
+	InputFailed      = "input.failed"      // an input could not be started
+	FlushFailed      = "flush.failed"      // a batch object could not be uploaded; it is retried
+	RetentionApplied = "retention.applied" // a retention run deleted or archived objects, or failed to
+	AlertFired       = "alert.fired"
+	AlertResolved    = "alert.resolved"
+)
+
+// Types lists every event type, for validating subscriptions.
+var Types = []string{InputCrashed, InputRecovered, InputFailed, FlushFailed, RetentionApplied, AlertFired, AlertResolved}
+
+// Severities of events.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// DefaultRecent is how many events a Bus keeps unless Config.Recent is set.
+const DefaultRecent = 1000
+
+// Event is one thing that happened.
+type Event struct {
+	ID       uint64         `json:"id"`   // increasing from 1 per process; GET /events?after= pages by it
+	Type     string         `json:"type"` // e.g. input.crashed
+	Severity string         `json:"severity"`
+	Message  string         `json:"message"`
+	Subject  string         `json:"subject,omitempty"` // what it is about, e.g. the title of an input
+	Data     map[string]any `json:"data,omitempty"`    // details of the type, e.g. the error
+	At       time.Time      `json:"at"`
+}
+
+// Config configures a Bus.
+type Config struct {
+	Recent   int       // events kept for Recent (default 1000)
+	Webhooks []Webhook // subscribers
+}
+
+// Filter selects events of Recent.
+type Filter struct {
+	Types []string  // patterns as in Webhook.Types; empty for every type
+	After uint64    // only events with a greater ID
+	Since time.Time // only events at or after it
+	Limit int       // at most this many; with After the oldest, else the newest
+}
+
+// Bus keeps the latest events in memory and hands each to the webhooks subscribed to its type.
+// Publishing never blocks. A nil *Bus drops what is published, so publishers need no check.
+type Bus struct {
+	mu     sync.Mutex // guards everything below; Configure and Close also hold confMu
+	ring   []Event    // the latest events, oldest at ring[start]
+	start  int
+	n      int
+	nextID uint64
+	hooks  []*hook
+
+	confMu  sync.Mutex
+	closed  bool
+	backoff time.Duration // first wait between retries; shortened by tests
+}
+
+// NewBus returns a Bus configured with cfg, or an error when a webhook is invalid.
+func NewBus(cfg Config) (*Bus, error) {
+	b := &Bus{backoff: minBackoff}
+	if err := b.Configure(cfg); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Configure resizes the events kept to cfg.Recent and replaces the webhooks with those of cfg.
+// Replaced webhooks are stopped after they tried what is queued. On an invalid webhook nothing
+// changes.
+func (b *Bus) Configure(cfg Config) error {
+	hooks := make([]*hook, 0, len(cfg.Webhooks))
+	for i, w := range cfg.Webhooks {
+		h, err := newHook(w, b.backoff)
+		if err != nil {
+			return fmt.Errorf("webhook %d: %w", i, err)
+		}
+		hooks = append(hooks, h)
+	}
+	size := cfg.Recent
+	if size <= 0 {
+		size = DefaultRecent
+	}
+
+	b.confMu.Lock()
+	defer b.confMu.Unlock()
+	if b.closed {
+		return fmt.Errorf("events: bus is closed")
+	}
+	for _, h := range hooks {
+		go h.run()
+	}
+	b.mu.Lock()
+	if size != len(b.ring) {
+		ring := make([]Event, size)
+		keep := min(b.n, size)
+		for i := 0; i < keep; i++ {
+			ring[i] = b.ring[(b.start+b.n-keep+i)%len(b.ring)]
+		}
+		b.ring, b.start, b.n = ring, 0, keep
+	}
+	prev := b.hooks
+	b.hooks = hooks
+	b.mu.Unlock()
+	for _, h := range prev {
+		h.shutdown()
+	}
+	return nil
+}
+
+// Publish records ev, stamped with the next ID and, when unset, the current time, and queues
+// it on the webhooks subscribed to its type.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	if ev.Severity == "" {
+		ev.Severity = SeverityInfo
+	}
+	b.mu.Lock()
+	if len(b.ring) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.nextID++
+	ev.ID = b.nextID
+	if b.n < len(b.ring) {
+		b.ring[(b.start+b.n)%len(b.ring)] = ev
+		b.n++
+	} else {
+		b.ring[b.start] = ev
+		b.start = (b.start + 1) % len(b.ring)
+	}
+	hooks := b.hooks
+	b.mu.Unlock()
+	for _, h := range hooks {
+		if Match(h.types, ev.Type) {
+			h.enqueue(ev)
+		}
+	}
+}
+
+// Recent returns the kept events that pass f, oldest first.
+func (b *Bus) Recent(f Filter) []Event {
+	if b == nil {
+		return []Event{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []Event{}
+	for i := 0; i < b.n; i++ {
+		ev := b.ring[(b.start+i)%len(b.ring)]
+		if ev.ID <= f.After || ev.At.Before(f.Since) || !Match(f.Types, ev.Type) {
+			continue
+		}
+		out = append(out, ev)
+		if f.Limit > 0 && f.After > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// LastID returns the ID of the latest event published, or 0.
+func (b *Bus) LastID() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextID
+}
+
+// Webhooks returns the status of each webhook, in the order they were configured.
+func (b *Bus) Webhooks() []WebhookStatus {
+	if b == nil {
+		return []WebhookStatus{}
+	}
+	b.mu.Lock()
+	hooks := b.hooks
+	b.mu.Unlock()
+	out := make([]WebhookStatus, 0, len(hooks))
+	for _, h := range hooks {
+		out = append(out, h.status())
+	}
+	return out
+}
+
+// Close tries what the webhooks have queued once and stops them, for use on shutdown. Events
+// published afterwards are still kept for Recent.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.confMu.Lock()
+	defer b.confMu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	b.mu.Lock()
+	prev := b.hooks
+	b.hooks = nil
+	b.mu.Unlock()
+	for _, h := range prev {
+		h.shutdown()
+	}
+}
+
+// Match reports whether an event of type typ is selected by patterns: a type, a family such
+// as input.* or *. Empty patterns select every type.
+func Match(patterns []string, typ string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		switch {
+		case p == "*", p == typ:
+			return true
+		case strings.HasSuffix(p, ".*") && strings.HasPrefix(typ, p[:len(p)-1]):
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether p selects at least one known event type.
+func ValidPattern(p string) bool {
+	for _, t := range Types {
+		if Match([]string{p}, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// logf logs a message of the events package.
+func logf(format string, args ...any) {
+	log.Printf("[events] "+format, args...)
+}
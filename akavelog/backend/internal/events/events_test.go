@@ -0,0 +1,169 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecentKeepsTheLatest(t *testing.T) {
+	b, err := NewBus(Config{Recent: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []string{InputCrashed, FlushFailed, AlertFired, InputRecovered, FlushFailed} {
+		b.Publish(Event{Type: typ})
+	}
+	got := b.Recent(Filter{})
+	if len(got) != 3 || got[0].ID != 3 || got[2].ID != 5 {
+		t.Fatalf("Recent = %+v, want IDs 3..5", got)
+	}
+	if b.LastID() != 5 {
+		t.Errorf("LastID = %d, want 5", b.LastID())
+	}
+	if got[0].Severity != SeverityInfo || got[0].At.IsZero() {
+		t.Errorf("event not stamped: %+v", got[0])
+	}
+
+	if got := b.Recent(Filter{Types: []string{"flush.failed"}}); len(got) != 1 || got[0].ID != 5 {
+		t.Errorf("by type = %+v, want ID 5", got)
+	}
+	if got := b.Recent(Filter{Types: []string{"input.*"}}); len(got) != 1 || got[0].ID != 4 {
+		t.Errorf("by family = %+v, want ID 4", got)
+	}
+	if got := b.Recent(Filter{After: 3, Limit: 1}); len(got) != 1 || got[0].ID != 4 {
+		t.Errorf("after 3 = %+v, want ID 4", got)
+	}
+	if got := b.Recent(Filter{Limit: 1}); len(got) != 1 || got[0].ID != 5 {
+		t.Errorf("limit 1 = %+v, want the newest", got)
+	}
+
+	if err := b.Configure(Config{Recent: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Recent(Filter{}); len(got) != 2 || got[0].ID != 4 || got[1].ID != 5 {
+		t.Errorf("after shrinking = %+v, want IDs 4, 5", got)
+	}
+	b.Publish(Event{Type: AlertResolved})
+	if got := b.Recent(Filter{}); len(got) != 2 || got[1].ID != 6 {
+		t.Errorf("after publishing = %+v, want IDs 5, 6", got)
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(Event{Type: InputCrashed})
+	if got := b.Recent(Filter{}); len(got) != 0 {
+		t.Errorf("Recent = %v", got)
+	}
+	b.Close()
+}
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		patterns []string
+		typ      string
+		want     bool
+	}{
+		{nil, InputCrashed, true},
+		{[]string{"*"}, AlertFired, true},
+		{[]string{"input.*"}, InputFailed, true},
+		{[]string{"input.*"}, AlertFired, false},
+		{[]string{"alert.fired", "flush.failed"}, FlushFailed, true},
+		{[]string{"alert.fired"}, AlertResolved, false},
+	} {
+		if got := Match(c.patterns, c.typ); got != c.want {
+			t.Errorf("Match(%v, %s) = %v, want %v", c.patterns, c.typ, got, c.want)
+		}
+	}
+}
+
+func TestInvalidWebhook(t *testing.T) {
+	for _, w := range []Webhook{
+		{URL: "ftp://example.com"},
+		{URL: "https://example.com", Types: []string{"input.exploded"}},
+	} {
+		if _, err := NewBus(Config{Webhooks: []Webhook{w}}); err == nil {
+			t.Errorf("NewBus(%+v) succeeded", w)
+		}
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var got []Event
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if sig := r.Header.Get(SignatureHeader); sig != Sign("s3cret", body) {
+			t.Errorf("signature = %q", sig)
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get(EventHeader) != ev.Type || r.Header.Get("Authorization") != "Bearer t" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		mu.Lock()
+		got = append(got, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	b := &Bus{backoff: time.Millisecond}
+	err := b.Configure(Config{Webhooks: []Webhook{{
+		Name: "ops", URL: srv.URL + "/hook?token=x", Types: []string{"input.*"},
+		Secret: "s3cret", Headers: map[string]string{"Authorization": "Bearer t"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Publish(Event{Type: InputCrashed, Subject: "edge-http", Severity: SeverityError})
+	b.Publish(Event{Type: AlertFired})
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Webhooks()[0].Sent == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.Close()
+
+	if len(got) != 1 || got[0].Type != InputCrashed || got[0].Subject != "edge-http" {
+		t.Fatalf("delivered %+v, want the input.crashed event", got)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("attempts = %d, want 2 (one retry)", n)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	b := &Bus{backoff: time.Millisecond}
+	if err := b.Configure(Config{Webhooks: []Webhook{{URL: srv.URL + "/secret-path"}}}); err != nil {
+		t.Fatal(err)
+	}
+	b.Publish(Event{Type: FlushFailed})
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Webhooks()[0].Failed == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	st := b.Webhooks()[0]
+	if st.Failed != 1 || st.Sent != 0 || st.LastError == "" {
+		t.Errorf("status = %+v, want one failure (400 is not retried)", st)
+	}
+	if st.URL != srv.URL {
+		t.Errorf("URL = %q, want the path redacted", st.URL)
+	}
+	b.Close()
+}
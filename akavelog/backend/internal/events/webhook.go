@@ -0,0 +1,263 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// QueueSize is how many events each webhook buffers; further ones are dropped and counted
+	// until it catches up.
+	QueueSize = 100
+	// DefaultMaxRetries is how often a failed delivery is retried unless MaxRetries is set.
+	DefaultMaxRetries = 3
+	// DefaultTimeout bounds one delivery unless Timeout is set.
+	DefaultTimeout = 10 * time.Second
+
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the body under the webhook's
+	// secret, when it has one.
+	SignatureHeader = "X-Akavelog-Signature"
+	// EventHeader holds the type of the event delivered.
+	EventHeader = "X-Akavelog-Event"
+	// DeliveryHeader holds the ID of the event delivered; retries send the same one.
+	DeliveryHeader = "X-Akavelog-Delivery"
+
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Webhook subscribes a URL to events: each event of its Types is POSTed to it as JSON.
+type Webhook struct {
+	Name       string            // shown in its status (default: the host of URL)
+	URL        string            // http or https
+	Types      []string          // event types, families such as input.* or *; empty for every type
+	Secret     string            // signs the body in X-Akavelog-Signature when set
+	Headers    map[string]string // extra request headers, e.g. an Authorization token
+	Timeout    time.Duration     // of one attempt (default 10s)
+	MaxRetries int               // retries of a failed delivery (default 3; negative for none)
+}
+
+// WebhookStatus is the runtime view of one webhook, as returned by GET /events/webhooks.
+type WebhookStatus struct {
+	Name        string     `json:"name"`
+	URL         string     `json:"url"` // scheme and host only; the path may hold a token
+	Types       []string   `json:"types"`
+	Queued      int        `json:"queued"`
+	Sent        int64      `json:"sent"`
+	Failed      int64      `json:"failed"`  // events given up on after their retries
+	Dropped     int64      `json:"dropped"` // events that found the queue full
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// hook delivers the events queued for one webhook, in order.
+type hook struct {
+	Webhook
+	types   []string
+	client  *http.Client
+	backoff time.Duration
+	queue   chan Event
+	stop    chan struct{}
+	done    chan struct{}
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	mu          sync.Mutex
+	lastSentAt  time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func newHook(w Webhook, backoff time.Duration) (*hook, error) {
+	u, err := url.Parse(w.URL)
+	if w.URL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url is required and must be an http or https URL")
+	}
+	for _, p := range w.Types {
+		if !ValidPattern(p) {
+			return nil, fmt.Errorf("unknown event type %q (want one of %s, a family such as input.* or *)", p, strings.Join(Types, ", "))
+		}
+	}
+	if w.Name == "" {
+		w.Name = u.Host
+	}
+	if w.Timeout <= 0 {
+		w.Timeout = DefaultTimeout
+	}
+	switch {
+	case w.MaxRetries == 0:
+		w.MaxRetries = DefaultMaxRetries
+	case w.MaxRetries < 0:
+		w.MaxRetries = 0
+	}
+	types := w.Types
+	if types == nil {
+		types = []string{}
+	}
+	return &hook{
+		Webhook: w,
+		types:   types,
+		client:  &http.Client{Timeout: w.Timeout},
+		backoff: backoff,
+		queue:   make(chan Event, QueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+func (h *hook) run() {
+	defer close(h.done)
+	for {
+		select {
+		case ev := <-h.queue:
+			h.deliver(ev, true)
+		case <-h.stop:
+			// What is queued gets one attempt each.
+			for {
+				select {
+				case ev := <-h.queue:
+					h.deliver(ev, false)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver POSTs ev, retrying failures that are not permanent with exponential backoff while
+// retry is set and the hook is not stopping.
+func (h *hook) deliver(ev Event, retry bool) {
+	body, err := json.Marshal(ev)
+	backoff := h.backoff
+	for attempt := 0; body != nil; attempt++ {
+		var permanent bool
+		permanent, err = h.post(ev, body)
+		if err == nil || permanent || !retry || attempt >= h.MaxRetries {
+			break
+		}
+		select {
+		case <-h.stop:
+			retry = false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.failed.Add(1)
+		h.lastError, h.lastErrorAt = err.Error(), now
+		logf("webhook %s: %s %d: %v", h.Name, ev.Type, ev.ID, err)
+		return
+	}
+	h.sent.Add(1)
+	h.lastSentAt = now
+}
+
+// post sends one attempt of ev. Responses other than 2xx are errors; those other than 408,
+// 429 and 5xx are permanent.
+func (h *hook) post(ev Event, body []byte) (permanent bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "akavelog")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(EventHeader, ev.Type)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(ev.ID, 10))
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, errors.New(redactErr(err.Error(), h.URL))
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("POST %s: %s", redactURL(h.URL), resp.Status)
+	if s := strings.TrimSpace(string(msg)); s != "" {
+		err = fmt.Errorf("%w: %s", err, s)
+	}
+	retryable := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return !retryable, err
+}
+
+// Sign returns the X-Akavelog-Signature of body under secret: "sha256=" and the hex HMAC.
+// Receivers recompute it over the raw body and compare in constant time.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *hook) enqueue(ev Event) {
+	select {
+	case h.queue <- ev:
+	default:
+		h.dropped.Add(1)
+		logf("webhook %s: queue full, dropped %s %d", h.Name, ev.Type, ev.ID)
+	}
+}
+
+func (h *hook) status() WebhookStatus {
+	st := WebhookStatus{Name: h.Name, URL: redactURL(h.URL), Types: h.types, Queued: len(h.queue),
+		Sent: h.sent.Load(), Failed: h.failed.Load(), Dropped: h.dropped.Load()}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.lastSentAt.IsZero() {
+		t := h.lastSentAt
+		st.LastSentAt = &t
+	}
+	if h.lastError != "" {
+		t := h.lastErrorAt
+		st.LastError, st.LastErrorAt = h.lastError, &t
+	}
+	return st
+}
+
+// shutdown stops the hook after it has tried what is queued.
+func (h *hook) shutdown() {
+	close(h.stop)
+	<-h.done
+}
+
+// redactURL returns the scheme and host of a URL, whose path or query may hold a secret.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// redactErr replaces raw in msg, as net/http quotes the URL in its errors, by its scheme and host.
+func redactErr(msg, raw string) string {
+	return strings.ReplaceAll(msg, raw, redactURL(raw))
+}
@@ -0,0 +1,523 @@
+// Package export runs searches in the background and writes their results to O3 as CSV or
+// NDJSON objects under Prefix, to be downloaded with presigned URLs.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Prefix is where exports are written, as <Prefix>/<job id>.<format>.
+const Prefix = "exports"
+
+// ErrTooManyJobs is returned by Start when MaxRunning exports are running.
+var ErrTooManyJobs = errors.New("too many running exports")
+
+// Format is the encoding of an export.
+type Format string
+
+const (
+	// CSV writes a header row and a row per entry. Without fields, tags are one JSON column.
+	CSV Format = "csv"
+	// NDJSON writes an entry per line; with fields, an object of just those.
+	NDJSON Format = "ndjson"
+)
+
+// csvColumns are the CSV columns of an export without fields.
+var csvColumns = []string{"timestamp", "project_id", "service", "level", "message", "tags"}
+
+// ParseFormat returns the format named s; "" is CSV.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return CSV, nil
+	case CSV, NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want csv or ndjson)", s)
+}
+
+func (f Format) contentType() string {
+	if f == NDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Store keeps the export objects (storage.O3Client).
+type Store interface {
+	PutObjectFile(ctx context.Context, key string, f *os.File, contentType string) error
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+	DeleteObject(ctx context.Context, key string) error
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+}
+
+// Config bounds the exports of a Manager. Zero fields take their defaults.
+type Config struct {
+	MaxRunning   int           // exports running at once (default 2)
+	MaxRows      int           // rows one export writes at most (default 1000000)
+	MaxObjects   int           // batch objects one export reads at most (default 10000)
+	MaxScanBytes int64         // stored size of the objects one export reads (default 10 GiB)
+	Timeout      time.Duration // an export is canceled after this long (default 30m)
+	URLExpiry    time.Duration // download URLs are valid this long (default 1h)
+	TTL          time.Duration // exports are deleted this long after they finish (default 24h)
+}
+
+func (c *Config) defaults() {
+	if c.MaxRunning <= 0 {
+		c.MaxRunning = 2
+	}
+	if c.MaxRows <= 0 {
+		c.MaxRows = 1000000
+	}
+	if c.MaxObjects <= 0 {
+		c.MaxObjects = 10000
+	}
+	if c.MaxScanBytes <= 0 {
+		c.MaxScanBytes = 10 << 30
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Minute
+	}
+	if c.URLExpiry <= 0 {
+		c.URLExpiry = time.Hour
+	}
+	if c.TTL <= 0 {
+		c.TTL = 24 * time.Hour
+	}
+}
+
+// Request describes an export.
+type Request struct {
+	Query     *query.Query // nil exports every entry
+	ProjectID string
+	Start     time.Time
+	End       time.Time
+	Format    Format
+	Fields    []string // entry fields and tags to write; nil for whole entries
+	MaxRows   int      // at most Config.MaxRows; 0 for that
+}
+
+// Status is the state of a Job.
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Job is an export running or finished. Its fields are read with Manager.Snapshot.
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	req        Request
+	key        string
+	status     Status
+	err        error
+	rows       int
+	bytes      int64
+	truncated  bool // MaxRows was reached
+	progress   search.ScanStats
+	createdAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// ID returns the job's ID.
+func (j *Job) ID() string { return j.id }
+
+// ProjectID returns the project the job exports, or "" for all projects.
+func (j *Job) ProjectID() string { return j.req.ProjectID }
+
+// Done is closed when the job has finished.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Snapshot is the state of a Job at one point, with a download URL once it is done.
+type Snapshot struct {
+	ID           string           `json:"id"`
+	Status       Status           `json:"status"`
+	Format       Format           `json:"format"`
+	Query        string           `json:"query"`
+	ProjectID    string           `json:"project_id,omitempty"`
+	Start        time.Time        `json:"start"`
+	End          time.Time        `json:"end"`
+	Fields       []string         `json:"fields,omitempty"`
+	Rows         int              `json:"rows"`
+	Bytes        int64            `json:"bytes"`
+	Truncated    bool             `json:"truncated"`
+	Progress     search.ScanStats `json:"progress"`
+	Key          string           `json:"key,omitempty"`
+	DownloadURL  string           `json:"download_url,omitempty"`
+	URLExpiresAt *time.Time       `json:"url_expires_at,omitempty"`
+	ExpiresAt    *time.Time       `json:"expires_at,omitempty"` // when the export is deleted
+	Error        string           `json:"error,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	FinishedAt   *time.Time       `json:"finished_at,omitempty"`
+}
+
+// Manager runs exports and deletes them TTL after they finish, including those left by an
+// earlier process. It is safe for concurrent use.
+type Manager struct {
+	cfg   Config
+	index search.Index
+	logs  search.Store
+	store Store
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager returns a manager reading entries with index and logs and writing exports to
+// store. It sweeps expired exports right away and then every hour.
+func NewManager(cfg Config, index search.Index, logs search.Store, store Store) *Manager {
+	cfg.defaults()
+	m := &Manager{cfg: cfg, index: index, logs: logs, store: store, jobs: make(map[string]*Job),
+		stop: make(chan struct{}), done: make(chan struct{})}
+	go m.loop()
+	return m
+}
+
+func (m *Manager) loop() {
+	defer close(m.done)
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		m.sweep()
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// sweep forgets the jobs that finished more than TTL ago and deletes every export object
+// older than TTL.
+func (m *Manager) sweep() {
+	now := time.Now()
+	m.mu.Lock()
+	for id, j := range m.jobs {
+		j.mu.Lock()
+		expired := !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > m.cfg.TTL
+		j.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	objects, err := m.store.ListObjects(ctx, Prefix+"/")
+	if err != nil {
+		log.Printf("[export] list %s/: %v", Prefix, err)
+		return
+	}
+	for _, o := range objects {
+		if now.Sub(o.LastModified) <= m.cfg.TTL {
+			continue
+		}
+		if err := m.store.DeleteObject(ctx, o.Key); err != nil {
+			log.Printf("[export] delete %s: %v", o.Key, err)
+		}
+	}
+}
+
+// Stop cancels every running export and stops the sweeps.
+func (m *Manager) Stop() {
+	close(m.stop)
+	<-m.done
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		if j.status == StatusRunning {
+			j.status = StatusCanceled
+		}
+		j.mu.Unlock()
+		j.cancel()
+	}
+}
+
+// Start runs an export in the background.
+func (m *Manager) Start(req Request) (*Job, error) {
+	if req.MaxRows <= 0 || req.MaxRows > m.cfg.MaxRows {
+		req.MaxRows = m.cfg.MaxRows
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running := 0
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		if j.status == StatusRunning {
+			running++
+		}
+		j.mu.Unlock()
+	}
+	if running >= m.cfg.MaxRunning {
+		return nil, ErrTooManyJobs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	id := uuid.NewString()
+	j := &Job{
+		id:        id,
+		req:       req,
+		key:       Prefix + "/" + id + "." + string(req.Format),
+		status:    StatusRunning,
+		createdAt: time.Now().UTC(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.jobs[id] = j
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		err := m.run(ctx, j)
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.finishedAt = time.Now().UTC()
+		switch {
+		case err == nil:
+			j.status = StatusDone
+		case j.status == StatusCanceled:
+		case errors.Is(err, context.DeadlineExceeded):
+			j.status, j.err = StatusFailed, fmt.Errorf("export timed out after %s: %w", m.cfg.Timeout, err)
+		default:
+			j.status, j.err = StatusFailed, err
+		}
+	}()
+	return j, nil
+}
+
+// run writes the matching entries to a temporary file and uploads it.
+func (m *Manager) run(ctx context.Context, j *Job) error {
+	f, err := os.CreateTemp("", "akavelog-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := newWriter(f, j.req.Format, j.req.Fields)
+	rows := 0
+	var werr error
+	_, err = search.Scan(ctx, m.index, m.logs, search.ScanOptions{
+		ProjectID:  j.req.ProjectID,
+		Start:      j.req.Start,
+		End:        j.req.End,
+		Query:      j.req.Query,
+		MaxObjects: m.cfg.MaxObjects,
+		MaxBytes:   m.cfg.MaxScanBytes,
+		Progress: func(st search.ScanStats) {
+			j.mu.Lock()
+			j.progress, j.rows = st, rows
+			j.mu.Unlock()
+		},
+	}, func(e *model.LogEntry, _ time.Time) bool {
+		if rows == j.req.MaxRows {
+			j.mu.Lock()
+			j.truncated = true
+			j.mu.Unlock()
+			return false
+		}
+		if werr = w.write(e); werr != nil {
+			return false
+		}
+		rows++
+		return true
+	})
+	if err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := m.store.PutObjectFile(ctx, j.key, f, j.req.Format.contentType()); err != nil {
+		return fmt.Errorf("upload %s: %w", j.key, err)
+	}
+	j.mu.Lock()
+	j.rows, j.bytes = rows, info.Size()
+	j.mu.Unlock()
+	return nil
+}
+
+// Get returns the export with the given ID, or nil.
+func (m *Manager) Get(id string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id]
+}
+
+// List returns every export this process knows of, newest first.
+func (m *Manager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].createdAt.After(out[b].createdAt) })
+	return out
+}
+
+// Cancel stops a running export, or deletes a finished one and its object. It reports
+// whether the export existed.
+func (m *Manager) Cancel(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	j := m.jobs[id]
+	if j == nil {
+		m.mu.Unlock()
+		return false, nil
+	}
+	delete(m.jobs, id)
+	m.mu.Unlock()
+	j.mu.Lock()
+	running := j.status == StatusRunning
+	if running {
+		j.status = StatusCanceled
+	}
+	j.mu.Unlock()
+	j.cancel()
+	if running {
+		return true, nil
+	}
+	return true, m.store.DeleteObject(ctx, j.key)
+}
+
+// Snapshot returns the state of j and, once it is done, a new download URL valid for
+// URLExpiry.
+func (m *Manager) Snapshot(ctx context.Context, j *Job) (Snapshot, error) {
+	j.mu.Lock()
+	s := Snapshot{
+		ID:        j.id,
+		Status:    j.status,
+		Format:    j.req.Format,
+		ProjectID: j.req.ProjectID,
+		Start:     j.req.Start,
+		End:       j.req.End,
+		Fields:    j.req.Fields,
+		Rows:      j.rows,
+		Bytes:     j.bytes,
+		Truncated: j.truncated,
+		Progress:  j.progress,
+		CreatedAt: j.createdAt,
+	}
+	if j.req.Query != nil {
+		s.Query = j.req.Query.String()
+	}
+	if j.err != nil {
+		s.Error = j.err.Error()
+	}
+	if !j.finishedAt.IsZero() {
+		finished, expires := j.finishedAt, j.finishedAt.Add(m.cfg.TTL)
+		s.FinishedAt, s.ExpiresAt = &finished, &expires
+	}
+	j.mu.Unlock()
+	if s.Status != StatusDone {
+		return s, nil
+	}
+	url, err := m.store.PresignGet(ctx, j.key, m.cfg.URLExpiry)
+	if err != nil {
+		return s, fmt.Errorf("presign %s: %w", j.key, err)
+	}
+	expires := time.Now().UTC().Add(m.cfg.URLExpiry)
+	s.Key, s.DownloadURL, s.URLExpiresAt = j.key, url, &expires
+	return s, nil
+}
+
+// writer encodes entries in an export format.
+type writer struct {
+	buf    *bufio.Writer
+	csv    *csv.Writer // nil for NDJSON
+	json   *json.Encoder
+	fields []string
+	row    []string
+}
+
+func newWriter(out io.Writer, format Format, fields []string) *writer {
+	w := &writer{buf: bufio.NewWriterSize(out, 1<<16), fields: fields}
+	if format == NDJSON {
+		w.json = json.NewEncoder(w.buf)
+		w.json.SetEscapeHTML(false)
+		return w
+	}
+	w.csv = csv.NewWriter(w.buf)
+	header := fields
+	if header == nil {
+		header = csvColumns
+	}
+	w.row = make([]string, len(header))
+	_ = w.csv.Write(header) // errors stick to the writer and are returned by flush
+	return w
+}
+
+func (w *writer) write(e *model.LogEntry) error {
+	if w.csv == nil {
+		if w.fields == nil {
+			return w.json.Encode(e)
+		}
+		obj := make(map[string]string, len(w.fields))
+		for _, f := range w.fields {
+			if v, ok := processors.GetField(e, f); ok {
+				obj[f] = v
+			}
+		}
+		return w.json.Encode(obj)
+	}
+	if w.fields == nil {
+		tags := ""
+		if len(e.Tags) > 0 {
+			data, err := json.Marshal(e.Tags)
+			if err != nil {
+				return err
+			}
+			tags = string(data)
+		}
+		w.row[0], w.row[1], w.row[2], w.row[3], w.row[4], w.row[5] = e.Timestamp, e.ProjectID, e.Service, e.Level, e.Message, tags
+	} else {
+		for i, f := range w.fields {
+			w.row[i], _ = processors.GetField(e, f)
+		}
+	}
+	return w.csv.Write(w.row)
+}
+
+func (w *writer) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
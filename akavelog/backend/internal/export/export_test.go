@@ -0,0 +1,182 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+type memIndex []model.Batch
+
+func (x memIndex) Find(context.Context, repository.BatchFilter) ([]model.Batch, error) {
+	return x, nil
+}
+
+type memLogs map[string][]model.LogEntry
+
+func (s memLogs) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	return s[key], nil
+}
+
+// memStore keeps uploaded exports in memory.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+	old     []storage.ObjectInfo // listed as left by an earlier process
+	deleted []string
+}
+
+func (s *memStore) PutObjectFile(_ context.Context, key string, f *os.File, _ string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = string(data)
+	return nil
+}
+
+func (s *memStore) PresignGet(_ context.Context, key string, _ time.Duration) (string, error) {
+	return "https://o3.example/" + key + "?signed", nil
+}
+
+func (s *memStore) DeleteObject(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func (s *memStore) ListObjects(context.Context, string) ([]storage.ObjectInfo, error) {
+	return s.old, nil
+}
+
+func fixture() (memIndex, memLogs) {
+	return memIndex{{Key: "a"}}, memLogs{"a": {
+		{Timestamp: "2026-03-01T10:00:00Z", ProjectID: "p1", Service: "api", Level: "error", Message: `db "timeout"`, Tags: map[string]string{"duration": "900"}},
+		{Timestamp: "2026-03-01T10:05:00Z", ProjectID: "p1", Service: "api", Level: "info", Message: "ok"},
+		{Timestamp: "2026-03-01T10:10:00Z", ProjectID: "p1", Service: "web", Level: "error", Message: "down"},
+	}}
+}
+
+func export(t *testing.T, m *Manager, req Request) Snapshot {
+	t.Helper()
+	req.Start = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	req.End = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	j, err := m.Start(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-j.Done()
+	snap, err := m.Snapshot(context.Background(), j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Status != StatusDone {
+		t.Fatalf("status %s: %s", snap.Status, snap.Error)
+	}
+	return snap
+}
+
+func TestExport(t *testing.T) {
+	index, logs := fixture()
+	store := &memStore{objects: make(map[string]string)}
+	m := NewManager(Config{}, index, logs, store)
+	defer m.Stop()
+
+	q, err := query.Parse("level:error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := export(t, m, Request{Query: q, Format: CSV})
+	want := "timestamp,project_id,service,level,message,tags\n" +
+		`2026-03-01T10:00:00Z,p1,api,error,"db ""timeout""","{""duration"":""900""}"` + "\n" +
+		"2026-03-01T10:10:00Z,p1,web,error,down,\n"
+	if got := store.objects[snap.Key]; got != want {
+		t.Errorf("csv:\n%s\nwant:\n%s", got, want)
+	}
+	if snap.Rows != 2 || snap.Bytes != int64(len(want)) || snap.Truncated || !strings.HasPrefix(snap.DownloadURL, "https://") || snap.URLExpiresAt == nil {
+		t.Errorf("snapshot %+v", snap)
+	}
+
+	snap = export(t, m, Request{Format: NDJSON, Fields: []string{"service", "duration"}, MaxRows: 2})
+	want = `{"duration":"900","service":"api"}` + "\n" + `{"service":"api"}` + "\n"
+	if got := store.objects[snap.Key]; got != want || !strings.HasSuffix(snap.Key, ".ndjson") {
+		t.Errorf("%s:\n%s\nwant:\n%s", snap.Key, got, want)
+	}
+	if snap.Rows != 2 || !snap.Truncated {
+		t.Errorf("snapshot %+v", snap)
+	}
+
+	if ok, err := m.Cancel(context.Background(), snap.ID); !ok || err != nil {
+		t.Fatalf("Cancel: %v, %v", ok, err)
+	}
+	if _, ok := store.objects[snap.Key]; ok || m.Get(snap.ID) != nil {
+		t.Error("canceled export kept")
+	}
+	if len(m.List()) != 1 {
+		t.Errorf("List: %d exports, want 1", len(m.List()))
+	}
+}
+
+// blockingLogs holds every download until its context is done.
+type blockingLogs struct{}
+
+func (blockingLogs) GetObjectLogs(ctx context.Context, _ string) ([]model.LogEntry, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestExportLimits(t *testing.T) {
+	index, _ := fixture()
+	store := &memStore{objects: make(map[string]string), old: []storage.ObjectInfo{
+		{Key: Prefix + "/old.csv", LastModified: time.Now().Add(-48 * time.Hour)},
+		{Key: Prefix + "/new.csv", LastModified: time.Now()},
+	}}
+	m := NewManager(Config{MaxRunning: 1}, index, blockingLogs{}, store)
+	defer m.Stop()
+
+	j, err := m.Start(Request{Format: CSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start(Request{Format: CSV}); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("second export: err = %v, want ErrTooManyJobs", err)
+	}
+	if ok, _ := m.Cancel(context.Background(), j.ID()); !ok {
+		t.Fatal("Cancel: export not found")
+	}
+	<-j.Done()
+	if snap, _ := m.Snapshot(context.Background(), j); snap.Status != StatusCanceled || snap.DownloadURL != "" {
+		t.Errorf("canceled export: %+v", snap)
+	}
+
+	// The first sweep runs in the background when the manager starts.
+	var deleted []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		store.mu.Lock()
+		deleted = append([]string(nil), store.deleted...)
+		store.mu.Unlock()
+		if len(deleted) > 0 {
+			break
+		}
+	}
+	if len(deleted) != 1 || deleted[0] != Prefix+"/old.csv" {
+		t.Errorf("swept %v, want only the expired export", deleted)
+	}
+}
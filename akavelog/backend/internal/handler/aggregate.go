@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxAggregateFields  = 10
+	maxAggregateTopN    = 100
+	maxAggregateObjects = 2000
+)
+
+type aggregateRequest struct {
+	Query     string   `json:"query"`
+	ProjectID string   `json:"project_id"`
+	Start     string   `json:"start"`    // RFC 3339; default end - 24h
+	End       string   `json:"end"`      // RFC 3339; default now
+	Interval  string   `json:"interval"` // histogram bucket width, e.g. 5m or 1d; "" or auto to pick one, none for no histogram
+	GroupBy   []string `json:"group_by"`
+	Top       []string `json:"top"`
+	TopN      int      `json:"top_n"` // default 10, at most 100
+
+	savedSearch *uuid.UUID // the saved search being run, if any
+}
+
+// Aggregate counts the entries of a time range matching a query (POST /logs/aggregate): a
+// histogram of entries per interval, counts grouped by the group_by fields and the top_n most
+// frequent values of each top field. It streams the batch objects the batches index lists for
+// the range through a search.Aggregator, so no entries are returned.
+func (h *QueryHandler) Aggregate(c echo.Context) error {
+	var req aggregateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	return h.aggregate(c, req)
+}
+
+func (h *QueryHandler) aggregate(c echo.Context, req aggregateRequest) error {
+	if h.Store == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "aggregation not available", "aggregation requires O3 storage")
+	}
+	opts, msg, detail := scanOptions(req.Query, req.ProjectID, req.Start, req.End)
+	if msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	opts.MaxObjects = maxAggregateObjects
+	a, msg, detail := newAggregator(req, opts.Start, opts.End)
+	if msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	began := time.Now()
+	st, err := search.Scan(c.Request().Context(), h.Index, h.Store, opts, func(e *model.LogEntry, t time.Time) bool {
+		a.Add(e, t)
+		return true
+	})
+	res := a.Result()
+	recordRun(h.History, newQueryRun(c, model.QueryAggregate, req.savedSearch, opts, began, res.Total), err)
+	if err != nil {
+		return response.InternalError(c, "aggregation failed", err.Error())
+	}
+	return response.OK(c, map[string]any{
+		"query":        opts.Query.String(),
+		"start":        opts.Start,
+		"end":          opts.End,
+		"aggregations": res,
+		"stats":        st,
+	}, "")
+}
+
+// newAggregator validates the interval, group_by, top and top_n of req and returns an
+// aggregator for [start, end). It returns a message and detail for a 400 response, or "" when
+// they are valid.
+func newAggregator(req aggregateRequest, start, end time.Time) (*search.Aggregator, string, string) {
+	agg := search.AggregateOptions{Start: start, End: end}
+	switch v := strings.TrimSpace(req.Interval); v {
+	case "", "auto":
+		agg.Interval = search.AutoInterval(start, end)
+	case "none":
+	default:
+		d, err := parseDays(v)
+		if err != nil || d <= 0 {
+			return nil, "invalid interval", "interval must be a duration such as 5m or 1d, auto or none"
+		}
+		agg.Interval = d
+	}
+	var ok bool
+	if agg.GroupBy, ok = aggregateFields(req.GroupBy); !ok {
+		return nil, "invalid group_by", "group_by takes at most " + strconv.Itoa(maxAggregateFields) + " non-empty field names"
+	}
+	if agg.Top, ok = aggregateFields(req.Top); !ok {
+		return nil, "invalid top", "top takes at most " + strconv.Itoa(maxAggregateFields) + " non-empty field names"
+	}
+	if req.TopN < 0 || req.TopN > maxAggregateTopN {
+		return nil, "invalid top_n", "top_n must be between 1 and " + strconv.Itoa(maxAggregateTopN)
+	}
+	agg.TopN = req.TopN
+	a, err := search.NewAggregator(agg)
+	if err != nil {
+		return nil, "invalid interval", err.Error()
+	}
+	return a, "", ""
+}
+
+// aggregateFields trims and de-duplicates field names. It reports false for an empty name or
+// too many fields; nil stays nil.
+func aggregateFields(fields []string) ([]string, bool) {
+	if len(fields) > maxAggregateFields {
+		return nil, false
+	}
+	var out []string
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			return nil, false
+		}
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out, true
+}
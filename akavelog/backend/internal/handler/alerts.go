@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/alerting"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxAlertName        = 200
+	maxAlertChannels    = 20
+	defaultAlertHistory = 50
+)
+
+// AlertHandler handles /alerts. Like OutputHandler, every change is persisted first and then
+// the whole set is reloaded into the Engine.
+type AlertHandler struct {
+	Repo     *repository.AlertRepository
+	Channels *repository.NotificationChannelRepository
+	Engine   *alerting.Engine
+}
+
+type alertRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Query       string   `json:"query"` // entries counted; empty counts every entry
+	ProjectID   string   `json:"project_id"`
+	Condition   string   `json:"condition"` // "count > 100 in 5m" or "no logs in 10m"
+	Severity    string   `json:"severity"`  // info, warning or critical (default warning)
+	Channels    []string `json:"channels"`  // names of notification channels told when it fires and resolves
+	Enabled     *bool    `json:"enabled"`   // default true
+}
+
+type alertResponse struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Query       string           `json:"query"`
+	ProjectID   string           `json:"project_id,omitempty"`
+	Condition   string           `json:"condition"`
+	Severity    string           `json:"severity"`
+	Channels    []string         `json:"channels"`
+	Enabled     bool             `json:"enabled"`
+	State       model.AlertState `json:"state"`
+	Status      *alerting.Status `json:"status"` // null while the alert is not evaluated (disabled)
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
+}
+
+// alertListSpec filters GET /alerts by severity, state, project_id and enabled; q searches names and descriptions.
+var alertListSpec = listSpec[alertResponse]{
+	text: func(a alertResponse) string { return a.Name + " " + a.Description },
+	filters: map[string]func(alertResponse, string) bool{
+		"severity":   equalFold(func(a alertResponse) string { return a.Severity }),
+		"state":      equalFold(func(a alertResponse) string { return string(a.State) }),
+		"project_id": equalFold(func(a alertResponse) string { return a.ProjectID }),
+		"enabled":    boolFilter(func(a alertResponse) bool { return a.Enabled }),
+	},
+	sorts: namedSorts(func(a alertResponse) string { return a.Name }, func(a alertResponse) string { return a.CreatedAt }, func(a alertResponse) string { return a.UpdatedAt }),
+}
+
+func (h *AlertHandler) newResponse(a model.Alert) alertResponse {
+	out := alertResponse{
+		ID:          a.ID.String(),
+		Name:        a.Name,
+		Description: a.Description,
+		Query:       a.Query,
+		ProjectID:   a.ProjectID,
+		Condition:   a.Condition,
+		Severity:    a.Severity,
+		Channels:    a.Channels,
+		Enabled:     a.Enabled,
+		State:       a.State,
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   a.UpdatedAt.Format(time.RFC3339),
+	}
+	if out.Channels == nil {
+		out.Channels = []string{}
+	}
+	if st, ok := h.Engine.Status(a.ID); ok {
+		out.Status = &st
+		out.State = st.State
+	}
+	return out
+}
+
+// ListAlerts returns all alerts by name with their live status (GET /alerts), or those of
+// the project the caller is bound to.
+func (h *AlertHandler) ListAlerts(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list alerts failed", "list alerts: "+err.Error())
+	}
+	out := make([]alertResponse, 0, len(list))
+	for _, a := range list {
+		if visible(c.Request().Context(), a.ProjectID) {
+			out = append(out, h.newResponse(a))
+		}
+	}
+	return listResponse(c, "alerts", out, alertListSpec)
+}
+
+// AlertStatus returns how many alerts are in each state and the firing ones, most severe
+// first (GET /alerts/status).
+func (h *AlertHandler) AlertStatus(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "alert status failed", "list alerts: "+err.Error())
+	}
+	counts := map[model.AlertState]int{model.AlertOK: 0, model.AlertFiring: 0, model.AlertResolved: 0}
+	disabled := 0
+	firing := []alertResponse{}
+	for _, a := range list {
+		if !visible(c.Request().Context(), a.ProjectID) {
+			continue
+		}
+		if !a.Enabled {
+			disabled++
+			continue
+		}
+		r := h.newResponse(a)
+		counts[r.State]++
+		if r.State == model.AlertFiring {
+			firing = append(firing, r)
+		}
+	}
+	rank := map[string]int{model.SeverityCritical: 0, model.SeverityWarning: 1, model.SeverityInfo: 2}
+	sort.SliceStable(firing, func(i, j int) bool { return rank[firing[i].Severity] < rank[firing[j].Severity] })
+	return response.OK(c, map[string]any{"states": counts, "disabled": disabled, "firing": firing}, "")
+}
+
+// GetAlert returns one alert with its live status (GET /alerts/:id).
+func (h *AlertHandler) GetAlert(c echo.Context) error {
+	a, err := h.byID(c)
+	if a == nil {
+		return err
+	}
+	return response.OK(c, h.newResponse(*a), "")
+}
+
+// CreateAlert validates and saves an alert and starts evaluating it (POST /alerts).
+func (h *AlertHandler) CreateAlert(c echo.Context) error {
+	var req alertRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	var a model.Alert
+	if ok, err := h.apply(c, &a, req); !ok {
+		return err
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), a.Name)
+	if err != nil {
+		return response.InternalError(c, "create alert failed", "get alert: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "alert name already in use", "an alert named "+a.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &a); err != nil {
+		return response.InternalError(c, "create alert failed", "create alert: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, h.newResponse(a), "alert created")
+}
+
+// UpdateAlert replaces an alert's definition (PUT /alerts/:id). A changed alert counts anew
+// from now in its current state; disabling a firing alert resolves it.
+func (h *AlertHandler) UpdateAlert(c echo.Context) error {
+	a, err := h.byID(c)
+	if a == nil {
+		return err
+	}
+	var req alertRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := a.Name
+	if ok, err := h.apply(c, a, req); !ok {
+		return err
+	}
+	if a.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), a.Name)
+		if err != nil {
+			return response.InternalError(c, "update alert failed", "get alert: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "alert name already in use", "an alert named "+a.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), a); err != nil {
+		return response.InternalError(c, "update alert failed", "update alert: "+err.Error())
+	}
+	if !a.Enabled && a.State == model.AlertFiring {
+		ev := model.AlertEvent{AlertID: a.ID, State: model.AlertResolved, Message: "alert disabled"}
+		if err := h.Repo.RecordState(c.Request().Context(), &ev); err != nil {
+			return response.InternalError(c, "update alert failed", "resolve alert: "+err.Error())
+		}
+		a.State, a.StateChangedAt, a.LastValue = ev.State, &ev.CreatedAt, ev.Value
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, h.newResponse(*a), "alert updated")
+}
+
+// DeleteAlert removes an alert and its history (DELETE /alerts/:id).
+func (h *AlertHandler) DeleteAlert(c echo.Context) error {
+	a, err := h.byID(c)
+	if a == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), a.ID); err != nil {
+		return response.InternalError(c, "delete alert failed", "delete alert: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, nil, "alert deleted")
+}
+
+// ListAlertHistory returns the state changes of an alert, newest first
+// (GET /alerts/:id/history?limit=).
+func (h *AlertHandler) ListAlertHistory(c echo.Context) error {
+	a, err := h.byID(c)
+	if a == nil {
+		return err
+	}
+	limit := defaultAlertHistory
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > repository.MaxAlertEvents {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(repository.MaxAlertEvents))
+		}
+		limit = n
+	}
+	events, err := h.Repo.ListEvents(c.Request().Context(), a.ID, limit)
+	if err != nil {
+		return response.InternalError(c, "list alert history failed", "list alert events: "+err.Error())
+	}
+	out := make([]map[string]any, 0, len(events))
+	for _, ev := range events {
+		out = append(out, map[string]any{
+			"id":         ev.ID.String(),
+			"state":      ev.State,
+			"value":      ev.Value,
+			"message":    ev.Message,
+			"created_at": ev.CreatedAt,
+		})
+	}
+	return response.OK(c, map[string]any{"events": out}, "")
+}
+
+// Reload loads every persisted alert into the Engine. Alerts that fail to parse are skipped
+// and logged.
+func (h *AlertHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[alerts] reload list: %v", err)
+		return
+	}
+	if err := h.Engine.Load(list); err != nil {
+		log.Printf("[alerts] reload: %v", err)
+	}
+}
+
+// byID loads the alert named by the :id path parameter, if the caller may see it. When it
+// returns nil, the error response has already been written and err is its result.
+func (h *AlertHandler) byID(c echo.Context) (*model.Alert, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	a, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get alert failed", "get alert: "+err.Error())
+	}
+	if a == nil || !visible(c.Request().Context(), a.ProjectID) {
+		return nil, response.NotFound(c, "alert not found", "alert not found")
+	}
+	return a, nil
+}
+
+// apply copies req onto a and validates it, including that its channels exist. When it
+// reports false, the error response has already been written and err is its result.
+func (h *AlertHandler) apply(c echo.Context, a *model.Alert, req alertRequest) (bool, error) {
+	if msg, detail := applyAlert(a, req); msg != "" {
+		return false, response.BadRequest(c, msg, detail)
+	}
+	for _, name := range a.Channels {
+		ch, err := h.Channels.GetByName(c.Request().Context(), name)
+		if err != nil {
+			return false, response.InternalError(c, "save alert failed", "get notification channel: "+err.Error())
+		}
+		if ch == nil {
+			return false, response.BadRequest(c, "invalid channels", "no notification channel named "+name)
+		}
+	}
+	return true, nil
+}
+
+// applyAlert copies req onto a and validates what needs no lookups. It returns a message and detail for a 400
+// response, or "" when a is valid.
+func applyAlert(a *model.Alert, req alertRequest) (string, string) {
+	a.Name = strings.TrimSpace(req.Name)
+	if a.Name == "" || len(a.Name) > maxAlertName {
+		return "invalid name", "name is required and at most " + strconv.Itoa(maxAlertName) + " bytes"
+	}
+	a.Description = req.Description
+	a.Query = strings.TrimSpace(req.Query)
+	if _, err := query.Parse(a.Query); err != nil {
+		return "invalid query", err.Error()
+	}
+	a.ProjectID = strings.TrimSpace(req.ProjectID)
+	a.Condition = strings.Join(strings.Fields(req.Condition), " ")
+	if _, err := alerting.ParseCondition(a.Condition); err != nil {
+		return "invalid condition", err.Error()
+	}
+	a.Severity = strings.ToLower(strings.TrimSpace(req.Severity))
+	switch a.Severity {
+	case "":
+		a.Severity = model.SeverityWarning
+	case model.SeverityInfo, model.SeverityWarning, model.SeverityCritical:
+	default:
+		return "invalid severity", "severity must be info, warning or critical"
+	}
+	if len(req.Channels) > maxAlertChannels {
+		return "invalid channels", "channels takes at most " + strconv.Itoa(maxAlertChannels) + " names"
+	}
+	a.Channels = nil
+	for _, name := range req.Channels {
+		if name = strings.TrimSpace(name); name == "" {
+			return "invalid channels", "channel names must not be empty"
+		}
+		a.Channels = append(a.Channels, name)
+	}
+	a.Enabled = req.Enabled == nil || *req.Enabled
+	return "", ""
+}
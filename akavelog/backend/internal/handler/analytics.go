@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/anomaly"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+// AnalyticsHandler handles /analytics, the baselines the anomaly analyzer learned.
+type AnalyticsHandler struct {
+	Repo     *repository.BaselineRepository
+	Analyzer *anomaly.Analyzer // nil without O3 or when disabled
+}
+
+type hourlyBaseline struct {
+	Hour    int     `json:"hour"` // of day, UTC
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stddev"`
+	Samples int     `json:"samples"`
+}
+
+type metricBaselineResponse struct {
+	Mean     float64            `json:"mean"`
+	StdDev   float64            `json:"stddev"`
+	Samples  int                `json:"samples"`
+	Learning bool               `json:"learning"` // fewer samples than min_samples: not compared with yet
+	Hourly   []hourlyBaseline   `json:"hourly"`   // hours of day with samples
+	Last     *model.Observation `json:"last"`     // the last bucket, as compared with the baseline
+}
+
+// ListBaselines returns the volume and error rate baselines of every service, with the last
+// bucket each was compared with (GET /analytics/baselines?project_id=&service=).
+func (h *AnalyticsHandler) ListBaselines(c echo.Context) error {
+	if h.Analyzer == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "anomaly detection not available", "anomaly detection requires O3 storage and is off when anomaly.disabled is set")
+	}
+	ctx := c.Request().Context()
+	list, err := h.Repo.List(ctx, c.QueryParam("project_id"), c.QueryParam("service"))
+	if err != nil {
+		return response.InternalError(c, "list baselines failed", "list baselines: "+err.Error())
+	}
+	cursor, err := h.Repo.Cursor(ctx)
+	if err != nil {
+		return response.InternalError(c, "list baselines failed", "load cursor: "+err.Error())
+	}
+	cfg := h.Analyzer.Config()
+	out := make([]map[string]any, 0, len(list))
+	for _, b := range list {
+		out = append(out, map[string]any{
+			"project_id":   b.ProjectID,
+			"service":      b.Service,
+			"volume":       newMetricBaseline(b.Volume, cfg.MinSamples),
+			"error_rate":   newMetricBaseline(b.ErrorRate, cfg.MinSamples),
+			"last_seen_at": b.LastSeenAt,
+			"updated_at":   b.UpdatedAt,
+		})
+	}
+	var analyzed *time.Time
+	if !cursor.IsZero() {
+		analyzed = &cursor
+	}
+	return response.OK(c, map[string]any{
+		"baselines":         out,
+		"interval":          cfg.Interval.String(),
+		"threshold":         cfg.Threshold,
+		"min_samples":       cfg.MinSamples,
+		"last_analyzed_end": analyzed,
+	}, "")
+}
+
+func newMetricBaseline(m model.MetricBaseline, minSamples int) metricBaselineResponse {
+	out := metricBaselineResponse{
+		Mean:     m.Overall.Mean,
+		StdDev:   math.Sqrt(m.Overall.Variance),
+		Samples:  m.Overall.Samples,
+		Learning: m.Overall.Samples < minSamples,
+		Hourly:   []hourlyBaseline{},
+		Last:     m.Last,
+	}
+	for hour, w := range m.Hourly {
+		if w.Samples > 0 {
+			out.Hourly = append(out.Hourly, hourlyBaseline{Hour: hour, Mean: w.Mean, StdDev: math.Sqrt(w.Variance), Samples: w.Samples})
+		}
+	}
+	return out
+}
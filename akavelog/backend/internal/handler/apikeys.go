@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// maxAPIKeyName bounds the names of API keys.
+const maxAPIKeyName = 128
+
+// APIKeyHandler handles /api-keys. A key is returned once, when it is created; only its hash
+// is stored. Every change drops the authenticator's cache so it applies right away.
+type APIKeyHandler struct {
+	Repo     *repository.APIKeyRepository
+	Projects *ProjectHandler // optional; resolves project_id
+	Auth     *middleware.Authenticator
+}
+
+type apiKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Key        string   `json:"key,omitempty"` // POST /api-keys only
+	Scopes     []string `json:"scopes"`
+	ProjectID  *string  `json:"project_id"` // null for every project
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// apiKeyListSpec lets q search GET /api-keys by name and prefix.
+var apiKeyListSpec = listSpec[apiKeyResponse]{
+	text:  func(k apiKeyResponse) string { return k.Name + " " + k.Prefix },
+	sorts: namedSorts(func(k apiKeyResponse) string { return k.Name }, func(k apiKeyResponse) string { return k.CreatedAt }, func(k apiKeyResponse) string { return k.UpdatedAt }),
+}
+
+type apiKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ProjectID string   `json:"project_id"` // ID or name; empty for every project
+	ExpiresAt string   `json:"expires_at"` // RFC 3339; empty for never
+}
+
+func newAPIKeyResponse(k model.APIKey) apiKeyResponse {
+	out := apiKeyResponse{
+		ID:        k.ID.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: k.UpdatedAt.Format(time.RFC3339),
+	}
+	if out.Scopes == nil {
+		out.Scopes = []string{}
+	}
+	if k.ProjectID != nil {
+		id := k.ProjectID.String()
+		out.ProjectID = &id
+	}
+	if k.ExpiresAt != nil {
+		at := k.ExpiresAt.Format(time.RFC3339)
+		out.ExpiresAt = &at
+	}
+	if k.LastUsedAt != nil {
+		at := k.LastUsedAt.Format(time.RFC3339)
+		out.LastUsedAt = &at
+	}
+	return out
+}
+
+// ListAPIKeys returns all keys, without the keys themselves (GET /api-keys).
+func (h *APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list API keys failed", "list API keys: "+err.Error())
+	}
+	out := make([]apiKeyResponse, 0, len(list))
+	for _, k := range list {
+		out = append(out, newAPIKeyResponse(k))
+	}
+	return listResponse(c, "api_keys", out, apiKeyListSpec)
+}
+
+// GetAPIKey returns one key, without the key itself (GET /api-keys/:id).
+func (h *APIKeyHandler) GetAPIKey(c echo.Context) error {
+	k, err := h.byID(c)
+	if k == nil {
+		return err
+	}
+	return response.OK(c, newAPIKeyResponse(*k), "")
+}
+
+// CreateAPIKey generates and persists a key and returns it, the only time it is shown
+// (POST /api-keys).
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	var req apiKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	k := model.APIKey{}
+	if msg, detail := h.apply(c, &k, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), k.Name)
+	if err != nil {
+		return response.InternalError(c, "create API key failed", "get API key: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "API key name already in use", "an API key named "+k.Name+" already exists")
+	}
+	key, prefix, hash, err := middleware.GenerateKey()
+	if err != nil {
+		return response.InternalError(c, "create API key failed", "generate key: "+err.Error())
+	}
+	k.Prefix, k.Hash = prefix, hash
+	if err := h.Repo.Create(c.Request().Context(), &k); err != nil {
+		return response.InternalError(c, "create API key failed", "create API key: "+err.Error())
+	}
+	out := newAPIKeyResponse(k)
+	out.Key = key
+	return response.Created(c, out, "API key created; store the key now, it is not shown again")
+}
+
+// UpdateAPIKey replaces a key's name, scopes, project and expiry (PUT /api-keys/:id). The
+// key itself stays the same.
+func (h *APIKeyHandler) UpdateAPIKey(c echo.Context) error {
+	k, err := h.byID(c)
+	if k == nil {
+		return err
+	}
+	var req apiKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := k.Name
+	if msg, detail := h.apply(c, k, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if k.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), k.Name)
+		if err != nil {
+			return response.InternalError(c, "update API key failed", "get API key: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "API key name already in use", "an API key named "+k.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), k); err != nil {
+		return response.InternalError(c, "update API key failed", "update API key: "+err.Error())
+	}
+	h.Auth.Forget()
+	return response.OK(c, newAPIKeyResponse(*k), "API key updated")
+}
+
+// DeleteAPIKey revokes a key (DELETE /api-keys/:id).
+func (h *APIKeyHandler) DeleteAPIKey(c echo.Context) error {
+	k, err := h.byID(c)
+	if k == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), k.ID); err != nil {
+		return response.InternalError(c, "delete API key failed", "delete API key: "+err.Error())
+	}
+	h.Auth.Forget()
+	return response.OK(c, nil, "API key deleted")
+}
+
+// byID loads the key named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *APIKeyHandler) byID(c echo.Context) (*model.APIKey, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	k, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get API key failed", "get API key: "+err.Error())
+	}
+	if k == nil {
+		return nil, response.NotFound(c, "API key not found", "API key not found")
+	}
+	return k, nil
+}
+
+// apply copies req onto k and validates it. It returns a message and detail for a 400
+// response, or "" when k is valid.
+func (h *APIKeyHandler) apply(c echo.Context, k *model.APIKey, req apiKeyRequest) (string, string) {
+	k.Name = strings.TrimSpace(req.Name)
+	if k.Name == "" || len(k.Name) > maxAPIKeyName {
+		return "invalid name", "name is required and at most 128 bytes"
+	}
+	k.Scopes = nil
+	seen := make(map[string]bool)
+	for _, s := range req.Scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !middleware.ValidScope(s) {
+			return "invalid scopes", "unknown scope " + s + "; scopes are " + strings.Join(middleware.Scopes, ", ")
+		}
+		if !seen[s] {
+			seen[s] = true
+			k.Scopes = append(k.Scopes, s)
+		}
+	}
+	if len(k.Scopes) == 0 {
+		return "invalid scopes", "scopes needs at least one of " + strings.Join(middleware.Scopes, ", ")
+	}
+	projectID, msg, err := h.Projects.resolve(c.Request().Context(), req.ProjectID)
+	if err != nil {
+		return "invalid project_id", "get project: " + err.Error()
+	}
+	if msg != "" {
+		return "invalid project_id", msg
+	}
+	k.ProjectID = projectID
+	if k.ProjectID != nil && seen[middleware.ScopeAdmin] {
+		return "invalid scopes", "a key bound to a project cannot have the admin scope"
+	}
+	k.ExpiresAt = nil
+	if req.ExpiresAt != "" {
+		at, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return "invalid expires_at", "expires_at must be an RFC 3339 time"
+		}
+		k.ExpiresAt = &at
+	}
+	return "", ""
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared against when a login names no user, so unknown emails take as long
+// to refuse as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("akavelog"), bcrypt.DefaultCost)
+
+// AuthHandler handles /auth: logging in with email and password for a session token, and
+// the logged-in user's own account.
+type AuthHandler struct {
+	Users  *repository.UserRepository
+	Tokens *middleware.Tokens
+	Auth   *middleware.Authenticator
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type passwordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Login checks an email and password and returns a session token to send as a Bearer token
+// (POST /auth/login).
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req loginRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	ctx := c.Request().Context()
+	u, err := h.Users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		return response.InternalError(c, "login failed", "get user: "+err.Error())
+	}
+	hash := dummyHash
+	if u != nil {
+		hash = []byte(u.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || u == nil || u.Disabled {
+		return response.Error(c, http.StatusUnauthorized, "invalid email or password", "invalid email or password")
+	}
+	token, exp, err := h.Tokens.Issue(*u)
+	if err != nil {
+		return response.InternalError(c, "login failed", "issue token: "+err.Error())
+	}
+	now := time.Now()
+	if err := h.Users.TouchLogin(ctx, u.ID, now); err != nil {
+		log.Printf("[auth] record login of %s: %v", u.Email, err)
+	}
+	u.LastLoginAt = &now
+	return response.OK(c, map[string]any{
+		"token":      token,
+		"expires_at": exp.Format(time.RFC3339),
+		"user":       newUserResponse(*u),
+	}, "")
+}
+
+// Me returns who the request was authenticated as (GET /auth/me).
+func (h *AuthHandler) Me(c echo.Context) error {
+	p := middleware.PrincipalFrom(c)
+	if p == nil {
+		return response.OK(c, map[string]any{"authenticated": false}, "")
+	}
+	out := map[string]any{"authenticated": true, "name": p.Name, "scopes": p.Scopes}
+	if p.UserID == uuid.Nil {
+		out["kind"] = "api_key"
+		if p.ProjectID != "" {
+			out["project_id"] = p.ProjectID
+		}
+		return response.OK(c, out, "")
+	}
+	u, err := h.Users.GetByID(c.Request().Context(), p.UserID)
+	if err != nil {
+		return response.InternalError(c, "get user failed", "get user: "+err.Error())
+	}
+	if u == nil {
+		return response.NotFound(c, "user not found", "user not found")
+	}
+	out["kind"] = "user"
+	out["user"] = newUserResponse(*u)
+	return response.OK(c, out, "")
+}
+
+// ChangePassword replaces the logged-in user's password and ends their other sessions
+// (PUT /auth/password). The new token is returned.
+func (h *AuthHandler) ChangePassword(c echo.Context) error {
+	p := middleware.PrincipalFrom(c)
+	if p == nil || p.UserID == uuid.Nil {
+		return response.BadRequest(c, "not a user", "log in as a user to change your password")
+	}
+	var req passwordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	ctx := c.Request().Context()
+	u, err := h.Users.GetByID(ctx, p.UserID)
+	if err != nil {
+		return response.InternalError(c, "change password failed", "get user: "+err.Error())
+	}
+	if u == nil {
+		return response.NotFound(c, "user not found", "user not found")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		return response.Error(c, http.StatusForbidden, "wrong password", "current_password is wrong")
+	}
+	if msg, detail := setPassword(u, req.NewPassword); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if err := h.Users.SetPassword(ctx, u); err != nil {
+		return response.InternalError(c, "change password failed", "set password: "+err.Error())
+	}
+	h.Auth.Forget()
+	token, exp, err := h.Tokens.Issue(*u)
+	if err != nil {
+		return response.InternalError(c, "change password failed", "issue token: "+err.Error())
+	}
+	return response.OK(c, map[string]any{"token": token, "expires_at": exp.Format(time.RFC3339)}, "password changed")
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultBatchLimit = 1000
+	maxBatchLimit     = 10000
+)
+
+// BatchHandler handles /batches, the index of uploaded batch objects.
+type BatchHandler struct {
+	Repo *repository.BatchRepository
+}
+
+// ListBatches returns the indexed objects holding entries of a project and time range, oldest
+// first (GET /batches?project_id=&prefix=&start=&end=&limit=). start and end are RFC 3339.
+func (h *BatchHandler) ListBatches(c echo.Context) error {
+	f := repository.BatchFilter{
+		ProjectID: c.QueryParam("project_id"),
+		Prefix:    c.QueryParam("prefix"),
+		Limit:     defaultBatchLimit,
+	}
+	var err error
+	if f.Start, err = queryTime(c, "start"); err != nil {
+		return response.BadRequest(c, "invalid start", "start must be an RFC 3339 time")
+	}
+	if f.End, err = queryTime(c, "end"); err != nil {
+		return response.BadRequest(c, "invalid end", "end must be an RFC 3339 time")
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxBatchLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(maxBatchLimit))
+		}
+		f.Limit = n
+	}
+	list, err := h.Repo.Find(c.Request().Context(), f)
+	if err != nil {
+		return response.InternalError(c, "list batches failed", "find batches: "+err.Error())
+	}
+	if list == nil {
+		list = []model.Batch{}
+	}
+	var entries int
+	var size int64
+	for _, b := range list {
+		entries += b.Count
+		size += b.Size
+	}
+	return response.OK(c, map[string]any{"batches": list, "count": len(list), "entries": entries, "bytes": size}, "")
+}
+
+// queryTime parses the RFC 3339 query parameter name; it is zero when absent.
+func queryTime(c echo.Context, name string) (time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
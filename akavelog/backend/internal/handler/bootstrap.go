@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+// InputSpec declares an input for ReconcileInputs, with the fields of POST /inputs. Its Title
+// identifies it.
+type InputSpec struct {
+	Type        string
+	Title       string
+	Description string
+	Project     string // project ID or name
+	State       string
+	Config      map[string]any
+}
+
+// PipelineSpec declares a pipeline to create at startup, with the fields of POST /pipelines.
+// Its Name identifies it; Input is the title of the input it applies to.
+type PipelineSpec struct {
+	Name        string
+	Description string
+	Input       string
+	Project     string
+	Enabled     *bool
+	Processors  []model.ProcessorConfig
+}
+
+// InputChanges lists the titles of the inputs ReconcileInputs created, updated and deleted,
+// and the specs it failed to apply.
+type InputChanges struct {
+	Created []string             `json:"created,omitempty"`
+	Updated []string             `json:"updated,omitempty"`
+	Deleted []string             `json:"deleted,omitempty"`
+	Failed  []InputChangeFailure `json:"failed,omitempty"`
+}
+
+// InputChangeFailure is a spec ReconcileInputs failed to apply, or an input it failed to delete.
+type InputChangeFailure struct {
+	Title string `json:"title"`
+	Error string `json:"error"`
+}
+
+// Empty reports whether nothing changed.
+func (c InputChanges) Empty() bool {
+	return len(c.Created) == 0 && len(c.Updated) == 0 && len(c.Deleted) == 0
+}
+
+func (c *InputChanges) fail(title, err string) {
+	c.Failed = append(c.Failed, InputChangeFailure{Title: title, Error: err})
+}
+
+// ReconcileInputs makes the inputs match specs. Inputs no input has the title of yet are
+// created and started. Existing ones whose config drifted from their spec are updated and
+// restarted, with the config of the spec replacing theirs; their state and project are only
+// changed when the spec sets them. With prune, inputs whose title is in no spec are deleted.
+// Specs that fail are logged, skipped and listed under Failed.
+func (h *InputHandler) ReconcileInputs(ctx context.Context, specs []InputSpec, prune bool) InputChanges {
+	var changes InputChanges
+	if len(specs) == 0 && !prune {
+		return changes
+	}
+	list, err := h.InputRepo.List(ctx)
+	if err != nil {
+		log.Printf("[inputs] reconcile: list inputs: %v", err)
+		return changes
+	}
+	byTitle := make(map[string]model.Input, len(list))
+	for _, in := range list {
+		if !visibleID(ctx, in.ProjectID) {
+			continue // callers bound to a project neither update nor prune the inputs of others
+		}
+		if _, dup := byTitle[in.Title]; !dup {
+			byTitle[in.Title] = in
+		}
+	}
+	declared := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Title == "" {
+			log.Printf("[inputs] reconcile: %s input without a title: skipped", spec.Type)
+			changes.fail("", spec.Type+" input without a title")
+			continue
+		}
+		declared[spec.Title] = true
+		req := createInputRequest{Type: spec.Type, Title: spec.Title, Description: spec.Description, State: spec.State}
+		if len(spec.Config) > 0 {
+			if req.Config, err = json.Marshal(spec.Config); err != nil {
+				log.Printf("[inputs] reconcile %q: config: %v", spec.Title, err)
+				changes.fail(spec.Title, "config: "+err.Error())
+				continue
+			}
+		}
+		if spec.Project != "" {
+			req.ProjectID = &spec.Project
+		}
+		existing, ok := byTitle[spec.Title]
+		if !ok {
+			in, _, ingestKey, fail := h.createInput(ctx, req)
+			if fail != nil {
+				log.Printf("[inputs] reconcile %q: %s", spec.Title, fail)
+				changes.fail(spec.Title, fail.String())
+				continue
+			}
+			byTitle[in.Title] = in
+			changes.Created = append(changes.Created, in.Title)
+			if in.LastError != "" {
+				log.Printf("[inputs] reconcile %q: created but not started: %s", in.Title, in.LastError)
+			}
+			if ingestKey != "" {
+				log.Printf("[inputs] reconcile: created %s input %q (%s); get its ingest key with POST /inputs/%s/rotate-key", in.Type, in.Title, in.ID, in.ID)
+			} else {
+				log.Printf("[inputs] reconcile: created %s input %q (%s)", in.Type, in.Title, in.ID)
+			}
+			continue
+		}
+		if existing.Type != spec.Type {
+			log.Printf("[inputs] reconcile %q: is a %s input, declared %s: skipped (delete it to recreate it)", spec.Title, existing.Type, spec.Type)
+			changes.fail(spec.Title, fmt.Sprintf("is a %s input, declared %s (delete it to recreate it)", existing.Type, spec.Type))
+			continue
+		}
+		drift, err := h.inputDrift(ctx, existing, spec)
+		if err != nil {
+			log.Printf("[inputs] reconcile %q: %v", spec.Title, err)
+			changes.fail(spec.Title, err.Error())
+			continue
+		}
+		if len(drift) == 0 {
+			continue
+		}
+		req.replaceConfig = true
+		if _, fail := h.updateInput(ctx, &existing, req); fail != nil {
+			log.Printf("[inputs] reconcile %q: %s", spec.Title, fail)
+			changes.fail(spec.Title, fail.String())
+			continue
+		}
+		changes.Updated = append(changes.Updated, existing.Title)
+		if existing.LastError != "" {
+			log.Printf("[inputs] reconcile %q: updated but not started: %s", existing.Title, existing.LastError)
+		}
+		log.Printf("[inputs] reconcile: updated %s input %q (%s): %v", existing.Type, existing.Title, existing.ID, drift)
+	}
+	if prune {
+		for _, in := range list {
+			if declared[in.Title] || !visibleID(ctx, in.ProjectID) {
+				continue
+			}
+			if err := h.deleteInput(ctx, in); err != nil {
+				log.Printf("[inputs] reconcile: delete %q: %v", in.Title, err)
+				changes.fail(in.Title, "delete: "+err.Error())
+				continue
+			}
+			changes.Deleted = append(changes.Deleted, in.Title)
+			log.Printf("[inputs] reconcile: deleted %s input %q (%s): not declared", in.Type, in.Title, in.ID)
+		}
+	}
+	return changes
+}
+
+// inputDrift returns what of in differs from spec: config, state or project. State and
+// project only count when spec sets them.
+func (h *InputHandler) inputDrift(ctx context.Context, in model.Input, spec InputSpec) ([]string, error) {
+	var drift []string
+	want := make(inputs.Config, len(spec.Config)+2)
+	for k, v := range spec.Config {
+		want[k] = v
+	}
+	if spec.Description != "" {
+		want["description"] = spec.Description
+	}
+	if _, ok := want["base_path"]; !ok {
+		want["base_path"] = "/ingest"
+	}
+	// Secrets masked by GET /inputs/export keep their stored values, as on update.
+	secrets.KeepMasked(want, runtimeConfig(in))
+	// Compare as JSON, so that numbers read from the file and from the database are alike.
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	haveJSON, _ := json.Marshal(runtimeConfig(in))
+	if !bytes.Equal(wantJSON, haveJSON) {
+		drift = append(drift, "config")
+	}
+	if spec.State != "" {
+		if state, ok := parseState(spec.State, in.DesiredState); ok && state != in.DesiredState {
+			drift = append(drift, "state")
+		}
+	}
+	if spec.Project != "" {
+		projectID, msg, err := h.Projects.resolve(ctx, spec.Project)
+		if err != nil {
+			return nil, fmt.Errorf("get project: %w", err)
+		}
+		if msg == "" && (in.ProjectID == nil || *in.ProjectID != *projectID) {
+			drift = append(drift, "project")
+		}
+	}
+	return drift, nil
+}
+
+// BootstrapPipelines creates the pipelines of specs that no pipeline has the name of yet, and
+// reloads the Manager when it created any. Pipelines that exist are left as they are. Specs
+// that fail are logged and skipped.
+func (h *PipelineHandler) BootstrapPipelines(ctx context.Context, specs []PipelineSpec) {
+	if len(specs) == 0 {
+		return
+	}
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[pipeline] bootstrap: list pipelines: %v", err)
+		return
+	}
+	inputList, err := h.InputRepo.List(ctx)
+	if err != nil {
+		log.Printf("[pipeline] bootstrap: list inputs: %v", err)
+		return
+	}
+	names := make(map[string]bool, len(list))
+	for _, p := range list {
+		names[p.Name] = true
+	}
+	inputIDs := make(map[string]string, len(inputList))
+	for _, in := range inputList {
+		inputIDs[in.Title] = in.ID.String()
+	}
+	created := 0
+	for _, spec := range specs {
+		if names[spec.Name] {
+			continue
+		}
+		req := pipelineRequest{
+			Name:        spec.Name,
+			Description: spec.Description,
+			ProjectID:   spec.Project,
+			Enabled:     spec.Enabled,
+			Processors:  spec.Processors,
+		}
+		if spec.Input != "" {
+			id, ok := inputIDs[spec.Input]
+			if !ok {
+				log.Printf("[pipeline] bootstrap %q: no input titled %q: skipped", spec.Name, spec.Input)
+				continue
+			}
+			req.InputID = id
+		}
+		var p model.Pipeline
+		if msg, detail := h.apply(ctx, &p, req); msg != "" {
+			log.Printf("[pipeline] bootstrap %q: %s: %s", spec.Name, msg, detail)
+			continue
+		}
+		if err := h.Repo.Create(ctx, &p); err != nil {
+			log.Printf("[pipeline] bootstrap %q: create: %v", spec.Name, err)
+			continue
+		}
+		names[p.Name] = true
+		created++
+		log.Printf("[pipeline] bootstrap: created pipeline %q (%s)", p.Name, p.ID)
+	}
+	if created > 0 {
+		h.Reload(ctx)
+	}
+}
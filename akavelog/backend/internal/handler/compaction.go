@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/akave-ai/akavelog/internal/compaction"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// CompactionHandler handles /compaction. Manager is nil when O3 is not configured or
+// compaction is disabled; POST /compaction/run then answers 503.
+type CompactionHandler struct {
+	Streams *repository.StreamRepository
+	Manager *compaction.Manager
+}
+
+// Prefixes returns the key prefixes batch objects are stored under: logs and the o3_prefix of
+// every stream. It is what the Manager compacts on every run.
+func (h *CompactionHandler) Prefixes(ctx context.Context) ([]string, error) {
+	streams, err := h.Streams.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list streams: %w", err)
+	}
+	return storage.BatchPrefixes(streams), nil
+}
+
+// GetCompaction returns whether compaction is enabled, its settings and the last run
+// (GET /compaction).
+func (h *CompactionHandler) GetCompaction(c echo.Context) error {
+	if h.Manager == nil {
+		return response.OK(c, map[string]any{"enabled": false}, "")
+	}
+	cfg := h.Manager.Config()
+	return response.OK(c, map[string]any{
+		"enabled":      true,
+		"interval":     cfg.Interval.String(),
+		"min_age":      cfg.MinAge.String(),
+		"small_bytes":  cfg.SmallBytes,
+		"target_bytes": cfg.TargetBytes,
+		"min_objects":  cfg.MinObjects,
+		"codec":        cfg.Codec,
+		"last_run":     h.Manager.LastRun(),
+	}, "")
+}
+
+// Run compacts every day that is due now and returns the run's stats (POST /compaction/run).
+// With ?dry_run=true, it only reports the days it would compact.
+func (h *CompactionHandler) Run(c echo.Context) error {
+	if h.Manager == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "compaction not enabled", "compaction requires O3 storage and compaction.enabled")
+	}
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return response.BadRequest(c, "invalid dry_run", "dry_run must be true or false")
+		}
+		dryRun = b
+	}
+	st, err := h.Manager.Run(c.Request().Context(), dryRun)
+	if err != nil {
+		return response.InternalError(c, "compaction run failed", err.Error())
+	}
+	return response.OK(c, st, "compaction run finished")
+}
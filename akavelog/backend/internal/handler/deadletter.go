@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/akave-ai/akavelog/internal/deadletter"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// DeadLetterHandler handles /deadletter. Queue is nil when O3 is not configured; every
+// endpoint then answers 503.
+type DeadLetterHandler struct {
+	Queue     *deadletter.Queue
+	Pipelines *pipeline.Manager
+	Buffer    inputs.InputBuffer          // where replayed entries go after their pipelines
+	InputRepo *repository.InputRepository // optional; replayed entries take their input's project
+	Projects  *ProjectHandler             // optional; resolves the project_id of replayed entries
+}
+
+func (h *DeadLetterHandler) unavailable(c echo.Context) error {
+	return response.Error(c, http.StatusServiceUnavailable, "dead-letter queue not enabled", "the dead-letter queue requires O3 storage")
+}
+
+// List returns the dead-letter objects, newest first (GET /deadletter).
+func (h *DeadLetterHandler) List(c echo.Context) error {
+	if h.Queue == nil {
+		return h.unavailable(c)
+	}
+	objects, err := h.Queue.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list dead letters failed", "list dead letters: "+err.Error())
+	}
+	written, dropped := h.Queue.Counts()
+	return response.OK(c, map[string]any{"objects": objects, "written": written, "dropped": dropped}, "")
+}
+
+// Get returns the records of one object (GET /deadletter/:key).
+func (h *DeadLetterHandler) Get(c echo.Context) error {
+	if h.Queue == nil {
+		return h.unavailable(c)
+	}
+	key := c.Param("key")
+	records, err := h.Queue.Get(c.Request().Context(), key)
+	if err != nil {
+		return h.getError(c, err)
+	}
+	return response.OK(c, map[string]any{"key": key, "records": records}, "")
+}
+
+// Replay runs every record of an object through the pipelines of its input again and deletes
+// the object (POST /deadletter/:key/replay). Records that fail again are dead-lettered anew.
+// When the buffer pushes back, the replay stops and the object is kept.
+func (h *DeadLetterHandler) Replay(c echo.Context) error {
+	if h.Queue == nil {
+		return h.unavailable(c)
+	}
+	key := c.Param("key")
+	records, err := h.Queue.Get(c.Request().Context(), key)
+	if err != nil {
+		return h.getError(c, err)
+	}
+	buffers := make(map[uuid.UUID]*pipeline.Buffer)
+	for n, r := range records {
+		inputID, _ := uuid.Parse(r.InputID) // uuid.Nil runs the global pipelines
+		b := buffers[inputID]
+		if b == nil {
+			b, err = h.replayBuffer(c.Request().Context(), inputID)
+			if err != nil {
+				return response.InternalError(c, "replay failed", fmt.Sprintf("replayed %d of %d records: get input: %v", n, len(records), err))
+			}
+			buffers[inputID] = b
+		}
+		if err := b.Insert([]byte(r.Payload)); err != nil {
+			// Keep the object; replaying it again repeats the records already taken.
+			return response.Error(c, inputs.BackpressureStatus(err), "replay interrupted", fmt.Sprintf("replayed %d of %d records: %v", n, len(records), err))
+		}
+	}
+	if err := h.Queue.Delete(c.Request().Context(), key); err != nil {
+		log.Printf("[deadletter] delete replayed %s: %v", key, err)
+		return response.InternalError(c, "replayed but delete failed", "delete dead letter: "+err.Error())
+	}
+	return response.OK(c, map[string]any{"key": key, "replayed": len(records)}, "dead letters replayed")
+}
+
+// replayBuffer returns the buffer records of inputID are replayed through: the input's
+// pipelines, stamping its project when it still has one.
+func (h *DeadLetterHandler) replayBuffer(ctx context.Context, inputID uuid.UUID) (*pipeline.Buffer, error) {
+	b := &pipeline.Buffer{Manager: h.Pipelines, InputID: inputID, Next: h.Buffer, DeadLetter: h.Queue}
+	if h.Projects != nil {
+		b.Projects = h.Projects.Resolve
+	}
+	if h.InputRepo == nil || inputID == uuid.Nil {
+		return b, nil
+	}
+	in, err := h.InputRepo.GetByID(ctx, inputID)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil && in.ProjectID != nil {
+		b.ProjectID = in.ProjectID.String()
+	}
+	return b, nil
+}
+
+// Delete discards an object without replaying it (DELETE /deadletter/:key).
+func (h *DeadLetterHandler) Delete(c echo.Context) error {
+	if h.Queue == nil {
+		return h.unavailable(c)
+	}
+	key := c.Param("key")
+	if _, err := h.Queue.Get(c.Request().Context(), key); err != nil {
+		return h.getError(c, err)
+	}
+	if err := h.Queue.Delete(c.Request().Context(), key); err != nil {
+		return response.InternalError(c, "delete dead letter failed", "delete dead letter: "+err.Error())
+	}
+	return response.OK(c, nil, "dead letters deleted")
+}
+
+func (h *DeadLetterHandler) getError(c echo.Context, err error) error {
+	if errors.Is(err, deadletter.ErrNotFound) {
+		return response.NotFound(c, "dead letter not found", "no dead-letter object "+c.Param("key"))
+	}
+	return response.InternalError(c, "get dead letter failed", "get dead letter: "+err.Error())
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/events"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// EventHandler handles /events, the system events of akavelog and the webhooks told about them.
+type EventHandler struct {
+	Bus *events.Bus
+}
+
+// ListEvents returns the recent system events, oldest first (GET /events?type=&after=&since=&limit=).
+// type is a comma-separated list of types or families such as input.*; after is an event ID,
+// so a poller passes the last_id of its previous answer and gets only what is new. Without
+// after the newest limit events are returned, with it the oldest after it.
+func (h *EventHandler) ListEvents(c echo.Context) error {
+	f := events.Filter{Limit: defaultEventLimit}
+	if v := c.QueryParam("type"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			if !events.ValidPattern(p) {
+				return response.BadRequest(c, "invalid type", "unknown event type "+p+" (want one of "+strings.Join(events.Types, ", ")+", or a family such as input.*)")
+			}
+			f.Types = append(f.Types, p)
+		}
+	}
+	if v := c.QueryParam("after"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return response.BadRequest(c, "invalid after", "after must be an event ID")
+		}
+		f.After = n
+	}
+	var err error
+	if f.Since, err = queryTime(c, "since"); err != nil {
+		return response.BadRequest(c, "invalid since", "since must be an RFC 3339 time")
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxEventLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(maxEventLimit))
+		}
+		f.Limit = n
+	}
+	list := h.Bus.Recent(f)
+	lastID := f.After
+	if len(list) > 0 {
+		lastID = list[len(list)-1].ID
+	} else if f.After == 0 {
+		lastID = h.Bus.LastID()
+	}
+	return response.OK(c, map[string]any{"events": list, "count": len(list), "last_id": lastID}, "")
+}
+
+// ListWebhooks returns the webhooks subscribed to system events with their delivery counters
+// (GET /events/webhooks). Webhooks are set in the config file under events.webhooks.
+func (h *EventHandler) ListWebhooks(c echo.Context) error {
+	return response.OK(c, map[string]any{"webhooks": h.Bus.Webhooks()}, "")
+}
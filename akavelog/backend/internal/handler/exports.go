@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/export"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+const maxExportFields = 100
+
+// ExportHandler handles /exports, searches whose results are written to O3 in the
+// background. Manager is nil when O3 is not configured; every route then answers 503.
+type ExportHandler struct {
+	Manager *export.Manager
+}
+
+type exportRequest struct {
+	Query     string   `json:"query"`
+	ProjectID string   `json:"project_id"`
+	Start     string   `json:"start"`  // RFC 3339; default end - 24h
+	End       string   `json:"end"`    // RFC 3339; default now
+	Format    string   `json:"format"` // csv (default) or ndjson
+	Fields    []string `json:"fields"` // columns to write; default the whole entry
+	Limit     int      `json:"limit"`  // rows at most; default and at most the configured MAX_ROWS
+}
+
+// CreateExport starts an export and answers 202 with its job (POST /exports).
+func (h *ExportHandler) CreateExport(c echo.Context) error {
+	if h.Manager == nil {
+		return exportsUnavailable(c)
+	}
+	var req exportRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	opts, msg, detail := scanOptions(req.Query, req.ProjectID, req.Start, req.End)
+	if msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	format, err := export.ParseFormat(req.Format)
+	if err != nil {
+		return response.BadRequest(c, "invalid format", err.Error())
+	}
+	if len(req.Fields) > maxExportFields {
+		return response.BadRequest(c, "invalid fields", "fields takes at most "+strconv.Itoa(maxExportFields)+" names")
+	}
+	var fields []string
+	for _, f := range req.Fields {
+		if f = strings.TrimSpace(f); f == "" {
+			return response.BadRequest(c, "invalid fields", "field names must not be empty")
+		}
+		fields = append(fields, f)
+	}
+	if req.Limit < 0 {
+		return response.BadRequest(c, "invalid limit", "limit must not be negative")
+	}
+	job, err := h.Manager.Start(export.Request{
+		Query:     opts.Query,
+		ProjectID: opts.ProjectID,
+		Start:     opts.Start,
+		End:       opts.End,
+		Format:    format,
+		Fields:    fields,
+		MaxRows:   req.Limit,
+	})
+	if errors.Is(err, export.ErrTooManyJobs) {
+		return response.Error(c, http.StatusTooManyRequests, "too many running exports", "wait for a running export to finish or cancel one")
+	}
+	if err != nil {
+		return response.InternalError(c, "start export failed", err.Error())
+	}
+	snap, err := h.Manager.Snapshot(c.Request().Context(), job)
+	if err != nil {
+		return response.InternalError(c, "start export failed", err.Error())
+	}
+	return response.Accepted(c, snap, "export running; poll GET /exports/"+job.ID())
+}
+
+// ListExports returns the exports still kept, newest first (GET /exports). Callers bound to a
+// project get those of that project.
+func (h *ExportHandler) ListExports(c echo.Context) error {
+	if h.Manager == nil {
+		return exportsUnavailable(c)
+	}
+	jobs := h.Manager.List()
+	out := make([]export.Snapshot, 0, len(jobs))
+	for _, j := range jobs {
+		if !visible(c.Request().Context(), j.ProjectID()) {
+			continue
+		}
+		snap, err := h.Manager.Snapshot(c.Request().Context(), j)
+		if err != nil {
+			return response.InternalError(c, "list exports failed", err.Error())
+		}
+		out = append(out, snap)
+	}
+	return response.OK(c, map[string]any{"exports": out}, "")
+}
+
+// GetExport returns an export's status and, once it is done, a download URL
+// (GET /exports/:id).
+func (h *ExportHandler) GetExport(c echo.Context) error {
+	if h.Manager == nil {
+		return exportsUnavailable(c)
+	}
+	job := h.Manager.Get(c.Param("id"))
+	if job == nil || !visible(c.Request().Context(), job.ProjectID()) {
+		return response.NotFound(c, "export not found", "no export with this id; exports are kept for a limited time")
+	}
+	snap, err := h.Manager.Snapshot(c.Request().Context(), job)
+	if err != nil {
+		return response.InternalError(c, "get export failed", err.Error())
+	}
+	return response.OK(c, snap, "")
+}
+
+// DeleteExport cancels a running export or deletes a finished one (DELETE /exports/:id).
+func (h *ExportHandler) DeleteExport(c echo.Context) error {
+	if h.Manager == nil {
+		return exportsUnavailable(c)
+	}
+	if job := h.Manager.Get(c.Param("id")); job == nil || !visible(c.Request().Context(), job.ProjectID()) {
+		return response.NotFound(c, "export not found", "no export with this id; exports are kept for a limited time")
+	}
+	found, err := h.Manager.Cancel(c.Request().Context(), c.Param("id"))
+	if !found {
+		return response.NotFound(c, "export not found", "no export with this id; exports are kept for a limited time")
+	}
+	if err != nil {
+		return response.InternalError(c, "delete export failed", err.Error())
+	}
+	return response.OK(c, nil, "export deleted")
+}
+
+func exportsUnavailable(c echo.Context) error {
+	return response.Error(c, http.StatusServiceUnavailable, "exports not available", "exports require O3 storage")
+}
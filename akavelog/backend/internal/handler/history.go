@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/labstack/echo/v4"
+)
+
+const recordRunTimeout = 5 * time.Second
+
+// QueryHistory records the runs of searches, aggregations and SQL statements.
+type QueryHistory interface {
+	RecordRun(ctx context.Context, run *model.QueryRun) error
+}
+
+// recordRun adds run to history, when there is one. A failure is only logged: the history
+// must not fail the query it records.
+func recordRun(history QueryHistory, run *model.QueryRun, err error) {
+	if history == nil {
+		return
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recordRunTimeout)
+	defer cancel()
+	if err := history.RecordRun(ctx, run); err != nil {
+		log.Printf("[query] record %s run: %v", run.Kind, err)
+	}
+}
+
+// requestOwner returns the user a request is made for, who owns the searches it saves and
+// the runs it records. Requests are not authenticated yet, so it is always "": every saved
+// search and run is visible to all.
+func requestOwner(echo.Context) string {
+	return ""
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/google/uuid"
+)
+
+// inputKeysRefresh is how often the keys of an input are looked up again, so keys issued and
+// rotated on another server apply here too.
+const inputKeysRefresh = 30 * time.Second
+
+// InputKeys holds the valid ingest keys of every input for their listeners. Keys change
+// while inputs run, so a key is rotated without restarting the listener. Until the keys of an
+// input were looked up once, by Reload or on its own, whether it requires any is unknown and
+// its listener refuses requests.
+type InputKeys struct {
+	Repo *repository.InputKeyRepository
+
+	mu       sync.RWMutex
+	keys     map[uuid.UUID][]model.InputKey
+	fetched  map[uuid.UUID]time.Time // last lookup, failed or not
+	loaded   bool                    // Reload succeeded: inputs without keys have none
+	known    map[uuid.UUID]bool      // inputs whose keys were looked up since
+	required map[uuid.UUID]bool      // inputs issued a key here, which keep requiring one
+}
+
+// Reload loads the valid keys of every input.
+func (k *InputKeys) Reload(ctx context.Context) {
+	list, err := k.Repo.ListValid(ctx)
+	if err != nil {
+		log.Printf("[inputs] load ingest keys: %v", err)
+		return
+	}
+	keys := make(map[uuid.UUID][]model.InputKey)
+	fetched := make(map[uuid.UUID]time.Time)
+	now := time.Now()
+	for _, key := range list {
+		keys[key.InputID] = append(keys[key.InputID], key)
+		fetched[key.InputID] = now
+	}
+	k.mu.Lock()
+	k.keys, k.fetched, k.loaded, k.known = keys, fetched, true, make(map[uuid.UUID]bool)
+	k.mu.Unlock()
+}
+
+// Of returns the keys of input id for its listener, or nil when k is nil.
+func (k *InputKeys) Of(id uuid.UUID) inputs.IngestKeys {
+	if k == nil {
+		return nil
+	}
+	return inputKeysOf{k, id}
+}
+
+// Issue generates a key and makes it the current key of input id. The input's previous keys
+// keep working for grace. It returns the key, shown only now.
+func (k *InputKeys) Issue(ctx context.Context, id uuid.UUID, grace time.Duration) (string, *model.InputKey, error) {
+	key, prefix, hash, err := middleware.GenerateIngestKey()
+	if err != nil {
+		return "", nil, err
+	}
+	ik := &model.InputKey{InputID: id, Prefix: prefix, Hash: hash}
+	if err := k.Repo.Rotate(ctx, ik, time.Now().Add(grace)); err != nil {
+		return "", nil, err
+	}
+	k.mu.Lock()
+	if k.required == nil {
+		k.required = make(map[uuid.UUID]bool)
+	}
+	k.required[id] = true
+	k.mu.Unlock()
+	k.refresh(ctx, id)
+	return key, ik, nil
+}
+
+// Forget drops the keys of a deleted input.
+func (k *InputKeys) Forget(id uuid.UUID) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	delete(k.keys, id)
+	delete(k.fetched, id)
+	delete(k.known, id)
+	delete(k.required, id)
+	k.mu.Unlock()
+}
+
+// refresh loads the valid keys of input id again.
+func (k *InputKeys) refresh(ctx context.Context, id uuid.UUID) {
+	list, err := k.Repo.ListValidByInput(ctx, id)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys == nil {
+		k.keys, k.fetched = make(map[uuid.UUID][]model.InputKey), make(map[uuid.UUID]time.Time)
+	}
+	if k.known == nil {
+		k.known = make(map[uuid.UUID]bool)
+	}
+	k.fetched[id] = time.Now()
+	if err != nil {
+		// The keys loaded before, if any, stay in force.
+		log.Printf("[inputs] load ingest keys of %s: %v", id, err)
+		return
+	}
+	k.known[id] = true
+	if len(list) == 0 {
+		delete(k.keys, id)
+		delete(k.required, id)
+		return
+	}
+	k.keys[id] = list
+}
+
+// inputKeysOf is the inputs.IngestKeys of one input.
+type inputKeysOf struct {
+	k  *InputKeys
+	id uuid.UUID
+}
+
+// Enabled reports whether the input has keys. It fails when they were never looked up
+// successfully, or a key was issued but could not be loaded since, so a database outage does
+// not open the listener to unauthenticated requests.
+func (o inputKeysOf) Enabled() (bool, error) {
+	o.fresh()
+	o.k.mu.RLock()
+	defer o.k.mu.RUnlock()
+	switch {
+	case len(o.k.keys[o.id]) > 0:
+		return true, nil
+	case o.k.required[o.id] || !(o.k.loaded || o.k.known[o.id]):
+		return false, inputs.ErrKeysUnavailable
+	}
+	return false, nil
+}
+
+func (o inputKeysOf) Accepts(key string) bool {
+	o.fresh()
+	return o.match(middleware.HashKey(key))
+}
+
+// fresh loads the keys of the input again when they were loaded inputKeysRefresh ago.
+func (o inputKeysOf) fresh() {
+	o.k.mu.RLock()
+	stale := time.Since(o.k.fetched[o.id]) >= inputKeysRefresh
+	o.k.mu.RUnlock()
+	if stale {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		o.k.refresh(ctx, o.id)
+	}
+}
+
+func (o inputKeysOf) match(hash string) bool {
+	now := time.Now()
+	o.k.mu.RLock()
+	defer o.k.mu.RUnlock()
+	for _, key := range o.k.keys[o.id] {
+		if key.Hash == hash && (key.ExpiresAt == nil || now.Before(*key.ExpiresAt)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestInputKeysFailClosed(t *testing.T) {
+	id := uuid.New()
+	// A lookup was just attempted, so Enabled answers from what is cached.
+	k := &InputKeys{fetched: map[uuid.UUID]time.Time{id: time.Now()}}
+	enabled := func() (bool, error) { return k.Of(id).Enabled() }
+
+	if _, err := enabled(); !errors.Is(err, inputs.ErrKeysUnavailable) {
+		t.Fatalf("never loaded: err = %v, want ErrKeysUnavailable", err)
+	}
+	k.loaded = true
+	if on, err := enabled(); on || err != nil {
+		t.Fatalf("loaded without keys: %v, %v", on, err)
+	}
+	k.required = map[uuid.UUID]bool{id: true}
+	if _, err := enabled(); !errors.Is(err, inputs.ErrKeysUnavailable) {
+		t.Fatalf("key issued but not loaded: err = %v, want ErrKeysUnavailable", err)
+	}
+	k.keys = map[uuid.UUID][]model.InputKey{id: {{InputID: id, Hash: "h"}}}
+	if on, err := enabled(); !on || err != nil {
+		t.Fatalf("with keys: %v, %v", on, err)
+	}
+}
@@ -3,16 +3,25 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/akave-ai/akavelog/internal/events"
 	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/metrics"
 	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/recent"
 	"github.com/akave-ai/akavelog/internal/repository"
 	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/secrets"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -22,26 +31,66 @@ import (
 type InputHandler struct {
 	Registry      *inputs.Registry
 	Buffer        inputs.InputBuffer
+	Pipelines     *pipeline.Manager   // optional; entries pass through unchanged when nil
+	DeadLetter    pipeline.DeadLetter // optional; receives payloads pipelines could not store
 	InputRepo     *repository.InputRepository
+	Projects      *ProjectHandler    // optional; resolves and validates project_id
+	Keys          *InputKeys         // optional; ingest keys of the listeners
+	Events        *events.Bus        // optional; told when inputs fail to start, crash or recover
+	Recent        *recent.Store      // optional; keeps the latest entries of each input
+	IDs           *pipeline.IDWindow // optional; drops entries resubmitted with the same _id
 	Instances     map[uuid.UUID]InstanceRecord
 	InstancesMu   sync.Mutex
 	MountIngest   func(path string, h http.Handler)
 	UnmountIngest func(path string)
 }
 
-// InstanceRecord holds a persisted input and its running MessageInput.
+// InstanceRecord holds a persisted input, its running MessageInput and the runtime metrics
+// fed by the MeteredBuffer the input was created with.
 type InstanceRecord struct {
-	Input model.Input
-	Run   inputs.MessageInput
+	Input   model.Input
+	Run     inputs.MessageInput
+	Metrics *inputs.Metrics
+
+	// Maintained by the supervisor: the last Health error, how often the input was restarted,
+	// and the backoff before the next restart attempt.
+	LastError   string
+	Restarts    int
+	failures    int
+	nextRestart time.Time
 }
 
 type inputInstanceResponse struct {
 	ID            string          `json:"id"`
 	Type          string          `json:"type"`
 	Title         string          `json:"title"`
+	ProjectID     string          `json:"project_id,omitempty"`
 	Configuration json.RawMessage `json:"configuration"`
 	CreatedAt     string          `json:"created_at"`
+	UpdatedAt     string          `json:"updated_at"`
+	Version       int64           `json:"version"` // send it back in If-Match or version to update the input
 	State         string          `json:"state"`
+	Health        string          `json:"health,omitempty"` // healthy or unhealthy; running inputs only
+	LastError     string          `json:"last_error,omitempty"`
+	LastErrorAt   string          `json:"last_error_at,omitempty"`
+	Restarts      int             `json:"restarts,omitempty"`
+	IngestKey     string          `json:"ingest_key,omitempty"` // POST /inputs only
+}
+
+// inputListSpec filters GET /inputs by type, state and project_id; q searches titles.
+var inputListSpec = listSpec[inputInstanceResponse]{
+	text: func(in inputInstanceResponse) string { return in.Title },
+	filters: map[string]func(inputInstanceResponse, string) bool{
+		"type":       equalFold(func(in inputInstanceResponse) string { return in.Type }),
+		"state":      equalFold(func(in inputInstanceResponse) string { return in.State }),
+		"project_id": equalFold(func(in inputInstanceResponse) string { return in.ProjectID }),
+	},
+	sorts: map[string]func(a, b inputInstanceResponse) int{
+		"title":      byString(func(in inputInstanceResponse) string { return in.Title }),
+		"type":       byString(func(in inputInstanceResponse) string { return in.Type }),
+		"state":      byString(func(in inputInstanceResponse) string { return in.State }),
+		"created_at": byString(func(in inputInstanceResponse) string { return in.CreatedAt }),
+	},
 }
 
 type createInputRequest struct {
@@ -50,6 +99,46 @@ type createInputRequest struct {
 	Description string          `json:"description"`
 	Listen      string          `json:"listen"`
 	Config      json.RawMessage `json:"config"`
+	State       string          `json:"state"`      // optional RUNNING, STOPPED or PAUSED
+	ProjectID   *string         `json:"project_id"` // optional; "" on update removes the project
+	Version     *int64          `json:"version"`    // update only: the version the update is based on, unless If-Match is sent
+
+	replaceConfig bool // update only: Config replaces the stored config instead of being merged into it
+}
+
+func newInputResponse(in model.Input, state model.InputState) inputInstanceResponse {
+	out := inputInstanceResponse{
+		ID:            in.ID.String(),
+		Type:          in.Type,
+		Title:         in.Title,
+		Configuration: secrets.RedactJSON(in.Configuration),
+		CreatedAt:     in.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:     in.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Version:       in.Version,
+		State:         string(state),
+	}
+	if state == model.InputStateFailed {
+		out.LastError = in.LastError
+	}
+	if in.ProjectID != nil {
+		out.ProjectID = in.ProjectID.String()
+	}
+	return out
+}
+
+// parseState maps a request state onto an InputState. Empty returns ok with fallback.
+func parseState(s string, fallback model.InputState) (model.InputState, bool) {
+	switch model.InputState(strings.ToUpper(strings.TrimSpace(s))) {
+	case "":
+		return fallback, true
+	case model.InputStateRunning:
+		return model.InputStateRunning, true
+	case model.InputStateStopped:
+		return model.InputStateStopped, true
+	case model.InputStatePaused:
+		return model.InputStatePaused, true
+	}
+	return "", false
 }
 
 // ListTypes returns registered input type names (GET /inputs/types).
@@ -78,7 +167,8 @@ func (h *InputHandler) GetTypeInfo(c echo.Context) error {
 	return response.OK(c, info, "")
 }
 
-// ListInputs returns all inputs from the database (GET /inputs).
+// ListInputs returns all inputs from the database (GET /inputs), or those of the project the
+// caller is bound to.
 func (h *InputHandler) ListInputs(c echo.Context) error {
 	list, err := h.InputRepo.List(c.Request().Context())
 	if err != nil {
@@ -87,22 +177,30 @@ func (h *InputHandler) ListInputs(c echo.Context) error {
 	out := make([]inputInstanceResponse, 0, len(list))
 	h.InstancesMu.Lock()
 	for _, in := range list {
+		if !visibleID(c.Request().Context(), in.ProjectID) {
+			continue
+		}
 		rec, running := h.Instances[in.ID]
-		state := string(in.DesiredState)
+		item := newInputResponse(in, in.DesiredState)
 		if running && rec.Run != nil {
-			state = "RUNNING"
-		}
-		out = append(out, inputInstanceResponse{
-			ID:            in.ID.String(),
-			Type:          in.Type,
-			Title:         in.Title,
-			Configuration: in.Configuration,
-			CreatedAt:     in.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			State:         state,
-		})
+			item.State = string(model.InputStateRunning)
+			item.Health = "healthy"
+			if rec.LastError != "" {
+				item.Health = "unhealthy"
+				item.LastError = rec.LastError
+			}
+			item.Restarts = rec.Restarts
+		} else if in.DesiredState == model.InputStateRunning && in.LastError != "" {
+			item.State = string(model.InputStateFailed)
+			item.LastError = in.LastError
+			if in.LastErrorAt != nil {
+				item.LastErrorAt = in.LastErrorAt.Format("2006-01-02T15:04:05Z07:00")
+			}
+		}
+		out = append(out, item)
 	}
 	h.InstancesMu.Unlock()
-	return response.OK(c, map[string]any{"inputs": out}, "")
+	return listResponse(c, "inputs", out, inputListSpec)
 }
 
 // CreateInput creates an input, persists it, and starts it (POST /inputs).
@@ -111,12 +209,48 @@ func (h *InputHandler) CreateInput(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body", "invalid JSON body")
 	}
+	in, state, ingestKey, fail := h.createInput(c.Request().Context(), req)
+	if fail != nil {
+		return response.Error(c, fail.status, fail.message, fail.detail)
+	}
+	out := newInputResponse(in, state)
+	out.IngestKey = ingestKey
+	if ingestKey != "" {
+		return response.Created(c, out, "input created; store the ingest_key now, it is not shown again")
+	}
+	return response.Created(c, out, "input created")
+}
+
+// inputFailure is why an input could not be created: the status, message and detail of the
+// error response.
+type inputFailure struct {
+	status          int
+	message, detail string
+}
+
+func (f *inputFailure) String() string {
+	return f.message + ": " + f.detail
+}
+
+// createInput validates req, persists the input, issues its ingest key if its type takes
+// them, and starts it unless it is created stopped or paused.
+func (h *InputHandler) createInput(ctx context.Context, req createInputRequest) (model.Input, model.InputState, string, *inputFailure) {
+	badRequest := func(message, detail string) (model.Input, model.InputState, string, *inputFailure) {
+		return model.Input{}, "", "", &inputFailure{http.StatusBadRequest, message, detail}
+	}
+	internalError := func(message, detail string) (model.Input, model.InputState, string, *inputFailure) {
+		return model.Input{}, "", "", &inputFailure{http.StatusInternalServerError, message, detail}
+	}
 	if req.Type == "" {
-		return response.BadRequest(c, "missing type", "missing 'type'")
+		return badRequest("missing type", "missing 'type'")
 	}
 	if req.Title == "" {
 		req.Title = "input-" + uuid.New().String()[:8]
 	}
+	state, ok := parseState(req.State, model.InputStateRunning)
+	if !ok {
+		return badRequest("invalid state", "state must be one of RUNNING, STOPPED, PAUSED")
+	}
 
 	cfg := make(inputs.Config)
 	if len(req.Config) > 0 {
@@ -132,36 +266,31 @@ func (h *InputHandler) CreateInput(c echo.Context) error {
 		cfg["listen"] = req.Listen
 	}
 	if req.Type == "http" && cfg["listen"] == nil {
-		return response.BadRequest(c, "listen is required", "http input must have a listen port (e.g. :9001); nothing is mounted on the main server")
+		return badRequest("listen is required", "http input must have a listen port (e.g. :9001); nothing is mounted on the main server")
+	}
+	secrets.KeepMasked(cfg, nil)
+	if keys := refusedRefs(ctx, cfg, nil); len(keys) > 0 {
+		return model.Input{}, "", "", &inputFailure{http.StatusForbidden, "insufficient scope", refsDetail(keys)}
 	}
 	cfgJSON, err := json.Marshal(cfg)
 	if err != nil {
-		return response.BadRequest(c, "invalid config", "build config: "+err.Error())
+		return badRequest("invalid config", "build config: "+err.Error())
 	}
 
 	// Validate config via factory
 	if err := h.Registry.ValidateConfig(req.Type, cfg); err != nil {
-		return response.BadRequest(c, "invalid config", err.Error())
+		return badRequest("invalid config", err.Error())
 	}
+	warnPlaintextSecrets("inputs", req.Title, cfg)
 
-	// For http: ensure the same port is not already in use
-	if req.Type == "http" {
-		listen, _ := cfg["listen"].(string)
-		existing, err := h.InputRepo.List(c.Request().Context())
+	// Ensure the same port is not already in use by another input
+	if listen, _ := cfg["listen"].(string); listen != "" {
+		inUse, err := h.listenInUse(ctx, listen, uuid.Nil)
 		if err != nil {
-			return response.InternalError(c, "list inputs failed", "list inputs: "+err.Error())
+			return internalError("list inputs failed", "list inputs: "+err.Error())
 		}
-		for _, ex := range existing {
-			if ex.Type != "http" {
-				continue
-			}
-			var exCfg map[string]interface{}
-			if len(ex.Configuration) > 0 {
-				_ = json.Unmarshal(ex.Configuration, &exCfg)
-			}
-			if exListen, _ := exCfg["listen"].(string); exListen != "" && exListen == listen {
-				return response.Error(c, 409, "listen address already in use", "listen "+listen+" is already used by another input")
-			}
+		if inUse {
+			return model.Input{}, "", "", &inputFailure{http.StatusConflict, "listen address already in use", "listen " + listen + " is already used by another input"}
 		}
 	}
 
@@ -169,34 +298,120 @@ func (h *InputHandler) CreateInput(c echo.Context) error {
 		Type:          req.Type,
 		Title:         req.Title,
 		Configuration: cfgJSON,
-		DesiredState:  model.InputStateRunning,
+		DesiredState:  state,
 	}
-	if err := h.InputRepo.Create(c.Request().Context(), &in); err != nil {
-		return response.InternalError(c, "create input failed", "create input: "+err.Error())
+	if bound := callerProject(ctx); req.ProjectID == nil && bound != "" {
+		req.ProjectID = &bound
 	}
-
-	run, err := h.Registry.Create(req.Type, cfg, h.Buffer)
-	if err != nil {
-		return response.BadRequest(c, "create input runtime failed", "create input runtime: "+err.Error())
+	if req.ProjectID != nil {
+		projectID, msg, err := h.Projects.resolve(ctx, *req.ProjectID)
+		if err != nil {
+			return internalError("create input failed", "get project: "+err.Error())
+		}
+		if msg != "" {
+			return badRequest("invalid project_id", msg)
+		}
+		if !visibleID(ctx, projectID) {
+			return model.Input{}, "", "", &inputFailure{http.StatusForbidden, "project not allowed", "you may only create inputs of project " + callerProject(ctx)}
+		}
+		in.ProjectID = projectID
+	}
+	// The runtime is built before the input is stored, so a config its type cannot run is
+	// refused without leaving an input behind.
+	var run inputs.MessageInput
+	var metrics *inputs.Metrics
+	if state == model.InputStateRunning {
+		in.ID = uuid.New()
+		if run, metrics, err = h.newRuntime(in, cfg); err != nil {
+			return badRequest("create input runtime failed", "create input runtime: "+err.Error())
+		}
 	}
-	if err := run.Start(); err != nil {
-		return response.InternalError(c, "start input failed", "start input: "+err.Error())
+	if err := h.InputRepo.Create(ctx, &in); err != nil {
+		return internalError("create input failed", "create input: "+err.Error())
+	}
+	var ingestKey string
+	if info, _ := h.Registry.GetTypeInfo(in.Type); info.IngestKeys && h.Keys != nil {
+		if ingestKey, _, err = h.Keys.Issue(ctx, in.ID, 0); err != nil {
+			if derr := h.InputRepo.Delete(ctx, in.ID); derr != nil {
+				log.Printf("[inputs] remove %s after its key was not issued: %v", in.Title, derr)
+			}
+			return internalError("create input failed", "issue ingest key: "+err.Error())
+		}
 	}
 
-	// No mounting on main server: each input runs on its own listen port only
+	// Stopped and paused inputs are only persisted; POST /inputs/:id/start runs them later.
+	// The input is stored by now, so one that does not start is reported FAILED, as on restore.
+	if run != nil {
+		state = h.startRuntime(ctx, &in, run, metrics)
+	}
+	return in, state, ingestKey, nil
+}
 
-	h.InstancesMu.Lock()
-	h.Instances[in.ID] = InstanceRecord{Input: in, Run: run}
-	h.InstancesMu.Unlock()
+// runtimeConfig decodes the persisted configuration of in, with the default base_path.
+func runtimeConfig(in model.Input) inputs.Config {
+	cfg := make(inputs.Config)
+	if len(in.Configuration) > 0 {
+		_ = json.Unmarshal(in.Configuration, &cfg)
+	}
+	if _, ok := cfg["base_path"]; !ok {
+		cfg["base_path"] = "/ingest"
+	}
+	return cfg
+}
 
-	return response.Created(c, inputInstanceResponse{
-		ID:            in.ID.String(),
-		Type:          in.Type,
-		Title:         in.Title,
-		Configuration: in.Configuration,
-		CreatedAt:     in.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		State:         "RUNNING",
-	}, "input created")
+// newRuntime creates a MessageInput whose buffer counts messages and bytes into fresh Metrics
+// and, when Pipelines is set, stamps entries with the project of in and runs them through
+// the pipelines of in, keeping the entries they pass in Recent. Inputs that take ingest keys
+// check the keys of in. The input gets the ID of in under inputs.InputIDKey.
+func (h *InputHandler) newRuntime(in model.Input, cfg inputs.Config) (inputs.MessageInput, *inputs.Metrics, error) {
+	metrics := inputs.NewInputMetrics(in.ID.String(), in.Type)
+	buffer := h.Buffer
+	if h.Pipelines != nil {
+		b := &pipeline.Buffer{Manager: h.Pipelines, InputID: in.ID, Next: buffer, DeadLetter: h.DeadLetter}
+		if in.ProjectID != nil {
+			b.ProjectID = in.ProjectID.String()
+		}
+		if h.Projects != nil {
+			b.Projects = h.Projects.Resolve
+		}
+		b.IDs = h.IDs
+		if h.Recent != nil {
+			inputID := in.ID.String()
+			b.OnEntry = func(e *model.LogEntry) { h.Recent.Add(inputID, e) }
+		}
+		buffer = b
+	}
+	withID := make(inputs.Config, len(cfg)+1)
+	for k, v := range cfg {
+		withID[k] = v
+	}
+	withID[inputs.InputIDKey] = in.ID.String()
+	run, err := h.Registry.Create(in.Type, withID, &inputs.MeteredBuffer{InputBuffer: buffer, Metrics: metrics})
+	if keyed, ok := run.(inputs.KeyedInput); ok && h.Keys != nil {
+		keyed.SetIngestKeys(h.Keys.Of(in.ID))
+	}
+	return run, metrics, err
+}
+
+// listenInUse reports whether any persisted input other than exclude is bound to listen.
+func (h *InputHandler) listenInUse(ctx context.Context, listen string, exclude uuid.UUID) (bool, error) {
+	existing, err := h.InputRepo.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, ex := range existing {
+		if ex.ID == exclude {
+			continue
+		}
+		var exCfg map[string]interface{}
+		if len(ex.Configuration) > 0 {
+			_ = json.Unmarshal(ex.Configuration, &exCfg)
+		}
+		if exListen, _ := exCfg["listen"].(string); exListen != "" && exListen == listen {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // stopAndUnmount stops the running input and unmounts its path if it is an HTTP endpoint.
@@ -215,10 +430,22 @@ func (h *InputHandler) stopAndUnmount(rec InstanceRecord) {
 	}
 }
 
-// UpdateInput updates an input by id (PUT /inputs/:id). Stops existing instance, updates DB, restarts.
+// UpdateInput replaces the definition of an input (PUT /inputs/:id): the config sent replaces
+// the stored one. The update must name the version of the input it is based on, in If-Match
+// or the version field; 428 without it and 409 when the input was changed since.
 func (h *InputHandler) UpdateInput(c echo.Context) error {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
+	return h.update(c, false)
+}
+
+// PatchInput updates some fields of an input (PATCH /inputs/:id): fields left out are kept and
+// config is a JSON merge patch (RFC 7386) of the stored config, where null removes a key. The
+// version is checked like PUT when given, but is optional.
+func (h *InputHandler) PatchInput(c echo.Context) error {
+	return h.update(c, true)
+}
+
+func (h *InputHandler) update(c echo.Context, patch bool) error {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return response.BadRequest(c, "invalid id", "invalid id")
 	}
@@ -227,32 +454,156 @@ func (h *InputHandler) UpdateInput(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body", "invalid JSON body")
 	}
+	version, ok, err := expectedVersion(c.Request().Header.Get("If-Match"), req.Version)
+	if err != nil {
+		return response.BadRequest(c, "invalid If-Match", err.Error())
+	}
+	if !ok && !patch {
+		return response.Error(c, http.StatusPreconditionRequired, "version required",
+			"send the version of the input the update is based on in If-Match or version")
+	}
 
 	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
-	if err != nil || in == nil {
+	if err != nil || in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
 		return response.NotFound(c, "input not found", "input not found")
 	}
+	if ok && version != in.Version {
+		return versionConflict(c, in.Version, version)
+	}
+	if patch {
+		if len(req.Config) > 0 {
+			merged, err := mergePatch(in.Configuration, req.Config)
+			if err != nil {
+				return response.BadRequest(c, "invalid config", err.Error())
+			}
+			req.Config = merged
+		} else {
+			req.Config = in.Configuration
+		}
+	}
+	req.replaceConfig = true
+	state, fail := h.updateInput(c.Request().Context(), in, req)
+	if fail != nil {
+		return response.Error(c, fail.status, fail.message, fail.detail)
+	}
+	c.Response().Header().Set("ETag", inputETag(in.Version))
+	return response.OK(c, newInputResponse(*in, state), "input updated")
+}
 
-	// Stop and unmount existing instance if running
-	h.InstancesMu.Lock()
-	rec, running := h.Instances[in.ID]
-	if running {
-		h.stopAndUnmount(rec)
-		delete(h.Instances, in.ID)
+// expectedVersion returns the version of an input an update is based on: the If-Match header,
+// an ETag as PUT and PATCH answer or a bare number, or else the version of the body. ok is
+// false when neither names one; If-Match: * names none either but does not need one.
+func expectedVersion(ifMatch string, body *int64) (version int64, ok bool, err error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		if body == nil {
+			return 0, false, nil
+		}
+		return *body, true, nil
+	}
+	if ifMatch == "*" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("If-Match must be the version of the input, e.g. \"3\"")
+	}
+	return v, true, nil
+}
+
+func inputETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func versionConflict(c echo.Context, current, expected int64) error {
+	c.Response().Header().Set("ETag", inputETag(current))
+	return response.Error(c, http.StatusConflict, "input was changed",
+		fmt.Sprintf("the input is at version %d, the update is based on version %d: get it again and retry", current, expected))
+}
+
+// mergePatch applies the JSON merge patch patch (RFC 7386) to the object doc.
+func mergePatch(doc, patch json.RawMessage) (json.RawMessage, error) {
+	target := map[string]any{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, fmt.Errorf("stored config: %w", err)
+		}
+	}
+	var p map[string]any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("config must be a JSON object: %w", err)
+	}
+	if p == nil {
+		// A null patch would replace the config with null.
+		return nil, fmt.Errorf("config must be a JSON object, not null")
+	}
+	return json.Marshal(mergeObject(target, p))
+}
+
+func mergeObject(target, patch map[string]any) map[string]any {
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(target, k)
+		case map[string]any:
+			sub, _ := target[k].(map[string]any)
+			if sub == nil {
+				sub = map[string]any{}
+			}
+			target[k] = mergeObject(sub, v)
+		default:
+			target[k] = v
+		}
+	}
+	return target
+}
+
+// updateInput applies req to in, persists it if in is still at in.Version and restarts it
+// unless it is now stopped or paused. The running instance is stopped only once the update is
+// stored. The config of req is merged into the one of in, or
+// replaces it when req.replaceConfig is set.
+func (h *InputHandler) updateInput(ctx context.Context, in *model.Input, req createInputRequest) (model.InputState, *inputFailure) {
+	badRequest := func(message, detail string) (model.InputState, *inputFailure) {
+		return "", &inputFailure{http.StatusBadRequest, message, detail}
+	}
+	internalError := func(message, detail string) (model.InputState, *inputFailure) {
+		return "", &inputFailure{http.StatusInternalServerError, message, detail}
+	}
+	state, ok := parseState(req.State, in.DesiredState)
+	if !ok {
+		return badRequest("invalid state", "state must be one of RUNNING, STOPPED, PAUSED")
+	}
+	if req.ProjectID != nil {
+		projectID, msg, err := h.Projects.resolve(ctx, *req.ProjectID)
+		if err != nil {
+			return internalError("update input failed", "get project: "+err.Error())
+		}
+		if msg != "" {
+			return badRequest("invalid project_id", msg)
+		}
+		if !visibleID(ctx, projectID) {
+			return "", &inputFailure{http.StatusForbidden, "project not allowed", "you may only move inputs to project " + callerProject(ctx)}
+		}
+		in.ProjectID = projectID
 	}
-	h.InstancesMu.Unlock()
 
 	// Build new config (same as CreateInput)
 	if req.Title != "" {
 		in.Title = req.Title
 	}
+	prev := runtimeConfig(*in)
 	cfg := make(inputs.Config)
-	if len(in.Configuration) > 0 {
+	if len(in.Configuration) > 0 && !req.replaceConfig {
 		_ = json.Unmarshal(in.Configuration, &cfg)
 	}
 	if len(req.Config) > 0 {
 		_ = json.Unmarshal(req.Config, &cfg)
 	}
+	// Secrets shown masked by GET /inputs keep their stored values when sent back.
+	secrets.KeepMasked(cfg, prev)
+	if keys := refusedRefs(ctx, cfg, prev); len(keys) > 0 {
+		return "", &inputFailure{http.StatusForbidden, "insufficient scope", refsDetail(keys)}
+	}
 	if req.Description != "" {
 		cfg["description"] = req.Description
 	}
@@ -264,60 +615,271 @@ func (h *InputHandler) UpdateInput(c echo.Context) error {
 	}
 	cfgJSON, err := json.Marshal(cfg)
 	if err != nil {
-		return response.BadRequest(c, "invalid config", "build config: "+err.Error())
+		return badRequest("invalid config", "build config: "+err.Error())
 	}
 
 	// Validate config via factory
 	if err := h.Registry.ValidateConfig(in.Type, cfg); err != nil {
-		return response.BadRequest(c, "invalid config", err.Error())
+		return badRequest("invalid config", err.Error())
 	}
-	// For http with listen: ensure port not already in use by another input (excluding this one)
-	if in.Type == "http" {
-		if listen, _ := cfg["listen"].(string); listen != "" {
-			existing, err := h.InputRepo.List(c.Request().Context())
-			if err != nil {
-				return response.InternalError(c, "list inputs failed", "list inputs: "+err.Error())
-			}
-			for _, ex := range existing {
-				if ex.ID == in.ID || ex.Type != "http" {
-					continue
-				}
-				var exCfg map[string]interface{}
-				if len(ex.Configuration) > 0 {
-					_ = json.Unmarshal(ex.Configuration, &exCfg)
-				}
-				if exListen, _ := exCfg["listen"].(string); exListen != "" && exListen == listen {
-					return response.Error(c, 409, "listen address already in use", "listen "+listen+" is already used by another input")
-				}
-			}
+	warnPlaintextSecrets("inputs", in.Title, cfg)
+	// Ensure port not already in use by another input (excluding this one)
+	if listen, _ := cfg["listen"].(string); listen != "" {
+		inUse, err := h.listenInUse(ctx, listen, in.ID)
+		if err != nil {
+			return internalError("list inputs failed", "list inputs: "+err.Error())
+		}
+		if inUse {
+			return "", &inputFailure{http.StatusConflict, "listen address already in use", "listen " + listen + " is already used by another input"}
 		}
 	}
 
 	in.Configuration = cfgJSON
-	in.DesiredState = model.InputStateRunning
-	if err := h.InputRepo.Update(c.Request().Context(), in); err != nil {
+	in.DesiredState = state
+	// The runtime is built before the update is stored, so a config its type cannot run is
+	// refused and the input keeps running as it was.
+	var run inputs.MessageInput
+	var metrics *inputs.Metrics
+	if state == model.InputStateRunning {
+		if run, metrics, err = h.newRuntime(*in, cfg); err != nil {
+			return badRequest("create input runtime failed", "create input runtime: "+err.Error())
+		}
+	}
+	if err := h.InputRepo.Update(ctx, in); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return "", &inputFailure{http.StatusConflict, "input was changed", "the input was changed while it was being updated: get it again and retry"}
+		}
+		return internalError("update input failed", "update input: "+err.Error())
+	}
+
+	// Only a stored update replaces the running instance, so a refused one leaves it running.
+	// The update is stored by now, so an input that does not start is reported FAILED.
+	h.stopInstance(in.ID)
+	if run != nil {
+		return h.startRuntime(ctx, in, run, metrics), nil
+	}
+	return state, nil
+}
+
+// startRuntime starts run, the runtime of the stored input in, and keeps it in Instances. A
+// runtime that does not start is recorded as the last_error of in, which is then FAILED.
+func (h *InputHandler) startRuntime(ctx context.Context, in *model.Input, run inputs.MessageInput, metrics *inputs.Metrics) model.InputState {
+	var err error
+	if serr := run.Start(); serr != nil {
+		err = fmt.Errorf("start input: %w", serr)
+	}
+	h.recordStartError(ctx, *in, err)
+	if err != nil {
+		in.LastError = err.Error()
+		return model.InputStateFailed
+	}
+	in.LastError = ""
+	h.InstancesMu.Lock()
+	h.Instances[in.ID] = InstanceRecord{Input: *in, Run: run, Metrics: metrics}
+	h.InstancesMu.Unlock()
+	return model.InputStateRunning
+}
+
+// StartInput starts a stopped or paused input and persists RUNNING (POST /inputs/:id/start).
+func (h *InputHandler) StartInput(c echo.Context) error {
+	return h.transition(c, model.InputStateRunning, "input started")
+}
+
+// StopInput stops a running input and persists STOPPED (POST /inputs/:id/stop).
+func (h *InputHandler) StopInput(c echo.Context) error {
+	return h.transition(c, model.InputStateStopped, "input stopped")
+}
+
+// PauseInput stops a running input and persists PAUSED (POST /inputs/:id/pause). The listener is
+// released like STOPPED; PAUSED only records that the operator means to resume it.
+func (h *InputHandler) PauseInput(c echo.Context) error {
+	return h.transition(c, model.InputStatePaused, "input paused")
+}
+
+// transition moves an input to state: the runtime is started or stopped first, and the new
+// desired state is persisted only once that succeeded so restarts restore what is running now.
+func (h *InputHandler) transition(c echo.Context, state model.InputState, message string) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
+	if err != nil || in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
+		return response.NotFound(c, "input not found", "input not found")
+	}
+
+	if state == model.InputStateRunning {
+		err := h.startInstance(*in)
+		h.recordStartError(c.Request().Context(), *in, err)
+		if err != nil {
+			return response.InternalError(c, "start input failed", err.Error())
+		}
+	} else {
+		h.stopInstance(id)
+	}
+
+	if err := h.InputRepo.UpdateState(c.Request().Context(), id, state); err != nil {
 		return response.InternalError(c, "update input failed", "update input: "+err.Error())
 	}
+	in.DesiredState = state
+	return response.OK(c, newInputResponse(*in, state), message)
+}
+
+// Bounds of the grace period of POST /inputs/:id/rotate-key.
+const (
+	defaultKeyGrace = 24 * time.Hour
+	maxKeyGrace     = 30 * 24 * time.Hour
+)
+
+type rotateKeyRequest struct {
+	GracePeriod string `json:"grace_period"` // Go duration; default 24h, 0s revokes the old keys now
+}
+
+// RotateKey issues a new ingest key for an input (POST /inputs/:id/rotate-key). The input's
+// previous keys keep working for grace_period, so shippers can move to the new key without
+// dropping entries; the listener keeps running. Inputs without a key get their first one.
+func (h *InputHandler) RotateKey(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	var req rotateKeyRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return response.BadRequest(c, "invalid request body", "invalid JSON body")
+		}
+	}
+	grace := defaultKeyGrace
+	if req.GracePeriod != "" {
+		grace, err = time.ParseDuration(req.GracePeriod)
+		if err != nil || grace < 0 || grace > maxKeyGrace {
+			return response.BadRequest(c, "invalid grace_period", "grace_period must be a duration from 0s to 720h")
+		}
+	}
+	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
+	if err != nil || in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
+		return response.NotFound(c, "input not found", "input not found")
+	}
+	if info, _ := h.Registry.GetTypeInfo(in.Type); !info.IngestKeys || h.Keys == nil {
+		return response.BadRequest(c, "ingest keys not supported", "inputs of type "+in.Type+" do not take ingest keys")
+	}
+	key, ik, err := h.Keys.Issue(c.Request().Context(), in.ID, grace)
+	if err != nil {
+		return response.InternalError(c, "rotate key failed", "issue ingest key: "+err.Error())
+	}
+	return response.OK(c, map[string]any{
+		"ingest_key":              key,
+		"prefix":                  ik.Prefix,
+		"previous_keys_expire_at": ik.CreatedAt.Add(grace).Format(time.RFC3339),
+	}, "ingest key rotated; store the ingest_key now, it is not shown again")
+}
 
-	run, err := h.Registry.Create(in.Type, cfg, h.Buffer)
+// startInstance creates and starts the runtime for in unless it is already running.
+func (h *InputHandler) startInstance(in model.Input) error {
+	h.InstancesMu.Lock()
+	defer h.InstancesMu.Unlock()
+	if rec, ok := h.Instances[in.ID]; ok && rec.Run != nil {
+		return nil
+	}
+	run, metrics, err := h.newRuntime(in, runtimeConfig(in))
 	if err != nil {
-		return response.BadRequest(c, "create input runtime failed", "create input runtime: "+err.Error())
+		return fmt.Errorf("create input runtime: %w", err)
 	}
 	if err := run.Start(); err != nil {
-		return response.InternalError(c, "start input failed", "start input: "+err.Error())
+		return fmt.Errorf("start input: %w", err)
+	}
+	in.DesiredState = model.InputStateRunning
+	h.Instances[in.ID] = InstanceRecord{Input: in, Run: run, Metrics: metrics}
+	return nil
+}
+
+// recordStartError persists err as the input's last_error so GET /inputs can report it as FAILED.
+// A nil err clears a previously recorded failure.
+func (h *InputHandler) recordStartError(ctx context.Context, in model.Input, err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+		h.Events.Publish(inputEvent(events.InputFailed, events.SeverityError, in, "input "+in.Title+" could not be started: "+msg, msg))
+	} else if in.LastError == "" {
+		return
+	}
+	if err := h.InputRepo.SetLastError(ctx, in.ID, msg); err != nil {
+		log.Printf("[inputs] record error for %s: %v", in.Title, err)
+	}
+}
+
+// stopInstance stops and forgets the runtime for id, if any.
+func (h *InputHandler) stopInstance(id uuid.UUID) {
+	h.InstancesMu.Lock()
+	defer h.InstancesMu.Unlock()
+	if rec, ok := h.Instances[id]; ok {
+		h.stopAndUnmount(rec)
+		delete(h.Instances, id)
+	}
+}
+
+type inputMetricsResponse struct {
+	ID      string                 `json:"id"`
+	Type    string                 `json:"type"`
+	Title   string                 `json:"title"`
+	State   string                 `json:"state"`
+	Metrics inputs.MetricsSnapshot `json:"metrics"`
+}
+
+// GetInputMetrics returns runtime counters for one input (GET /inputs/:id/metrics).
+// Inputs that are not running report zeros.
+func (h *InputHandler) GetInputMetrics(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
 	}
 	h.InstancesMu.Lock()
-	h.Instances[in.ID] = InstanceRecord{Input: *in, Run: run}
+	rec, running := h.Instances[id]
 	h.InstancesMu.Unlock()
+	if running && visibleID(c.Request().Context(), rec.Input.ProjectID) {
+		return response.OK(c, inputMetricsResponse{
+			ID:      id.String(),
+			Type:    rec.Input.Type,
+			Title:   rec.Input.Title,
+			State:   string(model.InputStateRunning),
+			Metrics: rec.Metrics.Snapshot(),
+		}, "")
+	}
+	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
+	if err != nil || in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
+		return response.NotFound(c, "input not found", "input not found")
+	}
+	return response.OK(c, inputMetricsResponse{
+		ID:    id.String(),
+		Type:  in.Type,
+		Title: in.Title,
+		State: string(in.DesiredState),
+	}, "")
+}
 
-	return response.OK(c, inputInstanceResponse{
-		ID:            in.ID.String(),
-		Type:          in.Type,
-		Title:         in.Title,
-		Configuration: in.Configuration,
-		CreatedAt:     in.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		State:         "RUNNING",
-	}, "input updated")
+// ListInputMetrics returns runtime counters for every running input plus their totals (GET /inputs/metrics).
+// Callers bound to a project get the inputs of that project.
+func (h *InputHandler) ListInputMetrics(c echo.Context) error {
+	var total inputs.MetricsSnapshot
+	h.InstancesMu.Lock()
+	out := make([]inputMetricsResponse, 0, len(h.Instances))
+	for id, rec := range h.Instances {
+		if !visibleID(c.Request().Context(), rec.Input.ProjectID) {
+			continue
+		}
+		snap := rec.Metrics.Snapshot()
+		total.Add(snap)
+		out = append(out, inputMetricsResponse{
+			ID:      id.String(),
+			Type:    rec.Input.Type,
+			Title:   rec.Input.Title,
+			State:   string(model.InputStateRunning),
+			Metrics: snap,
+		})
+	}
+	h.InstancesMu.Unlock()
+	sort.Slice(out, func(a, b int) bool { return out[a].Title < out[b].Title })
+	return response.OK(c, map[string]any{"inputs": out, "total": total}, "")
 }
 
 // DeleteInput deletes an input by id (DELETE /inputs/:id). Stops and unmounts then removes from DB.
@@ -329,25 +891,39 @@ func (h *InputHandler) DeleteInput(c echo.Context) error {
 	}
 
 	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
-	if err != nil || in == nil {
+	if err != nil || in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
 		return response.NotFound(c, "input not found", "input not found")
 	}
 
+	if err := h.deleteInput(c.Request().Context(), *in); err != nil {
+		return response.InternalError(c, "delete input failed", "delete input: "+err.Error())
+	}
+	return response.OK(c, nil, "input deleted")
+}
+
+// deleteInput stops in and removes it with its ingest keys and metrics.
+func (h *InputHandler) deleteInput(ctx context.Context, in model.Input) error {
 	h.InstancesMu.Lock()
-	rec, running := h.Instances[id]
+	rec, running := h.Instances[in.ID]
 	if running {
 		h.stopAndUnmount(rec)
-		delete(h.Instances, id)
+		delete(h.Instances, in.ID)
 	}
 	h.InstancesMu.Unlock()
 
-	if err := h.InputRepo.Delete(c.Request().Context(), id); err != nil {
-		return response.InternalError(c, "delete input failed", "delete input: "+err.Error())
+	if err := h.InputRepo.Delete(ctx, in.ID); err != nil {
+		return err
 	}
-	return response.OK(c, nil, "input deleted")
+	h.Keys.Forget(in.ID)
+	if h.Recent != nil {
+		h.Recent.Forget(in.ID.String())
+	}
+	metrics.ForgetInput(in.ID.String(), in.Type)
+	return nil
 }
 
-// RestoreInputs loads inputs from the DB and starts each on its listen port. Nothing is mounted on the main server.
+// RestoreInputs loads inputs from the DB and starts each RUNNING one on its listen port.
+// Stopped and paused inputs stay down. Nothing is mounted on the main server.
 func (h *InputHandler) RestoreInputs(ctx context.Context) {
 	list, err := h.InputRepo.List(ctx)
 	if err != nil {
@@ -355,32 +931,80 @@ func (h *InputHandler) RestoreInputs(ctx context.Context) {
 		return
 	}
 	for _, in := range list {
-		if in.Type != "http" {
+		if in.DesiredState != "" && in.DesiredState != model.InputStateRunning {
+			log.Printf("[inputs] skip restore %s: desired state %s", in.Title, in.DesiredState)
 			continue
 		}
-		cfg := make(inputs.Config)
-		if len(in.Configuration) > 0 {
-			_ = json.Unmarshal(in.Configuration, &cfg)
-		}
-		if _, hasListen := cfg["listen"]; !hasListen {
-			log.Printf("[inputs] skip restore %s: no listen (inputs must have own port)", in.Title)
-			continue
-		}
-		if _, ok := cfg["base_path"]; !ok {
-			cfg["base_path"] = "/ingest"
-		}
-		run, err := h.Registry.Create(in.Type, cfg, h.Buffer)
+		err := h.restoreInput(in)
+		h.recordStartError(ctx, in, err)
 		if err != nil {
-			log.Printf("[inputs] restore create %s: %v", in.Title, err)
-			continue
+			log.Printf("[inputs] restore %s: %v", in.Title, err)
 		}
-		if err := run.Start(); err != nil {
-			log.Printf("[inputs] restore start %s: %v", in.Title, err)
-			continue
+	}
+}
+
+// restoreInput starts one persisted input. Its error is what GET /inputs shows as last_error.
+func (h *InputHandler) restoreInput(in model.Input) error {
+	info, ok := h.Registry.GetTypeInfo(in.Type)
+	if !ok {
+		return fmt.Errorf("unknown input type %q", in.Type)
+	}
+	cfg := runtimeConfig(in)
+	if _, hasListen := cfg["listen"]; !hasListen && requiresListen(info) {
+		return errors.New("no listen configured (inputs must have their own port)")
+	}
+	run, metrics, err := h.newRuntime(in, cfg)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if err := run.Start(); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	h.InstancesMu.Lock()
+	h.Instances[in.ID] = InstanceRecord{Input: in, Run: run, Metrics: metrics}
+	h.InstancesMu.Unlock()
+	if listen, ok := cfg["listen"]; ok {
+		log.Printf("[inputs] restored %s → listen %s", in.Title, listen)
+	} else {
+		log.Printf("[inputs] restored %s", in.Title)
+	}
+	return nil
+}
+
+// DrainInputs stops every running input for shutdown, all at once: inputs.DrainableInput ones
+// gracefully until ctx is done, the others with Stop. It returns when they have stopped or
+// ctx is done. Desired states are kept, so the inputs start again with the server.
+func (h *InputHandler) DrainInputs(ctx context.Context) {
+	h.InstancesMu.Lock()
+	recs := make([]InstanceRecord, 0, len(h.Instances))
+	for id, rec := range h.Instances {
+		recs = append(recs, rec)
+		delete(h.Instances, id)
+	}
+	h.InstancesMu.Unlock()
+	var wg sync.WaitGroup
+	for _, rec := range recs {
+		wg.Add(1)
+		go func(rec InstanceRecord) {
+			defer wg.Done()
+			if d, ok := rec.Run.(inputs.DrainableInput); ok {
+				if err := d.Drain(ctx); err != nil {
+					log.Printf("[inputs] drain %s: %v", rec.Input.Title, err)
+				}
+			}
+			h.stopAndUnmount(rec)
+		}(rec)
+	}
+	inputs.Wait(ctx, &wg)
+}
+
+// requiresListen reports whether the type binds its own port. Pull-based inputs (s3, redis, mqtt,
+// docker) have no listen field and are restored without one.
+func requiresListen(info inputs.InputTypeInfo) bool {
+	for _, f := range info.Fields {
+		if f.Name == "listen" {
+			return f.Required
 		}
-		h.InstancesMu.Lock()
-		h.Instances[in.ID] = InstanceRecord{Input: in, Run: run}
-		h.InstancesMu.Unlock()
-		log.Printf("[inputs] restored %s → listen %s", in.Title, cfg["listen"])
 	}
+	return false
 }
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/secrets"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	maxBulkInputs     = 100      // items of one POST or DELETE /inputs/bulk
+	maxInputDocument  = 10 << 20 // bytes of one POST /inputs/import body
+	yamlContentType   = "application/yaml"
+	inputDocumentName = "inputs"
+)
+
+// bulkInputResult is the outcome of one item of POST or DELETE /inputs/bulk: the status and
+// error it would have got on its own.
+type bulkInputResult struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+	IngestKey string `json:"ingest_key,omitempty"` // POST only
+}
+
+// inputDocument is the input set of GET /inputs/export and POST /inputs/import, in the shape
+// of the inputs of the config file, so an export can also be pasted there.
+type inputDocument struct {
+	Inputs []inputDocumentItem `json:"inputs" yaml:"inputs"`
+}
+
+type inputDocumentItem struct {
+	Type        string         `json:"type" yaml:"type"`
+	Title       string         `json:"title" yaml:"title"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Project     string         `json:"project,omitempty" yaml:"project,omitempty"` // project name, or ID
+	State       string         `json:"state,omitempty" yaml:"state,omitempty"`
+	Config      map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+// CreateInputs creates the inputs of the body {"inputs": [...]}, each with the fields of
+// POST /inputs (POST /inputs/bulk). Every item is created on its own: the answer lists the
+// status, error or created input of each, in order.
+func (h *InputHandler) CreateInputs(c echo.Context) error {
+	var req struct {
+		Inputs []createInputRequest `json:"inputs"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	if len(req.Inputs) == 0 || len(req.Inputs) > maxBulkInputs {
+		return response.BadRequest(c, "invalid inputs", fmt.Sprintf("inputs must hold 1 to %d inputs", maxBulkInputs))
+	}
+	results := make([]bulkInputResult, 0, len(req.Inputs))
+	failed := 0
+	for i, item := range req.Inputs {
+		res := bulkInputResult{Index: i, Title: item.Title}
+		in, _, ingestKey, fail := h.createInput(c.Request().Context(), item)
+		if fail != nil {
+			res.Status, res.Error = fail.status, fail.String()
+			failed++
+		} else {
+			res.ID, res.Title, res.Status, res.IngestKey = in.ID.String(), in.Title, http.StatusCreated, ingestKey
+			res.Error = in.LastError // created, but did not start
+		}
+		results = append(results, res)
+	}
+	return response.OK(c, map[string]any{"results": results, "created": len(results) - failed, "failed": failed}, "")
+}
+
+// DeleteInputs deletes the inputs of the body {"ids": [...]} (DELETE /inputs/bulk). The
+// answer lists the status and error of each ID, in order.
+func (h *InputHandler) DeleteInputs(c echo.Context) error {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkInputs {
+		return response.BadRequest(c, "invalid ids", fmt.Sprintf("ids must hold 1 to %d input IDs", maxBulkInputs))
+	}
+	ctx := c.Request().Context()
+	results := make([]bulkInputResult, 0, len(req.IDs))
+	failed := 0
+	for i, ref := range req.IDs {
+		res := bulkInputResult{Index: i, ID: ref}
+		res.Title, res.Status, res.Error = h.deleteByID(ctx, ref)
+		if res.Error != "" {
+			failed++
+		}
+		results = append(results, res)
+	}
+	return response.OK(c, map[string]any{"results": results, "deleted": len(results) - failed, "failed": failed}, "")
+}
+
+// deleteByID deletes the input of id and returns its title, or the status and error it fails with.
+func (h *InputHandler) deleteByID(ctx context.Context, id string) (string, int, string) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return "", http.StatusBadRequest, "invalid id"
+	}
+	in, err := h.InputRepo.GetByID(ctx, uid)
+	if err != nil {
+		return "", http.StatusInternalServerError, "get input: " + err.Error()
+	}
+	if in == nil || !visibleID(ctx, in.ProjectID) {
+		return "", http.StatusNotFound, "input not found"
+	}
+	if err := h.deleteInput(ctx, *in); err != nil {
+		return in.Title, http.StatusInternalServerError, "delete input: " + err.Error()
+	}
+	return in.Title, http.StatusOK, ""
+}
+
+// ExportInputs answers every input as an input document (GET /inputs/export): JSON, or YAML
+// with format=yaml; callers bound to a project get the inputs of that project. The document
+// is not wrapped in the usual response. Secrets are masked unless include_secrets=true,
+// which needs the admin scope; references to secrets are kept.
+func (h *InputHandler) ExportInputs(c echo.Context) error {
+	format := strings.ToLower(c.QueryParam("format"))
+	if format != "" && format != "json" && format != "yaml" {
+		return response.BadRequest(c, "invalid format", "format must be json or yaml")
+	}
+	ctx := c.Request().Context()
+	list, err := h.InputRepo.List(ctx)
+	if err != nil {
+		return response.InternalError(c, "list inputs failed", "list inputs: "+err.Error())
+	}
+	includeSecrets := c.QueryParam("include_secrets") == "true"
+	projects := map[uuid.UUID]string{}
+	doc := inputDocument{Inputs: make([]inputDocumentItem, 0, len(list))}
+	// Oldest first, so an import creates them in the order they were created.
+	for i := len(list) - 1; i >= 0; i-- {
+		in := list[i]
+		if !visibleID(ctx, in.ProjectID) {
+			continue
+		}
+		cfg := map[string]any(runtimeConfig(in))
+		item := inputDocumentItem{Type: in.Type, Title: in.Title, State: string(in.DesiredState)}
+		if d, ok := cfg["description"].(string); ok {
+			item.Description = d
+			delete(cfg, "description")
+		}
+		if cfg["base_path"] == "/ingest" {
+			delete(cfg, "base_path")
+		}
+		if !includeSecrets {
+			cfg = secrets.Redact(cfg)
+		}
+		if len(cfg) > 0 {
+			item.Config = cfg
+		}
+		if in.ProjectID != nil {
+			name, ok := projects[*in.ProjectID]
+			if !ok {
+				name = in.ProjectID.String()
+				if h.Projects != nil {
+					if p, err := h.Projects.Repo.GetByID(ctx, *in.ProjectID); err == nil && p != nil {
+						name = p.Name
+					}
+				}
+				projects[*in.ProjectID] = name
+			}
+			item.Project = name
+		}
+		doc.Inputs = append(doc.Inputs, item)
+	}
+
+	if format == "yaml" {
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return response.InternalError(c, "export inputs failed", "encode YAML: "+err.Error())
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+inputDocumentName+`.yaml"`)
+		return c.Blob(http.StatusOK, yamlContentType, out)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+inputDocumentName+`.json"`)
+	return c.JSONPretty(http.StatusOK, doc, "  ")
+}
+
+// ImportInputs makes the inputs match an input document (POST /inputs/import), as the
+// inputs of the config file are reconciled: inputs with a title not in use are created,
+// existing ones updated where they differ, and with prune=true inputs not in the document
+// are deleted. The body is JSON, or YAML when its Content-Type or format=yaml says so.
+// Masked secrets keep the values stored; on new inputs they are left out.
+func (h *InputHandler) ImportInputs(c echo.Context) error {
+	raw, err := io.ReadAll(io.LimitReader(c.Request().Body, maxInputDocument+1))
+	if err != nil {
+		return response.BadRequest(c, "invalid request body", "read body: "+err.Error())
+	}
+	if len(raw) > maxInputDocument {
+		return response.Error(c, http.StatusRequestEntityTooLarge, "document too large", fmt.Sprintf("the document exceeds %d bytes", maxInputDocument))
+	}
+	var doc inputDocument
+	if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "yaml") || strings.EqualFold(c.QueryParam("format"), "yaml") {
+		err = yaml.Unmarshal(raw, &doc)
+	} else {
+		err = json.Unmarshal(raw, &doc)
+	}
+	if err != nil {
+		return response.BadRequest(c, "invalid document", err.Error())
+	}
+	seen := make(map[string]bool, len(doc.Inputs))
+	specs := make([]InputSpec, 0, len(doc.Inputs))
+	for _, item := range doc.Inputs {
+		if item.Title == "" || item.Type == "" {
+			return response.BadRequest(c, "invalid document", "every input needs a type and a title")
+		}
+		if seen[item.Title] {
+			return response.BadRequest(c, "invalid document", "two inputs are titled "+item.Title)
+		}
+		seen[item.Title] = true
+		specs = append(specs, InputSpec{
+			Type:        item.Type,
+			Title:       item.Title,
+			Description: item.Description,
+			Project:     item.Project,
+			State:       item.State,
+			Config:      item.Config,
+		})
+	}
+	prune := c.QueryParam("prune") == "true"
+	if len(specs) == 0 && prune {
+		return response.BadRequest(c, "invalid document", "refusing to delete every input: the document has none")
+	}
+	changes := h.ReconcileInputs(c.Request().Context(), specs, prune)
+	return response.OK(c, changes, "inputs imported")
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name, doc, patch, want string
+	}{
+		{"set", `{"a":1}`, `{"b":"x"}`, `{"a":1,"b":"x"}`},
+		{"replace", `{"a":1}`, `{"a":2}`, `{"a":2}`},
+		{"null removes", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null of missing key", `{"a":1}`, `{"z":null}`, `{"a":1}`},
+		{"nested merge", `{"tls":{"cert":"c","key":"k"},"port":1}`, `{"tls":{"key":"k2"}}`, `{"port":1,"tls":{"cert":"c","key":"k2"}}`},
+		{"nested null", `{"tls":{"cert":"c","key":"k"}}`, `{"tls":{"cert":null}}`, `{"tls":{"key":"k"}}`},
+		{"object over scalar", `{"tls":true}`, `{"tls":{"cert":"c"}}`, `{"tls":{"cert":"c"}}`},
+		{"nulls inside new object dropped", `{}`, `{"tls":{"cert":"c","key":null}}`, `{"tls":{"cert":"c"}}`},
+		{"array replaced", `{"topics":["a","b"]}`, `{"topics":["c"]}`, `{"topics":["c"]}`},
+		{"empty doc", ``, `{"a":1}`, `{"a":1}`},
+	} {
+		got, err := mergePatch(json.RawMessage(tc.doc), json.RawMessage(tc.patch))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	for name, patch := range map[string]string{"array": `["a"]`, "null": `null`, "string": `"a"`} {
+		if _, err := mergePatch(json.RawMessage(`{"a":1}`), json.RawMessage(patch)); err == nil {
+			t.Errorf("patch %s accepted", name)
+		}
+	}
+}
+
+func TestExpectedVersion(t *testing.T) {
+	seven := int64(7)
+	for _, tc := range []struct {
+		name    string
+		ifMatch string
+		body    *int64
+		version int64
+		ok      bool
+		err     bool
+	}{
+		{name: "none", ok: false},
+		{name: "body", body: &seven, version: 7, ok: true},
+		{name: "bare number", ifMatch: "3", version: 3, ok: true},
+		{name: "etag", ifMatch: `"3"`, version: 3, ok: true},
+		{name: "weak etag", ifMatch: `W/"3"`, version: 3, ok: true},
+		{name: "header wins over body", ifMatch: ` "3" `, body: &seven, version: 3, ok: true},
+		{name: "star", ifMatch: "*", ok: false},
+		{name: "star ignores body", ifMatch: "*", body: &seven, ok: false},
+		{name: "not a version", ifMatch: `"abc"`, err: true},
+		{name: "list of etags", ifMatch: `"3", "4"`, err: true},
+	} {
+		version, ok, err := expectedVersion(tc.ifMatch, tc.body)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: err = %v", tc.name, err)
+			continue
+		}
+		if version != tc.version || ok != tc.ok {
+			t.Errorf("%s: got (%d, %v), want (%d, %v)", tc.name, version, ok, tc.version, tc.ok)
+		}
+	}
+}
+
+type stubRun struct {
+	inputs.HealthState
+	stopped bool
+}
+
+func (r *stubRun) Start() error { return nil }
+func (r *stubRun) Stop() error  { r.stopped = true; return nil }
+
+// strictFactory refuses configs without a port, and cannot build a runtime for "broken" ones.
+type strictFactory struct{}
+
+func (strictFactory) Name() string                     { return "strict" }
+func (strictFactory) ConfigSpec() inputs.InputTypeInfo { return inputs.InputTypeInfo{Type: "strict"} }
+func (strictFactory) Create(cfg inputs.Config, _ inputs.InputBuffer) (inputs.MessageInput, error) {
+	if cfg["broken"] == true {
+		return nil, errors.New("cannot listen")
+	}
+	return &stubRun{}, nil
+}
+func (strictFactory) ValidateConfig(cfg inputs.Config) error {
+	if _, ok := cfg["port"]; !ok {
+		return errors.New("port is required")
+	}
+	return nil
+}
+
+func TestUpdateInputRefusedKeepsRunning(t *testing.T) {
+	reg := inputs.NewRegistry()
+	reg.Register(strictFactory{})
+	in := model.Input{ID: uuid.New(), Type: "strict", Title: "t", Configuration: json.RawMessage(`{"port":1}`),
+		DesiredState: model.InputStateRunning, Version: 3}
+	run := &stubRun{}
+	h := &InputHandler{Registry: reg, Instances: map[uuid.UUID]InstanceRecord{in.ID: {Input: in, Run: run}}}
+
+	for name, req := range map[string]createInputRequest{
+		"invalid state":  {State: "ASLEEP"},
+		"invalid config": {Config: json.RawMessage(`{"host":"x"}`), replaceConfig: true},
+		"no runtime":     {Config: json.RawMessage(`{"port":2,"broken":true}`), replaceConfig: true},
+	} {
+		cp := in
+		if _, fail := h.updateInput(context.Background(), &cp, req); fail == nil || fail.status != http.StatusBadRequest {
+			t.Fatalf("%s: fail = %v, want 400", name, fail)
+		}
+		if run.stopped || h.Instances[in.ID].Run != run {
+			t.Errorf("%s: the running instance was stopped", name)
+		}
+	}
+}
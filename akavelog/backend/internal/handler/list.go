@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listSpec says how a list endpoint filters and sorts its items. Every list endpoint takes
+// the same query parameters:
+//   - limit (default 100, at most 1000) and offset page the items;
+//   - sort names one of sorts, prefixed with "-" for descending order; without it the items
+//     keep the order the repository returned;
+//   - q keeps the items whose text contains it, ignoring case;
+//   - each of filters keeps the items it matches when its parameter is given.
+type listSpec[T any] struct {
+	text    func(T) string
+	filters map[string]func(item T, value string) bool
+	sorts   map[string]func(a, b T) int
+}
+
+// byString is a sort comparing a string field.
+func byString[T any](field func(T) string) func(a, b T) int {
+	return func(a, b T) int { return cmp.Compare(field(a), field(b)) }
+}
+
+// equalFold is a filter comparing a field with the parameter, ignoring case.
+func equalFold[T any](field func(T) string) func(T, string) bool {
+	return func(item T, value string) bool { return strings.EqualFold(field(item), value) }
+}
+
+// boolFilter is a filter comparing a boolean field with a true or false parameter.
+func boolFilter[T any](field func(T) bool) func(T, string) bool {
+	return func(item T, value string) bool {
+		b, err := strconv.ParseBool(value)
+		return err == nil && field(item) == b
+	}
+}
+
+// listResponse answers a list endpoint with the page of items the query parameters select,
+// under key, with the total count of the items that matched: {key: [...], total, limit,
+// offset}. Invalid parameters are answered with 400.
+func listResponse[T any](c echo.Context, key string, items []T, spec listSpec[T]) error {
+	limit, offset := defaultListLimit, 0
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxListLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(maxListLimit))
+		}
+		limit = n
+	}
+	if v := c.QueryParam("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return response.BadRequest(c, "invalid offset", "offset must be 0 or more")
+		}
+		offset = n
+	}
+	var sortBy func(a, b T) int
+	if v := strings.TrimSpace(c.QueryParam("sort")); v != "" {
+		name, desc := strings.CutPrefix(v, "-")
+		f, ok := spec.sorts[name]
+		if !ok {
+			return response.BadRequest(c, "invalid sort", "sort must be one of "+strings.Join(sortedKeys(spec.sorts), ", ")+", optionally prefixed with -")
+		}
+		sortBy = f
+		if desc {
+			sortBy = func(a, b T) int { return f(b, a) }
+		}
+	}
+
+	q := strings.ToLower(strings.TrimSpace(c.QueryParam("q")))
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		if q != "" && (spec.text == nil || !strings.Contains(strings.ToLower(spec.text(item)), q)) {
+			continue
+		}
+		keep := true
+		for param, match := range spec.filters {
+			if v := strings.TrimSpace(c.QueryParam(param)); v != "" && !match(item, v) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			matched = append(matched, item)
+		}
+	}
+	if sortBy != nil {
+		slices.SortStableFunc(matched, sortBy)
+	}
+	page := matched[min(offset, len(matched)):min(offset+limit, len(matched))]
+	return response.OK(c, map[string]any{key: page, "total": len(matched), "limit": limit, "offset": offset}, "")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// namedSorts sorts items by name, created_at and updated_at, the sorts most lists share.
+func namedSorts[T any](name, created, updated func(T) string) map[string]func(a, b T) int {
+	return map[string]func(a, b T) int{
+		"name":       byString(name),
+		"created_at": byString(created),
+		"updated_at": byString(updated),
+	}
+}
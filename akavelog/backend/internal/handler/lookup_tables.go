@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/lookup"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// maxLookupCSVBytes caps uploaded CSV data.
+const maxLookupCSVBytes = 16 << 20
+
+var lookupTableName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LookupTableHandler handles /lookup-tables. Definitions are validated by loading them once;
+// changes invalidate the Store's cached copy so lookup processors see them on the next entry.
+type LookupTableHandler struct {
+	Repo  *repository.LookupTableRepository
+	Store *lookup.Store
+}
+
+type lookupTableResponse struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Kind        string        `json:"kind"`
+	KeyColumn   string        `json:"key_column"`
+	Query       string        `json:"query,omitempty"`
+	CSV         string        `json:"csv,omitempty"` // GET /lookup-tables/:id only
+	TTLSeconds  int           `json:"ttl_seconds,omitempty"`
+	Cache       lookup.Status `json:"cache"`
+	CreatedAt   string        `json:"created_at"`
+	UpdatedAt   string        `json:"updated_at"`
+}
+
+// lookupTableListSpec filters GET /lookup-tables by kind; q searches names and descriptions.
+var lookupTableListSpec = listSpec[lookupTableResponse]{
+	text: func(t lookupTableResponse) string { return t.Name + " " + t.Description },
+	filters: map[string]func(lookupTableResponse, string) bool{
+		"kind": equalFold(func(t lookupTableResponse) string { return t.Kind }),
+	},
+	sorts: namedSorts(func(t lookupTableResponse) string { return t.Name }, func(t lookupTableResponse) string { return t.CreatedAt }, func(t lookupTableResponse) string { return t.UpdatedAt }),
+}
+
+type lookupTableRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"` // csv or postgres
+	KeyColumn   string `json:"key_column"`
+	CSV         string `json:"csv"`
+	Query       string `json:"query"`
+	TTLSeconds  int    `json:"ttl_seconds"`
+}
+
+func (h *LookupTableHandler) newResponse(t model.LookupTable, withCSV bool) lookupTableResponse {
+	out := lookupTableResponse{
+		ID:          t.ID.String(),
+		Name:        t.Name,
+		Description: t.Description,
+		Kind:        string(t.Kind),
+		KeyColumn:   t.KeyColumn,
+		Query:       t.Query,
+		Cache:       h.Store.Status(t.Name),
+		CreatedAt:   t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   t.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if t.Kind == model.LookupTablePostgres {
+		out.TTLSeconds = t.TTLSeconds
+	}
+	if withCSV {
+		out.CSV = t.CSVData
+	}
+	return out
+}
+
+// ListLookupTables returns all lookup tables without their CSV data (GET /lookup-tables).
+func (h *LookupTableHandler) ListLookupTables(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list lookup tables failed", "list lookup tables: "+err.Error())
+	}
+	out := make([]lookupTableResponse, 0, len(list))
+	for _, t := range list {
+		out = append(out, h.newResponse(t, false))
+	}
+	return listResponse(c, "lookup_tables", out, lookupTableListSpec)
+}
+
+// GetLookupTable returns one lookup table including its CSV data (GET /lookup-tables/:id).
+func (h *LookupTableHandler) GetLookupTable(c echo.Context) error {
+	t, err := h.byID(c)
+	if t == nil {
+		return err
+	}
+	return response.OK(c, h.newResponse(*t, true), "")
+}
+
+// CreateLookupTable validates and persists a lookup table (POST /lookup-tables).
+func (h *LookupTableHandler) CreateLookupTable(c echo.Context) error {
+	var req lookupTableRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	t := model.LookupTable{}
+	if msg, detail := h.apply(c.Request().Context(), &t, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), t.Name)
+	if err != nil {
+		return response.InternalError(c, "create lookup table failed", "get lookup table: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, 409, "lookup table name already in use", "a lookup table named "+t.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &t); err != nil {
+		return response.InternalError(c, "create lookup table failed", "create lookup table: "+err.Error())
+	}
+	h.Store.Invalidate(t.Name)
+	return response.Created(c, h.newResponse(t, false), "lookup table created")
+}
+
+// UpdateLookupTable replaces a lookup table's definition (PUT /lookup-tables/:id).
+func (h *LookupTableHandler) UpdateLookupTable(c echo.Context) error {
+	t, err := h.byID(c)
+	if t == nil {
+		return err
+	}
+	var req lookupTableRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := t.Name
+	if msg, detail := h.apply(c.Request().Context(), t, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	return h.save(c, t, oldName)
+}
+
+// UploadLookupTableCSV replaces the rows of a CSV table with the raw request body
+// (PUT /lookup-tables/:id/csv, Content-Type text/csv).
+func (h *LookupTableHandler) UploadLookupTableCSV(c echo.Context) error {
+	t, err := h.byID(c)
+	if t == nil {
+		return err
+	}
+	if t.Kind != model.LookupTableCSV {
+		return response.BadRequest(c, "not a csv table", "lookup table "+t.Name+" is backed by a query")
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxLookupCSVBytes+1))
+	if err != nil {
+		return response.BadRequest(c, "invalid request body", "read body: "+err.Error())
+	}
+	if len(body) > maxLookupCSVBytes {
+		return response.Error(c, 413, "csv too large", "csv data exceeds 16 MiB")
+	}
+	t.CSVData = string(body)
+	if _, err := h.Store.Load(c.Request().Context(), *t); err != nil {
+		return response.BadRequest(c, "invalid lookup table", err.Error())
+	}
+	return h.save(c, t, t.Name)
+}
+
+// DeleteLookupTable removes a lookup table (DELETE /lookup-tables/:id). Lookup processors
+// that still reference it report an error on each entry.
+func (h *LookupTableHandler) DeleteLookupTable(c echo.Context) error {
+	t, err := h.byID(c)
+	if t == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), t.ID); err != nil {
+		return response.InternalError(c, "delete lookup table failed", "delete lookup table: "+err.Error())
+	}
+	h.Store.Invalidate(t.Name)
+	return response.OK(c, nil, "lookup table deleted")
+}
+
+// byID loads the table named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *LookupTableHandler) byID(c echo.Context) (*model.LookupTable, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	t, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get lookup table failed", "get lookup table: "+err.Error())
+	}
+	if t == nil {
+		return nil, response.NotFound(c, "lookup table not found", "lookup table not found")
+	}
+	return t, nil
+}
+
+func (h *LookupTableHandler) save(c echo.Context, t *model.LookupTable, oldName string) error {
+	if t.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), t.Name)
+		if err != nil {
+			return response.InternalError(c, "update lookup table failed", "get lookup table: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, 409, "lookup table name already in use", "a lookup table named "+t.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), t); err != nil {
+		return response.InternalError(c, "update lookup table failed", "update lookup table: "+err.Error())
+	}
+	h.Store.Invalidate(oldName)
+	h.Store.Invalidate(t.Name)
+	return response.OK(c, h.newResponse(*t, false), "lookup table updated")
+}
+
+// apply copies req onto t and validates it by loading the table once. It returns a message
+// and detail for a 400 response, or "" when t is valid.
+func (h *LookupTableHandler) apply(ctx context.Context, t *model.LookupTable, req lookupTableRequest) (string, string) {
+	t.Name = strings.TrimSpace(req.Name)
+	if !lookupTableName.MatchString(t.Name) {
+		return "invalid name", "name is required and may contain only letters, digits, '_', '.' and '-'"
+	}
+	t.Description = req.Description
+	t.Kind = model.LookupTableKind(strings.ToLower(strings.TrimSpace(req.Kind)))
+	t.KeyColumn = strings.TrimSpace(req.KeyColumn)
+	if t.KeyColumn == "" {
+		return "missing key_column", "missing 'key_column'"
+	}
+	if req.TTLSeconds < 0 {
+		return "invalid ttl_seconds", "ttl_seconds must not be negative"
+	}
+	t.TTLSeconds = req.TTLSeconds
+	switch t.Kind {
+	case model.LookupTableCSV:
+		if len(req.CSV) > maxLookupCSVBytes {
+			return "csv too large", "csv data exceeds 16 MiB"
+		}
+		t.CSVData, t.Query = req.CSV, ""
+	case model.LookupTablePostgres:
+		if strings.TrimSpace(req.Query) == "" {
+			return "missing query", "postgres lookup tables need a 'query'"
+		}
+		t.Query, t.CSVData = req.Query, ""
+	default:
+		return "invalid kind", "kind must be csv or postgres"
+	}
+	if _, err := h.Store.Load(ctx, *t); err != nil {
+		return "invalid lookup table", err.Error()
+	}
+	return "", ""
+}
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/notifications"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/secrets"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var channelName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// NotificationHandler handles /notifications, the channels alerts are announced on. Like
+// OutputHandler, every change is persisted first and then the whole set is reloaded into the
+// Notifier.
+type NotificationHandler struct {
+	Registry *notifications.Registry
+	Repo     *repository.NotificationChannelRepository
+	Notifier *notifications.Notifier
+}
+
+type notificationResponse struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Type        string                `json:"type"`
+	Description string                `json:"description,omitempty"`
+	Enabled     bool                  `json:"enabled"`
+	Config      json.RawMessage       `json:"config"`
+	Status      *notifications.Status `json:"status"` // null when the channel is not running
+	CreatedAt   string                `json:"created_at"`
+	UpdatedAt   string                `json:"updated_at"`
+}
+
+// notificationListSpec filters GET /notifications by type and enabled; q searches names and descriptions.
+var notificationListSpec = listSpec[notificationResponse]{
+	text: func(n notificationResponse) string { return n.Name + " " + n.Description },
+	filters: map[string]func(notificationResponse, string) bool{
+		"type":    equalFold(func(n notificationResponse) string { return n.Type }),
+		"enabled": boolFilter(func(n notificationResponse) bool { return n.Enabled }),
+	},
+	sorts: namedSorts(func(n notificationResponse) string { return n.Name }, func(n notificationResponse) string { return n.CreatedAt }, func(n notificationResponse) string { return n.UpdatedAt }),
+}
+
+type notificationRequest struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Enabled     *bool           `json:"enabled"` // default true
+	Config      json.RawMessage `json:"config"`
+}
+
+func (h *NotificationHandler) newResponse(ch model.NotificationChannel) notificationResponse {
+	out := notificationResponse{
+		ID:          ch.ID.String(),
+		Name:        ch.Name,
+		Type:        ch.Type,
+		Description: ch.Description,
+		Enabled:     ch.Enabled,
+		Config:      secrets.RedactJSON(ch.Configuration),
+		CreatedAt:   ch.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   ch.UpdatedAt.Format(time.RFC3339),
+	}
+	if len(out.Config) == 0 {
+		out.Config = json.RawMessage(`{}`)
+	}
+	if st, ok := h.Notifier.Status(ch.ID); ok {
+		out.Status = &st
+	}
+	return out
+}
+
+// ListTypes returns the config spec of every channel type (GET /notifications/types).
+func (h *NotificationHandler) ListTypes(c echo.Context) error {
+	return response.OK(c, map[string]any{"types": h.Registry.AllTypesInfo()}, "")
+}
+
+// ListChannels returns all notification channels with their delivery counters
+// (GET /notifications).
+func (h *NotificationHandler) ListChannels(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list notification channels failed", "list notification channels: "+err.Error())
+	}
+	out := make([]notificationResponse, 0, len(list))
+	for _, ch := range list {
+		out = append(out, h.newResponse(ch))
+	}
+	return listResponse(c, "channels", out, notificationListSpec)
+}
+
+// GetChannel returns one notification channel (GET /notifications/:id).
+func (h *NotificationHandler) GetChannel(c echo.Context) error {
+	ch, err := h.byID(c)
+	if ch == nil {
+		return err
+	}
+	return response.OK(c, h.newResponse(*ch), "")
+}
+
+// CreateChannel validates, persists and starts a notification channel (POST /notifications).
+func (h *NotificationHandler) CreateChannel(c echo.Context) error {
+	var req notificationRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	ch := model.NotificationChannel{}
+	if keys := refusedRefsJSON(c.Request().Context(), req.Config, nil); len(keys) > 0 {
+		return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+	}
+	if msg, detail := h.apply(&ch, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), ch.Name)
+	if err != nil {
+		return response.InternalError(c, "create notification channel failed", "get notification channel: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "notification channel name already in use", "a notification channel named "+ch.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &ch); err != nil {
+		return response.InternalError(c, "create notification channel failed", "create notification channel: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, h.newResponse(ch), "notification channel created")
+}
+
+// UpdateChannel replaces a channel's definition and restarts it (PUT /notifications/:id).
+func (h *NotificationHandler) UpdateChannel(c echo.Context) error {
+	ch, err := h.byID(c)
+	if ch == nil {
+		return err
+	}
+	var req notificationRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	if keys := refusedRefsJSON(c.Request().Context(), req.Config, ch.Configuration); len(keys) > 0 {
+		return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+	}
+	oldName := ch.Name
+	if msg, detail := h.apply(ch, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if ch.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), ch.Name)
+		if err != nil {
+			return response.InternalError(c, "update notification channel failed", "get notification channel: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "notification channel name already in use", "a notification channel named "+ch.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), ch); err != nil {
+		return response.InternalError(c, "update notification channel failed", "update notification channel: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, h.newResponse(*ch), "notification channel updated")
+}
+
+// DeleteChannel stops and removes a notification channel (DELETE /notifications/:id). Alerts
+// that still list it simply stop notifying it.
+func (h *NotificationHandler) DeleteChannel(c echo.Context) error {
+	ch, err := h.byID(c)
+	if ch == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), ch.ID); err != nil {
+		return response.InternalError(c, "delete notification channel failed", "delete notification channel: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, nil, "notification channel deleted")
+}
+
+// TestChannel sends a test notification over a channel once, enabled or not, and reports
+// whether it was accepted (POST /notifications/:id/test). A failed send answers 502 with the
+// error.
+func (h *NotificationHandler) TestChannel(c echo.Context) error {
+	ch, err := h.byID(c)
+	if ch == nil {
+		return err
+	}
+	if err := h.Notifier.Test(c.Request().Context(), *ch); err != nil {
+		return response.Error(c, http.StatusBadGateway, "test notification failed", err.Error())
+	}
+	return response.OK(c, map[string]any{"id": ch.ID.String(), "sent": true}, "test notification sent")
+}
+
+// Reload loads every persisted channel into the Notifier. Channels that fail to create are
+// skipped and logged.
+func (h *NotificationHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[notifications] reload list: %v", err)
+		return
+	}
+	if err := h.Notifier.Load(list); err != nil {
+		log.Printf("[notifications] reload: %v", err)
+	}
+}
+
+// byID loads the channel named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *NotificationHandler) byID(c echo.Context) (*model.NotificationChannel, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	ch, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get notification channel failed", "get notification channel: "+err.Error())
+	}
+	if ch == nil {
+		return nil, response.NotFound(c, "notification channel not found", "notification channel not found")
+	}
+	return ch, nil
+}
+
+// apply copies req onto ch and validates it by creating the channel once. It returns a
+// message and detail for a 400 response, or "" when ch is valid.
+func (h *NotificationHandler) apply(ch *model.NotificationChannel, req notificationRequest) (string, string) {
+	ch.Name = strings.TrimSpace(req.Name)
+	if !channelName.MatchString(ch.Name) {
+		return "invalid name", "name is required and may contain only letters, digits, '_', '.' and '-'"
+	}
+	ch.Type = strings.TrimSpace(req.Type)
+	if _, ok := h.Registry.GetTypeInfo(ch.Type); !ok {
+		return "invalid type", "unknown notification channel type: " + ch.Type
+	}
+	ch.Description = req.Description
+	ch.Enabled = req.Enabled == nil || *req.Enabled
+	// Secrets shown masked by GET /notifications keep their stored values when sent back.
+	ch.Configuration = secrets.KeepMaskedJSON(req.Config, ch.Configuration)
+	if len(ch.Configuration) == 0 || string(ch.Configuration) == "null" {
+		ch.Configuration = json.RawMessage(`{}`)
+	}
+	if err := h.Notifier.Validate(*ch); err != nil {
+		return "invalid config", err.Error()
+	}
+	warnPlaintextSecretsJSON("notifications", ch.Name, ch.Configuration)
+	return "", ""
+}
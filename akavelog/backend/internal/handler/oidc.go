@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+// oidcCookie holds the state, nonce and PKCE verifier of a login in progress.
+const oidcCookie = "akavelog_oidc"
+
+// oidcLoginTimeout bounds how long a login at the identity provider may take.
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDCHandler handles /auth/oidc, logging users in with an OpenID Connect identity provider.
+// A user logging in for the first time gets an account without a password, tied to the
+// provider's subject; at every login that account's role is set from the user's groups.
+// A verified email also logs in to the local account with that email, keeping its role.
+// The result is the same session token POST /auth/login returns.
+type OIDCHandler struct {
+	OIDC   *middleware.OIDC
+	Users  *repository.UserRepository
+	Tokens *middleware.Tokens
+	Auth   *middleware.Authenticator
+	// PostLoginURL, when set, is where the callback redirects with the token in the fragment
+	// (#token=...&expires_at=...), e.g. the frontend; otherwise it answers JSON.
+	PostLoginURL string
+}
+
+// Login redirects to the identity provider (GET /auth/oidc/login).
+func (h *OIDCHandler) Login(c echo.Context) error {
+	state, nonce, verifier, err := middleware.NewOIDCLogin()
+	if err != nil {
+		return response.InternalError(c, "SSO login failed", "generate state: "+err.Error())
+	}
+	target, err := h.OIDC.AuthCodeURL(c.Request().Context(), state, nonce, verifier)
+	if err != nil {
+		return response.Error(c, http.StatusBadGateway, "SSO login failed", err.Error())
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcCookie,
+		Value:    state + "." + nonce + "." + verifier,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcLoginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusFound, target)
+}
+
+// Callback finishes a login the identity provider redirected back from, and returns or
+// redirects with a session token (GET /auth/oidc/callback).
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	if e := c.QueryParam("error"); e != "" {
+		return response.Error(c, http.StatusUnauthorized, "SSO login failed", e+": "+c.QueryParam("error_description"))
+	}
+	cookie, err := c.Cookie(oidcCookie)
+	var parts []string
+	if err == nil {
+		parts = strings.Split(cookie.Value, ".")
+	}
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(c.QueryParam("state"))) != 1 {
+		return response.BadRequest(c, "invalid SSO state", "the login expired or was started in another browser; start it again at /auth/oidc/login")
+	}
+	c.SetCookie(&http.Cookie{Name: oidcCookie, Path: "/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	ctx := c.Request().Context()
+	id, err := h.OIDC.Exchange(ctx, c.QueryParam("code"), parts[2], parts[1])
+	if err != nil {
+		if errors.Is(err, middleware.ErrInvalidToken) {
+			return response.Error(c, http.StatusUnauthorized, "SSO login failed", "the identity provider returned an invalid ID token")
+		}
+		return response.Error(c, http.StatusBadGateway, "SSO login failed", err.Error())
+	}
+	u, err := h.Users.GetBySSO(ctx, id.Issuer, id.Subject)
+	if err == nil && u == nil && id.Email != "" {
+		u, err = h.Users.GetByEmail(ctx, id.Email)
+	}
+	if err != nil {
+		return response.InternalError(c, "SSO login failed", "get user: "+err.Error())
+	}
+	if u == nil {
+		if id.Email == "" {
+			return response.Error(c, http.StatusForbidden, "SSO login refused", "the identity provider sent no verified email")
+		}
+		if id.Role == "" {
+			return response.Error(c, http.StatusForbidden, "SSO login refused", "your groups grant no akavelog role")
+		}
+		// No password: the account can only log in through the identity provider until an
+		// admin sets one.
+		u = &model.User{Email: id.Email, Name: id.Name, Role: id.Role, SSOIssuer: id.Issuer, SSOSubject: id.Subject}
+		if err := h.Users.Create(ctx, u); err != nil {
+			return response.InternalError(c, "SSO login failed", "create user: "+err.Error())
+		}
+	} else {
+		if u.Disabled {
+			return response.Error(c, http.StatusForbidden, "SSO login refused", "this user is disabled")
+		}
+		changed, refused := linkSSO(u, id)
+		if refused != "" {
+			return response.Error(c, http.StatusForbidden, "SSO login refused", refused)
+		}
+		if changed {
+			if err := h.Users.Update(ctx, u); err != nil {
+				return response.InternalError(c, "SSO login failed", "update user: "+err.Error())
+			}
+			h.Auth.Forget()
+		}
+	}
+	token, exp, err := h.Tokens.Issue(*u)
+	if err != nil {
+		return response.InternalError(c, "SSO login failed", "issue token: "+err.Error())
+	}
+	now := time.Now()
+	if err := h.Users.TouchLogin(ctx, u.ID, now); err != nil {
+		log.Printf("[auth] record login of %s: %v", u.Email, err)
+	}
+	u.LastLoginAt = &now
+	if h.PostLoginURL != "" {
+		fragment := url.Values{"token": {token}, "expires_at": {exp.Format(time.RFC3339)}}
+		return c.Redirect(http.StatusFound, h.PostLoginURL+"#"+fragment.Encode())
+	}
+	return response.OK(c, map[string]any{
+		"token":      token,
+		"expires_at": exp.Format(time.RFC3339),
+		"user":       newUserResponse(*u),
+	}, "")
+}
+
+// linkSSO updates u, found for the identity id logged in as, and reports whether it changed,
+// or why the login is refused. Accounts created by single sign-on take their name and role
+// from the provider; other accounts keep theirs. A passwordless account without a subject
+// was created by single sign-on before subjects were recorded, and is tied to id.
+func linkSSO(u *model.User, id *middleware.OIDCIdentity) (bool, string) {
+	if u.SSOSubject == "" && u.PasswordHash == "" {
+		u.SSOIssuer, u.SSOSubject = id.Issuer, id.Subject
+	} else if u.SSOSubject == "" {
+		return false, ""
+	} else if u.SSOIssuer != id.Issuer || u.SSOSubject != id.Subject {
+		return false, "this email belongs to another single sign-on account"
+	}
+	if id.Role == "" {
+		return false, "your groups grant no akavelog role"
+	}
+	if id.Name != "" {
+		u.Name = id.Name
+	}
+	u.Role = id.Role
+	return true, ""
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestLinkSSO(t *testing.T) {
+	id := &middleware.OIDCIdentity{Issuer: "https://idp", Subject: "00u1", Email: "dev@example.com", Name: "Dev", Role: model.RoleAdmin}
+	for _, tc := range []struct {
+		name        string
+		user        model.User
+		id          *middleware.OIDCIdentity
+		wantChanged bool
+		wantRefused bool
+		wantRole    string
+	}{
+		{"created by SSO", model.User{Role: model.RoleViewer, SSOIssuer: "https://idp", SSOSubject: "00u1"}, id, true, false, model.RoleAdmin},
+		{"local account keeps its role", model.User{Role: model.RoleViewer, PasswordHash: "$2a$10$x"}, id, false, false, model.RoleViewer},
+		{"SSO account before subjects", model.User{Role: model.RoleViewer}, id, true, false, model.RoleAdmin},
+		{"other subject", model.User{Role: model.RoleViewer, SSOIssuer: "https://idp", SSOSubject: "00u2"}, id, false, true, model.RoleViewer},
+		{"other issuer", model.User{Role: model.RoleViewer, SSOIssuer: "https://other", SSOSubject: "00u1"}, id, false, true, model.RoleViewer},
+		{"no role", model.User{Role: model.RoleViewer, SSOIssuer: "https://idp", SSOSubject: "00u1"},
+			&middleware.OIDCIdentity{Issuer: "https://idp", Subject: "00u1"}, false, true, model.RoleViewer},
+	} {
+		u := tc.user
+		changed, refused := linkSSO(&u, tc.id)
+		if changed != tc.wantChanged || (refused != "") != tc.wantRefused || u.Role != tc.wantRole {
+			t.Errorf("%s: changed %v, refused %q, role %q", tc.name, changed, refused, u.Role)
+		}
+		if changed && (u.SSOIssuer != tc.id.Issuer || u.SSOSubject != tc.id.Subject) {
+			t.Errorf("%s: linked to %q %q", tc.name, u.SSOIssuer, u.SSOSubject)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/secrets"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var outputName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// OutputHandler handles /outputs and /outputs/types. Like PipelineHandler, every change is
+// persisted first and then the whole set is reloaded into the Dispatcher. Renames and deletes
+// also update the streams that list the output, in the same transaction.
+type OutputHandler struct {
+	Registry   *outputs.Registry
+	Repo       *repository.OutputRepository
+	Dispatcher *outputs.Dispatcher
+	UnitOfWork *repository.UnitOfWork
+	Streams    *StreamHandler // reloaded when streams listing the output change
+}
+
+type outputResponse struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description,omitempty"`
+	Enabled     bool            `json:"enabled"`
+	AllEntries  bool            `json:"all_entries"`
+	Config      json.RawMessage `json:"config"`
+	Status      *outputs.Status `json:"status"` // null when the output is not running
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// outputListSpec filters GET /outputs by type and enabled; q searches names and descriptions.
+var outputListSpec = listSpec[outputResponse]{
+	text: func(o outputResponse) string { return o.Name + " " + o.Description },
+	filters: map[string]func(outputResponse, string) bool{
+		"type":    equalFold(func(o outputResponse) string { return o.Type }),
+		"enabled": boolFilter(func(o outputResponse) bool { return o.Enabled }),
+	},
+	sorts: namedSorts(func(o outputResponse) string { return o.Name }, func(o outputResponse) string { return o.CreatedAt }, func(o outputResponse) string { return o.UpdatedAt }),
+}
+
+type outputRequest struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Enabled     *bool           `json:"enabled"` // default true
+	AllEntries  bool            `json:"all_entries"`
+	Config      json.RawMessage `json:"config"`
+}
+
+func (h *OutputHandler) newResponse(o model.Output) outputResponse {
+	out := outputResponse{
+		ID:          o.ID.String(),
+		Name:        o.Name,
+		Type:        o.Type,
+		Description: o.Description,
+		Enabled:     o.Enabled,
+		AllEntries:  o.AllEntries,
+		Config:      secrets.RedactJSON(o.Configuration),
+		CreatedAt:   o.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   o.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if len(out.Config) == 0 {
+		out.Config = json.RawMessage(`{}`)
+	}
+	if st, ok := h.Dispatcher.Status(o.ID); ok {
+		out.Status = &st
+	}
+	return out
+}
+
+// ListTypes returns the config spec of every registered output type (GET /outputs/types).
+func (h *OutputHandler) ListTypes(c echo.Context) error {
+	return response.OK(c, map[string]any{"types": h.Registry.AllTypesInfo()}, "")
+}
+
+// GetTypeInfo returns the config spec for one output type (GET /outputs/types/:type).
+func (h *OutputHandler) GetTypeInfo(c echo.Context) error {
+	typeName := c.Param("type")
+	info, ok := h.Registry.GetTypeInfo(typeName)
+	if !ok {
+		return response.NotFound(c, "unknown output type", "unknown output type: "+typeName)
+	}
+	return response.OK(c, info, "")
+}
+
+// ListOutputs returns all outputs with their delivery counters (GET /outputs).
+func (h *OutputHandler) ListOutputs(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list outputs failed", "list outputs: "+err.Error())
+	}
+	out := make([]outputResponse, 0, len(list))
+	for _, o := range list {
+		out = append(out, h.newResponse(o))
+	}
+	return listResponse(c, "outputs", out, outputListSpec)
+}
+
+// GetOutput returns one output (GET /outputs/:id).
+func (h *OutputHandler) GetOutput(c echo.Context) error {
+	o, err := h.byID(c)
+	if o == nil {
+		return err
+	}
+	return response.OK(c, h.newResponse(*o), "")
+}
+
+// GetStatus returns only the runtime status of an output (GET /outputs/:id/status), for
+// polling delivery counters and output details such as replication lag.
+func (h *OutputHandler) GetStatus(c echo.Context) error {
+	o, err := h.byID(c)
+	if o == nil {
+		return err
+	}
+	st, running := h.Dispatcher.Status(o.ID)
+	out := map[string]any{"id": o.ID.String(), "name": o.Name, "running": running, "status": nil}
+	if running {
+		out["status"] = st
+	}
+	return response.OK(c, out, "")
+}
+
+// CreateOutput validates, persists and starts an output (POST /outputs).
+func (h *OutputHandler) CreateOutput(c echo.Context) error {
+	var req outputRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	o := model.Output{}
+	if keys := refusedRefsJSON(c.Request().Context(), req.Config, nil); len(keys) > 0 {
+		return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+	}
+	if msg, detail := h.apply(&o, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), o.Name)
+	if err != nil {
+		return response.InternalError(c, "create output failed", "get output: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, 409, "output name already in use", "an output named "+o.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &o); err != nil {
+		return response.InternalError(c, "create output failed", "create output: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, h.newResponse(o), "output created")
+}
+
+// UpdateOutput replaces an output's definition and restarts it (PUT /outputs/:id).
+func (h *OutputHandler) UpdateOutput(c echo.Context) error {
+	o, err := h.byID(c)
+	if o == nil {
+		return err
+	}
+	var req outputRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	if keys := refusedRefsJSON(c.Request().Context(), req.Config, o.Configuration); len(keys) > 0 {
+		return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+	}
+	oldName := o.Name
+	if msg, detail := h.apply(o, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if o.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), o.Name)
+		if err != nil {
+			return response.InternalError(c, "update output failed", "get output: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, 409, "output name already in use", "an output named "+o.Name+" already exists")
+		}
+	}
+	var renamed int64
+	err = h.UnitOfWork.Do(c.Request().Context(), func(tx *repository.Tx) error {
+		if err := tx.Outputs().Update(c.Request().Context(), o); err != nil {
+			return err
+		}
+		if o.Name == oldName {
+			return nil
+		}
+		renamed, err = tx.Streams().RenameOutput(c.Request().Context(), oldName, o.Name)
+		return err
+	})
+	if err != nil {
+		return response.InternalError(c, "update output failed", "update output: "+err.Error())
+	}
+	if renamed > 0 {
+		h.Streams.Reload(c.Request().Context())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, h.newResponse(*o), "output updated")
+}
+
+// DeleteOutput stops and removes an output (DELETE /outputs/:id), and removes it from the
+// outputs of the streams that list it.
+func (h *OutputHandler) DeleteOutput(c echo.Context) error {
+	o, err := h.byID(c)
+	if o == nil {
+		return err
+	}
+	var detached int64
+	err = h.UnitOfWork.Do(c.Request().Context(), func(tx *repository.Tx) error {
+		if err := tx.Outputs().Delete(c.Request().Context(), o.ID); err != nil {
+			return err
+		}
+		detached, err = tx.Streams().DetachOutput(c.Request().Context(), o.Name)
+		return err
+	})
+	if err != nil {
+		return response.InternalError(c, "delete output failed", "delete output: "+err.Error())
+	}
+	if detached > 0 {
+		h.Streams.Reload(c.Request().Context())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, map[string]any{"detached_streams": detached}, "output deleted")
+}
+
+// Reload loads every persisted output into the Dispatcher. Outputs that fail to create are
+// skipped and logged.
+func (h *OutputHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[outputs] reload list: %v", err)
+		return
+	}
+	if err := h.Dispatcher.Load(list); err != nil {
+		log.Printf("[outputs] reload: %v", err)
+	}
+}
+
+// byID loads the output named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *OutputHandler) byID(c echo.Context) (*model.Output, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	o, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get output failed", "get output: "+err.Error())
+	}
+	if o == nil {
+		return nil, response.NotFound(c, "output not found", "output not found")
+	}
+	return o, nil
+}
+
+// apply copies req onto o and validates it by creating the output once. It returns a message
+// and detail for a 400 response, or "" when o is valid.
+func (h *OutputHandler) apply(o *model.Output, req outputRequest) (string, string) {
+	o.Name = strings.TrimSpace(req.Name)
+	if !outputName.MatchString(o.Name) {
+		return "invalid name", "name is required and may contain only letters, digits, '_', '.' and '-'"
+	}
+	o.Type = strings.TrimSpace(req.Type)
+	if _, ok := h.Registry.GetTypeInfo(o.Type); !ok {
+		return "invalid type", "unknown output type: " + o.Type
+	}
+	o.Description = req.Description
+	o.Enabled = req.Enabled == nil || *req.Enabled
+	o.AllEntries = req.AllEntries
+	// Secrets shown masked by GET /outputs keep their stored values when sent back.
+	o.Configuration = secrets.KeepMaskedJSON(req.Config, o.Configuration)
+	if len(o.Configuration) == 0 || string(o.Configuration) == "null" {
+		o.Configuration = json.RawMessage(`{}`)
+	}
+	if err := h.Dispatcher.Validate(*o); err != nil {
+		return "invalid config", err.Error()
+	}
+	warnPlaintextSecretsJSON("outputs", o.Name, o.Configuration)
+	return "", ""
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/rules"
+	"github.com/akave-ai/akavelog/internal/secrets"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// PipelineHandler handles /pipelines. Every change is persisted first and then the whole set
+// is reloaded into the Manager, so running inputs pick it up without a restart.
+type PipelineHandler struct {
+	Repo      *repository.PipelineRepository
+	InputRepo *repository.InputRepository
+	Projects  *ProjectHandler // optional; resolves project_id
+	Manager   *pipeline.Manager
+}
+
+type pipelineResponse struct {
+	ID          string                  `json:"id"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	InputID     *string                 `json:"input_id"`   // null for global pipelines
+	ProjectID   *string                 `json:"project_id"` // null for pipelines of every project
+	Enabled     bool                    `json:"enabled"`
+	Processors  []model.ProcessorConfig `json:"processors"`
+	CreatedAt   string                  `json:"created_at"`
+	UpdatedAt   string                  `json:"updated_at"`
+}
+
+// pipelineListSpec filters GET /pipelines by enabled; q searches names and descriptions.
+var pipelineListSpec = listSpec[pipelineResponse]{
+	text: func(p pipelineResponse) string { return p.Name + " " + p.Description },
+	filters: map[string]func(pipelineResponse, string) bool{
+		"enabled": boolFilter(func(p pipelineResponse) bool { return p.Enabled }),
+	},
+	sorts: namedSorts(func(p pipelineResponse) string { return p.Name }, func(p pipelineResponse) string { return p.CreatedAt }, func(p pipelineResponse) string { return p.UpdatedAt }),
+}
+
+type pipelineRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	InputID     string                  `json:"input_id"`   // empty for a global pipeline
+	ProjectID   string                  `json:"project_id"` // ID or name; defaults to the input's project
+	Enabled     *bool                   `json:"enabled"`    // default true
+	Processors  []model.ProcessorConfig `json:"processors"`
+}
+
+func newPipelineResponse(p model.Pipeline) pipelineResponse {
+	out := pipelineResponse{
+		ID:          p.ID.String(),
+		Name:        p.Name,
+		Description: p.Description,
+		Enabled:     p.Enabled,
+		Processors:  redactProcessors(p.Processors),
+		CreatedAt:   p.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   p.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if out.Processors == nil {
+		out.Processors = []model.ProcessorConfig{}
+	}
+	if p.InputID != nil {
+		id := p.InputID.String()
+		out.InputID = &id
+	}
+	if p.ProjectID != nil {
+		id := p.ProjectID.String()
+		out.ProjectID = &id
+	}
+	return out
+}
+
+// ListPipelines returns all pipelines in execution order (GET /pipelines), or those of the
+// project the caller is bound to.
+func (h *PipelineHandler) ListPipelines(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list pipelines failed", "list pipelines: "+err.Error())
+	}
+	out := make([]pipelineResponse, 0, len(list))
+	for _, p := range list {
+		if visibleID(c.Request().Context(), p.ProjectID) {
+			out = append(out, newPipelineResponse(p))
+		}
+	}
+	return listResponse(c, "pipelines", out, pipelineListSpec)
+}
+
+// GetPipeline returns one pipeline (GET /pipelines/:id).
+func (h *PipelineHandler) GetPipeline(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	p, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return response.InternalError(c, "get pipeline failed", "get pipeline: "+err.Error())
+	}
+	if p == nil || !visibleID(c.Request().Context(), p.ProjectID) {
+		return response.NotFound(c, "pipeline not found", "pipeline not found")
+	}
+	return response.OK(c, newPipelineResponse(*p), "")
+}
+
+// CreatePipeline validates, persists and loads a pipeline (POST /pipelines).
+func (h *PipelineHandler) CreatePipeline(c echo.Context) error {
+	var req pipelineRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	for _, pc := range req.Processors {
+		if keys := refusedRefs(c.Request().Context(), pc.Config, nil); len(keys) > 0 {
+			return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+		}
+	}
+	p := model.Pipeline{}
+	if msg, detail := h.apply(c.Request().Context(), &p, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if err := h.Repo.Create(c.Request().Context(), &p); err != nil {
+		return response.InternalError(c, "create pipeline failed", "create pipeline: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, newPipelineResponse(p), "pipeline created")
+}
+
+// UpdatePipeline replaces a pipeline's definition (PUT /pipelines/:id).
+func (h *PipelineHandler) UpdatePipeline(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	p, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return response.InternalError(c, "get pipeline failed", "get pipeline: "+err.Error())
+	}
+	if p == nil || !visibleID(c.Request().Context(), p.ProjectID) {
+		return response.NotFound(c, "pipeline not found", "pipeline not found")
+	}
+	var req pipelineRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	// Secrets shown masked by GET /pipelines keep their stored values when sent back to the
+	// processor of the same type at the same position.
+	for i, pc := range req.Processors {
+		var prev map[string]any
+		if i < len(p.Processors) && p.Processors[i].Type == pc.Type {
+			prev = p.Processors[i].Config
+			secrets.KeepMasked(pc.Config, prev)
+		}
+		if keys := refusedRefs(c.Request().Context(), pc.Config, prev); len(keys) > 0 {
+			return response.Error(c, http.StatusForbidden, "insufficient scope", refsDetail(keys))
+		}
+	}
+	if msg, detail := h.apply(c.Request().Context(), p, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if err := h.Repo.Update(c.Request().Context(), p); err != nil {
+		return response.InternalError(c, "update pipeline failed", "update pipeline: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, newPipelineResponse(*p), "pipeline updated")
+}
+
+// DeletePipeline removes a pipeline (DELETE /pipelines/:id).
+func (h *PipelineHandler) DeletePipeline(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	p, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil || p == nil || !visibleID(c.Request().Context(), p.ProjectID) {
+		return response.NotFound(c, "pipeline not found", "pipeline not found")
+	}
+	if err := h.Repo.Delete(c.Request().Context(), id); err != nil {
+		return response.InternalError(c, "delete pipeline failed", "delete pipeline: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, nil, "pipeline deleted")
+}
+
+// apply copies req onto p and validates the result. It returns a message and detail for a
+// 400 response, or "" when p is valid.
+func (h *PipelineHandler) apply(ctx context.Context, p *model.Pipeline, req pipelineRequest) (string, string) {
+	p.Name = strings.TrimSpace(req.Name)
+	if p.Name == "" {
+		return "missing name", "missing 'name'"
+	}
+	p.Description = req.Description
+	p.Enabled = req.Enabled == nil || *req.Enabled
+	p.Processors = req.Processors
+	p.InputID = nil
+	var in *model.Input
+	if req.InputID != "" {
+		id, err := uuid.Parse(req.InputID)
+		if err != nil {
+			return "invalid input_id", "input_id must be a UUID"
+		}
+		in, err = h.InputRepo.GetByID(ctx, id)
+		if err != nil || in == nil || !visibleID(ctx, in.ProjectID) {
+			return "input not found", "input " + req.InputID + " not found"
+		}
+		p.InputID = &id
+	}
+	projectID, msg, err := h.Projects.resolve(ctx, req.ProjectID)
+	if err != nil {
+		return "invalid project_id", "get project: " + err.Error()
+	}
+	if msg != "" {
+		return "invalid project_id", msg
+	}
+	if !visibleID(ctx, projectID) {
+		return "invalid project_id", "you may only manage pipelines of project " + callerProject(ctx)
+	}
+	p.ProjectID = projectID
+	if in != nil && in.ProjectID != nil {
+		// Entries of the input always belong to its project.
+		if p.ProjectID == nil {
+			p.ProjectID = in.ProjectID
+		} else if *p.ProjectID != *in.ProjectID {
+			return "invalid project_id", "input " + req.InputID + " belongs to project " + in.ProjectID.String()
+		}
+	}
+	if err := pipeline.Validate(*p); err != nil {
+		return "invalid pipeline", err.Error()
+	}
+	return "", ""
+}
+
+// Reload loads every persisted pipeline into the Manager. Pipelines that no longer compile
+// are skipped and logged.
+func (h *PipelineHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[pipeline] reload list: %v", err)
+		return
+	}
+	if err := h.Manager.Load(list); err != nil {
+		log.Printf("[pipeline] reload: %v", err)
+	}
+}
+
+type extractorTestRequest struct {
+	Type    string         `json:"type"` // regex, dissect, cef or leef
+	Config  map[string]any `json:"config"`
+	Message string         `json:"message"`
+}
+
+// TestExtractor runs a candidate extractor against a sample message without saving anything
+// (POST /extractors/test).
+func (h *PipelineHandler) TestExtractor(c echo.Context) error {
+	var req extractorTestRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	ex, err := pipeline.NewExtractor(req.Type, req.Config)
+	if err != nil {
+		return response.BadRequest(c, "invalid extractor", err.Error())
+	}
+	fields, matched := ex.Extract(req.Message)
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	return response.OK(c, map[string]any{"matched": matched, "fields": fields}, "")
+}
+
+// GetPipelineStats reports the loaded processors of a pipeline with their counters, e.g. the
+// match rate of a dry-run filter (GET /pipelines/:id/stats).
+func (h *PipelineHandler) GetPipelineStats(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	if callerProject(c.Request().Context()) != "" {
+		p, err := h.Repo.GetByID(c.Request().Context(), id)
+		if err != nil || p == nil || !visibleID(c.Request().Context(), p.ProjectID) {
+			return response.NotFound(c, "pipeline not found", "pipeline not found")
+		}
+	}
+	stats, ok := h.Manager.Stats(id)
+	if !ok {
+		return response.NotFound(c, "pipeline not loaded", "pipeline is unknown, disabled or invalid")
+	}
+	return response.OK(c, map[string]any{"processors": stats}, "")
+}
+
+type ruleValidateRequest struct {
+	Expression string           `json:"expression"`
+	Entries    []model.LogEntry `json:"entries"` // optional samples to evaluate
+}
+
+// ValidateRule checks the syntax of a rule expression and, given sample entries, reports
+// which of them match (POST /rules/validate).
+func (h *PipelineHandler) ValidateRule(c echo.Context) error {
+	var req ruleValidateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	expr, err := rules.Parse(req.Expression)
+	if err != nil {
+		out := map[string]any{"valid": false, "error": err.Error()}
+		var syn *rules.SyntaxError
+		if errors.As(err, &syn) {
+			out["position"] = syn.Pos
+		}
+		return response.OK(c, out, "")
+	}
+	matches := make([]bool, len(req.Entries))
+	matched := 0
+	for i := range req.Entries {
+		if expr.Match(pipeline.EntryGetter(&req.Entries[i])) {
+			matches[i] = true
+			matched++
+		}
+	}
+	out := map[string]any{"valid": true}
+	if len(req.Entries) > 0 {
+		out["evaluated"] = len(req.Entries)
+		out["matched"] = matched
+		out["match_rate"] = float64(matched) / float64(len(req.Entries))
+		out["matches"] = matches
+	}
+	return response.OK(c, out, "")
+}
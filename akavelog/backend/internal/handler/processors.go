@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+// ProcessorHandler handles /processors/types, the catalogue a UI builds pipelines from.
+type ProcessorHandler struct {
+	Registry *processors.Registry
+}
+
+// ListTypes returns the config spec of every registered processor type (GET /processors/types).
+func (h *ProcessorHandler) ListTypes(c echo.Context) error {
+	return response.OK(c, map[string]any{"types": h.Registry.AllTypesInfo()}, "")
+}
+
+// GetTypeInfo returns the config spec for one processor type (GET /processors/types/:type).
+func (h *ProcessorHandler) GetTypeInfo(c echo.Context) error {
+	typeName := c.Param("type")
+	info, ok := h.Registry.GetTypeInfo(typeName)
+	if !ok {
+		return response.NotFound(c, "unknown processor type", "unknown processor type: "+typeName)
+	}
+	return response.OK(c, info, "")
+}
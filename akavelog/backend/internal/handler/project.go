@@ -1,3 +1,310 @@
 package handler
 
-// @dev POST /projects → create project + API key
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var projectName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ProjectHandler handles /projects, the tenants entries are stored under. After every change
+// the projects are reloaded into the set Resolve answers from, which input buffers consult
+// for the project_id of each entry, and the batcher for the storage target of its batches.
+type ProjectHandler struct {
+	Repo    *repository.ProjectRepository
+	Targets []string // storage targets an o3_target may name (storage.targets)
+
+	known   atomic.Pointer[map[string]string] // project ID and name → ID
+	targets atomic.Pointer[map[string]string] // project ID → o3_target, for projects that set one
+}
+
+type projectResponse struct {
+	ID          string                   `json:"id"`
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	OwnerEmail  string                   `json:"owner_email,omitempty"`
+	O3Target    string                   `json:"o3_target,omitempty"`
+	Usage       *repository.ProjectUsage `json:"usage,omitempty"` // GET /projects/:id only
+	CreatedAt   string                   `json:"created_at"`
+	UpdatedAt   string                   `json:"updated_at"`
+}
+
+// projectListSpec filters GET /projects by owner_email; q searches names and descriptions.
+var projectListSpec = listSpec[projectResponse]{
+	text: func(p projectResponse) string { return p.Name + " " + p.Description },
+	filters: map[string]func(projectResponse, string) bool{
+		"owner_email": equalFold(func(p projectResponse) string { return p.OwnerEmail }),
+	},
+	sorts: namedSorts(func(p projectResponse) string { return p.Name }, func(p projectResponse) string { return p.CreatedAt }, func(p projectResponse) string { return p.UpdatedAt }),
+}
+
+type projectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OwnerEmail  string `json:"owner_email"`
+	O3Target    string `json:"o3_target"`
+}
+
+func newProjectResponse(p model.Project) projectResponse {
+	return projectResponse{
+		ID:          p.ID.String(),
+		Name:        p.Name,
+		Description: p.Description,
+		OwnerEmail:  p.OwnerEmail,
+		O3Target:    p.O3Target,
+		CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// ListProjects returns all projects (GET /projects).
+func (h *ProjectHandler) ListProjects(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list projects failed", "list projects: "+err.Error())
+	}
+	out := make([]projectResponse, 0, len(list))
+	for _, p := range list {
+		out = append(out, newProjectResponse(p))
+	}
+	return listResponse(c, "projects", out, projectListSpec)
+}
+
+// GetProject returns one project with its inputs, pipelines and stored batches counted
+// (GET /projects/:id).
+func (h *ProjectHandler) GetProject(c echo.Context) error {
+	p, err := h.byID(c)
+	if p == nil {
+		return err
+	}
+	usage, err := h.Repo.Usage(c.Request().Context(), p.ID)
+	if err != nil {
+		return response.InternalError(c, "get project failed", "count project usage: "+err.Error())
+	}
+	out := newProjectResponse(*p)
+	out.Usage = &usage
+	return response.OK(c, out, "")
+}
+
+// CreateProject persists a project (POST /projects).
+func (h *ProjectHandler) CreateProject(c echo.Context) error {
+	var req projectRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	p := model.Project{}
+	if msg, detail := applyProject(&p, req, h.Targets); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), p.Name)
+	if err != nil {
+		return response.InternalError(c, "create project failed", "get project: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "project name already in use", "a project named "+p.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &p); err != nil {
+		return response.InternalError(c, "create project failed", "create project: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, newProjectResponse(p), "project created")
+}
+
+// UpdateProject replaces a project's name, description, owner and storage target (PUT
+// /projects/:id). Its ID, and so the keys its entries are stored under, never changes; a new
+// o3_target applies to the batches uploaded from then on.
+func (h *ProjectHandler) UpdateProject(c echo.Context) error {
+	p, err := h.byID(c)
+	if p == nil {
+		return err
+	}
+	var req projectRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := p.Name
+	if msg, detail := applyProject(p, req, h.Targets); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if p.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), p.Name)
+		if err != nil {
+			return response.InternalError(c, "update project failed", "get project: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "project name already in use", "a project named "+p.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), p); err != nil {
+		return response.InternalError(c, "update project failed", "update project: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, newProjectResponse(*p), "project updated")
+}
+
+// DeleteProject removes a project (DELETE /projects/:id). A project that inputs or pipelines
+// still belong to answers 409. Its stored objects are kept until retention removes them.
+func (h *ProjectHandler) DeleteProject(c echo.Context) error {
+	p, err := h.byID(c)
+	if p == nil {
+		return err
+	}
+	usage, err := h.Repo.Usage(c.Request().Context(), p.ID)
+	if err != nil {
+		return response.InternalError(c, "delete project failed", "count project usage: "+err.Error())
+	}
+	if usage.Inputs > 0 || usage.Pipelines > 0 {
+		return response.Error(c, http.StatusConflict, "project in use",
+			"project "+p.Name+" still has inputs or pipelines; move or delete them first")
+	}
+	if err := h.Repo.Delete(c.Request().Context(), p.ID); err != nil {
+		return response.InternalError(c, "delete project failed", "delete project: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, nil, "project deleted")
+}
+
+// Reload loads every project into the set Resolve answers from. On error the previous set
+// is kept.
+func (h *ProjectHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[projects] reload list: %v", err)
+		return
+	}
+	known := make(map[string]string, 2*len(list))
+	targets := make(map[string]string)
+	for _, p := range list {
+		known[p.Name] = p.ID.String()
+	}
+	for _, p := range list {
+		known[p.ID.String()] = p.ID.String()
+		if p.O3Target != "" {
+			targets[p.ID.String()] = p.O3Target
+		}
+	}
+	h.known.Store(&known)
+	h.targets.Store(&targets)
+}
+
+// StorageTarget returns the o3_target of the project projectID, or "" when it has none.
+func (h *ProjectHandler) StorageTarget(projectID string) string {
+	targets := h.targets.Load()
+	if targets == nil {
+		return ""
+	}
+	return (*targets)[projectID]
+}
+
+// Resolve returns the ID of the project ref names by ID or name, and false when there is no
+// such project.
+func (h *ProjectHandler) Resolve(ref string) (string, bool) {
+	known := h.known.Load()
+	if known == nil {
+		return "", false
+	}
+	id, ok := (*known)[ref]
+	return id, ok
+}
+
+// resolve looks up the project_id of a request by ID or name. "" is no project. It returns a
+// detail for a 400 response when no such project exists.
+func (h *ProjectHandler) resolve(ctx context.Context, ref string) (*uuid.UUID, string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, "", nil
+	}
+	if h == nil {
+		return nil, "projects are not available", nil
+	}
+	var p *model.Project
+	var err error
+	if id, perr := uuid.Parse(ref); perr == nil {
+		p, err = h.Repo.GetByID(ctx, id)
+	} else {
+		p, err = h.Repo.GetByName(ctx, ref)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if p == nil {
+		return nil, "unknown project: " + ref, nil
+	}
+	return &p.ID, "", nil
+}
+
+// byID loads the project named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *ProjectHandler) byID(c echo.Context) (*model.Project, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	p, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get project failed", "get project: "+err.Error())
+	}
+	if p == nil {
+		return nil, response.NotFound(c, "project not found", "project not found")
+	}
+	return p, nil
+}
+
+// applyProject copies req onto p and validates it; its o3_target must be one of targets. It
+// returns a message and detail for a 400 response, or "" when p is valid.
+func applyProject(p *model.Project, req projectRequest, targets []string) (string, string) {
+	p.Name = strings.TrimSpace(req.Name)
+	if !projectName.MatchString(p.Name) {
+		return "invalid name", "name is required, at most 64 characters, and may contain only letters, digits, '_', '.' and '-'"
+	}
+	if _, err := uuid.Parse(p.Name); err == nil {
+		return "invalid name", "name must not be a UUID"
+	}
+	p.Description = req.Description
+	p.OwnerEmail = strings.TrimSpace(req.OwnerEmail)
+	if p.OwnerEmail != "" {
+		if _, err := mail.ParseAddress(p.OwnerEmail); err != nil {
+			return "invalid owner_email", err.Error()
+		}
+	}
+	p.O3Target = strings.TrimSpace(req.O3Target)
+	return checkStorageTarget(targets, p.O3Target)
+}
+
+// callerProject returns the project the caller of ctx is bound to, or "" when it may access
+// every project.
+func callerProject(ctx context.Context) string {
+	if p := middleware.PrincipalFromContext(ctx); p != nil {
+		return p.ProjectID
+	}
+	return ""
+}
+
+// visible reports whether the caller of ctx may access a resource of project, the ID of its
+// project or "" for none. Callers bound to a project only access that project's resources;
+// the others are answered 404, as if the resource did not exist.
+func visible(ctx context.Context, project string) bool {
+	bound := callerProject(ctx)
+	return bound == "" || strings.EqualFold(bound, project)
+}
+
+// visibleID is visible for a project ID that may be nil.
+func visibleID(ctx context.Context, project *uuid.UUID) bool {
+	if project == nil {
+		return visible(ctx, "")
+	}
+	return visible(ctx, project.String())
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func TestVisible(t *testing.T) {
+	shop, other := uuid.New(), uuid.New()
+	bound := middleware.WithPrincipal(context.Background(), &middleware.Principal{Name: "tenant", ProjectID: shop.String()})
+	everyProject := middleware.WithPrincipal(context.Background(), &middleware.Principal{Name: "ops"})
+
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		project *uuid.UUID
+		want    bool
+	}{
+		{"bound, own project", bound, &shop, true},
+		{"bound, other project", bound, &other, false},
+		{"bound, no project", bound, nil, false},
+		{"unbound", everyProject, &other, true},
+		{"unbound, no project", everyProject, nil, true},
+		{"server", context.Background(), &other, true},
+	} {
+		if got := visibleID(tc.ctx, tc.project); got != tc.want {
+			t.Errorf("%s: visibleID = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if !visible(bound, shop.String()) || visible(bound, "") || visible(bound, "other") {
+		t.Error("visible does not confine a bound caller to its project")
+	}
+	if callerProject(bound) != shop.String() || callerProject(everyProject) != "" {
+		t.Error("callerProject")
+	}
+}
+
+func TestListInputMetricsConfined(t *testing.T) {
+	shop, other := uuid.New(), uuid.New()
+	h := &InputHandler{Instances: map[uuid.UUID]InstanceRecord{}}
+	for _, in := range []model.Input{
+		{ID: uuid.New(), Title: "shop", ProjectID: &shop},
+		{ID: uuid.New(), Title: "other", ProjectID: &other},
+		{ID: uuid.New(), Title: "shared"},
+	} {
+		h.Instances[in.ID] = InstanceRecord{Input: in, Metrics: inputs.NewMetrics()}
+	}
+
+	list := func(p *middleware.Principal) []string {
+		req := httptest.NewRequest(http.MethodGet, "/inputs/metrics", nil)
+		if p != nil {
+			req = req.WithContext(middleware.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		if err := h.ListInputMetrics(echo.New().NewContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("ListInputMetrics: %v %d", err, rec.Code)
+		}
+		var body struct {
+			Data struct {
+				Inputs []inputMetricsResponse `json:"inputs"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, in := range body.Data.Inputs {
+			titles = append(titles, in.Title)
+		}
+		return titles
+	}
+	if got := list(&middleware.Principal{ProjectID: shop.String()}); len(got) != 1 || got[0] != "shop" {
+		t.Errorf("bound to shop: %v", got)
+	}
+	if got := list(nil); len(got) != 3 {
+		t.Errorf("unbound: %v", got)
+	}
+}
+
+func TestKeyVisible(t *testing.T) {
+	shop := uuid.New().String()
+	bound := middleware.WithPrincipal(context.Background(), &middleware.Principal{Name: "tenant", ProjectID: shop})
+	for key, want := range map[string]bool{
+		"logs/" + shop + "/2024/02/17/b1.json.gz":             true,
+		"streams/errors/" + shop + "/2024/02/17/b1.json.gz":   true,
+		"logs/" + uuid.New().String() + "/2024/02/17/b1.json": false,
+		"logs/default/2024/02/17/b1.json.gz":                  false,
+		"reports/" + shop + ".json":                           false,
+	} {
+		if got := keyVisible(bound, key); got != want {
+			t.Errorf("keyVisible(%s) = %v, want %v", key, got, want)
+		}
+	}
+	if !keyVisible(context.Background(), "logs/default/2024/02/17/b1.json.gz") {
+		t.Error("an unbound caller is confined")
+	}
+}
+
+func TestPresignConfined(t *testing.T) {
+	shop := uuid.New().String()
+	fs, err := storage.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &UploadHandler{Store: storage.NewClient(fs)}
+	key := "logs/" + uuid.New().String() + "/2024/02/17/b1.json"
+	if err := h.Store.PutObject(context.Background(), key, []byte("{}\n"), "application/x-ndjson"); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/uploads/presign", strings.NewReader(`{"key":"`+key+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{ProjectID: shop}))
+	rec := httptest.NewRecorder()
+	if err := h.Presign(echo.New().NewContext(req, rec)); err != nil || rec.Code != http.StatusNotFound {
+		t.Errorf("presign of another project's batch: %v %d", err, rec.Code)
+	}
+}
@@ -1,3 +1,160 @@
 package handler
 
-// @dev POST /query → searches logs
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+	defaultQueryRange = 24 * time.Hour
+)
+
+// QueryHandler handles /query, searching the entries uploaded to O3. Store is nil when O3 is
+// not configured; POST /query then answers 503. Runs are recorded in History, when set.
+type QueryHandler struct {
+	Index   search.Index
+	Store   search.Store
+	History QueryHistory
+}
+
+type queryRequest struct {
+	Query     string `json:"query"`
+	ProjectID string `json:"project_id"`
+	Start     string `json:"start"` // RFC 3339; default end - 24h
+	End       string `json:"end"`   // RFC 3339; default now
+	Limit     int    `json:"limit"` // default 100, at most 1000
+
+	savedSearch *uuid.UUID // the saved search being run, if any
+}
+
+// Search returns the newest entries of a time range matching a query (POST /query). It reads
+// the batch objects the batches index lists for the range, newest first, and stops once it has
+// limit matches or has read search.DefaultMaxObjects objects.
+func (h *QueryHandler) Search(c echo.Context) error {
+	var req queryRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	return h.search(c, req)
+}
+
+func (h *QueryHandler) search(c echo.Context, req queryRequest) error {
+	if h.Store == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "search not available", "search requires O3 storage")
+	}
+	opts, msg, detail := scanOptions(req.Query, req.ProjectID, req.Start, req.End)
+	if msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	opts.Newest = true
+	limit := defaultQueryLimit
+	if req.Limit != 0 {
+		if req.Limit < 0 || req.Limit > maxQueryLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and 1000")
+		}
+		limit = req.Limit
+	}
+	type hit struct {
+		at    time.Time
+		entry model.LogEntry
+	}
+	var hits []hit
+	began := time.Now()
+	st, err := search.Scan(c.Request().Context(), h.Index, h.Store, opts, func(e *model.LogEntry, t time.Time) bool {
+		hits = append(hits, hit{t, *e})
+		return len(hits) < limit
+	})
+	recordRun(h.History, newQueryRun(c, model.QuerySearch, req.savedSearch, opts, began, len(hits)), err)
+	if err != nil {
+		return response.InternalError(c, "search failed", err.Error())
+	}
+	// Objects overlap in time, so order the matches across them, newest first.
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].at.After(hits[j].at) })
+	entries := make([]model.LogEntry, len(hits))
+	for i, m := range hits {
+		entries[i] = m.entry
+	}
+	return response.OK(c, map[string]any{
+		"query":   opts.Query.String(),
+		"start":   opts.Start,
+		"end":     opts.End,
+		"entries": entries,
+		"count":   len(entries),
+		"stats":   st,
+	}, "")
+}
+
+// scanOptions parses the query and time range of a search. end defaults to now and start to
+// 24 hours before end. It returns a message and detail for a 400 response, or "" when valid.
+func scanOptions(src, projectID, start, end string) (search.ScanOptions, string, string) {
+	q, err := query.Parse(src)
+	if err != nil {
+		return search.ScanOptions{}, "invalid query", err.Error()
+	}
+	opts := search.ScanOptions{ProjectID: strings.TrimSpace(projectID), Query: q, End: time.Now().UTC()}
+	if end != "" {
+		if opts.End, err = time.Parse(time.RFC3339Nano, end); err != nil {
+			return opts, "invalid end", "end must be an RFC 3339 time"
+		}
+	}
+	opts.Start = opts.End.Add(-defaultQueryRange)
+	if start != "" {
+		if opts.Start, err = time.Parse(time.RFC3339Nano, start); err != nil {
+			return opts, "invalid start", "start must be an RFC 3339 time"
+		}
+	}
+	if !opts.Start.Before(opts.End) {
+		return opts, "invalid range", "start must be before end"
+	}
+	return opts, "", ""
+}
+
+// newQueryRun describes a search or aggregation that started at began, for the history.
+func newQueryRun(c echo.Context, kind model.QueryKind, saved *uuid.UUID, opts search.ScanOptions, began time.Time, results int) *model.QueryRun {
+	return &model.QueryRun{
+		SavedSearchID: saved,
+		Owner:         requestOwner(c),
+		Kind:          kind,
+		Query:         opts.Query.String(),
+		ProjectID:     opts.ProjectID,
+		Start:         opts.Start,
+		End:           opts.End,
+		DurationMS:    time.Since(began).Milliseconds(),
+		Results:       results,
+	}
+}
+
+type queryValidateRequest struct {
+	Query string `json:"query"`
+}
+
+// Validate parses a query and returns its parse tree and the rule expression it compiles to,
+// or the syntax error and its position (POST /query/validate).
+func (h *QueryHandler) Validate(c echo.Context) error {
+	var req queryValidateRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	q, err := query.Parse(req.Query)
+	if err != nil {
+		out := map[string]any{"valid": false, "error": err.Error()}
+		var syn *query.SyntaxError
+		if errors.As(err, &syn) {
+			out["position"] = syn.Pos
+		}
+		return response.OK(c, out, "")
+	}
+	return response.OK(c, map[string]any{"valid": true, "tree": q.Tree(), "rule": q.Rule()}, "")
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/akave-ai/akavelog/internal/recent"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultRecentLimit = 200
+
+// RecentLogsHandler handles /logs/recent, the latest entries accepted by the inputs.
+type RecentLogsHandler struct {
+	Store *recent.Store
+}
+
+// ListRecent returns the latest entries, oldest first (GET /logs/recent?service=&level=&input_id=&since=&before=&after=&limit=).
+// When older entries pass the filter too, next_cursor is the before of the next page.
+func (h *RecentLogsHandler) ListRecent(c echo.Context) error {
+	return recentResponse(c, h.Store, c.QueryParam("input_id"))
+}
+
+// RecentLogs returns the latest entries of one input, oldest first (GET /inputs/:id/recent),
+// with the parameters of GET /logs/recent. They are kept for each input on its own, so a busy
+// input does not push out the entries of a quiet one.
+func (h *InputHandler) RecentLogs(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid id", "invalid id")
+	}
+	in, err := h.InputRepo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return response.InternalError(c, "get input failed", "get input: "+err.Error())
+	}
+	if in == nil || !visibleID(c.Request().Context(), in.ProjectID) {
+		return response.NotFound(c, "input not found", "input not found")
+	}
+	return recentResponse(c, h.Recent, id.String())
+}
+
+// recentResponse answers the entries of store passing the query parameters of c, of inputID
+// when set.
+func recentResponse(c echo.Context, store *recent.Store, inputID string) error {
+	if store == nil {
+		return response.OK(c, map[string]any{"logs": []recent.Entry{}, "count": 0}, "")
+	}
+	f := recent.Filter{
+		InputID: inputID,
+		Service: c.QueryParam("service"),
+		Level:   c.QueryParam("level"),
+		Limit:   defaultRecentLimit,
+	}
+	var err error
+	if f.Since, err = queryTime(c, "since"); err != nil {
+		return response.BadRequest(c, "invalid since", "since must be an RFC 3339 time")
+	}
+	for _, p := range []struct {
+		name string
+		to   *uint64
+	}{{"before", &f.Before}, {"after", &f.After}} {
+		if v := c.QueryParam(p.name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return response.BadRequest(c, "invalid "+p.name, p.name+" must be the seq of an entry")
+			}
+			*p.to = n
+		}
+	}
+	all, _ := store.Capacity()
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > all {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(all))
+		}
+		f.Limit = n
+	}
+	list, more := store.List(f)
+	out := map[string]any{"logs": list, "count": len(list)}
+	if more {
+		out["next_cursor"] = list[0].Seq
+	}
+	return response.OK(c, out, "")
+}
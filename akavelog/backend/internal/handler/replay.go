@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/replay"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ReplayHandler handles /replay, background jobs that run archived entries through the
+// pipelines again. Manager is nil when O3 is not configured; every route then answers 503.
+type ReplayHandler struct {
+	Manager *replay.Manager
+}
+
+type replayRequest struct {
+	Query     string   `json:"query"`
+	ProjectID string   `json:"project_id"`
+	Start     string   `json:"start"`    // RFC 3339; default end - 24h
+	End       string   `json:"end"`      // RFC 3339; default now
+	InputID   string   `json:"input_id"` // run this input's pipelines; default the global ones
+	Outputs   []string `json:"outputs"`  // only these outputs; default every routed one
+	Archive   bool     `json:"archive"`
+}
+
+// CreateReplay starts a replay and answers 202 with its job (POST /replay).
+func (h *ReplayHandler) CreateReplay(c echo.Context) error {
+	if h.Manager == nil {
+		return replaysUnavailable(c)
+	}
+	var req replayRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	opts, msg, detail := scanOptions(req.Query, req.ProjectID, req.Start, req.End)
+	if msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	var inputID uuid.UUID
+	if s := strings.TrimSpace(req.InputID); s != "" {
+		var err error
+		if inputID, err = uuid.Parse(s); err != nil {
+			return response.BadRequest(c, "invalid input_id", "input_id must be a UUID")
+		}
+	}
+	job, err := h.Manager.Start(replay.Request{
+		Query:     opts.Query,
+		ProjectID: opts.ProjectID,
+		Start:     opts.Start,
+		End:       opts.End,
+		InputID:   inputID,
+		Outputs:   req.Outputs,
+		Archive:   req.Archive,
+	})
+	switch {
+	case errors.Is(err, replay.ErrTooManyJobs):
+		return response.Error(c, http.StatusTooManyRequests, "too many running replays", "wait for the running replay to finish or cancel it")
+	case errors.Is(err, replay.ErrNoTarget), errors.Is(err, replay.ErrNoArchive), errors.Is(err, replay.ErrUnknownOutput):
+		return response.BadRequest(c, "nothing to replay to", err.Error())
+	case err != nil:
+		return response.InternalError(c, "start replay failed", err.Error())
+	}
+	return response.Accepted(c, job.Snapshot(), "replay running; poll GET /replay/"+job.ID())
+}
+
+// ListReplays returns the replays still kept, newest first (GET /replay).
+func (h *ReplayHandler) ListReplays(c echo.Context) error {
+	if h.Manager == nil {
+		return replaysUnavailable(c)
+	}
+	jobs := h.Manager.List()
+	out := make([]replay.Snapshot, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, j.Snapshot())
+	}
+	return response.OK(c, map[string]any{"replays": out}, "")
+}
+
+// GetReplay returns a replay's status and progress (GET /replay/:id).
+func (h *ReplayHandler) GetReplay(c echo.Context) error {
+	if h.Manager == nil {
+		return replaysUnavailable(c)
+	}
+	job := h.Manager.Get(c.Param("id"))
+	if job == nil {
+		return response.NotFound(c, "replay not found", "no replay with this id; replays are kept for a limited time")
+	}
+	return response.OK(c, job.Snapshot(), "")
+}
+
+// DeleteReplay cancels a running replay or forgets a finished one (DELETE /replay/:id).
+// Entries already handed on stay delivered.
+func (h *ReplayHandler) DeleteReplay(c echo.Context) error {
+	if h.Manager == nil {
+		return replaysUnavailable(c)
+	}
+	if !h.Manager.Cancel(c.Param("id")) {
+		return response.NotFound(c, "replay not found", "no replay with this id; replays are kept for a limited time")
+	}
+	return response.OK(c, nil, "replay deleted")
+}
+
+func replaysUnavailable(c echo.Context) error {
+	return response.Error(c, http.StatusServiceUnavailable, "replay not available", "replay requires O3 storage")
+}
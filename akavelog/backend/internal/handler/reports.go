@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/report"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxReportName     = 200
+	maxReportOutputs  = 20
+	defaultReportRuns = 20
+)
+
+// ReportHandler handles /reports, saved aggregations run on a cron schedule whose results are
+// stored in O3. Scheduler is nil when O3 is not configured; reports can then be managed but
+// are not run.
+type ReportHandler struct {
+	Repo      *repository.ReportRepository
+	Searches  *repository.SavedSearchRepository
+	Outputs   *repository.OutputRepository
+	Scheduler *report.Scheduler
+}
+
+type reportRequest struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	SavedSearchID string   `json:"saved_search_id"` // a saved aggregation
+	Schedule      string   `json:"schedule"`        // cron expression, e.g. "0 8 * * mon"
+	Timezone      string   `json:"timezone"`        // IANA name the schedule is matched in (default UTC)
+	Formats       []string `json:"formats"`         // json, csv, html (default json)
+	Outputs       []string `json:"outputs"`         // names of outputs told about each run
+	Enabled       *bool    `json:"enabled"`         // default true
+}
+
+type reportLastRun struct {
+	At     time.Time `json:"at"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+type reportResponse struct {
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	Description   string               `json:"description,omitempty"`
+	SavedSearchID string               `json:"saved_search_id"`
+	Schedule      string               `json:"schedule"`
+	Timezone      string               `json:"timezone"`
+	Formats       []model.ReportFormat `json:"formats"`
+	Outputs       []string             `json:"outputs"`
+	Enabled       bool                 `json:"enabled"`
+	NextRunAt     *time.Time           `json:"next_run_at"`
+	LastRun       *reportLastRun       `json:"last_run"` // null until it has run
+	CreatedAt     string               `json:"created_at"`
+	UpdatedAt     string               `json:"updated_at"`
+}
+
+// reportListSpec filters GET /reports by saved_search_id and enabled; q searches names and descriptions.
+var reportListSpec = listSpec[reportResponse]{
+	text: func(r reportResponse) string { return r.Name + " " + r.Description },
+	filters: map[string]func(reportResponse, string) bool{
+		"saved_search_id": equalFold(func(r reportResponse) string { return r.SavedSearchID }),
+		"enabled":         boolFilter(func(r reportResponse) bool { return r.Enabled }),
+	},
+	sorts: namedSorts(func(r reportResponse) string { return r.Name }, func(r reportResponse) string { return r.CreatedAt }, func(r reportResponse) string { return r.UpdatedAt }),
+}
+
+func newReportResponse(r model.Report) reportResponse {
+	out := reportResponse{
+		ID:            r.ID.String(),
+		Name:          r.Name,
+		Description:   r.Description,
+		SavedSearchID: r.SavedSearchID.String(),
+		Schedule:      r.Schedule,
+		Timezone:      r.Timezone,
+		Formats:       r.Formats,
+		Outputs:       r.Outputs,
+		Enabled:       r.Enabled,
+		NextRunAt:     r.NextRunAt,
+		CreatedAt:     r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     r.UpdatedAt.Format(time.RFC3339),
+	}
+	if out.Outputs == nil {
+		out.Outputs = []string{}
+	}
+	if r.LastRunAt != nil {
+		out.LastRun = &reportLastRun{At: *r.LastRunAt, Status: r.LastStatus, Error: r.LastError}
+	}
+	return out
+}
+
+// ListReports returns all reports by name (GET /reports). Callers bound to a project get those
+// of that project.
+func (h *ReportHandler) ListReports(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list reports failed", "list reports: "+err.Error())
+	}
+	out := make([]reportResponse, 0, len(list))
+	for _, r := range list {
+		if visible(c.Request().Context(), r.ProjectID) {
+			out = append(out, newReportResponse(r))
+		}
+	}
+	return listResponse(c, "reports", out, reportListSpec)
+}
+
+// GetReport returns one report (GET /reports/:id).
+func (h *ReportHandler) GetReport(c echo.Context) error {
+	r, err := h.byID(c)
+	if r == nil {
+		return err
+	}
+	return response.OK(c, newReportResponse(*r), "")
+}
+
+// CreateReport validates and saves a report and schedules its first run (POST /reports).
+func (h *ReportHandler) CreateReport(c echo.Context) error {
+	var req reportRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	var r model.Report
+	if ok, err := h.apply(c, &r, req); !ok {
+		return err
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), r.Name)
+	if err != nil {
+		return response.InternalError(c, "create report failed", "get report: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "report name already in use", "a report named "+r.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &r); err != nil {
+		return response.InternalError(c, "create report failed", "create report: "+err.Error())
+	}
+	return response.Created(c, newReportResponse(r), "report created")
+}
+
+// UpdateReport replaces a report's definition and reschedules it (PUT /reports/:id). Its runs
+// are kept.
+func (h *ReportHandler) UpdateReport(c echo.Context) error {
+	r, err := h.byID(c)
+	if r == nil {
+		return err
+	}
+	var req reportRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := r.Name
+	if ok, err := h.apply(c, r, req); !ok {
+		return err
+	}
+	if r.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), r.Name)
+		if err != nil {
+			return response.InternalError(c, "update report failed", "get report: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "report name already in use", "a report named "+r.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), r); err != nil {
+		return response.InternalError(c, "update report failed", "update report: "+err.Error())
+	}
+	return response.OK(c, newReportResponse(*r), "report updated")
+}
+
+// DeleteReport removes a report and its runs (DELETE /reports/:id). Stored reports are kept
+// in O3.
+func (h *ReportHandler) DeleteReport(c echo.Context) error {
+	r, err := h.byID(c)
+	if r == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), r.ID); err != nil {
+		return response.InternalError(c, "delete report failed", "delete report: "+err.Error())
+	}
+	return response.OK(c, nil, "report deleted")
+}
+
+// RunReport runs a report now, in the background, over the range of its saved search up to
+// now (POST /reports/:id/run). Its schedule is not changed.
+func (h *ReportHandler) RunReport(c echo.Context) error {
+	if h.Scheduler == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "reports not available", "reports require O3 storage")
+	}
+	r, err := h.byID(c)
+	if r == nil {
+		return err
+	}
+	if !h.Scheduler.RunNow(*r) {
+		return response.Error(c, http.StatusServiceUnavailable, "reports not available", "the server is shutting down")
+	}
+	return response.Accepted(c, map[string]any{"id": r.ID.String()}, "report running; see GET /reports/"+r.ID.String()+"/runs")
+}
+
+// ListReportRuns returns the runs of a report, newest first, with the O3 key of each format
+// stored (GET /reports/:id/runs?limit=).
+func (h *ReportHandler) ListReportRuns(c echo.Context) error {
+	r, err := h.byID(c)
+	if r == nil {
+		return err
+	}
+	limit := defaultReportRuns
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > repository.MaxReportRuns {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(repository.MaxReportRuns))
+		}
+		limit = n
+	}
+	runs, err := h.Repo.ListRuns(c.Request().Context(), r.ID, limit)
+	if err != nil {
+		return response.InternalError(c, "list report runs failed", "list report runs: "+err.Error())
+	}
+	out := make([]map[string]any, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, map[string]any{
+			"id":          run.ID.String(),
+			"scheduled":   run.Scheduled,
+			"status":      run.Status,
+			"start":       run.Start,
+			"end":         run.End,
+			"total":       run.Total,
+			"objects":     run.Objects,
+			"duration_ms": run.DurationMS,
+			"error":       run.Error,
+			"created_at":  run.CreatedAt,
+		})
+	}
+	return response.OK(c, map[string]any{"runs": out}, "")
+}
+
+// byID loads the report named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *ReportHandler) byID(c echo.Context) (*model.Report, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	r, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get report failed", "get report: "+err.Error())
+	}
+	if r == nil || !visible(c.Request().Context(), r.ProjectID) {
+		return nil, response.NotFound(c, "report not found", "report not found")
+	}
+	return r, nil
+}
+
+// apply copies req onto r, validates it and computes its next run. When it reports false, the
+// error response has already been written and err is its result.
+func (h *ReportHandler) apply(c echo.Context, r *model.Report, req reportRequest) (bool, error) {
+	if msg, detail := applyReport(r, req); msg != "" {
+		return false, response.BadRequest(c, msg, detail)
+	}
+	msg, detail, err := h.checkReferences(c.Request().Context(), r, requestOwner(c))
+	if err != nil {
+		return false, response.InternalError(c, "save report failed", err.Error())
+	}
+	if msg != "" {
+		return false, response.BadRequest(c, msg, detail)
+	}
+	r.NextRunAt = nil
+	if r.Enabled {
+		sched, _ := report.ParseSchedule(r.Schedule, r.Timezone) // checked by applyReport
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return false, response.BadRequest(c, "invalid schedule", "the schedule does not fire within five years")
+		}
+		r.NextRunAt = &next
+	}
+	return true, nil
+}
+
+// applyReport copies req onto r and validates what needs no lookups. It returns a message and
+// detail for a 400 response, or "" when r is valid.
+func applyReport(r *model.Report, req reportRequest) (string, string) {
+	r.Name = strings.TrimSpace(req.Name)
+	if r.Name == "" || len(r.Name) > maxReportName {
+		return "invalid name", "name is required and at most " + strconv.Itoa(maxReportName) + " bytes"
+	}
+	r.Description = req.Description
+	id, err := uuid.Parse(strings.TrimSpace(req.SavedSearchID))
+	if err != nil {
+		return "invalid saved_search_id", "saved_search_id must be the id of a saved aggregation"
+	}
+	r.SavedSearchID = id
+	r.Schedule = strings.TrimSpace(req.Schedule)
+	r.Timezone = strings.TrimSpace(req.Timezone)
+	if r.Timezone == "" {
+		r.Timezone = "UTC"
+	}
+	if _, err := report.ParseSchedule(r.Schedule, r.Timezone); err != nil {
+		return "invalid schedule", err.Error()
+	}
+	r.Formats = nil
+	seen := make(map[model.ReportFormat]bool)
+	for _, f := range req.Formats {
+		format := model.ReportFormat(strings.ToLower(strings.TrimSpace(f)))
+		switch format {
+		case model.ReportJSON, model.ReportCSV, model.ReportHTML:
+		default:
+			return "invalid formats", "formats must be json, csv or html"
+		}
+		if !seen[format] {
+			seen[format] = true
+			r.Formats = append(r.Formats, format)
+		}
+	}
+	if len(r.Formats) == 0 {
+		r.Formats = []model.ReportFormat{model.ReportJSON}
+	}
+	if len(req.Outputs) > maxReportOutputs {
+		return "invalid outputs", "outputs takes at most " + strconv.Itoa(maxReportOutputs) + " names"
+	}
+	r.Outputs = nil
+	for _, name := range req.Outputs {
+		if name = strings.TrimSpace(name); name == "" {
+			return "invalid outputs", "output names must not be empty"
+		}
+		r.Outputs = append(r.Outputs, name)
+	}
+	r.Enabled = req.Enabled == nil || *req.Enabled
+	return "", ""
+}
+
+// checkReferences checks that r's saved search is an aggregation owner may see and that its
+// outputs exist, and gives r the project of the saved search. It returns a message and detail
+// for a 400 response, or "" when they do.
+func (h *ReportHandler) checkReferences(ctx context.Context, r *model.Report, owner string) (string, string, error) {
+	s, err := h.Searches.GetByID(ctx, r.SavedSearchID)
+	if err != nil {
+		return "", "", err
+	}
+	if s == nil || (s.Owner != owner && !s.Shared) || !visible(ctx, s.ProjectID) {
+		return "invalid saved_search_id", "saved search " + r.SavedSearchID.String() + " not found", nil
+	}
+	if s.Kind != model.QueryAggregate {
+		return "invalid saved_search_id", "saved search " + s.Name + " is a " + string(s.Kind) + "; reports run aggregations", nil
+	}
+	r.ProjectID = s.ProjectID
+	for _, name := range r.Outputs {
+		o, err := h.Outputs.GetByName(ctx, name)
+		if err != nil {
+			return "", "", err
+		}
+		if o == nil {
+			return "invalid outputs", "no output named " + name, nil
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/retention"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// retentionProject matches the project IDs the batcher stores under their own key segment.
+var retentionProject = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// RetentionHandler handles /retention. Policies can be managed without O3; Manager is nil
+// then, and the endpoints that look at objects answer 503.
+type RetentionHandler struct {
+	Repo        *repository.RetentionPolicyRepository
+	Streams     *repository.StreamRepository
+	Manager     *retention.Manager
+	DefaultDays atomic.Int64 // for logs/ objects no policy covers; changed by config reloads
+}
+
+type retentionPolicyResponse struct {
+	ID        string  `json:"id"`
+	ProjectID string  `json:"project_id"`
+	StreamID  *string `json:"stream_id"` // null for logs/
+	Days      int     `json:"days"`
+	Action    string  `json:"action"`
+	Enabled   bool    `json:"enabled"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type retentionPolicyRequest struct {
+	ProjectID string `json:"project_id"` // "" for every project
+	StreamID  string `json:"stream_id"`  // "" for logs/
+	Days      *int   `json:"days"`
+	Action    string `json:"action"`  // delete (default) or archive
+	Enabled   *bool  `json:"enabled"` // default true
+}
+
+func newRetentionPolicyResponse(p model.RetentionPolicy) retentionPolicyResponse {
+	out := retentionPolicyResponse{
+		ID:        p.ID.String(),
+		ProjectID: p.ProjectID,
+		Days:      p.Days,
+		Action:    string(p.Action),
+		Enabled:   p.Enabled,
+		CreatedAt: p.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: p.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if p.StreamID != nil {
+		id := p.StreamID.String()
+		out.StreamID = &id
+	}
+	return out
+}
+
+func (h *RetentionHandler) unavailable(c echo.Context) error {
+	return response.Error(c, http.StatusServiceUnavailable, "retention not enabled", "retention requires O3 storage")
+}
+
+// Rules loads the policies and streams and resolves them into retention rules. It is what the
+// Manager applies on every run.
+func (h *RetentionHandler) Rules(ctx context.Context) ([]retention.Rule, error) {
+	policies, err := h.Repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list policies: %w", err)
+	}
+	streams, err := h.Streams.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list streams: %w", err)
+	}
+	return retention.BuildRules(policies, streams, int(h.DefaultDays.Load())), nil
+}
+
+// GetRetention returns the policies, the rules they resolve to and the last run (GET /retention).
+// Callers bound to a project get the policies of that project and the rules that apply to it.
+func (h *RetentionHandler) GetRetention(c echo.Context) error {
+	ctx := c.Request().Context()
+	policies, err := h.Repo.List(ctx)
+	if err != nil {
+		return response.InternalError(c, "list retention policies failed", "list policies: "+err.Error())
+	}
+	out := make([]retentionPolicyResponse, 0, len(policies))
+	for _, p := range policies {
+		if visible(ctx, p.ProjectID) {
+			out = append(out, newRetentionPolicyResponse(p))
+		}
+	}
+	all, err := h.Rules(ctx)
+	if err != nil {
+		return response.InternalError(c, "resolve retention rules failed", err.Error())
+	}
+	rules := []retention.Rule{}
+	for _, r := range all {
+		if r.Project == "" || visible(ctx, r.Project) {
+			rules = append(rules, r)
+		}
+	}
+	status := map[string]any{"policies": out, "rules": rules, "enabled": h.Manager != nil}
+	if h.Manager != nil {
+		status["dry_run"] = h.Manager.DryRun()
+		status["last_run"] = h.Manager.LastRun()
+	}
+	return response.OK(c, status, "")
+}
+
+// CreatePolicy validates and persists a policy (POST /retention/policies). It applies from the
+// next run.
+func (h *RetentionHandler) CreatePolicy(c echo.Context) error {
+	var req retentionPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	p := model.RetentionPolicy{}
+	if msg, detail := h.applyPolicy(c.Request().Context(), &p, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if taken, err := h.scopeTaken(c, &p); taken {
+		return err
+	}
+	if err := h.Repo.Create(c.Request().Context(), &p); err != nil {
+		return response.InternalError(c, "create retention policy failed", "create policy: "+err.Error())
+	}
+	return response.Created(c, newRetentionPolicyResponse(p), "retention policy created")
+}
+
+// UpdatePolicy replaces a policy (PUT /retention/policies/:id).
+func (h *RetentionHandler) UpdatePolicy(c echo.Context) error {
+	p, err := h.byID(c)
+	if p == nil {
+		return err
+	}
+	var req retentionPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	if msg, detail := h.applyPolicy(c.Request().Context(), p, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if taken, err := h.scopeTaken(c, p); taken {
+		return err
+	}
+	if err := h.Repo.Update(c.Request().Context(), p); err != nil {
+		return response.InternalError(c, "update retention policy failed", "update policy: "+err.Error())
+	}
+	return response.OK(c, newRetentionPolicyResponse(*p), "retention policy updated")
+}
+
+// DeletePolicy removes a policy (DELETE /retention/policies/:id).
+func (h *RetentionHandler) DeletePolicy(c echo.Context) error {
+	p, err := h.byID(c)
+	if p == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), p.ID); err != nil {
+		return response.InternalError(c, "delete retention policy failed", "delete policy: "+err.Error())
+	}
+	return response.OK(c, nil, "retention policy deleted")
+}
+
+// Upcoming lists the objects that expire within the given period, default 7d, soonest first
+// (GET /retention/upcoming?within=). Callers bound to a project get the objects of that project.
+func (h *RetentionHandler) Upcoming(c echo.Context) error {
+	if h.Manager == nil {
+		return h.unavailable(c)
+	}
+	within := 7 * 24 * time.Hour
+	if v := c.QueryParam("within"); v != "" {
+		d, err := parseDays(v)
+		if err != nil || d < 0 {
+			return response.BadRequest(c, "invalid within", "within must be a duration such as 7d or 12h")
+		}
+		within = d
+	}
+	until := time.Now().UTC().Add(within)
+	all, err := h.Manager.Upcoming(c.Request().Context(), until)
+	if err != nil {
+		return response.InternalError(c, "list upcoming deletions failed", err.Error())
+	}
+	actions := []retention.Action{}
+	var size int64
+	for _, a := range all {
+		if keyVisible(c.Request().Context(), a.Key) {
+			actions = append(actions, a)
+			size += a.Size
+		}
+	}
+	return response.OK(c, map[string]any{"until": until, "objects": actions, "count": len(actions), "bytes": size}, "")
+}
+
+// Run applies the rules now and returns the run's stats (POST /retention/run). With
+// ?dry_run=true, or when the server runs in dry-run mode, nothing is deleted.
+func (h *RetentionHandler) Run(c echo.Context) error {
+	if h.Manager == nil {
+		return h.unavailable(c)
+	}
+	dryRun := h.Manager.DryRun()
+	if v := c.QueryParam("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return response.BadRequest(c, "invalid dry_run", "dry_run must be true or false")
+		}
+		dryRun = dryRun || b
+	}
+	st, err := h.Manager.Run(c.Request().Context(), dryRun)
+	if err != nil {
+		return response.InternalError(c, "retention run failed", err.Error())
+	}
+	return response.OK(c, st, "retention run finished")
+}
+
+// byID loads the policy named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *RetentionHandler) byID(c echo.Context) (*model.RetentionPolicy, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	p, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get retention policy failed", "get policy: "+err.Error())
+	}
+	if p == nil || !visible(c.Request().Context(), p.ProjectID) {
+		return nil, response.NotFound(c, "retention policy not found", "retention policy not found")
+	}
+	return p, nil
+}
+
+// scopeTaken reports whether another policy has p's project and stream; the 409 response (or
+// an error response) has then been written and err is its result.
+func (h *RetentionHandler) scopeTaken(c echo.Context, p *model.RetentionPolicy) (bool, error) {
+	existing, err := h.Repo.GetByScope(c.Request().Context(), p.ProjectID, p.StreamID)
+	if err != nil {
+		return true, response.InternalError(c, "save retention policy failed", "get policy: "+err.Error())
+	}
+	if existing != nil && existing.ID != p.ID {
+		return true, response.Error(c, http.StatusConflict, "retention policy already exists",
+			"policy "+existing.ID.String()+" already covers this project and stream")
+	}
+	return false, nil
+}
+
+// applyPolicy copies req onto p and validates it. It returns a message and detail for a 400
+// response, or "" when p is valid.
+func (h *RetentionHandler) applyPolicy(ctx context.Context, p *model.RetentionPolicy, req retentionPolicyRequest) (string, string) {
+	p.ProjectID = strings.TrimSpace(req.ProjectID)
+	if p.ProjectID != "" && !retentionProject.MatchString(p.ProjectID) {
+		return "invalid project_id", "project_id must be 1-64 letters, digits, '.', '_' or '-'"
+	}
+	p.StreamID = nil
+	if v := strings.TrimSpace(req.StreamID); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return "invalid stream_id", "stream_id must be a UUID"
+		}
+		s, err := h.Streams.GetByID(ctx, id)
+		if err != nil {
+			return "invalid stream_id", "get stream: " + err.Error()
+		}
+		if s == nil {
+			return "invalid stream_id", "stream not found"
+		}
+		if s.O3Prefix == "" {
+			return "invalid stream_id", "stream " + s.Name + " has no o3_prefix; its entries are stored under logs/ with the others"
+		}
+		p.StreamID = &id
+	}
+	if req.Days == nil || *req.Days < 0 {
+		return "invalid days", "days is required and must not be negative (0 keeps objects forever)"
+	}
+	p.Days = *req.Days
+	p.Action = model.RetentionAction(strings.ToLower(strings.TrimSpace(req.Action)))
+	switch p.Action {
+	case "":
+		p.Action = model.RetentionDelete
+	case model.RetentionDelete, model.RetentionArchive:
+	default:
+		return "invalid action", "action must be delete or archive"
+	}
+	p.Enabled = req.Enabled == nil || *req.Enabled
+	return "", ""
+}
+
+// parseDays parses a Go duration, also accepting whole days such as 30d.
+func parseDays(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/logsql"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxSavedSearchName  = 200
+	defaultSavedRange   = "24h"
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 1000
+)
+
+// SavedSearchHandler handles /saved-searches, named searches, aggregations and SQL statements
+// that are run again with POST /saved-searches/:id/run, and /query/history, the runs recorded
+// by Query and SQL.
+type SavedSearchHandler struct {
+	Repo  *repository.SavedSearchRepository
+	Query *QueryHandler
+	SQL   *SQLHandler
+}
+
+type savedSearchLastRun struct {
+	At         time.Time `json:"at"`
+	DurationMS int64     `json:"duration_ms"`
+	Results    int       `json:"results"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type savedSearchResponse struct {
+	ID          string                  `json:"id"`
+	Owner       string                  `json:"owner,omitempty"`
+	Shared      bool                    `json:"shared"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	Kind        string                  `json:"kind"`
+	Query       string                  `json:"query"`
+	ProjectID   string                  `json:"project_id,omitempty"`
+	Range       string                  `json:"range"`
+	Params      model.SavedSearchParams `json:"params"`
+	RunCount    int64                   `json:"run_count"`
+	LastRun     *savedSearchLastRun     `json:"last_run"` // null until it has run
+	CreatedAt   string                  `json:"created_at"`
+	UpdatedAt   string                  `json:"updated_at"`
+}
+
+// savedSearchListSpec filters GET /saved-searches by kind, project_id and shared; q searches names and descriptions.
+var savedSearchListSpec = listSpec[savedSearchResponse]{
+	text: func(s savedSearchResponse) string { return s.Name + " " + s.Description },
+	filters: map[string]func(savedSearchResponse, string) bool{
+		"kind":       equalFold(func(s savedSearchResponse) string { return s.Kind }),
+		"project_id": equalFold(func(s savedSearchResponse) string { return s.ProjectID }),
+		"shared":     boolFilter(func(s savedSearchResponse) bool { return s.Shared }),
+	},
+	sorts: namedSorts(func(s savedSearchResponse) string { return s.Name }, func(s savedSearchResponse) string { return s.CreatedAt }, func(s savedSearchResponse) string { return s.UpdatedAt }),
+}
+
+type savedSearchRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Shared      *bool                   `json:"shared"` // default true
+	Kind        string                  `json:"kind"`   // search (default), aggregate or sql
+	Query       string                  `json:"query"`
+	ProjectID   string                  `json:"project_id"`
+	Range       string                  `json:"range"` // how far back a run reads, e.g. 1h or 7d (default 24h)
+	Params      model.SavedSearchParams `json:"params"`
+}
+
+type savedSearchRunRequest struct {
+	Start string `json:"start"` // RFC 3339; default end - range
+	End   string `json:"end"`   // RFC 3339; default now
+	Async bool   `json:"async"` // for sql, as for POST /logs/sql
+}
+
+func newSavedSearchResponse(s model.SavedSearch) savedSearchResponse {
+	out := savedSearchResponse{
+		ID:          s.ID.String(),
+		Owner:       s.Owner,
+		Shared:      s.Shared,
+		Name:        s.Name,
+		Description: s.Description,
+		Kind:        string(s.Kind),
+		Query:       s.Query,
+		ProjectID:   s.ProjectID,
+		Range:       s.TimeRange,
+		Params:      s.Params,
+		RunCount:    s.RunCount,
+		CreatedAt:   s.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   s.UpdatedAt.Format(time.RFC3339),
+	}
+	if s.LastRunAt != nil {
+		out.LastRun = &savedSearchLastRun{At: *s.LastRunAt, DurationMS: s.LastRunMS, Results: s.LastResults, Error: s.LastError}
+	}
+	return out
+}
+
+// ListSavedSearches returns the caller's saved searches and the shared ones, by name
+// (GET /saved-searches?kind=). Callers bound to a project get those of that project.
+func (h *SavedSearchHandler) ListSavedSearches(c echo.Context) error {
+	kind := model.QueryKind(strings.TrimSpace(c.QueryParam("kind")))
+	if kind != "" && !validQueryKind(kind) {
+		return response.BadRequest(c, "invalid kind", "kind must be search, aggregate or sql")
+	}
+	list, err := h.Repo.List(c.Request().Context(), requestOwner(c), kind)
+	if err != nil {
+		return response.InternalError(c, "list saved searches failed", "list saved searches: "+err.Error())
+	}
+	out := make([]savedSearchResponse, 0, len(list))
+	for _, s := range list {
+		if visible(c.Request().Context(), s.ProjectID) {
+			out = append(out, newSavedSearchResponse(s))
+		}
+	}
+	return listResponse(c, "saved_searches", out, savedSearchListSpec)
+}
+
+// GetSavedSearch returns one saved search (GET /saved-searches/:id).
+func (h *SavedSearchHandler) GetSavedSearch(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	return response.OK(c, newSavedSearchResponse(*s), "")
+}
+
+// CreateSavedSearch validates and saves a search (POST /saved-searches).
+func (h *SavedSearchHandler) CreateSavedSearch(c echo.Context) error {
+	var req savedSearchRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	s := model.SavedSearch{Owner: requestOwner(c)}
+	if msg, detail := applySavedSearch(&s, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), s.Owner, s.Name)
+	if err != nil {
+		return response.InternalError(c, "create saved search failed", "get saved search: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "saved search name already in use", "a saved search named "+s.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &s); err != nil {
+		return response.InternalError(c, "create saved search failed", "create saved search: "+err.Error())
+	}
+	return response.Created(c, newSavedSearchResponse(s), "saved search created")
+}
+
+// UpdateSavedSearch replaces a saved search's definition (PUT /saved-searches/:id). Its run
+// count and last run are kept.
+func (h *SavedSearchHandler) UpdateSavedSearch(c echo.Context) error {
+	s, err := h.ownedByID(c)
+	if s == nil {
+		return err
+	}
+	var req savedSearchRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := s.Name
+	if msg, detail := applySavedSearch(s, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if s.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), s.Owner, s.Name)
+		if err != nil {
+			return response.InternalError(c, "update saved search failed", "get saved search: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "saved search name already in use", "a saved search named "+s.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), s); err != nil {
+		return response.InternalError(c, "update saved search failed", "update saved search: "+err.Error())
+	}
+	return response.OK(c, newSavedSearchResponse(*s), "saved search updated")
+}
+
+// DeleteSavedSearch removes a saved search (DELETE /saved-searches/:id). Its runs stay in the
+// history.
+func (h *SavedSearchHandler) DeleteSavedSearch(c echo.Context) error {
+	s, err := h.ownedByID(c)
+	if s == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), s.ID); err != nil {
+		return response.InternalError(c, "delete saved search failed", "delete saved search: "+err.Error())
+	}
+	return response.OK(c, nil, "saved search deleted")
+}
+
+// RunSavedSearch runs a saved search over its range up to now, or the start and end given,
+// and answers as POST /query, /logs/aggregate or /logs/sql would
+// (POST /saved-searches/:id/run). The run updates the search's last run.
+func (h *SavedSearchHandler) RunSavedSearch(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	var req savedSearchRunRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	end, err := parseTime(req.End)
+	if err != nil {
+		return response.BadRequest(c, "invalid end", "end must be an RFC 3339 time")
+	}
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	start, err := parseTime(req.Start)
+	if err != nil {
+		return response.BadRequest(c, "invalid start", "start must be an RFC 3339 time")
+	}
+	if start.IsZero() {
+		d, err := parseDays(s.TimeRange)
+		if err != nil || d <= 0 {
+			d = defaultQueryRange
+		}
+		start = end.Add(-d)
+	}
+	from, to := start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano)
+	id := s.ID
+	switch s.Kind {
+	case model.QueryAggregate:
+		return h.Query.aggregate(c, aggregateRequest{
+			Query:       s.Query,
+			ProjectID:   s.ProjectID,
+			Start:       from,
+			End:         to,
+			Interval:    s.Params.Interval,
+			GroupBy:     s.Params.GroupBy,
+			Top:         s.Params.Top,
+			TopN:        s.Params.TopN,
+			savedSearch: &id,
+		})
+	case model.QuerySQL:
+		return h.SQL.run(c, sqlRequest{SQL: s.Query, ProjectID: s.ProjectID, Start: from, End: to, Async: req.Async, savedSearch: &id})
+	}
+	return h.Query.search(c, queryRequest{Query: s.Query, ProjectID: s.ProjectID, Start: from, End: to, Limit: s.Params.Limit, savedSearch: &id})
+}
+
+// SavedSearchHistory returns the runs of a saved search, newest first
+// (GET /saved-searches/:id/history?limit=).
+func (h *SavedSearchHandler) SavedSearchHistory(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	return h.history(c, repository.RunFilter{SavedSearchID: &s.ID})
+}
+
+// QueryHistory returns the caller's recent runs of searches, aggregations and SQL
+// statements, newest first (GET /query/history?kind=&limit=).
+func (h *SavedSearchHandler) QueryHistory(c echo.Context) error {
+	kind := model.QueryKind(strings.TrimSpace(c.QueryParam("kind")))
+	if kind != "" && !validQueryKind(kind) {
+		return response.BadRequest(c, "invalid kind", "kind must be search, aggregate or sql")
+	}
+	return h.history(c, repository.RunFilter{Owner: requestOwner(c), Kind: kind})
+}
+
+func (h *SavedSearchHandler) history(c echo.Context, f repository.RunFilter) error {
+	f.Limit = defaultHistoryLimit
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHistoryLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+		}
+		f.Limit = n
+	}
+	runs, err := h.Repo.ListRuns(c.Request().Context(), f)
+	if err != nil {
+		return response.InternalError(c, "list query history failed", "list query history: "+err.Error())
+	}
+	out := make([]map[string]any, 0, len(runs))
+	for _, r := range runs {
+		run := map[string]any{
+			"id":          r.ID.String(),
+			"kind":        r.Kind,
+			"query":       r.Query,
+			"project_id":  r.ProjectID,
+			"duration_ms": r.DurationMS,
+			"results":     r.Results,
+			"error":       r.Error,
+			"created_at":  r.CreatedAt,
+		}
+		if r.SavedSearchID != nil {
+			run["saved_search_id"] = r.SavedSearchID.String()
+		}
+		if !r.Start.IsZero() {
+			run["start"], run["end"] = r.Start, r.End
+		}
+		out = append(out, run)
+	}
+	return response.OK(c, map[string]any{"runs": out}, "")
+}
+
+// byID loads the saved search named by the :id path parameter, if the caller may see it.
+// When it returns nil, the error response has already been written and err is its result.
+func (h *SavedSearchHandler) byID(c echo.Context) (*model.SavedSearch, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	s, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get saved search failed", "get saved search: "+err.Error())
+	}
+	if s == nil || (s.Owner != requestOwner(c) && !s.Shared) || !visible(c.Request().Context(), s.ProjectID) {
+		return nil, response.NotFound(c, "saved search not found", "saved search not found")
+	}
+	return s, nil
+}
+
+// ownedByID is byID for changes, which only the owner may make.
+func (h *SavedSearchHandler) ownedByID(c echo.Context) (*model.SavedSearch, error) {
+	s, err := h.byID(c)
+	if s == nil {
+		return nil, err
+	}
+	if s.Owner != requestOwner(c) {
+		return nil, response.Error(c, http.StatusForbidden, "not the owner", "only the owner of a saved search can change it")
+	}
+	return s, nil
+}
+
+func validQueryKind(k model.QueryKind) bool {
+	return k == model.QuerySearch || k == model.QueryAggregate || k == model.QuerySQL
+}
+
+// applySavedSearch copies req onto s and validates it. Params that do not apply to the kind
+// are dropped. It returns a message and detail for a 400 response, or "" when s is valid.
+func applySavedSearch(s *model.SavedSearch, req savedSearchRequest) (string, string) {
+	s.Name = strings.TrimSpace(req.Name)
+	if s.Name == "" || len(s.Name) > maxSavedSearchName {
+		return "invalid name", "name is required and at most " + strconv.Itoa(maxSavedSearchName) + " bytes"
+	}
+	s.Description = req.Description
+	s.Shared = req.Shared == nil || *req.Shared
+	s.Kind = model.QueryKind(strings.ToLower(strings.TrimSpace(req.Kind)))
+	if s.Kind == "" {
+		s.Kind = model.QuerySearch
+	}
+	if !validQueryKind(s.Kind) {
+		return "invalid kind", "kind must be search, aggregate or sql"
+	}
+	s.Query = strings.TrimSpace(req.Query)
+	s.ProjectID = strings.TrimSpace(req.ProjectID)
+	s.TimeRange = strings.TrimSpace(req.Range)
+	if s.TimeRange == "" {
+		s.TimeRange = defaultSavedRange
+	}
+	d, err := parseDays(s.TimeRange)
+	if err != nil || d <= 0 {
+		return "invalid range", "range must be a duration such as 1h or 7d"
+	}
+	p := req.Params
+	s.Params = model.SavedSearchParams{}
+	switch s.Kind {
+	case model.QuerySQL:
+		if _, err := logsql.Parse(s.Query); err != nil {
+			return "invalid sql", err.Error()
+		}
+		return "", ""
+	case model.QueryAggregate:
+		end := time.Now().UTC()
+		if _, msg, detail := newAggregator(aggregateRequest{Interval: p.Interval, GroupBy: p.GroupBy, Top: p.Top, TopN: p.TopN}, end.Add(-d), end); msg != "" {
+			return msg, detail
+		}
+		groupBy, _ := aggregateFields(p.GroupBy)
+		top, _ := aggregateFields(p.Top)
+		s.Params = model.SavedSearchParams{Interval: strings.TrimSpace(p.Interval), GroupBy: groupBy, Top: top, TopN: p.TopN}
+	default:
+		if p.Limit < 0 || p.Limit > maxQueryLimit {
+			return "invalid limit", "limit must be between 1 and " + strconv.Itoa(maxQueryLimit)
+		}
+		s.Params.Limit = p.Limit
+	}
+	if _, err := query.Parse(s.Query); err != nil {
+		return "invalid query", err.Error()
+	}
+	return "", ""
+}
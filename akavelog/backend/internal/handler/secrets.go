@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+// warnPlaintextSecrets logs the secret keys of cfg, the config of the named input, output or
+// channel, that hold plaintext rather than an env://, file:// or vault:// reference.
+func warnPlaintextSecrets(component, name string, cfg map[string]any) {
+	if keys := secrets.Plaintext(cfg); len(keys) > 0 {
+		log.Printf("[%s] %q stores plaintext secrets in %v; use env://, file:// or vault:// references", component, name, keys)
+	}
+}
+
+// warnPlaintextSecretsJSON is warnPlaintextSecrets for a JSON config.
+func warnPlaintextSecretsJSON(component, name string, raw json.RawMessage) {
+	var cfg map[string]any
+	if json.Unmarshal(raw, &cfg) == nil {
+		warnPlaintextSecrets(component, name, cfg)
+	}
+}
+
+// refusedRefs returns the keys of cfg, sorted, holding references that prev (the config cfg
+// replaces, or nil) does not hold under the same key, when the caller of ctx lacks the admin
+// scope. References read the server's environment, files and Vault, so only admins may add
+// them; others may keep the ones already stored.
+func refusedRefs(ctx context.Context, cfg, prev map[string]any) []string {
+	if p := middleware.PrincipalFromContext(ctx); p == nil || p.Has(middleware.ScopeAdmin) {
+		return nil
+	}
+	old := secrets.Refs(prev)
+	var keys []string
+	for k, ref := range secrets.Refs(cfg) {
+		if old[k] != ref {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// refusedRefsJSON is refusedRefs for JSON configs.
+func refusedRefsJSON(ctx context.Context, cfg, prev json.RawMessage) []string {
+	var next, old map[string]any
+	_ = json.Unmarshal(cfg, &next)
+	_ = json.Unmarshal(prev, &old)
+	return refusedRefs(ctx, next, old)
+}
+
+// refsDetail is the detail of the 403 response refusing the references under keys.
+func refsDetail(keys []string) string {
+	return "only the admin scope may store env://, file:// or vault:// references (in " + strings.Join(keys, ", ") + ")"
+}
+
+// redactProcessors returns procs with the secrets of their configs masked.
+func redactProcessors(procs []model.ProcessorConfig) []model.ProcessorConfig {
+	if procs == nil {
+		return nil
+	}
+	out := make([]model.ProcessorConfig, len(procs))
+	for i, pc := range procs {
+		pc.Config = secrets.Redact(pc.Config)
+		out[i] = pc
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/logsql"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults of SQLHandler.
+const (
+	DefaultSQLMaxScanBytes = 1 << 30
+	DefaultSQLSyncWait     = 10 * time.Second
+)
+
+// SQLHandler handles /logs/sql, SQL statements over the entries uploaded to O3. Every
+// statement runs as a job of Jobs; a statement still running after SyncWait is answered with
+// its job ID, to be polled with GET /logs/sql/:id. Store is nil when O3 is not configured;
+// POST /logs/sql then answers 503. Finished statements are recorded in History, when set.
+type SQLHandler struct {
+	Index        search.Index
+	Store        search.Store
+	Jobs         *logsql.Jobs
+	History      QueryHistory
+	MaxScanBytes int64         // default DefaultSQLMaxScanBytes
+	MaxRows      int           // default logsql.DefaultMaxRows
+	SyncWait     time.Duration // default DefaultSQLSyncWait
+}
+
+type sqlRequest struct {
+	SQL       string `json:"sql"`
+	ProjectID string `json:"project_id"`
+	Start     string `json:"start"` // RFC 3339; narrowed by timestamp conditions in WHERE
+	End       string `json:"end"`   // RFC 3339
+	Async     bool   `json:"async"` // answer with the job ID right away
+
+	savedSearch *uuid.UUID // the saved search being run, if any
+}
+
+// Run parses and starts a statement (POST /logs/sql). It answers with the result when the
+// statement finishes within SyncWait, else with 202 and the job.
+func (h *SQLHandler) Run(c echo.Context) error {
+	var req sqlRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	return h.run(c, req)
+}
+
+func (h *SQLHandler) run(c echo.Context, req sqlRequest) error {
+	if h.Store == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "sql not available", "sql requires O3 storage")
+	}
+	stmt, err := logsql.Parse(req.SQL)
+	if err != nil {
+		return response.BadRequest(c, "invalid sql", err.Error())
+	}
+	opts := logsql.Options{ProjectID: strings.TrimSpace(req.ProjectID), MaxBytes: h.MaxScanBytes, MaxRows: h.MaxRows}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultSQLMaxScanBytes
+	}
+	if opts.Start, err = parseTime(req.Start); err != nil {
+		return response.BadRequest(c, "invalid start", "start must be an RFC 3339 time")
+	}
+	if opts.End, err = parseTime(req.End); err != nil {
+		return response.BadRequest(c, "invalid end", "end must be an RFC 3339 time")
+	}
+	owner := requestOwner(c)
+	job, err := h.Jobs.Start(stmt, func(ctx context.Context, progress func(search.ScanStats)) (*logsql.Result, error) {
+		opts.Progress = progress
+		began := time.Now()
+		res, err := stmt.Run(ctx, h.Index, h.Store, opts)
+		run := &model.QueryRun{
+			SavedSearchID: req.savedSearch,
+			Owner:         owner,
+			Kind:          model.QuerySQL,
+			Query:         stmt.String(),
+			ProjectID:     opts.ProjectID,
+			Start:         opts.Start,
+			End:           opts.End,
+			DurationMS:    time.Since(began).Milliseconds(),
+		}
+		if res != nil {
+			run.Start, run.End, run.Results = res.Start, res.End, len(res.Rows)
+		}
+		recordRun(h.History, run, err)
+		return res, err
+	})
+	if errors.Is(err, logsql.ErrTooManyJobs) {
+		return response.Error(c, http.StatusTooManyRequests, "too many running queries", "wait for a running query to finish or cancel one")
+	}
+	if err != nil {
+		return response.InternalError(c, "start query failed", err.Error())
+	}
+	if !req.Async {
+		wait := h.SyncWait
+		if wait <= 0 {
+			wait = DefaultSQLSyncWait
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-job.Done():
+			// The caller has the result; the job need not be kept.
+			h.Jobs.Cancel(job.ID())
+			return h.finished(c, job)
+		case <-timer.C:
+		case <-c.Request().Context().Done():
+			h.Jobs.Cancel(job.ID())
+			return c.Request().Context().Err()
+		}
+	}
+	return response.Accepted(c, job.Snapshot(), "query running; poll GET /logs/sql/"+job.ID())
+}
+
+// finished answers with the result of a finished job, or why it failed.
+func (h *SQLHandler) finished(c echo.Context, job *logsql.Job) error {
+	snap := job.Snapshot()
+	switch err := job.Err(); {
+	case err == nil:
+		return response.OK(c, snap, "")
+	case errors.Is(err, search.ErrScanLimit), errors.Is(err, logsql.ErrTooManyRows):
+		return response.BadRequest(c, "query too large", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return response.Error(c, http.StatusGatewayTimeout, "query timed out", err.Error())
+	default:
+		return response.InternalError(c, "query failed", err.Error())
+	}
+}
+
+// GetJob returns the state of a statement and, once it is done, its result
+// (GET /logs/sql/:id).
+func (h *SQLHandler) GetJob(c echo.Context) error {
+	job := h.Jobs.Get(c.Param("id"))
+	if job == nil {
+		return response.NotFound(c, "query not found", "no query with this id; results are kept for a limited time")
+	}
+	return response.OK(c, job.Snapshot(), "")
+}
+
+// CancelJob cancels a running statement or discards a result (DELETE /logs/sql/:id).
+func (h *SQLHandler) CancelJob(c echo.Context) error {
+	if !h.Jobs.Cancel(c.Param("id")) {
+		return response.NotFound(c, "query not found", "no query with this id; results are kept for a limited time")
+	}
+	return response.OK(c, nil, "query canceled")
+}
+
+// parseTime parses an optional RFC 3339 time; "" is the zero time.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
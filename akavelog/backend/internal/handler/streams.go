@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/deadletter"
+	"github.com/akave-ai/akavelog/internal/export"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/report"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/retention"
+	"github.com/akave-ai/akavelog/internal/streams"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	streamName     = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	streamO3Prefix = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*$`)
+)
+
+// StreamHandler handles /streams. Like PipelineHandler, every change is persisted first and
+// then the whole set is reloaded into the Router.
+type StreamHandler struct {
+	Repo    *repository.StreamRepository
+	Router  *streams.Router
+	Targets []string // storage targets an o3_target may name (storage.targets)
+}
+
+type streamResponse struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	Enabled       bool     `json:"enabled"`
+	MatchType     string   `json:"match_type"`
+	Rules         []string `json:"rules"`
+	RetentionDays int      `json:"retention_days"`
+	O3Prefix      string   `json:"o3_prefix,omitempty"`
+	O3Target      string   `json:"o3_target,omitempty"`
+	Outputs       []string `json:"outputs"`
+	Matched       *int64   `json:"matched"` // null when the stream is not loaded
+	CreatedAt     string   `json:"created_at"`
+	UpdatedAt     string   `json:"updated_at"`
+}
+
+// streamListSpec filters GET /streams by enabled; q searches names and descriptions.
+var streamListSpec = listSpec[streamResponse]{
+	text: func(s streamResponse) string { return s.Name + " " + s.Description },
+	filters: map[string]func(streamResponse, string) bool{
+		"enabled": boolFilter(func(s streamResponse) bool { return s.Enabled }),
+	},
+	sorts: namedSorts(func(s streamResponse) string { return s.Name }, func(s streamResponse) string { return s.CreatedAt }, func(s streamResponse) string { return s.UpdatedAt }),
+}
+
+type streamRequest struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Enabled       *bool    `json:"enabled"`    // default true
+	MatchType     string   `json:"match_type"` // all (default) or any
+	Rules         []string `json:"rules"`
+	RetentionDays int      `json:"retention_days"`
+	O3Prefix      string   `json:"o3_prefix"`
+	O3Target      string   `json:"o3_target"`
+	Outputs       []string `json:"outputs"`
+}
+
+func (h *StreamHandler) newResponse(s model.Stream) streamResponse {
+	out := streamResponse{
+		ID:            s.ID.String(),
+		Name:          s.Name,
+		Description:   s.Description,
+		Enabled:       s.Enabled,
+		MatchType:     string(s.MatchType),
+		Rules:         s.Rules,
+		RetentionDays: s.RetentionDays,
+		O3Prefix:      s.O3Prefix,
+		O3Target:      s.O3Target,
+		Outputs:       s.Outputs,
+		CreatedAt:     s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:     s.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if out.Rules == nil {
+		out.Rules = []string{}
+	}
+	if out.Outputs == nil {
+		out.Outputs = []string{}
+	}
+	if n, ok := h.Router.Matched(s.ID); ok {
+		out.Matched = &n
+	}
+	return out
+}
+
+// ListStreams returns all streams in matching order (GET /streams).
+func (h *StreamHandler) ListStreams(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list streams failed", "list streams: "+err.Error())
+	}
+	out := make([]streamResponse, 0, len(list))
+	for _, s := range list {
+		out = append(out, h.newResponse(s))
+	}
+	return listResponse(c, "streams", out, streamListSpec)
+}
+
+// GetStream returns one stream (GET /streams/:id).
+func (h *StreamHandler) GetStream(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	return response.OK(c, h.newResponse(*s), "")
+}
+
+// CreateStream validates, persists and loads a stream (POST /streams).
+func (h *StreamHandler) CreateStream(c echo.Context) error {
+	var req streamRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	s := model.Stream{}
+	if msg, detail := applyStream(&s, req, h.Targets); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByName(c.Request().Context(), s.Name)
+	if err != nil {
+		return response.InternalError(c, "create stream failed", "get stream: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, 409, "stream name already in use", "a stream named "+s.Name+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &s); err != nil {
+		return response.InternalError(c, "create stream failed", "create stream: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.Created(c, h.newResponse(s), "stream created")
+}
+
+// UpdateStream replaces a stream's definition (PUT /streams/:id).
+func (h *StreamHandler) UpdateStream(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	var req streamRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	oldName := s.Name
+	if msg, detail := applyStream(s, req, h.Targets); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if s.Name != oldName {
+		existing, err := h.Repo.GetByName(c.Request().Context(), s.Name)
+		if err != nil {
+			return response.InternalError(c, "update stream failed", "get stream: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, 409, "stream name already in use", "a stream named "+s.Name+" already exists")
+		}
+	}
+	if err := h.Repo.Update(c.Request().Context(), s); err != nil {
+		return response.InternalError(c, "update stream failed", "update stream: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, h.newResponse(*s), "stream updated")
+}
+
+// DeleteStream removes a stream (DELETE /streams/:id).
+func (h *StreamHandler) DeleteStream(c echo.Context) error {
+	s, err := h.byID(c)
+	if s == nil {
+		return err
+	}
+	if err := h.Repo.Delete(c.Request().Context(), s.ID); err != nil {
+		return response.InternalError(c, "delete stream failed", "delete stream: "+err.Error())
+	}
+	h.Reload(c.Request().Context())
+	return response.OK(c, nil, "stream deleted")
+}
+
+// Reload loads every persisted stream into the Router. Streams whose rules no longer parse
+// are skipped and logged.
+func (h *StreamHandler) Reload(ctx context.Context) {
+	list, err := h.Repo.List(ctx)
+	if err != nil {
+		log.Printf("[streams] reload list: %v", err)
+		return
+	}
+	if err := h.Router.Load(list); err != nil {
+		log.Printf("[streams] reload: %v", err)
+	}
+}
+
+// Ensure returns the stream named like s, creating s and reloading the Router when there is
+// none. Streams the server relies on, such as that of its own logs, are set up this way.
+func (h *StreamHandler) Ensure(ctx context.Context, s model.Stream) (*model.Stream, error) {
+	existing, err := h.Repo.GetByName(ctx, s.Name)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	if err := h.Repo.Create(ctx, &s); err != nil {
+		return nil, err
+	}
+	h.Reload(ctx)
+	return &s, nil
+}
+
+// byID loads the stream named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *StreamHandler) byID(c echo.Context) (*model.Stream, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	s, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get stream failed", "get stream: "+err.Error())
+	}
+	if s == nil {
+		return nil, response.NotFound(c, "stream not found", "stream not found")
+	}
+	return s, nil
+}
+
+// applyStream copies req onto s and validates it; its o3_target must be one of targets. It
+// returns a message and detail for a 400 response, or "" when s is valid.
+func applyStream(s *model.Stream, req streamRequest, targets []string) (string, string) {
+	s.Name = strings.TrimSpace(req.Name)
+	if !streamName.MatchString(s.Name) {
+		return "invalid name", "name is required and may contain only letters, digits, '_', '.' and '-'"
+	}
+	s.Description = req.Description
+	s.Enabled = req.Enabled == nil || *req.Enabled
+	s.MatchType = model.StreamMatchType(strings.ToLower(strings.TrimSpace(req.MatchType)))
+	switch s.MatchType {
+	case "":
+		s.MatchType = model.StreamMatchAll
+	case model.StreamMatchAll, model.StreamMatchAny:
+	default:
+		return "invalid match_type", "match_type must be all or any"
+	}
+	s.Rules = nil
+	for _, r := range req.Rules {
+		if r = strings.TrimSpace(r); r != "" {
+			s.Rules = append(s.Rules, r)
+		}
+	}
+	if req.RetentionDays < 0 {
+		return "invalid retention_days", "retention_days must not be negative"
+	}
+	s.RetentionDays = req.RetentionDays
+	s.O3Prefix = strings.Trim(strings.TrimSpace(req.O3Prefix), "/")
+	if s.O3Prefix != "" && (!streamO3Prefix.MatchString(s.O3Prefix) || path.Clean(s.O3Prefix) != s.O3Prefix || strings.HasPrefix(s.O3Prefix, "..")) {
+		return "invalid o3_prefix", "o3_prefix must be a relative key prefix of letters, digits, '_', '.', '-' and '/'"
+	}
+	if s.O3Prefix == deadletter.Prefix || strings.HasPrefix(s.O3Prefix, deadletter.Prefix+"/") {
+		return "invalid o3_prefix", "o3_prefix " + deadletter.Prefix + " is reserved for the dead-letter queue"
+	}
+	if s.O3Prefix == retention.ArchivePrefix || strings.HasPrefix(s.O3Prefix, retention.ArchivePrefix+"/") {
+		return "invalid o3_prefix", "o3_prefix " + retention.ArchivePrefix + " is reserved for archived objects"
+	}
+	if s.O3Prefix == export.Prefix || strings.HasPrefix(s.O3Prefix, export.Prefix+"/") {
+		return "invalid o3_prefix", "o3_prefix " + export.Prefix + " is reserved for exports"
+	}
+	if s.O3Prefix == report.Prefix || strings.HasPrefix(s.O3Prefix, report.Prefix+"/") {
+		return "invalid o3_prefix", "o3_prefix " + report.Prefix + " is reserved for reports"
+	}
+	s.O3Target = strings.TrimSpace(req.O3Target)
+	if msg, detail := checkStorageTarget(targets, s.O3Target); msg != "" {
+		return msg, detail
+	}
+	s.Outputs = nil
+	for _, o := range req.Outputs {
+		if o = strings.TrimSpace(o); o != "" {
+			s.Outputs = append(s.Outputs, o)
+		}
+	}
+	if err := streams.Validate(*s); err != nil {
+		return "invalid stream", err.Error()
+	}
+	return "", ""
+}
+
+// checkStorageTarget returns a message and detail for a 400 response when the o3_target name
+// is neither "" nor one of the configured storage targets.
+func checkStorageTarget(targets []string, name string) (string, string) {
+	if name == "" || slices.Contains(targets, name) {
+		return "", ""
+	}
+	if len(targets) == 0 {
+		return "invalid o3_target", "no storage targets are configured (storage.targets)"
+	}
+	return "invalid o3_target", "o3_target must be one of the storage targets: " + strings.Join(targets, ", ")
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/events"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const (
+	minRestartBackoff = 5 * time.Second
+	maxRestartBackoff = 5 * time.Minute
+)
+
+// Supervise probes every running input each interval until ctx is done. Unhealthy inputs are
+// marked with their error (see ListInputs) and restarted with exponential backoff, so a listener
+// that failed to bind or died after Start comes back once the cause is gone.
+func (h *InputHandler) Supervise(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.checkInstances(now)
+		}
+	}
+}
+
+// checkInstances runs one supervisor pass. The instance lock is held throughout, like
+// startInstance, so API calls never see a half-restarted input.
+func (h *InputHandler) checkInstances(now time.Time) {
+	h.InstancesMu.Lock()
+	defer h.InstancesMu.Unlock()
+	for id, rec := range h.Instances {
+		if rec.Run == nil {
+			continue
+		}
+		err := rec.Run.Health()
+		if err == nil {
+			if rec.LastError != "" || rec.failures > 0 {
+				log.Printf("[inputs] %s healthy again", rec.Input.Title)
+				h.Events.Publish(inputEvent(events.InputRecovered, events.SeverityInfo, rec.Input, "input "+rec.Input.Title+" is healthy again", ""))
+				rec.LastError, rec.failures = "", 0
+				h.Instances[id] = rec
+			}
+			continue
+		}
+		if rec.LastError == "" {
+			log.Printf("[inputs] %s unhealthy: %v", rec.Input.Title, err)
+			h.Events.Publish(inputEvent(events.InputCrashed, events.SeverityError, rec.Input, "input "+rec.Input.Title+" is unhealthy: "+err.Error(), err.Error()))
+		}
+		rec.LastError = err.Error()
+		if now.Before(rec.nextRestart) {
+			h.Instances[id] = rec
+			continue
+		}
+
+		rec.failures++
+		rec.nextRestart = now.Add(restartBackoff(rec.failures))
+		h.stopAndUnmount(rec)
+		run, metrics, err := h.newRuntime(rec.Input, runtimeConfig(rec.Input))
+		if err == nil {
+			err = run.Start()
+		}
+		if err != nil {
+			// Keep the stopped runtime: it still reports unhealthy, so the next pass retries.
+			log.Printf("[inputs] restart %s failed: %v (next attempt in %v)", rec.Input.Title, err, restartBackoff(rec.failures))
+			rec.LastError = err.Error()
+			h.Instances[id] = rec
+			continue
+		}
+		rec.Run, rec.Metrics = run, metrics
+		rec.Restarts++
+		log.Printf("[inputs] restarted %s (attempt %d)", rec.Input.Title, rec.failures)
+		h.Instances[id] = rec
+	}
+}
+
+// inputEvent returns the event of type typ about in, with its error if any.
+func inputEvent(typ, severity string, in model.Input, message, errMsg string) events.Event {
+	data := map[string]any{"input_id": in.ID.String(), "type": in.Type}
+	if errMsg != "" {
+		data["error"] = errMsg
+	}
+	return events.Event{Type: typ, Severity: severity, Message: message, Subject: in.Title, Data: data}
+}
+
+// restartBackoff doubles from minRestartBackoff per consecutive failure, capped at maxRestartBackoff.
+func restartBackoff(failures int) time.Duration {
+	d := minRestartBackoff
+	for n := 1; n < failures && d < maxRestartBackoff; n++ {
+		d *= 2
+	}
+	return min(d, maxRestartBackoff)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/tail"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultTailHeartbeat is how often an idle tail is sent a keep-alive.
+const DefaultTailHeartbeat = 15 * time.Second
+
+// maxTailBacklog bounds the recent entries sent before the live ones.
+const maxTailBacklog = 200
+
+const tailWriteWait = 10 * time.Second
+
+// TailHandler streams ingested entries as they arrive (GET /logs/tail), as server-sent events
+// or, for a WebSocket upgrade request, as WebSocket text frames.
+type TailHandler struct {
+	Hub       *tail.Hub
+	Recent    func() []tail.Event // entries ingested before the request, oldest first; may be nil
+	Heartbeat time.Duration       // default DefaultTailHeartbeat
+
+	upgrader websocket.Upgrader // checks that browsers connect from the same origin
+}
+
+// tailDropped tells a subscriber how many matching entries it has missed so far.
+type tailDropped struct {
+	Dropped int64 `json:"dropped"`
+}
+
+// Tail subscribes to the entries matching the query and project_id parameters and streams
+// them until the client goes away. backlog=N first sends the last N matching entries.
+func (h *TailHandler) Tail(c echo.Context) error {
+	q, err := query.Parse(c.QueryParam("query"))
+	if err != nil {
+		return response.BadRequest(c, "invalid query", err.Error())
+	}
+	projectID := strings.TrimSpace(c.QueryParam("project_id"))
+	backlog := 0
+	if v := c.QueryParam("backlog"); v != "" {
+		if backlog, err = strconv.Atoi(v); err != nil || backlog < 0 || backlog > maxTailBacklog {
+			return response.BadRequest(c, "invalid backlog", fmt.Sprintf("backlog must be between 0 and %d", maxTailBacklog))
+		}
+	}
+	sub, err := h.Hub.Subscribe(q, projectID)
+	if errors.Is(err, tail.ErrTooManySubscribers) {
+		return response.Error(c, http.StatusTooManyRequests, "too many tail subscribers", "close another tail and retry")
+	}
+	if err != nil {
+		return response.Error(c, http.StatusServiceUnavailable, "tail not available", err.Error())
+	}
+	defer sub.Close()
+
+	var past []tail.Event
+	if backlog > 0 && h.Recent != nil {
+		for _, ev := range h.Recent() {
+			if (projectID == "" || ev.Entry.ProjectID == projectID) && q.MatchEntry(&ev.Entry) {
+				past = append(past, ev)
+			}
+		}
+		if len(past) > backlog {
+			past = past[len(past)-backlog:]
+		}
+	}
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultTailHeartbeat
+	}
+	if websocket.IsWebSocketUpgrade(c.Request()) {
+		return h.serveWebSocket(c, sub, past, heartbeat)
+	}
+	return h.serveSSE(c, sub, past, heartbeat)
+}
+
+// serveSSE writes "log" events carrying an entry and, with the heartbeat, a "dropped" event
+// when entries were missed since the last one, or else a comment keeping proxies from
+// closing the stream.
+func (h *TailHandler) serveSSE(c echo.Context, sub *tail.Subscription, past []tail.Event, heartbeat time.Duration) error {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	send := func(event string, v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		return err
+	}
+	for _, ev := range past {
+		if err := send("log", ev); err != nil {
+			return nil
+		}
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	var reported int64
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if err := send("log", ev); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			var err error
+			if d := sub.Dropped(); d != reported {
+				reported = d
+				err = send("dropped", tailDropped{Dropped: d})
+			} else {
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err != nil {
+				return nil
+			}
+		case <-c.Request().Context().Done():
+			return nil
+		}
+		w.Flush()
+	}
+}
+
+// serveWebSocket writes each entry as a JSON text frame and, with the heartbeat, a ping and a
+// {"dropped": n} frame when entries were missed since the last one. Frames from the client
+// are discarded.
+func (h *TailHandler) serveWebSocket(c echo.Context, sub *tail.Subscription, past []tail.Event, heartbeat time.Duration) error {
+	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil // Upgrade already wrote the HTTP error
+	}
+	defer conn.Close()
+	conn.SetReadLimit(1024)
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	send := func(v any) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(tailWriteWait))
+		return conn.WriteJSON(v)
+	}
+	for _, ev := range past {
+		if send(ev) != nil {
+			return nil
+		}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	var reported int64
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(tailWriteWait))
+				return nil
+			}
+			if send(ev) != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if d := sub.Dropped(); d != reported {
+				reported = d
+				if send(tailDropped{Dropped: d}) != nil {
+					return nil
+				}
+			}
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(tailWriteWait)) != nil {
+				return nil
+			}
+		case <-gone:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/deadletter"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// UploadHandler handles /uploads, the batch objects in O3. Store is nil when O3 is not
+// configured; every endpoint then answers 503.
+type UploadHandler struct {
+	Store   *storage.O3Client
+	Batches *repository.BatchRepository // optional; Verify checks the CIDs it records
+}
+
+func (h *UploadHandler) unavailable(c echo.Context) error {
+	return response.Error(c, http.StatusServiceUnavailable, "uploads not available", "uploads require O3 storage")
+}
+
+const (
+	defaultUploadLimit = 100
+	maxUploadLimit     = 1000
+
+	defaultPresignExpiry = 15 * time.Minute
+	maxPresignExpiry     = 7 * 24 * time.Hour // the longest SigV4 allows
+)
+
+// ListUploads lists batch objects, newest first unless order=asc, a page at a time
+// (GET /uploads?prefix=&project_id=&start=&end=&order=&limit=&cursor=). start and end are
+// RFC 3339 and select objects by the day in their key; pass next_cursor as cursor for the
+// next page.
+func (h *UploadHandler) ListUploads(c echo.Context) error {
+	if h.Store == nil {
+		return h.unavailable(c)
+	}
+	opts := storage.ListOptions{
+		Prefix:  c.QueryParam("prefix"),
+		Project: c.QueryParam("project_id"),
+		Desc:    true,
+		Limit:   defaultUploadLimit,
+		Cursor:  c.QueryParam("cursor"),
+	}
+	var err error
+	if opts.Start, err = queryTime(c, "start"); err != nil {
+		return response.BadRequest(c, "invalid start", "start must be an RFC 3339 time")
+	}
+	if opts.End, err = queryTime(c, "end"); err != nil {
+		return response.BadRequest(c, "invalid end", "end must be an RFC 3339 time")
+	}
+	switch c.QueryParam("order") {
+	case "", "desc":
+	case "asc":
+		opts.Desc = false
+	default:
+		return response.BadRequest(c, "invalid order", "order must be asc or desc")
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxUploadLimit {
+			return response.BadRequest(c, "invalid limit", "limit must be between 1 and "+strconv.Itoa(maxUploadLimit))
+		}
+		opts.Limit = n
+	}
+	page, err := h.Store.ListObjectsPage(c.Request().Context(), opts)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return response.BadRequest(c, "invalid cursor", "cursor must be the next_cursor of a previous page")
+		}
+		return response.InternalError(c, "list uploads failed", "list objects: "+err.Error())
+	}
+	if page.Objects == nil {
+		page.Objects = []storage.ObjectInfo{}
+	}
+	return response.OK(c, map[string]any{
+		"objects":     page.Objects,
+		"count":       len(page.Objects),
+		"next_cursor": page.NextCursor,
+		"truncated":   page.NextCursor != "",
+	}, "")
+}
+
+// Verify downloads an object and checks it against the checksums recorded in its metadata and
+// the local manifest and, on Akave, its root CID against the one in the batches index
+// (GET /uploads/verify?key=). A mismatch is reported with ok false, not as an error. Callers
+// bound to a project may only verify the batches of that project.
+func (h *UploadHandler) Verify(c echo.Context) error {
+	if h.Store == nil {
+		return h.unavailable(c)
+	}
+	key := c.QueryParam("key")
+	if key == "" {
+		return response.BadRequest(c, "key is required", "missing query parameter key")
+	}
+	ctx := c.Request().Context()
+	if !keyVisible(ctx, key) {
+		return response.NotFound(c, "object not found", "no object "+key)
+	}
+	cid := ""
+	if h.Batches != nil {
+		b, err := h.Batches.Get(ctx, key)
+		if err != nil {
+			return response.InternalError(c, "verify failed", "get batch: "+err.Error())
+		}
+		if b != nil {
+			cid = b.CID
+		}
+	}
+	v, err := h.Store.VerifyCID(ctx, key, cid)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return response.NotFound(c, "object not found", "no object "+key)
+		}
+		return response.InternalError(c, "verify failed", "verify: "+err.Error())
+	}
+	msg := "object verified"
+	if !v.OK {
+		msg = "object failed verification"
+	}
+	return response.OK(c, v, msg)
+}
+
+type presignRequest struct {
+	Key       string `json:"key"`
+	ExpiresIn string `json:"expires_in"` // Go duration; default 15m, at most 168h
+}
+
+// Presign returns a URL that downloads a batch object straight from O3 until it expires
+// (POST /uploads/presign). Dead-letter objects hold raw payloads and are not presigned.
+// Callers bound to a project may only presign the batches of that project.
+func (h *UploadHandler) Presign(c echo.Context) error {
+	if h.Store == nil {
+		return h.unavailable(c)
+	}
+	var req presignRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	key := strings.TrimPrefix(strings.TrimSpace(req.Key), "/")
+	if key == "" {
+		return response.BadRequest(c, "key is required", "missing field key")
+	}
+	if strings.HasPrefix(key, deadletter.Prefix+"/") {
+		return response.BadRequest(c, "invalid key", "dead-letter objects cannot be presigned")
+	}
+	if !keyVisible(c.Request().Context(), key) {
+		return response.NotFound(c, "object not found", "no object "+key)
+	}
+	expires := defaultPresignExpiry
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > maxPresignExpiry {
+			return response.BadRequest(c, "invalid expires_in", "expires_in must be a duration between 1s and 168h")
+		}
+		expires = d
+	}
+	ctx := c.Request().Context()
+	info, err := h.Store.StatObject(ctx, key)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return response.NotFound(c, "object not found", "no object "+key)
+		}
+		return response.InternalError(c, "presign failed", "stat object: "+err.Error())
+	}
+	now := time.Now().UTC()
+	url, err := h.Store.PresignGet(ctx, key, expires)
+	if err != nil {
+		return response.InternalError(c, "presign failed", "presign: "+err.Error())
+	}
+	return response.OK(c, map[string]any{
+		"key":           info.Key,
+		"size":          info.Size,
+		"last_modified": info.LastModified,
+		"url":           url,
+		"expires_at":    now.Add(expires),
+	}, "")
+}
+
+// keyVisible reports whether the caller of ctx may access the object at key: any object for
+// callers not bound to a project, else only batches in the partition of their project.
+func keyVisible(ctx context.Context, key string) bool {
+	if callerProject(ctx) == "" {
+		return true
+	}
+	_, project, ok := storage.KeyParts(key)
+	return ok && visible(ctx, project)
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bounds of user fields. bcrypt ignores password bytes past 72.
+const (
+	minPassword  = 8
+	maxPassword  = 72
+	maxUserName  = 128
+	maxUserEmail = 254
+)
+
+// UserHandler handles /users, the admin API for user accounts. The last enabled admin
+// cannot be deleted, demoted or disabled, so the deployment always has one.
+type UserHandler struct {
+	Repo *repository.UserRepository
+	Auth *middleware.Authenticator
+}
+
+type userResponse struct {
+	ID          string  `json:"id"`
+	Email       string  `json:"email"`
+	Name        string  `json:"name"`
+	Role        string  `json:"role"`
+	Disabled    bool    `json:"disabled"`
+	LastLoginAt *string `json:"last_login_at"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// userListSpec filters GET /users by role and disabled; q searches names and emails.
+var userListSpec = listSpec[userResponse]{
+	text: func(u userResponse) string { return u.Name + " " + u.Email },
+	filters: map[string]func(userResponse, string) bool{
+		"role":     equalFold(func(u userResponse) string { return u.Role }),
+		"disabled": boolFilter(func(u userResponse) bool { return u.Disabled }),
+	},
+	sorts: namedSorts(func(u userResponse) string { return u.Name }, func(u userResponse) string { return u.CreatedAt }, func(u userResponse) string { return u.UpdatedAt }),
+}
+
+type userRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+	Password string `json:"password"` // required on create; on update, empty keeps it
+}
+
+func newUserResponse(u model.User) userResponse {
+	out := userResponse{
+		ID:        u.ID.String(),
+		Email:     u.Email,
+		Name:      u.Name,
+		Role:      u.Role,
+		Disabled:  u.Disabled,
+		CreatedAt: u.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: u.UpdatedAt.Format(time.RFC3339),
+	}
+	if u.LastLoginAt != nil {
+		at := u.LastLoginAt.Format(time.RFC3339)
+		out.LastLoginAt = &at
+	}
+	return out
+}
+
+// ListUsers returns all users (GET /users).
+func (h *UserHandler) ListUsers(c echo.Context) error {
+	list, err := h.Repo.List(c.Request().Context())
+	if err != nil {
+		return response.InternalError(c, "list users failed", "list users: "+err.Error())
+	}
+	out := make([]userResponse, 0, len(list))
+	for _, u := range list {
+		out = append(out, newUserResponse(u))
+	}
+	return listResponse(c, "users", out, userListSpec)
+}
+
+// GetUser returns one user (GET /users/:id).
+func (h *UserHandler) GetUser(c echo.Context) error {
+	u, err := h.byID(c)
+	if u == nil {
+		return err
+	}
+	return response.OK(c, newUserResponse(*u), "")
+}
+
+// CreateUser persists a new user with a password (POST /users).
+func (h *UserHandler) CreateUser(c echo.Context) error {
+	var req userRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	u := model.User{}
+	if msg, detail := applyUser(&u, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if msg, detail := setPassword(&u, req.Password); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	existing, err := h.Repo.GetByEmail(c.Request().Context(), u.Email)
+	if err != nil {
+		return response.InternalError(c, "create user failed", "get user: "+err.Error())
+	}
+	if existing != nil {
+		return response.Error(c, http.StatusConflict, "email already in use", "a user with email "+u.Email+" already exists")
+	}
+	if err := h.Repo.Create(c.Request().Context(), &u); err != nil {
+		return response.InternalError(c, "create user failed", "create user: "+err.Error())
+	}
+	return response.Created(c, newUserResponse(u), "user created")
+}
+
+// UpdateUser replaces a user's email, name, role and disabled flag, and the password when
+// one is given (PUT /users/:id). Disabling a user or changing the password ends their
+// sessions.
+func (h *UserHandler) UpdateUser(c echo.Context) error {
+	u, err := h.byID(c)
+	if u == nil {
+		return err
+	}
+	var req userRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body", "invalid JSON body")
+	}
+	ctx := c.Request().Context()
+	old := *u
+	if msg, detail := applyUser(u, req); msg != "" {
+		return response.BadRequest(c, msg, detail)
+	}
+	if req.Password != "" {
+		if msg, detail := setPassword(u, req.Password); msg != "" {
+			return response.BadRequest(c, msg, detail)
+		}
+	}
+	if u.Email != old.Email {
+		existing, err := h.Repo.GetByEmail(ctx, u.Email)
+		if err != nil {
+			return response.InternalError(c, "update user failed", "get user: "+err.Error())
+		}
+		if existing != nil {
+			return response.Error(c, http.StatusConflict, "email already in use", "a user with email "+u.Email+" already exists")
+		}
+	}
+	if old.Role == model.RoleAdmin && !old.Disabled && (u.Role != model.RoleAdmin || u.Disabled) {
+		if ok, err := h.keepAdmin(c, u); !ok {
+			return err
+		}
+	}
+	if err := h.Repo.Update(ctx, u); err != nil {
+		return response.InternalError(c, "update user failed", "update user: "+err.Error())
+	}
+	if req.Password != "" {
+		if err := h.Repo.SetPassword(ctx, u); err != nil {
+			return response.InternalError(c, "update user failed", "set password: "+err.Error())
+		}
+	}
+	h.Auth.Forget()
+	return response.OK(c, newUserResponse(*u), "user updated")
+}
+
+// DeleteUser removes a user; their sessions stop working (DELETE /users/:id).
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	u, err := h.byID(c)
+	if u == nil {
+		return err
+	}
+	if u.Role == model.RoleAdmin && !u.Disabled {
+		if ok, err := h.keepAdmin(c, u); !ok {
+			return err
+		}
+	}
+	if err := h.Repo.Delete(c.Request().Context(), u.ID); err != nil {
+		return response.InternalError(c, "delete user failed", "delete user: "+err.Error())
+	}
+	h.Auth.Forget()
+	return response.OK(c, nil, "user deleted")
+}
+
+// keepAdmin checks that another enabled admin remains without u. When it returns false, the
+// error response has already been written and err is its result.
+func (h *UserHandler) keepAdmin(c echo.Context, u *model.User) (bool, error) {
+	n, err := h.Repo.CountAdmins(c.Request().Context(), u.ID)
+	if err != nil {
+		return false, response.InternalError(c, "count admins failed", "count admins: "+err.Error())
+	}
+	if n == 0 {
+		return false, response.Error(c, http.StatusConflict, "last admin", "at least one enabled admin must remain")
+	}
+	return true, nil
+}
+
+// byID loads the user named by the :id path parameter. When it returns nil, the error
+// response has already been written and err is its result.
+func (h *UserHandler) byID(c echo.Context) (*model.User, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, response.BadRequest(c, "invalid id", "invalid id")
+	}
+	u, err := h.Repo.GetByID(c.Request().Context(), id)
+	if err != nil {
+		return nil, response.InternalError(c, "get user failed", "get user: "+err.Error())
+	}
+	if u == nil {
+		return nil, response.NotFound(c, "user not found", "user not found")
+	}
+	return u, nil
+}
+
+// applyUser copies req onto u, except the password, and validates it. It returns a message
+// and detail for a 400 response, or "" when u is valid.
+func applyUser(u *model.User, req userRequest) (string, string) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || len(email) > maxUserEmail {
+		return "invalid email", "email must be a plain address such as alice@example.com"
+	}
+	u.Email = email
+	u.Name = strings.TrimSpace(req.Name)
+	if len(u.Name) > maxUserName {
+		return "invalid name", "name is at most 128 bytes"
+	}
+	u.Role = strings.ToLower(strings.TrimSpace(req.Role))
+	if u.Role != model.RoleAdmin && u.Role != model.RoleOperator && u.Role != model.RoleViewer {
+		return "invalid role", "role must be admin, operator or viewer"
+	}
+	u.Disabled = req.Disabled
+	return "", ""
+}
+
+// setPassword validates password and stores its bcrypt hash on u. It returns a message and
+// detail for a 400 response, or "" on success.
+func setPassword(u *model.User, password string) (string, string) {
+	if len(password) < minPassword || len(password) > maxPassword {
+		return "invalid password", "password must be 8 to 72 bytes"
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "invalid password", "hash password: " + err.Error()
+	}
+	u.PasswordHash = string(hash)
+	return "", ""
+}
@@ -0,0 +1,262 @@
+// Package idempotency lets clients retry POST requests safely: a request carrying an
+// Idempotency-Key header is answered once, and a retry with the same key gets the stored
+// response again instead of creating a second input or ingesting the batch twice.
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header is the request header naming the key.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on a response answered from the store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Defaults of a Store.
+const (
+	DefaultTTL     = 24 * time.Hour
+	DefaultMaxKeys = 10000
+	DefaultMaxBody = 10 << 20 // requests with larger bodies are passed on without a key
+	MaxKeyLength   = 255
+)
+
+// Config bounds a Store. Zero fields take their defaults.
+type Config struct {
+	TTL     time.Duration // how long a response is kept
+	MaxKeys int           // keys kept at most; the oldest are forgotten first
+	MaxBody int64         // requests with larger bodies are not deduplicated
+}
+
+// Store keeps the responses to the requests that carried a key, in memory, for Config.TTL.
+// Keys are scoped to the request's method, path and credentials, so two clients or two
+// endpoints cannot collide. A key reused with another body is refused with 422, and a retry
+// arriving while the first request is still being served with 409.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *entry, oldest first in order
+	order   *list.List
+}
+
+type entry struct {
+	scope       string
+	fingerprint string
+	expires     time.Time
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// NewStore returns an empty Store bounded by cfg.
+func NewStore(cfg Config) *Store {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = DefaultMaxKeys
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = DefaultMaxBody
+	}
+	return &Store{cfg: cfg, now: time.Now, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Len returns the number of keys kept.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	return len(s.entries)
+}
+
+// Middleware answers the requests of next that carry an Idempotency-Key once. Only POST, PUT
+// and PATCH requests are deduplicated. Responses with a status of 5xx, 408, 409 or 429 are not
+// kept, so a retry after them is served again.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(Header))
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxKeyLength {
+			writeError(w, http.StatusBadRequest, "invalid Idempotency-Key", "Idempotency-Key is longer than 255 bytes")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, s.cfg.MaxBody+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "read error", err.Error())
+			return
+		}
+		if int64(len(body)) > s.cfg.MaxBody {
+			// Too large to fingerprint: served as if it had no key.
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		id := scope(r) + "\x00" + key
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+		e, fresh := s.begin(id, fingerprint)
+		switch {
+		case e == nil:
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key reused",
+				"the Idempotency-Key was used with another request body; use a new key")
+			return
+		case !fresh && !e.done:
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusConflict, "request in progress",
+				"a request with this Idempotency-Key is still being served; retry later")
+			return
+		case !fresh:
+			for k, v := range e.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(e.status)
+			w.Write(e.body)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				s.forget(id)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		if keep(rec.status) {
+			header := w.Header().Clone()
+			header.Del("X-Request-Id") // the retry has its own
+			s.finish(id, rec.status, header, rec.body.Bytes())
+		} else {
+			s.forget(id)
+		}
+		completed = true
+	})
+}
+
+// keep reports whether a response of status answers the retries of its request.
+func keep(status int) bool {
+	switch {
+	case status >= 500, status == http.StatusRequestTimeout, status == http.StatusConflict, status == http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+// begin returns the entry of id and whether it was just created for a request with
+// fingerprint, or nil when id is taken by a request with another fingerprint.
+func (s *Store) begin(id, fingerprint string) (*entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	if el, ok := s.entries[id]; ok {
+		e := el.Value.(*entry)
+		if e.fingerprint != fingerprint {
+			return nil, false
+		}
+		return e, false
+	}
+	e := &entry{scope: id, fingerprint: fingerprint, expires: s.now().Add(s.cfg.TTL)}
+	s.entries[id] = s.order.PushBack(e)
+	for len(s.entries) > s.cfg.MaxKeys {
+		oldest := s.order.Front()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).scope)
+	}
+	return e, true
+}
+
+func (s *Store) finish(id string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[id]; ok {
+		e := el.Value.(*entry)
+		e.done, e.status, e.header, e.body = true, status, header, body
+	}
+}
+
+func (s *Store) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[id]; ok {
+		s.order.Remove(el)
+		delete(s.entries, id)
+	}
+}
+
+// expire forgets the entries past their TTL; s.mu is held. Entries expire in the order they
+// were created, so it stops at the first live one.
+func (s *Store) expire() {
+	now := s.now()
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		e := el.Value.(*entry)
+		if now.Before(e.expires) {
+			return
+		}
+		s.order.Remove(el)
+		delete(s.entries, e.scope)
+	}
+}
+
+// scope identifies who sent r where: its method, path and a hash of its credentials.
+func scope(r *http.Request) string {
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "X-API-Key", "X-Akavelog-Token"} {
+		io.WriteString(h, r.Header.Get(name))
+		h.Write([]byte{0})
+	}
+	return r.Method + " " + r.URL.Path + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes a response on and keeps a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status, r.wrote = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// writeError answers with the error shape of the management API.
+func writeError(w http.ResponseWriter, status int, message, detail string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"message": message, "error": detail, "status": status})
+}
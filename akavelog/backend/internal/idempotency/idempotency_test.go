@@ -0,0 +1,146 @@
+package idempotency
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func send(h http.Handler, method, key, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/inputs", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	if token != "" {
+		r.Header.Set("X-API-Key", token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int64
+	s := NewStore(Config{})
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := calls.Add(1)
+		w.Header().Set("Location", fmt.Sprintf("/inputs/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "created %d from %s", n, body)
+	}))
+
+	first := send(h, http.MethodPost, "k1", "a", `{"title":"x"}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `created 1 from {"title":"x"}` {
+		t.Fatalf("first: %d %s", first.Code, first.Body)
+	}
+	retry := send(h, http.MethodPost, "k1", "a", `{"title":"x"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("Location") != "/inputs/1" || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry: %d %s %v", retry.Code, retry.Body, retry.Header())
+	}
+	if calls.Load() != 1 {
+		t.Errorf("handler called %d times", calls.Load())
+	}
+
+	if w := send(h, http.MethodPost, "k1", "a", `{"title":"y"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: %d", w.Code)
+	}
+	// Other credentials, no key and GET requests are served again.
+	send(h, http.MethodPost, "k1", "b", `{"title":"x"}`)
+	send(h, http.MethodPost, "", "a", `{"title":"x"}`)
+	send(h, http.MethodGet, "k1", "a", "")
+	if calls.Load() != 4 {
+		t.Errorf("handler called %d times, want 4", calls.Load())
+	}
+	if s.Len() != 2 {
+		t.Errorf("%d keys kept", s.Len())
+	}
+}
+
+func TestMiddlewareFailures(t *testing.T) {
+	var calls atomic.Int64
+	status := http.StatusServiceUnavailable
+	h := NewStore(Config{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	send(h, http.MethodPost, "k", "", "batch")
+	status = http.StatusAccepted
+	if w := send(h, http.MethodPost, "k", "", "batch"); w.Code != http.StatusAccepted {
+		t.Errorf("retry after 503: %d", w.Code)
+	}
+	send(h, http.MethodPost, "k", "", "batch")
+	if calls.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", calls.Load())
+	}
+	if w := send(h, http.MethodPost, strings.Repeat("k", MaxKeyLength+1), "", "batch"); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: %d", w.Code)
+	}
+}
+
+func TestMiddlewareInProgress(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := NewStore(Config{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		send(h, http.MethodPost, "k", "", "batch")
+	}()
+	<-started
+	if w := send(h, http.MethodPost, "k", "", "batch"); w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("concurrent retry: %d", w.Code)
+	}
+	close(release)
+	wg.Wait()
+	if w := send(h, http.MethodPost, "k", "", "batch"); w.Code != http.StatusAccepted || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("later retry: %d", w.Code)
+	}
+}
+
+func TestStoreBounds(t *testing.T) {
+	now := time.Now()
+	s := NewStore(Config{TTL: time.Minute, MaxKeys: 2, MaxBody: 4})
+	s.now = func() time.Time { return now }
+	var calls atomic.Int64
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+	}))
+	for _, key := range []string{"a", "b", "c"} {
+		send(h, http.MethodPost, key, "", "x")
+	}
+	if s.Len() != 2 {
+		t.Errorf("%d keys kept, want 2", s.Len())
+	}
+	send(h, http.MethodPost, "a", "", "x") // forgotten: served again
+	if calls.Load() != 4 {
+		t.Errorf("handler called %d times, want 4", calls.Load())
+	}
+	now = now.Add(2 * time.Minute)
+	if s.Len() != 0 {
+		t.Errorf("%d keys kept after the TTL", s.Len())
+	}
+	// Larger bodies are passed on whole, without a key.
+	var got string
+	h = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	send(h, http.MethodPost, "big", "", "0123456789")
+	if got != "0123456789" || s.Len() != 0 {
+		t.Errorf("large body: %q, %d keys", got, s.Len())
+	}
+}
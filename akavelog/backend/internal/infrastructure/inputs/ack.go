@@ -0,0 +1,84 @@
+package inputs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Acknowledgement modes of an input, its ack_mode setting: when a client is told its payloads
+// were taken.
+const (
+	AckAccepted = "accepted" // once they are in the ingest queue (the default)
+	AckDurable  = "durable"  // once their entries are written to the write-ahead log
+)
+
+// AckModeField is the config field of inputs that acknowledge requests. Factories append it to
+// their ConfigSpec and call AckModeFromConfig.
+var AckModeField = ConfigField{Name: "ack_mode", Type: "string", Required: false,
+	Description: "accepted answers once entries are queued (default); durable once they are written to the write-ahead log, or taken by the batcher when there is none", Example: AckDurable}
+
+// AckModeFromConfig returns the ack_mode of cfg, AckAccepted when unset.
+func AckModeFromConfig(cfg Config) (string, error) {
+	v, _ := cfg["ack_mode"].(string)
+	switch mode := strings.ToLower(strings.TrimSpace(v)); mode {
+	case "":
+		return AckAccepted, nil
+	case AckAccepted, AckDurable:
+		return mode, nil
+	}
+	return "", fmt.Errorf("ack_mode must be %s or %s", AckAccepted, AckDurable)
+}
+
+// ErrNotDurable is returned by a durable insert whose entry could not be written to the
+// write-ahead log. The entry was not kept; the client should retry.
+var ErrNotDurable = errors.New("entry could not be written to the write-ahead log")
+
+type durableKey struct{}
+
+// WithDurable returns a ctx under which an insert returns only once the payload's entry is
+// written to the write-ahead log, or ErrNotDurable. Buffers that queue payloads wait for the
+// rest of the chain to take them.
+func WithDurable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, durableKey{}, true)
+}
+
+// Durable reports whether inserts under ctx must be durable.
+func Durable(ctx context.Context) bool {
+	v, _ := ctx.Value(durableKey{}).(bool)
+	return v
+}
+
+// EntryIDs collects the IDs given to the entries inserted under a context, so an input can
+// tell its client what they are. It is safe for concurrent use.
+type EntryIDs struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+type entryIDsKey struct{}
+
+// WithEntryIDs returns a ctx under which the pipeline records in the returned EntryIDs the ID
+// of every payload inserted, in order, and "" for payloads that are not valid entries.
+func WithEntryIDs(ctx context.Context) (context.Context, *EntryIDs) {
+	ids := &EntryIDs{}
+	return context.WithValue(ctx, entryIDsKey{}, ids), ids
+}
+
+// RecordEntryID records id in the EntryIDs of ctx, if it has one.
+func RecordEntryID(ctx context.Context, id string) {
+	if ids, ok := ctx.Value(entryIDsKey{}).(*EntryIDs); ok {
+		ids.mu.Lock()
+		ids.ids = append(ids.ids, id)
+		ids.mu.Unlock()
+	}
+}
+
+// List returns the IDs recorded so far.
+func (r *EntryIDs) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
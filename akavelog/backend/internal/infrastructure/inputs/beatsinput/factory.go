@@ -0,0 +1,83 @@
+package beatsinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+const defaultIdleTimeout = 5 * time.Minute
+
+// Factory creates Beats (Lumberjack v2) inputs. Registers as "beats".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "beats"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "beats",
+		Description: "Elastic Beats Lumberjack v2 protocol over TCP, for Filebeat/Winlogbeat/Metricbeat using output.logstash. Windows are acked once every event is buffered; compressed frames and optional TLS are supported.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":5044"},
+			{Name: "tls_cert_file", Type: "string", Required: false, Description: "PEM server certificate; enables TLS together with tls_key_file", Example: "/etc/akavelog/beats.crt"},
+			{Name: "tls_key_file", Type: "string", Required: false, Description: "PEM server key", Example: "/etc/akavelog/beats.key"},
+			{Name: "tls_ca_file", Type: "string", Required: false, Description: "PEM CA bundle; when set, clients must present a certificate signed by it"},
+			{Name: "idle_timeout", Type: "string", Required: false, Description: "Close connections idle for this long (default 5m)", Example: "5m"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for events without service.name (defaults to the beat name, e.g. filebeat)"},
+		},
+	}
+}
+
+// ValidateConfig validates beats input config: listen is required and TLS files must come in pairs.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer)
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	str := func(k string) string {
+		v, _ := cfg[k].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Listen:      str("listen"),
+		CertFile:    str("tls_cert_file"),
+		KeyFile:     str("tls_key_file"),
+		CAFile:      str("tls_ca_file"),
+		IdleTimeout: defaultIdleTimeout,
+		Service:     str("service"),
+	}
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :5044)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :5044 or 0.0.0.0:5044)")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return c, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.CAFile != "" && c.CertFile == "" {
+		return c, fmt.Errorf("tls_ca_file requires tls_cert_file and tls_key_file")
+	}
+	if v := str("idle_timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("idle_timeout must be a positive duration (e.g. 5m)")
+		}
+		c.IdleTimeout = d
+	}
+	return c, nil
+}
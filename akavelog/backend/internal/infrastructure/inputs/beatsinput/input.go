@@ -0,0 +1,269 @@
+package beatsinput
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Config holds the parsed settings of a beats input.
+type Config struct {
+	Listen      string
+	CertFile    string
+	KeyFile     string
+	CAFile      string // when set, client certificates are required and verified against it
+	IdleTimeout time.Duration
+	Service     string // fallback service; empty uses the beat name
+}
+
+// Input is a Lumberjack v2 listener that writes every Beats event to an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	cfg     Config
+	tls     *tls.Config
+	buffer  inputs.InputBuffer
+	metrics *inputs.Metrics
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	drain    inputs.Drainer
+}
+
+// NewInput creates a beats input. TLS files are loaded here so bad paths fail on create.
+func NewInput(cfg Config, buffer inputs.InputBuffer) (*Input, error) {
+	i := &Input{cfg: cfg, buffer: buffer, metrics: inputs.MetricsOf(buffer), conns: make(map[net.Conn]struct{})}
+	if cfg.CertFile != "" {
+		tc, err := inputs.ServerTLSConfig(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		i.tls = tc
+	}
+	return i, nil
+}
+
+// Addr returns the bound address once started (useful when listening on :0).
+func (i *Input) Addr() net.Addr {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.listener != nil {
+		return i.listener.Addr()
+	}
+	return nil
+}
+
+func (i *Input) Start() error {
+	ln, err := net.Listen("tcp", i.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", i.cfg.Listen, err)
+	}
+	if i.tls != nil {
+		ln = tls.NewListener(ln, i.tls)
+	}
+	i.mu.Lock()
+	i.listener = ln
+	i.mu.Unlock()
+	i.wg.Add(1)
+	go i.acceptLoop(ln)
+	log.Printf("[beats] listening on %s (tls=%t)", i.cfg.Listen, i.tls != nil)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	ln := i.listener
+	i.listener = nil
+	for c := range i.conns {
+		_ = c.Close()
+	}
+	i.mu.Unlock()
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	i.wg.Wait()
+	return err
+}
+
+// Drain stops accepting connections, reads what clients have already sent for up to
+// inputs.DrainReadTimeout, then stops. Windows left unacked are resent by the clients. When
+// ctx is done first it stops at once.
+func (i *Input) Drain(ctx context.Context) error {
+	deadline := i.drain.Begin()
+	i.mu.Lock()
+	ln := i.listener
+	i.listener = nil
+	i.mu.Unlock()
+	if ln != nil {
+		_ = ln.Close()
+	}
+	i.mu.Lock()
+	for c := range i.conns {
+		_ = c.SetReadDeadline(deadline)
+	}
+	i.mu.Unlock()
+	inputs.Wait(ctx, &i.wg)
+	return i.Stop()
+}
+
+func (i *Input) acceptLoop(ln net.Listener) {
+	defer i.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[beats] accept on %s: %v", i.cfg.Listen, err)
+				i.Fail(fmt.Errorf("accept on %s: %w", i.cfg.Listen, err))
+			}
+			return
+		}
+		i.mu.Lock()
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+		i.metrics.ConnOpened()
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			defer func() {
+				i.mu.Lock()
+				delete(i.conns, conn)
+				i.mu.Unlock()
+				i.metrics.ConnClosed()
+				_ = conn.Close()
+			}()
+			i.serve(conn)
+		}()
+	}
+}
+
+// serve reads frames until the peer disconnects. Beats announce a window size before each
+// batch and wait for an ack carrying the last sequence number; events are inserted before
+// the ack is written, so an unacked window is resent by the client after a failure.
+// Without a window frame, every data frame is acked on its own.
+func (i *Input) serve(conn net.Conn) {
+	br := bufio.NewReader(conn)
+	var window, pending, lastSeq uint32
+	for {
+		i.drain.SetReadDeadline(conn, i.cfg.IdleTimeout)
+		f, err := readFrame(br)
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			if !i.drain.Draining() {
+				log.Printf("[beats] %s: closing idle connection", conn.RemoteAddr())
+			}
+			return
+		case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+			return
+		default:
+			i.metrics.Error()
+			log.Printf("[beats] %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		if f.Kind == frameWindow {
+			if pending > 0 {
+				if err := writeAck(conn, lastSeq); err != nil {
+					log.Printf("[beats] %s: write ack: %v", conn.RemoteAddr(), err)
+					return
+				}
+			}
+			window, pending = f.Window, 0
+			continue
+		}
+		for _, ev := range f.Events {
+			if err := i.insert(ev.Fields); err != nil {
+				// Without an ack for the window the client resends it on a new connection.
+				log.Printf("[beats] %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			lastSeq = ev.Seq
+			pending++
+		}
+		if pending > 0 && (window == 0 || pending >= window) {
+			if err := writeAck(conn, lastSeq); err != nil {
+				log.Printf("[beats] %s: write ack: %v", conn.RemoteAddr(), err)
+				return
+			}
+			pending = 0
+		}
+	}
+}
+
+func (i *Input) insert(fields map[string]any) error {
+	raw, err := json.Marshal(i.entry(fields))
+	if err != nil {
+		log.Printf("[beats] marshal entry: %v", err)
+		return nil
+	}
+	return i.buffer.Insert(raw)
+}
+
+// entry maps a Beats event onto a LogEntry. Nested ECS objects are flattened into dotted tags
+// (host.name, log.file.path, ...); service.name and log.level fill service and level, and the
+// beat name from @metadata is kept as the "beat" tag.
+func (i *Input) entry(fields map[string]any) model.LogEntry {
+	beat := ""
+	if meta, ok := fields["@metadata"].(map[string]any); ok {
+		beat, _ = meta["beat"].(string)
+	}
+	if agent, ok := fields["agent"].(map[string]any); ok && beat == "" {
+		beat, _ = agent["type"].(string)
+	}
+
+	flat := make(map[string]any, len(fields))
+	for k, v := range fields {
+		if k == "@metadata" {
+			continue
+		}
+		flatten(k, v, flat)
+	}
+	for from, to := range map[string]string{"service.name": "service", "log.level": "level"} {
+		if v, ok := flat[from]; ok {
+			if _, taken := flat[to]; !taken {
+				flat[to] = v
+				delete(flat, from)
+			}
+		}
+	}
+
+	service := i.cfg.Service
+	if service == "" {
+		service = beat
+	}
+	if service == "" {
+		service = "beats"
+	}
+	entry := inputs.RecordToEntry(flat, service)
+	if beat != "" {
+		entry.Tags["beat"] = beat
+	}
+	return entry
+}
+
+// flatten copies v into out under key, expanding nested objects into dotted keys.
+func flatten(key string, v any, out map[string]any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		out[key] = v
+		return
+	}
+	for k, nested := range m {
+		flatten(key+"."+k, nested, out)
+	}
+}
@@ -0,0 +1,134 @@
+package beatsinput
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func (b *memBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.msgs)
+}
+
+func u32(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, n)
+	return b
+}
+
+func jsonFrame(seq uint32, v any) []byte {
+	payload, _ := json.Marshal(v)
+	out := append([]byte{'2', 'J'}, u32(seq)...)
+	out = append(out, u32(uint32(len(payload)))...)
+	return append(out, payload...)
+}
+
+func compressedFrame(frames ...[]byte) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	for _, f := range frames {
+		_, _ = zw.Write(f)
+	}
+	_ = zw.Close()
+	out := append([]byte{'2', 'C'}, u32(uint32(z.Len()))...)
+	return append(out, z.Bytes()...)
+}
+
+func TestReadFrame_Data(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{'2', 'D'})
+	b.Write(u32(7))
+	b.Write(u32(1))
+	b.Write(u32(4))
+	b.WriteString("line")
+	b.Write(u32(5))
+	b.WriteString("hello")
+	f, err := readFrame(&b)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(f.Events) != 1 || f.Events[0].Seq != 7 || f.Events[0].Fields["line"] != "hello" {
+		t.Fatalf("unexpected frame %+v", f)
+	}
+	if _, err := readFrame(bytes.NewReader([]byte{'2', 'J', 0, 0})); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected unexpected EOF for truncated frame, got %v", err)
+	}
+}
+
+func TestInput_WindowAck(t *testing.T) {
+	buf := &memBuffer{}
+	in, err := NewInput(Config{Listen: "127.0.0.1:0"}, buf)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := in.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer in.Stop()
+
+	conn, err := net.Dial("tcp", in.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	ev := map[string]any{
+		"@timestamp": "2024-01-02T03:04:05Z",
+		"@metadata":  map[string]any{"beat": "filebeat"},
+		"message":    "hello",
+		"host":       map[string]any{"name": "web-1"},
+		"log":        map[string]any{"level": "error", "file": map[string]any{"path": "/var/log/app.log"}},
+	}
+	// Window of 3: one plain JSON frame then a compressed frame holding two more.
+	var msg []byte
+	msg = append(msg, '2', 'W')
+	msg = append(msg, u32(3)...)
+	msg = append(msg, jsonFrame(1, ev)...)
+	msg = append(msg, compressedFrame(jsonFrame(2, ev), jsonFrame(3, ev))...)
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	ack := make([]byte, 6)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		t.Fatalf("read ack: %v", err)
+	}
+	if ack[0] != '2' || ack[1] != 'A' || binary.BigEndian.Uint32(ack[2:]) != 3 {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+	if buf.Len() != 3 {
+		t.Fatalf("expected 3 buffered entries, got %d", buf.Len())
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	if entry.Service != "filebeat" || entry.Level != "error" || entry.Message != "hello" ||
+		entry.Timestamp != "2024-01-02T03:04:05Z" || entry.Tags["host.name"] != "web-1" ||
+		entry.Tags["log.file.path"] != "/var/log/app.log" || entry.Tags["beat"] != "filebeat" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
@@ -0,0 +1,191 @@
+package beatsinput
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Lumberjack v2 frame types. Every frame starts with the version byte '2' and one of these.
+const (
+	protocolVersion = '2'
+	frameWindow     = 'W'
+	frameJSON       = 'J'
+	frameData       = 'D'
+	frameCompressed = 'C'
+	frameAck        = 'A'
+)
+
+// maxPayloadSize bounds a single JSON, data or compressed payload so a bad length prefix
+// cannot make us allocate arbitrary memory.
+const maxPayloadSize = 64 << 20
+
+// event is one decoded Beats event and the sequence number the client assigned it.
+type event struct {
+	Seq    uint32
+	Fields map[string]any
+}
+
+// frame is one top-level frame: either a window size or the events it carried.
+// Compressed frames are expanded, so events may hold many entries.
+type frame struct {
+	Kind   byte
+	Window uint32
+	Events []event
+}
+
+// readFrame decodes one frame from r.
+//
+//	window:     '2' 'W' uint32(size)
+//	json:       '2' 'J' uint32(seq) uint32(len) payload
+//	data:       '2' 'D' uint32(seq) uint32(pairs) { uint32(len) key uint32(len) value }...
+//	compressed: '2' 'C' uint32(len) zlib(frames...)
+func readFrame(r io.Reader) (f frame, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return frame{}, err
+	}
+	if hdr[0] != protocolVersion {
+		return frame{}, fmt.Errorf("unsupported protocol version %q", hdr[0])
+	}
+	// EOF is only clean between frames; inside one it means the peer cut us off.
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+	f.Kind = hdr[1]
+	switch f.Kind {
+	case frameWindow:
+		n, err := readUint32(r)
+		if err != nil {
+			return f, err
+		}
+		f.Window = n
+	case frameJSON:
+		ev, err := readJSON(r)
+		if err != nil {
+			return f, err
+		}
+		f.Events = []event{ev}
+	case frameData:
+		ev, err := readData(r)
+		if err != nil {
+			return f, err
+		}
+		f.Events = []event{ev}
+	case frameCompressed:
+		events, err := readCompressed(r)
+		if err != nil {
+			return f, err
+		}
+		f.Events = events
+	default:
+		return f, fmt.Errorf("unknown frame type %q", f.Kind)
+	}
+	return f, nil
+}
+
+func readJSON(r io.Reader) (event, error) {
+	seq, err := readUint32(r)
+	if err != nil {
+		return event{}, err
+	}
+	payload, err := readPayload(r)
+	if err != nil {
+		return event{}, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return event{}, fmt.Errorf("json frame %d: %w", seq, err)
+	}
+	return event{Seq: seq, Fields: fields}, nil
+}
+
+func readData(r io.Reader) (event, error) {
+	seq, err := readUint32(r)
+	if err != nil {
+		return event{}, err
+	}
+	pairs, err := readUint32(r)
+	if err != nil {
+		return event{}, err
+	}
+	fields := make(map[string]any)
+	for n := uint32(0); n < pairs; n++ {
+		k, err := readPayload(r)
+		if err != nil {
+			return event{}, err
+		}
+		v, err := readPayload(r)
+		if err != nil {
+			return event{}, err
+		}
+		fields[string(k)] = string(v)
+	}
+	return event{Seq: seq, Fields: fields}, nil
+}
+
+// readCompressed inflates a compressed frame and decodes the event frames inside it.
+func readCompressed(r io.Reader) ([]event, error) {
+	payload, err := readPayload(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("compressed frame: %w", err)
+	}
+	defer zr.Close()
+	inner := bufio.NewReader(io.LimitReader(zr, maxPayloadSize))
+	var events []event
+	for {
+		f, err := readFrame(inner)
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("compressed frame: %w", err)
+		}
+		if f.Kind == frameWindow {
+			return nil, errors.New("compressed frame: unexpected window frame")
+		}
+		events = append(events, f.Events...)
+	}
+}
+
+func readPayload(r io.Reader) ([]byte, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxPayloadSize {
+		return nil, fmt.Errorf("payload of %d bytes exceeds limit %d", n, maxPayloadSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// writeAck acknowledges every event up to and including seq.
+func writeAck(w io.Writer, seq uint32) error {
+	b := []byte{protocolVersion, frameAck, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[2:], seq)
+	_, err := w.Write(b)
+	return err
+}
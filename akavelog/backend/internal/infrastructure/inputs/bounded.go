@@ -0,0 +1,205 @@
+package inputs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Overflow policies of a BoundedBuffer: what Insert does when the buffer is full.
+const (
+	OverflowBlock      = "block"       // wait up to BlockTimeout for space, then ErrBufferTimeout
+	OverflowDropOldest = "drop_oldest" // discard the oldest queued payload to make room
+	OverflowDropNewest = "drop_newest" // discard the new payload
+	OverflowReject     = "reject"      // return ErrBufferFull
+)
+
+// BoundedBufferConfig configures a BoundedBuffer.
+type BoundedBufferConfig struct {
+	Capacity     int           // payloads queued at most (default 10000)
+	Overflow     string        // one of the Overflow* policies (default block)
+	BlockTimeout time.Duration // block: longest wait for space (default 5s)
+}
+
+// BoundedBufferStats is the runtime view of a BoundedBuffer.
+type BoundedBufferStats struct {
+	Capacity int    `json:"capacity"`
+	Overflow string `json:"overflow"`
+	Queued   int    `json:"queued"`
+	Dropped  int64  `json:"dropped"`  // drop_oldest/drop_newest
+	Rejected int64  `json:"rejected"` // reject, block timeouts and inserts after Close
+}
+
+// BoundedBuffer queues payloads for Next, which is fed from one goroutine. It bounds memory
+// between inputs and a slow backend and applies the overflow policy when full, so inputs can
+// push back on their clients instead.
+type BoundedBuffer struct {
+	cfg  BoundedBufferConfig
+	next InputBuffer
+	ch   chan queued
+	done chan struct{}
+
+	mu     sync.RWMutex // Insert holds it shared while sending; Close exclusively
+	closed bool
+
+	dropped  atomic.Int64
+	rejected atomic.Int64
+}
+
+// queued is a payload in a BoundedBuffer with the span it was inserted under, so its trace
+// continues in Next. done is set for durable inserts and receives the result of Next.
+type queued struct {
+	p    []byte
+	sc   trace.SpanContext
+	done chan error
+}
+
+// NewBoundedBuffer starts a BoundedBuffer feeding next.
+func NewBoundedBuffer(cfg BoundedBufferConfig, next InputBuffer) (*BoundedBuffer, error) {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 10000
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowBlock
+	}
+	switch cfg.Overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowReject:
+	default:
+		return nil, fmt.Errorf("overflow must be one of block, drop_oldest, drop_newest, reject")
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = 5 * time.Second
+	}
+	b := &BoundedBuffer{cfg: cfg, next: next, ch: make(chan queued, cfg.Capacity), done: make(chan struct{})}
+	go b.run()
+	return b, nil
+}
+
+func (b *BoundedBuffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+// InsertContext queues p under a buffer.insert span, which covers any wait for space. When ctx
+// is Durable, it also waits until Next has taken p and returns Next's error.
+func (b *BoundedBuffer) InsertContext(ctx context.Context, p []byte) error {
+	ctx, span := tracing.Child(ctx, "buffer.insert")
+	defer span.End()
+	q := queued{p: p, sc: trace.SpanContextFromContext(ctx)}
+	if Durable(ctx) {
+		q.done = make(chan error, 1)
+	}
+	err := b.insert(q)
+	if err == nil && q.done != nil {
+		select {
+		case err = <-q.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	return err
+}
+
+func (b *BoundedBuffer) insert(p queued) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		b.rejected.Add(1)
+		return ErrBufferClosed
+	}
+	select {
+	case b.ch <- p:
+		return nil
+	default:
+	}
+	switch b.cfg.Overflow {
+	case OverflowDropNewest:
+		if p.done != nil {
+			// A durable payload is refused rather than silently dropped.
+			b.rejected.Add(1)
+			return ErrBufferFull
+		}
+		b.dropped.Add(1)
+		return nil
+	case OverflowDropOldest:
+		for {
+			select {
+			case old := <-b.ch:
+				b.dropped.Add(1)
+				if old.done != nil {
+					old.done <- ErrBufferFull
+				}
+			default:
+			}
+			select {
+			case b.ch <- p:
+				return nil
+			default:
+			}
+		}
+	case OverflowReject:
+		b.rejected.Add(1)
+		return ErrBufferFull
+	}
+	t := time.NewTimer(b.cfg.BlockTimeout)
+	defer t.Stop()
+	select {
+	case b.ch <- p:
+		return nil
+	case <-t.C:
+		b.rejected.Add(1)
+		return ErrBufferTimeout
+	}
+}
+
+func (b *BoundedBuffer) run() {
+	defer close(b.done)
+	for q := range b.ch {
+		ctx := context.Background()
+		if q.sc.IsValid() {
+			ctx = trace.ContextWithSpanContext(ctx, q.sc)
+		}
+		if q.done != nil {
+			ctx = WithDurable(ctx)
+		}
+		err := InsertContext(ctx, b.next, q.p)
+		if err != nil {
+			log.Printf("[buffer] insert: %v", err)
+		}
+		if q.done != nil {
+			q.done <- err
+		}
+	}
+}
+
+// Stats reports the queue length and the overflow counters.
+func (b *BoundedBuffer) Stats() BoundedBufferStats {
+	return BoundedBufferStats{
+		Capacity: b.cfg.Capacity,
+		Overflow: b.cfg.Overflow,
+		Queued:   len(b.ch),
+		Dropped:  b.dropped.Load(),
+		Rejected: b.rejected.Load(),
+	}
+}
+
+// Close rejects further inserts and returns once every queued payload was handed to Next.
+func (b *BoundedBuffer) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.ch)
+	b.mu.Unlock()
+	<-b.done
+}
@@ -0,0 +1,152 @@
+package inputs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateBuffer blocks every Insert until release is closed, simulating a stalled backend.
+type gateBuffer struct {
+	release chan struct{}
+	mu      sync.Mutex
+	msgs    []string
+}
+
+func (b *gateBuffer) Insert(p []byte) error {
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, string(p))
+	return nil
+}
+
+// fill inserts until the stalled run goroutine holds one payload and the queue is full.
+func fill(t *testing.T, b *BoundedBuffer, payloads ...string) {
+	t.Helper()
+	for _, p := range payloads {
+		if err := b.Insert([]byte(p)); err != nil {
+			t.Fatalf("insert %s: %v", p, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBoundedBufferReject(t *testing.T) {
+	next := &gateBuffer{release: make(chan struct{})}
+	b, err := NewBoundedBuffer(BoundedBufferConfig{Capacity: 1, Overflow: OverflowReject}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, b, "a", "b")
+	if err := b.Insert([]byte("c")); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("err = %v, want ErrBufferFull", err)
+	}
+	if st := b.Stats(); st.Rejected != 1 || st.Queued != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	close(next.release)
+	b.Close()
+	if len(next.msgs) != 2 {
+		t.Fatalf("delivered %v, want a and b", next.msgs)
+	}
+	if err := b.Insert([]byte("d")); !errors.Is(err, ErrBufferClosed) {
+		t.Fatalf("after close err = %v, want ErrBufferClosed", err)
+	}
+}
+
+func TestBoundedBufferDropOldest(t *testing.T) {
+	next := &gateBuffer{release: make(chan struct{})}
+	b, err := NewBoundedBuffer(BoundedBufferConfig{Capacity: 1, Overflow: OverflowDropOldest}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, b, "a", "b", "c")
+	close(next.release)
+	b.Close()
+	if got := next.msgs; len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Fatalf("delivered %v, want [a c]", got)
+	}
+	if st := b.Stats(); st.Dropped != 1 {
+		t.Fatalf("dropped = %d, want 1", st.Dropped)
+	}
+}
+
+func TestBoundedBufferBlockTimesOut(t *testing.T) {
+	next := &gateBuffer{release: make(chan struct{})}
+	b, err := NewBoundedBuffer(BoundedBufferConfig{Capacity: 1, BlockTimeout: 20 * time.Millisecond}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, b, "a", "b")
+	err = b.Insert([]byte("c"))
+	if !errors.Is(err, ErrBufferTimeout) {
+		t.Fatalf("err = %v, want ErrBufferTimeout", err)
+	}
+	if BackpressureStatus(err) != 429 || BackpressureStatus(ErrBufferFull) != 503 {
+		t.Fatal("unexpected backpressure status")
+	}
+	close(next.release)
+	b.Close()
+}
+
+func TestBoundedBufferInvalidOverflow(t *testing.T) {
+	if _, err := NewBoundedBuffer(BoundedBufferConfig{Overflow: "spill"}, &gateBuffer{}); err == nil {
+		t.Fatal("expected error for unknown overflow policy")
+	}
+}
+
+// ctxBuffer records whether each insert was durable and fails the durable ones with err.
+type ctxBuffer struct {
+	err     error
+	mu      sync.Mutex
+	durable []bool
+}
+
+func (b *ctxBuffer) Insert(p []byte) error { return b.InsertContext(context.Background(), p) }
+
+func (b *ctxBuffer) InsertContext(ctx context.Context, p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.durable = append(b.durable, Durable(ctx))
+	if Durable(ctx) {
+		return b.err
+	}
+	return nil
+}
+
+func TestBoundedBufferDurableWaitsForNext(t *testing.T) {
+	next := &ctxBuffer{err: ErrNotDurable}
+	b, err := NewBoundedBuffer(BoundedBufferConfig{Capacity: 4}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.InsertContext(context.Background(), []byte("a")); err != nil {
+		t.Fatalf("accepted insert: %v", err)
+	}
+	if err := b.InsertContext(WithDurable(context.Background()), []byte("b")); !errors.Is(err, ErrNotDurable) {
+		t.Fatalf("durable insert err = %v, want Next's ErrNotDurable", err)
+	}
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	if len(next.durable) != 2 || next.durable[0] || !next.durable[1] {
+		t.Fatalf("durable flags seen by Next = %v, want [false true]", next.durable)
+	}
+}
+
+func TestBoundedBufferDurableNotDropped(t *testing.T) {
+	next := &gateBuffer{release: make(chan struct{})}
+	b, err := NewBoundedBuffer(BoundedBufferConfig{Capacity: 1, Overflow: OverflowDropNewest}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, b, "a", "b")
+	if err := b.InsertContext(WithDurable(context.Background()), []byte("c")); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("err = %v, want ErrBufferFull", err)
+	}
+	close(next.release)
+	b.Close()
+}
@@ -1,7 +1,52 @@
 package inputs
 
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
 // InputBuffer receives raw log payloads from inputs.
 // The backend provides an implementation (e.g. in-memory or persistence).
+//
+// Insert returns an error only when the payload was not taken because the backend cannot keep
+// up or is shutting down (ErrBufferFull, ErrBufferTimeout, ErrBufferClosed). Payloads that are
+// taken but turn out invalid are handled downstream and return nil. Inputs that can push back
+// (HTTP status, withheld acks) should do so on error; BackpressureStatus maps it to HTTP.
 type InputBuffer interface {
-	Insert([]byte)
+	Insert([]byte) error
+}
+
+// ContextBuffer is an InputBuffer that also takes the context of the request or message a
+// payload came with, so the payload's trace continues through it.
+type ContextBuffer interface {
+	InputBuffer
+	InsertContext(ctx context.Context, p []byte) error
+}
+
+// InsertContext inserts p into b with ctx when b is a ContextBuffer, and with Insert otherwise.
+func InsertContext(ctx context.Context, b InputBuffer, p []byte) error {
+	if cb, ok := b.(ContextBuffer); ok {
+		return cb.InsertContext(ctx, p)
+	}
+	return b.Insert(p)
+}
+
+var (
+	// ErrBufferFull is returned by a BoundedBuffer with the reject policy when it is full.
+	ErrBufferFull = errors.New("buffer full")
+	// ErrBufferTimeout is returned by a BoundedBuffer with the block policy when no space
+	// became free within its block timeout.
+	ErrBufferTimeout = errors.New("buffer full: timed out waiting for space")
+	// ErrBufferClosed is returned after a BoundedBuffer is closed.
+	ErrBufferClosed = errors.New("buffer closed")
+)
+
+// BackpressureStatus returns the HTTP status for an Insert error: 429 when the caller should
+// slow down (ErrBufferTimeout) and 503 otherwise. HTTP inputs send it with Retry-After: 1.
+func BackpressureStatus(err error) int {
+	if errors.Is(err, ErrBufferTimeout) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
 }
@@ -0,0 +1,16 @@
+package inputs
+
+import "context"
+
+// CheckpointStore persists per-input progress (e.g. the last processed object key) so
+// polling inputs resume where they stopped after a restart. The backend provides a
+// Postgres implementation via Registry.SetCheckpointStore.
+type CheckpointStore interface {
+	GetCheckpoint(ctx context.Context, key string) (string, error)
+	SetCheckpoint(ctx context.Context, key, value string) error
+}
+
+// CheckpointAware is implemented by factories whose inputs need a CheckpointStore.
+type CheckpointAware interface {
+	SetCheckpointStore(CheckpointStore)
+}
@@ -0,0 +1,62 @@
+package cloudwatchinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates CloudWatch Logs subscription inputs. Registers as "cloudwatch".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "cloudwatch"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "cloudwatch",
+		Description: "Receives CloudWatch Logs subscription filter payloads (gzipped, base64) on its own port, either as a Kinesis Data Firehose HTTP endpoint destination or forwarded by a Lambda/Kinesis consumer, and expands them into one entry per log event.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":9030"},
+			{Name: "path", Type: "string", Required: false, Description: "Path served on the listen port", Example: "/cloudwatch"},
+			{Name: "access_key", Type: "string", Required: false, Description: "Firehose access key expected in X-Amz-Firehose-Access-Key (or Authorization: Bearer); empty disables the check"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for events; defaults to the log group name", Example: "lambda-orders"},
+		},
+	}
+}
+
+// ValidateConfig validates cloudwatch input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{Listen: str("listen"), Path: str("path"), AccessKey: str("access_key"), Service: str("service")}
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :9030)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :9030 or 0.0.0.0:9030)")
+	}
+	c.Path = "/" + strings.Trim(c.Path, "/")
+	if c.Path == "/" {
+		c.Path = "/cloudwatch"
+	}
+	return c, nil
+}
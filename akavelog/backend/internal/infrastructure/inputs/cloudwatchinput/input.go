@@ -0,0 +1,219 @@
+package cloudwatchinput
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const maxRequestBody = 64 << 20 // Firehose HTTP endpoint buffers top out at 64MB
+
+// Config holds the parsed settings of a cloudwatch input.
+type Config struct {
+	Listen    string
+	Path      string
+	AccessKey string // empty = no check
+	Service   string // empty = use the log group
+}
+
+// Input accepts CloudWatch Logs subscription deliveries over HTTP.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	buffer inputs.InputBuffer
+	server *http.Server
+}
+
+// NewInput creates a cloudwatch input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer}
+}
+
+// firehoseResponse is the body Firehose expects back; a non-200 status makes it retry.
+type firehoseResponse struct {
+	RequestID    string `json:"requestId"`
+	Timestamp    int64  `json:"timestamp"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.cfg.Path, func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Amz-Firehose-Request-Id")
+		fail := func(status int, msg string) {
+			writeJSON(w, status, firehoseResponse{RequestID: requestID, Timestamp: time.Now().UnixMilli(), ErrorMessage: msg})
+		}
+		if r.Method != http.MethodPost {
+			fail(http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !i.authorized(r) {
+			fail(http.StatusUnauthorized, "invalid access key")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+		if err != nil {
+			fail(http.StatusBadRequest, "read error")
+			return
+		}
+		if len(body) > maxRequestBody {
+			fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxRequestBody))
+			return
+		}
+		records, firehoseID, err := splitBody(body)
+		if err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		if requestID == "" {
+			requestID = firehoseID
+		}
+		var entries [][]byte
+		budget := int64(maxDecompressed)
+		for _, rec := range records {
+			payloads, err := decodeRecord(rec, &budget)
+			if errors.Is(err, errTooLarge) {
+				fail(http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			if err != nil {
+				fail(http.StatusBadRequest, err.Error())
+				return
+			}
+			for _, p := range payloads {
+				entries = append(entries, i.expand(p)...)
+			}
+		}
+		for _, raw := range entries {
+			if err := i.buffer.Insert(raw); err != nil {
+				// Firehose retries the whole request when the endpoint answers with an error;
+				// the entries already stored carry their event ID as _id, so they are dropped
+				// as duplicates then.
+				w.Header().Set("Retry-After", "1")
+				fail(inputs.BackpressureStatus(err), err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, firehoseResponse{RequestID: requestID, Timestamp: time.Now().UnixMilli()})
+	})
+	return mux
+}
+
+func (i *Input) authorized(r *http.Request) bool {
+	if i.cfg.AccessKey == "" {
+		return true
+	}
+	key := r.Header.Get("X-Amz-Firehose-Access-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(i.cfg.AccessKey)) == 1
+}
+
+// splitBody returns the encoded records in a request. Accepted shapes: a Firehose delivery
+// ({"records":[{"data":...}]}), a Lambda subscription event ({"awslogs":{"data":...}}),
+// or a bare gzip / base64 payload as read from a Kinesis stream.
+func splitBody(body []byte) ([][]byte, string, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return [][]byte{trimmed}, "", nil
+	}
+	var env struct {
+		firehoseRequest
+		AWSLogs *struct {
+			Data string `json:"data"`
+		} `json:"awslogs"`
+	}
+	if err := json.Unmarshal(trimmed, &env); err != nil {
+		return nil, "", err
+	}
+	if env.AWSLogs != nil {
+		return [][]byte{[]byte(env.AWSLogs.Data)}, "", nil
+	}
+	out := make([][]byte, 0, len(env.Records))
+	for _, r := range env.Records {
+		out = append(out, []byte(r.Data))
+	}
+	return out, env.RequestID, nil
+}
+
+// expand produces one entry per log event. CONTROL_MESSAGE payloads (sent by CloudWatch to
+// check the destination is reachable) carry no log data and are skipped.
+func (i *Input) expand(p subscriptionPayload) [][]byte {
+	if p.MessageType == "CONTROL_MESSAGE" {
+		return nil
+	}
+	service := i.cfg.Service
+	if service == "" {
+		service = p.LogGroup
+	}
+	out := make([][]byte, 0, len(p.LogEvents))
+	for _, ev := range p.LogEvents {
+		var entry model.LogEntry
+		var record map[string]any
+		if text := strings.TrimSpace(ev.Message); strings.HasPrefix(text, "{") && json.Unmarshal([]byte(text), &record) == nil {
+			entry = inputs.RecordToEntry(record, service)
+		} else {
+			entry = model.LogEntry{Service: service, Level: "info", Message: strings.TrimRight(ev.Message, "\n"), Tags: make(map[string]string)}
+		}
+		entry.Timestamp = time.UnixMilli(ev.Timestamp).UTC().Format(time.RFC3339Nano)
+		entry.Tags["log_group"] = p.LogGroup
+		entry.Tags["log_stream"] = p.LogStream
+		entry.Tags["aws_account"] = p.Owner
+		entry.Tags["cloudwatch_event_id"] = ev.ID
+		if entry.ID == "" {
+			entry.ID = ev.ID // unique per event, so a resent request is not stored twice
+		}
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		out = append(out, raw)
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.cfg.Listen,
+		Handler: i.Handler(),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[cloudwatch] listener %s: %v", i.cfg.Listen, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.cfg.Listen, err))
+		}
+	}()
+	log.Printf("[cloudwatch] listening on %s%s", i.cfg.Listen, i.cfg.Path)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
@@ -0,0 +1,145 @@
+package cloudwatchinput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func encodePayload(t *testing.T, p subscriptionPayload) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(p); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func newTestInput(t *testing.T, cfg inputs.Config) (*Input, *memBuffer) {
+	t.Helper()
+	cfg["listen"] = ":0"
+	c, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &memBuffer{}
+	return NewInput(c, buf), buf
+}
+
+func TestFirehoseDelivery(t *testing.T) {
+	in, buf := newTestInput(t, inputs.Config{"access_key": "k"})
+	data := encodePayload(t, subscriptionPayload{
+		MessageType: "DATA_MESSAGE",
+		Owner:       "123456789012",
+		LogGroup:    "/aws/lambda/orders",
+		LogStream:   "2024/01/01/[$LATEST]abc",
+		LogEvents: []logEvent{
+			{ID: "1", Timestamp: 1700000000000, Message: "START RequestId: x\n"},
+			{ID: "2", Timestamp: 1700000000001, Message: `{"level":"error","message":"boom"}`},
+		},
+	})
+	control := encodePayload(t, subscriptionPayload{MessageType: "CONTROL_MESSAGE"})
+	body, _ := json.Marshal(map[string]any{"requestId": "req-1", "records": []map[string]string{{"data": data}, {"data": control}}})
+
+	req := httptest.NewRequest(http.MethodPost, "/cloudwatch", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing key: status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/cloudwatch", bytes.NewReader(body))
+	req.Header.Set("X-Amz-Firehose-Access-Key", "k")
+	rec = httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"requestId":"req-1"`) {
+		t.Fatalf("status = %d body = %s", rec.Code, rec.Body)
+	}
+	if len(buf.msgs) != 2 {
+		t.Fatalf("got %d entries, want 2", len(buf.msgs))
+	}
+	var first, second model.LogEntry
+	_ = json.Unmarshal(buf.msgs[0], &first)
+	_ = json.Unmarshal(buf.msgs[1], &second)
+	if first.Service != "/aws/lambda/orders" || first.Message != "START RequestId: x" || first.Tags["log_stream"] != "2024/01/01/[$LATEST]abc" {
+		t.Fatalf("first: %+v", first)
+	}
+	if second.Level != "error" || second.Message != "boom" || second.Timestamp != "2023-11-14T22:13:20.001Z" {
+		t.Fatalf("second: %+v", second)
+	}
+}
+
+func TestLambdaEnvelope(t *testing.T) {
+	in, buf := newTestInput(t, inputs.Config{"service": "orders"})
+	data := encodePayload(t, subscriptionPayload{MessageType: "DATA_MESSAGE", LogGroup: "g", LogEvents: []logEvent{{ID: "1", Message: "hi"}}})
+	body := `{"awslogs":{"data":"` + data + `"}}`
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cloudwatch", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || len(buf.msgs) != 1 || !strings.Contains(string(buf.msgs[0]), `"service":"orders"`) {
+		t.Fatalf("status = %d entries = %d", rec.Code, len(buf.msgs))
+	}
+}
+
+func TestEventIDIsEntryID(t *testing.T) {
+	in, buf := newTestInput(t, inputs.Config{})
+	data := encodePayload(t, subscriptionPayload{MessageType: "DATA_MESSAGE", LogGroup: "g", LogEvents: []logEvent{
+		{ID: "36", Message: "plain"},
+		{ID: "37", Message: `{"_id":"own","message":"json"}`},
+	}})
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cloudwatch", strings.NewReader(data)))
+	if rec.Code != http.StatusOK || len(buf.msgs) != 2 {
+		t.Fatalf("status = %d entries = %d", rec.Code, len(buf.msgs))
+	}
+	var first, second model.LogEntry
+	_ = json.Unmarshal(buf.msgs[0], &first)
+	_ = json.Unmarshal(buf.msgs[1], &second)
+	if first.ID != "36" || second.ID != "own" {
+		t.Errorf("_id = %q, %q; want 36, own", first.ID, second.ID)
+	}
+}
+
+func TestDecodeBudget(t *testing.T) {
+	data := encodePayload(t, subscriptionPayload{MessageType: "DATA_MESSAGE", LogEvents: []logEvent{{ID: "1", Message: strings.Repeat("x", 1000)}}})
+	budget := int64(1500)
+	if _, err := decodeRecord([]byte(data), &budget); err != nil {
+		t.Fatal(err)
+	}
+	// The second record fits on its own but not in what the first one left.
+	if _, err := decodeRecord([]byte(data), &budget); err != errTooLarge {
+		t.Errorf("second record: err = %v, want errTooLarge", err)
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	in, buf := newTestInput(t, inputs.Config{})
+	body := bytes.Repeat([]byte("a"), maxRequestBody+1)
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cloudwatch", bytes.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge || len(buf.msgs) != 0 {
+		t.Errorf("status = %d entries = %d", rec.Code, len(buf.msgs))
+	}
+}
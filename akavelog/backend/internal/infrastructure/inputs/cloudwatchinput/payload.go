@@ -0,0 +1,85 @@
+package cloudwatchinput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const maxDecompressed = 64 << 20 // decoded bytes of all records in a request; guards against gzip bombs
+
+// errTooLarge is returned when the records of a request decode to more than maxDecompressed bytes.
+var errTooLarge = fmt.Errorf("records exceed %d bytes once decoded", maxDecompressed)
+
+// subscriptionPayload is what a CloudWatch Logs subscription filter delivers, after base64
+// decoding and gunzipping.
+type subscriptionPayload struct {
+	MessageType         string     `json:"messageType"` // DATA_MESSAGE or CONTROL_MESSAGE
+	Owner               string     `json:"owner"`
+	LogGroup            string     `json:"logGroup"`
+	LogStream           string     `json:"logStream"`
+	SubscriptionFilters []string   `json:"subscriptionFilters"`
+	LogEvents           []logEvent `json:"logEvents"`
+}
+
+type logEvent struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"` // epoch ms
+	Message   string `json:"message"`
+}
+
+// firehoseRequest is the Kinesis Data Firehose HTTP endpoint delivery body.
+type firehoseRequest struct {
+	RequestID string `json:"requestId"`
+	Timestamp int64  `json:"timestamp"`
+	Records   []struct {
+		Data string `json:"data"`
+	} `json:"records"`
+}
+
+// decodeRecord turns one record (base64 and/or gzip encoded) into a subscription payload.
+// Firehose can concatenate several gzip members into one record; each member is decoded.
+// *budget is the number of decoded bytes the request may still use; the record's are taken
+// from it, and errTooLarge is returned when they do not fit.
+func decodeRecord(data []byte, budget *int64) ([]subscriptionPayload, error) {
+	data = bytes.TrimSpace(data)
+	if !isGzip(data) {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("record is neither gzip nor base64: %w", err)
+		}
+		data = decoded
+	}
+	if isGzip(data) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		data, err = io.ReadAll(io.LimitReader(zr, *budget+1))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+	}
+	if int64(len(data)) > *budget {
+		return nil, errTooLarge
+	}
+	*budget -= int64(len(data))
+	var out []subscriptionPayload
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var p subscriptionPayload
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("decode subscription payload: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func isGzip(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
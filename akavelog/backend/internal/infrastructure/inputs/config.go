@@ -1,5 +1,92 @@
 package inputs
 
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+// InputIDKey is set in the Config of a persisted input to its ID, so inputs that keep state
+// elsewhere (a broker session, a consumer name) can name it after the input.
+const InputIDKey = "input_id"
+
 // Config is a key-value map for input-type-specific configuration.
 // The backend passes it when creating an input; implementations interpret it.
 type Config map[string]any
+
+// Resolved returns a copy of c with its env://, file:// and vault:// references replaced by
+// the secrets they name. Configs are stored with their references and resolved when used.
+func (c Config) Resolved() (Config, error) {
+	return secrets.ResolveMap(context.Background(), c)
+}
+
+// Int returns cfg[key] as an int. JSON numbers decode as float64; ints and numeric strings are accepted too.
+func (c Config) Int(key string) (int, bool) {
+	switch v := c[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Float returns cfg[key] as a float64. Accepts JSON numbers, ints and numeric strings.
+func (c Config) Float(key string) (float64, bool) {
+	switch v := c[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Bool returns cfg[key] as a bool. Accepts JSON booleans and "true"/"false" strings.
+func (c Config) Bool(key string) (bool, bool) {
+	switch v := c[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// Strings returns cfg[key] as a list. Accepts a JSON array of strings or a comma-separated string;
+// empty items are dropped.
+func (c Config) Strings(key string) []string {
+	var raw []string
+	switch v := c[key].(type) {
+	case string:
+		raw = strings.Split(v, ",")
+	case []string:
+		raw = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	}
+	out := make([]string, 0, len(raw))
+	for _, s := range raw {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package datadoginput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates Datadog logs intake compatible inputs. Registers as "datadog".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "datadog"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "datadog",
+		Description: "Datadog logs intake API (/api/v2/logs) on its own port, so Datadog agents, Vector's datadog_logs sink and Datadog libraries can ship here via a custom intake URL. ddsource, ddtags, service, status and hostname are mapped onto entry fields.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":9050"},
+			{Name: "api_keys", Type: "string", Required: false, Description: "Comma-separated accepted DD-API-KEY values; empty accepts any key"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name when a log has none (default datadog)", Example: "datadog"},
+		},
+	}
+}
+
+// ValidateConfig validates datadog input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	listen, _ := cfg["listen"].(string)
+	if strings.TrimSpace(listen) == "" {
+		return fmt.Errorf("listen is required: each input must have its own port (e.g. :9050)")
+	}
+	if _, _, err := net.SplitHostPort(strings.TrimSpace(listen)); err != nil {
+		return fmt.Errorf("listen must be host:port or :port (e.g. :9050 or 0.0.0.0:9050)")
+	}
+	return nil
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	if err := f.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	listen, _ := cfg["listen"].(string)
+	service, _ := cfg["service"].(string)
+	if service == "" {
+		service = "datadog"
+	}
+	return NewInput(strings.TrimSpace(listen), cfg.Strings("api_keys"), service, buffer), nil
+}
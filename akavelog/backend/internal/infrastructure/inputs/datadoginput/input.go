@@ -0,0 +1,194 @@
+package datadoginput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/tracing"
+)
+
+// maxRequestBody matches the intake's 5MB uncompressed payload limit.
+const maxRequestBody = 5 << 20
+
+// Input serves the Datadog logs intake API and writes logs to an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	listenAddr string
+	apiKeys    []string
+	service    string
+	buffer     inputs.InputBuffer
+	server     *http.Server
+}
+
+// NewInput creates a Datadog intake input. Empty apiKeys accepts any key.
+func NewInput(listenAddr string, apiKeys []string, service string, buffer inputs.InputBuffer) *Input {
+	return &Input{listenAddr: listenAddr, apiKeys: apiKeys, service: service, buffer: buffer}
+}
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/validate", func(w http.ResponseWriter, r *http.Request) {
+		if !i.validKey(apiKey(r)) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"Forbidden"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"valid": true})
+	})
+	logs := http.HandlerFunc(i.handleLogs)
+	mux.Handle("/api/v2/logs", logs)
+	mux.Handle("/v1/input", logs)
+	mux.Handle("/v1/input/", logs) // legacy intake: /v1/input/<api key>
+	return mux
+}
+
+// apiKey reads the key from the DD-API-KEY header, the dd-api-key query parameter,
+// or the legacy /v1/input/<key> path.
+func apiKey(r *http.Request) string {
+	if k := r.Header.Get("DD-API-KEY"); k != "" {
+		return k
+	}
+	if k := r.URL.Query().Get("dd-api-key"); k != "" {
+		return k
+	}
+	return strings.TrimPrefix(r.URL.Path, "/v1/input/")
+}
+
+func (i *Input) validKey(key string) bool {
+	if len(i.apiKeys) == 0 {
+		return true
+	}
+	valid := false
+	for _, k := range i.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (i *Input) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !i.validKey(apiKey(r)) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"Forbidden"}})
+		return
+	}
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid gzip body"}})
+			return
+		}
+		defer zr.Close()
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid deflate body"}})
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxRequestBody+1))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"read error"}})
+		return
+	}
+	if len(data) > maxRequestBody {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"errors": []string{"payload too large"}})
+		return
+	}
+	records, err := decodeLogs(data, strings.HasPrefix(r.Header.Get("Content-Type"), "application/json"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+		return
+	}
+	for _, rec := range records {
+		raw, err := json.Marshal(i.toEntry(rec, r.URL.Query()))
+		if err != nil {
+			continue
+		}
+		if err := inputs.InsertContext(r.Context(), i.buffer, raw); err != nil {
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, inputs.BackpressureStatus(err), map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+	}
+	// The intake answers 202 with an empty object.
+	writeJSON(w, http.StatusAccepted, map[string]any{})
+}
+
+// decodeLogs accepts a JSON array, a single JSON object, or (non-JSON content types)
+// one plain-text log per line.
+func decodeLogs(data []byte, isJSON bool) ([]map[string]any, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	switch {
+	case trimmed[0] == '[':
+		var arr []map[string]any
+		if err := json.Unmarshal(trimmed, &arr); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	case trimmed[0] == '{':
+		var obj map[string]any
+		if err := json.Unmarshal(trimmed, &obj); err != nil {
+			return nil, err
+		}
+		return []map[string]any{obj}, nil
+	case isJSON:
+		return nil, fmt.Errorf("invalid JSON payload")
+	}
+	var out []map[string]any
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, map[string]any{"message": line})
+		}
+	}
+	return out, nil
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.listenAddr,
+		Handler: tracing.Handler("ingest.datadog", i.Handler()),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[datadog] listener %s: %v", i.listenAddr, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.listenAddr, err))
+		}
+	}()
+	log.Printf("[datadog] listening on %s", i.listenAddr)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
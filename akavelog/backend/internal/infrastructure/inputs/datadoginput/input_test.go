@@ -0,0 +1,80 @@
+package datadoginput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func TestLogsIntake(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(":0", []string{"key1"}, "datadog", buf)
+	payload := `[{"message":"payment failed","status":"CRITICAL","service":"billing","ddsource":"python","ddtags":"env:prod,version:1.2,canary","hostname":"i-123"},{"message":"ok"}]`
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(payload))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/logs", bytes.NewReader(gz.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("without key: status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/logs", bytes.NewReader(gz.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("DD-API-KEY", "key1")
+	rec = httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d body = %s", rec.Code, rec.Body)
+	}
+	if len(buf.msgs) != 2 {
+		t.Fatalf("got %d entries, want 2", len(buf.msgs))
+	}
+	var e model.LogEntry
+	_ = json.Unmarshal(buf.msgs[0], &e)
+	if e.Service != "billing" || e.Level != "fatal" || e.Tags["env"] != "prod" || e.Tags["canary"] != "true" ||
+		e.Tags["ddsource"] != "python" || e.Tags["hostname"] != "i-123" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	_ = json.Unmarshal(buf.msgs[1], &e)
+	if e.Service != "datadog" || e.Level != "info" {
+		t.Fatalf("defaults: %+v", e)
+	}
+}
+
+func TestPlainTextWithQueryParams(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(":0", nil, "datadog", buf)
+	req := httptest.NewRequest(http.MethodPost, "/v1/input/anykey?ddsource=nginx&service=web", strings.NewReader("line one\nline two\n"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted || len(buf.msgs) != 2 || !strings.Contains(string(buf.msgs[1]), `"service":"web"`) {
+		t.Fatalf("status=%d entries=%d", rec.Code, len(buf.msgs))
+	}
+}
@@ -0,0 +1,70 @@
+package datadoginput
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// toEntry maps a Datadog log onto a LogEntry. "status" is Datadog's level; "ddtags"
+// ("k:v,k2:v2") becomes individual tags; ddsource and hostname are kept as tags.
+// Query parameters (ddsource, service, hostname, ddtags) apply when the log lacks them,
+// as the intake does for plain-text submissions.
+func (i *Input) toEntry(rec map[string]any, q url.Values) model.LogEntry {
+	for _, k := range []string{"ddsource", "service", "hostname", "ddtags"} {
+		if _, ok := rec[k]; !ok && q.Get(k) != "" {
+			rec[k] = q.Get(k)
+		}
+	}
+	if status, ok := rec["status"]; ok {
+		if _, hasLevel := rec["level"]; !hasLevel {
+			rec["level"] = status
+		}
+		delete(rec, "status")
+	}
+	ddtags, _ := rec["ddtags"].(string)
+	delete(rec, "ddtags")
+	entry := inputs.RecordToEntry(rec, i.service)
+	entry.Level = normalizeStatus(entry.Level)
+	for _, t := range strings.Split(ddtags, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(t, ":")
+		if !ok {
+			v = "true"
+		}
+		entry.Tags[k] = v
+	}
+	return entry
+}
+
+// normalizeStatus maps Datadog/syslog status names onto the levels used elsewhere.
+func normalizeStatus(s string) string {
+	switch strings.ToLower(s) {
+	case "emerg", "emergency", "alert", "critical", "crit", "fatal":
+		return "fatal"
+	case "error", "err":
+		return "error"
+	case "warning", "warn":
+		return "warn"
+	case "notice", "info", "ok", "success":
+		return "info"
+	case "debug", "trace":
+		return "debug"
+	case "":
+		return "info"
+	}
+	return strings.ToLower(s)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
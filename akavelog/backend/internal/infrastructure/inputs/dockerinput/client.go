@@ -0,0 +1,193 @@
+package dockerinput
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is the oldest Docker Engine API version providing everything used here.
+const apiVersion = "v1.41"
+
+// client is a minimal Docker Engine API client over a unix socket or TCP.
+type client struct {
+	http *http.Client
+	base string
+}
+
+type container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"` // running, exited, ...
+	Labels map[string]string `json:"Labels"`
+}
+
+// Name returns the container name without the leading slash.
+func (c container) Name() string {
+	if len(c.Names) == 0 {
+		return shortID(c.ID)
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func newClient(socket string) *client {
+	if strings.HasPrefix(socket, "tcp://") || strings.HasPrefix(socket, "http://") {
+		base := "http://" + strings.TrimPrefix(strings.TrimPrefix(socket, "tcp://"), "http://")
+		return &client{http: &http.Client{}, base: strings.TrimSuffix(base, "/") + "/" + apiVersion}
+	}
+	path := strings.TrimPrefix(socket, "unix://")
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+	return &client{http: &http.Client{Transport: tr}, base: "http://docker/" + apiVersion}
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// listContainers returns the containers matching all label filters, stopped ones included.
+func (c *client) listContainers(ctx context.Context, labels []string) ([]container, error) {
+	q := url.Values{"all": {"1"}}
+	if len(labels) > 0 {
+		filters, _ := json.Marshal(map[string][]string{"label": labels})
+		q.Set("filters", string(filters))
+	}
+	resp, err := c.get(ctx, "/containers/json", q)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out []container
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode container list: %w", err)
+	}
+	return out, nil
+}
+
+// hasTTY reports whether the container was started with a TTY (its log stream is then not multiplexed).
+func (c *client) hasTTY(ctx context.Context, id string) (bool, error) {
+	resp, err := c.get(ctx, "/containers/"+id+"/json", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Config struct {
+			Tty bool `json:"Tty"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, fmt.Errorf("decode container inspect: %w", err)
+	}
+	return info.Config.Tty, nil
+}
+
+// followLogs opens a following log stream with timestamps, starting at since.
+func (c *client) followLogs(ctx context.Context, id string, stdout, stderr bool, since time.Time) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("follow", "1")
+	q.Set("timestamps", "1")
+	q.Set("stdout", strconv.FormatBool(stdout))
+	q.Set("stderr", strconv.FormatBool(stderr))
+	q.Set("since", strconv.FormatFloat(float64(since.UnixNano())/1e9, 'f', 9, 64))
+	resp, err := c.get(ctx, "/containers/"+id+"/logs", q)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// logLine is one line of container output.
+type logLine struct {
+	Stream string
+	Time   time.Time
+	Text   string
+}
+
+// readLogStream calls fn for each line. Non-TTY streams use Docker's multiplexed framing:
+// an 8-byte header (stream type, 3 zero bytes, big-endian payload length) per chunk.
+func readLogStream(r io.Reader, tty bool, fn func(logLine)) error {
+	if tty {
+		return scanLines(r, "stdout", fn)
+	}
+	br := bufio.NewReader(r)
+	partial := map[string]string{}
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return err
+		}
+		stream := "stdout"
+		if hdr[0] == 2 {
+			stream = "stderr"
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(hdr[4:]))
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return err
+		}
+		data := partial[stream] + string(payload)
+		for {
+			idx := strings.IndexByte(data, '\n')
+			if idx < 0 {
+				break
+			}
+			fn(parseLine(stream, data[:idx]))
+			data = data[idx+1:]
+		}
+		partial[stream] = data
+	}
+}
+
+func scanLines(r io.Reader, stream string, fn func(logLine)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fn(parseLine(stream, sc.Text()))
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// parseLine splits the RFC3339Nano timestamp Docker prefixes when timestamps=1.
+func parseLine(stream, line string) logLine {
+	line = strings.TrimSuffix(line, "\r")
+	if sp := strings.IndexByte(line, ' '); sp > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, line[:sp]); err == nil {
+			return logLine{Stream: stream, Time: ts.UTC(), Text: line[sp+1:]}
+		}
+	}
+	return logLine{Stream: stream, Time: time.Now().UTC(), Text: line}
+}
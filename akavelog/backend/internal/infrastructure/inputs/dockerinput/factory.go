@@ -0,0 +1,81 @@
+package dockerinput
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+const (
+	defaultSocket          = "/var/run/docker.sock"
+	defaultRefreshInterval = 10 * time.Second
+)
+
+// Factory creates container log inputs. Registers as "docker".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "docker"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "docker",
+		Description: "Streams stdout/stderr of containers matching a label selector from the Docker Engine API (Docker or Podman's Docker-compatible socket). Entries are tagged with container name, image, and labels.",
+		Fields: []inputs.ConfigField{
+			{Name: "socket", Type: "string", Required: false, Description: "Unix socket path or tcp://host:port of the Docker API (default /var/run/docker.sock)", Example: "/var/run/docker.sock"},
+			{Name: "label_selector", Type: "string", Required: false, Description: "Comma-separated label filters (key or key=value); empty selects all running containers", Example: "akavelog.enable=true"},
+			{Name: "streams", Type: "string", Required: false, Description: "Which streams to follow: all (default), stdout, or stderr", Example: "all"},
+			{Name: "service_label", Type: "string", Required: false, Description: "Container label whose value is used as service (defaults to the container name)", Example: "com.docker.compose.service"},
+			{Name: "refresh_interval", Type: "string", Required: false, Description: "How often to look for new containers (default 10s)", Example: "10s"},
+		},
+	}
+}
+
+// ValidateConfig validates docker input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	c := Config{Socket: defaultSocket, Stdout: true, Stderr: true, RefreshInterval: defaultRefreshInterval}
+	if v, _ := cfg["socket"].(string); strings.TrimSpace(v) != "" {
+		c.Socket = strings.TrimSpace(v)
+	}
+	if v, _ := cfg["label_selector"].(string); v != "" {
+		for _, sel := range strings.Split(v, ",") {
+			if sel = strings.TrimSpace(sel); sel != "" {
+				c.Labels = append(c.Labels, sel)
+			}
+		}
+	}
+	switch v, _ := cfg["streams"].(string); v {
+	case "", "all":
+	case "stdout":
+		c.Stderr = false
+	case "stderr":
+		c.Stdout = false
+	default:
+		return c, fmt.Errorf("streams must be one of all, stdout, stderr")
+	}
+	c.ServiceLabel, _ = cfg["service_label"].(string)
+	if v, _ := cfg["refresh_interval"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("refresh_interval must be a positive duration (e.g. 10s)")
+		}
+		c.RefreshInterval = d
+	}
+	return c, nil
+}
@@ -0,0 +1,7 @@
+package dockerinput
+
+import "github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+
+func init() {
+	inputs.GlobalRegistry.Register(&Factory{})
+}
@@ -0,0 +1,211 @@
+package dockerinput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Config holds the parsed settings of a docker input.
+type Config struct {
+	Socket          string
+	Labels          []string // label filters: "key" or "key=value"
+	Stdout          bool
+	Stderr          bool
+	ServiceLabel    string
+	RefreshInterval time.Duration
+}
+
+// Input discovers containers by label and streams their logs into an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	client *client
+	buffer inputs.InputBuffer
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	streams  map[string]context.CancelFunc // container ID → stream cancel
+	lastSeen map[string]time.Time          // container ID → last line time, so restarts resume without duplicates; removed containers are pruned
+	wg       sync.WaitGroup
+}
+
+// NewInput creates a docker input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{
+		cfg:      cfg,
+		client:   newClient(cfg.Socket),
+		buffer:   buffer,
+		streams:  make(map[string]context.CancelFunc),
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+// Start verifies the Docker API is reachable, then begins discovery in the background.
+func (i *Input) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	probeCtx, probeCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer probeCancel()
+	if _, err := i.client.listContainers(probeCtx, i.cfg.Labels); err != nil {
+		cancel()
+		return err
+	}
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	i.wg.Add(1)
+	go i.discoverLoop(ctx)
+	log.Printf("[docker] watching containers on %s (labels=%v)", i.cfg.Socket, i.cfg.Labels)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+	i.mu.Unlock()
+	i.wg.Wait()
+	return nil
+}
+
+func (i *Input) discoverLoop(ctx context.Context) {
+	defer i.wg.Done()
+	started := time.Now().UTC()
+	ticker := time.NewTicker(i.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		i.discover(ctx, started)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover starts a stream for every matching running container that is not already
+// followed, and forgets the position of containers that were removed. Stopped containers keep
+// theirs, so a restarted container resumes where it left off. Logs written before the input
+// started are not replayed.
+func (i *Input) discover(ctx context.Context, started time.Time) {
+	list, err := i.client.listContainers(ctx, i.cfg.Labels)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[docker] list containers: %v", err)
+		}
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	exists := make(map[string]bool, len(list))
+	for _, c := range list {
+		exists[c.ID] = true
+	}
+	for id := range i.lastSeen {
+		if !exists[id] {
+			delete(i.lastSeen, id)
+		}
+	}
+	for _, c := range list {
+		if c.State != "running" {
+			continue
+		}
+		if _, ok := i.streams[c.ID]; ok {
+			continue
+		}
+		since, ok := i.lastSeen[c.ID]
+		if !ok {
+			since = started
+		}
+		sctx, cancel := context.WithCancel(ctx)
+		i.streams[c.ID] = cancel
+		i.wg.Add(1)
+		go i.follow(sctx, c, since)
+	}
+}
+
+func (i *Input) follow(ctx context.Context, c container, since time.Time) {
+	defer i.wg.Done()
+	defer func() {
+		i.mu.Lock()
+		if cancel, ok := i.streams[c.ID]; ok {
+			cancel()
+			delete(i.streams, c.ID)
+		}
+		i.mu.Unlock()
+	}()
+	tty, err := i.client.hasTTY(ctx, c.ID)
+	if err != nil {
+		log.Printf("[docker] inspect %s: %v", c.Name(), err)
+		return
+	}
+	body, err := i.client.followLogs(ctx, c.ID, i.cfg.Stdout, i.cfg.Stderr, since)
+	if err != nil {
+		log.Printf("[docker] logs %s: %v", c.Name(), err)
+		return
+	}
+	defer body.Close()
+	log.Printf("[docker] following %s (%s)", c.Name(), c.Image)
+
+	err = readLogStream(body, tty, func(l logLine) {
+		// Docker's since filter has second granularity; skip lines already forwarded.
+		if !l.Time.After(since) {
+			return
+		}
+		i.insert(c, l)
+		i.mu.Lock()
+		i.lastSeen[c.ID] = l.Time
+		i.mu.Unlock()
+	})
+	if err != nil && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+		log.Printf("[docker] stream %s: %v", c.Name(), err)
+	}
+}
+
+func (i *Input) insert(c container, l logLine) {
+	service := c.Name()
+	if i.cfg.ServiceLabel != "" && c.Labels[i.cfg.ServiceLabel] != "" {
+		service = c.Labels[i.cfg.ServiceLabel]
+	}
+	var entry model.LogEntry
+	var record map[string]any
+	if text := strings.TrimSpace(l.Text); strings.HasPrefix(text, "{") && json.Unmarshal([]byte(text), &record) == nil {
+		entry = inputs.RecordToEntry(record, service)
+	} else {
+		entry = model.LogEntry{Service: service, Level: "info", Message: l.Text, Tags: make(map[string]string)}
+	}
+	entry.Timestamp = l.Time.Format(time.RFC3339Nano)
+	entry.Tags["container_id"] = shortID(c.ID)
+	entry.Tags["container_name"] = c.Name()
+	entry.Tags["image"] = c.Image
+	entry.Tags["stream"] = l.Stream
+	for k, v := range c.Labels {
+		entry.Tags["label."+k] = v
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[docker] marshal entry: %v", err)
+		return
+	}
+	if err := i.buffer.Insert(raw); err != nil {
+		log.Printf("[docker] %s: %v", c.Name(), err)
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
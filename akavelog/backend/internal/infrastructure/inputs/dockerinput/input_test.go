@@ -0,0 +1,164 @@
+package dockerinput
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+// frame is one chunk of Docker's multiplexed log stream.
+func frame(stream byte, payload string) []byte {
+	hdr := make([]byte, 8, 8+len(payload))
+	hdr[0] = stream
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(payload)))
+	return append(hdr, payload...)
+}
+
+func TestReadLogStream(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(frame(1, "2024-02-17T12:00:00.000000001Z first\n2024-02-17T12:00:01Z sec"))
+	stream.Write(frame(2, "2024-02-17T12:00:02Z oops\n"))
+	stream.Write(frame(1, "ond\r\n"))
+	var lines []logLine
+	err := readLogStream(&stream, false, func(l logLine) { lines = append(lines, l) })
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+	want := []logLine{
+		{Stream: "stdout", Time: time.Date(2024, 2, 17, 12, 0, 0, 1, time.UTC), Text: "first"},
+		{Stream: "stderr", Time: time.Date(2024, 2, 17, 12, 0, 2, 0, time.UTC), Text: "oops"},
+		{Stream: "stdout", Time: time.Date(2024, 2, 17, 12, 0, 1, 0, time.UTC), Text: "second"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %+v", lines)
+	}
+	for n := range want {
+		if lines[n] != want[n] {
+			t.Errorf("line %d = %+v, want %+v", n, lines[n], want[n])
+		}
+	}
+
+	// A header cut short ends the stream with an error.
+	truncated := frame(1, "2024-02-17T12:00:00Z cut\n")[:20]
+	if err := readLogStream(bytes.NewReader(truncated), false, func(logLine) {}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated frame: %v", err)
+	}
+
+	lines = nil
+	tty := "2024-02-17T12:00:00Z plain\r\nno timestamp\n"
+	if err := readLogStream(strings.NewReader(tty), true, func(l logLine) { lines = append(lines, l) }); !errors.Is(err, io.EOF) {
+		t.Fatalf("tty: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Text != "plain" || lines[1].Text != "no timestamp" || lines[1].Stream != "stdout" {
+		t.Errorf("tty lines = %+v", lines)
+	}
+}
+
+// fakeDocker serves the container list, inspect and a log stream that ends after its lines.
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers []container
+	lines      []string
+	since      []string // since of each logs request
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	switch {
+	case path == "/containers/json":
+		json.NewEncoder(w).Encode(f.containers)
+	case strings.HasSuffix(path, "/json"):
+		w.Write([]byte(`{"Config":{"Tty":false}}`))
+	case strings.HasSuffix(path, "/logs"):
+		f.since = append(f.since, r.URL.Query().Get("since"))
+		for _, l := range f.lines {
+			w.Write(frame(1, l+"\n"))
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDocker) set(state string, lines ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers = nil
+	if state != "" {
+		f.containers = []container{{ID: "c0ffee1234567890", Names: []string{"/web"}, Image: "nginx", State: state}}
+	}
+	f.lines = lines
+}
+
+func TestDiscoverResumes(t *testing.T) {
+	docker := &fakeDocker{}
+	srv := httptest.NewServer(docker)
+	defer srv.Close()
+	buf := &memBuffer{}
+	in := NewInput(Config{Socket: "tcp://" + strings.TrimPrefix(srv.URL, "http://"), Stdout: true, Stderr: true}, buf)
+	ctx := context.Background()
+	started := time.Date(2024, 2, 17, 12, 0, 0, 0, time.UTC)
+	discover := func() {
+		in.discover(ctx, started)
+		in.wg.Wait() // the fake's streams end after their lines
+	}
+
+	docker.set("running", "2024-02-17T11:59:59Z before start", "2024-02-17T12:00:01Z one", "2024-02-17T12:00:02.5Z two")
+	discover()
+	if len(buf.msgs) != 2 {
+		t.Fatalf("stored %d entries, want 2", len(buf.msgs))
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "one" || entry.Service != "web" || entry.Tags["container_id"] != "c0ffee123456" || entry.Tags["stream"] != "stdout" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	// Docker's since has second granularity, so the restarted stream repeats the last line.
+	docker.set("running", "2024-02-17T12:00:02.5Z two", "2024-02-17T12:00:03Z three")
+	discover()
+	if len(buf.msgs) != 3 {
+		t.Fatalf("after resume stored %d entries, want 3", len(buf.msgs))
+	}
+	if got := docker.since[1]; !strings.HasPrefix(got, "1708171202.5") {
+		t.Errorf("resumed with since = %s", got)
+	}
+
+	// A stopped container keeps its position and is not followed; a removed one forgets it.
+	docker.set("exited")
+	discover()
+	if _, ok := in.lastSeen["c0ffee1234567890"]; !ok || len(docker.since) != 2 {
+		t.Errorf("stopped container: lastSeen %v, %d log requests", in.lastSeen, len(docker.since))
+	}
+	docker.set("")
+	discover()
+	if len(in.lastSeen) != 0 {
+		t.Errorf("lastSeen kept a removed container: %v", in.lastSeen)
+	}
+}
@@ -0,0 +1,75 @@
+package inputs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DrainReadTimeout is how long a draining socket input keeps reading what connected clients
+// have already sent before it closes their connections.
+const DrainReadTimeout = time.Second
+
+// DrainableInput is implemented by inputs that can stop gracefully. Drain stops accepting new
+// connections and requests, lets the data being received arrive, then stops like Stop. When
+// ctx is done first, it stops at once.
+type DrainableInput interface {
+	MessageInput
+	Drain(ctx context.Context) error
+}
+
+// ShutdownServer shuts srv down gracefully: it closes its listeners and waits for the
+// requests being served. When ctx is done first, the remaining connections are closed.
+func ShutdownServer(ctx context.Context, srv *http.Server) error {
+	if srv == nil {
+		return nil
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		_ = srv.Close()
+		return err
+	}
+	return nil
+}
+
+// Drainer holds the drain deadline of a socket input. The zero value is not draining.
+type Drainer struct {
+	deadline atomic.Int64 // unix nanoseconds; 0 while not draining
+}
+
+// Begin starts draining and returns the deadline until which connections are read.
+func (d *Drainer) Begin() time.Time {
+	t := time.Now().Add(DrainReadTimeout)
+	d.deadline.Store(t.UnixNano())
+	return t
+}
+
+// Draining reports whether Begin was called.
+func (d *Drainer) Draining() bool {
+	return d.deadline.Load() != 0
+}
+
+// SetReadDeadline sets the deadline of the next read from conn: the drain deadline once
+// draining, otherwise idle from now when idle is positive.
+func (d *Drainer) SetReadDeadline(conn net.Conn, idle time.Duration) {
+	if ns := d.deadline.Load(); ns != 0 {
+		_ = conn.SetReadDeadline(time.Unix(0, ns))
+	} else if idle > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(idle))
+	}
+}
+
+// Wait waits for wg until ctx is done.
+func Wait(ctx context.Context, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,132 @@
+package inputs
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrUnsupportedEncoding is returned by DecodeContent for unknown Content-Encoding values.
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	// ErrContentTooLarge is returned when a body decompresses to more than the allowed size.
+	ErrContentTooLarge = errors.New("decoded content too large")
+)
+
+// DecodeContent undoes the Content-Encoding of body: gzip, deflate, zstd or snappy (block format,
+// as sent by Loki and Prometheus clients). Listed encodings are undone last-applied first; empty
+// and identity leave body unchanged. limit caps the decoded size so small bombs cannot exhaust memory.
+func DecodeContent(encoding string, body []byte, limit int64) ([]byte, error) {
+	codings := strings.Split(encoding, ",")
+	for n := len(codings) - 1; n >= 0; n-- {
+		var err error
+		body, err = decodeOne(strings.ToLower(strings.TrimSpace(codings[n])), body, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func decodeOne(coding string, body []byte, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch coding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		// Content-Encoding deflate is zlib-wrapped per RFC 9110, but some clients send raw deflate.
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			fr := flate.NewReader(bytes.NewReader(body))
+			defer fr.Close()
+			r = fr
+		} else {
+			defer zr.Close()
+			r = zr
+		}
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case "snappy":
+		n, err := snappy.DecodedLen(body)
+		if err != nil {
+			return nil, fmt.Errorf("snappy: %w", err)
+		}
+		if limit > 0 && int64(n) > limit {
+			return nil, ErrContentTooLarge
+		}
+		out, err := snappy.Decode(nil, body)
+		if err != nil {
+			return nil, fmt.Errorf("snappy: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, coding)
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", coding, err)
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, ErrContentTooLarge
+	}
+	return out, nil
+}
+
+// SplitBatch splits a request body into individual payloads: the elements of a JSON array, or
+// the lines of NDJSON when every non-empty line is valid JSON. Anything else, including a single
+// JSON object spread over several lines, is returned as one payload.
+func SplitBatch(body []byte) [][]byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if json.Valid(trimmed) {
+		if trimmed[0] == '[' {
+			var arr []json.RawMessage
+			if json.Unmarshal(trimmed, &arr) == nil {
+				out := make([][]byte, 0, len(arr))
+				for _, el := range arr {
+					out = append(out, el)
+				}
+				return out
+			}
+		}
+		return [][]byte{trimmed}
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return [][]byte{body}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
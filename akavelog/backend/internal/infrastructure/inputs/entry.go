@@ -0,0 +1,164 @@
+package inputs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Well-known record keys mapped onto LogEntry fields by RecordToEntry.
+var (
+	serviceKeys   = []string{"service", "service_name", "app", "application"}
+	levelKeys     = []string{"level", "severity", "log_level", "lvl"}
+	messageKeys   = []string{"message", "msg", "log", "short_message"}
+	timestampKeys = []string{"timestamp", "time", "@timestamp", "ts"}
+)
+
+// RecordToEntry maps a decoded structured record (e.g. a Fluent event or a JSON object)
+// onto a LogEntry. Well-known keys fill service, level, message, timestamp, project_id and _id;
+// every other key becomes a tag. fallbackService is used when the record has no service.
+func RecordToEntry(record map[string]any, fallbackService string) model.LogEntry {
+	entry := model.LogEntry{
+		Service: fallbackService,
+		Level:   "info",
+		Tags:    make(map[string]string),
+	}
+	used := make(map[string]bool)
+	if v, k := firstString(record, serviceKeys); v != "" {
+		entry.Service = v
+		used[k] = true
+	}
+	if v, k := firstString(record, levelKeys); v != "" {
+		entry.Level = v
+		used[k] = true
+	}
+	if v, k := firstString(record, messageKeys); v != "" {
+		entry.Message = v
+		used[k] = true
+	}
+	for _, k := range timestampKeys {
+		if v, ok := record[k]; ok && v != nil {
+			entry.Timestamp = timestampString(v)
+			used[k] = true
+			break
+		}
+	}
+	if v, ok := record["project_id"].(string); ok {
+		entry.ProjectID = v
+		used["project_id"] = true
+	}
+	if v, ok := record["_id"].(string); ok {
+		entry.ID = v
+		used["_id"] = true
+	}
+	if tags, ok := record["tags"].(map[string]any); ok {
+		for k, v := range tags {
+			entry.Tags[k] = StringifyValue(v)
+		}
+		used["tags"] = true
+	}
+	for k, v := range record {
+		if used[k] {
+			continue
+		}
+		entry.Tags[k] = StringifyValue(v)
+	}
+	if entry.Message == "" {
+		// Keep records without a message field instead of dropping them in the validator.
+		b, _ := json.Marshal(record)
+		entry.Message = string(b)
+	}
+	if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return entry
+}
+
+// WrapPlain returns raw unchanged when it is a JSON object. Anything else (plain text lines
+// from legacy emitters) is wrapped as the message of a LogEntry for service, so it passes validation.
+func WrapPlain(raw []byte, service string) []byte {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return raw
+	}
+	b, err := json.Marshal(model.LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Service:   service,
+		Level:     "info",
+		Message:   string(trimmed),
+	})
+	if err != nil {
+		return raw
+	}
+	return b
+}
+
+// StringifyValue renders an arbitrary decoded value as a tag string.
+// Scalars use their natural form; maps and slices are JSON encoded.
+func StringifyValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprint(t)
+		}
+		return string(b)
+	}
+}
+
+func firstString(record map[string]any, keys []string) (string, string) {
+	for _, k := range keys {
+		if v, ok := record[k]; ok && v != nil {
+			if s := StringifyValue(v); s != "" {
+				return s, k
+			}
+		}
+	}
+	return "", ""
+}
+
+// timestampString converts numeric epoch values (s, ms) or time.Time to RFC3339; strings are kept as-is.
+func timestampString(v any) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case string:
+		return t
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC().Format(time.RFC3339Nano)
+	case int64:
+		return epochString(t)
+	case uint64:
+		return epochString(int64(t))
+	case int:
+		return epochString(int64(t))
+	default:
+		return StringifyValue(v)
+	}
+}
+
+func epochString(n int64) string {
+	if n > 1e12 {
+		return time.UnixMilli(n).UTC().Format(time.RFC3339Nano)
+	}
+	return time.Unix(n, 0).UTC().Format(time.RFC3339Nano)
+}
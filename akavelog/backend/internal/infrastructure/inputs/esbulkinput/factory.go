@@ -0,0 +1,60 @@
+package esbulkinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates Elasticsearch _bulk API shim inputs. Registers as "elasticsearch_bulk".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "elasticsearch_bulk"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "elasticsearch_bulk",
+		Description: "Elasticsearch _bulk NDJSON API on its own port, so Filebeat/Logstash with an Elasticsearch output can ship to akavelog. index/create actions are ingested; a per-item bulk response is returned.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":9200"},
+			{Name: "username", Type: "string", Required: false, Description: "Basic auth username; when set, requests must authenticate"},
+			{Name: "password", Type: "string", Required: false, Description: "Basic auth password"},
+			{Name: "version", Type: "string", Required: false, Description: "Elasticsearch version reported to clients (default 8.11.0)", Example: "8.11.0"},
+		},
+	}
+}
+
+// ValidateConfig validates elasticsearch_bulk input config. Listen is required.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	listen, _ := cfg["listen"].(string)
+	if strings.TrimSpace(listen) == "" {
+		return fmt.Errorf("listen is required: each input must have its own port (e.g. :9200)")
+	}
+	if _, _, err := net.SplitHostPort(strings.TrimSpace(listen)); err != nil {
+		return fmt.Errorf("listen must be host:port or :port (e.g. :9200 or 0.0.0.0:9200)")
+	}
+	user, _ := cfg["username"].(string)
+	pass, _ := cfg["password"].(string)
+	if (user == "") != (pass == "") {
+		return fmt.Errorf("username and password must be set together")
+	}
+	return nil
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	if err := f.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	listen, _ := cfg["listen"].(string)
+	user, _ := cfg["username"].(string)
+	pass, _ := cfg["password"].(string)
+	version, _ := cfg["version"].(string)
+	if version == "" {
+		version = "8.11.0"
+	}
+	return NewInput(strings.TrimSpace(listen), user, pass, version, buffer), nil
+}
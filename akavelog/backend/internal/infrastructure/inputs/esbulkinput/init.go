@@ -0,0 +1,7 @@
+package esbulkinput
+
+import "github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+
+func init() {
+	inputs.GlobalRegistry.Register(&Factory{})
+}
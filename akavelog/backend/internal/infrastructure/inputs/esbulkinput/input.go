@@ -0,0 +1,299 @@
+package esbulkinput
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/google/uuid"
+)
+
+const maxRequestBody = 100 << 20 // 100MB, Elasticsearch's default http.max_content_length
+
+// Input serves a minimal Elasticsearch API (cluster info + _bulk) and writes documents to an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	listenAddr string
+	username   string
+	password   string
+	version    string
+	buffer     inputs.InputBuffer
+	server     *http.Server
+	seqNo      atomic.Int64
+}
+
+// NewInput creates an Elasticsearch bulk shim. Empty username disables authentication.
+func NewInput(listenAddr, username, password, version string, buffer inputs.InputBuffer) *Input {
+	return &Input{listenAddr: listenAddr, username: username, password: password, version: version, buffer: buffer}
+}
+
+// bulkItem is the per-action result in a _bulk response.
+type bulkItem struct {
+	Index       string         `json:"_index"`
+	ID          string         `json:"_id"`
+	Version     int            `json:"_version,omitempty"`
+	Result      string         `json:"result,omitempty"`
+	Status      int            `json:"status"`
+	SeqNo       int64          `json:"_seq_no,omitempty"`
+	PrimaryTerm int            `json:"_primary_term,omitempty"`
+	Shards      map[string]int `json:"_shards,omitempty"`
+	Error       *bulkError     `json:"error,omitempty"`
+}
+
+type bulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type actionMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+func (i *Input) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Beats and the Elasticsearch clients refuse servers that do not identify as Elasticsearch.
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		if !i.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="akavelog"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": map[string]string{"type": "security_exception", "reason": "missing or invalid credentials"}, "status": 401})
+			return
+		}
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case path == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			writeJSON(w, http.StatusOK, i.clusterInfo())
+		case strings.HasSuffix(path, "/_bulk") && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+			defaultIndex := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "_bulk")
+			i.handleBulk(w, r, strings.TrimSuffix(defaultIndex, "/"))
+		case path == "/_license":
+			writeJSON(w, http.StatusOK, map[string]any{"license": map[string]string{"status": "active", "type": "basic"}})
+		default:
+			// Template, ILM and alias setup calls from shippers: acknowledge so they proceed to _bulk.
+			writeJSON(w, http.StatusOK, map[string]any{"acknowledged": true})
+		}
+	})
+}
+
+func (i *Input) authorized(r *http.Request) bool {
+	if i.username == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(i.username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(i.password)) == 1
+}
+
+func (i *Input) clusterInfo() map[string]any {
+	return map[string]any{
+		"name":         "akavelog",
+		"cluster_name": "akavelog",
+		"cluster_uuid": "akavelog",
+		"version": map[string]any{
+			"number":         i.version,
+			"build_flavor":   "default",
+			"lucene_version": "9.8.0",
+		},
+		"tagline": "You Know, for Search",
+	}
+}
+
+// bulkAction is one action of a _bulk request.
+type bulkAction struct {
+	op     string
+	meta   actionMeta
+	source []byte // the document of index and create
+}
+
+// requestError fails a whole _bulk request.
+type requestError struct {
+	status      int
+	typ, reason string
+}
+
+func (i *Input) handleBulk(w http.ResponseWriter, r *http.Request, defaultIndex string) {
+	start := time.Now()
+	var body io.Reader = io.LimitReader(r.Body, maxRequestBody+1)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "parse_exception", "invalid gzip body: "+err.Error())
+			return
+		}
+		defer zr.Close()
+		body = io.LimitReader(zr, maxRequestBody+1)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+		return
+	}
+	if len(raw) > maxRequestBody {
+		writeError(w, http.StatusRequestEntityTooLarge, "content_too_long_exception", fmt.Sprintf("request body exceeds %d bytes", maxRequestBody))
+		return
+	}
+	// Nothing is stored until the whole request parsed: a client retries a request refused
+	// with 400, and would store the actions before the bad line twice.
+	actions, perr := parseBulk(raw, defaultIndex)
+	if perr != nil {
+		writeError(w, perr.status, perr.typ, perr.reason)
+		return
+	}
+	items := make([]map[string]bulkItem, 0, len(actions))
+	hasErrors := false
+	for _, a := range actions {
+		var item bulkItem
+		switch a.op {
+		case "index", "create":
+			item = i.ingest(a.meta, a.source)
+		case "update":
+			item = rejected(a.meta, "update is not supported by akavelog")
+		case "delete":
+			item = rejected(a.meta, "delete is not supported by akavelog")
+		}
+		if item.Error != nil {
+			hasErrors = true
+		}
+		items = append(items, map[string]bulkItem{a.op: item})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"took":   time.Since(start).Milliseconds(),
+		"errors": hasErrors,
+		"items":  items,
+	})
+}
+
+// parseBulk splits the NDJSON body of a _bulk request into its actions. Actions without an
+// _index get defaultIndex and those without an _id a new one.
+func parseBulk(body []byte, defaultIndex string) ([]bulkAction, *requestError) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 64*1024), maxRequestBody)
+	var actions []bulkAction
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]actionMeta
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return nil, &requestError{http.StatusBadRequest, "illegal_argument_exception", "Malformed action/metadata line"}
+		}
+		for op, meta := range action {
+			if meta.Index == "" {
+				meta.Index = defaultIndex
+			}
+			if meta.ID == "" {
+				meta.ID = uuid.New().String()
+			}
+			a := bulkAction{op: op, meta: meta}
+			switch op {
+			case "index", "create":
+				if !sc.Scan() {
+					return nil, &requestError{http.StatusBadRequest, "illegal_argument_exception", "The bulk request must be terminated by a newline"}
+				}
+				a.source = append([]byte(nil), sc.Bytes()...)
+			case "update":
+				sc.Scan() // skip the partial document
+			case "delete":
+			default:
+				return nil, &requestError{http.StatusBadRequest, "illegal_argument_exception",
+					fmt.Sprintf("Malformed action/metadata line, expected one of [create, delete, index, update] but found [%s]", op)}
+			}
+			actions = append(actions, a)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, &requestError{http.StatusBadRequest, "parse_exception", err.Error()}
+	}
+	return actions, nil
+}
+
+func (i *Input) ingest(meta actionMeta, source []byte) bulkItem {
+	var doc map[string]any
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return bulkItem{Index: meta.Index, ID: meta.ID, Status: http.StatusBadRequest,
+			Error: &bulkError{Type: "mapper_parsing_exception", Reason: "failed to parse: " + err.Error()}}
+	}
+	service := meta.Index
+	if service == "" {
+		service = "elasticsearch"
+	}
+	entry := inputs.RecordToEntry(doc, service)
+	entry.Tags["es_index"] = meta.Index
+	entry.Tags["es_id"] = meta.ID
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return bulkItem{Index: meta.Index, ID: meta.ID, Status: http.StatusInternalServerError,
+			Error: &bulkError{Type: "exception", Reason: err.Error()}}
+	}
+	if err := i.buffer.Insert(raw); err != nil {
+		// Beats and Logstash retry items rejected with 429.
+		return bulkItem{Index: meta.Index, ID: meta.ID, Status: http.StatusTooManyRequests,
+			Error: &bulkError{Type: "es_rejected_execution_exception", Reason: err.Error()}}
+	}
+	return bulkItem{
+		Index:       meta.Index,
+		ID:          meta.ID,
+		Version:     1,
+		Result:      "created",
+		Status:      http.StatusCreated,
+		SeqNo:       i.seqNo.Add(1) - 1,
+		PrimaryTerm: 1,
+		Shards:      map[string]int{"total": 1, "successful": 1, "failed": 0},
+	}
+}
+
+func rejected(meta actionMeta, reason string) bulkItem {
+	return bulkItem{Index: meta.Index, ID: meta.ID, Status: http.StatusBadRequest,
+		Error: &bulkError{Type: "illegal_argument_exception", Reason: reason}}
+}
+
+func writeError(w http.ResponseWriter, status int, typ, reason string) {
+	writeJSON(w, status, map[string]any{"error": bulkError{Type: typ, Reason: reason}, "status": status})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.listenAddr,
+		Handler: i.Handler(),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[es-bulk] listener %s: %v", i.listenAddr, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.listenAddr, err))
+		}
+	}()
+	log.Printf("[es-bulk] listening on %s", i.listenAddr)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
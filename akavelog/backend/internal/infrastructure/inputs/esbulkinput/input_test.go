@@ -0,0 +1,175 @@
+package esbulkinput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func bulk(t *testing.T, in *Input, path, body string, gz bool) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	payload := []byte(body)
+	if gz {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write(payload)
+		zw.Close()
+		payload = b.Bytes()
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if gz {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.SetBasicAuth("beats", "secret")
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", rec.Body.String(), err)
+	}
+	return rec, resp
+}
+
+func TestParseBulk(t *testing.T) {
+	body := `{"index":{"_index":"app","_id":"1"}}
+{"message":"one"}
+
+{"create":{}}
+{"message":"two"}
+{"update":{"_id":"3"}}
+{"doc":{"message":"three"}}
+{"delete":{"_id":"4"}}
+`
+	actions, perr := parseBulk([]byte(body), "logs")
+	if perr != nil {
+		t.Fatalf("parseBulk: %+v", perr)
+	}
+	if len(actions) != 4 {
+		t.Fatalf("actions = %d, want 4", len(actions))
+	}
+	for i, want := range []string{"index", "create", "update", "delete"} {
+		if actions[i].op != want {
+			t.Errorf("action %d op = %q, want %q", i, actions[i].op, want)
+		}
+	}
+	if actions[0].meta.Index != "app" || actions[0].meta.ID != "1" || string(actions[0].source) != `{"message":"one"}` {
+		t.Errorf("index action = %+v", actions[0])
+	}
+	if actions[1].meta.Index != "logs" || actions[1].meta.ID == "" || string(actions[1].source) != `{"message":"two"}` {
+		t.Errorf("create action = %+v", actions[1])
+	}
+
+	for name, body := range map[string]string{
+		"malformed action": "{\"index\":{}}\n{\"message\":\"ok\"}\nnot json\n",
+		"unknown op":       "{\"upsert\":{}}\n{}\n",
+		"missing source":   "{\"index\":{}}\n",
+	} {
+		if _, perr := parseBulk([]byte(body), "logs"); perr == nil || perr.status != http.StatusBadRequest {
+			t.Errorf("%s: error = %+v, want 400", name, perr)
+		}
+	}
+}
+
+func TestBulk(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(":0", "beats", "secret", "8.11.0", buf)
+	body := `{"index":{"_id":"a"}}
+{"message":"payment failed","level":"error"}
+{"index":{}}
+not a document
+{"delete":{"_id":"b"}}
+`
+	for _, gz := range []bool{false, true} {
+		buf.msgs = nil
+		rec, resp := bulk(t, in, "/filebeat/_bulk", body, gz)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("gzip=%v: status = %d: %s", gz, rec.Code, rec.Body)
+		}
+		if resp["errors"] != true {
+			t.Errorf("gzip=%v: errors = %v, want true", gz, resp["errors"])
+		}
+		items := resp["items"].([]any)
+		if len(items) != 3 {
+			t.Fatalf("gzip=%v: items = %d, want 3", gz, len(items))
+		}
+		var statuses []float64
+		for _, it := range items {
+			for _, v := range it.(map[string]any) {
+				statuses = append(statuses, v.(map[string]any)["status"].(float64))
+			}
+		}
+		if statuses[0] != 201 || statuses[1] != 400 || statuses[2] != 400 {
+			t.Errorf("gzip=%v: statuses = %v", gz, statuses)
+		}
+		if len(buf.msgs) != 1 {
+			t.Fatalf("gzip=%v: stored %d entries, want 1", gz, len(buf.msgs))
+		}
+		var entry model.LogEntry
+		if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Message != "payment failed" || entry.Tags["es_index"] != "filebeat" || entry.Tags["es_id"] != "a" {
+			t.Errorf("gzip=%v: entry = %+v", gz, entry)
+		}
+	}
+}
+
+func TestBulkMalformedStoresNothing(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(":0", "beats", "secret", "8.11.0", buf)
+	body := `{"index":{}}
+{"message":"one"}
+{"index":{}}
+{"message":"two"}
+{"index":
+`
+	rec, resp := bulk(t, in, "/_bulk", body, false)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if e, _ := resp["error"].(map[string]any); e == nil || e["type"] != "illegal_argument_exception" {
+		t.Errorf("error = %v", resp["error"])
+	}
+	if len(buf.msgs) != 0 {
+		t.Errorf("stored %d entries of a refused request", len(buf.msgs))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.SetBasicAuth("beats", "secret")
+	rec = httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid gzip: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestBulkAuth(t *testing.T) {
+	in := NewInput(":0", "beats", "secret", "8.11.0", &memBuffer{})
+	req := httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader("{\"index\":{}}\n{}\n"))
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("X-Elastic-Product") != "Elasticsearch" {
+		t.Errorf("without credentials: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,56 @@
+package fluentinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates Fluent forward protocol inputs. Registers as "fluent_forward".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "fluent_forward"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "fluent_forward",
+		Description: "Fluentd/Fluent Bit forward protocol (msgpack over TCP). Supports Message, Forward, PackedForward and CompressedPackedForward modes, chunk acks, and the optional shared-key handshake.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind (e.g. :24224). Must be unique across inputs.", Example: ":24224"},
+			{Name: "shared_key", Type: "string", Required: false, Description: "Shared key for the HELO/PING/PONG handshake. Empty disables authentication."},
+			{Name: "self_hostname", Type: "string", Required: false, Description: "Hostname announced in PONG replies", Example: "akavelog"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for records without a service field (defaults to the Fluent tag)"},
+		},
+	}
+}
+
+// ValidateConfig validates fluent_forward input config. Listen is required.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	listen, _ := cfg["listen"].(string)
+	listen = strings.TrimSpace(listen)
+	if listen == "" {
+		return fmt.Errorf("listen is required: each input must have its own port (e.g. :24224)")
+	}
+	if _, _, err := net.SplitHostPort(listen); err != nil {
+		return fmt.Errorf("listen must be host:port or :port (e.g. :24224 or 0.0.0.0:24224)")
+	}
+	return nil
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	if err := f.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	listen, _ := cfg["listen"].(string)
+	sharedKey, _ := cfg["shared_key"].(string)
+	hostname, _ := cfg["self_hostname"].(string)
+	if hostname == "" {
+		hostname = "akavelog"
+	}
+	service, _ := cfg["service"].(string)
+	return NewInput(strings.TrimSpace(listen), sharedKey, hostname, service, buffer), nil
+}
@@ -0,0 +1,228 @@
+package fluentinput
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const handshakeTimeout = 10 * time.Second
+
+// Input is a Fluent forward protocol listener that writes every received event to an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	listenAddr string
+	sharedKey  string
+	hostname   string
+	service    string
+	buffer     inputs.InputBuffer
+	metrics    *inputs.Metrics
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	drain    inputs.Drainer
+}
+
+// NewInput creates a forward input bound to listenAddr. An empty sharedKey disables the handshake.
+// service is the fallback service name; when empty the Fluent tag is used.
+func NewInput(listenAddr, sharedKey, hostname, service string, buffer inputs.InputBuffer) *Input {
+	return &Input{
+		listenAddr: listenAddr,
+		sharedKey:  sharedKey,
+		hostname:   hostname,
+		service:    service,
+		buffer:     buffer,
+		metrics:    inputs.MetricsOf(buffer),
+		conns:      make(map[net.Conn]struct{}),
+	}
+}
+
+func (i *Input) Start() error {
+	ln, err := net.Listen("tcp", i.listenAddr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", i.listenAddr, err)
+	}
+	i.mu.Lock()
+	i.listener = ln
+	i.mu.Unlock()
+	i.wg.Add(1)
+	go i.acceptLoop(ln)
+	log.Printf("[fluent] listening on %s", i.listenAddr)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	ln := i.listener
+	i.listener = nil
+	for c := range i.conns {
+		_ = c.Close()
+	}
+	i.mu.Unlock()
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	i.wg.Wait()
+	return err
+}
+
+// Drain stops accepting connections, reads what forwarders have already sent for up to
+// inputs.DrainReadTimeout, then stops. Chunks left unacked are retried by the forwarders.
+// When ctx is done first it stops at once.
+func (i *Input) Drain(ctx context.Context) error {
+	deadline := i.drain.Begin()
+	i.mu.Lock()
+	ln := i.listener
+	i.listener = nil
+	i.mu.Unlock()
+	if ln != nil {
+		_ = ln.Close()
+	}
+	i.mu.Lock()
+	for c := range i.conns {
+		_ = c.SetReadDeadline(deadline)
+	}
+	i.mu.Unlock()
+	inputs.Wait(ctx, &i.wg)
+	return i.Stop()
+}
+
+func (i *Input) acceptLoop(ln net.Listener) {
+	defer i.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[fluent] accept on %s: %v", i.listenAddr, err)
+				i.Fail(fmt.Errorf("accept on %s: %w", i.listenAddr, err))
+			}
+			return
+		}
+		i.mu.Lock()
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+		i.metrics.ConnOpened()
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			defer func() {
+				i.mu.Lock()
+				delete(i.conns, conn)
+				i.mu.Unlock()
+				i.metrics.ConnClosed()
+				_ = conn.Close()
+			}()
+			if err := i.serve(conn); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) &&
+				!(i.drain.Draining() && errors.Is(err, os.ErrDeadlineExceeded)) {
+				i.metrics.Error()
+				log.Printf("[fluent] connection %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (i *Input) serve(conn net.Conn) error {
+	dec := msgpack.NewDecoder(bufio.NewReader(conn))
+	enc := msgpack.NewEncoder(conn)
+	if i.sharedKey != "" {
+		if err := i.handshake(conn, dec, enc); err != nil {
+			return fmt.Errorf("handshake: %w", err)
+		}
+	}
+	for {
+		v, err := dec.DecodeInterface()
+		if err != nil {
+			return err
+		}
+		msg, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected array message, got %T", v)
+		}
+		events, option, err := parseMessage(msg)
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			if err := i.insert(ev); err != nil {
+				// Closing without the chunk ack makes the forwarder retry the chunk.
+				return err
+			}
+		}
+		if chunk, ok := option["chunk"]; ok {
+			if err := enc.Encode(map[string]any{"ack": chunk}); err != nil {
+				return fmt.Errorf("write ack: %w", err)
+			}
+		}
+	}
+}
+
+// handshake runs HELO → PING → PONG. The client must prove knowledge of the shared key.
+func (i *Input) handshake(conn net.Conn, dec *msgpack.Decoder, enc *msgpack.Encoder) error {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo := []any{"HELO", map[string]any{"nonce": nonce, "auth": "", "keepalive": true}}
+	if err := enc.Encode(helo); err != nil {
+		return err
+	}
+
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+	ping, ok := v.([]any)
+	if !ok || len(ping) < 4 {
+		return errors.New("malformed PING")
+	}
+	if kind, _ := asString(ping[0]); kind != "PING" {
+		return fmt.Errorf("expected PING, got %v", ping[0])
+	}
+	clientHost, _ := asString(ping[1])
+	salt, _ := asString(ping[2])
+	digest, _ := asString(ping[3])
+
+	expected := sharedKeyDigest(salt, clientHost, nonce, i.sharedKey)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(expected)) != 1 {
+		_ = enc.Encode([]any{"PONG", false, "shared_key mismatch", i.hostname, ""})
+		return errors.New("shared_key mismatch from " + clientHost)
+	}
+	pong := []any{"PONG", true, "", i.hostname, sharedKeyDigest(salt, i.hostname, nonce, i.sharedKey)}
+	return enc.Encode(pong)
+}
+
+func (i *Input) insert(ev event) error {
+	service := i.service
+	if service == "" {
+		service = ev.Tag
+	}
+	entry := inputs.RecordToEntry(ev.Record, service)
+	entry.Timestamp = ev.Time.Format(time.RFC3339Nano)
+	entry.Tags["fluent_tag"] = ev.Tag
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[fluent] marshal entry: %v", err)
+		return nil
+	}
+	return i.buffer.Insert(raw)
+}
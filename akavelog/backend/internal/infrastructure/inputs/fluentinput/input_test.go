@@ -0,0 +1,145 @@
+package fluentinput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func (b *memBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.msgs)
+}
+
+func TestParseMessage_Modes(t *testing.T) {
+	rec := map[string]any{"message": "hello", "level": "warn"}
+	et := &EventTime{Sec: 1700000000, Nsec: 5}
+
+	var packed bytes.Buffer
+	enc := msgpack.NewEncoder(&packed)
+	_ = enc.Encode([]any{et, rec})
+	_ = enc.Encode([]any{int64(1700000001), rec})
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(packed.Bytes())
+	_ = zw.Close()
+
+	cases := map[string][]any{
+		"message":    {"app.web", et, rec},
+		"forward":    {"app.web", []any{[]any{et, rec}, []any{int64(1700000001), rec}}},
+		"packed":     {"app.web", packed.Bytes()},
+		"compressed": {"app.web", gz.Bytes(), map[string]any{"compressed": "gzip"}},
+	}
+	want := map[string]int{"message": 1, "forward": 2, "packed": 2, "compressed": 2}
+	for name, msg := range cases {
+		// Round-trip through msgpack so the decoder sees wire types.
+		b, err := msgpack.Marshal(msg)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", name, err)
+		}
+		var decoded []any
+		if err := msgpack.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		events, _, err := parseMessage(decoded)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", name, err)
+		}
+		if len(events) != want[name] {
+			t.Fatalf("%s: expected %d events, got %d", name, want[name], len(events))
+		}
+		if events[0].Tag != "app.web" || !events[0].Time.Equal(et.Time()) {
+			t.Fatalf("%s: unexpected first event %+v", name, events[0])
+		}
+	}
+}
+
+func TestParseMessage_GzipBomb(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(make([]byte, maxDecompressed+1))
+	_ = zw.Close()
+	_, _, err := parseMessage([]any{"app.web", gz.Bytes(), map[string]any{"compressed": "gzip"}})
+	if err == nil || !strings.Contains(err.Error(), "exceed") {
+		t.Fatalf("err = %v, want size limit", err)
+	}
+}
+
+func TestInput_HandshakeAndAck(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput("127.0.0.1:0", "secret", "akavelog", "", buf)
+	if err := in.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer in.Stop()
+
+	conn, err := net.Dial("tcp", in.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	dec := msgpack.NewDecoder(conn)
+	enc := msgpack.NewEncoder(conn)
+
+	var helo []any
+	if err := dec.Decode(&helo); err != nil {
+		t.Fatalf("read HELO: %v", err)
+	}
+	nonce, _ := asBytes(helo[1].(map[string]any)["nonce"])
+	digest := sharedKeyDigest("salt", "client", nonce, "secret")
+	if err := enc.Encode([]any{"PING", "client", "salt", digest, "", ""}); err != nil {
+		t.Fatalf("write PING: %v", err)
+	}
+	var pong []any
+	if err := dec.Decode(&pong); err != nil {
+		t.Fatalf("read PONG: %v", err)
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		t.Fatalf("handshake rejected: %v", pong)
+	}
+
+	msg := []any{"app.web", int64(1700000000), map[string]any{"message": "hi", "service": "web"}, map[string]any{"chunk": "c1"}}
+	if err := enc.Encode(msg); err != nil {
+		t.Fatalf("write message: %v", err)
+	}
+	var ack map[string]any
+	if err := dec.Decode(&ack); err != nil {
+		t.Fatalf("read ack: %v", err)
+	}
+	if ack["ack"] != "c1" {
+		t.Fatalf("expected ack c1, got %v", ack)
+	}
+	if buf.Len() != 1 {
+		t.Fatalf("expected 1 buffered entry, got %d", buf.Len())
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	if entry.Service != "web" || entry.Message != "hi" || entry.Tags["fluent_tag"] != "app.web" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
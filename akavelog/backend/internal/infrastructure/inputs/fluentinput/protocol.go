@@ -0,0 +1,229 @@
+package fluentinput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// maxDecompressed bounds the entries of a CompressedPackedForward message once decompressed,
+// guarding against gzip bombs; the beats input allows payloads of the same size.
+const maxDecompressed = 64 << 20
+
+// EventTime is the Fluent forward protocol ext type 0: seconds and nanoseconds since epoch.
+type EventTime struct {
+	Sec  uint32
+	Nsec uint32
+}
+
+func init() {
+	msgpack.RegisterExt(0, (*EventTime)(nil))
+}
+
+// Time returns the event time in UTC.
+func (t *EventTime) Time() time.Time {
+	return time.Unix(int64(t.Sec), int64(t.Nsec)).UTC()
+}
+
+func (t *EventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[:4], t.Sec)
+	binary.BigEndian.PutUint32(b[4:], t.Nsec)
+	return b, nil
+}
+
+func (t *EventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid EventTime length %d", len(b))
+	}
+	t.Sec = binary.BigEndian.Uint32(b[:4])
+	t.Nsec = binary.BigEndian.Uint32(b[4:])
+	return nil
+}
+
+// event is one decoded (time, record) pair from any forward mode.
+type event struct {
+	Tag    string
+	Time   time.Time
+	Record map[string]any
+}
+
+// parseMessage decodes one top-level forward message into events and returns its option map.
+//
+//	Message:                 [tag, time, record, option?]
+//	Forward:                 [tag, [[time, record], ...], option?]
+//	PackedForward:           [tag, bin(entries...), option?]
+//	CompressedPackedForward: [tag, bin(gzip(entries...)), {"compressed": "gzip", ...}]
+func parseMessage(msg []any) ([]event, map[string]any, error) {
+	if len(msg) < 2 {
+		return nil, nil, fmt.Errorf("message has %d elements, want at least 2", len(msg))
+	}
+	tag, ok := asString(msg[0])
+	if !ok {
+		return nil, nil, errors.New("tag must be a string")
+	}
+	var option map[string]any
+	switch second := msg[1].(type) {
+	case []any:
+		if len(msg) > 2 {
+			option, _ = msg[2].(map[string]any)
+		}
+		events := make([]event, 0, len(second))
+		for _, e := range second {
+			pair, ok := e.([]any)
+			if !ok || len(pair) < 2 {
+				return nil, nil, errors.New("forward entry must be [time, record]")
+			}
+			ev, err := newEvent(tag, pair[0], pair[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			events = append(events, ev)
+		}
+		return events, option, nil
+	case string, []byte:
+		if len(msg) > 2 {
+			option, _ = msg[2].(map[string]any)
+		}
+		raw, _ := asBytes(second)
+		if c, _ := option["compressed"].(string); c == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				return nil, nil, fmt.Errorf("compressed entries: %w", err)
+			}
+			raw, err = io.ReadAll(io.LimitReader(zr, maxDecompressed+1))
+			if err != nil {
+				return nil, nil, fmt.Errorf("compressed entries: %w", err)
+			}
+			if len(raw) > maxDecompressed {
+				return nil, nil, fmt.Errorf("compressed entries exceed %d bytes", maxDecompressed)
+			}
+		} else if c != "" && c != "text" {
+			return nil, nil, fmt.Errorf("unsupported compression %q", c)
+		}
+		events, err := parsePackedEntries(tag, raw)
+		return events, option, err
+	default:
+		if len(msg) < 3 {
+			return nil, nil, errors.New("message mode requires [tag, time, record]")
+		}
+		if len(msg) > 3 {
+			option, _ = msg[3].(map[string]any)
+		}
+		ev, err := newEvent(tag, msg[1], msg[2])
+		if err != nil {
+			return nil, nil, err
+		}
+		return []event{ev}, option, nil
+	}
+}
+
+// parsePackedEntries decodes a concatenated stream of [time, record] msgpack arrays.
+func parsePackedEntries(tag string, raw []byte) ([]event, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(raw))
+	var events []event
+	for {
+		v, err := dec.DecodeInterface()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("packed entries: %w", err)
+		}
+		pair, ok := v.([]any)
+		if !ok || len(pair) < 2 {
+			return nil, errors.New("packed entry must be [time, record]")
+		}
+		ev, err := newEvent(tag, pair[0], pair[1])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+}
+
+func newEvent(tag string, t any, rec any) (event, error) {
+	record, ok := rec.(map[string]any)
+	if !ok {
+		return event{}, errors.New("record must be a map")
+	}
+	ts, err := eventTime(t)
+	if err != nil {
+		return event{}, err
+	}
+	return event{Tag: tag, Time: ts, Record: record}, nil
+}
+
+func eventTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case *EventTime:
+		return t.Time(), nil
+	case int8:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int16:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int32:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case uint8:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case uint16:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case uint32:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case uint64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case float32:
+		return floatTime(float64(t)), nil
+	case float64:
+		return floatTime(t), nil
+	case nil:
+		return time.Now().UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time type %T", v)
+	}
+}
+
+func floatTime(f float64) time.Time {
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC()
+}
+
+func asString(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	}
+	return "", false
+}
+
+func asBytes(v any) ([]byte, bool) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), true
+	case []byte:
+		return t, true
+	}
+	return nil, false
+}
+
+// sharedKeyDigest computes hex(sha512(salt + hostname + nonce + sharedKey)) as used by PING and PONG.
+func sharedKeyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,26 @@
+package inputs
+
+import "sync"
+
+// HealthState records why an input stopped working. Inputs embed it to get a Health method and
+// call Fail from the goroutine whose listener or worker died, since Start has already returned.
+type HealthState struct {
+	mu  sync.Mutex
+	err error
+}
+
+// Fail marks the input unhealthy with err. The first failure is kept.
+func (h *HealthState) Fail(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// Health returns nil while the input is working, or the error it failed with.
+func (h *HealthState) Health() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
@@ -0,0 +1,57 @@
+package hecinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates Splunk HTTP Event Collector compatible inputs. Registers as "splunk_hec".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "splunk_hec"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "splunk_hec",
+		Description: "Splunk HTTP Event Collector API on its own port: /services/collector/event, /services/collector/raw, /services/collector/ack and /services/collector/health with Splunk token auth.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":8088"},
+			{Name: "tokens", Type: "string", Required: true, Description: "Comma-separated HEC tokens accepted in 'Authorization: Splunk <token>'", Example: "11111111-2222-3333-4444-555555555555"},
+			{Name: "ack_enabled", Type: "bool", Required: false, Description: "Require a request channel and return ackId values that can be polled on /services/collector/ack"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name when an event has no source or sourcetype", Example: "splunk"},
+		},
+	}
+}
+
+// ValidateConfig validates splunk_hec input config. Listen and at least one token are required.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	listen, _ := cfg["listen"].(string)
+	if strings.TrimSpace(listen) == "" {
+		return fmt.Errorf("listen is required: each input must have its own port (e.g. :8088)")
+	}
+	if _, _, err := net.SplitHostPort(strings.TrimSpace(listen)); err != nil {
+		return fmt.Errorf("listen must be host:port or :port (e.g. :8088 or 0.0.0.0:8088)")
+	}
+	if len(cfg.Strings("tokens")) == 0 {
+		return fmt.Errorf("tokens is required: at least one HEC token")
+	}
+	return nil
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	if err := f.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	listen, _ := cfg["listen"].(string)
+	ack, _ := cfg.Bool("ack_enabled")
+	service, _ := cfg["service"].(string)
+	if service == "" {
+		service = "splunk"
+	}
+	return NewInput(strings.TrimSpace(listen), cfg.Strings("tokens"), ack, service, buffer), nil
+}
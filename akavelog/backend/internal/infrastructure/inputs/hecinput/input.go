@@ -0,0 +1,431 @@
+package hecinput
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/tracing"
+)
+
+const maxRequestBody = 32 << 20 // 32MB
+
+// Bounds of the ack IDs kept for clients to query. Clients that never query, or open a new
+// channel per request, would otherwise grow them without limit.
+const (
+	maxAckChannels    = 1000             // channels with unqueried IDs; the least recently used is dropped
+	maxAcksPerChannel = 1000             // unqueried IDs of a channel; the oldest is dropped
+	ackTTL            = 10 * time.Minute // IDs not queried in this time are dropped
+)
+
+// hecResponse mirrors Splunk's {"text": ..., "code": ...} reply body.
+type hecResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId,omitempty"`
+}
+
+// Splunk HEC status codes used by clients to decide whether to retry.
+var (
+	respSuccess        = hecResponse{Text: "Success", Code: 0}
+	respTokenRequired  = hecResponse{Text: "Token is required", Code: 2}
+	respInvalidAuth    = hecResponse{Text: "Invalid authorization", Code: 3}
+	respInvalidToken   = hecResponse{Text: "Invalid token", Code: 4}
+	respNoData         = hecResponse{Text: "No data", Code: 5}
+	respInvalidFormat  = hecResponse{Text: "Invalid data format", Code: 6}
+	respChannelMissing = hecResponse{Text: "Data channel is missing", Code: 10}
+	respServerBusy     = hecResponse{Text: "Server is busy", Code: 9}
+	respHealthy        = hecResponse{Text: "HEC is healthy", Code: 17}
+)
+
+// hecEvent is one event object of the /services/collector/event protocol.
+type hecEvent struct {
+	Time       json.RawMessage `json:"time"`
+	Host       string          `json:"host"`
+	Source     string          `json:"source"`
+	Sourcetype string          `json:"sourcetype"`
+	Index      string          `json:"index"`
+	Event      json.RawMessage `json:"event"`
+	Fields     map[string]any  `json:"fields"`
+}
+
+// Input serves the Splunk HEC API on its own port and writes events to an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	listenAddr string
+	tokens     []string
+	ackEnabled bool
+	service    string
+	buffer     inputs.InputBuffer
+	server     *http.Server
+
+	ackMu     sync.Mutex
+	nextAck   int64
+	pending   map[string]*ackChannel // channel → issued, not yet queried ack IDs
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// ackChannel holds the ack IDs issued on a channel and not yet queried.
+type ackChannel struct {
+	ids  map[int64]time.Time // ID → when it was issued
+	used time.Time           // last issue or query
+}
+
+// NewInput creates a HEC input. When ackEnabled, every request must carry a channel.
+func NewInput(listenAddr string, tokens []string, ackEnabled bool, service string, buffer inputs.InputBuffer) *Input {
+	return &Input{
+		listenAddr: listenAddr,
+		tokens:     tokens,
+		ackEnabled: ackEnabled,
+		service:    service,
+		buffer:     buffer,
+		pending:    make(map[string]*ackChannel),
+		now:        time.Now,
+	}
+}
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/services/collector/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, respHealthy)
+	})
+	mux.HandleFunc("/services/collector/health/1.0", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, respHealthy)
+	})
+	event := i.authenticated(i.handleEvent)
+	mux.Handle("/services/collector", event)
+	mux.Handle("/services/collector/event", event)
+	mux.Handle("/services/collector/event/1.0", event)
+	mux.Handle("/services/collector/raw", i.authenticated(i.handleRaw))
+	mux.Handle("/services/collector/raw/1.0", i.authenticated(i.handleRaw))
+	mux.Handle("/services/collector/ack", i.authenticated(i.handleAck))
+	return mux
+}
+
+// authenticated checks the HEC token ("Authorization: Splunk <token>") and method.
+func (i *Input) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			writeJSON(w, http.StatusUnauthorized, respTokenRequired)
+			return
+		}
+		scheme, token, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Splunk") {
+			writeJSON(w, http.StatusUnauthorized, respInvalidAuth)
+			return
+		}
+		if !i.validToken(strings.TrimSpace(token)) {
+			writeJSON(w, http.StatusForbidden, respInvalidToken)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (i *Input) validToken(token string) bool {
+	valid := false
+	for _, t := range i.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func channelOf(r *http.Request) string {
+	if ch := r.Header.Get("X-Splunk-Request-Channel"); ch != "" {
+		return ch
+	}
+	return r.URL.Query().Get("channel")
+}
+
+func (i *Input) handleEvent(w http.ResponseWriter, r *http.Request) {
+	channel := channelOf(r)
+	if i.ackEnabled && channel == "" {
+		writeJSON(w, http.StatusBadRequest, respChannelMissing)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, respInvalidFormat)
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		writeJSON(w, http.StatusBadRequest, respNoData)
+		return
+	}
+	// HEC bodies are a stream of concatenated JSON objects, not an array.
+	var entries [][]byte
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var ev hecEvent
+		err := dec.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil || len(ev.Event) == 0 {
+			writeJSON(w, http.StatusBadRequest, respInvalidFormat)
+			return
+		}
+		raw, err := i.entryFromEvent(ev)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, respInvalidFormat)
+			return
+		}
+		entries = append(entries, raw)
+	}
+	for _, raw := range entries {
+		if err := inputs.InsertContext(r.Context(), i.buffer, raw); err != nil {
+			i.writeBusy(w, err)
+			return
+		}
+	}
+	i.writeSuccess(w, channel)
+}
+
+func (i *Input) handleRaw(w http.ResponseWriter, r *http.Request) {
+	channel := channelOf(r)
+	if i.ackEnabled && channel == "" {
+		writeJSON(w, http.StatusBadRequest, respChannelMissing)
+		return
+	}
+	q := r.URL.Query()
+	meta := hecEvent{Host: q.Get("host"), Source: q.Get("source"), Sourcetype: q.Get("sourcetype"), Index: q.Get("index")}
+	sc := bufio.NewScanner(io.LimitReader(r.Body, maxRequestBody))
+	sc.Buffer(make([]byte, 64*1024), maxRequestBody)
+	n := 0
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		meta.Event, _ = json.Marshal(line)
+		raw, err := i.entryFromEvent(meta)
+		if err != nil {
+			continue
+		}
+		if err := inputs.InsertContext(r.Context(), i.buffer, raw); err != nil {
+			i.writeBusy(w, err)
+			return
+		}
+		n++
+	}
+	if n == 0 {
+		writeJSON(w, http.StatusBadRequest, respNoData)
+		return
+	}
+	i.writeSuccess(w, channel)
+}
+
+// writeBusy answers like a Splunk indexer whose queues are full, so forwarders back off and retry.
+func (i *Input) writeBusy(w http.ResponseWriter, err error) {
+	w.Header().Set("Retry-After", "1")
+	writeJSON(w, inputs.BackpressureStatus(err), respServerBusy)
+}
+
+// handleAck answers {"acks":[...]} with the status of each ID. Events are inserted into the buffer
+// before the response is sent, so every ID issued on the channel is reported as indexed. An ID
+// dropped by the ack bounds is reported false, and the client resends its events.
+func (i *Input) handleAck(w http.ResponseWriter, r *http.Request) {
+	channel := channelOf(r)
+	if channel == "" {
+		writeJSON(w, http.StatusBadRequest, respChannelMissing)
+		return
+	}
+	var req struct {
+		Acks []int64 `json:"acks"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, respInvalidFormat)
+		return
+	}
+	out := make(map[string]bool, len(req.Acks))
+	i.ackMu.Lock()
+	now := i.now()
+	i.sweep(now)
+	ch := i.pending[channel]
+	for _, id := range req.Acks {
+		ok := false
+		if ch != nil {
+			_, ok = ch.ids[id]
+			delete(ch.ids, id)
+		}
+		out[strconv.FormatInt(id, 10)] = ok
+	}
+	if ch != nil {
+		ch.used = now
+		if len(ch.ids) == 0 {
+			delete(i.pending, channel)
+		}
+	}
+	i.ackMu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"acks": out})
+}
+
+func (i *Input) writeSuccess(w http.ResponseWriter, channel string) {
+	if !i.ackEnabled {
+		writeJSON(w, http.StatusOK, respSuccess)
+		return
+	}
+	i.ackMu.Lock()
+	now := i.now()
+	i.sweep(now)
+	id := i.nextAck
+	i.nextAck++
+	ch := i.pending[channel]
+	if ch == nil {
+		if len(i.pending) >= maxAckChannels {
+			i.dropLeastUsedChannel()
+		}
+		ch = &ackChannel{ids: make(map[int64]time.Time)}
+		i.pending[channel] = ch
+	}
+	if len(ch.ids) >= maxAcksPerChannel {
+		oldest := id
+		for old := range ch.ids {
+			oldest = min(oldest, old)
+		}
+		delete(ch.ids, oldest)
+	}
+	ch.ids[id] = now
+	ch.used = now
+	i.ackMu.Unlock()
+	resp := respSuccess
+	resp.AckID = &id
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// sweep drops the ack IDs issued more than ackTTL ago, at most once every tenth of it.
+// ackMu must be held.
+func (i *Input) sweep(now time.Time) {
+	if now.Sub(i.lastSweep) < ackTTL/10 {
+		return
+	}
+	i.lastSweep = now
+	for name, ch := range i.pending {
+		for id, issued := range ch.ids {
+			if now.Sub(issued) > ackTTL {
+				delete(ch.ids, id)
+			}
+		}
+		if len(ch.ids) == 0 {
+			delete(i.pending, name)
+		}
+	}
+}
+
+// dropLeastUsedChannel forgets the channel issued or queried longest ago. ackMu must be held.
+func (i *Input) dropLeastUsedChannel() {
+	var victim string
+	var used time.Time
+	for name, ch := range i.pending {
+		if victim == "" || ch.used.Before(used) {
+			victim, used = name, ch.used
+		}
+	}
+	delete(i.pending, victim)
+}
+
+// entryFromEvent maps a HEC event onto a LogEntry. Object events are flattened via RecordToEntry;
+// host, source, sourcetype, index and indexed fields become tags.
+func (i *Input) entryFromEvent(ev hecEvent) ([]byte, error) {
+	service := ev.Sourcetype
+	if ev.Source != "" {
+		service = ev.Source
+	}
+	if service == "" {
+		service = i.service
+	}
+	var entry model.LogEntry
+	var record map[string]any
+	var text string
+	switch {
+	case json.Unmarshal(ev.Event, &record) == nil:
+		entry = inputs.RecordToEntry(record, service)
+	case json.Unmarshal(ev.Event, &text) == nil:
+		entry = model.LogEntry{Service: service, Level: "info", Message: text, Tags: make(map[string]string)}
+	default:
+		entry = model.LogEntry{Service: service, Level: "info", Message: string(ev.Event), Tags: make(map[string]string)}
+	}
+	if ts, ok := parseHECTime(ev.Time); ok {
+		entry.Timestamp = ts.Format(time.RFC3339Nano)
+	} else if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	for k, v := range map[string]string{"host": ev.Host, "source": ev.Source, "sourcetype": ev.Sourcetype, "index": ev.Index} {
+		if v != "" {
+			entry.Tags[k] = v
+		}
+	}
+	for k, v := range ev.Fields {
+		entry.Tags[k] = inputs.StringifyValue(v)
+	}
+	return json.Marshal(entry)
+}
+
+// parseHECTime accepts epoch seconds with optional fraction, as a number or a string.
+func parseHECTime(raw json.RawMessage) (time.Time, bool) {
+	s := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if s == "" || s == "null" {
+		return time.Time{}, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC(), true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.listenAddr,
+		Handler: tracing.Handler("ingest.splunk_hec", i.Handler()),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[hec] listener %s: %v", i.listenAddr, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.listenAddr, err))
+		}
+	}()
+	log.Printf("[hec] listening on %s", i.listenAddr)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
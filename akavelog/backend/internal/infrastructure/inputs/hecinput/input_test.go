@@ -0,0 +1,176 @@
+package hecinput
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+	err  error
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func post(t *testing.T, in *Input, path, channel, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Splunk tok")
+	if channel != "" {
+		req.Header.Set("X-Splunk-Request-Channel", channel)
+	}
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: response %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// send posts one event on channel and returns its ack ID.
+func send(t *testing.T, in *Input, channel string) int64 {
+	t.Helper()
+	code, resp := post(t, in, "/services/collector/event", channel, `{"event":"hello"}`)
+	id, ok := resp["ackId"].(float64)
+	if code != http.StatusOK || !ok {
+		t.Fatalf("event: %d %v", code, resp)
+	}
+	return int64(id)
+}
+
+// query asks which of ids are acked on channel.
+func query(t *testing.T, in *Input, channel string, ids ...int64) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string][]int64{"acks": ids})
+	code, resp := post(t, in, "/services/collector/ack", channel, string(body))
+	if code != http.StatusOK {
+		t.Fatalf("ack: %d %v", code, resp)
+	}
+	return resp["acks"].(map[string]any)
+}
+
+func TestEvent(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(":0", []string{"tok"}, false, "splunk", buf)
+	code, resp := post(t, in, "/services/collector/event", "",
+		`{"time":1700000000.5,"host":"web-1","sourcetype":"nginx","event":{"message":"GET /","level":"warn"},"fields":{"env":"prod"}}{"event":"second"}`)
+	if code != http.StatusOK || resp["code"] != float64(0) {
+		t.Fatalf("event: %d %v", code, resp)
+	}
+	if len(buf.msgs) != 2 {
+		t.Fatalf("stored %d entries, want 2", len(buf.msgs))
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "GET /" || entry.Service != "nginx" || entry.Tags["host"] != "web-1" || entry.Tags["env"] != "prod" ||
+		entry.Timestamp != "2023-11-14T22:13:20.5Z" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	if code, resp := post(t, in, "/services/collector/event", "", `{"event":"ok"}{"time":1}`); code != http.StatusBadRequest || resp["code"] != float64(6) {
+		t.Errorf("event without event: %d %v", code, resp)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/services/collector/event", strings.NewReader(`{"event":"x"}`))
+	req.Header.Set("Authorization", "Splunk wrong")
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("wrong token: status = %d", rec.Code)
+	}
+}
+
+func TestBusy(t *testing.T) {
+	buf := &memBuffer{err: inputs.ErrBufferFull}
+	in := NewInput(":0", []string{"tok"}, true, "splunk", buf)
+	for _, path := range []string{"/services/collector/event", "/services/collector/raw"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"event":"hello"}`))
+		req.Header.Set("Authorization", "Splunk tok")
+		req.Header.Set("X-Splunk-Request-Channel", "ch")
+		rec := httptest.NewRecorder()
+		in.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" || !strings.Contains(rec.Body.String(), `"code":9`) {
+			t.Errorf("%s: %d %v %s", path, rec.Code, rec.Header(), rec.Body)
+		}
+	}
+	if len(in.pending) != 0 {
+		t.Errorf("ack IDs issued for refused requests: %v", in.pending)
+	}
+}
+
+func TestAck(t *testing.T) {
+	in := NewInput(":0", []string{"tok"}, true, "splunk", &memBuffer{})
+	if code, resp := post(t, in, "/services/collector/event", "", `{"event":"hello"}`); code != http.StatusBadRequest || resp["code"] != float64(10) {
+		t.Errorf("without channel: %d %v", code, resp)
+	}
+	a, b := send(t, in, "ch1"), send(t, in, "ch1")
+	other := send(t, in, "ch2")
+	acks := query(t, in, "ch1", a, b, other)
+	if acks[fmt.Sprint(a)] != true || acks[fmt.Sprint(b)] != true || acks[fmt.Sprint(other)] != false {
+		t.Errorf("acks = %v", acks)
+	}
+	// An ID is reported once.
+	if acks := query(t, in, "ch1", a); acks[fmt.Sprint(a)] != false {
+		t.Errorf("queried again: %v", acks)
+	}
+	if _, ok := in.pending["ch1"]; ok {
+		t.Error("channel kept after its last ID was queried")
+	}
+}
+
+func TestAckBounds(t *testing.T) {
+	in := NewInput(":0", []string{"tok"}, true, "splunk", &memBuffer{})
+	now := time.Date(2024, 2, 17, 12, 0, 0, 0, time.UTC)
+	in.now = func() time.Time { return now }
+
+	first := send(t, in, "busy")
+	for n := 1; n < maxAcksPerChannel+1; n++ {
+		send(t, in, "busy")
+	}
+	if got := len(in.pending["busy"].ids); got != maxAcksPerChannel {
+		t.Errorf("channel holds %d IDs, want %d", got, maxAcksPerChannel)
+	}
+	if acks := query(t, in, "busy", first, first+1); acks[fmt.Sprint(first)] != false || acks[fmt.Sprint(first+1)] != true {
+		t.Errorf("oldest ID not dropped: %v", acks)
+	}
+
+	for n := 0; n < maxAckChannels; n++ {
+		now = now.Add(time.Millisecond)
+		send(t, in, fmt.Sprintf("ch%d", n))
+	}
+	if len(in.pending) != maxAckChannels {
+		t.Errorf("%d channels, want %d", len(in.pending), maxAckChannels)
+	}
+	if _, ok := in.pending["busy"]; ok {
+		t.Error("least recently used channel kept")
+	}
+
+	late := send(t, in, "late")
+	now = now.Add(ackTTL + time.Minute)
+	if acks := query(t, in, "late", late); acks[fmt.Sprint(late)] != false {
+		t.Errorf("expired ID reported: %v", acks)
+	}
+	if len(in.pending) != 0 {
+		t.Errorf("%d channels kept after expiry", len(in.pending))
+	}
+}
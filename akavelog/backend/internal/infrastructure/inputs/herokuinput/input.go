@@ -0,0 +1,163 @@
+package herokuinput
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const maxRequestBody = 16 << 20
+
+// Config holds the parsed settings of a heroku_drain input.
+type Config struct {
+	Listen      string
+	Path        string
+	DrainTokens []string // empty = any drain
+	Username    string   // empty = no basic auth
+	Password    string
+	Service     string
+}
+
+// Input receives Logplex HTTPS drain batches.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	buffer inputs.InputBuffer
+	server *http.Server
+}
+
+// NewInput creates a heroku_drain input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer}
+}
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.cfg.Path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !i.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="akavelog"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := r.Header.Get("Logplex-Drain-Token")
+		if !i.validToken(token) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		frames, err := readFrames(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			log.Printf("[heroku] drain %s: %v", token, err)
+			// Frames before the malformed one are kept; Logplex does not retry 4xx batches.
+		}
+		if want, err := strconv.Atoi(r.Header.Get("Logplex-Msg-Count")); err == nil && want != len(frames) {
+			log.Printf("[heroku] drain %s: Logplex-Msg-Count %d, parsed %d frames", token, want, len(frames))
+		}
+		frameID := r.Header.Get("Logplex-Frame-Id")
+		for _, f := range frames {
+			if err := i.insert(f, token, frameID); err != nil {
+				// Logplex retries the whole batch on 5xx/429.
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(inputs.BackpressureStatus(err))
+				return
+			}
+		}
+		// Logplex treats any 2xx as delivered; 204 keeps responses minimal.
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func (i *Input) authorized(r *http.Request) bool {
+	if i.cfg.Username == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(i.cfg.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(i.cfg.Password)) == 1
+}
+
+func (i *Input) validToken(token string) bool {
+	if len(i.cfg.DrainTokens) == 0 {
+		return true
+	}
+	valid := false
+	for _, t := range i.cfg.DrainTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (i *Input) insert(frame, token, frameID string) error {
+	entry := model.LogEntry{Service: i.cfg.Service, Level: "info", Message: frame, Tags: map[string]string{}}
+	if m, err := parseSyslog(frame); err == nil {
+		entry.Level = m.Level()
+		entry.Message = m.Message
+		if !m.Timestamp.IsZero() {
+			entry.Timestamp = m.Timestamp.UTC().Format(time.RFC3339Nano)
+		}
+		for k, v := range map[string]string{"syslog_app": m.AppName, "dyno": m.ProcID, "host": m.Hostname} {
+			if v != "" {
+				entry.Tags[k] = v
+			}
+		}
+	}
+	if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	if token != "" {
+		entry.Tags["drain_token"] = token
+	}
+	if frameID != "" {
+		entry.Tags["logplex_frame_id"] = frameID
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[heroku] marshal entry: %v", err)
+		return nil
+	}
+	return i.buffer.Insert(raw)
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.cfg.Listen,
+		Handler: i.Handler(),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[heroku] listener %s: %v", i.cfg.Listen, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.cfg.Listen, err))
+		}
+	}()
+	log.Printf("[heroku] listening on %s%s", i.cfg.Listen, i.cfg.Path)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
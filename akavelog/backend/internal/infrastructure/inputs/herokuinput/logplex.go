@@ -0,0 +1,130 @@
+package herokuinput
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFrameSize = 1 << 20
+
+// syslogMessage is one parsed RFC 5424 frame as sent by Logplex.
+type syslogMessage struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string // "app" for application output, "heroku" for platform messages
+	ProcID    string // dyno, e.g. web.1
+	MsgID     string
+	Message   string
+}
+
+// severityLevels maps syslog severities 0-7 onto LogEntry levels.
+var severityLevels = [...]string{"fatal", "fatal", "fatal", "error", "warn", "info", "info", "debug"}
+
+func (m syslogMessage) Level() string {
+	if m.Severity >= 0 && m.Severity < len(severityLevels) {
+		return severityLevels[m.Severity]
+	}
+	return "info"
+}
+
+// readFrames splits an octet-counted body ("<len> <frame><len> <frame>...", RFC 6587).
+func readFrames(r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+	var frames []string
+	for {
+		lenStr, err := br.ReadString(' ')
+		if errors.Is(err, io.EOF) && strings.TrimSpace(lenStr) == "" {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("frame length: %w", err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(lenStr))
+		if err != nil || n <= 0 || n > maxFrameSize {
+			return frames, fmt.Errorf("bad frame length %q", strings.TrimSpace(lenStr))
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return frames, fmt.Errorf("frame truncated: %w", err)
+		}
+		frames = append(frames, string(buf))
+	}
+}
+
+// parseSyslog parses "<PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG".
+// Logplex omits structured data entirely, so SD is only skipped when it is clearly present.
+func parseSyslog(frame string) (syslogMessage, error) {
+	var m syslogMessage
+	frame = strings.TrimRight(frame, "\r\n")
+	if !strings.HasPrefix(frame, "<") {
+		return m, fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(frame, '>')
+	if end < 2 {
+		return m, fmt.Errorf("bad priority")
+	}
+	pri, err := strconv.Atoi(frame[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return m, fmt.Errorf("bad priority %q", frame[1:end])
+	}
+	m.Facility, m.Severity = pri/8, pri%8
+	fields := strings.SplitN(frame[end+1:], " ", 7)
+	if len(fields) < 6 {
+		return m, fmt.Errorf("truncated header")
+	}
+	if fields[1] != "-" {
+		if m.Timestamp, err = time.Parse(time.RFC3339Nano, fields[1]); err != nil {
+			return m, fmt.Errorf("bad timestamp %q", fields[1])
+		}
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = nilValue(fields[2]), nilValue(fields[3]), nilValue(fields[4]), nilValue(fields[5])
+	if len(fields) == 7 {
+		m.Message = skipStructuredData(fields[6])
+	}
+	return m, nil
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// skipStructuredData drops leading "[id k=v ...]" elements.
+func skipStructuredData(s string) string {
+	for strings.HasPrefix(s, "[") {
+		end := structuredDataEnd(s)
+		if end < 0 {
+			return s
+		}
+		s = s[end+1:]
+	}
+	return strings.TrimPrefix(s, " ")
+}
+
+// structuredDataEnd returns the index of the ']' closing the element at s[0], honouring
+// quoted, backslash-escaped parameter values.
+func structuredDataEnd(s string) int {
+	inQuote := false
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuote = !inQuote
+		case ']':
+			if !inQuote {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,53 @@
+package herokuinput
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func frame(s string) string {
+	return fmt.Sprintf("%d %s", len(s), s)
+}
+
+func TestReadFramesAndParse(t *testing.T) {
+	f1 := "<158>1 2012-11-30T06:45:29.123+00:00 host heroku router - at=info method=GET path=\"/\" status=200"
+	f2 := "<190>1 2012-11-30T06:45:30+00:00 host app web.3 - State changed from starting to up\n"
+	f3 := "<11>1 - host app worker.1 - [meta key=\"v]al\"] disk full"
+	frames, err := readFrames(strings.NewReader(frame(f1) + frame(f2) + frame(f3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+
+	m, err := parseSyslog(frames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.AppName != "heroku" || m.ProcID != "router" || m.Level() != "info" || !strings.HasPrefix(m.Message, "at=info") {
+		t.Fatalf("frame 1: %+v", m)
+	}
+	if !m.Timestamp.Equal(time.Date(2012, 11, 30, 6, 45, 29, 123e6, time.UTC)) {
+		t.Fatalf("timestamp: %v", m.Timestamp)
+	}
+
+	m, _ = parseSyslog(frames[1])
+	if m.ProcID != "web.3" || m.Message != "State changed from starting to up" {
+		t.Fatalf("frame 2: %+v", m)
+	}
+
+	m, _ = parseSyslog(frames[2])
+	if m.Level() != "error" || m.Message != "disk full" || !m.Timestamp.IsZero() {
+		t.Fatalf("frame 3: %+v", m)
+	}
+}
+
+func TestReadFrames_BadLength(t *testing.T) {
+	frames, err := readFrames(strings.NewReader(frame("<13>1 - h a p - ok") + "x <13>"))
+	if err == nil || len(frames) != 1 {
+		t.Fatalf("frames=%d err=%v", len(frames), err)
+	}
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
 )
 
+const (
+	defaultMaxBodySize = 10 << 20
+	defaultMaxEntries  = 10000
+)
+
 // Factory creates HTTP ingest inputs. Registers as "http".
 type Factory struct{}
 
@@ -16,17 +21,26 @@ func (f *Factory) Name() string {
 }
 
 func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	fields := []inputs.ConfigField{
+		{Name: "listen", Type: "string", Required: true, Description: "host:port to bind (e.g. :9001). Must be unique across inputs.", Example: ":9001"},
+		{Name: "base_path", Type: "string", Required: false, Description: "Path served on the listen port", Example: "/ingest"},
+		{Name: "auth_token", Type: "string", Required: false, Description: "Accepted token, or a list / comma-separated tokens, sent as Authorization: Bearer <token> or X-Akavelog-Token. Empty disables authentication."},
+		{Name: "max_body_bytes", Type: "number", Required: false, Description: "Requests with larger bodies are rejected with 413 (default 10485760)", Example: "10485760"},
+		{Name: "requests_per_second", Type: "number", Required: false, Description: "Requests per second accepted across all clients; excess gets 429. 0 disables the limit (default)", Example: "200"},
+		{Name: "burst", Type: "number", Required: false, Description: "Requests allowed at once above requests_per_second (default 2x requests_per_second)", Example: "400"},
+		{Name: "max_entries_per_request", Type: "number", Required: false, Description: "Entries a JSON-array or NDJSON body may contain; larger batches get 413 (default 10000)", Example: "10000"},
+		inputs.AckModeField,
+	}
 	return inputs.InputTypeInfo{
 		Type:        "http",
-		Description: "HTTP ingest endpoint on its own port. Each input listens on host:port and serves POST /ingest. Nothing is mounted on the main server.",
-		Fields: []inputs.ConfigField{
-			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind (e.g. :9001). Must be unique across inputs.", Example: ":9001"},
-			{Name: "base_path", Type: "string", Required: false, Description: "Path served on the listen port", Example: "/ingest"},
-		},
+		Description: "HTTP ingest endpoint on its own port. Each input listens on host:port and serves POST /ingest, over HTTPS when tls_cert/tls_key are set. Nothing is mounted on the main server.",
+		Fields:      append(fields, inputs.TLSFields...),
+		IngestKeys:  true,
 	}
 }
 
-// ValidateConfig validates http input config. Listen is required (each input has its own port).
+// ValidateConfig validates http input config. Listen is required (each input has its own port)
+// and TLS certificate files, when set, must load.
 func (f *Factory) ValidateConfig(cfg inputs.Config) error {
 	listen, _ := cfg["listen"].(string)
 	listen = strings.TrimSpace(listen)
@@ -36,7 +50,8 @@ func (f *Factory) ValidateConfig(cfg inputs.Config) error {
 	if !validListenAddr(listen) {
 		return fmt.Errorf("listen must be host:port or :port (e.g. :9001 or 0.0.0.0:9001)")
 	}
-	return nil
+	_, err := parseConfig(cfg)
+	return err
 }
 
 func validListenAddr(addr string) bool {
@@ -61,9 +76,60 @@ func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.M
 	if strings.TrimSpace(listen) == "" {
 		return nil, fmt.Errorf("listen is required for http input")
 	}
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	listen, _ := cfg["listen"].(string)
 	basePath, _ := cfg["base_path"].(string)
 	if basePath == "" {
 		basePath = "/ingest"
 	}
-	return NewInput(basePath, "", buffer, listen), nil
+	c := Config{
+		BasePath:    basePath,
+		Listen:      strings.TrimSpace(listen),
+		AuthTokens:  cfg.Strings("auth_token"),
+		MaxBodySize: defaultMaxBodySize,
+		MaxEntries:  defaultMaxEntries,
+	}
+	if v, ok := cfg.Int("max_entries_per_request"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("max_entries_per_request must be positive")
+		}
+		c.MaxEntries = v
+	}
+	if v, ok := cfg.Int("max_body_bytes"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("max_body_bytes must be positive")
+		}
+		c.MaxBodySize = int64(v)
+	}
+	if v, ok := cfg.Int("requests_per_second"); ok {
+		if v < 0 {
+			return c, fmt.Errorf("requests_per_second must not be negative")
+		}
+		c.RateLimit = float64(v)
+	}
+	c.Burst = 2 * int(c.RateLimit)
+	if v, ok := cfg.Int("burst"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("burst must be positive")
+		}
+		c.Burst = v
+	}
+	ack, err := inputs.AckModeFromConfig(cfg)
+	if err != nil {
+		return c, err
+	}
+	c.AckMode = ack
+	tc, err := inputs.ServerTLSFromConfig(cfg)
+	if err != nil {
+		return c, err
+	}
+	c.TLS = tc
+	return c, nil
 }
@@ -1,56 +1,106 @@
 package httpinput
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/akave-ai/akavelog/internal/idempotency"
 	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
 	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
 )
 
 const maxLoggedBody = 2048
 const maxBodyInRawLog = 64 * 1024 // 64KB max body stored in raw_request
 
+// Config holds the parsed settings of an http input.
+type Config struct {
+	BasePath    string
+	Description string      // path suffix when mounted without Listen
+	Listen      string      // optional; empty means mount-only
+	AuthTokens  []string    // empty = no authentication
+	TLS         *tls.Config // nil serves plain HTTP
+	MaxBodySize int64       // larger bodies get 413
+	RateLimit   float64     // requests per second across all clients; 0 = unlimited
+	Burst       int
+	MaxEntries  int    // entries one JSON-array/NDJSON request may carry; 0 = unlimited
+	AckMode     string // inputs.AckAccepted (default) or inputs.AckDurable
+}
+
+// Receipt is the body of the 202 answer to an ingest request.
+type Receipt struct {
+	IngestID string `json:"ingest_id"` // also the ingest_id tag of the request's raw log entry
+	AckMode  string `json:"ack_mode"`
+	Entries  int    `json:"entries"` // entries of the body, besides the raw log entry
+	// IDs are the _id of each entry of the body in durable mode, in order; "" for entries
+	// that were not valid.
+	IDs []string `json:"ids,omitempty"`
+}
+
 // Input is an HTTP ingest endpoint that writes request body to an InputBuffer.
 // It also logs the full HTTP request (method, path, query, headers, body) as a raw log entry.
 type Input struct {
+	inputs.HealthState
+
 	path       string
 	listenAddr string
+	tokens     []string
+	keys       inputs.IngestKeys // optional; see SetIngestKeys
+	tls        *tls.Config
+	maxBody    int64
+	maxEntries int
+	durable    bool
+	limiter    *rate.Limiter
 	buffer     inputs.InputBuffer
+	metrics    *inputs.Metrics
 	server     *http.Server
 }
 
-// NewInput creates an HTTP input. listenAddr is optional; if set, Start() binds to that address
-// and the path is just basePath (e.g. /ingest). Otherwise path is basePath/description (e.g. /ingest/raw).
-func NewInput(
-	basePath string,
-	description string,
-	buffer inputs.InputBuffer,
-	listenAddr string,
-) *Input {
-	basePath = "/" + strings.Trim(strings.TrimSpace(basePath), "/")
+// NewInput creates an HTTP input. Listen is optional; if set, Start() binds to that address
+// and the path is just BasePath (e.g. /ingest). Otherwise path is BasePath/Description (e.g. /ingest/raw).
+// When AuthTokens is non-empty every request must carry one of them.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	basePath := "/" + strings.Trim(strings.TrimSpace(cfg.BasePath), "/")
 	if basePath == "/" {
 		basePath = "/ingest"
 	}
 	var path string
-	if listenAddr != "" {
+	if cfg.Listen != "" {
 		path = basePath
 	} else {
-		desc := strings.TrimSpace(description)
+		desc := strings.TrimSpace(cfg.Description)
 		desc = strings.Trim(desc, "/")
 		if desc == "" {
 			desc = "raw"
 		}
 		path = basePath + "/" + desc
 	}
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.Burst)
+	}
 	return &Input{
 		path:       path,
-		listenAddr: listenAddr,
+		listenAddr: cfg.Listen,
+		tokens:     cfg.AuthTokens,
+		tls:        cfg.TLS,
+		maxBody:    cfg.MaxBodySize,
+		maxEntries: cfg.MaxEntries,
+		durable:    cfg.AckMode == inputs.AckDurable,
+		limiter:    limiter,
 		buffer:     buffer,
+		metrics:    inputs.MetricsOf(buffer),
 	}
 }
 
@@ -63,7 +113,38 @@ func corsHeaders(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Access-Control-Allow-Origin", origin)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Akavelog-Token, Idempotency-Key")
+}
+
+// SetIngestKeys makes the input accept the ingest keys of keys besides its auth_token, and
+// require one of them once there are any.
+func (i *Input) SetIngestKeys(keys inputs.IngestKeys) { i.keys = keys }
+
+// authorized reports whether r carries an accepted token or ingest key in Authorization: Bearer
+// or X-Akavelog-Token. It fails with inputs.ErrKeysUnavailable when the ingest keys could not
+// be looked up.
+func (i *Input) authorized(r *http.Request) (bool, error) {
+	keys := false
+	if i.keys != nil {
+		var err error
+		if keys, err = i.keys.Enabled(); err != nil {
+			return false, err
+		}
+	}
+	if len(i.tokens) == 0 && !keys {
+		return true, nil
+	}
+	token := inputs.RequestToken(r)
+	if token == "" {
+		return false, nil
+	}
+	valid := false
+	for _, t := range i.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid || (keys && i.keys.Accepts(token)), nil
 }
 
 func (i *Input) Handler() http.Handler {
@@ -73,11 +154,64 @@ func (i *Input) Handler() http.Handler {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		ok, err := i.authorized(r)
+		if err != nil {
+			i.metrics.Error()
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if !ok {
+			i.metrics.Error()
+			w.Header().Set("WWW-Authenticate", `Bearer realm="akavelog"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !i.limiter.Allow() {
+			i.metrics.Rejected()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		if i.maxBody > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, i.maxBody)
+		}
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				i.metrics.Rejected()
+				http.Error(w, fmt.Sprintf("body exceeds %d bytes", i.maxBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			i.metrics.Error()
 			http.Error(w, "read error", http.StatusBadRequest)
 			return
 		}
+		body, err = inputs.DecodeContent(r.Header.Get("Content-Encoding"), body, i.maxBody)
+		switch {
+		case err == nil:
+		case errors.Is(err, inputs.ErrContentTooLarge):
+			i.metrics.Rejected()
+			http.Error(w, fmt.Sprintf("decoded body exceeds %d bytes", i.maxBody), http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, inputs.ErrUnsupportedEncoding):
+			i.metrics.Error()
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		default:
+			i.metrics.Error()
+			http.Error(w, "decode body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Split before logging anything so an oversized batch is rejected as a whole and the
+		// client can resend it in smaller pieces.
+		entries := inputs.SplitBatch(body)
+		if i.maxEntries > 0 && len(entries) > i.maxEntries {
+			i.metrics.Rejected()
+			http.Error(w, fmt.Sprintf("batch has %d entries, limit is %d", len(entries), i.maxEntries), http.StatusRequestEntityTooLarge)
+			return
+		}
 
 		// Build full request data for raw log (method, path, query, headers, body)
 		headers := make(map[string]string)
@@ -97,33 +231,61 @@ func (i *Input) Handler() http.Handler {
 			Headers: headers,
 			Body:    bodyStr,
 		}
+		receipt := Receipt{IngestID: uuid.New().String(), AckMode: inputs.AckAccepted, Entries: len(entries)}
+		ctx := r.Context()
+		if i.durable {
+			receipt.AckMode = inputs.AckDurable
+			ctx = inputs.WithDurable(ctx)
+		}
 		entry := model.LogEntry{
 			Timestamp:  time.Now().UTC().Format(time.RFC3339),
 			Service:    "ingest",
 			Level:      "info",
 			Message:    "raw http request",
-			Tags:       map[string]string{"path": r.URL.Path},
+			Tags:       map[string]string{"path": r.URL.Path, "ingest_id": receipt.IngestID},
 			RawRequest: rawReq,
 		}
 		rawLogJSON, err := json.Marshal(entry)
 		if err != nil {
+			i.metrics.Error()
 			log.Printf("[ingest] marshal raw log: %v", err)
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		i.buffer.Insert(rawLogJSON)
+		if err := inputs.InsertContext(ctx, i.buffer, rawLogJSON); err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), inputs.BackpressureStatus(err))
+			return
+		}
 
-		// If body present, also insert as-is so normal log payloads are still ingested
+		// If body present, also insert it so normal log payloads are still ingested. JSON arrays and
+		// NDJSON batches are inserted entry by entry; any other body is inserted as-is.
+		var ids *inputs.EntryIDs
+		if i.durable {
+			ctx, ids = inputs.WithEntryIDs(ctx)
+		}
 		if len(body) > 0 {
 			preview := string(body)
 			if len(preview) > maxLoggedBody {
 				preview = preview[:maxLoggedBody] + "..."
 			}
 			log.Printf("[ingest] received %d bytes: %s", len(body), preview)
-			i.buffer.Insert(body)
+			for n, p := range entries {
+				if err := inputs.InsertContext(ctx, i.buffer, p); err != nil {
+					// The entries before n were taken; a client that resends the batch repeats them.
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, fmt.Sprintf("accepted %d of %d entries: %v", n, len(entries), err), inputs.BackpressureStatus(err))
+					return
+				}
+			}
 		}
 
+		if ids != nil {
+			receipt.IDs = ids.List()
+		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(receipt)
 	})
 }
 
@@ -131,16 +293,27 @@ func (i *Input) Start() error {
 	if i.listenAddr == "" {
 		return nil
 	}
+	// Mounted under /ingest the server's store deduplicates retries; on its own port the input
+	// keeps its own.
 	i.server = &http.Server{
-		Addr:    i.listenAddr,
-		Handler: i.Handler(),
+		Addr:      i.listenAddr,
+		Handler:   tracing.Handler("ingest.http", idempotency.NewStore(idempotency.Config{}).Middleware(i.Handler())),
+		TLSConfig: i.tls,
 	}
 	go func() {
-		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if i.tls != nil {
+			// Certificates are already loaded into TLSConfig.
+			err = i.server.ListenAndServeTLS("", "")
+		} else {
+			err = i.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Printf("[ingest] listener %s: %v", i.listenAddr, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.listenAddr, err))
 		}
 	}()
-	log.Printf("[ingest] listening on %s", i.listenAddr)
+	log.Printf("[ingest] listening on %s (tls=%t)", i.listenAddr, i.tls != nil)
 	return nil
 }
 
@@ -150,3 +323,8 @@ func (i *Input) Stop() error {
 	}
 	return nil
 }
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
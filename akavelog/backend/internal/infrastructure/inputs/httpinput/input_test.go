@@ -2,8 +2,13 @@ package httpinput
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
@@ -15,12 +20,13 @@ type memBuffer struct {
 	msgs [][]byte
 }
 
-func (b *memBuffer) Insert(p []byte) {
+func (b *memBuffer) Insert(p []byte) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	cp := make([]byte, len(p))
 	copy(cp, p)
 	b.msgs = append(b.msgs, cp)
+	return nil
 }
 
 func (b *memBuffer) Last() []byte {
@@ -63,3 +69,227 @@ func TestHTTPInput_InsertsBodyIntoBuffer(t *testing.T) {
 		t.Fatalf("expected inserted %q, got %q", string(body), string(got))
 	}
 }
+
+func TestHTTPInput_RequiresToken(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(Config{BasePath: "/ingest", Listen: ":0", AuthTokens: []string{"s3cret"}}, buf)
+	srv := httptest.NewServer(in.Handler())
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong bearer", "Authorization", "Bearer nope", http.StatusUnauthorized},
+		{"bearer", "Authorization", "Bearer s3cret", http.StatusAccepted},
+		{"header", "X-Akavelog-Token", "s3cret", http.StatusAccepted},
+	}
+	accepted := 0
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ingest", bytes.NewReader([]byte(`{"message":"hi"}`)))
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: post: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusAccepted {
+			accepted++
+		}
+	}
+	// Each accepted request inserts its raw request entry and the entry of its body; refused
+	// ones insert nothing.
+	if accepted != 2 || len(buf.msgs) != 2*accepted {
+		t.Fatalf("%d requests accepted with %d inserts; want 2 accepted with 2 inserts each", accepted, len(buf.msgs))
+	}
+}
+
+// memKeys are ingest keys that can change while the input runs.
+type memKeys struct {
+	mu   sync.Mutex
+	keys map[string]bool
+	err  error // of Enabled, as when the keys cannot be looked up
+}
+
+func (k *memKeys) Enabled() (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.keys) > 0, k.err
+}
+
+func (k *memKeys) Accepts(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.keys[key]
+}
+
+func TestHTTPInput_IngestKeys(t *testing.T) {
+	keys := &memKeys{keys: map[string]bool{}}
+	in := NewInput(Config{BasePath: "/ingest", Listen: ":0", AuthTokens: []string{"s3cret"}}, &memBuffer{})
+	in.SetIngestKeys(keys)
+	srv := httptest.NewServer(in.Handler())
+	defer srv.Close()
+
+	post := func(token string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ingest", bytes.NewReader([]byte(`{"message":"hi"}`)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := post("aki_old"); got != http.StatusUnauthorized {
+		t.Fatalf("no keys yet: expected 401, got %d", got)
+	}
+	keys.mu.Lock()
+	keys.keys["aki_old"], keys.keys["aki_new"] = true, true
+	keys.mu.Unlock()
+	for _, token := range []string{"aki_old", "aki_new", "s3cret"} {
+		if got := post(token); got != http.StatusAccepted {
+			t.Fatalf("%s: expected 202, got %d", token, got)
+		}
+	}
+	// Rotation is seen by the running listener.
+	keys.mu.Lock()
+	delete(keys.keys, "aki_old")
+	keys.mu.Unlock()
+	if got := post("aki_old"); got != http.StatusUnauthorized {
+		t.Fatalf("expired key: expected 401, got %d", got)
+	}
+	// Keys that cannot be looked up do not let requests in.
+	keys.mu.Lock()
+	keys.keys, keys.err = map[string]bool{}, inputs.ErrKeysUnavailable
+	keys.mu.Unlock()
+	if got := post("aki_new"); got != http.StatusServiceUnavailable {
+		t.Fatalf("keys unavailable: expected 503, got %d", got)
+	}
+}
+
+func TestHTTPInput_Limits(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(Config{BasePath: "/ingest", Listen: ":0", MaxBodySize: 8, RateLimit: 1, Burst: 3, MaxEntries: 1}, buf)
+	srv := httptest.NewServer(in.Handler())
+	defer srv.Close()
+
+	post := func(body string) int {
+		resp, err := http.Post(srv.URL+"/ingest", "text/plain", bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := post("this body is too long"); got != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", got)
+	}
+	if got := post("[1,2]"); got != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for too many entries, got %d", got)
+	}
+	if got := post("ok"); got != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", got)
+	}
+	if got := post("ok"); got != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after burst, got %d", got)
+	}
+}
+
+func TestHTTPInput_GzipBatch(t *testing.T) {
+	buf := &memBuffer{}
+	in := NewInput(Config{BasePath: "/ingest", Listen: ":0"}, buf)
+	srv := httptest.NewServer(in.Handler())
+	defer srv.Close()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("{\"service\":\"a\",\"message\":\"one\"}\n{\"service\":\"a\",\"message\":\"two\"}\n"))
+	_ = zw.Close()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ingest", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	// One raw request entry plus one entry per NDJSON line.
+	if len(buf.msgs) != 3 || string(buf.Last()) != `{"service":"a","message":"two"}` {
+		t.Fatalf("unexpected inserts %q", buf.msgs)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/ingest", bytes.NewReader([]byte("x")))
+	req.Header.Set("Content-Encoding", "br")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for br, got %d", resp.StatusCode)
+	}
+}
+
+// durableBuffer fails durable inserts with err and takes the others.
+type durableBuffer struct {
+	memBuffer
+	err error
+}
+
+func (b *durableBuffer) InsertContext(ctx context.Context, p []byte) error {
+	if inputs.Durable(ctx) && b.err != nil {
+		return b.err
+	}
+	inputs.RecordEntryID(ctx, fmt.Sprint("id-", len(b.msgs)))
+	return b.Insert(p)
+}
+
+func TestHTTPInput_AckMode(t *testing.T) {
+	if _, err := parseConfig(inputs.Config{"listen": ":0", "ack_mode": "eventually"}); err == nil {
+		t.Fatal("expected error for unknown ack_mode")
+	}
+	c, err := parseConfig(inputs.Config{"listen": ":0", "ack_mode": "Durable"})
+	if err != nil || c.AckMode != inputs.AckDurable {
+		t.Fatalf("ack_mode = %q, %v", c.AckMode, err)
+	}
+
+	buf := &durableBuffer{}
+	srv := httptest.NewServer(NewInput(c, buf).Handler())
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/ingest", "application/x-ndjson", strings.NewReader("{\"message\":\"a\"}\n{\"message\":\"b\"}\n"))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	var receipt Receipt
+	err = json.NewDecoder(resp.Body).Decode(&receipt)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status %d, decode: %v", resp.StatusCode, err)
+	}
+	if receipt.IngestID == "" || receipt.AckMode != inputs.AckDurable || receipt.Entries != 2 || fmt.Sprint(receipt.IDs) != "[id-1 id-2]" {
+		t.Fatalf("receipt = %+v", receipt)
+	}
+	if !strings.Contains(string(buf.msgs[0]), receipt.IngestID) {
+		t.Errorf("raw request entry lacks the ingest ID: %s", buf.msgs[0])
+	}
+
+	buf.err = inputs.ErrNotDurable
+	resp, err = http.Post(srv.URL+"/ingest", "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After when not durable, got %d", resp.StatusCode)
+	}
+}
@@ -3,10 +3,13 @@ package inputs
 import "net/http"
 
 // MessageInput is the minimal interface implemented by all input types.
-// It can be started and stopped.
+// It can be started and stopped, and reports whether it is still working after Start returned.
 type MessageInput interface {
 	Start() error
 	Stop() error
+	// Health returns nil while the input is receiving, or the error that stopped it
+	// (e.g. a listener that failed to bind). Most inputs embed HealthState.
+	Health() error
 }
 
 // HTTPEndpointInput is implemented by inputs that expose an HTTP endpoint.
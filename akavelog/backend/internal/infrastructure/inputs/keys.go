@@ -0,0 +1,41 @@
+package inputs
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrKeysUnavailable is returned by IngestKeys.Enabled when the keys of an input could not be
+// looked up and whether it requires any is not known. Inputs refuse requests with 503 then,
+// rather than letting them in unauthenticated.
+var ErrKeysUnavailable = errors.New("ingest keys unavailable")
+
+// IngestKeys are the ingest keys of one input, kept up to date while it runs so keys can be
+// rotated without restarting it.
+type IngestKeys interface {
+	// Enabled reports whether the input has keys; without any, only its own configured
+	// tokens (if any) are required. It fails with ErrKeysUnavailable when that is not known.
+	Enabled() (bool, error)
+	// Accepts reports whether key is one of the input's valid keys.
+	Accepts(key string) bool
+}
+
+// KeyedInput is implemented by inputs that accept per-input ingest keys. SetIngestKeys is
+// called before Start.
+type KeyedInput interface {
+	MessageInput
+	SetIngestKeys(keys IngestKeys)
+}
+
+// RequestToken returns the token r carries in the X-Akavelog-Token header or as
+// Authorization: Bearer, or "".
+func RequestToken(r *http.Request) string {
+	if token := strings.TrimSpace(r.Header.Get("X-Akavelog-Token")); token != "" {
+		return token
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package inputs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts the runtime activity of one input instance. Methods are safe for concurrent
+// use and do nothing on a nil receiver, so inputs can report without checking.
+type Metrics struct {
+	messages    atomic.Int64
+	bytes       atomic.Int64
+	errors      atomic.Int64
+	rejected    atomic.Int64
+	connections atomic.Int64
+	lastMessage atomic.Int64 // unix nanoseconds, 0 until the first message
+
+	// Prometheus series of the input, nil unless created by NewInputMetrics. Unlike the
+	// counters above they survive the input's restarts.
+	promMessages, promBytes, promErrors, promRejected prometheus.Counter
+}
+
+// MetricsSnapshot is a point-in-time copy of Metrics, as returned by the API.
+type MetricsSnapshot struct {
+	MessagesReceived int64      `json:"messages_received"`
+	BytesReceived    int64      `json:"bytes_received"`
+	Errors           int64      `json:"errors"`
+	Rejected         int64      `json:"requests_rejected"`
+	Connections      int64      `json:"connections"`
+	LastMessageAt    *time.Time `json:"last_message_at,omitempty"`
+}
+
+// NewMetrics returns zeroed Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// NewInputMetrics returns zeroed Metrics that also count into the /metrics series of input id
+// of type typ.
+func NewInputMetrics(id, typ string) *Metrics {
+	return &Metrics{
+		promMessages: metrics.InputMessages.WithLabelValues(id, typ),
+		promBytes:    metrics.InputBytes.WithLabelValues(id, typ),
+		promErrors:   metrics.InputErrors.WithLabelValues(id, typ),
+		promRejected: metrics.InputRejected.WithLabelValues(id, typ),
+	}
+}
+
+// Received records one message of n bytes.
+func (m *Metrics) Received(n int) {
+	if m == nil {
+		return
+	}
+	m.messages.Add(1)
+	m.bytes.Add(int64(n))
+	m.lastMessage.Store(time.Now().UnixNano())
+	if m.promMessages != nil {
+		m.promMessages.Inc()
+		m.promBytes.Add(float64(n))
+	}
+}
+
+// Error records a failed read, decode or request.
+func (m *Metrics) Error() {
+	if m == nil {
+		return
+	}
+	m.errors.Add(1)
+	if m.promErrors != nil {
+		m.promErrors.Inc()
+	}
+}
+
+// Rejected records a request refused by a limit (size, rate) before it was read, or a payload
+// the buffer did not take.
+func (m *Metrics) Rejected() {
+	if m == nil {
+		return
+	}
+	m.rejected.Add(1)
+	if m.promRejected != nil {
+		m.promRejected.Inc()
+	}
+}
+
+// ConnOpened and ConnClosed track currently open client connections.
+func (m *Metrics) ConnOpened() {
+	if m == nil {
+		return
+	}
+	m.connections.Add(1)
+}
+
+func (m *Metrics) ConnClosed() {
+	if m == nil {
+		return
+	}
+	m.connections.Add(-1)
+}
+
+// Snapshot returns the current counter values.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	if m == nil {
+		return MetricsSnapshot{}
+	}
+	s := MetricsSnapshot{
+		MessagesReceived: m.messages.Load(),
+		BytesReceived:    m.bytes.Load(),
+		Errors:           m.errors.Load(),
+		Rejected:         m.rejected.Load(),
+		Connections:      m.connections.Load(),
+	}
+	if ns := m.lastMessage.Load(); ns != 0 {
+		t := time.Unix(0, ns).UTC()
+		s.LastMessageAt = &t
+	}
+	return s
+}
+
+// Add folds o into s: counters are summed and the latest message time is kept.
+func (s *MetricsSnapshot) Add(o MetricsSnapshot) {
+	s.MessagesReceived += o.MessagesReceived
+	s.BytesReceived += o.BytesReceived
+	s.Errors += o.Errors
+	s.Rejected += o.Rejected
+	s.Connections += o.Connections
+	if o.LastMessageAt != nil && (s.LastMessageAt == nil || o.LastMessageAt.After(*s.LastMessageAt)) {
+		s.LastMessageAt = o.LastMessageAt
+	}
+}
+
+// MeteredBuffer counts every payload against Metrics before passing it to InputBuffer, and
+// counts payloads InputBuffer pushes back as rejected. The backend hands one to each input it
+// creates, so messages and bytes are tracked for every type.
+type MeteredBuffer struct {
+	InputBuffer
+	Metrics *Metrics
+}
+
+func (b *MeteredBuffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+func (b *MeteredBuffer) InsertContext(ctx context.Context, p []byte) error {
+	b.Metrics.Received(len(p))
+	if err := InsertContext(ctx, b.InputBuffer, p); err != nil {
+		b.Metrics.Rejected()
+		return err
+	}
+	return nil
+}
+
+// MetricsOf returns the Metrics behind buffer if it is a MeteredBuffer, or nil. Inputs use it to
+// report connections and errors.
+func MetricsOf(buffer InputBuffer) *Metrics {
+	if mb, ok := buffer.(*MeteredBuffer); ok {
+		return mb.Metrics
+	}
+	return nil
+}
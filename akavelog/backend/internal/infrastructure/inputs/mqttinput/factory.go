@@ -0,0 +1,117 @@
+package mqttinput
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates MQTT subscriber inputs. Registers as "mqtt".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "mqtt"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "mqtt",
+		Description: "Subscribes to topics on an MQTT broker. Each message is one log payload; with QoS 1 or 2 a message is acknowledged only after it is buffered, so the broker redelivers on reconnect.",
+		Fields: []inputs.ConfigField{
+			{Name: "broker", Type: "string", Required: true, Description: "Broker URL: tcp://, ssl://, tls://, ws:// or wss://", Example: "tcp://mqtt.local:1883"},
+			{Name: "topics", Type: "string", Required: true, Description: "Comma-separated topic filters (wildcards + and # allowed)", Example: "devices/+/logs,gateway/#"},
+			{Name: "qos", Type: "number", Required: false, Description: "Subscription QoS 0, 1 or 2 (default 1)", Example: "1"},
+			{Name: "client_id", Type: "string", Required: false, Description: "MQTT client ID. Keep it stable so the broker can keep the session (default: derived from the input ID)", Example: "akavelog-1"},
+			{Name: "clean_session", Type: "bool", Required: false, Description: "Start a clean session on connect; disables redelivery of messages queued while offline (default false)"},
+			{Name: "username", Type: "string", Required: false, Description: "Broker username"},
+			{Name: "password", Type: "string", Required: false, Description: "Broker password"},
+			{Name: "tls_ca_file", Type: "string", Required: false, Description: "PEM CA bundle used to verify the broker", Example: "/etc/akavelog/mqtt-ca.pem"},
+			{Name: "tls_cert_file", Type: "string", Required: false, Description: "PEM client certificate for mutual TLS"},
+			{Name: "tls_key_file", Type: "string", Required: false, Description: "PEM client key for mutual TLS"},
+			{Name: "tls_insecure_skip_verify", Type: "bool", Required: false, Description: "Skip broker certificate verification (testing only)"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name when a payload has none (default mqtt)", Example: "iot-fleet"},
+		},
+	}
+}
+
+// ValidateConfig validates mqtt input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer)
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Broker:   str("broker"),
+		Topics:   cfg.Strings("topics"),
+		QoS:      1,
+		ClientID: str("client_id"),
+		Username: str("username"),
+		Password: str("password"),
+		CAFile:   str("tls_ca_file"),
+		CertFile: str("tls_cert_file"),
+		KeyFile:  str("tls_key_file"),
+		Service:  str("service"),
+	}
+	if c.Broker == "" {
+		return c, fmt.Errorf("broker is required (e.g. tcp://mqtt.local:1883)")
+	}
+	u, err := url.Parse(c.Broker)
+	if err != nil || u.Host == "" {
+		return c, fmt.Errorf("broker must be a URL such as tcp://host:1883")
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return c, fmt.Errorf("broker scheme must be one of tcp, ssl, tls, ws, wss")
+	}
+	if len(c.Topics) == 0 {
+		return c, fmt.Errorf("topics is required: at least one topic filter")
+	}
+	if v, ok := cfg.Int("qos"); ok {
+		if v < 0 || v > 2 {
+			return c, fmt.Errorf("qos must be 0, 1 or 2")
+		}
+		c.QoS = byte(v)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return c, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	c.CleanSession, _ = cfg.Bool("clean_session")
+	c.InsecureSkipVerify, _ = cfg.Bool("tls_insecure_skip_verify")
+	if c.ClientID == "" {
+		c.ClientID = defaultClientID(str(inputs.InputIDKey), c)
+	}
+	if c.Service == "" {
+		c.Service = "mqtt"
+	}
+	return c, nil
+}
+
+// defaultClientID names the client after the input, or after its broker and topics for an
+// input without an ID, so the broker finds the persistent session again after a restart.
+// 23 characters is the longest client ID MQTT 3.1.1 brokers must accept.
+func defaultClientID(inputID string, c Config) string {
+	seed := inputID
+	if seed == "" {
+		seed = c.Broker + "|" + strings.Join(c.Topics, ",")
+	}
+	sum := sha256.Sum256([]byte(seed))
+	return "akavelog-" + hex.EncodeToString(sum[:])[:14]
+}
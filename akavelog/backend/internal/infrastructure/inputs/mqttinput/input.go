@@ -0,0 +1,169 @@
+package mqttinput
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const connectTimeout = 10 * time.Second
+
+// Config holds the parsed settings of an mqtt input.
+type Config struct {
+	Broker             string
+	Topics             []string
+	QoS                byte
+	ClientID           string
+	CleanSession       bool
+	Username           string
+	Password           string
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	Service            string
+}
+
+// Input subscribes to MQTT topics and writes each message to an InputBuffer.
+type Input struct {
+	cfg    Config
+	buffer inputs.InputBuffer
+	client mqtt.Client
+}
+
+// NewInput creates an mqtt input. TLS files are loaded here so bad paths fail on create.
+func NewInput(cfg Config, buffer inputs.InputBuffer) (*Input, error) {
+	i := &Input{cfg: cfg, buffer: buffer}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(cfg.CleanSession).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(connectTimeout).
+		SetOrderMatters(false).
+		// Ack only after the payload is buffered so QoS 1/2 messages are redelivered if we fail first.
+		SetAutoAckDisabled(true).
+		SetOnConnectHandler(i.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[mqtt] connection to %s lost: %v", cfg.Broker, err)
+		})
+	if cfg.CAFile != "" || cfg.CertFile != "" || cfg.InsecureSkipVerify {
+		tlsCfg, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+	i.client = mqtt.NewClient(opts)
+	return i, nil
+}
+
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca_file contains no PEM certificates")
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// onConnect (re)subscribes after every connect; persistent sessions keep subscriptions, but
+// brokers are free to drop them and clean sessions always do.
+func (i *Input) onConnect(c mqtt.Client) {
+	filters := make(map[string]byte, len(i.cfg.Topics))
+	for _, t := range i.cfg.Topics {
+		filters[t] = i.cfg.QoS
+	}
+	tok := c.SubscribeMultiple(filters, i.handle)
+	if tok.WaitTimeout(connectTimeout) && tok.Error() != nil {
+		log.Printf("[mqtt] subscribe %v: %v", i.cfg.Topics, tok.Error())
+		return
+	}
+	log.Printf("[mqtt] subscribed to %v on %s (qos=%d)", i.cfg.Topics, i.cfg.Broker, i.cfg.QoS)
+}
+
+func (i *Input) handle(_ mqtt.Client, msg mqtt.Message) {
+	raw, err := json.Marshal(i.entry(msg.Topic(), msg.Payload()))
+	if err != nil {
+		log.Printf("[mqtt] marshal entry: %v", err)
+		return
+	}
+	if err := i.buffer.Insert(raw); err != nil {
+		// Left unacked, QoS 1/2 messages are redelivered by the broker after a reconnect.
+		log.Printf("[mqtt] %s: %v", msg.Topic(), err)
+		return
+	}
+	msg.Ack()
+}
+
+// entry maps JSON object payloads through RecordToEntry and wraps anything else as the message.
+func (i *Input) entry(topic string, payload []byte) model.LogEntry {
+	var entry model.LogEntry
+	var record map[string]any
+	if trimmed := bytes.TrimSpace(payload); bytes.HasPrefix(trimmed, []byte("{")) && json.Unmarshal(trimmed, &record) == nil {
+		entry = inputs.RecordToEntry(record, i.cfg.Service)
+	} else {
+		entry = model.LogEntry{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			Service:   i.cfg.Service,
+			Level:     "info",
+			Message:   strings.TrimSpace(string(payload)),
+			Tags:      make(map[string]string),
+		}
+	}
+	entry.Tags["mqtt_topic"] = topic
+	return entry
+}
+
+// Start connects in the background; the client keeps retrying until the broker is reachable.
+func (i *Input) Start() error {
+	i.client.Connect()
+	log.Printf("[mqtt] connecting to %s as %s", i.cfg.Broker, i.cfg.ClientID)
+	return nil
+}
+
+// Health reports the broker connection. While paho is reconnecting the client still counts as
+// connected, so only an input that never reached the broker or gave up is unhealthy.
+func (i *Input) Health() error {
+	if !i.client.IsConnected() {
+		return fmt.Errorf("not connected to %s", i.cfg.Broker)
+	}
+	return nil
+}
+
+// Stop disconnects. A persistent session keeps its subscriptions, so the broker queues QoS 1/2
+// messages for the next start; only a clean session unsubscribes.
+func (i *Input) Stop() error {
+	if i.cfg.CleanSession && i.client.IsConnectionOpen() {
+		i.client.Unsubscribe(i.cfg.Topics...).WaitTimeout(connectTimeout)
+	}
+	i.client.Disconnect(250)
+	return nil
+}
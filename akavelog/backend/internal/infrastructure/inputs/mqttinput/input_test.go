@@ -0,0 +1,123 @@
+package mqttinput
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+	err  error
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+// fakeClient records the calls Stop makes; the other methods are not used.
+type fakeClient struct {
+	mqtt.Client
+	unsubscribed []string
+	disconnected bool
+}
+
+func (c *fakeClient) IsConnectionOpen() bool { return true }
+
+func (c *fakeClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.unsubscribed = append(c.unsubscribed, topics...)
+	return &mqtt.DummyToken{}
+}
+
+func (c *fakeClient) Disconnect(uint) { c.disconnected = true }
+
+type fakeMessage struct {
+	mqtt.Message
+	topic   string
+	payload []byte
+	acked   bool
+}
+
+func (m *fakeMessage) Topic() string   { return m.topic }
+func (m *fakeMessage) Payload() []byte { return m.payload }
+func (m *fakeMessage) Ack()            { m.acked = true }
+
+func TestDefaultClientID(t *testing.T) {
+	base := inputs.Config{"broker": "tcp://mqtt.local:1883", "topics": "devices/+/logs"}
+	withID := func(id string) inputs.Config {
+		cfg := inputs.Config{inputs.InputIDKey: id}
+		for k, v := range base {
+			cfg[k] = v
+		}
+		return cfg
+	}
+	a1, err := parseConfig(withID("0b7e5c1a-3f1d-4a55-9d0e-1c2b3a4d5e6f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, _ := parseConfig(withID("0b7e5c1a-3f1d-4a55-9d0e-1c2b3a4d5e6f"))
+	b, _ := parseConfig(withID("7d1f0c2e-9a8b-4c3d-8e7f-6a5b4c3d2e1f"))
+	if a1.ClientID != a2.ClientID {
+		t.Errorf("client ID changes between creates: %q, %q", a1.ClientID, a2.ClientID)
+	}
+	if a1.ClientID == b.ClientID {
+		t.Errorf("two inputs share client ID %q", a1.ClientID)
+	}
+	if len(a1.ClientID) > 23 {
+		t.Errorf("client ID %q is longer than 23 characters", a1.ClientID)
+	}
+
+	n1, _ := parseConfig(base)
+	n2, _ := parseConfig(base)
+	if n1.ClientID != n2.ClientID {
+		t.Errorf("client ID without an input ID is not stable: %q, %q", n1.ClientID, n2.ClientID)
+	}
+	cfg := withID("0b7e5c1a-3f1d-4a55-9d0e-1c2b3a4d5e6f")
+	cfg["client_id"] = "gateway-7"
+	if c, _ := parseConfig(cfg); c.ClientID != "gateway-7" {
+		t.Errorf("client_id = %q, want gateway-7", c.ClientID)
+	}
+}
+
+func TestStopKeepsPersistentSession(t *testing.T) {
+	for _, clean := range []bool{false, true} {
+		client := &fakeClient{}
+		in := &Input{cfg: Config{Topics: []string{"a/#", "b"}, CleanSession: clean}, client: client}
+		if err := in.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if !client.disconnected {
+			t.Errorf("clean_session=%v: not disconnected", clean)
+		}
+		if got := len(client.unsubscribed) > 0; got != clean {
+			t.Errorf("clean_session=%v: unsubscribed %v", clean, client.unsubscribed)
+		}
+	}
+}
+
+func TestHandleAcksAfterInsert(t *testing.T) {
+	buf := &memBuffer{}
+	in := &Input{cfg: Config{Service: "mqtt"}, buffer: buf}
+	msg := &fakeMessage{topic: "devices/7/logs", payload: []byte(`{"message":"boot","level":"warn"}`)}
+	in.handle(nil, msg)
+	if !msg.acked || len(buf.msgs) != 1 {
+		t.Fatalf("acked = %v, stored = %d", msg.acked, len(buf.msgs))
+	}
+
+	buf.err = errors.New("buffer full")
+	msg = &fakeMessage{topic: "devices/7/logs", payload: []byte("plain text")}
+	in.handle(nil, msg)
+	if msg.acked {
+		t.Error("a message the buffer refused was acked")
+	}
+}
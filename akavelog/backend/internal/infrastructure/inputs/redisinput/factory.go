@@ -0,0 +1,113 @@
+package redisinput
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	modeList   = "list"
+	modeStream = "stream"
+)
+
+// Factory creates Redis list/stream inputs. Registers as "redis".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "redis"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "redis",
+		Description: "Consumes a Redis list (BRPOP) or stream (XREADGROUP). Stream entries are acknowledged with XACK only after they are buffered; pending entries are re-read on restart.",
+		Fields: []inputs.ConfigField{
+			{Name: "url", Type: "string", Required: true, Description: "Redis URL (redis:// or rediss:// for TLS)", Example: "redis://localhost:6379/0"},
+			{Name: "password", Type: "string", Required: false, Description: "Password; overrides the one in url"},
+			{Name: "mode", Type: "string", Required: false, Description: "list or stream (default list)", Example: "stream"},
+			{Name: "key", Type: "string", Required: true, Description: "List or stream key", Example: "logs"},
+			{Name: "group", Type: "string", Required: false, Description: "stream: consumer group, created if missing (default akavelog)", Example: "akavelog"},
+			{Name: "consumer", Type: "string", Required: false, Description: "stream: consumer name within the group (default: hostname)"},
+			{Name: "field", Type: "string", Required: false, Description: "stream: entry field holding the payload; without it all fields form the record (default message)", Example: "message"},
+			{Name: "batch_size", Type: "number", Required: false, Description: "stream: entries read per XREADGROUP (default 100)", Example: "100"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name when a payload has none (default redis)", Example: "worker"},
+		},
+	}
+}
+
+// ValidateConfig validates redis input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Mode:      str("mode"),
+		Key:       str("key"),
+		Group:     str("group"),
+		Consumer:  str("consumer"),
+		Field:     str("field"),
+		BatchSize: 100,
+		Service:   str("service"),
+	}
+	rawURL := str("url")
+	if rawURL == "" {
+		return c, fmt.Errorf("url is required (e.g. redis://localhost:6379/0)")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return c, fmt.Errorf("url: %w", err)
+	}
+	if p := str("password"); p != "" {
+		opts.Password = p
+	}
+	c.Options = opts
+	if c.Key == "" {
+		return c, fmt.Errorf("key is required")
+	}
+	if c.Mode == "" {
+		c.Mode = modeList
+	}
+	if c.Mode != modeList && c.Mode != modeStream {
+		return c, fmt.Errorf("mode must be list or stream")
+	}
+	if v, ok := cfg.Int("batch_size"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("batch_size must be positive")
+		}
+		c.BatchSize = v
+	}
+	if c.Group == "" {
+		c.Group = "akavelog"
+	}
+	if c.Consumer == "" {
+		c.Consumer, _ = os.Hostname()
+		if c.Consumer == "" {
+			c.Consumer = "akavelog"
+		}
+	}
+	if c.Field == "" {
+		c.Field = "message"
+	}
+	if c.Service == "" {
+		c.Service = "redis"
+	}
+	return c, nil
+}
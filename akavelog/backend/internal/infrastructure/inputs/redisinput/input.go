@@ -0,0 +1,235 @@
+package redisinput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	blockTimeout = 5 * time.Second
+	minBackoff   = time.Second
+	maxBackoff   = 30 * time.Second
+)
+
+// Config holds the parsed settings of a redis input.
+type Config struct {
+	Options   *redis.Options
+	Mode      string // list or stream
+	Key       string
+	Group     string
+	Consumer  string
+	Field     string
+	BatchSize int
+	Service   string
+}
+
+// Input pops or reads log payloads from Redis into an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	buffer inputs.InputBuffer
+	client *redis.Client
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	pending bool // stream: re-read the pending entry list before new entries
+}
+
+// NewInput creates a redis input. No connection is made until Start.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer}
+}
+
+// Start checks the server is reachable and, for streams, creates the consumer group, then
+// consumes in the background.
+func (i *Input) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	i.client = redis.NewClient(i.cfg.Options)
+	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer pingCancel()
+	err := i.client.Ping(pingCtx).Err()
+	if err == nil && i.cfg.Mode == modeStream {
+		err = i.ensureGroup(pingCtx)
+	}
+	if err != nil {
+		cancel()
+		_ = i.client.Close()
+		return err
+	}
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	i.wg.Add(1)
+	go i.run(ctx)
+	log.Printf("[redis] consuming %s %q from %s", i.cfg.Mode, i.cfg.Key, i.cfg.Options.Addr)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+	i.mu.Unlock()
+	i.wg.Wait()
+	if i.client != nil {
+		return i.client.Close()
+	}
+	return nil
+}
+
+// run calls the mode's read step until ctx is done, backing off exponentially on errors.
+// go-redis reconnects on the next command, so retrying the step is enough to recover.
+func (i *Input) run(ctx context.Context) {
+	defer i.wg.Done()
+	step := i.popList
+	if i.cfg.Mode == modeStream {
+		step = i.readStream
+	}
+	backoff := minBackoff
+	i.pending = true
+	for ctx.Err() == nil {
+		err := step(ctx)
+		if err == nil || errors.Is(err, redis.Nil) {
+			backoff = minBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[redis] %s %q: %v (retrying in %v)", i.cfg.Mode, i.cfg.Key, err, backoff)
+		i.pending = true
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (i *Input) popList(ctx context.Context) error {
+	res, err := i.client.BRPop(ctx, blockTimeout, i.cfg.Key).Result()
+	if err != nil {
+		return err
+	}
+	// res is [key, value].
+	if err := i.insert([]byte(res[1]), nil, ""); err != nil {
+		// Put the value back where BRPOP took it from so it is read again.
+		if perr := i.client.RPush(ctx, i.cfg.Key, res[1]).Err(); perr != nil {
+			log.Printf("[redis] requeue %q: %v", i.cfg.Key, perr)
+		}
+		return err
+	}
+	return nil
+}
+
+// readStream first re-reads this consumer's pending entries (delivered before a crash or failed
+// ack, never acknowledged), then new ones, and acks each batch once it is buffered.
+func (i *Input) readStream(ctx context.Context) error {
+	start := ">"
+	if i.pending {
+		start = "0"
+	}
+	streams, err := i.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    i.cfg.Group,
+		Consumer: i.cfg.Consumer,
+		Streams:  []string{i.cfg.Key, start},
+		Count:    int64(i.cfg.BatchSize),
+		Block:    blockTimeout,
+	}).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		// The stream or group was deleted since Start; recreate it and read again.
+		if err := i.ensureGroup(ctx); err != nil {
+			return err
+		}
+		i.pending = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ids []string
+	var insertErr error
+	for _, s := range streams {
+		for _, msg := range s.Messages {
+			if insertErr = i.insertStream(msg); insertErr != nil {
+				break
+			}
+			ids = append(ids, msg.ID)
+		}
+		if insertErr != nil {
+			break
+		}
+	}
+	if len(ids) == 0 && insertErr == nil {
+		i.pending = false
+		return nil
+	}
+	if len(ids) > 0 {
+		if err := i.client.XAck(ctx, i.cfg.Key, i.cfg.Group, ids...).Err(); err != nil {
+			return err
+		}
+	}
+	// Entries from the rejected one on stay pending and are re-read on the next attempt.
+	return insertErr
+}
+
+// ensureGroup creates the consumer group (and stream) if needed. Start at "0" so entries written
+// before the group existed are not skipped.
+func (i *Input) ensureGroup(ctx context.Context) error {
+	err := i.client.XGroupCreateMkStream(ctx, i.cfg.Key, i.cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (i *Input) insertStream(msg redis.XMessage) error {
+	if v, ok := msg.Values[i.cfg.Field]; ok && len(msg.Values) == 1 {
+		return i.insert([]byte(inputs.StringifyValue(v)), nil, msg.ID)
+	}
+	return i.insert(nil, msg.Values, msg.ID)
+}
+
+// insert maps a payload (JSON object or plain text) or a stream field map onto a LogEntry.
+func (i *Input) insert(payload []byte, record map[string]any, id string) error {
+	var entry model.LogEntry
+	if record == nil {
+		if text := strings.TrimSpace(string(payload)); strings.HasPrefix(text, "{") && json.Unmarshal([]byte(text), &record) == nil {
+			entry = inputs.RecordToEntry(record, i.cfg.Service)
+		} else {
+			entry = model.LogEntry{
+				Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+				Service:   i.cfg.Service,
+				Level:     "info",
+				Message:   text,
+				Tags:      make(map[string]string),
+			}
+		}
+	} else {
+		entry = inputs.RecordToEntry(record, i.cfg.Service)
+	}
+	entry.Tags["redis_key"] = i.cfg.Key
+	if id != "" {
+		entry.Tags["redis_id"] = id
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[redis] marshal entry: %v", err)
+		return nil
+	}
+	return i.buffer.Insert(raw)
+}
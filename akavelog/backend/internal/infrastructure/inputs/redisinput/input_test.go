@@ -0,0 +1,300 @@
+package redisinput
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+	err  error
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func (b *memBuffer) messages(t *testing.T) []string {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for _, raw := range b.msgs {
+		var e model.LogEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+type streamEntry struct {
+	id, message string
+	delivered   bool
+}
+
+// fakeRedis speaks just enough RESP2 for the commands the input sends. Blocking reads return
+// at once when there is nothing to read.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	list     []string
+	entries  []*streamEntry
+	hasGroup bool
+	creates  int // XGROUP CREATE calls
+	reads    int // XREADGROUP calls
+	acked    []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) input(mode string, buf *memBuffer) *Input {
+	in := NewInput(Config{
+		Options:   &redis.Options{Addr: f.ln.Addr().String(), Protocol: 2, DisableIdentity: true},
+		Mode:      mode,
+		Key:       "logs",
+		Group:     "akavelog",
+		Consumer:  "c1",
+		Field:     "message",
+		BatchSize: 10,
+		Service:   "redis",
+	}, buf)
+	in.client = redis.NewClient(in.cfg.Options)
+	return in
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for k := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[k] = string(b[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func (f *fakeRedis) reply(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "BRPOP":
+		if len(f.list) == 0 {
+			return "*-1\r\n"
+		}
+		v := f.list[len(f.list)-1]
+		f.list = f.list[:len(f.list)-1]
+		return "*2\r\n" + bulk(args[1]) + bulk(v)
+	case "RPUSH":
+		f.list = append(f.list, args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(f.list))
+	case "XGROUP":
+		f.creates++
+		if f.hasGroup {
+			return "-BUSYGROUP Consumer Group name already exists\r\n"
+		}
+		f.hasGroup = true
+		return "+OK\r\n"
+	case "XREADGROUP":
+		f.reads++
+		if !f.hasGroup {
+			return "-NOGROUP No such key 'logs' or consumer group 'akavelog'\r\n"
+		}
+		// ... STREAMS <key> <id> are the last arguments.
+		start := args[len(args)-1]
+		var out []string
+		for _, e := range f.entries {
+			if (start == ">") != e.delivered {
+				e.delivered = true
+				out = append(out, "*2\r\n"+bulk(e.id)+"*2\r\n"+bulk("message")+bulk(e.message))
+			}
+		}
+		if len(out) == 0 && start == ">" {
+			return "*-1\r\n"
+		}
+		return "*1\r\n*2\r\n" + bulk(args[len(args)-2]) + fmt.Sprintf("*%d\r\n", len(out)) + strings.Join(out, "")
+	case "XACK":
+		for _, id := range args[3:] {
+			for n, e := range f.entries {
+				if e.id == id {
+					f.entries = append(f.entries[:n], f.entries[n+1:]...)
+					f.acked = append(f.acked, id)
+					break
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", len(args)-3)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func TestPopListRequeues(t *testing.T) {
+	f := newFakeRedis(t)
+	f.list = []string{`{"message":"older"}`, "newest"}
+	buf := &memBuffer{err: errors.New("buffer full")}
+	in := f.input(modeList, buf)
+	defer in.client.Close()
+	ctx := context.Background()
+
+	if err := in.popList(ctx); err == nil {
+		t.Fatal("popList succeeded with a failing buffer")
+	}
+	if len(f.list) != 2 || f.list[1] != "newest" {
+		t.Fatalf("list after refused insert = %q", f.list)
+	}
+
+	buf.err = nil
+	for range 2 {
+		if err := in.popList(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := buf.messages(t); len(got) != 2 || got[0] != "newest" || got[1] != "older" {
+		t.Errorf("stored %q", got)
+	}
+	if err := in.popList(ctx); !errors.Is(err, redis.Nil) {
+		t.Errorf("empty list: %v", err)
+	}
+}
+
+func TestReadStreamPendingAndAck(t *testing.T) {
+	f := newFakeRedis(t)
+	f.hasGroup = true
+	f.entries = []*streamEntry{{id: "1-0", message: "one"}, {id: "2-0", message: "two"}}
+	buf := &memBuffer{err: errors.New("buffer full")}
+	in := f.input(modeStream, buf)
+	defer in.client.Close()
+	ctx := context.Background()
+
+	// The refused entries are delivered but not acked, so they stay pending.
+	if err := in.readStream(ctx); err == nil {
+		t.Fatal("readStream succeeded with a failing buffer")
+	}
+	if len(f.acked) != 0 {
+		t.Fatalf("acked %v of refused entries", f.acked)
+	}
+
+	buf.err = nil
+	in.pending = true // as run does after an error
+	if err := in.readStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.messages(t); len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("stored %q", got)
+	}
+	if len(f.acked) != 2 || f.acked[0] != "1-0" || f.acked[1] != "2-0" {
+		t.Errorf("acked %v", f.acked)
+	}
+	// An empty pending list switches to new entries.
+	if err := in.readStream(ctx); err != nil || in.pending {
+		t.Errorf("after pending drained: err = %v, pending = %v", err, in.pending)
+	}
+	if f.creates != 0 {
+		t.Errorf("readStream created the group %d times", f.creates)
+	}
+
+	// A deleted group is recreated and the pending list read again.
+	f.hasGroup = false
+	if err := in.readStream(ctx); err != nil || f.creates != 1 || !in.pending {
+		t.Errorf("after NOGROUP: err = %v, creates = %d, pending = %v", err, f.creates, in.pending)
+	}
+}
+
+func TestStartCreatesGroupOnce(t *testing.T) {
+	f := newFakeRedis(t)
+	in := f.input(modeStream, &memBuffer{})
+	in.client.Close()
+	if err := in.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		reads := f.reads
+		f.mu.Unlock()
+		if reads >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := in.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if f.reads < 3 || f.creates != 1 {
+		t.Errorf("%d reads, %d group creates; want at least 3 reads and 1 create", f.reads, f.creates)
+	}
+}
@@ -9,8 +9,9 @@ import (
 // Registry holds registered input factories. The backend uses it to create inputs.
 // Infrastructure packages (e.g. httpinput) register their factory in init().
 type Registry struct {
-	mu        sync.RWMutex
-	factories map[string]Factory
+	mu          sync.RWMutex
+	factories   map[string]Factory
+	checkpoints CheckpointStore
 }
 
 // NewRegistry returns a new Registry.
@@ -25,6 +26,21 @@ func (r *Registry) Register(factory Factory) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.factories[factory.Name()] = factory
+	if ca, ok := factory.(CheckpointAware); ok && r.checkpoints != nil {
+		ca.SetCheckpointStore(r.checkpoints)
+	}
+}
+
+// SetCheckpointStore hands store to every registered (and later registered) CheckpointAware factory.
+func (r *Registry) SetCheckpointStore(store CheckpointStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkpoints = store
+	for _, factory := range r.factories {
+		if ca, ok := factory.(CheckpointAware); ok {
+			ca.SetCheckpointStore(store)
+		}
+	}
 }
 
 // Create builds a MessageInput for the given type and config.
@@ -35,6 +51,10 @@ func (r *Registry) Create(name string, cfg Config, buffer InputBuffer) (MessageI
 	if !ok {
 		return nil, fmt.Errorf("unknown input type: %s", name)
 	}
+	cfg, err := cfg.Resolved()
+	if err != nil {
+		return nil, err
+	}
 	return factory.Create(cfg, buffer)
 }
 
@@ -46,6 +66,10 @@ func (r *Registry) ValidateConfig(typeName string, cfg Config) error {
 	if !ok {
 		return nil
 	}
+	cfg, err := cfg.Resolved()
+	if err != nil {
+		return err
+	}
 	if v, ok := factory.(interface{ ValidateConfig(Config) error }); ok {
 		return v.ValidateConfig(cfg)
 	}
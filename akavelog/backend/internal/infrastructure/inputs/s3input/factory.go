@@ -0,0 +1,119 @@
+package s3input
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+const (
+	defaultPollInterval = time.Minute
+	defaultLookback     = time.Hour
+)
+
+// Factory creates S3/O3 bucket polling inputs. Registers as "s3".
+type Factory struct {
+	mu          sync.RWMutex
+	checkpoints inputs.CheckpointStore
+}
+
+func (f *Factory) Name() string {
+	return "s3"
+}
+
+// SetCheckpointStore implements inputs.CheckpointAware.
+func (f *Factory) SetCheckpointStore(store inputs.CheckpointStore) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checkpoints = store
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "s3",
+		Description: "Polls an S3-compatible bucket/prefix (including another Akave O3 bucket) for new objects and ingests their NDJSON or JSON-array content, gzip or plain. Progress is checkpointed in Postgres.",
+		Fields: []inputs.ConfigField{
+			{Name: "endpoint", Type: "string", Required: true, Description: "S3-compatible endpoint URL", Example: "https://o3-rc2.akave.xyz"},
+			{Name: "bucket", Type: "string", Required: true, Description: "Bucket to poll", Example: "app-logs"},
+			{Name: "prefix", Type: "string", Required: false, Description: "Only objects under this key prefix are read", Example: "logs/"},
+			{Name: "region", Type: "string", Required: false, Description: "Region (default us-east-1)", Example: "us-east-1"},
+			{Name: "access_key", Type: "string", Required: false, Description: "Access key ID"},
+			{Name: "secret_key", Type: "string", Required: false, Description: "Secret access key"},
+			{Name: "poll_interval", Type: "string", Required: false, Description: "How often to list for new objects (default 1m)", Example: "1m"},
+			{Name: "lookback", Type: "string", Required: false, Description: "How much older than the newest object read a new object may be and still be read, e.g. a multipart upload that took long (default 1h)", Example: "1h"},
+			{Name: "key_date_layout", Type: "string", Required: false, Description: "Go time layout of the date that follows prefix in keys; listings then start near the last object read instead of at the start of the prefix", Example: "2006/01/02"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for records without a service field", Example: "s3"},
+		},
+	}
+}
+
+// ValidateConfig validates s3 input config. Endpoint and bucket are required.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, _, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	o3cfg, c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewO3Client(o3cfg)
+	if err != nil {
+		return nil, fmt.Errorf("s3 client: %w", err)
+	}
+	f.mu.RLock()
+	store := f.checkpoints
+	f.mu.RUnlock()
+	return NewInput(c, client, store, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (*config.O3Config, Config, error) {
+	o3cfg := &config.O3Config{}
+	o3cfg.Endpoint, _ = cfg["endpoint"].(string)
+	o3cfg.Bucket, _ = cfg["bucket"].(string)
+	o3cfg.Region, _ = cfg["region"].(string)
+	o3cfg.AccessKey, _ = cfg["access_key"].(string)
+	o3cfg.SecretKey, _ = cfg["secret_key"].(string)
+	if strings.TrimSpace(o3cfg.Endpoint) == "" {
+		return nil, Config{}, fmt.Errorf("endpoint is required")
+	}
+	if strings.TrimSpace(o3cfg.Bucket) == "" {
+		return nil, Config{}, fmt.Errorf("bucket is required")
+	}
+	c := Config{Bucket: o3cfg.Bucket, PollInterval: defaultPollInterval, Lookback: defaultLookback, Service: "s3"}
+	c.Prefix, _ = cfg["prefix"].(string)
+	if v, _ := cfg["poll_interval"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, c, fmt.Errorf("poll_interval must be a positive duration (e.g. 1m)")
+		}
+		c.PollInterval = d
+	}
+	if v, _ := cfg["lookback"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, c, fmt.Errorf("lookback must be a duration (e.g. 1h)")
+		}
+		c.Lookback = d
+	}
+	c.KeyLayout, _ = cfg["key_date_layout"].(string)
+	if c.KeyLayout != "" && !strings.Contains(c.KeyLayout, "2006") {
+		return nil, c, fmt.Errorf("key_date_layout must be a Go time layout with a year (e.g. 2006/01/02)")
+	}
+	if v, _ := cfg["service"].(string); v != "" {
+		c.Service = v
+	}
+	c.CheckpointKey = "s3:" + strings.TrimSuffix(o3cfg.Endpoint, "/") + "/" + o3cfg.Bucket + "/" + c.Prefix
+	// Inputs on the same bucket/prefix (e.g. for different projects) each read every object.
+	if id, _ := cfg[inputs.InputIDKey].(string); id != "" {
+		c.LegacyKey = c.CheckpointKey
+		c.CheckpointKey += "#" + id
+	}
+	return o3cfg, c, nil
+}
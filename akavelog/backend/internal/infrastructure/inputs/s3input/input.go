@@ -0,0 +1,302 @@
+package s3input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// maxDecompressed bounds a gzip object once decompressed, guarding against gzip bombs.
+const maxDecompressed = 256 << 20
+
+// errUndecodable marks objects that cannot be read however often they are retried: bad gzip,
+// a bad JSON array, a line over 16 MiB or more than maxDecompressed bytes of content.
+var errUndecodable = errors.New("undecodable object")
+
+// Config holds the parsed settings of an s3 input.
+type Config struct {
+	Bucket        string
+	Prefix        string
+	PollInterval  time.Duration
+	Lookback      time.Duration // how late an object may appear after newer ones were read
+	KeyLayout     string        // time layout of the date that follows Prefix in keys; "" if none
+	Service       string
+	CheckpointKey string // identifies this input's bucket/prefix in the checkpoint store
+	LegacyKey     string // key of older versions, shared by inputs on the same bucket/prefix
+}
+
+// checkpoint records which objects were processed. LastModified does not order objects by
+// when they appear: a multipart upload gets the time it started, so it can be listed after
+// objects modified later. Every object modified within the lookback before the newest one
+// processed is therefore read unless its key was seen; objects older than that are done.
+type checkpoint struct {
+	Floor time.Time            `json:"floor"`          // objects modified before it are done
+	Seen  map[string]time.Time `json:"seen,omitempty"` // processed keys modified at or after Floor
+}
+
+func (c checkpoint) String() string {
+	if c.Floor.IsZero() && len(c.Seen) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// parseCheckpoint reads a checkpoint, including the "<LastModified>|<key>" form of older
+// versions, which had processed every object up to that one.
+func parseCheckpoint(s string) checkpoint {
+	if strings.HasPrefix(s, "{") {
+		var c checkpoint
+		if json.Unmarshal([]byte(s), &c) != nil {
+			return checkpoint{}
+		}
+		return c
+	}
+	ts, key, ok := strings.Cut(s, "|")
+	if !ok {
+		return checkpoint{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return checkpoint{}
+	}
+	return checkpoint{Floor: t, Seen: map[string]time.Time{key: t}}
+}
+
+// covers reports whether obj was already processed according to c.
+func (c checkpoint) covers(obj storage.ObjectInfo) bool {
+	if obj.LastModified.Before(c.Floor) {
+		return true
+	}
+	_, ok := c.Seen[obj.Key]
+	return ok
+}
+
+// add records obj as processed, moves the floor to lookback before the newest object
+// processed, and forgets the keys below it.
+func (c *checkpoint) add(obj storage.ObjectInfo, lookback time.Duration) {
+	if c.Seen == nil {
+		c.Seen = make(map[string]time.Time)
+	}
+	c.Seen[obj.Key] = obj.LastModified
+	if floor := obj.LastModified.Add(-lookback); floor.After(c.Floor) {
+		c.Floor = floor
+	}
+	for key, modified := range c.Seen {
+		if modified.Before(c.Floor) {
+			delete(c.Seen, key)
+		}
+	}
+}
+
+// Input polls a bucket/prefix and ingests each new object once.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	client *storage.O3Client
+	store  inputs.CheckpointStore // nil keeps progress in memory only
+	buffer inputs.InputBuffer
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	last   checkpoint
+}
+
+// NewInput creates an s3 polling input. store may be nil.
+func NewInput(cfg Config, client *storage.O3Client, store inputs.CheckpointStore, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, client: client, store: store, buffer: buffer}
+}
+
+func (i *Input) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if i.store != nil {
+		v, err := i.store.GetCheckpoint(ctx, i.cfg.CheckpointKey)
+		if err == nil && v == "" && i.cfg.LegacyKey != "" {
+			v, err = i.store.GetCheckpoint(ctx, i.cfg.LegacyKey)
+		}
+		if err != nil {
+			cancel()
+			return fmt.Errorf("load checkpoint: %w", err)
+		}
+		i.last = parseCheckpoint(v)
+	} else {
+		log.Printf("[s3] no checkpoint store: progress for %s is kept in memory only", i.cfg.CheckpointKey)
+	}
+	i.mu.Lock()
+	i.cancel = cancel
+	i.done = make(chan struct{})
+	i.mu.Unlock()
+	go i.pollLoop(ctx)
+	log.Printf("[s3] polling %s/%s every %v (checkpoint floor %s, %d recent keys)", i.cfg.Bucket, i.cfg.Prefix, i.cfg.PollInterval,
+		i.last.Floor.Format(time.RFC3339), len(i.last.Seen))
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	cancel, done := i.cancel, i.done
+	i.cancel = nil
+	i.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+func (i *Input) pollLoop(ctx context.Context) {
+	defer close(i.done)
+	ticker := time.NewTicker(i.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := i.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[s3] poll %s/%s: %v", i.cfg.Bucket, i.cfg.Prefix, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll processes every object the checkpoint does not cover, oldest first, and saves the
+// checkpoint once it stops, so a crash re-reads the objects of the poll it interrupted.
+// Objects that cannot be decoded are logged and skipped.
+func (i *Input) poll(ctx context.Context) (err error) {
+	objects, err := i.client.ListObjectsAfter(ctx, i.cfg.Prefix, i.startAfter())
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	sort.Slice(objects, func(a, b int) bool {
+		if !objects[a].LastModified.Equal(objects[b].LastModified) {
+			return objects[a].LastModified.Before(objects[b].LastModified)
+		}
+		return objects[a].Key < objects[b].Key
+	})
+	saved := i.last.String()
+	defer func() {
+		if cp := i.last.String(); i.store != nil && cp != saved {
+			if serr := i.store.SetCheckpoint(context.WithoutCancel(ctx), i.cfg.CheckpointKey, cp); serr != nil && err == nil {
+				err = fmt.Errorf("save checkpoint: %w", serr)
+			}
+		}
+	}()
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if strings.HasSuffix(obj.Key, "/") || i.last.covers(obj) {
+			continue
+		}
+		n, err := i.ingestObject(ctx, obj.Key)
+		switch {
+		case errors.Is(err, errUndecodable):
+			log.Printf("[s3] skipping %s: %v", obj.Key, err)
+		case err != nil:
+			return fmt.Errorf("%s: %w", obj.Key, err)
+		default:
+			log.Printf("[s3] ingested %d entries from %s", n, obj.Key)
+		}
+		i.last.add(obj, i.cfg.Lookback)
+	}
+	return nil
+}
+
+// startAfter returns the key the listing starts after: the prefix and the day before the
+// floor formatted with KeyLayout, a day of margin for keys dated before their upload ended;
+// or "" to list the whole prefix.
+func (i *Input) startAfter() string {
+	if i.cfg.KeyLayout == "" || i.last.Floor.IsZero() {
+		return ""
+	}
+	return i.cfg.Prefix + i.last.Floor.UTC().AddDate(0, 0, -1).Format(i.cfg.KeyLayout)
+}
+
+func (i *Input) ingestObject(ctx context.Context, key string) (int, error) {
+	data, err := i.client.GetObject(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("get: %w", err)
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		if data, err = gunzip(data, maxDecompressed); err != nil {
+			return 0, fmt.Errorf("%w: gzip: %v", errUndecodable, err)
+		}
+	}
+	records, err := decodeRecords(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errUndecodable, err)
+	}
+	for _, rec := range records {
+		entry := inputs.RecordToEntry(rec, i.cfg.Service)
+		entry.Tags["s3_key"] = key
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		if err := i.buffer.Insert(raw); err != nil {
+			// The checkpoint stays before this object, so the next poll reads it again.
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
+
+// gunzip decompresses data, failing once it exceeds limit bytes.
+func gunzip(data []byte, limit int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("content exceeds %d bytes", limit)
+	}
+	return out, nil
+}
+
+// decodeRecords accepts a JSON array of objects (akavelog batch format) or NDJSON.
+// Non-JSON lines are kept as plain messages.
+func decodeRecords(data []byte) ([]map[string]any, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var arr []map[string]any
+		if err := json.Unmarshal(trimmed, &arr); err != nil {
+			return nil, fmt.Errorf("decode json array: %w", err)
+		}
+		return arr, nil
+	}
+	var out []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(trimmed))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			rec = map[string]any{"message": string(line)}
+		}
+		out = append(out, rec)
+	}
+	return out, sc.Err()
+}
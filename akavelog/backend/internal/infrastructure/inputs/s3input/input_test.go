@@ -0,0 +1,279 @@
+package s3input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+// keys returns the s3_key tags of the entries inserted so far and forgets them.
+func (b *memBuffer) keys(t *testing.T) []string {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for _, raw := range b.msgs {
+		var e model.LogEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e.Tags["s3_key"])
+	}
+	b.msgs = nil
+	return out
+}
+
+type memCheckpoints map[string]string
+
+func (m memCheckpoints) GetCheckpoint(_ context.Context, key string) (string, error) {
+	return m[key], nil
+}
+
+func (m memCheckpoints) SetCheckpoint(_ context.Context, key, value string) error {
+	m[key] = value
+	return nil
+}
+
+// bucket is a directory-backed bucket whose objects get the LastModified they are put with.
+type bucket struct {
+	dir    string
+	client *storage.O3Client
+}
+
+func newBucket(t *testing.T) *bucket {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &bucket{dir: dir, client: storage.NewClient(store)}
+}
+
+func (b *bucket) put(t *testing.T, key string, modified time.Time) {
+	t.Helper()
+	if err := b.client.PutObject(context.Background(), key, []byte(`{"message":"m"}`+"\n"), "application/x-ndjson"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(b.dir, filepath.FromSlash(key)), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	t0 := time.Date(2024, 2, 17, 12, 0, 0, 0, time.UTC)
+	var c checkpoint
+	c.add(storage.ObjectInfo{Key: "a", LastModified: t0}, time.Hour)
+	c.add(storage.ObjectInfo{Key: "b", LastModified: t0.Add(30 * time.Minute)}, time.Hour)
+	c.add(storage.ObjectInfo{Key: "c", LastModified: t0.Add(90 * time.Minute)}, time.Hour)
+	if !c.Floor.Equal(t0.Add(30*time.Minute)) || len(c.Seen) != 2 {
+		t.Fatalf("checkpoint = %+v", c)
+	}
+	for _, tc := range []struct {
+		obj  storage.ObjectInfo
+		want bool
+	}{
+		{storage.ObjectInfo{Key: "a", LastModified: t0}, true},
+		{storage.ObjectInfo{Key: "b", LastModified: t0.Add(30 * time.Minute)}, true},
+		{storage.ObjectInfo{Key: "late", LastModified: t0.Add(45 * time.Minute)}, false},
+		{storage.ObjectInfo{Key: "too-late", LastModified: t0.Add(10 * time.Minute)}, true},
+	} {
+		if got := c.covers(tc.obj); got != tc.want {
+			t.Errorf("covers(%s) = %v, want %v", tc.obj.Key, got, tc.want)
+		}
+	}
+
+	if back := parseCheckpoint(c.String()); !back.Floor.Equal(c.Floor) || len(back.Seen) != 2 {
+		t.Errorf("round trip = %+v", back)
+	}
+	legacy := parseCheckpoint(t0.Format(time.RFC3339Nano) + "|logs/a.json")
+	if !legacy.covers(storage.ObjectInfo{Key: "logs/a.json", LastModified: t0}) ||
+		!legacy.covers(storage.ObjectInfo{Key: "logs/old.json", LastModified: t0.Add(-time.Second)}) ||
+		legacy.covers(storage.ObjectInfo{Key: "logs/new.json", LastModified: t0.Add(time.Second)}) {
+		t.Errorf("legacy checkpoint = %+v", legacy)
+	}
+	if parseCheckpoint("").String() != "" {
+		t.Error("empty checkpoint")
+	}
+}
+
+func TestPollLateObjects(t *testing.T) {
+	b := newBucket(t)
+	buf := &memBuffer{}
+	store := memCheckpoints{}
+	cfg := Config{Prefix: "logs/", Lookback: time.Hour, Service: "s3", CheckpointKey: "s3:test"}
+	in := NewInput(cfg, b.client, store, buf)
+	ctx := context.Background()
+	t0 := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	b.put(t, "logs/b.json", t0)
+	b.put(t, "logs/a.json", t0.Add(10*time.Minute))
+	if err := in.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 2 || got[0] != "logs/b.json" || got[1] != "logs/a.json" {
+		t.Fatalf("first poll read %v", got)
+	}
+
+	// A multipart upload started before the last object read finishes after it.
+	b.put(t, "logs/multipart.json", t0.Add(5*time.Minute))
+	b.put(t, "logs/ancient.json", t0.Add(-2*time.Hour))
+	if err := in.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 1 || got[0] != "logs/multipart.json" {
+		t.Fatalf("second poll read %v", got)
+	}
+
+	// A restart resumes from the stored checkpoint.
+	in = NewInput(cfg, b.client, store, buf)
+	in.last = parseCheckpoint(store["s3:test"])
+	if err := in.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 0 {
+		t.Fatalf("poll after restart read %v", got)
+	}
+}
+
+func TestPollStartAfter(t *testing.T) {
+	b := newBucket(t)
+	buf := &memBuffer{}
+	cfg := Config{Prefix: "logs/", Lookback: time.Hour, KeyLayout: "2006/01/02", Service: "s3"}
+	in := NewInput(cfg, b.client, nil, buf)
+	now := time.Now().UTC().Truncate(time.Second)
+	if in.startAfter() != "" {
+		t.Errorf("startAfter without a checkpoint = %q", in.startAfter())
+	}
+
+	b.put(t, "logs/"+now.Format("2006/01/02")+"/new.json", now)
+	if err := in.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 1 {
+		t.Fatalf("first poll read %v", got)
+	}
+	if want := "logs/" + in.last.Floor.AddDate(0, 0, -1).Format("2006/01/02"); in.startAfter() != want {
+		t.Errorf("startAfter = %q, want %q", in.startAfter(), want)
+	}
+
+	// Keys dated before the listing starts are not listed, even if modified recently.
+	old := now.AddDate(0, 0, -3)
+	b.put(t, "logs/"+old.Format("2006/01/02")+"/old.json", now.Add(-time.Minute))
+	b.put(t, "logs/"+now.Format("2006/01/02")+"/later.json", now.Add(time.Minute))
+	if err := in.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 1 || got[0] != "logs/"+now.Format("2006/01/02")+"/later.json" {
+		t.Fatalf("second poll read %v", got)
+	}
+}
+
+func TestPollSkipsUndecodable(t *testing.T) {
+	b := newBucket(t)
+	buf := &memBuffer{}
+	store := &countingCheckpoints{memCheckpoints: memCheckpoints{}}
+	in := NewInput(Config{Prefix: "logs/", Lookback: time.Hour, Service: "s3", CheckpointKey: "s3:test"}, b.client, store, buf)
+	ctx := context.Background()
+	t0 := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for n, body := range []string{"\x1f\x8bnot gzip", `[{"message":"a"},`} {
+		key := fmt.Sprintf("logs/bad%d.json", n)
+		if err := b.client.PutObject(ctx, key, []byte(body), "application/json"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(b.dir, filepath.FromSlash(key)), t0, t0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.put(t, "logs/good.json", t0.Add(time.Minute))
+	if err := in.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.keys(t); len(got) != 1 || got[0] != "logs/good.json" {
+		t.Fatalf("read %v", got)
+	}
+	if len(in.last.Seen) != 3 || store.sets != 1 {
+		t.Errorf("checkpoint = %+v after %d saves, want the 3 objects saved once", in.last, store.sets)
+	}
+	if err := in.poll(ctx); err != nil || store.sets != 1 {
+		t.Errorf("poll without new objects: err = %v, %d saves", err, store.sets)
+	}
+}
+
+type countingCheckpoints struct {
+	memCheckpoints
+	sets int
+}
+
+func (c *countingCheckpoints) SetCheckpoint(ctx context.Context, key, value string) error {
+	c.sets++
+	return c.memCheckpoints.SetCheckpoint(ctx, key, value)
+}
+
+func TestGunzipLimit(t *testing.T) {
+	var zb bytes.Buffer
+	zw := gzip.NewWriter(&zb)
+	zw.Write(make([]byte, 1<<20))
+	zw.Close()
+	if out, err := gunzip(zb.Bytes(), 1<<20); err != nil || len(out) != 1<<20 {
+		t.Errorf("at the limit: %d bytes, %v", len(out), err)
+	}
+	if _, err := gunzip(zb.Bytes(), 1<<20-1); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("over the limit: %v", err)
+	}
+}
+
+func TestCheckpointKeyPerInput(t *testing.T) {
+	cfg := inputs.Config{"endpoint": "https://o3.example/", "bucket": "logs", "prefix": "app/"}
+	_, shared, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg[inputs.InputIDKey] = "0b7e5c1a-3f1d-4a55-9d0e-1c2b3a4d5e6f"
+	_, a, _ := parseConfig(cfg)
+	cfg[inputs.InputIDKey] = "7d1f0c2e-9a8b-4c3d-8e7f-6a5b4c3d2e1f"
+	_, b, _ := parseConfig(cfg)
+	if a.CheckpointKey == b.CheckpointKey {
+		t.Errorf("two inputs on one prefix share checkpoint %q", a.CheckpointKey)
+	}
+	if a.LegacyKey != shared.CheckpointKey {
+		t.Errorf("legacy key = %q, want %q", a.LegacyKey, shared.CheckpointKey)
+	}
+
+	// An input that had the shared checkpoint resumes from it.
+	store := memCheckpoints{shared.CheckpointKey: time.Now().UTC().Format(time.RFC3339Nano) + "|app/x.json"}
+	in := NewInput(a, nil, store, &memBuffer{})
+	in.cfg.PollInterval = time.Hour
+	if err := in.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer in.Stop()
+	if in.last.Floor.IsZero() {
+		t.Error("legacy checkpoint not loaded")
+	}
+}
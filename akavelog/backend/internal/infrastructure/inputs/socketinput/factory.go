@@ -0,0 +1,96 @@
+package socketinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+const (
+	defaultMaxFrameSize = 64 * 1024
+	defaultIdleTimeout  = 5 * time.Minute
+)
+
+// Factory creates raw socket inputs. It registers twice: as "tcp" and as "udp".
+type Factory struct {
+	network string
+}
+
+func (f *Factory) Name() string {
+	return f.network
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	fields := []inputs.ConfigField{
+		{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":5140"},
+		{Name: "framing", Type: "string", Required: false, Description: "Frame delimiting: newline (default), null, or length (4-byte big-endian length prefix)", Example: "newline"},
+		{Name: "max_frame_size", Type: "number", Required: false, Description: "Frames larger than this many bytes are dropped (default 65536)", Example: "65536"},
+		{Name: "service", Type: "string", Required: false, Description: "Service name used when a frame is plain text rather than a JSON log entry", Example: "legacy-app"},
+	}
+	desc := "Raw UDP socket. Each datagram is split into frames; each frame is inserted as one payload."
+	if f.network == "tcp" {
+		fields = append(fields, inputs.ConfigField{Name: "idle_timeout", Type: "string", Required: false, Description: "Close connections idle for this long (default 5m)", Example: "5m"})
+		desc = "Raw TCP socket. Reads delimited or length-prefixed frames from each connection; each frame is inserted as one payload."
+	}
+	return inputs.InputTypeInfo{Type: f.network, Description: desc, Fields: fields}
+}
+
+// ValidateConfig validates socket input config: listen is required, framing and sizes must be valid.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(f.network, cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(f.network, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(network string, cfg inputs.Config) (Config, error) {
+	c := Config{
+		Network:      network,
+		Framing:      FramingNewline,
+		MaxFrameSize: defaultMaxFrameSize,
+		IdleTimeout:  defaultIdleTimeout,
+		Service:      network,
+	}
+	listen, _ := cfg["listen"].(string)
+	c.Listen = strings.TrimSpace(listen)
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :5140)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :5140 or 0.0.0.0:5140)")
+	}
+	if framing, _ := cfg["framing"].(string); framing != "" {
+		switch Framing(framing) {
+		case FramingNewline, FramingNull, FramingLength:
+			c.Framing = Framing(framing)
+		default:
+			return c, fmt.Errorf("framing must be one of newline, null, length")
+		}
+	}
+	if v, ok := cfg.Int("max_frame_size"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("max_frame_size must be positive")
+		}
+		c.MaxFrameSize = v
+	}
+	if v, _ := cfg["idle_timeout"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("idle_timeout must be a positive duration (e.g. 5m)")
+		}
+		c.IdleTimeout = d
+	}
+	if v, _ := cfg["service"].(string); v != "" {
+		c.Service = v
+	}
+	return c, nil
+}
@@ -0,0 +1,114 @@
+package socketinput
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Framing selects how a byte stream is split into frames.
+type Framing string
+
+const (
+	FramingNewline Framing = "newline" // frames end with \n (a trailing \r is stripped)
+	FramingNull    Framing = "null"    // frames end with a NUL byte
+	FramingLength  Framing = "length"  // each frame is preceded by a 4-byte big-endian length
+)
+
+// errFrameTooLarge is returned for a frame exceeding the configured maximum. The oversized
+// frame has already been consumed, so the caller can keep reading the next one.
+var errFrameTooLarge = errors.New("frame exceeds max_frame_size")
+
+// frameReader reads frames from a stream.
+type frameReader struct {
+	r       *bufio.Reader
+	framing Framing
+	max     int
+}
+
+func newFrameReader(r io.Reader, framing Framing, max int) *frameReader {
+	return &frameReader{r: bufio.NewReader(r), framing: framing, max: max}
+}
+
+// Next returns the next frame. At end of stream a final unterminated frame is returned
+// together with io.EOF.
+func (f *frameReader) Next() ([]byte, error) {
+	switch f.framing {
+	case FramingLength:
+		return f.nextLengthPrefixed()
+	case FramingNull:
+		return f.nextDelimited(0)
+	default:
+		frame, err := f.nextDelimited('\n')
+		return bytes.TrimSuffix(frame, []byte{'\r'}), err
+	}
+}
+
+func (f *frameReader) nextDelimited(delim byte) ([]byte, error) {
+	var frame []byte
+	tooLarge := false
+	for {
+		chunk, err := f.r.ReadSlice(delim)
+		if !tooLarge {
+			if len(frame)+len(chunk) > f.max+1 {
+				tooLarge = true
+				frame = nil
+			} else {
+				frame = append(frame, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLarge {
+			if err != nil {
+				return nil, err
+			}
+			return nil, errFrameTooLarge
+		}
+		if err != nil {
+			return frame, err
+		}
+		return frame[:len(frame)-1], nil
+	}
+}
+
+func (f *frameReader) nextLengthPrefixed() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if int64(n) > int64(f.max) {
+		if _, err := f.r.Discard(int(n)); err != nil {
+			return nil, err
+		}
+		return nil, errFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(f.r, frame); err != nil {
+		return nil, fmt.Errorf("read %d-byte frame: %w", n, err)
+	}
+	return frame, nil
+}
+
+// splitDatagram splits one UDP datagram into frames using the same framing rules.
+func splitDatagram(data []byte, framing Framing, max int) ([][]byte, error) {
+	fr := &frameReader{r: bufio.NewReader(bytes.NewReader(data)), framing: framing, max: max}
+	var frames [][]byte
+	for {
+		frame, err := fr.Next()
+		if len(frame) > 0 {
+			frames = append(frames, frame)
+		}
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil && err != errFrameTooLarge {
+			return frames, err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package socketinput
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrameReader(t *testing.T) {
+	lp := func(s string) []byte {
+		b := make([]byte, 4, 4+len(s))
+		binary.BigEndian.PutUint32(b, uint32(len(s)))
+		return append(b, s...)
+	}
+	cases := []struct {
+		name    string
+		framing Framing
+		input   []byte
+		want    []string
+		dropped int
+	}{
+		{"newline", FramingNewline, []byte("one\r\ntwo\nthree"), []string{"one", "two", "three"}, 0},
+		{"null", FramingNull, []byte("a\x00b\x00"), []string{"a", "b"}, 0},
+		{"length", FramingLength, append(lp("hello"), lp("world")...), []string{"hello", "world"}, 0},
+		{"oversized newline", FramingNewline, []byte("ok\n0123456789abcdef\nnext\n"), []string{"ok", "next"}, 1},
+		{"oversized length", FramingLength, append(lp("0123456789abcdef"), lp("fine")...), []string{"fine"}, 1},
+	}
+	for _, tc := range cases {
+		fr := newFrameReader(bytes.NewReader(tc.input), tc.framing, 8)
+		var got []string
+		dropped := 0
+		for {
+			frame, err := fr.Next()
+			if len(frame) > 0 {
+				got = append(got, string(frame))
+			}
+			if errors.Is(err, errFrameTooLarge) {
+				dropped++
+				continue
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+		if len(got) != len(tc.want) || dropped != tc.dropped {
+			t.Fatalf("%s: got %q (dropped %d), want %q (dropped %d)", tc.name, got, dropped, tc.want, tc.dropped)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: frame %d = %q, want %q", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
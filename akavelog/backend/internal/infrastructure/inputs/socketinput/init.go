@@ -0,0 +1,8 @@
+package socketinput
+
+import "github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+
+func init() {
+	inputs.GlobalRegistry.Register(&Factory{network: "tcp"})
+	inputs.GlobalRegistry.Register(&Factory{network: "udp"})
+}
@@ -0,0 +1,221 @@
+package socketinput
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Config holds the parsed settings of a tcp or udp input.
+type Config struct {
+	Network      string
+	Listen       string
+	Framing      Framing
+	MaxFrameSize int
+	IdleTimeout  time.Duration // tcp only
+	Service      string        // service for plain-text frames
+}
+
+// Input is a raw TCP or UDP listener that inserts every received frame into an InputBuffer.
+type Input struct {
+	inputs.HealthState
+
+	cfg     Config
+	buffer  inputs.InputBuffer
+	metrics *inputs.Metrics
+
+	mu       sync.Mutex
+	listener net.Listener
+	packet   net.PacketConn
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	drain    inputs.Drainer
+}
+
+// NewInput creates a socket input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer, metrics: inputs.MetricsOf(buffer), conns: make(map[net.Conn]struct{})}
+}
+
+// Addr returns the bound address once started (useful when listening on :0).
+func (i *Input) Addr() net.Addr {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.listener != nil {
+		return i.listener.Addr()
+	}
+	if i.packet != nil {
+		return i.packet.LocalAddr()
+	}
+	return nil
+}
+
+func (i *Input) Start() error {
+	if i.cfg.Network == "udp" {
+		pc, err := net.ListenPacket("udp", i.cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("listen udp %s: %w", i.cfg.Listen, err)
+		}
+		i.mu.Lock()
+		i.packet = pc
+		i.mu.Unlock()
+		i.wg.Add(1)
+		go i.readPackets(pc)
+	} else {
+		ln, err := net.Listen("tcp", i.cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("listen tcp %s: %w", i.cfg.Listen, err)
+		}
+		i.mu.Lock()
+		i.listener = ln
+		i.mu.Unlock()
+		i.wg.Add(1)
+		go i.acceptLoop(ln)
+	}
+	log.Printf("[socket] %s listening on %s (framing=%s)", i.cfg.Network, i.cfg.Listen, i.cfg.Framing)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	ln, pc := i.listener, i.packet
+	i.listener, i.packet = nil, nil
+	for c := range i.conns {
+		_ = c.Close()
+	}
+	i.mu.Unlock()
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	if pc != nil {
+		err = pc.Close()
+	}
+	i.wg.Wait()
+	return err
+}
+
+// Drain stops accepting connections, reads what clients have already sent for up to
+// inputs.DrainReadTimeout, then stops. When ctx is done first it stops at once.
+func (i *Input) Drain(ctx context.Context) error {
+	deadline := i.drain.Begin()
+	i.mu.Lock()
+	ln, pc := i.listener, i.packet
+	i.listener = nil
+	i.mu.Unlock()
+	if ln != nil {
+		_ = ln.Close()
+	}
+	i.mu.Lock()
+	for c := range i.conns {
+		_ = c.SetReadDeadline(deadline)
+	}
+	i.mu.Unlock()
+	if pc != nil {
+		_ = pc.SetReadDeadline(deadline)
+	}
+	inputs.Wait(ctx, &i.wg)
+	return i.Stop()
+}
+
+func (i *Input) acceptLoop(ln net.Listener) {
+	defer i.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[socket] accept on %s: %v", i.cfg.Listen, err)
+				i.Fail(fmt.Errorf("accept on %s: %w", i.cfg.Listen, err))
+			}
+			return
+		}
+		i.mu.Lock()
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+		i.metrics.ConnOpened()
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			defer func() {
+				i.mu.Lock()
+				delete(i.conns, conn)
+				i.mu.Unlock()
+				i.metrics.ConnClosed()
+				_ = conn.Close()
+			}()
+			i.serveConn(conn)
+		}()
+	}
+}
+
+func (i *Input) serveConn(conn net.Conn) {
+	fr := newFrameReader(conn, i.cfg.Framing, i.cfg.MaxFrameSize)
+	for {
+		i.drain.SetReadDeadline(conn, i.cfg.IdleTimeout)
+		frame, err := fr.Next()
+		if len(frame) > 0 {
+			i.insert(frame)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, errFrameTooLarge):
+			i.metrics.Error()
+			log.Printf("[socket] %s: dropped frame larger than %d bytes", conn.RemoteAddr(), i.cfg.MaxFrameSize)
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			if !i.drain.Draining() {
+				log.Printf("[socket] %s: closing idle connection", conn.RemoteAddr())
+			}
+			return
+		case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+			return
+		default:
+			i.metrics.Error()
+			log.Printf("[socket] %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (i *Input) readPackets(pc net.PacketConn) {
+	defer i.wg.Done()
+	buf := make([]byte, i.cfg.MaxFrameSize+1)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !(i.drain.Draining() && errors.Is(err, os.ErrDeadlineExceeded)) {
+				log.Printf("[socket] udp read on %s: %v", i.cfg.Listen, err)
+				i.Fail(fmt.Errorf("udp read on %s: %w", i.cfg.Listen, err))
+			}
+			return
+		}
+		if n > i.cfg.MaxFrameSize {
+			i.metrics.Error()
+			log.Printf("[socket] %s: dropped datagram larger than %d bytes", addr, i.cfg.MaxFrameSize)
+			continue
+		}
+		frames, err := splitDatagram(buf[:n], i.cfg.Framing, i.cfg.MaxFrameSize)
+		if err != nil {
+			i.metrics.Error()
+			log.Printf("[socket] %s: %v", addr, err)
+		}
+		for _, frame := range frames {
+			i.insert(frame)
+		}
+	}
+}
+
+func (i *Input) insert(frame []byte) {
+	p := make([]byte, len(frame))
+	copy(p, frame)
+	if err := i.buffer.Insert(inputs.WrapPlain(p, i.cfg.Service)); err != nil {
+		log.Printf("[socket] %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package socketinput
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func (b *memBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.msgs)
+}
+
+func TestDrainReadsOpenConnections(t *testing.T) {
+	var buf memBuffer
+	in := NewInput(Config{Network: "tcp", Listen: "127.0.0.1:0", Framing: FramingNewline, MaxFrameSize: 1024, IdleTimeout: time.Minute}, &buf)
+	if err := in.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", in.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); buf.Len() < 1 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The client keeps its connection open: the frame it sent before the drain is still
+	// read, and the drain ends after the read timeout rather than the idle timeout.
+	if _, err := conn.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := in.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > 3*time.Second {
+		t.Errorf("Drain took %v", took)
+	}
+	if buf.Len() != 2 {
+		t.Errorf("inserted %d frames, want 2", buf.Len())
+	}
+	if _, err := net.DialTimeout("tcp", conn.RemoteAddr().String(), time.Second); err == nil {
+		t.Error("listener still accepts connections after Drain")
+	}
+}
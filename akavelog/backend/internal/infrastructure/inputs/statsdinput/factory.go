@@ -0,0 +1,72 @@
+package statsdinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+const (
+	formatAuto   = "auto"
+	formatStatsd = "statsd"
+	formatInflux = "influx"
+)
+
+// Factory creates statsd / InfluxDB line protocol UDP inputs. Registers as "statsd".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "statsd"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "statsd",
+		Description: "UDP metrics listener. Parses statsd (name:value|type|@rate|#tags) and InfluxDB line protocol packets into one log entry per metric value, with metric_name, metric_value, metric_type and the metric's tags as tags.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind (UDP). Must be unique across inputs.", Example: ":8125"},
+			{Name: "format", Type: "string", Required: false, Description: "auto (default, detected per line), statsd or influx", Example: "auto"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for metric entries (default metrics)", Example: "legacy-app"},
+		},
+	}
+}
+
+// ValidateConfig validates statsd input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	c := Config{Format: formatAuto, Service: "metrics"}
+	listen, _ := cfg["listen"].(string)
+	c.Listen = strings.TrimSpace(listen)
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :8125)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :8125 or 0.0.0.0:8125)")
+	}
+	if v, _ := cfg["format"].(string); v != "" {
+		switch v {
+		case formatAuto, formatStatsd, formatInflux:
+			c.Format = v
+		default:
+			return c, fmt.Errorf("format must be one of auto, statsd, influx")
+		}
+	}
+	if v, _ := cfg["service"].(string); v != "" {
+		c.Service = v
+	}
+	return c, nil
+}
@@ -0,0 +1,175 @@
+package statsdinput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const maxPacketSize = 64 * 1024
+
+// Config holds the parsed settings of a statsd input.
+type Config struct {
+	Listen  string
+	Format  string // auto, statsd or influx
+	Service string
+}
+
+// Input listens for statsd / InfluxDB line protocol datagrams and writes one entry per metric.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	buffer inputs.InputBuffer
+
+	mu     sync.Mutex
+	packet net.PacketConn
+	wg     sync.WaitGroup
+	drain  inputs.Drainer
+}
+
+// NewInput creates a statsd input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer}
+}
+
+// Addr returns the bound address once started (useful when listening on :0).
+func (i *Input) Addr() net.Addr {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.packet != nil {
+		return i.packet.LocalAddr()
+	}
+	return nil
+}
+
+func (i *Input) Start() error {
+	pc, err := net.ListenPacket("udp", i.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listen udp %s: %w", i.cfg.Listen, err)
+	}
+	i.mu.Lock()
+	i.packet = pc
+	i.mu.Unlock()
+	i.wg.Add(1)
+	go i.readPackets(pc)
+	log.Printf("[statsd] listening on %s (format=%s)", i.cfg.Listen, i.cfg.Format)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	i.mu.Lock()
+	pc := i.packet
+	i.packet = nil
+	i.mu.Unlock()
+	var err error
+	if pc != nil {
+		err = pc.Close()
+	}
+	i.wg.Wait()
+	return err
+}
+
+// Drain reads the datagrams already received for up to inputs.DrainReadTimeout, then stops.
+// When ctx is done first it stops at once.
+func (i *Input) Drain(ctx context.Context) error {
+	deadline := i.drain.Begin()
+	i.mu.Lock()
+	pc := i.packet
+	i.mu.Unlock()
+	if pc != nil {
+		_ = pc.SetReadDeadline(deadline)
+	}
+	inputs.Wait(ctx, &i.wg)
+	return i.Stop()
+}
+
+func (i *Input) readPackets(pc net.PacketConn) {
+	defer i.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !(i.drain.Draining() && errors.Is(err, os.ErrDeadlineExceeded)) {
+				log.Printf("[statsd] udp read on %s: %v", i.cfg.Listen, err)
+				i.Fail(fmt.Errorf("udp read on %s: %w", i.cfg.Listen, err))
+			}
+			return
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			metrics, err := i.parseLine(line)
+			if err != nil {
+				log.Printf("[statsd] %s: %v", addr, err)
+				continue
+			}
+			for _, m := range metrics {
+				i.insert(m)
+			}
+		}
+	}
+}
+
+func (i *Input) parseLine(line string) ([]metric, error) {
+	switch i.cfg.Format {
+	case formatStatsd:
+		return parseStatsd(line)
+	case formatInflux:
+		return parseInflux(line)
+	}
+	if looksLikeStatsd(line) {
+		return parseStatsd(line)
+	}
+	return parseInflux(line)
+}
+
+// toEntry renders a metric as a LogEntry: "name=value" as the message, the parsed fields
+// as metric_* tags, and the metric's own tags alongside them.
+func toEntry(m metric, service string) model.LogEntry {
+	tags := make(map[string]string, len(m.Tags)+4)
+	for k, v := range m.Tags {
+		tags[k] = v
+	}
+	tags["metric_name"] = m.Name
+	tags["metric_value"] = m.Value
+	tags["metric_type"] = m.Type
+	if m.SampleRate != 1 {
+		tags["metric_sample_rate"] = strconv.FormatFloat(m.SampleRate, 'f', -1, 64)
+	}
+	ts := m.Time
+	if ts.IsZero() {
+		ts = time.Now().UTC()
+	}
+	return model.LogEntry{
+		Timestamp: ts.Format(time.RFC3339Nano),
+		Service:   service,
+		Level:     "info",
+		Message:   m.Name + "=" + m.Value,
+		Tags:      tags,
+	}
+}
+
+func (i *Input) insert(m metric) {
+	raw, err := json.Marshal(toEntry(m, i.cfg.Service))
+	if err != nil {
+		log.Printf("[statsd] marshal entry: %v", err)
+		return
+	}
+	if err := i.buffer.Insert(raw); err != nil {
+		log.Printf("[statsd] %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package statsdinput
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is one parsed value from either protocol.
+type metric struct {
+	Name       string
+	Value      string
+	Type       string // statsd: c, g, ms, h, s, d; influx: float, integer, string, boolean
+	SampleRate float64
+	Tags       map[string]string
+	Time       time.Time // zero when the line carries none
+}
+
+// looksLikeStatsd reports whether line is statsd: "name:value|type" where the first ':' and
+// '|' come before any space. Influx lines always have a space between tags and fields.
+func looksLikeStatsd(line string) bool {
+	colon := strings.IndexByte(line, ':')
+	pipe := strings.IndexByte(line, '|')
+	space := strings.IndexByte(line, ' ')
+	return colon > 0 && pipe > colon && (space < 0 || space > pipe)
+}
+
+// parseStatsd parses "name:value|type[|@rate][|#k:v,k2:v2]". Multiple values for one name
+// may be packed as "name:v1:v2|type" (DogStatsD extension); each yields a metric.
+func parseStatsd(line string) ([]metric, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("statsd: missing name in %q", line)
+	}
+	sections := strings.Split(rest, "|")
+	if len(sections) < 2 || sections[1] == "" {
+		return nil, fmt.Errorf("statsd: missing type in %q", line)
+	}
+	base := metric{Name: name, Type: sections[1], SampleRate: 1, Tags: map[string]string{}}
+	switch base.Type {
+	case "c", "g", "ms", "h", "s", "d":
+	default:
+		return nil, fmt.Errorf("statsd: unknown type %q", base.Type)
+	}
+	for _, s := range sections[2:] {
+		switch {
+		case strings.HasPrefix(s, "@"):
+			rate, err := strconv.ParseFloat(s[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("statsd: bad sample rate %q", s)
+			}
+			base.SampleRate = rate
+		case strings.HasPrefix(s, "#"):
+			for _, t := range strings.Split(s[1:], ",") {
+				if t == "" {
+					continue
+				}
+				k, v, _ := strings.Cut(t, ":")
+				base.Tags[k] = v
+			}
+		case strings.HasPrefix(s, "T"):
+			// DogStatsD timestamp extension: T<unix seconds>.
+			if sec, err := strconv.ParseInt(s[1:], 10, 64); err == nil {
+				base.Time = time.Unix(sec, 0).UTC()
+			}
+		}
+	}
+	var out []metric
+	for _, v := range strings.Split(sections[0], ":") {
+		if base.Type != "s" {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("statsd: bad value %q", v)
+			}
+		}
+		m := base
+		m.Value = v
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// parseInflux parses "measurement[,tag=v...] field=v[,field2=v2...] [timestamp_ns]".
+// Each field yields a metric named measurement.field ("value" fields keep the bare measurement).
+func parseInflux(line string) ([]metric, error) {
+	parts := splitUnescaped(line, ' ', true)
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("influx: expected measurement, fields and optional timestamp in %q", line)
+	}
+	head := splitUnescaped(parts[0], ',', false)
+	measurement := unescape(head[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("influx: missing measurement in %q", line)
+	}
+	tags := map[string]string{}
+	for _, kv := range head[1:] {
+		k, v, ok := cutUnescaped(kv, '=')
+		if !ok {
+			return nil, fmt.Errorf("influx: bad tag %q", kv)
+		}
+		tags[unescape(k)] = unescape(v)
+	}
+	var ts time.Time
+	if len(parts) == 3 {
+		ns, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("influx: bad timestamp %q", parts[2])
+		}
+		ts = time.Unix(0, ns).UTC()
+	}
+	var out []metric
+	for _, kv := range splitUnescaped(parts[1], ',', true) {
+		k, v, ok := cutUnescaped(kv, '=')
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("influx: bad field %q", kv)
+		}
+		value, typ, err := influxValue(v)
+		if err != nil {
+			return nil, err
+		}
+		name := measurement
+		if field := unescape(k); field != "value" {
+			name += "." + field
+		}
+		out = append(out, metric{Name: name, Value: value, Type: typ, SampleRate: 1, Tags: tags, Time: ts})
+	}
+	return out, nil
+}
+
+func influxValue(v string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		if len(v) < 2 || !strings.HasSuffix(v, `"`) {
+			return "", "", fmt.Errorf("influx: unterminated string %s", v)
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1]), "string", nil
+	case strings.HasSuffix(v, "i") || strings.HasSuffix(v, "u"):
+		if _, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSuffix(v, "i"), "u"), 10, 64); err == nil {
+			return v[:len(v)-1], "integer", nil
+		}
+	case v == "t" || v == "T" || v == "true" || v == "True" || v == "TRUE":
+		return "true", "boolean", nil
+	case v == "f" || v == "F" || v == "false" || v == "False" || v == "FALSE":
+		return "false", "boolean", nil
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return "", "", fmt.Errorf("influx: bad field value %q", v)
+	}
+	return v, "float", nil
+}
+
+// splitUnescaped splits s on sep, ignoring backslash-escaped separators and, when quotes is
+// set, separators inside double-quoted strings.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var out []string
+	start, inQuote := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			inQuote = !inQuote
+		case c == sep && !inQuote:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	parts := splitUnescaped(s, sep, true)
+	if len(parts) < 2 {
+		return s, "", false
+	}
+	return parts[0], s[len(parts[0])+1:], true
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ").Replace(s)
+}
@@ -0,0 +1,71 @@
+package statsdinput
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatsd(t *testing.T) {
+	ms, err := parseStatsd("api.requests:1|c|@0.5|#env:prod,region:eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 {
+		t.Fatalf("got %d metrics", len(ms))
+	}
+	m := ms[0]
+	if m.Name != "api.requests" || m.Value != "1" || m.Type != "c" || m.SampleRate != 0.5 || m.Tags["env"] != "prod" || m.Tags["region"] != "eu" {
+		t.Fatalf("unexpected metric: %+v", m)
+	}
+
+	ms, err = parseStatsd("latency:12:15.5|ms")
+	if err != nil || len(ms) != 2 || ms[1].Value != "15.5" {
+		t.Fatalf("packed values: %+v, %v", ms, err)
+	}
+	if _, err := parseStatsd("bad:abc|c"); err == nil {
+		t.Fatal("expected error for non-numeric counter")
+	}
+	if _, err := parseStatsd("users:alice|s"); err != nil {
+		t.Fatalf("set values are strings: %v", err)
+	}
+}
+
+func TestParseInflux(t *testing.T) {
+	ms, err := parseInflux(`cpu\ load,host=web\,1,region=eu usage=0.5,cores=8i,note="a b, c" 1700000000000000000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 3 {
+		t.Fatalf("got %d metrics, want 3", len(ms))
+	}
+	if ms[0].Name != "cpu load.usage" || ms[0].Value != "0.5" || ms[0].Type != "float" {
+		t.Fatalf("field 0: %+v", ms[0])
+	}
+	if ms[1].Value != "8" || ms[1].Type != "integer" {
+		t.Fatalf("field 1: %+v", ms[1])
+	}
+	if ms[2].Value != "a b, c" || ms[2].Type != "string" {
+		t.Fatalf("field 2: %+v", ms[2])
+	}
+	if ms[0].Tags["host"] != "web,1" || !ms[0].Time.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("tags/time: %+v", ms[0])
+	}
+
+	ms, err = parseInflux("temp value=21.5")
+	if err != nil || ms[0].Name != "temp" || !ms[0].Time.IsZero() {
+		t.Fatalf("value field: %+v, %v", ms, err)
+	}
+}
+
+func TestLooksLikeStatsd(t *testing.T) {
+	for line, want := range map[string]bool{
+		"a.b:1|c":                  true,
+		"a:1|g|#k:v":               true,
+		"cpu,host=a usage=1":       false,
+		"cpu,url=http://x usage=1": false,
+	} {
+		if got := looksLikeStatsd(line); got != want {
+			t.Errorf("looksLikeStatsd(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package inputs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSFields are the config fields of listeners that can terminate TLS themselves.
+// Factories append them to their ConfigSpec and call ServerTLSFromConfig.
+var TLSFields = []ConfigField{
+	{Name: "tls_cert", Type: "string", Required: false, Description: "PEM server certificate file; enables TLS together with tls_key", Example: "/etc/akavelog/tls/server.crt"},
+	{Name: "tls_key", Type: "string", Required: false, Description: "PEM server private key file", Example: "/etc/akavelog/tls/server.key"},
+	{Name: "tls_client_ca", Type: "string", Required: false, Description: "PEM CA bundle; when set, clients must present a certificate signed by it (mTLS)"},
+}
+
+// ServerTLSFromConfig builds a server tls.Config from tls_cert, tls_key and tls_client_ca.
+// It returns nil without error when TLS is not configured.
+func ServerTLSFromConfig(cfg Config) (*tls.Config, error) {
+	str := func(k string) string {
+		v, _ := cfg[k].(string)
+		return strings.TrimSpace(v)
+	}
+	cert, key, ca := str("tls_cert"), str("tls_key"), str("tls_client_ca")
+	if cert == "" && key == "" && ca == "" {
+		return nil, nil
+	}
+	if cert == "" || key == "" {
+		return nil, fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	return ServerTLSConfig(cert, key, ca)
+}
+
+// ServerTLSConfig loads a server certificate and, when clientCAFile is set, requires and verifies
+// client certificates signed by it.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s contains no PEM certificates", clientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
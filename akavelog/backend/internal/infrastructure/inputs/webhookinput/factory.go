@@ -0,0 +1,118 @@
+package webhookinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Factory creates signed webhook receivers. Registers as "webhook".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "webhook"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "webhook",
+		Description: "Webhook receiver on its own port. Verifies GitHub, Stripe, GitLab or generic HMAC signatures before accepting a payload and tags each entry with the event type.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":9010"},
+			{Name: "path", Type: "string", Required: false, Description: "Path served on the listen port", Example: "/webhook"},
+			{Name: "provider", Type: "string", Required: false, Description: "Signature scheme: github, stripe, gitlab, hmac or none (default none)", Example: "github"},
+			{Name: "secret", Type: "string", Required: false, Description: "Webhook secret; required unless provider is none"},
+			{Name: "signature_header", Type: "string", Required: false, Description: "hmac: header carrying the hex digest", Example: "X-Signature"},
+			{Name: "signature_prefix", Type: "string", Required: false, Description: "hmac: prefix before the digest in the header", Example: "sha256="},
+			{Name: "algorithm", Type: "string", Required: false, Description: "hmac: sha1, sha256 or sha512 (default sha256)", Example: "sha256"},
+			{Name: "event_header", Type: "string", Required: false, Description: "hmac/none: header naming the event type", Example: "X-Event-Type"},
+			{Name: "tolerance", Type: "string", Required: false, Description: "stripe: maximum age of the signed timestamp (default 5m)", Example: "5m"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for entries (default: the provider, or webhook)", Example: "github"},
+		},
+	}
+}
+
+// ValidateConfig validates webhook input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Listen:          str("listen"),
+		Path:            str("path"),
+		Provider:        strings.ToLower(str("provider")),
+		Secret:          str("secret"),
+		SignatureHeader: str("signature_header"),
+		SignaturePrefix: str("signature_prefix"),
+		Algorithm:       strings.ToLower(str("algorithm")),
+		EventHeader:     str("event_header"),
+		Tolerance:       5 * time.Minute,
+		Service:         str("service"),
+	}
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :9010)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :9010 or 0.0.0.0:9010)")
+	}
+	c.Path = "/" + strings.Trim(c.Path, "/")
+	if c.Path == "/" {
+		c.Path = "/webhook"
+	}
+	if c.Provider == "" {
+		c.Provider = providerNone
+	}
+	switch c.Provider {
+	case providerNone:
+	case providerGitHub, providerStripe, providerGitLab:
+		if c.Secret == "" {
+			return c, fmt.Errorf("secret is required for provider %q", c.Provider)
+		}
+	case providerHMAC:
+		if c.Secret == "" {
+			return c, fmt.Errorf("secret is required for provider %q", c.Provider)
+		}
+		if c.SignatureHeader == "" {
+			c.SignatureHeader = "X-Signature"
+		}
+		if c.Algorithm == "" {
+			c.Algorithm = "sha256"
+		}
+		if hashFor(c.Algorithm) == nil {
+			return c, fmt.Errorf("algorithm must be one of sha1, sha256, sha512")
+		}
+	default:
+		return c, fmt.Errorf("provider must be one of github, stripe, gitlab, hmac, none")
+	}
+	if v := str("tolerance"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("tolerance must be a positive duration (e.g. 5m)")
+		}
+		c.Tolerance = d
+	}
+	if c.Service == "" {
+		c.Service = c.Provider
+		if c.Provider == providerNone || c.Provider == providerHMAC {
+			c.Service = "webhook"
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,130 @@
+package webhookinput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/tracing"
+)
+
+const maxRequestBody = 25 << 20 // 25MB, GitHub's payload cap
+
+// Config holds the parsed settings of a webhook input.
+type Config struct {
+	Listen          string
+	Path            string
+	Provider        string // github, stripe, gitlab, hmac or none
+	Secret          string
+	SignatureHeader string // hmac only
+	SignaturePrefix string // hmac only
+	Algorithm       string // hmac only
+	EventHeader     string
+	Tolerance       time.Duration // stripe only
+	Service         string
+}
+
+// Input receives webhooks on its own port, verifies them and writes one entry per delivery.
+type Input struct {
+	inputs.HealthState
+
+	cfg    Config
+	buffer inputs.InputBuffer
+	server *http.Server
+}
+
+// NewInput creates a webhook input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	return &Input{cfg: cfg, buffer: buffer}
+}
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.cfg.Path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, "read error", http.StatusBadRequest)
+			return
+		}
+		if err := i.cfg.verify(r.Header, body, time.Now()); err != nil {
+			log.Printf("[webhook] rejected %s delivery from %s: %v", i.cfg.Provider, r.RemoteAddr, err)
+			status := http.StatusUnauthorized
+			if errors.Is(err, errMissingSignature) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		raw, err := json.Marshal(i.entry(r, body))
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if err := inputs.InsertContext(r.Context(), i.buffer, raw); err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), inputs.BackpressureStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// entry keeps the payload verbatim as the message; the event type and delivery ID become tags.
+func (i *Input) entry(r *http.Request, body []byte) model.LogEntry {
+	var payload map[string]any
+	_ = json.Unmarshal(body, &payload)
+	event, delivery := i.cfg.eventInfo(r.Header, payload)
+	tags := map[string]string{"webhook_provider": i.cfg.Provider}
+	if event != "" {
+		tags["event_type"] = event
+	}
+	if delivery != "" {
+		tags["delivery_id"] = delivery
+	}
+	return model.LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Service:   i.cfg.Service,
+		Level:     "info",
+		Message:   string(body),
+		Tags:      tags,
+	}
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.cfg.Listen,
+		Handler: tracing.Handler("ingest.webhook", i.Handler()),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[webhook] listener %s: %v", i.cfg.Listen, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.cfg.Listen, err))
+		}
+	}()
+	log.Printf("[webhook] listening on %s%s (provider=%s)", i.cfg.Listen, i.cfg.Path, i.cfg.Provider)
+	return nil
+}
+
+func (i *Input) Stop() error {
+	if i.server != nil {
+		return i.server.Close()
+	}
+	return nil
+}
+
+// Drain stops accepting requests and waits for the ones being served, until ctx is done.
+func (i *Input) Drain(ctx context.Context) error {
+	return inputs.ShutdownServer(ctx, i.server)
+}
@@ -0,0 +1,152 @@
+package webhookinput
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	providerNone   = "none"
+	providerGitHub = "github"
+	providerStripe = "stripe"
+	providerGitLab = "gitlab"
+	providerHMAC   = "hmac"
+)
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errBadSignature     = errors.New("signature mismatch")
+	errStaleTimestamp   = errors.New("signature timestamp outside tolerance")
+)
+
+func hashFor(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "sha1":
+		return sha1.New
+	case "sha256":
+		return sha256.New
+	case "sha512":
+		return sha512.New
+	}
+	return nil
+}
+
+func computeHMAC(h func() hash.Hash, secret string, parts ...[]byte) []byte {
+	mac := hmac.New(h, []byte(secret))
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return mac.Sum(nil)
+}
+
+// equalHex compares a hex-encoded signature against the expected digest in constant time.
+func equalHex(sig string, want []byte) bool {
+	got, err := hex.DecodeString(strings.TrimSpace(sig))
+	return err == nil && hmac.Equal(got, want)
+}
+
+// verify checks the request signature for the configured provider.
+func (c Config) verify(h http.Header, body []byte, now time.Time) error {
+	switch c.Provider {
+	case providerGitHub:
+		// X-Hub-Signature-256: sha256=<hex HMAC-SHA256(secret, body)>
+		sig := h.Get("X-Hub-Signature-256")
+		if sig == "" {
+			return errMissingSignature
+		}
+		if !equalHex(strings.TrimPrefix(sig, "sha256="), computeHMAC(sha256.New, c.Secret, body)) {
+			return errBadSignature
+		}
+	case providerGitLab:
+		// GitLab sends the secret token verbatim.
+		tok := h.Get("X-Gitlab-Token")
+		if tok == "" {
+			return errMissingSignature
+		}
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(c.Secret)) != 1 {
+			return errBadSignature
+		}
+	case providerStripe:
+		return c.verifyStripe(h.Get("Stripe-Signature"), body, now)
+	case providerHMAC:
+		sig := h.Get(c.SignatureHeader)
+		if sig == "" {
+			return errMissingSignature
+		}
+		if !equalHex(strings.TrimPrefix(sig, c.SignaturePrefix), computeHMAC(hashFor(c.Algorithm), c.Secret, body)) {
+			return errBadSignature
+		}
+	}
+	return nil
+}
+
+// verifyStripe checks "Stripe-Signature: t=<unix>,v1=<hex>[,v1=...]", where each v1 is
+// HMAC-SHA256(secret, "<t>.<body>"). Any matching v1 is accepted (secret rotation).
+func (c Config) verifyStripe(header string, body []byte, now time.Time) error {
+	if header == "" {
+		return errMissingSignature
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == "" || len(sigs) == 0 {
+		return errMissingSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errMissingSignature
+	}
+	age := now.Sub(time.Unix(sec, 0))
+	if age > c.Tolerance || age < -c.Tolerance {
+		return errStaleTimestamp
+	}
+	want := computeHMAC(sha256.New, c.Secret, []byte(ts), []byte("."), body)
+	for _, s := range sigs {
+		if equalHex(s, want) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
+
+// eventInfo returns the provider's event type and delivery ID for tagging.
+func (c Config) eventInfo(h http.Header, payload map[string]any) (event, delivery string) {
+	switch c.Provider {
+	case providerGitHub:
+		return h.Get("X-GitHub-Event"), h.Get("X-GitHub-Delivery")
+	case providerGitLab:
+		return h.Get("X-Gitlab-Event"), h.Get("X-Gitlab-Event-UUID")
+	case providerStripe:
+		event, _ = payload["type"].(string)
+		delivery, _ = payload["id"].(string)
+		return event, delivery
+	}
+	if c.EventHeader != "" {
+		event = h.Get(c.EventHeader)
+	}
+	if event == "" {
+		event, _ = payload["type"].(string)
+	}
+	return event, ""
+}
@@ -0,0 +1,122 @@
+package webhookinput
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify_Providers(t *testing.T) {
+	body := `{"type":"invoice.paid","id":"evt_1"}`
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	cases := []struct {
+		name    string
+		cfg     inputs.Config
+		headers map[string]string
+		wantErr error
+	}{
+		{"github ok", inputs.Config{"provider": "github"}, map[string]string{"X-Hub-Signature-256": "sha256=" + sign("s3cret", body)}, nil},
+		{"github bad", inputs.Config{"provider": "github"}, map[string]string{"X-Hub-Signature-256": "sha256=" + sign("other", body)}, errBadSignature},
+		{"github missing", inputs.Config{"provider": "github"}, nil, errMissingSignature},
+		{"gitlab ok", inputs.Config{"provider": "gitlab"}, map[string]string{"X-Gitlab-Token": "s3cret"}, nil},
+		{"gitlab bad", inputs.Config{"provider": "gitlab"}, map[string]string{"X-Gitlab-Token": "nope"}, errBadSignature},
+		{"stripe ok", inputs.Config{"provider": "stripe"}, map[string]string{"Stripe-Signature": "t=" + ts + ",v1=deadbeef,v1=" + sign("s3cret", ts+"."+body)}, nil},
+		{"stripe stale", inputs.Config{"provider": "stripe", "tolerance": "1s"}, map[string]string{"Stripe-Signature": "t=" + strconv.FormatInt(now.Unix()-60, 10) + ",v1=" + sign("s3cret", ts+"."+body)}, errStaleTimestamp},
+		{"hmac ok", inputs.Config{"provider": "hmac", "signature_header": "X-Sig", "signature_prefix": "sha256="}, map[string]string{"X-Sig": "sha256=" + sign("s3cret", body)}, nil},
+		{"none", inputs.Config{}, nil, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg["listen"] = ":0"
+			tc.cfg["secret"] = "s3cret"
+			c, err := parseConfig(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			if err := c.verify(h, []byte(body), now); err != tc.wantErr {
+				t.Fatalf("verify = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfig_RequiresSecret(t *testing.T) {
+	if _, err := parseConfig(inputs.Config{"listen": ":0", "provider": "github"}); err == nil {
+		t.Fatal("expected error without secret")
+	}
+	if _, err := parseConfig(inputs.Config{"listen": ":0", "provider": "bitbucket", "secret": "x"}); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
+
+func TestHandler_TagsEventType(t *testing.T) {
+	c, err := parseConfig(inputs.Config{"listen": ":0", "provider": "github", "secret": "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &memBuffer{}
+	in := NewInput(c, buf)
+	body := `{"ref":"refs/heads/main"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("s3cret", body))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", "abc-123")
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+	if len(buf.msgs) != 1 {
+		t.Fatalf("got %d entries, want 1", len(buf.msgs))
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal(buf.msgs[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Service != "github" || entry.Tags["event_type"] != "push" || entry.Tags["delivery_id"] != "abc-123" || entry.Message != body {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+sign("wrong", body))
+	rec = httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || len(buf.msgs) != 1 {
+		t.Fatalf("forged delivery: status=%d entries=%d", rec.Code, len(buf.msgs))
+	}
+}
@@ -0,0 +1,108 @@
+package wsinput
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+const (
+	defaultRateLimit      = 100
+	defaultMaxMessageSize = 1 << 20
+	defaultPingInterval   = 30 * time.Second
+)
+
+// Factory creates WebSocket ingest inputs. Registers as "websocket".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "websocket"
+}
+
+func (f *Factory) ConfigSpec() inputs.InputTypeInfo {
+	return inputs.InputTypeInfo{
+		Type:        "websocket",
+		Description: "WebSocket endpoint on its own port. Clients keep one connection open and send JSON log frames (an object or an array of objects per frame); the server pings to keep connections alive and rate-limits each connection.",
+		Fields: []inputs.ConfigField{
+			{Name: "listen", Type: "string", Required: true, Description: "host:port to bind. Must be unique across inputs.", Example: ":9020"},
+			{Name: "path", Type: "string", Required: false, Description: "Path that accepts upgrades", Example: "/ws"},
+			{Name: "rate_limit", Type: "number", Required: false, Description: "Log entries per second allowed per connection; 0 disables the limit (default 100)", Example: "100"},
+			{Name: "burst", Type: "number", Required: false, Description: "Entries a connection may send at once above rate_limit (default 2x rate_limit)", Example: "200"},
+			{Name: "max_message_size", Type: "number", Required: false, Description: "Largest accepted frame in bytes (default 1048576)", Example: "1048576"},
+			{Name: "ping_interval", Type: "string", Required: false, Description: "Ping period; a connection that misses a pong for two periods is closed (default 30s)", Example: "30s"},
+			{Name: "allowed_origins", Type: "string", Required: false, Description: "Comma-separated Origin values allowed to connect; empty allows any", Example: "https://app.example.com"},
+			{Name: "service", Type: "string", Required: false, Description: "Service name for plain-text frames (default websocket)", Example: "web-frontend"},
+		},
+		IngestKeys: true,
+	}
+}
+
+// ValidateConfig validates websocket input config.
+func (f *Factory) ValidateConfig(cfg inputs.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+func (f *Factory) Create(cfg inputs.Config, buffer inputs.InputBuffer) (inputs.MessageInput, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewInput(c, buffer), nil
+}
+
+func parseConfig(cfg inputs.Config) (Config, error) {
+	c := Config{
+		RateLimit:      defaultRateLimit,
+		MaxMessageSize: defaultMaxMessageSize,
+		PingInterval:   defaultPingInterval,
+		AllowedOrigins: cfg.Strings("allowed_origins"),
+		Service:        "websocket",
+	}
+	listen, _ := cfg["listen"].(string)
+	c.Listen = strings.TrimSpace(listen)
+	if c.Listen == "" {
+		return c, fmt.Errorf("listen is required: each input must have its own port (e.g. :9020)")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return c, fmt.Errorf("listen must be host:port or :port (e.g. :9020 or 0.0.0.0:9020)")
+	}
+	path, _ := cfg["path"].(string)
+	c.Path = "/" + strings.Trim(strings.TrimSpace(path), "/")
+	if c.Path == "/" {
+		c.Path = "/ws"
+	}
+	if v, ok := cfg.Int("rate_limit"); ok {
+		if v < 0 {
+			return c, fmt.Errorf("rate_limit must not be negative")
+		}
+		c.RateLimit = v
+	}
+	c.Burst = 2 * c.RateLimit
+	if v, ok := cfg.Int("burst"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("burst must be positive")
+		}
+		c.Burst = v
+	}
+	if v, ok := cfg.Int("max_message_size"); ok {
+		if v <= 0 {
+			return c, fmt.Errorf("max_message_size must be positive")
+		}
+		c.MaxMessageSize = v
+	}
+	if v, _ := cfg["ping_interval"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("ping_interval must be a positive duration (e.g. 30s)")
+		}
+		c.PingInterval = d
+	}
+	if v, _ := cfg["service"].(string); v != "" {
+		c.Service = v
+	}
+	return c, nil
+}
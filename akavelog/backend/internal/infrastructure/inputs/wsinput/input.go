@@ -0,0 +1,259 @@
+package wsinput
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
+)
+
+const writeWait = 10 * time.Second
+
+// Config holds the parsed settings of a websocket input.
+type Config struct {
+	Listen         string
+	Path           string
+	RateLimit      int // entries/s per connection; 0 = unlimited
+	Burst          int
+	MaxMessageSize int
+	PingInterval   time.Duration
+	AllowedOrigins []string // empty = any origin
+	Service        string
+}
+
+// Input accepts long-lived WebSocket connections carrying JSON log frames.
+type Input struct {
+	inputs.HealthState
+
+	cfg      Config
+	buffer   inputs.InputBuffer
+	metrics  *inputs.Metrics
+	upgrader websocket.Upgrader
+	server   *http.Server
+	keys     inputs.IngestKeys // optional; see SetIngestKeys
+
+	mu    sync.Mutex
+	conns map[*websocket.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// NewInput creates a websocket input from a parsed Config.
+func NewInput(cfg Config, buffer inputs.InputBuffer) *Input {
+	i := &Input{cfg: cfg, buffer: buffer, metrics: inputs.MetricsOf(buffer), conns: make(map[*websocket.Conn]struct{})}
+	i.upgrader = websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			return len(cfg.AllowedOrigins) == 0 || slices.Contains(cfg.AllowedOrigins, r.Header.Get("Origin"))
+		},
+	}
+	return i
+}
+
+// frameReply is sent back when a frame is rejected; accepted frames get no reply.
+type frameReply struct {
+	Error   string `json:"error"`
+	Dropped int    `json:"dropped"`
+}
+
+// SetIngestKeys makes the input require one of the ingest keys of keys once there are any,
+// in Authorization: Bearer, X-Akavelog-Token or, for browsers, the token query parameter.
+func (i *Input) SetIngestKeys(keys inputs.IngestKeys) { i.keys = keys }
+
+func (i *Input) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.cfg.Path, func(w http.ResponseWriter, r *http.Request) {
+		required := false
+		if i.keys != nil {
+			var err error
+			if required, err = i.keys.Enabled(); err != nil {
+				i.metrics.Error()
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if required {
+			token := inputs.RequestToken(r)
+			if token == "" {
+				token = r.URL.Query().Get("token")
+			}
+			if token == "" || !i.keys.Accepts(token) {
+				i.metrics.Error()
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		conn, err := i.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade already wrote the HTTP error
+		}
+		i.mu.Lock()
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+		i.metrics.ConnOpened()
+		i.wg.Add(1)
+		go i.serve(conn)
+	})
+	return mux
+}
+
+// serve reads frames until the peer goes away. The read deadline is pushed forward by every
+// pong, so a client that stops answering pings is dropped after two ping periods.
+func (i *Input) serve(conn *websocket.Conn) {
+	defer i.wg.Done()
+	defer func() {
+		i.mu.Lock()
+		delete(i.conns, conn)
+		i.mu.Unlock()
+		i.metrics.ConnClosed()
+		conn.Close()
+	}()
+	pongWait := 2 * i.cfg.PingInterval
+	conn.SetReadLimit(int64(i.cfg.MaxMessageSize))
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	var writeMu sync.Mutex
+	go func() {
+		ticker := time.NewTicker(i.cfg.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				writeMu.Lock()
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+				writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if i.cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(i.cfg.RateLimit), i.cfg.Burst)
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				i.metrics.Error()
+				log.Printf("[websocket] %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		frames := splitFrame(data)
+		reply := admit(limiter, len(frames))
+		if reply != nil {
+			i.metrics.Error()
+		} else {
+			for n, f := range frames {
+				if err := i.buffer.Insert(inputs.WrapPlain(f, i.cfg.Service)); err != nil {
+					reply = &frameReply{Error: err.Error(), Dropped: len(frames) - n}
+					break
+				}
+			}
+		}
+		if reply != nil {
+			writeMu.Lock()
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := conn.WriteJSON(reply)
+			writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// admit takes a token of limiter for each of the n entries of a frame, or returns the reply
+// rejecting all of them. A frame of more entries than the burst could never be admitted, so
+// it is refused as too large rather than as over the rate.
+func admit(limiter *rate.Limiter, n int) *frameReply {
+	if limiter.Limit() != rate.Inf && n > limiter.Burst() {
+		return &frameReply{Error: fmt.Sprintf("frame too large: %d entries, at most %d per frame", n, limiter.Burst()), Dropped: n}
+	}
+	if !limiter.AllowN(time.Now(), n) {
+		return &frameReply{Error: "rate limit exceeded", Dropped: n}
+	}
+	return nil
+}
+
+// splitFrame returns the entries of one frame: each element of a JSON array, or the frame itself.
+func splitFrame(data []byte) [][]byte {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if trimmed[0] == '[' {
+		var arr []json.RawMessage
+		if json.Unmarshal(trimmed, &arr) == nil {
+			out := make([][]byte, 0, len(arr))
+			for _, el := range arr {
+				out = append(out, el)
+			}
+			return out
+		}
+	}
+	return [][]byte{trimmed}
+}
+
+func (i *Input) Start() error {
+	i.server = &http.Server{
+		Addr:    i.cfg.Listen,
+		Handler: i.Handler(),
+	}
+	go func() {
+		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[websocket] listener %s: %v", i.cfg.Listen, err)
+			i.Fail(fmt.Errorf("listener %s: %w", i.cfg.Listen, err))
+		}
+	}()
+	log.Printf("[websocket] listening on %s%s", i.cfg.Listen, i.cfg.Path)
+	return nil
+}
+
+// Stop closes the listener and every open connection. Hijacked connections are not closed
+// by http.Server.Close, so they are tracked separately.
+func (i *Input) Stop() error {
+	var err error
+	if i.server != nil {
+		err = i.server.Close()
+	}
+	i.mu.Lock()
+	for conn := range i.conns {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "input stopped"), time.Now().Add(time.Second))
+		conn.Close()
+	}
+	i.mu.Unlock()
+	i.wg.Wait()
+	return err
+}
+
+// Drain stops accepting connections and waits for the upgrades in progress, until ctx is
+// done, then closes the open connections like Stop.
+func (i *Input) Drain(ctx context.Context) error {
+	err := inputs.ShutdownServer(ctx, i.server)
+	if stopErr := i.Stop(); err == nil {
+		err = stopErr
+	}
+	return err
+}
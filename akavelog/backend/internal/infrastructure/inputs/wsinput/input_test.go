@@ -0,0 +1,124 @@
+package wsinput
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	msgs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, append([]byte(nil), p...))
+	return nil
+}
+
+func (b *memBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.msgs)
+}
+
+func dial(t *testing.T, cfg inputs.Config) (*websocket.Conn, *memBuffer) {
+	t.Helper()
+	c, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &memBuffer{}
+	in := NewInput(c, buf)
+	srv := httptest.NewServer(in.Handler())
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, buf
+}
+
+func TestInput_ObjectsAndArrays(t *testing.T) {
+	conn, buf := dial(t, inputs.Config{"listen": ":0"})
+	frames := []string{
+		`{"service":"web","message":"one"}`,
+		`[{"service":"web","message":"two"},{"service":"web","message":"three"}]`,
+		`plain text`,
+	}
+	for _, f := range frames {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for buf.Len() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if buf.Len() != 4 {
+		t.Fatalf("got %d entries, want 4", buf.Len())
+	}
+	if !strings.Contains(string(buf.msgs[3]), `"service":"websocket"`) {
+		t.Fatalf("plain frame not wrapped: %s", buf.msgs[3])
+	}
+}
+
+func TestInput_RateLimit(t *testing.T) {
+	conn, buf := dial(t, inputs.Config{"listen": ":0", "rate_limit": 1, "burst": 2})
+	frame := `[{"service":"a","message":"1"},{"service":"a","message":"2"},{"service":"a","message":"3"}]`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+	var reply frameReply
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Dropped != 3 || !strings.HasPrefix(reply.Error, "frame too large") || buf.Len() != 0 {
+		t.Fatalf("reply=%+v entries=%d", reply, buf.Len())
+	}
+
+	// Frames within the burst are taken until the tokens run out.
+	frame = `[{"service":"a","message":"1"},{"service":"a","message":"2"}]`
+	for n := 0; n < 2; n++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Dropped != 2 || reply.Error != "rate limit exceeded" || buf.Len() != 2 {
+		t.Fatalf("reply=%+v entries=%d", reply, buf.Len())
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		limiter *rate.Limiter
+		n       int
+		want    string
+	}{
+		{"unlimited", rate.NewLimiter(rate.Inf, 0), 1000, ""},
+		{"within burst", rate.NewLimiter(1, 5), 5, ""},
+		{"over burst", rate.NewLimiter(1, 5), 6, "frame too large: 6 entries, at most 5 per frame"},
+	} {
+		reply := admit(tc.limiter, tc.n)
+		switch {
+		case reply == nil && tc.want != "":
+			t.Errorf("%s: admitted, want %q", tc.name, tc.want)
+		case reply != nil && (reply.Error != tc.want || reply.Dropped != tc.n):
+			t.Errorf("%s: %+v, want %q", tc.name, reply, tc.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package outputs
+
+import (
+	"context"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+)
+
+// Buffer implements inputs.InputBuffer: it inserts every payload into Next (the batcher) and
+// hands the valid ones Next took to the Dispatcher as well.
+type Buffer struct {
+	Dispatcher *Dispatcher
+	Next       inputs.InputBuffer
+}
+
+func (b *Buffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+// InsertContext is Insert for a payload whose trace ctx carries.
+func (b *Buffer) InsertContext(ctx context.Context, p []byte) error {
+	if err := inputs.InsertContext(ctx, b.Next, p); err != nil {
+		return err
+	}
+	if !b.Dispatcher.Active() {
+		return nil
+	}
+	entry, err := batcher.ValidateLog(p)
+	if err != nil {
+		return nil
+	}
+	b.Dispatcher.Dispatch(entry)
+	return nil
+}
@@ -0,0 +1,340 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// QueueSize is how many entries each output buffers; further entries are dropped and
+	// counted until the output catches up.
+	QueueSize = 10000
+	// BatchSize is the most entries handed to one Output.Write.
+	BatchSize = 500
+	// FlushInterval is how long an output waits for a batch to fill.
+	FlushInterval = time.Second
+
+	writeTimeout = 30 * time.Second
+)
+
+// Status is the runtime view of one output, as returned by the API.
+type Status struct {
+	Queued      int            `json:"queued"`
+	Sent        int64          `json:"sent"`
+	Failed      int64          `json:"failed"`  // entries in batches whose Write failed
+	Dropped     int64          `json:"dropped"` // entries that found the queue full
+	LastSentAt  *time.Time     `json:"last_sent_at,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	LastErrorAt *time.Time     `json:"last_error_at,omitempty"`
+	Details     map[string]any `json:"details,omitempty"` // from StatsReporter outputs
+}
+
+// runner feeds one Output from its queue in batches.
+type runner struct {
+	def   model.Output
+	out   Output
+	queue chan model.LogEntry
+	stop  chan struct{}
+	done  chan struct{}
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	mu          sync.Mutex
+	lastSentAt  time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (r *runner) run() {
+	defer close(r.done)
+	t := time.NewTicker(FlushInterval)
+	defer t.Stop()
+	batch := make([]model.LogEntry, 0, BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			r.write(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case e := <-r.queue:
+			batch = append(batch, e)
+			if len(batch) >= BatchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-r.stop:
+			for {
+				select {
+				case e := <-r.queue:
+					batch = append(batch, e)
+					if len(batch) >= BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *runner) write(batch []model.LogEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	err := r.out.Write(ctx, batch)
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.failed.Add(int64(len(batch)))
+		r.lastError, r.lastErrorAt = err.Error(), now
+		log.Printf("[outputs] %s: write %d entries: %v", r.def.Name, len(batch), err)
+		return
+	}
+	r.sent.Add(int64(len(batch)))
+	r.lastSentAt = now
+}
+
+func (r *runner) enqueue(e model.LogEntry) {
+	select {
+	case r.queue <- e:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// enqueueWait waits for room in the queue until ctx is done. An entry for a runner that is
+// stopping is dropped.
+func (r *runner) enqueueWait(ctx context.Context, e model.LogEntry) error {
+	select {
+	case r.queue <- e:
+		return nil
+	case <-r.stop:
+		r.dropped.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *runner) status() Status {
+	st := Status{Queued: len(r.queue), Sent: r.sent.Load(), Failed: r.failed.Load(), Dropped: r.dropped.Load()}
+	if sr, ok := r.out.(StatsReporter); ok {
+		st.Details = sr.Stats()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.lastSentAt.IsZero() {
+		t := r.lastSentAt
+		st.LastSentAt = &t
+	}
+	if r.lastError != "" {
+		t := r.lastErrorAt
+		st.LastError, st.LastErrorAt = r.lastError, &t
+	}
+	return st
+}
+
+// shutdown stops the runner after it has written what is queued, then closes the Output.
+func (r *runner) shutdown() {
+	close(r.stop)
+	<-r.done
+	if err := r.out.Close(); err != nil {
+		log.Printf("[outputs] %s: close: %v", r.def.Name, err)
+	}
+}
+
+type runnerSet struct {
+	all    []*runner // AllEntries outputs
+	byName map[string]*runner
+	byID   map[uuid.UUID]*runner
+}
+
+// Dispatcher runs the enabled outputs and hands each entry to the outputs it is meant for.
+// Load swaps the set atomically, like pipeline.Manager.
+type Dispatcher struct {
+	registry *Registry
+	route    func(e *model.LogEntry) []string
+
+	mu      sync.Mutex // serializes Load and Close
+	current atomic.Pointer[runnerSet]
+}
+
+// NewDispatcher returns a Dispatcher that creates outputs from registry. route returns the
+// names of the outputs an entry is routed to besides the AllEntries ones, e.g.
+// streams.Router.Outputs; it may be nil.
+func NewDispatcher(registry *Registry, route func(e *model.LogEntry) []string) *Dispatcher {
+	d := &Dispatcher{registry: registry, route: route}
+	d.current.Store(&runnerSet{byName: map[string]*runner{}, byID: map[uuid.UUID]*runner{}})
+	return d
+}
+
+// DecodeConfig returns the configuration of o as a Config (empty when unset), with its
+// secret references resolved.
+func DecodeConfig(o model.Output) (Config, error) {
+	cfg := make(Config)
+	if len(o.Configuration) > 0 {
+		if err := json.Unmarshal(o.Configuration, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return cfg.Resolved()
+}
+
+// Validate creates (and closes) the Output for o without starting it.
+func (d *Dispatcher) Validate(o model.Output) error {
+	out, err := d.create(o)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func (d *Dispatcher) create(o model.Output) (Output, error) {
+	cfg, err := DecodeConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	return d.registry.Create(o.Type, cfg)
+}
+
+// Load starts the enabled outputs in list and stops the others. Outputs whose definition is
+// unchanged (same UpdatedAt) keep running with their queue and counters; changed ones are
+// replaced after their queue is written. An output that fails to create is skipped and its
+// error returned.
+func (d *Dispatcher) Load(list []model.Output) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev := d.current.Load()
+	next := &runnerSet{byName: make(map[string]*runner, len(list)), byID: make(map[uuid.UUID]*runner, len(list))}
+	var errs []error
+	for _, o := range list {
+		if !o.Enabled {
+			continue
+		}
+		r, ok := prev.byID[o.ID]
+		if !ok || !r.def.UpdatedAt.Equal(o.UpdatedAt) {
+			out, err := d.create(o)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("output %q: %w", o.Name, err))
+				continue
+			}
+			r = &runner{def: o, out: out, queue: make(chan model.LogEntry, QueueSize), stop: make(chan struct{}), done: make(chan struct{})}
+			go r.run()
+		}
+		next.byID[o.ID] = r
+		next.byName[o.Name] = r
+		if o.AllEntries {
+			next.all = append(next.all, r)
+		}
+	}
+	d.current.Store(next)
+	for id, r := range prev.byID {
+		if next.byID[id] != r {
+			r.shutdown()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Active reports whether any output is running, so callers can skip decoding entries.
+func (d *Dispatcher) Active() bool {
+	return len(d.current.Load().byID) > 0
+}
+
+// Dispatch queues a copy of e on every output it is routed to. It never blocks.
+func (d *Dispatcher) Dispatch(e *model.LogEntry) {
+	for _, r := range d.targets(e) {
+		r.enqueue(*e)
+	}
+}
+
+// DispatchWait is Dispatch for backfills such as replays: it waits for room in the queues
+// instead of dropping e, until ctx is done. When only is not empty, e goes just to the outputs
+// it names among those it is routed to.
+func (d *Dispatcher) DispatchWait(ctx context.Context, e *model.LogEntry, only []string) error {
+	for _, r := range d.targets(e) {
+		if len(only) > 0 && !slices.Contains(only, r.def.Name) {
+			continue
+		}
+		if err := r.enqueueWait(ctx, *e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targets returns the running outputs e is routed to, each once.
+func (d *Dispatcher) targets(e *model.LogEntry) []*runner {
+	set := d.current.Load()
+	if len(set.byID) == 0 {
+		return nil
+	}
+	var names []string
+	if d.route != nil {
+		names = d.route(e)
+	}
+	if len(set.all) == 0 && len(names) == 0 {
+		return nil
+	}
+	out := append(make([]*runner, 0, len(set.all)+len(names)), set.all...)
+	for _, name := range names {
+		if r, ok := set.byName[name]; ok && !slices.Contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Running reports whether an output called name is running.
+func (d *Dispatcher) Running(name string) bool {
+	_, ok := d.current.Load().byName[name]
+	return ok
+}
+
+// Send queues a copy of e on the running output called name, whatever the routing, e.g. to
+// announce a report. It reports false when no such output is running. It never blocks.
+func (d *Dispatcher) Send(name string, e *model.LogEntry) bool {
+	r, ok := d.current.Load().byName[name]
+	if !ok {
+		return false
+	}
+	r.enqueue(*e)
+	return true
+}
+
+// Status reports the counters of a running output. ok is false when it is not running
+// (unknown, disabled or failed to create).
+func (d *Dispatcher) Status(id uuid.UUID) (Status, bool) {
+	r, ok := d.current.Load().byID[id]
+	if !ok {
+		return Status{}, false
+	}
+	return r.status(), true
+}
+
+// Close writes what is queued and stops every output, for use on shutdown.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev := d.current.Swap(&runnerSet{byName: map[string]*runner{}, byID: map[uuid.UUID]*runner{}})
+	for _, r := range prev.byID {
+		r.shutdown()
+	}
+}
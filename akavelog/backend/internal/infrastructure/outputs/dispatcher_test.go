@@ -0,0 +1,188 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+type memOutput struct {
+	mu      sync.Mutex
+	entries []model.LogEntry
+	fail    bool
+	closed  bool
+}
+
+func (o *memOutput) Write(_ context.Context, entries []model.LogEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.fail {
+		return errors.New("unavailable")
+	}
+	o.entries = append(o.entries, entries...)
+	return nil
+}
+
+func (o *memOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	return nil
+}
+
+// memFactory hands out one memOutput per output name.
+type memFactory struct {
+	mu   sync.Mutex
+	outs map[string]*memOutput
+}
+
+func (f *memFactory) Name() string               { return "mem" }
+func (f *memFactory) ConfigSpec() OutputTypeInfo { return OutputTypeInfo{Type: "mem"} }
+func (f *memFactory) Create(cfg Config) (Output, error) {
+	name, _ := cfg["name"].(string)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := &memOutput{fail: name == "down"}
+	f.outs[name] = o
+	return o, nil
+}
+
+func memDef(name string, all bool) model.Output {
+	return model.Output{ID: uuid.New(), Name: name, Type: "mem", Enabled: true, AllEntries: all,
+		Configuration: []byte(`{"name": "` + name + `"}`), UpdatedAt: time.Unix(1, 0)}
+}
+
+func TestDispatcher(t *testing.T) {
+	f := &memFactory{outs: map[string]*memOutput{}}
+	reg := NewRegistry()
+	reg.Register(f)
+	d := NewDispatcher(reg, func(e *model.LogEntry) []string {
+		if e.Service == "audit" {
+			return []string{"siem", "siem", "missing"}
+		}
+		return nil
+	})
+
+	archive, siem, down := memDef("archive", true), memDef("siem", false), memDef("down", true)
+	bad := memDef("bad", false)
+	bad.Configuration = []byte(`{}`)
+	off := memDef("off", true)
+	off.Enabled = false
+	if err := d.Load([]model.Output{archive, siem, down, bad, off}); err == nil {
+		t.Error("invalid output loaded without error")
+	}
+	if !d.Active() {
+		t.Fatal("no outputs running")
+	}
+	d.Dispatch(&model.LogEntry{Service: "api", Message: "a"})
+	d.Dispatch(&model.LogEntry{Service: "audit", Message: "b"})
+	if !d.Send("siem", &model.LogEntry{Service: "api", Message: "c"}) {
+		t.Error("Send to a running output failed")
+	}
+	if d.Send("off", &model.LogEntry{Message: "x"}) {
+		t.Error("Send to a disabled output succeeded")
+	}
+
+	// Reloading an unchanged output keeps it; a changed one is replaced after its queue drains.
+	siemBefore := f.outs["siem"]
+	archive.UpdatedAt = time.Unix(2, 0)
+	if err := d.Load([]model.Output{archive, siem, down}); err != nil {
+		t.Fatal(err)
+	}
+	if f.outs["siem"] != siemBefore {
+		t.Error("unchanged output recreated")
+	}
+	d.Close()
+
+	if got := f.outs["siem"].entries; len(got) != 2 || got[0].Message != "b" || got[1].Message != "c" {
+		t.Errorf("siem got %+v", got)
+	}
+	if !f.outs["siem"].closed {
+		t.Error("output not closed")
+	}
+	if _, ok := d.Status(archive.ID); ok {
+		t.Error("status after Close")
+	}
+	if d.Active() {
+		t.Error("active after Close")
+	}
+}
+
+func TestDispatcherStatus(t *testing.T) {
+	f := &memFactory{outs: map[string]*memOutput{}}
+	reg := NewRegistry()
+	reg.Register(f)
+	d := NewDispatcher(reg, nil)
+	ok, down := memDef("ok", true), memDef("down", true)
+	if err := d.Load([]model.Output{ok, down}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		d.Dispatch(&model.LogEntry{Message: "x"})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		okSt, _ := d.Status(ok.ID)
+		downSt, _ := d.Status(down.ID)
+		if okSt.Sent == 3 && downSt.Failed == 3 {
+			if okSt.LastSentAt == nil || downSt.LastError != "unavailable" || downSt.LastErrorAt == nil {
+				t.Errorf("status ok=%+v down=%+v", okSt, downSt)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status ok=%+v down=%+v", okSt, downSt)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	d.Close()
+}
+
+func TestDispatcherDispatchWait(t *testing.T) {
+	f := &memFactory{outs: map[string]*memOutput{}}
+	reg := NewRegistry()
+	reg.Register(f)
+	d := NewDispatcher(reg, func(e *model.LogEntry) []string {
+		if e.Service == "audit" {
+			return []string{"siem"}
+		}
+		return nil
+	})
+	if err := d.Load([]model.Output{memDef("archive", true), memDef("siem", false)}); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Running("siem") || d.Running("missing") {
+		t.Error("Running is wrong")
+	}
+	ctx := context.Background()
+	for _, e := range []model.LogEntry{{Service: "api", Message: "a"}, {Service: "audit", Message: "b"}} {
+		if err := d.DispatchWait(ctx, &e, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.DispatchWait(ctx, &e, []string{"siem"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Close()
+
+	if got := f.outs["archive"].entries; len(got) != 2 {
+		t.Errorf("archive got %+v", got)
+	}
+	if got := f.outs["siem"].entries; len(got) != 2 || got[0].Message != "b" || got[1].Message != "b" {
+		t.Errorf("siem got %+v", got)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := d.DispatchWait(canceled, &model.LogEntry{Message: "c"}, nil); err != nil {
+		t.Errorf("DispatchWait without outputs: %v", err)
+	}
+}
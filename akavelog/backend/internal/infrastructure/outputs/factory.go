@@ -0,0 +1,10 @@
+package outputs
+
+// Factory creates an Output from config.
+// Each output type (stdout, http, etc.) implements and registers a Factory.
+// ConfigSpec declares which configuration fields this output type needs.
+type Factory interface {
+	Name() string
+	ConfigSpec() OutputTypeInfo
+	Create(cfg Config) (Output, error)
+}
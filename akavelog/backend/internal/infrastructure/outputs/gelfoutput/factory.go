@@ -0,0 +1,81 @@
+package gelfoutput
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+)
+
+// Factory creates GELF TCP outputs. Registers as "gelf".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "gelf"
+}
+
+func (f *Factory) ConfigSpec() outputs.OutputTypeInfo {
+	return outputs.OutputTypeInfo{
+		Type:        "gelf",
+		Description: "Sends entries as GELF 1.1 over TCP, optionally with TLS, to a Graylog GELF TCP input, e.g. to double-write while migrating from Graylog.",
+		Fields: []outputs.ConfigField{
+			{Name: "address", Type: "string", Required: true, Description: "host:port of the Graylog GELF TCP input", Example: "graylog.internal:12201"},
+			{Name: "source", Type: "string", Required: false, Description: "GELF host field when an entry has no host tag (default: this server's hostname)", Example: "akavelog"},
+			{Name: "dial_timeout", Type: "string", Required: false, Description: "Timeout for connecting (default 5s)", Example: "5s"},
+			{Name: "tls", Type: "bool", Required: false, Description: "Connect with TLS (default false)", Example: "true"},
+			{Name: "tls_ca_file", Type: "string", Required: false, Description: "PEM CA bundle used to verify Graylog; implies tls", Example: "/etc/akavelog/graylog-ca.pem"},
+			{Name: "tls_cert_file", Type: "string", Required: false, Description: "PEM client certificate for mutual TLS; implies tls"},
+			{Name: "tls_key_file", Type: "string", Required: false, Description: "PEM client key for mutual TLS"},
+			{Name: "tls_insecure_skip_verify", Type: "bool", Required: false, Description: "Skip certificate verification (testing only); implies tls"},
+		},
+	}
+}
+
+func (f *Factory) Create(cfg outputs.Config) (outputs.Output, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewOutput(c)
+}
+
+func parseConfig(cfg outputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Address:     str("address"),
+		Source:      str("source"),
+		DialTimeout: 5 * time.Second,
+		CAFile:      str("tls_ca_file"),
+		CertFile:    str("tls_cert_file"),
+		KeyFile:     str("tls_key_file"),
+	}
+	if c.Address == "" {
+		return c, fmt.Errorf("address is required (e.g. graylog.internal:12201)")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return c, fmt.Errorf("address must be host:port (e.g. graylog.internal:12201)")
+	}
+	if c.Source == "" {
+		c.Source, _ = os.Hostname()
+	}
+	if v := str("dial_timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("dial_timeout must be a positive duration (e.g. 5s)")
+		}
+		c.DialTimeout = d
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return c, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	c.InsecureSkipVerify, _ = cfg.Bool("tls_insecure_skip_verify")
+	c.TLS, _ = cfg.Bool("tls")
+	c.TLS = c.TLS || c.CAFile != "" || c.CertFile != "" || c.InsecureSkipVerify
+	return c, nil
+}
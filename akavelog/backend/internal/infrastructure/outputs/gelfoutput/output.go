@@ -0,0 +1,176 @@
+package gelfoutput
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Config holds the parsed settings of a gelf output.
+type Config struct {
+	Address            string
+	Source             string
+	DialTimeout        time.Duration
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// syslogLevels maps akavelog levels onto the syslog severities GELF uses.
+var syslogLevels = map[string]int{"trace": 7, "debug": 7, "info": 6, "warn": 4, "error": 3, "fatal": 2}
+
+// invalidFieldChars matches what GELF does not allow in additional field names.
+var invalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// Output writes null-byte delimited GELF messages over one TCP connection, which is dialed
+// on first use and again after a write fails.
+type Output struct {
+	cfg    Config
+	tlsCfg *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewOutput creates a gelf output. TLS files are loaded here so bad paths fail on create; the
+// connection is made by the first Write.
+func NewOutput(cfg Config) (*Output, error) {
+	o := &Output{cfg: cfg}
+	if cfg.TLS {
+		tc, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		o.tlsCfg = tc
+	}
+	return o, nil
+}
+
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca_file contains no PEM certificates")
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func (o *Output) Write(ctx context.Context, entries []model.LogEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// A connection Graylog closed while idle only shows up on write, so a failed batch is
+	// sent once more on a fresh connection. Graylog may then see part of it twice.
+	err := o.send(ctx, entries)
+	if err != nil && ctx.Err() == nil {
+		err = o.send(ctx, entries)
+	}
+	return err
+}
+
+func (o *Output) send(ctx context.Context, entries []model.LogEntry) error {
+	if o.conn == nil {
+		d := &net.Dialer{Timeout: o.cfg.DialTimeout}
+		var conn net.Conn
+		var err error
+		if o.tlsCfg != nil {
+			conn, err = (&tls.Dialer{NetDialer: d, Config: o.tlsCfg}).DialContext(ctx, "tcp", o.cfg.Address)
+		} else {
+			conn, err = d.DialContext(ctx, "tcp", o.cfg.Address)
+		}
+		if err != nil {
+			return fmt.Errorf("connect %s: %w", o.cfg.Address, err)
+		}
+		o.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		o.conn.SetWriteDeadline(deadline)
+	}
+	w := bufio.NewWriter(o.conn)
+	for i := range entries {
+		b, err := json.Marshal(o.message(&entries[i]))
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+		w.WriteByte(0)
+	}
+	if err := w.Flush(); err != nil {
+		o.conn.Close()
+		o.conn = nil
+		return fmt.Errorf("write %s: %w", o.cfg.Address, err)
+	}
+	return nil
+}
+
+// message converts e to a GELF 1.1 message. Service, project and tags become additional
+// fields; a host tag becomes the GELF host.
+func (o *Output) message(e *model.LogEntry) map[string]any {
+	m := map[string]any{
+		"version":       "1.1",
+		"host":          o.cfg.Source,
+		"short_message": e.Message,
+		"_service":      e.Service,
+		"_level_name":   e.Level,
+	}
+	if m["short_message"] == "" {
+		m["short_message"] = "-" // GELF requires a non-empty short_message
+	}
+	if t, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+		m["timestamp"] = float64(t.UnixMicro()) / 1e6
+	}
+	if l, ok := syslogLevels[e.Level]; ok {
+		m["level"] = l
+	}
+	if e.ProjectID != "" {
+		m["_project_id"] = e.ProjectID
+	}
+	for k, v := range e.Tags {
+		if k == "host" && v != "" {
+			m["host"] = v
+			continue
+		}
+		k = invalidFieldChars.ReplaceAllString(k, "_")
+		if k == "id" { // _id is reserved by Graylog
+			k = "tag_id"
+		}
+		m["_"+k] = v
+	}
+	return m
+}
+
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conn == nil {
+		return nil
+	}
+	err := o.conn.Close()
+	o.conn = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package gelfoutput
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// listen accepts one connection at a time and sends each GELF message it reads to msgs.
+func listen(t *testing.T) (net.Listener, chan map[string]any) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := make(chan map[string]any, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for {
+				b, err := r.ReadBytes(0)
+				if err != nil {
+					conn.Close()
+					break
+				}
+				var m map[string]any
+				if err := json.Unmarshal(b[:len(b)-1], &m); err != nil {
+					t.Error(err)
+				}
+				msgs <- m
+			}
+		}
+	}()
+	return ln, msgs
+}
+
+func TestWriteSendsGELF(t *testing.T) {
+	ln, msgs := listen(t)
+	defer ln.Close()
+	c, err := parseConfig(outputs.Config{"address": ln.Addr().String(), "source": "akavelog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := NewOutput(c)
+	defer o.Close()
+	entries := []model.LogEntry{
+		{Timestamp: "2026-01-02T03:04:05.5Z", Service: "api", Level: "error", Message: "boom", Tags: map[string]string{"host": "web-1", "id": "7", "user.name": "ann"}},
+		{Timestamp: "2026-01-02T03:04:06Z", Service: "api", Level: "info", Message: "ok", ProjectID: "p1"},
+	}
+	if err := o.Write(context.Background(), entries); err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-msgs, <-msgs
+	if first["version"] != "1.1" || first["host"] != "web-1" || first["short_message"] != "boom" || first["level"] != 3.0 {
+		t.Errorf("first = %v", first)
+	}
+	if first["timestamp"] != 1767323045.5 || first["_service"] != "api" || first["_tag_id"] != "7" || first["_user.name"] != "ann" {
+		t.Errorf("first = %v", first)
+	}
+	if second["host"] != "akavelog" || second["_project_id"] != "p1" || second["level"] != 6.0 {
+		t.Errorf("second = %v", second)
+	}
+}
+
+func TestWriteReconnects(t *testing.T) {
+	ln, msgs := listen(t)
+	defer ln.Close()
+	c, _ := parseConfig(outputs.Config{"address": ln.Addr().String()})
+	o, _ := NewOutput(c)
+	defer o.Close()
+	batch := []model.LogEntry{{Service: "api", Level: "info", Message: "a"}}
+	if err := o.Write(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	<-msgs
+	o.conn.Close() // as if the peer had dropped the idle connection
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := o.Write(ctx, batch); err != nil {
+		t.Fatalf("write after lost connection: %v", err)
+	}
+	<-msgs
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, bad := range []outputs.Config{
+		{},
+		{"address": "graylog"},
+		{"address": "graylog:12201", "dial_timeout": "x"},
+		{"address": "graylog:12201", "tls_cert_file": "c.pem"},
+	} {
+		if _, err := parseConfig(bad); err == nil {
+			t.Errorf("parseConfig(%v) accepted", bad)
+		}
+	}
+	c, err := parseConfig(outputs.Config{"address": "graylog:12201", "tls_insecure_skip_verify": true})
+	if err != nil || !c.TLS {
+		t.Errorf("tls_insecure_skip_verify does not imply tls: %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,4 @@
+package outputs
+
+// GlobalRegistry is the default registry. Output implementations (e.g. stdoutoutput) register in init().
+var GlobalRegistry = NewRegistry()
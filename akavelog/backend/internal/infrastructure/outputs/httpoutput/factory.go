@@ -0,0 +1,106 @@
+package httpoutput
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+)
+
+// Factory creates HTTP forwarding outputs. Registers as "http".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "http"
+}
+
+func (f *Factory) ConfigSpec() outputs.OutputTypeInfo {
+	return outputs.OutputTypeInfo{
+		Type:        "http",
+		Description: "POSTs each batch as NDJSON to a URL, e.g. an internal webhook or a PagerDuty relay. Retries network errors, 429 and 5xx with exponential backoff and stops calling the URL for a while after repeated failures (circuit breaker).",
+		Fields: []outputs.ConfigField{
+			{Name: "url", Type: "string", Required: true, Description: "http or https URL to POST to", Example: "https://hooks.internal/logs"},
+			{Name: "headers", Type: "object", Required: false, Description: "Extra request headers, e.g. an Authorization token", Example: `{"Authorization": "Bearer <token>"}`},
+			{Name: "timeout", Type: "string", Required: false, Description: "Timeout of one request (default 10s)", Example: "10s"},
+			{Name: "max_retries", Type: "int", Required: false, Description: "Retries of a failed batch before it counts as failed (default 3)", Example: "3"},
+			{Name: "max_backoff", Type: "string", Required: false, Description: "Upper bound of the wait between retries, which starts at 500ms and doubles (default 30s)", Example: "30s"},
+			{Name: "compress", Type: "bool", Required: false, Description: "gzip the request body (default false)", Example: "true"},
+			{Name: "breaker_threshold", Type: "int", Required: false, Description: "Consecutive failed batches that open the circuit breaker (default 5)", Example: "5"},
+			{Name: "breaker_cooldown", Type: "string", Required: false, Description: "How long an open breaker fails batches without calling the URL before trying again (default 30s)", Example: "30s"},
+		},
+	}
+}
+
+func (f *Factory) Create(cfg outputs.Config) (outputs.Output, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewOutput(c), nil
+}
+
+func parseConfig(cfg outputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	dur := func(key string, def time.Duration) (time.Duration, error) {
+		v := str(key)
+		if v == "" {
+			return def, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("%s must be a positive duration (e.g. %s)", key, def)
+		}
+		return d, nil
+	}
+	c := Config{
+		URL:              str("url"),
+		Headers:          map[string]string{},
+		MaxRetries:       3,
+		BreakerThreshold: 5,
+	}
+	u, err := url.Parse(c.URL)
+	if c.URL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c, fmt.Errorf("url is required and must be an http or https URL")
+	}
+	if v, ok := cfg["headers"]; ok && v != nil {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return c, fmt.Errorf("headers must be an object of strings")
+		}
+		for k, hv := range m {
+			s, ok := hv.(string)
+			if !ok {
+				return c, fmt.Errorf("header %q must be a string", k)
+			}
+			c.Headers[k] = s
+		}
+	}
+	if c.Timeout, err = dur("timeout", 10*time.Second); err != nil {
+		return c, err
+	}
+	if c.MaxBackoff, err = dur("max_backoff", 30*time.Second); err != nil {
+		return c, err
+	}
+	if c.BreakerCooldown, err = dur("breaker_cooldown", 30*time.Second); err != nil {
+		return c, err
+	}
+	if n, ok := cfg.Int("max_retries"); ok {
+		if n < 0 {
+			return c, fmt.Errorf("max_retries must not be negative")
+		}
+		c.MaxRetries = n
+	}
+	if n, ok := cfg.Int("breaker_threshold"); ok {
+		if n < 1 {
+			return c, fmt.Errorf("breaker_threshold must be at least 1")
+		}
+		c.BreakerThreshold = n
+	}
+	c.Compress, _ = cfg.Bool("compress")
+	return c, nil
+}
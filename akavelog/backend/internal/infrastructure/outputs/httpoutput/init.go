@@ -0,0 +1,7 @@
+package httpoutput
+
+import "github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+
+func init() {
+	outputs.GlobalRegistry.Register(&Factory{})
+}
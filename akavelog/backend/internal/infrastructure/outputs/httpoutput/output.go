@@ -0,0 +1,159 @@
+package httpoutput
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const minBackoff = 500 * time.Millisecond
+
+// errBreakerOpen is returned while the circuit breaker is open.
+var errBreakerOpen = errors.New("circuit breaker open")
+
+// Config is the parsed configuration of an HTTP output.
+type Config struct {
+	URL              string
+	Headers          map[string]string
+	Timeout          time.Duration
+	MaxRetries       int
+	MaxBackoff       time.Duration
+	Compress         bool
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Output POSTs batches as NDJSON. After BreakerThreshold consecutive failed batches it opens
+// its circuit breaker and fails batches without a request until BreakerCooldown has passed;
+// the next batch is then tried once and closes the breaker again if it succeeds.
+type Output struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	failures  int // consecutive failed batches
+	openUntil time.Time
+}
+
+// NewOutput creates an HTTP output.
+func NewOutput(cfg Config) *Output {
+	return &Output{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (o *Output) Write(ctx context.Context, entries []model.LogEntry) error {
+	o.mu.Lock()
+	open := time.Now().Before(o.openUntil)
+	o.mu.Unlock()
+	if open {
+		return errBreakerOpen
+	}
+	body, err := o.encode(entries)
+	if err != nil {
+		return err
+	}
+	err = o.send(ctx, body)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err == nil {
+		o.failures = 0
+		return nil
+	}
+	o.failures++
+	if o.failures >= o.cfg.BreakerThreshold {
+		o.openUntil = time.Now().Add(o.cfg.BreakerCooldown)
+		err = fmt.Errorf("%w (circuit breaker open for %s)", err, o.cfg.BreakerCooldown)
+	}
+	return err
+}
+
+// send posts body, retrying network errors, 429 and 5xx with exponential backoff.
+func (o *Output) send(ctx context.Context, body []byte) error {
+	backoff := min(minBackoff, o.cfg.MaxBackoff)
+	for attempt := 0; ; attempt++ {
+		retry, err := o.post(ctx, body)
+		if err == nil || !retry || attempt >= o.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (gave up retrying: %v)", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, o.cfg.MaxBackoff)
+	}
+}
+
+// post makes one request. retry reports whether a failure is worth retrying.
+func (o *Output) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if o.cfg.Compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range o.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("POST %s: %s", o.cfg.URL, resp.Status)
+}
+
+func (o *Output) encode(entries []model.LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if o.cfg.Compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Stats reports the circuit breaker state.
+func (o *Output) Stats() map[string]any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	state := "closed"
+	if time.Now().Before(o.openUntil) {
+		state = "open"
+	} else if o.failures >= o.cfg.BreakerThreshold {
+		state = "half_open"
+	}
+	return map[string]any{"breaker": state, "consecutive_failures": o.failures}
+}
+
+func (o *Output) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,117 @@
+package httpoutput
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func newTestOutput(t *testing.T, url string, extra outputs.Config) *Output {
+	t.Helper()
+	cfg := outputs.Config{"url": url, "max_backoff": "1ms"}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOutput(c)
+}
+
+func TestWritePostsNDJSON(t *testing.T) {
+	var lines int
+	var auth, ctype string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ctype = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			lines++
+		}
+	}))
+	defer srv.Close()
+	o := newTestOutput(t, srv.URL, outputs.Config{"headers": map[string]any{"Authorization": "Bearer x"}})
+	entries := []model.LogEntry{{Service: "api", Message: "a"}, {Service: "api", Message: "b"}}
+	if err := o.Write(context.Background(), entries); err != nil {
+		t.Fatal(err)
+	}
+	if lines != 2 || auth != "Bearer x" || ctype != "application/x-ndjson" {
+		t.Errorf("lines=%d auth=%q content-type=%q", lines, auth, ctype)
+	}
+}
+
+func TestWriteRetries(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer srv.Close()
+	o := newTestOutput(t, srv.URL, nil)
+	if err := o.Write(context.Background(), []model.LogEntry{{Service: "api", Message: "a"}}); err != nil || calls.Load() != 2 {
+		t.Errorf("503 then 200: err=%v calls=%d", err, calls.Load())
+	}
+
+	calls.Store(0)
+	status = http.StatusBadRequest
+	if err := o.Write(context.Background(), []model.LogEntry{{Service: "api", Message: "a"}}); err == nil || calls.Load() != 1 {
+		t.Errorf("400 retried or accepted: err=%v calls=%d", err, calls.Load())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	o := newTestOutput(t, srv.URL, outputs.Config{"max_retries": 0, "breaker_threshold": 2, "breaker_cooldown": "1h"})
+	batch := []model.LogEntry{{Service: "api", Message: "a"}}
+	for i := 0; i < 2; i++ {
+		if err := o.Write(context.Background(), batch); err == nil {
+			t.Fatal("500 accepted")
+		}
+	}
+	if got := o.Stats()["breaker"]; got != "open" {
+		t.Fatalf("breaker = %v after 2 failures", got)
+	}
+	if err := o.Write(context.Background(), batch); !errors.Is(err, errBreakerOpen) || calls.Load() != 2 {
+		t.Errorf("open breaker: err=%v calls=%d", err, calls.Load())
+	}
+
+	o.mu.Lock()
+	o.openUntil = time.Now()
+	o.mu.Unlock()
+	if got := o.Stats()["breaker"]; got != "half_open" {
+		t.Errorf("breaker = %v after cooldown", got)
+	}
+	o.Write(context.Background(), batch)
+	if calls.Load() != 3 || o.Stats()["breaker"] != "open" {
+		t.Errorf("half-open attempt: calls=%d breaker=%v", calls.Load(), o.Stats()["breaker"])
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, bad := range []outputs.Config{
+		{},
+		{"url": "ftp://example.com"},
+		{"url": "http://example.com", "headers": "x"},
+		{"url": "http://example.com", "timeout": "soon"},
+		{"url": "http://example.com", "breaker_threshold": 0},
+	} {
+		if _, err := parseConfig(bad); err == nil {
+			t.Errorf("parseConfig(%v) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package outputs forwards entries to external systems in addition to the O3 batcher. It
+// mirrors package inputs: output types register a Factory in init(), and the Dispatcher runs
+// the configured outputs, each with its own queue so a slow destination never blocks ingest.
+package outputs
+
+import (
+	"context"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Output is the minimal interface implemented by all output types.
+type Output interface {
+	// Write delivers one batch. An error counts the whole batch as failed; outputs that can
+	// retry do so before returning.
+	Write(ctx context.Context, entries []model.LogEntry) error
+	// Close releases connections. Write is not called after Close.
+	Close() error
+}
+
+// StatsReporter is implemented by outputs with state of their own, such as a circuit breaker.
+// The values are reported as details in the output's status.
+type StatsReporter interface {
+	Stats() map[string]any
+}
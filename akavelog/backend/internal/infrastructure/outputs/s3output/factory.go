@@ -0,0 +1,112 @@
+package s3output
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/storage"
+)
+
+// Factory creates S3 replication outputs. Registers as "s3".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "s3"
+}
+
+func (f *Factory) ConfigSpec() outputs.OutputTypeInfo {
+	return outputs.OutputTypeInfo{
+		Type:        "s3",
+		Description: "Replicates entries to a second S3-compatible bucket (another region or provider) for disaster recovery. Writes the same gzipped JSON batch objects as the O3 batcher and keeps failed objects queued for retry.",
+		Fields: []outputs.ConfigField{
+			{Name: "endpoint", Type: "string", Required: true, Description: "S3-compatible endpoint URL", Example: "https://s3.eu-central-1.amazonaws.com"},
+			{Name: "bucket", Type: "string", Required: true, Description: "Bucket to write to; it must exist", Example: "akavelog-dr"},
+			{Name: "region", Type: "string", Required: false, Description: "Region (default us-east-1)", Example: "eu-central-1"},
+			{Name: "access_key", Type: "string", Required: true, Description: "Access key"},
+			{Name: "secret_key", Type: "string", Required: true, Description: "Secret key"},
+			{Name: "prefix", Type: "string", Required: false, Description: "Top-level key prefix (default logs)", Example: "logs"},
+			{Name: "project", Type: "string", Required: false, Description: "Project part of the key (default default)", Example: "default"},
+			{Name: "max_batch_size", Type: "int", Required: false, Description: "Entries per object (default 1000)", Example: "1000"},
+			{Name: "flush_interval", Type: "string", Required: false, Description: "Longest time entries wait before their object is written (default 30s)", Example: "30s"},
+			{Name: "max_pending_entries", Type: "int", Required: false, Description: "Entries kept waiting for replication; beyond this the oldest objects are dropped (default 100000)", Example: "100000"},
+		},
+	}
+}
+
+func (f *Factory) Create(cfg outputs.Config) (outputs.Output, error) {
+	c, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewO3Client(&config.O3Config{
+		Endpoint:  c.Endpoint,
+		Bucket:    c.Bucket,
+		Region:    c.Region,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewOutput(c, client), nil
+}
+
+func parseConfig(cfg outputs.Config) (Config, error) {
+	str := func(key string) string {
+		v, _ := cfg[key].(string)
+		return strings.TrimSpace(v)
+	}
+	c := Config{
+		Endpoint:          str("endpoint"),
+		Bucket:            str("bucket"),
+		Region:            str("region"),
+		AccessKey:         str("access_key"),
+		SecretKey:         str("secret_key"),
+		Prefix:            strings.Trim(str("prefix"), "/"),
+		Project:           str("project"),
+		MaxBatchSize:      1000,
+		FlushInterval:     30 * time.Second,
+		MaxPendingEntries: 100000,
+	}
+	u, err := url.Parse(c.Endpoint)
+	if c.Endpoint == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c, fmt.Errorf("endpoint is required and must be an http or https URL")
+	}
+	if c.Bucket == "" {
+		return c, fmt.Errorf("bucket is required")
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return c, fmt.Errorf("access_key and secret_key are required")
+	}
+	if c.Prefix == "" {
+		c.Prefix = "logs"
+	}
+	if path.Clean(c.Prefix) != c.Prefix || strings.HasPrefix(c.Prefix, "..") {
+		return c, fmt.Errorf("prefix must be a relative key prefix such as logs or dr/logs")
+	}
+	if n, ok := cfg.Int("max_batch_size"); ok {
+		if n < 1 {
+			return c, fmt.Errorf("max_batch_size must be at least 1")
+		}
+		c.MaxBatchSize = n
+	}
+	if v := str("flush_interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("flush_interval must be a positive duration (e.g. 30s)")
+		}
+		c.FlushInterval = d
+	}
+	if n, ok := cfg.Int("max_pending_entries"); ok {
+		if n < c.MaxBatchSize {
+			return c, fmt.Errorf("max_pending_entries must be at least max_batch_size")
+		}
+		c.MaxPendingEntries = n
+	}
+	return c, nil
+}
@@ -0,0 +1,232 @@
+package s3output
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+
+	putTimeout   = time.Minute
+	closeTimeout = 30 * time.Second
+)
+
+// Config holds the parsed settings of an s3 output.
+type Config struct {
+	Endpoint          string
+	Bucket            string
+	Region            string
+	AccessKey         string
+	SecretKey         string
+	Prefix            string
+	Project           string
+	MaxBatchSize      int
+	FlushInterval     time.Duration
+	MaxPendingEntries int
+}
+
+// putter is the part of storage.O3Client the output uses.
+type putter interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// object is a sealed batch waiting to be uploaded.
+type object struct {
+	key        string
+	data       []byte
+	count      int
+	receivedAt time.Time // when its oldest entry was handed to Write
+}
+
+// Output batches entries like the O3 batcher and uploads them from its own goroutine, so a
+// slow or unreachable bucket never fails Write. Objects that fail to upload stay queued and
+// are retried in order with exponential backoff.
+type Output struct {
+	cfg    Config
+	client putter
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu          sync.Mutex
+	batch       []model.LogEntry
+	batchSince  time.Time
+	pending     []object
+	pendingN    int // entries in batch and pending
+	replicated  int64
+	dropped     int64
+	lastKey     string
+	lastAt      time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// NewOutput creates an s3 output and starts its upload loop.
+func NewOutput(cfg Config, client putter) *Output {
+	o := &Output{cfg: cfg, client: client, stop: make(chan struct{}), done: make(chan struct{})}
+	go o.loop()
+	return o
+}
+
+// Write adds entries to the current batch. It only fails when the output is closed.
+func (o *Output) Write(_ context.Context, entries []model.LogEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range entries {
+		if len(o.batch) == 0 {
+			o.batchSince = time.Now()
+		}
+		o.batch = append(o.batch, e)
+		o.pendingN++
+		if len(o.batch) >= o.cfg.MaxBatchSize {
+			o.seal()
+		}
+	}
+	return nil
+}
+
+// seal turns the current batch into a pending object, dropping the oldest objects when
+// more than MaxPendingEntries are waiting. o.mu must be held.
+func (o *Output) seal() {
+	if len(o.batch) == 0 {
+		return
+	}
+	entries := o.batch
+	o.batch = nil
+	data, err := batcher.EncodeBatch(entries)
+	if err != nil {
+		o.pendingN -= len(entries)
+		o.dropped += int64(len(entries))
+		o.lastError, o.lastErrorAt = err.Error(), time.Now()
+		return
+	}
+	o.pending = append(o.pending, object{
+		key:        storage.KeyForBatchUnder(o.cfg.Prefix, o.cfg.Project, uuid.New().String(), ".json.gz"),
+		data:       data,
+		count:      len(entries),
+		receivedAt: o.batchSince,
+	})
+	for o.pendingN > o.cfg.MaxPendingEntries && len(o.pending) > 1 {
+		o.pendingN -= o.pending[0].count
+		o.dropped += int64(o.pending[0].count)
+		o.pending = o.pending[1:]
+	}
+}
+
+func (o *Output) loop() {
+	defer close(o.done)
+	tick := min(o.cfg.FlushInterval, time.Second)
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	backoff := minBackoff
+	var retryAt time.Time
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-t.C:
+		}
+		o.mu.Lock()
+		if len(o.batch) > 0 && time.Since(o.batchSince) >= o.cfg.FlushInterval {
+			o.seal()
+		}
+		o.mu.Unlock()
+		if time.Now().Before(retryAt) {
+			continue
+		}
+		if err := o.upload(context.Background()); err != nil {
+			retryAt = time.Now().Add(backoff)
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff, retryAt = minBackoff, time.Time{}
+	}
+}
+
+// upload puts the pending objects in order and stops at the first failure.
+func (o *Output) upload(ctx context.Context) error {
+	for {
+		o.mu.Lock()
+		if len(o.pending) == 0 {
+			o.mu.Unlock()
+			return nil
+		}
+		obj := o.pending[0]
+		o.mu.Unlock()
+
+		putCtx, cancel := context.WithTimeout(ctx, putTimeout)
+		err := o.client.PutObject(putCtx, obj.key, obj.data, "application/gzip")
+		cancel()
+
+		o.mu.Lock()
+		if err != nil {
+			o.lastError, o.lastErrorAt = err.Error(), time.Now()
+			o.mu.Unlock()
+			log.Printf("[s3output] put %s/%s: %v", o.cfg.Bucket, obj.key, err)
+			return err
+		}
+		// The object may have been dropped for space while it was uploading.
+		if len(o.pending) > 0 && o.pending[0].key == obj.key {
+			o.pending = o.pending[1:]
+			o.pendingN -= obj.count
+		}
+		o.replicated += int64(obj.count)
+		o.lastKey, o.lastAt = obj.key, time.Now()
+		o.mu.Unlock()
+	}
+}
+
+// Stats reports replication progress. lag_seconds is how long the oldest entry not yet
+// replicated has been waiting (0 when everything is replicated).
+func (o *Output) Stats() map[string]any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var oldest time.Time
+	if len(o.pending) > 0 {
+		oldest = o.pending[0].receivedAt
+	} else if len(o.batch) > 0 {
+		oldest = o.batchSince
+	}
+	lag := 0.0
+	if !oldest.IsZero() {
+		lag = time.Since(oldest).Seconds()
+	}
+	st := map[string]any{
+		"bucket":             o.cfg.Bucket,
+		"lag_seconds":        lag,
+		"pending_entries":    o.pendingN,
+		"pending_objects":    len(o.pending),
+		"replicated_entries": o.replicated,
+		"dropped_entries":    o.dropped,
+	}
+	if !o.lastAt.IsZero() {
+		st["last_replicated_at"] = o.lastAt
+		st["last_replicated_key"] = o.lastKey
+	}
+	if o.lastError != "" {
+		st["last_error"] = o.lastError
+		st["last_error_at"] = o.lastErrorAt
+	}
+	return st
+}
+
+// Close stops the upload loop and makes one last attempt to upload what is waiting.
+// Entries that still fail are lost.
+func (o *Output) Close() error {
+	close(o.stop)
+	<-o.done
+	o.mu.Lock()
+	o.seal()
+	o.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return o.upload(ctx)
+}
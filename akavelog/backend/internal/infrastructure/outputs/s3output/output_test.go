@@ -0,0 +1,140 @@
+package s3output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type fakeBucket struct {
+	mu      sync.Mutex
+	fail    bool
+	objects map[string][]byte
+}
+
+func (b *fakeBucket) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail {
+		return errors.New("unavailable")
+	}
+	b.objects[key] = data
+	return nil
+}
+
+func (b *fakeBucket) entries(t *testing.T) int {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for key, data := range b.objects {
+		if !strings.HasPrefix(key, "dr/default/") || !strings.HasSuffix(key, ".json.gz") {
+			t.Errorf("key %q", key)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []model.LogEntry
+		if err := json.NewDecoder(zr).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+		n += len(entries)
+	}
+	return n
+}
+
+func testConfig(t *testing.T, extra outputs.Config) Config {
+	t.Helper()
+	cfg := outputs.Config{"endpoint": "https://s3.example.com", "bucket": "dr", "access_key": "a", "secret_key": "s", "prefix": "dr", "max_batch_size": 2}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := parseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReplicatesBatches(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string][]byte{}}
+	o := NewOutput(testConfig(t, nil), bucket)
+	batch := []model.LogEntry{{Service: "api", Message: "a"}, {Service: "api", Message: "b"}, {Service: "api", Message: "c"}}
+	if err := o.Write(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := bucket.entries(t); n != 3 || len(bucket.objects) != 2 {
+		t.Errorf("replicated %d entries in %d objects, want 3 in 2", n, len(bucket.objects))
+	}
+	if st := o.Stats(); st["lag_seconds"] != 0.0 || st["replicated_entries"] != int64(3) {
+		t.Errorf("stats = %v", st)
+	}
+}
+
+func TestRetriesAndReportsLag(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string][]byte{}, fail: true}
+	o := NewOutput(testConfig(t, outputs.Config{"max_pending_entries": 4}), bucket)
+	defer o.Close()
+	for i := 0; i < 3; i++ {
+		o.Write(context.Background(), []model.LogEntry{{Service: "api", Message: "a"}, {Service: "api", Message: "b"}})
+	}
+	if err := o.upload(context.Background()); err == nil {
+		t.Fatal("upload to failing bucket succeeded")
+	}
+	st := o.Stats()
+	if st["pending_objects"] != 2 || st["dropped_entries"] != int64(2) || st["lag_seconds"].(float64) <= 0 || st["last_error"] != "unavailable" {
+		t.Errorf("stats while failing = %v", st)
+	}
+
+	bucket.mu.Lock()
+	bucket.fail = false
+	bucket.mu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for o.Stats()["pending_objects"] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("pending objects not retried")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := bucket.entries(t); n != 4 {
+		t.Errorf("replicated %d entries, want 4", n)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	base := outputs.Config{"endpoint": "https://s3.example.com", "bucket": "dr", "access_key": "a", "secret_key": "s"}
+	for key, v := range map[string]any{
+		"endpoint":            "s3.example.com",
+		"bucket":              "",
+		"secret_key":          "",
+		"prefix":              "../x",
+		"flush_interval":      "0s",
+		"max_pending_entries": 10,
+	} {
+		cfg := outputs.Config{}
+		for k, bv := range base {
+			cfg[k] = bv
+		}
+		cfg[key] = v
+		if _, err := parseConfig(cfg); err == nil {
+			t.Errorf("parseConfig with %s=%v accepted", key, v)
+		}
+	}
+	c, err := parseConfig(base)
+	if err != nil || c.Prefix != "logs" || c.MaxBatchSize != 1000 {
+		t.Errorf("defaults = %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,36 @@
+package stdoutoutput
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+)
+
+// Factory creates stdout outputs. Registers as "stdout".
+type Factory struct{}
+
+func (f *Factory) Name() string {
+	return "stdout"
+}
+
+func (f *Factory) ConfigSpec() outputs.OutputTypeInfo {
+	return outputs.OutputTypeInfo{
+		Type:        "stdout",
+		Description: "Writes entries as NDJSON to the server's standard output (or standard error), e.g. for a container log collector or debugging routing.",
+		Fields: []outputs.ConfigField{
+			{Name: "target", Type: "string", Required: false, Description: "stdout (default) or stderr", Example: "stdout"},
+		},
+	}
+}
+
+func (f *Factory) Create(cfg outputs.Config) (outputs.Output, error) {
+	target, _ := cfg["target"].(string)
+	switch target {
+	case "", "stdout":
+		return &Output{w: os.Stdout}, nil
+	case "stderr":
+		return &Output{w: os.Stderr}, nil
+	}
+	return nil, fmt.Errorf("target must be stdout or stderr")
+}
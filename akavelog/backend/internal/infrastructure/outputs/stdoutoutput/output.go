@@ -0,0 +1,34 @@
+package stdoutoutput
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// writeMu serializes batches of all stdout outputs, so their lines do not interleave.
+var writeMu sync.Mutex
+
+// Output writes one JSON object per line.
+type Output struct {
+	w io.Writer
+}
+
+func (o *Output) Write(_ context.Context, entries []model.LogEntry) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	bw := bufio.NewWriter(o.w)
+	enc := json.NewEncoder(bw)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (o *Output) Close() error { return nil }
@@ -0,0 +1,17 @@
+package outputs
+
+import "github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+
+// Config is an output's configuration, with the same helpers as input configuration.
+type Config = inputs.Config
+
+// ConfigField describes one configuration field, as for input types.
+type ConfigField = inputs.ConfigField
+
+// OutputTypeInfo describes an output type and the configuration it expects.
+// Returned by Factory.ConfigSpec() and exposed via GET /outputs/types and GET /outputs/types/:type.
+type OutputTypeInfo struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Fields      []ConfigField `json:"fields"`
+}
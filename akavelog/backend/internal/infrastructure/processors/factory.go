@@ -0,0 +1,25 @@
+package processors
+
+// Factory creates a Processor from config.
+// Each processor type implements and registers a Factory.
+// ConfigSpec declares the type's configuration fields and default stage.
+type Factory interface {
+	Name() string
+	ConfigSpec() ProcessorTypeInfo
+	Create(cfg Config) (Processor, error)
+}
+
+// NewFactory returns a Factory for info.Type that builds processors with create, for types
+// that need no state of their own.
+func NewFactory(info ProcessorTypeInfo, create func(cfg Config) (Processor, error)) Factory {
+	return &funcFactory{info: info, create: create}
+}
+
+type funcFactory struct {
+	info   ProcessorTypeInfo
+	create func(cfg Config) (Processor, error)
+}
+
+func (f *funcFactory) Name() string                         { return f.info.Type }
+func (f *funcFactory) ConfigSpec() ProcessorTypeInfo        { return f.info }
+func (f *funcFactory) Create(cfg Config) (Processor, error) { return f.create(cfg) }
@@ -0,0 +1,66 @@
+package processors
+
+import "github.com/akave-ai/akavelog/internal/model"
+
+// Field names that address LogEntry fields; any other name addresses a tag.
+const (
+	FieldID        = "_id"
+	FieldMessage   = "message"
+	FieldService   = "service"
+	FieldLevel     = "level"
+	FieldTimestamp = "timestamp"
+	FieldProjectID = "project_id"
+)
+
+// GetField returns the entry field or tag called name.
+func GetField(e *model.LogEntry, name string) (string, bool) {
+	switch name {
+	case FieldID:
+		return e.ID, e.ID != ""
+	case FieldMessage:
+		return e.Message, true
+	case FieldService:
+		return e.Service, true
+	case FieldLevel:
+		return e.Level, true
+	case FieldTimestamp:
+		return e.Timestamp, true
+	case FieldProjectID:
+		return e.ProjectID, true
+	}
+	v, ok := e.Tags[name]
+	return v, ok
+}
+
+// SetField sets the entry field or tag called name.
+func SetField(e *model.LogEntry, name, value string) {
+	switch name {
+	case FieldID:
+		e.ID = value
+	case FieldMessage:
+		e.Message = value
+	case FieldService:
+		e.Service = value
+	case FieldLevel:
+		e.Level = value
+	case FieldTimestamp:
+		e.Timestamp = value
+	case FieldProjectID:
+		e.ProjectID = value
+	default:
+		if e.Tags == nil {
+			e.Tags = make(map[string]string)
+		}
+		e.Tags[name] = value
+	}
+}
+
+// DeleteField removes the tag called name, or clears the entry field of that name.
+func DeleteField(e *model.LogEntry, name string) {
+	switch name {
+	case FieldID, FieldMessage, FieldService, FieldLevel, FieldTimestamp, FieldProjectID:
+		SetField(e, name, "")
+	default:
+		delete(e.Tags, name)
+	}
+}
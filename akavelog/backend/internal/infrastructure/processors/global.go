@@ -0,0 +1,4 @@
+package processors
+
+// GlobalRegistry is the default registry. Processor implementations register in init().
+var GlobalRegistry = NewRegistry()
@@ -0,0 +1,35 @@
+// Package processors defines the processor types that pipelines are built from and the
+// registry they are created through. Processor implementations (the built-ins in
+// internal/pipeline, or third-party packages) register a Factory in init(), the same way
+// input types register with inputs.GlobalRegistry.
+package processors
+
+import (
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Processor transforms one entry in place. It returns false to drop the entry; an error
+// leaves the entry as the processor got it and is reported by the pipeline.
+type Processor interface {
+	Process(e *model.LogEntry) (bool, error)
+}
+
+// ProcessorFunc adapts a function to Processor.
+type ProcessorFunc func(e *model.LogEntry) (bool, error)
+
+func (f ProcessorFunc) Process(e *model.LogEntry) (bool, error) { return f(e) }
+
+// StatsReporter is implemented by processors that keep counters, such as dry-run filters.
+// The counters are served by GET /pipelines/:id/stats.
+type StatsReporter interface {
+	Stats() map[string]any
+}
+
+// Emitter is implemented by processors that generate entries of their own, such as dedup
+// summaries. Emit returns the entries due at now; with final set it returns everything still
+// pending because the processor is being replaced or the server is stopping.
+type Emitter interface {
+	Emit(now time.Time, final bool) []model.LogEntry
+}
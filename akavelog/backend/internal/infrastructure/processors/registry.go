@@ -0,0 +1,88 @@
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry holds registered processor factories. Pipelines use it to build their processors.
+// Processor packages register their factory in init().
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry returns a new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+// Register adds a factory for a processor type. It panics on a duplicate name or an invalid
+// default stage, since both are programming errors in an init().
+func (r *Registry) Register(factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := factory.Name()
+	if _, exists := r.factories[name]; exists {
+		panic(fmt.Sprintf("processor type %q already registered", name))
+	}
+	if stage := factory.ConfigSpec().DefaultStage; !ValidStage(stage) {
+		panic(fmt.Sprintf("processor type %q: invalid default stage %q", name, stage))
+	}
+	r.factories[name] = factory
+}
+
+// Create builds a Processor for the given type and config.
+func (r *Registry) Create(name string, cfg Config) (Processor, error) {
+	factory, ok := r.factory(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown processor type %q", name)
+	}
+	cfg, err := cfg.Resolved()
+	if err != nil {
+		return nil, err
+	}
+	return factory.Create(cfg)
+}
+
+// ListRegistered returns all registered processor type names.
+func (r *Registry) ListRegistered() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	return names
+}
+
+// GetTypeInfo returns the config spec for the given processor type. ok is false if the type is not registered.
+func (r *Registry) GetTypeInfo(name string) (info ProcessorTypeInfo, ok bool) {
+	factory, ok := r.factory(name)
+	if !ok {
+		return ProcessorTypeInfo{}, false
+	}
+	return factory.ConfigSpec(), true
+}
+
+// AllTypesInfo returns config specs for all registered processor types, sorted by type.
+func (r *Registry) AllTypesInfo() []ProcessorTypeInfo {
+	r.mu.RLock()
+	out := make([]ProcessorTypeInfo, 0, len(r.factories))
+	for _, factory := range r.factories {
+		out = append(out, factory.ConfigSpec())
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+func (r *Registry) factory(name string) (Factory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.factories[name]
+	return f, ok
+}
@@ -0,0 +1,40 @@
+package processors
+
+import (
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Config is a processor's configuration, with the same helpers as input configuration.
+type Config = inputs.Config
+
+// ConfigField describes one configuration field, as for input types.
+type ConfigField = inputs.ConfigField
+
+// ProcessorTypeInfo describes a processor type and the configuration it expects.
+// Returned by Factory.ConfigSpec() and exposed via GET /processors/types.
+type ProcessorTypeInfo struct {
+	Type         string              `json:"type"`
+	Description  string              `json:"description"`
+	DefaultStage model.PipelineStage `json:"default_stage"`
+	Fields       []ConfigField       `json:"fields"`
+}
+
+// stageOrder is the execution order of stages.
+var stageOrder = map[model.PipelineStage]int{
+	model.PipelineStageParse:  0,
+	model.PipelineStageEnrich: 1,
+	model.PipelineStageFilter: 2,
+	model.PipelineStageRoute:  3,
+}
+
+// ValidStage reports whether s is one of the pipeline stages.
+func ValidStage(s model.PipelineStage) bool {
+	_, ok := stageOrder[s]
+	return ok
+}
+
+// StageIndex returns the position of s in the execution order (parse first, route last).
+func StageIndex(s model.PipelineStage) int {
+	return stageOrder[s]
+}
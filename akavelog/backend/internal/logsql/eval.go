@@ -0,0 +1,253 @@
+package logsql
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// row is what expressions are evaluated against: an entry and, for a group, the values of the
+// statement's aggregates.
+type row struct {
+	e    *model.LogEntry
+	aggs []Value
+}
+
+// field returns the entry field or tag name; NULL when the entry has no such tag. tags is
+// the map of all tags.
+func (r *row) field(name string) Value {
+	if name == "tags" {
+		return Value{kind: KindMap, m: r.e.Tags}
+	}
+	if v, ok := processors.GetField(r.e, name); ok {
+		return stringValue(v)
+	}
+	return null
+}
+
+func (x *expr) eval(r *row) Value {
+	switch x.kind {
+	case exprLit:
+		return x.val
+	case exprField:
+		return r.field(x.name)
+	case exprStar:
+		return boolValue(true)
+	case exprUnary:
+		v := x.args[0].eval(r)
+		if x.op == "-" {
+			n, ok := v.number()
+			if !ok {
+				return null
+			}
+			return numberValue(-n)
+		}
+		b, ok := truth(v)
+		if !ok {
+			return null
+		}
+		return boolValue(!b)
+	case exprBinary:
+		return x.binary(r)
+	case exprCall:
+		if fn := functions[x.name]; !fn.aggregate {
+			args := make([]Value, len(x.args))
+			for i, a := range x.args {
+				args[i] = a.eval(r)
+			}
+			return fn.call(x, args)
+		}
+		return r.aggs[x.agg]
+	case exprIn:
+		v := x.args[0].eval(r)
+		if v.IsNull() {
+			return null
+		}
+		sawNull := false
+		for _, a := range x.args[1:] {
+			c, ok := compare(v, a.eval(r))
+			if !ok {
+				sawNull = true
+			} else if c == 0 {
+				return boolValue(!x.not)
+			}
+		}
+		if sawNull {
+			return null
+		}
+		return boolValue(x.not)
+	case exprLike:
+		v := x.args[0].eval(r)
+		if v.IsNull() {
+			return null
+		}
+		return boolValue(x.re.MatchString(v.String()) != x.not)
+	case exprIsNull:
+		return boolValue(x.args[0].eval(r).IsNull() != x.not)
+	case exprBetween:
+		v := x.args[0].eval(r)
+		lo, okLo := compare(v, x.args[1].eval(r))
+		hi, okHi := compare(v, x.args[2].eval(r))
+		if !okLo || !okHi {
+			return null
+		}
+		return boolValue((lo >= 0 && hi <= 0) != x.not)
+	}
+	return null
+}
+
+func (x *expr) binary(r *row) Value {
+	switch x.op {
+	case "and", "or":
+		// Three-valued logic: FALSE AND NULL is FALSE, TRUE OR NULL is TRUE.
+		a, okA := truth(x.args[0].eval(r))
+		if okA && a == (x.op == "or") {
+			return boolValue(a)
+		}
+		b, okB := truth(x.args[1].eval(r))
+		if okB && b == (x.op == "or") {
+			return boolValue(b)
+		}
+		if !okA || !okB {
+			return null
+		}
+		return boolValue(x.op == "and")
+	case "+", "-", "*", "/", "%":
+		a, okA := x.args[0].eval(r).number()
+		b, okB := x.args[1].eval(r).number()
+		if !okA || !okB {
+			return null
+		}
+		switch x.op {
+		case "+":
+			return numberValue(a + b)
+		case "-":
+			return numberValue(a - b)
+		case "*":
+			return numberValue(a * b)
+		}
+		if b == 0 {
+			return null
+		}
+		if x.op == "%" {
+			return numberValue(math.Mod(a, b))
+		}
+		return numberValue(a / b)
+	}
+	c, ok := compare(x.args[0].eval(r), x.args[1].eval(r))
+	if !ok {
+		return null
+	}
+	switch x.op {
+	case "=":
+		return boolValue(c == 0)
+	case "!=":
+		return boolValue(c != 0)
+	case "<":
+		return boolValue(c < 0)
+	case "<=":
+		return boolValue(c <= 0)
+	case ">":
+		return boolValue(c > 0)
+	}
+	return boolValue(c >= 0)
+}
+
+// maxDistinct bounds the values count(DISTINCT x) remembers per group.
+const maxDistinct = 100000
+
+// accumulator computes an aggregate over the values added to it.
+type accumulator interface {
+	add(v Value) error
+	result() Value
+}
+
+func newAccumulator(x *expr) accumulator {
+	switch x.name {
+	case "count":
+		if x.distinct {
+			return &countDistinct{seen: make(map[string]struct{})}
+		}
+		return new(count)
+	case "sum":
+		return &sum{}
+	case "avg":
+		return &sum{avg: true}
+	case "min":
+		return &extreme{want: -1}
+	}
+	return &extreme{want: 1}
+}
+
+type count int
+
+func (c *count) add(v Value) error {
+	if !v.IsNull() {
+		*c++
+	}
+	return nil
+}
+
+func (c *count) result() Value { return numberValue(float64(*c)) }
+
+type countDistinct struct{ seen map[string]struct{} }
+
+func (c *countDistinct) add(v Value) error {
+	if v.IsNull() {
+		return nil
+	}
+	if len(c.seen) == maxDistinct {
+		if _, ok := c.seen[v.key()]; !ok {
+			return fmt.Errorf("count(DISTINCT ...) over more than %d values", maxDistinct)
+		}
+	}
+	c.seen[v.key()] = struct{}{}
+	return nil
+}
+
+func (c *countDistinct) result() Value { return numberValue(float64(len(c.seen))) }
+
+// sum adds up the numeric values; others are ignored. It is NULL without any.
+type sum struct {
+	total float64
+	n     int
+	avg   bool
+}
+
+func (s *sum) add(v Value) error {
+	if n, ok := v.number(); ok {
+		s.total += n
+		s.n++
+	}
+	return nil
+}
+
+func (s *sum) result() Value {
+	switch {
+	case s.n == 0:
+		return null
+	case s.avg:
+		return numberValue(s.total / float64(s.n))
+	}
+	return numberValue(s.total)
+}
+
+// extreme keeps the smallest (want -1) or largest (want 1) value.
+type extreme struct {
+	v    Value
+	want int
+}
+
+func (e *extreme) add(v Value) error {
+	if v.IsNull() {
+		return nil
+	}
+	if c, ok := compare(v, e.v); !ok || c == e.want {
+		e.v = v
+	}
+	return nil
+}
+
+func (e *extreme) result() Value { return e.v }
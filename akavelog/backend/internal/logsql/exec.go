@@ -0,0 +1,254 @@
+package logsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/search"
+)
+
+// Defaults and limits of Run.
+const (
+	DefaultRange      = 24 * time.Hour // without a start, the last day before the end is read
+	DefaultMaxRows    = 10000
+	DefaultMaxObjects = 10000
+	maxSortRows       = 200000 // rows ORDER BY buffers
+	maxGroups         = 100000
+)
+
+// ErrTooManyRows is returned when a statement needs more rows in memory than allowed.
+var ErrTooManyRows = errors.New("too many rows")
+
+// Options bound a run.
+type Options struct {
+	ProjectID  string    // only this project; WHERE project_id = '...' also narrows the scan
+	Start      time.Time // the scan reads the entries in [Start, End), narrowed by WHERE
+	End        time.Time // default now
+	MaxObjects int       // default DefaultMaxObjects
+	MaxBytes   int64     // of the objects read, as indexed; 0 for no limit
+	MaxRows    int       // result rows, default DefaultMaxRows
+	Progress   func(search.ScanStats)
+}
+
+// Result is the result of a statement.
+type Result struct {
+	Columns   []string         `json:"columns"`
+	Rows      [][]Value        `json:"rows"`
+	Truncated bool             `json:"truncated"` // rows were cut at MaxRows
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Stats     search.ScanStats `json:"stats"`
+}
+
+// outRow is a result row and its ORDER BY keys.
+type outRow struct {
+	values []Value
+	keys   []Value
+}
+
+// Run executes s over the entries the batches index lists for its time range.
+func (s *Statement) Run(ctx context.Context, index search.Index, store search.Store, opts Options) (*Result, error) {
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = DefaultMaxRows
+	}
+	if opts.MaxObjects <= 0 {
+		opts.MaxObjects = DefaultMaxObjects
+	}
+	scan := search.ScanOptions{
+		ProjectID:  opts.ProjectID,
+		Start:      opts.Start,
+		End:        opts.End,
+		MaxObjects: opts.MaxObjects,
+		MaxBytes:   opts.MaxBytes,
+		Progress:   opts.Progress,
+	}
+	if scan.ProjectID == "" {
+		scan.ProjectID = s.project
+	}
+	if !s.start.IsZero() && (scan.Start.IsZero() || s.start.After(scan.Start)) {
+		scan.Start = s.start
+	}
+	if !s.end.IsZero() && (scan.End.IsZero() || s.end.Before(scan.End)) {
+		scan.End = s.end
+	}
+	if scan.End.IsZero() {
+		scan.End = time.Now().UTC()
+	}
+	if scan.Start.IsZero() {
+		scan.Start = scan.End.Add(-DefaultRange)
+	}
+	res := &Result{Columns: s.Columns(), Rows: [][]Value{}, Start: scan.Start, End: scan.End}
+	if !scan.Start.Before(scan.End) {
+		return res, nil
+	}
+
+	var rows []outRow
+	var err error
+	if s.aggregate {
+		rows, res.Stats, err = s.runGroups(ctx, index, store, scan)
+	} else {
+		rows, res.Stats, err = s.runRows(ctx, index, store, scan, opts.MaxRows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.orderBy) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for k, it := range s.orderBy {
+				if c := sortCompare(rows[i].keys[k], rows[j].keys[k]); c != 0 {
+					return (c < 0) != it.desc
+				}
+			}
+			return false
+		})
+	}
+	if s.limit >= 0 && len(rows) > s.limit {
+		rows = rows[:s.limit]
+	}
+	if len(rows) > opts.MaxRows {
+		rows, res.Truncated = rows[:opts.MaxRows], true
+	}
+	for _, r := range rows {
+		res.Rows = append(res.Rows, r.values)
+	}
+	return res, nil
+}
+
+// output evaluates the SELECT items and ORDER BY keys for r.
+func (s *Statement) output(r *row) outRow {
+	out := outRow{values: make([]Value, len(s.items))}
+	for i, it := range s.items {
+		out.values[i] = it.x.eval(r)
+	}
+	if len(s.orderBy) > 0 {
+		out.keys = make([]Value, len(s.orderBy))
+		for i, it := range s.orderBy {
+			out.keys[i] = it.x.eval(r)
+		}
+	}
+	return out
+}
+
+// matches reports whether r passes the WHERE condition.
+func (s *Statement) matches(r *row) bool {
+	if s.where == nil {
+		return true
+	}
+	b, ok := truth(s.where.eval(r))
+	return ok && b
+}
+
+// runRows returns a row per matching entry. Without ORDER BY, it stops once it has enough.
+func (s *Statement) runRows(ctx context.Context, index search.Index, store search.Store, scan search.ScanOptions, maxRows int) ([]outRow, search.ScanStats, error) {
+	want := maxRows + 1 // one more tells that rows were cut
+	if s.limit >= 0 && s.limit <= maxRows {
+		want = s.limit
+	}
+	var rows []outRow
+	var err error
+	if want == 0 {
+		return rows, search.ScanStats{}, nil
+	}
+	st, scanErr := search.Scan(ctx, index, store, scan, func(e *model.LogEntry, _ time.Time) bool {
+		r := &row{e: e}
+		if !s.matches(r) {
+			return true
+		}
+		rows = append(rows, s.output(r))
+		if len(s.orderBy) == 0 {
+			return len(rows) < want
+		}
+		if len(rows) > maxSortRows {
+			err = fmt.Errorf("%w: ORDER BY over more than %d rows; narrow the time range or the WHERE condition", ErrTooManyRows, maxSortRows)
+			return false
+		}
+		return true
+	})
+	if scanErr != nil {
+		return nil, st, scanErr
+	}
+	return rows, st, err
+}
+
+// group is the state of one GROUP BY group.
+type group struct {
+	first model.LogEntry // evaluates the grouped expressions
+	accs  []accumulator
+}
+
+func (s *Statement) newGroup(e *model.LogEntry) *group {
+	g := &group{accs: make([]accumulator, len(s.aggs))}
+	if e != nil {
+		g.first = *e
+	}
+	for i, x := range s.aggs {
+		g.accs[i] = newAccumulator(x)
+	}
+	return g
+}
+
+// runGroups aggregates the matching entries into one row per group, in the order the groups
+// were first seen, and applies HAVING.
+func (s *Statement) runGroups(ctx context.Context, index search.Index, store search.Store, scan search.ScanOptions) ([]outRow, search.ScanStats, error) {
+	groups := make(map[string]*group)
+	var order []*group
+	if len(s.groupBy) == 0 {
+		// Aggregates without GROUP BY give one row, even over no entries.
+		g := s.newGroup(nil)
+		groups[""] = g
+		order = append(order, g)
+	}
+	var err error
+	keys := make([]string, len(s.groupBy))
+	st, scanErr := search.Scan(ctx, index, store, scan, func(e *model.LogEntry, _ time.Time) bool {
+		r := &row{e: e}
+		if !s.matches(r) {
+			return true
+		}
+		for i, x := range s.groupBy {
+			keys[i] = x.eval(r).key()
+		}
+		key := strings.Join(keys, "\x00")
+		g := groups[key]
+		if g == nil {
+			if len(groups) == maxGroups {
+				err = fmt.Errorf("%w: more than %d groups", ErrTooManyRows, maxGroups)
+				return false
+			}
+			g = s.newGroup(e)
+			groups[key] = g
+			order = append(order, g)
+		}
+		for i, x := range s.aggs {
+			if err = g.accs[i].add(x.args[0].eval(r)); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if scanErr != nil {
+		return nil, st, scanErr
+	}
+	if err != nil {
+		return nil, st, err
+	}
+	rows := make([]outRow, 0, len(order))
+	for _, g := range order {
+		r := &row{e: &g.first, aggs: make([]Value, len(g.accs))}
+		for i, a := range g.accs {
+			r.aggs[i] = a.result()
+		}
+		if s.having != nil {
+			if b, ok := truth(s.having.eval(r)); !ok || !b {
+				continue
+			}
+		}
+		rows = append(rows, s.output(r))
+	}
+	return rows, st, nil
+}
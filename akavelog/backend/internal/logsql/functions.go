@@ -0,0 +1,261 @@
+package logsql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// funcDef is a function statements may call. Only the functions in functions exist, so a
+// statement cannot reach anything but the entries it scans.
+type funcDef struct {
+	aggregate bool
+	minArgs   int
+	maxArgs   int                               // -1 for any number
+	check     func(x *expr) error               // validates literal arguments at parse time
+	call      func(x *expr, args []Value) Value // scalar functions
+}
+
+func (f funcDef) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+}
+
+var functions = map[string]funcDef{
+	// Aggregates; see newAccumulator.
+	"count": {aggregate: true, minArgs: 1, maxArgs: 1},
+	"sum":   {aggregate: true, minArgs: 1, maxArgs: 1},
+	"avg":   {aggregate: true, minArgs: 1, maxArgs: 1},
+	"min":   {aggregate: true, minArgs: 1, maxArgs: 1},
+	"max":   {aggregate: true, minArgs: 1, maxArgs: 1},
+
+	"lower":  {minArgs: 1, maxArgs: 1, call: stringFunc(strings.ToLower)},
+	"upper":  {minArgs: 1, maxArgs: 1, call: stringFunc(strings.ToUpper)},
+	"trim":   {minArgs: 1, maxArgs: 1, call: stringFunc(strings.TrimSpace)},
+	"length": {minArgs: 1, maxArgs: 1, call: fnLength},
+	"substr": {minArgs: 2, maxArgs: 3, call: fnSubstr},
+	"replace": {minArgs: 3, maxArgs: 3, call: func(_ *expr, a []Value) Value {
+		if a[0].IsNull() {
+			return null
+		}
+		return stringValue(strings.ReplaceAll(a[0].String(), a[1].String(), a[2].String()))
+	}},
+	"concat":   {minArgs: 1, maxArgs: -1, call: fnConcat},
+	"coalesce": {minArgs: 1, maxArgs: -1, call: fnCoalesce},
+	"regexp_like": {minArgs: 2, maxArgs: 2, check: checkRegexp, call: func(x *expr, a []Value) Value {
+		if a[0].IsNull() {
+			return null
+		}
+		return boolValue(x.re.MatchString(a[0].String()))
+	}},
+	"to_number": {minArgs: 1, maxArgs: 1, call: func(_ *expr, a []Value) Value {
+		if n, ok := a[0].number(); ok {
+			return numberValue(n)
+		}
+		return null
+	}},
+	"abs":         {minArgs: 1, maxArgs: 1, call: numberFunc(math.Abs)},
+	"floor":       {minArgs: 1, maxArgs: 1, call: numberFunc(math.Floor)},
+	"ceil":        {minArgs: 1, maxArgs: 1, call: numberFunc(math.Ceil)},
+	"round":       {minArgs: 1, maxArgs: 2, call: fnRound},
+	"date_trunc":  {minArgs: 2, maxArgs: 2, check: checkDateTrunc, call: fnDateTrunc},
+	"time_bucket": {minArgs: 2, maxArgs: 2, check: checkTimeBucket, call: fnTimeBucket},
+}
+
+func stringFunc(f func(string) string) func(*expr, []Value) Value {
+	return func(_ *expr, a []Value) Value {
+		if a[0].IsNull() {
+			return null
+		}
+		return stringValue(f(a[0].String()))
+	}
+}
+
+func numberFunc(f func(float64) float64) func(*expr, []Value) Value {
+	return func(_ *expr, a []Value) Value {
+		n, ok := a[0].number()
+		if !ok {
+			return null
+		}
+		return numberValue(f(n))
+	}
+}
+
+func fnLength(_ *expr, a []Value) Value {
+	if a[0].IsNull() {
+		return null
+	}
+	return numberValue(float64(utf8.RuneCountInString(a[0].String())))
+}
+
+// fnSubstr returns the characters of a[0] from position a[1] (1 for the first), a[2] of them
+// or all the rest.
+func fnSubstr(_ *expr, a []Value) Value {
+	if a[0].IsNull() {
+		return null
+	}
+	runes := []rune(a[0].String())
+	start, ok := a[1].number()
+	if !ok {
+		return null
+	}
+	from := max(int(start)-1, 0)
+	to := len(runes)
+	if len(a) == 3 {
+		n, ok := a[2].number()
+		if !ok {
+			return null
+		}
+		to = min(int(start)-1+int(n), len(runes))
+	}
+	if from >= to {
+		return stringValue("")
+	}
+	return stringValue(string(runes[from:to]))
+}
+
+func fnConcat(_ *expr, a []Value) Value {
+	var b strings.Builder
+	for _, v := range a {
+		b.WriteString(v.String())
+	}
+	return stringValue(b.String())
+}
+
+func fnCoalesce(_ *expr, a []Value) Value {
+	for _, v := range a {
+		if !v.IsNull() {
+			return v
+		}
+	}
+	return null
+}
+
+func fnRound(_ *expr, a []Value) Value {
+	n, ok := a[0].number()
+	if !ok {
+		return null
+	}
+	digits := 0.0
+	if len(a) == 2 {
+		if digits, ok = a[1].number(); !ok {
+			return null
+		}
+	}
+	scale := math.Pow(10, math.Trunc(digits))
+	return numberValue(math.Round(n*scale) / scale)
+}
+
+// stringLit returns the string literal argument i of x.
+func stringLit(x *expr, i int) (string, error) {
+	if a := x.args[i]; a.kind == exprLit && a.val.kind == KindString {
+		return a.val.s, nil
+	}
+	return "", fmt.Errorf("argument %d must be a string literal", i+1)
+}
+
+func checkRegexp(x *expr) error {
+	pattern, err := stringLit(x, 1)
+	if err != nil {
+		return err
+	}
+	if x.re, err = regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	return nil
+}
+
+var truncUnits = map[string]bool{"second": true, "minute": true, "hour": true, "day": true, "week": true, "month": true, "year": true}
+
+func checkDateTrunc(x *expr) error {
+	unit, err := stringLit(x, 0)
+	if err != nil {
+		return err
+	}
+	if !truncUnits[strings.ToLower(unit)] {
+		return errors.New("unit must be second, minute, hour, day, week, month or year")
+	}
+	return nil
+}
+
+// entryTime parses a timestamp argument.
+func entryTime(v Value) (time.Time, bool) {
+	if v.kind != KindString {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, v.s)
+	return t.UTC(), err == nil
+}
+
+// fnDateTrunc truncates the timestamp a[1] to the start of its a[0] (weeks start on Monday),
+// in UTC.
+func fnDateTrunc(_ *expr, a []Value) Value {
+	t, ok := entryTime(a[1])
+	if !ok {
+		return null
+	}
+	y, m, d := t.Date()
+	switch strings.ToLower(a[0].s) {
+	case "second":
+		t = t.Truncate(time.Second)
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "hour":
+		t = t.Truncate(time.Hour)
+	case "day":
+		t = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case "week":
+		t = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		t = time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return stringValue(t.Format(time.RFC3339))
+}
+
+// parseInterval parses a Go duration, also accepting whole days such as 1d.
+func parseInterval(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func checkTimeBucket(x *expr) error {
+	s, err := stringLit(x, 0)
+	if err != nil {
+		return err
+	}
+	if d, err := parseInterval(s); err != nil || d < time.Second {
+		return errors.New("interval must be a duration of at least 1s, such as 5m or 1d")
+	}
+	return nil
+}
+
+// fnTimeBucket returns the start of the a[0]-wide bucket, aligned in UTC, holding the
+// timestamp a[1].
+func fnTimeBucket(_ *expr, a []Value) Value {
+	t, ok := entryTime(a[1])
+	if !ok {
+		return null
+	}
+	d, _ := parseInterval(a[0].s)
+	return stringValue(t.Truncate(d).Format(time.RFC3339))
+}
@@ -0,0 +1,225 @@
+package logsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+)
+
+// ErrTooManyJobs is returned by Start when MaxRunning jobs are running.
+var ErrTooManyJobs = errors.New("too many running queries")
+
+// JobStatus is the state of a Job.
+type JobStatus string
+
+const (
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// JobsConfig bounds the jobs of a Jobs.
+type JobsConfig struct {
+	MaxRunning int           // jobs running at once (default 4)
+	Timeout    time.Duration // a job is canceled after this long (default 5m)
+	ResultTTL  time.Duration // finished jobs are kept this long (default 1h)
+}
+
+// Job is a statement running in the background. Its fields are read with Snapshot.
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	sql        string
+	status     JobStatus
+	err        error
+	result     *Result
+	progress   search.ScanStats
+	createdAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// JobSnapshot is the state of a Job at one point.
+type JobSnapshot struct {
+	ID         string           `json:"id"`
+	SQL        string           `json:"sql"`
+	Status     JobStatus        `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	Result     *Result          `json:"result,omitempty"`
+	Progress   search.ScanStats `json:"progress"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ID returns the job's ID.
+func (j *Job) ID() string { return j.id }
+
+// Done is closed when the job has finished.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Err returns why the job failed, or nil.
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// errText returns the message of j.err. j.mu is held.
+func (j *Job) errText() string {
+	if j.err == nil {
+		return ""
+	}
+	return j.err.Error()
+}
+
+// Snapshot returns the job's current state.
+func (j *Job) Snapshot() JobSnapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := JobSnapshot{
+		ID:        j.id,
+		SQL:       j.sql,
+		Status:    j.status,
+		Error:     j.errText(),
+		Result:    j.result,
+		Progress:  j.progress,
+		CreatedAt: j.createdAt,
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		s.FinishedAt = &t
+	}
+	return s
+}
+
+// Jobs runs statements in the background and keeps their results for a while, so long
+// queries can be polled by ID. It is safe for concurrent use.
+type Jobs struct {
+	cfg  JobsConfig
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobs returns an empty Jobs. Zero config fields take their defaults.
+func NewJobs(cfg JobsConfig) *Jobs {
+	if cfg.MaxRunning <= 0 {
+		cfg.MaxRunning = 4
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.ResultTTL <= 0 {
+		cfg.ResultTTL = time.Hour
+	}
+	return &Jobs{cfg: cfg, jobs: make(map[string]*Job)}
+}
+
+// Start runs s in the background with run, which is given the job's context and a function
+// reporting progress.
+func (js *Jobs) Start(s *Statement, run func(ctx context.Context, progress func(search.ScanStats)) (*Result, error)) (*Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.prune(time.Now())
+	running := 0
+	for _, j := range js.jobs {
+		if j.Snapshot().Status == JobRunning {
+			running++
+		}
+	}
+	if running >= js.cfg.MaxRunning {
+		return nil, ErrTooManyJobs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), js.cfg.Timeout)
+	j := &Job{
+		id:        uuid.NewString(),
+		sql:       s.String(),
+		status:    JobRunning,
+		createdAt: time.Now().UTC(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	js.jobs[j.id] = j
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		res, err := run(ctx, func(st search.ScanStats) {
+			j.mu.Lock()
+			j.progress = st
+			j.mu.Unlock()
+		})
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.finishedAt = time.Now().UTC()
+		switch {
+		case err == nil:
+			j.status, j.result, j.progress = JobDone, res, res.Stats
+		case j.status == JobCanceled:
+		case errors.Is(err, context.DeadlineExceeded):
+			j.status, j.err = JobFailed, fmt.Errorf("query timed out after %s: %w", js.cfg.Timeout, err)
+		default:
+			j.status, j.err = JobFailed, err
+		}
+	}()
+	return j, nil
+}
+
+// Get returns the job with the given ID, or nil.
+func (js *Jobs) Get(id string) *Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.prune(time.Now())
+	return js.jobs[id]
+}
+
+// Cancel stops a running job and forgets a finished one. It reports whether the job existed.
+func (js *Jobs) Cancel(id string) bool {
+	js.mu.Lock()
+	j := js.jobs[id]
+	if j == nil {
+		js.mu.Unlock()
+		return false
+	}
+	delete(js.jobs, id)
+	js.mu.Unlock()
+	j.mu.Lock()
+	if j.status == JobRunning {
+		j.status = JobCanceled
+	}
+	j.mu.Unlock()
+	j.cancel()
+	return true
+}
+
+// Close cancels every running job.
+func (js *Jobs) Close() {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	for id, j := range js.jobs {
+		j.mu.Lock()
+		if j.status == JobRunning {
+			j.status = JobCanceled
+		}
+		j.mu.Unlock()
+		j.cancel()
+		delete(js.jobs, id)
+	}
+}
+
+// prune forgets the jobs that finished more than ResultTTL ago. js.mu is held.
+func (js *Jobs) prune(now time.Time) {
+	for id, j := range js.jobs {
+		j.mu.Lock()
+		expired := !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > js.cfg.ResultTTL
+		j.mu.Unlock()
+		if expired {
+			delete(js.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package logsql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // identifiers as written; strings unquoted
+	pos    int
+	quoted bool // a double-quoted identifier, never a keyword
+}
+
+// SyntaxError reports where a statement failed to parse or is not allowed.
+type SyntaxError struct {
+	Pos int // byte offset in the source
+	Msg string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg) }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c == '.' || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// lex splits src into tokens. Identifiers may contain dots (tag names such as http.status) or
+// be double-quoted ("user-agent"); strings are single-quoted with ” for a quote.
+func lex(src string) ([]token, error) {
+	var out []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			out = append(out, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			j := i
+			for j < len(src) && (isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				j++
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				for j < len(src) && isDigit(src[j]) {
+					j++
+				}
+			}
+			out = append(out, token{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return nil, &SyntaxError{Pos: i, Msg: "unterminated quote"}
+				}
+				if src[j] == c {
+					if j+1 < len(src) && src[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			if c == '"' {
+				out = append(out, token{kind: tokIdent, text: b.String(), pos: i, quoted: true})
+			} else {
+				out = append(out, token{kind: tokString, text: b.String(), pos: i})
+			}
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range []string{"<=", ">=", "<>", "!=", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ";"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected %q", src[i:i+1])}
+			}
+			out = append(out, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(out, token{kind: tokEOF, pos: len(src)}), nil
+}
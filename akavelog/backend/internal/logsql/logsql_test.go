@@ -0,0 +1,257 @@
+package logsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/search"
+)
+
+// memIndex returns its batches and records the last filter.
+type memIndex struct {
+	batches []model.Batch
+	last    repository.BatchFilter
+}
+
+func (x *memIndex) Find(_ context.Context, f repository.BatchFilter) ([]model.Batch, error) {
+	x.last = f
+	return x.batches, nil
+}
+
+type memStore map[string][]model.LogEntry
+
+func (s memStore) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	return s[key], nil
+}
+
+func fixture() (*memIndex, memStore) {
+	e := func(ts, service, level, msg, duration string) model.LogEntry {
+		out := model.LogEntry{Timestamp: ts, ProjectID: "p1", Service: service, Level: level, Message: msg}
+		if duration != "" {
+			out.Tags = map[string]string{"duration": duration}
+		}
+		return out
+	}
+	return &memIndex{batches: []model.Batch{{Key: "a", Size: 10}, {Key: "b", Size: 10}}}, memStore{
+		"a": {
+			e("2026-03-01T10:00:00Z", "api", "error", "db timeout", "900"),
+			e("2026-03-01T10:20:00Z", "api", "info", "ok", "120"),
+			e("2026-03-01T11:05:00Z", "web", "error", "upstream timeout", "1500"),
+		},
+		"b": {
+			e("2026-03-01T11:30:00Z", "api", "error", "Timeout again", "80"),
+			e("2026-03-01T12:10:00Z", "worker", "warn", "slow job", ""),
+		},
+	}
+}
+
+func run(t *testing.T, sql string) *Result {
+	t.Helper()
+	s, err := Parse(sql)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", sql, err)
+	}
+	index, store := fixture()
+	res, err := s.Run(context.Background(), index, store, Options{
+		Start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Run(%q): %v", sql, err)
+	}
+	return res
+}
+
+// rows encodes the result rows as JSON, for comparison.
+func rows(t *testing.T, res *Result) string {
+	t.Helper()
+	data, err := json.Marshal(res.Rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		sql, columns, rows string
+	}{
+		{
+			`SELECT service, message FROM logs WHERE level = 'error' AND message ILIKE '%timeout%' ORDER BY timestamp DESC`,
+			"service,message",
+			`[["api","Timeout again"],["web","upstream timeout"],["api","db timeout"]]`,
+		},
+		{
+			`select service, count(*) as n, avg(duration) from logs group by service order by n desc, service limit 2`,
+			"service,n,avg(duration)",
+			`[["api",3,366.6666666666667],["web",1,1500]]`,
+		},
+		{
+			`SELECT time_bucket('1h', timestamp) AS hour, count(*) FROM logs GROUP BY 1 HAVING count(*) > 1 ORDER BY hour`,
+			"hour,count(*)",
+			`[["2026-03-01T10:00:00Z",2],["2026-03-01T11:00:00Z",2]]`,
+		},
+		{
+			`SELECT count(*), count(DISTINCT service), max(duration), min(level) FROM logs WHERE duration > 100`,
+			"count(*),count(DISTINCT service),max(duration),min(level)",
+			`[[3,2,"1500","error"]]`,
+		},
+		{
+			`SELECT upper(service), duration * 2 FROM logs WHERE service NOT IN ('api', 'web') OR duration IS NULL`,
+			"upper(service),(duration * 2)",
+			`[["WORKER",null]]`,
+		},
+		{
+			`SELECT count(*) FROM logs WHERE service = 'nope'`,
+			"count(*)",
+			`[[0]]`,
+		},
+	} {
+		res := run(t, tc.sql)
+		if got := strings.Join(res.Columns, ","); got != tc.columns {
+			t.Errorf("%s: columns %s, want %s", tc.sql, got, tc.columns)
+		}
+		if got := rows(t, res); got != tc.rows {
+			t.Errorf("%s: rows %s, want %s", tc.sql, got, tc.rows)
+		}
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	s, err := Parse(`SELECT * FROM logs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, store := fixture()
+	res, err := s.Run(context.Background(), index, store, Options{MaxRows: 2, End: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 2 || !res.Truncated || len(res.Columns) != 6 {
+		t.Errorf("rows %d, truncated %v, columns %v", len(res.Rows), res.Truncated, res.Columns)
+	}
+	if _, err := s.Run(context.Background(), index, store, Options{MaxBytes: 15}); !errors.Is(err, search.ErrScanLimit) {
+		t.Errorf("err = %v, want ErrScanLimit", err)
+	}
+}
+
+func TestPushdown(t *testing.T) {
+	s, err := Parse(`SELECT message FROM logs WHERE project_id = 'p1' AND timestamp >= '2026-03-01T11:00:00Z' AND timestamp < '2026-03-01T12:00:00Z'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, store := fixture()
+	res, err := s.Run(context.Background(), index, store, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := repository.BatchFilter{ProjectID: "p1", Start: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	if index.last != want {
+		t.Errorf("filter %+v, want %+v", index.last, want)
+	}
+	if got := rows(t, res); got != `[["upstream timeout"],["Timeout again"]]` {
+		t.Errorf("rows %s", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		sql string
+		pos int
+		msg string
+	}{
+		{`SELECT * FROM users`, 14, "unknown table"},
+		{`SELECT service, count(*) FROM logs`, 7, "must appear in GROUP BY"},
+		{`SELECT load_file('x') FROM logs`, 7, "not allowed"},
+		{`SELECT * FROM logs WHERE count(*) > 1`, 25, "not allowed in WHERE"},
+		{`SELECT sum(count(*)) FROM logs`, 11, "cannot be nested"},
+		{`SELECT * FROM logs WHERE message LIKE service`, 38, "string literal"},
+		{`SELECT * FROM logs WHERE message = 'x`, 35, "unterminated"},
+		{`SELECT * FROM logs LIMIT 10; DROP TABLE logs`, 29, "unexpected"},
+		{`SELECT * FROM logs ORDER BY 9`, 28, "not in the select list"},
+		{`SELECT date_trunc('fortnight', timestamp) FROM logs`, 7, "unit must be"},
+	} {
+		_, err := Parse(tc.sql)
+		var syn *SyntaxError
+		if !errors.As(err, &syn) {
+			t.Errorf("%s: err = %v, want a SyntaxError", tc.sql, err)
+			continue
+		}
+		if syn.Pos != tc.pos || !strings.Contains(syn.Msg, tc.msg) {
+			t.Errorf("%s: %v, want position %d and %q", tc.sql, err, tc.pos, tc.msg)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	where := func(n int, open, close string) string {
+		return "SELECT count(*) FROM logs WHERE " + strings.Repeat(open, n) + "level = 'error'" + strings.Repeat(close, n)
+	}
+	if _, err := Parse(where(50, "(", ")")); err != nil {
+		t.Errorf("50 parentheses: %v", err)
+	}
+	for _, tc := range []struct {
+		sql string
+		msg string
+	}{
+		{where(MaxDepth, "(", ")"), "nested more than"},
+		{where(MaxDepth, "NOT ", ""), "nested more than"},
+		{"SELECT " + strings.Repeat("- ", MaxDepth+1) + "1 FROM logs", "nested more than"},
+		{where(1e6, "(", ")"), "longer than"},
+	} {
+		var syn *SyntaxError
+		if _, err := Parse(tc.sql); !errors.As(err, &syn) || !strings.Contains(syn.Msg, tc.msg) {
+			t.Errorf("%.40s...: err = %v, want a SyntaxError with %q", tc.sql, err, tc.msg)
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	js := NewJobs(JobsConfig{MaxRunning: 1})
+	s, _ := Parse(`SELECT count(*) FROM logs`)
+	release := make(chan struct{})
+	j, err := js.Start(s, func(ctx context.Context, progress func(search.ScanStats)) (*Result, error) {
+		progress(search.ScanStats{Objects: 1})
+		select {
+		case <-release:
+			return &Result{Columns: []string{"count(*)"}}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.Start(s, nil); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("second job: err = %v, want ErrTooManyJobs", err)
+	}
+	close(release)
+	<-j.Done()
+	if snap := js.Get(j.ID()).Snapshot(); snap.Status != JobDone || snap.Result == nil || snap.FinishedAt == nil {
+		t.Errorf("snapshot %+v", snap)
+	}
+
+	j, err = js.Start(s, func(ctx context.Context, _ func(search.ScanStats)) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !js.Cancel(j.ID()) {
+		t.Fatal("Cancel: job not found")
+	}
+	<-j.Done()
+	if snap := j.Snapshot(); snap.Status != JobCanceled {
+		t.Errorf("canceled job: %+v", snap)
+	}
+	if js.Get(j.ID()) != nil {
+		t.Error("canceled job still listed")
+	}
+}
@@ -0,0 +1,858 @@
+package logsql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type exprKind uint8
+
+const (
+	exprLit exprKind = iota
+	exprField
+	exprStar // the argument of count(*)
+	exprUnary
+	exprBinary
+	exprCall
+	exprIn      // args[0] IN (args[1:])
+	exprLike    // args[0] LIKE args[1]
+	exprIsNull  // args[0] IS NULL
+	exprBetween // args[0] BETWEEN args[1] AND args[2]
+)
+
+// expr is a node of a parsed expression.
+type expr struct {
+	kind     exprKind
+	op       string // unary and binary operators, lowercase; like or ilike
+	val      Value  // literals
+	name     string // fields; functions, lowercase
+	args     []*expr
+	not      bool           // NOT IN, NOT LIKE, IS NOT NULL, NOT BETWEEN
+	distinct bool           // count(DISTINCT x)
+	re       *regexp.Regexp // LIKE and regexp_like patterns
+	agg      int            // aggregate calls: index into the row's aggregate values
+	pos      int
+}
+
+// String returns the canonical form of x, used to match SELECT items with GROUP BY
+// expressions and as the name of unnamed columns.
+func (x *expr) String() string {
+	switch x.kind {
+	case exprLit:
+		switch x.val.kind {
+		case KindString:
+			return "'" + strings.ReplaceAll(x.val.s, "'", "''") + "'"
+		case KindNull:
+			return "NULL"
+		}
+		return strings.ToUpper(x.val.String())
+	case exprField:
+		if !plainIdent(x.name) {
+			return `"` + strings.ReplaceAll(x.name, `"`, `""`) + `"`
+		}
+		return x.name
+	case exprStar:
+		return "*"
+	case exprUnary:
+		if x.op == "-" {
+			return "-" + x.args[0].String()
+		}
+		return "(NOT " + x.args[0].String() + ")"
+	case exprBinary:
+		op := x.op
+		if op == "and" || op == "or" {
+			op = strings.ToUpper(op)
+		}
+		return "(" + x.args[0].String() + " " + op + " " + x.args[1].String() + ")"
+	case exprCall:
+		args := make([]string, len(x.args))
+		for i, a := range x.args {
+			args[i] = a.String()
+		}
+		if x.distinct {
+			return x.name + "(DISTINCT " + strings.Join(args, ", ") + ")"
+		}
+		return x.name + "(" + strings.Join(args, ", ") + ")"
+	case exprIn:
+		items := make([]string, len(x.args)-1)
+		for i, a := range x.args[1:] {
+			items[i] = a.String()
+		}
+		return "(" + x.args[0].String() + notText(x.not) + " IN (" + strings.Join(items, ", ") + "))"
+	case exprLike:
+		return "(" + x.args[0].String() + notText(x.not) + " " + strings.ToUpper(x.op) + " " + x.args[1].String() + ")"
+	case exprIsNull:
+		if x.not {
+			return "(" + x.args[0].String() + " IS NOT NULL)"
+		}
+		return "(" + x.args[0].String() + " IS NULL)"
+	case exprBetween:
+		return "(" + x.args[0].String() + notText(x.not) + " BETWEEN " + x.args[1].String() + " AND " + x.args[2].String() + ")"
+	}
+	return "?"
+}
+
+func notText(not bool) string {
+	if not {
+		return " NOT"
+	}
+	return ""
+}
+
+// plainIdent reports whether name can be written without quotes.
+func plainIdent(name string) bool {
+	if name == "" || !isIdentStart(name[0]) || keywords[strings.ToUpper(name)] {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isIdentChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true, "HAVING": true,
+	"ORDER": true, "ASC": true, "DESC": true, "LIMIT": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "LIKE": true, "ILIKE": true, "IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+	"BETWEEN": true, "AS": true, "DISTINCT": true,
+}
+
+// Table is the only table statements select from: every entry in O3.
+const Table = "logs"
+
+// starColumns are the columns SELECT * returns.
+var starColumns = []string{"timestamp", "project_id", "service", "level", "message", "tags"}
+
+type selectItem struct {
+	x    *expr
+	name string // column name: the alias, field name or expression
+}
+
+type orderItem struct {
+	x    *expr
+	desc bool
+}
+
+// Statement is a parsed SELECT statement. It is immutable and safe for concurrent use.
+type Statement struct {
+	src       string
+	items     []selectItem
+	where     *expr
+	groupBy   []*expr
+	having    *expr
+	orderBy   []orderItem
+	limit     int // -1 for none
+	aggs      []*expr
+	aggregate bool // GROUP BY or aggregate functions: one row per group
+
+	// Pushed down from WHERE: the scan only reads objects of this range and project.
+	start, end time.Time
+	project    string
+}
+
+// String returns the source the statement was parsed from.
+func (s *Statement) String() string { return s.src }
+
+// Columns returns the names of the result columns.
+func (s *Statement) Columns() []string {
+	out := make([]string, len(s.items))
+	for i, it := range s.items {
+		out[i] = it.name
+	}
+	return out
+}
+
+// Parse parses a SELECT statement over the logs table:
+//
+//	SELECT items FROM logs [WHERE cond] [GROUP BY exprs] [HAVING cond]
+//	       [ORDER BY expr [ASC|DESC], ...] [LIMIT n]
+//
+// Only the functions in the whitelist may be called. Statements longer than MaxLength and
+// expressions nested deeper than MaxDepth are refused.
+func Parse(src string) (*Statement, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("statement longer than %d bytes", MaxLength)}
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	s, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	s.src = src
+	return s, nil
+}
+
+// Limits of Parse, so that a crafted statement cannot exhaust the stack of the recursive
+// parser.
+const (
+	MaxLength = 64 << 10 // bytes of a statement
+	MaxDepth  = 100      // nesting of parentheses, function calls, NOTs and signs
+)
+
+type parser struct {
+	toks   []token
+	i      int
+	depth  int // of nested expressions around i
+	aggs   []*expr
+	inAgg  bool
+	clause string // the clause being parsed, for errors about aggregates
+}
+
+// enter descends into a nested expression, refusing more than MaxDepth levels.
+func (p *parser) enter() error {
+	if p.depth++; p.depth > MaxDepth {
+		return p.errorf(p.peek(), "expression nested more than %d levels deep", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokEOF {
+		return p.errorf(t, "unexpected end of statement")
+	}
+	return p.errorf(t, "unexpected %q", t.text)
+}
+
+// isKeyword reports whether t is the keyword kw (uppercase).
+func isKeyword(t token, kw string) bool {
+	return t.kind == tokIdent && !t.quoted && strings.EqualFold(t.text, kw)
+}
+
+// keyword consumes the keyword kw when it comes next.
+func (p *parser) keyword(kw string) bool {
+	if isKeyword(p.peek(), kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return p.errorf(t, "expected %s", kw)
+		}
+		return p.errorf(t, "expected %s, got %q", kw, t.text)
+	}
+	return nil
+}
+
+func (p *parser) op(o string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == o {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(o string) error {
+	if !p.op(o) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return p.errorf(t, "expected %s", o)
+		}
+		return p.errorf(t, "expected %s, got %q", o, t.text)
+	}
+	return nil
+}
+
+func (p *parser) statement() (*Statement, error) {
+	s := &Statement{limit: -1}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	p.clause = "SELECT"
+	for {
+		if p.op("*") {
+			for _, f := range starColumns {
+				s.items = append(s.items, selectItem{x: &expr{kind: exprField, name: f}, name: f})
+			}
+		} else {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			it := selectItem{x: x, name: x.String()}
+			if x.kind == exprField {
+				it.name = x.name
+			}
+			if p.keyword("AS") || (p.peek().kind == tokIdent && !isKeyword(p.peek(), "FROM") && (p.peek().quoted || !keywords[strings.ToUpper(p.peek().text)])) {
+				t := p.next()
+				if t.kind != tokIdent || (!t.quoted && keywords[strings.ToUpper(t.text)]) {
+					return nil, p.errorf(t, "expected a column alias")
+				}
+				it.name = t.text
+			}
+			s.items = append(s.items, it)
+		}
+		if !p.op(",") {
+			break
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokIdent || !strings.EqualFold(t.text, Table) {
+		return nil, p.errorf(t, "unknown table %q; the only table is %s", t.text, Table)
+	}
+	if p.keyword("WHERE") {
+		p.clause = "WHERE"
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		s.where = x
+	}
+	if p.keyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		p.clause = "GROUP BY"
+		for {
+			x, err := p.columnRef(s, false)
+			if err != nil {
+				return nil, err
+			}
+			s.groupBy = append(s.groupBy, x)
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("HAVING") {
+		p.clause = "HAVING"
+		t := p.peek()
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if len(s.groupBy) == 0 && len(p.aggs) == 0 {
+			return nil, p.errorf(t, "HAVING needs GROUP BY or aggregate functions")
+		}
+		s.having = x
+	}
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		p.clause = "ORDER BY"
+		for {
+			x, err := p.columnRef(s, true)
+			if err != nil {
+				return nil, err
+			}
+			it := orderItem{x: x}
+			if p.keyword("DESC") {
+				it.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			s.orderBy = append(s.orderBy, it)
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, p.errorf(t, "LIMIT takes a non-negative integer")
+		}
+		s.limit = n
+	}
+	p.op(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	s.aggs = p.aggs
+	s.aggregate = len(s.groupBy) > 0 || len(s.aggs) > 0
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	s.pushdown()
+	return s, nil
+}
+
+// columnRef parses a GROUP BY or ORDER BY item: an ordinal of a SELECT item (1 for the first),
+// in ORDER BY also an alias, or else an expression.
+func (p *parser) columnRef(s *Statement, aliases bool) (*expr, error) {
+	t := p.peek()
+	if t.kind == tokNumber {
+		if n, err := strconv.Atoi(t.text); err == nil {
+			if n < 1 || n > len(s.items) {
+				return nil, p.errorf(t, "%s position %d is not in the select list", p.clause, n)
+			}
+			p.i++
+			return s.items[n-1].x, nil
+		}
+	}
+	if aliases && t.kind == tokIdent {
+		if after := p.toks[p.i+1]; after.kind == tokEOF || (after.kind == tokOp && (after.text == "," || after.text == ";")) ||
+			isKeyword(after, "ASC") || isKeyword(after, "DESC") || isKeyword(after, "LIMIT") {
+			for _, it := range s.items {
+				if it.name == t.text && (t.quoted || !keywords[strings.ToUpper(t.text)]) {
+					p.i++
+					return it.x, nil
+				}
+			}
+		}
+	}
+	return p.expr()
+}
+
+func (p *parser) expr() (*expr, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	return p.or()
+}
+
+func (p *parser) or() (*expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.keyword("OR") {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{kind: exprBinary, op: "or", args: []*expr{left, right}, pos: t.pos}
+	}
+}
+
+func (p *parser) and() (*expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.keyword("AND") {
+			return left, nil
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{kind: exprBinary, op: "and", args: []*expr{left, right}, pos: t.pos}
+	}
+}
+
+func (p *parser) not() (*expr, error) {
+	t := p.peek()
+	if p.keyword("NOT") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{kind: exprUnary, op: "not", args: []*expr{x}, pos: t.pos}, nil
+	}
+	return p.comparison()
+}
+
+var comparisons = map[string]string{"=": "=", "!=": "!=", "<>": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+func (p *parser) comparison() (*expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if op, ok := comparisons[t.text]; ok && t.kind == tokOp {
+		p.i++
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{kind: exprBinary, op: op, args: []*expr{left, right}, pos: t.pos}, nil
+	}
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &expr{kind: exprIsNull, not: not, args: []*expr{left}, pos: t.pos}, nil
+	}
+	not := p.keyword("NOT")
+	switch {
+	case p.keyword("IN"):
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		x := &expr{kind: exprIn, not: not, args: []*expr{left}, pos: t.pos}
+		for {
+			item, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			x.args = append(x.args, item)
+			if !p.op(",") {
+				break
+			}
+		}
+		return x, p.expectOp(")")
+	case isKeyword(p.peek(), "LIKE"), isKeyword(p.peek(), "ILIKE"):
+		op := strings.ToLower(p.next().text)
+		pt := p.next()
+		if pt.kind != tokString {
+			return nil, p.errorf(pt, "%s takes a string literal pattern", strings.ToUpper(op))
+		}
+		re, err := likePattern(pt.text, op == "ilike")
+		if err != nil {
+			return nil, p.errorf(pt, "invalid pattern: %v", err)
+		}
+		pattern := &expr{kind: exprLit, val: stringValue(pt.text), pos: pt.pos}
+		return &expr{kind: exprLike, op: op, not: not, re: re, args: []*expr{left, pattern}, pos: t.pos}, nil
+	case p.keyword("BETWEEN"):
+		lo, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		hi, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{kind: exprBetween, not: not, args: []*expr{left, lo, hi}, pos: t.pos}, nil
+	}
+	if not {
+		return nil, p.errorf(p.peek(), "expected IN, LIKE, ILIKE or BETWEEN after NOT")
+	}
+	return left, nil
+}
+
+// likePattern compiles a LIKE pattern: % matches any text, _ one character.
+func likePattern(pattern string, fold bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+func (p *parser) additive() (*expr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.op("+") && !p.op("-") {
+			return left, nil
+		}
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{kind: exprBinary, op: t.text, args: []*expr{left, right}, pos: t.pos}
+	}
+}
+
+func (p *parser) multiplicative() (*expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.op("*") && !p.op("/") && !p.op("%") {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{kind: exprBinary, op: t.text, args: []*expr{left, right}, pos: t.pos}
+	}
+}
+
+func (p *parser) unary() (*expr, error) {
+	t := p.peek()
+	if p.op("-") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind == exprLit && x.val.kind == KindNumber {
+			x.val.n, x.pos = -x.val.n, t.pos
+			return x, nil
+		}
+		return &expr{kind: exprUnary, op: "-", args: []*expr{x}, pos: t.pos}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (*expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+		return &expr{kind: exprLit, val: numberValue(n), pos: t.pos}, nil
+	case tokString:
+		return &expr{kind: exprLit, val: stringValue(t.text), pos: t.pos}, nil
+	case tokOp:
+		if t.text != "(" {
+			return nil, p.unexpected(t)
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expectOp(")")
+	case tokIdent:
+		if !t.quoted {
+			switch strings.ToUpper(t.text) {
+			case "NULL":
+				return &expr{kind: exprLit, val: null, pos: t.pos}, nil
+			case "TRUE", "FALSE":
+				return &expr{kind: exprLit, val: boolValue(strings.EqualFold(t.text, "true")), pos: t.pos}, nil
+			}
+			if keywords[strings.ToUpper(t.text)] {
+				return nil, p.unexpected(t)
+			}
+			if p.peek().kind == tokOp && p.peek().text == "(" {
+				return p.call(t)
+			}
+		}
+		return &expr{kind: exprField, name: t.text, pos: t.pos}, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+// call parses the arguments of the function named by t.
+func (p *parser) call(t token) (*expr, error) {
+	p.i++ // the (
+	name := strings.ToLower(t.text)
+	fn, ok := functions[name]
+	if !ok {
+		return nil, p.errorf(t, "function %s is not allowed", name)
+	}
+	x := &expr{kind: exprCall, name: name, pos: t.pos}
+	if fn.aggregate {
+		if p.inAgg {
+			return nil, p.errorf(t, "aggregate functions cannot be nested")
+		}
+		if p.clause == "WHERE" || p.clause == "GROUP BY" {
+			return nil, p.errorf(t, "aggregate functions are not allowed in %s", p.clause)
+		}
+		p.inAgg = true
+		defer func() { p.inAgg = false }()
+		x.distinct = p.keyword("DISTINCT")
+		if x.distinct && name != "count" {
+			return nil, p.errorf(t, "DISTINCT is only supported in count")
+		}
+	}
+	if !(p.peek().kind == tokOp && p.peek().text == ")") {
+		for {
+			if name == "count" && !x.distinct && p.op("*") {
+				x.args = append(x.args, &expr{kind: exprStar, pos: t.pos})
+			} else {
+				arg, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				x.args = append(x.args, arg)
+			}
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	if len(x.args) < fn.minArgs || (fn.maxArgs >= 0 && len(x.args) > fn.maxArgs) {
+		return nil, p.errorf(t, "%s takes %s", name, fn.arity())
+	}
+	if fn.check != nil {
+		if err := fn.check(x); err != nil {
+			return nil, p.errorf(t, "%s: %v", name, err)
+		}
+	}
+	if fn.aggregate {
+		x.agg = len(p.aggs)
+		p.aggs = append(p.aggs, x)
+	}
+	return x, nil
+}
+
+// check validates the use of columns: with GROUP BY or aggregates, every column outside an
+// aggregate function must be grouped by.
+func (s *Statement) check() error {
+	if !s.aggregate {
+		return nil
+	}
+	grouped := make(map[string]bool, len(s.groupBy))
+	for _, x := range s.groupBy {
+		grouped[x.String()] = true
+	}
+	var walk func(x *expr) error
+	walk = func(x *expr) error {
+		if grouped[x.String()] {
+			return nil
+		}
+		switch {
+		case x.kind == exprField:
+			return &SyntaxError{Pos: x.pos, Msg: fmt.Sprintf("column %s must appear in GROUP BY or be used in an aggregate function", x.name)}
+		case x.kind == exprCall && functions[x.name].aggregate:
+			return nil
+		}
+		for _, a := range x.args {
+			if err := walk(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, it := range s.items {
+		if err := walk(it.x); err != nil {
+			return err
+		}
+	}
+	if s.having != nil {
+		if err := walk(s.having); err != nil {
+			return err
+		}
+	}
+	for _, it := range s.orderBy {
+		if err := walk(it.x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushdown narrows the scan to the time range and project the top-level AND conditions of
+// WHERE require, such as timestamp >= '2024-05-01T00:00:00Z' AND project_id = 'web'.
+func (s *Statement) pushdown() {
+	var visit func(x *expr)
+	visit = func(x *expr) {
+		if x == nil {
+			return
+		}
+		if x.kind == exprBinary && x.op == "and" {
+			visit(x.args[0])
+			visit(x.args[1])
+			return
+		}
+		if x.kind == exprBetween && !x.not && isField(x.args[0], "timestamp") {
+			if lo, ok := timeLit(x.args[1]); ok {
+				s.narrow(">=", lo)
+			}
+			if hi, ok := timeLit(x.args[2]); ok {
+				s.narrow("<=", hi)
+			}
+			return
+		}
+		if x.kind != exprBinary {
+			return
+		}
+		field, lit, op := x.args[0], x.args[1], x.op
+		if field.kind != exprField {
+			field, lit, op = x.args[1], x.args[0], flip[op]
+		}
+		switch {
+		case isField(field, "timestamp"):
+			if t, ok := timeLit(lit); ok {
+				s.narrow(op, t)
+			}
+		case isField(field, "project_id") && op == "=" && lit.kind == exprLit && lit.val.kind == KindString:
+			s.project = lit.val.s
+		}
+	}
+	visit(s.where)
+}
+
+var flip = map[string]string{"=": "=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+func isField(x *expr, name string) bool { return x.kind == exprField && x.name == name }
+
+func timeLit(x *expr) (time.Time, bool) {
+	if x.kind != exprLit || x.val.kind != KindString {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, x.val.s)
+	return t, err == nil
+}
+
+// narrow intersects the pushed-down range with timestamp op t.
+func (s *Statement) narrow(op string, t time.Time) {
+	var lo, hi time.Time // hi is exclusive
+	switch op {
+	case ">", ">=":
+		lo = t
+	case "<":
+		hi = t
+	case "<=":
+		hi = t.Add(time.Nanosecond)
+	case "=":
+		lo, hi = t, t.Add(time.Nanosecond)
+	default:
+		return
+	}
+	if !lo.IsZero() && (s.start.IsZero() || lo.After(s.start)) {
+		s.start = lo
+	}
+	if !hi.IsZero() && (s.end.IsZero() || hi.Before(s.end)) {
+		s.end = hi
+	}
+}
@@ -0,0 +1,161 @@
+package logsql
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a Value.
+type Kind uint8
+
+const (
+	KindNull Kind = iota
+	KindString
+	KindNumber
+	KindBool
+	KindMap // the tags column
+)
+
+// Value is a SQL value. Entry fields are strings; they compare as numbers when both sides are
+// numeric and as times when both are RFC 3339 timestamps, as rule expressions do.
+type Value struct {
+	kind Kind
+	s    string
+	n    float64
+	b    bool
+	m    map[string]string
+}
+
+var null = Value{}
+
+func stringValue(s string) Value  { return Value{kind: KindString, s: s} }
+func numberValue(n float64) Value { return Value{kind: KindNumber, n: n} }
+func boolValue(b bool) Value      { return Value{kind: KindBool, b: b} }
+
+// Kind returns the type of v.
+func (v Value) Kind() Kind { return v.kind }
+
+// IsNull reports whether v is NULL.
+func (v Value) IsNull() bool { return v.kind == KindNull }
+
+// String returns v as text: numbers in their shortest form, NULL as "".
+func (v Value) String() string {
+	switch v.kind {
+	case KindString:
+		return v.s
+	case KindNumber:
+		return strconv.FormatFloat(v.n, 'f', -1, 64)
+	case KindBool:
+		return strconv.FormatBool(v.b)
+	case KindMap:
+		data, _ := json.Marshal(v.m)
+		return string(data)
+	}
+	return ""
+}
+
+// MarshalJSON encodes v as a JSON string, number, boolean, object or null.
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.kind {
+	case KindString:
+		return json.Marshal(v.s)
+	case KindNumber:
+		if math.IsNaN(v.n) || math.IsInf(v.n, 0) {
+			return []byte("null"), nil
+		}
+		return json.Marshal(v.n)
+	case KindBool:
+		return json.Marshal(v.b)
+	case KindMap:
+		if v.m == nil {
+			return []byte("{}"), nil
+		}
+		return json.Marshal(v.m)
+	}
+	return []byte("null"), nil
+}
+
+// number returns v as a number: numbers as they are, numeric strings parsed.
+func (v Value) number() (float64, bool) {
+	switch v.kind {
+	case KindNumber:
+		return v.n, true
+	case KindString:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.s), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// key identifies v for grouping and DISTINCT: equal values have equal keys.
+func (v Value) key() string {
+	return string('0'+rune(v.kind)) + v.String()
+}
+
+// compare orders a and b: -1, 0 or 1. ok is false when either is NULL.
+func compare(a, b Value) (int, bool) {
+	if a.kind == KindNull || b.kind == KindNull {
+		return 0, false
+	}
+	if x, okx := a.number(); okx {
+		if y, oky := b.number(); oky {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if a.kind == KindString && b.kind == KindString {
+		if x, err := time.Parse(time.RFC3339Nano, a.s); err == nil {
+			if y, err := time.Parse(time.RFC3339Nano, b.s); err == nil {
+				return x.Compare(y), true
+			}
+		}
+	}
+	if a.kind == KindBool && b.kind == KindBool {
+		switch {
+		case a.b == b.b:
+			return 0, true
+		case !a.b:
+			return -1, true
+		}
+		return 1, true
+	}
+	return strings.Compare(a.String(), b.String()), true
+}
+
+// sortCompare orders values for ORDER BY: NULLs first, then by compare.
+func sortCompare(a, b Value) int {
+	switch {
+	case a.kind == KindNull && b.kind == KindNull:
+		return 0
+	case a.kind == KindNull:
+		return -1
+	case b.kind == KindNull:
+		return 1
+	}
+	c, _ := compare(a, b)
+	return c
+}
+
+// truth returns whether v is TRUE; ok is false for NULL.
+func truth(v Value) (bool, bool) {
+	switch v.kind {
+	case KindNull:
+		return false, false
+	case KindBool:
+		return v.b, true
+	case KindNumber:
+		return v.n != 0, true
+	case KindString:
+		b, err := strconv.ParseBool(v.s)
+		return b && err == nil, true
+	}
+	return len(v.m) > 0, true
+}
@@ -0,0 +1,122 @@
+package lookup
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxRows caps the rows of one table so a bad query cannot exhaust memory.
+const MaxRows = 1_000_000
+
+// Querier runs the SQL of a Postgres-backed table.
+type Querier interface {
+	QueryTable(ctx context.Context, query, keyColumn string) (*Table, error)
+}
+
+// ParseCSV reads CSV with a header row. keyColumn names the column rows are keyed by;
+// later rows win over earlier ones with the same key.
+func ParseCSV(data, keyColumn string) (*Table, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("csv has no header row")
+		}
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	key, err := columnIndex(header, keyColumn)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{Columns: header, Rows: make(Rows)}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		if len(t.Rows) >= MaxRows {
+			return nil, fmt.Errorf("more than %d rows", MaxRows)
+		}
+		t.Rows[rec[key]] = rowValues(header, key, rec)
+	}
+	return t, nil
+}
+
+// PoolQuerier runs table queries on a Postgres pool inside read-only transactions.
+type PoolQuerier struct {
+	Pool *pgxpool.Pool
+}
+
+func (q PoolQuerier) QueryTable(ctx context.Context, query, keyColumn string) (*Table, error) {
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fields := rows.FieldDescriptions()
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.Name
+	}
+	key, err := columnIndex(header, keyColumn)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{Columns: header, Rows: make(Rows)}
+	for rows.Next() {
+		if len(t.Rows) >= MaxRows {
+			return nil, fmt.Errorf("more than %d rows", MaxRows)
+		}
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		rec := make([]string, len(values))
+		for i, v := range values {
+			rec[i] = inputs.StringifyValue(v)
+		}
+		t.Rows[rec[key]] = rowValues(header, key, rec)
+	}
+	return t, rows.Err()
+}
+
+func columnIndex(header []string, name string) (int, error) {
+	for i, h := range header {
+		if h == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("key column %q not in columns %v", name, header)
+}
+
+// rowValues maps every column except the key onto its value in rec.
+func rowValues(header []string, key int, rec []string) map[string]string {
+	row := make(map[string]string, len(header)-1)
+	for i, h := range header {
+		if i != key && i < len(rec) {
+			row[h] = rec[i]
+		}
+	}
+	return row
+}
@@ -0,0 +1,209 @@
+// Package lookup loads lookup tables (CSV or Postgres-backed) and caches them in memory
+// for the pipeline's lookup processor.
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// DefaultTTL is used for Postgres-backed tables without a positive ttl_seconds.
+const DefaultTTL = 5 * time.Minute
+
+// loadTimeout bounds one load of a table (definition read plus query).
+const loadTimeout = 30 * time.Second
+
+// failedRetry is how long a table that failed to load reports that error before it is retried,
+// so a missing table does not cost a database round trip per entry.
+const failedRetry = 30 * time.Second
+
+// Definitions reads persisted table definitions. repository.LookupTableRepository implements it.
+type Definitions interface {
+	GetByName(ctx context.Context, name string) (*model.LookupTable, error)
+}
+
+// Rows maps a key onto the other columns of its row.
+type Rows map[string]map[string]string
+
+// Table is one loaded table.
+type Table struct {
+	Name     string
+	Columns  []string
+	Rows     Rows
+	LoadedAt time.Time
+}
+
+// Status describes a cached table, as reported by the API.
+type Status struct {
+	Loaded    bool       `json:"loaded"`
+	Rows      int        `json:"rows"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type entry struct {
+	table      *Table
+	ttl        time.Duration // 0: never expires (CSV)
+	refreshing bool
+	lastErr    error
+	failedAt   time.Time // set while table is nil because the first load failed
+}
+
+// Store caches tables by name. The first lookup loads a table synchronously; once a
+// Postgres-backed table is older than its TTL, lookups keep serving the cached rows while
+// one background refresh runs.
+type Store struct {
+	defs  Definitions
+	query Querier // nil disables Postgres-backed tables
+
+	mu     sync.Mutex
+	tables map[string]*entry
+}
+
+// NewStore returns a Store reading definitions from defs. query runs the SQL of
+// Postgres-backed tables and may be nil.
+func NewStore(defs Definitions, query Querier) *Store {
+	return &Store{defs: defs, query: query, tables: make(map[string]*entry)}
+}
+
+// Lookup returns the row for key in table. ok is false when the key is not in the table;
+// err is set when the table does not exist or cannot be loaded.
+func (s *Store) Lookup(ctx context.Context, table, key string) (map[string]string, bool, error) {
+	t, err := s.table(ctx, table)
+	if err != nil {
+		return nil, false, err
+	}
+	row, ok := t.Rows[key]
+	return row, ok, nil
+}
+
+func (s *Store) table(ctx context.Context, name string) (*Table, error) {
+	s.mu.Lock()
+	e, ok := s.tables[name]
+	if ok && e.table != nil {
+		if e.ttl > 0 && time.Since(e.table.LoadedAt) > e.ttl && !e.refreshing {
+			e.refreshing = true
+			go s.refresh(name)
+		}
+		t := e.table
+		s.mu.Unlock()
+		return t, nil
+	}
+	if ok && time.Since(e.failedAt) < failedRetry {
+		err := e.lastErr
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	t, ttl, err := s.load(ctx, name)
+	if err != nil {
+		s.mu.Lock()
+		s.tables[name] = &entry{lastErr: err, failedAt: time.Now()}
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Lock()
+	s.tables[name] = &entry{table: t, ttl: ttl}
+	s.mu.Unlock()
+	return t, nil
+}
+
+func (s *Store) refresh(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	t, ttl, err := s.load(ctx, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.tables[name]
+	if !ok || e.table == nil {
+		return // invalidated meanwhile; the next lookup loads it fresh
+	}
+	e.refreshing = false
+	if err != nil {
+		log.Printf("[lookup] refresh %s: %v (keeping %d cached rows)", name, err, len(e.table.Rows))
+		e.lastErr = err
+		e.table.LoadedAt = time.Now() // retry after another TTL instead of on every lookup
+		return
+	}
+	e.table, e.ttl, e.lastErr = t, ttl, nil
+}
+
+func (s *Store) load(ctx context.Context, name string) (*Table, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+	def, err := s.defs.GetByName(ctx, name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("lookup table %q: %w", name, err)
+	}
+	if def == nil {
+		return nil, 0, fmt.Errorf("lookup table %q not found", name)
+	}
+	t, err := s.Load(ctx, *def)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ttl time.Duration
+	if def.Kind == model.LookupTablePostgres {
+		ttl = DefaultTTL
+		if def.TTLSeconds > 0 {
+			ttl = time.Duration(def.TTLSeconds) * time.Second
+		}
+	}
+	return t, ttl, nil
+}
+
+// Load reads the rows of def without caching them; the API uses it to validate definitions.
+func (s *Store) Load(ctx context.Context, def model.LookupTable) (*Table, error) {
+	var t *Table
+	var err error
+	switch def.Kind {
+	case model.LookupTableCSV:
+		t, err = ParseCSV(def.CSVData, def.KeyColumn)
+	case model.LookupTablePostgres:
+		if s.query == nil {
+			return nil, fmt.Errorf("lookup table %q: postgres tables are not available", def.Name)
+		}
+		t, err = s.query.QueryTable(ctx, def.Query, def.KeyColumn)
+	default:
+		return nil, fmt.Errorf("lookup table %q: unknown kind %q", def.Name, def.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup table %q: %w", def.Name, err)
+	}
+	t.Name = def.Name
+	t.LoadedAt = time.Now()
+	return t, nil
+}
+
+// Invalidate drops the cached copy of a table so the next lookup reloads its definition.
+func (s *Store) Invalidate(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tables, name)
+}
+
+// Status reports the cache state of a table without loading it.
+func (s *Store) Status(name string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.tables[name]
+	if !ok {
+		return Status{}
+	}
+	if e.table == nil {
+		return Status{LastError: e.lastErr.Error()}
+	}
+	st := Status{Loaded: true, Rows: len(e.table.Rows)}
+	at := e.table.LoadedAt
+	st.LoadedAt = &at
+	if e.lastErr != nil {
+		st.LastError = e.lastErr.Error()
+	}
+	return st
+}
@@ -0,0 +1,119 @@
+package lookup
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+type memDefs struct {
+	mu    sync.Mutex
+	defs  map[string]model.LookupTable
+	reads int
+}
+
+func (d *memDefs) GetByName(_ context.Context, name string) (*model.LookupTable, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reads++
+	t, ok := d.defs[name]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+type fakeQuerier struct {
+	mu   sync.Mutex
+	team string
+}
+
+func (q *fakeQuerier) QueryTable(_ context.Context, _, _ string) (*Table, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return &Table{Columns: []string{"host", "team"}, Rows: Rows{"web-1": {"team": q.team}}}, nil
+}
+
+func TestParseCSV(t *testing.T) {
+	tbl, err := ParseCSV("status, description,class\n200,OK,2xx\n503,\"Service Unavailable, retry\",5xx\n", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tbl.Rows["503"]; got["description"] != "Service Unavailable, retry" || got["class"] != "5xx" {
+		t.Errorf("row 503 = %v", got)
+	}
+	if _, ok := tbl.Rows["200"]["status"]; ok {
+		t.Error("key column copied into row")
+	}
+	for _, bad := range []string{"", "a,b\n1,2,3\n"} {
+		if _, err := ParseCSV(bad, "a"); err == nil {
+			t.Errorf("ParseCSV(%q) accepted", bad)
+		}
+	}
+	if _, err := ParseCSV("a,b\n", "c"); err == nil || !strings.Contains(err.Error(), "key column") {
+		t.Errorf("missing key column: %v", err)
+	}
+}
+
+func TestStoreCachesAndRefreshes(t *testing.T) {
+	defs := &memDefs{defs: map[string]model.LookupTable{
+		"codes": {Name: "codes", Kind: model.LookupTableCSV, KeyColumn: "code", CSVData: "code,text\n404,Not Found\n"},
+		"hosts": {Name: "hosts", Kind: model.LookupTablePostgres, KeyColumn: "host", Query: "SELECT", TTLSeconds: 1},
+	}}
+	q := &fakeQuerier{team: "edge"}
+	s := NewStore(defs, q)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		row, ok, err := s.Lookup(ctx, "codes", "404")
+		if err != nil || !ok || row["text"] != "Not Found" {
+			t.Fatalf("lookup = %v, %v, %v", row, ok, err)
+		}
+	}
+	if _, ok, _ := s.Lookup(ctx, "codes", "500"); ok {
+		t.Error("missing key found")
+	}
+	if defs.reads != 1 {
+		t.Errorf("definition read %d times, want 1", defs.reads)
+	}
+
+	if _, _, err := s.Lookup(ctx, "nope", "x"); err == nil {
+		t.Error("missing table did not fail")
+	}
+	s.Lookup(ctx, "nope", "x")
+	if defs.reads != 2 {
+		t.Errorf("failed table retried immediately (%d reads)", defs.reads)
+	}
+
+	row, _, _ := s.Lookup(ctx, "hosts", "web-1")
+	if row["team"] != "edge" {
+		t.Fatalf("hosts row = %v", row)
+	}
+	q.mu.Lock()
+	q.team = "core"
+	q.mu.Unlock()
+	s.mu.Lock()
+	s.tables["hosts"].table.LoadedAt = time.Now().Add(-2 * time.Second)
+	s.mu.Unlock()
+	s.Lookup(ctx, "hosts", "web-1") // serves the stale row and starts a refresh
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		row, _, _ = s.Lookup(ctx, "hosts", "web-1")
+		if row["team"] == "core" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("table not refreshed after its ttl")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Invalidate("codes")
+	if st := s.Status("codes"); st.Loaded {
+		t.Errorf("status after invalidate = %+v", st)
+	}
+}
@@ -0,0 +1,114 @@
+// Package metrics holds the Prometheus collectors akavelog reports about itself on /metrics:
+// ingest per input, the ingest queue, batcher flushes, O3 uploads, pipeline processors and the
+// HTTP API. They are registered with the default registry, which /metrics serves.
+package metrics
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "akavelog"
+
+var (
+	InputMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "input", Name: "messages_total",
+		Help: "Messages received by an input.",
+	}, []string{"input", "type"})
+	InputBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "input", Name: "bytes_total",
+		Help: "Bytes received by an input.",
+	}, []string{"input", "type"})
+	InputErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "input", Name: "errors_total",
+		Help: "Failed reads, decodes and requests of an input.",
+	}, []string{"input", "type"})
+	InputRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "input", Name: "rejected_total",
+		Help: "Requests and payloads of an input refused by a limit or the ingest queue.",
+	}, []string{"input", "type"})
+
+	BatcherFlushes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "batcher", Name: "flushes_total",
+		Help: "Batches sealed, by reason: size, interval or stop.",
+	}, []string{"reason"})
+	BatcherFlushEntries = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "batcher", Name: "flush_entries",
+		Help:    "Entries per sealed batch.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+	})
+	BatcherFlushDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "batcher", Name: "flush_duration_seconds",
+		Help:    "Time to encode and upload a sealed batch.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	})
+
+	O3UploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "o3", Name: "upload_bytes_total",
+		Help: "Bytes of batch objects uploaded to O3, including retries.",
+	})
+	O3Uploads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "o3", Name: "uploads_total",
+		Help: "Batch objects uploaded to O3, including retries.",
+	})
+	O3UploadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "o3", Name: "upload_errors_total",
+		Help: "Failed uploads of batch objects to O3.",
+	})
+	O3UploadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "o3", Name: "upload_duration_seconds",
+		Help:    "Time to upload one batch object to O3, successful or not.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	PipelineProcessorDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "pipeline", Name: "processor_duration_seconds",
+		Help:    "Time a processor spent on one entry, by stage and processor type.",
+		Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+	}, []string{"stage", "processor"})
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_total",
+		Help: "HTTP requests served, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "Time to serve an HTTP request, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		InputMessages, InputBytes, InputErrors, InputRejected,
+		BatcherFlushes, BatcherFlushEntries, BatcherFlushDuration,
+		O3UploadBytes, O3Uploads, O3UploadErrors, O3UploadDuration,
+		PipelineProcessorDuration,
+		HTTPRequests, HTTPRequestDuration,
+	)
+}
+
+// ForgetInput drops the series of a deleted input.
+func ForgetInput(id, typ string) {
+	for _, v := range []*prometheus.CounterVec{InputMessages, InputBytes, InputErrors, InputRejected} {
+		v.DeleteLabelValues(id, typ)
+	}
+}
+
+// RegisterGauge exports fn as a gauge read at every scrape. Registering a name twice is
+// logged and ignored.
+func RegisterGauge(subsystem, name, help string, fn func() float64) {
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help}, fn))
+}
+
+// RegisterCounter exports fn as a counter read at every scrape, for totals kept elsewhere.
+func RegisterCounter(subsystem, name, help string, fn func() float64) {
+	register(prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help}, fn))
+}
+
+func register(c prometheus.Collector) {
+	if err := prometheus.Register(c); err != nil {
+		log.Printf("[metrics] register: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDKey = "request_id"
+
+// validRequestID bounds the X-Request-ID values taken from callers, which end up in logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// AccessLog writes one structured line per request to logger: request ID, method, route,
+// status, latency, sizes, client and the principal the request was authenticated as.
+//
+// The request ID is the caller's X-Request-ID when it is a plausible one and a new UUID
+// otherwise. It is returned in the X-Request-ID response header, set on the request's span
+// and available to handlers through RequestIDFrom. 5xx responses are logged at error level,
+// 4xx at warn and the rest at info.
+func AccessLog(logger zerolog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID.MatchString(id) {
+				id = uuid.NewString()
+			}
+			c.Set(requestIDKey, id)
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			span := trace.SpanFromContext(req.Context())
+			span.SetAttributes(attribute.String("http.request_id", id))
+
+			// Let the error handler write the response now, so its status and size are logged.
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			status := res.Status
+			var ev *zerolog.Event
+			switch {
+			case status >= 500:
+				ev = logger.Error()
+			case status >= 400:
+				ev = logger.Warn()
+			default:
+				ev = logger.Info()
+			}
+			bytesIn, _ := strconv.ParseInt(req.Header.Get(echo.HeaderContentLength), 10, 64)
+			ev = ev.Str("request_id", id).
+				Str("method", req.Method).
+				Str("route", routeOf(c)).
+				Str("path", req.URL.Path).
+				Int("status", status).
+				Dur("latency_ms", time.Since(start)).
+				Int64("bytes_in", bytesIn).
+				Int64("bytes_out", res.Size).
+				Str("remote_ip", c.RealIP()).
+				Str("user_agent", req.UserAgent())
+			if p := PrincipalFrom(c); p != nil {
+				ev = ev.Str("principal", p.Name)
+				if p.UserID != uuid.Nil {
+					ev = ev.Str("user_id", p.UserID.String())
+				}
+				if p.KeyID != uuid.Nil {
+					ev = ev.Str("key_id", p.KeyID.String())
+				}
+			}
+			if sc := span.SpanContext(); sc.IsValid() {
+				ev = ev.Str("trace_id", sc.TraceID().String())
+			}
+			ev.Msg("request")
+			return nil
+		}
+	}
+}
+
+// RequestIDFrom returns the ID AccessLog gave the request, or "" without it.
+func RequestIDFrom(c echo.Context) string {
+	id, _ := c.Get(requestIDKey).(string)
+	return id
+}
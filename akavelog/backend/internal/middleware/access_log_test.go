@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	e := echo.New()
+	e.Use(AccessLog(zerolog.New(&out)))
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(principalKey, &Principal{Name: "ci-key", Scopes: []string{ScopeRead}})
+			return next(c)
+		}
+	})
+	e.GET("/inputs/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, RequestIDFrom(c))
+	})
+	e.GET("/fail", func(c echo.Context) error { return echo.NewHTTPError(http.StatusBadGateway) })
+
+	for _, tc := range []struct {
+		path, id  string
+		keepID    bool
+		status    int
+		level     string
+		wantRoute string
+	}{
+		{"/inputs/1", "abc-123", true, 200, "info", "/inputs/:id"},
+		{"/inputs/2", "", false, 200, "info", "/inputs/:id"},
+		{"/inputs/3", "bad id\nforged", false, 200, "info", "/inputs/:id"},
+		{"/fail", "", false, 502, "error", "/fail"},
+	} {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.id != "" {
+			req.Header.Set(echo.HeaderXRequestID, tc.id)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		if id == "" || (tc.keepID && id != tc.id) || (!tc.keepID && id == tc.id) {
+			t.Errorf("%s: X-Request-ID = %q (sent %q)", tc.path, id, tc.id)
+		}
+		if rec.Code == http.StatusOK && rec.Body.String() != id {
+			t.Errorf("%s: RequestIDFrom = %q, header %q", tc.path, rec.Body.String(), id)
+		}
+		var line struct {
+			Level     string `json:"level"`
+			RequestID string `json:"request_id"`
+			Route     string `json:"route"`
+			Status    int    `json:"status"`
+			BytesOut  int64  `json:"bytes_out"`
+			Principal string `json:"principal"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("%s: access log %q: %v", tc.path, out.String(), err)
+		}
+		if line.Level != tc.level || line.RequestID != id || line.Route != tc.wantRoute || line.Status != tc.status ||
+			line.BytesOut != int64(rec.Body.Len()) || line.Principal != "ci-key" {
+			t.Errorf("%s: access log = %+v", tc.path, line)
+		}
+	}
+}
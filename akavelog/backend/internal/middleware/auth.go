@@ -1 +1,347 @@
 package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Scopes of API keys. admin grants every scope and write grants read.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeIngest = "ingest"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes lists the valid scopes.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeIngest, ScopeAdmin}
+
+// ValidScope reports whether s is one of Scopes.
+func ValidScope(s string) bool {
+	for _, v := range Scopes {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPrefix starts every generated key, so leaked keys are easy to search for.
+const KeyPrefix = "akl_"
+
+// IngestKeyPrefix starts every ingest key of an input; they are not keys of the management
+// API.
+const IngestKeyPrefix = "aki_"
+
+// touchInterval is how often the last use of a key is recorded at most.
+const touchInterval = time.Minute
+
+// maxJSONBody bounds the request bodies checked for the project_id of project keys.
+const maxJSONBody = 32 << 20
+
+const principalKey = "auth.principal"
+
+var (
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrExpiredKey = errors.New("API key expired")
+)
+
+// GenerateKey returns a new random key, the prefix that identifies it and its hash.
+func GenerateKey() (key, prefix, hash string, err error) {
+	return generateKey(KeyPrefix)
+}
+
+// GenerateIngestKey returns a new random ingest key of an input, the prefix that identifies
+// it and its hash.
+func GenerateIngestKey() (key, prefix, hash string, err error) {
+	return generateKey(IngestKeyPrefix)
+}
+
+func generateKey(start string) (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = start + hex.EncodeToString(b)
+	return key, key[:len(start)+8], HashKey(key), nil
+}
+
+// HashKey returns the hex SHA-256 of key, as stored.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Principal is who a request was authenticated as: an API key or a logged-in user.
+type Principal struct {
+	KeyID     uuid.UUID // uuid.Nil for users and the configured admin key
+	UserID    uuid.UUID // uuid.Nil for API keys
+	Name      string    // key name or user email
+	Role      string    // user role; "" for API keys
+	Scopes    []string
+	ProjectID string // "" for every project
+}
+
+// Has reports whether p was granted scope.
+func (p *Principal) Has(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin || (s == ScopeWrite && scope == ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore looks keys up by hash (repository.APIKeyRepository).
+type KeyStore interface {
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	Touch(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+type cachedKey struct {
+	key     model.APIKey
+	fetched time.Time
+	touched time.Time
+}
+
+// Authenticator checks keys against a KeyStore. Keys found are cached for a while, so most
+// requests do not query the database; Forget drops the cache after keys change.
+type Authenticator struct {
+	store     KeyStore
+	adminHash string
+	ttl       time.Duration
+
+	users  UserStore // optional; see UseTokens
+	tokens *Tokens
+	oidc   *OIDC // optional; see UseOIDC
+
+	mu        sync.Mutex
+	cache     map[string]*cachedKey // by hash
+	userCache map[uuid.UUID]*cachedUser
+}
+
+// NewAuthenticator returns an Authenticator over store. adminKey, when set, is a key with
+// the admin scope that is not stored, for creating the first keys. Keys found in store are
+// trusted for cacheTTL (default 30s), which bounds how long a key deleted on another server
+// keeps working here.
+func NewAuthenticator(store KeyStore, adminKey string, cacheTTL time.Duration) *Authenticator {
+	if cacheTTL <= 0 {
+		cacheTTL = 30 * time.Second
+	}
+	a := &Authenticator{
+		store:     store,
+		ttl:       cacheTTL,
+		cache:     make(map[string]*cachedKey),
+		userCache: make(map[uuid.UUID]*cachedUser),
+	}
+	if adminKey != "" {
+		a.adminHash = HashKey(adminKey)
+	}
+	return a
+}
+
+// Authenticate returns the principal of key, or ErrInvalidKey or ErrExpiredKey. With
+// UseTokens and UseOIDC, key may also be a session token or a token of the identity
+// provider, refused with ErrInvalidToken.
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	if isToken(key) {
+		switch {
+		case a.tokens != nil && isSessionToken(key):
+			return a.authenticateToken(ctx, key)
+		case a.oidc != nil:
+			return a.authenticateOIDC(ctx, key)
+		case a.tokens != nil:
+			return nil, ErrInvalidToken
+		}
+	}
+	hash := HashKey(key)
+	if a.adminHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.adminHash)) == 1 {
+		return &Principal{Name: "admin", Scopes: []string{ScopeAdmin}}, nil
+	}
+	now := time.Now()
+	a.mu.Lock()
+	c := a.cache[hash]
+	a.mu.Unlock()
+	if c == nil || now.Sub(c.fetched) > a.ttl {
+		k, err := a.store.GetByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			a.mu.Lock()
+			delete(a.cache, hash)
+			a.mu.Unlock()
+			return nil, ErrInvalidKey
+		}
+		c = &cachedKey{key: *k, fetched: now}
+		if k.LastUsedAt != nil {
+			c.touched = *k.LastUsedAt
+		}
+		a.mu.Lock()
+		a.cache[hash] = c
+		a.mu.Unlock()
+	}
+	if c.key.ExpiresAt != nil && !now.Before(*c.key.ExpiresAt) {
+		return nil, ErrExpiredKey
+	}
+	a.mu.Lock()
+	touch := now.Sub(c.touched) >= touchInterval
+	if touch {
+		c.touched = now
+	}
+	a.mu.Unlock()
+	if touch {
+		if err := a.store.Touch(ctx, c.key.ID, now); err != nil {
+			log.Printf("[auth] record use of key %s: %v", c.key.Name, err)
+		}
+	}
+	p := &Principal{KeyID: c.key.ID, Name: c.key.Name, Scopes: c.key.Scopes}
+	if c.key.ProjectID != nil {
+		p.ProjectID = c.key.ProjectID.String()
+	}
+	return p, nil
+}
+
+// Forget drops every cached key and user, so changed and deleted ones take effect right
+// away. It does nothing on a nil Authenticator, as when authentication is off.
+func (a *Authenticator) Forget() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.cache = make(map[string]*cachedKey)
+	a.userCache = make(map[uuid.UUID]*cachedUser)
+	a.mu.Unlock()
+}
+
+// RequireAuth authenticates every request whose route scopeOf maps to a scope ("" for a
+// public route) and refuses it unless the key or user has that scope. The API key or session
+// token is read from the X-API-Key header or an Authorization Bearer token, or for event
+// streams the api_key query parameter. A key bound to a project may only name that project: a project_id query
+// parameter or top-level JSON body field naming another is refused, and a missing one is set
+// to the key's project.
+func RequireAuth(a *Authenticator, scopeOf func(echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scope := scopeOf(c)
+			if scope == "" {
+				return next(c)
+			}
+			key := requestKey(c.Request())
+			if key == "" {
+				return response.Error(c, http.StatusUnauthorized, "API key required", "send an API key in the X-API-Key header or an API key or session token as a Bearer token")
+			}
+			p, err := a.Authenticate(c.Request().Context(), key)
+			if errors.Is(err, ErrInvalidKey) || errors.Is(err, ErrExpiredKey) {
+				return response.Error(c, http.StatusUnauthorized, "invalid API key", err.Error())
+			}
+			if errors.Is(err, ErrInvalidToken) {
+				return response.Error(c, http.StatusUnauthorized, "invalid session token", "log in again at /auth/login")
+			}
+			if err != nil {
+				return response.InternalError(c, "authentication failed", "look up API key: "+err.Error())
+			}
+			if !p.Has(scope) {
+				return response.Error(c, http.StatusForbidden, "insufficient scope", "this request needs an API key or user role with the "+scope+" scope")
+			}
+			if p.ProjectID != "" {
+				if detail := confineToProject(c.Request(), p.ProjectID); detail != "" {
+					return response.Error(c, http.StatusForbidden, "project not allowed", detail)
+				}
+			}
+			c.Set(principalKey, p)
+			c.SetRequest(c.Request().WithContext(WithPrincipal(c.Request().Context(), p)))
+			return next(c)
+		}
+	}
+}
+
+// PrincipalFrom returns who the request was authenticated as, or nil for public routes and
+// when authentication is off.
+func PrincipalFrom(c echo.Context) *Principal {
+	p, _ := c.Get(principalKey).(*Principal)
+	return p
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal returns a copy of ctx that PrincipalFromContext answers p from.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFromContext is PrincipalFrom for the context of a request, for code that is not
+// given the echo.Context. It is nil for work the server does on its own.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalCtxKey{}).(*Principal)
+	return p
+}
+
+func requestKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	// Browsers cannot set headers on EventSource requests.
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return r.URL.Query().Get("api_key")
+	}
+	return ""
+}
+
+// confineToProject checks and fills in the project_id of r for a key bound to project. It
+// returns a detail for a 403 response when r names another project.
+func confineToProject(r *http.Request, project string) string {
+	q := r.URL.Query()
+	if v, ok := q["project_id"]; ok && !(len(v) == 1 && (v[0] == project || v[0] == "")) {
+		return "this API key may only access project " + project
+	}
+	q.Set("project_id", project)
+	r.URL.RawQuery = q.Encode()
+
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), echo.MIMEApplicationJSON) {
+		return ""
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxJSONBody))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+	var body map[string]json.RawMessage
+	if json.Unmarshal(raw, &body) != nil || body == nil {
+		return "" // not an object; the handler rejects it
+	}
+	if v, ok := body["project_id"]; ok {
+		var s string
+		if json.Unmarshal(v, &s) != nil || (s != project && s != "") {
+			return "this API key may only access project " + project
+		}
+	}
+	body["project_id"], _ = json.Marshal(project)
+	if raw, err = json.Marshal(body); err != nil {
+		return ""
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	r.ContentLength = int64(len(raw))
+	return ""
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type memKeys struct {
+	keys    map[string]model.APIKey // by hash
+	lookups int
+	touched int
+}
+
+func (m *memKeys) GetByHash(_ context.Context, hash string) (*model.APIKey, error) {
+	m.lookups++
+	k, ok := m.keys[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &k, nil
+}
+
+func (m *memKeys) Touch(context.Context, uuid.UUID, time.Time) error {
+	m.touched++
+	return nil
+}
+
+func (m *memKeys) add(t *testing.T, k model.APIKey) string {
+	t.Helper()
+	key, prefix, hash, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || !strings.HasPrefix(key, prefix) || hash != HashKey(key) || len(hash) != 64 {
+		t.Fatalf("GenerateKey() = %q, %q, %q", key, prefix, hash)
+	}
+	k.ID, k.Prefix, k.Hash = uuid.New(), prefix, hash
+	m.keys[hash] = k
+	return key
+}
+
+func TestPrincipalHas(t *testing.T) {
+	for _, tc := range []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{[]string{ScopeRead}, ScopeRead, true},
+		{[]string{ScopeRead}, ScopeWrite, false},
+		{[]string{ScopeWrite}, ScopeRead, true},
+		{[]string{ScopeWrite}, ScopeIngest, false},
+		{[]string{ScopeIngest}, ScopeRead, false},
+		{[]string{ScopeAdmin}, ScopeIngest, true},
+		{nil, ScopeRead, false},
+	} {
+		p := &Principal{Scopes: tc.scopes}
+		if got := p.Has(tc.scope); got != tc.want {
+			t.Errorf("%v.Has(%s) = %v", tc.scopes, tc.scope, got)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := &memKeys{keys: make(map[string]model.APIKey)}
+	past := time.Now().Add(-time.Hour)
+	reader := store.add(t, model.APIKey{Name: "reader", Scopes: []string{ScopeRead}})
+	expired := store.add(t, model.APIKey{Name: "old", Scopes: []string{ScopeRead}, ExpiresAt: &past})
+	a := NewAuthenticator(store, "bootstrap", time.Minute)
+	ctx := context.Background()
+
+	p, err := a.Authenticate(ctx, reader)
+	if err != nil || p.Name != "reader" || !p.Has(ScopeRead) {
+		t.Fatalf("reader: %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate(ctx, reader); err != nil || store.lookups != 1 || store.touched != 1 {
+		t.Errorf("cached key: %v, %d lookups, %d touches", err, store.lookups, store.touched)
+	}
+	if _, err := a.Authenticate(ctx, expired); !errors.Is(err, ErrExpiredKey) {
+		t.Errorf("expired key: %v", err)
+	}
+	if _, err := a.Authenticate(ctx, "akl_nope"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("unknown key: %v", err)
+	}
+	if p, err := a.Authenticate(ctx, "bootstrap"); err != nil || !p.Has(ScopeAdmin) {
+		t.Errorf("admin key: %+v, %v", p, err)
+	}
+
+	// A deleted key works until the cache is dropped.
+	delete(store.keys, HashKey(reader))
+	a.Forget()
+	if _, err := a.Authenticate(ctx, reader); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("deleted key: %v", err)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	store := &memKeys{keys: make(map[string]model.APIKey)}
+	project := uuid.New()
+	reader := store.add(t, model.APIKey{Name: "reader", Scopes: []string{ScopeRead}})
+	tenant := store.add(t, model.APIKey{Name: "tenant", Scopes: []string{ScopeWrite}, ProjectID: &project})
+
+	e := echo.New()
+	e.Use(RequireAuth(NewAuthenticator(store, "", 0), func(c echo.Context) string {
+		if c.Path() == "/public" {
+			return ""
+		}
+		if c.Request().Method == http.MethodGet {
+			return ScopeRead
+		}
+		return ScopeWrite
+	}))
+	e.GET("/public", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+	e.GET("/things", func(c echo.Context) error {
+		return c.String(http.StatusOK, PrincipalFrom(c).Name+" "+c.QueryParam("project_id"))
+	})
+	e.GET("/whoami", func(c echo.Context) error {
+		p := PrincipalFromContext(c.Request().Context())
+		return c.String(http.StatusOK, p.Name+" "+p.ProjectID)
+	})
+	e.POST("/things", func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		return c.String(http.StatusOK, string(body))
+	})
+
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/public", ""); rec.Code != http.StatusOK {
+		t.Errorf("public route: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/things", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no key: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/things", "", "X-API-Key", "akl_wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/things", "", "Authorization", "Bearer "+reader); rec.Code != http.StatusOK || rec.Body.String() != "reader " {
+		t.Errorf("bearer key: %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/things", "{}", "X-API-Key", reader); rec.Code != http.StatusForbidden {
+		t.Errorf("read key writing: %d", rec.Code)
+	}
+
+	// A project key is confined to its project.
+	if rec := do(http.MethodGet, "/things", "", "X-API-Key", tenant); rec.Body.String() != "tenant "+project.String() {
+		t.Errorf("project key query: %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/whoami", "", "X-API-Key", tenant); rec.Body.String() != "tenant "+project.String() {
+		t.Errorf("principal in the request context: %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/things?project_id=other", "", "X-API-Key", tenant); rec.Code != http.StatusForbidden {
+		t.Errorf("project key naming another project: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/things", `{"project_id":"other"}`, "X-API-Key", tenant); rec.Code != http.StatusForbidden {
+		t.Errorf("project key body naming another project: %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/things", `{"name":"x"}`, "X-API-Key", tenant)
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["project_id"] != project.String() || body["name"] != "x" {
+		t.Errorf("project key body: %d %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+// tokenIssuer is the iss claim of session tokens.
+const tokenIssuer = "akavelog"
+
+// tokenHeader is the encoded JOSE header of every session token: HS256.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var ErrInvalidToken = errors.New("invalid or expired session token")
+
+// RoleScopes returns the scopes a user role grants: admin every scope, operator changes and
+// ingestion, viewer reads only. An unknown role grants none.
+func RoleScopes(role string) []string {
+	switch role {
+	case model.RoleAdmin:
+		return []string{ScopeAdmin}
+	case model.RoleOperator:
+		return []string{ScopeWrite, ScopeIngest}
+	case model.RoleViewer:
+		return []string{ScopeRead}
+	}
+	return nil
+}
+
+// Claims are the claims of a session token.
+type Claims struct {
+	Subject   string `json:"sub"` // user id
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Tokens issues and verifies the session tokens of users: JWTs signed with HMAC-SHA256.
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokens returns Tokens signing with secret whose tokens are valid for ttl (default 12h).
+func NewTokens(secret []byte, ttl time.Duration) *Tokens {
+	if ttl <= 0 {
+		ttl = 12 * time.Hour
+	}
+	return &Tokens{secret: secret, ttl: ttl}
+}
+
+// Issue returns a session token for u and when it expires.
+func (t *Tokens) Issue(u model.User) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(t.ttl)
+	payload, err := json.Marshal(Claims{
+		Subject:   u.ID.String(),
+		Email:     u.Email,
+		Role:      u.Role,
+		Issuer:    tokenIssuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: exp.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signing := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signing + "." + t.sign(signing), exp.Truncate(time.Second), nil
+}
+
+// Parse verifies token and returns its claims, or ErrInvalidToken when it is malformed,
+// signed with another secret or expired.
+func (t *Tokens) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Issuer != tokenIssuer {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func (t *Tokens) sign(s string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isToken reports whether key looks like a session token rather than an API key.
+func isToken(key string) bool {
+	return !strings.HasPrefix(key, KeyPrefix) && strings.Count(key, ".") == 2
+}
+
+// isSessionToken reports whether key is a token issued by Tokens rather than by an identity
+// provider.
+func isSessionToken(key string) bool {
+	return strings.HasPrefix(key, tokenHeader+".")
+}
+
+// UserStore looks users up by id (repository.UserRepository).
+type UserStore interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+}
+
+type cachedUser struct {
+	user    model.User
+	fetched time.Time
+}
+
+// UseTokens makes a accept session tokens issued by tokens for the users in users, besides
+// API keys; call it before serving. Users are cached like keys, so a disabled user or
+// changed role applies within the cache TTL, or right away after Forget.
+func (a *Authenticator) UseTokens(users UserStore, tokens *Tokens) {
+	a.users, a.tokens = users, tokens
+}
+
+// authenticateToken returns the principal of a session token. The user's current role is
+// used rather than the one in the token, and tokens issued before the user's last password
+// change are refused.
+func (a *Authenticator) authenticateToken(ctx context.Context, token string) (*Principal, error) {
+	claims, err := a.tokens.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	a.mu.Lock()
+	c := a.userCache[id]
+	a.mu.Unlock()
+	if c == nil || now.Sub(c.fetched) > a.ttl {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			a.mu.Lock()
+			delete(a.userCache, id)
+			a.mu.Unlock()
+			return nil, ErrInvalidToken
+		}
+		c = &cachedUser{user: *u, fetched: now}
+		a.mu.Lock()
+		a.userCache[id] = c
+		a.mu.Unlock()
+	}
+	u := c.user
+	if u.Disabled || claims.IssuedAt < u.PasswordChangedAt.Unix() {
+		return nil, ErrInvalidToken
+	}
+	return &Principal{UserID: u.ID, Name: u.Email, Role: u.Role, Scopes: RoleScopes(u.Role)}, nil
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type memUsers map[uuid.UUID]model.User
+
+func (m memUsers) GetByID(_ context.Context, id uuid.UUID) (*model.User, error) {
+	u, ok := m[id]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func TestTokens(t *testing.T) {
+	tokens := NewTokens([]byte("secret"), time.Hour)
+	u := model.User{ID: uuid.New(), Email: "ops@example.com", Role: model.RoleOperator}
+	token, exp, err := tokens.Issue(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(exp); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v", d)
+	}
+	claims, err := tokens.Parse(token)
+	if err != nil || claims.Subject != u.ID.String() || claims.Email != u.Email || claims.Role != u.Role {
+		t.Fatalf("Parse() = %+v, %v", claims, err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + parts[1] + "x." + parts[2]
+	for name, tok := range map[string]string{
+		"other secret": mustIssue(t, NewTokens([]byte("other"), time.Hour), u),
+		"tampered":     forged,
+		"expired":      mustIssue(t, NewTokens([]byte("secret"), time.Nanosecond), u),
+		"malformed":    "a.b",
+	} {
+		if _, err := tokens.Parse(tok); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func mustIssue(t *testing.T, tokens *Tokens, u model.User) string {
+	t.Helper()
+	token, _, err := tokens.Issue(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRoleScopes(t *testing.T) {
+	for _, tc := range []struct {
+		role  string
+		scope string
+		want  bool
+	}{
+		{model.RoleAdmin, ScopeAdmin, true},
+		{model.RoleOperator, ScopeWrite, true},
+		{model.RoleOperator, ScopeIngest, true},
+		{model.RoleOperator, ScopeRead, true},
+		{model.RoleOperator, ScopeAdmin, false},
+		{model.RoleViewer, ScopeRead, true},
+		{model.RoleViewer, ScopeWrite, false},
+		{"root", ScopeRead, false},
+	} {
+		p := &Principal{Scopes: RoleScopes(tc.role)}
+		if got := p.Has(tc.scope); got != tc.want {
+			t.Errorf("%s has %s = %v", tc.role, tc.scope, got)
+		}
+	}
+}
+
+func TestRequireAuthSessions(t *testing.T) {
+	tokens := NewTokens([]byte("secret"), time.Hour)
+	past := time.Now().Add(-time.Minute)
+	viewer := model.User{ID: uuid.New(), Email: "viewer@example.com", Role: model.RoleViewer, PasswordChangedAt: past}
+	operator := model.User{ID: uuid.New(), Email: "ops@example.com", Role: model.RoleOperator, PasswordChangedAt: past}
+	users := memUsers{viewer.ID: viewer, operator.ID: operator}
+	a := NewAuthenticator(&memKeys{keys: make(map[string]model.APIKey)}, "", 0)
+	a.UseTokens(users, tokens)
+
+	e := echo.New()
+	e.Use(RequireAuth(a, func(c echo.Context) string {
+		if c.Request().Method == http.MethodGet {
+			return ScopeRead
+		}
+		return ScopeWrite
+	}))
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, PrincipalFrom(c).Role) }
+	e.GET("/things", ok)
+	e.POST("/things", ok)
+
+	do := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/things", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	viewerToken, operatorToken := mustIssue(t, tokens, viewer), mustIssue(t, tokens, operator)
+	if rec := do(http.MethodGet, viewerToken); rec.Code != http.StatusOK || rec.Body.String() != model.RoleViewer {
+		t.Errorf("viewer reading: %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer writing: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, operatorToken); rec.Code != http.StatusOK {
+		t.Errorf("operator writing: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, mustIssue(t, NewTokens([]byte("other"), time.Hour), viewer)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("foreign token: %d", rec.Code)
+	}
+
+	// Roles, disabling and password changes apply to tokens already issued.
+	viewer.Role = model.RoleOperator
+	operator.Disabled = true
+	users[viewer.ID], users[operator.ID] = viewer, operator
+	a.Forget()
+	if rec := do(http.MethodPost, viewerToken); rec.Code != http.StatusOK {
+		t.Errorf("promoted viewer writing: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, operatorToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("disabled operator: %d", rec.Code)
+	}
+	viewer.PasswordChangedAt = time.Now().Add(time.Second)
+	users[viewer.ID] = viewer
+	a.Forget()
+	if rec := do(http.MethodGet, viewerToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token from before password change: %d", rec.Code)
+	}
+	delete(users, viewer.ID)
+	a.Forget()
+	if rec := do(http.MethodGet, mustIssue(t, tokens, viewer)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user: %d", rec.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/labstack/echo/v4"
+)
+
+// Metrics counts every request and its duration into the HTTP metrics, labelled by the
+// matched route (e.g. /inputs/:id) rather than the path, so IDs do not multiply the series.
+// Requests that match no route are counted under "unmatched".
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := routeOf(c)
+			method := c.Request().Method
+			metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(statusOf(c, err))).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// routeOf returns the route c matched, or "unmatched".
+func routeOf(c echo.Context) string {
+	if route := c.Path(); route != "" && route != "/*" {
+		return route
+	}
+	return "unmatched"
+}
+
+// statusOf returns the status the response to c has, or will have once Echo handles err.
+func statusOf(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
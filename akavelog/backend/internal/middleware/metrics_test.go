@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	e := echo.New()
+	e.Use(Metrics())
+	e.GET("/inputs/:id", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	e.GET("/fail/:id", func(c echo.Context) error { return echo.NewHTTPError(http.StatusConflict) })
+
+	for _, path := range []string{"/inputs/1", "/inputs/2", "/fail/1", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, tc := range []struct {
+		route, code string
+		want        float64
+	}{
+		{"/inputs/:id", "204", 2},
+		{"/fail/:id", "409", 1},
+		{"unmatched", "404", 1},
+	} {
+		if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", tc.route, tc.code)); got != tc.want {
+			t.Errorf("requests of %s %s = %v, want %v", tc.route, tc.code, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // SHA-384 and SHA-512 of RS384, RS512, ES384 and ES512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// oidcSkew is the clock skew allowed when checking the times in ID tokens.
+const oidcSkew = time.Minute
+
+// jwksRefresh is how often the keys of the identity provider are fetched again at most, when
+// a token names a key that is not known.
+const jwksRefresh = time.Minute
+
+// OIDCOptions configure an OpenID Connect identity provider.
+type OIDCOptions struct {
+	Issuer       string // e.g. https://example.okta.com/oauth2/default
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // this server's /auth/oidc/callback, as registered with the provider
+	Scopes       []string // requested besides openid; default email and profile
+	Audiences    []string // accepted aud of tokens sent to the API; default ClientID
+	GroupsClaim  string   // claim listing the user's groups, may be a dotted path; default groups
+	// Groups granting each role; the highest role matched wins. DefaultRole is given to users
+	// in none of them, or "" to refuse them.
+	AdminGroups    []string
+	OperatorGroups []string
+	ViewerGroups   []string
+	DefaultRole    string
+	Client         *http.Client // default http.DefaultClient with a 10s timeout
+}
+
+// OIDCIdentity is the user an ID or access token of the identity provider names.
+type OIDCIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string // "" unless the provider says it is verified
+	Name    string
+	Groups  []string
+	Role    string // "" when the user's groups grant no role
+	Expires time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDC logs users in with an OpenID Connect identity provider (authorization code flow with
+// PKCE) and verifies the tokens it issues. The provider's configuration is discovered on
+// first use, so the server starts while the provider is unreachable.
+type OIDC struct {
+	opts   OIDCOptions
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey // by kid
+	fetched   time.Time
+}
+
+// NewOIDC returns an OIDC for opts.
+func NewOIDC(opts OIDCOptions) (*OIDC, error) {
+	opts.Issuer = strings.TrimRight(opts.Issuer, "/")
+	if opts.Issuer == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer, client_id and redirect_url are required")
+	}
+	if opts.DefaultRole != "" && RoleScopes(opts.DefaultRole) == nil {
+		return nil, fmt.Errorf("oidc: unknown default_role %q", opts.DefaultRole)
+	}
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{"email", "profile"}
+	}
+	if len(opts.Audiences) == 0 {
+		opts.Audiences = []string{opts.ClientID}
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = "groups"
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDC{opts: opts, client: client}, nil
+}
+
+// NewOIDCLogin returns the random state, nonce and PKCE verifier of a login.
+func NewOIDCLogin() (state, nonce, verifier string, err error) {
+	var out [3]string
+	for i := range out {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", "", "", err
+		}
+		out[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return out[0], out[1], out[2], nil
+}
+
+// AuthCodeURL returns the provider URL that starts a login.
+func (o *OIDC) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.opts.ClientID},
+		"redirect_uri":          {o.opts.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, o.opts.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems the code of a login and returns the identity in its ID token, which must
+// carry nonce.
+func (o *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCIdentity, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.opts.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.opts.ClientID), url.QueryEscape(o.opts.ClientSecret))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("oidc: token response: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token request: %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	return o.verify(ctx, body.IDToken, []string{o.opts.ClientID}, nonce)
+}
+
+// Verify checks a token the provider issued for the API, signed with its keys, unexpired and
+// for one of the configured audiences, and returns its identity.
+func (o *OIDC) Verify(ctx context.Context, token string) (*OIDCIdentity, error) {
+	return o.verify(ctx, token, o.opts.Audiences, "")
+}
+
+func (o *OIDC) verify(ctx context.Context, token string, audiences []string, nonce string) (*OIDCIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig) {
+		return nil, ErrInvalidToken
+	}
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s, _ := claims["iss"].(string); s != d.Issuer {
+		return nil, ErrInvalidToken
+	}
+	if !audienceMatches(claims["aud"], audiences) {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcSkew)) {
+		return nil, ErrInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrInvalidToken
+	}
+	if nonce != "" {
+		if s, _ := claims["nonce"].(string); s != nonce {
+			return nil, ErrInvalidToken
+		}
+	}
+	id := &OIDCIdentity{Expires: time.Unix(int64(exp), 0), Groups: claimStrings(lookupClaim(claims, o.opts.GroupsClaim))}
+	id.Issuer = d.Issuer
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Email = strings.ToLower(id.Email)
+	// An email the provider does not vouch for may belong to someone else.
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		id.Email = ""
+	}
+	id.Name, _ = claims["name"].(string)
+	if id.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	id.Role = o.role(id.Groups)
+	return id, nil
+}
+
+// role returns the highest role groups grant, or the default role.
+func (o *OIDC) role(groups []string) string {
+	in := func(list []string) bool {
+		for _, g := range groups {
+			for _, l := range list {
+				if g == l {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch {
+	case in(o.opts.AdminGroups):
+		return model.RoleAdmin
+	case in(o.opts.OperatorGroups):
+		return model.RoleOperator
+	case in(o.opts.ViewerGroups):
+		return model.RoleViewer
+	}
+	return o.opts.DefaultRole
+}
+
+func (o *OIDC) discover(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	d := o.discovery
+	o.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+	d = &oidcDiscovery{}
+	if err := o.getJSON(ctx, o.opts.Issuer+"/.well-known/openid-configuration", d); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != o.opts.Issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", d.Issuer, o.opts.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: missing endpoints")
+	}
+	o.mu.Lock()
+	o.discovery = d
+	o.mu.Unlock()
+	return d, nil
+}
+
+// key returns the provider key kid, fetching the provider's keys again when it is not known.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	k, ok := o.keys[kid]
+	stale := time.Since(o.fetched) >= jwksRefresh
+	o.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return nil, ErrInvalidToken
+	}
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		if pub := j.publicKey(); pub != nil {
+			keys[j.Kid] = pub
+		}
+	}
+	o.mu.Lock()
+	o.keys, o.fetched = keys, time.Now()
+	o.mu.Unlock()
+	if k, ok = keys[kid]; !ok {
+		return nil, ErrInvalidToken
+	}
+	return k, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// jwk is a JSON Web Key of type RSA or EC.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jwk) publicKey() crypto.PublicKey {
+	num := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	switch j.Kty {
+	case "RSA":
+		n, e := num(j.N), num(j.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		x, y := num(j.X), num(j.Y)
+		if x == nil || y == nil {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	}
+	return nil
+}
+
+// verifySignature checks sig over signed with key for alg: RS256, RS384, RS512, ES256,
+// ES384 or ES512.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) bool {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func audienceMatches(aud any, audiences []string) bool {
+	for _, a := range claimStrings(aud) {
+		for _, want := range audiences {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupClaim returns the claim at a dotted path such as realm_access.roles.
+func lookupClaim(claims map[string]any, path string) any {
+	var v any = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// claimStrings returns a claim that is a string or a list of strings as a list.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// UseOIDC makes a accept tokens issued by the provider o, besides API keys and session
+// tokens; call it before serving. They are trusted as they are: the user's role comes from
+// the token's groups, and no user account is needed.
+func (a *Authenticator) UseOIDC(o *OIDC) {
+	a.oidc = o
+}
+
+func (a *Authenticator) authenticateOIDC(ctx context.Context, token string) (*Principal, error) {
+	id, err := a.oidc.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if id.Role == "" {
+		return nil, ErrInvalidToken
+	}
+	name := id.Email
+	if name == "" {
+		name = id.Subject
+	}
+	return &Principal{Name: name, Role: id.Role, Scopes: RoleScopes(id.Role)}, nil
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// fakeIdP is an OpenID Connect provider signing with one RSA key.
+type fakeIdP struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	idToken  string // returned by the token endpoint
+	verifier string // code_verifier the token endpoint received
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "akavelog" || secret != "s3cret" || r.FormValue("code") != "code-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		p.verifier = r.FormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.idToken, "token_type": "Bearer"})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeIdP) sign(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *fakeIdP) claims(aud string, groups ...string) map[string]any {
+	return map[string]any{
+		"iss":            p.URL,
+		"aud":            aud,
+		"sub":            "00u1",
+		"email":          "Dev@Example.com",
+		"name":           "Dev",
+		"email_verified": true,
+		"groups":         groups,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func newTestOIDC(t *testing.T, p *fakeIdP) *OIDC {
+	t.Helper()
+	o, err := NewOIDC(OIDCOptions{
+		Issuer:         p.URL,
+		ClientID:       "akavelog",
+		ClientSecret:   "s3cret",
+		RedirectURL:    "http://localhost:8080/auth/oidc/callback",
+		Audiences:      []string{"api://akavelog"},
+		AdminGroups:    []string{"ops-admins"},
+		OperatorGroups: []string{"ops"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestOIDCVerify(t *testing.T) {
+	p := newFakeIdP(t)
+	o := newTestOIDC(t, p)
+	ctx := context.Background()
+
+	id, err := o.Verify(ctx, p.sign(t, p.key, p.claims("api://akavelog", "ops", "ops-admins")))
+	if err != nil || id.Email != "dev@example.com" || id.Role != model.RoleAdmin {
+		t.Fatalf("Verify() = %+v, %v", id, err)
+	}
+	if id.Issuer != p.URL || id.Subject != "00u1" {
+		t.Errorf("issuer, subject = %q, %q", id.Issuer, id.Subject)
+	}
+	if id, err := o.Verify(ctx, p.sign(t, p.key, p.claims("api://akavelog", "ops"))); err != nil || id.Role != model.RoleOperator {
+		t.Errorf("operator: %+v, %v", id, err)
+	}
+	for _, verified := range []any{nil, false, "true"} {
+		claims := p.claims("api://akavelog")
+		if verified == nil {
+			delete(claims, "email_verified")
+		} else {
+			claims["email_verified"] = verified
+		}
+		if id, err := o.Verify(ctx, p.sign(t, p.key, claims)); err != nil || id.Email != "" {
+			t.Errorf("email_verified %v: %+v, %v", verified, id, err)
+		}
+	}
+	if id, err := o.Verify(ctx, p.sign(t, p.key, p.claims("api://akavelog", "sales"))); err != nil || id.Role != "" {
+		t.Errorf("no group: %+v, %v", id, err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := p.claims("api://akavelog")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	foreign := p.claims("api://akavelog")
+	foreign["iss"] = "https://evil.example.com"
+	for name, token := range map[string]string{
+		"other audience": p.sign(t, p.key, p.claims("api://other")),
+		"other key":      p.sign(t, other, p.claims("api://akavelog")),
+		"expired":        p.sign(t, p.key, expired),
+		"other issuer":   p.sign(t, p.key, foreign),
+	} {
+		if _, err := o.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// The API accepts the provider's tokens of users with a role.
+	a := NewAuthenticator(&memKeys{}, "", 0)
+	a.UseTokens(memUsers{}, NewTokens([]byte("secret"), time.Hour))
+	a.UseOIDC(o)
+	pr, err := a.Authenticate(ctx, p.sign(t, p.key, p.claims("api://akavelog", "ops")))
+	if err != nil || pr.Name != "dev@example.com" || !pr.Has(ScopeWrite) || pr.Has(ScopeAdmin) {
+		t.Errorf("Authenticate() = %+v, %v", pr, err)
+	}
+	if _, err := a.Authenticate(ctx, p.sign(t, p.key, p.claims("api://akavelog"))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token without role: %v", err)
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newFakeIdP(t)
+	o := newTestOIDC(t, p)
+	ctx := context.Background()
+
+	state, nonce, verifier, err := NewOIDCLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := o.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	challenge := sha256.Sum256([]byte(verifier))
+	if u.Path != "/authorize" || q.Get("state") != state || q.Get("nonce") != nonce || q.Get("scope") != "openid email profile" ||
+		q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		t.Errorf("AuthCodeURL() = %s", target)
+	}
+
+	claims := p.claims("akavelog", "ops-admins")
+	claims["nonce"] = nonce
+	p.idToken = p.sign(t, p.key, claims)
+	id, err := o.Exchange(ctx, "code-1", verifier, nonce)
+	if err != nil || id.Role != model.RoleAdmin || id.Name != "Dev" || p.verifier != verifier {
+		t.Fatalf("Exchange() = %+v, %v (verifier %q)", id, err, p.verifier)
+	}
+	if _, err := o.Exchange(ctx, "code-1", verifier, "another nonce"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("replayed ID token: %v", err)
+	}
+	if _, err := o.Exchange(ctx, "code-2", verifier, nonce); err == nil {
+		t.Error("unknown code: no error")
+	}
+}
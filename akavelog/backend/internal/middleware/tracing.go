@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"github.com/labstack/echo/v4"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// Tracing serves every request under a server span named after its method and route,
+// continuing the caller's trace from its traceparent header. Ingest requests carry the span
+// on into their input's buffer.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := routeOf(c)
+			ctx, span := tracing.StartServer(req, req.Method+" "+route)
+			defer span.End()
+			span.SetAttributes(semconv.HTTPRoute(route))
+			c.SetRequest(req.WithContext(ctx))
+			err := next(c)
+			tracing.SetStatus(span, statusOf(c, err))
+			return err
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertState is where an alert is in its lifecycle: ok until its condition holds, firing
+// while it does, and resolved once it stops holding.
+type AlertState string
+
+const (
+	AlertOK       AlertState = "ok"
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved"
+)
+
+// Alert severities.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert counts the ingested entries matching Query (and ProjectID when set) and fires when
+// Condition holds, e.g. "count > 100 in 5m" or "no logs in 10m". Channels names the
+// notification channels told when it fires and resolves. State, StateChangedAt and LastValue
+// are as of the last state change.
+type Alert struct {
+	ID             uuid.UUID  `db:"id"`
+	Name           string     `db:"name"`
+	Description    string     `db:"description"`
+	Query          string     `db:"query"`
+	ProjectID      string     `db:"project_id"`
+	Condition      string     `db:"condition"`
+	Severity       string     `db:"severity"`
+	Channels       []string   `db:"channels"`
+	Enabled        bool       `db:"enabled"`
+	State          AlertState `db:"state"`
+	StateChangedAt *time.Time `db:"state_changed_at"`
+	LastValue      int        `db:"last_value"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// AlertEvent is a state change of an alert. Value is the count in the window at the change.
+type AlertEvent struct {
+	ID        uuid.UUID  `db:"id"`
+	AlertID   uuid.UUID  `db:"alert_id"`
+	State     AlertState `db:"state"`
+	Value     int        `db:"value"`
+	Message   string     `db:"message"`
+	CreatedAt time.Time  `db:"created_at"`
+}
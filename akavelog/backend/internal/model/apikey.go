@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a key of the management API. The key itself is shown once when it is created;
+// only its SHA-256 (Hash) and first characters (Prefix) are kept.
+type APIKey struct {
+	ID         uuid.UUID  `db:"id"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	Hash       string     `db:"hash"`
+	Scopes     []string   `db:"scopes"`     // read, write, ingest, admin
+	ProjectID  *uuid.UUID `db:"project_id"` // nil for every project
+	ExpiresAt  *time.Time `db:"expires_at"` // nil for never
+	LastUsedAt *time.Time `db:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+}
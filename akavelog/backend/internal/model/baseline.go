@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// EWMA is an exponentially weighted moving mean and variance of a metric, with the number of
+// buckets it has learned from.
+type EWMA struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  int     `json:"samples"`
+}
+
+// Observation is a metric's value in one analyzed bucket, compared with the baseline it had
+// then. Score is the deviation in standard deviations.
+type Observation struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Value    float64   `json:"value"`
+	Expected float64   `json:"expected"`
+	StdDev   float64   `json:"stddev"`
+	Score    float64   `json:"score"`
+	Seasonal bool      `json:"seasonal"` // compared with the baseline of the bucket's hour of day
+	Learning bool      `json:"learning"` // no baseline had enough samples to compare with
+	Anomaly  string    `json:"anomaly,omitempty"`
+}
+
+// MetricBaseline is what the anomaly analyzer learned about one metric of a service: over all
+// buckets, and per hour of day (UTC) for daily patterns.
+type MetricBaseline struct {
+	Overall EWMA         `json:"overall"`
+	Hourly  [24]EWMA     `json:"hourly"`
+	Last    *Observation `json:"last,omitempty"`
+}
+
+// Baseline holds the learned volume (entries per bucket) and error rate (share of error and
+// fatal entries) of one service in a project. UpdatedAt is the end of the last bucket it
+// learned from; LastSeenAt the end of the last bucket the service had entries in.
+type Baseline struct {
+	ProjectID  string
+	Service    string
+	Volume     MetricBaseline
+	ErrorRate  MetricBaseline
+	LastSeenAt time.Time
+	UpdatedAt  time.Time
+}
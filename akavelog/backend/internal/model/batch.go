@@ -1,2 +1,20 @@
 package model
 
+import "time"
+
+// Batch is an uploaded batch object as recorded in the batches index, so queries can find the
+// objects of a time range without listing the bucket.
+type Batch struct {
+	Key          string     `json:"key"`
+	ProjectID    string     `json:"project_id"`
+	Prefix       string     `json:"prefix"`           // logs or the o3_prefix of a stream
+	Target       string     `json:"target,omitempty"` // storage target holding the object; "" for storage.o3
+	MinTimestamp *time.Time `json:"min_timestamp"`    // nil when no entry has a valid timestamp
+	MaxTimestamp *time.Time `json:"max_timestamp"`
+	Count        int        `json:"count"`
+	Size         int64      `json:"size"`
+	SHA256       string     `json:"sha256"` // base64, as in the object's metadata
+	Codec        string     `json:"codec"`
+	CID          string     `json:"cid,omitempty"` // root CID on a content-addressed backend (Akave)
+	UploadedAt   time.Time  `json:"uploaded_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InputKey is an ingest key of an input. The key itself is shown once when it is issued;
+// only its SHA-256 (Hash) and first characters (Prefix) are kept.
+type InputKey struct {
+	ID        uuid.UUID  `db:"id"`
+	InputID   uuid.UUID  `db:"input_id"`
+	Prefix    string     `db:"prefix"`
+	Hash      string     `db:"hash"`
+	ExpiresAt *time.Time `db:"expires_at"` // nil for the current key
+	CreatedAt time.Time  `db:"created_at"`
+}
@@ -12,7 +12,8 @@ type RawRequestData struct {
 // LogEntry is the validated structure for an ingested log.
 // Ingest payloads should be JSON with these fields.
 type LogEntry struct {
-	Timestamp   string            `json:"timestamp"`             // ISO8601 or Unix ms
+	ID          string            `json:"_id,omitempty"`         // ULID assigned at ingest, or the client's own for idempotent resubmission
+	Timestamp   string            `json:"timestamp"`             // RFC3339 UTC once validated; ingest accepts ISO8601 or Unix s/ms/us/ns
 	Service     string            `json:"service"`               // required
 	Level       string            `json:"level"`                 // e.g. debug, info, warn, error
 	Message     string            `json:"message"`                // required
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LookupTableKind string
+
+const (
+	// LookupTableCSV tables hold their rows in CSV uploaded through the API.
+	LookupTableCSV LookupTableKind = "csv"
+	// LookupTablePostgres tables are the result of a read-only SQL query, refreshed every TTL.
+	LookupTablePostgres LookupTableKind = "postgres"
+)
+
+// LookupTable maps the values of KeyColumn onto the other columns of a row.
+type LookupTable struct {
+	ID          uuid.UUID       `db:"id"`
+	Name        string          `db:"name"`
+	Description string          `db:"description"`
+	Kind        LookupTableKind `db:"kind"`
+	KeyColumn   string          `db:"key_column"`
+	CSVData     string          `db:"csv_data"`
+	Query       string          `db:"query"`
+	TTLSeconds  int             `db:"ttl_seconds"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
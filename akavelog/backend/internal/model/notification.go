@@ -0,0 +1,21 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationChannel is a persisted destination of alert notifications, such as a Slack
+// webhook or an email address. Alerts list the Names of the channels they notify.
+type NotificationChannel struct {
+	ID            uuid.UUID       `db:"id"`
+	Name          string          `db:"name"`
+	Type          string          `db:"type"`
+	Description   string          `db:"description"`
+	Enabled       bool            `db:"enabled"`
+	Configuration json.RawMessage `db:"configuration"`
+	CreatedAt     time.Time       `db:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Output is a persisted forwarding destination. It receives the entries of the streams that
+// list its Name in their outputs, or every entry when AllEntries is set.
+type Output struct {
+	ID            uuid.UUID       `db:"id"`
+	Name          string          `db:"name"`
+	Type          string          `db:"type"`
+	Description   string          `db:"description"`
+	Enabled       bool            `db:"enabled"`
+	AllEntries    bool            `db:"all_entries"`
+	Configuration json.RawMessage `db:"configuration"`
+	CreatedAt     time.Time       `db:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at"`
+}
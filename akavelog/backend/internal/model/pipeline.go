@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PipelineStage orders processors within a pipeline: parse, then enrich, filter and route.
+type PipelineStage string
+
+const (
+	PipelineStageParse  PipelineStage = "parse"
+	PipelineStageEnrich PipelineStage = "enrich"
+	PipelineStageFilter PipelineStage = "filter"
+	PipelineStageRoute  PipelineStage = "route"
+)
+
+// ProcessorConfig is one configured processor of a pipeline. An empty Stage uses the
+// processor type's default stage.
+type ProcessorConfig struct {
+	Type   string         `json:"type"`
+	Stage  PipelineStage  `json:"stage,omitempty"`
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// Pipeline is a persisted chain of processors. A nil InputID applies it to every input; a
+// ProjectID limits it to the entries of that project.
+type Pipeline struct {
+	ID          uuid.UUID         `db:"id"`
+	Name        string            `db:"name"`
+	Description string            `db:"description"`
+	InputID     *uuid.UUID        `db:"input_id"`
+	ProjectID   *uuid.UUID        `db:"project_id"`
+	Enabled     bool              `db:"enabled"`
+	Processors  []ProcessorConfig `db:"processors"`
+	CreatedAt   time.Time         `db:"created_at"`
+	UpdatedAt   time.Time         `db:"updated_at"`
+}
@@ -7,12 +7,27 @@ import (
 	"github.com/google/uuid"
 )
 
+// Project is a tenant. Its ID is the project_id of its entries and the project segment of
+// their O3 keys; inputs and pipelines may belong to one. O3Target names the storage target
+// its batches are kept in, unless a stream they are routed into names another.
+type Project struct {
+	ID          uuid.UUID `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	OwnerEmail  string    `db:"owner_email"`
+	O3Target    string    `db:"o3_target"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
 type InputState string
 
 const (
 	InputStateRunning InputState = "RUNNING"
 	InputStateStopped InputState = "STOPPED"
 	InputStatePaused  InputState = "PAUSED"
+	// InputStateFailed is reported for RUNNING inputs that could not be started; it is never persisted.
+	InputStateFailed InputState = "FAILED"
 )
 
 type Input struct {
@@ -21,8 +36,13 @@ type Input struct {
 	Title         string          `db:"title"`
 	Configuration json.RawMessage `db:"configuration"`
 	Global        bool            `db:"global"`
+	ProjectID     *uuid.UUID      `db:"project_id"` // stamped on every entry; nil keeps the entries' own
 	NodeID        string          `db:"node_id"`
 	CreatorUserID string          `db:"creator_user_id"`
 	CreatedAt     time.Time       `db:"created_at"`
 	DesiredState  InputState      `db:"desired_state"`
+	LastError     string          `db:"last_error"`
+	LastErrorAt   *time.Time      `db:"last_error_at"`
+	Version       int64           `db:"version"` // incremented by every update of the definition
+	UpdatedAt     time.Time       `db:"updated_at"`
 }
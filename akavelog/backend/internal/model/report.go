@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportFormat is a rendering of a report's results.
+type ReportFormat string
+
+const (
+	ReportJSON ReportFormat = "json"
+	ReportCSV  ReportFormat = "csv"
+	ReportHTML ReportFormat = "html"
+)
+
+// Report runs a saved aggregation on a cron Schedule, matched in Timezone, and stores its
+// results in O3 in each of Formats. The outputs named in Outputs are sent an entry announcing
+// every run. NextRunAt is nil while the report is disabled. ProjectID is the project of the
+// saved search, "" for none.
+type Report struct {
+	ID            uuid.UUID      `db:"id"`
+	Name          string         `db:"name"`
+	Description   string         `db:"description"`
+	SavedSearchID uuid.UUID      `db:"saved_search_id"`
+	ProjectID     string         `db:"project_id"`
+	Schedule      string         `db:"schedule"`
+	Timezone      string         `db:"timezone"`
+	Formats       []ReportFormat `db:"formats"`
+	Outputs       []string       `db:"outputs"`
+	Enabled       bool           `db:"enabled"`
+	NextRunAt     *time.Time     `db:"next_run_at"`
+	LastRunAt     *time.Time     `db:"last_run_at"`
+	LastStatus    string         `db:"last_status"`
+	LastError     string         `db:"last_error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+}
+
+// Report run statuses.
+const (
+	ReportRunDone   = "done"
+	ReportRunFailed = "failed"
+)
+
+// ReportRun is one run of a report over [Start, End). Objects maps each format stored to its
+// O3 key.
+type ReportRun struct {
+	ID         uuid.UUID               `db:"id"`
+	ReportID   uuid.UUID               `db:"report_id"`
+	Scheduled  bool                    `db:"scheduled"`
+	Status     string                  `db:"status"`
+	Start      time.Time               `db:"range_start"`
+	End        time.Time               `db:"range_end"`
+	Total      int                     `db:"total"`
+	Objects    map[ReportFormat]string `db:"objects"`
+	DurationMS int64                   `db:"duration_ms"`
+	Error      string                  `db:"error"`
+	CreatedAt  time.Time               `db:"created_at"`
+}
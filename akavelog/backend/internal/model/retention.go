@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RetentionAction string
+
+const (
+	// RetentionDelete deletes expired objects.
+	RetentionDelete RetentionAction = "delete"
+	// RetentionArchive moves expired objects under the archive/ prefix.
+	RetentionArchive RetentionAction = "archive"
+)
+
+// RetentionPolicy says how long batch objects of a project and stream are kept. An empty
+// ProjectID covers every project; a nil StreamID covers the default logs/ prefix. Days 0 keeps
+// objects forever, overriding broader policies.
+type RetentionPolicy struct {
+	ID        uuid.UUID       `db:"id"`
+	ProjectID string          `db:"project_id"`
+	StreamID  *uuid.UUID      `db:"stream_id"`
+	Days      int             `db:"days"`
+	Action    RetentionAction `db:"action"`
+	Enabled   bool            `db:"enabled"`
+	CreatedAt time.Time       `db:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at"`
+}
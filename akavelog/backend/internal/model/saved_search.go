@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type QueryKind string
+
+const (
+	// QuerySearch returns matching entries (POST /query).
+	QuerySearch QueryKind = "search"
+	// QueryAggregate counts matching entries (POST /logs/aggregate).
+	QueryAggregate QueryKind = "aggregate"
+	// QuerySQL runs a SQL statement (POST /logs/sql).
+	QuerySQL QueryKind = "sql"
+)
+
+// SavedSearch is a named search, aggregation or SQL statement that can be run again. Query is
+// in the query language, or SQL for QuerySQL; TimeRange is how far back a run reads, e.g.
+// "24h" or "7d". Owner is empty until requests are authenticated; searches of other owners
+// are visible only when Shared.
+type SavedSearch struct {
+	ID          uuid.UUID         `db:"id"`
+	Owner       string            `db:"owner"`
+	Shared      bool              `db:"shared"`
+	Name        string            `db:"name"`
+	Description string            `db:"description"`
+	Kind        QueryKind         `db:"kind"`
+	Query       string            `db:"query"`
+	ProjectID   string            `db:"project_id"`
+	TimeRange   string            `db:"time_range"`
+	Params      SavedSearchParams `db:"params"`
+	RunCount    int64             `db:"run_count"`
+	LastRunAt   *time.Time        `db:"last_run_at"`
+	LastRunMS   int64             `db:"last_run_ms"`
+	LastResults int               `db:"last_results"`
+	LastError   string            `db:"last_error"`
+	CreatedAt   time.Time         `db:"created_at"`
+	UpdatedAt   time.Time         `db:"updated_at"`
+}
+
+// SavedSearchParams are the settings of a saved search beyond its query: Limit for searches,
+// the rest for aggregations.
+type SavedSearchParams struct {
+	Limit    int      `json:"limit,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	GroupBy  []string `json:"group_by,omitempty"`
+	Top      []string `json:"top,omitempty"`
+	TopN     int      `json:"top_n,omitempty"`
+}
+
+// QueryRun is one run of a search, aggregation or SQL statement, as kept in the query history.
+// Results is the number of entries, the total counted or the rows returned. Start and End are
+// the time range read; zero when a statement failed before it was known.
+type QueryRun struct {
+	ID            uuid.UUID  `db:"id"`
+	SavedSearchID *uuid.UUID `db:"saved_search_id"`
+	Owner         string     `db:"owner"`
+	Kind          QueryKind  `db:"kind"`
+	Query         string     `db:"query"`
+	ProjectID     string     `db:"project_id"`
+	Start         time.Time  `db:"range_start"`
+	End           time.Time  `db:"range_end"`
+	DurationMS    int64      `db:"duration_ms"`
+	Results       int        `db:"results"`
+	Error         string     `db:"error"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StreamMatchType string
+
+const (
+	// StreamMatchAll routes an entry into the stream when every rule matches.
+	StreamMatchAll StreamMatchType = "all"
+	// StreamMatchAny routes an entry into the stream when at least one rule matches.
+	StreamMatchAny StreamMatchType = "any"
+)
+
+// Stream is a named subset of entries, selected by rule expressions (see internal/rules).
+// RetentionDays, O3Prefix, O3Target and Outputs are per-stream settings for the entries it
+// contains; zero values fall back to the server defaults.
+type Stream struct {
+	ID            uuid.UUID       `db:"id"`
+	Name          string          `db:"name"`
+	Description   string          `db:"description"`
+	Enabled       bool            `db:"enabled"`
+	MatchType     StreamMatchType `db:"match_type"`
+	Rules         []string        `db:"rules"`
+	RetentionDays int             `db:"retention_days"`
+	O3Prefix      string          `db:"o3_prefix"`
+	O3Target      string          `db:"o3_target"` // storage target name; "" for storage.o3
+	Outputs       []string        `db:"outputs"`   // output target names
+	CreatedAt     time.Time       `db:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at"`
+}
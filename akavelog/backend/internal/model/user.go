@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles of users. An admin manages everything, including users and API keys; an operator
+// changes inputs, pipelines and the rest of the configuration; a viewer only reads.
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// User is a person who logs in to the management API. Emails are stored lower-case.
+type User struct {
+	ID                uuid.UUID  `db:"id"`
+	Email             string     `db:"email"`
+	Name              string     `db:"name"`
+	PasswordHash      string     `db:"password_hash"` // bcrypt; empty for users created by single sign-on
+	SSOIssuer         string     `db:"sso_issuer"`    // identity provider of a user created by single sign-on
+	SSOSubject        string     `db:"sso_subject"`   // its subject at SSOIssuer; empty for other users
+	Role              string     `db:"role"`
+	Disabled          bool       `db:"disabled"`
+	PasswordChangedAt time.Time  `db:"password_changed_at"`
+	LastLoginAt       *time.Time `db:"last_login_at"`
+	CreatedAt         time.Time  `db:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at"`
+}
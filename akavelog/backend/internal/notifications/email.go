@@ -0,0 +1,187 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	GlobalRegistry.Register(&emailFactory{})
+}
+
+// emailFactory creates SMTP email channels. Registers as "email".
+type emailFactory struct{}
+
+func (f *emailFactory) Name() string { return "email" }
+
+func (f *emailFactory) ConfigSpec() TypeInfo {
+	return TypeInfo{
+		Type:        "email",
+		Description: "Sends a plain-text email over SMTP with the title as its subject.",
+		Fields: []ConfigField{
+			{Name: "host", Type: "string", Required: true, Description: "SMTP server", Example: "smtp.example.com"},
+			{Name: "port", Type: "int", Required: false, Description: "SMTP port (default 587, or 465 with tls)", Example: "587"},
+			{Name: "tls", Type: "string", Required: false, Description: "starttls (default; upgrades when the server offers it), tls (implicit TLS) or none", Example: "starttls"},
+			{Name: "username", Type: "string", Required: false, Description: "User for PLAIN authentication, which needs TLS unless the server is localhost", Example: "alerts@example.com"},
+			{Name: "password", Type: "string", Required: false, Description: "Password for PLAIN authentication", Example: "secret"},
+			{Name: "from", Type: "string", Required: true, Description: "Sender address", Example: "akavelog <alerts@example.com>"},
+			{Name: "to", Type: "string", Required: true, Description: "Recipients, as an array or comma-separated", Example: "oncall@example.com, ops@example.com"},
+			{Name: "timeout", Type: "string", Required: false, Description: "Timeout of one send (default 10s)", Example: "10s"},
+		},
+	}
+}
+
+func (f *emailFactory) Create(cfg Config) (Channel, error) {
+	c := &emailChannel{host: str(cfg, "host"), tls: strings.ToLower(str(cfg, "tls")), username: str(cfg, "username")}
+	c.password, _ = cfg["password"].(string)
+	if c.host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	switch c.tls {
+	case "":
+		c.tls = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("tls must be starttls, tls or none")
+	}
+	c.port = 587
+	if c.tls == "tls" {
+		c.port = 465
+	}
+	if n, ok := cfg.Int("port"); ok {
+		if n < 1 || n > 65535 {
+			return nil, fmt.Errorf("port must be between 1 and 65535")
+		}
+		c.port = n
+	}
+	from, err := mail.ParseAddress(str(cfg, "from"))
+	if err != nil {
+		return nil, fmt.Errorf("from must be an email address")
+	}
+	c.from = from
+	for _, s := range cfg.Strings("to") {
+		to, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("to: %q is not an email address", s)
+		}
+		c.to = append(c.to, to)
+	}
+	if len(c.to) == 0 {
+		return nil, fmt.Errorf("to is required")
+	}
+	if c.timeout, err = timeout(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type emailChannel struct {
+	host     string
+	port     int
+	tls      string
+	username string
+	password string
+	from     *mail.Address
+	to       []*mail.Address
+	timeout  time.Duration
+}
+
+func (c *emailChannel) Send(ctx context.Context, m *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := c.send(ctx, c.message(m))
+	// 5xx replies are permanent; 4xx and network errors are worth retrying.
+	var perr *textproto.Error
+	if errors.As(err, &perr) && perr.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (c *emailChannel) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+	tlsConfig := &tls.Config{ServerName: c.host}
+	var conn net.Conn
+	var err error
+	if c.tls == "tls" {
+		d := &tls.Dialer{Config: tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.tls == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if c.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.username, c.password, c.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.from.Address); err != nil {
+		return err
+	}
+	for _, to := range c.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message returns m as a plain-text email.
+func (c *emailChannel) message(m *Message) []byte {
+	to := make([]string, len(c.to))
+	for i, a := range c.to {
+		to[i] = a.String()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body := strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,88 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// str returns cfg[key] as a trimmed string.
+func str(cfg Config, key string) string {
+	v, _ := cfg[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// httpURL returns cfg[key] when it is an http or https URL.
+func httpURL(cfg Config, key string) (string, error) {
+	v := str(cfg, key)
+	u, err := url.Parse(v)
+	if v == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%s is required and must be an http or https URL", key)
+	}
+	return v, nil
+}
+
+// timeout returns cfg["timeout"] as a duration, or defaultTimeout.
+func timeout(cfg Config) (time.Duration, error) {
+	v := str(cfg, "timeout")
+	if v == "" {
+		return defaultTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("timeout must be a positive duration (e.g. %s)", defaultTimeout)
+	}
+	return d, nil
+}
+
+// postJSON posts v as JSON to url. Responses other than 2xx are errors; those other than 429
+// and 5xx are Permanent.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("POST %s: %s", redactURL(url), resp.Status)
+	if s := strings.TrimSpace(string(msg)); s != "" {
+		err = fmt.Errorf("%w: %s", err, s)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return Permanent(err)
+}
+
+// redactURL returns the scheme and host of a URL, whose path may hold a secret such as a
+// Slack webhook token.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
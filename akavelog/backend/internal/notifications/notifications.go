@@ -0,0 +1,171 @@
+// Package notifications tells people about alerts over channels such as Slack, email,
+// PagerDuty and webhooks. It mirrors package outputs: channel types register a Factory, and
+// the Notifier runs the configured channels, each with its own queue, retrying failed sends.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Config is a channel's configuration, with the same helpers as input configuration.
+type Config = inputs.Config
+
+// ConfigField describes one configuration field, as for input types.
+type ConfigField = inputs.ConfigField
+
+// TypeInfo describes a channel type and the configuration it expects.
+// Exposed via GET /notifications/types.
+type TypeInfo struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Fields      []ConfigField `json:"fields"`
+}
+
+// Notification is what a channel tells about: an alert changing state.
+type Notification struct {
+	AlertID     string           `json:"alert_id"`
+	Alert       string           `json:"alert"`
+	Description string           `json:"description,omitempty"`
+	Severity    string           `json:"severity"`
+	State       model.AlertState `json:"state"` // firing or resolved
+	Condition   string           `json:"condition"`
+	Query       string           `json:"query,omitempty"`
+	ProjectID   string           `json:"project_id,omitempty"`
+	Value       int              `json:"value"` // entries counted in the window
+	Message     string           `json:"message"`
+	At          time.Time        `json:"at"`
+	Test        bool             `json:"test,omitempty"` // sent by POST /notifications/:id/test
+}
+
+// AlertNotification returns the notification of a's state change ev.
+func AlertNotification(a model.Alert, ev model.AlertEvent) Notification {
+	return Notification{
+		AlertID:     a.ID.String(),
+		Alert:       a.Name,
+		Description: a.Description,
+		Severity:    a.Severity,
+		State:       ev.State,
+		Condition:   a.Condition,
+		Query:       a.Query,
+		ProjectID:   a.ProjectID,
+		Value:       ev.Value,
+		Message:     ev.Message,
+		At:          ev.CreatedAt,
+	}
+}
+
+// Message is a notification as a channel sends it, with the title and body rendered from the
+// channel's templates.
+type Message struct {
+	Notification
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Channel is the interface implemented by all channel types.
+type Channel interface {
+	// Send delivers one message. Errors are retried unless marked Permanent.
+	Send(ctx context.Context, m *Message) error
+}
+
+// Factory creates a Channel from config. Each channel type implements and registers one.
+type Factory interface {
+	Name() string
+	ConfigSpec() TypeInfo
+	Create(cfg Config) (Channel, error)
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a request the destination rejected.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Registry holds registered channel factories.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry returns a new Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// GlobalRegistry is the default registry; the built-in channel types register in init().
+var GlobalRegistry = NewRegistry()
+
+// Register adds a factory for a channel type. It panics on a duplicate name.
+func (r *Registry) Register(factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.factories[factory.Name()]; exists {
+		panic(fmt.Sprintf("notification channel type %q already registered", factory.Name()))
+	}
+	r.factories[factory.Name()] = factory
+}
+
+// Create builds a Channel for the given type and config.
+func (r *Registry) Create(name string, cfg Config) (Channel, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown notification channel type: %s", name)
+	}
+	return factory.Create(cfg)
+}
+
+// GetTypeInfo returns the config spec of a channel type, with the fields every type takes.
+// ok is false if the type is not registered.
+func (r *Registry) GetTypeInfo(name string) (info TypeInfo, ok bool) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return TypeInfo{}, false
+	}
+	return withCommonFields(factory.ConfigSpec()), true
+}
+
+// AllTypesInfo returns the config specs of all registered channel types, sorted by type.
+func (r *Registry) AllTypesInfo() []TypeInfo {
+	r.mu.RLock()
+	out := make([]TypeInfo, 0, len(r.factories))
+	for _, factory := range r.factories {
+		out = append(out, withCommonFields(factory.ConfigSpec()))
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// commonFields are handled by the Notifier for every channel type.
+var commonFields = []ConfigField{
+	{Name: "title_template", Type: "string", Required: false, Description: "Go text/template of the title, over the fields of the notification (default " + DefaultTitle + ")", Example: "{{upper .State}}: {{.Alert}}"},
+	{Name: "body_template", Type: "string", Required: false, Description: "Go text/template of the body (default: the message, severity, condition, query, project and time)", Example: "{{.Message}} ({{.Value}} entries)"},
+	{Name: "max_retries", Type: "int", Required: false, Description: "Retries of a failed send, waiting from 1s and doubling up to 1m (default 3)", Example: "3"},
+}
+
+func withCommonFields(info TypeInfo) TypeInfo {
+	info.Fields = append(append([]ConfigField(nil), info.Fields...), commonFields...)
+	return info
+}
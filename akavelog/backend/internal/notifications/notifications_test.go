@@ -0,0 +1,275 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+func firing() Notification {
+	return Notification{
+		AlertID:   "a1",
+		Alert:     "api errors",
+		Severity:  model.SeverityCritical,
+		State:     model.AlertFiring,
+		Condition: "count > 100 in 5m",
+		Query:     "service:api AND level:error",
+		Value:     153,
+		Message:   "153 entries in 5m0s, condition count > 100 in 5m",
+		At:        time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	tmpl, err := parseTemplates(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := tmpl.render(firing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "[FIRING] api errors" {
+		t.Errorf("title %q", m.Title)
+	}
+	wantBody := "153 entries in 5m0s, condition count > 100 in 5m\nSeverity: critical\nCondition: count > 100 in 5m\nQuery: service:api AND level:error\nAt: 2026-03-01T10:00:00Z"
+	if m.Body != wantBody {
+		t.Errorf("body:\n%s\nwant:\n%s", m.Body, wantBody)
+	}
+
+	tmpl, err = parseTemplates(Config{"title_template": "{{lower .Severity}}: {{.Alert}} at {{.Value}}", "body_template": " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := tmpl.render(firing()); m.Title != "critical: api errors at 153" || !strings.HasPrefix(m.Body, "153 entries") {
+		t.Errorf("custom templates: %+v", m)
+	}
+
+	n := NewNotifier(GlobalRegistry)
+	bad := model.NotificationChannel{Name: "bad", Type: "webhook", Configuration: json.RawMessage(`{"url": "http://x", "title_template": "{{.Nope}}"}`)}
+	if err := n.Validate(bad); err == nil || !strings.Contains(err.Error(), "title_template") {
+		t.Errorf("Validate with an unknown field: %v", err)
+	}
+}
+
+// recorder is an HTTP server answering with the statuses in codes, then 200, and recording
+// request bodies.
+type recorder struct {
+	mu     sync.Mutex
+	codes  []int
+	bodies []map[string]any
+	header http.Header
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var v map[string]any
+	_ = json.Unmarshal(body, &v)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.bodies = append(rec.bodies, v)
+	rec.header = r.Header
+	if len(rec.codes) > 0 {
+		code := rec.codes[0]
+		rec.codes = rec.codes[1:]
+		w.WriteHeader(code)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (rec *recorder) requests() []map[string]any {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]map[string]any(nil), rec.bodies...)
+}
+
+func send(t *testing.T, typ string, cfg Config, n Notification) {
+	t.Helper()
+	ch, err := GlobalRegistry.Create(typ, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, _ := parseTemplates(cfg)
+	m, err := tmpl.render(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPChannels(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	send(t, "slack", Config{"webhook_url": srv.URL, "channel": "#alerts"}, firing())
+	send(t, "webhook", Config{"url": srv.URL, "headers": map[string]any{"Authorization": "Bearer t"}}, firing())
+	if got := rec.header.Get("Authorization"); got != "Bearer t" {
+		t.Errorf("webhook header %q", got)
+	}
+	send(t, "pagerduty", Config{"routing_key": "rk", "url": srv.URL}, firing())
+	resolved := firing()
+	resolved.State = model.AlertResolved
+	send(t, "pagerduty", Config{"routing_key": "rk", "url": srv.URL}, resolved)
+
+	reqs := rec.requests()
+	if len(reqs) != 4 {
+		t.Fatalf("%d requests", len(reqs))
+	}
+	slack := reqs[0]
+	att, _ := slack["attachments"].([]any)
+	if slack["text"] != "[FIRING] api errors" || slack["channel"] != "#alerts" || len(att) != 1 || att[0].(map[string]any)["color"] != "#d00000" {
+		t.Errorf("slack %v", slack)
+	}
+	if hook := reqs[1]; hook["alert"] != "api errors" || hook["state"] != "firing" || hook["value"] != float64(153) || hook["title"] != "[FIRING] api errors" {
+		t.Errorf("webhook %v", hook)
+	}
+	trigger, resolve := reqs[2], reqs[3]
+	payload, _ := trigger["payload"].(map[string]any)
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "akavelog-a1" || trigger["routing_key"] != "rk" ||
+		payload["severity"] != "critical" || payload["summary"] != "[FIRING] api errors" || payload["source"] != "akavelog" {
+		t.Errorf("pagerduty trigger %v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "akavelog-a1" || resolve["payload"] != nil {
+		t.Errorf("pagerduty resolve %v", resolve)
+	}
+}
+
+func waitStatus(t *testing.T, n *Notifier, id uuid.UUID, done func(Status) bool) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _ := n.Status(id)
+		if done(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status %+v", st)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	rec := &recorder{codes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n := NewNotifier(GlobalRegistry)
+	n.backoff = time.Millisecond
+	defer n.Close()
+	hook := model.NotificationChannel{ID: uuid.New(), Name: "hook", Type: "webhook", Enabled: true,
+		Configuration: json.RawMessage(`{"url": "` + srv.URL + `"}`)}
+	off := model.NotificationChannel{ID: uuid.New(), Name: "off", Type: "webhook", Configuration: json.RawMessage(`{"url": "` + srv.URL + `"}`)}
+	if err := n.Load([]model.NotificationChannel{hook, off}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.Status(off.ID); ok {
+		t.Error("disabled channel running")
+	}
+	n.Notify([]string{"hook", "off", "unknown"}, firing())
+	st := waitStatus(t, n, hook.ID, func(st Status) bool { return st.Sent+st.Failed > 0 })
+	if st.Sent != 1 || len(rec.requests()) != 3 {
+		t.Errorf("after retries: %+v, %d requests", st, len(rec.requests()))
+	}
+
+	// Rejected requests are not retried.
+	rec.mu.Lock()
+	rec.codes, rec.bodies = []int{http.StatusBadRequest}, nil
+	rec.mu.Unlock()
+	n.Notify([]string{"hook"}, firing())
+	st = waitStatus(t, n, hook.ID, func(st Status) bool { return st.Failed > 0 })
+	if len(rec.requests()) != 1 || !strings.Contains(st.LastError, "400") {
+		t.Errorf("rejected: %+v, %d requests", st, len(rec.requests()))
+	}
+
+	// Test sends once, also over disabled channels.
+	rec.mu.Lock()
+	rec.bodies = nil
+	rec.mu.Unlock()
+	if err := n.Test(context.Background(), off); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := rec.requests(); len(reqs) != 1 || reqs[0]["test"] != true || reqs[0]["title"] != "[TEST] [FIRING] test notification" {
+		t.Errorf("test notification %v", reqs)
+	}
+}
+
+// fakeSMTP accepts one message without TLS or authentication and returns its DATA.
+func fakeSMTP(t *testing.T) (addr string, data <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		var msg strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				out <- msg.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestEmail(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	send(t, "email", Config{"host": host, "port": port, "tls": "none", "from": "akavelog <alerts@example.com>", "to": "oncall@example.com, ops@example.com"}, firing())
+	msg := <-data
+	for _, want := range []string{"Subject: [FIRING] api errors\r\n", "To: <oncall@example.com>, <ops@example.com>\r\n", "\r\nSeverity: critical\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if _, err := GlobalRegistry.Create("email", Config{"host": host, "from": "alerts@example.com", "to": "not an address"}); err == nil {
+		t.Error("invalid recipient accepted")
+	}
+}
@@ -0,0 +1,314 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// QueueSize is how many notifications each channel buffers; further ones are dropped and
+	// counted until the channel catches up.
+	QueueSize = 100
+	// DefaultMaxRetries is how often a failed send is retried unless max_retries is set.
+	DefaultMaxRetries = 3
+
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	sendTimeout = 30 * time.Second
+)
+
+// Status is the runtime view of one channel, as returned by the API.
+type Status struct {
+	Queued      int        `json:"queued"`
+	Sent        int64      `json:"sent"`
+	Failed      int64      `json:"failed"`  // notifications given up on after their retries
+	Dropped     int64      `json:"dropped"` // notifications that found the queue full
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// channel is a created channel with its templates and retry limit.
+type channel struct {
+	def     model.NotificationChannel
+	ch      Channel
+	tmpl    templates
+	retries int
+}
+
+// runner sends the notifications queued for one channel, in order.
+type runner struct {
+	channel
+	backoff time.Duration // first wait between retries
+	queue   chan Notification
+	stop    chan struct{}
+	done    chan struct{}
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	mu          sync.Mutex
+	lastSentAt  time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (r *runner) run() {
+	defer close(r.done)
+	for {
+		select {
+		case n := <-r.queue:
+			r.deliver(n, true)
+		case <-r.stop:
+			// What is queued gets one attempt each.
+			for {
+				select {
+				case n := <-r.queue:
+					r.deliver(n, false)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver renders and sends n, retrying failures that are not permanent with exponential
+// backoff while retry is set and the runner is not stopping.
+func (r *runner) deliver(n Notification, retry bool) {
+	m, err := r.tmpl.render(n)
+	backoff := r.backoff
+	for attempt := 0; m != nil; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = r.ch.Send(ctx, m)
+		cancel()
+		if err == nil || IsPermanent(err) || !retry || attempt >= r.retries {
+			break
+		}
+		select {
+		case <-r.stop:
+			retry = false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.failed.Add(1)
+		r.lastError, r.lastErrorAt = err.Error(), now
+		log.Printf("[notifications] %s: %s %s: %v", r.def.Name, n.Alert, n.State, err)
+		return
+	}
+	r.sent.Add(1)
+	r.lastSentAt = now
+}
+
+func (r *runner) enqueue(n Notification) {
+	select {
+	case r.queue <- n:
+	default:
+		r.dropped.Add(1)
+		log.Printf("[notifications] %s: queue full, dropped %s %s", r.def.Name, n.Alert, n.State)
+	}
+}
+
+func (r *runner) status() Status {
+	st := Status{Queued: len(r.queue), Sent: r.sent.Load(), Failed: r.failed.Load(), Dropped: r.dropped.Load()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.lastSentAt.IsZero() {
+		t := r.lastSentAt
+		st.LastSentAt = &t
+	}
+	if r.lastError != "" {
+		t := r.lastErrorAt
+		st.LastError, st.LastErrorAt = r.lastError, &t
+	}
+	return st
+}
+
+// shutdown stops the runner after it has tried what is queued.
+func (r *runner) shutdown() {
+	close(r.stop)
+	<-r.done
+}
+
+type runnerSet struct {
+	byName map[string]*runner
+	byID   map[uuid.UUID]*runner
+}
+
+// Notifier runs the enabled channels and queues notifications on the channels they name.
+// Load swaps the set atomically, like outputs.Dispatcher.
+type Notifier struct {
+	registry *Registry
+	backoff  time.Duration
+
+	mu      sync.Mutex // serializes Load and Close
+	current atomic.Pointer[runnerSet]
+}
+
+// NewNotifier returns a Notifier that creates channels from registry.
+func NewNotifier(registry *Registry) *Notifier {
+	n := &Notifier{registry: registry, backoff: minBackoff}
+	n.current.Store(&runnerSet{byName: map[string]*runner{}, byID: map[uuid.UUID]*runner{}})
+	return n
+}
+
+// DecodeConfig returns the configuration of c as a Config (empty when unset), with its
+// secret references resolved.
+func DecodeConfig(c model.NotificationChannel) (Config, error) {
+	cfg := make(Config)
+	if len(c.Configuration) > 0 {
+		if err := json.Unmarshal(c.Configuration, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return cfg.Resolved()
+}
+
+func (n *Notifier) create(c model.NotificationChannel) (channel, error) {
+	cfg, err := DecodeConfig(c)
+	if err != nil {
+		return channel{}, err
+	}
+	tmpl, err := parseTemplates(cfg)
+	if err != nil {
+		return channel{}, err
+	}
+	retries := DefaultMaxRetries
+	if v, ok := cfg.Int("max_retries"); ok {
+		if v < 0 || v > 10 {
+			return channel{}, fmt.Errorf("max_retries must be between 0 and 10")
+		}
+		retries = v
+	}
+	ch, err := n.registry.Create(c.Type, cfg)
+	if err != nil {
+		return channel{}, err
+	}
+	return channel{def: c, ch: ch, tmpl: tmpl, retries: retries}, nil
+}
+
+// Validate creates the channel of c without starting it and renders a test notification
+// with its templates.
+func (n *Notifier) Validate(c model.NotificationChannel) error {
+	ch, err := n.create(c)
+	if err != nil {
+		return err
+	}
+	_, err = ch.tmpl.render(testNotification(c))
+	return err
+}
+
+// Test sends a test notification over c once, whether or not c is enabled, and returns the
+// error of the attempt.
+func (n *Notifier) Test(ctx context.Context, c model.NotificationChannel) error {
+	ch, err := n.create(c)
+	if err != nil {
+		return err
+	}
+	m, err := ch.tmpl.render(testNotification(c))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return ch.ch.Send(ctx, m)
+}
+
+func testNotification(c model.NotificationChannel) Notification {
+	return Notification{
+		AlertID:   "test-" + c.ID.String(),
+		Alert:     "test notification",
+		Severity:  model.SeverityInfo,
+		State:     model.AlertFiring,
+		Condition: "count > 0 in 5m",
+		Value:     1,
+		Message:   "Test notification of channel " + c.Name + " from akavelog.",
+		At:        time.Now().UTC(),
+		Test:      true,
+	}
+}
+
+// Load starts the enabled channels in list and stops the others. Channels whose definition
+// is unchanged (same UpdatedAt) keep running with their queue and counters; changed ones are
+// replaced after their queue is tried. A channel that fails to create is skipped and its
+// error returned.
+func (n *Notifier) Load(list []model.NotificationChannel) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prev := n.current.Load()
+	next := &runnerSet{byName: make(map[string]*runner, len(list)), byID: make(map[uuid.UUID]*runner, len(list))}
+	var errs []error
+	for _, c := range list {
+		if !c.Enabled {
+			continue
+		}
+		r, ok := prev.byID[c.ID]
+		if !ok || !r.def.UpdatedAt.Equal(c.UpdatedAt) {
+			ch, err := n.create(c)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("notification channel %q: %w", c.Name, err))
+				continue
+			}
+			r = &runner{channel: ch, backoff: n.backoff, queue: make(chan Notification, QueueSize), stop: make(chan struct{}), done: make(chan struct{})}
+			go r.run()
+		}
+		next.byID[c.ID] = r
+		next.byName[c.Name] = r
+	}
+	n.current.Store(next)
+	for id, r := range prev.byID {
+		if next.byID[id] != r {
+			r.shutdown()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notify queues notif on each running channel in names. Names of channels that are not
+// running are logged and skipped. It never blocks.
+func (n *Notifier) Notify(names []string, notif Notification) {
+	set := n.current.Load()
+	for _, name := range names {
+		r, ok := set.byName[name]
+		if !ok {
+			log.Printf("[notifications] %s %s: channel %q is not running", notif.Alert, notif.State, name)
+			continue
+		}
+		r.enqueue(notif)
+	}
+}
+
+// Status reports the counters of a running channel. ok is false when it is not running
+// (unknown, disabled or failed to create).
+func (n *Notifier) Status(id uuid.UUID) (Status, bool) {
+	r, ok := n.current.Load().byID[id]
+	if !ok {
+		return Status{}, false
+	}
+	return r.status(), true
+}
+
+// Close tries what is queued once and stops every channel, for use on shutdown.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prev := n.current.Swap(&runnerSet{byName: map[string]*runner{}, byID: map[uuid.UUID]*runner{}})
+	for _, r := range prev.byID {
+		r.shutdown()
+	}
+}
@@ -0,0 +1,124 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// PagerDutyEventsURL is the Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// maxSummary is the longest summary PagerDuty accepts.
+const maxSummary = 1024
+
+func init() {
+	GlobalRegistry.Register(&pagerDutyFactory{})
+}
+
+// pagerDutyFactory creates PagerDuty Events API v2 channels. Registers as "pagerduty".
+type pagerDutyFactory struct{}
+
+func (f *pagerDutyFactory) Name() string { return "pagerduty" }
+
+func (f *pagerDutyFactory) ConfigSpec() TypeInfo {
+	return TypeInfo{
+		Type:        "pagerduty",
+		Description: "Triggers a PagerDuty incident through the Events API v2 when an alert fires and resolves it when the alert resolves. Incidents are deduplicated per alert; the title is the summary.",
+		Fields: []ConfigField{
+			{Name: "routing_key", Type: "string", Required: true, Description: "Integration key of an Events API v2 integration", Example: "R0123456789ABCDEF0123456789ABCDE"},
+			{Name: "source", Type: "string", Required: false, Description: "Source of the events (default akavelog)", Example: "akavelog-prod"},
+			{Name: "url", Type: "string", Required: false, Description: "Events endpoint (default " + PagerDutyEventsURL + ")", Example: "https://events.eu.pagerduty.com/v2/enqueue"},
+			{Name: "timeout", Type: "string", Required: false, Description: "Timeout of one request (default 10s)", Example: "10s"},
+		},
+	}
+}
+
+func (f *pagerDutyFactory) Create(cfg Config) (Channel, error) {
+	c := &pagerDutyChannel{url: PagerDutyEventsURL, routingKey: str(cfg, "routing_key"), source: str(cfg, "source")}
+	if c.routingKey == "" {
+		return nil, fmt.Errorf("routing_key is required")
+	}
+	if c.source == "" {
+		c.source = "akavelog"
+	}
+	if str(cfg, "url") != "" {
+		u, err := httpURL(cfg, "url")
+		if err != nil {
+			return nil, err
+		}
+		c.url = u
+	}
+	t, err := timeout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.client = &http.Client{Timeout: t}
+	return c, nil
+}
+
+type pagerDutyChannel struct {
+	url        string
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger or resolve
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+func (c *pagerDutyChannel) Send(ctx context.Context, m *Message) error {
+	ev := pagerDutyEvent{RoutingKey: c.routingKey, EventAction: "trigger", DedupKey: "akavelog-" + m.AlertID}
+	if m.State == model.AlertResolved {
+		ev.EventAction = "resolve"
+	} else {
+		summary := m.Title
+		if len(summary) > maxSummary {
+			summary = summary[:maxSummary]
+		}
+		ev.Payload = &pagerDutyPayload{
+			Summary:  summary,
+			Source:   c.source,
+			Severity: pagerDutySeverity(m.Severity),
+			CustomDetails: map[string]any{
+				"body":      m.Body,
+				"condition": m.Condition,
+				"value":     m.Value,
+			},
+		}
+		if !m.At.IsZero() {
+			ev.Payload.Timestamp = m.At.UTC().Format(time.RFC3339)
+		}
+		if m.Query != "" {
+			ev.Payload.CustomDetails["query"] = m.Query
+		}
+		if m.ProjectID != "" {
+			ev.Payload.CustomDetails["project_id"] = m.ProjectID
+		}
+	}
+	return postJSON(ctx, c.client, c.url, nil, ev)
+}
+
+// pagerDutySeverity maps an alert severity onto PagerDuty's critical, error, warning or info.
+func pagerDutySeverity(s string) string {
+	switch s {
+	case model.SeverityCritical, model.SeverityInfo:
+		return s
+	}
+	return model.SeverityWarning
+}
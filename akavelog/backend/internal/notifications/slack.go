@@ -0,0 +1,83 @@
+package notifications
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func init() {
+	GlobalRegistry.Register(&slackFactory{})
+}
+
+// slackFactory creates Slack incoming-webhook channels. Registers as "slack".
+type slackFactory struct{}
+
+func (f *slackFactory) Name() string { return "slack" }
+
+func (f *slackFactory) ConfigSpec() TypeInfo {
+	return TypeInfo{
+		Type:        "slack",
+		Description: "Posts to a Slack incoming webhook: the title as the text and the body as an attachment colored by severity, green when resolved.",
+		Fields: []ConfigField{
+			{Name: "webhook_url", Type: "string", Required: true, Description: "Incoming webhook URL", Example: "https://hooks.slack.com/services/T000/B000/XXXX"},
+			{Name: "channel", Type: "string", Required: false, Description: "Channel to post to instead of the webhook's own, where allowed", Example: "#alerts"},
+			{Name: "username", Type: "string", Required: false, Description: "Name to post as, where allowed", Example: "akavelog"},
+			{Name: "timeout", Type: "string", Required: false, Description: "Timeout of one request (default 10s)", Example: "10s"},
+		},
+	}
+}
+
+func (f *slackFactory) Create(cfg Config) (Channel, error) {
+	u, err := httpURL(cfg, "webhook_url")
+	if err != nil {
+		return nil, err
+	}
+	t, err := timeout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &slackChannel{url: u, channel: str(cfg, "channel"), username: str(cfg, "username"), client: &http.Client{Timeout: t}}, nil
+}
+
+type slackChannel struct {
+	url      string
+	channel  string
+	username string
+	client   *http.Client
+}
+
+type slackAttachment struct {
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+	Fallback string `json:"fallback"`
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+func (c *slackChannel) Send(ctx context.Context, m *Message) error {
+	p := slackPayload{Text: m.Title, Channel: c.channel, Username: c.username}
+	if m.Body != "" {
+		p.Attachments = []slackAttachment{{Color: slackColor(m), Text: m.Body, Fallback: m.Body}}
+	}
+	return postJSON(ctx, c.client, c.url, nil, p)
+}
+
+func slackColor(m *Message) string {
+	if m.State == model.AlertResolved {
+		return "#2eb886"
+	}
+	switch m.Severity {
+	case model.SeverityCritical:
+		return "#d00000"
+	case model.SeverityInfo:
+		return "#439fe0"
+	}
+	return "#daa038"
+}
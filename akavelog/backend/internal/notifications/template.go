@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Default templates of channels that set none.
+const (
+	DefaultTitle = `{{if .Test}}[TEST] {{end}}[{{upper .State}}] {{.Alert}}`
+	DefaultBody  = `{{.Message}}
+Severity: {{.Severity}}
+Condition: {{.Condition}}{{with .Query}}
+Query: {{.}}{{end}}{{with .ProjectID}}
+Project: {{.}}{{end}}
+At: {{.At.UTC.Format "2006-01-02T15:04:05Z07:00"}}`
+)
+
+var templateFuncs = template.FuncMap{
+	"upper": func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+	"lower": func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+}
+
+// templates render the title and body of a channel's messages.
+type templates struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// parseTemplates parses the title_template and body_template of cfg, or the defaults.
+func parseTemplates(cfg Config) (templates, error) {
+	parse := func(key, def string) (*template.Template, error) {
+		src, _ := cfg[key].(string)
+		if strings.TrimSpace(src) == "" {
+			src = def
+		}
+		t, err := template.New(key).Funcs(templateFuncs).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return t, nil
+	}
+	var t templates
+	var err error
+	if t.title, err = parse("title_template", DefaultTitle); err != nil {
+		return t, err
+	}
+	t.body, err = parse("body_template", DefaultBody)
+	return t, err
+}
+
+// render returns n as a message with its title and body rendered.
+func (t templates) render(n Notification) (*Message, error) {
+	m := &Message{Notification: n}
+	var buf bytes.Buffer
+	if err := t.title.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("title_template: %w", err)
+	}
+	m.Title = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := t.body.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("body_template: %w", err)
+	}
+	m.Body = strings.TrimSpace(buf.String())
+	return m, nil
+}
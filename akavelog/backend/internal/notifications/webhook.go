@@ -0,0 +1,64 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	GlobalRegistry.Register(&webhookFactory{})
+}
+
+// webhookFactory creates generic webhook channels. Registers as "webhook".
+type webhookFactory struct{}
+
+func (f *webhookFactory) Name() string { return "webhook" }
+
+func (f *webhookFactory) ConfigSpec() TypeInfo {
+	return TypeInfo{
+		Type:        "webhook",
+		Description: "POSTs each notification as JSON to a URL: the alert's fields, state, value and message with the rendered title and body.",
+		Fields: []ConfigField{
+			{Name: "url", Type: "string", Required: true, Description: "http or https URL to POST to", Example: "https://hooks.internal/alerts"},
+			{Name: "headers", Type: "object", Required: false, Description: "Extra request headers, e.g. an Authorization token", Example: `{"Authorization": "Bearer <token>"}`},
+			{Name: "timeout", Type: "string", Required: false, Description: "Timeout of one request (default 10s)", Example: "10s"},
+		},
+	}
+}
+
+func (f *webhookFactory) Create(cfg Config) (Channel, error) {
+	u, err := httpURL(cfg, "url")
+	if err != nil {
+		return nil, err
+	}
+	t, err := timeout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &webhookChannel{url: u, headers: map[string]string{}, client: &http.Client{Timeout: t}}
+	if v, ok := cfg["headers"]; ok && v != nil {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("headers must be an object of strings")
+		}
+		for k, hv := range m {
+			s, ok := hv.(string)
+			if !ok {
+				return nil, fmt.Errorf("header %q must be a string", k)
+			}
+			c.headers[k] = s
+		}
+	}
+	return c, nil
+}
+
+type webhookChannel struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (c *webhookChannel) Send(ctx context.Context, m *Message) error {
+	return postJSON(ctx, c.client, c.url, c.headers, m)
+}
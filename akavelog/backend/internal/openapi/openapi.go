@@ -0,0 +1,379 @@
+// Package openapi describes the management and query API as an OpenAPI 3 document, for
+// GET /openapi.json and the Swagger UI of GET /docs.
+//
+// The document is generated from the routes registered on the Echo server, so a new route is
+// described without more work: its operation ID is the name of its handler method, its
+// summary that name in words, its tag the first segment of its path. Docs adds what the
+// routes do not say, such as query parameters and request bodies.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Version is the version of the OpenAPI specification documents are written in.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag groups operations; there is one per first path segment.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations of a path by lower-case method.
+type PathItem map[string]*Operation
+
+// Operation is one route.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"` // empty: no authentication
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation.
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response is one response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body of one content type.
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, as far as this API needs one.
+type Schema struct {
+	Ref                  string            `json:"$ref,omitempty"`
+	Type                 string            `json:"type,omitempty"`
+	Format               string            `json:"format,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Enum                 []string          `json:"enum,omitempty"`
+	Default              any               `json:"default,omitempty"`
+	Items                *Schema           `json:"items,omitempty"`
+	Properties           map[string]Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema           `json:"additionalProperties,omitempty"`
+}
+
+// Components holds the schemas and security schemes operations refer to.
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way to authenticate.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Doc is what a route's registration does not say about it. Every field is optional.
+type Doc struct {
+	Summary     string
+	Description string
+	Query       []Parameter // in is set to query
+	Headers     []Parameter // in is set to header
+	Body        string      // description of a JSON request body; empty for none
+	Paged       bool        // a list taking limit, offset, sort and q
+	Public      bool        // needs no authentication
+}
+
+// String, Integer and Boolean are the schemas of simple parameters.
+var (
+	String  = Schema{Type: "string"}
+	Integer = Schema{Type: "integer"}
+	Boolean = Schema{Type: "boolean"}
+)
+
+// Query returns a query parameter.
+func Query(name string, schema Schema, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// pagedParams are the parameters of every paged list.
+var pagedParams = []Parameter{
+	Query("limit", Schema{Type: "integer", Default: 100}, "Items per page, at most 1000"),
+	Query("offset", Schema{Type: "integer", Default: 0}, "Items to skip"),
+	Query("sort", String, "Field to sort by, prefixed with - for descending order"),
+	Query("q", String, "Keeps the items whose name or title contains it, ignoring case"),
+}
+
+// Generate describes routes. docs holds the Doc of a route by "METHOD /path", with the path
+// as registered (e.g. "GET /inputs/:id"). Routes registered for every method, as e.Any does,
+// are described only for the methods docs names.
+func Generate(info Info, routes []*echo.Route, docs map[string]Doc) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]Schema{
+				"Response": {Type: "object", Description: "Envelope of every successful response", Properties: map[string]Schema{
+					"data":    {Description: "The result; its shape depends on the operation"},
+					"status":  Integer,
+					"message": String,
+					"path":    String,
+				}},
+				"Error": {Type: "object", Description: "Envelope of every error response", Properties: map[string]Schema{
+					"message":    {Type: "string", Description: "What went wrong"},
+					"error":      {Type: "string", Description: "Details"},
+					"path":       String,
+					"status":     Integer,
+					"request_id": {Type: "string", Description: "X-Request-ID of the response, to quote when reporting the error"},
+				}},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				"apiKey":      {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "An API key"},
+				"bearerToken": {Type: "http", Scheme: "bearer", Description: "An API key or a user's session token from POST /auth/login"},
+			},
+		},
+		Security: []map[string][]string{{"apiKey": {}}, {"bearerToken": {}}},
+	}
+
+	ids := operationIDs(routes)
+	tags := map[string]bool{}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		method := r.Method
+		if method == echo.RouteNotFound || seen[method+" "+r.Path] {
+			continue
+		}
+		seen[method+" "+r.Path] = true
+		d, ok := docs[method+" "+r.Path]
+		if !ok && isAny(routes, r.Path) {
+			continue
+		}
+		op := operation(method, r, ids[r], d)
+		tags[op.Tags[0]] = true
+		path := openAPIPath(r.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	}
+	for name := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(a, b int) bool { return doc.Tags[a].Name < doc.Tags[b].Name })
+	return doc
+}
+
+// isAny reports whether path is registered for every method, as e.Any does.
+func isAny(routes []*echo.Route, path string) bool {
+	n := 0
+	for _, r := range routes {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n > 5
+}
+
+func operation(method string, r *echo.Route, id string, d Doc) *Operation {
+	op := &Operation{
+		OperationID: id,
+		Summary:     d.Summary,
+		Description: d.Description,
+		Tags:        []string{tag(r.Path)},
+		Responses: map[string]Response{
+			"200": {Description: "Success", Content: map[string]MediaType{
+				echo.MIMEApplicationJSON: {Schema: Schema{Ref: "#/components/schemas/Response"}},
+			}},
+			"default": {Description: "Error", Content: map[string]MediaType{
+				echo.MIMEApplicationJSON: {Schema: Schema{Ref: "#/components/schemas/Error"}},
+			}},
+		},
+	}
+	if op.Summary == "" {
+		if _, name := handlerName(r.Name); name != "" {
+			op.Summary = words(name)
+		} else {
+			op.Summary = method + " " + r.Path
+		}
+	}
+	for _, seg := range strings.Split(r.Path, "/") {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			op.Parameters = append(op.Parameters, Parameter{Name: seg[1:], In: "path", Required: true, Schema: String})
+		case seg == "*":
+			op.Parameters = append(op.Parameters, Parameter{Name: "path", In: "path", Required: true, Schema: String, Description: "Rest of the path"})
+		}
+	}
+	if d.Paged {
+		op.Parameters = append(op.Parameters, pagedParams...)
+	}
+	for _, p := range d.Query {
+		p.In = "query"
+		op.Parameters = append(op.Parameters, p)
+	}
+	for _, p := range d.Headers {
+		p.In = "header"
+		op.Parameters = append(op.Parameters, p)
+	}
+	if d.Body != "" {
+		op.RequestBody = &RequestBody{Description: d.Body, Required: true, Content: map[string]MediaType{
+			echo.MIMEApplicationJSON: {Schema: Schema{Type: "object"}},
+		}}
+	}
+	if d.Public {
+		op.Security = &[]map[string][]string{}
+	}
+	return op
+}
+
+// operationIDs names the operations of routes: by their handler method, or by their
+// handler type and method when methods of several types share a name ("InputListTypes",
+// "OutputListTypes"), or by method and path for function literals ("getLogsStatus").
+func operationIDs(routes []*echo.Route) map[*echo.Route]string {
+	types := map[string]map[string]bool{}
+	for _, r := range routes {
+		if typ, name := handlerName(r.Name); name != "" {
+			if types[name] == nil {
+				types[name] = map[string]bool{}
+			}
+			types[name][typ] = true
+		}
+	}
+	ids := map[*echo.Route]string{}
+	for _, r := range routes {
+		typ, name := handlerName(r.Name)
+		switch {
+		case name == "":
+			ids[r] = strings.ToLower(r.Method) + pathWords(r.Path)
+		case len(types[name]) > 1:
+			ids[r] = strings.TrimSuffix(typ, "Handler") + name
+		default:
+			ids[r] = name
+		}
+	}
+	return ids
+}
+
+// handlerFunc matches the name Echo gives a route of a method value, e.g.
+// "github.com/akave-ai/akavelog/internal/handler.(*InputHandler).ListInputs-fm", or
+// "...handler.InputHandler.ListInputs-fm" for a value receiver.
+var handlerFunc = regexp.MustCompile(`\.(?:\(\*)?([A-Za-z0-9_]+)\)?\.([A-Z][A-Za-z0-9_]*)-fm$`)
+
+// handlerName returns the type and method a route is handled by, or "" for a function literal.
+func handlerName(name string) (typ, method string) {
+	if m := handlerFunc.FindStringSubmatch(name); m != nil {
+		return m[1], m[2]
+	}
+	return "", ""
+}
+
+// words spells an identifier out: "ListInputs" is "List inputs", "GetAPIKey" "Get API key".
+func words(id string) string {
+	var out []string
+	runes := []rune(id)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !(unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) ||
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+			continue
+		}
+		w := string(runes[start:i])
+		if len(out) > 0 && !isAcronym(w) {
+			w = strings.ToLower(w)
+		}
+		out = append(out, w)
+		start = i
+	}
+	return strings.Join(out, " ")
+}
+
+func isAcronym(w string) bool {
+	return len(w) > 1 && strings.ToUpper(w) == w
+}
+
+// pathWords turns the path of a function literal into a name, "/logs/status" into "LogsStatus".
+func pathWords(path string) string {
+	var b strings.Builder
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		if strings.HasPrefix(seg, ":") || seg == "*" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return b.String()
+}
+
+func tag(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if seg == "" {
+		return "root"
+	}
+	return strings.TrimSuffix(seg, ".json")
+}
+
+// openAPIPath writes Echo's path parameters the OpenAPI way: /inputs/:id is /inputs/{id}.
+func openAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			segs[i] = "{" + seg[1:] + "}"
+		case seg == "*":
+			segs[i] = "{path}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+//go:embed docs.html
+var docsHTML []byte
+
+// UI serves the Swagger UI page of GET /docs, showing the document at specURL. The page is
+// embedded in the binary; the browser loads the Swagger UI scripts it uses from unpkg.com.
+func UI(specURL string) echo.HandlerFunc {
+	page := strings.ReplaceAll(string(docsHTML), "{{SPEC_URL}}", specURL)
+	return func(c echo.Context) error {
+		return c.HTML(http.StatusOK, page)
+	}
+}
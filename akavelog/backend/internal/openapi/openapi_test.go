@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type inputHandler struct{}
+
+func (inputHandler) ListInputs(echo.Context) error  { return nil }
+func (inputHandler) UpdateInput(echo.Context) error { return nil }
+func (inputHandler) ListTypes(echo.Context) error   { return nil }
+
+type outputHandler struct{}
+
+func (*outputHandler) ListTypes(echo.Context) error { return nil }
+func (*outputHandler) GetAPIKey(echo.Context) error { return nil }
+
+func TestGenerate(t *testing.T) {
+	e := echo.New()
+	var in inputHandler
+	out := &outputHandler{}
+	e.GET("/inputs", in.ListInputs)
+	e.PUT("/inputs/:id", in.UpdateInput)
+	e.GET("/inputs/types", in.ListTypes)
+	e.GET("/outputs/types", out.ListTypes)
+	e.GET("/api-keys/:id", out.GetAPIKey)
+	e.GET("/logs/status", func(echo.Context) error { return nil })
+	e.POST("/auth/login", func(echo.Context) error { return nil })
+	e.Any("/ingest/*", func(echo.Context) error { return nil })
+
+	doc := Generate(Info{Title: "test", Version: "1"}, e.Routes(), map[string]Doc{
+		"GET /inputs":      {Paged: true, Query: []Parameter{Query("type", String, "Input type")}},
+		"PUT /inputs/:id":  {Body: "The input", Headers: []Parameter{{Name: "If-Match", Schema: String}}},
+		"POST /auth/login": {Summary: "Log in", Public: true, Body: "Email and password"},
+		"POST /ingest/*":   {Summary: "Ingest entries"},
+	})
+
+	list := doc.Paths["/inputs"]["get"]
+	if list == nil || list.OperationID != "ListInputs" || list.Summary != "List inputs" || list.Tags[0] != "inputs" {
+		t.Fatalf("GET /inputs: %+v", list)
+	}
+	if len(list.Parameters) != 5 || list.Parameters[0].Name != "limit" || list.Parameters[4].Name != "type" || list.Parameters[4].In != "query" {
+		t.Errorf("GET /inputs parameters: %+v", list.Parameters)
+	}
+	update := doc.Paths["/inputs/{id}"]["put"]
+	if update == nil || update.RequestBody == nil || len(update.Parameters) != 2 ||
+		update.Parameters[0].In != "path" || update.Parameters[1].In != "header" {
+		t.Errorf("PUT /inputs/{id}: %+v", update)
+	}
+	if op := doc.Paths["/inputs/types"]["get"]; op == nil || op.OperationID != "inputListTypes" {
+		t.Errorf("GET /inputs/types: %+v", op)
+	}
+	if op := doc.Paths["/api-keys/{id}"]["get"]; op == nil || op.Summary != "Get API key" || op.Tags[0] != "api-keys" {
+		t.Errorf("GET /api-keys/{id}: %+v", op)
+	}
+	if op := doc.Paths["/logs/status"]["get"]; op == nil || op.OperationID != "getLogsStatus" {
+		t.Errorf("GET /logs/status: %+v", op)
+	}
+	if op := doc.Paths["/auth/login"]["post"]; op == nil || op.Security == nil || len(*op.Security) != 0 || op.Summary != "Log in" {
+		t.Errorf("POST /auth/login: %+v", op)
+	}
+	if ingest := doc.Paths["/ingest/{path}"]; len(ingest) != 1 || ingest["post"] == nil {
+		t.Errorf("/ingest/{path}: %v", ingest)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(raw)
+	if !strings.Contains(s, `"openapi":"3.0.3"`) || !strings.Contains(s, `"security":[]`) || strings.Contains(s, `"security":null`) {
+		t.Errorf("document: %s", s)
+	}
+}
+
+func TestWords(t *testing.T) {
+	for id, want := range map[string]string{
+		"ListInputs":           "List inputs",
+		"GetAPIKey":            "Get API key",
+		"UploadLookupTableCSV": "Upload lookup table CSV",
+		"Run":                  "Run",
+		"ListInputMetrics":     "List input metrics",
+	} {
+		if got := words(id); got != want {
+			t.Errorf("words(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestUI(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/docs", nil), rec)
+	if err := UI("/openapi.json")(c); err != nil {
+		t.Fatal(err)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "url: '/openapi.json'") || !strings.Contains(body, "SwaggerUIBundle") {
+		t.Errorf("page: %s", body)
+	}
+}
@@ -0,0 +1,247 @@
+// Package parquet writes and reads the subset of Apache Parquet the batcher needs: flat tables
+// of optional string and timestamp columns, PLAIN-encoded in Snappy-compressed v1 data pages,
+// one row group per file. DuckDB, Trino, Spark and other Parquet engines read these files as is.
+//
+// A file is the magic "PAR1", one column chunk per column, the Thrift-encoded FileMetaData,
+// its 4-byte little-endian length and "PAR1" again. Read understands the files Write produces
+// (and the same layout from other writers); dictionary-encoded and v2 pages are not supported.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Kind is the type of a column.
+type Kind int
+
+const (
+	String    Kind = iota // UTF-8 string (BYTE_ARRAY, UTF8)
+	Timestamp             // microseconds since the Unix epoch, UTC (INT64, TIMESTAMP_MICROS)
+)
+
+// Column is one optional column of a table. Values are per row: Strings for String columns and
+// Times for Timestamp columns. Null marks rows without a value; nil means none are null.
+type Column struct {
+	Name    string
+	Kind    Kind
+	Null    []bool
+	Strings []string
+	Times   []int64
+}
+
+func (c *Column) null(row int) bool { return c.Null != nil && c.Null[row] }
+
+// Parquet enum values used here.
+const (
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repRequired = 0
+	repOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+
+	pageData = 0
+)
+
+var magic = []byte("PAR1")
+
+// ErrNotParquet is returned by Read for data without the Parquet magic bytes.
+var ErrNotParquet = errors.New("parquet: not a parquet file")
+
+// IsParquet reports whether data starts with the Parquet magic bytes.
+func IsParquet(data []byte) bool { return bytes.HasPrefix(data, magic) }
+
+// Write encodes rows rows of cols as a Parquet file.
+func Write(cols []Column, rows int) ([]byte, error) {
+	for i := range cols {
+		c := &cols[i]
+		n := len(c.Strings)
+		if c.Kind == Timestamp {
+			n = len(c.Times)
+		}
+		if n != rows || (c.Null != nil && len(c.Null) != rows) {
+			return nil, fmt.Errorf("parquet: column %s has %d values for %d rows", c.Name, n, rows)
+		}
+	}
+
+	out := append([]byte(nil), magic...)
+	chunks := make([]chunkMeta, len(cols))
+	for i := range cols {
+		c := &cols[i]
+		body := levels(c, rows)
+		nulls := int64(0)
+		var minT, maxT int64
+		seen := false
+		for row := range rows {
+			if c.null(row) {
+				nulls++
+				continue
+			}
+			switch c.Kind {
+			case String:
+				body = binary.LittleEndian.AppendUint32(body, uint32(len(c.Strings[row])))
+				body = append(body, c.Strings[row]...)
+			case Timestamp:
+				t := c.Times[row]
+				body = binary.LittleEndian.AppendUint64(body, uint64(t))
+				if !seen || t < minT {
+					minT = t
+				}
+				if !seen || t > maxT {
+					maxT = t
+				}
+				seen = true
+			}
+		}
+		compressed := snappy.Encode(nil, body)
+
+		var h tWriter
+		h.begin()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(body)))
+		h.i32(3, int32(len(compressed)))
+		h.structField(5)
+		h.i32(1, int32(rows))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+
+		chunks[i] = chunkMeta{
+			offset:       int64(len(out)),
+			uncompressed: int64(len(h.buf) + len(body)),
+			compressed:   int64(len(h.buf) + len(compressed)),
+			nulls:        nulls,
+			hasMinMax:    seen,
+			min:          minT,
+			max:          maxT,
+		}
+		out = append(out, h.buf...)
+		out = append(out, compressed...)
+	}
+
+	footer := fileMetaData(cols, chunks, rows)
+	out = append(out, footer...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(footer)))
+	return append(out, magic...), nil
+}
+
+// chunkMeta is what the footer records about a written column chunk.
+type chunkMeta struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+	nulls        int64
+	hasMinMax    bool
+	min, max     int64
+}
+
+// levels returns the definition levels of c (1 for a value, 0 for null) as a 4-byte length
+// followed by bit-packed runs of the RLE/bit-packing hybrid encoding, bit width 1.
+func levels(c *Column, rows int) []byte {
+	groups := (rows + 7) / 8
+	enc := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	for g := range groups {
+		var b byte
+		for bit := range 8 {
+			if row := g*8 + bit; row < rows && !c.null(row) {
+				b |= 1 << bit
+			}
+		}
+		enc = append(enc, b)
+	}
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(enc)))
+	return append(out, enc...)
+}
+
+func fileMetaData(cols []Column, chunks []chunkMeta, rows int) []byte {
+	var w tWriter
+	w.begin()
+	w.i32(1, 1) // version
+
+	w.list(2, tStruct, len(cols)+1)
+	w.begin()
+	w.str(4, "schema")
+	w.i32(5, int32(len(cols)))
+	w.end()
+	for _, c := range cols {
+		w.begin()
+		w.i32(1, physicalType(c.Kind))
+		w.i32(3, repOptional)
+		w.str(4, c.Name)
+		w.i32(6, convertedType(c.Kind))
+		w.end()
+	}
+
+	w.i64(3, int64(rows))
+
+	var total int64
+	for _, ch := range chunks {
+		total += ch.uncompressed
+	}
+	w.list(4, tStruct, 1)
+	w.begin()
+	w.list(1, tStruct, len(cols))
+	for i, c := range cols {
+		ch := chunks[i]
+		w.begin()
+		w.i64(2, ch.offset)
+		w.structField(3)
+		w.i32(1, physicalType(c.Kind))
+		w.list(2, tI32, 2)
+		w.zigzag(encodingPlain)
+		w.zigzag(encodingRLE)
+		w.list(3, tBinary, 1)
+		w.varint(uint64(len(c.Name)))
+		w.buf = append(w.buf, c.Name...)
+		w.i32(4, codecSnappy)
+		w.i64(5, int64(rows))
+		w.i64(6, ch.uncompressed)
+		w.i64(7, ch.compressed)
+		w.i64(9, ch.offset)
+		w.structField(12)
+		w.i64(3, ch.nulls)
+		if ch.hasMinMax {
+			w.binary(5, binary.LittleEndian.AppendUint64(nil, uint64(ch.max)))
+			w.binary(6, binary.LittleEndian.AppendUint64(nil, uint64(ch.min)))
+		}
+		w.end()
+		w.end()
+		w.end()
+	}
+	w.i64(2, total)
+	w.i64(3, int64(rows))
+	w.end()
+
+	w.str(6, "akavelog")
+	w.end()
+	return w.buf
+}
+
+func physicalType(k Kind) int32 {
+	if k == Timestamp {
+		return typeInt64
+	}
+	return typeByteArray
+}
+
+func convertedType(k Kind) int32 {
+	if k == Timestamp {
+		return convertedTimestampMicros
+	}
+	return convertedUTF8
+}
@@ -0,0 +1,97 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	const rows = 20
+	ts := Column{Name: "timestamp", Kind: Timestamp, Null: make([]bool, rows), Times: make([]int64, rows)}
+	msg := Column{Name: "message", Kind: String, Strings: make([]string, rows)}
+	tag := Column{Name: "tag_env", Kind: String, Null: make([]bool, rows), Strings: make([]string, rows)}
+	for i := range rows {
+		ts.Times[i] = 1_700_000_000_000_000 + int64(i)
+		ts.Null[i] = i == 3
+		msg.Strings[i] = "message " + strconv.Itoa(i)
+		tag.Null[i] = i%3 != 0
+		if !tag.Null[i] {
+			tag.Strings[i] = "prod"
+		}
+	}
+	data, err := Write([]Column{ts, msg, tag}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, magic) || !bytes.HasSuffix(data, magic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	if n := binary.LittleEndian.Uint32(data[len(data)-8:]); int(n) >= len(data) {
+		t.Fatalf("footer length %d out of range", n)
+	}
+
+	cols, n, err := Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != rows || len(cols) != 3 {
+		t.Fatalf("read %d rows, %d columns", n, len(cols))
+	}
+	if cols[0].Name != "timestamp" || cols[0].Kind != Timestamp || cols[2].Name != "tag_env" {
+		t.Fatalf("schema = %+v", cols)
+	}
+	for i := range rows {
+		if cols[0].Null[i] != (i == 3) || (i != 3 && cols[0].Times[i] != ts.Times[i]) {
+			t.Errorf("row %d: timestamp %d (null %v)", i, cols[0].Times[i], cols[0].Null[i])
+		}
+		if cols[1].Null[i] || cols[1].Strings[i] != msg.Strings[i] {
+			t.Errorf("row %d: message %q", i, cols[1].Strings[i])
+		}
+		if cols[2].Null[i] != tag.Null[i] || cols[2].Strings[i] != tag.Strings[i] {
+			t.Errorf("row %d: tag %q (null %v)", i, cols[2].Strings[i], cols[2].Null[i])
+		}
+	}
+}
+
+func TestWriteRejectsShortColumns(t *testing.T) {
+	_, err := Write([]Column{{Name: "message", Kind: String, Strings: []string{"a"}}}, 2)
+	if err == nil {
+		t.Fatal("expected error for a column shorter than rows")
+	}
+}
+
+func TestReadRejectsOtherData(t *testing.T) {
+	if _, _, err := Read([]byte(`[{"message":"x"}]`)); err != ErrNotParquet {
+		t.Fatalf("err = %v, want ErrNotParquet", err)
+	}
+}
+
+func TestThriftFieldIDs(t *testing.T) {
+	// Field deltas over 15 and going backwards use the long form.
+	var w tWriter
+	w.begin()
+	w.i32(1, -2)
+	w.i64(20, 300)
+	w.str(4, "x")
+	w.end()
+	got := map[int16]int64{}
+	r := &tReader{buf: w.buf}
+	err := r.readStruct(func(id int16, typ byte) (bool, error) {
+		if typ == tBinary {
+			b, err := r.binary()
+			got[id] = int64(len(b))
+			return true, err
+		}
+		v, err := r.zigzag()
+		got[id] = v
+		return true, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[1] != -2 || got[20] != 300 || got[4] != 1 {
+		t.Fatalf("fields = %v", got)
+	}
+}
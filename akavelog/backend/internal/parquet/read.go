@@ -0,0 +1,330 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// schemaLeaf is a column as described by the footer's schema.
+type schemaLeaf struct {
+	name string
+	typ  int32
+	rep  int32
+}
+
+// chunkInfo locates a column chunk.
+type chunkInfo struct {
+	codec     int32
+	numValues int64
+	offset    int64
+}
+
+// Read decodes a Parquet file of flat string and INT64 timestamp columns and returns its
+// columns and row count.
+func Read(data []byte) ([]Column, int, error) {
+	if len(data) < 12 || !IsParquet(data) || !IsParquet(data[len(data)-4:]) {
+		return nil, 0, ErrNotParquet
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if n > len(data)-12 {
+		return nil, 0, errTruncated
+	}
+	r := &tReader{buf: data[len(data)-8-n : len(data)-8]}
+
+	var leaves []schemaLeaf
+	var rows int64
+	var groups [][]chunkInfo
+	err := r.readStruct(func(id int16, typ byte) (bool, error) {
+		switch {
+		case id == 2 && typ == tList:
+			_, n, err := r.listHeader()
+			if err != nil {
+				return true, err
+			}
+			for i := range n {
+				leaf, err := readSchemaElement(r)
+				if err != nil {
+					return true, err
+				}
+				if i > 0 {
+					leaves = append(leaves, leaf)
+				}
+			}
+		case id == 3 && typ == tI64:
+			v, err := r.zigzag()
+			rows = v
+			return true, err
+		case id == 4 && typ == tList:
+			_, n, err := r.listHeader()
+			if err != nil {
+				return true, err
+			}
+			for range n {
+				g, err := readRowGroup(r)
+				if err != nil {
+					return true, err
+				}
+				groups = append(groups, g)
+			}
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("parquet footer: %w", err)
+	}
+
+	cols := make([]Column, len(leaves))
+	for i, leaf := range leaves {
+		cols[i].Name = leaf.name
+		switch {
+		case leaf.typ == typeByteArray:
+			cols[i].Kind = String
+		case leaf.typ == typeInt64:
+			cols[i].Kind = Timestamp
+		default:
+			return nil, 0, fmt.Errorf("parquet: column %s has unsupported type %d", leaf.name, leaf.typ)
+		}
+		cols[i].Null = make([]bool, 0, rows)
+	}
+	for _, g := range groups {
+		if len(g) != len(cols) {
+			return nil, 0, fmt.Errorf("parquet: row group has %d columns, schema %d", len(g), len(cols))
+		}
+		for i, ch := range g {
+			if err := readChunk(data, ch, leaves[i].rep == repOptional, &cols[i]); err != nil {
+				return nil, 0, fmt.Errorf("parquet: column %s: %w", cols[i].Name, err)
+			}
+		}
+	}
+	for i := range cols {
+		if len(cols[i].Null) != int(rows) {
+			return nil, 0, fmt.Errorf("parquet: column %s has %d values for %d rows", cols[i].Name, len(cols[i].Null), rows)
+		}
+	}
+	return cols, int(rows), nil
+}
+
+func readSchemaElement(r *tReader) (schemaLeaf, error) {
+	var leaf schemaLeaf
+	err := r.readStruct(func(id int16, typ byte) (bool, error) {
+		var err error
+		switch {
+		case id == 1 && typ == tI32:
+			var v int64
+			v, err = r.zigzag()
+			leaf.typ = int32(v)
+		case id == 3 && typ == tI32:
+			var v int64
+			v, err = r.zigzag()
+			leaf.rep = int32(v)
+		case id == 4 && typ == tBinary:
+			var b []byte
+			b, err = r.binary()
+			leaf.name = string(b)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	return leaf, err
+}
+
+func readRowGroup(r *tReader) ([]chunkInfo, error) {
+	var chunks []chunkInfo
+	err := r.readStruct(func(id int16, typ byte) (bool, error) {
+		if id != 1 || typ != tList {
+			return false, nil
+		}
+		_, n, err := r.listHeader()
+		if err != nil {
+			return true, err
+		}
+		for range n {
+			var ch chunkInfo
+			err := r.readStruct(func(id int16, typ byte) (bool, error) {
+				if id != 3 || typ != tStruct {
+					return false, nil
+				}
+				return true, r.readStruct(func(id int16, typ byte) (bool, error) {
+					var err error
+					switch {
+					case id == 4 && typ == tI32:
+						var v int64
+						v, err = r.zigzag()
+						ch.codec = int32(v)
+					case id == 5 && typ == tI64:
+						ch.numValues, err = r.zigzag()
+					case id == 9 && typ == tI64:
+						ch.offset, err = r.zigzag()
+					default:
+						return false, nil
+					}
+					return true, err
+				})
+			})
+			if err != nil {
+				return true, err
+			}
+			chunks = append(chunks, ch)
+		}
+		return true, nil
+	})
+	return chunks, err
+}
+
+// readChunk appends the values of the data pages of ch to col.
+func readChunk(data []byte, ch chunkInfo, optional bool, col *Column) error {
+	pos := ch.offset
+	for read := int64(0); read < ch.numValues; {
+		if pos < 0 || pos >= int64(len(data)) {
+			return errTruncated
+		}
+		r := &tReader{buf: data[pos:]}
+		var pageType, size, values, encoding int64
+		err := r.readStruct(func(id int16, typ byte) (bool, error) {
+			var err error
+			switch {
+			case id == 1 && typ == tI32:
+				pageType, err = r.zigzag()
+			case id == 3 && typ == tI32:
+				size, err = r.zigzag()
+			case id == 5 && typ == tStruct:
+				err = r.readStruct(func(id int16, typ byte) (bool, error) {
+					var err error
+					switch {
+					case id == 1 && typ == tI32:
+						values, err = r.zigzag()
+					case id == 2 && typ == tI32:
+						encoding, err = r.zigzag()
+					default:
+						return false, nil
+					}
+					return true, err
+				})
+			default:
+				return false, nil
+			}
+			return true, err
+		})
+		if err != nil {
+			return fmt.Errorf("page header: %w", err)
+		}
+		start := pos + int64(r.pos)
+		if size < 0 || start+size > int64(len(data)) {
+			return errTruncated
+		}
+		pos = start + size
+		if pageType != pageData {
+			return fmt.Errorf("unsupported page type %d", pageType)
+		}
+		if encoding != encodingPlain {
+			return fmt.Errorf("unsupported encoding %d", encoding)
+		}
+		body := data[start:pos]
+		switch ch.codec {
+		case codecUncompressed:
+		case codecSnappy:
+			if body, err = snappy.Decode(nil, body); err != nil {
+				return fmt.Errorf("snappy: %w", err)
+			}
+		default:
+			return fmt.Errorf("unsupported compression codec %d", ch.codec)
+		}
+		if err := readPage(body, int(values), optional, col); err != nil {
+			return err
+		}
+		read += values
+	}
+	return nil
+}
+
+// readPage decodes the definition levels and PLAIN values of a v1 data page of n values.
+func readPage(body []byte, n int, optional bool, col *Column) error {
+	defined := make([]bool, n)
+	if optional {
+		if len(body) < 4 {
+			return errTruncated
+		}
+		size := int(binary.LittleEndian.Uint32(body))
+		if size > len(body)-4 {
+			return errTruncated
+		}
+		if err := decodeLevels(body[4:4+size], defined); err != nil {
+			return err
+		}
+		body = body[4+size:]
+	} else {
+		for i := range defined {
+			defined[i] = true
+		}
+	}
+	for _, ok := range defined {
+		col.Null = append(col.Null, !ok)
+		switch col.Kind {
+		case String:
+			var s string
+			if ok {
+				if len(body) < 4 {
+					return errTruncated
+				}
+				l := int(binary.LittleEndian.Uint32(body))
+				if l > len(body)-4 {
+					return errTruncated
+				}
+				s, body = string(body[4:4+l]), body[4+l:]
+			}
+			col.Strings = append(col.Strings, s)
+		case Timestamp:
+			var t int64
+			if ok {
+				if len(body) < 8 {
+					return errTruncated
+				}
+				t, body = int64(binary.LittleEndian.Uint64(body)), body[8:]
+			}
+			col.Times = append(col.Times, t)
+		}
+	}
+	return nil
+}
+
+// decodeLevels reads RLE/bit-packed hybrid levels of bit width 1 into defined.
+func decodeLevels(enc []byte, defined []bool) error {
+	i := 0
+	for i < len(defined) {
+		h, n := binary.Uvarint(enc)
+		if n <= 0 {
+			return errTruncated
+		}
+		enc = enc[n:]
+		if h&1 == 1 { // bit-packed groups of 8
+			groups := int(h >> 1)
+			if groups > len(enc) {
+				return errTruncated
+			}
+			for _, b := range enc[:groups] {
+				for bit := 0; bit < 8 && i < len(defined); bit++ {
+					defined[i] = b&(1<<bit) != 0
+					i++
+				}
+			}
+			enc = enc[groups:]
+			continue
+		}
+		run := int(h >> 1)
+		if len(enc) < 1 {
+			return errTruncated
+		}
+		v := enc[0] != 0
+		enc = enc[1:]
+		for ; run > 0 && i < len(defined); run-- {
+			defined[i] = v
+			i++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Thrift compact protocol field types.
+const (
+	tStop   = 0
+	tTrue   = 1
+	tFalse  = 2
+	tByte   = 3
+	tI16    = 4
+	tI32    = 5
+	tI64    = 6
+	tDouble = 7
+	tBinary = 8
+	tList   = 9
+	tSet    = 10
+	tMap    = 11
+	tStruct = 12
+)
+
+var errTruncated = errors.New("parquet: truncated thrift data")
+
+// tWriter writes Thrift compact protocol structs, which is how Parquet encodes its page
+// headers and file footer.
+type tWriter struct {
+	buf  []byte
+	last []int16 // last field id of each open struct
+}
+
+func (w *tWriter) varint(v uint64) { w.buf = binary.AppendUvarint(w.buf, v) }
+
+func (w *tWriter) zigzag(v int64) { w.varint(uint64(v<<1) ^ uint64(v>>63)) }
+
+func (w *tWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		w.buf = append(w.buf, byte(d)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *tWriter) begin()                { w.last = append(w.last, 0) }
+func (w *tWriter) end()                  { w.buf = append(w.buf, tStop); w.last = w.last[:len(w.last)-1] }
+func (w *tWriter) i32(id int16, v int32) { w.field(id, tI32); w.zigzag(int64(v)) }
+func (w *tWriter) i64(id int16, v int64) { w.field(id, tI64); w.zigzag(v) }
+func (w *tWriter) binary(id int16, b []byte) {
+	w.field(id, tBinary)
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+func (w *tWriter) str(id int16, s string) { w.binary(id, []byte(s)) }
+
+// structField starts a nested struct field; close it with end.
+func (w *tWriter) structField(id int16) { w.field(id, tStruct); w.begin() }
+
+// list writes a list header; the n elements follow.
+func (w *tWriter) list(id int16, elem byte, n int) {
+	w.field(id, tList)
+	w.listHeader(elem, n)
+}
+
+func (w *tWriter) listHeader(elem byte, n int) {
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|elem)
+	w.varint(uint64(n))
+}
+
+// tReader reads Thrift compact protocol structs. Fields it is not asked about are skipped.
+type tReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *tReader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, errTruncated
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *tReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *tReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *tReader) binary() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.buf)-r.pos) < n {
+		return nil, errTruncated
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *tReader) listHeader() (byte, int, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	n := int(b >> 4)
+	if n == 15 {
+		v, err := r.varint()
+		if err != nil {
+			return 0, 0, err
+		}
+		n = int(v)
+	}
+	if n > len(r.buf)-r.pos {
+		return 0, 0, errTruncated
+	}
+	return b & 0x0f, n, nil
+}
+
+// readStruct calls fn for each field of a struct until its stop byte. fn returns false for
+// fields it does not read, which are then skipped.
+func (r *tReader) readStruct(fn func(id int16, typ byte) (bool, error)) error {
+	var last int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return err
+		}
+		typ := b & 0x0f
+		if typ == tStop {
+			return nil
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		last = id
+		ok, err := fn(id, typ)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := r.skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *tReader) skip(typ byte) error {
+	var err error
+	switch typ {
+	case tTrue, tFalse:
+	case tByte:
+		_, err = r.byte()
+	case tI16, tI32, tI64:
+		_, err = r.varint()
+	case tDouble:
+		if r.pos+8 > len(r.buf) {
+			return errTruncated
+		}
+		r.pos += 8
+	case tBinary:
+		_, err = r.binary()
+	case tList, tSet:
+		var elem byte
+		var n int
+		if elem, n, err = r.listHeader(); err != nil {
+			return err
+		}
+		for range n {
+			if elem == tTrue || elem == tFalse {
+				elem = tByte // booleans in lists take a byte each
+			}
+			if err := r.skip(elem); err != nil {
+				return err
+			}
+		}
+	case tMap:
+		var n uint64
+		if n, err = r.varint(); err != nil || n == 0 {
+			return err
+		}
+		var kv byte
+		if kv, err = r.byte(); err != nil {
+			return err
+		}
+		for range n {
+			if err := r.skip(kv >> 4); err != nil {
+				return err
+			}
+			if err := r.skip(kv & 0x0f); err != nil {
+				return err
+			}
+		}
+	case tStruct:
+		err = r.readStruct(func(int16, byte) (bool, error) { return false, nil })
+	default:
+		err = fmt.Errorf("parquet: unknown thrift type %d", typ)
+	}
+	return err
+}
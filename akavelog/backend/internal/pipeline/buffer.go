@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/deadletter"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pkg"
+	"github.com/google/uuid"
+)
+
+// DeadLetter receives payloads that cannot be stored, with the stage and reason they failed
+// at (one of the deadletter.Stage constants). deadletter.Queue implements it.
+type DeadLetter interface {
+	Add(payload []byte, inputID uuid.UUID, stage, reason string)
+}
+
+// TagUnknownProject keeps the project_id of an entry that named no known project; the entry
+// itself is stored under the default project.
+const TagUnknownProject = "unknown_project"
+
+// Buffer implements inputs.InputBuffer: it decodes each payload, runs it through the
+// Manager's chain for InputID and inserts the kept entries into Next.
+//
+// When ProjectID is set, every entry belongs to that project whatever its payload or the
+// processors say. Otherwise, when Projects is set, an entry's own project_id is resolved
+// through it, by project ID or name, and cleared when it names no known project.
+//
+// When DeadLetter is set, payloads that fail to decode or normalize, and entries a processor
+// returned an error for, go to DeadLetter instead of Next.
+//
+// Every entry gets a ULID as its _id unless its payload has one. When IDs is set, an entry
+// whose own _id was stored within its window is dropped as a resubmission; the key is the
+// entry's project and _id. The IDs are recorded in the EntryIDs of the insert's context.
+//
+// When OnEntry is set, it is called with every entry Next accepted.
+type Buffer struct {
+	Manager    *Manager
+	InputID    uuid.UUID
+	Next       inputs.InputBuffer
+	DeadLetter DeadLetter
+	ProjectID  string
+	Projects   func(ref string) (id string, ok bool)
+	IDs        *IDWindow
+	OnEntry    func(entry *model.LogEntry)
+}
+
+// Insert returns Next's error; payloads dropped by a processor or dead-lettered return nil.
+func (b *Buffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+// InsertContext is Insert for a payload whose trace ctx carries.
+func (b *Buffer) InsertContext(ctx context.Context, p []byte) error {
+	entry, err := batcher.ValidateLog(p)
+	if err != nil {
+		inputs.RecordEntryID(ctx, "")
+		if b.DeadLetter != nil {
+			stage := deadletter.StageNormalize
+			if errors.Is(err, batcher.ErrInvalidJSON) {
+				stage = deadletter.StageDecode
+			}
+			b.DeadLetter.Add(p, b.InputID, stage, err.Error())
+			return nil
+		}
+		// Let the next buffer reject it the way it always has.
+		return inputs.InsertContext(ctx, b.Next, p)
+	}
+	clientID := entry.ID != ""
+	if !clientID {
+		entry.ID = pkg.NewULID()
+	}
+	b.stampProject(entry)
+	inputs.RecordEntryID(ctx, entry.ID)
+	var idKey string
+	if clientID && b.IDs != nil {
+		idKey = entry.ProjectID + "/" + entry.ID
+		if b.IDs.Seen(idKey) {
+			return nil
+		}
+	}
+	_, hadError := entry.Tags[TagError]
+	if !b.Manager.ProcessContext(ctx, b.InputID, entry) {
+		return nil
+	}
+	if b.ProjectID != "" {
+		entry.ProjectID = b.ProjectID
+	}
+	if reason, failed := entry.Tags[TagError]; failed && !hadError && b.DeadLetter != nil {
+		b.DeadLetter.Add(p, b.InputID, deadletter.StagePipeline, reason)
+		return nil
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[pipeline] marshal entry: %v", err)
+		return nil
+	}
+	if err := inputs.InsertContext(ctx, b.Next, raw); err != nil {
+		return err
+	}
+	if idKey != "" {
+		b.IDs.Add(idKey)
+	}
+	if b.OnEntry != nil {
+		b.OnEntry(entry)
+	}
+	return nil
+}
+
+// stampProject sets the project of entry before it enters the pipelines.
+func (b *Buffer) stampProject(entry *model.LogEntry) {
+	switch {
+	case b.ProjectID != "":
+		entry.ProjectID = b.ProjectID
+	case entry.ProjectID == "" || b.Projects == nil:
+	default:
+		if id, ok := b.Projects(entry.ProjectID); ok {
+			entry.ProjectID = id
+			return
+		}
+		if entry.Tags == nil {
+			entry.Tags = make(map[string]string)
+		}
+		entry.Tags[TagUnknownProject] = entry.ProjectID
+		entry.ProjectID = ""
+	}
+}
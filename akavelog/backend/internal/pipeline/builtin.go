@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "add_tags",
+		Description:  "Sets the tags in config.tags on every entry, overwriting existing values.",
+		DefaultStage: model.PipelineStageEnrich,
+		Fields: []processors.ConfigField{
+			{Name: "tags", Type: "object", Required: true, Description: "Tags to set on every entry, overwriting existing values", Example: `{"env": "prod"}`},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return buildAddTags(cfg) })
+	register(processors.ProcessorTypeInfo{
+		Type:         "drop",
+		Description:  "Drops entries whose level is in config.levels or whose service is in config.services.",
+		DefaultStage: model.PipelineStageFilter,
+		Fields: []processors.ConfigField{
+			{Name: "levels", Type: "string", Required: false, Description: "Levels to drop (list or comma-separated)", Example: "debug,trace"},
+			{Name: "services", Type: "string", Required: false, Description: "Services to drop (list or comma-separated); levels or services is required", Example: "healthcheck"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return buildDrop(cfg) })
+}
+
+func buildAddTags(cfg map[string]any) (processors.Processor, error) {
+	raw, ok := cfg["tags"].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("add_tags: config.tags must be a non-empty object")
+	}
+	tags := make(map[string]string, len(raw))
+	for k, v := range raw {
+		tags[k] = inputs.StringifyValue(v)
+	}
+	return processors.ProcessorFunc(func(e *model.LogEntry) (bool, error) {
+		if e.Tags == nil {
+			e.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			e.Tags[k] = v
+		}
+		return true, nil
+	}), nil
+}
+
+func buildDrop(cfg map[string]any) (processors.Processor, error) {
+	c := inputs.Config(cfg)
+	levels := toSet(c.Strings("levels"), strings.ToLower)
+	services := toSet(c.Strings("services"), nil)
+	if len(levels) == 0 && len(services) == 0 {
+		return nil, fmt.Errorf("drop: config.levels or config.services is required")
+	}
+	return processors.ProcessorFunc(func(e *model.LogEntry) (bool, error) {
+		return !levels[strings.ToLower(e.Level)] && !services[e.Service], nil
+	}), nil
+}
+
+func toSet(list []string, norm func(string) string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		if norm != nil {
+			s = norm(s)
+		}
+		set[s] = true
+	}
+	return set
+}
+
+// register adds a built-in processor type to processors.GlobalRegistry.
+func register(info processors.ProcessorTypeInfo, create func(cfg processors.Config) (processors.Processor, error)) {
+	processors.GlobalRegistry.Register(processors.NewFactory(info, create))
+}
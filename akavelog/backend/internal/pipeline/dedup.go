@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Tags set on dedup summary entries.
+const (
+	TagDedupSuppressed = "dedup_suppressed"
+	TagDedupFirstSeen  = "dedup_first_seen"
+	TagDedupLastSeen   = "dedup_last_seen"
+)
+
+const (
+	defaultDedupWindow  = time.Minute
+	defaultDedupMaxKeys = 100000
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "dedup",
+		Description:  "Suppresses entries whose config.fields repeat within config.window and emits a summary with the suppressed count.",
+		DefaultStage: model.PipelineStageFilter,
+		Fields: []processors.ConfigField{
+			{Name: "fields", Type: "string", Required: false, Description: "Fields forming the fingerprint (default service,level,message)"},
+			{Name: "window", Type: "string", Required: false, Description: "An entry is a duplicate if its fingerprint was seen within this (default 1m)", Example: "1m"},
+			{Name: "summary_interval", Type: "string", Required: false, Description: "Summary interval during a burst (default the window)"},
+			{Name: "max_keys", Type: "number", Required: false, Description: "Fingerprints tracked at most (default 100000)", Example: "100000"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newDedupProcessor(cfg) })
+}
+
+// dedupProcessor keeps the first entry of each fingerprint and drops repeats until the
+// fingerprint has been quiet for a whole window. Suppressed counts are reported by Emit as
+// summary entries: every summary interval while duplicates keep arriving, and once more when
+// the window closes.
+type dedupProcessor struct {
+	fields   []string
+	window   time.Duration
+	interval time.Duration // between summaries of an ongoing burst
+	maxKeys  int
+
+	now func() time.Time
+
+	mu    sync.Mutex
+	seen  map[uint64]*dedupState
+	ready []model.LogEntry // summaries of bursts closed in Process
+
+	suppressed atomic.Int64
+	summaries  atomic.Int64
+}
+
+type dedupState struct {
+	first       model.LogEntry // copy of the kept entry, the template for summaries
+	firstSeen   time.Time
+	lastSeen    time.Time
+	lastSummary time.Time
+	suppressed  int // since the last summary
+}
+
+func newDedupProcessor(cfg map[string]any) (*dedupProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &dedupProcessor{
+		fields:  c.Strings("fields"),
+		window:  defaultDedupWindow,
+		maxKeys: defaultDedupMaxKeys,
+		now:     time.Now,
+		seen:    make(map[uint64]*dedupState),
+	}
+	if len(p.fields) == 0 {
+		p.fields = []string{processors.FieldService, processors.FieldLevel, processors.FieldMessage}
+	}
+	for _, key := range []string{"window", "summary_interval"} {
+		v, _ := cfg[key].(string)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("dedup: config.%s must be a positive duration (e.g. 30s)", key)
+		}
+		if key == "window" {
+			p.window = d
+		} else {
+			p.interval = d
+		}
+	}
+	if p.interval == 0 {
+		p.interval = p.window
+	}
+	if n, ok := c.Int("max_keys"); ok {
+		if n <= 0 {
+			return nil, fmt.Errorf("dedup: config.max_keys must be positive")
+		}
+		p.maxKeys = n
+	}
+	return p, nil
+}
+
+func (p *dedupProcessor) fingerprint(e *model.LogEntry) uint64 {
+	h := fnv.New64a()
+	for _, f := range p.fields {
+		v, _ := processors.GetField(e, f)
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func (p *dedupProcessor) Process(e *model.LogEntry) (bool, error) {
+	fp := p.fingerprint(e)
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st, ok := p.seen[fp]; ok && now.Sub(st.lastSeen) < p.window {
+		st.lastSeen = now
+		st.suppressed++
+		p.suppressed.Add(1)
+		return false, nil
+	}
+	// New, or the previous burst is over; hold its summary for the next Emit.
+	if st, ok := p.seen[fp]; ok {
+		if st.suppressed > 0 {
+			p.ready = append(p.ready, p.summary(st, now))
+		}
+		delete(p.seen, fp)
+	}
+	if len(p.seen) >= p.maxKeys {
+		return true, nil // untracked rather than unbounded
+	}
+	first := *e
+	first.Tags = cloneTags(e.Tags)
+	first.RawRequest = nil
+	p.seen[fp] = &dedupState{first: first, firstSeen: now, lastSeen: now, lastSummary: now}
+	return true, nil
+}
+
+// Emit returns summaries for fingerprints whose summary interval has passed with duplicates
+// pending, and forgets fingerprints that have been quiet for a whole window.
+func (p *dedupProcessor) Emit(now time.Time, final bool) []model.LogEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.ready
+	p.ready = nil
+	for fp, st := range p.seen {
+		expired := final || now.Sub(st.lastSeen) >= p.window
+		if st.suppressed > 0 && (expired || now.Sub(st.lastSummary) >= p.interval) {
+			out = append(out, p.summary(st, now))
+			st.suppressed = 0
+			st.lastSummary = now
+		}
+		if expired {
+			delete(p.seen, fp)
+		}
+	}
+	p.summaries.Add(int64(len(out)))
+	return out
+}
+
+// summary copies the first entry of a burst and records how many repeats were dropped.
+func (p *dedupProcessor) summary(st *dedupState, now time.Time) model.LogEntry {
+	e := st.first
+	e.Timestamp = now.UTC().Format(time.RFC3339Nano)
+	e.Tags = make(map[string]string, len(st.first.Tags)+3)
+	for k, v := range st.first.Tags {
+		e.Tags[k] = v
+	}
+	e.Tags[TagDedupSuppressed] = strconv.Itoa(st.suppressed)
+	e.Tags[TagDedupFirstSeen] = st.firstSeen.UTC().Format(time.RFC3339Nano)
+	e.Tags[TagDedupLastSeen] = st.lastSeen.UTC().Format(time.RFC3339Nano)
+	return e
+}
+
+func (p *dedupProcessor) Stats() map[string]any {
+	p.mu.Lock()
+	tracked := len(p.seen)
+	p.mu.Unlock()
+	return map[string]any{
+		"fields":     strings.Join(p.fields, ","),
+		"tracked":    tracked,
+		"suppressed": p.suppressed.Load(),
+		"summaries":  p.summaries.Load(),
+	}
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestDedupWindowAndSummaries(t *testing.T) {
+	p, err := newDedupProcessor(map[string]any{"window": "10s", "summary_interval": "30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+	retry := func() bool {
+		keep, _ := p.Process(&model.LogEntry{Service: "api", Level: "error", Message: "upstream timeout", Tags: map[string]string{"host": "a"}})
+		return keep
+	}
+
+	if !retry() {
+		t.Fatal("first entry dropped")
+	}
+	// Repeats every 5s stay inside the sliding window, so all are suppressed.
+	for range 8 {
+		now = now.Add(5 * time.Second)
+		if retry() {
+			t.Fatal("duplicate kept")
+		}
+	}
+	if keep, _ := p.Process(&model.LogEntry{Service: "api", Level: "error", Message: "other"}); !keep {
+		t.Error("different message suppressed")
+	}
+	// 40s after the first entry the summary interval has passed while the burst continues.
+	out := p.Emit(now, false)
+	if len(out) != 1 || out[0].Tags[TagDedupSuppressed] != "8" || out[0].Message != "upstream timeout" || out[0].Tags["host"] != "a" {
+		t.Fatalf("periodic summary = %+v", out)
+	}
+	now = now.Add(5 * time.Second)
+	retry()
+	// Quiet for a whole window: the burst closes with a final summary of the remainder.
+	now = now.Add(10 * time.Second)
+	out = p.Emit(now, false)
+	if len(out) != 1 || out[0].Tags[TagDedupSuppressed] != "1" {
+		t.Fatalf("closing summary = %+v", out)
+	}
+	if !retry() {
+		t.Error("entry after the window was suppressed")
+	}
+	if s := p.Stats(); s["suppressed"] != int64(9) || s["summaries"] != int64(2) || s["tracked"] != 1 {
+		t.Errorf("stats = %v", s)
+	}
+
+	if _, err := newDedupProcessor(map[string]any{"window": "soon"}); err == nil {
+		t.Error("invalid window accepted")
+	}
+}
+
+func TestManagerFlushesEmittedEntries(t *testing.T) {
+	m := NewManager()
+	pipelines := []model.Pipeline{{ID: uuid.New(), Name: "d", Enabled: true, Processors: []model.ProcessorConfig{
+		{Type: "dedup", Config: map[string]any{"fields": "message"}},
+	}}}
+	if err := m.Load(pipelines); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		m.Process(uuid.Nil, &model.LogEntry{Service: "api", Message: "same"})
+	}
+	// Reloading replaces the processor; its pending summary is kept for the next Flush.
+	if err := m.Load(pipelines); err != nil {
+		t.Fatal(err)
+	}
+	sink := &memBuffer{}
+	m.Flush(sink, false)
+	if len(sink.logs) != 1 {
+		t.Fatalf("flushed %d entries, want 1", len(sink.logs))
+	}
+	var e model.LogEntry
+	if err := json.Unmarshal(sink.logs[0], &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Message != "same" || e.Tags[TagDedupSuppressed] != "2" {
+		t.Errorf("summary = %+v", e)
+	}
+}
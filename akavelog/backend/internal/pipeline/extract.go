@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Extractor pulls named fields out of a string. ok is false when the input does not match.
+type Extractor interface {
+	Extract(s string) (fields map[string]string, ok bool)
+}
+
+// extractorBuilders are the extractor types usable as processors and by POST /extractors/test.
+var extractorBuilders = map[string]func(cfg map[string]any) (Extractor, error){
+	"regex":   func(cfg map[string]any) (Extractor, error) { return newRegexExtractor(cfg) },
+	"dissect": func(cfg map[string]any) (Extractor, error) { return newDissectExtractor(cfg) },
+	"cef":     func(map[string]any) (Extractor, error) { return cefExtractor{}, nil },
+	"leef":    func(map[string]any) (Extractor, error) { return leefExtractor{}, nil },
+}
+
+// extractorPrefixes are the default config.prefix of extractor types whose field names would
+// otherwise collide with the entry's own (a CEF "name" or "version").
+var extractorPrefixes = map[string]string{
+	"cef":  "cef.",
+	"leef": "leef.",
+}
+
+// sourceField and prefixField are the config fields shared by the extracting processors.
+var (
+	sourceField = processors.ConfigField{Name: "source", Type: "string", Required: false, Description: "Field to parse (default message)", Example: "message"}
+	prefixField = processors.ConfigField{Name: "prefix", Type: "string", Required: false, Description: "Prefix for the extracted field names", Example: "http."}
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "regex",
+		Description:  "Extracts the named groups of config.pattern from config.source (default message) into fields.",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			{Name: "pattern", Type: "string", Required: true, Description: "Regular expression with named groups", Example: `(?P<method>[A-Z]+) (?P<path>\S+) (?P<status>\d{3})`},
+			sourceField, prefixField,
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newExtractProcessor("regex", cfg) })
+	register(processors.ProcessorTypeInfo{
+		Type:         "dissect",
+		Description:  "Splits config.source (default message) on the literal delimiters of config.pattern, e.g. \"%{ip} - %{user} [%{ts}]\".",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			{Name: "pattern", Type: "string", Required: true, Description: "Literal text with %{name} keys; %{} and %{?name} skip a value, %{name->} skips repeated delimiters", Example: `%{ip} - %{user} [%{ts}] "%{request}"`},
+			sourceField, prefixField,
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newExtractProcessor("dissect", cfg) })
+	register(processors.ProcessorTypeInfo{
+		Type:         "cef",
+		Description:  "Decodes an ArcSight CEF message into cef.* fields (header and extension) and sets the level from its severity.",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			sourceField, prefixField,
+			{Name: "set_level", Type: "bool", Required: false, Description: "Set the level from the CEF severity (default true)"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newExtractProcessor("cef", cfg) })
+	register(processors.ProcessorTypeInfo{
+		Type:         "leef",
+		Description:  "Decodes an IBM LEEF 1.0/2.0 message into leef.* fields (header and attributes) and sets the level from sev.",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			sourceField, prefixField,
+			{Name: "set_level", Type: "bool", Required: false, Description: "Set the level from the sev attribute (default true)"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newExtractProcessor("leef", cfg) })
+}
+
+// NewExtractor builds an extractor of the given type ("regex", "dissect", "cef" or "leef").
+func NewExtractor(typeName string, cfg map[string]any) (Extractor, error) {
+	build, ok := extractorBuilders[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown extractor type %q", typeName)
+	}
+	return build(cfg)
+}
+
+// extractProcessor runs an Extractor on one field and writes the results back with SetField,
+// so an extracted "level" or "service" replaces the entry's own; other names become tags.
+// Entries that do not match pass unchanged.
+type extractProcessor struct {
+	ex       Extractor
+	source   string
+	prefix   string
+	setLevel bool // for levelExtractors
+}
+
+func newExtractProcessor(typeName string, cfg map[string]any) (processors.Processor, error) {
+	ex, err := NewExtractor(typeName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &extractProcessor{ex: ex, source: processors.FieldMessage}
+	if s, _ := cfg["source"].(string); s != "" {
+		p.source = s
+	}
+	if prefix, ok := cfg["prefix"].(string); ok {
+		p.prefix = prefix
+	} else {
+		p.prefix = extractorPrefixes[typeName]
+	}
+	p.setLevel = true
+	if v, ok := inputs.Config(cfg).Bool("set_level"); ok {
+		p.setLevel = v
+	}
+	return p, nil
+}
+
+func (p *extractProcessor) Process(e *model.LogEntry) (bool, error) {
+	v, ok := processors.GetField(e, p.source)
+	if !ok {
+		return true, nil
+	}
+	fields, ok := p.ex.Extract(v)
+	if !ok {
+		return true, nil
+	}
+	for k, v := range fields {
+		processors.SetField(e, p.prefix+k, v)
+	}
+	if lx, ok := p.ex.(levelExtractor); ok && p.setLevel {
+		if level, ok := lx.Level(fields); ok {
+			e.Level = level
+		}
+	}
+	return true, nil
+}
+
+type regexExtractor struct {
+	re *regexp.Regexp
+}
+
+func newRegexExtractor(cfg map[string]any) (*regexExtractor, error) {
+	pattern, _ := cfg["pattern"].(string)
+	if pattern == "" {
+		return nil, fmt.Errorf("regex: config.pattern is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex: %w", err)
+	}
+	named := false
+	for _, n := range re.SubexpNames() {
+		named = named || n != ""
+	}
+	if !named {
+		return nil, fmt.Errorf("regex: pattern has no named groups, e.g. (?P<status>\\d+)")
+	}
+	return &regexExtractor{re: re}, nil
+}
+
+func (x *regexExtractor) Extract(s string) (map[string]string, bool) {
+	m := x.re.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	fields := make(map[string]string)
+	for i, name := range x.re.SubexpNames() {
+		if name != "" {
+			fields[name] = m[i]
+		}
+	}
+	return fields, true
+}
+
+// dissectKey is one %{...} of a dissect pattern and the literal that follows it.
+type dissectKey struct {
+	name  string // empty for skipped keys: %{} and %{?name}
+	delim string // empty for the last key, which takes the rest of the input
+	pad   bool   // %{name->}: skip repeated delimiters after the value
+}
+
+// dissectExtractor tokenizes on literal delimiters, like Elastic's dissect.
+type dissectExtractor struct {
+	prefix string
+	keys   []dissectKey
+}
+
+func newDissectExtractor(cfg map[string]any) (*dissectExtractor, error) {
+	pattern, _ := cfg["pattern"].(string)
+	if pattern == "" {
+		return nil, fmt.Errorf("dissect: config.pattern is required")
+	}
+	x := &dissectExtractor{}
+	rest := pattern
+	start := strings.Index(rest, "%{")
+	if start < 0 {
+		return nil, fmt.Errorf("dissect: pattern has no %%{key}")
+	}
+	x.prefix, rest = rest[:start], rest[start:]
+	for rest != "" {
+		end := strings.IndexByte(rest, '}')
+		if !strings.HasPrefix(rest, "%{") || end < 0 {
+			return nil, fmt.Errorf("dissect: unterminated key in %q", rest)
+		}
+		var k dissectKey
+		name := rest[2:end]
+		if strings.HasSuffix(name, "->") {
+			k.pad, name = true, strings.TrimSuffix(name, "->")
+		}
+		if !strings.HasPrefix(name, "?") {
+			k.name = name
+		}
+		rest = rest[end+1:]
+		next := strings.Index(rest, "%{")
+		if next < 0 {
+			next = len(rest)
+		}
+		k.delim, rest = rest[:next], rest[next:]
+		if k.delim == "" && rest != "" {
+			return nil, fmt.Errorf("dissect: keys %q and the next one need a delimiter between them", name)
+		}
+		x.keys = append(x.keys, k)
+	}
+	return x, nil
+}
+
+func (x *dissectExtractor) Extract(s string) (map[string]string, bool) {
+	if !strings.HasPrefix(s, x.prefix) {
+		return nil, false
+	}
+	s = s[len(x.prefix):]
+	fields := make(map[string]string, len(x.keys))
+	for _, k := range x.keys {
+		var value string
+		if k.delim == "" {
+			value, s = s, ""
+		} else {
+			i := strings.Index(s, k.delim)
+			if i < 0 {
+				return nil, false
+			}
+			value, s = s[:i], s[i+len(k.delim):]
+			if k.pad {
+				for strings.HasPrefix(s, k.delim) {
+					s = s[len(k.delim):]
+				}
+			}
+		}
+		if k.name != "" {
+			fields[k.name] = value
+		}
+	}
+	return fields, true
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestDissect(t *testing.T) {
+	x, err := NewExtractor("dissect", map[string]any{
+		"pattern": `%{ip} %{?ident} %{user} [%{ts}] "%{method} %{path} %{}" %{status->} %{bytes}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := x.Extract(`10.0.0.1 - alice [01/Mar/2024:12:30:45 +0000] "GET /index.html HTTP/1.1" 200   512`)
+	want := map[string]string{
+		"ip": "10.0.0.1", "user": "alice", "ts": "01/Mar/2024:12:30:45 +0000",
+		"method": "GET", "path": "/index.html", "status": "200", "bytes": "512",
+	}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract = %v, %v; want %v", got, ok, want)
+	}
+	if _, ok := x.Extract("garbage"); ok {
+		t.Error("non-matching input matched")
+	}
+
+	for _, pattern := range []string{"", "no keys", "%{a}%{b}", "%{a"} {
+		if _, err := NewExtractor("dissect", map[string]any{"pattern": pattern}); err == nil {
+			t.Errorf("pattern %q accepted", pattern)
+		}
+	}
+}
+
+func TestRegexProcessor(t *testing.T) {
+	if _, err := NewExtractor("regex", map[string]any{"pattern": `\d+`}); err == nil {
+		t.Error("pattern without named groups accepted")
+	}
+	p, err := newExtractProcessor("regex", map[string]any{
+		"pattern": `^(?P<level>[A-Z]+) status=(?P<status>\d+)`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{Level: "info", Message: "ERROR status=503 upstream down"}
+	if keep, err := p.Process(&e); !keep || err != nil {
+		t.Fatal(keep, err)
+	}
+	if e.Level != "ERROR" || e.Tags["status"] != "503" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	p, _ = newExtractProcessor("regex", map[string]any{"pattern": `(?P<code>\d+)`, "source": "raw", "prefix": "x_"})
+	e = model.LogEntry{Message: "m", Tags: map[string]string{"raw": "code 42"}}
+	p.Process(&e)
+	if e.Tags["x_code"] != "42" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+}
+
+type mapResolver map[string]map[string]string
+
+func (r mapResolver) Lookup(_ context.Context, table, key string) (map[string]string, bool, error) {
+	if table != "hosts" {
+		return nil, false, fmt.Errorf("lookup table %q not found", table)
+	}
+	row, ok := r[key]
+	return row, ok, nil
+}
+
+func TestLookupProcessor(t *testing.T) {
+	SetLookupResolver(mapResolver{"web-1": {"team": "edge", "region": "eu"}})
+	defer SetLookupResolver(nil)
+
+	p, err := newLookupProcessor(map[string]any{
+		"table": "hosts", "source": "host", "fields": []any{"team"}, "prefix": "host_",
+		"default": map[string]any{"team": "unknown"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{Tags: map[string]string{"host": "web-1"}}
+	p.Process(&e)
+	if e.Tags["host_team"] != "edge" || e.Tags["host_region"] != "" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+	e = model.LogEntry{Tags: map[string]string{"host": "db-9"}}
+	p.Process(&e)
+	if e.Tags["host_team"] != "unknown" {
+		t.Errorf("default not applied: %v", e.Tags)
+	}
+
+	p, _ = newLookupProcessor(map[string]any{"table": "gone", "source": "host"})
+	if _, err := p.Process(&model.LogEntry{Tags: map[string]string{"host": "web-1"}}); err == nil {
+		t.Error("missing table not reported")
+	}
+	if _, err := newLookupProcessor(map[string]any{"table": "hosts"}); err == nil {
+		t.Error("config without source accepted")
+	}
+}
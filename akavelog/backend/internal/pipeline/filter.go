@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/rules"
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "filter",
+		Description:  "Drops entries matching config.expression or config.query (action drop) or all others (action keep); config.dry_run only counts.",
+		DefaultStage: model.PipelineStageFilter,
+		Fields: []processors.ConfigField{
+			{Name: "expression", Type: "string", Required: false, Description: "Rule expression, e.g. level <= debug and service in [api, web]; required unless query is set", Example: "level <= debug"},
+			{Name: "query", Type: "string", Required: false, Description: "Search query instead of an expression, e.g. service:api AND level:debug", Example: "level:debug AND message:~\"health.?check\""},
+			{Name: "action", Type: "string", Required: false, Description: "drop (default) drops matching entries, keep drops all others", Example: "drop"},
+			{Name: "dry_run", Type: "bool", Required: false, Description: "Only count matches (see GET /pipelines/:id/stats); nothing is dropped"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newFilterProcessor(cfg) })
+}
+
+// filterProcessor evaluates a rules expression per entry. In dry-run mode nothing is dropped;
+// the counters show what the rule would do.
+type filterProcessor struct {
+	expr   *rules.Expr
+	keep   bool // keep only matching entries instead of dropping them
+	dryRun bool
+
+	evaluated atomic.Int64
+	matched   atomic.Int64
+	dropped   atomic.Int64 // or would have been, in dry-run mode
+}
+
+func newFilterProcessor(cfg map[string]any) (*filterProcessor, error) {
+	src, _ := cfg["expression"].(string)
+	qsrc, _ := cfg["query"].(string)
+	switch {
+	case strings.TrimSpace(src) != "" && strings.TrimSpace(qsrc) != "":
+		return nil, fmt.Errorf("filter: set config.expression or config.query, not both")
+	case strings.TrimSpace(qsrc) != "":
+		q, err := query.Parse(qsrc)
+		if err != nil {
+			return nil, fmt.Errorf("filter: query %w", err)
+		}
+		src = q.Rule()
+	case strings.TrimSpace(src) == "":
+		return nil, fmt.Errorf("filter: config.expression or config.query is required")
+	}
+	expr, err := rules.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	p := &filterProcessor{expr: expr}
+	switch action, _ := cfg["action"].(string); strings.ToLower(action) {
+	case "", "drop":
+	case "keep":
+		p.keep = true
+	default:
+		return nil, fmt.Errorf("filter: action must be drop or keep")
+	}
+	p.dryRun, _ = inputs.Config(cfg).Bool("dry_run")
+	return p, nil
+}
+
+// EntryGetter adapts an entry to rules.Getter using processors.GetField.
+func EntryGetter(e *model.LogEntry) rules.Getter {
+	return func(field string) (string, bool) { return processors.GetField(e, field) }
+}
+
+func (p *filterProcessor) Process(e *model.LogEntry) (bool, error) {
+	p.evaluated.Add(1)
+	match := p.expr.Match(EntryGetter(e))
+	if match {
+		p.matched.Add(1)
+	}
+	if match == p.keep {
+		return true, nil
+	}
+	p.dropped.Add(1)
+	return p.dryRun, nil
+}
+
+func (p *filterProcessor) Stats() map[string]any {
+	evaluated, matched := p.evaluated.Load(), p.matched.Load()
+	rate := 0.0
+	if evaluated > 0 {
+		rate = float64(matched) / float64(evaluated)
+	}
+	key := "dropped"
+	if p.dryRun {
+		key = "would_drop"
+	}
+	return map[string]any{
+		"dry_run":    p.dryRun,
+		"evaluated":  evaluated,
+		"matched":    matched,
+		"match_rate": rate,
+		key:          p.dropped.Load(),
+	}
+}
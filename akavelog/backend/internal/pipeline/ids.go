@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultIDWindow = 10 * time.Minute
+	defaultMaxIDs   = 1000000
+)
+
+// IDWindow remembers the client-provided _id of the entries stored within a window, so
+// Buffer drops a resubmitted entry instead of storing it twice. It keeps at most max IDs,
+// forgetting the oldest first. It is safe for concurrent use.
+type IDWindow struct {
+	window time.Duration
+	max    int
+	now    func() time.Time
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []idSeen // in the order added; order[head] is the oldest
+	head  int
+
+	duplicates atomic.Int64
+}
+
+type idSeen struct {
+	key string
+	at  time.Time
+}
+
+// NewIDWindow returns a window of the given length keeping at most max IDs. Values <= 0 take
+// the defaults, 10m and 1000000.
+func NewIDWindow(window time.Duration, max int) *IDWindow {
+	if window <= 0 {
+		window = defaultIDWindow
+	}
+	if max <= 0 {
+		max = defaultMaxIDs
+	}
+	return &IDWindow{window: window, max: max, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Seen reports whether key was added within the window, and counts it as a duplicate if so.
+func (w *IDWindow) Seen(key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	at, ok := w.seen[key]
+	if !ok || w.now().Sub(at) >= w.window {
+		return false
+	}
+	w.duplicates.Add(1)
+	return true
+}
+
+// Add remembers key from now on.
+func (w *IDWindow) Add(key string) {
+	now := w.now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seen[key] = now
+	w.order = append(w.order, idSeen{key, now})
+	w.expire(now)
+}
+
+// expire forgets the IDs older than the window and, beyond max, the oldest.
+func (w *IDWindow) expire(now time.Time) {
+	for ; w.head < len(w.order); w.head++ {
+		e := w.order[w.head]
+		if now.Sub(e.at) < w.window && len(w.order)-w.head <= w.max {
+			break
+		}
+		// A key added again later has a newer entry further on.
+		if w.seen[e.key].Equal(e.at) {
+			delete(w.seen, e.key)
+		}
+		w.order[w.head] = idSeen{}
+	}
+	if w.head > len(w.order)/2 {
+		w.order = append(w.order[:0], w.order[w.head:]...)
+		w.head = 0
+	}
+}
+
+// Len returns how many IDs are remembered.
+func (w *IDWindow) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.seen)
+}
+
+// Duplicates returns how many entries Seen reported as duplicates.
+func (w *IDWindow) Duplicates() int64 {
+	return w.duplicates.Load()
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestIDWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	w := NewIDWindow(time.Minute, 2)
+	w.now = func() time.Time { return now }
+	w.Add("a")
+	if !w.Seen("a") || w.Seen("b") {
+		t.Fatal("a should be seen and b not")
+	}
+	now = now.Add(time.Minute)
+	if w.Seen("a") {
+		t.Error("a is seen after the window")
+	}
+	w.Add("b")
+	w.Add("c")
+	w.Add("d") // over max: b goes
+	if w.Seen("b") || !w.Seen("c") || !w.Seen("d") || w.Len() != 2 {
+		t.Errorf("kept %d ids, want c and d", w.Len())
+	}
+	if n := w.Duplicates(); n != 3 {
+		t.Errorf("duplicates = %d, want 3", n)
+	}
+}
+
+// failBuffer fails every insert with err.
+type failBuffer struct{ err error }
+
+func (b failBuffer) Insert([]byte) error { return b.err }
+
+func TestBufferEntryIDs(t *testing.T) {
+	next := &memBuffer{}
+	b := &Buffer{Manager: NewManager(), Next: next, IDs: NewIDWindow(time.Minute, 0)}
+	ctx, ids := inputs.WithEntryIDs(context.Background())
+	b.InsertContext(ctx, []byte(`{"service":"api","message":"generated"}`))
+	b.InsertContext(ctx, []byte(`{"_id":"order-1","service":"api","message":"mine"}`))
+	b.InsertContext(ctx, []byte(`{"_id":"order-1","service":"api","message":"resent"}`))
+	b.InsertContext(ctx, []byte(`{"_id":"order-1","project_id":"other","service":"api","message":"another project"}`))
+	b.InsertContext(ctx, []byte(`not json`))
+
+	got := ids.List()
+	if len(got) != 5 || len(got[0]) != 26 || got[1] != "order-1" || got[2] != "order-1" || got[4] != "" {
+		t.Fatalf("recorded ids = %q", got)
+	}
+	if len(next.logs) != 4 { // the generated one, order-1 twice in two projects, not json
+		t.Fatalf("stored %d payloads, want 4", len(next.logs))
+	}
+	var e model.LogEntry
+	if err := json.Unmarshal(next.logs[0], &e); err != nil || e.ID != got[0] {
+		t.Errorf("stored id = %q, want %q (%v)", e.ID, got[0], err)
+	}
+
+	// An entry that was not stored is not a duplicate of its retry.
+	b.Next = failBuffer{err: inputs.ErrBufferFull}
+	if err := b.Insert([]byte(`{"_id":"order-2","service":"api","message":"m"}`)); !errors.Is(err, inputs.ErrBufferFull) {
+		t.Fatalf("err = %v", err)
+	}
+	b.Next = next
+	b.Insert([]byte(`{"_id":"order-2","service":"api","message":"m"}`))
+	if len(next.logs) != 5 {
+		t.Errorf("the retry of a refused entry was dropped")
+	}
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// LookupResolver resolves keys in named lookup tables. lookup.Store implements it.
+type LookupResolver interface {
+	Lookup(ctx context.Context, table, key string) (map[string]string, bool, error)
+}
+
+type resolverHolder struct{ r LookupResolver }
+
+var lookupResolver atomic.Pointer[resolverHolder]
+
+// SetLookupResolver sets the tables used by lookup processors, like inputs.Registry.SetCheckpointStore.
+func SetLookupResolver(r LookupResolver) {
+	lookupResolver.Store(&resolverHolder{r: r})
+}
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "lookup",
+		Description:  "Looks up config.source in lookup table config.table and copies the row's columns (or config.fields) into fields.",
+		DefaultStage: model.PipelineStageEnrich,
+		Fields: []processors.ConfigField{
+			{Name: "table", Type: "string", Required: true, Description: "Lookup table name", Example: "hosts"},
+			{Name: "source", Type: "string", Required: true, Description: "Field whose value is the lookup key", Example: "host"},
+			{Name: "fields", Type: "string", Required: false, Description: "Columns to copy (list or comma-separated; default all)"},
+			{Name: "prefix", Type: "string", Required: false, Description: "Prefix for the copied field names", Example: "host."},
+			{Name: "default", Type: "object", Required: false, Description: "Fields to set when the key is not in the table", Example: `{"team": "unknown"}`},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newLookupProcessor(cfg) })
+}
+
+// lookupProcessor maps a source field through a lookup table. Keys missing from the table
+// get config.default, if set; otherwise the entry passes unchanged.
+type lookupProcessor struct {
+	table    string
+	source   string
+	fields   []string // empty copies every column
+	prefix   string
+	defaults map[string]string
+}
+
+func newLookupProcessor(cfg map[string]any) (*lookupProcessor, error) {
+	p := &lookupProcessor{fields: inputs.Config(cfg).Strings("fields")}
+	p.table, _ = cfg["table"].(string)
+	p.source, _ = cfg["source"].(string)
+	if p.table == "" || p.source == "" {
+		return nil, fmt.Errorf("lookup: config.table and config.source are required")
+	}
+	p.prefix, _ = cfg["prefix"].(string)
+	if raw, ok := cfg["default"].(map[string]any); ok {
+		p.defaults = make(map[string]string, len(raw))
+		for k, v := range raw {
+			p.defaults[k] = inputs.StringifyValue(v)
+		}
+	}
+	return p, nil
+}
+
+func (p *lookupProcessor) Process(e *model.LogEntry) (bool, error) {
+	key, ok := processors.GetField(e, p.source)
+	if !ok {
+		return true, nil
+	}
+	h := lookupResolver.Load()
+	if h == nil || h.r == nil {
+		return true, fmt.Errorf("lookup: no lookup tables configured")
+	}
+	row, found, err := h.r.Lookup(context.Background(), p.table, key)
+	if err != nil {
+		return true, err
+	}
+	if !found {
+		row = p.defaults
+	}
+	if len(p.fields) == 0 {
+		for k, v := range row {
+			processors.SetField(e, p.prefix+k, v)
+		}
+		return true, nil
+	}
+	for _, k := range p.fields {
+		if v, ok := row[k]; ok {
+			processors.SetField(e, p.prefix+k, v)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,370 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/rules"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricCounter   = "counter"
+	metricHistogram = "histogram"
+
+	defaultRollupService = "metrics"
+	defaultMaxSeries     = 10000
+)
+
+var (
+	metricNameRe   = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelInvalidRe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "metric",
+		Description:  "Counts matching entries (or observes config.value_field in a histogram) per label set for /metrics and optional O3 rollups.",
+		DefaultStage: model.PipelineStageRoute,
+		Fields: []processors.ConfigField{
+			{Name: "name", Type: "string", Required: true, Description: "Prometheus metric name", Example: "http_5xx_total"},
+			{Name: "type", Type: "string", Required: false, Description: "counter (default) or histogram", Example: "counter"},
+			{Name: "help", Type: "string", Required: false, Description: "Metric help text"},
+			{Name: "expression", Type: "string", Required: false, Description: "Rule expression entries must match to be counted", Example: "status >= 500"},
+			{Name: "labels", Type: "string", Required: false, Description: "Fields used as labels (list or comma-separated)", Example: "service"},
+			{Name: "value_field", Type: "string", Required: false, Description: "Numeric field observed by a histogram", Example: "duration_ms"},
+			{Name: "buckets", Type: "object", Required: false, Description: "Histogram bucket upper bounds, in increasing order", Example: "[10, 100, 1000]"},
+			{Name: "rollup_interval", Type: "string", Required: false, Description: "Also emit one rollup entry per label set and interval to O3", Example: "1m"},
+			{Name: "rollup_service", Type: "string", Required: false, Description: "Service of rollup entries (default metrics)"},
+			{Name: "drop", Type: "bool", Required: false, Description: "Drop the source entries once counted"},
+			{Name: "max_series", Type: "number", Required: false, Description: "Label sets tracked at most; entries with new ones are not counted beyond it (default 10000)", Example: "10000"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newMetricProcessor(cfg) })
+}
+
+// MetricsRegisterer is where metric processors register their series; /metrics serves the
+// default registry.
+var MetricsRegisterer = prometheus.DefaultRegisterer
+
+// metricVecs keeps one collector per metric name across pipeline reloads, so counters do not
+// reset when an unrelated pipeline changes.
+var metricVecs = struct {
+	sync.Mutex
+	byName map[string]registeredVec
+}{byName: make(map[string]registeredVec)}
+
+type registeredVec struct {
+	signature string // type, labels and buckets; a change replaces the collector
+	collector prometheus.Collector
+	series    *seriesSet
+}
+
+// seriesSet holds the label sets a collector has, which it keeps until it is replaced.
+type seriesSet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// add reports whether key is one of the label sets, adding it if fewer than limit are held.
+func (s *seriesSet) add(key string, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return true
+	}
+	if len(s.keys) >= limit {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+func (s *seriesSet) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+// metricVec returns the collector for name and its label sets, creating or replacing it when
+// its definition changed.
+func metricVec(name, signature string, create func() prometheus.Collector) (prometheus.Collector, *seriesSet, error) {
+	metricVecs.Lock()
+	defer metricVecs.Unlock()
+	if rv, ok := metricVecs.byName[name]; ok {
+		if rv.signature == signature {
+			return rv.collector, rv.series, nil
+		}
+		MetricsRegisterer.Unregister(rv.collector)
+	}
+	c := create()
+	if err := MetricsRegisterer.Register(c); err != nil {
+		return nil, nil, fmt.Errorf("metric: register %s: %w", name, err)
+	}
+	rv := registeredVec{signature: signature, collector: c, series: &seriesSet{keys: make(map[string]struct{})}}
+	metricVecs.byName[name] = rv
+	return c, rv.series, nil
+}
+
+// metricProcessor turns entries into metric observations. Entries pass through unchanged
+// unless config.drop is set.
+type metricProcessor struct {
+	name       string
+	kind       string
+	match      *rules.Expr // nil counts every entry
+	fields     []string    // label values, in labelNames order
+	valueField string
+	drop       bool
+	maxSeries  int // label sets counted at most
+
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+	series    *seriesSet
+
+	dropped atomic.Int64 // observations not counted because of maxSeries
+
+	rollup        time.Duration // 0 disables rollup entries
+	rollupService string
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windows     map[string]*rollupWindow
+}
+
+// rollupWindow aggregates one label set during the current rollup interval.
+type rollupWindow struct {
+	labels   []string
+	count    int64
+	sum      float64
+	min, max float64
+}
+
+func newMetricProcessor(cfg map[string]any) (*metricProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &metricProcessor{kind: metricCounter, rollupService: defaultRollupService, maxSeries: defaultMaxSeries,
+		windows: make(map[string]*rollupWindow)}
+	p.name, _ = cfg["name"].(string)
+	if !metricNameRe.MatchString(p.name) {
+		return nil, fmt.Errorf("metric: config.name must be a Prometheus metric name (e.g. http_5xx_total)")
+	}
+	if k, _ := cfg["type"].(string); k != "" {
+		p.kind = strings.ToLower(k)
+	}
+	if src, _ := cfg["expression"].(string); strings.TrimSpace(src) != "" {
+		expr, err := rules.Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("metric: %w", err)
+		}
+		p.match = expr
+	}
+	p.fields = c.Strings("labels")
+	labelNames := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		labelNames[i] = labelInvalidRe.ReplaceAllString(f, "_")
+	}
+	p.drop, _ = c.Bool("drop")
+	if n, ok := c.Int("max_series"); ok {
+		if n <= 0 {
+			return nil, fmt.Errorf("metric: config.max_series must be positive")
+		}
+		p.maxSeries = n
+	}
+	help, _ := cfg["help"].(string)
+	if help == "" {
+		help = "Derived from log entries by the metric pipeline processor."
+	}
+
+	switch p.kind {
+	case metricCounter:
+		sig := "counter|" + strings.Join(labelNames, ",")
+		col, series, err := metricVec(p.name, sig, func() prometheus.Collector {
+			return prometheus.NewCounterVec(prometheus.CounterOpts{Name: p.name, Help: help}, labelNames)
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.counter, p.series = col.(*prometheus.CounterVec), series
+	case metricHistogram:
+		p.valueField, _ = cfg["value_field"].(string)
+		if p.valueField == "" {
+			return nil, fmt.Errorf("metric: config.value_field is required for histograms")
+		}
+		buckets, err := metricBuckets(cfg["buckets"])
+		if err != nil {
+			return nil, err
+		}
+		sig := fmt.Sprintf("histogram|%s|%v", strings.Join(labelNames, ","), buckets)
+		col, series, err := metricVec(p.name, sig, func() prometheus.Collector {
+			return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: p.name, Help: help, Buckets: buckets}, labelNames)
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.histogram, p.series = col.(*prometheus.HistogramVec), series
+	default:
+		return nil, fmt.Errorf("metric: config.type must be counter or histogram")
+	}
+
+	if v, _ := cfg["rollup_interval"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("metric: config.rollup_interval must be a duration of at least 1s (e.g. 1m)")
+		}
+		p.rollup = d
+	}
+	if v, _ := cfg["rollup_service"].(string); v != "" {
+		p.rollupService = v
+	}
+	return p, nil
+}
+
+// metricBuckets reads config.buckets, a list of increasing upper bounds. Defaults to
+// prometheus.DefBuckets.
+func metricBuckets(raw any) ([]float64, error) {
+	if raw == nil {
+		return prometheus.DefBuckets, nil
+	}
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("metric: config.buckets must be a non-empty list of numbers")
+	}
+	out := make([]float64, len(list))
+	for i, v := range list {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("metric: config.buckets must be a non-empty list of numbers")
+		}
+		out[i] = f
+	}
+	if !sort.Float64sAreSorted(out) {
+		return nil, fmt.Errorf("metric: config.buckets must be in increasing order")
+	}
+	return out, nil
+}
+
+func (p *metricProcessor) Process(e *model.LogEntry) (bool, error) {
+	if p.match != nil && !p.match.Match(EntryGetter(e)) {
+		return true, nil
+	}
+	labels := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		labels[i], _ = processors.GetField(e, f)
+	}
+	key := strings.Join(labels, "\x00")
+	value := 1.0
+	if p.histogram != nil {
+		raw, ok := processors.GetField(e, p.valueField)
+		if !ok || raw == "" {
+			return true, nil
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return true, fmt.Errorf("metric %s: %s is not a number: %q", p.name, p.valueField, raw)
+		}
+		value = v
+	}
+	// Label values come from the entries, so new label sets are not counted once maxSeries
+	// are: the collector keeps its series until it is replaced. Rollup windows only hold
+	// counted label sets, so they are bounded too.
+	if !p.series.add(key, p.maxSeries) {
+		p.dropped.Add(1)
+		return !p.drop, nil
+	}
+	if p.histogram != nil {
+		p.histogram.WithLabelValues(labels...).Observe(value)
+	} else {
+		p.counter.WithLabelValues(labels...).Inc()
+	}
+	if p.rollup > 0 {
+		p.addRollup(key, labels, value)
+	}
+	return !p.drop, nil
+}
+
+func (p *metricProcessor) addRollup(key string, labels []string, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.windows[key]
+	if w == nil {
+		w = &rollupWindow{labels: labels, min: math.Inf(1), max: math.Inf(-1)}
+		p.windows[key] = w
+	}
+	w.count++
+	w.sum += value
+	w.min = math.Min(w.min, value)
+	w.max = math.Max(w.max, value)
+}
+
+// Emit returns one rollup entry per label set once the rollup interval has passed, tagged like
+// statsd metric entries so both land in the same datasets.
+func (p *metricProcessor) Emit(now time.Time, final bool) []model.LogEntry {
+	if p.rollup == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.windowStart.IsZero() {
+		p.windowStart = now.Truncate(p.rollup)
+	}
+	end := p.windowStart.Add(p.rollup)
+	if !final && now.Before(end) {
+		return nil
+	}
+	if final {
+		end = now
+	}
+	out := make([]model.LogEntry, 0, len(p.windows))
+	for _, w := range p.windows {
+		tags := map[string]string{
+			"metric_name":         p.name,
+			"metric_type":         p.kind,
+			"metric_count":        strconv.FormatInt(w.count, 10),
+			"metric_window_start": p.windowStart.UTC().Format(time.RFC3339),
+			"metric_window_end":   end.UTC().Format(time.RFC3339),
+		}
+		msg := fmt.Sprintf("%s=%d", p.name, w.count)
+		if p.kind == metricHistogram {
+			sum := strconv.FormatFloat(w.sum, 'f', -1, 64)
+			tags["metric_value"] = sum
+			tags["metric_min"] = strconv.FormatFloat(w.min, 'f', -1, 64)
+			tags["metric_max"] = strconv.FormatFloat(w.max, 'f', -1, 64)
+			msg = fmt.Sprintf("%s count=%d sum=%s", p.name, w.count, sum)
+		} else {
+			tags["metric_value"] = tags["metric_count"]
+		}
+		for i, f := range p.fields {
+			tags[f] = w.labels[i]
+		}
+		out = append(out, model.LogEntry{
+			Timestamp: end.UTC().Format(time.RFC3339Nano),
+			Service:   p.rollupService,
+			Level:     "info",
+			Message:   msg,
+			Tags:      tags,
+		})
+	}
+	p.windows = make(map[string]*rollupWindow)
+	p.windowStart = now.Truncate(p.rollup)
+	return out
+}
+
+func (p *metricProcessor) Stats() map[string]any {
+	p.mu.Lock()
+	windows := len(p.windows)
+	p.mu.Unlock()
+	return map[string]any{
+		"name":    p.name,
+		"series":  p.series.len(),
+		"windows": windows,
+		"dropped": p.dropped.Load(),
+	}
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricProcessor(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	MetricsRegisterer = reg
+	defer func() { MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	errors5xx, err := newMetricProcessor(map[string]any{
+		"name": "test_http_5xx_total", "expression": "status >= 500", "labels": []any{"service", "http.method"},
+		"rollup_interval": "1m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latency, err := newMetricProcessor(map[string]any{
+		"name": "test_latency_ms", "type": "histogram", "value_field": "duration_ms",
+		"buckets": []any{10.0, 100.0, 1000.0}, "labels": "service", "drop": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []model.LogEntry{
+		{Service: "api", Tags: map[string]string{"status": "503", "http.method": "GET", "duration_ms": "250"}},
+		{Service: "api", Tags: map[string]string{"status": "500", "http.method": "GET", "duration_ms": "5"}},
+		{Service: "api", Tags: map[string]string{"status": "200", "http.method": "GET", "duration_ms": "7"}},
+		{Service: "web", Tags: map[string]string{"status": "502", "http.method": "POST"}},
+	}
+	for i := range entries {
+		if keep, err := errors5xx.Process(&entries[i]); !keep || err != nil {
+			t.Fatalf("counter: keep=%v err=%v", keep, err)
+		}
+		if _, err := latency.Process(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `
+# HELP test_http_5xx_total Derived from log entries by the metric pipeline processor.
+# TYPE test_http_5xx_total counter
+test_http_5xx_total{http_method="GET",service="api"} 2
+test_http_5xx_total{http_method="POST",service="web"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "test_http_5xx_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(latency.histogram); n != 1 {
+		t.Errorf("histogram series = %d, want 1 (web has no duration)", n)
+	}
+	if keep, _ := latency.Process(&model.LogEntry{Service: "api", Tags: map[string]string{"duration_ms": "1"}}); keep {
+		t.Error("drop: entry kept")
+	}
+	if _, err := latency.Process(&model.LogEntry{Tags: map[string]string{"duration_ms": "slow"}}); err == nil {
+		t.Error("non-numeric value accepted")
+	}
+
+	// Rebuilding with the same definition keeps the series; rollups come out per label set.
+	again, err := newMetricProcessor(map[string]any{"name": "test_http_5xx_total", "labels": "service,http.method"})
+	if err != nil || again.counter != errors5xx.counter {
+		t.Errorf("rebuilt counter not shared: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+	if out := errors5xx.Emit(now, false); len(out) != 0 {
+		t.Errorf("rollup before the interval ended: %+v", out)
+	}
+	errors5xx.Process(&model.LogEntry{Service: "api", Tags: map[string]string{"status": "500", "http.method": "GET"}})
+	out := errors5xx.Emit(now.Add(time.Minute), false)
+	if len(out) != 2 {
+		t.Fatalf("rollups = %+v", out)
+	}
+	r := out[0]
+	if r.Tags["service"] != "api" {
+		r = out[1]
+	}
+	if r.Service != "metrics" || r.Message != "test_http_5xx_total=3" || r.Tags["http.method"] != "GET" ||
+		r.Tags["metric_window_start"] != "2026-01-01T12:00:00Z" || r.Tags["metric_window_end"] != "2026-01-01T12:01:00Z" {
+		t.Errorf("rollup = %+v", r)
+	}
+
+	for _, cfg := range []map[string]any{
+		{"name": "bad-name"},
+		{"name": "x", "type": "gauge"},
+		{"name": "x", "type": "histogram"},
+		{"name": "x", "type": "histogram", "value_field": "v", "buckets": []any{5.0, 1.0}},
+		{"name": "x", "expression": "level >"},
+	} {
+		if _, err := newMetricProcessor(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+}
+
+func TestMetricMaxSeries(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	MetricsRegisterer = reg
+	defer func() { MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	p, err := newMetricProcessor(map[string]any{"name": "test_by_user_total", "labels": "user", "max_series": 2.0, "rollup_interval": "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"a", "b", "c", "a", "d"} {
+		if keep, err := p.Process(&model.LogEntry{Tags: map[string]string{"user": user}}); !keep || err != nil {
+			t.Fatalf("%s: keep=%v err=%v", user, keep, err)
+		}
+	}
+	if n := testutil.CollectAndCount(p.counter); n != 2 {
+		t.Errorf("series = %d, want 2", n)
+	}
+	if got := p.Stats(); got["series"] != 2 || got["windows"] != 2 || got["dropped"] != int64(2) {
+		t.Errorf("stats = %v", got)
+	}
+	if out := p.Emit(time.Now().Add(time.Hour), true); len(out) != 2 {
+		t.Errorf("rollups = %d, want 2", len(out))
+	}
+
+	if _, err := newMetricProcessor(map[string]any{"name": "x", "max_series": 0.0}); err == nil {
+		t.Error("max_series 0 accepted")
+	}
+}
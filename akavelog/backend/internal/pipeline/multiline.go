@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const (
+	defaultMultilineTimeout  = 2 * time.Second
+	defaultMultilineMaxLines = 500
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "multiline",
+		Description:  "Merges continuation lines (e.g. stack traces) into the entry that started them; config.pattern matches start lines.",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			{Name: "pattern", Type: "string", Required: true, Description: "Regular expression matching the first line of an event", Example: `^\d{4}-\d{2}-\d{2}`},
+			{Name: "negate", Type: "bool", Required: false, Description: "The pattern matches continuation lines instead"},
+			{Name: "group_by", Type: "string", Required: false, Description: "Fields whose values separate interleaved streams (default service)"},
+			{Name: "timeout", Type: "string", Required: false, Description: "Release an event after this long without a new line (default 2s)", Example: "2s"},
+			{Name: "max_lines", Type: "number", Required: false, Description: "Release an event once it has this many lines (default 500)", Example: "500"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newMultilineProcessor(cfg) })
+}
+
+// multilineProcessor holds the latest event per group and appends continuation lines to it.
+// An event is released when the next start line of its group arrives (in place of that line,
+// which is held in turn), when it reaches max_lines, or by Emit once no line arrived for the
+// timeout.
+type multilineProcessor struct {
+	start    *regexp.Regexp
+	negate   bool // lines NOT matching start begin an event
+	groupBy  []string
+	timeout  time.Duration
+	maxLines int
+
+	now func() time.Time
+
+	mu   sync.Mutex
+	held map[string]*multilineEvent
+
+	merged atomic.Int64 // events released with more than one line
+	lines  atomic.Int64 // continuation lines absorbed
+}
+
+type multilineEvent struct {
+	entry    model.LogEntry
+	lines    int
+	lastLine time.Time
+}
+
+func newMultilineProcessor(cfg map[string]any) (*multilineProcessor, error) {
+	c := inputs.Config(cfg)
+	src, _ := cfg["pattern"].(string)
+	if src == "" {
+		return nil, fmt.Errorf("multiline: config.pattern is required (e.g. ^\\d{4}-\\d{2}-\\d{2})")
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("multiline: invalid config.pattern: %w", err)
+	}
+	p := &multilineProcessor{
+		start:    re,
+		groupBy:  c.Strings("group_by"),
+		timeout:  defaultMultilineTimeout,
+		maxLines: defaultMultilineMaxLines,
+		now:      time.Now,
+		held:     make(map[string]*multilineEvent),
+	}
+	p.negate, _ = c.Bool("negate")
+	if len(p.groupBy) == 0 {
+		p.groupBy = []string{processors.FieldService}
+	}
+	if v, _ := cfg["timeout"].(string); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("multiline: config.timeout must be a positive duration (e.g. 2s)")
+		}
+		p.timeout = d
+	}
+	if n, ok := c.Int("max_lines"); ok {
+		if n < 2 {
+			return nil, fmt.Errorf("multiline: config.max_lines must be at least 2")
+		}
+		p.maxLines = n
+	}
+	return p, nil
+}
+
+func (p *multilineProcessor) isStart(line string) bool {
+	return p.start.MatchString(line) != p.negate
+}
+
+func (p *multilineProcessor) Process(e *model.LogEntry) (bool, error) {
+	parts := make([]string, len(p.groupBy))
+	for i, f := range p.groupBy {
+		parts[i], _ = processors.GetField(e, f)
+	}
+	key := strings.Join(parts, "\x00")
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev := p.held[key]
+	if ev == nil || p.isStart(e.Message) {
+		// Hold this line as the start of a new event and release the previous one in its place.
+		next := &multilineEvent{entry: *e, lines: 1, lastLine: now}
+		next.entry.Tags = cloneTags(e.Tags)
+		p.held[key] = next
+		if ev == nil {
+			return false, nil
+		}
+		*e = p.release(ev)
+		return true, nil
+	}
+	ev.entry.Message += "\n" + e.Message
+	ev.lines++
+	ev.lastLine = now
+	p.lines.Add(1)
+	if ev.lines < p.maxLines {
+		return false, nil
+	}
+	delete(p.held, key)
+	*e = p.release(ev)
+	return true, nil
+}
+
+func (p *multilineProcessor) release(ev *multilineEvent) model.LogEntry {
+	if ev.lines > 1 {
+		p.merged.Add(1)
+	}
+	return ev.entry
+}
+
+// Emit releases events that have seen no line for the timeout, or all of them when final.
+func (p *multilineProcessor) Emit(now time.Time, final bool) []model.LogEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []model.LogEntry
+	for key, ev := range p.held {
+		if final || now.Sub(ev.lastLine) >= p.timeout {
+			out = append(out, p.release(ev))
+			delete(p.held, key)
+		}
+	}
+	return out
+}
+
+func (p *multilineProcessor) Stats() map[string]any {
+	p.mu.Lock()
+	held := len(p.held)
+	p.mu.Unlock()
+	return map[string]any{
+		"held":               held,
+		"merged":             p.merged.Load(),
+		"continuation_lines": p.lines.Load(),
+	}
+}
+
+func cloneTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestMultilineMergesStackTraces(t *testing.T) {
+	p, err := newMultilineProcessor(map[string]any{"pattern": `^\d{4}-\d{2}-\d{2}`, "timeout": "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+
+	var out []string
+	feed := func(service, line string) {
+		e := model.LogEntry{Service: service, Message: line}
+		if keep, _ := p.Process(&e); keep {
+			out = append(out, e.Service+": "+e.Message)
+		}
+	}
+	feed("api", "2026-01-01 12:00:00 ERROR request failed")
+	feed("api", "java.lang.NullPointerException: x")
+	feed("worker", "2026-01-01 12:00:00 INFO started")
+	feed("api", "\tat com.example.Handler.run(Handler.java:42)")
+	feed("api", "2026-01-01 12:00:01 INFO next")
+
+	want := "api: 2026-01-01 12:00:00 ERROR request failed\njava.lang.NullPointerException: x\n\tat com.example.Handler.run(Handler.java:42)"
+	if len(out) != 1 || out[0] != want {
+		t.Fatalf("released %q", out)
+	}
+	if got := p.Emit(now.Add(time.Second), false); len(got) != 0 {
+		t.Errorf("released before the timeout: %+v", got)
+	}
+	got := p.Emit(now.Add(5*time.Second), false)
+	if len(got) != 2 {
+		t.Fatalf("timeout released %d events, want 2", len(got))
+	}
+	if s := p.Stats(); s["merged"] != int64(1) || s["continuation_lines"] != int64(2) || s["held"] != 0 {
+		t.Errorf("stats = %v", s)
+	}
+}
+
+func TestMultilineNegateAndMaxLines(t *testing.T) {
+	// Continuation lines start with whitespace; everything else starts an event.
+	p, err := newMultilineProcessor(map[string]any{"pattern": `^\s`, "negate": true, "max_lines": 3.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []model.LogEntry
+	for _, line := range []string{"Traceback:", "  a", "  b", "  c", "done"} {
+		e := model.LogEntry{Message: line}
+		if keep, _ := p.Process(&e); keep {
+			out = append(out, e)
+		}
+	}
+	// "  c" arrives with nothing held after the max_lines release and starts its own event.
+	if len(out) != 2 || out[0].Message != "Traceback:\n  a\n  b" || out[1].Message != "  c" {
+		t.Errorf("released %+v", out)
+	}
+
+	for _, cfg := range []map[string]any{{}, {"pattern": "("}, {"pattern": "x", "timeout": "-1s"}, {"pattern": "x", "max_lines": 1.0}} {
+		if _, err := newMultilineProcessor(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+}
+
+func TestEmittedEntriesContinueThroughChain(t *testing.T) {
+	m := NewManager()
+	if err := m.Load([]model.Pipeline{{ID: uuid.New(), Name: "m", Enabled: true, Processors: []model.ProcessorConfig{
+		{Type: "multiline", Config: map[string]any{"pattern": "^START"}},
+		appendProc(model.PipelineStageRoute, "!"),
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	m.Process(uuid.Nil, &model.LogEntry{Message: "START x"})
+	m.Process(uuid.Nil, &model.LogEntry{Message: "more"})
+	sink := &memBuffer{}
+	m.Flush(sink, true)
+	if len(sink.logs) != 1 || !strings.Contains(string(sink.logs[0]), `"START x\nmore!"`) {
+		t.Errorf("flushed %q", sink.logs)
+	}
+}
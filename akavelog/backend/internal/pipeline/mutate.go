@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "mutate",
+		Description:  "Renames, copies, sets, converts, lowercases, uppercases and removes fields, in that order.",
+		DefaultStage: model.PipelineStageEnrich,
+		Fields: []processors.ConfigField{
+			{Name: "rename", Type: "object", Required: false, Description: "Fields to rename, from → to", Example: `{"svc": "service"}`},
+			{Name: "copy", Type: "object", Required: false, Description: "Fields to copy, from → to", Example: `{"service": "app"}`},
+			{Name: "set", Type: "object", Required: false, Description: "Static values to set", Example: `{"env": "prod"}`},
+			{Name: "convert", Type: "object", Required: false, Description: "Fields to convert to int, float, bool or string", Example: `{"status": "int"}`},
+			{Name: "lowercase", Type: "string", Required: false, Description: "Fields to lowercase (list or comma-separated)"},
+			{Name: "uppercase", Type: "string", Required: false, Description: "Fields to uppercase (list or comma-separated)"},
+			{Name: "remove", Type: "string", Required: false, Description: "Fields to remove (list or comma-separated)"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newMutateProcessor(cfg) })
+}
+
+// fieldPair is one from → to mapping of rename or copy.
+type fieldPair struct{ from, to string }
+
+// mutateProcessor applies simple schema fixes declaratively. Operations run in a fixed order
+// (rename, copy, set, convert, lowercase, uppercase, remove), and mappings in key order, so
+// the result never depends on map iteration.
+type mutateProcessor struct {
+	rename    []fieldPair
+	copy      []fieldPair
+	set       []fieldPair // to = field, from = static value
+	convert   []fieldPair // from = field, to = type
+	lowercase []string
+	uppercase []string
+	remove    []string
+}
+
+func newMutateProcessor(cfg map[string]any) (*mutateProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &mutateProcessor{
+		lowercase: c.Strings("lowercase"),
+		uppercase: c.Strings("uppercase"),
+		remove:    c.Strings("remove"),
+	}
+	var err error
+	if p.rename, err = mutatePairs(cfg, "rename"); err != nil {
+		return nil, err
+	}
+	if p.copy, err = mutatePairs(cfg, "copy"); err != nil {
+		return nil, err
+	}
+	if p.set, err = mutatePairs(cfg, "set"); err != nil {
+		return nil, err
+	}
+	if p.convert, err = mutatePairs(cfg, "convert"); err != nil {
+		return nil, err
+	}
+	for _, pr := range p.convert {
+		switch pr.to {
+		case "int", "float", "bool", "string":
+		default:
+			return nil, fmt.Errorf("mutate: convert.%s must be int, float, bool or string", pr.from)
+		}
+	}
+	if len(p.rename)+len(p.copy)+len(p.set)+len(p.convert)+len(p.lowercase)+len(p.uppercase)+len(p.remove) == 0 {
+		return nil, fmt.Errorf("mutate: no operations configured")
+	}
+	return p, nil
+}
+
+// mutatePairs reads config[key] as an object of string values, sorted by key. Non-string
+// values of set are stringified; elsewhere they are an error.
+func mutatePairs(cfg map[string]any, key string) ([]fieldPair, error) {
+	raw, ok := cfg[key]
+	if !ok {
+		return nil, nil
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("mutate: config.%s must be an object", key)
+	}
+	pairs := make([]fieldPair, 0, len(obj))
+	for k, v := range obj {
+		s, ok := v.(string)
+		if key == "set" {
+			s, ok = inputs.StringifyValue(v), true
+		}
+		if !ok || k == "" || (s == "" && key != "set") {
+			return nil, fmt.Errorf("mutate: config.%s.%s must be a non-empty string", key, k)
+		}
+		if key == "set" {
+			pairs = append(pairs, fieldPair{from: s, to: k})
+		} else {
+			pairs = append(pairs, fieldPair{from: k, to: s})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].from+"\x00"+pairs[i].to < pairs[j].from+"\x00"+pairs[j].to })
+	return pairs, nil
+}
+
+func (p *mutateProcessor) Process(e *model.LogEntry) (bool, error) {
+	for _, pr := range p.rename {
+		if v, ok := processors.GetField(e, pr.from); ok {
+			processors.DeleteField(e, pr.from)
+			processors.SetField(e, pr.to, v)
+		}
+	}
+	for _, pr := range p.copy {
+		if v, ok := processors.GetField(e, pr.from); ok {
+			processors.SetField(e, pr.to, v)
+		}
+	}
+	for _, pr := range p.set {
+		processors.SetField(e, pr.to, pr.from)
+	}
+	var errs []string
+	for _, pr := range p.convert {
+		v, ok := processors.GetField(e, pr.from)
+		if !ok {
+			continue
+		}
+		cv, err := coerceValue(v, pr.to)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", pr.from, err))
+			continue
+		}
+		processors.SetField(e, pr.from, cv)
+	}
+	for _, f := range p.lowercase {
+		if v, ok := processors.GetField(e, f); ok {
+			processors.SetField(e, f, strings.ToLower(v))
+		}
+	}
+	for _, f := range p.uppercase {
+		if v, ok := processors.GetField(e, f); ok {
+			processors.SetField(e, f, strings.ToUpper(v))
+		}
+	}
+	for _, f := range p.remove {
+		processors.DeleteField(e, f)
+	}
+	if len(errs) > 0 {
+		return true, fmt.Errorf("convert %s", strings.Join(errs, "; "))
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/secrets"
+)
+
+func TestMutateProcessor(t *testing.T) {
+	p, err := newMutateProcessor(map[string]any{
+		"rename":    map[string]any{"svc": "service", "lvl": "severity"},
+		"copy":      map[string]any{"service": "app"},
+		"set":       map[string]any{"env": "prod", "replicas": 3.0},
+		"convert":   map[string]any{"status": "int", "ok": "bool"},
+		"lowercase": []any{"severity"},
+		"uppercase": "method",
+		"remove":    []any{"debug_id", "project_id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{
+		Service:   "old",
+		ProjectID: "p1",
+		Tags: map[string]string{
+			"svc": "checkout", "lvl": "WARN", "status": "200.0", "ok": "TRUE", "method": "get", "debug_id": "x",
+		},
+	}
+	if keep, err := p.Process(&e); !keep || err != nil {
+		t.Fatalf("keep=%v err=%v", keep, err)
+	}
+	if e.Service != "checkout" || e.ProjectID != "" {
+		t.Errorf("entry = %+v", e)
+	}
+	want := map[string]string{
+		"app": "checkout", "severity": "warn", "env": "prod", "replicas": "3", "status": "200", "ok": "true", "method": "GET",
+	}
+	if len(e.Tags) != len(want) {
+		t.Errorf("tags = %v", e.Tags)
+	}
+	for k, v := range want {
+		if e.Tags[k] != v {
+			t.Errorf("%s = %q, want %q", k, e.Tags[k], v)
+		}
+	}
+
+	e = model.LogEntry{Tags: map[string]string{"status": "n/a"}}
+	if keep, err := p.Process(&e); !keep || err == nil {
+		t.Errorf("failed conversion: keep=%v err=%v", keep, err)
+	}
+
+	for _, cfg := range []map[string]any{
+		{},
+		{"rename": "a"},
+		{"rename": map[string]any{"a": ""}},
+		{"convert": map[string]any{"a": "date"}},
+	} {
+		if _, err := newMutateProcessor(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+}
+
+func TestMutateRefusesReferences(t *testing.T) {
+	t.Setenv("AKAVELOG_TEST_JWT_SECRET", "signing-secret")
+	_, err := processors.GlobalRegistry.Create("mutate", processors.Config{
+		"set": map[string]any{"x": "env://AKAVELOG_TEST_JWT_SECRET"},
+	})
+	if !errors.Is(err, secrets.ErrNotSecretKey) {
+		t.Errorf("Create error = %v, want ErrNotSecretKey", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package pipeline runs ingested entries through ordered chains of processors between
+// an input's buffer and the batcher.
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/metrics"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/selflog"
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TagError is set on entries a processor failed on; the entry continues through the chain.
+const TagError = "pipeline_error"
+
+// step is one built processor with the pipeline it came from. A step with a project only
+// runs on entries of that project.
+type step struct {
+	pipeline string
+	project  string
+	index    int
+	typ      string
+	stage    model.PipelineStage
+	proc     processors.Processor
+	latency  prometheus.Observer // metrics.PipelineProcessorDuration of stage and typ
+}
+
+// ProcessorStats is the runtime view of one loaded processor, as returned by the API.
+type ProcessorStats struct {
+	Index int                 `json:"index"`
+	Type  string              `json:"type"`
+	Stage model.PipelineStage `json:"stage"`
+	Stats map[string]any      `json:"stats,omitempty"`
+}
+
+// Validate builds the processors of p without loading them. Errors name the offending
+// processor so the API can report them before anything is persisted.
+func Validate(p model.Pipeline) error {
+	_, err := compile(p)
+	return err
+}
+
+func compile(p model.Pipeline) ([]step, error) {
+	var project string
+	if p.ProjectID != nil {
+		project = p.ProjectID.String()
+	}
+	steps := make([]step, 0, len(p.Processors))
+	for i, pc := range p.Processors {
+		info, ok := processors.GlobalRegistry.GetTypeInfo(pc.Type)
+		if !ok {
+			return nil, fmt.Errorf("processor %d: unknown type %q", i, pc.Type)
+		}
+		stage := pc.Stage
+		if stage == "" {
+			stage = info.DefaultStage
+		}
+		if !processors.ValidStage(stage) {
+			return nil, fmt.Errorf("processor %d (%s): invalid stage %q", i, pc.Type, stage)
+		}
+		cfg := pc.Config
+		if cfg == nil {
+			cfg = map[string]any{}
+		}
+		proc, err := processors.GlobalRegistry.Create(pc.Type, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("processor %d: %w", i, err)
+		}
+		steps = append(steps, step{pipeline: p.Name, project: project, index: i, typ: pc.Type, stage: stage, proc: proc,
+			latency: metrics.PipelineProcessorDuration.WithLabelValues(string(stage), pc.Type)})
+	}
+	return steps, nil
+}
+
+// chains is an immutable snapshot of the compiled pipelines.
+type chains struct {
+	global     []step
+	byInput    map[uuid.UUID][]step // input pipelines merged with the global ones
+	byPipeline map[uuid.UUID][]step // in processor order, for Stats
+}
+
+// Manager holds the compiled pipelines and runs entries through them. Load swaps the whole
+// set atomically, so Process never sees a half-updated configuration.
+type Manager struct {
+	current atomic.Pointer[chains]
+
+	mu      sync.Mutex
+	pending []model.LogEntry // emitted by processors replaced in Load, delivered by Flush
+}
+
+// NewManager returns a Manager with no pipelines; every entry passes unchanged.
+func NewManager() *Manager {
+	m := &Manager{}
+	m.current.Store(&chains{})
+	return m
+}
+
+// Load compiles the enabled pipelines in list and replaces the running set. Pipelines run in
+// list order within a stage; for an input, its own processors run before the global ones of
+// the same stage. Pipelines of a project skip the entries of other projects. A pipeline that fails to compile is skipped and its error returned, so one
+// bad definition does not disable the others.
+func (m *Manager) Load(list []model.Pipeline) error {
+	var errs []error
+	var global []step
+	own := make(map[uuid.UUID][]step)
+	byPipeline := make(map[uuid.UUID][]step)
+	for _, p := range list {
+		if !p.Enabled {
+			continue
+		}
+		steps, err := compile(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %q: %w", p.Name, err))
+			continue
+		}
+		byPipeline[p.ID] = append([]step{}, steps...)
+		if p.InputID == nil {
+			global = append(global, steps...)
+		} else {
+			own[*p.InputID] = append(own[*p.InputID], steps...)
+		}
+	}
+	next := &chains{global: sortByStage(global), byInput: make(map[uuid.UUID][]step, len(own)), byPipeline: byPipeline}
+	for id, steps := range own {
+		merged := append(append([]step{}, steps...), global...)
+		next.byInput[id] = sortByStage(merged)
+	}
+	prev := m.current.Swap(next)
+	if emitted := emitAll(prev, time.Now(), true); len(emitted) > 0 {
+		m.mu.Lock()
+		m.pending = append(m.pending, emitted...)
+		m.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// emitAll collects the entries due from every Emitter in c and runs each through the
+// processors after its emitter, so a merged or summary entry is still filtered and routed.
+// Global emitters continue on the global chain; those of input pipelines on the input's chain.
+func emitAll(c *chains, now time.Time, final bool) []model.LogEntry {
+	if c == nil {
+		return nil
+	}
+	var out []model.LogEntry
+	done := make(map[processors.Emitter]bool)
+	emitChain := func(steps []step) {
+		for i, s := range steps {
+			em, ok := s.proc.(processors.Emitter)
+			if !ok || done[em] {
+				continue
+			}
+			done[em] = true
+			for _, e := range em.Emit(now, final) {
+				if runSteps(context.Background(), steps[i+1:], &e) {
+					out = append(out, e)
+				}
+			}
+		}
+	}
+	emitChain(c.global)
+	for _, steps := range c.byInput {
+		emitChain(steps)
+	}
+	return out
+}
+
+// Flush inserts the entries processors have generated into sink. With final set, everything
+// pending is flushed, for use on shutdown.
+func (m *Manager) Flush(sink inputs.InputBuffer, final bool) {
+	m.mu.Lock()
+	out := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+	out = append(out, emitAll(m.current.Load(), time.Now(), final)...)
+	for i := range out {
+		raw, err := json.Marshal(&out[i])
+		if err != nil {
+			log.Printf("[pipeline] marshal emitted entry: %v", err)
+			continue
+		}
+		if err := sink.Insert(raw); err != nil {
+			log.Printf("[pipeline] insert emitted entry: %v", err)
+		}
+	}
+}
+
+// Run calls Flush every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context, sink inputs.InputBuffer, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Flush(sink, false)
+		}
+	}
+}
+
+func sortByStage(steps []step) []step {
+	sort.SliceStable(steps, func(i, j int) bool {
+		return processors.StageIndex(steps[i].stage) < processors.StageIndex(steps[j].stage)
+	})
+	return steps
+}
+
+// Process runs e through the chain for inputID (uuid.Nil for entries not tied to an input)
+// and reports whether it should be kept.
+func (m *Manager) Process(inputID uuid.UUID, e *model.LogEntry) bool {
+	return m.ProcessContext(context.Background(), inputID, e)
+}
+
+// ProcessContext is Process for an entry whose trace ctx carries: each stage the entry goes
+// through gets a span.
+func (m *Manager) ProcessContext(ctx context.Context, inputID uuid.UUID, e *model.LogEntry) bool {
+	c := m.current.Load()
+	steps, ok := c.byInput[inputID]
+	if !ok {
+		steps = c.global
+	}
+	return runSteps(ctx, steps, e)
+}
+
+func runSteps(ctx context.Context, steps []step, e *model.LogEntry) bool {
+	var span trace.Span // of stage; nil before the first step
+	var stage model.PipelineStage
+	defer func() {
+		if span != nil {
+			span.End()
+		}
+	}()
+	for _, s := range steps {
+		if s.project != "" && s.project != e.ProjectID {
+			continue
+		}
+		if span == nil || s.stage != stage {
+			if span != nil {
+				span.End()
+			}
+			stage = s.stage
+			_, span = tracing.Child(ctx, "pipeline."+string(stage))
+		}
+		start := time.Now()
+		keep, err := s.proc.Process(e)
+		s.latency.Observe(time.Since(start).Seconds())
+		if err != nil {
+			// Logging failures of akavelog's own logs would feed them back in.
+			if e.Service != selflog.Service {
+				log.Printf("[pipeline] %s: processor %d (%s): %v", s.pipeline, s.index, s.stage, err)
+			}
+			span.AddEvent("processor error", trace.WithAttributes(
+				attribute.String("pipeline", s.pipeline),
+				attribute.Int("processor.index", s.index),
+				attribute.String("processor.type", s.typ),
+				attribute.String("error", err.Error()),
+			))
+			if e.Tags == nil {
+				e.Tags = make(map[string]string)
+			}
+			e.Tags[TagError] = err.Error()
+			continue
+		}
+		if !keep {
+			span.SetAttributes(attribute.Bool("dropped", true), attribute.String("dropped_by", s.typ))
+			return false
+		}
+	}
+	return true
+}
+
+// Stats reports the processors of a loaded pipeline and the counters of those that keep any.
+// ok is false when the pipeline is not loaded (unknown, disabled or invalid). Counters restart
+// whenever pipelines are reloaded.
+func (m *Manager) Stats(pipelineID uuid.UUID) ([]ProcessorStats, bool) {
+	steps, ok := m.current.Load().byPipeline[pipelineID]
+	if !ok {
+		return nil, false
+	}
+	out := make([]ProcessorStats, 0, len(steps))
+	for _, s := range steps {
+		ps := ProcessorStats{Index: s.index, Type: s.typ, Stage: s.stage}
+		if r, ok := s.proc.(processors.StatsReporter); ok {
+			ps.Stats = r.Stats()
+		}
+		out = append(out, ps)
+	}
+	return out, true
+}
@@ -0,0 +1,357 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type memBuffer struct {
+	mu   sync.Mutex
+	logs [][]byte
+}
+
+func (b *memBuffer) Insert(p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logs = append(b.logs, p)
+	return nil
+}
+
+func init() {
+	register(processors.ProcessorTypeInfo{Type: "test_append", DefaultStage: model.PipelineStageParse},
+		func(cfg processors.Config) (processors.Processor, error) {
+			s, _ := cfg["s"].(string)
+			return processors.ProcessorFunc(func(e *model.LogEntry) (bool, error) {
+				e.Message += s
+				return true, nil
+			}), nil
+		})
+	register(processors.ProcessorTypeInfo{Type: "test_fail", DefaultStage: model.PipelineStageEnrich},
+		func(processors.Config) (processors.Processor, error) {
+			return processors.ProcessorFunc(func(*model.LogEntry) (bool, error) {
+				return true, errors.New("boom")
+			}), nil
+		})
+}
+
+func appendProc(stage model.PipelineStage, s string) model.ProcessorConfig {
+	return model.ProcessorConfig{Type: "test_append", Stage: stage, Config: map[string]any{"s": s}}
+}
+
+func TestManagerOrdersByStage(t *testing.T) {
+	input := uuid.New()
+	m := NewManager()
+	err := m.Load([]model.Pipeline{
+		{Name: "global", Enabled: true, Processors: []model.ProcessorConfig{
+			appendProc(model.PipelineStageRoute, "-g-route"),
+			appendProc(model.PipelineStageParse, "-g-parse"),
+		}},
+		{Name: "own", Enabled: true, InputID: &input, Processors: []model.ProcessorConfig{
+			appendProc(model.PipelineStageEnrich, "-i-enrich"),
+			appendProc(model.PipelineStageParse, "-i-parse"),
+		}},
+		{Name: "disabled", Enabled: false, Processors: []model.ProcessorConfig{appendProc("", "-off")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := model.LogEntry{Message: "m"}
+	m.Process(input, &e)
+	if want := "m-i-parse-g-parse-i-enrich-g-route"; e.Message != want {
+		t.Errorf("input chain = %q, want %q", e.Message, want)
+	}
+	e = model.LogEntry{Message: "m"}
+	m.Process(uuid.New(), &e)
+	if want := "m-g-parse-g-route"; e.Message != want {
+		t.Errorf("global chain = %q, want %q", e.Message, want)
+	}
+}
+
+func TestManagerTracesStages(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	m := NewManager()
+	err := m.Load([]model.Pipeline{{Name: "p", Enabled: true, Processors: []model.ProcessorConfig{
+		appendProc(model.PipelineStageParse, "-a"),
+		appendProc(model.PipelineStageParse, "-b"),
+		{Type: "test_fail"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Process(uuid.Nil, &model.LogEntry{Message: "untraced"})
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("untraced entry started %d spans", n)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "ingest")
+	m.ProcessContext(ctx, uuid.Nil, &model.LogEntry{Message: "m"})
+	parent.End()
+	var names []string
+	for _, s := range rec.Ended() {
+		if s.Name() != "ingest" && s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the ingest span", s.Name())
+		}
+		names = append(names, s.Name())
+		if s.Name() == "pipeline.enrich" && (len(s.Events()) != 1 || s.Events()[0].Name != "processor error") {
+			t.Errorf("enrich events = %+v", s.Events())
+		}
+	}
+	if got := strings.Join(names, ","); got != "pipeline.parse,pipeline.enrich,ingest" {
+		t.Errorf("spans = %s", got)
+	}
+}
+
+func TestManagerDropAndErrors(t *testing.T) {
+	m := NewManager()
+	err := m.Load([]model.Pipeline{
+		{Name: "p", Enabled: true, Processors: []model.ProcessorConfig{
+			{Type: "drop", Config: map[string]any{"levels": []any{"DEBUG"}}},
+			{Type: "test_fail"},
+			{Type: "add_tags", Config: map[string]any{"tags": map[string]any{"env": "prod", "n": 1.0}}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Process(uuid.Nil, &model.LogEntry{Level: "debug", Message: "noise"}) {
+		t.Error("debug entry was kept")
+	}
+	e := model.LogEntry{Level: "info", Message: "kept"}
+	if !m.Process(uuid.Nil, &e) {
+		t.Fatal("info entry was dropped")
+	}
+	if e.Tags["env"] != "prod" || e.Tags["n"] != "1" || e.Tags[TagError] != "boom" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+}
+
+func TestLoadSkipsInvalidPipelines(t *testing.T) {
+	m := NewManager()
+	err := m.Load([]model.Pipeline{
+		{Name: "bad", Enabled: true, Processors: []model.ProcessorConfig{{Type: "nope"}}},
+		{Name: "good", Enabled: true, Processors: []model.ProcessorConfig{appendProc("", "!")}},
+	})
+	if err == nil || !strings.Contains(err.Error(), `"bad"`) {
+		t.Fatalf("err = %v", err)
+	}
+	e := model.LogEntry{Message: "m"}
+	m.Process(uuid.Nil, &e)
+	if e.Message != "m!" {
+		t.Errorf("good pipeline not loaded: %q", e.Message)
+	}
+
+	for _, p := range []model.Pipeline{
+		{Processors: []model.ProcessorConfig{{Type: "add_tags"}}},
+		{Processors: []model.ProcessorConfig{{Type: "drop", Stage: "later", Config: map[string]any{"levels": "debug"}}}},
+	} {
+		if Validate(p) == nil {
+			t.Errorf("Validate(%+v) accepted", p.Processors)
+		}
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	m := NewManager()
+	if err := m.Load([]model.Pipeline{{Name: "p", Enabled: true, Processors: []model.ProcessorConfig{
+		{Type: "drop", Config: map[string]any{"services": "noisy"}},
+		appendProc("", "!"),
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	next := &memBuffer{}
+	b := &Buffer{Manager: m, Next: next}
+	b.Insert([]byte(`{"service":"api","message":"hi","level":"WARNING"}`))
+	b.Insert([]byte(`{"service":"noisy","message":"x"}`))
+	b.Insert([]byte(`not json`))
+
+	if len(next.logs) != 2 {
+		t.Fatalf("got %d entries, want 2", len(next.logs))
+	}
+	var e model.LogEntry
+	if err := json.Unmarshal(next.logs[0], &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Message != "hi!" || e.Level != "warn" {
+		t.Errorf("entry = %+v", e)
+	}
+	if string(next.logs[1]) != "not json" {
+		t.Errorf("invalid payload not passed through: %q", next.logs[1])
+	}
+}
+
+type memDeadLetter struct {
+	stages []string
+}
+
+func (d *memDeadLetter) Add(_ []byte, _ uuid.UUID, stage, _ string) {
+	d.stages = append(d.stages, stage)
+}
+
+func TestBufferDeadLetter(t *testing.T) {
+	m := NewManager()
+	if err := m.Load([]model.Pipeline{{Name: "p", Enabled: true, Processors: []model.ProcessorConfig{
+		{Type: "test_fail", Stage: model.PipelineStageEnrich},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	next, dl := &memBuffer{}, &memDeadLetter{}
+	b := &Buffer{Manager: m, Next: next, DeadLetter: dl}
+	b.Insert([]byte(`not json`))
+	b.Insert([]byte(`{"service":"api"}`))
+	b.Insert([]byte(`{"service":"api","message":"hi"}`))
+
+	if len(next.logs) != 0 {
+		t.Errorf("%d entries stored, want 0", len(next.logs))
+	}
+	if want := "decode,normalize,pipeline"; strings.Join(dl.stages, ",") != want {
+		t.Errorf("dead-letter stages = %v, want %s", dl.stages, want)
+	}
+}
+
+func TestFilterProcessorAndStats(t *testing.T) {
+	keepID, dryID := uuid.New(), uuid.New()
+	input := uuid.New()
+	m := NewManager()
+	err := m.Load([]model.Pipeline{
+		{ID: dryID, Name: "dry", Enabled: true, Processors: []model.ProcessorConfig{
+			{Type: "filter", Config: map[string]any{"expression": "level <= debug", "dry_run": true}},
+		}},
+		{ID: keepID, Name: "keep", Enabled: true, InputID: &input, Processors: []model.ProcessorConfig{
+			{Type: "filter", Config: map[string]any{"expression": `service in [api, web]`, "action": "keep"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []model.LogEntry{
+		{Service: "api", Level: "debug"},
+		{Service: "api", Level: "info"},
+		{Service: "batch", Level: "debug"},
+		{Service: "web", Level: "error"},
+	}
+	kept := 0
+	for i := range entries {
+		if m.Process(input, &entries[i]) {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Errorf("kept %d entries, want 3 (only service batch dropped)", kept)
+	}
+
+	stats, ok := m.Stats(dryID)
+	if !ok || len(stats) != 1 {
+		t.Fatalf("stats = %v, %v", stats, ok)
+	}
+	s := stats[0].Stats
+	// The keep filter runs first (input pipelines precede global ones), so the dry run sees 3.
+	if s["evaluated"] != int64(3) || s["matched"] != int64(1) || s["would_drop"] != int64(1) || s["dry_run"] != true {
+		t.Errorf("dry-run stats = %v", s)
+	}
+	if stats, _ := m.Stats(keepID); stats[0].Stats["dropped"] != int64(1) {
+		t.Errorf("keep stats = %v", stats[0].Stats)
+	}
+	if _, ok := m.Stats(uuid.New()); ok {
+		t.Error("stats for unknown pipeline")
+	}
+
+	if _, err := newFilterProcessor(map[string]any{"expression": "level >="}); err == nil {
+		t.Error("invalid expression accepted")
+	}
+	if _, err := newFilterProcessor(map[string]any{"expression": "level == debug", "action": "maybe"}); err == nil {
+		t.Error("invalid action accepted")
+	}
+	f, err := newFilterProcessor(map[string]any{"query": "service:batch OR level:debug", "action": "keep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keep, _ := f.Process(&model.LogEntry{Service: "web", Level: "info"}); keep {
+		t.Error("query filter kept a non-matching entry")
+	}
+	if _, err := newFilterProcessor(map[string]any{"query": "service:"}); err == nil {
+		t.Error("invalid query accepted")
+	}
+	if _, err := newFilterProcessor(map[string]any{"query": "service:api", "expression": "service == api"}); err == nil {
+		t.Error("expression and query both accepted")
+	}
+}
+
+func TestBuiltinTypesHaveConfigSpecs(t *testing.T) {
+	for _, info := range processors.GlobalRegistry.AllTypesInfo() {
+		if strings.HasPrefix(info.Type, "test_") {
+			continue
+		}
+		if info.Description == "" || len(info.Fields) == 0 {
+			t.Errorf("%s: missing description or config fields", info.Type)
+		}
+	}
+	if _, err := processors.GlobalRegistry.Create("nope", nil); err == nil {
+		t.Error("unknown type created")
+	}
+}
+
+func TestBufferProjects(t *testing.T) {
+	shop := uuid.New()
+	m := NewManager()
+	if err := m.Load([]model.Pipeline{
+		{Name: "shop", Enabled: true, ProjectID: &shop, Processors: []model.ProcessorConfig{appendProc("", "-shop")}},
+		{Name: "all", Enabled: true, Processors: []model.ProcessorConfig{appendProc("", "!")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	projects := func(ref string) (string, bool) {
+		if ref == shop.String() || ref == "shop" {
+			return shop.String(), true
+		}
+		return "", false
+	}
+	decode := func(raw []byte) model.LogEntry {
+		t.Helper()
+		var e model.LogEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	// An input's project overrides the payload's.
+	next := &memBuffer{}
+	b := &Buffer{Manager: m, Next: next, ProjectID: shop.String(), Projects: projects}
+	b.Insert([]byte(`{"service":"api","message":"m","project_id":"other"}`))
+	if e := decode(next.logs[0]); e.ProjectID != shop.String() || e.Message != "m-shop!" {
+		t.Errorf("input project: %+v", e)
+	}
+
+	// Without one, payloads name a project by ID or name; unknown projects are cleared.
+	next = &memBuffer{}
+	b = &Buffer{Manager: m, Next: next, Projects: projects}
+	b.Insert([]byte(`{"service":"api","message":"m","project_id":"shop"}`))
+	b.Insert([]byte(`{"service":"api","message":"m","project_id":"other"}`))
+	b.Insert([]byte(`{"service":"api","message":"m"}`))
+	if e := decode(next.logs[0]); e.ProjectID != shop.String() || e.Message != "m-shop!" {
+		t.Errorf("named project: %+v", e)
+	}
+	if e := decode(next.logs[1]); e.ProjectID != "" || e.Tags[TagUnknownProject] != "other" || e.Message != "m!" {
+		t.Errorf("unknown project: %+v", e)
+	}
+	if e := decode(next.logs[2]); e.ProjectID != "" || e.Message != "m!" {
+		t.Errorf("no project: %+v", e)
+	}
+}
@@ -0,0 +1,226 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const (
+	redactMask = "mask"
+	redactHash = "hash"
+	redactDrop = "drop"
+)
+
+// detector finds one kind of sensitive value. valid, when set, rejects regex matches that are
+// not real values (e.g. digit runs failing the Luhn check).
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+// builtinDetectors are selectable by name in config.detectors; all are used by default.
+var builtinDetectors = map[string]detector{
+	"email": {
+		name: "email",
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		name:  "credit_card",
+		re:    regexp.MustCompile(`\b[2-6]\d(?:[ -]?\d){11,17}\b`), // issuer prefixes 2-6; keeps epoch timestamps out
+		valid: luhnValid,
+	},
+	"ipv4": {
+		name:  "ipv4",
+		re:    regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`),
+		valid: func(s string) bool { return net.ParseIP(s) != nil },
+	},
+	"ipv6": {
+		name:  "ipv6",
+		re:    regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}(?:%[0-9a-z]+)?`),
+		valid: func(s string) bool { ip := net.ParseIP(strings.SplitN(s, "%", 2)[0]); return ip != nil && len(s) > 2 },
+	},
+	"api_key": {
+		name: "api_key",
+		re: regexp.MustCompile(strings.Join([]string{
+			`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,                       // AWS access key id
+			`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                      // GitHub token
+			`\bxox[abposr]-[A-Za-z0-9-]{10,}`,                     // Slack token
+			`\b[sr]k_(?:live|test)_[A-Za-z0-9]{16,}\b`,            // Stripe key
+			`\bAIza[0-9A-Za-z_-]{35}\b`,                           // Google API key
+			`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`, // JWT
+			`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`,             // Authorization: Bearer …
+		}, "|")),
+	},
+}
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "redact",
+		Description:  "Masks, hashes or drops emails, card numbers, IPs, API keys and custom patterns in the message and tags.",
+		DefaultStage: model.PipelineStageFilter,
+		Fields: []processors.ConfigField{
+			{Name: "detectors", Type: "string", Required: false, Description: "Built-in detectors: email, credit_card, ipv4, ipv6, api_key (default all)", Example: "email,credit_card"},
+			{Name: "patterns", Type: "object", Required: false, Description: "Custom regular expressions by name", Example: `{"ssn": "\\d{3}-\\d{2}-\\d{4}"}`},
+			{Name: "action", Type: "string", Required: false, Description: "mask (default), hash or drop", Example: "mask"},
+			{Name: "mask", Type: "string", Required: false, Description: "Replacement for mask (default [REDACTED:<detector>])"},
+			{Name: "hmac_secret", Type: "string", Required: false, Description: "Key for hash"},
+			{Name: "hmac_secret_env", Type: "string", Required: false, Description: "Environment variable holding the key for hash"},
+			{Name: "fields", Type: "string", Required: false, Description: "Fields to scan (default message, all tags and the raw request)"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newRedactProcessor(cfg) })
+}
+
+// redactProcessor rewrites sensitive values in place. Tag and header names are never changed.
+type redactProcessor struct {
+	detectors []detector
+	action    string
+	mask      string // "" uses [REDACTED:<detector>]
+	secret    []byte
+	fields    []string // empty: message, every tag and the raw request
+}
+
+func newRedactProcessor(cfg map[string]any) (*redactProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &redactProcessor{action: redactMask, fields: c.Strings("fields")}
+	if s, _ := cfg["action"].(string); s != "" {
+		p.action = strings.ToLower(s)
+	}
+	p.mask, _ = cfg["mask"].(string)
+
+	names := c.Strings("detectors")
+	if _, set := cfg["detectors"]; !set {
+		for name := range builtinDetectors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		d, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown detector %q", name)
+		}
+		p.detectors = append(p.detectors, d)
+	}
+	if raw, ok := cfg["patterns"].(map[string]any); ok {
+		custom := make([]string, 0, len(raw))
+		for name := range raw {
+			custom = append(custom, name)
+		}
+		sort.Strings(custom)
+		for _, name := range custom {
+			pattern, _ := raw[name].(string)
+			re, err := regexp.Compile(pattern)
+			if pattern == "" || err != nil {
+				return nil, fmt.Errorf("redact: patterns.%s is not a valid regex: %v", name, err)
+			}
+			p.detectors = append(p.detectors, detector{name: name, re: re})
+		}
+	}
+	if len(p.detectors) == 0 {
+		return nil, fmt.Errorf("redact: no detectors or patterns configured")
+	}
+
+	switch p.action {
+	case redactMask, redactDrop:
+	case redactHash:
+		secret, _ := cfg["hmac_secret"].(string)
+		if env, _ := cfg["hmac_secret_env"].(string); env != "" {
+			secret = os.Getenv(env)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("redact: action hash needs hmac_secret or a non-empty hmac_secret_env")
+		}
+		p.secret = []byte(secret)
+	default:
+		return nil, fmt.Errorf("redact: action must be mask, hash or drop")
+	}
+	return p, nil
+}
+
+func (p *redactProcessor) Process(e *model.LogEntry) (bool, error) {
+	found := false
+	apply := func(v string) string {
+		out, hit := p.redact(v)
+		found = found || hit
+		return out
+	}
+	if len(p.fields) == 0 {
+		e.Message = apply(e.Message)
+		for k, v := range e.Tags {
+			e.Tags[k] = apply(v)
+		}
+		if r := e.RawRequest; r != nil {
+			r.Path, r.Query, r.Body = apply(r.Path), apply(r.Query), apply(r.Body)
+			for k, v := range r.Headers {
+				r.Headers[k] = apply(v)
+			}
+		}
+	} else {
+		for _, f := range p.fields {
+			if v, ok := processors.GetField(e, f); ok {
+				processors.SetField(e, f, apply(v))
+			}
+		}
+	}
+	return !(found && p.action == redactDrop), nil
+}
+
+// redact replaces every detected value in s and reports whether any was found.
+func (p *redactProcessor) redact(s string) (string, bool) {
+	found := false
+	for _, d := range p.detectors {
+		s = d.re.ReplaceAllStringFunc(s, func(m string) string {
+			if d.valid != nil && !d.valid(m) {
+				return m
+			}
+			found = true
+			return p.replacement(d.name, m)
+		})
+	}
+	return s, found
+}
+
+func (p *redactProcessor) replacement(name, value string) string {
+	if p.action == redactHash {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write([]byte(value))
+		return "[" + name + ":" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+	}
+	if p.mask != "" {
+		return p.mask
+	}
+	return "[REDACTED:" + name + "]"
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// DefaultSampleField is the tag that records how many entries a kept entry stands for.
+const DefaultSampleField = "sample_rate"
+
+// sampleSweepInterval is how often idle per-key windows are forgotten.
+const sampleSweepInterval = 10 * time.Second
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "sample",
+		Description:  "Keeps config.percent of entries, or about config.per_second per key; error and fatal entries are always kept.",
+		DefaultStage: model.PipelineStageFilter,
+		Fields: []processors.ConfigField{
+			{Name: "percent", Type: "number", Required: false, Description: "Share of entries to keep at random; percent or per_second is required", Example: "10"},
+			{Name: "per_second", Type: "number", Required: false, Description: "Entries to keep per second and key", Example: "100"},
+			{Name: "key", Type: "string", Required: false, Description: "Fields forming the per_second key (default service,level)"},
+			{Name: "always_keep_levels", Type: "string", Required: false, Description: "Levels never sampled (default error,fatal)"},
+			{Name: "field", Type: "string", Required: false, Description: "Tag that records the sample rate (default sample_rate)"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newSampleProcessor(cfg) })
+}
+
+// sampleProcessor thins out high-volume streams. Kept entries get the sample rate (1 in N) in
+// a tag so counts can be multiplied back at query time.
+type sampleProcessor struct {
+	percent   float64 // probabilistic mode when > 0
+	perSecond int     // rate mode when > 0
+	keyFields []string
+	always    map[string]bool // levels that bypass sampling
+	field     string
+
+	now    func() time.Time
+	random func() float64
+
+	mu        sync.Mutex
+	windows   map[string]*sampleWindow
+	lastSweep time.Time
+
+	seen       atomic.Int64
+	kept       atomic.Int64
+	alwaysKept atomic.Int64
+}
+
+// sampleWindow counts one key's entries in the current and the previous second.
+type sampleWindow struct {
+	start time.Time
+	count int
+	prev  int
+}
+
+func newSampleProcessor(cfg map[string]any) (*sampleProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &sampleProcessor{
+		field:  DefaultSampleField,
+		now:    time.Now,
+		random: rand.Float64,
+	}
+	percent, hasPercent := c.Float("percent")
+	perSecond, hasRate := c.Int("per_second")
+	switch {
+	case hasPercent == hasRate:
+		return nil, fmt.Errorf("sample: set exactly one of config.percent and config.per_second")
+	case hasPercent && (percent <= 0 || percent > 100):
+		return nil, fmt.Errorf("sample: config.percent must be in (0, 100]")
+	case hasRate && perSecond <= 0:
+		return nil, fmt.Errorf("sample: config.per_second must be positive")
+	}
+	p.percent, p.perSecond = percent, perSecond
+
+	p.keyFields = c.Strings("key")
+	if len(p.keyFields) == 0 {
+		p.keyFields = []string{processors.FieldService, processors.FieldLevel}
+	}
+	levels := []string{"error", "fatal"}
+	if _, ok := cfg["always_keep_levels"]; ok {
+		levels = c.Strings("always_keep_levels")
+	}
+	p.always = toSet(levels, strings.ToLower)
+	if f, _ := cfg["field"].(string); f != "" {
+		p.field = f
+	}
+	if p.perSecond > 0 {
+		p.windows = make(map[string]*sampleWindow)
+	}
+	return p, nil
+}
+
+func (p *sampleProcessor) Process(e *model.LogEntry) (bool, error) {
+	p.seen.Add(1)
+	if p.always[strings.ToLower(e.Level)] {
+		p.alwaysKept.Add(1)
+		return true, nil
+	}
+	var keep bool
+	var rate string
+	if p.percent > 0 {
+		keep = p.random()*100 < p.percent
+		rate = strconv.FormatFloat(100/p.percent, 'g', 4, 64)
+	} else {
+		n, k := p.rateFor(p.key(e))
+		keep = k
+		rate = strconv.Itoa(n)
+	}
+	if !keep {
+		return false, nil
+	}
+	p.kept.Add(1)
+	processors.SetField(e, p.field, rate)
+	return true, nil
+}
+
+func (p *sampleProcessor) key(e *model.LogEntry) string {
+	parts := make([]string, len(p.keyFields))
+	for i, f := range p.keyFields {
+		parts[i], _ = processors.GetField(e, f)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// rateFor counts an entry against its key's one-second window and returns the current rate
+// (keep 1 in n) and whether this entry is the one kept. The rate comes from the busier of the
+// previous and the current second, so a steady stream keeps about perSecond entries a second.
+func (p *sampleProcessor) rateFor(key string) (int, bool) {
+	now := p.now()
+	sec := now.Truncate(time.Second)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.lastSweep) >= sampleSweepInterval {
+		for k, w := range p.windows {
+			if sec.Sub(w.start) > time.Second {
+				delete(p.windows, k)
+			}
+		}
+		p.lastSweep = now
+	}
+	w := p.windows[key]
+	if w == nil {
+		w = &sampleWindow{start: sec}
+		p.windows[key] = w
+	}
+	if sec.After(w.start) {
+		w.prev = 0
+		if sec.Sub(w.start) == time.Second {
+			w.prev = w.count
+		}
+		w.start, w.count = sec, 0
+	}
+	w.count++
+	n := 1
+	if expected := max(w.prev, w.count); expected > p.perSecond {
+		n = (expected + p.perSecond - 1) / p.perSecond
+	}
+	return n, (w.count-1)%n == 0
+}
+
+func (p *sampleProcessor) Stats() map[string]any {
+	seen, kept, always := p.seen.Load(), p.kept.Load(), p.alwaysKept.Load()
+	out := map[string]any{
+		"seen":        seen,
+		"kept":        kept,
+		"always_kept": always,
+		"dropped":     seen - kept - always,
+	}
+	if p.perSecond > 0 {
+		p.mu.Lock()
+		out["keys"] = len(p.windows)
+		p.mu.Unlock()
+	}
+	return out
+}
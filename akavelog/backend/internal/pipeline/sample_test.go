@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestSamplePerSecond(t *testing.T) {
+	p, err := newSampleProcessor(map[string]any{"per_second": 10.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+
+	run := func(n int, e model.LogEntry) (kept int, rates map[string]int) {
+		rates = map[string]int{}
+		for range n {
+			entry := e
+			if keep, _ := p.Process(&entry); keep {
+				kept++
+				rates[entry.Tags[DefaultSampleField]]++
+			}
+		}
+		return kept, rates
+	}
+	api := model.LogEntry{Service: "api", Level: "info"}
+
+	// First second: no history, so the rate ramps up as the count grows.
+	if kept, _ := run(1000, api); kept < 10 || kept > 60 {
+		t.Errorf("first second kept %d", kept)
+	}
+	// Steady state: the previous second sets the rate, about 10 are kept at 1 in 100.
+	now = now.Add(time.Second)
+	if kept, rates := run(1000, api); kept != 10 || rates["100"] != 10 {
+		t.Errorf("second second kept %d, rates %v", kept, rates)
+	}
+	// A different key has its own budget, and errors bypass sampling without a rate.
+	if kept, rates := run(5, model.LogEntry{Service: "web", Level: "info"}); kept != 5 || rates["1"] != 5 {
+		t.Errorf("quiet key kept %d, rates %v", kept, rates)
+	}
+	if kept, rates := run(50, model.LogEntry{Service: "api", Level: "ERROR"}); kept != 50 || rates[""] != 50 {
+		t.Errorf("errors kept %d, rates %v", kept, rates)
+	}
+	// After a gap the old count no longer applies.
+	now = now.Add(5 * time.Second)
+	if kept, _ := run(10, api); kept != 10 {
+		t.Errorf("after gap kept %d", kept)
+	}
+
+	s := p.Stats()
+	if s["seen"] != int64(2065) || s["always_kept"] != int64(50) || s["keys"] != 2 {
+		t.Errorf("stats = %v", s)
+	}
+}
+
+func TestSamplePercent(t *testing.T) {
+	p, err := newSampleProcessor(map[string]any{"percent": "25", "field": "sr", "always_keep_levels": "fatal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	for range 10000 {
+		e := model.LogEntry{Level: "error"}
+		if keep, _ := p.Process(&e); keep {
+			kept++
+			if e.Tags["sr"] != "4" {
+				t.Fatalf("sample rate = %q", e.Tags["sr"])
+			}
+		}
+	}
+	if kept < 2200 || kept > 2800 {
+		t.Errorf("kept %d of 10000 at 25%%", kept)
+	}
+
+	for _, cfg := range []map[string]any{
+		{},
+		{"percent": 10.0, "per_second": 5.0},
+		{"percent": 0.0},
+		{"percent": 150.0},
+		{"per_second": -1.0},
+	} {
+		if _, err := newSampleProcessor(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cefHeader names the seven pipe-separated CEF header fields after "CEF:".
+var cefHeader = []string{"version", "device_vendor", "device_product", "device_version", "signature_id", "name", "severity"}
+
+// cefKeyRe matches a valid extension key; CEF keys are alphanumeric with the occasional _ . [ ].
+var cefKeyRe = regexp.MustCompile(`^[A-Za-z0-9_.\[\]-]+$`)
+
+// levelExtractor is implemented by extractors that know the entry's severity, such as CEF and
+// LEEF. The extract processor applies it unless config.set_level is false.
+type levelExtractor interface {
+	Level(fields map[string]string) (string, bool)
+}
+
+// cefExtractor decodes ArcSight Common Event Format messages. Anything before "CEF:" (usually
+// a syslog header) is ignored.
+type cefExtractor struct{}
+
+func (cefExtractor) Extract(s string) (map[string]string, bool) {
+	i := strings.Index(s, "CEF:")
+	if i < 0 {
+		return nil, false
+	}
+	header, ext, ok := splitCEFHeader(s[i+len("CEF:"):])
+	if !ok {
+		return nil, false
+	}
+	fields := make(map[string]string, len(header)+8)
+	for i, name := range cefHeader {
+		fields[name] = header[i]
+	}
+	for k, v := range parseCEFExtension(ext) {
+		fields[k] = v
+	}
+	// csN=value csNLabel=name pairs carry custom fields; expose them under their label.
+	var labels []string
+	for k := range fields {
+		if strings.HasSuffix(k, "Label") {
+			labels = append(labels, k)
+		}
+	}
+	for _, k := range labels {
+		base, label := strings.TrimSuffix(k, "Label"), fields[k]
+		if v, ok := fields[base]; ok && label != "" {
+			delete(fields, base)
+			delete(fields, k)
+			fields[labelInvalidRe.ReplaceAllString(label, "_")] = v
+		}
+	}
+	return fields, true
+}
+
+func (cefExtractor) Level(fields map[string]string) (string, bool) {
+	return severityLevel(fields["severity"])
+}
+
+// splitCEFHeader splits the seven header fields, undoing the \| and \\ escapes, and returns
+// the extension that follows them.
+func splitCEFHeader(s string) ([]string, string, bool) {
+	var header []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		case c == '|':
+			header = append(header, b.String())
+			b.Reset()
+			if len(header) == len(cefHeader) {
+				return header, s[i+1:], true
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return nil, "", false
+}
+
+// parseCEFExtension parses space-separated key=value pairs whose values may contain spaces: a
+// value runs until the last space before the next unescaped key=.
+func parseCEFExtension(ext string) map[string]string {
+	type pair struct{ keyStart, eq int }
+	var pairs []pair
+	for i := 0; i < len(ext); i++ {
+		if ext[i] == '\\' {
+			i++
+			continue
+		}
+		if ext[i] != '=' {
+			continue
+		}
+		start := strings.LastIndexByte(ext[:i], ' ') + 1
+		if start < i && cefKeyRe.MatchString(ext[start:i]) {
+			pairs = append(pairs, pair{start, i})
+		}
+	}
+	fields := make(map[string]string, len(pairs))
+	for n, p := range pairs {
+		end := len(ext)
+		if n+1 < len(pairs) {
+			end = pairs[n+1].keyStart
+		}
+		fields[ext[p.keyStart:p.eq]] = unescapeCEF(strings.TrimRight(ext[p.eq+1:end], " "))
+	}
+	return fields
+}
+
+var cefUnescaper = strings.NewReplacer(`\\`, `\`, `\=`, `=`, `\n`, "\n", `\r`, "\r", `\|`, `|`)
+
+func unescapeCEF(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return cefUnescaper.Replace(s)
+}
+
+// leefExtractor decodes IBM QRadar LEEF 1.0 (tab-separated attributes) and LEEF 2.0 (with a
+// delimiter header field, given as a character or hex such as x09 or ^).
+type leefExtractor struct{}
+
+func (leefExtractor) Extract(s string) (map[string]string, bool) {
+	i := strings.Index(s, "LEEF:")
+	if i < 0 {
+		return nil, false
+	}
+	parts := strings.SplitN(s[i+len("LEEF:"):], "|", 7)
+	if len(parts) < 6 {
+		return nil, false
+	}
+	fields := map[string]string{
+		"version":         parts[0],
+		"vendor":          parts[1],
+		"product":         parts[2],
+		"product_version": parts[3],
+		"event_id":        parts[4],
+	}
+	delim, attrs := "\t", parts[5]
+	if strings.HasPrefix(parts[0], "2") {
+		if len(parts) < 7 {
+			return nil, false
+		}
+		d, err := leefDelimiter(parts[5])
+		if err != nil {
+			return nil, false
+		}
+		delim, attrs = d, parts[6]
+	} else if len(parts) == 7 {
+		attrs = parts[5] + "|" + parts[6] // attribute values may contain pipes
+	}
+	for _, kv := range strings.Split(attrs, delim) {
+		k, v, ok := strings.Cut(kv, "=")
+		if k = strings.TrimSpace(k); ok && k != "" {
+			fields[k] = v
+		}
+	}
+	return fields, true
+}
+
+func (leefExtractor) Level(fields map[string]string) (string, bool) {
+	return severityLevel(fields["sev"])
+}
+
+func leefDelimiter(s string) (string, error) {
+	lower := strings.ToLower(s)
+	hex := ""
+	switch {
+	case s == "":
+		return "\t", nil
+	case strings.HasPrefix(lower, "0x"):
+		hex = lower[2:]
+	case len(s) > 1 && lower[0] == 'x':
+		hex = lower[1:]
+	default:
+		return s[:1], nil
+	}
+	n, err := strconv.ParseUint(hex, 16, 8)
+	if err != nil {
+		return "", fmt.Errorf("invalid LEEF delimiter %q", s)
+	}
+	return string(rune(n)), nil
+}
+
+// severityLevel maps a CEF/LEEF severity (0-10, or Low/Medium/High/Very-High) to a level.
+func severityLevel(sev string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(sev)) {
+	case "unknown", "low":
+		return "info", true
+	case "medium":
+		return "warn", true
+	case "high":
+		return "error", true
+	case "very-high", "very high":
+		return "fatal", true
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(sev))
+	switch {
+	case err != nil || n < 0 || n > 10:
+		return "", false
+	case n <= 3:
+		return "info", true
+	case n <= 6:
+		return "warn", true
+	case n <= 8:
+		return "error", true
+	}
+	return "fatal", true
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestCEFProcessor(t *testing.T) {
+	p, err := newExtractProcessor("cef", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{
+		Level: "info",
+		Message: `<134>Jan 18 11:07:53 fw01 CEF:0|Security|threat\|manager|1.0|100|worm successfully stopped|10|` +
+			`src=10.0.0.1 dst=2.1.2.2 spt=1232 msg=Detected a threat. No action needed\=true cs1Label=policy cs1=Block all act=blocked a\\b`,
+	}
+	if keep, err := p.Process(&e); !keep || err != nil {
+		t.Fatalf("keep=%v err=%v", keep, err)
+	}
+	want := map[string]string{
+		"cef.version":        "0",
+		"cef.device_vendor":  "Security",
+		"cef.device_product": "threat|manager",
+		"cef.signature_id":   "100",
+		"cef.name":           "worm successfully stopped",
+		"cef.severity":       "10",
+		"cef.src":            "10.0.0.1",
+		"cef.spt":            "1232",
+		"cef.msg":            "Detected a threat. No action needed=true",
+		"cef.policy":         "Block all",
+		"cef.act":            `blocked a\b`,
+	}
+	for k, v := range want {
+		if e.Tags[k] != v {
+			t.Errorf("%s = %q, want %q", k, e.Tags[k], v)
+		}
+	}
+	if _, ok := e.Tags["cef.cs1"]; ok {
+		t.Error("labelled custom field kept under its cs1 key")
+	}
+	if e.Level != "fatal" {
+		t.Errorf("level = %q, want fatal from severity 10", e.Level)
+	}
+
+	ex, _ := NewExtractor("cef", nil)
+	if _, ok := ex.Extract("CEF:0|only|three"); ok {
+		t.Error("truncated header accepted")
+	}
+	if fields, ok := ex.Extract("CEF:1|V|P|2|sig|name|Medium|"); !ok || len(fields) != 7 {
+		t.Errorf("empty extension: %v %v", fields, ok)
+	}
+}
+
+func TestLEEFProcessor(t *testing.T) {
+	p, err := newExtractProcessor("leef", map[string]any{"prefix": "", "set_level": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{Level: "info", Message: "LEEF:1.0|Microsoft|MSExchange|2013|15345|src=10.50.1.1\tdst=2.10.20.20\tsev=7\tusrName=joe"}
+	p.Process(&e)
+	if e.Tags["vendor"] != "Microsoft" || e.Tags["event_id"] != "15345" || e.Tags["usrName"] != "joe" || e.Tags["sev"] != "7" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+	if e.Level != "info" {
+		t.Errorf("level changed to %q with set_level false", e.Level)
+	}
+
+	ex, _ := NewExtractor("leef", nil)
+	for _, msg := range []string{
+		"LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=5",
+		"LEEF:2.0|Lancope|StealthWatch|1.0|41|x5E|src=10.0.1.8^dst=10.0.0.5^sev=5",
+	} {
+		fields, ok := ex.Extract(msg)
+		if !ok || fields["src"] != "10.0.1.8" || fields["dst"] != "10.0.0.5" || fields["version"] != "2.0" {
+			t.Errorf("Extract(%q) = %v, %v", msg, fields, ok)
+		}
+		if level, _ := ex.(levelExtractor).Level(fields); level != "warn" {
+			t.Errorf("level = %q, want warn for sev 5", level)
+		}
+	}
+	if _, ok := ex.Extract("LEEF:2.0|V|P|1|id|xZZ|a=b"); ok {
+		t.Error("invalid delimiter accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// TagStreams lists the IDs of the streams an entry was routed into, comma-separated.
+const TagStreams = "streams"
+
+// StreamRouter matches entries against the configured streams. streams.Router implements it.
+type StreamRouter interface {
+	Route(e *model.LogEntry) []string
+}
+
+type streamRouterHolder struct{ r StreamRouter }
+
+var streamRouter atomic.Pointer[streamRouterHolder]
+
+// SetStreamRouter sets the streams used by stream_router processors, like SetLookupResolver.
+func SetStreamRouter(r StreamRouter) {
+	streamRouter.Store(&streamRouterHolder{r: r})
+}
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "stream_router",
+		Description:  "Tags each entry with the IDs of the streams whose rules it matches (tag streams).",
+		DefaultStage: model.PipelineStageRoute,
+		Fields: []processors.ConfigField{
+			{Name: "drop_unmatched", Type: "bool", Required: false, Description: "Drop entries that match no stream"},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newStreamRouterProcessor(cfg) })
+}
+
+// streamRouterProcessor writes the matching stream IDs into TagStreams, replacing any value
+// the entry arrived with.
+type streamRouterProcessor struct {
+	dropUnmatched bool
+
+	routed    atomic.Int64
+	unmatched atomic.Int64
+}
+
+func newStreamRouterProcessor(cfg map[string]any) (*streamRouterProcessor, error) {
+	p := &streamRouterProcessor{}
+	p.dropUnmatched, _ = inputs.Config(cfg).Bool("drop_unmatched")
+	return p, nil
+}
+
+func (p *streamRouterProcessor) Process(e *model.LogEntry) (bool, error) {
+	h := streamRouter.Load()
+	if h == nil || h.r == nil {
+		return true, fmt.Errorf("stream_router: no streams configured")
+	}
+	ids := h.r.Route(e)
+	if len(ids) == 0 {
+		p.unmatched.Add(1)
+		delete(e.Tags, TagStreams)
+		return !p.dropUnmatched, nil
+	}
+	p.routed.Add(1)
+	processors.SetField(e, TagStreams, strings.Join(ids, ","))
+	return true, nil
+}
+
+func (p *streamRouterProcessor) Stats() map[string]any {
+	return map[string]any{"routed": p.routed.Load(), "unmatched": p.unmatched.Load()}
+}
@@ -0,0 +1,291 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+const (
+	formatAuto   = "auto"
+	formatJSON   = "json"
+	formatLogfmt = "logfmt"
+	formatKV     = "kv"
+
+	defaultMaxDepth = 3
+)
+
+func init() {
+	register(processors.ProcessorTypeInfo{
+		Type:         "structured",
+		Description:  "Parses JSON, logfmt or key=value bodies in config.source (default message) into fields.",
+		DefaultStage: model.PipelineStageParse,
+		Fields: []processors.ConfigField{
+			{Name: "format", Type: "string", Required: false, Description: "auto (default), json, logfmt or kv", Example: "auto"},
+			sourceField, prefixField,
+			{Name: "max_depth", Type: "number", Required: false, Description: "Levels of nested JSON objects to flatten (default 3)", Example: "3"},
+			{Name: "separator", Type: "string", Required: false, Description: "Joins nested JSON keys (default .)"},
+			{Name: "field_split", Type: "string", Required: false, Description: "Pair separator for kv (default space)"},
+			{Name: "value_split", Type: "string", Required: false, Description: "Key/value separator for kv (default =)"},
+			{Name: "coerce", Type: "object", Required: false, Description: "Field types to validate: int, float, bool or string", Example: `{"status": "int"}`},
+		},
+	}, func(cfg processors.Config) (processors.Processor, error) { return newStructuredProcessor(cfg) })
+}
+
+// structuredProcessor flattens a structured body into fields. Nested JSON objects are joined
+// with separator up to maxDepth levels; deeper values and arrays are kept as JSON strings.
+type structuredProcessor struct {
+	source    string
+	format    string
+	prefix    string
+	separator string
+	maxDepth  int
+	fieldSep  string // kv only
+	valueSep  string // kv only
+	coerce    map[string]string
+}
+
+func newStructuredProcessor(cfg map[string]any) (*structuredProcessor, error) {
+	c := inputs.Config(cfg)
+	p := &structuredProcessor{
+		source:    processors.FieldMessage,
+		format:    formatAuto,
+		separator: ".",
+		maxDepth:  defaultMaxDepth,
+		fieldSep:  " ",
+		valueSep:  "=",
+		coerce:    make(map[string]string),
+	}
+	if s, _ := cfg["source"].(string); s != "" {
+		p.source = s
+	}
+	if s, _ := cfg["format"].(string); s != "" {
+		p.format = strings.ToLower(s)
+	}
+	switch p.format {
+	case formatAuto, formatJSON, formatLogfmt, formatKV:
+	default:
+		return nil, fmt.Errorf("structured: format must be auto, json, logfmt or kv")
+	}
+	p.prefix, _ = cfg["prefix"].(string)
+	if s, _ := cfg["separator"].(string); s != "" {
+		p.separator = s
+	}
+	if n, ok := c.Int("max_depth"); ok {
+		if n < 1 {
+			return nil, fmt.Errorf("structured: max_depth must be at least 1")
+		}
+		p.maxDepth = n
+	}
+	if s, _ := cfg["field_split"].(string); s != "" {
+		p.fieldSep = s
+	}
+	if s, _ := cfg["value_split"].(string); s != "" {
+		p.valueSep = s
+	}
+	if raw, ok := cfg["coerce"].(map[string]any); ok {
+		for field, t := range raw {
+			typ, _ := t.(string)
+			switch typ {
+			case "int", "float", "bool", "string":
+				p.coerce[field] = typ
+			default:
+				return nil, fmt.Errorf("structured: coerce.%s must be int, float, bool or string", field)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *structuredProcessor) Process(e *model.LogEntry) (bool, error) {
+	body, ok := processors.GetField(e, p.source)
+	if !ok {
+		return true, nil
+	}
+	fields := p.parse(body)
+	if len(fields) == 0 {
+		return true, nil
+	}
+	var errs []string
+	for k, v := range fields {
+		if typ, ok := p.coerce[k]; ok {
+			cv, err := coerceValue(v, typ)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", k, err))
+				continue
+			}
+			v = cv
+		}
+		processors.SetField(e, p.prefix+k, v)
+	}
+	if len(errs) > 0 {
+		return true, fmt.Errorf("coerce %s", strings.Join(errs, "; "))
+	}
+	return true, nil
+}
+
+// parse returns the fields of body in the configured format, or nil if it is not in that format.
+func (p *structuredProcessor) parse(body string) map[string]string {
+	switch p.format {
+	case formatJSON:
+		return p.parseJSON(body)
+	case formatLogfmt:
+		return parseLogfmt(body, false)
+	case formatKV:
+		return p.parseKV(body)
+	}
+	if f := p.parseJSON(body); f != nil {
+		return f
+	}
+	if f := parseLogfmt(body, true); f != nil {
+		return f
+	}
+	return p.parseKV(body)
+}
+
+func (p *structuredProcessor) parseJSON(body string) map[string]string {
+	trimmed := bytes.TrimSpace([]byte(body))
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(trimmed, &obj); err != nil {
+		return nil
+	}
+	fields := make(map[string]string, len(obj))
+	p.flatten(fields, "", obj, 1)
+	return fields
+}
+
+func (p *structuredProcessor) flatten(out map[string]string, prefix string, obj map[string]any, depth int) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + p.separator + k
+		}
+		if nested, ok := v.(map[string]any); ok && depth < p.maxDepth {
+			p.flatten(out, key, nested, depth+1)
+			continue
+		}
+		out[key] = inputs.StringifyValue(v)
+	}
+}
+
+func (p *structuredProcessor) parseKV(body string) map[string]string {
+	fields := make(map[string]string)
+	for _, tok := range strings.Split(body, p.fieldSep) {
+		k, v, ok := strings.Cut(strings.TrimSpace(tok), p.valueSep)
+		if !ok || k == "" || strings.ContainsFunc(k, unicode.IsSpace) {
+			continue
+		}
+		fields[k] = strings.Trim(strings.TrimSpace(v), `"'`)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// parseLogfmt parses key=value pairs separated by spaces, with double-quoted values and bare
+// keys (read as "true"). In strict mode every token must be a key=value pair, so free text
+// that merely contains one is not mistaken for logfmt.
+func parseLogfmt(body string, strict bool) map[string]string {
+	fields := make(map[string]string)
+	s := strings.TrimSpace(body)
+	for s != "" {
+		end := strings.IndexFunc(s, func(r rune) bool { return r == '=' || unicode.IsSpace(r) })
+		if end == 0 {
+			return nil
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		s = s[end:]
+		if !strings.HasPrefix(s, "=") {
+			if strict {
+				return nil
+			}
+			fields[key] = "true"
+			s = strings.TrimLeftFunc(s, unicode.IsSpace)
+			continue
+		}
+		s = s[1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			n, ok := quotedLen(s)
+			if !ok {
+				return nil
+			}
+			unq, err := strconv.Unquote(s[:n])
+			if err != nil {
+				return nil
+			}
+			value, s = unq, s[n:]
+			if s != "" && !unicode.IsSpace(rune(s[0])) {
+				return nil
+			}
+		} else {
+			n := strings.IndexFunc(s, unicode.IsSpace)
+			if n < 0 {
+				n = len(s)
+			}
+			value, s = s[:n], s[n:]
+		}
+		fields[key] = value
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// quotedLen returns the length of the double-quoted string at the start of s.
+func quotedLen(s string) (int, bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// coerceValue checks v against typ and renders it canonically (e.g. "1.50" → "1.5", "TRUE" → "true").
+func coerceValue(v, typ string) (string, error) {
+	switch typ {
+	case "int":
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if ferr != nil {
+				return "", fmt.Errorf("%q is not an int", v)
+			}
+			n = int64(f)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "float":
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a float", v)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", v)
+		}
+		return strconv.FormatBool(b), nil
+	}
+	return v, nil
+}
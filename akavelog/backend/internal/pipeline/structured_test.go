@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestStructuredAutoDetect(t *testing.T) {
+	p, err := newStructuredProcessor(map[string]any{"max_depth": 2.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]map[string]string{
+		`{"level":"warn","http":{"status":503,"req":{"path":"/x"}},"ids":[1,2]}`: {
+			"level": "warn", "http.status": "503", "http.req": `{"path":"/x"}`, "ids": "[1,2]",
+		},
+		`ts=2024-03-01 msg="upstream down" retry dry_run=false`: nil, // bare "retry" fails strict logfmt, falls back to kv
+		`at=info method=GET path="/a b" status=200`: {
+			"at": "info", "method": "GET", "path": "/a b", "status": "200",
+		},
+		`user=bob logged in from ip=10.0.0.1`: {"user": "bob", "ip": "10.0.0.1"},
+		`plain text only`:                     {},
+	}
+	for body, want := range cases {
+		e := model.LogEntry{Level: "info", Message: body}
+		if _, err := p.Process(&e); err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			if e.Tags["ts"] != "2024-03-01" || e.Tags["dry_run"] != "false" {
+				t.Errorf("%s: tags = %v", body, e.Tags)
+			}
+			continue
+		}
+		got := map[string]string{}
+		for k, v := range e.Tags {
+			got[k] = v
+		}
+		if l, ok := want["level"]; ok {
+			if e.Level != l {
+				t.Errorf("%s: level = %q", body, e.Level)
+			}
+			delete(want, "level")
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: tags = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestStructuredFormatsAndCoercion(t *testing.T) {
+	p, err := newStructuredProcessor(map[string]any{
+		"format":      "kv",
+		"field_split": ";",
+		"value_split": ":",
+		"prefix":      "kv_",
+		"coerce":      map[string]any{"ms": "float", "ok": "bool", "n": "int"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{Message: "ms: 1.50; ok:TRUE; n:x; name:a b"}
+	_, err = p.Process(&e)
+	if err == nil {
+		t.Error("bad int coercion not reported")
+	}
+	want := map[string]string{"kv_ms": "1.5", "kv_ok": "true", "kv_name": "a b"}
+	if !reflect.DeepEqual(e.Tags, want) {
+		t.Errorf("tags = %v, want %v", e.Tags, want)
+	}
+
+	p, _ = newStructuredProcessor(map[string]any{"format": "json"})
+	e = model.LogEntry{Message: "a=b"}
+	p.Process(&e)
+	if len(e.Tags) != 0 {
+		t.Errorf("json format parsed logfmt: %v", e.Tags)
+	}
+
+	for _, cfg := range []map[string]any{
+		{"format": "xml"},
+		{"max_depth": 0.0},
+		{"coerce": map[string]any{"a": "date"}},
+	} {
+		if _, err := newStructuredProcessor(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+}
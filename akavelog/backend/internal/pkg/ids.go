@@ -1,3 +1,76 @@
 package pkg
 
-// @dev ULID generator for batch IDs
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDLen is the length of a ULID string.
+const ULIDLen = 26
+
+var ulidState struct {
+	mu   sync.Mutex
+	ms   int64    // millisecond of the last ULID
+	rand [10]byte // its random part
+}
+
+// NewULID returns a ULID (https://github.com/ulid/spec): a 48-bit millisecond timestamp and 80
+// random bits in 26 characters of Crockford base32, which sort by time. IDs made in the same
+// millisecond increment the random part of the one before, so the IDs of a process sort in the
+// order they were made.
+func NewULID() string {
+	return ulidAt(time.Now())
+}
+
+func ulidAt(t time.Time) string {
+	ms := t.UnixMilli()
+	s := &ulidState
+	s.mu.Lock()
+	if ms <= s.ms && increment(&s.rand) {
+		ms = s.ms
+	} else {
+		if ms <= s.ms {
+			ms = s.ms + 1 // the random part overflowed: borrow the next millisecond
+		}
+		rand.Read(s.rand[:])
+		s.ms = ms
+	}
+	var b [16]byte
+	for i := range 6 {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(b[6:], s.rand[:])
+	s.mu.Unlock()
+	return encodeULID(b)
+}
+
+// increment adds one to the big-endian r and reports false when it overflowed.
+func increment(r *[10]byte) bool {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i]++; r[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID writes the 128 bits of b as 26 base32 characters, the first holding the two
+// leading zero bits of the 130 they cover.
+func encodeULID(b [16]byte) string {
+	var out [ULIDLen]byte
+	for i := range out {
+		v := 0
+		for j := range 5 {
+			v <<= 1
+			if bit := 5*i + j - 2; bit >= 0 && b[bit/8]&(0x80>>(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		out[i] = crockford[v]
+	}
+	return string(out[:])
+}
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestULIDEncoding(t *testing.T) {
+	// The timestamp of the spec's example, 01ARYZ6S41 = 1469918176385 ms.
+	id := ulidAt(time.UnixMilli(1469918176385))
+	if len(id) != ULIDLen || id[:10] != "01ARYZ6S41" {
+		t.Fatalf("ulid = %s, want a timestamp of 01ARYZ6S41", id)
+	}
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("largest ulid = %s", got)
+	}
+}
+
+func TestULIDsSortInOrder(t *testing.T) {
+	at := time.UnixMilli(1800000000000)
+	prev := ulidAt(at)
+	for i := 0; i < 1000; i++ {
+		next := ulidAt(at) // the same millisecond
+		if next <= prev {
+			t.Fatalf("%s after %s", next, prev)
+		}
+		prev = next
+	}
+	if earlier := ulidAt(at.Add(-time.Second)); earlier <= prev {
+		t.Errorf("a clock step back gave %s after %s", earlier, prev)
+	}
+	if later := NewULID(); later <= prev {
+		t.Errorf("NewULID %s sorts before %s", later, prev)
+	}
+}
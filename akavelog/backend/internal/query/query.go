@@ -0,0 +1,424 @@
+// Package query implements the search language of the log search API, e.g.
+//
+//	service:api AND level:error AND message:~"timeout" AND duration:>500
+//
+// Terms are field:value pairs or bare words matched against the message; adjacent terms are
+// ANDed. A query compiles to a rules expression, so it matches entries exactly as filter and
+// stream rules do.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/rules"
+)
+
+// Node operators. And, Or and Not have Children; the others compare Field with Value.
+const (
+	OpAnd      = "and"
+	OpOr       = "or"
+	OpNot      = "not"
+	OpEq       = "eq"       // field:value
+	OpPrefix   = "prefix"   // field:value*
+	OpSuffix   = "suffix"   // field:*value
+	OpContains = "contains" // field:*value*, or a bare word on the message
+	OpWildcard = "wildcard" // field:va*l?e
+	OpRegex    = "regex"    // field:~"re"
+	OpGt       = "gt"       // field:>value
+	OpGte      = "gte"      // field:>=value
+	OpLt       = "lt"       // field:<value
+	OpLte      = "lte"      // field:<=value
+	OpExists   = "exists"   // field:*
+)
+
+// DefaultField is the field bare words are searched in.
+const DefaultField = "message"
+
+// MaxDepth bounds how deeply parentheses and NOTs nest, so that a crafted query cannot
+// exhaust the stack of the recursive parser.
+const MaxDepth = 100
+
+// Node is a node of the parse tree.
+type Node struct {
+	Op       string  `json:"op"`
+	Field    string  `json:"field,omitempty"`
+	Value    string  `json:"value,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+	Pos      int     `json:"pos"` // byte offset in the source
+}
+
+// Query is a parsed query. It is immutable and safe for concurrent use.
+type Query struct {
+	src  string
+	root *Node
+	rule string
+	expr *rules.Expr
+}
+
+// String returns the source the query was parsed from.
+func (q *Query) String() string { return q.src }
+
+// Tree returns the parse tree; nil for the empty query, which matches everything.
+func (q *Query) Tree() *Node { return q.root }
+
+// Rule returns the rules expression the query compiles to; "" for the empty query.
+func (q *Query) Rule() string { return q.rule }
+
+// Match reports whether the entry whose fields get returns matches the query.
+func (q *Query) Match(get rules.Getter) bool {
+	if q.expr == nil {
+		return true
+	}
+	return q.expr.Match(get)
+}
+
+// MatchEntry reports whether e matches the query. Fields are looked up as by filter rules:
+// the entry's own fields, then its tags.
+func (q *Query) MatchEntry(e *model.LogEntry) bool {
+	return q.Match(func(field string) (string, bool) { return processors.GetField(e, field) })
+}
+
+// SyntaxError reports where a query failed to parse.
+type SyntaxError struct {
+	Pos int // byte offset in the source
+	Msg string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg) }
+
+// Parse parses and compiles src. An empty or blank query matches every entry.
+func Parse(src string) (*Query, error) {
+	p := &parser{src: src}
+	q := &Query{src: src}
+	p.skipSpace()
+	if p.eof() {
+		return q, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); !p.eof() {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	}
+	q.root = root
+	var b strings.Builder
+	if err := compile(&b, root); err != nil {
+		return nil, err
+	}
+	q.rule = b.String()
+	if q.expr, err = rules.Parse(q.rule); err != nil {
+		return nil, &SyntaxError{Pos: 0, Msg: err.Error()}
+	}
+	return q, nil
+}
+
+// compile writes n as a rules expression.
+func compile(b *strings.Builder, n *Node) error {
+	switch n.Op {
+	case OpAnd, OpOr:
+		b.WriteByte('(')
+		for i, c := range n.Children {
+			if i > 0 {
+				b.WriteString(" " + n.Op + " ")
+			}
+			if err := compile(b, c); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+		return nil
+	case OpNot:
+		b.WriteString("not ")
+		return compile(b, n.Children[0])
+	case OpExists:
+		b.WriteString("exists(" + n.Field + ")")
+		return nil
+	case OpWildcard:
+		re := "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(n.Value)) + "$"
+		b.WriteString(n.Field + " =~ " + quote(re))
+		return nil
+	case OpRegex:
+		if _, err := regexp.Compile(n.Value); err != nil {
+			return &SyntaxError{Pos: n.Pos, Msg: "invalid regex: " + err.Error()}
+		}
+	}
+	op, ok := ruleOps[n.Op]
+	if !ok {
+		return &SyntaxError{Pos: n.Pos, Msg: "unknown operator " + n.Op}
+	}
+	b.WriteString(n.Field + " " + op + " " + quote(n.Value))
+	return nil
+}
+
+var ruleOps = map[string]string{
+	OpEq: "==", OpPrefix: "startswith", OpSuffix: "endswith", OpContains: "contains",
+	OpRegex: "=~", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<=",
+}
+
+// quote quotes s for the rules lexer, which only unescapes \" and \\.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+type parser struct {
+	src   string
+	pos   int
+	depth int // of parentheses and NOTs around pos
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// keyword consumes one of words (case-insensitive, followed by a space or parenthesis).
+func (p *parser) keyword(words ...string) bool {
+	p.skipSpace()
+	for _, w := range words {
+		end := p.pos + len(w)
+		if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], w) {
+			continue
+		}
+		if isSymbol(w) || end == len(p.src) || strings.IndexByte(" \t\r\n()", p.src[end]) >= 0 {
+			p.pos = end
+			return true
+		}
+	}
+	return false
+}
+
+func isSymbol(w string) bool { return w == "&&" || w == "||" || w == "!" || w == "-" }
+
+func (p *parser) parseOr() (*Node, error) {
+	start := p.pos
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Node{l}
+	for p.keyword("OR", "||") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, r)
+	}
+	if len(children) == 1 {
+		return l, nil
+	}
+	return &Node{Op: OpOr, Children: children, Pos: start}, nil
+}
+
+func (p *parser) parseAnd() (*Node, error) {
+	start := p.pos
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Node{l}
+	for {
+		if !p.keyword("AND", "&&") {
+			// Adjacent terms are ANDed too.
+			if p.skipSpace(); p.eof() || p.src[p.pos] == ')' || p.peekKeyword("OR", "||") {
+				break
+			}
+		}
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, r)
+	}
+	if len(children) == 1 {
+		return l, nil
+	}
+	return &Node{Op: OpAnd, Children: children, Pos: start}, nil
+}
+
+func (p *parser) peekKeyword(words ...string) bool {
+	pos := p.pos
+	ok := p.keyword(words...)
+	p.pos = pos
+	return ok
+}
+
+func (p *parser) parseNot() (*Node, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.keyword("NOT", "!", "-") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpNot, Children: []*Node{n}, Pos: start}, nil
+	}
+	return p.parsePrimary()
+}
+
+// enter descends into a nested expression, refusing more than MaxDepth levels.
+func (p *parser) enter() error {
+	if p.depth++; p.depth > MaxDepth {
+		return p.errorf("query nested more than %d levels deep", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) parsePrimary() (*Node, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of query")
+	}
+	start := p.pos
+	switch c := p.src[p.pos]; {
+	case c == '(':
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.eof() || p.src[p.pos] != ')' {
+			return nil, p.errorf("expected )")
+		}
+		p.pos++
+		return n, nil
+	case c == ')':
+		return nil, p.errorf("unexpected )")
+	case c == '"' || c == '\'':
+		s, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpContains, Field: DefaultField, Value: s, Pos: start}, nil
+	}
+	word := p.readWord(false)
+	if word == "" {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	}
+	if p.eof() || p.src[p.pos] != ':' {
+		n := wildcard(DefaultField, word, start)
+		if n.Op == OpEq {
+			n.Op = OpContains // a bare word is searched for, not matched whole
+		}
+		return n, nil
+	}
+	if !fieldName.MatchString(word) {
+		return nil, &SyntaxError{Pos: start, Msg: fmt.Sprintf("invalid field name %q", word)}
+	}
+	p.pos++ // the colon
+	return p.parseValue(word, start)
+}
+
+var fieldName = regexp.MustCompile(`^[A-Za-z_@][A-Za-z0-9_.@/-]*$`)
+
+// parseValue reads the operator and value of a field:value term.
+func (p *parser) parseValue(field string, start int) (*Node, error) {
+	op := OpEq
+	for _, o := range []struct{ text, op string }{{"~", OpRegex}, {">=", OpGte}, {"<=", OpLte}, {">", OpGt}, {"<", OpLt}} {
+		if strings.HasPrefix(p.src[p.pos:], o.text) {
+			op = o.op
+			p.pos += len(o.text)
+			break
+		}
+	}
+	if p.eof() {
+		return nil, p.errorf("expected value after %s:", field)
+	}
+	if c := p.src[p.pos]; c == '"' || c == '\'' {
+		s, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: op, Field: field, Value: s, Pos: start}, nil
+	}
+	word := p.readWord(true)
+	if word == "" {
+		return nil, p.errorf("expected value after %s:", field)
+	}
+	if op == OpEq {
+		return wildcard(field, word, start), nil
+	}
+	return &Node{Op: op, Field: field, Value: word, Pos: start}, nil
+}
+
+// wildcard turns an unquoted value with * or ? into the matching node.
+func wildcard(field, v string, pos int) *Node {
+	n := &Node{Op: OpEq, Field: field, Value: v, Pos: pos}
+	if !strings.ContainsAny(v, "*?") {
+		return n
+	}
+	inner := strings.Trim(v, "*")
+	switch {
+	case inner == "":
+		n.Op, n.Value = OpExists, ""
+	case strings.ContainsAny(inner, "*?"):
+		n.Op = OpWildcard
+	case strings.HasPrefix(v, "*") && strings.HasSuffix(v, "*"):
+		n.Op, n.Value = OpContains, inner
+	case strings.HasPrefix(v, "*"):
+		n.Op, n.Value = OpSuffix, inner
+	default:
+		n.Op, n.Value = OpPrefix, inner
+	}
+	return n
+}
+
+// readWord reads an unquoted word up to a space, parenthesis or quote, and up to a colon
+// unless colons is set (values such as times contain them).
+func (p *parser) readWord(colons bool) string {
+	start := p.pos
+	for !p.eof() {
+		c := p.src[p.pos]
+		if strings.IndexByte(" \t\r\n()\"'", c) >= 0 || (c == ':' && !colons) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// readString reads a quoted string; a backslash escapes the quote or itself and is kept
+// before anything else, so regexes need no double escaping.
+func (p *parser) readString() (string, error) {
+	start := p.pos
+	q := p.src[p.pos]
+	var b strings.Builder
+	for p.pos++; !p.eof(); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			if n := p.src[p.pos]; n != q && n != '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(p.src[p.pos])
+		case c == q:
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
@@ -0,0 +1,115 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestMatch(t *testing.T) {
+	e := &model.LogEntry{
+		Service: "api", Level: "error", Message: "upstream timeout after 30s",
+		Tags: map[string]string{"duration": "812", "http.path": "/v1/users", "region": "eu-west-1"},
+	}
+	cases := map[string]bool{
+		``: true,
+		`service:api AND level:error AND message:~"timeout" AND duration:>500`: true,
+		`service:api level:error`:       true, // implicit AND
+		`service:web OR level:error`:    true,
+		`service:web OR level:warn`:     false,
+		`timeout`:                       true, // bare words search the message
+		`"after 30s"`:                   true,
+		`-timeout`:                      false,
+		`NOT service:api`:               false,
+		`!(service:web)`:                true,
+		`duration:>=812 duration:<=812`: true,
+		`duration:<500`:                 false,
+		`level:>warn`:                   true, // levels compare by rank
+		`http.path:/v1/*`:               true,
+		`http.path:*users`:              true,
+		`message:*timeout*`:             true,
+		`region:eu-*-1`:                 true,
+		`region:eu-?est-1`:              true,
+		`region:us-*`:                   false,
+		`trace_id:*`:                    false,
+		`region:*`:                      true,
+		`message:~'\d+s$'`:              true,
+		`service:"api" and (level:warn or level:error)`: true,
+		`service:api AND (level:warn OR duration:<100)`: false,
+	}
+	for src, want := range cases {
+		q, err := Parse(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if got := q.MatchEntry(e); got != want {
+			t.Errorf("%s (rule %s) = %v, want %v", src, q.Rule(), got, want)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	q, err := Parse(`service:api AND NOT level:debug OR timeout`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := q.Tree()
+	if root.Op != OpOr || len(root.Children) != 2 {
+		t.Fatalf("root = %+v", root)
+	}
+	and := root.Children[0]
+	if and.Op != OpAnd || and.Children[0].Field != "service" || and.Children[1].Op != OpNot {
+		t.Fatalf("and = %+v", and)
+	}
+	if bare := root.Children[1]; bare.Op != OpContains || bare.Field != DefaultField || bare.Value != "timeout" || bare.Pos != 35 {
+		t.Fatalf("bare term = %+v", bare)
+	}
+	if want := `((service == "api" and not level == "debug") or message contains "timeout")`; q.Rule() != want {
+		t.Fatalf("rule = %s, want %s", q.Rule(), want)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	cases := map[string]int{
+		`service:`:             8,
+		`(service:api`:         12,
+		`service:api)`:         11,
+		`message:~"(unclosed"`: 0,
+		`"open`:                0,
+		`service:api AND`:      15,
+		`9lives:x`:             0,
+	}
+	for src, pos := range cases {
+		_, err := Parse(src)
+		var syn *SyntaxError
+		if !errors.As(err, &syn) {
+			t.Errorf("%s: err = %v, want a SyntaxError", src, err)
+			continue
+		}
+		if syn.Pos != pos {
+			t.Errorf("%s: error at %d (%v), want %d", src, syn.Pos, err, pos)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(n int, open, close string) string {
+		return strings.Repeat(open, n) + "service:api" + strings.Repeat(close, n)
+	}
+	if _, err := Parse(nested(MaxDepth, "(", ")")); err != nil {
+		t.Errorf("%d levels: %v", MaxDepth, err)
+	}
+	for _, src := range []string{
+		nested(MaxDepth+1, "(", ")"),
+		nested(1e6, "(", ")"),
+		nested(1e6, "NOT ", ""),
+	} {
+		var syn *SyntaxError
+		if _, err := Parse(src); !errors.As(err, &syn) {
+			t.Errorf("%d bytes deep: err = %v, want a SyntaxError", len(src), err)
+		}
+	}
+}
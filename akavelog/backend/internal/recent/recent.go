@@ -0,0 +1,179 @@
+// Package recent keeps the latest entries accepted by the inputs in memory, for GET
+// /logs/recent, GET /inputs/:id/recent and the backlog of GET /logs/tail.
+package recent
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Defaults of a Store.
+const (
+	DefaultCapacity = 1000
+	DefaultPerInput = 100
+)
+
+// Entry is a kept entry with where and when it was received.
+type Entry struct {
+	Seq      uint64         `json:"seq"`                // increasing per process; the cursor of List
+	InputID  string         `json:"input_id,omitempty"` // empty for entries of no input
+	Entry    model.LogEntry `json:"entry"`
+	Received time.Time      `json:"received_at"`
+}
+
+// Filter selects entries of List. Zero fields select everything.
+type Filter struct {
+	InputID string    // entries of this input, from its own ring
+	Service string    // service, ignoring case
+	Level   string    // level, ignoring case
+	Since   time.Time // received at or after
+	Before  uint64    // older than this Seq: the cursor of the next page
+	After   uint64    // newer than this Seq, for polling
+	Limit   int       // at most this many: the newest, or with After the oldest
+}
+
+// Store keeps the latest Capacity entries, and the latest PerInput entries of every input on
+// their own, so a quiet input's entries are not pushed out by a busy one. It is safe for
+// concurrent use.
+type Store struct {
+	capacity int
+	perInput int
+
+	mu     sync.RWMutex
+	seq    uint64
+	all    ring
+	inputs map[string]*ring
+}
+
+// NewStore returns a store keeping capacity entries and perInput entries per input. Values
+// <= 0 take their defaults.
+func NewStore(capacity, perInput int) *Store {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if perInput <= 0 {
+		perInput = DefaultPerInput
+	}
+	return &Store{capacity: capacity, perInput: perInput, all: newRing(capacity), inputs: map[string]*ring{}}
+}
+
+// Capacity returns how many entries the store keeps in all, and per input.
+func (s *Store) Capacity() (all, perInput int) {
+	return s.capacity, s.perInput
+}
+
+// Add keeps a copy of e, received from inputID ("" when it came from no input).
+func (s *Store) Add(inputID string, e *model.LogEntry) {
+	if e == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	entry := Entry{Seq: s.seq, InputID: inputID, Entry: *e, Received: time.Now().UTC()}
+	s.all.push(entry)
+	if inputID == "" {
+		return
+	}
+	r, ok := s.inputs[inputID]
+	if !ok {
+		nr := newRing(s.perInput)
+		r = &nr
+		s.inputs[inputID] = r
+	}
+	r.push(entry)
+}
+
+// Forget drops the entries kept for inputID on its own, e.g. once the input is deleted.
+func (s *Store) Forget(inputID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inputs, inputID)
+}
+
+// List returns the kept entries that pass f, oldest first, and whether older ones passing f
+// are kept too (then pass the Seq of the first entry as Before for the next page).
+func (s *Store) List(f Filter) ([]Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := &s.all
+	if f.InputID != "" {
+		if r = s.inputs[f.InputID]; r == nil {
+			return []Entry{}, false
+		}
+	}
+	out := []Entry{}
+	more := false
+	if f.After > 0 {
+		// Oldest first from After, for polling.
+		for i := 0; i < r.n; i++ {
+			e := r.at(i)
+			if e.Seq <= f.After || (f.Before > 0 && e.Seq >= f.Before) || !f.match(e) {
+				continue
+			}
+			out = append(out, e)
+			if f.Limit > 0 && len(out) == f.Limit {
+				break
+			}
+		}
+		return out, false
+	}
+	// Newest first, reversed below, so the page ends at the newest entry.
+	for i := r.n - 1; i >= 0; i-- {
+		e := r.at(i)
+		if (f.Before > 0 && e.Seq >= f.Before) || !f.match(e) {
+			continue
+		}
+		if f.Limit > 0 && len(out) == f.Limit {
+			more = true
+			break
+		}
+		out = append(out, e)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, more
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.Service != "" && !strings.EqualFold(e.Entry.Service, f.Service):
+		return false
+	case f.Level != "" && !strings.EqualFold(e.Entry.Level, f.Level):
+		return false
+	case !f.Since.IsZero() && e.Received.Before(f.Since):
+		return false
+	case f.InputID != "" && e.InputID != f.InputID:
+		return false
+	}
+	return true
+}
+
+// ring holds the latest len(buf) entries, the oldest at buf[start].
+type ring struct {
+	buf      []Entry
+	start, n int
+}
+
+func newRing(size int) ring {
+	return ring{buf: make([]Entry, size)}
+}
+
+func (r *ring) push(e Entry) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = e
+		r.n++
+		return
+	}
+	r.buf[r.start] = e
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// at returns the i-th oldest entry.
+func (r *ring) at(i int) Entry {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
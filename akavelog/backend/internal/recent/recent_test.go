@@ -0,0 +1,100 @@
+package recent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func seqs(list []Entry) []uint64 {
+	out := make([]uint64, len(list))
+	for i, e := range list {
+		out[i] = e.Seq
+	}
+	return out
+}
+
+func TestStoreKeepsTheLatest(t *testing.T) {
+	s := NewStore(4, 2)
+	for i := 1; i <= 6; i++ {
+		input := "a"
+		if i%2 == 0 {
+			input = "b"
+		}
+		s.Add(input, &model.LogEntry{Service: "api", Level: "info", Message: fmt.Sprint(i)})
+	}
+	list, more := s.List(Filter{})
+	if got := fmt.Sprint(seqs(list)); got != "[3 4 5 6]" || more {
+		t.Fatalf("all = %s (more %v), want [3 4 5 6]", got, more)
+	}
+	list, _ = s.List(Filter{InputID: "a"})
+	if got := fmt.Sprint(seqs(list)); got != "[3 5]" {
+		t.Errorf("input a = %s, want [3 5]", got)
+	}
+	if list[0].InputID != "a" || list[0].Entry.Message != "3" || list[0].Received.IsZero() {
+		t.Errorf("entry = %+v", list[0])
+	}
+	s.Forget("a")
+	if list, _ := s.List(Filter{InputID: "a"}); len(list) != 0 {
+		t.Errorf("forgotten input = %v", seqs(list))
+	}
+}
+
+func TestStoreQuietInputSurvives(t *testing.T) {
+	s := NewStore(3, 2)
+	s.Add("quiet", &model.LogEntry{Service: "cron", Message: "ran"})
+	for i := 0; i < 10; i++ {
+		s.Add("busy", &model.LogEntry{Service: "api", Message: "hit"})
+	}
+	if list, _ := s.List(Filter{Service: "cron"}); len(list) != 0 {
+		t.Errorf("the global ring still holds the quiet entry: %v", seqs(list))
+	}
+	if list, _ := s.List(Filter{InputID: "quiet"}); len(list) != 1 || list[0].Entry.Service != "cron" {
+		t.Errorf("quiet input = %+v, want its entry", list)
+	}
+}
+
+func TestStoreFilterAndCursor(t *testing.T) {
+	s := NewStore(10, 10)
+	levels := []string{"info", "error", "info", "ERROR", "info", "error"}
+	for i, level := range levels {
+		service := "api"
+		if i == 5 {
+			service = "web"
+		}
+		s.Add("in", &model.LogEntry{Service: service, Level: level, Message: "m"})
+	}
+
+	list, _ := s.List(Filter{Level: "error"})
+	if got := fmt.Sprint(seqs(list)); got != "[2 4 6]" {
+		t.Errorf("level error = %s, want [2 4 6]", got)
+	}
+	list, _ = s.List(Filter{Level: "error", Service: "API"})
+	if got := fmt.Sprint(seqs(list)); got != "[2 4]" {
+		t.Errorf("api errors = %s, want [2 4]", got)
+	}
+	if list, _ := s.List(Filter{Since: time.Now().Add(time.Minute)}); len(list) != 0 {
+		t.Errorf("since the future = %v", seqs(list))
+	}
+
+	// Pages of 2, newest first, each oldest first.
+	page, more := s.List(Filter{Limit: 2})
+	if got := fmt.Sprint(seqs(page)); got != "[5 6]" || !more {
+		t.Fatalf("page 1 = %s (more %v)", got, more)
+	}
+	page, more = s.List(Filter{Limit: 2, Before: page[0].Seq})
+	if got := fmt.Sprint(seqs(page)); got != "[3 4]" || !more {
+		t.Fatalf("page 2 = %s (more %v)", got, more)
+	}
+	page, more = s.List(Filter{Limit: 2, Before: page[0].Seq})
+	if got := fmt.Sprint(seqs(page)); got != "[1 2]" || more {
+		t.Fatalf("page 3 = %s (more %v)", got, more)
+	}
+
+	page, _ = s.List(Filter{After: 4, Limit: 1})
+	if got := fmt.Sprint(seqs(page)); got != "[5]" {
+		t.Errorf("after 4 = %s, want [5]", got)
+	}
+}
@@ -0,0 +1,396 @@
+// Package replay reads archived entries back from O3 and runs them through the pipelines
+// again, handing what they keep to the outputs and, optionally, to the batcher for the streams
+// they are now routed to. It fills an output or stream added after the entries were ingested,
+// or one a misconfigured processor kept them from.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTooManyJobs is returned by Start when MaxRunning replays are running.
+	ErrTooManyJobs = errors.New("too many running replays")
+	// ErrNoTarget is returned when a replay would hand its entries to nothing: no output is
+	// running and it does not archive.
+	ErrNoTarget = errors.New("no output is running and archive is off")
+	// ErrNoArchive is returned for a replay that archives when there is no batcher.
+	ErrNoArchive = errors.New("archive needs O3 storage")
+	// ErrUnknownOutput is returned for a replay to an output that is not running.
+	ErrUnknownOutput = errors.New("output not running")
+)
+
+// Pipelines runs entries through the processors of an input (pipeline.Manager).
+type Pipelines interface {
+	ProcessContext(ctx context.Context, inputID uuid.UUID, e *model.LogEntry) bool
+}
+
+// Outputs hands entries to the running outputs (outputs.Dispatcher).
+type Outputs interface {
+	Active() bool
+	Running(name string) bool
+	DispatchWait(ctx context.Context, e *model.LogEntry, only []string) error
+}
+
+// Targets are where replayed entries go.
+type Targets struct {
+	Pipelines Pipelines
+	Outputs   Outputs
+	// Archive receives, for Request.Archive, the entries the pipelines now route to another O3
+	// prefix than before; nil when there is no batcher.
+	Archive inputs.InputBuffer
+	// KeyPrefix returns the O3 prefix of an entry's streams, "" for logs/
+	// (streams.Router.KeyPrefix).
+	KeyPrefix func(e *model.LogEntry) string
+}
+
+// Config bounds the replays of a Manager. Zero fields take their defaults.
+type Config struct {
+	MaxRunning   int           // replays running at once (default 1)
+	MaxObjects   int           // batch objects one replay reads at most (default 10000)
+	MaxScanBytes int64         // stored size of the objects one replay reads (default 10 GiB)
+	Timeout      time.Duration // a replay is canceled after this long (default 1h)
+	TTL          time.Duration // finished replays are forgotten this long after (default 24h)
+}
+
+func (c *Config) defaults() {
+	if c.MaxRunning <= 0 {
+		c.MaxRunning = 1
+	}
+	if c.MaxObjects <= 0 {
+		c.MaxObjects = 10000
+	}
+	if c.MaxScanBytes <= 0 {
+		c.MaxScanBytes = 10 << 30
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = time.Hour
+	}
+	if c.TTL <= 0 {
+		c.TTL = 24 * time.Hour
+	}
+}
+
+// Request describes a replay.
+type Request struct {
+	Query     *query.Query // nil replays every entry
+	ProjectID string
+	Start     time.Time
+	End       time.Time
+	InputID   uuid.UUID // entries run through this input's pipelines; uuid.Nil for the global ones
+	Outputs   []string  // only these outputs, among those an entry is routed to; nil for all
+	Archive   bool      // store entries routed to another O3 prefix than before under it
+}
+
+// Status is the state of a Job.
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Counts tells what a replay did with the entries it read.
+type Counts struct {
+	search.ScanStats
+	Replayed int `json:"replayed"` // kept by the pipelines and handed on
+	Dropped  int `json:"dropped"`  // dropped by a processor
+	Archived int `json:"archived"` // stored under a stream's O3 prefix
+}
+
+// Job is a replay running or finished. Its state is read with Snapshot.
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	req        Request
+	status     Status
+	err        error
+	counts     Counts
+	createdAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// ID returns the job's ID.
+func (j *Job) ID() string { return j.id }
+
+// Done is closed when the job has finished.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Snapshot is the state of a Job at one point.
+type Snapshot struct {
+	ID         string     `json:"id"`
+	Status     Status     `json:"status"`
+	Query      string     `json:"query"`
+	ProjectID  string     `json:"project_id,omitempty"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	InputID    *uuid.UUID `json:"input_id,omitempty"`
+	Outputs    []string   `json:"outputs,omitempty"`
+	Archive    bool       `json:"archive"`
+	Counts     Counts     `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Snapshot returns the state of j.
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := Snapshot{
+		ID:        j.id,
+		Status:    j.status,
+		ProjectID: j.req.ProjectID,
+		Start:     j.req.Start,
+		End:       j.req.End,
+		Outputs:   j.req.Outputs,
+		Archive:   j.req.Archive,
+		Counts:    j.counts,
+		CreatedAt: j.createdAt,
+	}
+	if j.req.Query != nil {
+		s.Query = j.req.Query.String()
+	}
+	if j.req.InputID != uuid.Nil {
+		id := j.req.InputID
+		s.InputID = &id
+	}
+	if j.err != nil {
+		s.Error = j.err.Error()
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		s.FinishedAt = &finished
+	}
+	return s
+}
+
+// Manager runs replays in the background. It is safe for concurrent use.
+type Manager struct {
+	cfg     Config
+	index   search.Index
+	logs    search.Store
+	targets Targets
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager returns a manager reading entries with index and logs and replaying them to
+// targets.
+func NewManager(cfg Config, index search.Index, logs search.Store, targets Targets) *Manager {
+	cfg.defaults()
+	return &Manager{cfg: cfg, index: index, logs: logs, targets: targets, jobs: make(map[string]*Job)}
+}
+
+// Check returns why req cannot run, or nil.
+func (m *Manager) Check(req Request) error {
+	if req.Archive && m.targets.Archive == nil {
+		return ErrNoArchive
+	}
+	if !req.Archive && (m.targets.Outputs == nil || !m.targets.Outputs.Active()) {
+		return ErrNoTarget
+	}
+	for _, name := range req.Outputs {
+		if m.targets.Outputs == nil || !m.targets.Outputs.Running(name) {
+			return fmt.Errorf("%w: %q", ErrUnknownOutput, name)
+		}
+	}
+	return nil
+}
+
+// Start runs a replay in the background.
+func (m *Manager) Start(req Request) (*Job, error) {
+	if err := m.Check(req); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running := 0
+	now := time.Now()
+	for id, j := range m.jobs {
+		j.mu.Lock()
+		if j.status == StatusRunning {
+			running++
+		}
+		expired := !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > m.cfg.TTL
+		j.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+	if running >= m.cfg.MaxRunning {
+		return nil, ErrTooManyJobs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	j := &Job{
+		id:        uuid.NewString(),
+		req:       req,
+		status:    StatusRunning,
+		createdAt: now.UTC(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.jobs[j.id] = j
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		counts, err := m.Run(ctx, req, func(c Counts) {
+			j.mu.Lock()
+			j.counts = c
+			j.mu.Unlock()
+		})
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.counts, j.finishedAt = counts, time.Now().UTC()
+		switch {
+		case err == nil:
+			j.status = StatusDone
+		case j.status == StatusCanceled:
+		case errors.Is(err, context.DeadlineExceeded):
+			j.status, j.err = StatusFailed, fmt.Errorf("replay timed out after %s: %w", m.cfg.Timeout, err)
+		default:
+			j.status, j.err = StatusFailed, err
+		}
+	}()
+	return j, nil
+}
+
+// Run replays req in the calling goroutine, oldest object first, calling progress after each
+// object. Entries already handed on stay delivered when it fails.
+func (m *Manager) Run(ctx context.Context, req Request, progress func(Counts)) (Counts, error) {
+	if err := m.Check(req); err != nil {
+		return Counts{}, err
+	}
+	var c Counts
+	var herr error
+	st, err := search.Scan(ctx, m.index, m.logs, search.ScanOptions{
+		ProjectID:  req.ProjectID,
+		Start:      req.Start,
+		End:        req.End,
+		Query:      req.Query,
+		MaxObjects: m.cfg.MaxObjects,
+		MaxBytes:   m.cfg.MaxScanBytes,
+		Progress: func(st search.ScanStats) {
+			if progress != nil {
+				c.ScanStats = st
+				progress(c)
+			}
+		},
+	}, func(e *model.LogEntry, _ time.Time) bool {
+		herr = m.replay(ctx, req, e, &c)
+		return herr == nil
+	})
+	c.ScanStats = st
+	if err == nil {
+		err = herr
+	}
+	return c, err
+}
+
+// replay runs one entry through the pipelines and hands it on.
+func (m *Manager) replay(ctx context.Context, req Request, e *model.LogEntry, c *Counts) error {
+	var before string
+	if req.Archive && m.targets.KeyPrefix != nil {
+		before = m.targets.KeyPrefix(e)
+	}
+	if m.targets.Pipelines != nil && !m.targets.Pipelines.ProcessContext(ctx, req.InputID, e) {
+		c.Dropped++
+		return nil
+	}
+	c.Replayed++
+	if m.targets.Outputs != nil {
+		if err := m.targets.Outputs.DispatchWait(ctx, e, req.Outputs); err != nil {
+			return err
+		}
+	}
+	if !req.Archive || m.targets.KeyPrefix == nil {
+		return nil
+	}
+	// Entries whose prefix is unchanged are already stored there.
+	if after := m.targets.KeyPrefix(e); after == "" || after == before {
+		return nil
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+	if err := inputs.InsertContext(ctx, m.targets.Archive, raw); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	c.Archived++
+	return nil
+}
+
+// Get returns the replay with the given ID, or nil.
+func (m *Manager) Get(id string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id]
+}
+
+// List returns the replays this process knows of, newest first.
+func (m *Manager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].createdAt.After(out[b].createdAt) })
+	return out
+}
+
+// Cancel stops a running replay or forgets a finished one. It reports whether the replay
+// existed.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	j := m.jobs[id]
+	delete(m.jobs, id)
+	m.mu.Unlock()
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	if j.status == StatusRunning {
+		j.status = StatusCanceled
+	}
+	j.mu.Unlock()
+	j.cancel()
+	return true
+}
+
+// Stop cancels every running replay and waits for them to return.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		if j.status == StatusRunning {
+			j.status = StatusCanceled
+		}
+		j.mu.Unlock()
+		j.cancel()
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+	for _, j := range jobs {
+		<-j.done
+	}
+}
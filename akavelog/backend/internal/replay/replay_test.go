@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/google/uuid"
+)
+
+type memIndex []model.Batch
+
+func (x memIndex) Find(context.Context, repository.BatchFilter) ([]model.Batch, error) {
+	return x, nil
+}
+
+type memLogs map[string][]model.LogEntry
+
+func (s memLogs) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	out := make([]model.LogEntry, len(s[key]))
+	copy(out, s[key])
+	return out, nil
+}
+
+// dropDebug drops debug entries and routes audit entries to the stream "audit".
+type dropDebug struct{ inputs []uuid.UUID }
+
+func (p *dropDebug) ProcessContext(_ context.Context, inputID uuid.UUID, e *model.LogEntry) bool {
+	p.inputs = append(p.inputs, inputID)
+	if e.Service == "audit" {
+		e.Tags = map[string]string{"prefix": "audit"}
+	}
+	return e.Level != "debug"
+}
+
+type memOutputs struct {
+	active bool
+	got    []string
+	only   []string
+}
+
+func (o *memOutputs) Active() bool { return o.active }
+
+func (o *memOutputs) Running(name string) bool { return o.active && name == "siem" }
+
+func (o *memOutputs) DispatchWait(ctx context.Context, e *model.LogEntry, only []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o.got, o.only = append(o.got, e.Message), only
+	return nil
+}
+
+type memArchive struct{ got []string }
+
+func (a *memArchive) Insert(p []byte) error {
+	var e model.LogEntry
+	if err := json.Unmarshal(p, &e); err != nil {
+		return err
+	}
+	a.got = append(a.got, e.Message)
+	return nil
+}
+
+func fixture() (memIndex, memLogs) {
+	return memIndex{{Key: "a"}, {Key: "b"}}, memLogs{
+		"a": {
+			{Timestamp: "2026-03-01T10:00:00Z", Service: "api", Level: "error", Message: "one"},
+			{Timestamp: "2026-03-01T10:05:00Z", Service: "api", Level: "debug", Message: "two"},
+		},
+		"b": {
+			{Timestamp: "2026-03-01T11:00:00Z", Service: "audit", Level: "info", Message: "three"},
+			{Timestamp: "2026-03-01T11:05:00Z", Service: "audit", Level: "info", Message: "four", Tags: map[string]string{"prefix": "audit"}},
+			{Timestamp: "2026-03-03T00:00:00Z", Service: "api", Level: "error", Message: "later"},
+		},
+	}
+}
+
+func request() Request {
+	return Request{Start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestRun(t *testing.T) {
+	index, logs := fixture()
+	pipelines, outputs, archive := &dropDebug{}, &memOutputs{active: true}, &memArchive{}
+	m := NewManager(Config{}, index, logs, Targets{
+		Pipelines: pipelines,
+		Outputs:   outputs,
+		Archive:   archive,
+		KeyPrefix: func(e *model.LogEntry) string { return e.Tags["prefix"] },
+	})
+	input := uuid.New()
+	req := request()
+	req.InputID, req.Outputs, req.Archive = input, []string{"siem"}, true
+	var calls int
+	c, err := m.Run(context.Background(), req, func(Counts) { calls++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Objects != 2 || c.Matched != 4 || c.Replayed != 3 || c.Dropped != 1 || c.Archived != 1 || calls != 2 {
+		t.Errorf("counts %+v, %d progress calls", c, calls)
+	}
+	if len(outputs.got) != 3 || outputs.got[0] != "one" || outputs.got[2] != "four" || len(outputs.only) != 1 {
+		t.Errorf("outputs got %v for %v", outputs.got, outputs.only)
+	}
+	// "four" was stored under audit/ already.
+	if len(archive.got) != 1 || archive.got[0] != "three" {
+		t.Errorf("archived %v", archive.got)
+	}
+	for _, id := range pipelines.inputs {
+		if id != input {
+			t.Errorf("ran the pipelines of %v", id)
+		}
+	}
+
+	q, err := query.Parse("service:api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs.got = nil
+	req = request()
+	req.Query = q
+	if c, err = m.Run(context.Background(), req, nil); err != nil || c.Replayed != 1 || len(outputs.got) != 1 {
+		t.Errorf("query replay: %+v, %v, %v", c, outputs.got, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	index, logs := fixture()
+	outputs := &memOutputs{}
+	m := NewManager(Config{}, index, logs, Targets{Outputs: outputs})
+	if _, err := m.Start(request()); !errors.Is(err, ErrNoTarget) {
+		t.Errorf("replay without outputs: %v", err)
+	}
+	req := request()
+	req.Archive = true
+	if _, err := m.Start(req); !errors.Is(err, ErrNoArchive) {
+		t.Errorf("archive without a batcher: %v", err)
+	}
+	outputs.active = true
+	req = request()
+	req.Outputs = []string{"siem", "gone"}
+	if _, err := m.Start(req); !errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("replay to an output not running: %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	index, logs := fixture()
+	m := NewManager(Config{}, index, logs, Targets{Outputs: &memOutputs{active: true}})
+	j, err := m.Start(request())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-j.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay did not finish")
+	}
+	s := j.Snapshot()
+	if s.Status != StatusDone || s.Counts.Replayed != 4 || s.FinishedAt == nil {
+		t.Errorf("snapshot %+v", s)
+	}
+	if m.Get(j.ID()) != j || len(m.List()) != 1 {
+		t.Error("job not listed")
+	}
+	if !m.Cancel(j.ID()) || m.Get(j.ID()) != nil || m.Cancel(j.ID()) {
+		t.Error("cancel of a finished replay")
+	}
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/search"
+)
+
+// Result is what one run of a report computed; every format renders it.
+type Result struct {
+	Report       string            `json:"report"`
+	Description  string            `json:"description,omitempty"`
+	SavedSearch  string            `json:"saved_search"`
+	Query        string            `json:"query"`
+	ProjectID    string            `json:"project_id,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	GeneratedAt  time.Time         `json:"generated_at"`
+	GroupBy      []string          `json:"group_by,omitempty"` // the order of the group values
+	Aggregations search.Aggregates `json:"aggregations"`
+	Stats        search.ScanStats  `json:"stats"`
+}
+
+// Render returns res in format f and its content type.
+func Render(res *Result, f model.ReportFormat) ([]byte, string, error) {
+	switch f {
+	case model.ReportJSON:
+		data, err := json.MarshalIndent(res, "", "  ")
+		return data, "application/json", err
+	case model.ReportCSV:
+		data, err := renderCSV(res)
+		return data, "text/csv; charset=utf-8", err
+	case model.ReportHTML:
+		data, err := renderHTML(res)
+		return data, "text/html; charset=utf-8", err
+	}
+	return nil, "", fmt.Errorf("unknown report format %q", f)
+}
+
+// renderCSV writes one row per number with the columns section, field, value and count:
+// the total, each histogram bucket (by its start), each group (fields and values joined by
+// commas) and each top value. (other) and (missing) count the rest.
+func renderCSV(res *Result) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	row := func(section, field, value string, count int) {
+		_ = w.Write([]string{section, field, value, strconv.Itoa(count)})
+	}
+	_ = w.Write([]string{"section", "field", "value", "count"})
+	agg := res.Aggregations
+	row("total", "", "", agg.Total)
+	for _, b := range agg.Histogram {
+		row("histogram", "timestamp", b.Start.UTC().Format(time.RFC3339), b.Count)
+	}
+	fields := strings.Join(res.GroupBy, ",")
+	for _, g := range agg.Groups {
+		row("group", fields, strings.Join(groupValues(res.GroupBy, g), ","), g.Count)
+	}
+	if agg.GroupsOther > 0 {
+		row("group", fields, "(other)", agg.GroupsOther)
+	}
+	for _, field := range topFields(agg) {
+		tv := agg.Top[field]
+		for _, v := range tv.Values {
+			row("top", field, v.Value, v.Count)
+		}
+		if tv.Other > 0 {
+			row("top", field, "(other)", tv.Other)
+		}
+		if tv.Missing > 0 {
+			row("top", field, "(missing)", tv.Missing)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func groupValues(groupBy []string, g search.Group) []string {
+	values := make([]string, len(groupBy))
+	for i, f := range groupBy {
+		values[i] = g.Values[f]
+	}
+	return values
+}
+
+func topFields(agg search.Aggregates) []string {
+	fields := make([]string, 0, len(agg.Top))
+	for f := range agg.Top {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// barWidth is the width in pixels of the longest histogram bar.
+const barWidth = 300
+
+type htmlBucket struct {
+	Start string
+	Count int
+	Width int
+}
+
+type htmlTop struct {
+	Field string
+	search.TopValues
+}
+
+type htmlView struct {
+	*Result
+	Range     string
+	Generated string
+	Histogram []htmlBucket
+	Groups    [][]string // values, then the count
+	Top       []htmlTop
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Report}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+td.n { text-align: right; }
+.bar { background: #4a7bd0; height: 10px; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Report}}</h1>
+{{with .Description}}<p>{{.}}</p>{{end}}
+<p>Saved search <b>{{.SavedSearch}}</b>{{with .Query}}: <code>{{.}}</code>{{end}}{{with .ProjectID}}, project {{.}}{{end}}<br>
+{{.Range}} <span class="muted">(generated {{.Generated}})</span></p>
+<p><b>{{.Aggregations.Total}}</b> entries{{if .Stats.Truncated}} <span class="muted">(more objects matched than were read)</span>{{end}}</p>
+{{if .Histogram}}<h2>Entries per {{.Aggregations.Interval}}</h2>
+<table>
+<tr><th>Start</th><th>Entries</th><th></th></tr>
+{{range .Histogram}}<tr><td>{{.Start}}</td><td class="n">{{.Count}}</td><td><div class="bar" style="width: {{.Width}}px"></div></td></tr>
+{{end}}</table>
+{{end}}{{if .Groups}}<h2>Groups</h2>
+<table>
+<tr>{{range .GroupBy}}<th>{{.}}</th>{{end}}<th>Entries</th></tr>
+{{range .Groups}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}{{if .Aggregations.GroupsOther}}<tr><td colspan="{{len .GroupBy}}" class="muted">other</td><td class="n">{{.Aggregations.GroupsOther}}</td></tr>
+{{end}}</table>
+{{end}}{{range .Top}}<h2>Top {{.Field}}</h2>
+<table>
+<tr><th>{{.Field}}</th><th>Entries</th></tr>
+{{range .Values}}<tr><td>{{.Value}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}{{if .Other}}<tr><td class="muted">other</td><td class="n">{{.Other}}</td></tr>
+{{end}}{{if .Missing}}<tr><td class="muted">missing</td><td class="n">{{.Missing}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// renderHTML renders res as a standalone page with a table per aggregation.
+func renderHTML(res *Result) ([]byte, error) {
+	agg := res.Aggregations
+	v := htmlView{
+		Result:    res,
+		Range:     res.Start.UTC().Format(time.RFC3339) + " to " + res.End.UTC().Format(time.RFC3339),
+		Generated: res.GeneratedAt.UTC().Format(time.RFC3339),
+	}
+	highest := 0
+	for _, b := range agg.Histogram {
+		highest = max(highest, b.Count)
+	}
+	for _, b := range agg.Histogram {
+		width := 0
+		if highest > 0 {
+			width = b.Count * barWidth / highest
+		}
+		v.Histogram = append(v.Histogram, htmlBucket{Start: b.Start.UTC().Format(time.RFC3339), Count: b.Count, Width: width})
+	}
+	for _, g := range agg.Groups {
+		v.Groups = append(v.Groups, append(groupValues(res.GroupBy, g), strconv.Itoa(g.Count)))
+	}
+	for _, f := range topFields(agg) {
+		v.Top = append(v.Top, htmlTop{Field: f, TopValues: agg.Top[f]})
+	}
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
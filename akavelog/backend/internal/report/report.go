@@ -0,0 +1,375 @@
+// Package report runs saved aggregations on a cron schedule. Each run renders the
+// aggregation's results as JSON, CSV or HTML, stores them in O3 under reports/ and announces
+// them on outputs.
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/cron"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/google/uuid"
+)
+
+// Prefix is the O3 prefix reports are stored under.
+const Prefix = "reports"
+
+// Service is the service of the entries announcing runs on outputs.
+const Service = "akavelog-reports"
+
+const (
+	defaultRange  = 24 * time.Hour
+	recordTimeout = 10 * time.Second
+)
+
+// Repo stores reports and their runs (repository.ReportRepository).
+type Repo interface {
+	Due(ctx context.Context, now time.Time) ([]model.Report, error)
+	Claim(ctx context.Context, id uuid.UUID, due, next time.Time) (bool, error)
+	RecordRun(ctx context.Context, run *model.ReportRun) error
+}
+
+// Searches loads the saved aggregations reports run (repository.SavedSearchRepository).
+type Searches interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*model.SavedSearch, error)
+}
+
+// Store is the part of storage.O3Client reports are written with.
+type Store interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Notify queues an entry on the output called name (outputs.Dispatcher.Send). It reports false
+// when no such output is running.
+type Notify func(name string, e *model.LogEntry) bool
+
+// Config configures a Scheduler. Zero fields take their defaults.
+type Config struct {
+	Interval   time.Duration // how often due reports are looked for (default 1m)
+	Timeout    time.Duration // a run is canceled after this long (default 10m)
+	MaxObjects int           // batch objects one run reads at most (default 10000)
+	URLExpiry  time.Duration // download URLs sent to outputs are valid this long (default 7 days)
+}
+
+func (c *Config) setDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Minute
+	}
+	if c.MaxObjects <= 0 {
+		c.MaxObjects = 10000
+	}
+	if c.URLExpiry <= 0 {
+		c.URLExpiry = 7 * 24 * time.Hour
+	}
+}
+
+// ParseSchedule parses the cron expression of a report in its time zone ("" for UTC).
+func ParseSchedule(expr, timezone string) (*cron.Schedule, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", timezone)
+		}
+	}
+	return cron.Parse(expr, loc)
+}
+
+// Scheduler runs reports when they are due, one at a time, and on request.
+type Scheduler struct {
+	cfg      Config
+	repo     Repo
+	searches Searches
+	index    search.Index
+	logs     search.Store
+	store    Store
+	notify   Notify // may be nil
+
+	ctx    context.Context // canceled by Stop
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup // runs started by RunNow
+}
+
+// NewScheduler starts a scheduler that looks for due reports every Interval, the first time
+// right away. Entries are read through index and logs; renderings are written to store.
+func NewScheduler(cfg Config, repo Repo, searches Searches, index search.Index, logs search.Store, store Store, notify Notify) *Scheduler {
+	cfg.setDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{cfg: cfg, repo: repo, searches: searches, index: index, logs: logs, store: store, notify: notify,
+		ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	go s.loop()
+	return s
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	t := time.NewTicker(s.cfg.Interval)
+	defer t.Stop()
+	for {
+		if err := s.RunDue(s.ctx, time.Now()); err != nil && s.ctx.Err() == nil {
+			log.Printf("[reports] %v", err)
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stop stops the scheduler, canceling runs in progress, and waits for them to end.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.cancel()
+	<-s.done
+	s.running.Wait()
+}
+
+// RunDue runs the reports due at now. Each run is first claimed by moving the report's next
+// run to its following time, so schedulers of several servers run it once. A run missed while
+// no server was up runs once, for the time it was due.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	due, err := s.repo.Due(ctx, now)
+	if err != nil {
+		return fmt.Errorf("load due reports: %w", err)
+	}
+	for i := range due {
+		r := &due[i]
+		var next time.Time
+		sched, err := ParseSchedule(r.Schedule, r.Timezone)
+		if err == nil {
+			next = sched.Next(now)
+		} else {
+			// Not run again until the schedule is fixed.
+			log.Printf("[reports] %s: %v", r.Name, err)
+		}
+		claimed, err := s.repo.Claim(ctx, r.ID, *r.NextRunAt, next)
+		if err != nil {
+			return fmt.Errorf("claim report %s: %w", r.Name, err)
+		}
+		if claimed && sched != nil {
+			s.Run(ctx, r, *r.NextRunAt, true)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// RunNow runs r in the background over the range of its saved search up to now. It reports
+// false once the scheduler is stopped.
+func (s *Scheduler) RunNow(r model.Report) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.Run(s.ctx, &r, time.Now(), false)
+	}()
+	return true
+}
+
+// Run runs r over the range of its saved search ending at end, stores every format, records
+// the run and announces it on r's outputs. A failed run is recorded and announced as well.
+func (s *Scheduler) Run(ctx context.Context, r *model.Report, end time.Time, scheduled bool) *model.ReportRun {
+	began := time.Now()
+	run := &model.ReportRun{
+		ID:        uuid.New(),
+		ReportID:  r.ID,
+		Scheduled: scheduled,
+		Status:    model.ReportRunDone,
+		Start:     end.UTC(),
+		End:       end.UTC(),
+		Objects:   make(map[model.ReportFormat]string),
+	}
+	runCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	res, err := s.generate(runCtx, r, run)
+	cancel()
+	if err != nil {
+		run.Status, run.Error = model.ReportRunFailed, err.Error()
+		log.Printf("[reports] %s: %v", r.Name, err)
+	}
+	run.DurationMS = time.Since(began).Milliseconds()
+
+	// Record and announce even when ctx was canceled by Stop.
+	ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := s.repo.RecordRun(ctx, run); err != nil {
+		log.Printf("[reports] %s: record run: %v", r.Name, err)
+	}
+	s.announce(ctx, r, run, res)
+	return run
+}
+
+// generate aggregates the entries of the run's range and stores each format of r.
+func (s *Scheduler) generate(ctx context.Context, r *model.Report, run *model.ReportRun) (*Result, error) {
+	ss, err := s.searches.GetByID(ctx, r.SavedSearchID)
+	if err != nil {
+		return nil, fmt.Errorf("load saved search: %w", err)
+	}
+	if ss == nil {
+		return nil, fmt.Errorf("saved search %s not found", r.SavedSearchID)
+	}
+	if ss.Kind != model.QueryAggregate {
+		return nil, fmt.Errorf("saved search %s is a %s, not an aggregation", ss.Name, ss.Kind)
+	}
+	q, err := query.Parse(ss.Query)
+	if err != nil {
+		return nil, fmt.Errorf("saved search %s: %w", ss.Name, err)
+	}
+	d, err := parseDuration(ss.TimeRange)
+	if err != nil || d <= 0 {
+		d = defaultRange
+	}
+	run.Start = run.End.Add(-d)
+	agg, err := aggregateOptions(ss.Params, run.Start, run.End)
+	if err != nil {
+		return nil, fmt.Errorf("saved search %s: %w", ss.Name, err)
+	}
+	a, err := search.NewAggregator(agg)
+	if err != nil {
+		return nil, fmt.Errorf("saved search %s: %w", ss.Name, err)
+	}
+	st, err := search.Scan(ctx, s.index, s.logs, search.ScanOptions{
+		ProjectID:  ss.ProjectID,
+		Start:      run.Start,
+		End:        run.End,
+		Query:      q,
+		MaxObjects: s.cfg.MaxObjects,
+	}, func(e *model.LogEntry, t time.Time) bool {
+		a.Add(e, t)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Report:       r.Name,
+		Description:  r.Description,
+		SavedSearch:  ss.Name,
+		Query:        q.String(),
+		ProjectID:    ss.ProjectID,
+		Start:        run.Start,
+		End:          run.End,
+		GeneratedAt:  time.Now().UTC(),
+		GroupBy:      agg.GroupBy,
+		Aggregations: a.Result(),
+		Stats:        st,
+	}
+	run.Total = res.Aggregations.Total
+	for _, f := range r.Formats {
+		data, contentType, err := Render(res, f)
+		if err != nil {
+			return res, err
+		}
+		key := objectKey(r.ID, run.ID, run.End, f)
+		if err := s.store.PutObject(ctx, key, data, contentType); err != nil {
+			return res, fmt.Errorf("upload %s: %w", key, err)
+		}
+		run.Objects[f] = key
+	}
+	return res, nil
+}
+
+// announce queues an entry describing run on every output of r, with the key and a download
+// URL of each stored format.
+func (s *Scheduler) announce(ctx context.Context, r *model.Report, run *model.ReportRun, res *Result) {
+	if s.notify == nil || len(r.Outputs) == 0 {
+		return
+	}
+	e := &model.LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Service:   Service,
+		Level:     "info",
+		Message: fmt.Sprintf("report %s: %d entries from %s to %s", r.Name, run.Total,
+			run.Start.Format(time.RFC3339), run.End.Format(time.RFC3339)),
+		Tags: map[string]string{
+			"report":    r.Name,
+			"report_id": r.ID.String(),
+			"run_id":    run.ID.String(),
+			"status":    run.Status,
+			"total":     strconv.Itoa(run.Total),
+			"start":     run.Start.Format(time.RFC3339),
+			"end":       run.End.Format(time.RFC3339),
+		},
+	}
+	if res != nil {
+		e.ProjectID = res.ProjectID
+	}
+	if run.Status == model.ReportRunFailed {
+		e.Level = "error"
+		e.Message = fmt.Sprintf("report %s failed: %s", r.Name, run.Error)
+		e.Tags["error"] = run.Error
+	}
+	for f, key := range run.Objects {
+		e.Tags["key_"+string(f)] = key
+		if url, err := s.store.PresignGet(ctx, key, s.cfg.URLExpiry); err == nil {
+			e.Tags["url_"+string(f)] = url
+		} else {
+			log.Printf("[reports] %s: presign %s: %v", r.Name, key, err)
+		}
+	}
+	for _, name := range r.Outputs {
+		if !s.notify(name, e) {
+			log.Printf("[reports] %s: output %q is not running", r.Name, name)
+		}
+	}
+}
+
+// objectKey is where a rendering is stored: reports/<report>/YYYY/MM/DD/<run>.<format>, by
+// the end of the run's range.
+func objectKey(reportID, runID uuid.UUID, end time.Time, f model.ReportFormat) string {
+	return fmt.Sprintf("%s/%s/%s/%s.%s", Prefix, reportID, end.UTC().Format("2006/01/02"), runID, f)
+}
+
+// aggregateOptions builds the aggregation of a saved search's params over [start, end). The
+// params were validated when the search was saved.
+func aggregateOptions(p model.SavedSearchParams, start, end time.Time) (search.AggregateOptions, error) {
+	agg := search.AggregateOptions{Start: start, End: end, GroupBy: p.GroupBy, Top: p.Top, TopN: p.TopN}
+	switch v := strings.TrimSpace(p.Interval); v {
+	case "", "auto":
+		agg.Interval = search.AutoInterval(start, end)
+	case "none":
+	default:
+		d, err := parseDuration(v)
+		if err != nil || d <= 0 {
+			return agg, fmt.Errorf("invalid interval %q", v)
+		}
+		agg.Interval = d
+	}
+	return agg, nil
+}
+
+// parseDuration is time.ParseDuration that also accepts whole days ("7d").
+func parseDuration(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,181 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/google/uuid"
+)
+
+type memIndex []model.Batch
+
+func (x memIndex) Find(context.Context, repository.BatchFilter) ([]model.Batch, error) {
+	return x, nil
+}
+
+type memLogs map[string][]model.LogEntry
+
+func (s memLogs) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	return s[key], nil
+}
+
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (s *memStore) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = string(data)
+	return nil
+}
+
+func (s *memStore) PresignGet(_ context.Context, key string, _ time.Duration) (string, error) {
+	return "https://o3.example/" + key + "?signed", nil
+}
+
+// memRepo serves one due report and records claims and runs.
+type memRepo struct {
+	mu      sync.Mutex
+	due     []model.Report
+	claimed map[uuid.UUID]time.Time
+	taken   bool // another scheduler claims every run first
+	runs    []model.ReportRun
+}
+
+func (r *memRepo) Due(context.Context, time.Time) ([]model.Report, error) { return r.due, nil }
+
+func (r *memRepo) Claim(_ context.Context, id uuid.UUID, _, next time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.taken {
+		return false, nil
+	}
+	r.claimed[id] = next
+	return true, nil
+}
+
+func (r *memRepo) RecordRun(_ context.Context, run *model.ReportRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+type memSearches map[uuid.UUID]*model.SavedSearch
+
+func (s memSearches) GetByID(_ context.Context, id uuid.UUID) (*model.SavedSearch, error) {
+	return s[id], nil
+}
+
+func fixture() (memIndex, memLogs) {
+	return memIndex{{Key: "a"}}, memLogs{"a": {
+		{Timestamp: "2026-03-01T10:00:00Z", Service: "api", Level: "error", Message: "timeout"},
+		{Timestamp: "2026-03-01T10:05:00Z", Service: "api", Level: "info", Message: "ok"},
+		{Timestamp: "2026-03-01T10:10:00Z", Service: "web", Level: "error", Message: "down"},
+		{Timestamp: "2026-03-01T13:00:00Z", Service: "api", Level: "error", Message: "timeout"},
+		{Timestamp: "2026-03-02T00:00:00Z", Service: "api", Level: "error", Message: "after the range"},
+	}}
+}
+
+func TestRunDue(t *testing.T) {
+	index, logs := fixture()
+	agg := &model.SavedSearch{ID: uuid.New(), Name: "errors", Kind: model.QueryAggregate, Query: "level:error",
+		TimeRange: "1d", Params: model.SavedSearchParams{Interval: "12h", GroupBy: []string{"service"}, Top: []string{"level"}}}
+	due := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	rep := model.Report{ID: uuid.New(), Name: "daily errors", SavedSearchID: agg.ID, Schedule: "@daily", Enabled: true,
+		Formats: []model.ReportFormat{model.ReportJSON, model.ReportCSV, model.ReportHTML}, Outputs: []string{"siem"}, NextRunAt: &due}
+	repo := &memRepo{due: []model.Report{rep}, claimed: map[uuid.UUID]time.Time{}}
+	store := &memStore{objects: map[string]string{}}
+	var sent []*model.LogEntry
+	notify := func(name string, e *model.LogEntry) bool {
+		sent = append(sent, e)
+		return name == "siem"
+	}
+	s := &Scheduler{repo: repo, searches: memSearches{agg.ID: agg}, index: index, logs: logs, store: store, notify: notify}
+	s.cfg.setDefaults()
+
+	if err := s.RunDue(context.Background(), due.Add(30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if next := repo.claimed[rep.ID]; !next.Equal(due.Add(24 * time.Hour)) {
+		t.Errorf("claimed next run %s", next)
+	}
+	if len(repo.runs) != 1 {
+		t.Fatalf("%d runs recorded", len(repo.runs))
+	}
+	run := repo.runs[0]
+	if run.Status != model.ReportRunDone || run.Total != 3 || !run.Scheduled || !run.Start.Equal(due.Add(-24*time.Hour)) || !run.End.Equal(due) {
+		t.Errorf("run %+v", run)
+	}
+	key := run.Objects[model.ReportCSV]
+	if want := Prefix + "/" + rep.ID.String() + "/2026/03/02/" + run.ID.String() + ".csv"; key != want {
+		t.Errorf("csv key %s, want %s", key, want)
+	}
+	wantCSV := "section,field,value,count\n" +
+		"total,,,3\n" +
+		"histogram,timestamp,2026-03-01T00:00:00Z,2\n" +
+		"histogram,timestamp,2026-03-01T12:00:00Z,1\n" +
+		"group,service,api,2\n" +
+		"group,service,web,1\n" +
+		"top,level,error,3\n"
+	if got := store.objects[key]; got != wantCSV {
+		t.Errorf("csv:\n%s\nwant:\n%s", got, wantCSV)
+	}
+	var res Result
+	if err := json.Unmarshal([]byte(store.objects[run.Objects[model.ReportJSON]]), &res); err != nil || res.Aggregations.Total != 3 || res.SavedSearch != "errors" {
+		t.Errorf("json: %+v, %v", res, err)
+	}
+	if html := store.objects[run.Objects[model.ReportHTML]]; !strings.Contains(html, "<h1>daily errors</h1>") || !strings.Contains(html, "<td>web</td>") {
+		t.Errorf("html:\n%s", html)
+	}
+	if len(sent) != 1 || sent[0].Service != Service || sent[0].Level != "info" || sent[0].Tags["url_csv"] == "" || sent[0].Tags["total"] != "3" {
+		t.Errorf("announced %+v", sent)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	index, logs := fixture()
+	search := &model.SavedSearch{ID: uuid.New(), Name: "latest", Kind: model.QuerySearch}
+	due := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	rep := model.Report{ID: uuid.New(), Name: "wrong kind", SavedSearchID: search.ID, Schedule: "0 * * * *", Enabled: true,
+		Formats: []model.ReportFormat{model.ReportJSON}, Outputs: []string{"siem"}, NextRunAt: &due}
+	broken := model.Report{ID: uuid.New(), Name: "broken", SavedSearchID: search.ID, Schedule: "every day", Enabled: true, NextRunAt: &due}
+	repo := &memRepo{due: []model.Report{rep, broken}, claimed: map[uuid.UUID]time.Time{}}
+	var sent []*model.LogEntry
+	s := &Scheduler{repo: repo, searches: memSearches{search.ID: search}, index: index, logs: logs,
+		store: &memStore{objects: map[string]string{}}, notify: func(_ string, e *model.LogEntry) bool {
+			sent = append(sent, e)
+			return true
+		}}
+	s.cfg.setDefaults()
+
+	if err := s.RunDue(context.Background(), due); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.runs) != 1 || repo.runs[0].Status != model.ReportRunFailed || !strings.Contains(repo.runs[0].Error, "not an aggregation") {
+		t.Errorf("runs %+v", repo.runs)
+	}
+	if len(sent) != 1 || sent[0].Level != "error" {
+		t.Errorf("announced %+v", sent)
+	}
+	// An invalid schedule is not run and not due again.
+	if next, ok := repo.claimed[broken.ID]; !ok || !next.IsZero() {
+		t.Errorf("broken schedule claimed with next run %s, %v", next, ok)
+	}
+
+	repo.runs, repo.taken = nil, true
+	if err := s.RunDue(context.Background(), due); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.runs) != 0 {
+		t.Error("ran a report claimed by another scheduler")
+	}
+}
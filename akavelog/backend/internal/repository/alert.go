@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// MaxAlertEvents is how many state changes are kept per alert; older ones are deleted as new
+// ones are recorded.
+const MaxAlertEvents = 1000
+
+// AlertRepository persists alerts and their state changes.
+type AlertRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAlertRepository returns an AlertRepository using the given pool.
+func NewAlertRepository(pool *pgxpool.Pool) *AlertRepository {
+	return &AlertRepository{pool: pool}
+}
+
+const alertColumns = `id, name, description, query, project_id, condition, severity, channels, enabled,
+	state, state_changed_at, last_value, created_at, updated_at`
+
+func scanAlert(row pgx.Row) (*model.Alert, error) {
+	var a model.Alert
+	var channels []byte
+	err := row.Scan(
+		&a.ID,
+		&a.Name,
+		&a.Description,
+		&a.Query,
+		&a.ProjectID,
+		&a.Condition,
+		&a.Severity,
+		&channels,
+		&a.Enabled,
+		&a.State,
+		&a.StateChangedAt,
+		&a.LastValue,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(channels, &a.Channels); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create inserts a new alert in state ok and returns it with ID and timestamps set.
+func (r *AlertRepository) Create(ctx context.Context, a *model.Alert) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	channels, err := json.Marshal(stringsOrEmpty(a.Channels))
+	if err != nil {
+		return err
+	}
+	a.State = model.AlertOK
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO alerts (id, name, description, query, project_id, condition, severity, channels, enabled, state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at`,
+		a.ID,
+		a.Name,
+		a.Description,
+		a.Query,
+		a.ProjectID,
+		a.Condition,
+		a.Severity,
+		channels,
+		a.Enabled,
+		a.State,
+	).Scan(&a.CreatedAt, &a.UpdatedAt)
+}
+
+// List returns all alerts by name.
+func (r *AlertRepository) List(ctx context.Context) ([]model.Alert, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+alertColumns+` FROM alerts ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one alert by id, or nil if not found.
+func (r *AlertRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Alert, error) {
+	return scanAlert(r.pool.QueryRow(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = $1`, id))
+}
+
+// GetByName returns one alert by name, or nil if not found.
+func (r *AlertRepository) GetByName(ctx context.Context, name string) (*model.Alert, error) {
+	return scanAlert(r.pool.QueryRow(ctx, `SELECT `+alertColumns+` FROM alerts WHERE name = $1`, name))
+}
+
+// Update replaces the definition of an existing alert; its state is kept.
+func (r *AlertRepository) Update(ctx context.Context, a *model.Alert) error {
+	channels, err := json.Marshal(stringsOrEmpty(a.Channels))
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `
+		UPDATE alerts SET name = $1, description = $2, query = $3, project_id = $4, condition = $5,
+			severity = $6, channels = $7, enabled = $8, updated_at = now()
+		WHERE id = $9
+		RETURNING updated_at`,
+		a.Name,
+		a.Description,
+		a.Query,
+		a.ProjectID,
+		a.Condition,
+		a.Severity,
+		channels,
+		a.Enabled,
+		a.ID,
+	).Scan(&a.UpdatedAt)
+}
+
+// Delete removes an alert and its events.
+func (r *AlertRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM alerts WHERE id = $1`, id)
+	return err
+}
+
+// RecordState moves an alert to ev.State and adds ev to its events, which are trimmed to
+// MaxAlertEvents. A zero ev.CreatedAt is set to now.
+func (r *AlertRepository) RecordState(ctx context.Context, ev *model.AlertEvent) error {
+	if ev.ID == uuid.Nil {
+		ev.ID = uuid.New()
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO alert_events (id, alert_id, state, value, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, now()))
+		RETURNING created_at`,
+		ev.ID,
+		ev.AlertID,
+		ev.State,
+		ev.Value,
+		ev.Message,
+		nullTime(ev.CreatedAt),
+	).Scan(&ev.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE alerts SET state = $1, state_changed_at = $2, last_value = $3 WHERE id = $4`,
+		ev.State, ev.CreatedAt, ev.Value, ev.AlertID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM alert_events WHERE alert_id = $1 AND created_at < (
+			SELECT created_at FROM alert_events WHERE alert_id = $1 ORDER BY created_at DESC OFFSET $2 LIMIT 1)`,
+		ev.AlertID, MaxAlertEvents-1)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListEvents returns the state changes of an alert, newest first; limit <= 0 returns all of
+// them.
+func (r *AlertRepository) ListEvents(ctx context.Context, alertID uuid.UUID, limit int) ([]model.AlertEvent, error) {
+	q := `SELECT id, alert_id, state, value, message, created_at
+		FROM alert_events WHERE alert_id = $1 ORDER BY created_at DESC, id`
+	args := []any{alertID}
+	if limit > 0 {
+		q += ` LIMIT $2`
+		args = append(args, limit)
+	}
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.AlertEvent
+	for rows.Next() {
+		var ev model.AlertEvent
+		if err := rows.Scan(&ev.ID, &ev.AlertID, &ev.State, &ev.Value, &ev.Message, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, ev)
+	}
+	return list, rows.Err()
+}
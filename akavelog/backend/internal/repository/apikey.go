@@ -1 +1,141 @@
 package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// APIKeyRepository persists the keys of the management API, by hash.
+type APIKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAPIKeyRepository returns an APIKeyRepository using the given pool.
+func NewAPIKeyRepository(pool *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{pool: pool}
+}
+
+const apiKeyColumns = `id, name, prefix, hash, scopes, project_id, expires_at, last_used_at, created_at, updated_at`
+
+func scanAPIKey(row pgx.Row) (*model.APIKey, error) {
+	var k model.APIKey
+	var scopes []byte
+	err := row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Prefix,
+		&k.Hash,
+		&scopes,
+		&k.ProjectID,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.CreatedAt,
+		&k.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Create inserts a new key and returns it with ID and timestamps set.
+func (r *APIKeyRepository) Create(ctx context.Context, k *model.APIKey) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	scopes, err := json.Marshal(stringsOrEmpty(k.Scopes))
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, name, prefix, hash, scopes, project_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		k.ID,
+		k.Name,
+		k.Prefix,
+		k.Hash,
+		scopes,
+		k.ProjectID,
+		k.ExpiresAt,
+	).Scan(&k.CreatedAt, &k.UpdatedAt)
+}
+
+// List returns all keys ordered by name.
+func (r *APIKeyRepository) List(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *k)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one key by id, or nil if not found.
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error) {
+	return scanAPIKey(r.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+}
+
+// GetByName returns one key by name, or nil if not found.
+func (r *APIKeyRepository) GetByName(ctx context.Context, name string) (*model.APIKey, error) {
+	return scanAPIKey(r.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE name = $1`, name))
+}
+
+// GetByHash returns the key whose SHA-256 is hash, or nil if not found.
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	return scanAPIKey(r.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = $1`, hash))
+}
+
+// Update replaces name, scopes, project_id and expires_at of an existing key. The key
+// itself cannot change.
+func (r *APIKeyRepository) Update(ctx context.Context, k *model.APIKey) error {
+	scopes, err := json.Marshal(stringsOrEmpty(k.Scopes))
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `
+		UPDATE api_keys SET name = $1, scopes = $2, project_id = $3, expires_at = $4, updated_at = now()
+		WHERE id = $5
+		RETURNING updated_at`,
+		k.Name,
+		scopes,
+		k.ProjectID,
+		k.ExpiresAt,
+		k.ID,
+	).Scan(&k.UpdatedAt)
+}
+
+// Touch records that the key was used at.
+func (r *APIKeyRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, at, id)
+	return err
+}
+
+// Delete removes a key by id; it stops working right away.
+func (r *APIKeyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	return err
+}
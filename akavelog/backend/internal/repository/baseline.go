@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// BaselineRepository persists the baselines of the anomaly analyzer and the bucket it
+// analyzed last.
+type BaselineRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewBaselineRepository returns a BaselineRepository using the given pool.
+func NewBaselineRepository(pool *pgxpool.Pool) *BaselineRepository {
+	return &BaselineRepository{pool: pool}
+}
+
+// List returns the baselines of projectID and service, each "" for all, ordered by project
+// and service.
+func (r *BaselineRepository) List(ctx context.Context, projectID, service string) ([]model.Baseline, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT project_id, service, volume, error_rate, last_seen_at, updated_at
+		FROM anomaly_baselines
+		WHERE ($1 = '' OR project_id = $1) AND ($2 = '' OR service = $2)
+		ORDER BY project_id, service`, projectID, service)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Baseline
+	for rows.Next() {
+		var b model.Baseline
+		var volume, errorRate []byte
+		if err := rows.Scan(&b.ProjectID, &b.Service, &volume, &errorRate, &b.LastSeenAt, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(volume, &b.Volume); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(errorRate, &b.ErrorRate); err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+// Save upserts list and deletes the baselines of services last seen before forgetBefore, in
+// one transaction.
+func (r *BaselineRepository) Save(ctx context.Context, list []model.Baseline, forgetBefore time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, b := range list {
+		volume, err := json.Marshal(b.Volume)
+		if err != nil {
+			return err
+		}
+		errorRate, err := json.Marshal(b.ErrorRate)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO anomaly_baselines (project_id, service, volume, error_rate, last_seen_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (project_id, service) DO UPDATE SET
+				volume = EXCLUDED.volume,
+				error_rate = EXCLUDED.error_rate,
+				last_seen_at = EXCLUDED.last_seen_at,
+				updated_at = EXCLUDED.updated_at`,
+			b.ProjectID,
+			b.Service,
+			volume,
+			errorRate,
+			b.LastSeenAt,
+			b.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM anomaly_baselines WHERE last_seen_at < $1`, forgetBefore); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Cursor returns the end of the last bucket analyzed, or the zero time before the first.
+func (r *BaselineRepository) Cursor(ctx context.Context) (time.Time, error) {
+	var end time.Time
+	err := r.pool.QueryRow(ctx, `SELECT bucket_end FROM anomaly_cursor WHERE id = 1`).Scan(&end)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return end, err
+}
+
+// Claim moves the cursor from prev (zero before the first bucket) to next. It reports false
+// when the cursor was not at prev, i.e. another server claimed the bucket.
+func (r *BaselineRepository) Claim(ctx context.Context, prev, next time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO anomaly_cursor (id, bucket_end) VALUES (1, $2)
+		ON CONFLICT (id) DO UPDATE SET bucket_end = EXCLUDED.bucket_end
+		WHERE anomaly_cursor.bucket_end = $1`,
+		nullTime(prev), next)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
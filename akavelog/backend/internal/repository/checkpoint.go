@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CheckpointRepository persists input checkpoints. It implements inputs.CheckpointStore.
+type CheckpointRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewCheckpointRepository returns a CheckpointRepository using the given pool.
+func NewCheckpointRepository(pool *pgxpool.Pool) *CheckpointRepository {
+	return &CheckpointRepository{pool: pool}
+}
+
+// GetCheckpoint returns the stored value for key, or "" if none.
+func (r *CheckpointRepository) GetCheckpoint(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.pool.QueryRow(ctx, `SELECT value FROM input_checkpoints WHERE key = $1`, key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+// SetCheckpoint upserts the value for key.
+func (r *CheckpointRepository) SetCheckpoint(ctx context.Context, key, value string) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO input_checkpoints (key, value, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		key, value)
+	return err
+}
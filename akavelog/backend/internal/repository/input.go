@@ -11,6 +11,10 @@ import (
 	"github.com/akave-ai/akavelog/internal/model"
 )
 
+// ErrVersionConflict is returned by InputRepository.Update when the input was changed since
+// the version being updated was read.
+var ErrVersionConflict = errors.New("input was changed since it was read")
+
 // InputRepository persists and reads input definitions.
 type InputRepository struct {
 	pool *pgxpool.Pool
@@ -24,9 +28,9 @@ func NewInputRepository(pool *pgxpool.Pool) *InputRepository {
 // Create inserts a new input and returns it with ID and CreatedAt set.
 func (r *InputRepository) Create(ctx context.Context, input *model.Input) error {
 	query := `
-		INSERT INTO inputs (id, type, title, configuration, global, node_id, creator_user_id, desired_state)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at`
+		INSERT INTO inputs (id, type, title, configuration, global, project_id, node_id, creator_user_id, desired_state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, version, updated_at`
 	if input.ID == uuid.Nil {
 		input.ID = uuid.New()
 	}
@@ -36,16 +40,18 @@ func (r *InputRepository) Create(ctx context.Context, input *model.Input) error
 		input.Title,
 		input.Configuration,
 		input.Global,
+		input.ProjectID,
 		input.NodeID,
 		input.CreatorUserID,
 		input.DesiredState,
-	).Scan(&input.ID, &input.CreatedAt)
+	).Scan(&input.ID, &input.CreatedAt, &input.Version, &input.UpdatedAt)
 }
 
 // List returns all inputs ordered by created_at descending.
 func (r *InputRepository) List(ctx context.Context) ([]model.Input, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, type, title, configuration, global, node_id, creator_user_id, created_at, desired_state
+		SELECT id, type, title, configuration, global, project_id, node_id, creator_user_id, created_at, desired_state,
+			COALESCE(last_error, ''), last_error_at, version, updated_at
 		FROM inputs
 		ORDER BY created_at DESC`)
 	if err != nil {
@@ -62,10 +68,15 @@ func (r *InputRepository) List(ctx context.Context) ([]model.Input, error) {
 			&in.Title,
 			&in.Configuration,
 			&in.Global,
+			&in.ProjectID,
 			&in.NodeID,
 			&in.CreatorUserID,
 			&in.CreatedAt,
 			&in.DesiredState,
+			&in.LastError,
+			&in.LastErrorAt,
+			&in.Version,
+			&in.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -78,17 +89,23 @@ func (r *InputRepository) List(ctx context.Context) ([]model.Input, error) {
 func (r *InputRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Input, error) {
 	var in model.Input
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, type, title, configuration, global, node_id, creator_user_id, created_at, desired_state
+		SELECT id, type, title, configuration, global, project_id, node_id, creator_user_id, created_at, desired_state,
+			COALESCE(last_error, ''), last_error_at, version, updated_at
 		FROM inputs WHERE id = $1`, id).Scan(
 		&in.ID,
 		&in.Type,
 		&in.Title,
 		&in.Configuration,
 		&in.Global,
+		&in.ProjectID,
 		&in.NodeID,
 		&in.CreatorUserID,
 		&in.CreatedAt,
 		&in.DesiredState,
+		&in.LastError,
+		&in.LastErrorAt,
+		&in.Version,
+		&in.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -99,17 +116,26 @@ func (r *InputRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Inp
 	return &in, nil
 }
 
-// Update updates an existing input by id. Only type, title, configuration, and desired_state are updated.
+// Update updates an existing input by id if it is still at input.Version, and sets its new
+// Version and UpdatedAt. Only type, title, configuration, project_id, and desired_state are
+// updated. It returns ErrVersionConflict when the input was updated since input.Version.
 func (r *InputRepository) Update(ctx context.Context, input *model.Input) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE inputs SET type = $1, title = $2, configuration = $3, desired_state = $4
-		WHERE id = $5`,
+	err := r.pool.QueryRow(ctx, `
+		UPDATE inputs SET type = $1, title = $2, configuration = $3, project_id = $4, desired_state = $5,
+			version = version + 1, updated_at = now()
+		WHERE id = $6 AND version = $7
+		RETURNING version, updated_at`,
 		input.Type,
 		input.Title,
 		input.Configuration,
+		input.ProjectID,
 		input.DesiredState,
 		input.ID,
-	)
+		input.Version,
+	).Scan(&input.Version, &input.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionConflict
+	}
 	return err
 }
 
@@ -118,3 +144,19 @@ func (r *InputRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.pool.Exec(ctx, `DELETE FROM inputs WHERE id = $1`, id)
 	return err
 }
+
+// UpdateState sets desired_state for one input.
+func (r *InputRepository) UpdateState(ctx context.Context, id uuid.UUID, state model.InputState) error {
+	_, err := r.pool.Exec(ctx, `UPDATE inputs SET desired_state = $1 WHERE id = $2`, state, id)
+	return err
+}
+
+// SetLastError records why an input failed to start. An empty msg clears it.
+func (r *InputRepository) SetLastError(ctx context.Context, id uuid.UUID, msg string) error {
+	if msg == "" {
+		_, err := r.pool.Exec(ctx, `UPDATE inputs SET last_error = NULL, last_error_at = NULL WHERE id = $1`, id)
+		return err
+	}
+	_, err := r.pool.Exec(ctx, `UPDATE inputs SET last_error = $1, last_error_at = now() WHERE id = $2`, msg, id)
+	return err
+}
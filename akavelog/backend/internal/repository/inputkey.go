@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// InputKeyRepository persists the ingest keys of inputs, by hash.
+type InputKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewInputKeyRepository returns an InputKeyRepository using the given pool.
+func NewInputKeyRepository(pool *pgxpool.Pool) *InputKeyRepository {
+	return &InputKeyRepository{pool: pool}
+}
+
+const inputKeyColumns = `id, input_id, prefix, hash, expires_at, created_at`
+
+func scanInputKey(row pgx.Row) (*model.InputKey, error) {
+	var k model.InputKey
+	err := row.Scan(&k.ID, &k.InputID, &k.Prefix, &k.Hash, &k.ExpiresAt, &k.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *InputKeyRepository) list(ctx context.Context, query string, args ...any) ([]model.InputKey, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.InputKey
+	for rows.Next() {
+		k, err := scanInputKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *k)
+	}
+	return list, rows.Err()
+}
+
+// ListValid returns the keys of every input that have not expired.
+func (r *InputKeyRepository) ListValid(ctx context.Context) ([]model.InputKey, error) {
+	return r.list(ctx, `SELECT `+inputKeyColumns+` FROM input_keys
+		WHERE expires_at IS NULL OR expires_at > now() ORDER BY created_at`)
+}
+
+// ListValidByInput returns the keys of one input that have not expired, newest first.
+func (r *InputKeyRepository) ListValidByInput(ctx context.Context, inputID uuid.UUID) ([]model.InputKey, error) {
+	return r.list(ctx, `SELECT `+inputKeyColumns+` FROM input_keys
+		WHERE input_id = $1 AND (expires_at IS NULL OR expires_at > now()) ORDER BY created_at DESC`, inputID)
+}
+
+// Rotate adds k as the current key of its input. The input's other keys expire at
+// expireOthers unless they expire sooner, and keys that have expired are removed.
+func (r *InputKeyRepository) Rotate(ctx context.Context, k *model.InputKey, expireOthers time.Time) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, `DELETE FROM input_keys WHERE input_id = $1 AND expires_at <= now()`, k.InputID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE input_keys SET expires_at = $2
+		WHERE input_id = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		k.InputID, expireOthers); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO input_keys (id, input_id, prefix, hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at`,
+		k.ID, k.InputID, k.Prefix, k.Hash,
+	).Scan(&k.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
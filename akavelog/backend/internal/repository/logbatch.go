@@ -1 +1,187 @@
 package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// BatchRepository persists the batches index: one row per batch object in O3.
+type BatchRepository struct {
+	db DB
+}
+
+// NewBatchRepository returns a BatchRepository using the given pool.
+func NewBatchRepository(pool *pgxpool.Pool) *BatchRepository {
+	return &BatchRepository{db: pool}
+}
+
+const batchColumns = `key, project_id, prefix, min_timestamp, max_timestamp, entry_count, size_bytes, sha256, codec, uploaded_at, target, cid`
+
+func scanBatch(row pgx.Row) (*model.Batch, error) {
+	var b model.Batch
+	err := row.Scan(
+		&b.Key,
+		&b.ProjectID,
+		&b.Prefix,
+		&b.MinTimestamp,
+		&b.MaxTimestamp,
+		&b.Count,
+		&b.Size,
+		&b.SHA256,
+		&b.Codec,
+		&b.UploadedAt,
+		&b.Target,
+		&b.CID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Upsert records b, replacing the row of an object uploaded again under the same key.
+func (r *BatchRepository) Upsert(ctx context.Context, b *model.Batch) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO batches (`+batchColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (key) DO UPDATE SET
+			project_id = EXCLUDED.project_id,
+			prefix = EXCLUDED.prefix,
+			min_timestamp = EXCLUDED.min_timestamp,
+			max_timestamp = EXCLUDED.max_timestamp,
+			entry_count = EXCLUDED.entry_count,
+			size_bytes = EXCLUDED.size_bytes,
+			sha256 = EXCLUDED.sha256,
+			codec = EXCLUDED.codec,
+			uploaded_at = EXCLUDED.uploaded_at,
+			target = EXCLUDED.target,
+			cid = EXCLUDED.cid`,
+		b.Key,
+		b.ProjectID,
+		b.Prefix,
+		b.MinTimestamp,
+		b.MaxTimestamp,
+		b.Count,
+		b.Size,
+		b.SHA256,
+		b.Codec,
+		b.UploadedAt,
+		b.Target,
+		b.CID,
+	)
+	return err
+}
+
+// Get returns the batch of the object at key, or nil if it is not indexed.
+func (r *BatchRepository) Get(ctx context.Context, key string) (*model.Batch, error) {
+	b, err := scanBatch(r.db.QueryRow(ctx, `SELECT `+batchColumns+` FROM batches WHERE key = $1`, key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// BatchFilter selects batches. Zero fields match everything.
+type BatchFilter struct {
+	ProjectID string
+	Prefix    string
+	Start     time.Time // objects with entries at or after Start
+	End       time.Time // objects with entries before End
+	Limit     int
+}
+
+// Find returns the batches matching f, oldest entries first. Objects whose entries have no
+// valid timestamp only match a filter without Start and End.
+func (r *BatchRepository) Find(ctx context.Context, f BatchFilter) ([]model.Batch, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.ProjectID != "" {
+		where = append(where, "project_id = "+arg(f.ProjectID))
+	}
+	if f.Prefix != "" {
+		where = append(where, "prefix = "+arg(f.Prefix))
+	}
+	if !f.Start.IsZero() {
+		where = append(where, "max_timestamp >= "+arg(f.Start))
+	}
+	if !f.End.IsZero() {
+		where = append(where, "min_timestamp < "+arg(f.End))
+	}
+	q := `SELECT ` + batchColumns + ` FROM batches`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY min_timestamp NULLS FIRST, key`
+	if f.Limit > 0 {
+		q += ` LIMIT ` + arg(f.Limit)
+	}
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Batch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *b)
+	}
+	return list, rows.Err()
+}
+
+// Keys returns the keys of every indexed batch under prefix ("" for all).
+func (r *BatchRepository) Keys(ctx context.Context, prefix string) (map[string]bool, error) {
+	rows, err := r.db.Query(ctx, `SELECT key FROM batches WHERE starts_with(key, $1)`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys[k] = true
+	}
+	return keys, rows.Err()
+}
+
+// Delete removes the rows of keys, e.g. after retention or compaction deleted the objects.
+func (r *BatchRepository) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(ctx, `DELETE FROM batches WHERE key = ANY($1)`, keys)
+	return err
+}
+
+// Replace records objects and removes the rows of sources in one transaction, so a search
+// never sees both a compacted object and the objects it was merged from, or neither.
+func (r *BatchRepository) Replace(ctx context.Context, objects []model.Batch, sources []string) error {
+	return inTx(ctx, r.db, func(tx pgx.Tx) error {
+		txr := &BatchRepository{db: tx}
+		for i := range objects {
+			if err := txr.Upsert(ctx, &objects[i]); err != nil {
+				return err
+			}
+		}
+		return txr.Delete(ctx, sources...)
+	})
+}
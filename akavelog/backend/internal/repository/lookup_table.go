@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// LookupTableRepository persists lookup table definitions. It implements lookup.Definitions.
+type LookupTableRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewLookupTableRepository returns a LookupTableRepository using the given pool.
+func NewLookupTableRepository(pool *pgxpool.Pool) *LookupTableRepository {
+	return &LookupTableRepository{pool: pool}
+}
+
+const lookupTableColumns = `id, name, description, kind, key_column, csv_data, query, ttl_seconds, created_at, updated_at`
+
+func scanLookupTable(row pgx.Row) (*model.LookupTable, error) {
+	var t model.LookupTable
+	err := row.Scan(
+		&t.ID,
+		&t.Name,
+		&t.Description,
+		&t.Kind,
+		&t.KeyColumn,
+		&t.CSVData,
+		&t.Query,
+		&t.TTLSeconds,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Create inserts a new lookup table and returns it with ID and timestamps set.
+func (r *LookupTableRepository) Create(ctx context.Context, t *model.LookupTable) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO lookup_tables (id, name, description, kind, key_column, csv_data, query, ttl_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at, updated_at`,
+		t.ID,
+		t.Name,
+		t.Description,
+		t.Kind,
+		t.KeyColumn,
+		t.CSVData,
+		t.Query,
+		t.TTLSeconds,
+	).Scan(&t.CreatedAt, &t.UpdatedAt)
+}
+
+// List returns all lookup tables ordered by name.
+func (r *LookupTableRepository) List(ctx context.Context) ([]model.LookupTable, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+lookupTableColumns+` FROM lookup_tables ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.LookupTable
+	for rows.Next() {
+		t, err := scanLookupTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one lookup table by id, or nil if not found.
+func (r *LookupTableRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.LookupTable, error) {
+	return scanLookupTable(r.pool.QueryRow(ctx, `SELECT `+lookupTableColumns+` FROM lookup_tables WHERE id = $1`, id))
+}
+
+// GetByName returns one lookup table by name, or nil if not found.
+func (r *LookupTableRepository) GetByName(ctx context.Context, name string) (*model.LookupTable, error) {
+	return scanLookupTable(r.pool.QueryRow(ctx, `SELECT `+lookupTableColumns+` FROM lookup_tables WHERE name = $1`, name))
+}
+
+// Update replaces every field of an existing lookup table except id and created_at.
+func (r *LookupTableRepository) Update(ctx context.Context, t *model.LookupTable) error {
+	return r.pool.QueryRow(ctx, `
+		UPDATE lookup_tables SET name = $1, description = $2, kind = $3, key_column = $4, csv_data = $5,
+			query = $6, ttl_seconds = $7, updated_at = now()
+		WHERE id = $8
+		RETURNING updated_at`,
+		t.Name,
+		t.Description,
+		t.Kind,
+		t.KeyColumn,
+		t.CSVData,
+		t.Query,
+		t.TTLSeconds,
+		t.ID,
+	).Scan(&t.UpdatedAt)
+}
+
+// Delete removes a lookup table by id.
+func (r *LookupTableRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM lookup_tables WHERE id = $1`, id)
+	return err
+}
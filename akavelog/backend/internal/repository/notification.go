@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// NotificationChannelRepository persists notification channel definitions.
+type NotificationChannelRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewNotificationChannelRepository returns a NotificationChannelRepository using the given pool.
+func NewNotificationChannelRepository(pool *pgxpool.Pool) *NotificationChannelRepository {
+	return &NotificationChannelRepository{pool: pool}
+}
+
+const notificationChannelColumns = `id, name, type, description, enabled, configuration, created_at, updated_at`
+
+func scanNotificationChannel(row pgx.Row) (*model.NotificationChannel, error) {
+	var c model.NotificationChannel
+	err := row.Scan(
+		&c.ID,
+		&c.Name,
+		&c.Type,
+		&c.Description,
+		&c.Enabled,
+		&c.Configuration,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Create inserts a new channel and returns it with ID and timestamps set.
+func (r *NotificationChannelRepository) Create(ctx context.Context, c *model.NotificationChannel) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO notification_channels (id, name, type, description, enabled, configuration)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`,
+		c.ID,
+		c.Name,
+		c.Type,
+		c.Description,
+		c.Enabled,
+		c.Configuration,
+	).Scan(&c.CreatedAt, &c.UpdatedAt)
+}
+
+// List returns all channels ordered by name.
+func (r *NotificationChannelRepository) List(ctx context.Context) ([]model.NotificationChannel, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+notificationChannelColumns+` FROM notification_channels ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.NotificationChannel
+	for rows.Next() {
+		c, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *c)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one channel by id, or nil if not found.
+func (r *NotificationChannelRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.NotificationChannel, error) {
+	return scanNotificationChannel(r.pool.QueryRow(ctx, `SELECT `+notificationChannelColumns+` FROM notification_channels WHERE id = $1`, id))
+}
+
+// GetByName returns one channel by name, or nil if not found.
+func (r *NotificationChannelRepository) GetByName(ctx context.Context, name string) (*model.NotificationChannel, error) {
+	return scanNotificationChannel(r.pool.QueryRow(ctx, `SELECT `+notificationChannelColumns+` FROM notification_channels WHERE name = $1`, name))
+}
+
+// Update replaces every field of an existing channel except id and created_at.
+func (r *NotificationChannelRepository) Update(ctx context.Context, c *model.NotificationChannel) error {
+	return r.pool.QueryRow(ctx, `
+		UPDATE notification_channels SET name = $1, type = $2, description = $3, enabled = $4,
+			configuration = $5, updated_at = now()
+		WHERE id = $6
+		RETURNING updated_at`,
+		c.Name,
+		c.Type,
+		c.Description,
+		c.Enabled,
+		c.Configuration,
+		c.ID,
+	).Scan(&c.UpdatedAt)
+}
+
+// Delete removes a channel by id.
+func (r *NotificationChannelRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM notification_channels WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// OutputRepository persists output definitions.
+type OutputRepository struct {
+	db DB
+}
+
+// NewOutputRepository returns an OutputRepository using the given pool.
+func NewOutputRepository(pool *pgxpool.Pool) *OutputRepository {
+	return &OutputRepository{db: pool}
+}
+
+const outputColumns = `id, name, type, description, enabled, all_entries, configuration, created_at, updated_at`
+
+func scanOutput(row pgx.Row) (*model.Output, error) {
+	var o model.Output
+	err := row.Scan(
+		&o.ID,
+		&o.Name,
+		&o.Type,
+		&o.Description,
+		&o.Enabled,
+		&o.AllEntries,
+		&o.Configuration,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Create inserts a new output and returns it with ID and timestamps set.
+func (r *OutputRepository) Create(ctx context.Context, o *model.Output) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO outputs (id, name, type, description, enabled, all_entries, configuration)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		o.ID,
+		o.Name,
+		o.Type,
+		o.Description,
+		o.Enabled,
+		o.AllEntries,
+		o.Configuration,
+	).Scan(&o.CreatedAt, &o.UpdatedAt)
+}
+
+// List returns all outputs ordered by name.
+func (r *OutputRepository) List(ctx context.Context) ([]model.Output, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+outputColumns+` FROM outputs ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Output
+	for rows.Next() {
+		o, err := scanOutput(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *o)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one output by id, or nil if not found.
+func (r *OutputRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Output, error) {
+	return scanOutput(r.db.QueryRow(ctx, `SELECT `+outputColumns+` FROM outputs WHERE id = $1`, id))
+}
+
+// GetByName returns one output by name, or nil if not found.
+func (r *OutputRepository) GetByName(ctx context.Context, name string) (*model.Output, error) {
+	return scanOutput(r.db.QueryRow(ctx, `SELECT `+outputColumns+` FROM outputs WHERE name = $1`, name))
+}
+
+// Update replaces every field of an existing output except id and created_at.
+func (r *OutputRepository) Update(ctx context.Context, o *model.Output) error {
+	return r.db.QueryRow(ctx, `
+		UPDATE outputs SET name = $1, type = $2, description = $3, enabled = $4, all_entries = $5,
+			configuration = $6, updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`,
+		o.Name,
+		o.Type,
+		o.Description,
+		o.Enabled,
+		o.AllEntries,
+		o.Configuration,
+		o.ID,
+	).Scan(&o.UpdatedAt)
+}
+
+// Delete removes an output by id.
+func (r *OutputRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM outputs WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// PipelineRepository persists and reads pipeline definitions.
+type PipelineRepository struct {
+	db DB
+}
+
+// NewPipelineRepository returns a PipelineRepository using the given pool.
+func NewPipelineRepository(pool *pgxpool.Pool) *PipelineRepository {
+	return &PipelineRepository{db: pool}
+}
+
+const pipelineColumns = `id, name, description, input_id, project_id, enabled, processors, created_at, updated_at`
+
+func scanPipeline(row pgx.Row) (model.Pipeline, error) {
+	var p model.Pipeline
+	var processors []byte
+	if err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		&p.InputID,
+		&p.ProjectID,
+		&p.Enabled,
+		&processors,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(processors, &p.Processors); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// Create inserts a new pipeline and returns it with ID and timestamps set.
+func (r *PipelineRepository) Create(ctx context.Context, p *model.Pipeline) error {
+	processors, err := json.Marshal(processorsOrEmpty(p.Processors))
+	if err != nil {
+		return err
+	}
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO pipelines (id, name, description, input_id, project_id, enabled, processors)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		p.ID,
+		p.Name,
+		p.Description,
+		p.InputID,
+		p.ProjectID,
+		p.Enabled,
+		processors,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+// List returns all pipelines in creation order, which is also the order they run in.
+func (r *PipelineRepository) List(ctx context.Context) ([]model.Pipeline, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+pipelineColumns+` FROM pipelines ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Pipeline
+	for rows.Next() {
+		p, err := scanPipeline(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one pipeline by id, or nil if not found.
+func (r *PipelineRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Pipeline, error) {
+	p, err := scanPipeline(r.db.QueryRow(ctx, `SELECT `+pipelineColumns+` FROM pipelines WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Update replaces name, description, input_id, project_id, enabled and processors of an
+// existing pipeline.
+func (r *PipelineRepository) Update(ctx context.Context, p *model.Pipeline) error {
+	processors, err := json.Marshal(processorsOrEmpty(p.Processors))
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(ctx, `
+		UPDATE pipelines SET name = $1, description = $2, input_id = $3, project_id = $4, enabled = $5, processors = $6,
+			updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`,
+		p.Name,
+		p.Description,
+		p.InputID,
+		p.ProjectID,
+		p.Enabled,
+		processors,
+		p.ID,
+	).Scan(&p.UpdatedAt)
+}
+
+// Delete removes a pipeline by id.
+func (r *PipelineRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM pipelines WHERE id = $1`, id)
+	return err
+}
+
+func processorsOrEmpty(list []model.ProcessorConfig) []model.ProcessorConfig {
+	if list == nil {
+		return []model.ProcessorConfig{}
+	}
+	return list
+}
@@ -1 +1,138 @@
 package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// ProjectRepository persists projects, the tenants entries, inputs and pipelines belong to.
+type ProjectRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewProjectRepository returns a ProjectRepository using the given pool.
+func NewProjectRepository(pool *pgxpool.Pool) *ProjectRepository {
+	return &ProjectRepository{pool: pool}
+}
+
+// ProjectUsage is what belongs to a project: its inputs and pipelines, and the batch objects
+// uploaded under it.
+type ProjectUsage struct {
+	Inputs    int   `json:"inputs"`
+	Pipelines int   `json:"pipelines"`
+	Batches   int   `json:"batches"`
+	Entries   int64 `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+}
+
+const projectColumns = `id, name, description, COALESCE(owner_email, ''), o3_target, created_at, updated_at`
+
+func scanProject(row pgx.Row) (*model.Project, error) {
+	var p model.Project
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		&p.OwnerEmail,
+		&p.O3Target,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create inserts a new project and returns it with ID and timestamps set.
+func (r *ProjectRepository) Create(ctx context.Context, p *model.Project) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO projects (id, name, description, owner_email, o3_target)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING created_at, updated_at`,
+		p.ID,
+		p.Name,
+		p.Description,
+		p.OwnerEmail,
+		p.O3Target,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+// List returns all projects ordered by name.
+func (r *ProjectRepository) List(ctx context.Context) ([]model.Project, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+projectColumns+` FROM projects ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one project by id, or nil if not found.
+func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Project, error) {
+	return scanProject(r.pool.QueryRow(ctx, `SELECT `+projectColumns+` FROM projects WHERE id = $1`, id))
+}
+
+// GetByName returns one project by name, or nil if not found.
+func (r *ProjectRepository) GetByName(ctx context.Context, name string) (*model.Project, error) {
+	return scanProject(r.pool.QueryRow(ctx, `SELECT `+projectColumns+` FROM projects WHERE name = $1`, name))
+}
+
+// Update replaces name, description, owner_email and o3_target of an existing project.
+func (r *ProjectRepository) Update(ctx context.Context, p *model.Project) error {
+	return r.pool.QueryRow(ctx, `
+		UPDATE projects SET name = $1, description = $2, owner_email = NULLIF($3, ''), o3_target = $4, updated_at = now()
+		WHERE id = $5
+		RETURNING updated_at`,
+		p.Name,
+		p.Description,
+		p.OwnerEmail,
+		p.O3Target,
+		p.ID,
+	).Scan(&p.UpdatedAt)
+}
+
+// Delete removes a project by id. It fails while inputs or pipelines reference it.
+func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM projects WHERE id = $1`, id)
+	return err
+}
+
+// Usage counts the inputs and pipelines of a project and the batch objects stored under it.
+func (r *ProjectRepository) Usage(ctx context.Context, id uuid.UUID) (ProjectUsage, error) {
+	var u ProjectUsage
+	err := r.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT count(*) FROM inputs WHERE project_id = $1),
+			(SELECT count(*) FROM pipelines WHERE project_id = $1),
+			count(*), COALESCE(sum(entry_count), 0), COALESCE(sum(size_bytes), 0)
+		FROM batches WHERE project_id = $1::text`, id).Scan(
+		&u.Inputs,
+		&u.Pipelines,
+		&u.Batches,
+		&u.Entries,
+		&u.Bytes,
+	)
+	return u, err
+}
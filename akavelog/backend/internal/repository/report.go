@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// MaxReportRuns is how many runs are kept per report; older ones are deleted as new ones are
+// recorded.
+const MaxReportRuns = 100
+
+// ReportRepository persists scheduled reports and their runs.
+type ReportRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewReportRepository returns a ReportRepository using the given pool.
+func NewReportRepository(pool *pgxpool.Pool) *ReportRepository {
+	return &ReportRepository{pool: pool}
+}
+
+const reportColumns = `id, name, description, saved_search_id, project_id, schedule, timezone, formats, outputs, enabled,
+	next_run_at, last_run_at, last_status, last_error, created_at, updated_at`
+
+func scanReport(row pgx.Row) (*model.Report, error) {
+	var r model.Report
+	var formats, outputs []byte
+	err := row.Scan(
+		&r.ID,
+		&r.Name,
+		&r.Description,
+		&r.SavedSearchID,
+		&r.ProjectID,
+		&r.Schedule,
+		&r.Timezone,
+		&formats,
+		&outputs,
+		&r.Enabled,
+		&r.NextRunAt,
+		&r.LastRunAt,
+		&r.LastStatus,
+		&r.LastError,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(formats, &r.Formats); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(outputs, &r.Outputs); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func marshalReportLists(r *model.Report) (formats, outputs []byte, err error) {
+	if formats, err = json.Marshal(r.Formats); err != nil {
+		return nil, nil, err
+	}
+	outputs, err = json.Marshal(stringsOrEmpty(r.Outputs))
+	return formats, outputs, err
+}
+
+func (r *ReportRepository) list(ctx context.Context, where string, args ...any) ([]model.Report, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+reportColumns+` FROM reports `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Report
+	for rows.Next() {
+		rep, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *rep)
+	}
+	return list, rows.Err()
+}
+
+// Create inserts a new report and returns it with ID and timestamps set.
+func (r *ReportRepository) Create(ctx context.Context, rep *model.Report) error {
+	formats, outputs, err := marshalReportLists(rep)
+	if err != nil {
+		return err
+	}
+	if rep.ID == uuid.Nil {
+		rep.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO reports (id, name, description, saved_search_id, schedule, timezone, formats, outputs, enabled, next_run_at, project_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at, updated_at`,
+		rep.ID,
+		rep.Name,
+		rep.Description,
+		rep.SavedSearchID,
+		rep.Schedule,
+		rep.Timezone,
+		formats,
+		outputs,
+		rep.Enabled,
+		rep.NextRunAt,
+		rep.ProjectID,
+	).Scan(&rep.CreatedAt, &rep.UpdatedAt)
+}
+
+// List returns all reports by name.
+func (r *ReportRepository) List(ctx context.Context) ([]model.Report, error) {
+	return r.list(ctx, `ORDER BY name`)
+}
+
+// Due returns the enabled reports whose next run is at or before now, most overdue first.
+func (r *ReportRepository) Due(ctx context.Context, now time.Time) ([]model.Report, error) {
+	return r.list(ctx, `WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at`, now)
+}
+
+// GetByID returns one report by id, or nil if not found.
+func (r *ReportRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Report, error) {
+	return scanReport(r.pool.QueryRow(ctx, `SELECT `+reportColumns+` FROM reports WHERE id = $1`, id))
+}
+
+// GetByName returns one report by name, or nil if not found.
+func (r *ReportRepository) GetByName(ctx context.Context, name string) (*model.Report, error) {
+	return scanReport(r.pool.QueryRow(ctx, `SELECT `+reportColumns+` FROM reports WHERE name = $1`, name))
+}
+
+// Update replaces the definition and next run of an existing report; its last-run fields are
+// kept.
+func (r *ReportRepository) Update(ctx context.Context, rep *model.Report) error {
+	formats, outputs, err := marshalReportLists(rep)
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `
+		UPDATE reports SET name = $1, description = $2, saved_search_id = $3, schedule = $4, timezone = $5,
+			formats = $6, outputs = $7, enabled = $8, next_run_at = $9, project_id = $10, updated_at = now()
+		WHERE id = $11
+		RETURNING updated_at`,
+		rep.Name,
+		rep.Description,
+		rep.SavedSearchID,
+		rep.Schedule,
+		rep.Timezone,
+		formats,
+		outputs,
+		rep.Enabled,
+		rep.NextRunAt,
+		rep.ProjectID,
+		rep.ID,
+	).Scan(&rep.UpdatedAt)
+}
+
+// Delete removes a report and its runs.
+func (r *ReportRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM reports WHERE id = $1`, id)
+	return err
+}
+
+// Claim moves the next run of a report from due to next. It reports false when another
+// scheduler claimed the run first or the report changed since it was loaded.
+func (r *ReportRepository) Claim(ctx context.Context, id uuid.UUID, due, next time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `UPDATE reports SET next_run_at = $1 WHERE id = $2 AND next_run_at = $3 AND enabled`,
+		nullTime(next), id, due)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RecordRun adds run to the runs of its report and updates the report's last-run fields. The
+// report's runs are trimmed to MaxReportRuns.
+func (r *ReportRepository) RecordRun(ctx context.Context, run *model.ReportRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	objects, err := json.Marshal(run.Objects)
+	if err != nil {
+		return err
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO report_runs (id, report_id, scheduled, status, range_start, range_end, total, objects, duration_ms, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		run.ID,
+		run.ReportID,
+		run.Scheduled,
+		run.Status,
+		run.Start,
+		run.End,
+		run.Total,
+		objects,
+		run.DurationMS,
+		run.Error,
+	).Scan(&run.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE reports SET last_run_at = $1, last_status = $2, last_error = $3 WHERE id = $4`,
+		run.CreatedAt, run.Status, run.Error, run.ReportID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM report_runs WHERE report_id = $1 AND created_at < (
+			SELECT created_at FROM report_runs WHERE report_id = $1 ORDER BY created_at DESC OFFSET $2 LIMIT 1)`,
+		run.ReportID, MaxReportRuns-1)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListRuns returns the runs of a report, newest first; limit <= 0 returns all of them.
+func (r *ReportRepository) ListRuns(ctx context.Context, reportID uuid.UUID, limit int) ([]model.ReportRun, error) {
+	q := `SELECT id, report_id, scheduled, status, range_start, range_end, total, objects, duration_ms, error, created_at
+		FROM report_runs WHERE report_id = $1 ORDER BY created_at DESC, id`
+	args := []any{reportID}
+	if limit > 0 {
+		q += ` LIMIT $2`
+		args = append(args, limit)
+	}
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.ReportRun
+	for rows.Next() {
+		var run model.ReportRun
+		var objects []byte
+		err := rows.Scan(
+			&run.ID,
+			&run.ReportID,
+			&run.Scheduled,
+			&run.Status,
+			&run.Start,
+			&run.End,
+			&run.Total,
+			&objects,
+			&run.DurationMS,
+			&run.Error,
+			&run.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(objects, &run.Objects); err != nil {
+			return nil, err
+		}
+		list = append(list, run)
+	}
+	return list, rows.Err()
+}
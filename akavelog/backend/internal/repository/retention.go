@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// RetentionPolicyRepository persists retention policies.
+type RetentionPolicyRepository struct {
+	db DB
+}
+
+// NewRetentionPolicyRepository returns a RetentionPolicyRepository using the given pool.
+func NewRetentionPolicyRepository(pool *pgxpool.Pool) *RetentionPolicyRepository {
+	return &RetentionPolicyRepository{db: pool}
+}
+
+const retentionPolicyColumns = `id, project_id, stream_id, days, action, enabled, created_at, updated_at`
+
+func scanRetentionPolicy(row pgx.Row) (*model.RetentionPolicy, error) {
+	var p model.RetentionPolicy
+	err := row.Scan(
+		&p.ID,
+		&p.ProjectID,
+		&p.StreamID,
+		&p.Days,
+		&p.Action,
+		&p.Enabled,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create inserts a new policy and returns it with ID and timestamps set.
+func (r *RetentionPolicyRepository) Create(ctx context.Context, p *model.RetentionPolicy) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO retention_policies (id, project_id, stream_id, days, action, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`,
+		p.ID,
+		p.ProjectID,
+		p.StreamID,
+		p.Days,
+		p.Action,
+		p.Enabled,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+// List returns all policies in creation order.
+func (r *RetentionPolicyRepository) List(ctx context.Context) ([]model.RetentionPolicy, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+retentionPolicyColumns+` FROM retention_policies ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.RetentionPolicy
+	for rows.Next() {
+		p, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one policy by id, or nil if not found.
+func (r *RetentionPolicyRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.RetentionPolicy, error) {
+	return scanRetentionPolicy(r.db.QueryRow(ctx, `SELECT `+retentionPolicyColumns+` FROM retention_policies WHERE id = $1`, id))
+}
+
+// GetByScope returns the policy for a project and stream, or nil if there is none.
+func (r *RetentionPolicyRepository) GetByScope(ctx context.Context, projectID string, streamID *uuid.UUID) (*model.RetentionPolicy, error) {
+	return scanRetentionPolicy(r.db.QueryRow(ctx, `
+		SELECT `+retentionPolicyColumns+` FROM retention_policies
+		WHERE project_id = $1 AND stream_id IS NOT DISTINCT FROM $2`, projectID, streamID))
+}
+
+// Update replaces every field of an existing policy except id and created_at.
+func (r *RetentionPolicyRepository) Update(ctx context.Context, p *model.RetentionPolicy) error {
+	return r.db.QueryRow(ctx, `
+		UPDATE retention_policies SET project_id = $1, stream_id = $2, days = $3, action = $4,
+			enabled = $5, updated_at = now()
+		WHERE id = $6
+		RETURNING updated_at`,
+		p.ProjectID,
+		p.StreamID,
+		p.Days,
+		p.Action,
+		p.Enabled,
+		p.ID,
+	).Scan(&p.UpdatedAt)
+}
+
+// Delete removes a policy by id.
+func (r *RetentionPolicyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM retention_policies WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// MaxQueryHistory is how many runs the query history keeps; older ones are deleted as new
+// ones are recorded.
+const MaxQueryHistory = 10000
+
+// SavedSearchRepository persists saved searches and the query history.
+type SavedSearchRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSavedSearchRepository returns a SavedSearchRepository using the given pool.
+func NewSavedSearchRepository(pool *pgxpool.Pool) *SavedSearchRepository {
+	return &SavedSearchRepository{pool: pool}
+}
+
+const savedSearchColumns = `id, owner, shared, name, description, kind, query, project_id, time_range, params,
+	run_count, last_run_at, last_run_ms, last_results, last_error, created_at, updated_at`
+
+func scanSavedSearch(row pgx.Row) (*model.SavedSearch, error) {
+	var s model.SavedSearch
+	var params []byte
+	err := row.Scan(
+		&s.ID,
+		&s.Owner,
+		&s.Shared,
+		&s.Name,
+		&s.Description,
+		&s.Kind,
+		&s.Query,
+		&s.ProjectID,
+		&s.TimeRange,
+		&params,
+		&s.RunCount,
+		&s.LastRunAt,
+		&s.LastRunMS,
+		&s.LastResults,
+		&s.LastError,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &s.Params); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create inserts a new saved search and returns it with ID and timestamps set.
+func (r *SavedSearchRepository) Create(ctx context.Context, s *model.SavedSearch) error {
+	params, err := json.Marshal(s.Params)
+	if err != nil {
+		return err
+	}
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO saved_searches (id, owner, shared, name, description, kind, query, project_id, time_range, params)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at`,
+		s.ID,
+		s.Owner,
+		s.Shared,
+		s.Name,
+		s.Description,
+		s.Kind,
+		s.Query,
+		s.ProjectID,
+		s.TimeRange,
+		params,
+	).Scan(&s.CreatedAt, &s.UpdatedAt)
+}
+
+// List returns the searches of owner and the shared ones of others, by name. A non-empty
+// kind keeps only searches of that kind.
+func (r *SavedSearchRepository) List(ctx context.Context, owner string, kind model.QueryKind) ([]model.SavedSearch, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+savedSearchColumns+` FROM saved_searches
+		WHERE (owner = $1 OR shared) AND ($2 = '' OR kind = $2)
+		ORDER BY name, owner`, owner, string(kind))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.SavedSearch
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one saved search by id, or nil if not found.
+func (r *SavedSearchRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.SavedSearch, error) {
+	return scanSavedSearch(r.pool.QueryRow(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = $1`, id))
+}
+
+// GetByName returns the saved search of owner with the given name, or nil if there is none.
+func (r *SavedSearchRepository) GetByName(ctx context.Context, owner, name string) (*model.SavedSearch, error) {
+	return scanSavedSearch(r.pool.QueryRow(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE owner = $1 AND name = $2`, owner, name))
+}
+
+// Update replaces the definition of an existing saved search; its owner and last-run fields
+// are kept.
+func (r *SavedSearchRepository) Update(ctx context.Context, s *model.SavedSearch) error {
+	params, err := json.Marshal(s.Params)
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `
+		UPDATE saved_searches SET shared = $1, name = $2, description = $3, kind = $4, query = $5,
+			project_id = $6, time_range = $7, params = $8, updated_at = now()
+		WHERE id = $9
+		RETURNING updated_at`,
+		s.Shared,
+		s.Name,
+		s.Description,
+		s.Kind,
+		s.Query,
+		s.ProjectID,
+		s.TimeRange,
+		params,
+		s.ID,
+	).Scan(&s.UpdatedAt)
+}
+
+// Delete removes a saved search by id. Its runs stay in the history.
+func (r *SavedSearchRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM saved_searches WHERE id = $1`, id)
+	return err
+}
+
+// RecordRun adds run to the query history and, for a run of a saved search, updates its
+// last-run fields. The history is trimmed to MaxQueryHistory runs.
+func (r *SavedSearchRepository) RecordRun(ctx context.Context, run *model.QueryRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO query_history (id, saved_search_id, owner, kind, query, project_id, range_start, range_end,
+			duration_ms, results, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at`,
+		run.ID,
+		run.SavedSearchID,
+		run.Owner,
+		run.Kind,
+		run.Query,
+		run.ProjectID,
+		nullTime(run.Start),
+		nullTime(run.End),
+		run.DurationMS,
+		run.Results,
+		run.Error,
+	).Scan(&run.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if run.SavedSearchID != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE saved_searches SET run_count = run_count + 1, last_run_at = $1, last_run_ms = $2,
+				last_results = $3, last_error = $4
+			WHERE id = $5`,
+			run.CreatedAt,
+			run.DurationMS,
+			run.Results,
+			run.Error,
+			*run.SavedSearchID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM query_history WHERE created_at < (
+			SELECT created_at FROM query_history ORDER BY created_at DESC OFFSET $1 LIMIT 1)`, MaxQueryHistory-1)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RunFilter selects runs of the query history. Zero fields match everything.
+type RunFilter struct {
+	Owner         string // runs of this owner
+	SavedSearchID *uuid.UUID
+	Kind          model.QueryKind
+	Limit         int
+}
+
+const queryRunColumns = `id, saved_search_id, owner, kind, query, project_id, range_start, range_end,
+	duration_ms, results, error, created_at`
+
+// ListRuns returns the runs matching f, newest first.
+func (r *SavedSearchRepository) ListRuns(ctx context.Context, f RunFilter) ([]model.QueryRun, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Owner != "" {
+		where = append(where, "owner = "+arg(f.Owner))
+	}
+	if f.SavedSearchID != nil {
+		where = append(where, "saved_search_id = "+arg(*f.SavedSearchID))
+	}
+	if f.Kind != "" {
+		where = append(where, "kind = "+arg(string(f.Kind)))
+	}
+	q := `SELECT ` + queryRunColumns + ` FROM query_history`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY created_at DESC, id`
+	if f.Limit > 0 {
+		q += ` LIMIT ` + arg(f.Limit)
+	}
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.QueryRun
+	for rows.Next() {
+		var run model.QueryRun
+		var start, end *time.Time
+		err := rows.Scan(
+			&run.ID,
+			&run.SavedSearchID,
+			&run.Owner,
+			&run.Kind,
+			&run.Query,
+			&run.ProjectID,
+			&start,
+			&end,
+			&run.DurationMS,
+			&run.Results,
+			&run.Error,
+			&run.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if start != nil {
+			run.Start = *start
+		}
+		if end != nil {
+			run.End = *end
+		}
+		list = append(list, run)
+	}
+	return list, rows.Err()
+}
+
+// nullTime returns nil for the zero time, so it is stored as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// StreamRepository persists stream definitions.
+type StreamRepository struct {
+	db DB
+}
+
+// NewStreamRepository returns a StreamRepository using the given pool.
+func NewStreamRepository(pool *pgxpool.Pool) *StreamRepository {
+	return &StreamRepository{db: pool}
+}
+
+const streamColumns = `id, name, description, enabled, match_type, rules, retention_days, o3_prefix, o3_target, outputs, created_at, updated_at`
+
+func scanStream(row pgx.Row) (*model.Stream, error) {
+	var s model.Stream
+	var rules, outputs []byte
+	err := row.Scan(
+		&s.ID,
+		&s.Name,
+		&s.Description,
+		&s.Enabled,
+		&s.MatchType,
+		&rules,
+		&s.RetentionDays,
+		&s.O3Prefix,
+		&s.O3Target,
+		&outputs,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(rules, &s.Rules); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(outputs, &s.Outputs); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create inserts a new stream and returns it with ID and timestamps set.
+func (r *StreamRepository) Create(ctx context.Context, s *model.Stream) error {
+	rules, outputs, err := marshalStreamLists(s)
+	if err != nil {
+		return err
+	}
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO streams (id, name, description, enabled, match_type, rules, retention_days, o3_prefix, o3_target, outputs)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at`,
+		s.ID,
+		s.Name,
+		s.Description,
+		s.Enabled,
+		s.MatchType,
+		rules,
+		s.RetentionDays,
+		s.O3Prefix,
+		s.O3Target,
+		outputs,
+	).Scan(&s.CreatedAt, &s.UpdatedAt)
+}
+
+// List returns all streams in creation order, which is also the order they are matched in.
+func (r *StreamRepository) List(ctx context.Context) ([]model.Stream, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+streamColumns+` FROM streams ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.Stream
+	for rows.Next() {
+		s, err := scanStream(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one stream by id, or nil if not found.
+func (r *StreamRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Stream, error) {
+	return scanStream(r.db.QueryRow(ctx, `SELECT `+streamColumns+` FROM streams WHERE id = $1`, id))
+}
+
+// GetByName returns one stream by name, or nil if not found.
+func (r *StreamRepository) GetByName(ctx context.Context, name string) (*model.Stream, error) {
+	return scanStream(r.db.QueryRow(ctx, `SELECT `+streamColumns+` FROM streams WHERE name = $1`, name))
+}
+
+// Update replaces every field of an existing stream except id and created_at.
+func (r *StreamRepository) Update(ctx context.Context, s *model.Stream) error {
+	rules, outputs, err := marshalStreamLists(s)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(ctx, `
+		UPDATE streams SET name = $1, description = $2, enabled = $3, match_type = $4, rules = $5,
+			retention_days = $6, o3_prefix = $7, o3_target = $8, outputs = $9, updated_at = now()
+		WHERE id = $10
+		RETURNING updated_at`,
+		s.Name,
+		s.Description,
+		s.Enabled,
+		s.MatchType,
+		rules,
+		s.RetentionDays,
+		s.O3Prefix,
+		s.O3Target,
+		outputs,
+		s.ID,
+	).Scan(&s.UpdatedAt)
+}
+
+// Delete removes a stream by id.
+func (r *StreamRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM streams WHERE id = $1`, id)
+	return err
+}
+
+// DetachOutput removes the output called name from the outputs of every stream that lists it,
+// and returns how many did.
+func (r *StreamRepository) DetachOutput(ctx context.Context, name string) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE streams SET outputs = outputs - $1::text, updated_at = now()
+		WHERE outputs @> jsonb_build_array($1::text)`, name)
+	return tag.RowsAffected(), err
+}
+
+// RenameOutput replaces the output called from by to in the outputs of every stream that
+// lists it, and returns how many did.
+func (r *StreamRepository) RenameOutput(ctx context.Context, from, to string) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE streams SET outputs = (
+			SELECT jsonb_agg(CASE WHEN v = $1 THEN $2 ELSE v END ORDER BY n)
+			FROM jsonb_array_elements_text(outputs) WITH ORDINALITY AS o(v, n)
+		), updated_at = now()
+		WHERE outputs @> jsonb_build_array($1::text)`, from, to)
+	return tag.RowsAffected(), err
+}
+
+func marshalStreamLists(s *model.Stream) ([]byte, []byte, error) {
+	rules, err := json.Marshal(stringsOrEmpty(s.Rules))
+	if err != nil {
+		return nil, nil, err
+	}
+	outputs, err := json.Marshal(stringsOrEmpty(s.Outputs))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, outputs, nil
+}
+
+func stringsOrEmpty(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB is what the repositories built on it run their statements on: a *pgxpool.Pool, or a
+// pgx.Tx when they take part in a UnitOfWork. Begin on a transaction opens a savepoint, so a
+// repository method that needs a transaction of its own nests inside an outer one.
+type DB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// UnitOfWork runs repository calls that must succeed or fail together, such as deleting an
+// output and detaching it from the streams that list it, in one transaction.
+type UnitOfWork struct {
+	db DB
+}
+
+// NewUnitOfWork returns a UnitOfWork opening its transactions on pool.
+func NewUnitOfWork(pool *pgxpool.Pool) *UnitOfWork {
+	return &UnitOfWork{db: pool}
+}
+
+// Do runs fn in a transaction. It commits when fn returns nil and rolls back when fn returns
+// an error or panics; the error is fn's, or that of the commit.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(tx *Tx) error) error {
+	return inTx(ctx, u.db, func(t pgx.Tx) error { return fn(&Tx{db: t}) })
+}
+
+// inTx runs fn in a transaction of db, or a savepoint when db is one already.
+func inTx(ctx context.Context, db DB, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db, fn)
+}
+
+// Tx hands out repositories bound to the transaction of a UnitOfWork. They must not be used
+// after Do returns.
+type Tx struct {
+	db pgx.Tx
+}
+
+// Batches returns the batches index in the transaction.
+func (t *Tx) Batches() *BatchRepository { return &BatchRepository{db: t.db} }
+
+// Streams returns the streams in the transaction.
+func (t *Tx) Streams() *StreamRepository { return &StreamRepository{db: t.db} }
+
+// Pipelines returns the pipelines in the transaction.
+func (t *Tx) Pipelines() *PipelineRepository { return &PipelineRepository{db: t.db} }
+
+// Outputs returns the outputs in the transaction.
+func (t *Tx) Outputs() *OutputRepository { return &OutputRepository{db: t.db} }
+
+// RetentionPolicies returns the retention policies in the transaction.
+func (t *Tx) RetentionPolicies() *RetentionPolicyRepository {
+	return &RetentionPolicyRepository{db: t.db}
+}
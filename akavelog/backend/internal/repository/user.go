@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// UserRepository persists the users of the management API.
+type UserRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserRepository returns a UserRepository using the given pool.
+func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
+	return &UserRepository{pool: pool}
+}
+
+const userColumns = `id, email, name, password_hash, sso_issuer, sso_subject, role, disabled,
+	password_changed_at, last_login_at, created_at, updated_at`
+
+func scanUser(row pgx.Row) (*model.User, error) {
+	var u model.User
+	err := row.Scan(
+		&u.ID,
+		&u.Email,
+		&u.Name,
+		&u.PasswordHash,
+		&u.SSOIssuer,
+		&u.SSOSubject,
+		&u.Role,
+		&u.Disabled,
+		&u.PasswordChangedAt,
+		&u.LastLoginAt,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Create inserts a new user and returns it with ID and timestamps set.
+func (r *UserRepository) Create(ctx context.Context, u *model.User) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO users (id, email, name, password_hash, sso_issuer, sso_subject, role, disabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING password_changed_at, created_at, updated_at`,
+		u.ID,
+		u.Email,
+		u.Name,
+		u.PasswordHash,
+		u.SSOIssuer,
+		u.SSOSubject,
+		u.Role,
+		u.Disabled,
+	).Scan(&u.PasswordChangedAt, &u.CreatedAt, &u.UpdatedAt)
+}
+
+// List returns all users ordered by email.
+func (r *UserRepository) List(ctx context.Context) ([]model.User, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY email`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *u)
+	}
+	return list, rows.Err()
+}
+
+// GetByID returns one user by id, or nil if not found.
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+// GetByEmail returns one user by lower-case email, or nil if not found.
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+// GetBySSO returns the user single sign-on created for subject at issuer, or nil if not found.
+func (r *UserRepository) GetBySSO(ctx context.Context, issuer, subject string) (*model.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users
+		WHERE sso_issuer = $1 AND sso_subject = $2 AND sso_subject <> ''`, issuer, subject))
+}
+
+// Update replaces email, name, role, disabled and the single sign-on account of an existing user.
+func (r *UserRepository) Update(ctx context.Context, u *model.User) error {
+	return r.pool.QueryRow(ctx, `
+		UPDATE users SET email = $1, name = $2, role = $3, disabled = $4, sso_issuer = $5,
+			sso_subject = $6, updated_at = now()
+		WHERE id = $7
+		RETURNING updated_at`,
+		u.Email,
+		u.Name,
+		u.Role,
+		u.Disabled,
+		u.SSOIssuer,
+		u.SSOSubject,
+		u.ID,
+	).Scan(&u.UpdatedAt)
+}
+
+// SetPassword replaces the password hash of a user, which ends the sessions issued before.
+func (r *UserRepository) SetPassword(ctx context.Context, u *model.User) error {
+	return r.pool.QueryRow(ctx, `
+		UPDATE users SET password_hash = $1, password_changed_at = now(), updated_at = now()
+		WHERE id = $2
+		RETURNING password_changed_at, updated_at`,
+		u.PasswordHash,
+		u.ID,
+	).Scan(&u.PasswordChangedAt, &u.UpdatedAt)
+}
+
+// TouchLogin records that the user logged in at.
+func (r *UserRepository) TouchLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE users SET last_login_at = $1 WHERE id = $2`, at, id)
+	return err
+}
+
+// CountAdmins returns how many enabled admins there are other than exclude.
+func (r *UserRepository) CountAdmins(ctx context.Context, exclude uuid.UUID) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `
+		SELECT count(*) FROM users WHERE role = $1 AND NOT disabled AND id <> $2`,
+		model.RoleAdmin, exclude).Scan(&n)
+	return n, err
+}
+
+// Delete removes a user by id.
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	return err
+}
@@ -3,6 +3,7 @@ package response
 import (
 	"net/http"
 
+	"github.com/akave-ai/akavelog/internal/secrets"
 	"github.com/labstack/echo/v4"
 )
 
@@ -14,12 +15,14 @@ type APIResponse struct {
 	Path    string `json:"path"`
 }
 
-// APIError is the standard error response shape.
+// APIError is the standard error response shape. RequestID is the X-Request-ID of the
+// response, to quote when reporting the error.
 type APIError struct {
-	Message string `json:"message"`
-	Error   string `json:"error"`
-	Path    string `json:"path"`
-	Status  int    `json:"status"`
+	Message   string `json:"message"`
+	Error     string `json:"error"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // pathFromContext returns the request path from Echo context.
@@ -50,18 +53,29 @@ func Created(c echo.Context, data any, message string) error {
 	})
 }
 
+// Accepted sends a 202 response with data, for work that continues in the background.
+func Accepted(c echo.Context, data any, message string) error {
+	return c.JSON(http.StatusAccepted, APIResponse{
+		Data:    data,
+		Status:  http.StatusAccepted,
+		Message: message,
+		Path:    pathFromContext(c),
+	})
+}
+
 // NoContent sends 204. For consistency you can use OK(c, nil, "Deleted") with 200 instead if you want a body.
 func NoContent(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
-// Error sends a JSON error response using APIError.
+// Error sends a JSON error response using APIError. Resolved secrets in errDetail are masked.
 func Error(c echo.Context, status int, message, errDetail string) error {
 	return c.JSON(status, APIError{
-		Message: message,
-		Error:   errDetail,
-		Path:    pathFromContext(c),
-		Status:  status,
+		Message:   message,
+		Error:     secrets.Scrub(errDetail),
+		Path:      pathFromContext(c),
+		Status:    status,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
 	})
 }
 
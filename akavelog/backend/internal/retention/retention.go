@@ -0,0 +1,359 @@
+// Package retention deletes batch objects from O3 once they are older than their retention
+// policy, or moves them under archive/. Policies are per project and stream (see
+// model.RetentionPolicy); a stream's retention_days and the server default apply where no
+// policy does.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ArchivePrefix is where archived objects are moved, keeping the rest of their key.
+const ArchivePrefix = "archive"
+
+// DefaultPrefix holds the objects of entries not stored under a stream's o3_prefix.
+const DefaultPrefix = storage.DefaultPrefix
+
+const day = 24 * time.Hour
+
+// Store is the part of storage.O3Client the manager uses.
+type Store interface {
+	ListObjects(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+	CopyObject(ctx context.Context, src, dst string) error
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// Rule is a resolved retention policy: objects under Prefix/Project/ are kept Days days.
+type Rule struct {
+	Prefix  string                `json:"prefix"`
+	Project string                `json:"project_id,omitempty"` // "" for every project
+	Days    int                   `json:"days"`                 // 0 keeps objects forever
+	Action  model.RetentionAction `json:"action"`
+	Source  string                `json:"source"` // "policy <id>", "stream <name>" or "default"
+}
+
+// BuildRules resolves policies, stream retention_days and defaultDays into rules, in the order
+// they take precedence. Policies of disabled or deleted streams, or of streams without an
+// o3_prefix (whose entries are stored with everything else under logs/), are skipped.
+func BuildRules(policies []model.RetentionPolicy, streams []model.Stream, defaultDays int) []Rule {
+	prefixes := make(map[uuid.UUID]string, len(streams))
+	for _, s := range streams {
+		if s.Enabled && s.O3Prefix != "" {
+			prefixes[s.ID] = s.O3Prefix
+		}
+	}
+	var rules []Rule
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+		prefix := DefaultPrefix
+		if p.StreamID != nil {
+			var ok bool
+			if prefix, ok = prefixes[*p.StreamID]; !ok {
+				continue
+			}
+		}
+		action := p.Action
+		if action == "" {
+			action = model.RetentionDelete
+		}
+		rules = append(rules, Rule{Prefix: prefix, Project: p.ProjectID, Days: p.Days, Action: action, Source: "policy " + p.ID.String()})
+	}
+	for _, s := range streams {
+		if _, ok := prefixes[s.ID]; ok && s.RetentionDays > 0 {
+			rules = append(rules, Rule{Prefix: s.O3Prefix, Days: s.RetentionDays, Action: model.RetentionDelete, Source: "stream " + s.Name})
+		}
+	}
+	if defaultDays > 0 {
+		rules = append(rules, Rule{Prefix: DefaultPrefix, Days: defaultDays, Action: model.RetentionDelete, Source: "default"})
+	}
+	return rules
+}
+
+// resolve returns the rule for key: among the rules with the longest prefix key is under, the
+// first for its project, else the first for every project. It returns nil when none applies.
+func resolve(rules []Rule, key string) *Rule {
+	prefix := ""
+	for _, r := range rules {
+		if len(r.Prefix) > len(prefix) && strings.HasPrefix(key, r.Prefix+"/") {
+			prefix = r.Prefix
+		}
+	}
+	if prefix == "" {
+		return nil
+	}
+	project, _, _ := strings.Cut(strings.TrimPrefix(key, prefix+"/"), "/")
+	var fallback *Rule
+	for i := range rules {
+		r := &rules[i]
+		if r.Prefix != prefix {
+			continue
+		}
+		if r.Project == project {
+			return r
+		}
+		if r.Project == "" && fallback == nil {
+			fallback = r
+		}
+	}
+	return fallback
+}
+
+// Action is an object due for deletion or archiving.
+type Action struct {
+	Key          string                `json:"key"`
+	Size         int64                 `json:"size"`
+	LastModified time.Time             `json:"last_modified"`
+	ExpiresAt    time.Time             `json:"expires_at"`
+	Action       model.RetentionAction `json:"action"`
+	Rule         string                `json:"rule"` // Rule.Source
+}
+
+// RunStats describes one retention run.
+type RunStats struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DryRun     bool      `json:"dry_run"`
+	Scanned    int       `json:"scanned"`
+	Deleted    int       `json:"deleted"`  // would be deleted in a dry run
+	Archived   int       `json:"archived"` // would be archived in a dry run
+	Bytes      int64     `json:"bytes"`
+	Errors     int       `json:"errors"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Config configures a Manager.
+type Config struct {
+	Interval time.Duration // between runs (default 1h)
+	DryRun   bool          // only log and report what runs would do
+
+	// OnDelete, when set, is called with the key of every object deleted or archived, so the
+	// batches index can follow.
+	OnDelete func(key string)
+	// OnRun, when set, is called with the stats of every run, dry runs and failed ones included.
+	OnRun func(st RunStats)
+}
+
+// Manager applies retention rules every Interval.
+type Manager struct {
+	store  Store
+	rules  func(ctx context.Context) ([]Rule, error)
+	config Config // Interval and DryRun are guarded by mu
+	reset  chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu   sync.Mutex
+	last *RunStats
+}
+
+// NewManager starts a manager that applies the rules returned by rules, loaded anew for each
+// run, to the objects in store. The first run starts right away.
+func NewManager(cfg Config, store Store, rules func(ctx context.Context) ([]Rule, error)) *Manager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	m := &Manager{store: store, rules: rules, config: cfg, reset: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	go m.loop()
+	return m
+}
+
+func (m *Manager) loop() {
+	defer close(m.done)
+	t := time.NewTicker(m.interval())
+	defer t.Stop()
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-m.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if _, err := m.Run(ctx, m.DryRun()); err != nil {
+			log.Printf("[retention] %v", err)
+		}
+		cancel()
+		for waiting := true; waiting; {
+			select {
+			case <-m.stop:
+				return
+			case <-m.reset:
+				t.Reset(m.interval())
+			case <-t.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+func (m *Manager) interval() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.Interval
+}
+
+// Reconfigure changes the interval (default 1h) and dry-run mode of scheduled runs. A new
+// interval counts from now.
+func (m *Manager) Reconfigure(interval time.Duration, dryRun bool) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	m.mu.Lock()
+	m.config.Interval, m.config.DryRun = interval, dryRun
+	m.mu.Unlock()
+	select {
+	case m.reset <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the manager, interrupting a run in progress.
+func (m *Manager) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// DryRun reports whether scheduled runs only report what they would do.
+func (m *Manager) DryRun() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.DryRun
+}
+
+// LastRun returns the stats of the last run, or nil before the first one.
+func (m *Manager) LastRun() *RunStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return nil
+	}
+	st := *m.last
+	return &st
+}
+
+// Upcoming returns the objects that expire before until, soonest first.
+func (m *Manager) Upcoming(ctx context.Context, until time.Time) ([]Action, error) {
+	actions, _, err := m.plan(ctx, until)
+	return actions, err
+}
+
+// plan lists the objects under every rule prefix and returns those expiring before until, and
+// how many objects it looked at.
+func (m *Manager) plan(ctx context.Context, until time.Time) ([]Action, int, error) {
+	rules, err := m.rules(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load rules: %w", err)
+	}
+	listed := make(map[string]bool) // prefixes
+	seen := make(map[string]bool)   // keys; a nested prefix lists objects again
+	var actions []Action
+	for _, r := range rules {
+		if listed[r.Prefix] {
+			continue
+		}
+		listed[r.Prefix] = true
+		objects, err := m.store.ListObjects(ctx, r.Prefix+"/")
+		if err != nil {
+			return nil, len(seen), fmt.Errorf("list %s/: %w", r.Prefix, err)
+		}
+		for _, obj := range objects {
+			if seen[obj.Key] {
+				continue
+			}
+			seen[obj.Key] = true
+			rule := resolve(rules, obj.Key)
+			if rule == nil || rule.Days <= 0 {
+				continue
+			}
+			expires := uploadedAt(obj).Add(time.Duration(rule.Days) * day)
+			if expires.After(until) {
+				continue
+			}
+			actions = append(actions, Action{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified,
+				ExpiresAt: expires, Action: rule.Action, Rule: rule.Source})
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].ExpiresAt.Before(actions[j].ExpiresAt) })
+	return actions, len(seen), nil
+}
+
+// uploadedAt is when obj was uploaded: its last modification, or the end of the day in its key
+// when that is earlier, so objects rewritten by the compactor keep the age of their entries.
+func uploadedAt(obj storage.ObjectInfo) time.Time {
+	if d, ok := storage.KeyDate(obj.Key); ok && d.Add(day).Before(obj.LastModified) {
+		return d.Add(day)
+	}
+	return obj.LastModified
+}
+
+// Run deletes or archives every expired object, or with dryRun only counts them.
+func (m *Manager) Run(ctx context.Context, dryRun bool) (RunStats, error) {
+	st := RunStats{StartedAt: time.Now().UTC(), DryRun: dryRun}
+	actions, scanned, err := m.plan(ctx, st.StartedAt)
+	st.Scanned = scanned
+	if err != nil {
+		st.Errors++
+		st.LastError = err.Error()
+	}
+	for _, a := range actions {
+		if ctx.Err() != nil {
+			break
+		}
+		if !dryRun {
+			if err := m.apply(ctx, a); err != nil {
+				st.Errors++
+				st.LastError = err.Error()
+				log.Printf("[retention] %s %s: %v", a.Action, a.Key, err)
+				continue
+			}
+			if m.config.OnDelete != nil {
+				m.config.OnDelete(a.Key)
+			}
+		}
+		if a.Action == model.RetentionArchive {
+			st.Archived++
+		} else {
+			st.Deleted++
+		}
+		st.Bytes += a.Size
+	}
+	st.FinishedAt = time.Now().UTC()
+	if st.Deleted+st.Archived > 0 || st.Errors > 0 {
+		verb := ""
+		if dryRun {
+			verb = " (dry run)"
+		}
+		log.Printf("[retention] scanned %d objects: deleted %d, archived %d, %d bytes, %d errors%s",
+			st.Scanned, st.Deleted, st.Archived, st.Bytes, st.Errors, verb)
+	}
+	m.mu.Lock()
+	m.last = &st
+	m.mu.Unlock()
+	if m.config.OnRun != nil {
+		m.config.OnRun(st)
+	}
+	return st, err
+}
+
+// apply deletes a's object, after copying it under ArchivePrefix when archiving.
+func (m *Manager) apply(ctx context.Context, a Action) error {
+	if a.Action == model.RetentionArchive {
+		if err := m.store.CopyObject(ctx, a.Key, ArchivePrefix+"/"+a.Key); err != nil {
+			return fmt.Errorf("copy: %w", err)
+		}
+	}
+	return m.store.DeleteObject(ctx, a.Key)
+}
@@ -0,0 +1,136 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/google/uuid"
+)
+
+// memStore is an in-memory bucket.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string]time.Time // key → last modified
+}
+
+func (s *memStore) ListObjects(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []storage.ObjectInfo
+	for k, t := range s.objects {
+		if len(k) >= len(prefix) && k[:len(prefix)] == prefix {
+			out = append(out, storage.ObjectInfo{Key: k, Size: 10, LastModified: t})
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) CopyObject(_ context.Context, src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[dst] = time.Now()
+	return nil
+}
+
+func (s *memStore) DeleteObject(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStore) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for k := range s.objects {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestBuildRulesPrecedence(t *testing.T) {
+	audit := model.Stream{ID: uuid.New(), Name: "audit", Enabled: true, O3Prefix: "audit", RetentionDays: 365}
+	shop := uuid.New()
+	rules := BuildRules([]model.RetentionPolicy{
+		{ID: shop, ProjectID: "shop", Days: 7, Action: model.RetentionArchive, Enabled: true},
+		{ID: uuid.New(), ProjectID: "shop", StreamID: &audit.ID, Days: 0, Enabled: true},
+		{ID: uuid.New(), ProjectID: "off", Days: 1, Enabled: false},
+	}, []model.Stream{audit}, 30)
+
+	cases := map[string]string{
+		"logs/shop/2024/01/01/a.json.gz":   "policy " + shop.String(),
+		"logs/other/2024/01/01/a.json.gz":  "default",
+		"logs/off/2024/01/01/a.json.gz":    "default",
+		"audit/other/2024/01/01/a.json.gz": "stream audit",
+	}
+	for key, want := range cases {
+		if r := resolve(rules, key); r == nil || r.Source != want {
+			t.Errorf("%s: rule %+v, want %s", key, r, want)
+		}
+	}
+	if r := resolve(rules, "audit/shop/2024/01/01/a.json.gz"); r == nil || r.Days != 0 {
+		t.Errorf("audit/shop: rule %+v, want the keep-forever policy", r)
+	}
+	if r := resolve(rules, "deadletter/x.json.gz"); r != nil {
+		t.Errorf("deadletter: rule %+v, want none", r)
+	}
+}
+
+func TestManagerRun(t *testing.T) {
+	old := time.Now().Add(-10 * day)
+	store := &memStore{objects: map[string]time.Time{
+		"logs/shop/a.json.gz":  old,
+		"logs/other/b.json.gz": old,
+		"logs/other/c.json.gz": time.Now(),
+	}}
+	rules := []Rule{
+		{Prefix: "logs", Project: "shop", Days: 5, Action: model.RetentionArchive, Source: "shop"},
+		{Prefix: "logs", Days: 5, Action: model.RetentionDelete, Source: "default"},
+	}
+	m := NewManager(Config{Interval: time.Hour, DryRun: true}, store, func(context.Context) ([]Rule, error) { return rules, nil })
+	defer m.Stop()
+
+	upcoming, err := m.Upcoming(context.Background(), time.Now().Add(6*day))
+	if err != nil || len(upcoming) != 3 {
+		t.Fatalf("upcoming = %+v, %v", upcoming, err)
+	}
+
+	st, err := m.Run(context.Background(), true)
+	if err != nil || st.Scanned != 3 || st.Deleted != 1 || st.Archived != 1 {
+		t.Fatalf("dry run = %+v, %v", st, err)
+	}
+	if len(store.keys()) != 3 {
+		t.Fatalf("dry run changed the bucket: %v", store.keys())
+	}
+
+	if _, err := m.Run(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	got := store.keys()
+	want := []string{"archive/logs/shop/a.json.gz", "logs/other/c.json.gz"}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("bucket = %v, want %v", got, want)
+	}
+	if last := m.LastRun(); last == nil || last.DryRun || last.Deleted != 1 {
+		t.Fatalf("last run = %+v", last)
+	}
+}
+
+func TestUploadedAtCompacted(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	obj := storage.ObjectInfo{Key: "logs/p/2024/02/17/compacted-x.json.gz", LastModified: modified}
+	if got, want := uploadedAt(obj), time.Date(2024, 2, 18, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("uploadedAt = %v, want %v", got, want)
+	}
+	obj.Key = "logs/p/2024/03/01/x.json.gz"
+	if got := uploadedAt(obj); !got.Equal(modified) {
+		t.Fatalf("uploadedAt = %v, want %v", got, modified)
+	}
+}
@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tokEOF    tokKind = iota
+	tokWord           // field names, bare values and keywords
+	tokString         // quoted values
+	tokOp             // comparison operators
+	tokPunct          // ( ) [ ] ,
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) isKeyword(words ...string) bool {
+	for _, w := range words {
+		if (t.kind == tokWord || t.kind == tokOp) && strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// twoCharOps are matched before the single-character ones.
+var twoCharOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||"}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '@' || c == '/' || c == ':' ||
+		c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			toks = append(toks, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, &SyntaxError{Pos: i, Msg: err.Error()}
+			}
+			toks = append(toks, token{kind: tokString, text: s, pos: i})
+			i += n
+		case strings.ContainsRune("=!<>&|~", rune(c)):
+			op := ""
+			for _, o := range twoCharOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			switch {
+			case op != "":
+			case c == '<' || c == '>':
+				op = string(c)
+			case c == '!':
+				op = "!"
+			case c == '=':
+				op = "==" // a single = reads as equality
+			default:
+				return nil, &SyntaxError{Pos: i, Msg: "unexpected " + strconv.Quote(string(c))}
+			}
+			n := len(op)
+			if op == "==" && !strings.HasPrefix(src[i:], "==") {
+				n = 1
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += n
+		case isWordByte(c):
+			start := i
+			for i < len(src) && isWordByte(src[i]) {
+				i++
+			}
+			toks = append(toks, token{kind: tokWord, text: src[start:i], pos: start})
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: "unexpected " + strconv.Quote(string(c))}
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads a single- or double-quoted string with backslash escapes and returns its
+// value and length in src.
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				// Keep the backslash for anything else so regexes like "\d+" need no double escaping.
+				if src[i] != quote && src[i] != '\\' {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(src[i])
+			}
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errUnterminated
+}
+
+type lexError string
+
+func (e lexError) Error() string { return string(e) }
+
+const errUnterminated = lexError("unterminated string")
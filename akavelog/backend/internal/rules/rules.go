@@ -0,0 +1,312 @@
+// Package rules implements the small expression language used to match log entries, e.g.
+//
+//	level >= warn and service in [api, web] and not message =~ "health.?check"
+//
+// Fields are looked up through a caller-supplied getter, so the package does not depend on
+// how entries are stored.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Getter returns the value of a field and whether it is present.
+type Getter func(field string) (string, bool)
+
+// Expr is a parsed expression. It is immutable and safe for concurrent use.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source the expression was parsed from.
+func (e *Expr) String() string { return e.src }
+
+// Match evaluates the expression against the fields returned by get. Missing fields compare
+// as the empty string; use exists(field) to test for presence.
+func (e *Expr) Match(get Getter) bool { return e.root.eval(get) }
+
+// SyntaxError reports where an expression failed to parse.
+type SyntaxError struct {
+	Pos int // byte offset in the source
+	Msg string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg) }
+
+// Parse compiles src. Regular expressions are compiled here, so Match never fails.
+func Parse(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type node interface {
+	eval(get Getter) bool
+}
+
+type andNode struct{ l, r node }
+type orNode struct{ l, r node }
+type notNode struct{ n node }
+type existsNode struct{ field string }
+
+func (n andNode) eval(get Getter) bool { return n.l.eval(get) && n.r.eval(get) }
+func (n orNode) eval(get Getter) bool  { return n.l.eval(get) || n.r.eval(get) }
+func (n notNode) eval(get Getter) bool { return !n.n.eval(get) }
+func (n existsNode) eval(get Getter) bool {
+	_, ok := get(n.field)
+	return ok
+}
+
+type cmpNode struct {
+	field  string
+	op     string
+	value  string
+	values []string       // in
+	re     *regexp.Regexp // =~ and !~
+}
+
+func (n cmpNode) eval(get Getter) bool {
+	v, _ := get(n.field)
+	switch n.op {
+	case "==":
+		return compare(n.field, v, n.value) == 0
+	case "!=":
+		return compare(n.field, v, n.value) != 0
+	case "<":
+		return compare(n.field, v, n.value) < 0
+	case "<=":
+		return compare(n.field, v, n.value) <= 0
+	case ">":
+		return compare(n.field, v, n.value) > 0
+	case ">=":
+		return compare(n.field, v, n.value) >= 0
+	case "=~":
+		return n.re.MatchString(v)
+	case "!~":
+		return !n.re.MatchString(v)
+	case "contains":
+		return strings.Contains(v, n.value)
+	case "startswith":
+		return strings.HasPrefix(v, n.value)
+	case "endswith":
+		return strings.HasSuffix(v, n.value)
+	case "in":
+		for _, want := range n.values {
+			if compare(n.field, v, want) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// levelRank orders the canonical levels so "level >= warn" works.
+var levelRank = map[string]int{"trace": 0, "debug": 1, "info": 2, "warn": 3, "error": 4, "fatal": 5}
+
+// compare orders a and b: by level rank for the level field, numerically when both are
+// numbers, and as case-sensitive strings otherwise.
+func compare(field, a, b string) int {
+	if field == "level" {
+		ra, oka := levelRank[strings.ToLower(a)]
+		rb, okb := levelRank[strings.ToLower(b)]
+		if oka && okb {
+			return ra - rb
+		}
+	}
+	if fa, err := strconv.ParseFloat(a, 64); err == nil {
+		if fb, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// MaxDepth bounds how deeply parentheses and NOTs nest, so that a crafted expression cannot
+// exhaust the stack of the recursive parser.
+const MaxDepth = 100
+
+type parser struct {
+	toks  []token
+	i     int
+	depth int // of parentheses and NOTs around i
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or", "||") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and", "&&") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().isKeyword("not", "!") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+// enter descends into a nested expression, refusing more than MaxDepth levels.
+func (p *parser) enter() error {
+	if p.depth++; p.depth > MaxDepth {
+		return &SyntaxError{Pos: p.peek().pos, Msg: fmt.Sprintf("expression nested more than %d levels deep", MaxDepth)}
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokPunct && t.text == "(":
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.text != ")" || c.kind != tokPunct {
+			return nil, &SyntaxError{Pos: c.pos, Msg: "expected )"}
+		}
+		return n, nil
+	case t.kind == tokWord && strings.EqualFold(t.text, "exists") && p.peek().text == "(":
+		p.next()
+		f := p.next()
+		if f.kind != tokWord {
+			return nil, &SyntaxError{Pos: f.pos, Msg: "expected field name"}
+		}
+		if c := p.next(); c.text != ")" {
+			return nil, &SyntaxError{Pos: c.pos, Msg: "expected )"}
+		}
+		return existsNode{field: f.text}, nil
+	case t.kind == tokWord:
+		return p.parseComparison(t)
+	case t.kind == tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	}
+	return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected field name, got %q", t.text)}
+}
+
+var (
+	symbolOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "=~": true, "!~": true}
+	wordOps   = map[string]bool{"in": true, "contains": true, "startswith": true, "endswith": true}
+)
+
+func (p *parser) parseComparison(field token) (node, error) {
+	opTok := p.next()
+	op := opTok.text
+	if opTok.kind == tokWord {
+		op = strings.ToLower(op)
+	}
+	if !(opTok.kind == tokOp && symbolOps[op] || opTok.kind == tokWord && wordOps[op]) {
+		return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("expected operator after %q", field.text)}
+	}
+	n := cmpNode{field: field.text, op: op}
+	if op == "in" {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		n.values = values
+		return n, nil
+	}
+	v := p.next()
+	if v.kind != tokWord && v.kind != tokString {
+		return nil, &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("expected value after %s", op)}
+	}
+	n.value = v.text
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(v.text)
+		if err != nil {
+			return nil, &SyntaxError{Pos: v.pos, Msg: "invalid regex: " + err.Error()}
+		}
+		n.re = re
+	}
+	return n, nil
+}
+
+func (p *parser) parseList() ([]string, error) {
+	if t := p.next(); t.text != "[" || t.kind != tokPunct {
+		return nil, &SyntaxError{Pos: t.pos, Msg: "expected [ after in"}
+	}
+	var values []string
+	for {
+		v := p.next()
+		if v.kind != tokWord && v.kind != tokString {
+			return nil, &SyntaxError{Pos: v.pos, Msg: "expected list value"}
+		}
+		values = append(values, v.text)
+		sep := p.next()
+		if sep.kind == tokPunct && sep.text == "]" {
+			return values, nil
+		}
+		if sep.kind != tokPunct || sep.text != "," {
+			return nil, &SyntaxError{Pos: sep.pos, Msg: "expected , or ]"}
+		}
+	}
+}
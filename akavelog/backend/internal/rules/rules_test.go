@@ -0,0 +1,97 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func getter(fields map[string]string) Getter {
+	return func(f string) (string, bool) {
+		v, ok := fields[f]
+		return v, ok
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entry := getter(map[string]string{
+		"service": "api", "level": "error", "message": "GET /healthz 200 in 3ms",
+		"status": "503", "latency_ms": "12.5", "http.path": "/v1/users",
+	})
+	cases := map[string]bool{
+		`service == api`:                 true,
+		`service = "api"`:                true,
+		`service != api`:                 false,
+		`level >= warn`:                  true,
+		`level < WARN`:                   false,
+		`status >= 500 and status < 600`: true,
+		`latency_ms > 9`:                 true, // numeric, not "12.5" < "9"
+		`message =~ "health.?z"`:         true,
+		`message !~ '^GET'`:              false,
+		`message contains "in 3ms" && http.path startswith /v1`: true,
+		`http.path endswith users`:                              true,
+		`service in [web, "api", worker]`:                       true,
+		`level in [debug, trace]`:                               false,
+		`not (service == api or level == debug)`:                false,
+		`!exists(trace_id) and exists(status)`:                  true,
+		`missing == ""`:                                         true,
+		`service == web or level == error and status == 503`:    true, // and binds tighter
+		`(service == web or level == error) and status == 404`:  false,
+	}
+	for src, want := range cases {
+		expr, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if got := expr.Match(entry); got != want {
+			t.Errorf("%q = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]int{
+		``:                      0,
+		`service ==`:            10,
+		`service api`:           8,
+		`service == api and`:    18,
+		`(level == warn`:        14,
+		`message =~ "("`:        11,
+		`service in [a, b`:      16,
+		`service == "open`:      11,
+		`service == a & b == c`: 13,
+		`service && api`:        8,
+	}
+	for src, pos := range cases {
+		_, err := Parse(src)
+		var syn *SyntaxError
+		if !errors.As(err, &syn) {
+			t.Errorf("Parse(%q) = %v, want a SyntaxError", src, err)
+			continue
+		}
+		if syn.Pos != pos {
+			t.Errorf("Parse(%q) position = %d, want %d (%v)", src, syn.Pos, pos, err)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(n int, open, close string) string {
+		return strings.Repeat(open, n) + "service == api" + strings.Repeat(close, n)
+	}
+	if _, err := Parse(nested(MaxDepth, "(", ")")); err != nil {
+		t.Errorf("%d levels: %v", MaxDepth, err)
+	}
+	for _, src := range []string{
+		nested(MaxDepth+1, "(", ")"),
+		nested(MaxDepth+1, "not ", ""),
+		nested(1e6, "(", ")"),
+		nested(1e6, "!", ""),
+	} {
+		var syn *SyntaxError
+		if _, err := Parse(src); !errors.As(err, &syn) {
+			t.Errorf("%d bytes deep: err = %v, want a SyntaxError", len(src), err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// Aggregation limits.
+const (
+	MaxBuckets       = 10000 // histogram buckets per aggregation
+	DefaultMaxGroups = 1000  // distinct group-by keys and top-N values per field
+	DefaultTopN      = 10
+	targetBuckets    = 60 // for the automatic interval
+)
+
+// niceIntervals are the automatic histogram intervals.
+var niceIntervals = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// AutoInterval returns the smallest interval of 1s, 5s, 10s, 30s, 1m, 5m, ... 1d, 7d that splits
+// [start, end) into at most about 60 buckets.
+func AutoInterval(start, end time.Time) time.Duration {
+	span := end.Sub(start)
+	for _, d := range niceIntervals {
+		if span/d <= targetBuckets {
+			return d
+		}
+	}
+	return niceIntervals[len(niceIntervals)-1]
+}
+
+// AggregateOptions selects what an Aggregator computes. Fields are looked up as by filter
+// rules: the entry's own fields, then its tags.
+type AggregateOptions struct {
+	Start     time.Time
+	End       time.Time     // exclusive
+	Interval  time.Duration // histogram bucket width; 0 for no histogram
+	GroupBy   []string      // count entries by the values of these fields; nil for no groups
+	Top       []string      // the most frequent values of each of these fields
+	TopN      int           // values per Top field, default DefaultTopN
+	MaxGroups int           // default DefaultMaxGroups
+}
+
+// Bucket is one histogram bucket, [Start, Start+interval).
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// Group is the number of entries with the same group-by values.
+type Group struct {
+	Values map[string]string `json:"values"` // "" for a missing field
+	Count  int               `json:"count"`
+}
+
+// TopValue is a value of a Top field and the number of entries that have it.
+type TopValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// TopValues are the most frequent values of a field.
+type TopValues struct {
+	Values  []TopValue `json:"values"`
+	Missing int        `json:"missing"` // entries without the field
+	Other   int        `json:"other"`   // entries with a value not in Values
+}
+
+// Aggregates is the result of an aggregation.
+type Aggregates struct {
+	Total       int                  `json:"total"`
+	Interval    string               `json:"interval,omitempty"`
+	Histogram   []Bucket             `json:"histogram,omitempty"`
+	Groups      []Group              `json:"groups,omitempty"` // most entries first
+	GroupsOther int                  `json:"groups_other,omitempty"`
+	Top         map[string]TopValues `json:"top,omitempty"`
+}
+
+// Aggregator counts the entries added to it. It is not safe for concurrent use.
+type Aggregator struct {
+	opts        AggregateOptions
+	first       time.Time // start of the first bucket
+	total       int
+	buckets     []int
+	groups      map[string]int
+	groupsOther int
+	top         []map[string]int
+	topMissing  []int
+	topOther    []int
+}
+
+// NewAggregator validates opts and returns an empty Aggregator.
+func NewAggregator(opts AggregateOptions) (*Aggregator, error) {
+	if opts.TopN <= 0 {
+		opts.TopN = DefaultTopN
+	}
+	if opts.MaxGroups <= 0 {
+		opts.MaxGroups = DefaultMaxGroups
+	}
+	a := &Aggregator{opts: opts}
+	if opts.Interval < 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if opts.Interval > 0 {
+		if opts.Start.IsZero() || !opts.Start.Before(opts.End) {
+			return nil, fmt.Errorf("a histogram needs start before end")
+		}
+		// Buckets are aligned to the interval in UTC, so 1h buckets start on the hour.
+		a.first = opts.Start.UTC().Truncate(opts.Interval)
+		n := (opts.End.Sub(a.first) + opts.Interval - 1) / opts.Interval
+		if n > MaxBuckets {
+			return nil, fmt.Errorf("interval %s gives %d buckets, at most %d are allowed", opts.Interval, n, MaxBuckets)
+		}
+		a.buckets = make([]int, n)
+	}
+	if len(opts.GroupBy) > 0 {
+		a.groups = make(map[string]int)
+	}
+	a.top = make([]map[string]int, len(opts.Top))
+	for i := range a.top {
+		a.top[i] = make(map[string]int)
+	}
+	a.topMissing = make([]int, len(opts.Top))
+	a.topOther = make([]int, len(opts.Top))
+	return a, nil
+}
+
+// Add counts e, whose timestamp is t.
+func (a *Aggregator) Add(e *model.LogEntry, t time.Time) {
+	a.total++
+	if a.buckets != nil {
+		if i := int(t.Sub(a.first) / a.opts.Interval); i >= 0 && i < len(a.buckets) {
+			a.buckets[i]++
+		}
+	}
+	if a.groups != nil {
+		values := make([]string, len(a.opts.GroupBy))
+		for i, f := range a.opts.GroupBy {
+			values[i], _ = processors.GetField(e, f)
+		}
+		key := strings.Join(values, "\x00")
+		if _, ok := a.groups[key]; ok || len(a.groups) < a.opts.MaxGroups {
+			a.groups[key]++
+		} else {
+			a.groupsOther++
+		}
+	}
+	for i, f := range a.opts.Top {
+		v, ok := processors.GetField(e, f)
+		switch {
+		case !ok || v == "":
+			a.topMissing[i]++
+		case a.top[i][v] > 0 || len(a.top[i]) < a.opts.MaxGroups:
+			a.top[i][v]++
+		default:
+			a.topOther[i]++
+		}
+	}
+}
+
+// Result returns the counts so far.
+func (a *Aggregator) Result() Aggregates {
+	out := Aggregates{Total: a.total}
+	if a.buckets != nil {
+		out.Interval = a.opts.Interval.String()
+		out.Histogram = make([]Bucket, len(a.buckets))
+		for i, n := range a.buckets {
+			out.Histogram[i] = Bucket{Start: a.first.Add(time.Duration(i) * a.opts.Interval), Count: n}
+		}
+	}
+	if a.groups != nil {
+		keys := make([]string, 0, len(a.groups))
+		for key := range a.groups {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if a.groups[keys[i]] != a.groups[keys[j]] {
+				return a.groups[keys[i]] > a.groups[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		out.Groups = make([]Group, len(keys))
+		for i, key := range keys {
+			values := strings.Split(key, "\x00")
+			g := Group{Values: make(map[string]string, len(values)), Count: a.groups[key]}
+			for j, f := range a.opts.GroupBy {
+				g.Values[f] = values[j]
+			}
+			out.Groups[i] = g
+		}
+		out.GroupsOther = a.groupsOther
+	}
+	if len(a.opts.Top) > 0 {
+		out.Top = make(map[string]TopValues, len(a.opts.Top))
+		for i, f := range a.opts.Top {
+			out.Top[f] = a.topValues(i)
+		}
+	}
+	return out
+}
+
+// topValues returns the TopN most frequent values of the i-th Top field, ties by value.
+func (a *Aggregator) topValues(i int) TopValues {
+	values := make([]TopValue, 0, len(a.top[i]))
+	for v, n := range a.top[i] {
+		values = append(values, TopValue{Value: v, Count: n})
+	}
+	sort.Slice(values, func(x, y int) bool {
+		if values[x].Count != values[y].Count {
+			return values[x].Count > values[y].Count
+		}
+		return values[x].Value < values[y].Value
+	})
+	out := TopValues{Missing: a.topMissing[i], Other: a.topOther[i]}
+	if len(values) > a.opts.TopN {
+		for _, v := range values[a.opts.TopN:] {
+			out.Other += v.Count
+		}
+		values = values[:a.opts.TopN]
+	}
+	out.Values = values
+	return out
+}
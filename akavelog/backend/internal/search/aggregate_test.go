@@ -0,0 +1,81 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestAggregator(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	a, err := NewAggregator(AggregateOptions{
+		Start:    start,
+		End:      start.Add(2 * time.Hour),
+		Interval: time.Hour,
+		GroupBy:  []string{"service", "level"},
+		Top:      []string{"service", "path"},
+		TopN:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(offset time.Duration, service, level, path string) {
+		e := &model.LogEntry{Service: service, Level: level}
+		if path != "" {
+			e.Tags = map[string]string{"path": path}
+		}
+		a.Add(e, start.Add(offset))
+	}
+	add(0, "api", "error", "/a")
+	add(20*time.Minute, "api", "error", "/b")
+	add(40*time.Minute, "web", "info", "/a")
+	add(100*time.Minute, "api", "info", "")
+
+	got := a.Result()
+	if got.Total != 4 || got.Interval != "1h0m0s" {
+		t.Errorf("total %d, interval %s", got.Total, got.Interval)
+	}
+	// Buckets are aligned to the hour: 00:00 (2), 01:00 (1), 02:00 (1).
+	want := []int{2, 1, 1}
+	if len(got.Histogram) != len(want) {
+		t.Fatalf("histogram %+v", got.Histogram)
+	}
+	for i, b := range got.Histogram {
+		if b.Count != want[i] || !b.Start.Equal(time.Date(2026, 1, 1, i, 0, 0, 0, time.UTC)) {
+			t.Errorf("bucket %d: %+v", i, b)
+		}
+	}
+	if len(got.Groups) != 3 || got.Groups[0].Count != 2 || got.Groups[0].Values["service"] != "api" || got.Groups[0].Values["level"] != "error" {
+		t.Errorf("groups %+v", got.Groups)
+	}
+	svc := got.Top["service"]
+	if len(svc.Values) != 1 || svc.Values[0] != (TopValue{"api", 3}) || svc.Other != 1 {
+		t.Errorf("top service %+v", svc)
+	}
+	path := got.Top["path"]
+	if len(path.Values) != 1 || path.Values[0] != (TopValue{"/a", 2}) || path.Other != 1 || path.Missing != 1 {
+		t.Errorf("top path %+v", path)
+	}
+}
+
+func TestAggregatorLimits(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := NewAggregator(AggregateOptions{Start: start, End: start.Add(24 * time.Hour), Interval: time.Second}); err == nil {
+		t.Error("86400 buckets accepted")
+	}
+	a, err := NewAggregator(AggregateOptions{GroupBy: []string{"service"}, MaxGroups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b", "c", "a"} {
+		a.Add(&model.LogEntry{Service: s}, start)
+	}
+	got := a.Result()
+	if len(got.Groups) != 2 || got.GroupsOther != 1 || got.Histogram != nil {
+		t.Errorf("result %+v", got)
+	}
+	if d := AutoInterval(start, start.Add(24*time.Hour)); d != 30*time.Minute {
+		t.Errorf("auto interval for 1d = %s", d)
+	}
+}
@@ -0,0 +1,139 @@
+// Package search reads log entries back from O3: it finds the batch objects of a time range
+// in the batches index and streams their entries through a query.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+)
+
+// DefaultMaxObjects bounds how many batch objects one scan downloads.
+const DefaultMaxObjects = 500
+
+// ErrScanLimit is returned when the objects of a scan are larger than ScanOptions.MaxBytes.
+var ErrScanLimit = errors.New("scan limit exceeded")
+
+// Index finds the batch objects of a time range (repository.BatchRepository).
+type Index interface {
+	Find(ctx context.Context, f repository.BatchFilter) ([]model.Batch, error)
+}
+
+// Store downloads batch objects (storage.O3Client).
+type Store interface {
+	GetObjectLogs(ctx context.Context, key string) ([]model.LogEntry, error)
+}
+
+// BatchStore is a Store that also reads batches uploaded to a named storage target
+// (model.Batch.Target), like storage.O3Client. A Store that is not cannot read them.
+type BatchStore interface {
+	Store
+	GetBatchLogs(ctx context.Context, b model.Batch) ([]model.LogEntry, error)
+}
+
+// readBatch downloads the entries of b from store, or from its storage target.
+func readBatch(ctx context.Context, store Store, b model.Batch) ([]model.LogEntry, error) {
+	if bs, ok := store.(BatchStore); ok {
+		return bs.GetBatchLogs(ctx, b)
+	}
+	if b.Target != "" {
+		return nil, fmt.Errorf("storage target %q is not readable here", b.Target)
+	}
+	return store.GetObjectLogs(ctx, b.Key)
+}
+
+// ScanOptions selects the entries a scan visits.
+type ScanOptions struct {
+	ProjectID  string // "" for every project
+	Start      time.Time
+	End        time.Time       // exclusive
+	Query      *query.Query    // nil matches every entry
+	MaxObjects int             // default DefaultMaxObjects
+	MaxBytes   int64           // fail before downloading more than this, as indexed; 0 for no limit
+	Progress   func(ScanStats) // called after each object
+	Newest     bool            // visit the newest objects first
+}
+
+// ScanStats describes a scan.
+type ScanStats struct {
+	Objects   int   `json:"scanned_objects"`
+	Bytes     int64 `json:"scanned_bytes"` // stored size of the objects read
+	Entries   int   `json:"scanned_entries"`
+	Matched   int   `json:"matched"`
+	Truncated bool  `json:"truncated"` // objects were left out: MaxObjects reached or fn stopped the scan
+}
+
+// Scan looks up the batch objects overlapping [Start, End) in the batches index, downloads
+// them one at a time and calls fn with every entry in the range that matches the query, and
+// its parsed timestamp. fn returns false to stop the scan. Entries without a valid timestamp
+// are skipped.
+func Scan(ctx context.Context, index Index, store Store, opts ScanOptions, fn func(e *model.LogEntry, t time.Time) bool) (ScanStats, error) {
+	var st ScanStats
+	if opts.MaxObjects <= 0 {
+		opts.MaxObjects = DefaultMaxObjects
+	}
+	batches, err := index.Find(ctx, repository.BatchFilter{ProjectID: opts.ProjectID, Start: opts.Start, End: opts.End})
+	if err != nil {
+		return st, fmt.Errorf("find batches: %w", err)
+	}
+	if opts.Newest {
+		for i, j := 0, len(batches)-1; i < j; i, j = i+1, j-1 {
+			batches[i], batches[j] = batches[j], batches[i]
+		}
+	}
+	if opts.MaxBytes > 0 {
+		var size int64
+		for i, b := range batches {
+			if i == opts.MaxObjects {
+				break
+			}
+			size += b.Size
+		}
+		if size > opts.MaxBytes {
+			return st, fmt.Errorf("%w: %d objects of %d bytes, the limit is %d bytes", ErrScanLimit, min(len(batches), opts.MaxObjects), size, opts.MaxBytes)
+		}
+	}
+	for i, b := range batches {
+		if i == opts.MaxObjects {
+			st.Truncated = true
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return st, err
+		}
+		entries, err := readBatch(ctx, store, b)
+		if err != nil {
+			return st, fmt.Errorf("read %s: %w", b.Key, err)
+		}
+		st.Objects++
+		st.Bytes += b.Size
+		for j := range entries {
+			e := &entries[j]
+			t, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+			if err != nil || (!opts.Start.IsZero() && t.Before(opts.Start)) || (!opts.End.IsZero() && !t.Before(opts.End)) {
+				continue
+			}
+			if opts.ProjectID != "" && e.ProjectID != "" && e.ProjectID != opts.ProjectID {
+				continue
+			}
+			st.Entries++
+			if opts.Query != nil && !opts.Query.MatchEntry(e) {
+				continue
+			}
+			st.Matched++
+			if !fn(e, t) {
+				st.Truncated = i < len(batches)-1 || j < len(entries)-1
+				return st, nil
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(st)
+		}
+	}
+	return st, nil
+}
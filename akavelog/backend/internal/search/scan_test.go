@@ -0,0 +1,144 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+	"github.com/akave-ai/akavelog/internal/repository"
+)
+
+// memIndex returns its batches, oldest first, whatever the filter.
+type memIndex []model.Batch
+
+func (x memIndex) Find(_ context.Context, _ repository.BatchFilter) ([]model.Batch, error) {
+	return append([]model.Batch(nil), x...), nil
+}
+
+// memStore maps keys to their entries.
+type memStore map[string][]model.LogEntry
+
+func (s memStore) GetObjectLogs(_ context.Context, key string) ([]model.LogEntry, error) {
+	return s[key], nil
+}
+
+func entry(ts, service, level, msg string) model.LogEntry {
+	return model.LogEntry{Timestamp: ts, Service: service, Level: level, Message: msg, ProjectID: "p1"}
+}
+
+func fixture() (memIndex, memStore) {
+	index := memIndex{{Key: "a", Size: 100}, {Key: "b", Size: 200}}
+	store := memStore{
+		"a": {
+			entry("2026-01-01T00:00:00Z", "api", "error", "db timeout"),
+			entry("2026-01-01T01:00:00Z", "web", "error", "timeout"),
+			entry("not a time", "api", "error", "timeout"),
+		},
+		"b": {
+			entry("2026-01-02T00:00:00Z", "api", "info", "ok"),
+			entry("2026-01-02T01:00:00Z", "api", "error", "upstream timeout"),
+			entry("2026-01-03T00:00:00Z", "api", "error", "late timeout"),
+		},
+	}
+	return index, store
+}
+
+func TestScan(t *testing.T) {
+	index, store := fixture()
+	q, err := query.Parse(`service:api AND level:error AND message:~"timeout"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := ScanOptions{
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC),
+		Query: q,
+	}
+	var got []string
+	st, err := Scan(context.Background(), index, store, opts, func(e *model.LogEntry, _ time.Time) bool {
+		got = append(got, e.Message)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "db timeout" || got[1] != "upstream timeout" {
+		t.Errorf("matched %q", got)
+	}
+	if st.Objects != 2 || st.Entries != 4 || st.Matched != 2 || st.Truncated {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestScanStops(t *testing.T) {
+	index, store := fixture()
+	opts := ScanOptions{Newest: true}
+	var got []string
+	st, err := Scan(context.Background(), index, store, opts, func(e *model.LogEntry, _ time.Time) bool {
+		got = append(got, e.Message)
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "ok" || !st.Truncated || st.Objects != 1 {
+		t.Errorf("matched %q, stats %+v", got, st)
+	}
+
+	st, err = Scan(context.Background(), index, store, ScanOptions{MaxObjects: 1}, func(*model.LogEntry, time.Time) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Objects != 1 || st.Matched != 2 || !st.Truncated {
+		t.Errorf("max objects: stats %+v", st)
+	}
+}
+
+func TestScanMaxBytes(t *testing.T) {
+	index, store := fixture()
+	_, err := Scan(context.Background(), index, store, ScanOptions{MaxBytes: 250}, func(*model.LogEntry, time.Time) bool { return true })
+	if !errors.Is(err, ErrScanLimit) {
+		t.Fatalf("err = %v, want ErrScanLimit", err)
+	}
+	st, err := Scan(context.Background(), index, store, ScanOptions{MaxBytes: 250, MaxObjects: 1, Newest: true}, func(*model.LogEntry, time.Time) bool { return true })
+	if err != nil || st.Bytes != 200 {
+		t.Errorf("newest object: stats %+v, err %v", st, err)
+	}
+}
+
+// targetStore holds the batches of named storage targets apart from those of its memStore.
+type targetStore struct {
+	memStore
+	targets map[string]memStore
+}
+
+func (s targetStore) GetBatchLogs(ctx context.Context, b model.Batch) ([]model.LogEntry, error) {
+	if b.Target == "" {
+		return s.GetObjectLogs(ctx, b.Key)
+	}
+	return s.targets[b.Target][b.Key], nil
+}
+
+func TestScanReadsStorageTargets(t *testing.T) {
+	index := memIndex{{Key: "a"}, {Key: "b", Target: "regulated"}}
+	plain := memStore{"a": {entry("2026-01-01T00:00:00Z", "api", "info", "plain")}}
+	store := targetStore{memStore: plain, targets: map[string]memStore{
+		"regulated": {"b": {entry("2026-01-01T00:00:01Z", "api", "info", "kept apart")}},
+	}}
+	var got []string
+	if _, err := Scan(context.Background(), index, store, ScanOptions{}, func(e *model.LogEntry, _ time.Time) bool {
+		got = append(got, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1] != "kept apart" {
+		t.Fatalf("scanned %v, want both targets", got)
+	}
+	if _, err := Scan(context.Background(), index, plain, ScanOptions{}, func(*model.LogEntry, time.Time) bool { return true }); err == nil {
+		t.Error("a store without targets read a targeted batch")
+	}
+}
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// envProvider resolves env://NAME to the environment variable NAME.
+type envProvider struct{}
+
+func (envProvider) Resolve(_ context.Context, ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errors.New("environment variable not set")
+	}
+	return v, nil
+}
+
+// fileProvider resolves file:///path to the content of the file, without trailing newlines.
+type fileProvider struct{}
+
+func (fileProvider) Resolve(_ context.Context, ref string) (string, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// vaultCacheTTL is how long a Vault secret is reused, so that the fields of one secret, and
+// inputs restarting, take one request.
+const vaultCacheTTL = time.Minute
+
+// vaultProvider resolves vault://path#field to a field of the Vault secret at path, read
+// over the HTTP API with VAULT_ADDR, VAULT_TOKEN and the optional VAULT_NAMESPACE. KV v2
+// paths include data/, e.g. secret/data/akavelog; KV v1 and other engines work too.
+type vaultProvider struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]vaultSecret
+}
+
+type vaultSecret struct {
+	data    map[string]any
+	fetched time.Time
+}
+
+func newVaultProvider() *vaultProvider {
+	return &vaultProvider{client: &http.Client{Timeout: 10 * time.Second}, cache: map[string]vaultSecret{}}
+}
+
+func (p *vaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", errors.New("want vault://path#field")
+	}
+	data, err := p.secret(ctx, strings.Trim(path, "/"))
+	if err != nil {
+		return "", err
+	}
+	switch v := data[field].(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("no field %q", field)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b), nil
+	}
+}
+
+// secret returns the data of the secret at path, cached for vaultCacheTTL.
+func (p *vaultProvider) secret(ctx context.Context, path string) (map[string]any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.cache[path]; ok && time.Since(s.fetched) < vaultCacheTTL {
+		return s.data, nil
+	}
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	u, err := url.JoinPath(addr, "v1", path)
+	if err != nil {
+		return nil, fmt.Errorf("VAULT_ADDR: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: %s", resp.Status)
+	}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: decode: %w", err)
+	}
+	data := body.Data
+	// KV v2 nests the fields under data.data, next to data.metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = inner
+		}
+	}
+	p.cache[path] = vaultSecret{data: data, fetched: time.Now()}
+	return data, nil
+}
@@ -0,0 +1,398 @@
+// Package secrets resolves references to secrets kept outside akavelog's config and database,
+// and redacts secret values from API responses and logs.
+//
+// A reference is a string value of the form scheme://ref:
+//
+//	env://O3_SECRET_KEY                         the environment variable O3_SECRET_KEY
+//	file:///run/secrets/o3_secret_key           the content of a file, without trailing newlines
+//	vault://secret/data/akavelog/o3#secret_key  a field of a HashiCorp Vault secret
+//
+// Other values are plaintext and used as they are. Configurations keep their references
+// when stored; they are resolved when the config is used, and only under secret keys.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in API responses and logs. A value sent back as Mask on update
+// keeps the stored one (KeepMasked).
+const Mask = "********"
+
+// minScrubLen is the shortest resolved value Scrub removes from text; shorter ones would
+// match too much.
+const minScrubLen = 6
+
+// Provider resolves the references of one scheme.
+type Provider interface {
+	// Resolve returns the secret ref names; ref is the reference without its scheme://.
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"env":   envProvider{},
+		"file":  fileProvider{},
+		"vault": newVaultProvider(),
+	}
+	resolved = map[string]struct{}{} // values resolved so far, removed from text by Scrub
+)
+
+// Register adds or replaces the Provider of scheme.
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// split returns the scheme and reference of s, or ok false when s is no reference.
+func split(s string) (scheme, ref string, ok bool) {
+	scheme, ref, ok = strings.Cut(s, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, " /") {
+		return "", "", false
+	}
+	mu.RLock()
+	_, ok = providers[scheme]
+	mu.RUnlock()
+	return scheme, ref, ok
+}
+
+// IsRef reports whether s is a reference of a registered scheme.
+func IsRef(s string) bool {
+	_, _, ok := split(s)
+	return ok
+}
+
+// Resolve returns the secret value references, or value itself when it is no reference.
+// Errors name the reference, never the secret.
+func Resolve(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	mu.RLock()
+	p := providers[scheme]
+	mu.RUnlock()
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", value, err)
+	}
+	if len(secret) >= minScrubLen {
+		mu.Lock()
+		resolved[secret] = struct{}{}
+		mu.Unlock()
+	}
+	return secret, nil
+}
+
+// ErrNotSecretKey refuses a reference under a key that is no secret key (see IsSecretKey).
+// Values of other keys end up in entries, requests and responses, where a resolved secret
+// would leak.
+var ErrNotSecretKey = errors.New("references are only allowed in secret keys such as password or token")
+
+// ResolveMap returns a copy of cfg with the references among the values of its secret keys
+// resolved, in nested maps and lists too. A reference under any other key fails with
+// ErrNotSecretKey. cfg is left as it is.
+func ResolveMap(ctx context.Context, cfg map[string]any) (map[string]any, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	out, err := resolveValue(ctx, cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]any), nil
+}
+
+func resolveValue(ctx context.Context, v any, secret bool) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if !IsRef(v) {
+			return v, nil
+		}
+		if !secret {
+			return nil, ErrNotSecretKey
+		}
+		return Resolve(ctx, v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			r, err := resolveValue(ctx, item, secret || IsSecretKey(k))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			r, err := resolveValue(ctx, item, secret)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// Refs returns the references among the values of cfg by key, nested ones as parent.key and
+// list items as key.N.
+func Refs(cfg map[string]any) map[string]string {
+	refs := make(map[string]string)
+	var walk func(v any, path string)
+	walk = func(v any, path string) {
+		switch v := v.(type) {
+		case string:
+			if IsRef(v) {
+				refs[path] = v
+			}
+		case map[string]any:
+			for k, item := range v {
+				if path != "" {
+					k = path + "." + k
+				}
+				walk(item, k)
+			}
+		case []any:
+			for i, item := range v {
+				walk(item, fmt.Sprintf("%s.%d", path, i))
+			}
+		}
+	}
+	walk(cfg, "")
+	return refs
+}
+
+// ResolveStruct resolves the references in the string fields of the struct v points to,
+// through nested structs, pointers, slices and maps of pointers (such as storage.targets).
+// Other maps are left as they are. Errors name the field by its koanf key.
+func ResolveStruct(ctx context.Context, v any) error {
+	return resolveField(ctx, reflect.ValueOf(v), "")
+}
+
+func resolveField(ctx context.Context, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveField(ctx, v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("koanf"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := resolveField(ctx, v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveField(ctx, v.Index(i), fmt.Sprintf("%s.%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Pointer {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := resolveField(ctx, iter.Value(), fmt.Sprintf("%s.%v", path, iter.Key())); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !v.CanSet() || !IsRef(v.String()) {
+			return nil
+		}
+		s, err := Resolve(ctx, v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(s)
+	}
+	return nil
+}
+
+// secretWords are the parts of config keys whose values are secret.
+var secretWords = []string{
+	"password", "passwd", "secret", "token", "credential", "authorization",
+	"api_key", "apikey", "access_key", "private_key", "shared_key", "routing_key", "tls_key",
+}
+
+// IsSecretKey reports whether values of the config key name are secret: passwords, tokens,
+// API keys and the like. Keys naming where a secret is (…_env, …_file) and which field holds
+// one (…_field) are not.
+func IsSecretKey(name string) bool {
+	n := strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	if strings.HasSuffix(n, "_env") || strings.HasSuffix(n, "_file") || strings.HasSuffix(n, "_field") {
+		return false
+	}
+	for _, w := range secretWords {
+		if strings.Contains(n, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of cfg where the values of secret keys are Mask, in nested maps and
+// lists too. References are kept, since they are no secret themselves.
+func Redact(cfg map[string]any) map[string]any {
+	if cfg == nil {
+		return nil
+	}
+	return redactValue(cfg, false).(map[string]any)
+}
+
+func redactValue(v any, secret bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = redactValue(item, secret || IsSecretKey(k))
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item, secret)
+		}
+		return out
+	case string:
+		if secret && v != "" && !IsRef(v) {
+			return Mask
+		}
+		return v
+	case nil:
+		return nil
+	}
+	if secret {
+		return Mask
+	}
+	return v
+}
+
+// RedactJSON is Redact for a JSON object. Other JSON is returned as it is.
+func RedactJSON(raw json.RawMessage) json.RawMessage {
+	var cfg map[string]any
+	if len(raw) == 0 || json.Unmarshal(raw, &cfg) != nil || cfg == nil {
+		return raw
+	}
+	out, err := json.Marshal(Redact(cfg))
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+// KeepMasked replaces the values of next that are Mask with the values under the same keys of
+// prev, so that a config read from the API can be sent back without its secrets.
+func KeepMasked(next, prev map[string]any) {
+	for k, v := range next {
+		switch v := v.(type) {
+		case string:
+			if v == Mask {
+				if old, ok := prev[k]; ok {
+					next[k] = old
+				} else {
+					delete(next, k)
+				}
+			}
+		case map[string]any:
+			old, _ := prev[k].(map[string]any)
+			KeepMasked(v, old)
+		}
+	}
+}
+
+// KeepMaskedJSON is KeepMasked for JSON objects. next is returned as it is when either is
+// not one.
+func KeepMaskedJSON(next, prev json.RawMessage) json.RawMessage {
+	var n, p map[string]any
+	if json.Unmarshal(next, &n) != nil || n == nil {
+		return next
+	}
+	_ = json.Unmarshal(prev, &p)
+	KeepMasked(n, p)
+	out, err := json.Marshal(n)
+	if err != nil {
+		return next
+	}
+	return out
+}
+
+// Plaintext returns the secret keys of cfg, nested ones as parent.key, whose values are
+// plaintext rather than references, sorted.
+func Plaintext(cfg map[string]any) []string {
+	var keys []string
+	var walk func(m map[string]any, prefix string, secret bool)
+	walk = func(m map[string]any, prefix string, secret bool) {
+		for k, v := range m {
+			isSecret := secret || IsSecretKey(k)
+			switch v := v.(type) {
+			case map[string]any:
+				walk(v, prefix+k+".", isSecret)
+			case string:
+				if isSecret && v != "" && v != Mask && !IsRef(v) {
+					keys = append(keys, prefix+k)
+				}
+			}
+		}
+	}
+	walk(cfg, "", false)
+	sort.Strings(keys)
+	return keys
+}
+
+// Scrub returns s with every secret resolved so far replaced by Mask.
+func Scrub(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for secret := range resolved {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return s
+}
+
+// Writer returns a writer that writes to w what it is given through Scrub. Writes are
+// expected to be whole lines, as the log package makes them.
+func Writer(w io.Writer) io.Writer {
+	return scrubWriter{w}
+}
+
+type scrubWriter struct{ w io.Writer }
+
+func (s scrubWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(s.w, Scrub(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.URL.Path != "/v1/secret/data/akavelog" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"secret_key":"from-vault-123"},"metadata":{"version":3}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("AKAVELOG_TEST_PASSWORD", "from-env-456")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file-789\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := map[string]any{
+		"listen":   ":9001",
+		"password": "env://AKAVELOG_TEST_PASSWORD",
+		"auth":     map[string]any{"token": "file://" + path},
+		"api_keys": []any{"vault://secret/data/akavelog#secret_key", "plain"},
+	}
+	got, err := ResolveMap(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["listen"] != ":9001" || got["password"] != "from-env-456" ||
+		got["auth"].(map[string]any)["token"] != "from-file-789" ||
+		got["api_keys"].([]any)[0] != "from-vault-123" || got["api_keys"].([]any)[1] != "plain" {
+		t.Errorf("resolved = %v", got)
+	}
+	if cfg["password"] != "env://AKAVELOG_TEST_PASSWORD" {
+		t.Error("ResolveMap changed its argument")
+	}
+
+	if refs := Refs(cfg); len(refs) != 3 || refs["auth.token"] != "file://"+path || refs["api_keys.0"] == "" {
+		t.Errorf("Refs = %v", refs)
+	}
+
+	// References outside secret keys would copy secrets into entries and requests.
+	for _, bad := range []map[string]any{
+		{"url": "env://AKAVELOG_TEST_PASSWORD"},
+		{"set": map[string]any{"x": "file://" + path}},
+		{"headers": map[string]any{"X-Env": []any{"file:///proc/self/environ"}}},
+	} {
+		if _, err := ResolveMap(context.Background(), bad); !errors.Is(err, ErrNotSecretKey) {
+			t.Errorf("ResolveMap(%v) error = %v, want ErrNotSecretKey", bad, err)
+		}
+	}
+
+	for _, ref := range []string{"env://AKAVELOG_TEST_UNSET", "vault://secret/data/other#secret_key", "vault://secret/data/akavelog#missing", "vault://no-field"} {
+		if _, err := Resolve(context.Background(), ref); err == nil {
+			t.Errorf("Resolve(%s) succeeded", ref)
+		}
+	}
+
+	var s struct {
+		O3 *struct {
+			SecretKey string `koanf:"secret_key"`
+		} `koanf:"o3"`
+		Extra   map[string]any `koanf:"extra"`
+		Targets map[string]*struct {
+			SecretKey string `koanf:"secret_key"`
+		} `koanf:"targets"`
+	}
+	s.O3 = &struct {
+		SecretKey string `koanf:"secret_key"`
+	}{SecretKey: "env://AKAVELOG_TEST_PASSWORD"}
+	s.Extra = map[string]any{"password": "env://AKAVELOG_TEST_PASSWORD"}
+	s.Targets = map[string]*struct {
+		SecretKey string `koanf:"secret_key"`
+	}{"regulated": {SecretKey: "env://AKAVELOG_TEST_PASSWORD"}}
+	if err := ResolveStruct(context.Background(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.O3.SecretKey != "from-env-456" || s.Extra["password"] != "env://AKAVELOG_TEST_PASSWORD" {
+		t.Errorf("ResolveStruct = %+v, extra %v", *s.O3, s.Extra)
+	}
+	if got := s.Targets["regulated"].SecretKey; got != "from-env-456" {
+		t.Errorf("target secret_key = %q, want it resolved", got)
+	}
+	s.O3.SecretKey = "env://AKAVELOG_TEST_UNSET"
+	if err := ResolveStruct(context.Background(), &s); err == nil || !strings.Contains(err.Error(), "o3.secret_key") {
+		t.Errorf("ResolveStruct error = %v, want one naming o3.secret_key", err)
+	}
+
+	if got := Scrub("auth failed with from-env-456 and from-vault-123"); got != "auth failed with "+Mask+" and "+Mask {
+		t.Errorf("Scrub = %q", got)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cfg := map[string]any{
+		"listen":          ":9001",
+		"password":        "hunter22",
+		"secret_key":      "env://O3_SECRET",
+		"hmac_secret_env": "HMAC_SECRET",
+		"tls_key_file":    "/etc/tls/key.pem",
+		"max_retries":     3.0,
+		"headers":         map[string]any{"Authorization": "Bearer abc", "X-Api-Key": "k", "Accept": "json"},
+		"api_keys":        []any{"a", "b"},
+	}
+	raw, _ := json.Marshal(cfg)
+	var got map[string]any
+	if err := json.Unmarshal(RedactJSON(raw), &got); err != nil {
+		t.Fatal(err)
+	}
+	headers := got["headers"].(map[string]any)
+	if got["listen"] != ":9001" || got["password"] != Mask || got["secret_key"] != "env://O3_SECRET" ||
+		got["hmac_secret_env"] != "HMAC_SECRET" || got["tls_key_file"] != "/etc/tls/key.pem" || got["max_retries"] != 3.0 ||
+		headers["Authorization"] != Mask || headers["X-Api-Key"] != Mask || headers["Accept"] != "json" ||
+		got["api_keys"].([]any)[0] != Mask {
+		t.Errorf("redacted = %v", got)
+	}
+	if cfg["password"] != "hunter22" {
+		t.Error("Redact changed its argument")
+	}
+	if keys := Plaintext(cfg); strings.Join(keys, ",") != "headers.Authorization,headers.X-Api-Key,password" {
+		t.Errorf("Plaintext = %v", keys)
+	}
+
+	// A config read from the API and sent back keeps its secrets.
+	next := got
+	next["listen"] = ":9002"
+	KeepMasked(next, cfg)
+	if next["password"] != "hunter22" || next["headers"].(map[string]any)["Authorization"] != "Bearer abc" || next["listen"] != ":9002" {
+		t.Errorf("KeepMasked = %v", next)
+	}
+}
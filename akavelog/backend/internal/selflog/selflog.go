@@ -0,0 +1,276 @@
+// Package selflog feeds akavelog's own logs (server, batcher, inputs, ...) back into ingest as
+// entries of Service, so they go through the pipelines and O3 archival and can be searched
+// alongside the logs akavelog receives.
+//
+// Lines reach it through Hook, for zerolog loggers, and CaptureStdLog, for the standard log
+// package most components write to. They are queued until Start and forwarded by a single
+// goroutine; when the queue is full or more than maxPerSecond lines arrive in a second, lines
+// are dropped and counted rather than slowing down the component that logged them.
+package selflog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// Service is the service of every entry selflog forwards.
+const Service = "akavelog-internal"
+
+const (
+	queueSize    = 1024
+	maxPerSecond = 200
+)
+
+// Options configures Start.
+type Options struct {
+	// Next receives the entries, usually a pipeline.Buffer in front of the ingest queue.
+	Next inputs.InputBuffer
+	// Level is the lowest level forwarded: debug, info (default), warn or error.
+	Level string
+	// Tags are added to every entry, e.g. the stream it is routed into.
+	Tags map[string]string
+}
+
+type forwarder struct {
+	queue   chan model.LogEntry
+	level   atomic.Int32 // lowest level queued
+	dropped atomic.Int64
+
+	mu     sync.Mutex
+	second int64 // unix second count belongs to
+	count  int
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var (
+	fwd  = newForwarder()
+	host = hostname()
+)
+
+func newForwarder() *forwarder {
+	f := &forwarder{queue: make(chan model.LogEntry, queueSize)}
+	f.level.Store(int32(zerolog.InfoLevel))
+	return f
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+// Hook returns a zerolog hook forwarding every event logged through it.
+func Hook() zerolog.Hook {
+	return zerolog.HookFunc(func(_ *zerolog.Event, level zerolog.Level, msg string) {
+		fwd.add(level, msg)
+	})
+}
+
+// CaptureStdLog forwards every line of the standard logger, which keeps writing where it did.
+// Their level is guessed from the words in the line.
+func CaptureStdLog() {
+	log.SetOutput(io.MultiWriter(log.Writer(), lineWriter{}))
+}
+
+// lineWriter forwards the lines the standard logger writes, without its date prefix.
+type lineWriter struct{}
+
+var stdPrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+func (lineWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(stdPrefix.ReplaceAllString(string(p), ""), "\n")
+	fwd.add(levelOf(msg), msg)
+	return len(p), nil
+}
+
+// levelOf guesses the level of a standard log line.
+func levelOf(msg string) zerolog.Level {
+	lower := strings.ToLower(msg)
+	for _, w := range []string{"error", "fail", "panic"} {
+		if strings.Contains(lower, w) {
+			return zerolog.ErrorLevel
+		}
+	}
+	for _, w := range []string{"warn", "invalid", "drop", "retry", "reject", "timed out"} {
+		if strings.Contains(lower, w) {
+			return zerolog.WarnLevel
+		}
+	}
+	return zerolog.InfoLevel
+}
+
+// Start forwards the lines queued so far and those to come into opts.Next until Stop.
+func Start(opts Options) error {
+	return fwd.start(opts)
+}
+
+// Stop forwards the lines still queued and stops forwarding; later lines are dropped. Call it
+// before opts.Next closes.
+func Stop() {
+	fwd.stopForwarding()
+}
+
+// Dropped returns the number of lines dropped because the queue was full or over the rate.
+func Dropped() int64 {
+	return fwd.dropped.Load()
+}
+
+// add queues a line logged at level. A "[component] " prefix becomes the component tag.
+func (f *forwarder) add(level zerolog.Level, msg string) {
+	if level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+	if int32(level) < f.level.Load() || level == zerolog.Disabled || msg == "" {
+		return
+	}
+	now := time.Now()
+	f.mu.Lock()
+	if sec := now.Unix(); sec != f.second {
+		f.second, f.count = sec, 0
+	}
+	f.count++
+	over := f.count > maxPerSecond
+	f.mu.Unlock()
+	if over {
+		f.dropped.Add(1)
+		return
+	}
+	e := model.LogEntry{
+		Timestamp: now.UTC().Format(time.RFC3339Nano),
+		Service:   Service,
+		Level:     levelName(level),
+		Message:   msg,
+		Tags:      map[string]string{"host": host},
+	}
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "] "); end > 1 {
+			e.Tags["component"] = msg[1:end]
+			e.Message = msg[end+2:]
+		}
+	}
+	select {
+	case f.queue <- e:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// levelName maps level to the level names of entries.
+func levelName(level zerolog.Level) string {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return "debug"
+	case zerolog.WarnLevel:
+		return "warn"
+	case zerolog.ErrorLevel:
+		return "error"
+	case zerolog.FatalLevel, zerolog.PanicLevel:
+		return "fatal"
+	default:
+		return "info"
+	}
+}
+
+// SetLevel changes the lowest level forwarded while forwarding.
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	fwd.mu.Lock()
+	defer fwd.mu.Unlock()
+	if fwd.stop != nil {
+		fwd.level.Store(int32(l))
+	}
+	return nil
+}
+
+// parseLevel parses an Options.Level.
+func parseLevel(s string) (zerolog.Level, error) {
+	if s == "" {
+		return zerolog.InfoLevel, nil
+	}
+	level, err := zerolog.ParseLevel(s)
+	if err != nil || level > zerolog.ErrorLevel {
+		return 0, fmt.Errorf("selflog: invalid level %q", s)
+	}
+	return level, nil
+}
+
+func (f *forwarder) start(opts Options) error {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stop != nil {
+		return errors.New("selflog: already started")
+	}
+	f.level.Store(int32(level))
+	f.stop, f.done = make(chan struct{}), make(chan struct{})
+	go f.run(opts.Next, opts.Tags, f.stop, f.done)
+	return nil
+}
+
+func (f *forwarder) stopForwarding() {
+	f.mu.Lock()
+	stop, done := f.stop, f.done
+	f.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	// Nothing is queued anymore: drop lines from now on.
+	f.level.Store(int32(zerolog.Disabled))
+	close(stop)
+	<-done
+	f.mu.Lock()
+	f.stop, f.done = nil, nil
+	f.mu.Unlock()
+}
+
+func (f *forwarder) run(next inputs.InputBuffer, tags map[string]string, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case e := <-f.queue:
+			f.insert(next, tags, e)
+		case <-stop:
+			for {
+				select {
+				case e := <-f.queue:
+					f.insert(next, tags, e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// insert inserts e into next. It does not log failures, which would be forwarded again.
+func (f *forwarder) insert(next inputs.InputBuffer, tags map[string]string, e model.LogEntry) {
+	for k, v := range tags {
+		e.Tags[k] = v
+	}
+	raw, err := json.Marshal(e)
+	if err == nil {
+		err = next.Insert(raw)
+	}
+	if err != nil {
+		f.dropped.Add(1)
+	}
+}
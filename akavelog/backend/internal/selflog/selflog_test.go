@@ -0,0 +1,68 @@
+package selflog
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/rs/zerolog"
+)
+
+type captureBuffer struct {
+	mu      sync.Mutex
+	entries []model.LogEntry
+}
+
+func (b *captureBuffer) Insert(p []byte) error {
+	var e model.LogEntry
+	if err := json.Unmarshal(p, &e); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.entries = append(b.entries, e)
+	b.mu.Unlock()
+	return nil
+}
+
+func TestForwardsStdAndZerologLines(t *testing.T) {
+	var buf captureBuffer
+	logger := zerolog.New(io.Discard).Hook(Hook())
+	std := log.New(lineWriter{}, "", log.LstdFlags)
+
+	std.Printf("[batcher] upload to O3: connection refused (queued for retry)")
+	std.Printf("[inputs] syslog: read failed: closed")
+	logger.Debug().Msg("below the level")
+	if err := Start(Options{Next: &buf, Level: "info", Tags: map[string]string{"streams": "s1"}}); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn().Msg("[server] slow shutdown")
+	std.Printf("registered input types: [http]")
+	Stop()
+	std.Printf("[server] after stop")
+
+	want := []model.LogEntry{
+		{Level: "warn", Message: "upload to O3: connection refused (queued for retry)", Tags: map[string]string{"component": "batcher"}},
+		{Level: "error", Message: "syslog: read failed: closed", Tags: map[string]string{"component": "inputs"}},
+		{Level: "warn", Message: "slow shutdown", Tags: map[string]string{"component": "server"}},
+		{Level: "info", Message: "registered input types: [http]"},
+	}
+	if len(buf.entries) != len(want) {
+		t.Fatalf("forwarded %d entries, want %d: %+v", len(buf.entries), len(want), buf.entries)
+	}
+	for i, got := range buf.entries {
+		w := want[i]
+		if got.Service != Service || got.Level != w.Level || got.Message != w.Message {
+			t.Errorf("entry %d = %s %s %q, want %s %s %q", i, got.Service, got.Level, got.Message, Service, w.Level, w.Message)
+		}
+		if got.Tags["component"] != w.Tags["component"] || got.Tags["streams"] != "s1" {
+			t.Errorf("entry %d tags = %v", i, got.Tags)
+		}
+	}
+	if err := Start(Options{Next: &buf, Level: "fatal"}); err == nil {
+		t.Error("Start accepted level fatal")
+		Stop()
+	}
+}
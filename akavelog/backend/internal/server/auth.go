@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/rand"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	akmiddleware "github.com/akave-ai/akavelog/internal/middleware"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+// readOnlyPosts are the POST routes that only read: searches and validations.
+var readOnlyPosts = map[string]bool{
+	"/query":                  true,
+	"/query/validate":         true,
+	"/logs/aggregate":         true,
+	"/logs/sql":               true,
+	"/saved-searches/:id/run": true,
+	"/uploads/presign":        true,
+	"/rules/validate":         true,
+	"/extractors/test":        true,
+}
+
+// serverWide reports whether path is a route of resources that apply to every project:
+// streams, outputs and retention runs.
+func serverWide(path string) bool {
+	for _, prefix := range []string{"/streams", "/outputs", "/retention/run"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseProjectKeys answers 403 to callers bound to a project on serverWide routes, since they
+// would reach the other projects' entries. It runs after RequireAuth.
+func refuseProjectKeys(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if p := akmiddleware.PrincipalFrom(c); p != nil && p.ProjectID != "" && serverWide(c.Path()) {
+			return response.Error(c, http.StatusForbidden, "project not allowed",
+				"streams, outputs and retention runs apply to every project; use an API key without a project")
+		}
+		return next(c)
+	}
+}
+
+// requiredScope returns the scope a request needs, by its route: none to log in or read the
+// API documentation, admin for users, API keys and changes to projects, ingest for sending
+// entries to /ingest, read for GET requests, searches and the caller's own account, and write
+// for every other change.
+// User roles map to scopes with akmiddleware.RoleScopes.
+func requiredScope(c echo.Context) string {
+	path, method := c.Path(), c.Request().Method
+	switch {
+	case path == "/auth/login" || path == "/auth/oidc/login" || path == "/auth/oidc/callback":
+		return ""
+	case path == "/openapi.json" || path == "/docs":
+		return ""
+	case path == "/auth/me" || path == "/auth/password":
+		return akmiddleware.ScopeRead
+	case path == "/users" || strings.HasPrefix(path, "/users/"):
+		return akmiddleware.ScopeAdmin
+	case path == "/api-keys" || strings.HasPrefix(path, "/api-keys/"):
+		return akmiddleware.ScopeAdmin
+	case strings.HasPrefix(path, "/admin/"):
+		return akmiddleware.ScopeAdmin
+	case path == "/inputs/export" && c.QueryParam("include_secrets") == "true":
+		return akmiddleware.ScopeAdmin
+	case (path == "/projects" || strings.HasPrefix(path, "/projects/")) && method != http.MethodGet:
+		return akmiddleware.ScopeAdmin
+	case path == "/ingest/*" && method != http.MethodGet:
+		return akmiddleware.ScopeIngest
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return akmiddleware.ScopeRead
+	case method == http.MethodPost && readOnlyPosts[path]:
+		return akmiddleware.ScopeRead
+	}
+	return akmiddleware.ScopeWrite
+}
+
+// newAuthenticator builds the authenticator of the management API from cfg, or returns nil
+// when cfg turns authentication off. It accepts the API keys in keys and the session tokens
+// of the users in users. An invalid cache_ttl is logged and its default used.
+func newAuthenticator(cfg *config.AuthConfig, keys *repository.APIKeyRepository, users *repository.UserRepository, tokens *akmiddleware.Tokens) *akmiddleware.Authenticator {
+	var adminKey string
+	var ttl time.Duration
+	if cfg != nil {
+		if cfg.Disabled {
+			log.Printf("[server] auth: disabled, the management API accepts requests without an API key")
+			return nil
+		}
+		adminKey = cfg.AdminKey
+		if cfg.CacheTTL != "" {
+			if d, err := time.ParseDuration(cfg.CacheTTL); err == nil && d > 0 {
+				ttl = d
+			} else {
+				log.Printf("[server] auth: invalid cache_ttl %q (using default)", cfg.CacheTTL)
+			}
+		}
+	}
+	a := akmiddleware.NewAuthenticator(keys, adminKey, ttl)
+	a.UseTokens(users, tokens)
+	return a
+}
+
+// newTokens builds the issuer of user session tokens from cfg. Without a jwt_secret, a random
+// one is used, so sessions end when the process restarts and are not accepted by other
+// servers. An invalid token_ttl is logged and its default used.
+func newTokens(cfg *config.AuthConfig) *akmiddleware.Tokens {
+	var secret []byte
+	var ttl time.Duration
+	if cfg != nil {
+		secret = []byte(cfg.JWTSecret)
+		if cfg.TokenTTL != "" {
+			if d, err := time.ParseDuration(cfg.TokenTTL); err == nil && d > 0 {
+				ttl = d
+			} else {
+				log.Printf("[server] auth: invalid token_ttl %q (using default)", cfg.TokenTTL)
+			}
+		}
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("[server] auth: generate jwt secret: %v", err)
+		}
+		log.Printf("[server] auth: no jwt_secret set, sessions end on restart and are not shared between servers")
+	}
+	return akmiddleware.NewTokens(secret, ttl)
+}
+
+// newOIDC builds the OpenID Connect provider of cfg, or returns nil when none is configured.
+// An invalid configuration is logged and single sign-on left off.
+func newOIDC(cfg *config.AuthConfig) *akmiddleware.OIDC {
+	if cfg == nil || cfg.OIDC == nil || cfg.OIDC.Issuer == "" {
+		return nil
+	}
+	c := cfg.OIDC
+	o, err := akmiddleware.NewOIDC(akmiddleware.OIDCOptions{
+		Issuer:         c.Issuer,
+		ClientID:       c.ClientID,
+		ClientSecret:   c.ClientSecret,
+		RedirectURL:    c.RedirectURL,
+		Scopes:         c.Scopes,
+		Audiences:      c.Audiences,
+		GroupsClaim:    c.GroupsClaim,
+		AdminGroups:    c.AdminGroups,
+		OperatorGroups: c.OperatorGroups,
+		ViewerGroups:   c.ViewerGroups,
+		DefaultRole:    c.DefaultRole,
+	})
+	if err != nil {
+		log.Printf("[server] auth: %v (single sign-on off)", err)
+		return nil
+	}
+	return o
+}
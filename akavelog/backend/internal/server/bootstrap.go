@@ -0,0 +1,43 @@
+package server
+
+import (
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/handler"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// inputSpecs converts the inputs of the config file for InputHandler.ReconcileInputs.
+func inputSpecs(list []config.InputSpec) []handler.InputSpec {
+	out := make([]handler.InputSpec, 0, len(list))
+	for _, s := range list {
+		out = append(out, handler.InputSpec{
+			Type:        s.Type,
+			Title:       s.Title,
+			Description: s.Description,
+			Project:     s.Project,
+			State:       s.State,
+			Config:      s.Config,
+		})
+	}
+	return out
+}
+
+// pipelineSpecs converts the pipelines of the config file for PipelineHandler.BootstrapPipelines.
+func pipelineSpecs(list []config.PipelineSpec) []handler.PipelineSpec {
+	out := make([]handler.PipelineSpec, 0, len(list))
+	for _, s := range list {
+		procs := make([]model.ProcessorConfig, 0, len(s.Processors))
+		for _, p := range s.Processors {
+			procs = append(procs, model.ProcessorConfig{Type: p.Type, Stage: model.PipelineStage(p.Stage), Config: p.Config})
+		}
+		out = append(out, handler.PipelineSpec{
+			Name:        s.Name,
+			Description: s.Description,
+			Input:       s.Input,
+			Project:     s.Project,
+			Enabled:     s.Enabled,
+			Processors:  procs,
+		})
+	}
+	return out
+}
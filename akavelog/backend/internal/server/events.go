@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/events"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/retention"
+)
+
+// newEventBus returns the bus of system events configured by cfg. When a webhook is invalid
+// it is logged and the bus runs without webhooks.
+func newEventBus(cfg *config.EventsConfig) *events.Bus {
+	ec := eventsConfig(cfg)
+	bus, err := events.NewBus(ec)
+	if err != nil {
+		log.Printf("[server] events: %v (webhooks disabled)", err)
+		ec.Webhooks = nil
+		bus, _ = events.NewBus(ec)
+	}
+	if n := len(ec.Webhooks); n > 0 {
+		log.Printf("[server] events: %d webhooks", n)
+	}
+	return bus
+}
+
+// eventsConfig converts cfg. An invalid webhook timeout is logged and the default used.
+func eventsConfig(cfg *config.EventsConfig) events.Config {
+	var ec events.Config
+	if cfg == nil {
+		return ec
+	}
+	ec.Recent = cfg.Recent
+	for _, w := range cfg.Webhooks {
+		hook := events.Webhook{Name: w.Name, URL: w.URL, Types: w.Types, Secret: w.Secret, Headers: w.Headers, MaxRetries: w.MaxRetries}
+		if w.Timeout != "" {
+			if d, err := time.ParseDuration(w.Timeout); err == nil && d > 0 {
+				hook.Timeout = d
+			} else {
+				log.Printf("[server] events: webhook %s: invalid timeout %q (using default)", w.URL, w.Timeout)
+			}
+		}
+		ec.Webhooks = append(ec.Webhooks, hook)
+	}
+	return ec
+}
+
+// flushFailedEvent is the event of a batch object that failed to upload, or of a batch that
+// could not be encoded when key is empty.
+func flushFailedEvent(key string, entries int, err error) events.Event {
+	msg := fmt.Sprintf("upload of %d entries to O3 failed: %v (queued for retry)", entries, err)
+	if key == "" {
+		msg = fmt.Sprintf("%d entries could not be encoded: %v", entries, err)
+	}
+	data := map[string]any{"entries": entries, "error": err.Error()}
+	if key != "" {
+		data["key"] = key
+	}
+	return events.Event{Type: events.FlushFailed, Severity: events.SeverityError, Message: msg, Subject: key, Data: data}
+}
+
+// retentionEvent is the event of a retention run that deleted or archived objects, or failed to.
+func retentionEvent(st retention.RunStats) events.Event {
+	ev := events.Event{
+		Type:     events.RetentionApplied,
+		Severity: events.SeverityInfo,
+		Message:  fmt.Sprintf("retention deleted %d and archived %d objects (%d bytes)", st.Deleted, st.Archived, st.Bytes),
+		Data: map[string]any{
+			"scanned": st.Scanned, "deleted": st.Deleted, "archived": st.Archived, "bytes": st.Bytes,
+			"errors": st.Errors, "started_at": st.StartedAt, "finished_at": st.FinishedAt,
+		},
+	}
+	if st.Errors > 0 {
+		ev.Severity = events.SeverityWarning
+		ev.Message += fmt.Sprintf(", %d errors: %s", st.Errors, st.LastError)
+		ev.Data["last_error"] = st.LastError
+	}
+	return ev
+}
+
+// alertEvent is the event of the state change ev of a.
+func alertEvent(a model.Alert, ev model.AlertEvent) events.Event {
+	out := events.Event{
+		Type:    events.AlertFired,
+		Message: ev.Message,
+		Subject: a.Name,
+		Data: map[string]any{
+			"alert_id": a.ID.String(), "severity": a.Severity, "condition": a.Condition,
+			"value": ev.Value, "project_id": a.ProjectID,
+		},
+		At: ev.CreatedAt,
+	}
+	switch {
+	case ev.State == model.AlertResolved:
+		out.Type, out.Severity = events.AlertResolved, events.SeverityInfo
+	case a.Severity == model.SeverityCritical:
+		out.Severity = events.SeverityError
+	case a.Severity == model.SeverityWarning:
+		out.Severity = events.SeverityWarning
+	default:
+		out.Severity = events.SeverityInfo
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/labstack/echo/v4"
+)
+
+// handleFlush uploads the open batches to O3 now instead of when they are full or due
+// (POST /admin/flush), and reports what it flushed and the retry queue left. Without O3
+// there are no batches and it answers 503.
+func (s *Server) handleFlush(c echo.Context) error {
+	if s.batcher == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "flush not available", "flushing requires O3 storage")
+	}
+	batches, entries := s.batcher.Flush(c.Request().Context())
+	log.Printf("[server] flushed %d batches, %d entries", batches, entries)
+	return response.OK(c, map[string]any{
+		"batches": batches,
+		"entries": entries,
+		"retry":   s.batcher.RetryStats(),
+	}, "Batches flushed")
+}
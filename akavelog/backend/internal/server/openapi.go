@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/akave-ai/akavelog/internal/openapi"
+	"github.com/labstack/echo/v4"
+)
+
+// apiInfo heads the OpenAPI document of GET /openapi.json.
+var apiInfo = openapi.Info{
+	Title:       "akavelog",
+	Description: "Management and query API of akavelog. Every response is wrapped in a Response, every error in an Error.",
+	Version:     "1",
+}
+
+// routeDocs says what the routes do not about themselves, for the OpenAPI document: see
+// openapi.Generate. Routes left out are still described, by their handler and path.
+var routeDocs = map[string]openapi.Doc{
+	"GET /openapi.json": {Summary: "OpenAPI document of this API", Public: true},
+	"GET /docs":         {Summary: "Swagger UI of this API", Public: true},
+
+	"POST /auth/login":        {Summary: "Log in", Public: true, Body: "email and password; returns a session token"},
+	"PUT /auth/password":      {Body: "current_password and new_password"},
+	"GET /auth/oidc/login":    {Summary: "Log in with the OpenID Connect provider", Public: true},
+	"GET /auth/oidc/callback": {Summary: "Callback of the OpenID Connect provider", Public: true},
+
+	"GET /inputs": {Paged: true, Query: []openapi.Parameter{
+		openapi.Query("type", openapi.String, "Input type"),
+		openapi.Query("state", openapi.Schema{Type: "string", Enum: []string{"RUNNING", "STOPPED", "PAUSED", "FAILED"}}, "State"),
+		openapi.Query("project_id", openapi.String, "Project ID"),
+	}},
+	"POST /inputs":                {Body: "type, title, config and optional description, listen, state and project_id", Headers: []openapi.Parameter{idempotencyKey}},
+	"PUT /inputs/:id":             {Body: "The input; its config replaces the stored one", Headers: []openapi.Parameter{ifMatch}},
+	"PATCH /inputs/:id":           {Body: "Fields to change; config is a JSON merge patch of the stored config", Headers: []openapi.Parameter{ifMatch}},
+	"POST /inputs/:id/rotate-key": {Body: "Optional grace_period of the previous keys"},
+	"POST /inputs/bulk":           {Summary: "Create several inputs", Body: "inputs, each as the body of POST /inputs; answers the result of each", Headers: []openapi.Parameter{idempotencyKey}},
+	"DELETE /inputs/bulk":         {Summary: "Delete several inputs", Body: "ids of the inputs; answers the result of each"},
+	"GET /inputs/export": {Summary: "Export every input as an input document", Query: []openapi.Parameter{
+		openapi.Query("format", openapi.Schema{Type: "string", Enum: []string{"json", "yaml"}}, "Format of the document"),
+		openapi.Query("include_secrets", openapi.Boolean, "Export secrets unmasked; needs the admin scope"),
+	}},
+	"POST /inputs/import": {Summary: "Make the inputs match an input document", Body: "An input document of GET /inputs/export, as JSON or YAML", Query: []openapi.Parameter{
+		openapi.Query("prune", openapi.Boolean, "Delete the inputs not in the document"),
+		openapi.Query("format", openapi.Schema{Type: "string", Enum: []string{"json", "yaml"}}, "Format of the document, if the Content-Type does not say"),
+	}},
+
+	"GET /streams":           {Paged: true, Query: []openapi.Parameter{enabled}},
+	"POST /streams":          {Body: "The stream"},
+	"PUT /streams/:id":       {Body: "The stream"},
+	"GET /outputs":           {Paged: true, Query: []openapi.Parameter{byType, enabled}},
+	"POST /outputs":          {Body: "The output"},
+	"PUT /outputs/:id":       {Body: "The output"},
+	"GET /pipelines":         {Paged: true, Query: []openapi.Parameter{enabled}},
+	"POST /pipelines":        {Body: "The pipeline"},
+	"PUT /pipelines/:id":     {Body: "The pipeline"},
+	"GET /projects":          {Paged: true, Query: []openapi.Parameter{openapi.Query("owner_email", openapi.String, "Owner email")}},
+	"POST /projects":         {Body: "The project"},
+	"PUT /projects/:id":      {Body: "The project"},
+	"GET /notifications":     {Paged: true, Query: []openapi.Parameter{byType, enabled}},
+	"POST /notifications":    {Body: "The notification channel"},
+	"PUT /notifications/:id": {Body: "The notification channel"},
+	"GET /alerts": {Paged: true, Query: []openapi.Parameter{
+		openapi.Query("severity", openapi.String, "Severity"),
+		openapi.Query("state", openapi.String, "State"),
+		openapi.Query("project_id", openapi.String, "Project ID"),
+		enabled,
+	}},
+	"POST /alerts":    {Body: "The alert"},
+	"PUT /alerts/:id": {Body: "The alert"},
+	"GET /users": {Paged: true, Query: []openapi.Parameter{
+		openapi.Query("role", openapi.Schema{Type: "string", Enum: []string{"admin", "operator", "viewer"}}, "Role"),
+		openapi.Query("disabled", openapi.Boolean, "Disabled"),
+	}},
+	"POST /users":            {Body: "The user"},
+	"PUT /users/:id":         {Body: "The user"},
+	"GET /api-keys":          {Paged: true},
+	"POST /api-keys":         {Body: "The API key; the answer holds the key, the only time it is shown"},
+	"PUT /api-keys/:id":      {Body: "The API key"},
+	"GET /lookup-tables":     {Paged: true, Query: []openapi.Parameter{openapi.Query("kind", openapi.String, "Kind")}},
+	"POST /lookup-tables":    {Body: "The lookup table"},
+	"PUT /lookup-tables/:id": {Body: "The lookup table"},
+	"GET /saved-searches": {Paged: true, Query: []openapi.Parameter{
+		openapi.Query("kind", openapi.String, "Kind"),
+		openapi.Query("project_id", openapi.String, "Project ID"),
+		openapi.Query("shared", openapi.Boolean, "Shared"),
+	}},
+	"POST /saved-searches":    {Body: "The saved search"},
+	"PUT /saved-searches/:id": {Body: "The saved search"},
+	"GET /reports": {Paged: true, Query: []openapi.Parameter{
+		openapi.Query("saved_search_id", openapi.String, "Saved search ID"),
+		enabled,
+	}},
+	"POST /reports":    {Body: "The report"},
+	"PUT /reports/:id": {Body: "The report"},
+
+	"POST /query":          {Summary: "Search the archived entries", Body: "query, project_id, start, end and limit"},
+	"POST /query/validate": {Summary: "Validate a query", Body: "query"},
+	"POST /logs/aggregate": {Summary: "Aggregate the archived entries", Body: "query, project_id, start, end, interval, group_by, top and top_n"},
+	"POST /logs/sql":       {Summary: "Run a SQL statement over the archived entries", Body: "sql, project_id, start, end and async"},
+	"GET /logs/tail": {Summary: "Follow the ingested entries as server-sent events", Query: []openapi.Parameter{
+		openapi.Query("query", openapi.String, "Query the entries must match"),
+		openapi.Query("project_id", openapi.String, "Project ID"),
+		openapi.Query("backlog", openapi.Integer, "Recent entries to send first"),
+	}},
+	"POST /exports":               {Body: "query, project_id, start, end, format, fields and limit"},
+	"POST /replay":                {Body: "start, end and optional query, project_id, input_id, outputs and archive"},
+	"POST /retention/policies":    {Body: "The retention policy"},
+	"PUT /retention/policies/:id": {Body: "The retention policy"},
+	"PUT /lookup-tables/:id/csv":  {Summary: "Upload the CSV of a lookup table"},
+	"POST /rules/validate":        {Body: "expression and optional sample entries"},
+	"POST /extractors/test":       {Body: "type, config and message"},
+	"POST /uploads/presign":       {Body: "key and optional expires_in"},
+
+	"GET /events": {Summary: "Recent system events, oldest first", Query: []openapi.Parameter{
+		openapi.Query("type", openapi.String, "Comma-separated event types or families, e.g. input.*,flush.failed"),
+		openapi.Query("after", openapi.Integer, "Only events with a greater ID: the last_id of the previous answer"),
+		openapi.Query("since", openapi.String, "Only events at or after this RFC 3339 time"),
+		openapi.Query("limit", openapi.Integer, "Events at most (default 100, at most 1000)"),
+	}},
+	"GET /events/webhooks": {Summary: "Webhooks of system events and their delivery counters"},
+
+	"GET /logs/recent":       {Summary: "The latest entries accepted by the inputs, oldest first", Query: append([]openapi.Parameter{openapi.Query("input_id", openapi.String, "Input ID")}, recentQuery...)},
+	"GET /inputs/:id/recent": {Summary: "The latest entries of an input, oldest first", Query: recentQuery},
+
+	"GET /ingest/*":  {Summary: "Recently ingested entries"},
+	"POST /ingest/*": {Summary: "Send entries to the input mounted on the path", Headers: []openapi.Parameter{{Name: "X-Akavelog-Token", Description: "Ingest key of the input", Schema: openapi.String}, idempotencyKey}},
+	"GET /metrics":   {Summary: "Prometheus metrics"},
+}
+
+var (
+	ifMatch        = openapi.Parameter{Name: "If-Match", Description: `Version of the input the update is based on, e.g. "3"`, Schema: openapi.String}
+	idempotencyKey = openapi.Parameter{Name: "Idempotency-Key", Description: "Retries with the same key get the first response", Schema: openapi.String}
+	enabled        = openapi.Query("enabled", openapi.Boolean, "Enabled")
+	byType         = openapi.Query("type", openapi.String, "Type")
+	recentQuery    = []openapi.Parameter{
+		openapi.Query("service", openapi.String, "Service, ignoring case"),
+		openapi.Query("level", openapi.String, "Level, ignoring case"),
+		openapi.Query("since", openapi.String, "Only entries received at or after this RFC 3339 time"),
+		openapi.Query("before", openapi.Integer, "Only entries with a smaller seq: the next_cursor of the previous page"),
+		openapi.Query("after", openapi.Integer, "Only entries with a greater seq, to poll"),
+		openapi.Query("limit", openapi.Integer, "Entries at most (default 200)"),
+	}
+)
+
+// registerDocs serves the OpenAPI document of the routes of e at GET /openapi.json and its
+// Swagger UI at GET /docs. The document is generated on first request, once every route is
+// registered.
+func registerDocs(e *echo.Echo) {
+	var (
+		once sync.Once
+		doc  *openapi.Document
+	)
+	e.GET("/openapi.json", func(c echo.Context) error {
+		once.Do(func() { doc = openapi.Generate(apiInfo, e.Routes(), routeDocs) })
+		return c.JSON(http.StatusOK, doc)
+	})
+	e.GET("/docs", openapi.UI("/openapi.json"))
+}
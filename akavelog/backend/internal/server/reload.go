@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/handler"
+	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/selflog"
+	"github.com/labstack/echo/v4"
+)
+
+// ReloadResult reports what a configuration reload changed.
+type ReloadResult struct {
+	File            string   `json:"file,omitempty"`   // config file read, if any
+	Applied         []string `json:"applied"`          // settings now in effect
+	RestartRequired []string `json:"restart_required"` // settings that changed but apply on a restart
+
+	Inputs handler.InputChanges `json:"inputs"` // inputs created, updated or deleted to match the config
+}
+
+// Reload reads the configuration again, from the same file and environment as at startup,
+// and applies what changed without a restart: batcher limits, the retention schedule and
+// default, log levels, the event webhooks and the pipelines declared in the config file. Declared inputs are
+// reconciled whether the file changed or not, which undoes drift made through the API.
+// Outputs are reloaded from the database. Settings that differ but cannot change while
+// running are reported in RestartRequired and keep their values. An invalid configuration
+// changes nothing.
+func (s *Server) Reload(ctx context.Context) (ReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	next, err := config.LoadConfigFile(s.Config.File)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	cur := *s.Config
+	obs := *cur.Observability
+	cur.Observability = &obs
+	res := ReloadResult{File: next.File, Applied: []string{}, RestartRequired: []string{}}
+
+	if s.batcher != nil {
+		changed, restart := s.batcher.Reconfigure(batcherConfig(next.Batcher))
+		for _, name := range changed {
+			res.Applied = append(res.Applied, "batcher."+name)
+		}
+		for _, name := range restart {
+			res.RestartRequired = append(res.RestartRequired, "batcher."+name)
+		}
+		cur.Batcher = next.Batcher
+	}
+	if !reflect.DeepEqual(cur.Retention, next.Retention) {
+		if s.retention != nil {
+			s.retention.Reconfigure(retentionSchedule(next.Retention))
+		}
+		var days int64
+		if next.Retention != nil {
+			days = int64(next.Retention.DefaultDays)
+		}
+		s.retentionHandler.DefaultDays.Store(days)
+		cur.Retention = next.Retention
+		res.Applied = append(res.Applied, "retention")
+	}
+	if obs.Logging.Level != next.Observability.Logging.Level {
+		setLogLevel(next.Observability)
+		obs.Logging.Level = next.Observability.Logging.Level
+		res.Applied = append(res.Applied, "observability.logging.level")
+	}
+	if obs.SelfLogs.Level != next.Observability.SelfLogs.Level && s.selfLogs {
+		if err := selflog.SetLevel(next.Observability.SelfLogs.Level); err != nil {
+			log.Printf("[server] reload: self_logs: %v", err)
+		} else {
+			obs.SelfLogs.Level = next.Observability.SelfLogs.Level
+			res.Applied = append(res.Applied, "observability.self_logs.level")
+		}
+	}
+	if !reflect.DeepEqual(cur.Events, next.Events) {
+		if err := s.events.Configure(eventsConfig(next.Events)); err != nil {
+			log.Printf("[server] reload: events: %v", err)
+		} else {
+			cur.Events = next.Events
+			res.Applied = append(res.Applied, "events")
+		}
+	}
+	res.Inputs = s.inputs.ReconcileInputs(ctx, inputSpecs(next.Inputs), next.PruneInputs)
+	if !res.Inputs.Empty() {
+		res.Applied = append(res.Applied, "inputs")
+	}
+	cur.Inputs, cur.PruneInputs = next.Inputs, next.PruneInputs
+	if !reflect.DeepEqual(cur.Pipelines, next.Pipelines) {
+		s.pipelineHandler.BootstrapPipelines(ctx, pipelineSpecs(next.Pipelines))
+		cur.Pipelines = next.Pipelines
+		res.Applied = append(res.Applied, "pipelines")
+	}
+	s.outputHandler.Reload(ctx)
+	res.Applied = append(res.Applied, "outputs")
+
+	res.RestartRequired = append(res.RestartRequired, changedSections(&cur, next)...)
+	*s.Config = cur
+	return res, nil
+}
+
+// changedSections returns the koanf names of the sections of the config that differ between
+// a and b, with the ones of observability as observability.<name>.
+func changedSections(a, b *config.Config) []string {
+	var names []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Tag.Get("koanf")
+		if name == "-" {
+			continue
+		}
+		if name == "observability" && a.Observability != nil && b.Observability != nil {
+			for _, sub := range changedFields(*a.Observability, *b.Observability) {
+				names = append(names, name+"."+sub)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// changedFields returns the koanf names of the fields of structs a and b that differ.
+func changedFields(a, b any) []string {
+	var names []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			names = append(names, strings.Split(va.Type().Field(i).Tag.Get("koanf"), ",")[0])
+		}
+	}
+	return names
+}
+
+// handleReload reloads the configuration (POST /admin/reload) and returns the ReloadResult.
+// An invalid configuration is a 400 and changes nothing.
+func (s *Server) handleReload(c echo.Context) error {
+	res, err := s.Reload(c.Request().Context())
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid configuration", err.Error())
+	}
+	log.Printf("[server] config reloaded: applied %v, restart required %v", res.Applied, res.RestartRequired)
+	return response.OK(c, res, "Configuration reloaded")
+}
+
+// reloadOnSIGHUP reloads the configuration on every SIGHUP until ctx is done.
+func (s *Server) reloadOnSIGHUP(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			res, err := s.Reload(ctx)
+			if err != nil {
+				log.Printf("[server] SIGHUP: config not reloaded: %v", err)
+				continue
+			}
+			log.Printf("[server] SIGHUP: config reloaded: applied %v, restart required %v", res.Applied, res.RestartRequired)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/batchindex"
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	"github.com/akave-ai/akavelog/internal/lookup"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/replay"
+	"github.com/akave-ai/akavelog/internal/repository"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/streams"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewReplayer returns a replay manager for akavelog replay, with the streams, pipelines and
+// outputs stored in the database, as a server would run them. With archive, entries routed to
+// another stream are batched to O3. The returned func flushes the batcher and the outputs; call
+// it once the replay is over.
+func NewReplayer(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, archive bool) (*replay.Manager, func(), error) {
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage: %w", err)
+	}
+	if store == nil {
+		return nil, nil, fmt.Errorf("storage is not configured")
+	}
+
+	streamList, err := repository.NewStreamRepository(pool).List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list streams: %w", err)
+	}
+	router := streams.NewRouter()
+	if err := router.Load(streamList); err != nil {
+		return nil, nil, fmt.Errorf("load streams: %w", err)
+	}
+	pipeline.SetStreamRouter(router)
+	lookupRepo := repository.NewLookupTableRepository(pool)
+	pipeline.SetLookupResolver(lookup.NewStore(lookupRepo, lookup.PoolQuerier{Pool: pool}))
+
+	pipelineList, err := repository.NewPipelineRepository(pool).List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list pipelines: %w", err)
+	}
+	pipelines := pipeline.NewManager()
+	if err := pipelines.Load(pipelineList); err != nil {
+		return nil, nil, fmt.Errorf("load pipelines: %w", err)
+	}
+
+	outputList, err := repository.NewOutputRepository(pool).List(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list outputs: %w", err)
+	}
+	dispatcher := outputs.NewDispatcher(outputs.GlobalRegistry, router.Outputs)
+	if err := dispatcher.Load(outputList); err != nil {
+		dispatcher.Close()
+		return nil, nil, fmt.Errorf("load outputs: %w", err)
+	}
+
+	batchRepo := repository.NewBatchRepository(pool)
+	targets := replay.Targets{Pipelines: pipelines, Outputs: dispatcher, KeyPrefix: router.KeyPrefix}
+	var b *batcher.Batcher
+	if archive {
+		index := &batchindex.Index{Repo: batchRepo}
+		b = batcher.NewBatcher(batcherConfig(cfg.Batcher), store, "default", &batcher.BatcherOpts{
+			OnFlush:   func(batch model.Batch) { index.Record(batch) },
+			KeyPrefix: router.KeyPrefix,
+		})
+		targets.Archive = b
+	}
+	closeAll := func() {
+		if b != nil {
+			b.Stop()
+		}
+		dispatcher.Close()
+	}
+	return newReplayManager(cfg.Replay, batchRepo, store, targets), closeAll, nil
+}
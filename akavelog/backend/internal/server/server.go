@@ -2,36 +2,98 @@ package server
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
+	"net/http"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/akave-ai/akavelog/internal/alerting"
+	"github.com/akave-ai/akavelog/internal/anomaly"
 	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/batchindex"
+	"github.com/akave-ai/akavelog/internal/compaction"
 	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/deadletter"
+	"github.com/akave-ai/akavelog/internal/events"
+	"github.com/akave-ai/akavelog/internal/export"
 	"github.com/akave-ai/akavelog/internal/handler"
+	"github.com/akave-ai/akavelog/internal/idempotency"
 	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/beatsinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/cloudwatchinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/datadoginput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/dockerinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/esbulkinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/fluentinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/hecinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/herokuinput"
 	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/httpinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/mqttinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/redisinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/s3input"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/socketinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/statsdinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/webhookinput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/inputs/wsinput"
+	"github.com/akave-ai/akavelog/internal/infrastructure/outputs"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/outputs/gelfoutput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/outputs/httpoutput"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/outputs/s3output"
+	_ "github.com/akave-ai/akavelog/internal/infrastructure/outputs/stdoutoutput"
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/logsql"
+	"github.com/akave-ai/akavelog/internal/lookup"
+	"github.com/akave-ai/akavelog/internal/metrics"
+	akmiddleware "github.com/akave-ai/akavelog/internal/middleware"
 	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/notifications"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/recent"
+	"github.com/akave-ai/akavelog/internal/replay"
 	"github.com/akave-ai/akavelog/internal/repository"
 	"github.com/akave-ai/akavelog/internal/response"
+	"github.com/akave-ai/akavelog/internal/report"
+	"github.com/akave-ai/akavelog/internal/retention"
+	"github.com/akave-ai/akavelog/internal/search"
+	"github.com/akave-ai/akavelog/internal/secrets"
+	"github.com/akave-ai/akavelog/internal/selflog"
 	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/streams"
+	"github.com/akave-ai/akavelog/internal/tail"
+	"github.com/akave-ai/akavelog/internal/tracing"
+	"github.com/akave-ai/akavelog/internal/wal"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 )
 
 // memoryBuffer implements inputs.InputBuffer for received log payloads.
+// Payloads go through the same validation and normalization as the batcher.
 type memoryBuffer struct {
 	mu   sync.Mutex
-	logs [][]byte
+	logs []model.LogEntry
 }
 
-func (b *memoryBuffer) Insert(p []byte) {
+func (b *memoryBuffer) Insert(p []byte) error {
+	entry, err := batcher.ValidateLog(p)
+	if err != nil {
+		log.Printf("[server] invalid log: %v", err)
+		return nil
+	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.logs = append(b.logs, p)
+	b.logs = append(b.logs, *entry)
+	return nil
 }
 
 // Server holds the Echo app and dependencies.
@@ -39,125 +101,1167 @@ type Server struct {
 	Echo           *echo.Echo
 	Config         *config.Config
 	batcher        *batcher.Batcher // optional; stopped on Shutdown
-	recentLogs     *RecentLogsStore
+	recentLogs     *recent.Store
 	uploadStatus   *UploadStatusStore
+	inputs         *handler.InputHandler
+	pipelines      *pipeline.Manager
+	outputs        *outputs.Dispatcher
+	bounded        *inputs.BoundedBuffer // in front of outputs and the batcher
+	deadLetters    *deadletter.Queue // nil without O3
+	manifest       *storage.Manifest // nil unless storage.o3.manifest is set
+	retention      *retention.Manager // nil without O3
+	compaction     *compaction.Manager // nil without O3 or unless enabled
+	sqlJobs        *logsql.Jobs        // statements of /logs/sql; canceled on Shutdown
+	tail           *tail.Hub           // subscribers of /logs/tail; closed on Shutdown
+	exports        *export.Manager     // nil without O3
+	replays        *replay.Manager     // nil without O3
+	reports        *report.Scheduler   // nil without O3; stopped before outputs close
+	alerts         *alerting.Engine    // evaluates /alerts; stopped on Shutdown
+	notifications  *notifications.Notifier // channels of /notifications; closed after alerts stop
+	events         *events.Bus             // system events of /events; closed last, after the batcher
+	anomaly        *anomaly.Analyzer       // nil without O3 or when disabled; stopped before alerts
+	buffer         inputs.InputBuffer // batcher or in-memory buffer; receives processor-generated entries
+	stopTracing    func(context.Context) error // nil unless tracing is enabled; flushes spans last
+	selfLogs       bool                        // own logs are forwarded into the ingest queue
+	retentionHandler *handler.RetentionHandler // default_days is updated by Reload
+	outputHandler    *handler.OutputHandler    // outputs are reloaded by Reload
+	pipelineHandler  *handler.PipelineHandler  // declared pipelines are bootstrapped by Reload
+	reloadMu         sync.Mutex                // one Reload at a time; guards Config
+	draining         *atomic.Bool              // set by Shutdown; /ingest answers 503
+	drainTimeout     time.Duration             // bounds draining the inputs and API requests
+	shutdownOnce     sync.Once
+	shutdownDone     chan struct{} // closed when Shutdown has finished
+	shutdownErr      error
+}
+
+// newBoundedBuffer builds the ingest queue from cfg. An invalid setting is logged and its
+// default used.
+func newBoundedBuffer(cfg *config.BufferConfig, next inputs.InputBuffer) *inputs.BoundedBuffer {
+	var bc inputs.BoundedBufferConfig
+	if cfg != nil {
+		bc.Capacity, bc.Overflow = cfg.Capacity, cfg.Overflow
+		if cfg.BlockTimeout != "" {
+			if d, err := time.ParseDuration(cfg.BlockTimeout); err == nil && d > 0 {
+				bc.BlockTimeout = d
+			} else {
+				log.Printf("[server] buffer: invalid block_timeout %q (using default)", cfg.BlockTimeout)
+			}
+		}
+	}
+	b, err := inputs.NewBoundedBuffer(bc, next)
+	if err != nil {
+		log.Printf("[server] buffer: %v (using block)", err)
+		bc.Overflow = inputs.OverflowBlock
+		b, _ = inputs.NewBoundedBuffer(bc, next)
+	}
+	st := b.Stats()
+	log.Printf("[server] ingest buffer: capacity=%d overflow=%s", st.Capacity, st.Overflow)
+	return b
+}
+
+// registerQueueMetrics exports the depth of the ingest queue and, when batching to O3, of the
+// open batches and the upload retry queue.
+func registerQueueMetrics(bounded *inputs.BoundedBuffer, b *batcher.Batcher) {
+	metrics.RegisterGauge("buffer", "queued", "Payloads waiting in the ingest queue.",
+		func() float64 { return float64(bounded.Stats().Queued) })
+	metrics.RegisterGauge("buffer", "capacity", "Payloads the ingest queue holds at most.",
+		func() float64 { return float64(bounded.Stats().Capacity) })
+	metrics.RegisterCounter("buffer", "dropped_total", "Payloads the ingest queue discarded when full.",
+		func() float64 { return float64(bounded.Stats().Dropped) })
+	metrics.RegisterCounter("buffer", "rejected_total", "Payloads the ingest queue refused.",
+		func() float64 { return float64(bounded.Stats().Rejected) })
+	if b == nil {
+		return
+	}
+	metrics.RegisterGauge("batcher", "pending_entries", "Entries in open batches.", func() float64 {
+		n := 0
+		for _, p := range b.Partitions() {
+			n += p.Pending
+		}
+		return float64(n)
+	})
+	metrics.RegisterGauge("batcher", "retry_objects", "Objects waiting for an upload retry.",
+		func() float64 { return float64(b.RetryStats().Depth) })
+	metrics.RegisterGauge("batcher", "retry_bytes", "Bytes of the objects waiting for an upload retry.",
+		func() float64 { return float64(b.RetryStats().Bytes) })
+}
+
+// setupTracing installs OpenTelemetry tracing when cfg enables it and returns the function that
+// stops it, or nil. A failure is logged and tracing left off.
+func setupTracing(cfg *config.ObservabilityConfig) func(context.Context) error {
+	if cfg == nil || !cfg.Tracing.Enabled {
+		return nil
+	}
+	stop, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.ServiceName,
+		Environment: cfg.Environment,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Printf("[server] tracing: %v (disabled)", err)
+		return nil
+	}
+	log.Printf("[server] tracing enabled: endpoint=%q sample_ratio=%v", cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+	return stop
+}
+
+// newAccessLogger returns the logger of access logs: JSON lines on stdout in production with
+// the json format, console lines otherwise, like the application logger. Its level is the
+// global zerolog level, which logLevel sets and a reload changes.
+func newAccessLogger(cfg *config.ObservabilityConfig) zerolog.Logger {
+	if cfg == nil {
+		cfg = config.DefaultObservabilityConfig()
+	}
+	var w io.Writer = os.Stdout
+	if !cfg.IsProduction() || cfg.Logging.Format != "json" {
+		w = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05"}
+	}
+	return zerolog.New(w).With().Timestamp().
+		Str("service", cfg.ServiceName).Str("component", "http").Logger()
+}
+
+// setLogLevel sets the global zerolog level to the one of cfg; an invalid level is info.
+func setLogLevel(cfg *config.ObservabilityConfig) {
+	if cfg == nil {
+		cfg = config.DefaultObservabilityConfig()
+	}
+	level, err := zerolog.ParseLevel(cfg.GetLogLevel())
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+}
+
+// startSelfLogs forwards akavelog's own logs through the pipelines into next, routed into the
+// stream cfg names, which is created when missing. It reports whether forwarding started.
+func startSelfLogs(cfg *config.ObservabilityConfig, streams *handler.StreamHandler, pipelines *pipeline.Manager, next inputs.InputBuffer) bool {
+	if cfg == nil || !cfg.SelfLogs.Enabled {
+		return false
+	}
+	name := cfg.SelfLogs.Stream
+	if name == "" {
+		name = selflog.Service
+	}
+	s, err := streams.Ensure(context.Background(), model.Stream{
+		Name:        name,
+		Description: "akavelog's own logs",
+		Enabled:     true,
+		MatchType:   model.StreamMatchAll,
+		Rules:       []string{`service == "` + selflog.Service + `"`},
+		O3Prefix:    name,
+	})
+	if err != nil {
+		log.Printf("[server] self logs: stream %q: %v (disabled)", name, err)
+		return false
+	}
+	err = selflog.Start(selflog.Options{
+		Next:  &pipeline.Buffer{Manager: pipelines, Next: next},
+		Level: cfg.SelfLogs.Level,
+		Tags:  map[string]string{pipeline.TagStreams: s.ID.String()},
+	})
+	if err != nil {
+		log.Printf("[server] self logs: %v (disabled)", err)
+		return false
+	}
+	metrics.RegisterCounter("self_logs", "dropped_total", "Own log lines not forwarded because the queue was full or over the rate.",
+		func() float64 { return float64(selflog.Dropped()) })
+	log.Printf("[server] self logs: forwarding into stream %q", name)
+	return true
+}
+
+// setStorageTargets builds the clients of the storage targets of cfg and sets them on store,
+// creating their buckets. On error none are set, and entries naming one are stored in the
+// default bucket.
+func setStorageTargets(store *storage.O3Client, cfg *config.StorageConfig) {
+	if len(cfg.Targets) == 0 {
+		return
+	}
+	targets, err := storage.Targets(cfg.O3, cfg.Targets)
+	if err != nil {
+		log.Printf("[server] storage targets: %v (none enabled)", err)
+		return
+	}
+	for name, t := range targets {
+		if err := t.EnsureBucket(context.Background()); err != nil {
+			log.Printf("[server] storage target %s: ensure bucket: %v (upload may fail)", name, err)
+		}
+	}
+	store.SetTargets(targets)
+	log.Printf("[server] storage targets: %v", store.TargetNames())
+}
+
+// openWAL opens the batcher's write-ahead log when configured. On error the batcher runs
+// without it, buffering in memory only.
+func openWAL(cfg *config.WALConfig) *wal.Log {
+	if cfg == nil || cfg.Dir == "" {
+		return nil
+	}
+	opts := wal.Options{Dir: cfg.Dir, SegmentSize: cfg.SegmentSize, Sync: wal.SyncPolicy(cfg.Fsync)}
+	if opts.Sync == "" {
+		opts.Sync = wal.SyncInterval
+	}
+	if cfg.FsyncInterval != "" {
+		d, err := time.ParseDuration(cfg.FsyncInterval)
+		if err != nil || d <= 0 {
+			log.Printf("[server] wal: invalid fsync_interval %q (using default)", cfg.FsyncInterval)
+		} else {
+			opts.SyncInterval = d
+		}
+	}
+	l, err := wal.Open(opts)
+	if err != nil {
+		log.Printf("[server] %v (batcher buffers in memory only)", err)
+		return nil
+	}
+	log.Printf("[server] wal enabled in %s (fsync=%s)", cfg.Dir, opts.Sync)
+	return l
+}
+
+// openManifest opens the local upload manifest when configured. On error uploads go on
+// without it.
+func openManifest(path string) *storage.Manifest {
+	if path == "" {
+		return nil
+	}
+	m, err := storage.OpenManifest(path)
+	if err != nil {
+		log.Printf("[server] %v (uploads are not recorded)", err)
+		return nil
+	}
+	log.Printf("[server] upload manifest: %s", path)
+	return m
+}
+
+// newRetentionManager starts the retention job with cfg. An invalid interval is logged and
+// the default used.
+func newRetentionManager(cfg *config.RetentionConfig, store retention.Store, rules func(context.Context) ([]retention.Rule, error), index *batchindex.Index, bus *events.Bus) *retention.Manager {
+	rc := retention.Config{
+		OnDelete: func(key string) { index.Remove(key) },
+		OnRun: func(st retention.RunStats) {
+			if !st.DryRun && st.Deleted+st.Archived+st.Errors > 0 {
+				bus.Publish(retentionEvent(st))
+			}
+		},
+	}
+	rc.Interval, rc.DryRun = retentionSchedule(cfg)
+	m := retention.NewManager(rc, store, rules)
+	log.Printf("[server] retention enabled (dry_run=%v)", rc.DryRun)
+	return m
+}
+
+// defaultDrainTimeout bounds draining inputs and API requests on shutdown unless
+// server.drain_timeout is set.
+const defaultDrainTimeout = 30 * time.Second
+
+// drainRetryAfter is the Retry-After, in seconds, of ingest requests refused while shutting down.
+const drainRetryAfter = "5"
+
+// drainTimeout returns the drain timeout of cfg. An invalid one is logged and the default used.
+func drainTimeout(cfg *config.ServerConfig) time.Duration {
+	if cfg.DrainTimeout == "" {
+		return defaultDrainTimeout
+	}
+	d, err := time.ParseDuration(cfg.DrainTimeout)
+	if err != nil || d <= 0 {
+		log.Printf("[server] invalid drain_timeout %q (using %s)", cfg.DrainTimeout, defaultDrainTimeout)
+		return defaultDrainTimeout
+	}
+	return d
+}
+
+// defaultMaxBodyBytes bounds API request bodies unless server.max_body_bytes is set.
+const defaultMaxBodyBytes = 10 << 20
+
+// bodyLimit refuses API request bodies larger than server.max_body_bytes with 413. Ingest
+// requests are left to the limits of their inputs.
+func bodyLimit(cfg *config.ServerConfig) echo.MiddlewareFunc {
+	limit := cfg.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	return middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Limit: strconv.FormatInt(limit, 10),
+		Skipper: func(c echo.Context) bool {
+			return strings.HasPrefix(c.Request().URL.Path, "/ingest/")
+		},
+	})
+}
+
+// retentionSchedule returns the interval (0 for the default) and dry-run mode of cfg. An
+// invalid interval is logged and the default used.
+func retentionSchedule(cfg *config.RetentionConfig) (time.Duration, bool) {
+	if cfg == nil {
+		return 0, false
+	}
+	var interval time.Duration
+	if cfg.Interval != "" {
+		if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("[server] retention: invalid interval %q (using default)", cfg.Interval)
+		}
+	}
+	return interval, cfg.DryRun
+}
+
+// batcherConfig returns the batcher settings of cfg over the defaults. An invalid codec is
+// logged and the default used.
+func batcherConfig(cfg *config.BatcherConfig) batcher.BatcherConfig {
+	bc := batcher.DefaultBatcherConfig()
+	if cfg == nil {
+		return bc
+	}
+	if cfg.MaxBatchSize > 0 {
+		bc.MaxBatchSize = cfg.MaxBatchSize
+	}
+	if cfg.MaxBatchBytes > 0 {
+		bc.MaxBatchBytes = cfg.MaxBatchBytes
+	}
+	if cfg.MaxObjectBytes > 0 {
+		bc.MaxObjectBytes = cfg.MaxObjectBytes
+	}
+	bc.ObjectSizeCompressed = cfg.ObjectSizeCompressed
+	if codec, err := storage.ParseCodec(cfg.Codec); err != nil {
+		log.Printf("[server] batcher: %v (using %s)", err, bc.Codec)
+	} else {
+		bc.Codec = codec
+	}
+	bc.SpillDir = cfg.SpillDir
+	if cfg.Workers > 0 {
+		bc.Workers = cfg.Workers
+	}
+	if cfg.MaxRetryBytes > 0 {
+		bc.MaxRetryBytes = cfg.MaxRetryBytes
+	}
+	if cfg.FlushInterval != "" {
+		if d, err := time.ParseDuration(cfg.FlushInterval); err == nil && d > 0 {
+			bc.FlushInterval = d
+		}
+	}
+	return bc
+}
+
+// newSQLHandler returns the /logs/sql handler with the limits of cfg. Invalid durations are
+// logged and their defaults used.
+func newSQLHandler(cfg *config.SQLConfig, index search.Index) *handler.SQLHandler {
+	h := &handler.SQLHandler{Index: index}
+	jc := logsql.JobsConfig{}
+	if cfg != nil {
+		h.MaxScanBytes = cfg.MaxScanBytes
+		h.MaxRows = cfg.MaxRows
+		jc.MaxRunning = cfg.MaxJobs
+		duration := func(name, v string, d *time.Duration) {
+			if v == "" {
+				return
+			}
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d = parsed
+			} else {
+				log.Printf("[server] sql: invalid %s %q (using default)", name, v)
+			}
+		}
+		duration("timeout", cfg.Timeout, &jc.Timeout)
+		duration("sync_wait", cfg.SyncWait, &h.SyncWait)
+		duration("result_ttl", cfg.ResultTTL, &jc.ResultTTL)
+	}
+	h.Jobs = logsql.NewJobs(jc)
+	return h
+}
+
+// newExportManager starts the export manager with cfg, reading entries through index and
+// writing to store. Invalid durations are logged and their defaults used.
+func newExportManager(cfg *config.ExportConfig, index search.Index, store *storage.O3Client) *export.Manager {
+	var ec export.Config
+	if cfg != nil {
+		ec.MaxRunning, ec.MaxRows, ec.MaxScanBytes = cfg.MaxJobs, cfg.MaxRows, cfg.MaxScanBytes
+		duration := func(name, v string, d *time.Duration) {
+			if v == "" {
+				return
+			}
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d = parsed
+			} else {
+				log.Printf("[server] export: invalid %s %q (using default)", name, v)
+			}
+		}
+		duration("timeout", cfg.Timeout, &ec.Timeout)
+		duration("url_expiry", cfg.URLExpiry, &ec.URLExpiry)
+		duration("ttl", cfg.TTL, &ec.TTL)
+	}
+	return export.NewManager(ec, index, store, store)
+}
+
+// newReplayManager returns the replay manager with cfg, reading entries through index and
+// store. Invalid durations are logged and their defaults used.
+func newReplayManager(cfg *config.ReplayConfig, index search.Index, store *storage.O3Client, targets replay.Targets) *replay.Manager {
+	var rc replay.Config
+	if cfg != nil {
+		rc.MaxRunning, rc.MaxObjects, rc.MaxScanBytes = cfg.MaxJobs, cfg.MaxObjects, cfg.MaxScanBytes
+		duration := func(name, v string, d *time.Duration) {
+			if v == "" {
+				return
+			}
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d = parsed
+			} else {
+				log.Printf("[server] replay: invalid %s %q (using default)", name, v)
+			}
+		}
+		duration("timeout", cfg.Timeout, &rc.Timeout)
+		duration("ttl", cfg.TTL, &rc.TTL)
+	}
+	return replay.NewManager(rc, index, store, targets)
+}
+
+// newIdempotencyStore returns the store of the responses to requests with an
+// Idempotency-Key, bounded by cfg. An invalid ttl is logged and its default used.
+func newIdempotencyStore(cfg *config.IdempotencyConfig) *idempotency.Store {
+	var ic idempotency.Config
+	if cfg != nil {
+		ic.MaxKeys = cfg.MaxKeys
+		if cfg.TTL != "" {
+			if d, err := time.ParseDuration(cfg.TTL); err == nil && d > 0 {
+				ic.TTL = d
+			} else {
+				log.Printf("[server] idempotency: invalid ttl %q (using default)", cfg.TTL)
+			}
+		}
+	}
+	return idempotency.NewStore(ic)
 }
 
+// newIDWindow returns the window of entry IDs that drops resubmitted entries, bounded by cfg.
+// An invalid window is logged and its default used.
+func newIDWindow(cfg *config.EntryIDsConfig) *pipeline.IDWindow {
+	if cfg == nil {
+		return pipeline.NewIDWindow(0, 0)
+	}
+	var window time.Duration
+	if cfg.Window != "" {
+		if d, err := time.ParseDuration(cfg.Window); err == nil && d > 0 {
+			window = d
+		} else {
+			log.Printf("[server] entry_ids: invalid window %q (using default)", cfg.Window)
+		}
+	}
+	return pipeline.NewIDWindow(window, cfg.MaxIDs)
+}
+
+// newReportScheduler starts the report scheduler with cfg. Invalid durations are logged and
+// their defaults used.
+func newReportScheduler(cfg *config.ReportsConfig, repo report.Repo, searches report.Searches, index search.Index, store *storage.O3Client, notify report.Notify) *report.Scheduler {
+	var rc report.Config
+	if cfg != nil {
+		rc.MaxObjects = cfg.MaxObjects
+		duration := func(name, v string, d *time.Duration) {
+			if v == "" {
+				return
+			}
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d = parsed
+			} else {
+				log.Printf("[server] reports: invalid %s %q (using default)", name, v)
+			}
+		}
+		duration("interval", cfg.Interval, &rc.Interval)
+		duration("timeout", cfg.Timeout, &rc.Timeout)
+		duration("url_expiry", cfg.URLExpiry, &rc.URLExpiry)
+	}
+	return report.NewScheduler(rc, repo, searches, index, store, store, notify)
+}
+
+// newAlertEngine starts the alert engine with cfg, announcing state changes on notifier and
+// bus. An invalid interval is logged and the default used.
+func newAlertEngine(cfg *config.AlertsConfig, store alerting.Store, notifier *notifications.Notifier, bus *events.Bus) *alerting.Engine {
+	ac := alerting.Config{OnChange: func(a model.Alert, ev model.AlertEvent) {
+		notifier.Notify(a.Channels, notifications.AlertNotification(a, ev))
+		bus.Publish(alertEvent(a, ev))
+	}}
+	if cfg != nil && cfg.Interval != "" {
+		if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+			ac.Interval = d
+		} else {
+			log.Printf("[server] alerts: invalid interval %q (using default)", cfg.Interval)
+		}
+	}
+	return alerting.NewEngine(ac, store)
+}
+
+// newAnomalyAnalyzer starts the anomaly analyzer with cfg, judging the anomaly alerts of
+// alerts. Invalid durations are logged and their defaults used.
+func newAnomalyAnalyzer(cfg *config.AnomalyConfig, repo anomaly.Repo, index search.Index, store *storage.O3Client, alerts *alerting.Engine) *anomaly.Analyzer {
+	var ac anomaly.Config
+	if cfg != nil {
+		ac.Alpha, ac.Threshold, ac.MinCount, ac.MinSamples, ac.MaxObjects = cfg.Alpha, cfg.Threshold, cfg.MinCount, cfg.MinSamples, cfg.MaxObjects
+		duration := func(name, v string, d *time.Duration) {
+			if v == "" {
+				return
+			}
+			if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+				*d = parsed
+			} else {
+				log.Printf("[server] anomaly: invalid %s %q (using default)", name, v)
+			}
+		}
+		duration("interval", cfg.Interval, &ac.Interval)
+		duration("delay", cfg.Delay, &ac.Delay)
+	}
+	return anomaly.NewAnalyzer(ac, repo, index, store, alerts)
+}
+
+// newRecentLogs returns the store of the latest entries sized by cfg.
+func newRecentLogs(cfg *config.RecentLogsConfig) *recent.Store {
+	if cfg == nil {
+		return recent.NewStore(0, 0)
+	}
+	return recent.NewStore(cfg.Capacity, cfg.PerInput)
+}
+
+// newTailHandler builds the handler of GET /logs/tail from cfg, with the entries of recentLogs
+// as its backlog. An invalid heartbeat is logged and its default used.
+func newTailHandler(cfg *config.TailConfig, recentLogs *recent.Store) *handler.TailHandler {
+	h := &handler.TailHandler{Recent: func() []tail.Event {
+		entries, _ := recentLogs.List(recent.Filter{})
+		out := make([]tail.Event, len(entries))
+		for i, e := range entries {
+			out[i] = tail.Event{Entry: e.Entry, Received: e.Received}
+		}
+		return out
+	}}
+	var maxSubscribers, queueSize int
+	if cfg != nil {
+		maxSubscribers, queueSize = cfg.MaxSubscribers, cfg.QueueSize
+		if cfg.Heartbeat != "" {
+			if d, err := time.ParseDuration(cfg.Heartbeat); err == nil && d > 0 {
+				h.Heartbeat = d
+			} else {
+				log.Printf("[server] tail: invalid heartbeat %q (using default)", cfg.Heartbeat)
+			}
+		}
+	}
+	h.Hub = tail.NewHub(maxSubscribers, queueSize)
+	return h
+}
+
+// newCompactionManager starts the compaction job with cfg, or returns nil when it is not
+// enabled. Merged objects use codec unless cfg sets another; invalid settings are logged and
+// their defaults used.
+func newCompactionManager(cfg *config.CompactionConfig, codec storage.Codec, store compaction.Store, prefixes func(context.Context) ([]string, error), index *batchindex.Index) *compaction.Manager {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	cc := compaction.Config{
+		SmallBytes:  cfg.SmallBytes,
+		TargetBytes: cfg.TargetBytes,
+		MinObjects:  cfg.MinObjects,
+		Codec:       codec,
+		OnCompact:   index.Compacted,
+	}
+	if cfg.Codec != "" {
+		if c, err := storage.ParseCodec(cfg.Codec); err != nil {
+			log.Printf("[server] compaction: %v (using %s)", err, codec)
+		} else {
+			cc.Codec = c
+		}
+	}
+	duration := func(name, v string, d *time.Duration) {
+		if v == "" {
+			return
+		}
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			*d = parsed
+		} else {
+			log.Printf("[server] compaction: invalid %s %q (using default)", name, v)
+		}
+	}
+	duration("interval", cfg.Interval, &cc.Interval)
+	duration("min_age", cfg.MinAge, &cc.MinAge)
+	m := compaction.NewManager(cc, store, prefixes)
+	cc = m.Config()
+	log.Printf("[server] compaction enabled (interval=%v, small_bytes=%d, min_objects=%d, codec=%s)", cc.Interval, cc.SmallBytes, cc.MinObjects, cc.Codec)
+	return m
+}
+
+// inputSupervisorInterval is how often running inputs are health-checked.
+const inputSupervisorInterval = 10 * time.Second
+
+// pipelineFlushInterval is how often entries generated by processors (dedup summaries) are
+// handed to the buffer.
+const pipelineFlushInterval = time.Second
+
 // New builds the Echo server and registers routes.
 // Caller must provide a non-nil pool (e.g. from database.Database.Pool).
 func New(cfg *config.Config, pool *pgxpool.Pool) *Server {
 	e := echo.New()
 	e.HideBanner = true
-	e.Use(middleware.Recover(), middleware.Logger())
+	// Own logs are queued from now on and forwarded once the pipelines and streams are loaded.
+	if cfg.Observability != nil && cfg.Observability.SelfLogs.Enabled {
+		selflog.CaptureStdLog()
+	}
+	// Resolved secrets never reach the logs, the forwarded ones included.
+	log.SetOutput(secrets.Writer(log.Writer()))
+	stopTracing := setupTracing(cfg.Observability)
+	if stopTracing != nil {
+		e.Use(akmiddleware.Tracing())
+	}
+	// Recover inside AccessLog, so panics are logged as the 500s they become.
+	setLogLevel(cfg.Observability)
+	e.Use(akmiddleware.Metrics(), akmiddleware.AccessLog(newAccessLogger(cfg.Observability)), middleware.Recover())
+	e.Use(bodyLimit(&cfg.Server))
 
-	recentLogs := newRecentLogsStore()
+	// The latest entries of the inputs, after their pipelines, for /logs/recent and /inputs/:id/recent.
+	recentLogs := newRecentLogs(cfg.RecentLogs)
 	uploadStatus := &UploadStatusStore{}
+	// System events (an input crashed, a flush failed, ...) are kept for GET /events and
+	// POSTed to the webhooks of the config file.
+	eventBus := newEventBus(cfg.Events)
+	streamRouter := streams.NewRouter()
+
+	// The batches index records every uploaded object, for time-range lookups without listing O3.
+	batchRepo := repository.NewBatchRepository(pool)
+	index := &batchindex.Index{Repo: batchRepo}
+
+	// Projects are resolved by every input's buffer, and name the storage target of their
+	// batches; load them before inputs start.
+	projectHandler := &handler.ProjectHandler{Repo: repository.NewProjectRepository(pool)}
+	projectHandler.Reload(context.Background())
 
 	var buf inputs.InputBuffer
 	var b *batcher.Batcher
-	if cfg.Storage != nil && cfg.Storage.O3 != nil {
-		o3Client, err := storage.NewO3Client(cfg.Storage.O3)
+	var deadLetters *deadletter.Queue
+	var store *storage.O3Client
+	var manifest *storage.Manifest
+	if cfg.Storage != nil {
+		o3Client, err := storage.New(cfg.Storage)
 		if err != nil {
-			log.Printf("[server] O3 client: %v (using in-memory buffer)", err)
+			log.Printf("[server] storage: %v (using in-memory buffer)", err)
 		}
 		if o3Client != nil {
+			store = o3Client
+			if cfg.Storage.O3 != nil {
+				manifest = openManifest(cfg.Storage.O3.Manifest)
+			}
+			if manifest != nil {
+				o3Client.SetManifest(manifest)
+			}
 			if err := o3Client.EnsureBucket(context.Background()); err != nil {
-				log.Printf("[server] O3 ensure bucket: %v (upload may fail)", err)
+				log.Printf("[server] %s ensure bucket: %v (upload may fail)", o3Client.Backend(), err)
 			}
-			bc := batcher.DefaultBatcherConfig()
-			if cfg.Batcher != nil {
-				if cfg.Batcher.MaxBatchSize > 0 {
-					bc.MaxBatchSize = cfg.Batcher.MaxBatchSize
-				}
-				if cfg.Batcher.FlushInterval != "" {
-					if d, err := time.ParseDuration(cfg.Batcher.FlushInterval); err == nil && d > 0 {
-						bc.FlushInterval = d
-					}
+			setStorageTargets(o3Client, cfg.Storage)
+			metrics.RegisterGauge("o3", "circuit_open", "1 while the O3 circuit breaker is open or half-open.", func() float64 {
+				if o3Client.CircuitStats().State == storage.CircuitClosed {
+					return 0
 				}
-			}
+				return 1
+			})
+			bc := batcherConfig(cfg.Batcher)
 			opts := &batcher.BatcherOpts{
-				OnLog:   func(entry *model.LogEntry) { recentLogs.AddEntry(entry) },
-				OnFlush: func(count int, key string) { uploadStatus.SetLastFlush(count, key) },
+				OnFlush: func(batch model.Batch) {
+					uploadStatus.SetLastFlush(batch.Count, batch.Key)
+					index.Record(batch)
+				},
+				OnUploadError: func(key string, entries int, err error) {
+					eventBus.Publish(flushFailedEvent(key, entries, err))
+				},
+				KeyPrefix: streamRouter.KeyPrefix,
+				// A stream's o3_target takes precedence over its project's.
+				Target: func(e *model.LogEntry) string {
+					if t := streamRouter.Target(e); t != "" {
+						return t
+					}
+					return projectHandler.StorageTarget(e.ProjectID)
+				},
+				WAL: openWAL(cfg.Storage.WAL),
 			}
 			b = batcher.NewBatcher(bc, o3Client, "default", opts)
+			deadLetters = deadletter.NewQueue(o3Client)
 			buf = b
 			uploadStatus.mu.Lock()
 			uploadStatus.BatcherOn = true
 			uploadStatus.mu.Unlock()
-			log.Printf("[server] batcher enabled: flush to Akave O3 (batch=%d, interval=%v)", bc.MaxBatchSize, bc.FlushInterval)
+			log.Printf("[server] batcher enabled: flush to %s (batch=%d, bytes=%d, interval=%v, object_bytes=%d, codec=%s, workers=%d)",
+				o3Client.Backend(), bc.MaxBatchSize, bc.MaxBatchBytes, bc.FlushInterval, bc.MaxObjectBytes, bc.Codec, bc.Workers)
 		}
 	}
 	if buf == nil {
 		buf = &memoryBuffer{}
 	}
+	// Outputs receive what the batcher receives, for the streams routed to them.
+	outputDispatcher := outputs.NewDispatcher(outputs.GlobalRegistry, streamRouter.Outputs)
+	buf = &outputs.Buffer{Dispatcher: outputDispatcher, Next: buf}
+	// GET /logs/tail subscribers see entries as they leave the queue, before batching.
+	tailHandler := newTailHandler(cfg.Tail, recentLogs)
+	buf = &tail.Buffer{Hub: tailHandler.Hub, Next: buf}
+	// Alerts count the entries leaving the queue that match their queries and announce their
+	// state changes on notification channels.
+	notificationHandler := &handler.NotificationHandler{
+		Registry: notifications.GlobalRegistry,
+		Repo:     repository.NewNotificationChannelRepository(pool),
+		Notifier: notifications.NewNotifier(notifications.GlobalRegistry),
+	}
+	notificationHandler.Reload(context.Background())
+	alertRepo := repository.NewAlertRepository(pool)
+	alertHandler := &handler.AlertHandler{Repo: alertRepo, Channels: notificationHandler.Repo,
+		Engine: newAlertEngine(cfg.Alerts, alertRepo, notificationHandler.Notifier, eventBus)}
+	alertHandler.Reload(context.Background())
+	buf = &alerting.Buffer{Engine: alertHandler.Engine, Next: buf}
+	// Inputs push back on their clients when this queue is full instead of growing memory.
+	bounded := newBoundedBuffer(cfg.Buffer, buf)
+	buf = bounded
+	registerQueueMetrics(bounded, b)
 
 	ingestD := NewIngestDispatcher()
 
+	// Polling inputs (s3) persist their progress so restarts do not re-ingest old objects.
+	inputs.GlobalRegistry.SetCheckpointStore(repository.NewCheckpointRepository(pool))
+
+	// Lookup tables back the pipeline's lookup processor.
+	lookupRepo := repository.NewLookupTableRepository(pool)
+	lookupHandler := &handler.LookupTableHandler{
+		Repo:  lookupRepo,
+		Store: lookup.NewStore(lookupRepo, lookup.PoolQuerier{Pool: pool}),
+	}
+	pipeline.SetLookupResolver(lookupHandler.Store)
+
+	// Streams are matched by the pipeline's stream_router processor; load them before pipelines run.
+	streamHandler := &handler.StreamHandler{
+		Repo:    repository.NewStreamRepository(pool),
+		Router:  streamRouter,
+		Targets: store.TargetNames(),
+	}
+	streamHandler.Reload(context.Background())
+	pipeline.SetStreamRouter(streamRouter)
+
+	// Retention applies the policies in retention_policies to the objects in O3.
+	retentionHandler := &handler.RetentionHandler{
+		Repo:    repository.NewRetentionPolicyRepository(pool),
+		Streams: streamHandler.Repo,
+	}
+	if cfg.Retention != nil {
+		retentionHandler.DefaultDays.Store(int64(cfg.Retention.DefaultDays))
+	}
+	if store != nil {
+		retentionHandler.Manager = newRetentionManager(cfg.Retention, store, retentionHandler.Rules, index, eventBus)
+	}
+
+	// Compaction merges the small objects of past days under logs/ and the streams' prefixes.
+	compactionHandler := &handler.CompactionHandler{Streams: streamHandler.Repo}
+	if store != nil {
+		codec := storage.CodecGzipJSON
+		if b != nil {
+			codec = b.Codec()
+		}
+		compactionHandler.Manager = newCompactionManager(cfg.Compaction, codec, store, compactionHandler.Prefixes, index)
+	}
+
+	projectHandler.Targets = store.TargetNames()
+	// Unless auth is disabled, every route needs an API key or user session with the scope
+	// requiredScope gives it.
+	apiKeyHandler := &handler.APIKeyHandler{Repo: repository.NewAPIKeyRepository(pool), Projects: projectHandler}
+	authHandler := &handler.AuthHandler{Users: repository.NewUserRepository(pool), Tokens: newTokens(cfg.Auth)}
+	apiKeyHandler.Auth = newAuthenticator(cfg.Auth, apiKeyHandler.Repo, authHandler.Users, authHandler.Tokens)
+	authHandler.Auth = apiKeyHandler.Auth
+	userHandler := &handler.UserHandler{Repo: authHandler.Users, Auth: apiKeyHandler.Auth}
+	var oidcHandler *handler.OIDCHandler
+	if o := newOIDC(cfg.Auth); o != nil {
+		oidcHandler = &handler.OIDCHandler{
+			OIDC:         o,
+			Users:        authHandler.Users,
+			Tokens:       authHandler.Tokens,
+			Auth:         apiKeyHandler.Auth,
+			PostLoginURL: cfg.Auth.OIDC.PostLoginURL,
+		}
+		if apiKeyHandler.Auth != nil {
+			apiKeyHandler.Auth.UseOIDC(o)
+		}
+	}
+	if apiKeyHandler.Auth != nil {
+		e.Use(akmiddleware.RequireAuth(apiKeyHandler.Auth, requiredScope), refuseProjectKeys)
+	}
+	// Pipelines run between every input's buffer and the batcher; load them before inputs start.
+	pipelineHandler := &handler.PipelineHandler{
+		Repo:      repository.NewPipelineRepository(pool),
+		InputRepo: repository.NewInputRepository(pool),
+		Projects:  projectHandler,
+		Manager:   pipeline.NewManager(),
+	}
+	pipelineHandler.Reload(context.Background())
+	selfLogs := startSelfLogs(cfg.Observability, streamHandler, pipelineHandler.Manager, bounded)
+	outputHandler := &handler.OutputHandler{
+		Registry:   outputs.GlobalRegistry,
+		Repo:       repository.NewOutputRepository(pool),
+		Dispatcher: outputDispatcher,
+		UnitOfWork: repository.NewUnitOfWork(pool),
+		Streams:    streamHandler,
+	}
+	outputHandler.Reload(context.Background())
+	processorHandler := &handler.ProcessorHandler{Registry: processors.GlobalRegistry}
+
+	// Entries resubmitted with the same _id within the window are stored once.
+	entryIDs := newIDWindow(cfg.EntryIDs)
+	metrics.RegisterCounter("ingest", "duplicates_total", "Entries dropped because their _id was already stored.",
+		func() float64 { return float64(entryIDs.Duplicates()) })
+	metrics.RegisterGauge("ingest", "remembered_ids", "Entry _id values remembered to detect duplicates.",
+		func() float64 { return float64(entryIDs.Len()) })
 	inputHandler := &handler.InputHandler{
 		Registry:      inputs.GlobalRegistry,
 		Buffer:        buf,
+		Pipelines:     pipelineHandler.Manager,
 		InputRepo:     repository.NewInputRepository(pool),
+		Projects:      projectHandler,
+		Keys:          &handler.InputKeys{Repo: repository.NewInputKeyRepository(pool)},
+		Events:        eventBus,
+		Recent:        recentLogs,
+		IDs:           entryIDs,
 		Instances:     make(map[uuid.UUID]handler.InstanceRecord),
 		MountIngest:   ingestD.Mount,
 		UnmountIngest: ingestD.Unmount,
 	}
+	uploadHandler := &handler.UploadHandler{Store: store, Batches: batchRepo}
+	batchHandler := &handler.BatchHandler{Repo: batchRepo}
+	// Searches, aggregations and SQL statements are recorded in the query history.
+	savedSearchRepo := repository.NewSavedSearchRepository(pool)
+	queryHandler := &handler.QueryHandler{Index: batchRepo, History: savedSearchRepo}
+	sqlHandler := newSQLHandler(cfg.SQL, batchRepo)
+	sqlHandler.History = savedSearchRepo
+	savedSearchHandler := &handler.SavedSearchHandler{Repo: savedSearchRepo, Query: queryHandler, SQL: sqlHandler}
+	exportHandler := &handler.ExportHandler{}
+	replayHandler := &handler.ReplayHandler{}
+	// Reports run saved aggregations on a schedule and announce them on outputs.
+	reportHandler := &handler.ReportHandler{
+		Repo:     repository.NewReportRepository(pool),
+		Searches: savedSearchRepo,
+		Outputs:  outputHandler.Repo,
+	}
+	if store != nil {
+		queryHandler.Store = store
+		sqlHandler.Store = store
+		exportHandler.Manager = newExportManager(cfg.Export, batchRepo, store)
+		// Replays skip the ingest queue, tails and alerts: the entries were seen when ingested.
+		targets := replay.Targets{Pipelines: pipelineHandler.Manager, Outputs: outputDispatcher, KeyPrefix: streamRouter.KeyPrefix}
+		if b != nil {
+			targets.Archive = b
+		}
+		replayHandler.Manager = newReplayManager(cfg.Replay, batchRepo, store, targets)
+		reportHandler.Scheduler = newReportScheduler(cfg.Reports, reportHandler.Repo, savedSearchRepo, batchRepo, store, outputDispatcher.Send)
+	}
+	// The anomaly analyzer learns per-service baselines from O3 and judges anomaly alerts.
+	analyticsHandler := &handler.AnalyticsHandler{Repo: repository.NewBaselineRepository(pool)}
+	if store != nil && (cfg.Anomaly == nil || !cfg.Anomaly.Disabled) {
+		analyticsHandler.Analyzer = newAnomalyAnalyzer(cfg.Anomaly, analyticsHandler.Repo, batchRepo, store, alertHandler.Engine)
+	}
+	deadLetterHandler := &handler.DeadLetterHandler{Pipelines: pipelineHandler.Manager, Buffer: buf,
+		InputRepo: inputHandler.InputRepo, Projects: projectHandler}
+	if deadLetters != nil {
+		inputHandler.DeadLetter = deadLetters
+		deadLetterHandler.Queue = deadLetters
+	}
+
+	// Retries of POST /inputs and /ingest with the same Idempotency-Key get the first response.
+	idempotent := newIdempotencyStore(cfg.Idempotency)
 
 	// Management API
 	e.GET("/inputs/types", inputHandler.ListTypes)
 	e.GET("/inputs/types/:type", inputHandler.GetTypeInfo)
 	e.GET("/inputs/info", inputHandler.GetAllTypesInfo)
 	e.GET("/inputs", inputHandler.ListInputs)
-	e.POST("/inputs", inputHandler.CreateInput)
+	e.GET("/inputs/metrics", inputHandler.ListInputMetrics)
+	e.GET("/inputs/:id/metrics", inputHandler.GetInputMetrics)
+	e.GET("/inputs/:id/recent", inputHandler.RecentLogs)
+	e.POST("/inputs", inputHandler.CreateInput, echo.WrapMiddleware(idempotent.Middleware))
+	e.POST("/inputs/bulk", inputHandler.CreateInputs, echo.WrapMiddleware(idempotent.Middleware))
+	e.DELETE("/inputs/bulk", inputHandler.DeleteInputs)
+	e.GET("/inputs/export", inputHandler.ExportInputs)
+	e.POST("/inputs/import", inputHandler.ImportInputs)
 	e.PUT("/inputs/:id", inputHandler.UpdateInput)
+	e.PATCH("/inputs/:id", inputHandler.PatchInput)
 	e.DELETE("/inputs/:id", inputHandler.DeleteInput)
+	e.POST("/inputs/:id/rotate-key", inputHandler.RotateKey)
+	e.POST("/inputs/:id/start", inputHandler.StartInput)
+	e.POST("/inputs/:id/stop", inputHandler.StopInput)
+	e.POST("/inputs/:id/pause", inputHandler.PauseInput)
+	e.GET("/streams", streamHandler.ListStreams)
+	e.GET("/streams/:id", streamHandler.GetStream)
+	e.POST("/streams", streamHandler.CreateStream)
+	e.PUT("/streams/:id", streamHandler.UpdateStream)
+	e.DELETE("/streams/:id", streamHandler.DeleteStream)
+	e.GET("/deadletter", deadLetterHandler.List)
+	e.GET("/deadletter/:key", deadLetterHandler.Get)
+	e.POST("/deadletter/:key/replay", deadLetterHandler.Replay)
+	e.DELETE("/deadletter/:key", deadLetterHandler.Delete)
+	e.GET("/uploads", uploadHandler.ListUploads)
+	e.GET("/uploads/verify", uploadHandler.Verify)
+	e.POST("/uploads/presign", uploadHandler.Presign)
+	e.GET("/batches", batchHandler.ListBatches)
+	e.POST("/query", queryHandler.Search)
+	e.POST("/query/validate", queryHandler.Validate)
+	e.GET("/query/history", savedSearchHandler.QueryHistory)
+	e.GET("/saved-searches", savedSearchHandler.ListSavedSearches)
+	e.GET("/saved-searches/:id", savedSearchHandler.GetSavedSearch)
+	e.POST("/saved-searches", savedSearchHandler.CreateSavedSearch)
+	e.PUT("/saved-searches/:id", savedSearchHandler.UpdateSavedSearch)
+	e.DELETE("/saved-searches/:id", savedSearchHandler.DeleteSavedSearch)
+	e.POST("/saved-searches/:id/run", savedSearchHandler.RunSavedSearch)
+	e.GET("/saved-searches/:id/history", savedSearchHandler.SavedSearchHistory)
+	e.POST("/logs/aggregate", queryHandler.Aggregate)
+	e.POST("/logs/sql", sqlHandler.Run)
+	e.GET("/logs/sql/:id", sqlHandler.GetJob)
+	e.DELETE("/logs/sql/:id", sqlHandler.CancelJob)
+	e.GET("/logs/tail", tailHandler.Tail)
+	e.GET("/exports", exportHandler.ListExports)
+	e.GET("/exports/:id", exportHandler.GetExport)
+	e.POST("/exports", exportHandler.CreateExport)
+	e.DELETE("/exports/:id", exportHandler.DeleteExport)
+	e.GET("/replay", replayHandler.ListReplays)
+	e.GET("/replay/:id", replayHandler.GetReplay)
+	e.POST("/replay", replayHandler.CreateReplay)
+	e.DELETE("/replay/:id", replayHandler.DeleteReplay)
+	e.GET("/reports", reportHandler.ListReports)
+	e.GET("/reports/:id", reportHandler.GetReport)
+	e.POST("/reports", reportHandler.CreateReport)
+	e.PUT("/reports/:id", reportHandler.UpdateReport)
+	e.DELETE("/reports/:id", reportHandler.DeleteReport)
+	e.POST("/reports/:id/run", reportHandler.RunReport)
+	e.GET("/reports/:id/runs", reportHandler.ListReportRuns)
+	e.GET("/retention", retentionHandler.GetRetention)
+	e.GET("/retention/upcoming", retentionHandler.Upcoming)
+	e.POST("/retention/run", retentionHandler.Run)
+	e.POST("/retention/policies", retentionHandler.CreatePolicy)
+	e.PUT("/retention/policies/:id", retentionHandler.UpdatePolicy)
+	e.DELETE("/retention/policies/:id", retentionHandler.DeletePolicy)
+	e.GET("/compaction", compactionHandler.GetCompaction)
+	e.POST("/compaction/run", compactionHandler.Run)
+	e.GET("/alerts", alertHandler.ListAlerts)
+	e.GET("/alerts/status", alertHandler.AlertStatus)
+	e.GET("/alerts/:id", alertHandler.GetAlert)
+	e.POST("/alerts", alertHandler.CreateAlert)
+	e.PUT("/alerts/:id", alertHandler.UpdateAlert)
+	e.DELETE("/alerts/:id", alertHandler.DeleteAlert)
+	e.GET("/alerts/:id/history", alertHandler.ListAlertHistory)
+	e.GET("/analytics/baselines", analyticsHandler.ListBaselines)
+	eventHandler := &handler.EventHandler{Bus: eventBus}
+	e.GET("/events", eventHandler.ListEvents)
+	e.GET("/events/webhooks", eventHandler.ListWebhooks)
+	e.GET("/notifications/types", notificationHandler.ListTypes)
+	e.GET("/notifications", notificationHandler.ListChannels)
+	e.GET("/notifications/:id", notificationHandler.GetChannel)
+	e.POST("/notifications", notificationHandler.CreateChannel)
+	e.PUT("/notifications/:id", notificationHandler.UpdateChannel)
+	e.DELETE("/notifications/:id", notificationHandler.DeleteChannel)
+	e.POST("/notifications/:id/test", notificationHandler.TestChannel)
+	e.GET("/outputs/types", outputHandler.ListTypes)
+	e.GET("/outputs/types/:type", outputHandler.GetTypeInfo)
+	e.GET("/outputs", outputHandler.ListOutputs)
+	e.GET("/outputs/:id", outputHandler.GetOutput)
+	e.GET("/outputs/:id/status", outputHandler.GetStatus)
+	e.POST("/outputs", outputHandler.CreateOutput)
+	e.PUT("/outputs/:id", outputHandler.UpdateOutput)
+	e.DELETE("/outputs/:id", outputHandler.DeleteOutput)
+	e.GET("/processors/types", processorHandler.ListTypes)
+	e.GET("/processors/types/:type", processorHandler.GetTypeInfo)
+	e.POST("/auth/login", authHandler.Login)
+	e.GET("/auth/me", authHandler.Me)
+	e.PUT("/auth/password", authHandler.ChangePassword)
+	if oidcHandler != nil {
+		e.GET("/auth/oidc/login", oidcHandler.Login)
+		e.GET("/auth/oidc/callback", oidcHandler.Callback)
+	}
+	e.GET("/users", userHandler.ListUsers)
+	e.GET("/users/:id", userHandler.GetUser)
+	e.POST("/users", userHandler.CreateUser)
+	e.PUT("/users/:id", userHandler.UpdateUser)
+	e.DELETE("/users/:id", userHandler.DeleteUser)
+	e.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+	e.GET("/api-keys/:id", apiKeyHandler.GetAPIKey)
+	e.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+	e.PUT("/api-keys/:id", apiKeyHandler.UpdateAPIKey)
+	e.DELETE("/api-keys/:id", apiKeyHandler.DeleteAPIKey)
+	e.GET("/projects", projectHandler.ListProjects)
+	e.GET("/projects/:id", projectHandler.GetProject)
+	e.POST("/projects", projectHandler.CreateProject)
+	e.PUT("/projects/:id", projectHandler.UpdateProject)
+	e.DELETE("/projects/:id", projectHandler.DeleteProject)
+	e.GET("/pipelines", pipelineHandler.ListPipelines)
+	e.GET("/pipelines/:id", pipelineHandler.GetPipeline)
+	e.POST("/pipelines", pipelineHandler.CreatePipeline)
+	e.PUT("/pipelines/:id", pipelineHandler.UpdatePipeline)
+	e.DELETE("/pipelines/:id", pipelineHandler.DeletePipeline)
+	e.GET("/pipelines/:id/stats", pipelineHandler.GetPipelineStats)
+	e.POST("/rules/validate", pipelineHandler.ValidateRule)
+	e.POST("/extractors/test", pipelineHandler.TestExtractor)
+	e.GET("/lookup-tables", lookupHandler.ListLookupTables)
+	e.GET("/lookup-tables/:id", lookupHandler.GetLookupTable)
+	e.POST("/lookup-tables", lookupHandler.CreateLookupTable)
+	e.PUT("/lookup-tables/:id", lookupHandler.UpdateLookupTable)
+	e.PUT("/lookup-tables/:id/csv", lookupHandler.UploadLookupTableCSV)
+	e.DELETE("/lookup-tables/:id", lookupHandler.DeleteLookupTable)
 
 	// Ingest: GET returns recent logs (raw HTTP, same response shape); POST/PUT etc. dispatch to path handler
+	recentHandler := &handler.RecentLogsHandler{Store: recentLogs}
+	draining := new(atomic.Bool)
+	ingest := echo.WrapHandler(idempotent.Middleware(ingestD))
 	e.Any("/ingest/*", func(c echo.Context) error {
 		if c.Request().Method == "GET" {
-			return response.OK(c, map[string]any{"logs": recentLogs.GetRecent()}, "")
+			return recentHandler.ListRecent(c)
 		}
-		return echo.WrapHandler(ingestD)(c)
+		if draining.Load() {
+			c.Response().Header().Set("Retry-After", drainRetryAfter)
+			return response.Error(c, http.StatusServiceUnavailable, "shutting down", "the server is shutting down; retry later")
+		}
+		return ingest(c)
 	})
 
-	// Demo UI: recent logs and upload status
-	e.GET("/logs/recent", func(c echo.Context) error {
-		return response.OK(c, map[string]any{"logs": recentLogs.GetRecent()}, "")
-	})
+	// Prometheus metrics, including those derived from logs by metric processors
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
+	// Recent logs, filtered and paged, and the upload status of the demo UI
+	e.GET("/logs/recent", recentHandler.ListRecent)
 	e.GET("/logs/status", func(c echo.Context) error {
 		st := uploadStatus.Get()
-		return response.OK(c, map[string]any{
+		status := map[string]any{
 			"batcher_enabled":  st.BatcherOn,
 			"last_upload_at":   st.LastAt,
 			"last_upload_key":  st.LastKey,
 			"last_upload_count": st.LastCount,
 			"pending_count":    st.Pending,
-		}, "")
+			"buffer":           bounded.Stats(),
+		}
+		if b != nil {
+			status["retry_queue"] = b.RetryStats()
+			status["partitions"] = b.Partitions()
+		}
+		if store != nil {
+			status["storage_backend"] = store.Backend()
+			status["circuit_breaker"] = store.CircuitStats()
+		}
+		return response.OK(c, status, "")
 	})
 
+	// OpenAPI document of every route above, and its Swagger UI
+	registerDocs(e)
+
+	// Listeners check ingest keys from the first request; load them before inputs start.
+	inputHandler.Keys.Reload(context.Background())
 	inputHandler.RestoreInputs(context.Background())
+	// Inputs declared in the config file are kept as declared; pipelines are created once and
+	// after that managed through the API like any other.
+	inputHandler.ReconcileInputs(context.Background(), inputSpecs(cfg.Inputs), cfg.PruneInputs)
+	pipelineHandler.BootstrapPipelines(context.Background(), pipelineSpecs(cfg.Pipelines))
 
 	types := inputs.GlobalRegistry.ListRegistered()
 	sort.Strings(types)
 	log.Printf("Registered input types: %v", types)
+	procTypes := processors.GlobalRegistry.ListRegistered()
+	sort.Strings(procTypes)
+	log.Printf("Registered processor types: %v", procTypes)
+	outTypes := outputs.GlobalRegistry.ListRegistered()
+	sort.Strings(outTypes)
+	log.Printf("Registered output types: %v", outTypes)
 
-	return &Server{Echo: e, Config: cfg, batcher: b, recentLogs: recentLogs, uploadStatus: uploadStatus}
+	s := &Server{Echo: e, Config: cfg, batcher: b, recentLogs: recentLogs, uploadStatus: uploadStatus, inputs: inputHandler,
+		pipelines: pipelineHandler.Manager, outputs: outputDispatcher, bounded: bounded, deadLetters: deadLetters, manifest: manifest, retention: retentionHandler.Manager,
+		compaction: compactionHandler.Manager, sqlJobs: sqlHandler.Jobs, tail: tailHandler.Hub, exports: exportHandler.Manager, replays: replayHandler.Manager, reports: reportHandler.Scheduler, alerts: alertHandler.Engine, notifications: notificationHandler.Notifier, events: eventBus,
+		anomaly: analyticsHandler.Analyzer, buffer: buf, stopTracing: stopTracing, selfLogs: selfLogs,
+		retentionHandler: retentionHandler, outputHandler: outputHandler, pipelineHandler: pipelineHandler,
+		draining: draining, drainTimeout: drainTimeout(&cfg.Server), shutdownDone: make(chan struct{})}
+	e.POST("/admin/reload", s.handleReload)
+	e.POST("/admin/flush", s.handleFlush)
+	return s
 }
 
-// Start starts the HTTP server. Blocks until the context is cancelled or the server fails.
-// On context cancel, Shutdown is called so the batcher flushes remaining logs.
+// Start starts the HTTP server and the input supervisor. Blocks until the context is cancelled
+// or the server fails. On context cancel, Shutdown is called, and Start returns once it is done.
 func (s *Server) Start(ctx context.Context) error {
+	go s.inputs.Supervise(ctx, inputSupervisorInterval)
+	go s.pipelines.Run(ctx, s.buffer, pipelineFlushInterval)
+	go s.reloadOnSIGHUP(ctx)
 	go func() {
 		<-ctx.Done()
 		_ = s.Shutdown(context.Background())
 	}()
 	addr := ":" + s.Config.Server.Port
-	return s.Echo.Start(addr)
+	err := s.Echo.Start(addr)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-s.shutdownDone
+		return s.shutdownErr
+	}
+	return err
 }
 
-// Shutdown gracefully shuts down the server and the batcher (flush remaining logs).
+// Shutdown stops the server without losing accepted logs. /ingest answers 503 with
+// Retry-After, the inputs stop accepting and deliver what they are receiving, and in-flight
+// API requests finish, all within the drain timeout. Then the pipelines and the ingest queue
+// are drained, outputs closed and the batcher flushed. Later calls wait for the first.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+		close(s.shutdownDone)
+	})
+	<-s.shutdownDone
+	return s.shutdownErr
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	start := time.Now()
+	log.Printf("[server] shutting down: draining inputs and requests for up to %s", s.drainTimeout)
+	s.draining.Store(true)
+	drainCtx, cancel := context.WithTimeout(ctx, s.drainTimeout)
+	defer cancel()
+	s.inputs.DrainInputs(drainCtx)
+	if err := s.Echo.Shutdown(drainCtx); err != nil {
+		log.Printf("[server] drain timed out after %s: closing open connections", s.drainTimeout)
+		_ = s.Echo.Close()
+	}
+
+	// Replays and reports hand entries to the pipelines, the ingest queue and the outputs;
+	// stop them before those are drained and closed.
+	if s.replays != nil {
+		s.replays.Stop()
+	}
+	if s.reports != nil {
+		s.reports.Stop()
+	}
+	s.pipelines.Flush(s.buffer, true)
+	if s.selfLogs {
+		selflog.Stop()
+	}
+	s.bounded.Close()
+	s.outputs.Close()
+	if s.deadLetters != nil {
+		s.deadLetters.Stop()
+	}
+	if s.retention != nil {
+		s.retention.Stop()
+	}
+	if s.compaction != nil {
+		s.compaction.Stop()
+	}
+	s.sqlJobs.Close()
+	s.tail.Close()
+	if s.anomaly != nil {
+		s.anomaly.Stop()
+	}
+	s.alerts.Stop()
+	s.notifications.Close()
+	if s.exports != nil {
+		s.exports.Stop()
+	}
 	if s.batcher != nil {
 		s.batcher.Stop()
 	}
-	return s.Echo.Shutdown(ctx)
+	s.events.Close()
+	var err error
+	if s.manifest != nil {
+		if err = s.manifest.Close(); err != nil {
+			log.Printf("[server] close manifest: %v", err)
+		}
+	}
+	log.Printf("[server] shut down in %s", time.Since(start).Round(time.Millisecond))
+	if s.stopTracing != nil {
+		if err := s.stopTracing(ctx); err != nil {
+			log.Printf("[server] flush traces: %v", err)
+		}
+	}
+	return err
 }
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// UploadStatusStore holds last flush info for the demo UI.
+type UploadStatusStore struct {
+	mu         sync.RWMutex
+	LastAt     time.Time `json:"last_upload_at"`
+	LastKey    string    `json:"last_upload_key"`
+	LastCount  int       `json:"last_upload_count"`
+	Pending    int       `json:"pending_count"`
+	BatcherOn  bool      `json:"batcher_enabled"`
+}
+
+func (u *UploadStatusStore) SetLastFlush(count int, key string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.LastAt = time.Now().UTC()
+	u.LastKey = key
+	u.LastCount = count
+	u.Pending = 0
+}
+
+func (u *UploadStatusStore) SetPending(n int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Pending = n
+}
+
+func (u *UploadStatusStore) Get() UploadStatusStore {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return UploadStatusStore{
+		LastAt:    u.LastAt,
+		LastKey:   u.LastKey,
+		LastCount: u.LastCount,
+		Pending:   u.Pending,
+		BatcherOn: u.BatcherOn,
+	}
+}
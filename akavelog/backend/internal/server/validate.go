@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/storage"
+	"github.com/akave-ai/akavelog/internal/wal"
+)
+
+// ValidateConfig checks what config.LoadConfigFile leaves to the server: durations, the
+// settings the server would replace by their defaults with a warning, and the inputs and
+// pipelines declared in the config file, as the API would create them. Secret references
+// of inputs are resolved. It returns every problem found, none when the server would
+// start with cfg as it is.
+func ValidateConfig(cfg *config.Config) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	duration := func(name, v string) {
+		if v == "" {
+			return
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			fail("%s: invalid duration %q", name, v)
+		}
+	}
+
+	duration("server.drain_timeout", cfg.Server.DrainTimeout)
+	if cfg.Server.MaxBodyBytes < 0 {
+		fail("server.max_body_bytes: must not be negative")
+	}
+	if c := cfg.Batcher; c != nil {
+		duration("batcher.flush_interval", c.FlushInterval)
+		if _, err := storage.ParseCodec(c.Codec); err != nil {
+			fail("batcher.codec: %v", err)
+		}
+	}
+	if c := cfg.Buffer; c != nil {
+		duration("buffer.block_timeout", c.BlockTimeout)
+		switch c.Overflow {
+		case "", inputs.OverflowBlock, inputs.OverflowDropOldest, inputs.OverflowDropNewest, inputs.OverflowReject:
+		default:
+			fail("buffer.overflow: invalid value %q", c.Overflow)
+		}
+	}
+	if cfg.Storage != nil {
+		switch strings.ToLower(strings.TrimSpace(cfg.Storage.Backend)) {
+		case "", storage.BackendO3, storage.BackendFS, storage.BackendGCS, storage.BackendAzure, storage.BackendAkave:
+		default:
+			fail("storage.backend: invalid value %q", cfg.Storage.Backend)
+		}
+	}
+	if cfg.Storage != nil && cfg.Storage.WAL != nil {
+		c := cfg.Storage.WAL
+		duration("storage.wal.fsync_interval", c.FsyncInterval)
+		switch wal.SyncPolicy(c.Fsync) {
+		case "", wal.SyncAlways, wal.SyncInterval, wal.SyncNever:
+		default:
+			fail("storage.wal.fsync: invalid value %q", c.Fsync)
+		}
+	}
+	if c := cfg.Retention; c != nil {
+		duration("retention.interval", c.Interval)
+	}
+	if c := cfg.Compaction; c != nil {
+		duration("compaction.interval", c.Interval)
+		duration("compaction.min_age", c.MinAge)
+	}
+	if c := cfg.SQL; c != nil {
+		duration("sql.timeout", c.Timeout)
+		duration("sql.sync_wait", c.SyncWait)
+		duration("sql.result_ttl", c.ResultTTL)
+	}
+	if c := cfg.Tail; c != nil {
+		duration("tail.heartbeat", c.Heartbeat)
+	}
+	if c := cfg.Export; c != nil {
+		duration("export.timeout", c.Timeout)
+		duration("export.url_expiry", c.URLExpiry)
+		duration("export.ttl", c.TTL)
+	}
+	if c := cfg.Replay; c != nil {
+		duration("replay.timeout", c.Timeout)
+		duration("replay.ttl", c.TTL)
+	}
+	if c := cfg.Reports; c != nil {
+		duration("reports.interval", c.Interval)
+		duration("reports.timeout", c.Timeout)
+		duration("reports.url_expiry", c.URLExpiry)
+	}
+	if c := cfg.Alerts; c != nil {
+		duration("alerts.interval", c.Interval)
+	}
+	if c := cfg.Anomaly; c != nil {
+		duration("anomaly.interval", c.Interval)
+		duration("anomaly.delay", c.Delay)
+	}
+	if c := cfg.Auth; c != nil {
+		duration("auth.cache_ttl", c.CacheTTL)
+		duration("auth.token_ttl", c.TokenTTL)
+	}
+
+	titles := make(map[string]bool, len(cfg.Inputs))
+	for i, spec := range cfg.Inputs {
+		name := fmt.Sprintf("inputs.%d", i)
+		if spec.Title != "" {
+			name = fmt.Sprintf("inputs.%d (%s)", i, spec.Title)
+		}
+		title := strings.TrimSpace(spec.Title)
+		switch {
+		case title == "":
+			fail("%s: missing title", name)
+		case titles[title]:
+			fail("%s: duplicate title", name)
+		}
+		titles[title] = true
+		switch model.InputState(spec.State) {
+		case "", model.InputStateRunning, model.InputStateStopped, model.InputStatePaused:
+		default:
+			fail("%s: invalid state %q", name, spec.State)
+		}
+		if _, ok := inputs.GlobalRegistry.GetTypeInfo(spec.Type); !ok {
+			fail("%s: unknown type %q", name, spec.Type)
+			continue
+		}
+		if err := inputs.GlobalRegistry.ValidateConfig(spec.Type, inputs.Config(spec.Config)); err != nil {
+			fail("%s: %v", name, err)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Pipelines))
+	for i, spec := range pipelineSpecs(cfg.Pipelines) {
+		name := fmt.Sprintf("pipelines.%d", i)
+		if spec.Name != "" {
+			name = fmt.Sprintf("pipelines.%d (%s)", i, spec.Name)
+		}
+		pname := strings.TrimSpace(spec.Name)
+		switch {
+		case pname == "":
+			fail("%s: missing name", name)
+		case names[pname]:
+			fail("%s: duplicate name", name)
+		}
+		names[pname] = true
+		if err := pipeline.Validate(model.Pipeline{Name: pname, Processors: spec.Processors}); err != nil {
+			fail("%s: %v", name, err)
+		}
+	}
+	return errs
+}
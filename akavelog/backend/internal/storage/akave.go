@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+// AkaveStore is the ObjectStore of a bucket on the Akave network, reached through the native
+// API of an Akave Link node (storage.backend: akave) instead of the S3-compatible O3 gateway.
+// Akave splits each file into chunks and addresses it by the root CID of their DAG; Put
+// returns that CID and Get reports it in MetaCID, so a stored batch can be checked against
+// the CID recorded at upload.
+//
+// Akave files are immutable and cannot be renamed, so Put of an existing key fails with
+// ErrObjectExists unless the file already holds the same content. They keep no
+// user metadata either: the checksums passed to Put are only kept in the manifest, and the
+// CID in the batch index. File names are flat, so the '/' of keys are stored escaped.
+type AkaveStore struct {
+	endpoint string
+	bucket   string
+	token    string
+	rest     *restClient
+}
+
+// NewAkaveStore returns the store of the bucket of cfg.
+func NewAkaveStore(cfg *config.AkaveConfig) (*AkaveStore, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+	s := &AkaveStore{endpoint: strings.TrimRight(cfg.Endpoint, "/"), bucket: cfg.Bucket, token: cfg.Token}
+	rest, err := newRestClient(s.authorize)
+	if err != nil {
+		return nil, err
+	}
+	s.rest = rest
+	return s, nil
+}
+
+func (s *AkaveStore) Backend() string { return BackendAkave }
+
+func (s *AkaveStore) circuitBreaker() *breaker { return s.rest.breaker }
+
+// authorize sends the token, if any, as a bearer token.
+func (s *AkaveStore) authorize(r *http.Request) error {
+	if s.token != "" {
+		r.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return nil
+}
+
+// akaveFile is the metadata of a file in the Link API.
+type akaveFile struct {
+	Name        string    `json:"Name"`
+	RootCID     string    `json:"RootCID"`
+	Size        int64     `json:"Size"`
+	EncodedSize int64     `json:"EncodedSize"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+}
+
+// info returns the ObjectInfo of f, the file of the object at key.
+func (f akaveFile) info(key string) ObjectInfo {
+	return ObjectInfo{Key: key, Size: f.Size, LastModified: f.CreatedAt.UTC(), CID: f.RootCID}
+}
+
+// akaveName is the file name of the object at key.
+func akaveName(key string) string { return url.PathEscape(key) }
+
+func (s *AkaveStore) bucketURL() string {
+	return s.endpoint + "/buckets/" + pathEscape(s.bucket)
+}
+
+func (s *AkaveStore) fileURL(key string) string {
+	return s.bucketURL() + "/files/" + pathEscape(akaveName(key))
+}
+
+// call sends req and decodes the data of its {success, data, error} response into out,
+// unless out is nil.
+func (s *AkaveStore) call(ctx context.Context, req restRequest, out any) error {
+	resp, err := s.rest.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   string          `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("akave %s: %w", req.method, err)
+	}
+	if !body.Success {
+		return fmt.Errorf("akave %s: %s", req.method, body.Error)
+	}
+	if out == nil || len(body.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Data, out)
+}
+
+func (s *AkaveStore) HeadBucket(ctx context.Context) error {
+	return s.call(ctx, restRequest{method: http.MethodGet, url: s.bucketURL()}, nil)
+}
+
+func (s *AkaveStore) EnsureBucket(ctx context.Context) error {
+	err := s.HeadBucket(ctx)
+	if !IsNotFound(err) {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"bucketName": s.bucket})
+	return s.call(ctx, restRequest{
+		method: http.MethodPost,
+		url:    s.endpoint + "/buckets",
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   bytes.NewReader(body),
+	}, nil)
+}
+
+// Put uploads body as a multipart form and returns its root CID. The old file of an existing
+// key is never deleted: a retried upload that already landed returns the file's CID, and
+// other content fails with ErrObjectExists.
+func (s *AkaveStore) Put(ctx context.Context, key string, body io.ReadSeeker, _ string, _ map[string]string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	existing, meta, err := s.Get(ctx, key)
+	switch {
+	case err == nil && bytes.Equal(existing, data):
+		return meta[MetaCID], nil
+	case err == nil:
+		return "", fmt.Errorf("akave put %s: %w", key, ErrObjectExists)
+	case !IsNotFound(err):
+		return "", err
+	}
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", akaveName(key))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	var f akaveFile
+	err = s.call(ctx, restRequest{
+		method: http.MethodPost,
+		url:    s.bucketURL() + "/files",
+		header: http.Header{"Content-Type": {mw.FormDataContentType()}},
+		body:   bytes.NewReader(form.Bytes()),
+	}, &f)
+	if err != nil {
+		return "", err
+	}
+	return f.RootCID, nil
+}
+
+// Get downloads the object, which the Link node checks chunk by chunk against their CIDs,
+// and returns its root CID in MetaCID.
+func (s *AkaveStore) Get(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	var f akaveFile
+	if err := s.call(ctx, restRequest{method: http.MethodGet, url: s.fileURL(key)}, &f); err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.fileURL(key) + "/download"})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, map[string]string{MetaCID: f.RootCID}, nil
+}
+
+func (s *AkaveStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	var f akaveFile
+	if err := s.call(ctx, restRequest{method: http.MethodGet, url: s.fileURL(key)}, &f); err != nil {
+		return ObjectInfo{}, err
+	}
+	return f.info(key), nil
+}
+
+func (s *AkaveStore) Delete(ctx context.Context, key string) error {
+	err := s.call(ctx, restRequest{method: http.MethodDelete, url: s.fileURL(key)}, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Copy downloads src and uploads it to dst; the copy has the same root CID.
+func (s *AkaveStore) Copy(ctx context.Context, src, dst string) error {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.fileURL(src) + "/download"})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	_, err = s.Put(ctx, dst, bytes.NewReader(data), resp.Header.Get("Content-Type"), nil)
+	return err
+}
+
+// List lists every file of the bucket, which the Link API neither filters by prefix nor
+// pages, and keeps those under prefix.
+func (s *AkaveStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var files []akaveFile
+	if err := s.call(ctx, restRequest{method: http.MethodGet, url: s.bucketURL() + "/files"}, &files); err != nil {
+		return nil, err
+	}
+	var out []ObjectInfo
+	for _, f := range files {
+		key, err := url.PathUnescape(f.Name)
+		if err != nil {
+			continue // not uploaded by akavelog
+		}
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, f.info(key))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (s *AkaveStore) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, o := range objects {
+		if dir, _, ok := strings.Cut(strings.TrimPrefix(o.Key, prefix), "/"); ok && dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// PresignGet is not supported: the Link API has no signed URLs, and its download endpoint
+// carries the node's own credentials.
+func (s *AkaveStore) PresignGet(context.Context, string, string, time.Duration) (string, error) {
+	return "", errors.New("akave: presigned URLs are not supported; download through the API")
+}
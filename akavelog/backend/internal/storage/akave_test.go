@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+// fakeLink is an in-memory Akave Link API holding the files of one bucket. The CID of a file
+// is derived from its content, as on the network.
+type fakeLink struct {
+	mu     sync.Mutex
+	bucket bool
+	files  map[string][]byte
+}
+
+func (f *fakeLink) cid(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "bafy" + hex.EncodeToString(sum[:8])
+}
+
+func (f *fakeLink) info(name string, data []byte) map[string]any {
+	return map[string]any{"Name": name, "RootCID": f.cid(data), "Size": len(data), "EncodedSize": len(data) * 2, "CreatedAt": time.Now()}
+}
+
+func (f *fakeLink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(code int, data any) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{"success": code < 300, "data": data, "error": http.StatusText(code)})
+	}
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		reply(http.StatusUnauthorized, nil)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch rest, _ := strings.CutPrefix(r.URL.Path, "/buckets"); {
+	case rest == "" && r.Method == http.MethodPost:
+		f.bucket = true
+		reply(http.StatusCreated, map[string]string{"Name": "logs"})
+	case !f.bucket:
+		reply(http.StatusNotFound, nil)
+	case rest == "/logs":
+		reply(http.StatusOK, map[string]string{"Name": "logs"})
+	case rest == "/logs/files" && r.Method == http.MethodPost:
+		file, hdr, err := r.FormFile("file")
+		if err != nil {
+			reply(http.StatusBadRequest, nil)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if _, ok := f.files[hdr.Filename]; ok {
+			reply(http.StatusConflict, nil) // files are immutable
+			return
+		}
+		f.files[hdr.Filename] = data
+		reply(http.StatusOK, f.info(hdr.Filename, data))
+	case rest == "/logs/files":
+		list := []map[string]any{}
+		for name, data := range f.files {
+			list = append(list, f.info(name, data))
+		}
+		reply(http.StatusOK, list)
+	default:
+		name, download := strings.CutSuffix(strings.TrimPrefix(rest, "/logs/files/"), "/download")
+		data, ok := f.files[name]
+		switch {
+		case !ok:
+			reply(http.StatusNotFound, nil)
+		case r.Method == http.MethodDelete:
+			delete(f.files, name)
+			reply(http.StatusOK, nil)
+		case download:
+			w.Write(data)
+		default:
+			reply(http.StatusOK, f.info(name, data))
+		}
+	}
+}
+
+func TestAkaveStore(t *testing.T) {
+	link := &fakeLink{files: map[string][]byte{}}
+	srv := httptest.NewServer(link)
+	defer srv.Close()
+	c, err := New(&config.StorageConfig{Backend: BackendAkave, Akave: &config.AkaveConfig{Endpoint: srv.URL, Bucket: "logs", Token: "tok"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.HeadBucket(ctx); !IsNotFound(err) {
+		t.Errorf("HeadBucket of a missing bucket: %v", err)
+	}
+	if err := c.EnsureBucket(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "logs/p1/2024/02/17/a.json.gz"
+	cid, err := c.PutObjectCID(ctx, key, []byte("batch"), "application/gzip", nil)
+	if err != nil || cid != link.cid([]byte("batch")) {
+		t.Fatalf("PutObjectCID = %q, %v", cid, err)
+	}
+	// Files are immutable: a retry of the same upload succeeds, other content is refused and
+	// the file is kept.
+	if again, err := c.PutObjectCID(ctx, key, []byte("batch"), "application/gzip", nil); err != nil || again != cid {
+		t.Fatalf("PutObjectCID retried = %q, %v", again, err)
+	}
+	if _, err := c.PutObjectCID(ctx, key, []byte("batch 2"), "application/gzip", nil); !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("PutObjectCID over an existing file: %v", err)
+	}
+	if v, err := c.VerifyCID(ctx, key, cid); err != nil || !v.OK || v.CID != cid {
+		t.Fatalf("VerifyCID = %+v, %v", v, err)
+	}
+	if v, err := c.VerifyCID(ctx, key, link.cid([]byte("batch 2"))); err != nil || v.OK {
+		t.Fatalf("VerifyCID against another CID = %+v, %v", v, err)
+	}
+
+	if err := c.CopyObject(ctx, key, "archive/"+key); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := c.StatObject(ctx, "archive/"+key); err != nil || info.CID != cid || info.Size != 5 {
+		t.Errorf("StatObject of the copy = %+v, %v", info, err)
+	}
+	if dirs, err := c.listDirs(ctx, "logs/"); err != nil || len(dirs) != 1 || dirs[0] != "p1" {
+		t.Errorf("listDirs = %v, %v", dirs, err)
+	}
+	if objects, err := c.ListObjects(ctx, "logs/"); err != nil || len(objects) != 1 || objects[0].Key != key || objects[0].CID != cid {
+		t.Errorf("ListObjects = %+v, %v", objects, err)
+	}
+	if _, err := c.GetObject(ctx, "logs/missing"); !IsNotFound(err) {
+		t.Errorf("GetObject of a missing key: %v", err)
+	}
+	if err := c.DeleteObject(ctx, "logs/missing"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+	if _, err := c.PresignGet(ctx, key, time.Hour); err == nil {
+		t.Error("PresignGet succeeded on akave")
+	}
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+const (
+	azureVersion    = "2021-08-06"
+	azureMetaPrefix = "x-ms-meta-"
+)
+
+// AzureStore is the ObjectStore of an Azure Blob Storage container (storage.backend: azure).
+// Requests are authorized with the account's Shared Key and downloads presigned with service
+// SAS tokens, so it needs no Azure SDK.
+type AzureStore struct {
+	account   string
+	container string
+	endpoint  string
+	key       []byte
+	rest      *restClient
+}
+
+// NewAzureStore returns the store of the container of cfg.
+func NewAzureStore(cfg *config.AzureConfig) (*AzureStore, error) {
+	if cfg.Account == "" || cfg.Key == "" {
+		return nil, errors.New("account and key are required")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	s := &AzureStore{account: cfg.Account, container: cfg.Container, key: key, endpoint: strings.TrimRight(cfg.Endpoint, "/")}
+	if s.endpoint == "" {
+		s.endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+	rest, err := newRestClient(s.authorize)
+	if err != nil {
+		return nil, err
+	}
+	s.rest = rest
+	return s, nil
+}
+
+func (s *AzureStore) Backend() string { return BackendAzure }
+
+func (s *AzureStore) circuitBreaker() *breaker { return s.rest.breaker }
+
+// authorize signs r with the Shared Key scheme of the Blob service.
+func (s *AzureStore) authorize(r *http.Request) error {
+	r.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("X-Ms-Version", azureVersion)
+	length := ""
+	if r.ContentLength > 0 {
+		length = fmt.Sprint(r.ContentLength)
+	}
+	h := r.Header
+	var b strings.Builder
+	for _, v := range []string{
+		r.Method, h.Get("Content-Encoding"), h.Get("Content-Language"), length, h.Get("Content-MD5"),
+		h.Get("Content-Type"), "", h.Get("If-Modified-Since"), h.Get("If-Match"), h.Get("If-None-Match"),
+		h.Get("If-Unmodified-Since"), h.Get("Range"),
+	} {
+		b.WriteString(v + "\n")
+	}
+	var names []string
+	for name := range h {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(h.Get(name)) + "\n")
+	}
+	b.WriteString(s.canonicalResource(r.URL))
+	r.Header.Set("Authorization", "SharedKey "+s.account+":"+s.signature(b.String()))
+	return nil
+}
+
+// canonicalResource is the account, the escaped path and the sorted query parameters of u.
+func (s *AzureStore) canonicalResource(u *url.URL) string {
+	res := "/" + s.account + u.EscapedPath()
+	q := u.Query()
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := q[name]
+		sort.Strings(values)
+		res += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+	return res
+}
+
+// signature returns the base64 HMAC-SHA256 of s with the account key.
+func (s *AzureStore) signature(str string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(str))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *AzureStore) containerURL(query string) string {
+	return s.endpoint + "/" + pathEscape(s.container) + "?" + query
+}
+
+func (s *AzureStore) blobURL(key string) string {
+	return s.endpoint + "/" + pathEscape(s.container) + "/" + escapeKey(key)
+}
+
+func (s *AzureStore) HeadBucket(ctx context.Context) error {
+	return s.rest.discard(ctx, restRequest{method: http.MethodHead, url: s.containerURL("restype=container")})
+}
+
+func (s *AzureStore) EnsureBucket(ctx context.Context) error {
+	err := s.rest.discard(ctx, restRequest{method: http.MethodPut, url: s.containerURL("restype=container")})
+	if hasStatus(err, http.StatusConflict) { // ContainerAlreadyExists
+		return nil
+	}
+	return err
+}
+
+// Put sends the object's MD5 in Content-MD5, so Azure rejects it if it arrives damaged.
+func (s *AzureStore) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, metadata map[string]string) (string, error) {
+	sum := md5.New()
+	if _, err := io.Copy(sum, body); err != nil {
+		return "", err
+	}
+	h := http.Header{
+		"X-Ms-Blob-Type": {"BlockBlob"},
+		"Content-Md5":    {base64.StdEncoding.EncodeToString(sum.Sum(nil))},
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	for k, v := range metadata {
+		h.Set(azureMetaPrefix+k, v)
+	}
+	return "", s.rest.discard(ctx, restRequest{method: http.MethodPut, url: s.blobURL(key), header: h, body: body})
+}
+
+func (s *AzureStore) Get(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.blobURL(key)})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metaFromHeader(resp.Header, azureMetaPrefix), nil
+}
+
+func (s *AzureStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodHead, url: s.blobURL(key)})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return headerInfo(key, resp), nil
+}
+
+func (s *AzureStore) Delete(ctx context.Context, key string) error {
+	err := s.rest.discard(ctx, restRequest{method: http.MethodDelete, url: s.blobURL(key)})
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Copy downloads src and uploads it to dst: Copy Blob may finish asynchronously, and
+// callers expect dst to exist once Copy returns.
+func (s *AzureStore) Copy(ctx context.Context, src, dst string) error {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.blobURL(src)})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	_, err = s.Put(ctx, dst, bytes.NewReader(data), resp.Header.Get("Content-Type"), metaFromHeader(resp.Header, azureMetaPrefix))
+	return err
+}
+
+// azureList is a page of List Blobs.
+type azureList struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified  string `xml:"Last-Modified"`
+				ContentLength int64  `xml:"Content-Length"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// list calls page for every page of the blobs under prefix, grouped by delimiter if set.
+func (s *AzureStore) list(ctx context.Context, prefix, delimiter string, page func(azureList)) error {
+	marker := ""
+	for {
+		q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if delimiter != "" {
+			q.Set("delimiter", delimiter)
+		}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.containerURL(q.Encode())})
+		if err != nil {
+			return err
+		}
+		var out azureList
+		err = xml.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("list %s: %w", prefix, err)
+		}
+		page(out)
+		if out.NextMarker == "" {
+			return nil
+		}
+		marker = out.NextMarker
+	}
+}
+
+func (s *AzureStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := s.list(ctx, prefix, "", func(page azureList) {
+		for _, b := range page.Blobs.Blob {
+			info := ObjectInfo{Key: b.Name, Size: b.Properties.ContentLength}
+			if t, err := http.ParseTime(b.Properties.LastModified); err == nil {
+				info.LastModified = t.UTC()
+			}
+			objects = append(objects, info)
+		}
+	})
+	return objects, err
+}
+
+func (s *AzureStore) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	var dirs []string
+	err := s.list(ctx, prefix, "/", func(page azureList) {
+		for _, p := range page.Blobs.BlobPrefix {
+			if dir := strings.Trim(strings.TrimPrefix(p.Name, prefix), "/"); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	})
+	return dirs, err
+}
+
+// PresignGet returns the blob's URL with a read-only service SAS signed with the account key.
+func (s *AzureStore) PresignGet(_ context.Context, key, filename string, expires time.Duration) (string, error) {
+	expiry := time.Now().UTC().Add(expires).Format("2006-01-02T15:04:05Z")
+	disposition := fmt.Sprintf("attachment; filename=%q", filename)
+	resource := "/blob/" + s.account + "/" + s.container + "/" + key
+	// Fields of the string to sign from version 2020-12-06 on; the unused ones are empty.
+	toSign := strings.Join([]string{
+		"r", "", expiry, resource, "", "", "", azureVersion, "b", "", "", "", disposition, "", "", "",
+	}, "\n")
+	q := url.Values{
+		"sv":   {azureVersion},
+		"sr":   {"b"},
+		"sp":   {"r"},
+		"se":   {expiry},
+		"rscd": {disposition},
+		"sig":  {s.signature(toSign)},
+	}
+	return s.blobURL(key) + "?" + q.Encode(), nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// KeyParts splits a batch key made by KeyForBatchUnder into its prefix and project. ok is false
+// for keys of another layout.
+func KeyParts(key string) (prefix, project string, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) < 6 {
+		return "", "", false
+	}
+	if _, ok := KeyDate(key); !ok {
+		return "", "", false
+	}
+	n := len(parts)
+	return path.Join(parts[:n-5]...), parts[n-5], true
+}
+
+// DescribeBatch returns the index record of the batch object at key, holding data and
+// encoding entries, as uploaded now.
+func DescribeBatch(key string, data []byte, entries []model.LogEntry) model.Batch {
+	b := model.Batch{
+		Key:        key,
+		Count:      len(entries),
+		Size:       int64(len(data)),
+		SHA256:     ComputeChecksums(data).SHA256,
+		Codec:      string(CodecForKey(key)),
+		UploadedAt: time.Now().UTC(),
+	}
+	b.Prefix, b.ProjectID, _ = KeyParts(key)
+	var first, last time.Time
+	for i := range entries {
+		t, err := time.Parse(time.RFC3339Nano, entries[i].Timestamp)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t.UTC()
+		}
+		if last.IsZero() || t.After(last) {
+			last = t.UTC()
+		}
+	}
+	if !first.IsZero() {
+		b.MinTimestamp, b.MaxTimestamp = &first, &last
+	}
+	return b
+}
+
+// BatchPrefixes returns the key prefixes batch objects are stored under: DefaultPrefix and the
+// o3_prefix of every stream.
+func BatchPrefixes(streams []model.Stream) []string {
+	prefixes := []string{DefaultPrefix}
+	for _, s := range streams {
+		if s.O3Prefix != "" {
+			prefixes = append(prefixes, s.O3Prefix)
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ErrCircuitOpen is returned, without a request, while an O3Client's circuit breaker is open.
+var ErrCircuitOpen = errors.New("o3 circuit breaker is open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"    // requests are sent
+	CircuitOpen     = "open"      // requests fail with ErrCircuitOpen until the cooldown ends
+	CircuitHalfOpen = "half_open" // one probe request is sent; its outcome closes or reopens
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// CircuitStats is the state of an O3Client's circuit breaker, shown by /logs/status.
+type CircuitStats struct {
+	State       string     `json:"state"`
+	Failures    int        `json:"consecutive_failures"`
+	Opens       int64      `json:"opens"` // times it opened since the start
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"` // when an open breaker lets a probe through
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	// Targets holds the breakers of the named storage targets.
+	Targets map[string]CircuitStats `json:"targets,omitempty"`
+}
+
+// breaker opens after failures consecutive failed requests, so a slow or failing O3 costs
+// callers nothing while it lasts. After cooldown one probe request is let through: it closes
+// the breaker when it succeeds and reopens it when it fails. It is safe for concurrent use.
+type breaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       string
+	consecutive int
+	opens       int64
+	openedAt    time.Time
+	probing     bool // a half-open probe is in flight
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func newBreaker(failures int, cooldown time.Duration) *breaker {
+	if failures <= 0 {
+		failures = defaultBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &breaker{failures: failures, cooldown: cooldown, now: time.Now, state: CircuitClosed}
+}
+
+// allow returns ErrCircuitOpen when a request must not be sent. Once the cooldown is over it
+// lets one request through as the probe.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+	b.probing = true
+	return nil
+}
+
+// success records a request that reached O3 and was answered.
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state, b.consecutive, b.probing = CircuitClosed, 0, false
+}
+
+// failure records a request that failed, opening the breaker after the threshold or a
+// failed probe.
+func (b *breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.consecutive++
+	b.lastError, b.lastErrorAt = err.Error(), now
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.consecutive >= b.failures) {
+		b.state, b.openedAt, b.probing = CircuitOpen, now, false
+		b.opens++
+	}
+}
+
+// abandon records a request whose outcome says nothing about O3, such as one its caller
+// canceled; a probe is let through again.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) stats() CircuitStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := CircuitStats{State: b.state, Failures: b.consecutive, Opens: b.opens, LastError: b.lastError}
+	if !b.lastErrorAt.IsZero() {
+		at := b.lastErrorAt
+		st.LastErrorAt = &at
+	}
+	if b.state != CircuitClosed {
+		opened, retry := b.openedAt, b.openedAt.Add(b.cooldown)
+		st.OpenedAt, st.RetryAt = &opened, &retry
+	}
+	return st
+}
+
+// breakerClient sends the SDK's requests through a breaker. Every attempt counts: transport
+// errors, throttling and 5xx responses are failures, other responses successes.
+type breakerClient struct {
+	next aws.HTTPClient
+	b    *breaker
+}
+
+func (h breakerClient) Do(r *http.Request) (*http.Response, error) {
+	if err := h.b.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := h.next.Do(r)
+	switch {
+	case err != nil && r.Context().Err() != nil:
+		h.b.abandon()
+	case err != nil:
+		h.b.failure(err)
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		h.b.failure(errors.New(resp.Status))
+	default:
+		h.b.success()
+	}
+	return resp, err
+}
+
+// CircuitStats reports the circuit breaker of c and those of its storage targets.
+func (c *O3Client) CircuitStats() CircuitStats {
+	if c == nil || c.breaker == nil {
+		return CircuitStats{State: CircuitClosed}
+	}
+	st := c.breaker.stats()
+	for name, t := range c.targets {
+		if st.Targets == nil {
+			st.Targets = make(map[string]CircuitStats, len(c.targets))
+		}
+		st.Targets[name] = t.CircuitStats()
+	}
+	return st
+}
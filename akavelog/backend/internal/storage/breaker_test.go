@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	var hits atomic.Int64
+	var down atomic.Bool
+	down.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	c, err := NewO3Client(&config.O3Config{Endpoint: srv.URL, Bucket: "logs", AccessKey: "key", SecretKey: "secret", MaxRetries: 1, MaxBackoff: "1ms",
+		RetryMode: "standard", BreakerFailures: 2, BreakerCooldown: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := c.HeadBucket(ctx); err == nil {
+		t.Fatal("HeadBucket succeeded against a failing endpoint")
+	}
+	if st := c.CircuitStats(); st.State != CircuitOpen || st.Opens != 1 || hits.Load() != 2 {
+		t.Fatalf("after two failed attempts: stats %+v, %d requests", st, hits.Load())
+	}
+	if err := c.HeadBucket(ctx); !errors.Is(err, ErrCircuitOpen) || hits.Load() != 2 {
+		t.Fatalf("open breaker: err %v after %d requests, want ErrCircuitOpen without one", err, hits.Load())
+	}
+
+	down.Store(false)
+	now = now.Add(time.Minute)
+	if err := c.HeadBucket(ctx); err != nil {
+		t.Fatalf("probe after the cooldown: %v", err)
+	}
+	if st := c.CircuitStats(); st.State != CircuitClosed || st.Failures != 0 {
+		t.Errorf("after a successful probe: %+v", st)
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	b.failure(errors.New("timeout"))
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request during the probe: %v", err)
+	}
+	b.failure(errors.New("timeout"))
+	if st := b.stats(); st.State != CircuitOpen || st.Opens != 2 || !st.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("after a failed probe: %+v", st)
+	}
+}
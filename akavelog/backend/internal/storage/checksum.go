@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Object metadata holding the checksums of every object put by O3Client.
+const (
+	MetaSHA256 = "sha256"
+	MetaCRC32C = "crc32c"
+	// MetaCID is the root CID a content-addressed backend (Akave) reports for an object,
+	// returned with its data rather than stored with it.
+	MetaCID = "cid"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksums of an object, base64-encoded as in the S3 x-amz-checksum-* headers.
+type Checksums struct {
+	SHA256 string `json:"sha256"`
+	CRC32C string `json:"crc32c"`
+}
+
+// ComputeChecksums returns the SHA-256 and CRC32C of data.
+func ComputeChecksums(data []byte) Checksums {
+	sum := sha256.Sum256(data)
+	crc := binary.BigEndian.AppendUint32(nil, crc32.Checksum(data, castagnoli))
+	return Checksums{
+		SHA256: base64.StdEncoding.EncodeToString(sum[:]),
+		CRC32C: base64.StdEncoding.EncodeToString(crc),
+	}
+}
+
+// ManifestEntry records one uploaded object.
+type ManifestEntry struct {
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	CRC32C     string    `json:"crc32c"`
+	CID        string    `json:"cid,omitempty"` // root CID returned by a content-addressed backend
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// Manifest is a local, append-only JSON-lines record of every object uploaded, kept apart
+// from the bucket so a tampered or corrupted object cannot vouch for itself.
+type Manifest struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]ManifestEntry
+}
+
+// OpenManifest opens or creates the manifest at path and loads its entries; for a key
+// uploaded more than once, the last entry wins.
+func OpenManifest(path string) (*Manifest, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	m := &Manifest{f: f, entries: make(map[string]ManifestEntry)}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e ManifestEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Key == "" {
+			continue // a torn last line from a crash
+		}
+		m.entries[e.Key] = e
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Record appends e.
+func (m *Manifest) Record(e ManifestEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	m.entries[e.Key] = e
+	return nil
+}
+
+// Lookup returns the entry recorded for key.
+func (m *Manifest) Lookup(key string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	return e, ok
+}
+
+// Close closes the manifest file.
+func (m *Manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.f.Close()
+}
+
+// SetManifest makes c record every object it puts in m.
+func (c *O3Client) SetManifest(m *Manifest) {
+	c.manifest = m
+}
+
+// Verification is the result of re-checking a stored object against its recorded checksums.
+type Verification struct {
+	Key      string         `json:"key"`
+	Size     int64          `json:"size"`
+	Actual   Checksums      `json:"actual"`             // computed from the downloaded object
+	CID      string         `json:"cid,omitempty"`      // root CID the content-addressed backend reports now
+	Metadata *Checksums     `json:"metadata,omitempty"` // stored with the object
+	Manifest *ManifestEntry `json:"manifest,omitempty"` // recorded in the local manifest
+	OK       bool           `json:"ok"`
+	Problems []string       `json:"problems,omitempty"`
+}
+
+// Verify downloads the object at key and compares its checksums with those in its metadata
+// and in the manifest. It is OK only when at least one of them is recorded and all recorded
+// checksums match. On a content-addressed backend, the object's root CID is also compared
+// with the one in the manifest.
+func (c *O3Client) Verify(ctx context.Context, key string) (*Verification, error) {
+	return c.VerifyCID(ctx, key, "")
+}
+
+// VerifyCID is Verify that also compares the object's root CID with cid, the one recorded
+// for it elsewhere (e.g. in the batch index). A matching CID counts as a recorded checksum,
+// so objects on Akave, which keeps no metadata, verify without a manifest.
+func (c *O3Client) VerifyCID(ctx context.Context, key, cid string) (*Verification, error) {
+	if c == nil {
+		return nil, fmt.Errorf("o3 client not configured")
+	}
+	data, metadata, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return verify(key, data, metadata, c.manifest, cid), nil
+}
+
+// verify checks data, the object at key, against its metadata, m (which may be nil) and cid,
+// the root CID recorded for it ("" for none).
+func verify(key string, data []byte, metadata map[string]string, m *Manifest, cid string) *Verification {
+	v := &Verification{Key: key, Size: int64(len(data)), Actual: ComputeChecksums(data), CID: metadata[MetaCID]}
+	if sha, crc := metadata[MetaSHA256], metadata[MetaCRC32C]; sha != "" || crc != "" {
+		v.Metadata = &Checksums{SHA256: sha, CRC32C: crc}
+		v.check("metadata", *v.Metadata)
+	}
+	if m != nil {
+		if e, ok := m.Lookup(key); ok {
+			v.Manifest = &e
+			v.check("manifest", Checksums{SHA256: e.SHA256, CRC32C: e.CRC32C})
+			if e.Size != v.Size {
+				v.Problems = append(v.Problems, fmt.Sprintf("manifest size %d, object %d bytes", e.Size, v.Size))
+			}
+			v.checkCID("manifest", e.CID)
+		}
+	}
+	v.checkCID("recorded", cid)
+	if v.Metadata == nil && v.Manifest == nil && (cid == "" || v.CID == "") {
+		v.Problems = append(v.Problems, "no recorded checksum")
+	}
+	v.OK = len(v.Problems) == 0
+	return v
+}
+
+// checkCID compares want, the root CID source recorded for the object at upload, with the
+// one the backend reports now: a content-addressed object whose CID changed is not the
+// object uploaded. Empty values are skipped.
+func (v *Verification) checkCID(source, want string) {
+	if want != "" && v.CID != "" && want != v.CID {
+		v.Problems = append(v.Problems, fmt.Sprintf("%s cid %s, stored object %s", source, want, v.CID))
+	}
+}
+
+// check compares want, from source, with the actual checksums. Empty values are skipped.
+func (v *Verification) check(source string, want Checksums) {
+	if want.SHA256 != "" && want.SHA256 != v.Actual.SHA256 {
+		v.Problems = append(v.Problems, source+" sha256 mismatch")
+	}
+	if want.CRC32C != "" && want.CRC32C != v.Actual.CRC32C {
+		v.Problems = append(v.Problems, source+" crc32c mismatch")
+	}
+}
+
+// recordUpload adds an uploaded object to the manifest, if any.
+func (c *O3Client) recordUpload(key string, size int, sums Checksums, cid string) {
+	if c.manifest == nil {
+		return
+	}
+	e := ManifestEntry{Key: key, Size: int64(size), SHA256: sums.SHA256, CRC32C: sums.CRC32C, CID: cid, UploadedAt: time.Now().UTC()}
+	if err := c.manifest.Record(e); err != nil {
+		log.Printf("[o3] %v", err)
+	}
+}
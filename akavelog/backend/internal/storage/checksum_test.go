@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeChecksums(t *testing.T) {
+	// Reference values for "123456789" (CRC32C check value e3069283).
+	got := ComputeChecksums([]byte("123456789"))
+	if got.CRC32C != "4waSgw==" {
+		t.Errorf("crc32c = %s", got.CRC32C)
+	}
+	if got.SHA256 != "FeKw08M4keuw8e9gnsQZQgwg4yDOlMZfvIwzEkSOsiU=" {
+		t.Errorf("sha256 = %s", got.SHA256)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.jsonl")
+	m, err := OpenManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("batch")
+	sums := ComputeChecksums(data)
+	if err := m.Record(ManifestEntry{Key: "logs/a", Size: 5, SHA256: sums.SHA256, CRC32C: sums.CRC32C}); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	m, err = OpenManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	meta := map[string]string{MetaSHA256: sums.SHA256, MetaCRC32C: sums.CRC32C}
+	if v := verify("logs/a", data, meta, m, ""); !v.OK || v.Manifest == nil {
+		t.Fatalf("intact object: %+v", v)
+	}
+	if v := verify("logs/a", []byte("bat h"), meta, m, ""); v.OK || len(v.Problems) != 4 {
+		t.Fatalf("damaged object: %+v", v)
+	}
+	if v := verify("logs/b", data, nil, m, ""); v.OK {
+		t.Fatalf("object without checksums verified: %+v", v)
+	}
+	// A content-addressed object has no checksums in its metadata, only its current root CID.
+	stored := map[string]string{MetaCID: "bafy1"}
+	if v := verify("logs/b", data, stored, m, "bafy1"); !v.OK {
+		t.Fatalf("object with its recorded CID: %+v", v)
+	}
+	if v := verify("logs/b", data, stored, m, "bafy2"); v.OK || len(v.Problems) != 1 {
+		t.Fatalf("object with another CID: %+v", v)
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/parquet"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is how a batch object encodes its log entries.
+type Codec string
+
+const (
+	CodecGzipJSON   Codec = "gzip-json"   // gzipped JSON array (default)
+	CodecZstdNDJSON Codec = "zstd-ndjson" // zstd-compressed newline-delimited JSON
+	CodecNDJSON     Codec = "ndjson"      // uncompressed newline-delimited JSON
+	CodecParquet    Codec = "parquet"     // columnar Parquet, see EncodeParquet
+)
+
+// Object metadata set on every batch object.
+const (
+	MetaCodec = "codec" // the object's Codec
+	MetaCount = "count" // log entries in the object
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCodec returns the codec named s; "" is CodecGzipJSON.
+func ParseCodec(s string) (Codec, error) {
+	switch c := Codec(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return CodecGzipJSON, nil
+	case CodecGzipJSON, CodecZstdNDJSON, CodecNDJSON, CodecParquet:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown codec %q (want gzip-json, zstd-ndjson, ndjson or parquet)", s)
+}
+
+// Ext is the object key extension for c.
+func (c Codec) Ext() string {
+	switch c {
+	case CodecZstdNDJSON:
+		return ".ndjson.zst"
+	case CodecNDJSON:
+		return ".ndjson"
+	case CodecParquet:
+		return ".parquet"
+	}
+	return ".json.gz"
+}
+
+// ContentType is the Content-Type objects of c are stored with.
+func (c Codec) ContentType() string {
+	switch c {
+	case CodecZstdNDJSON:
+		return "application/zstd"
+	case CodecNDJSON:
+		return "application/x-ndjson"
+	case CodecParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/gzip"
+}
+
+// CodecForKey returns the codec of a batch object from its key extension.
+func CodecForKey(key string) Codec {
+	switch {
+	case strings.HasSuffix(key, CodecZstdNDJSON.Ext()):
+		return CodecZstdNDJSON
+	case strings.HasSuffix(key, CodecNDJSON.Ext()):
+		return CodecNDJSON
+	case strings.HasSuffix(key, CodecParquet.Ext()):
+		return CodecParquet
+	}
+	return CodecGzipJSON
+}
+
+// EncodeRows encodes rows, each a marshaled log entry, as one object of codec c. Parquet
+// objects are built from the entries themselves with EncodeParquet.
+func EncodeRows(c Codec, rows [][]byte) ([]byte, error) {
+	if c == CodecParquet {
+		return nil, fmt.Errorf("encode rows: %s needs EncodeParquet", c)
+	}
+	var buf bytes.Buffer
+	switch c {
+	case CodecZstdNDJSON, CodecNDJSON:
+		for _, row := range rows {
+			buf.Write(row)
+			buf.WriteByte('\n')
+		}
+		if c == CodecNDJSON {
+			return buf.Bytes(), nil
+		}
+		return zstdEncoder.EncodeAll(buf.Bytes(), make([]byte, 0, buf.Len()/4)), nil
+	}
+	w := gzip.NewWriter(&buf)
+	write := func(p []byte) error {
+		if _, err := w.Write(p); err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		return nil
+	}
+	if err := write([]byte{'['}); err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if i > 0 {
+			if err := write([]byte{','}); err != nil {
+				return nil, err
+			}
+		}
+		if err := write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := write([]byte{']'}); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("gzip close: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeLogs decodes a batch object of any codec. The codec is detected from the data, Parquet
+// or gzip or zstd framing around a JSON array or NDJSON, so objects written before their codec
+// was recorded decode as well.
+func DecodeLogs(data []byte) ([]model.LogEntry, error) {
+	switch {
+	case parquet.IsParquet(data):
+		return decodeParquet(data)
+	case bytes.HasPrefix(data, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+	case bytes.HasPrefix(data, zstdMagic):
+		var err error
+		if data, err = zstdDecoder.DecodeAll(data, nil); err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] == '[' {
+		var entries []model.LogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("decode batch: %w", err)
+		}
+		return entries, nil
+	}
+	var entries []model.LogEntry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var e model.LogEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode batch: line %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// GetObjectLogs downloads the batch object at key and decodes its entries, whatever codec
+// it was written with.
+func (c *O3Client) GetObjectLogs(ctx context.Context, key string) ([]model.LogEntry, error) {
+	data, err := c.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeLogs(data)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	entries := []model.LogEntry{
+		{Service: "api", Level: "info", Message: "first"},
+		{Service: "api", Level: "error", Message: "second"},
+	}
+	rows := make([][]byte, len(entries))
+	for i := range entries {
+		rows[i], _ = json.Marshal(&entries[i])
+	}
+	for _, c := range []Codec{CodecGzipJSON, CodecZstdNDJSON, CodecNDJSON} {
+		data, err := EncodeRows(c, rows)
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		got, err := DecodeLogs(data)
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		if len(got) != 2 || got[0].Message != "first" || got[1].Level != "error" {
+			t.Fatalf("%s: decoded %+v", c, got)
+		}
+		if k := KeyForBatch("default", "abc", c.Ext()); CodecForKey(k) != c {
+			t.Errorf("CodecForKey(%s) = %s, want %s", k, CodecForKey(k), c)
+		}
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	if c, err := ParseCodec(""); err != nil || c != CodecGzipJSON {
+		t.Fatalf("ParseCodec(\"\") = %q, %v", c, err)
+	}
+	if c, err := ParseCodec("ZSTD-NDJSON"); err != nil || c != CodecZstdNDJSON {
+		t.Fatalf("ParseCodec(ZSTD-NDJSON) = %q, %v", c, err)
+	}
+	if _, err := ParseCodec("brotli"); err == nil {
+		t.Fatal("expected error for unknown codec")
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	entries := []model.LogEntry{
+		{ID: "01HQ3Z5N8M4XK2W6Y0R7T9V1BC", Timestamp: "2024-02-17T10:00:00.123456Z", Service: "api", Level: "info", Message: "first",
+			Tags: map[string]string{"env": "prod"}, ProjectID: "shop"},
+		{Timestamp: "not a time", Service: "db", Level: "error", Message: "second",
+			Tags: map[string]string{"region": "eu"}, RawRequest: &model.RawRequestData{Method: "POST", Path: "/x"}},
+	}
+	data, err := EncodeParquet(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeLogs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("decoded %d entries", len(got))
+	}
+	if got[0].ID != entries[0].ID || got[1].ID != "" {
+		t.Errorf("ids = %q, %q", got[0].ID, got[1].ID)
+	}
+	if got[0].Timestamp != entries[0].Timestamp || got[0].ProjectID != "shop" || got[0].Tags["env"] != "prod" || len(got[0].Tags) != 1 {
+		t.Errorf("entry 0 = %+v", got[0])
+	}
+	if got[1].Timestamp != "" || got[1].Tags["region"] != "eu" || got[1].RawRequest == nil || got[1].RawRequest.Path != "/x" {
+		t.Errorf("entry 1 = %+v", got[1])
+	}
+}
+
+func TestKeyDate(t *testing.T) {
+	d, ok := KeyDate("audit/shop/2024/02/17/abc.parquet")
+	if !ok || !d.Equal(time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("KeyDate = %v, %v", d, ok)
+	}
+	if _, ok := KeyDate("deadletter/abc.json"); ok {
+		t.Fatal("KeyDate parsed a key without a date")
+	}
+}
+
+func TestDescribeBatch(t *testing.T) {
+	entries := []model.LogEntry{
+		{Timestamp: "2024-02-17T10:00:00Z"},
+		{Timestamp: "not a time"},
+		{Timestamp: "2024-02-17T12:00:00+02:00"},
+	}
+	b := DescribeBatch("audit/eu/shop/2024/02/17/abc.ndjson.zst", []byte("data"), entries)
+	if b.Prefix != "audit/eu" || b.ProjectID != "shop" || b.Codec != string(CodecZstdNDJSON) || b.Count != 3 || b.Size != 4 {
+		t.Fatalf("batch = %+v", b)
+	}
+	if !b.MinTimestamp.Equal(time.Date(2024, 2, 17, 10, 0, 0, 0, time.UTC)) || !b.MaxTimestamp.Equal(*b.MinTimestamp) { // 12:00+02:00 is 10:00Z
+		t.Fatalf("time range = %v – %v", b.MinTimestamp, b.MaxTimestamp)
+	}
+	if _, _, ok := KeyParts("deadletter/abc.json"); ok {
+		t.Fatal("KeyParts accepted a key without a date")
+	}
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fsMetaDir is the directory of an FSStore holding the metadata of its objects, one JSON
+// file per object at the same relative path.
+const fsMetaDir = ".meta"
+
+// FSStore is the ObjectStore of a local directory (storage.backend: fs): each object is a
+// file at its key under the directory. It needs no credentials, so development setups and
+// tests run the batcher, search and the rest against it without a bucket.
+type FSStore struct {
+	dir string
+}
+
+// fsMeta is the metadata file of an object.
+type fsMeta struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewFSStore returns the store of dir, creating it if missing.
+func NewFSStore(dir string) (*FSStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{dir: abs}, nil
+}
+
+func (s *FSStore) Backend() string { return BackendFS }
+
+// path returns the file of the object at key, rejecting keys that would leave the directory.
+func (s *FSStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." || key == fsMetaDir || strings.HasPrefix(key, fsMetaDir+"/") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *FSStore) metaPath(key string) string {
+	return filepath.Join(s.dir, fsMetaDir, filepath.FromSlash(key)+".json")
+}
+
+func (s *FSStore) HeadBucket(context.Context) error {
+	fi, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+func (s *FSStore) EnsureBucket(context.Context) error {
+	return os.MkdirAll(s.dir, 0o755)
+}
+
+// Put writes the object to a temporary file renamed into place, so readers never see it
+// half-written.
+func (s *FSStore) Put(_ context.Context, key string, body io.ReadSeeker, contentType string, metadata map[string]string) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	meta, err := json.Marshal(fsMeta{ContentType: contentType, Metadata: metadata})
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(s.metaPath(key), func(f *os.File) error {
+		_, err := f.Write(meta)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	return "", writeFileAtomic(p, func(f *os.File) error {
+		_, err := io.Copy(f, body)
+		return err
+	})
+}
+
+// writeFileAtomic creates name with the contents write puts in a temporary file next to it.
+func writeFileAtomic(name string, write func(f *os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (s *FSStore) Get(_ context.Context, key string) ([]byte, map[string]string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, nil, fsError(key, err)
+	}
+	return data, s.readMeta(key).Metadata, nil
+}
+
+// readMeta returns the metadata of the object at key; none for an object without a
+// metadata file, such as one copied into the directory by hand.
+func (s *FSStore) readMeta(key string) fsMeta {
+	var m fsMeta
+	if raw, err := os.ReadFile(s.metaPath(key)); err == nil {
+		json.Unmarshal(raw, &m)
+	}
+	return m
+}
+
+func (s *FSStore) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, fsError(key, err)
+	}
+	if fi.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime().UTC()}, nil
+}
+
+// Delete also removes the directories the object leaves empty, as a bucket has none.
+func (s *FSStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	os.Remove(s.metaPath(key))
+	s.pruneDirs(filepath.Dir(p), s.dir)
+	s.pruneDirs(filepath.Dir(s.metaPath(key)), filepath.Join(s.dir, fsMetaDir))
+	return nil
+}
+
+// pruneDirs removes dir and its parents up to root while they are empty.
+func (s *FSStore) pruneDirs(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (s *FSStore) Copy(ctx context.Context, src, dst string) error {
+	p, err := s.path(src)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return fsError(src, err)
+	}
+	defer f.Close()
+	m := s.readMeta(src)
+	_, err = s.Put(ctx, dst, f, m.ContentType, m.Metadata)
+	return err
+}
+
+// List walks the directory of prefix's last '/' and keeps the files whose key starts with
+// prefix, as a bucket listing would.
+func (s *FSStore) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	root := s.dir
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		if !fs.ValidPath(prefix[:i]) {
+			return nil, nil
+		}
+		root = filepath.Join(s.dir, filepath.FromSlash(prefix[:i]))
+	}
+	var out []ObjectInfo
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(s.dir, p)
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if key == fsMetaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".tmp-") || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed while listing
+		}
+		out = append(out, ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime().UTC()})
+		return nil
+	})
+	return out, err
+}
+
+func (s *FSStore) ListDirs(_ context.Context, prefix string) ([]string, error) {
+	dir := strings.Trim(prefix, "/")
+	if dir == "" {
+		dir = "."
+	}
+	if !fs.ValidPath(dir) {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, filepath.FromSlash(dir)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && !(dir == "." && e.Name() == fsMetaDir) {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// PresignGet returns the file:// URL of the object: the directory has no server to sign
+// for, and dev setups read it on the same machine.
+func (s *FSStore) PresignGet(_ context.Context, key, _ string, _ time.Duration) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: path.Clean(filepath.ToSlash(p))}).String(), nil
+}
+
+// fsError wraps a missing file in ErrNotFound.
+func fsError(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+func newFSClient(t *testing.T) (*O3Client, string) {
+	t.Helper()
+	dir := t.TempDir()
+	c, err := New(&config.StorageConfig{Backend: BackendFS, FS: &config.FSConfig{Dir: dir}})
+	if err != nil || c == nil {
+		t.Fatalf("New: %v, %v", c, err)
+	}
+	return c, dir
+}
+
+func TestFSStoreObjects(t *testing.T) {
+	c, dir := newFSClient(t)
+	ctx := context.Background()
+	if err := c.EnsureBucket(ctx); err != nil {
+		t.Fatal(err)
+	}
+	key := "logs/default/2024/02/17/a.json.gz"
+	if err := c.PutObjectWithMetadata(ctx, key, []byte("batch"), "application/gzip", map[string]string{MetaCount: "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := c.GetObject(ctx, key); err != nil || string(data) != "batch" {
+		t.Fatalf("GetObject = %q, %v", data, err)
+	}
+	if v, err := c.Verify(ctx, key); err != nil || !v.OK {
+		t.Fatalf("Verify = %+v, %v", v, err)
+	}
+	if info, err := c.StatObject(ctx, key); err != nil || info.Size != 5 || info.LastModified.IsZero() {
+		t.Fatalf("StatObject = %+v, %v", info, err)
+	}
+
+	if err := c.CopyObject(ctx, key, "archive/"+key); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Verify(ctx, "archive/"+key); err != nil || !v.OK {
+		t.Fatalf("the copy lost its metadata: %+v, %v", v, err)
+	}
+	objects, err := c.ListObjects(ctx, "logs/default/2024/")
+	if err != nil || len(objects) != 1 || objects[0].Key != key {
+		t.Fatalf("ListObjects = %+v, %v", objects, err)
+	}
+	if objects, _ := c.ListObjects(ctx, "logs/def"); len(objects) != 1 {
+		t.Errorf("a prefix ending inside a directory name lists %+v", objects)
+	}
+	if objects, _ := c.ListObjects(ctx, ""); len(objects) != 2 {
+		t.Errorf("the whole directory lists %+v, want the two objects and no metadata", objects)
+	}
+	page, err := c.ListObjectsPage(ctx, ListOptions{})
+	if err != nil || len(page.Objects) != 1 {
+		t.Fatalf("ListObjectsPage = %+v, %v", page, err)
+	}
+
+	if err := c.DeleteObject(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteObject(ctx, key); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+	if _, err := c.GetObject(ctx, key); !IsNotFound(err) {
+		t.Errorf("GetObject after delete: %v, want not found", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs")); !os.IsNotExist(err) {
+		t.Errorf("emptied directories are kept: %v", err)
+	}
+}
+
+func TestFSStoreRejectsKeysOutsideItsDirectory(t *testing.T) {
+	c, _ := newFSClient(t)
+	ctx := context.Background()
+	for _, key := range []string{"../escape", "/abs", "a/../../b", ".meta/x"} {
+		if err := c.PutObject(ctx, key, []byte("x"), "text/plain"); err == nil {
+			t.Errorf("PutObject(%q) succeeded", key)
+		}
+	}
+}
+
+func TestFSStorePresignGet(t *testing.T) {
+	c, dir := newFSClient(t)
+	url, err := c.PresignGet(context.Background(), "exports/a b.csv", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url, "file://") || !strings.HasSuffix(url, filepath.ToSlash(filepath.Join(dir, "exports"))+"/a%20b.csv") {
+		t.Errorf("PresignGet = %s", url)
+	}
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	if _, err := New(&config.StorageConfig{Backend: "ftp"}); err == nil {
+		t.Error("New accepted an unknown backend")
+	}
+	if c, err := New(&config.StorageConfig{Backend: BackendGCS}); c != nil || err != nil {
+		t.Errorf("an unconfigured backend: %v, %v", c, err)
+	}
+}
@@ -0,0 +1,389 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsMetaPrefix      = "x-goog-meta-"
+	// gcsMaxExpires is the longest validity of a V4 signed URL.
+	gcsMaxExpires = 7 * 24 * time.Hour
+)
+
+// GCSStore is the ObjectStore of a Google Cloud Storage bucket (storage.backend: gcs). Objects
+// are read and written with the XML API and listed with the JSON API, authorized by OAuth
+// tokens of a service account key; downloads are presigned with V4 signed URLs.
+type GCSStore struct {
+	bucket   string
+	project  string
+	endpoint string
+	key      *gcsKey // nil: anonymous requests
+	rest     *restClient
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+}
+
+// gcsKey is a service account JSON key.
+type gcsKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	ProjectID   string `json:"project_id"`
+	rsa         *rsa.PrivateKey
+}
+
+// NewGCSStore returns the store of the bucket of cfg.
+func NewGCSStore(cfg *config.GCSConfig) (*GCSStore, error) {
+	s := &GCSStore{bucket: cfg.Bucket, project: cfg.Project, endpoint: strings.TrimRight(cfg.Endpoint, "/")}
+	if s.endpoint == "" {
+		s.endpoint = gcsDefaultEndpoint
+	}
+	if cfg.CredentialsFile != "" {
+		key, err := readGCSKey(cfg.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		s.key = key
+		if s.project == "" {
+			s.project = key.ProjectID
+		}
+	}
+	rest, err := newRestClient(s.authorize)
+	if err != nil {
+		return nil, err
+	}
+	s.rest = rest
+	return s, nil
+}
+
+func readGCSKey(name string) (*gcsKey, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("credentials_file: %w", err)
+	}
+	var key gcsKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("credentials_file %s: %w", name, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials_file %s: not a service account key", name)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("credentials_file %s: private_key is not PEM", name)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("credentials_file %s: private_key: %w", name, err)
+		}
+	}
+	rk, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credentials_file %s: private_key is not an RSA key", name)
+	}
+	key.rsa = rk
+	return &key, nil
+}
+
+func (s *GCSStore) Backend() string { return BackendGCS }
+
+func (s *GCSStore) circuitBreaker() *breaker { return s.rest.breaker }
+
+// authorize sets the bearer token of the service account on r, fetching a new one when the
+// cached one is about to expire.
+func (s *GCSStore) authorize(r *http.Request) error {
+	if s.key == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == "" || time.Until(s.tokenExp) < time.Minute {
+		token, exp, err := s.fetchToken(r.Context())
+		if err != nil {
+			return fmt.Errorf("gcs token: %w", err)
+		}
+		s.token, s.tokenExp = token, exp
+	}
+	r.Header.Set("Authorization", "Bearer "+s.token)
+	return nil
+}
+
+// fetchToken exchanges a JWT signed with the service account key for an access token.
+func (s *GCSStore) fetchToken(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.key.ClientEmail,
+		"scope": gcsScope,
+		"aud":   s.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := s.sign([]byte(unsigned))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	}
+	resp, err := s.rest.do(ctx, restRequest{
+		method:    http.MethodPost,
+		url:       s.key.TokenURI,
+		header:    http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		body:      strings.NewReader(form.Encode()),
+		anonymous: true,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", time.Time{}, err
+	}
+	if out.AccessToken == "" {
+		return "", time.Time{}, errors.New("no access_token in the response")
+	}
+	return out.AccessToken, now.Add(time.Duration(out.ExpiresIn) * time.Second), nil
+}
+
+// sign returns the RSA-SHA256 signature of data with the service account key.
+func (s *GCSStore) sign(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(nil, s.key.rsa, crypto.SHA256, sum[:])
+}
+
+func (s *GCSStore) objectURL(key string) string {
+	return s.endpoint + "/" + pathEscape(s.bucket) + "/" + escapeKey(key)
+}
+
+func (s *GCSStore) HeadBucket(ctx context.Context) error {
+	return s.rest.discard(ctx, restRequest{method: http.MethodHead, url: s.endpoint + "/" + pathEscape(s.bucket)})
+}
+
+// EnsureBucket creates the bucket in the configured project when it does not exist.
+func (s *GCSStore) EnsureBucket(ctx context.Context) error {
+	err := s.HeadBucket(ctx)
+	if !IsNotFound(err) {
+		return err
+	}
+	err = s.rest.discard(ctx, restRequest{
+		method: http.MethodPut,
+		url:    s.endpoint + "/" + pathEscape(s.bucket),
+		header: http.Header{"X-Goog-Project-Id": {s.project}},
+	})
+	if hasStatus(err, http.StatusConflict) {
+		return nil
+	}
+	return err
+}
+
+// Put sends the object's CRC32C, when metadata holds it, in x-goog-hash, so GCS rejects the
+// object if it arrives damaged.
+func (s *GCSStore) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, metadata map[string]string) (string, error) {
+	h := http.Header{}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	for k, v := range metadata {
+		h.Set(gcsMetaPrefix+k, v)
+	}
+	if crc := metadata[MetaCRC32C]; crc != "" {
+		h.Set("X-Goog-Hash", "crc32c="+crc)
+	}
+	return "", s.rest.discard(ctx, restRequest{method: http.MethodPut, url: s.objectURL(key), header: h, body: body})
+}
+
+func (s *GCSStore) Get(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodGet, url: s.objectURL(key)})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metaFromHeader(resp.Header, gcsMetaPrefix), nil
+}
+
+func (s *GCSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := s.rest.do(ctx, restRequest{method: http.MethodHead, url: s.objectURL(key)})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return headerInfo(key, resp), nil
+}
+
+// headerInfo returns the ObjectInfo in the headers of resp, the answer to a HEAD request.
+func headerInfo(key string, resp *http.Response) ObjectInfo {
+	info := ObjectInfo{Key: key, Size: resp.ContentLength}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = t.UTC()
+	}
+	return info
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	err := s.rest.discard(ctx, restRequest{method: http.MethodDelete, url: s.objectURL(key)})
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *GCSStore) Copy(ctx context.Context, src, dst string) error {
+	return s.rest.discard(ctx, restRequest{
+		method: http.MethodPut,
+		url:    s.objectURL(dst),
+		header: http.Header{"X-Goog-Copy-Source": {pathEscape(s.bucket) + "/" + escapeKey(src)}},
+	})
+}
+
+// gcsList is a page of the JSON API's objects.list.
+type gcsList struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	Prefixes      []string `json:"prefixes"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+// list calls page for every page of the objects under prefix, grouped by delimiter if set.
+func (s *GCSStore) list(ctx context.Context, prefix, delimiter string, page func(gcsList)) error {
+	token := ""
+	for {
+		q := url.Values{"prefix": {prefix}}
+		if delimiter != "" {
+			q.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		resp, err := s.rest.do(ctx, restRequest{
+			method: http.MethodGet,
+			url:    s.endpoint + "/storage/v1/b/" + pathEscape(s.bucket) + "/o?" + q.Encode(),
+		})
+		if err != nil {
+			return err
+		}
+		var out gcsList
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("list %s: %w", prefix, err)
+		}
+		page(out)
+		if out.NextPageToken == "" {
+			return nil
+		}
+		token = out.NextPageToken
+	}
+}
+
+func (s *GCSStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := s.list(ctx, prefix, "", func(page gcsList) {
+		for _, it := range page.Items {
+			size, _ := strconv.ParseInt(it.Size, 10, 64)
+			objects = append(objects, ObjectInfo{Key: it.Name, Size: size, LastModified: it.Updated.UTC()})
+		}
+	})
+	return objects, err
+}
+
+func (s *GCSStore) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	var dirs []string
+	err := s.list(ctx, prefix, "/", func(page gcsList) {
+		for _, p := range page.Prefixes {
+			if dir := strings.Trim(strings.TrimPrefix(p, prefix), "/"); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	})
+	return dirs, err
+}
+
+// PresignGet returns a V4 signed URL, signed with the service account key.
+func (s *GCSStore) PresignGet(_ context.Context, key, filename string, expires time.Duration) (string, error) {
+	if s.key == nil {
+		return "", errors.New("gcs: presigned URLs need a credentials_file")
+	}
+	if expires > gcsMaxExpires {
+		expires = gcsMaxExpires
+	}
+	u, err := url.Parse(s.objectURL(key))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+	query := map[string]string{
+		"X-Goog-Algorithm":             "GOOG4-RSA-SHA256",
+		"X-Goog-Credential":            s.key.ClientEmail + "/" + scope,
+		"X-Goog-Date":                  stamp,
+		"X-Goog-Expires":               strconv.Itoa(int(expires.Seconds())),
+		"X-Goog-SignedHeaders":         "host",
+		"response-content-disposition": fmt.Sprintf("attachment; filename=%q", filename),
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = pathEscape(name) + "=" + pathEscape(query[name])
+	}
+	canonicalQuery := strings.Join(pairs, "&")
+	canonical := strings.Join([]string{
+		http.MethodGet, u.EscapedPath(), canonicalQuery, "host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	sig, err := s.sign([]byte("GOOG4-RSA-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(sum[:])))
+	if err != nil {
+		return "", err
+	}
+	return s.objectURL(key) + "?" + canonicalQuery + "&X-Goog-Signature=" + hex.EncodeToString(sig), nil
+}
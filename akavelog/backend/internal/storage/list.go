@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDayPrefixes is the longest time range listed day by day; longer or open ranges list
+// each project's objects and filter them by the date in their key.
+const maxDayPrefixes = 400
+
+// ErrInvalidCursor is returned for a cursor ListObjectsPage did not make.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions selects batch objects for ListObjectsPage.
+type ListOptions struct {
+	Prefix  string    // top-level key prefix, e.g. logs or a stream's o3_prefix (default logs)
+	Project string    // only this project; "" for every project under Prefix
+	Start   time.Time // only objects whose key date is on or after Start's day
+	End     time.Time // only objects whose key date is on or before End's day
+	Desc    bool      // newest first
+	Limit   int       // at most this many objects; 0 for all
+	Cursor  string    // NextCursor of the previous page
+}
+
+// ObjectPage is one page of ListObjectsPage.
+type ObjectPage struct {
+	Objects    []ObjectInfo `json:"objects"`
+	NextCursor string       `json:"next_cursor,omitempty"` // "" on the last page
+}
+
+// ListObjectsPage lists the batch objects matching opts in upload order (LastModified, then
+// key) and returns at most opts.Limit of them, starting after opts.Cursor. With Start and End
+// set, only the day prefixes between them are listed (prefix/project/YYYY/MM/DD/), so a short
+// range of a large bucket is cheap.
+func (c *O3Client) ListObjectsPage(ctx context.Context, opts ListOptions) (ObjectPage, error) {
+	if c == nil {
+		return ObjectPage{}, fmt.Errorf("o3 client not configured")
+	}
+	after, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return ObjectPage{}, err
+	}
+	base := strings.Trim(opts.Prefix, "/")
+	if base == "" {
+		base = DefaultPrefix
+	}
+	projects := []string{opts.Project}
+	if opts.Project == "" {
+		if projects, err = c.listDirs(ctx, base+"/"); err != nil {
+			return ObjectPage{}, err
+		}
+	}
+	var objects []ObjectInfo
+	for _, project := range projects {
+		for _, prefix := range dayPrefixes(path.Join(base, project), opts.Start, opts.End) {
+			listed, err := c.ListObjects(ctx, prefix)
+			if err != nil {
+				return ObjectPage{}, err
+			}
+			objects = append(objects, listed...)
+		}
+	}
+	return pageObjects(objects, opts, after), nil
+}
+
+// listDirs returns the names of the "directories" right under prefix.
+func (c *O3Client) listDirs(ctx context.Context, prefix string) ([]string, error) {
+	return c.store.ListDirs(ctx, prefix)
+}
+
+// dayPrefixes returns the key prefixes to list for the objects of project dir between start
+// and end: one per day when both are set and the range is short, else dir itself.
+func dayPrefixes(dir string, start, end time.Time) []string {
+	if start.IsZero() || end.IsZero() {
+		return []string{dir + "/"}
+	}
+	first, last := truncateDay(start), truncateDay(end)
+	if last.Before(first) {
+		return nil
+	}
+	if last.Sub(first) > maxDayPrefixes*24*time.Hour {
+		return []string{dir + "/"}
+	}
+	var out []string
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		out = append(out, dir+"/"+d.Format("2006/01/02")+"/")
+	}
+	return out
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// cursor is the position of the last object of a page.
+type cursor struct {
+	modified time.Time
+	key      string
+}
+
+// compare returns -1, 0 or 1 as obj sorts before, at or after c in ascending order.
+func (c cursor) compare(obj ObjectInfo) int {
+	if cmp := obj.LastModified.Compare(c.modified); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(obj.Key, c.key)
+}
+
+func encodeCursor(obj ObjectInfo) string {
+	raw := obj.LastModified.UTC().Format(time.RFC3339Nano) + "|" + obj.Key
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, key, ok := strings.Cut(string(raw), "|")
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if !ok || err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{modified: t, key: key}, nil
+}
+
+// pageObjects filters objects by the dates in their keys, sorts them and returns the page of
+// opts.Limit objects following after.
+func pageObjects(objects []ObjectInfo, opts ListOptions, after *cursor) ObjectPage {
+	var first, last time.Time
+	if !opts.Start.IsZero() {
+		first = truncateDay(opts.Start)
+	}
+	if !opts.End.IsZero() {
+		last = truncateDay(opts.End)
+	}
+	kept := objects[:0]
+	for _, obj := range objects {
+		if !first.IsZero() || !last.IsZero() {
+			d, ok := KeyDate(obj.Key)
+			if !ok || (!first.IsZero() && d.Before(first)) || (!last.IsZero() && d.After(last)) {
+				continue
+			}
+		}
+		if after != nil {
+			if cmp := after.compare(obj); (opts.Desc && cmp >= 0) || (!opts.Desc && cmp <= 0) {
+				continue
+			}
+		}
+		kept = append(kept, obj)
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		a, b := kept[i], kept[j]
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.Before(b.LastModified) != opts.Desc
+		}
+		return (a.Key < b.Key) != opts.Desc
+	})
+	page := ObjectPage{Objects: kept}
+	if opts.Limit > 0 && len(kept) > opts.Limit {
+		page.Objects = kept[:opts.Limit]
+		page.NextCursor = encodeCursor(page.Objects[opts.Limit-1])
+	}
+	return page
+}
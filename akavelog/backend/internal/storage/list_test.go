@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayPrefixes(t *testing.T) {
+	start := time.Date(2024, 2, 28, 23, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)
+	got := dayPrefixes("logs/shop", start, end)
+	want := []string{"logs/shop/2024/02/28/", "logs/shop/2024/02/29/", "logs/shop/2024/03/01/"}
+	if len(got) != len(want) {
+		t.Fatalf("prefixes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("prefixes = %v, want %v", got, want)
+		}
+	}
+	if got := dayPrefixes("logs/shop", start, time.Time{}); len(got) != 1 || got[0] != "logs/shop/" {
+		t.Fatalf("open range = %v", got)
+	}
+	if got := dayPrefixes("logs/shop", end, start); len(got) != 0 {
+		t.Fatalf("inverted range = %v", got)
+	}
+}
+
+func TestPageObjects(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2024, 2, 17, h, 0, 0, 0, time.UTC) }
+	objects := func() []ObjectInfo {
+		return []ObjectInfo{
+			{Key: "logs/a/2024/02/17/3.json.gz", LastModified: at(3)},
+			{Key: "logs/a/2024/02/16/1.json.gz", LastModified: at(1)},
+			{Key: "logs/b/2024/02/17/2b.json.gz", LastModified: at(2)},
+			{Key: "logs/a/2024/02/17/2a.json.gz", LastModified: at(2)},
+			{Key: "logs/a/stray.json", LastModified: at(4)},
+		}
+	}
+	keys := func(p ObjectPage) []string {
+		var out []string
+		for _, o := range p.Objects {
+			out = append(out, o.Key)
+		}
+		return out
+	}
+
+	opts := ListOptions{Start: at(0), End: at(23), Limit: 2}
+	p := pageObjects(objects(), opts, nil)
+	if k := keys(p); len(k) != 2 || k[0] != "logs/a/2024/02/17/2a.json.gz" || k[1] != "logs/b/2024/02/17/2b.json.gz" || p.NextCursor == "" {
+		t.Fatalf("page 1 = %v, cursor %q", k, p.NextCursor)
+	}
+	after, err := decodeCursor(p.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = pageObjects(objects(), opts, after)
+	if k := keys(p); len(k) != 1 || k[0] != "logs/a/2024/02/17/3.json.gz" || p.NextCursor != "" {
+		t.Fatalf("page 2 = %v, cursor %q", k, p.NextCursor)
+	}
+
+	opts = ListOptions{Desc: true, Limit: 3}
+	p = pageObjects(objects(), opts, nil)
+	if k := keys(p); len(k) != 3 || k[0] != "logs/a/stray.json" || k[2] != "logs/b/2024/02/17/2b.json.gz" {
+		t.Fatalf("desc page 1 = %v", k)
+	}
+	after, _ = decodeCursor(p.NextCursor)
+	p = pageObjects(objects(), opts, after)
+	if k := keys(p); len(k) != 2 || k[0] != "logs/a/2024/02/17/2a.json.gz" || k[1] != "logs/a/2024/02/16/1.json.gz" {
+		t.Fatalf("desc page 2 = %v", k)
+	}
+
+	if _, err := decodeCursor("%%"); err == nil {
+		t.Fatal("decodeCursor accepted garbage")
+	}
+}
@@ -5,86 +5,226 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/akave-ai/akavelog/internal/config"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/smithy-go"
 )
 
-// O3Client uploads and downloads objects from Akave O3 (S3-compatible API).
+// O3Client uploads and downloads objects from Akave O3 (S3-compatible API), or from the
+// ObjectStore another backend provides (see New).
 type O3Client struct {
-	client *s3.Client
-	bucket string
+	store    ObjectStore
+	manifest *Manifest            // nil unless SetManifest was called
+	targets  map[string]*O3Client // named storage targets; see SetTargets
+	breaker  *breaker             // nil for backends without one
 }
 
 // NewO3Client builds an S3-compatible client for the given O3 config.
-// Returns nil if cfg is nil or endpoint/bucket are empty.
+// Returns nil if cfg is nil or endpoint/bucket are empty. Requests go through a circuit
+// breaker (see CircuitStats); invalid durations are logged and replaced by their defaults.
 func NewO3Client(cfg *config.O3Config) (*O3Client, error) {
 	if cfg == nil || cfg.Endpoint == "" || cfg.Bucket == "" {
 		return nil, nil
 	}
-	region := cfg.Region
-	if region == "" {
-		region = "us-east-1"
+	s, err := newS3Store(cfg)
+	if err != nil {
+		return nil, err
 	}
-	creds := credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")
-	client := s3.NewFromConfig(aws.Config{
-		Region:      region,
-		Credentials: aws.NewCredentialsCache(creds),
-	}, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(cfg.Endpoint)
-		o.UsePathStyle = true
-	})
-	return &O3Client{client: client, bucket: cfg.Bucket}, nil
+	return NewClient(s), nil
 }
 
-// EnsureBucket creates the bucket if it does not exist (HeadBucket fails → CreateBucket).
+// EnsureBucket creates the bucket if it does not exist.
 func (c *O3Client) EnsureBucket(ctx context.Context) error {
 	if c == nil {
 		return nil
 	}
-	_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(c.bucket)})
-	if err == nil {
-		return nil
-	}
-	// HeadBucket failed (404 NoSuchBucket or similar); try to create.
-	_, createErr := c.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(c.bucket)})
-	if createErr != nil {
-		var apiErr smithy.APIError
-		if errors.As(createErr, &apiErr) {
-			switch apiErr.ErrorCode() {
-			case "BucketAlreadyOwnedByYou", "BucketAlreadyExists":
-				return nil
-			}
-		}
-		return createErr
+	return c.store.EnsureBucket(ctx)
+}
+
+// HeadBucket checks that the bucket exists and the credentials may access it.
+func (c *O3Client) HeadBucket(ctx context.Context) error {
+	if c == nil {
+		return fmt.Errorf("o3 client not configured")
 	}
-	return nil
+	return c.store.HeadBucket(ctx)
 }
 
 // PutObject uploads data to key. Key can include prefixes (e.g. "project/default/2024/01/15/batch-abc.json.gz").
 func (c *O3Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	return c.PutObjectWithMetadata(ctx, key, data, contentType, nil)
+}
+
+// PutObjectWithMetadata is PutObject that also stores metadata (x-amz-meta-* headers) with the object.
+// Every object is sent with its SHA-256 in x-amz-checksum-sha256, so O3 rejects it if it arrives
+// damaged, and its SHA-256 and CRC32C are kept in its metadata and in the manifest, if any.
+func (c *O3Client) PutObjectWithMetadata(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error {
+	_, err := c.PutObjectCID(ctx, key, data, contentType, metadata)
+	return err
+}
+
+// PutObjectCID is PutObjectWithMetadata that also returns the root CID the backend gave the
+// object, when it is content-addressed (Akave); "" otherwise. The CID is kept in the manifest.
+func (c *O3Client) PutObjectCID(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("o3 client not configured")
+	}
+	sums := ComputeChecksums(data)
+	meta := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[MetaSHA256], meta[MetaCRC32C] = sums.SHA256, sums.CRC32C
+	cid, err := c.store.Put(ctx, key, bytes.NewReader(data), contentType, meta)
+	if err != nil {
+		return "", err
+	}
+	c.recordUpload(key, len(data), sums, cid)
+	return cid, nil
+}
+
+// ObjectInfo describes one stored object.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	CID          string    `json:"cid,omitempty"` // root CID on content-addressed backends (Akave)
+}
+
+// ListObjects returns every object under prefix, following continuation tokens across pages.
+func (c *O3Client) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if c == nil {
+		return nil, fmt.Errorf("o3 client not configured")
+	}
+	return c.store.List(ctx, prefix)
+}
+
+// ListObjectsAfter returns the objects under prefix whose keys sort after startAfter. Backends
+// that can start a listing at a key (S3, O3) skip the earlier keys on the server; the others
+// list the whole prefix.
+func (c *O3Client) ListObjectsAfter(ctx context.Context, prefix, startAfter string) ([]ObjectInfo, error) {
+	if c == nil {
+		return nil, fmt.Errorf("o3 client not configured")
+	}
+	if l, ok := c.store.(interface {
+		listAfter(ctx context.Context, prefix, startAfter string) ([]ObjectInfo, error)
+	}); ok {
+		return l.listAfter(ctx, prefix, startAfter)
+	}
+	objects, err := c.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := objects[:0]
+	for _, o := range objects {
+		if o.Key > startAfter {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+// StatObject returns the size and modification time of the object at key without
+// downloading it.
+func (c *O3Client) StatObject(ctx context.Context, key string) (ObjectInfo, error) {
+	if c == nil {
+		return ObjectInfo{}, fmt.Errorf("o3 client not configured")
+	}
+	return c.store.Stat(ctx, key)
+}
+
+// GetObject downloads the object at key.
+func (c *O3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("o3 client not configured")
+	}
+	data, _, err := c.store.Get(ctx, key)
+	return data, err
+}
+
+// DeleteObject removes the object at key. Deleting a missing key is not an error.
+func (c *O3Client) DeleteObject(ctx context.Context, key string) error {
 	if c == nil {
 		return fmt.Errorf("o3 client not configured")
 	}
-	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(c.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String(contentType),
-	})
-	return err
+	return c.store.Delete(ctx, key)
+}
+
+// CopyObject copies the object at src to dst within the bucket, keeping its metadata.
+func (c *O3Client) CopyObject(ctx context.Context, src, dst string) error {
+	if c == nil {
+		return fmt.Errorf("o3 client not configured")
+	}
+	return c.store.Copy(ctx, src, dst)
+}
+
+// IsNotFound reports whether err means the requested object does not exist.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
 }
 
+// DefaultPrefix holds the batch objects of entries not stored under a stream's o3_prefix.
+const DefaultPrefix = "logs"
+
 // KeyForBatch returns an object key for a log batch (e.g. logs/default/2024/02/17/abc123.json.gz).
 func KeyForBatch(projectID string, batchID string, ext string) string {
+	return KeyForBatchUnder(DefaultPrefix, projectID, batchID, ext)
+}
+
+// KeyForBatchUnder is KeyForBatch with another top-level prefix than logs/, such as a
+// stream's o3_prefix (e.g. audit/default/2024/02/17/abc123.json.gz).
+func KeyForBatchUnder(prefix, projectID, batchID, ext string) string {
 	if projectID == "" {
 		projectID = "default"
 	}
 	now := time.Now().UTC()
-	return path.Join("logs", projectID, now.Format("2006/01/02"), batchID+ext)
+	return path.Join(prefix, projectID, now.Format("2006/01/02"), batchID+ext)
+}
+
+// KeyDate returns the UTC day in a batch key made by KeyForBatchUnder, the day its object was
+// uploaded (or, for compacted objects, the day of the entries it holds).
+func KeyDate(key string) (time.Time, bool) {
+	dir := path.Dir(key)
+	parts := strings.Split(dir, "/")
+	if len(parts) < 3 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006/01/02", strings.Join(parts[len(parts)-3:], "/"))
+	return t, err == nil
+}
+
+// PutObjectFile uploads the contents of f to key, streaming it rather than reading it into
+// memory. f is read from its start.
+func (c *O3Client) PutObjectFile(ctx context.Context, key string, f *os.File, contentType string) error {
+	if c == nil {
+		return fmt.Errorf("o3 client not configured")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := c.store.Put(ctx, key, f, contentType, nil)
+	return err
+}
+
+// PresignGet returns a URL that downloads the object at key, as an attachment named after the
+// last element of the key, without credentials until expires has passed.
+func (c *O3Client) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("o3 client not configured")
+	}
+	return c.store.PresignGet(ctx, key, path.Base(key), expires)
 }
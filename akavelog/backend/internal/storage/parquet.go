@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/parquet"
+)
+
+// tagColumnPrefix starts the name of the Parquet column holding a tag, e.g. tag_env.
+const tagColumnPrefix = "tag_"
+
+// EncodeParquet encodes entries as a Parquet file with one column per LogEntry field and one
+// tag_<key> column per tag key found in entries. timestamp is a UTC TIMESTAMP_MICROS (null when
+// it does not parse), raw_request the request as JSON, and absent values are null.
+func EncodeParquet(entries []model.LogEntry) ([]byte, error) {
+	n := len(entries)
+	str := func(name string) parquet.Column {
+		return parquet.Column{Name: name, Kind: parquet.String, Null: make([]bool, n), Strings: make([]string, n)}
+	}
+	ts := parquet.Column{Name: "timestamp", Kind: parquet.Timestamp, Null: make([]bool, n), Times: make([]int64, n)}
+	service, level, message := str("service"), str("level"), str("message")
+	project, raw, id := str("project_id"), str("raw_request"), str("_id")
+
+	tagIndex := make(map[string]int)
+	var tags []parquet.Column
+	for i := range entries {
+		for k := range entries[i].Tags {
+			if _, ok := tagIndex[k]; !ok {
+				tagIndex[k] = len(tags)
+				tags = append(tags, str(tagColumnPrefix+k))
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	for i := range tags {
+		tagIndex[strings.TrimPrefix(tags[i].Name, tagColumnPrefix)] = i
+		for row := range tags[i].Null {
+			tags[i].Null[row] = true
+		}
+	}
+
+	for row := range entries {
+		e := &entries[row]
+		if t, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+			ts.Times[row] = t.UnixMicro()
+		} else {
+			ts.Null[row] = true
+		}
+		service.Strings[row] = e.Service
+		level.Strings[row] = e.Level
+		message.Strings[row] = e.Message
+		project.Strings[row], project.Null[row] = e.ProjectID, e.ProjectID == ""
+		id.Strings[row], id.Null[row] = e.ID, e.ID == ""
+		raw.Null[row] = e.RawRequest == nil
+		if e.RawRequest != nil {
+			b, err := json.Marshal(e.RawRequest)
+			if err != nil {
+				return nil, fmt.Errorf("marshal raw_request: %w", err)
+			}
+			raw.Strings[row] = string(b)
+		}
+		for k, v := range e.Tags {
+			c := &tags[tagIndex[k]]
+			c.Strings[row], c.Null[row] = v, false
+		}
+	}
+	cols := append([]parquet.Column{ts, service, level, message, project, raw, id}, tags...)
+	return parquet.Write(cols, n)
+}
+
+// decodeParquet reads back the entries of a file written by EncodeParquet.
+func decodeParquet(data []byte) ([]model.LogEntry, error) {
+	cols, rows, err := parquet.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]model.LogEntry, rows)
+	for _, c := range cols {
+		for row := range rows {
+			if c.Null[row] {
+				continue
+			}
+			e := &entries[row]
+			if c.Kind == parquet.Timestamp {
+				if c.Name == "timestamp" {
+					e.Timestamp = time.UnixMicro(c.Times[row]).UTC().Format(time.RFC3339Nano)
+				}
+				continue
+			}
+			v := c.Strings[row]
+			switch c.Name {
+			case "service":
+				e.Service = v
+			case "level":
+				e.Level = v
+			case "message":
+				e.Message = v
+			case "project_id":
+				e.ProjectID = v
+			case "_id":
+				e.ID = v
+			case "raw_request":
+				var req model.RawRequestData
+				if err := json.Unmarshal([]byte(v), &req); err != nil {
+					return nil, fmt.Errorf("decode raw_request: %w", err)
+				}
+				e.RawRequest = &req
+			default:
+				if k, ok := strings.CutPrefix(c.Name, tagColumnPrefix); ok {
+					if e.Tags == nil {
+						e.Tags = make(map[string]string)
+					}
+					e.Tags[k] = v
+				}
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+// restClient sends the requests of the backends that speak a REST API over net/http (GCS and
+// Azure), through a circuit breaker as O3's are, retrying transport errors, throttling and
+// 5xx responses with exponential backoff and jitter.
+type restClient struct {
+	client  breakerClient
+	breaker *breaker
+	// sign authorizes each attempt of a request that is not anonymous.
+	sign func(r *http.Request) error
+}
+
+// restRequest is one request of a restClient.
+type restRequest struct {
+	method    string
+	url       string
+	header    http.Header
+	body      io.ReadSeeker // nil for none; read from its start on every attempt
+	anonymous bool          // not signed, e.g. a token request
+}
+
+// statusError is a response with a non-2xx status. A 404 matches ErrNotFound.
+type statusError struct {
+	code    int
+	status  string
+	message string
+}
+
+func (e *statusError) Error() string {
+	if e.message == "" {
+		return e.status
+	}
+	return e.status + ": " + e.message
+}
+
+func (e *statusError) Is(target error) bool {
+	return target == ErrNotFound && e.code == http.StatusNotFound
+}
+
+// hasStatus reports whether err is a response with status code.
+func hasStatus(err error, code int) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == code
+}
+
+func newRestClient(sign func(r *http.Request) error) (*restClient, error) {
+	client, br, err := newHTTPClient(&config.O3Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &restClient{client: client, breaker: br, sign: sign}, nil
+}
+
+// do sends req and returns its 2xx response, whose body the caller closes. Other responses
+// are returned as a *statusError.
+func (c *restClient) do(ctx context.Context, req restRequest) (*http.Response, error) {
+	var size int64
+	if req.body != nil {
+		var err error
+		if size, err = req.body.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, size)
+		if err == nil {
+			return resp, nil
+		}
+		var se *statusError
+		retryable := !errors.Is(err, ErrCircuitOpen) &&
+			(!errors.As(err, &se) || se.code >= 500 || se.code == http.StatusTooManyRequests)
+		if !retryable || attempt >= defaultMaxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+		backoff := min(100*time.Millisecond<<attempt, defaultMaxBackoff)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff/2 + rand.N(backoff/2)):
+		}
+	}
+}
+
+func (c *restClient) send(ctx context.Context, req restRequest, size int64) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(ctx, req.method, req.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.header != nil {
+		r.Header = req.header.Clone()
+	}
+	if req.body != nil {
+		if _, err := req.body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r.Body, r.ContentLength = io.NopCloser(req.body), size
+		if size == 0 {
+			r.Body = http.NoBody
+		}
+	}
+	if !req.anonymous && c.sign != nil {
+		if err := c.sign(r); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, &statusError{code: resp.StatusCode, status: resp.Status, message: strings.TrimSpace(string(msg))}
+}
+
+// discard sends req and closes the body of its response.
+func (c *restClient) discard(ctx context.Context, req restRequest) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// escapeKey escapes each segment of an object key for a URL path.
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = pathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// pathEscape escapes s as RFC 3986 requires, leaving only unreserved characters, as the
+// canonical requests of signed URLs expect.
+func pathEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte("-._~", ch) >= 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// metaFromHeader returns the metadata in the headers of h starting with prefix (e.g.
+// x-goog-meta-), with lowercase names.
+func metaFromHeader(h http.Header, prefix string) map[string]string {
+	var meta map[string]string
+	for name, v := range h {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, prefix) && len(v) > 0 {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			meta[strings.TrimPrefix(lower, prefix)] = v[0]
+		}
+	}
+	return meta
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+// fakeBucket is an in-memory bucket for the fake GCS and Azure servers.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]http.Header
+}
+
+func (f *fakeBucket) put(key string, r *http.Request, prefix string) {
+	data, _ := io.ReadAll(r.Body)
+	meta := http.Header{}
+	for name, v := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			meta[name] = v
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key], f.meta[key] = data, meta
+}
+
+func (f *fakeBucket) get(w http.ResponseWriter, key string) {
+	f.mu.Lock()
+	data, ok := f.objects[key]
+	meta := f.meta[key]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, nil)
+		return
+	}
+	for name, v := range meta {
+		w.Header()[name] = v
+	}
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	w.Write(data)
+}
+
+func (f *fakeBucket) keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestGCSStore(t *testing.T) {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := &fakeBucket{objects: map[string][]byte{}, meta: map[string]http.Header{}}
+	var tokens atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[len(parts)-1])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if len(parts) != 3 || rsa.VerifyPKCS1v15(&pk.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+				http.Error(w, "bad assertion", http.StatusBadRequest)
+				return
+			}
+			tokens.Add(1)
+			fmt.Fprint(w, `{"access_token":"tok","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/storage/v1/b/logs/o" {
+			var out gcsList
+			prefix := r.URL.Query().Get("prefix")
+			for _, k := range bucket.keys(prefix) {
+				if r.URL.Query().Get("delimiter") == "" {
+					out.Items = append(out.Items, struct {
+						Name    string    `json:"name"`
+						Size    string    `json:"size"`
+						Updated time.Time `json:"updated"`
+					}{Name: k, Size: "5", Updated: time.Now()})
+				} else if dir, _, ok := strings.Cut(strings.TrimPrefix(k, prefix), "/"); ok {
+					out.Prefixes = append(out.Prefixes, prefix+dir+"/")
+				}
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/logs/")
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("X-Goog-Hash") == "" {
+				http.Error(w, "no x-goog-hash", http.StatusBadRequest)
+				return
+			}
+			bucket.put(key, r, gcsMetaPrefix)
+		case http.MethodGet, http.MethodHead:
+			bucket.get(w, key)
+		}
+	}))
+	defer srv.Close()
+
+	raw, _ := x509.MarshalPKCS8PrivateKey(pk)
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	sa, _ := json.Marshal(map[string]string{
+		"client_email": "akavelog@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: raw})),
+		"token_uri":    srv.URL + "/token",
+		"project_id":   "demo",
+	})
+	os.WriteFile(keyFile, sa, 0o600)
+	c, err := New(&config.StorageConfig{Backend: BackendGCS, GCS: &config.GCSConfig{Bucket: "logs", CredentialsFile: keyFile, Endpoint: srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "logs/p1/2024/02/17/a b.json"
+	if err := c.PutObjectWithMetadata(ctx, key, []byte("batch"), "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Verify(ctx, key); err != nil || !v.OK {
+		t.Fatalf("Verify = %+v, %v", v, err)
+	}
+	if _, err := c.GetObject(ctx, "logs/missing"); !IsNotFound(err) {
+		t.Errorf("GetObject of a missing key: %v", err)
+	}
+	if dirs, err := c.listDirs(ctx, "logs/"); err != nil || len(dirs) != 1 || dirs[0] != "p1" {
+		t.Errorf("listDirs = %v, %v", dirs, err)
+	}
+	if objects, err := c.ListObjects(ctx, "logs/p1/"); err != nil || len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("ListObjects = %+v, %v", objects, err)
+	}
+	if n := tokens.Load(); n != 1 {
+		t.Errorf("fetched %d tokens, want the first one cached", n)
+	}
+
+	u, err := c.PresignGet(ctx, key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(u)
+	q := parsed.Query()
+	if parsed.EscapedPath() != "/logs/logs/p1/2024/02/17/a%20b.json" || q.Get("X-Goog-Expires") != "3600" ||
+		!strings.HasPrefix(q.Get("X-Goog-Credential"), "akavelog@example.iam.gserviceaccount.com/") || q.Get("X-Goog-Signature") == "" {
+		t.Errorf("PresignGet = %s", u)
+	}
+}
+
+func TestAzureStore(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string][]byte{}, meta: map[string]http.Header{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey acct:") || r.Header.Get("X-Ms-Date") == "" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("comp") == "list" {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+			prefix := q.Get("prefix")
+			for _, k := range bucket.keys(prefix) {
+				if q.Get("delimiter") == "" {
+					fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>5</Content-Length></Properties></Blob>`,
+						k, time.Now().UTC().Format(http.TimeFormat))
+				} else if dir, _, ok := strings.Cut(strings.TrimPrefix(k, prefix), "/"); ok {
+					fmt.Fprintf(w, `<BlobPrefix><Name>%s%s/</Name></BlobPrefix>`, prefix, dir)
+				}
+			}
+			fmt.Fprint(w, `</Blobs><NextMarker/></EnumerationResults>`)
+			return
+		}
+		if q.Get("restype") == "container" {
+			w.WriteHeader(http.StatusConflict) // ContainerAlreadyExists
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/logs/")
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" || r.Header.Get("Content-Md5") == "" {
+				http.Error(w, "bad put", http.StatusBadRequest)
+				return
+			}
+			bucket.put(key, r, azureMetaPrefix)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodHead:
+			bucket.get(w, key)
+		case http.MethodDelete:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(&config.StorageConfig{Backend: BackendAzure, Azure: &config.AzureConfig{
+		Account: "acct", Key: base64.StdEncoding.EncodeToString([]byte("secret")), Container: "logs", Endpoint: srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.EnsureBucket(ctx); err != nil {
+		t.Errorf("EnsureBucket of an existing container: %v", err)
+	}
+	key := "logs/p1/2024/02/17/a.json"
+	if err := c.PutObjectWithMetadata(ctx, key, []byte("batch"), "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CopyObject(ctx, key, "archive/"+key); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Verify(ctx, "archive/"+key); err != nil || !v.OK {
+		t.Fatalf("Verify of the copy = %+v, %v", v, err)
+	}
+	if dirs, err := c.listDirs(ctx, "logs/"); err != nil || len(dirs) != 1 || dirs[0] != "p1" {
+		t.Errorf("listDirs = %v, %v", dirs, err)
+	}
+	if objects, err := c.ListObjects(ctx, "logs/"); err != nil || len(objects) != 1 || objects[0].Size != 5 {
+		t.Errorf("ListObjects = %+v, %v", objects, err)
+	}
+	if err := c.DeleteObject(ctx, "logs/missing"); err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+	u, err := c.PresignGet(ctx, key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(u)
+	if q := parsed.Query(); q.Get("sr") != "b" || q.Get("sp") != "r" || q.Get("sig") == "" {
+		t.Errorf("PresignGet = %s", u)
+	}
+}
+
+func TestAzureCanonicalResource(t *testing.T) {
+	s := &AzureStore{account: "acct"}
+	u, _ := url.Parse("https://acct.blob.core.windows.net/logs?restype=container&comp=list&prefix=a%2Fb%2F")
+	want := "/acct/logs\ncomp:list\nprefix:a/b/\nrestype:container"
+	if got := s.canonicalResource(u); got != want {
+		t.Errorf("canonicalResource = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// Defaults of the HTTP client tuning of O3Config.
+const (
+	defaultMaxIdleConns   = 100
+	defaultDialTimeout    = 5 * time.Second
+	defaultRequestTimeout = 60 * time.Second
+	defaultMaxRetries     = 2
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// s3Store is the ObjectStore of Akave O3 and other S3-compatible endpoints.
+type s3Store struct {
+	client  *s3.Client
+	bucket  string
+	breaker *breaker
+}
+
+// newS3Store builds the S3 client of cfg. Requests go through a circuit breaker (see
+// CircuitStats); invalid durations are logged and replaced by their defaults.
+func newS3Store(cfg *config.O3Config) (*s3Store, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	httpClient, br, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	creds := credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")
+	client := s3.NewFromConfig(aws.Config{
+		Region:      region,
+		Credentials: aws.NewCredentialsCache(creds),
+		HTTPClient:  httpClient,
+		Retryer:     newRetryer(cfg),
+	}, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(cfg.Endpoint)
+		o.UsePathStyle = true
+	})
+	return &s3Store{client: client, bucket: cfg.Bucket, breaker: br}, nil
+}
+
+// newHTTPClient returns the HTTP client of the tuning in cfg, sending requests through a
+// circuit breaker, and the breaker. The backends other than O3 use it with the defaults.
+func newHTTPClient(cfg *config.O3Config) (breakerClient, *breaker, error) {
+	var roots *x509.CertPool
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return breakerClient{}, nil, fmt.Errorf("ca_bundle: %w", err)
+		}
+		if roots, err = x509.SystemCertPool(); err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return breakerClient{}, nil, fmt.Errorf("ca_bundle %s: no PEM certificates", cfg.CABundle)
+		}
+	}
+	idle := cfg.MaxIdleConns
+	if idle <= 0 {
+		idle = defaultMaxIdleConns
+	}
+	dial := o3Duration("dial_timeout", cfg.DialTimeout, defaultDialTimeout)
+	httpClient := awshttp.NewBuildableClient().
+		WithTimeout(o3Duration("request_timeout", cfg.RequestTimeout, defaultRequestTimeout)).
+		WithDialerOptions(func(d *net.Dialer) { d.Timeout = dial }).
+		WithTransportOptions(func(t *http.Transport) {
+			t.MaxIdleConns, t.MaxIdleConnsPerHost = idle, idle
+			if roots != nil {
+				tc := t.TLSClientConfig.Clone()
+				if tc == nil {
+					tc = &tls.Config{MinVersion: tls.VersionTLS12}
+				}
+				tc.RootCAs = roots
+				t.TLSClientConfig = tc
+			}
+		})
+	br := newBreaker(cfg.BreakerFailures, o3Duration("breaker_cooldown", cfg.BreakerCooldown, defaultBreakerCooldown))
+	return breakerClient{next: httpClient, b: br}, br, nil
+}
+
+// newRetryer returns the SDK retryer of cfg: MaxRetries attempts after the first, with
+// exponential backoff and jitter up to MaxBackoff. In adaptive mode the client also slows
+// down while O3 throttles it. ErrCircuitOpen is never retried.
+func newRetryer(cfg *config.O3Config) func() aws.Retryer {
+	retries := cfg.MaxRetries
+	if retries <= 0 {
+		retries = defaultMaxRetries
+	}
+	backoff := o3Duration("max_backoff", cfg.MaxBackoff, defaultMaxBackoff)
+	standard := func(o *retry.StandardOptions) {
+		o.MaxAttempts = retries + 1
+		o.MaxBackoff = backoff
+		o.Retryables = append([]retry.IsErrorRetryable{retry.IsErrorRetryableFunc(func(err error) aws.Ternary {
+			if errors.Is(err, ErrCircuitOpen) {
+				return aws.FalseTernary
+			}
+			return aws.UnknownTernary
+		})}, o.Retryables...)
+	}
+	mode := strings.ToLower(strings.TrimSpace(cfg.RetryMode))
+	switch mode {
+	case "", "adaptive":
+	case "standard":
+	default:
+		log.Printf("[o3] invalid retry_mode %q (using adaptive)", cfg.RetryMode)
+		mode = "adaptive"
+	}
+	return func() aws.Retryer {
+		if mode == "standard" {
+			return retry.NewStandard(standard)
+		}
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
+		})
+	}
+}
+
+// o3Duration parses the duration setting name, or returns def when it is empty or invalid.
+func o3Duration(name, v string, def time.Duration) time.Duration {
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("[o3] invalid %s %q (using %s)", name, v, def)
+		return def
+	}
+	return d
+}
+
+func (s *s3Store) Backend() string { return BackendO3 }
+
+func (s *s3Store) circuitBreaker() *breaker { return s.breaker }
+
+// EnsureBucket creates the bucket if it does not exist (HeadBucket fails → CreateBucket).
+func (s *s3Store) EnsureBucket(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if err == nil {
+		return nil
+	}
+	// HeadBucket failed (404 NoSuchBucket or similar); try to create.
+	_, createErr := s.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(s.bucket)})
+	if createErr != nil {
+		var apiErr smithy.APIError
+		if errors.As(createErr, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "BucketAlreadyOwnedByYou", "BucketAlreadyExists":
+				return nil
+			}
+		}
+		return createErr
+	}
+	return nil
+}
+
+func (s *s3Store) HeadBucket(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+// Put sends the object's SHA-256, when metadata holds it, in x-amz-checksum-sha256, so O3
+// rejects the object if it arrives damaged.
+func (s *s3Store) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, metadata map[string]string) (string, error) {
+	in := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if len(metadata) > 0 {
+		in.Metadata = metadata
+	}
+	if sum := metadata[MetaSHA256]; sum != "" {
+		in.ChecksumSHA256 = aws.String(sum)
+	}
+	_, err := s.client.PutObject(ctx, in)
+	return "", err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, out.Metadata, nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength)}
+	if out.LastModified != nil {
+		info.LastModified = out.LastModified.UTC()
+	}
+	return info, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *s3Store) Copy(ctx context.Context, src, dst string) error {
+	source := strings.ReplaceAll(url.PathEscape(s.bucket+"/"+src), "%2F", "/")
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(source),
+	})
+	return err
+}
+
+// List follows continuation tokens across pages.
+func (s *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return s.listAfter(ctx, prefix, "")
+}
+
+// listAfter is List of the keys after startAfter (StartAfter of ListObjectsV2).
+func (s *s3Store) listAfter(ctx context.Context, prefix, startAfter string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if startAfter != "" {
+		in.StartAfter = aws.String(startAfter)
+	}
+	p := s3.NewListObjectsV2Paginator(s.client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{Key: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size)}
+			if obj.LastModified != nil {
+				info.LastModified = obj.LastModified.UTC()
+			}
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+func (s *s3Store) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	var dirs []string
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, cp := range page.CommonPrefixes {
+			if dir := strings.Trim(strings.TrimPrefix(aws.ToString(cp.Prefix), prefix), "/"); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+func (s *s3Store) PresignGet(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", filename)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
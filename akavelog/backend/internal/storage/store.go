@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/config"
+)
+
+// ErrNotFound is returned by the backends other than O3 for a missing object or bucket.
+// Check errors with IsNotFound, which also knows the S3 error codes.
+var ErrNotFound = errors.New("not found")
+
+// ErrObjectExists is returned by backends whose objects are immutable (Akave) for a Put to a
+// key that holds other content.
+var ErrObjectExists = errors.New("object exists with other content")
+
+// Storage backends (storage.backend).
+const (
+	BackendO3    = "o3"    // Akave O3 or any S3-compatible endpoint (default)
+	BackendFS    = "fs"    // a local directory, for development and tests
+	BackendGCS   = "gcs"   // Google Cloud Storage
+	BackendAzure = "azure" // Azure Blob Storage
+	BackendAkave = "akave" // the Akave network through the native API of an Akave Link node
+)
+
+// ObjectStore is a bucket of objects: what a storage backend does. O3Client builds the rest
+// on it (checksums, the manifest, batch codecs, listing pages, storage targets), so the
+// batcher, search and every other consumer work the same on each backend.
+type ObjectStore interface {
+	// Backend returns the backend name, e.g. BackendFS.
+	Backend() string
+	// HeadBucket checks that the bucket exists and may be accessed.
+	HeadBucket(ctx context.Context) error
+	// EnsureBucket creates the bucket if it does not exist.
+	EnsureBucket(ctx context.Context) error
+	// Put stores body at key with metadata and returns the object's content identifier: its
+	// root CID on content-addressed backends (Akave), "" on the others. When metadata holds
+	// MetaSHA256 or MetaCRC32C, backends that can have the object checked on arrival do so.
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string, metadata map[string]string) (string, error)
+	// Get downloads the object at key, with its metadata.
+	Get(ctx context.Context, key string) ([]byte, map[string]string, error)
+	// Stat returns the size and modification time of the object at key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes the object at key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Copy copies the object at src to dst, keeping its metadata.
+	Copy(ctx context.Context, src, dst string) error
+	// List returns every object under prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// ListDirs returns the names of the "directories" right under prefix, which ends in '/'.
+	ListDirs(ctx context.Context, prefix string) ([]string, error)
+	// PresignGet returns a URL that downloads the object at key as an attachment named
+	// filename until expires has passed.
+	PresignGet(ctx context.Context, key, filename string, expires time.Duration) (string, error)
+}
+
+// New builds the client of the backend cfg selects (storage.backend, default o3). Returns
+// nil if cfg is nil or the selected backend is not configured.
+func New(cfg *config.StorageConfig) (*O3Client, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	var (
+		store ObjectStore
+		err   error
+	)
+	switch backend := strings.ToLower(strings.TrimSpace(cfg.Backend)); backend {
+	case "", BackendO3:
+		return NewO3Client(cfg.O3)
+	case BackendFS:
+		if cfg.FS == nil || cfg.FS.Dir == "" {
+			return nil, nil
+		}
+		store, err = NewFSStore(cfg.FS.Dir)
+	case BackendGCS:
+		if cfg.GCS == nil || cfg.GCS.Bucket == "" {
+			return nil, nil
+		}
+		store, err = NewGCSStore(cfg.GCS)
+	case BackendAzure:
+		if cfg.Azure == nil || cfg.Azure.Container == "" {
+			return nil, nil
+		}
+		store, err = NewAzureStore(cfg.Azure)
+	case BackendAkave:
+		if cfg.Akave == nil || cfg.Akave.Bucket == "" {
+			return nil, nil
+		}
+		store, err = NewAkaveStore(cfg.Akave)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (o3, fs, gcs, azure or akave)", cfg.Backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Backend, err)
+	}
+	return NewClient(store), nil
+}
+
+// NewClient returns a client storing objects in store. Tests use it with an FSStore in a
+// temporary directory, so they need no bucket.
+func NewClient(store ObjectStore) *O3Client {
+	c := &O3Client{store: store}
+	if h, ok := store.(interface{ circuitBreaker() *breaker }); ok {
+		c.breaker = h.circuitBreaker()
+	}
+	return c
+}
+
+// Backend returns the name of the backend c stores objects in.
+func (c *O3Client) Backend() string {
+	if c == nil {
+		return ""
+	}
+	return c.store.Backend()
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/akave-ai/akavelog/internal/config"
+	"github.com/akave-ai/akavelog/internal/model"
+)
+
+// validTargetName is the form of a storage target name, as streams and projects refer to it.
+var validTargetName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Targets builds a client for each named target of cfg (storage.targets), whose unset fields
+// are taken from def. Batches of the streams and projects that name a target are stored with
+// its client, so their data is kept apart: in another bucket, or with other credentials.
+func Targets(def *config.O3Config, targets map[string]*config.O3Config) (map[string]*O3Client, error) {
+	out := make(map[string]*O3Client, len(targets))
+	for name, t := range targets {
+		if !validTargetName.MatchString(name) {
+			return nil, fmt.Errorf("storage target %q: names are lowercase letters, digits, '_' and '-'", name)
+		}
+		cfg := mergeO3Config(def, t)
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("storage target %s: endpoint and bucket are required", name)
+		}
+		c, err := NewO3Client(&cfg)
+		if err != nil {
+			return nil, fmt.Errorf("storage target %s: %w", name, err)
+		}
+		out[name] = c
+	}
+	return out, nil
+}
+
+// mergeO3Config returns t with the fields it leaves empty taken from def. The manifest is
+// shared, so it is never taken from t.
+func mergeO3Config(def, t *config.O3Config) config.O3Config {
+	var cfg config.O3Config
+	if t != nil {
+		cfg = *t
+	}
+	if def == nil {
+		return cfg
+	}
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&cfg.Endpoint, def.Endpoint},
+		{&cfg.Bucket, def.Bucket},
+		{&cfg.Region, def.Region},
+		{&cfg.AccessKey, def.AccessKey},
+		{&cfg.SecretKey, def.SecretKey},
+		{&cfg.DialTimeout, def.DialTimeout},
+		{&cfg.RequestTimeout, def.RequestTimeout},
+		{&cfg.RetryMode, def.RetryMode},
+		{&cfg.MaxBackoff, def.MaxBackoff},
+		{&cfg.CABundle, def.CABundle},
+		{&cfg.BreakerCooldown, def.BreakerCooldown},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	for _, f := range []struct {
+		dst *int
+		src int
+	}{
+		{&cfg.MaxIdleConns, def.MaxIdleConns},
+		{&cfg.MaxRetries, def.MaxRetries},
+		{&cfg.BreakerFailures, def.BreakerFailures},
+	} {
+		if *f.dst == 0 {
+			*f.dst = f.src
+		}
+	}
+	cfg.Manifest = ""
+	return cfg
+}
+
+// SetTargets sets the clients of the named storage targets c reads their batches with (see
+// Target). It is meant to be called once, before c is used.
+func (c *O3Client) SetTargets(targets map[string]*O3Client) {
+	if c != nil {
+		c.targets = targets
+	}
+}
+
+// Target returns the client of the storage target name: c itself for "", nil for a target
+// that is not configured.
+func (c *O3Client) Target(name string) *O3Client {
+	if name == "" || c == nil {
+		return c
+	}
+	return c.targets[name]
+}
+
+// TargetNames returns the names of the configured storage targets, sorted.
+func (c *O3Client) TargetNames() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.targets))
+	for name := range c.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetBatchLogs downloads the batch object b and decodes its entries, from the storage target
+// it was uploaded to.
+func (c *O3Client) GetBatchLogs(ctx context.Context, b model.Batch) ([]model.LogEntry, error) {
+	t := c.Target(b.Target)
+	if t == nil {
+		return nil, fmt.Errorf("storage target %q is not configured", b.Target)
+	}
+	return t.GetObjectLogs(ctx, b.Key)
+}
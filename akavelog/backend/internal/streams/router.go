@@ -0,0 +1,179 @@
+// Package streams routes entries into named streams by rule expressions and keeps the
+// per-stream settings (retention, O3 prefix, output targets) for the stages after routing.
+package streams
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/akave-ai/akavelog/internal/rules"
+	"github.com/google/uuid"
+)
+
+// compiled is a loaded stream with its parsed rules.
+type compiled struct {
+	stream  model.Stream
+	id      string
+	rules   []*rules.Expr
+	matched *atomic.Int64
+}
+
+func (c *compiled) match(get rules.Getter) bool {
+	if len(c.rules) == 0 {
+		return false
+	}
+	for _, r := range c.rules {
+		ok := r.Match(get)
+		if ok && c.stream.MatchType == model.StreamMatchAny {
+			return true
+		}
+		if !ok && c.stream.MatchType != model.StreamMatchAny {
+			return false
+		}
+	}
+	return c.stream.MatchType != model.StreamMatchAny
+}
+
+type snapshot struct {
+	list []*compiled // in load order
+	byID map[string]*compiled
+}
+
+// Router holds the enabled streams. Load swaps them atomically, like pipeline.Manager, so
+// Route never sees a half-updated set. It implements pipeline.StreamRouter.
+type Router struct {
+	current atomic.Pointer[snapshot]
+}
+
+// NewRouter returns a Router without streams.
+func NewRouter() *Router {
+	r := &Router{}
+	r.current.Store(&snapshot{byID: map[string]*compiled{}})
+	return r
+}
+
+// Validate parses the rules of s. Errors name the offending rule.
+func Validate(s model.Stream) error {
+	_, err := compile(s)
+	return err
+}
+
+func compile(s model.Stream) ([]*rules.Expr, error) {
+	out := make([]*rules.Expr, 0, len(s.Rules))
+	for i, src := range s.Rules {
+		expr, err := rules.Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		out = append(out, expr)
+	}
+	return out, nil
+}
+
+// Load replaces the routed streams with the enabled ones in list, matched in list order.
+// Match counters carry over for streams that stay loaded. A stream whose rules do not parse is
+// skipped and its error returned.
+func (r *Router) Load(list []model.Stream) error {
+	prev := r.current.Load()
+	next := &snapshot{byID: make(map[string]*compiled, len(list))}
+	var errs []error
+	for _, s := range list {
+		if !s.Enabled {
+			continue
+		}
+		exprs, err := compile(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stream %q: %w", s.Name, err))
+			continue
+		}
+		c := &compiled{stream: s, id: s.ID.String(), rules: exprs, matched: new(atomic.Int64)}
+		if old, ok := prev.byID[c.id]; ok {
+			c.matched = old.matched
+		}
+		next.list = append(next.list, c)
+		next.byID[c.id] = c
+	}
+	r.current.Store(next)
+	return errors.Join(errs...)
+}
+
+// Route returns the IDs of the streams e matches, in load order. A stream without rules
+// matches nothing.
+func (r *Router) Route(e *model.LogEntry) []string {
+	get := pipeline.EntryGetter(e)
+	var ids []string
+	for _, c := range r.current.Load().list {
+		if c.match(get) {
+			c.matched.Add(1)
+			ids = append(ids, c.id)
+		}
+	}
+	return ids
+}
+
+// Matched returns how many entries the stream has matched since it was first loaded. ok is
+// false when the stream is not loaded (unknown, disabled or invalid).
+func (r *Router) Matched(id uuid.UUID) (n int64, ok bool) {
+	c, ok := r.current.Load().byID[id.String()]
+	if !ok {
+		return 0, false
+	}
+	return c.matched.Load(), true
+}
+
+// Streams returns the loaded streams an entry was routed into, from its TagStreams tag.
+func (r *Router) Streams(e *model.LogEntry) []model.Stream {
+	tag := e.Tags[pipeline.TagStreams]
+	if tag == "" {
+		return nil
+	}
+	snap := r.current.Load()
+	var out []model.Stream
+	for _, id := range strings.Split(tag, ",") {
+		if c, ok := snap.byID[id]; ok {
+			out = append(out, c.stream)
+		}
+	}
+	return out
+}
+
+// KeyPrefix returns the o3_prefix of the first stream e was routed into that sets one, or ""
+// for the default logs/ prefix. It is meant for batcher.BatcherOpts.KeyPrefix.
+func (r *Router) KeyPrefix(e *model.LogEntry) string {
+	for _, s := range r.Streams(e) {
+		if s.O3Prefix != "" {
+			return s.O3Prefix
+		}
+	}
+	return ""
+}
+
+// Target returns the o3_target of the first stream e was routed into that sets one, or ""
+// when none does. It is meant for batcher.BatcherOpts.Target.
+func (r *Router) Target(e *model.LogEntry) string {
+	for _, s := range r.Streams(e) {
+		if s.O3Target != "" {
+			return s.O3Target
+		}
+	}
+	return ""
+}
+
+// Outputs returns the output names listed by the streams e was routed into, without
+// duplicates. It is meant as the route of an outputs.Dispatcher.
+func (r *Router) Outputs(e *model.LogEntry) []string {
+	var names []string
+	for _, s := range r.Streams(e) {
+		for _, name := range s.Outputs {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,75 @@
+package streams
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/infrastructure/processors"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/pipeline"
+	"github.com/google/uuid"
+)
+
+func TestRouter(t *testing.T) {
+	errs := model.Stream{ID: uuid.New(), Name: "errors", Enabled: true, MatchType: model.StreamMatchAll,
+		Rules: []string{"level >= error", "service == api"}, O3Prefix: "errors"}
+	audit := model.Stream{ID: uuid.New(), Name: "audit", Enabled: true, MatchType: model.StreamMatchAny,
+		Rules: []string{"exists(audit)", "service == auth"}, O3Prefix: "audit/v1"}
+	empty := model.Stream{ID: uuid.New(), Name: "empty", Enabled: true}
+	off := model.Stream{ID: uuid.New(), Name: "off", Rules: []string{"level exists"}}
+	bad := model.Stream{ID: uuid.New(), Name: "bad", Enabled: true, Rules: []string{"level >"}}
+
+	r := NewRouter()
+	if err := r.Load([]model.Stream{errs, audit, empty, off, bad}); err == nil {
+		t.Error("invalid stream loaded without error")
+	}
+	cases := []struct {
+		entry model.LogEntry
+		want  []string
+	}{
+		{model.LogEntry{Service: "api", Level: "error"}, []string{errs.ID.String()}},
+		{model.LogEntry{Service: "api", Level: "info"}, nil},
+		{model.LogEntry{Service: "auth", Level: "fatal"}, []string{audit.ID.String()}},
+		{model.LogEntry{Service: "api", Level: "error", Tags: map[string]string{"audit": "1"}}, []string{errs.ID.String(), audit.ID.String()}},
+	}
+	for _, c := range cases {
+		if got := r.Route(&c.entry); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Route(%+v) = %v, want %v", c.entry, got, c.want)
+		}
+	}
+	if n, ok := r.Matched(errs.ID); !ok || n != 2 {
+		t.Errorf("errors matched = %d, %v", n, ok)
+	}
+	if _, ok := r.Matched(off.ID); ok {
+		t.Error("disabled stream loaded")
+	}
+
+	// The processor tags entries; the batcher prefix comes from the first stream with one.
+	pipeline.SetStreamRouter(r)
+	proc, err := processors.GlobalRegistry.Create("stream_router", processors.Config{"drop_unmatched": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := model.LogEntry{Service: "auth", Level: "error", Tags: map[string]string{pipeline.TagStreams: "stale"}}
+	if keep, err := proc.Process(&e); !keep || err != nil {
+		t.Fatalf("keep=%v err=%v", keep, err)
+	}
+	if e.Tags[pipeline.TagStreams] != audit.ID.String() || r.KeyPrefix(&e) != "audit/v1" {
+		t.Errorf("tags = %v, prefix = %q", e.Tags, r.KeyPrefix(&e))
+	}
+	if keep, _ := proc.Process(&model.LogEntry{Service: "web", Level: "info"}); keep {
+		t.Error("drop_unmatched: entry kept")
+	}
+	if p := r.KeyPrefix(&model.LogEntry{}); p != "" {
+		t.Errorf("untagged prefix = %q", p)
+	}
+
+	// Counters survive a reload of the same stream.
+	errs.Rules = []string{"level >= warn"}
+	if err := r.Load([]model.Stream{errs}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := r.Matched(errs.ID); n != 2 {
+		t.Errorf("matched after reload = %d, want 2", n)
+	}
+}
@@ -0,0 +1,174 @@
+// Package tail fans ingested entries out to live subscribers, for GET /logs/tail.
+package tail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akave-ai/akavelog/internal/batcher"
+	"github.com/akave-ai/akavelog/internal/infrastructure/inputs"
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+)
+
+// Defaults of a Hub.
+const (
+	DefaultMaxSubscribers = 100
+	DefaultQueueSize      = 256
+)
+
+// ErrTooManySubscribers is returned by Subscribe when MaxSubscribers are subscribed.
+var ErrTooManySubscribers = errors.New("too many tail subscribers")
+
+// Event is an entry as sent to subscribers; it has the shape of the /logs/recent entries.
+type Event struct {
+	Entry    model.LogEntry `json:"entry"`
+	Received time.Time      `json:"received_at"`
+}
+
+// Subscription receives the published entries matching its query and project. Entries that
+// find its queue full are dropped and counted, so a slow subscriber never holds up ingestion.
+type Subscription struct {
+	hub       *Hub
+	query     *query.Query // nil matches every entry
+	projectID string       // "" for every project
+	events    chan Event
+	dropped   atomic.Int64
+	closed    bool // hub.mu is held
+}
+
+// Events returns the subscription's entries. It is closed when the subscription or the hub is
+// closed.
+func (s *Subscription) Events() <-chan Event { return s.events }
+
+// Dropped returns how many matching entries were dropped because the queue was full.
+func (s *Subscription) Dropped() int64 { return s.dropped.Load() }
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (s *Subscription) matches(e *model.LogEntry) bool {
+	if s.projectID != "" && e.ProjectID != s.projectID {
+		return false
+	}
+	return s.query == nil || s.query.MatchEntry(e)
+}
+
+// Hub hands every published entry to its subscriptions. It is safe for concurrent use.
+type Hub struct {
+	maxSubscribers int
+	queueSize      int
+
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	n      atomic.Int32 // len(subs), read without the lock by Active
+}
+
+// NewHub returns a hub for at most maxSubscribers subscriptions, each queueing up to
+// queueSize entries. Values <= 0 take their defaults.
+func NewHub(maxSubscribers, queueSize int) *Hub {
+	if maxSubscribers <= 0 {
+		maxSubscribers = DefaultMaxSubscribers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Hub{maxSubscribers: maxSubscribers, queueSize: queueSize, subs: make(map[*Subscription]struct{})}
+}
+
+// Active reports whether anyone is subscribed, so publishers can skip decoding entries.
+func (h *Hub) Active() bool { return h.n.Load() > 0 }
+
+// Subscribe returns a subscription to the entries of projectID ("" for all) matching q (nil
+// for all).
+func (h *Hub) Subscribe(q *query.Query, projectID string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.New("tail is closed")
+	}
+	if len(h.subs) >= h.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s := &Subscription{hub: h, query: q, projectID: projectID, events: make(chan Event, h.queueSize)}
+	h.subs[s] = struct{}{}
+	h.n.Add(1)
+	return s, nil
+}
+
+// Publish hands e to the subscriptions it matches.
+func (h *Hub) Publish(e *model.LogEntry) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	ev := Event{Entry: *e, Received: time.Now().UTC()}
+	for s := range h.subs {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.events <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Close ends every subscription and refuses new ones, so streaming requests finish before
+// the server shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// remove ends s. h.mu is held.
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	h.n.Add(-1)
+	close(s.events)
+}
+
+// Buffer implements inputs.InputBuffer: it inserts every payload into Next and publishes the
+// valid ones Next took to the Hub while anyone is subscribed.
+type Buffer struct {
+	Hub  *Hub
+	Next inputs.InputBuffer
+}
+
+func (b *Buffer) Insert(p []byte) error {
+	return b.InsertContext(context.Background(), p)
+}
+
+// InsertContext is Insert for a payload whose trace ctx carries.
+func (b *Buffer) InsertContext(ctx context.Context, p []byte) error {
+	if err := inputs.InsertContext(ctx, b.Next, p); err != nil {
+		return err
+	}
+	if !b.Hub.Active() {
+		return nil
+	}
+	entry, err := batcher.ValidateLog(p)
+	if err != nil {
+		return nil
+	}
+	b.Hub.Publish(entry)
+	return nil
+}
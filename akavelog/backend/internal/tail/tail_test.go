@@ -0,0 +1,108 @@
+package tail
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akave-ai/akavelog/internal/model"
+	"github.com/akave-ai/akavelog/internal/query"
+)
+
+type nopBuffer struct{ n int }
+
+func (b *nopBuffer) Insert([]byte) error {
+	b.n++
+	return nil
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub(2, 2)
+	if h.Active() {
+		t.Fatal("Active without subscribers")
+	}
+	q, err := query.Parse("level:error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := h.Subscribe(q, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := h.Subscribe(nil, "p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(nil, ""); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("third subscriber: err = %v, want ErrTooManySubscribers", err)
+	}
+
+	for _, e := range []model.LogEntry{
+		{ProjectID: "p1", Level: "error", Message: "a"},
+		{ProjectID: "p2", Level: "info", Message: "b"},
+		{ProjectID: "p2", Level: "error", Message: "c"},
+		{ProjectID: "p1", Level: "error", Message: "d"},
+	} {
+		h.Publish(&e)
+	}
+	// errs queues two of the three errors and drops the last.
+	for _, want := range []string{"a", "c"} {
+		if ev := <-errs.Events(); ev.Entry.Message != want {
+			t.Errorf("errs: got %q, want %q", ev.Entry.Message, want)
+		}
+	}
+	if errs.Dropped() != 1 {
+		t.Errorf("errs dropped %d, want 1", errs.Dropped())
+	}
+	for _, want := range []string{"b", "c"} {
+		if ev := <-p2.Events(); ev.Entry.Message != want {
+			t.Errorf("p2: got %q, want %q", ev.Entry.Message, want)
+		}
+	}
+
+	errs.Close()
+	errs.Close()
+	if _, ok := <-errs.Events(); ok {
+		t.Error("closed subscription still open")
+	}
+	h.Close()
+	if _, ok := <-p2.Events(); ok {
+		t.Error("subscription open after Hub.Close")
+	}
+	if h.Active() {
+		t.Error("Active after Close")
+	}
+	if _, err := h.Subscribe(nil, ""); err == nil {
+		t.Error("Subscribe after Close succeeded")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	h := NewHub(0, 0)
+	next := &nopBuffer{}
+	b := &Buffer{Hub: h, Next: next}
+	entry := []byte(`{"service":"api","level":"info","message":"hello"}`)
+	if err := b.Insert(entry); err != nil {
+		t.Fatal(err)
+	}
+	s, err := h.Subscribe(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Insert([]byte(`not json`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Insert(entry); err != nil {
+		t.Fatal(err)
+	}
+	if next.n != 3 {
+		t.Errorf("Next got %d payloads, want 3", next.n)
+	}
+	if ev := <-s.Events(); ev.Entry.Message != "hello" || ev.Received.IsZero() {
+		t.Errorf("event %+v", ev)
+	}
+	select {
+	case ev := <-s.Events():
+		t.Errorf("unexpected event %+v", ev)
+	default:
+	}
+}
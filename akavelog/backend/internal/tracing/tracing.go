@@ -0,0 +1,128 @@
+// Package tracing sets up OpenTelemetry tracing and holds the tracer the ingest path starts its
+// spans with: HTTP requests, the ingest queue, pipeline stages, batch flushes and O3 uploads.
+// Until Setup installs a provider, spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/akave-ai/akavelog"
+
+// Options configures Setup.
+type Options struct {
+	ServiceName string
+	Environment string
+	// Endpoint is the OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces. When empty
+	// the exporter reads OTEL_EXPORTER_OTLP_ENDPOINT and friends, and defaults to localhost.
+	Endpoint string
+	// SampleRatio is the fraction of new traces recorded (default 1). Requests that carry a
+	// traceparent follow the caller's decision.
+	SampleRatio float64
+}
+
+// Setup installs a tracer provider exporting to opts.Endpoint and the W3C trace context
+// propagator. The returned function flushes the spans still queued and stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var expOpts []otlptracehttp.Option
+	if opts.Endpoint != "" {
+		expOpts = append(expOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	}
+	exp, err := otlptracehttp.New(ctx, expOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: exporter: %w", err)
+	}
+	ratio := opts.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.DeploymentEnvironmentName(opts.Environment),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Child starts a span like Start, but only when ctx carries a span being recorded: payloads
+// that arrived without a trace do not start one on the hot paths. Otherwise it returns ctx
+// and a span that records nothing.
+func Child(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return Start(ctx, name, attrs...)
+}
+
+// StartServer starts the server span of r, continuing the trace of the caller's traceparent
+// header, if any.
+func StartServer(r *http.Request, name string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+}
+
+// Handler serves every request of h under a server span named name, for listeners of their
+// own; requests the API server routes are traced by its middleware.
+func Handler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := StartServer(r, name)
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		SetStatus(span, sw.status)
+	})
+}
+
+// SetStatus records the HTTP status of a server span; 5xx marks it failed.
+func SetStatus(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// statusWriter remembers the status written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Fail records err on span and marks it failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,318 @@
+// Package wal is an append-only write-ahead log of segment files. The batcher appends every
+// accepted entry before acknowledging it and removes segments once their entries are in O3, so
+// entries survive a crash between ingest and flush.
+//
+// Each record is a 4-byte little-endian payload length, a 4-byte CRC-32 (Castagnoli) of the
+// payload, and the payload. On Open, every existing segment is read up to its first torn or
+// corrupt record and the records are handed back for replay.
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy says when appended records are fsynced. Records reach the OS on every Append, so
+// all policies survive a process crash; they differ on power loss or a kernel crash.
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync on every Append
+	SyncInterval SyncPolicy = "interval" // fsync every Options.SyncInterval
+	SyncNever    SyncPolicy = "never"    // leave it to the OS
+)
+
+const (
+	headerSize = 8
+	segmentExt = ".wal"
+
+	// DefaultSegmentSize is the size at which a segment is closed and a new one started.
+	DefaultSegmentSize = 64 << 20
+	// DefaultSyncInterval is how often SyncInterval fsyncs.
+	DefaultSyncInterval = time.Second
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorrupt marks a record that fails its length or CRC check.
+var errCorrupt = errors.New("corrupt record")
+
+// Options configure a Log.
+type Options struct {
+	Dir          string
+	SegmentSize  int64         // default DefaultSegmentSize
+	Sync         SyncPolicy    // default SyncInterval
+	SyncInterval time.Duration // default DefaultSyncInterval
+}
+
+// Log is a write-ahead log in Options.Dir. It is safe for concurrent use.
+type Log struct {
+	opts   Options
+	replay [][]byte
+
+	mu      sync.Mutex
+	f       *os.File
+	seq     uint64   // sequence number of f
+	size    int64    // bytes written to f
+	dirty   bool     // f has writes not yet fsynced
+	pending []uint64 // closed segments not yet removed
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open opens the log in opts.Dir, creating the directory if needed. Records found in existing
+// segments are returned by Replay; their segments are removed by the first Checkpoint/Remove
+// that covers them.
+func Open(opts Options) (*Log, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("wal: dir is required")
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if opts.Sync == "" {
+		opts.Sync = SyncInterval
+	}
+	switch opts.Sync {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("wal: fsync must be always, interval or never")
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultSyncInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("wal: %w", err)
+	}
+	seqs, err := segments(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{opts: opts, pending: seqs}
+	for _, seq := range seqs {
+		recs, err := readSegment(l.path(seq))
+		if err != nil {
+			log.Printf("[wal] %s: %v; replaying %d records before it", l.path(seq), err, len(recs))
+		}
+		l.replay = append(l.replay, recs...)
+	}
+	if len(seqs) > 0 {
+		l.seq = seqs[len(seqs)-1]
+	}
+	if err := l.openSegment(); err != nil {
+		return nil, err
+	}
+	if opts.Sync == SyncInterval {
+		l.stop, l.done = make(chan struct{}), make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+// Replay returns the records found on Open, oldest first, and forgets them.
+func (l *Log) Replay() [][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := l.replay
+	l.replay = nil
+	return r
+}
+
+// Append writes one record.
+func (l *Log) Append(p []byte) error {
+	_, err := l.AppendSegment(p)
+	return err
+}
+
+// AppendSegment writes one record and returns the sequence number of the segment it went to,
+// for callers that track which segments still hold records they have not stored elsewhere.
+func (l *Log) AppendSegment(p []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, fmt.Errorf("wal: closed")
+	}
+	if l.size > 0 && l.size+headerSize+int64(len(p)) > l.opts.SegmentSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	buf := make([]byte, headerSize+len(p))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(p)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(p, crcTable))
+	copy(buf[headerSize:], p)
+	n, err := l.f.Write(buf)
+	l.size += int64(n)
+	if err != nil {
+		return l.seq, fmt.Errorf("wal: write: %w", err)
+	}
+	if l.opts.Sync == SyncAlways {
+		if err := l.f.Sync(); err != nil {
+			return l.seq, fmt.Errorf("wal: fsync: %w", err)
+		}
+		return l.seq, nil
+	}
+	l.dirty = true
+	return l.seq, nil
+}
+
+// Checkpoint closes the current segment if it has records and returns every segment closed
+// since the last Checkpoint (including those found on Open). Records appended before the call
+// are in those segments; pass them to Remove once the records are stored elsewhere.
+func (l *Log) Checkpoint() ([]uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, fmt.Errorf("wal: closed")
+	}
+	if l.size > 0 {
+		if err := l.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	segs := l.pending
+	l.pending = nil
+	return segs, nil
+}
+
+// Remove deletes the given closed segments.
+func (l *Log) Remove(segs []uint64) {
+	for _, seq := range segs {
+		if err := os.Remove(l.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[wal] remove segment: %v", err)
+		}
+	}
+}
+
+// Close fsyncs and closes the current segment, removing it when it is empty.
+func (l *Log) Close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	err := l.f.Sync()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if l.size == 0 {
+		os.Remove(l.path(l.seq))
+	}
+	return err
+}
+
+func (l *Log) syncLoop() {
+	defer close(l.done)
+	t := time.NewTicker(l.opts.SyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			l.mu.Lock()
+			if l.dirty && !l.closed {
+				if err := l.f.Sync(); err != nil {
+					log.Printf("[wal] fsync: %v", err)
+				}
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// rotate closes the current segment and starts the next. l.mu must be held.
+func (l *Log) rotate() error {
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("wal: fsync: %w", err)
+	}
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("wal: close segment: %w", err)
+	}
+	l.pending = append(l.pending, l.seq)
+	return l.openSegment()
+}
+
+// openSegment creates segment l.seq+1. l.mu must be held (or l not yet shared).
+func (l *Log) openSegment() error {
+	l.seq++
+	f, err := os.OpenFile(l.path(l.seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal: %w", err)
+	}
+	l.f, l.size, l.dirty = f, 0, false
+	return nil
+}
+
+func (l *Log) path(seq uint64) string {
+	return filepath.Join(l.opts.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// segments returns the sequence numbers of the segments in dir, in order.
+func segments(dir string) ([]uint64, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("wal: %w", err)
+	}
+	var seqs []uint64
+	for _, e := range ents {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// readSegment returns the records of one segment. A torn or corrupt record ends the segment;
+// the records before it are returned with the error.
+func readSegment(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recs [][]byte
+	for off := 0; off < len(data); {
+		if len(data)-off < headerSize {
+			return recs, fmt.Errorf("%w at offset %d: %v", errCorrupt, off, io.ErrUnexpectedEOF)
+		}
+		n := int(binary.LittleEndian.Uint32(data[off : off+4]))
+		sum := binary.LittleEndian.Uint32(data[off+4 : off+8])
+		off += headerSize
+		if n > len(data)-off {
+			return recs, fmt.Errorf("%w at offset %d: %v", errCorrupt, off-headerSize, io.ErrUnexpectedEOF)
+		}
+		p := data[off : off+n]
+		if crc32.Checksum(p, crcTable) != sum {
+			return recs, fmt.Errorf("%w at offset %d: checksum mismatch", errCorrupt, off-headerSize)
+		}
+		recs = append(recs, p)
+		off += n
+	}
+	return recs, nil
+}
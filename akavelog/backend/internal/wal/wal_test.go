@@ -0,0 +1,95 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendReplayAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir, SegmentSize: 64, Sync: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"first record", "second record", "third record"} {
+		if err := l.Append([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close() // as after a crash: nothing checkpointed
+
+	l, err = Open(Options{Dir: dir, SegmentSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := l.Replay()
+	if len(recs) != 3 || string(recs[0]) != "first record" || string(recs[2]) != "third record" {
+		t.Fatalf("replay = %q", recs)
+	}
+	if len(l.Replay()) != 0 {
+		t.Error("Replay returned records twice")
+	}
+	l.Append([]byte("fourth record"))
+	segs, err := l.Checkpoint()
+	if err != nil || len(segs) < 2 {
+		t.Fatalf("Checkpoint = %v, %v", segs, err)
+	}
+	l.Remove(segs)
+	l.Close()
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt)); len(files) != 0 {
+		t.Errorf("segments left after remove and close: %v", files)
+	}
+}
+
+func TestTornRecordEndsSegment(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir, Sync: SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([]byte("kept"))
+	l.Append([]byte("torn"))
+	path := l.path(l.seq)
+	l.Close()
+	fi, _ := os.Stat(path)
+	if err := os.Truncate(path, fi.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if recs := l.Replay(); len(recs) != 1 || string(recs[0]) != "kept" {
+		t.Errorf("replay = %q", recs)
+	}
+}
+
+func TestCorruptRecordEndsSegment(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := Open(Options{Dir: dir, Sync: SyncNever})
+	l.Append([]byte("good"))
+	l.Append([]byte("flipped"))
+	path := l.path(l.seq)
+	l.Close()
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	l, _ = Open(Options{Dir: dir})
+	defer l.Close()
+	if recs := l.Replay(); len(recs) != 1 || string(recs[0]) != "good" {
+		t.Errorf("replay = %q", recs)
+	}
+}
+
+func TestOpenValidatesOptions(t *testing.T) {
+	if _, err := Open(Options{}); err == nil {
+		t.Error("empty dir accepted")
+	}
+	if _, err := Open(Options{Dir: t.TempDir(), Sync: "sometimes"}); err == nil {
+		t.Error("unknown sync policy accepted")
+	}
+}
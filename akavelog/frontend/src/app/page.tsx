@@ -6,10 +6,10 @@ import {
   deleteInput,
   getInputs,
   getIngestBaseUrlForInput,
-  getLogsFromIngest,
   getTypeInfo,
   getUploadStatus,
   sendTestLog,
+  tailLogs,
   updateInput,
   type InputItem,
   type InputTypeInfo,
@@ -31,6 +31,8 @@ export default function DemoPage() {
   const [editFormValues, setEditFormValues] = useState<Record<string, string>>({});
   const [updating, setUpdating] = useState(false);
   const [deletingId, setDeletingId] = useState<string | null>(null);
+  // Ingest keys of the inputs created in this session; they are only returned on create.
+  const [ingestKeys, setIngestKeys] = useState<Record<string, string>>({});
 
   const loadInputs = useCallback(async () => {
     try {
@@ -42,16 +44,6 @@ export default function DemoPage() {
     }
   }, []);
 
-  const loadLogs = useCallback(async () => {
-    try {
-      // GET ingest endpoint directly (raw HTTP) to fetch logs
-      const { logs: list } = await getLogsFromIngest('raw');
-      setLogs(list);
-    } catch {
-      // ignore
-    }
-  }, []);
-
   const loadStatus = useCallback(async () => {
     try {
       const st = await getUploadStatus();
@@ -79,12 +71,18 @@ export default function DemoPage() {
   }, []);
 
   useEffect(() => {
-    const t = setInterval(() => {
-      loadLogs();
-      loadStatus();
-    }, 2000);
+    // Live tail: the last 200 entries first, then each one as it is ingested. The backlog is
+    // sent again after a reconnect, so the list restarts with it.
+    return tailLogs((log) => setLogs((prev) => [...prev, log].slice(-200)), {
+      backlog: 200,
+      onOpen: () => setLogs([]),
+    });
+  }, []);
+
+  useEffect(() => {
+    const t = setInterval(loadStatus, 2000);
     return () => clearInterval(t);
-  }, [loadLogs, loadStatus]);
+  }, [loadStatus]);
 
   const handleCreate = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -96,11 +94,13 @@ export default function DemoPage() {
         const trimmed = typeof v === 'string' ? v.trim() : v;
         if (trimmed !== '') config[k] = trimmed;
       });
-      await createInput({
+      const created = await createInput({
         type: 'http',
         title: newTitle.trim() || undefined,
         config: Object.keys(config).length > 0 ? config : undefined,
       });
+      const key = created.ingest_key;
+      if (key) setIngestKeys((prev) => ({ ...prev, [created.id]: key }));
       await loadInputs();
       setNewTitle('');
       if (httpTypeInfo) {
@@ -129,9 +129,9 @@ export default function DemoPage() {
           level: 'info',
           tags: { source: 'web' },
         },
-        { baseUrl: baseUrl ?? undefined }
+        { baseUrl: baseUrl ?? undefined, ingestKey: ingestKeys[input.id] }
       );
-      await loadLogs();
+      await loadStatus();
     } catch (e) {
       setError(e instanceof Error ? e.message : 'Send failed');
     }
@@ -154,7 +154,7 @@ export default function DemoPage() {
     setEditingId(null);
   };
 
-  const handleUpdate = async (e: React.FormEvent, id: string) => {
+  const handleUpdate = async (e: React.FormEvent, id: string, version: number) => {
     e.preventDefault();
     setUpdating(true);
     setError(null);
@@ -164,7 +164,7 @@ export default function DemoPage() {
         const trimmed = typeof v === 'string' ? v.trim() : v;
         if (trimmed !== '') config[k] = trimmed;
       });
-      await updateInput(id, {
+      await updateInput(id, version, {
         title: editTitle.trim() || undefined,
         config: Object.keys(config).length > 0 ? config : undefined,
       });
@@ -277,7 +277,7 @@ export default function DemoPage() {
                 >
                   {editingId === inp.id && httpTypeInfo ? (
                     <form
-                      onSubmit={(e) => handleUpdate(e, inp.id)}
+                      onSubmit={(e) => handleUpdate(e, inp.id, inp.version)}
                       className="p-3 space-y-3"
                     >
                       <div className="flex flex-wrap items-end gap-3">
@@ -328,6 +328,11 @@ export default function DemoPage() {
                       <span className="font-mono text-[var(--accent)]">{inp.title}</span>
                       <span className="text-[var(--muted)]">{ingestDisplayPath(inp)}</span>
                       <span className="text-xs text-[var(--success)]">{inp.state}</span>
+                      {ingestKeys[inp.id] && (
+                        <span className="font-mono text-xs text-[var(--muted)]" title="Ingest key; shown only in this session">
+                          {ingestKeys[inp.id]}
+                        </span>
+                      )}
                       <div className="flex gap-1.5">
                         <button
                           type="button"
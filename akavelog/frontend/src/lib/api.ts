@@ -1,4 +1,13 @@
 const API = process.env.NEXT_PUBLIC_API_URL || '/api';
+// API key sent with every request when the backend requires one (use a key with the read and write scopes).
+const API_KEY = process.env.NEXT_PUBLIC_API_KEY || '';
+
+function withKey(init?: RequestInit): RequestInit {
+  if (!API_KEY) return init ?? {};
+  const headers = new Headers(init?.headers);
+  headers.set('X-API-Key', API_KEY);
+  return { ...init, headers };
+}
 
 // Standard API response shape (success)
 export type APIResponse<T = unknown> = {
@@ -17,7 +26,7 @@ export type APIError = {
 };
 
 async function request<T>(url: string, init?: RequestInit): Promise<T> {
-  const r = await fetch(url, init);
+  const r = await fetch(url, withKey(init));
   const body = await r.json().catch(() => ({}));
   if (!r.ok) {
     const err = body as Partial<APIError>;
@@ -48,7 +57,12 @@ export type InputItem = {
   title: string;
   configuration: Record<string, unknown>;
   created_at: string;
+  updated_at: string;
+  /** Changes with every update; updates name the version they are based on. */
+  version: number;
   state: string;
+  /** Returned once, by createInput; the input's listener requires it. */
+  ingest_key?: string;
 };
 
 export type RawRequestData = {
@@ -88,7 +102,7 @@ export async function getTypeInfo(typeName: string): Promise<InputTypeInfo> {
 }
 
 export async function getInputs(): Promise<{ inputs: InputItem[] }> {
-  return request<{ inputs: InputItem[] }>(`${API}/inputs`);
+  return request<{ inputs: InputItem[] }>(`${API}/inputs?limit=1000`);
 }
 
 export async function createInput(body: {
@@ -105,8 +119,10 @@ export async function createInput(body: {
   });
 }
 
+/** PATCHes an input edited at version; fails with "input was changed" when someone else updated it since. */
 export async function updateInput(
   id: string,
+  version: number,
   body: {
     title?: string;
     description?: string;
@@ -115,8 +131,8 @@ export async function updateInput(
   }
 ): Promise<InputItem> {
   return request<InputItem>(`${API}/inputs/${encodeURIComponent(id)}`, {
-    method: 'PUT',
-    headers: { 'Content-Type': 'application/json' },
+    method: 'PATCH',
+    headers: { 'Content-Type': 'application/json', 'If-Match': `"${version}"` },
     body: JSON.stringify(body),
   });
 }
@@ -131,6 +147,36 @@ export async function getRecentLogs(): Promise<{ logs: LogEntry[] }> {
   return request<{ logs: LogEntry[] }>(`${API}/logs/recent`);
 }
 
+/**
+ * Follow GET /logs/tail as server-sent events: calls onLog for each entry (the last `backlog`
+ * ones first) and onDropped with the total of entries missed by a slow client. EventSource
+ * reconnects by itself after errors, calling onOpen each time. Returns a function that closes
+ * the stream.
+ */
+export function tailLogs(
+  onLog: (log: LogEntry) => void,
+  opts: {
+    query?: string;
+    projectId?: string;
+    backlog?: number;
+    onOpen?: () => void;
+    onDropped?: (n: number) => void;
+  } = {},
+): () => void {
+  const params = new URLSearchParams();
+  if (opts.query) params.set('query', opts.query);
+  if (opts.projectId) params.set('project_id', opts.projectId);
+  if (opts.backlog) params.set('backlog', String(opts.backlog));
+  // EventSource cannot send headers; the backend takes the key from api_key for event streams.
+  if (API_KEY) params.set('api_key', API_KEY);
+  const qs = params.toString();
+  const es = new EventSource(`${API}/logs/tail${qs ? `?${qs}` : ''}`);
+  if (opts.onOpen) es.onopen = opts.onOpen;
+  es.addEventListener('log', (ev) => onLog(JSON.parse((ev as MessageEvent).data) as LogEntry));
+  es.addEventListener('dropped', (ev) => opts.onDropped?.((JSON.parse((ev as MessageEvent).data) as { dropped: number }).dropped));
+  return () => es.close();
+}
+
 /** GET the ingest endpoint directly (raw HTTP); returns recent logs with full response details. */
 export async function getLogsFromIngest(ingestPath: string): Promise<{ logs: LogEntry[] }> {
   const path = ingestPath.replace(/^\/+|\/+$/g, '') || 'raw';
@@ -166,7 +212,7 @@ export function getIngestBaseUrlForInput(input: InputItem): string | null {
 export async function sendTestLog(
   ingestPath: string,
   payload: object,
-  options?: { baseUrl?: string | null }
+  options?: { baseUrl?: string | null; ingestKey?: string }
 ): Promise<void> {
   const path = ingestPath.replace(/^\/+|\/+$/g, '');
   const base = options?.baseUrl ?? API;
@@ -175,11 +221,13 @@ export async function sendTestLog(
     base && base.startsWith('http')
       ? `${base.replace(/\/+$/, '')}/ingest${pathPart}`
       : `${API}/ingest${pathPart || '/raw'}`;
-  const r = await fetch(url, {
+  const headers: Record<string, string> = { 'Content-Type': 'application/json' };
+  if (options?.ingestKey) headers['X-Akavelog-Token'] = options.ingestKey;
+  const r = await fetch(url, withKey({
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers,
     body: JSON.stringify(payload),
-  });
+  }));
   const body = await r.json().catch(() => ({}));
   if (!r.ok) {
     const err = body as Partial<APIError>;